@@ -15,12 +15,20 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
 	"os"
 
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/k8sapi"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/supportbundle"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/audit"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/eventrecorder"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/paths"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/version"
 )
 
@@ -32,9 +40,34 @@ func _main() int {
 	//Do not add anything before initializing logger
 	log := logger.Get()
 
+	migrateCheckpoint := flag.Bool("migrate-checkpoint", false, "convert the IPAM checkpoint file to the current format and exit")
+	dryRun := flag.Bool("dry-run", false, "with -migrate-checkpoint, only report what would be converted")
+	diffSnapshots := flag.Bool("diff-snapshots", false,
+		"print the differences between the two datastore snapshot files given as arguments and exit")
+	collect := flag.Bool("collect", false, "write a support bundle of the aws-node and node network state and exit")
+	collectOutput := flag.String("collect-output", "", "with -collect, the path of the bundle, in the log directory by default")
+	redact := flag.String("redact", supportbundle.DefaultRedaction,
+		"with -collect, what to redact from the bundle: a list of ips and account-ids, or none")
+	flag.Parse()
+	if *migrateCheckpoint {
+		return runCheckpointMigration(*dryRun)
+	}
+	if *diffSnapshots {
+		return runSnapshotDiff(flag.Args())
+	}
+	if *collect {
+		return runCollect(*collectOutput, *redact)
+	}
+
 	log.Infof("Starting L-IPAMD %s  ...", version.Version)
 	version.RegisterMetric()
 
+	// Fail fast with the variable to set when the run dir is on a read-only root filesystem
+	if err := paths.ValidateWritableDir(paths.RunDir(), paths.EnvRunDir); err != nil {
+		log.Errorf("Invalid run directory: %v", err)
+		return 1
+	}
+
 	//Check API Server Connectivity
 	if err := k8sapi.CheckAPIServerConnectivity(); err != nil {
 		log.Errorf("Failed to check API server connectivity: %s", err)
@@ -60,6 +93,7 @@ func _main() int {
 	}
 
 	eventrecorder.InitEventRecorder(rawK8SClient)
+	audit.Init()
 
 	ipamContext, err := ipamd.New(rawK8SClient, cacheK8SClient)
 	if err != nil {
@@ -67,9 +101,45 @@ func _main() int {
 		return 1
 	}
 
+	// Report misconfigurations through an event and the introspection endpoint
+	go ipamContext.RunStartupValidation()
+
+	// Removal of the startup taint once ipamd is ready
+	go ipamContext.StartStartupTaintManager()
+
 	// Pool manager
 	go ipamContext.StartNodeIPPoolManager()
 
+	// Stale pod rule and route scrubber
+	go ipamContext.StartStaleRuleScrubber()
+
+	// Auto Scaling lifecycle state of the instance
+	go ipamContext.StartLifecycleWatcher()
+
+	// Retries of the pod teardowns failed by the CNI plugin
+	go ipamContext.StartTeardownQueue()
+
+	// Availability of /28 prefixes in the subnet, with prefix delegation
+	go ipamContext.StartSubnetPrefixProbe()
+
+	// Kernel settings of the interfaces
+	go ipamContext.StartSysctlReconciler()
+
+	// Links of the secondary ENIs renamed, bounced or enslaved after the ENI was attached
+	go ipamContext.StartENILinkWatcher()
+
+	// Packets dropped by the pod IMDS block
+	go ipamContext.StartPodIMDSBlockMonitor()
+
+	// Conntrack table usage of the node
+	go ipamContext.StartConntrackMonitor()
+
+	// Datastore invariants checker
+	go ipamContext.StartDatastoreInvariantsChecker()
+
+	// Cluster-wide pool metrics, served by the elected aws-node pod
+	go ipamContext.StartClusterMetricsAggregator()
+
 	// Prometheus metrics
 	go ipamContext.ServeMetrics()
 
@@ -84,3 +154,73 @@ func _main() int {
 	}
 	return 0
 }
+
+// runCheckpointMigration converts the IPAM checkpoint file and prints the migration report
+func runCheckpointMigration(dryRun bool) int {
+	log := logger.Get()
+	report, err := ipamd.MigrateCheckpoint(dryRun)
+	if err != nil {
+		log.Errorf("Failed to migrate the IPAM checkpoint: %v", err)
+		return 1
+	}
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Errorf("Failed to marshal the checkpoint migration report: %v", err)
+		return 1
+	}
+	fmt.Println(string(out))
+	return 0
+}
+
+// runSnapshotDiff prints the differences between two snapshots saved from the /v1/datastore-snapshot introspection
+// endpoint, e.g. before and after an incident
+func runSnapshotDiff(files []string) int {
+	log := logger.Get()
+	if len(files) != 2 {
+		log.Errorf("-diff-snapshots takes the before and the after snapshot files, got %d arguments", len(files))
+		return 1
+	}
+	var snapshots [2]datastore.Snapshot
+	for i, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Errorf("Failed to read the datastore snapshot: %v", err)
+			return 1
+		}
+		if err := json.Unmarshal(data, &snapshots[i]); err != nil {
+			log.Errorf("Failed to parse the datastore snapshot %s: %v", file, err)
+			return 1
+		}
+	}
+	diff := datastore.DiffSnapshots(snapshots[0], snapshots[1])
+	if diff.Empty() {
+		fmt.Println("The snapshots are the same")
+		return 0
+	}
+	if err := diff.Print(os.Stdout); err != nil {
+		log.Errorf("Failed to print the snapshot differences: %v", err)
+		return 1
+	}
+	return 0
+}
+
+// runCollect writes a support bundle, replacing the aws-cni-support.sh script
+func runCollect(output, redact string) int {
+	log := logger.Get()
+	opts := supportbundle.DefaultOptions()
+	if output != "" {
+		opts.Output = output
+	}
+	redaction, err := supportbundle.ParseRedaction(redact)
+	if err != nil {
+		log.Errorf("Invalid -redact: %v", err)
+		return 1
+	}
+	opts.Redaction = redaction
+	if err := supportbundle.Collect(opts); err != nil {
+		log.Errorf("Failed to collect the support bundle: %v", err)
+		return 1
+	}
+	fmt.Println(opts.Output)
+	return 0
+}
@@ -0,0 +1,147 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// CNI installer binary copying the CNI plugin binaries and network configuration to the host once ipamd is up
+package main
+
+import (
+	"context"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/cniinstaller"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/paths"
+)
+
+const (
+	// ipamdHealthServiceName is the gRPC health service of ipamd that reports liveness
+	ipamdHealthServiceName = "grpc.health.v1.aws-node"
+	healthCheckInterval    = time.Second
+	legacyConfName         = "aws.conf"
+)
+
+var (
+	// pluginBins are the binaries of the upstream plugins, only installed when there is no init container
+	pluginBins = []string{"loopback", "portmap", "bandwidth", "host-local"}
+	cniBins    = []string{"aws-cni", "egress-v4-cni"}
+)
+
+func main() {
+	os.Exit(_main())
+}
+
+func _main() int {
+	log := logger.New(&logger.Configuration{LogLevel: logger.GetLogLevel(), LogLocation: "stdout"})
+
+	binDir := flag.String("bin-dir", paths.CNIBinDir(), "directory of the CNI binaries")
+	confDir := flag.String("conf-dir", paths.CNIConfDir(), "directory of the CNI network configuration")
+	template := flag.String("conflist-template", cniinstaller.ConflistName, "template of the CNI network configuration")
+	healthAddr := flag.String("health-addr", "127.0.0.1:50051", "gRPC address of ipamd")
+	healthTimeout := flag.Duration("health-timeout", 30*time.Second, "time for ipamd to report healthy once the CNI is installed, before the installation is rolled back")
+	flag.Parse()
+
+	for dir, env := range map[string]string{*binDir: paths.EnvCNIBinDir, *confDir: paths.EnvCNIConfDir} {
+		if err := paths.ValidateWritableDir(dir, env); err != nil {
+			log.Errorf("Failed to install the CNI: %v", err)
+			return 1
+		}
+	}
+
+	bins := cniBins
+	if os.Getenv("AWS_VPC_K8S_CNI_CONFIGURE_RPFILTER") != "false" {
+		bins = append(pluginBins, cniBins...)
+	}
+	installer := cniinstaller.New(*binDir, *confDir)
+	if err := install(installer, bins, *template); err != nil {
+		log.Errorf("Failed to install the CNI: %v", err)
+		rollback(log, installer)
+		return 1
+	}
+	log.Info("Installed the CNI plugin binaries and network configuration, checking the health of ipamd")
+
+	if err := waitForHealthy(*healthAddr, *healthTimeout); err != nil {
+		log.Errorf("ipamd is not healthy after installing the CNI: %v", err)
+		rollback(log, installer)
+		return 1
+	}
+
+	if err := os.Remove(filepath.Join(*confDir, legacyConfName)); err != nil && !os.IsNotExist(err) {
+		log.Warnf("Failed to remove the legacy network configuration %s: %v", legacyConfName, err)
+	}
+	log.Info("Successfully installed the CNI plugin binaries and network configuration")
+	return 0
+}
+
+func install(installer *cniinstaller.Installer, bins []string, template string) error {
+	// Generate the network configuration first, so that invalid overrides don't touch the host
+	data, err := ioutil.ReadFile(template)
+	if err != nil {
+		return err
+	}
+	cfg := cniinstaller.ConflistConfigFromEnv()
+	if cfg.Overrides, err = cniinstaller.LoadConflistOverrides(); err != nil {
+		return err
+	}
+	conflist, err := cniinstaller.GenerateConflist(data, cfg)
+	if err != nil {
+		return err
+	}
+	for _, bin := range bins {
+		if err := installer.InstallBinary(bin); err != nil {
+			return err
+		}
+	}
+	return installer.InstallConflist(conflist)
+}
+
+func rollback(log logger.Logger, installer *cniinstaller.Installer) {
+	if err := installer.Rollback(); err != nil {
+		log.Errorf("Failed to roll back the CNI installation: %v", err)
+		return
+	}
+	log.Info("Rolled back the CNI plugin binaries and network configuration to their previous version")
+}
+
+// waitForHealthy polls the gRPC health service of ipamd until it is serving
+func waitForHealthy(addr string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, addr, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+	for {
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: ipamdHealthServiceName})
+		if err == nil && resp.GetStatus() == healthpb.HealthCheckResponse_SERVING {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			if err == nil {
+				return errors.Errorf("ipamd reports %s", resp.GetStatus())
+			}
+			return err
+		case <-time.After(healthCheckInterval):
+		}
+	}
+}
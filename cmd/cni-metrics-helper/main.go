@@ -23,16 +23,28 @@ import (
 	"time"
 
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/spf13/pflag"
 
 	"github.com/aws/amazon-vpc-cni-k8s/cmd/cni-metrics-helper/metrics"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils/awssession"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ec2metadatawrapper"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ec2wrapper"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/k8sapi"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/publisher"
 )
 
+// subnetMetricsInterval is the interval between two collections of the subnet metrics, which cost a few EC2 calls
+const subnetMetricsInterval = 5 * time.Minute
+
 type options struct {
-	submitCW bool
-	help     bool
+	submitCW      bool
+	subnetMetrics bool
+	cwRegion      string
+	cwNamespace   string
+	cwRoleARN     string
+	cwDimensions  string
+	help          bool
 }
 
 func main() {
@@ -47,6 +59,10 @@ func main() {
 	flags := pflag.NewFlagSet("", pflag.ExitOnError)
 	flags.AddGoFlagSet(flag.CommandLine)
 	flags.BoolVar(&options.submitCW, "cloudwatch", true, "a bool")
+	flags.StringVar(&options.cwRegion, "cloudwatch-region", "", "region to publish the metrics to, the region of the cluster by default")
+	flags.StringVar(&options.cwNamespace, "cloudwatch-namespace", "", "CloudWatch namespace of the metrics, Kubernetes by default")
+	flags.StringVar(&options.cwRoleARN, "cloudwatch-role-arn", "", "IAM role to assume to publish the metrics")
+	flags.StringVar(&options.cwDimensions, "cloudwatch-dimensions", "", "comma separated <name>=<value> dimensions added to the metrics")
 
 	flags.Usage = func() {
 		_, _ = fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
@@ -80,6 +96,22 @@ func main() {
 		}
 	}
 
+	// The environment, which can come from a ConfigMap, overrides the flags
+	for env, value := range map[string]*string{
+		"CLOUDWATCH_REGION":     &options.cwRegion,
+		"CLOUDWATCH_NAMESPACE":  &options.cwNamespace,
+		"CLOUDWATCH_ROLE_ARN":   &options.cwRoleARN,
+		"CLOUDWATCH_DIMENSIONS": &options.cwDimensions,
+	} {
+		if val, found := os.LookupEnv(env); found {
+			*value = val
+		}
+	}
+
+	// Publishing the subnet metrics needs extra EC2 permissions, so it is opt-in
+	subnetENV, _ := os.LookupEnv("PUBLISH_SUBNET_METRICS")
+	options.subnetMetrics = strings.Compare(subnetENV, "yes") == 0 || strings.Compare(subnetENV, "true") == 0
+
 	// Fetch region, if using IRSA it be will auto injected as env variable in pod spec
 	// If not found then it will be empty, in which case we will try to fetch it from IMDS (existing approach)
 	// This can also mean that Cx is not using IRSA and we shouldn't enforce IRSA requirement
@@ -88,7 +120,8 @@ func main() {
 	// should be name/identifier for the cluster if specified
 	clusterID, _ := os.LookupEnv("AWS_CLUSTER_ID")
 
-	log.Infof("Starting CNIMetricsHelper. Sending metrics to CloudWatch: %v, LogLevel %s", options.submitCW, logConfig.LogLevel)
+	log.Infof("Starting CNIMetricsHelper. Sending metrics to CloudWatch: %v, subnet metrics: %v, LogLevel %s",
+		options.submitCW, options.subnetMetrics, logConfig.LogLevel)
 
 	clientSet, err := k8sapi.GetKubeClientSet()
 	if err != nil {
@@ -116,12 +149,34 @@ func main() {
 	var cw publisher.Publisher
 
 	if options.submitCW {
-		cw, err = publisher.New(ctx, region, clusterID, log)
+		dimensions, err := publisher.ParseDimensions(options.cwDimensions)
+		if err != nil {
+			log.Fatalf("Invalid CloudWatch dimensions: %v", err)
+		}
+		cwRegion := options.cwRegion
+		if cwRegion == "" {
+			cwRegion = region
+		}
+		cw, err = publisher.New(ctx, publisher.Config{
+			Region:     cwRegion,
+			ClusterID:  clusterID,
+			Namespace:  options.cwNamespace,
+			RoleARN:    options.cwRoleARN,
+			Dimensions: dimensions,
+		}, log)
 		if err != nil {
 			log.Fatalf("Failed to create publisher: %v", err)
 		}
 		go cw.Start()
 		defer cw.Stop()
+
+		if options.subnetMetrics {
+			ec2Client, err := newEC2Client(region)
+			if err != nil {
+				log.Fatalf("Failed to create EC2 client for subnet metrics: %v", err)
+			}
+			go publishSubnetMetrics(ctx, metrics.NewSubnetMetrics(ec2Client, clientSet, cw, log), log)
+		}
 	}
 
 	podWatcher := metrics.NewDefaultPodWatcher(k8sClient, log)
@@ -134,3 +189,26 @@ func main() {
 		metrics.Handler(ctx, cniMetric)
 	}
 }
+
+// newEC2Client returns an EC2 client for region, or for the region of the instance if region is empty
+func newEC2Client(region string) (ec2wrapper.EC2, error) {
+	sess := awssession.New()
+	if region == "" {
+		val, err := ec2metadatawrapper.New(sess).Region()
+		if err != nil {
+			return nil, err
+		}
+		region = val
+	}
+	return ec2wrapper.New(sess.Copy(&aws.Config{Region: aws.String(region)})), nil
+}
+
+// publishSubnetMetrics publishes the subnet metrics every subnetMetricsInterval
+func publishSubnetMetrics(ctx context.Context, subnetMetrics *metrics.SubnetMetrics, log logger.Logger) {
+	for range time.Tick(subnetMetricsInterval) {
+		log.Info("Collecting subnet metrics ...")
+		if err := subnetMetrics.Publish(ctx); err != nil {
+			log.Errorf("Failed to collect subnet metrics: %v", err)
+		}
+	}
+}
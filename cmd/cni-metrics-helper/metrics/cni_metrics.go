@@ -147,6 +147,18 @@ var InterestingCNIMetrics = map[string]metricsConvert{
 				actionFunc: metricsAdd,
 				data:       &dataPoints{},
 				logToFile:  true}}},
+	"awscni_trunk_branch_enis_used": {
+		actions: []metricsAction{
+			{cwMetricName: "trunkBranchENIsUsed",
+				matchFunc:  matchAny,
+				actionFunc: metricsAdd,
+				data:       &dataPoints{}}}},
+	"awscni_trunk_branch_enis_free": {
+		actions: []metricsAction{
+			{cwMetricName: "trunkBranchENIsFree",
+				matchFunc:  matchAny,
+				actionFunc: metricsAdd,
+				data:       &dataPoints{}}}},
 }
 
 // CNIMetricsTarget defines data structure for kube-state-metric target
@@ -0,0 +1,282 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metrics
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ec2wrapper"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/publisher"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
+)
+
+const (
+	subnetIDDimension         = "SubnetId"
+	availabilityZoneDimension = "AvailabilityZone"
+
+	// describeInstancesBatchSize is the number of instance IDs passed to a single DescribeInstances call
+	describeInstancesBatchSize = 100
+
+	// ipv4PrefixLength is the length of the prefixes assigned to ENIs in prefix delegation mode
+	ipv4PrefixLength = 28
+)
+
+// SubnetMetrics publishes the free IP addresses and the free /28 prefixes of the subnets the cluster nodes have ENIs in,
+// per subnet and per availability zone, so that alarms can be set before a subnet runs out of addresses.
+type SubnetMetrics struct {
+	ec2Client ec2wrapper.EC2
+	clientSet kubernetes.Interface
+	cw        publisher.Publisher
+	log       logger.Logger
+}
+
+// subnetStats are the free address counts of a subnet
+type subnetStats struct {
+	subnetID         string
+	availabilityZone string
+	availableIPs     int
+	freePrefixes     int
+}
+
+// NewSubnetMetrics creates a SubnetMetrics
+func NewSubnetMetrics(ec2Client ec2wrapper.EC2, clientSet kubernetes.Interface, cw publisher.Publisher, log logger.Logger) *SubnetMetrics {
+	return &SubnetMetrics{
+		ec2Client: ec2Client,
+		clientSet: clientSet,
+		cw:        cw,
+		log:       log,
+	}
+}
+
+// Publish collects the subnet stats and hands them to the CloudWatch publisher
+func (s *SubnetMetrics) Publish(ctx context.Context) error {
+	subnetIDs, err := s.nodeSubnetIDs(ctx)
+	if err != nil {
+		return err
+	}
+	if len(subnetIDs) == 0 {
+		s.log.Info("No node subnet found, skipping subnet metrics")
+		return nil
+	}
+	output, err := s.ec2Client.DescribeSubnetsWithContext(ctx, &ec2.DescribeSubnetsInput{SubnetIds: aws.StringSlice(subnetIDs)})
+	if err != nil {
+		return errors.Wrap(err, "subnet metrics: failed to describe subnets")
+	}
+
+	var allStats []subnetStats
+	for _, subnet := range output.Subnets {
+		stats, err := s.subnetStats(ctx, subnet)
+		if err != nil {
+			return err
+		}
+		allStats = append(allStats, stats)
+	}
+	s.cw.Publish(produceSubnetMetrics(allStats)...)
+	return nil
+}
+
+// nodeSubnetIDs returns the sorted IDs of the subnets that the ENIs of the cluster nodes are in
+func (s *SubnetMetrics) nodeSubnetIDs(ctx context.Context) ([]string, error) {
+	nodes, err := s.clientSet.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "subnet metrics: failed to list nodes")
+	}
+	var instanceIDs []string
+	for _, node := range nodes.Items {
+		if instanceID := instanceIDFromProviderID(node.Spec.ProviderID); instanceID != "" {
+			instanceIDs = append(instanceIDs, instanceID)
+		}
+	}
+
+	subnets := make(map[string]bool)
+	for start := 0; start < len(instanceIDs); start += describeInstancesBatchSize {
+		end := start + describeInstancesBatchSize
+		if end > len(instanceIDs) {
+			end = len(instanceIDs)
+		}
+		input := &ec2.DescribeInstancesInput{InstanceIds: aws.StringSlice(instanceIDs[start:end])}
+		for {
+			output, err := s.ec2Client.DescribeInstancesWithContext(ctx, input)
+			if err != nil {
+				return nil, errors.Wrap(err, "subnet metrics: failed to describe instances")
+			}
+			for _, reservation := range output.Reservations {
+				for _, instance := range reservation.Instances {
+					for _, eni := range instance.NetworkInterfaces {
+						subnets[aws.StringValue(eni.SubnetId)] = true
+					}
+				}
+			}
+			if aws.StringValue(output.NextToken) == "" {
+				break
+			}
+			input.NextToken = output.NextToken
+		}
+	}
+	delete(subnets, "")
+
+	subnetIDs := make([]string, 0, len(subnets))
+	for subnetID := range subnets {
+		subnetIDs = append(subnetIDs, subnetID)
+	}
+	sort.Strings(subnetIDs)
+	return subnetIDs, nil
+}
+
+// instanceIDFromProviderID returns the instance ID of a node provider ID, aws:///<availability zone>/<instance ID>, or
+// "" if the node is not an EC2 instance
+func instanceIDFromProviderID(providerID string) string {
+	if !strings.HasPrefix(providerID, "aws://") {
+		return ""
+	}
+	instanceID := providerID[strings.LastIndex(providerID, "/")+1:]
+	if !strings.HasPrefix(instanceID, "i-") {
+		return ""
+	}
+	return instanceID
+}
+
+// subnetStats counts the free /28 prefixes of a subnet from the addresses and prefixes assigned to its ENIs
+func (s *SubnetMetrics) subnetStats(ctx context.Context, subnet *ec2.Subnet) (subnetStats, error) {
+	stats := subnetStats{
+		subnetID:         aws.StringValue(subnet.SubnetId),
+		availabilityZone: aws.StringValue(subnet.AvailabilityZone),
+		availableIPs:     int(aws.Int64Value(subnet.AvailableIpAddressCount)),
+	}
+	var used []string
+	input := &ec2.DescribeNetworkInterfacesInput{
+		Filters: []*ec2.Filter{{Name: aws.String("subnet-id"), Values: []*string{subnet.SubnetId}}},
+	}
+	err := s.ec2Client.DescribeNetworkInterfacesPagesWithContext(ctx, input, func(output *ec2.DescribeNetworkInterfacesOutput, lastPage bool) bool {
+		for _, eni := range output.NetworkInterfaces {
+			for _, addr := range eni.PrivateIpAddresses {
+				used = append(used, aws.StringValue(addr.PrivateIpAddress))
+			}
+			for _, prefix := range eni.Ipv4Prefixes {
+				used = append(used, aws.StringValue(prefix.Ipv4Prefix))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return stats, errors.Wrapf(err, "subnet metrics: failed to describe the network interfaces of subnet %s", stats.subnetID)
+	}
+	stats.freePrefixes = freeIPv4Prefixes(aws.StringValue(subnet.CidrBlock), used)
+	return stats, nil
+}
+
+// freeIPv4Prefixes returns the number of aligned /28 blocks of cidr that contain none of the used addresses or
+// prefixes. The blocks holding the first four and the last address of the subnet are reserved by AWS, so never free.
+// Subnet CIDR reservations are not accounted for.
+func freeIPv4Prefixes(cidr string, used []string) int {
+	_, subnet, err := net.ParseCIDR(cidr)
+	if err != nil || subnet.IP.To4() == nil {
+		return 0
+	}
+	ones, _ := subnet.Mask.Size()
+	if ones > ipv4PrefixLength {
+		return 0
+	}
+	base := binary.BigEndian.Uint32(subnet.IP.To4())
+	blocks := uint32(1) << (ipv4PrefixLength - ones)
+	shift := uint(32 - ipv4PrefixLength)
+
+	taken := map[uint32]bool{0: true, blocks - 1: true}
+	for _, addr := range used {
+		// A prefix is taken as its first address, as it fills its whole block
+		ip := net.ParseIP(strings.SplitN(addr, "/", 2)[0]).To4()
+		if ip == nil || !subnet.Contains(ip) {
+			continue
+		}
+		taken[(binary.BigEndian.Uint32(ip)-base)>>shift] = true
+	}
+	return int(blocks) - len(taken)
+}
+
+// produceSubnetMetrics returns the metric data points of each subnet, with the SubnetId and AvailabilityZone
+// dimensions, and of each availability zone, summed over its subnets
+func produceSubnetMetrics(allStats []subnetStats) []*cloudwatch.MetricDatum {
+	var dataPoints []*cloudwatch.MetricDatum
+	azStats := make(map[string]*subnetStats)
+	var azs []string
+	for _, stats := range allStats {
+		dimensions := []*cloudwatch.Dimension{
+			{Name: aws.String(subnetIDDimension), Value: aws.String(stats.subnetID)},
+			{Name: aws.String(availabilityZoneDimension), Value: aws.String(stats.availabilityZone)},
+		}
+		dataPoints = append(dataPoints, subnetMetricData(stats, dimensions)...)
+		dataPoints = append(dataPoints, &cloudwatch.MetricDatum{
+			MetricName: aws.String("subnetIPv4PrefixFragmentation"),
+			Unit:       aws.String(cloudwatch.StandardUnitPercent),
+			Value:      aws.Float64(prefixFragmentation(stats)),
+			Dimensions: dimensions,
+		})
+
+		az, ok := azStats[stats.availabilityZone]
+		if !ok {
+			az = &subnetStats{availabilityZone: stats.availabilityZone}
+			azStats[stats.availabilityZone] = az
+			azs = append(azs, stats.availabilityZone)
+		}
+		az.availableIPs += stats.availableIPs
+		az.freePrefixes += stats.freePrefixes
+	}
+	sort.Strings(azs)
+	for _, az := range azs {
+		dimensions := []*cloudwatch.Dimension{{Name: aws.String(availabilityZoneDimension), Value: aws.String(az)}}
+		dataPoints = append(dataPoints, subnetMetricData(*azStats[az], dimensions)...)
+	}
+	return dataPoints
+}
+
+func subnetMetricData(stats subnetStats, dimensions []*cloudwatch.Dimension) []*cloudwatch.MetricDatum {
+	return []*cloudwatch.MetricDatum{
+		{
+			MetricName: aws.String("subnetAvailableIPAddresses"),
+			Unit:       aws.String(cloudwatch.StandardUnitCount),
+			Value:      aws.Float64(float64(stats.availableIPs)),
+			Dimensions: dimensions,
+		},
+		{
+			MetricName: aws.String("subnetFreeIPv4Prefixes"),
+			Unit:       aws.String(cloudwatch.StandardUnitCount),
+			Value:      aws.Float64(float64(stats.freePrefixes)),
+			Dimensions: dimensions,
+		},
+	}
+}
+
+// prefixFragmentation returns the percentage of the available addresses of a subnet that are not part of a free /28
+// prefix, which can be handed out as secondary IPs but not as prefixes
+func prefixFragmentation(stats subnetStats) float64 {
+	if stats.availableIPs == 0 {
+		return 0
+	}
+	inPrefixes := stats.freePrefixes * (1 << (32 - ipv4PrefixLength))
+	if inPrefixes >= stats.availableIPs {
+		return 0
+	}
+	return 100 * float64(stats.availableIPs-inPrefixes) / float64(stats.availableIPs)
+}
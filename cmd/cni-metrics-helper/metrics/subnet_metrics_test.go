@@ -0,0 +1,126 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mock_ec2wrapper "github.com/aws/amazon-vpc-cni-k8s/pkg/ec2wrapper/mocks"
+)
+
+func TestInstanceIDFromProviderID(t *testing.T) {
+	assert.Equal(t, "i-0123", instanceIDFromProviderID("aws:///us-west-2a/i-0123"))
+	assert.Equal(t, "", instanceIDFromProviderID("aws:///us-west-2a/fargate-ip-10-0-0-1"))
+	assert.Equal(t, "", instanceIDFromProviderID("gce://project/zone/i-0123"))
+	assert.Equal(t, "", instanceIDFromProviderID(""))
+}
+
+func TestFreeIPv4Prefixes(t *testing.T) {
+	// A /26 has 4 blocks, the first and the last hold reserved addresses
+	assert.Equal(t, 2, freeIPv4Prefixes("10.0.0.0/26", nil))
+	assert.Equal(t, 1, freeIPv4Prefixes("10.0.0.0/26", []string{"10.0.0.20", "10.0.1.20"}))
+	assert.Equal(t, 0, freeIPv4Prefixes("10.0.0.0/26", []string{"10.0.0.20", "10.0.0.32/28"}))
+	assert.Equal(t, 0, freeIPv4Prefixes("10.0.0.0/28", nil))
+	assert.Equal(t, 0, freeIPv4Prefixes("10.0.0.0/29", nil))
+	assert.Equal(t, 0, freeIPv4Prefixes("2001:db8::/64", nil))
+}
+
+func TestPrefixFragmentation(t *testing.T) {
+	assert.Equal(t, 0.0, prefixFragmentation(subnetStats{}))
+	assert.Equal(t, 0.0, prefixFragmentation(subnetStats{availableIPs: 32, freePrefixes: 2}))
+	assert.Equal(t, 50.0, prefixFragmentation(subnetStats{availableIPs: 32, freePrefixes: 1}))
+	assert.Equal(t, 100.0, prefixFragmentation(subnetStats{availableIPs: 10}))
+}
+
+func TestSubnetMetricsPublish(t *testing.T) {
+	m := setup(t)
+	ctx := context.Background()
+	mockEC2 := mock_ec2wrapper.NewMockEC2(gomock.NewController(t))
+
+	for name, providerID := range map[string]string{"node-1": "aws:///us-west-2a/i-1", "node-2": "aws:///us-west-2b/i-2"} {
+		_, _ = m.clientset.CoreV1().Nodes().Create(ctx, &v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       v1.NodeSpec{ProviderID: providerID},
+		}, metav1.CreateOptions{})
+	}
+
+	mockEC2.EXPECT().DescribeInstancesWithContext(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, input *ec2.DescribeInstancesInput, _ ...request.Option) (*ec2.DescribeInstancesOutput, error) {
+			assert.ElementsMatch(t, []string{"i-1", "i-2"}, aws.StringValueSlice(input.InstanceIds))
+			return &ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{Instances: []*ec2.Instance{
+				{NetworkInterfaces: []*ec2.InstanceNetworkInterface{{SubnetId: aws.String("subnet-1")}, {SubnetId: aws.String("subnet-2")}}},
+				{NetworkInterfaces: []*ec2.InstanceNetworkInterface{{SubnetId: aws.String("subnet-3")}}},
+			}}}}, nil
+		})
+	mockEC2.EXPECT().DescribeSubnetsWithContext(gomock.Any(), &ec2.DescribeSubnetsInput{
+		SubnetIds: aws.StringSlice([]string{"subnet-1", "subnet-2", "subnet-3"}),
+	}).Return(&ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{
+		{SubnetId: aws.String("subnet-1"), AvailabilityZone: aws.String("us-west-2a"), CidrBlock: aws.String("10.0.0.0/26"), AvailableIpAddressCount: aws.Int64(50)},
+		{SubnetId: aws.String("subnet-2"), AvailabilityZone: aws.String("us-west-2a"), CidrBlock: aws.String("10.0.1.0/26"), AvailableIpAddressCount: aws.Int64(58)},
+		{SubnetId: aws.String("subnet-3"), AvailabilityZone: aws.String("us-west-2b"), CidrBlock: aws.String("10.0.2.0/26"), AvailableIpAddressCount: aws.Int64(0)},
+	}}, nil)
+	usedAddresses := map[string]*ec2.NetworkInterface{
+		"subnet-1": {PrivateIpAddresses: []*ec2.NetworkInterfacePrivateIpAddress{{PrivateIpAddress: aws.String("10.0.0.20")}}},
+		"subnet-2": {Ipv4Prefixes: []*ec2.Ipv4PrefixSpecification{{Ipv4Prefix: aws.String("10.0.1.16/28")}}},
+		"subnet-3": {Ipv4Prefixes: []*ec2.Ipv4PrefixSpecification{{Ipv4Prefix: aws.String("10.0.2.16/28")}, {Ipv4Prefix: aws.String("10.0.2.32/28")}}},
+	}
+	mockEC2.EXPECT().DescribeNetworkInterfacesPagesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Times(3).DoAndReturn(
+		func(_ context.Context, input *ec2.DescribeNetworkInterfacesInput, fn func(*ec2.DescribeNetworkInterfacesOutput, bool) bool, _ ...request.Option) error {
+			subnetID := aws.StringValue(input.Filters[0].Values[0])
+			fn(&ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: []*ec2.NetworkInterface{usedAddresses[subnetID]}}, true)
+			return nil
+		})
+
+	var published []*cloudwatch.MetricDatum
+	m.mockPublisher.EXPECT().Publish(gomock.Any()).Do(func(dataPoints ...*cloudwatch.MetricDatum) {
+		published = dataPoints
+	})
+
+	subnetMetrics := NewSubnetMetrics(mockEC2, m.clientset, m.mockPublisher, testLog)
+	assert.NoError(t, subnetMetrics.Publish(ctx))
+
+	values := make(map[string]float64)
+	for _, datum := range published {
+		key := aws.StringValue(datum.MetricName)
+		for _, dimension := range datum.Dimensions {
+			key += "," + aws.StringValue(dimension.Value)
+		}
+		values[key] = aws.Float64Value(datum.Value)
+	}
+	assert.Equal(t, map[string]float64{
+		"subnetAvailableIPAddresses,subnet-1,us-west-2a":    50,
+		"subnetFreeIPv4Prefixes,subnet-1,us-west-2a":        1,
+		"subnetIPv4PrefixFragmentation,subnet-1,us-west-2a": 68,
+		"subnetAvailableIPAddresses,subnet-2,us-west-2a":    58,
+		"subnetFreeIPv4Prefixes,subnet-2,us-west-2a":        1,
+		"subnetIPv4PrefixFragmentation,subnet-2,us-west-2a": 100 * 42.0 / 58,
+		"subnetAvailableIPAddresses,subnet-3,us-west-2b":    0,
+		"subnetFreeIPv4Prefixes,subnet-3,us-west-2b":        0,
+		"subnetIPv4PrefixFragmentation,subnet-3,us-west-2b": 0,
+		"subnetAvailableIPAddresses,us-west-2a":             108,
+		"subnetFreeIPv4Prefixes,us-west-2a":                 2,
+		"subnetAvailableIPAddresses,us-west-2b":             0,
+		"subnetFreeIPv4Prefixes,us-west-2b":                 0,
+	}, values)
+}
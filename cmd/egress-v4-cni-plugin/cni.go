@@ -20,12 +20,12 @@ import (
 	"os"
 	"runtime"
 
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/cniutils"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
 
 	"github.com/containernetworking/cni/pkg/skel"
 	"github.com/containernetworking/cni/pkg/types"
 	"github.com/containernetworking/cni/pkg/types/current"
-	cniversion "github.com/containernetworking/cni/pkg/version"
 	"github.com/containernetworking/plugins/pkg/ip"
 	"github.com/containernetworking/plugins/pkg/ipam"
 	"github.com/containernetworking/plugins/pkg/ns"
@@ -73,7 +73,7 @@ func loadConf(bytes []byte) (*NetConf, logger.Logger, error) {
 	}
 
 	if conf.RawPrevResult != nil {
-		if err := cniversion.ParsePrevResult(&conf.NetConf); err != nil {
+		if err := cniutils.ParsePrevResult(&conf.NetConf); err != nil {
 			return nil, nil, fmt.Errorf("could not parse prevResult: %v", err)
 		}
 	}
@@ -246,7 +246,7 @@ func setupHostVeth(vethName string, result *current.Result) error {
 }
 
 func main() {
-	skel.PluginMain(cmdAdd, nil, cmdDel, cniversion.All, fmt.Sprintf("egress-v4 CNI plugin %s", version))
+	skel.PluginMain(cmdAdd, nil, cmdDel, cniutils.AllSpecVersions, fmt.Sprintf("egress-v4 CNI plugin %s", version))
 }
 
 func cmdAdd(args *skel.CmdArgs) error {
@@ -271,7 +271,7 @@ func cmdAdd(args *skel.CmdArgs) error {
 	//value of an env variable in VPC CNI determines whether this plugin should be enabled and this is an attempt to
 	//pass through the variable configured in VPC CNI.
 	if netConf.Enabled == "false" {
-		return types.PrintResult(result, netConf.CNIVersion)
+		return types.PrintResult(cniutils.ResultForVersion(result, netConf.CNIVersion), netConf.CNIVersion)
 	}
 
 	chain := utils.MustFormatChainNameWithPrefix(netConf.Name, args.ContainerID, "E4-")
@@ -339,7 +339,7 @@ func cmdAdd(args *skel.CmdArgs) error {
 	}
 
 	// Pass through the previous result
-	return types.PrintResult(result, netConf.CNIVersion)
+	return types.PrintResult(cniutils.ResultForVersion(result, netConf.CNIVersion), netConf.CNIVersion)
 }
 
 func cmdDel(args *skel.CmdArgs) error {
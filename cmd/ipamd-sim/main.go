@@ -0,0 +1,265 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// ipamd-sim runs the warm pool management of ipamd against a simulated EC2 and a pod churn profile, and reports the
+// pods that had to wait for an IP and the EC2 calls made, so that warm targets can be tuned offline
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils/fakeprovider"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/paths"
+)
+
+type options struct {
+	profile      string
+	tick         time.Duration
+	poolInterval time.Duration
+
+	warmENITarget          int
+	warmIPTarget           int
+	minimumIPTarget        int
+	warmPrefixTarget       int
+	maxENI                 int
+	enablePrefixDelegation bool
+
+	instanceType string
+	eniLimit     int
+	ipv4Limit    int
+	subnetCIDR   string
+	ec2Latency   time.Duration
+}
+
+// pendingPod is a pod waiting for an IP
+type pendingPod struct {
+	name    string
+	since   time.Time
+	starved bool
+}
+
+// report is the outcome of a simulation
+type report struct {
+	created int
+	// starved is the number of pods that didn't get an IP right away
+	starved int
+	// starvationEvents is the number of failed IP assignments, each pending pod failing once per tick
+	starvationEvents int
+	peakRunning      int
+	longestWait      time.Duration
+	pending          int
+}
+
+func main() {
+	opts := &options{}
+	flags := pflag.NewFlagSet("ipamd-sim", pflag.ExitOnError)
+	flags.StringVar(&opts.profile, "profile", "5m:1:1m",
+		"comma separated <duration>:<pods per second>:<pod lifetime> phases of pod churn")
+	flags.DurationVar(&opts.tick, "tick", time.Second, "interval between two rounds of pod creations and deletions")
+	flags.DurationVar(&opts.poolInterval, "pool-interval", 5*time.Second, "interval between two runs of the pool manager")
+	flags.IntVar(&opts.warmENITarget, "warm-eni-target", 1, "WARM_ENI_TARGET")
+	flags.IntVar(&opts.warmIPTarget, "warm-ip-target", 0, "WARM_IP_TARGET")
+	flags.IntVar(&opts.minimumIPTarget, "minimum-ip-target", 0, "MINIMUM_IP_TARGET")
+	flags.IntVar(&opts.warmPrefixTarget, "warm-prefix-target", 0, "WARM_PREFIX_TARGET")
+	flags.IntVar(&opts.maxENI, "max-eni", 0, "MAX_ENI, the ENI limit of the instance type if 0")
+	flags.BoolVar(&opts.enablePrefixDelegation, "prefix-delegation", false, "ENABLE_PREFIX_DELEGATION")
+	defaults := fakeprovider.DefaultConfig()
+	flags.StringVar(&opts.instanceType, "instance-type", defaults.InstanceType, "instance type of the node")
+	flags.IntVar(&opts.eniLimit, "eni-limit", defaults.ENILimit, "maximum number of ENIs of the instance type")
+	flags.IntVar(&opts.ipv4Limit, "ipv4-limit", defaults.IPv4Limit, "maximum number of IPv4 addresses per ENI of the instance type")
+	flags.StringVar(&opts.subnetCIDR, "subnet-cidr", defaults.SubnetCIDR, "CIDR of the subnet of the node, within "+defaults.VPCCIDR)
+	flags.DurationVar(&opts.ec2Latency, "ec2-latency", 0, "latency added to every EC2 call")
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(os.Args[1:])
+
+	if err := run(opts); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "ipamd-sim: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(opts *options) error {
+	phases, err := parseProfile(opts.profile)
+	if err != nil {
+		return err
+	}
+	// The instance type limits are persisted in the run directory
+	if _, found := os.LookupEnv(paths.EnvRunDir); !found {
+		runDir, err := os.MkdirTemp("", "ipamd-sim")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(runDir)
+		_ = os.Setenv(paths.EnvRunDir, runDir)
+	}
+
+	cfg := fakeprovider.DefaultConfig()
+	cfg.InstanceType = opts.instanceType
+	cfg.ENILimit = opts.eniLimit
+	cfg.IPv4Limit = opts.ipv4Limit
+	cfg.SubnetCIDR = opts.subnetCIDR
+	cfg.Latency = opts.ec2Latency
+	provider, err := fakeprovider.New(cfg)
+	if err != nil {
+		return err
+	}
+	sim, err := ipamd.NewSimulator(provider, ipamd.SimulatorConfig{
+		WarmENITarget:          opts.warmENITarget,
+		WarmIPTarget:           opts.warmIPTarget,
+		MinimumIPTarget:        opts.minimumIPTarget,
+		WarmPrefixTarget:       opts.warmPrefixTarget,
+		MaxENI:                 opts.maxENI,
+		EnablePrefixDelegation: opts.enablePrefixDelegation,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// The pool manager runs next to pod creations and deletions, like in ipamd
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(opts.poolInterval)
+		defer ticker.Stop()
+		for {
+			sim.ManagePool(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	start := time.Now()
+	r := churn(ctx, sim, phases, opts.tick)
+	stop()
+	wg.Wait()
+	printReport(os.Stdout, time.Since(start), opts, r, sim, provider)
+	return nil
+}
+
+// churn creates and deletes pods following phases until the last one ends or ctx is cancelled. Pods that don't get
+// an IP are retried on every tick, oldest first, before new pods are created.
+func churn(ctx context.Context, sim *ipamd.Simulator, phases []phase, tick time.Duration) *report {
+	r := &report{}
+	running := make(map[string]time.Time)
+	var pending []pendingPod
+	// toCreate accumulates the fractions of pods of low rates
+	toCreate := 0.0
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for _, p := range phases {
+		phaseEnd := time.Now().Add(p.duration)
+		for time.Now().Before(phaseEnd) {
+			select {
+			case <-ctx.Done():
+				r.pending = len(pending)
+				return r
+			case <-ticker.C:
+			}
+			now := time.Now()
+			for name, expiry := range running {
+				if now.After(expiry) {
+					_ = sim.DelPod(name)
+					delete(running, name)
+				}
+			}
+
+			toCreate += p.podsPerSecond * tick.Seconds()
+			for ; toCreate >= 1; toCreate-- {
+				pending = append(pending, pendingPod{name: fmt.Sprintf("pod-%d", r.created), since: now})
+				r.created++
+			}
+			for len(pending) > 0 {
+				pod := pending[0]
+				if _, err := sim.AddPod(pod.name); err != nil {
+					// The pool is empty, none of the pending pods gets an IP on this tick
+					r.starvationEvents += len(pending)
+					for i := range pending {
+						if !pending[i].starved {
+							pending[i].starved = true
+							r.starved++
+						}
+					}
+					break
+				}
+				pending = pending[1:]
+				running[pod.name] = now.Add(p.podLifetime)
+				if wait := now.Sub(pod.since); wait > r.longestWait {
+					r.longestWait = wait
+				}
+			}
+			if len(running) > r.peakRunning {
+				r.peakRunning = len(running)
+			}
+		}
+	}
+	r.pending = len(pending)
+	return r
+}
+
+func printReport(w io.Writer, elapsed time.Duration, opts *options, r *report, sim *ipamd.Simulator, provider *fakeprovider.Provider) {
+	_, _ = fmt.Fprintf(w, "Simulated %s of pod churn on a %s (%d ENIs, %d IPv4 addresses per ENI)\n",
+		elapsed.Round(time.Second), opts.instanceType, opts.eniLimit, opts.ipv4Limit)
+	_, _ = fmt.Fprintf(w, "Pods: %d created, %d running at peak, %d still pending\n", r.created, r.peakRunning, r.pending)
+	_, _ = fmt.Fprintf(w, "IP starvation: %d pods waited for an IP, %d failed assignments, longest wait %s\n",
+		r.starved, r.starvationEvents, r.longestWait.Round(time.Millisecond))
+	stats := sim.Stats()
+	_, _ = fmt.Fprintf(w, "Pool: %d ENIs, %s\n", sim.ENIs(), stats.String())
+
+	calls := provider.AllCalls()
+	apis := make([]string, 0, len(calls))
+	total := 0
+	for api, count := range calls {
+		apis = append(apis, api)
+		total += count
+	}
+	sort.Strings(apis)
+	_, _ = fmt.Fprintf(w, "EC2 calls: %d\n", total)
+	for _, api := range apis {
+		_, _ = fmt.Fprintf(w, "  %-40s %d\n", api, calls[api])
+	}
+
+	actions := make(map[string]int)
+	for _, decision := range sim.PoolDecisions() {
+		actions[decision.Operation+" "+decision.Action] += decision.Count
+	}
+	keys := make([]string, 0, len(actions))
+	for key := range actions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	_, _ = fmt.Fprintf(w, "Pool decisions:\n")
+	for _, key := range keys {
+		_, _ = fmt.Fprintf(w, "  %-40s %d\n", key, actions[key])
+	}
+}
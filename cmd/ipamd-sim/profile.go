@@ -0,0 +1,64 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// phase is a period of constant pod churn
+type phase struct {
+	duration time.Duration
+	// podsPerSecond is the rate pods are created at
+	podsPerSecond float64
+	// podLifetime is how long a pod runs once it got its IP
+	podLifetime time.Duration
+}
+
+// parseProfile parses a comma separated list of <duration>:<pods per second>:<pod lifetime> phases, e.g.
+// "2m:2:10m,5m:0.5:1m"
+func parseProfile(value string) ([]phase, error) {
+	var phases []phase
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 {
+			return nil, errors.Errorf("invalid phase %q, expected <duration>:<pods per second>:<pod lifetime>", entry)
+		}
+		duration, err := time.ParseDuration(parts[0])
+		if err != nil || duration <= 0 {
+			return nil, errors.Errorf("invalid duration %q in phase %q", parts[0], entry)
+		}
+		podsPerSecond, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || podsPerSecond < 0 {
+			return nil, errors.Errorf("invalid pod rate %q in phase %q", parts[1], entry)
+		}
+		podLifetime, err := time.ParseDuration(parts[2])
+		if err != nil || podLifetime <= 0 {
+			return nil, errors.Errorf("invalid pod lifetime %q in phase %q", parts[2], entry)
+		}
+		phases = append(phases, phase{duration: duration, podsPerSecond: podsPerSecond, podLifetime: podLifetime})
+	}
+	if len(phases) == 0 {
+		return nil, errors.New("empty profile")
+	}
+	return phases, nil
+}
@@ -0,0 +1,35 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProfile(t *testing.T) {
+	phases, err := parseProfile("2m:2:10m, 5m:0.5:1m")
+	assert.NoError(t, err)
+	assert.Equal(t, []phase{
+		{duration: 2 * time.Minute, podsPerSecond: 2, podLifetime: 10 * time.Minute},
+		{duration: 5 * time.Minute, podsPerSecond: 0.5, podLifetime: time.Minute},
+	}, phases)
+
+	for _, profile := range []string{"", "2m:2", "2m:-1:1m", "2m:x:1m", "0s:1:1m", "2m:1:0s", "2m:1:1m:1"} {
+		_, err := parseProfile(profile)
+		assert.Error(t, err, profile)
+	}
+}
@@ -15,15 +15,19 @@
 package main
 
 import (
-	"crypto/sha1"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"os"
+	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/cniutils"
 
@@ -32,10 +36,13 @@ import (
 	"github.com/containernetworking/cni/pkg/skel"
 	"github.com/containernetworking/cni/pkg/types"
 	"github.com/containernetworking/cni/pkg/types/current"
-	cniSpecVersion "github.com/containernetworking/cni/pkg/version"
 	"github.com/pkg/errors"
 	"golang.org/x/net/context"
+	"golang.org/x/sys/unix"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/aws/amazon-vpc-cni-k8s/cmd/routed-eni-cni-plugin/driver"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/grpcwrapper"
@@ -44,13 +51,53 @@ import (
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/rpcwrapper"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/typeswrapper"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/paths"
 	pb "github.com/aws/amazon-vpc-cni-k8s/rpc"
 )
 
 const ipamdAddress = "127.0.0.1:50051"
 
+// ipamdConnectParams replaces the default 1s reconnect backoff, so a connection refused while ipamd restarts is retried
+// quickly instead of stalling the ADD/DEL
+var ipamdConnectParams = grpc.ConnectParams{
+	Backoff: backoff.Config{
+		BaseDelay:  10 * time.Millisecond,
+		Multiplier: 1.6,
+		Jitter:     0.2,
+		MaxDelay:   time.Second,
+	},
+	MinConnectTimeout: time.Second,
+}
+
+// ipamdRequestAttempts is the number of times an AddNetwork or DelNetwork request is sent while ipamd is unavailable.
+// The attempts carry the same request ID, so ipamd doesn't assign or release addresses twice when an attempt
+// completed without the CNI plugin getting the reply.
+const ipamdRequestAttempts = 3
+
+// ipamdRequestRetryInterval is the time between the attempts of a request
+var ipamdRequestRetryInterval = time.Second
+
 const dummyVlanInterfacePrefix = "dummy"
 
+const (
+	// vmNetworkModeTap is the vmNetwork mode adding a tap device bridged to the pod interface
+	vmNetworkModeTap = "tap"
+	// defaultVMTapName is the name of the tap device when the runtime doesn't choose one
+	defaultVMTapName = "tap0"
+	// maxInterfaceNameLength is the longest name of a Linux interface
+	maxInterfaceNameLength = 15
+)
+
+// podGatewayIPv4 and podGatewayIPv6 are the dummy next hops of the pod default route set up by the driver
+var (
+	podGatewayIPv4 = net.IPv4(169, 254, 1, 1)
+	podGatewayIPv6 = net.ParseIP("fe80::1")
+)
+
+// podSecondaryRouteTableBase is added to the position of a secondary interface to get the route table holding its
+// default route inside the pod network namespace
+const podSecondaryRouteTableBase = 100
+
 var version string
 
 // NetConf stores the common network config for the CNI plugin
@@ -71,6 +118,44 @@ type NetConf struct {
 	PluginLogFile string `json:"pluginLogFile"`
 
 	PluginLogLevel string `json:"pluginLogLevel"`
+
+	// IPAMDSocket is the unix socket ipamd serves gRPC on next to ipamdAddress, in the run dir of aws-node. Older ipamd
+	// versions don't create it.
+	IPAMDSocket string `json:"ipamdSocket,omitempty"`
+
+	// RouteTableBase is added to the device index of a secondary ENI to get its route table, like ipamd does with
+	// AWS_VPC_K8S_CNI_ROUTE_TABLE_BASE. Older configurations don't set it.
+	RouteTableBase string `json:"routeTableBase,omitempty"`
+	routeTableBase int
+
+	// ValidAttachments lists the attachments the container runtime still uses, on GC
+	ValidAttachments []GCAttachment `json:"cni.dev/valid-attachments,omitempty"`
+
+	// RuntimeConfig holds the capability arguments passed by the container runtime
+	RuntimeConfig RuntimeConfig `json:"runtimeConfig,omitempty"`
+}
+
+// RuntimeConfig is the runtimeConfig of the network configuration
+type RuntimeConfig struct {
+	// TraceID is the ID the container runtime traces the request with, which the plugin uses instead of generating one
+	TraceID string `json:"traceID,omitempty"`
+	// VMNetwork is set by the VM-based runtimes, such as kata, which need the pod network handed to a guest
+	VMNetwork *VMNetwork `json:"vmNetwork,omitempty"`
+}
+
+// VMNetwork is the vmNetwork capability argument
+type VMNetwork struct {
+	// Mode is how the pod network is handed to the guest. "tap" adds a tap device bridged to the pod interface, which
+	// the runtime attaches to the guest.
+	Mode string `json:"mode"`
+	// TapName is the name of the tap device in the pod network namespace, tap0 by default
+	TapName string `json:"tapName,omitempty"`
+}
+
+// GCAttachment is an entry of cni.dev/valid-attachments
+type GCAttachment struct {
+	ContainerID string `json:"containerID"`
+	IfName      string `json:"ifname"`
 }
 
 // K8sArgs is the valid CNI_ARGS used for Kubernetes
@@ -85,6 +170,9 @@ type K8sArgs struct {
 
 	// K8S_POD_INFRA_CONTAINER_ID is pod's sandbox id
 	K8S_POD_INFRA_CONTAINER_ID types.UnmarshallableString
+
+	// K8S_POD_UID is pod's UID
+	K8S_POD_UID types.UnmarshallableString
 }
 
 func init() {
@@ -100,6 +188,7 @@ func LoadNetConf(bytes []byte) (*NetConf, logger.Logger, error) {
 		MTU:                "9001",
 		VethPrefix:         "eni",
 		PodSGEnforcingMode: sgpp.DefaultEnforcingMode,
+		IPAMDSocket:        paths.DefaultIPAMDSocket(),
 	}
 
 	if err := json.Unmarshal(bytes, &conf); err != nil {
@@ -107,7 +196,7 @@ func LoadNetConf(bytes []byte) (*NetConf, logger.Logger, error) {
 	}
 
 	if conf.RawPrevResult != nil {
-		if err := cniSpecVersion.ParsePrevResult(&conf.NetConf); err != nil {
+		if err := cniutils.ParsePrevResult(&conf.NetConf); err != nil {
 			return nil, nil, fmt.Errorf("could not parse prevResult: %v", err)
 		}
 	}
@@ -121,9 +210,93 @@ func LoadNetConf(bytes []byte) (*NetConf, logger.Logger, error) {
 	if len(conf.VethPrefix) > 4 {
 		return nil, nil, errors.New("conf.VethPrefix can be at most 4 characters long")
 	}
+	conf.routeTableBase = networkutils.DefaultRouteTableBase
+	if conf.RouteTableBase != "" {
+		routeTableBase, err := networkutils.ParseRouteTableBase(conf.RouteTableBase)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "conf.RouteTableBase")
+		}
+		conf.routeTableBase = routeTableBase
+	}
+	if vm := conf.RuntimeConfig.VMNetwork; vm != nil {
+		if vm.Mode != vmNetworkModeTap {
+			return nil, nil, errors.Errorf("runtimeConfig.vmNetwork: unsupported mode %q", vm.Mode)
+		}
+		if vm.TapName == "" {
+			vm.TapName = defaultVMTapName
+		}
+		if len(vm.TapName) > maxInterfaceNameLength {
+			return nil, nil, errors.Errorf("runtimeConfig.vmNetwork: tap name %q is longer than %d characters",
+				vm.TapName, maxInterfaceNameLength)
+		}
+	}
 	return &conf, log, nil
 }
 
+// podRouteTable returns the route table of the ENI with the given device index, or 0 for the primary ENI, which uses
+// the main table
+func (conf *NetConf) podRouteTable(deviceNumber int32) int {
+	if deviceNumber <= 0 {
+		return 0
+	}
+	return networkutils.ENIRouteTable(conf.routeTableBase, int(deviceNumber))
+}
+
+// podEgressRoutes returns the egress routes of the reply, through the main table of the primary ENI or through the
+// route table of the ENI of the pod IP. Invalid CIDRs are skipped, ipamd only sends valid ones.
+func (conf *NetConf) podEgressRoutes(r *pb.AddNetworkReply) []driver.EgressRoute {
+	podENITable := unix.RT_TABLE_MAIN
+	if routeTable := conf.podRouteTable(r.DeviceNumber); routeTable > 0 {
+		podENITable = routeTable
+	}
+	var routes []driver.EgressRoute
+	for _, route := range r.EgressRoutes {
+		_, dst, err := net.ParseCIDR(route.CIDR)
+		if err != nil {
+			continue
+		}
+		routeTable := unix.RT_TABLE_MAIN
+		if route.ViaPodENI {
+			routeTable = podENITable
+		}
+		routes = append(routes, driver.EgressRoute{Dst: dst, RouteTable: routeTable})
+	}
+	return routes
+}
+
+// retryUnavailable calls send until it fails with another error than codes.Unavailable, at most ipamdRequestAttempts
+// times
+func retryUnavailable(log logger.Logger, send func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := send()
+		if status.Code(err) != codes.Unavailable || attempt == ipamdRequestAttempts {
+			return err
+		}
+		log.Warnf("ipamd unavailable, retrying in %v: %v", ipamdRequestRetryInterval, err)
+		time.Sleep(ipamdRequestRetryInterval)
+	}
+}
+
+// dialIPAMD connects to ipamd over the unix socket at socketPath when it exists, falling back to the TCP address.
+// Every CNI invocation is a new process, so the connection can't be reused across calls; the socket avoids the TCP
+// handshake and the iptables traversal of loopback connections instead. A socket that can't be connected to, such as
+// one left behind by a crashed ipamd, falls back to the TCP address too.
+func dialIPAMD(grpcClient grpcwrapper.GRPC, socketPath, address string) (*grpc.ClientConn, error) {
+	opts := []grpc.DialOption{grpc.WithInsecure(), grpc.WithConnectParams(ipamdConnectParams)}
+	if _, err := os.Stat(socketPath); err != nil {
+		return grpcClient.Dial(address, opts...)
+	}
+	opts = append(opts, grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "unix", socketPath)
+		if err == nil {
+			return conn, nil
+		}
+		return d.DialContext(ctx, "tcp", address)
+	}))
+	return grpcClient.Dial(socketPath, opts...)
+}
+
 func cmdAdd(args *skel.CmdArgs) error {
 	return add(args, typeswrapper.New(), grpcwrapper.New(), rpcwrapper.New(), driver.New())
 }
@@ -131,13 +304,15 @@ func cmdAdd(args *skel.CmdArgs) error {
 func add(args *skel.CmdArgs, cniTypes typeswrapper.CNITYPES, grpcClient grpcwrapper.GRPC,
 	rpcClient rpcwrapper.RPC, driverClient driver.NetworkAPIs) error {
 
+	start := time.Now()
 	conf, log, err := LoadNetConf(args.StdinData)
 	if err != nil {
 		return errors.Wrap(err, "add cmd: error loading config from args")
 	}
 
-	log.Infof("Received CNI add request: ContainerID(%s) Netns(%s) IfName(%s) Args(%s) Path(%s) argsStdinData(%s)",
-		args.ContainerID, args.Netns, args.IfName, args.Args, args.Path, args.StdinData)
+	traceID := requestTraceID(conf)
+	log.Infof("Received CNI add request: ContainerID(%s) Netns(%s) IfName(%s) Args(%s) Path(%s) argsStdinData(%s) TraceID(%s)",
+		args.ContainerID, args.Netns, args.IfName, args.Args, args.Path, args.StdinData, traceID)
 
 	log.Debugf("Prev Result: %v\n", conf.PrevResult)
 
@@ -151,7 +326,7 @@ func add(args *skel.CmdArgs, cniTypes typeswrapper.CNITYPES, grpcClient grpcwrap
 	log.Debugf("MTU value set is %d:", mtu)
 
 	// Set up a connection to the ipamD server.
-	conn, err := grpcClient.Dial(ipamdAddress, grpc.WithInsecure())
+	conn, err := dialIPAMD(grpcClient, conf.IPAMDSocket, ipamdAddress)
 	if err != nil {
 		log.Errorf("Failed to connect to backend server for container %s: %v",
 			args.ContainerID, err)
@@ -161,17 +336,26 @@ func add(args *skel.CmdArgs, cniTypes typeswrapper.CNITYPES, grpcClient grpcwrap
 
 	c := rpcClient.NewCNIBackendClient(conn)
 
-	r, err := c.AddNetwork(context.Background(),
-		&pb.AddNetworkRequest{
-			ClientVersion:              version,
-			K8S_POD_NAME:               string(k8sArgs.K8S_POD_NAME),
-			K8S_POD_NAMESPACE:          string(k8sArgs.K8S_POD_NAMESPACE),
-			K8S_POD_INFRA_CONTAINER_ID: string(k8sArgs.K8S_POD_INFRA_CONTAINER_ID),
-			Netns:                      args.Netns,
-			ContainerID:                args.ContainerID,
-			NetworkName:                conf.Name,
-			IfName:                     args.IfName,
-		})
+	rpcStart := time.Now()
+	addRequest := &pb.AddNetworkRequest{
+		ClientVersion:              version,
+		K8S_POD_NAME:               string(k8sArgs.K8S_POD_NAME),
+		K8S_POD_NAMESPACE:          string(k8sArgs.K8S_POD_NAMESPACE),
+		K8S_POD_INFRA_CONTAINER_ID: string(k8sArgs.K8S_POD_INFRA_CONTAINER_ID),
+		Netns:                      args.Netns,
+		ContainerID:                args.ContainerID,
+		NetworkName:                conf.Name,
+		IfName:                     args.IfName,
+		TraceID:                    traceID,
+		K8S_POD_UID:                string(k8sArgs.K8S_POD_UID),
+		RequestID:                  requestID("add", args.ContainerID, args.IfName, conf.Name),
+	}
+	var r *pb.AddNetworkReply
+	err = retryUnavailable(log, func() (err error) {
+		r, err = c.AddNetwork(pb.WithTraceID(context.Background(), traceID), addRequest)
+		return err
+	})
+	rpcDuration := time.Since(rpcStart)
 
 	if err != nil {
 		log.Errorf("Error received from AddNetwork grpc call for containerID %s: %v",
@@ -189,6 +373,11 @@ func add(args *skel.CmdArgs, cniTypes typeswrapper.CNITYPES, grpcClient grpcwrap
 	log.Infof("Received add network response from ipamd for container %s interface %s: %+v",
 		args.ContainerID, args.IfName, r)
 
+	if r.PodMTU > 0 {
+		mtu = int(r.PodMTU)
+		log.Debugf("Using the pod MTU %d set on the pod or its node", mtu)
+	}
+
 	//We will let the values in result struct guide us in terms of IP Address Family configured.
 	var v4Addr, v6Addr, addr *net.IPNet
 	var addrFamily string
@@ -212,11 +401,13 @@ func add(args *skel.CmdArgs, cniTypes typeswrapper.CNITYPES, grpcClient grpcwrap
 
 	var hostVethName string
 	var dummyVlanInterface *current.Interface
+	var secondaryInterfaces []podSecondaryInterface
 
+	setupStart := time.Now()
 	// Non-zero value means pods are using branch ENI
 	if r.PodVlanId != 0 {
 		hostVethNamePrefix := sgpp.BuildHostVethNamePrefix(conf.VethPrefix, conf.PodSGEnforcingMode)
-		hostVethName = generateHostVethName(hostVethNamePrefix, string(k8sArgs.K8S_POD_NAMESPACE), string(k8sArgs.K8S_POD_NAME))
+		hostVethName = networkutils.GenerateHostVethName(hostVethNamePrefix, string(k8sArgs.K8S_POD_NAMESPACE), string(k8sArgs.K8S_POD_NAME))
 		err = driverClient.SetupBranchENIPodNetwork(hostVethName, args.IfName, args.Netns, v4Addr, v6Addr, int(r.PodVlanId), r.PodENIMAC,
 			r.PodENISubnetGW, int(r.ParentIfIndex), mtu, conf.PodSGEnforcingMode, log)
 
@@ -224,17 +415,41 @@ func add(args *skel.CmdArgs, cniTypes typeswrapper.CNITYPES, grpcClient grpcwrap
 		// which will be created for PPSG scenario to pass along the vlanId information
 		// as a part of the ADD cmd Result struct
 		// The podVlanId is used by DEL cmd, fetched from the prevResult struct to cleanup the pod network
-		dummyVlanInterfaceName := generateHostVethName(dummyVlanInterfacePrefix, string(k8sArgs.K8S_POD_NAMESPACE), string(k8sArgs.K8S_POD_NAME))
+		dummyVlanInterfaceName := networkutils.GenerateHostVethName(dummyVlanInterfacePrefix, string(k8sArgs.K8S_POD_NAMESPACE), string(k8sArgs.K8S_POD_NAME))
 
 		// The dummyVlanInterface is purely virtual and relevent only for ppsg, so we decided to keep it separate
 		// and not overload the already available hostVethInterface
 		dummyVlanInterface = &current.Interface{Name: dummyVlanInterfaceName, Mac: fmt.Sprint(r.PodVlanId)}
 		log.Debugf("Using dummy vlanInterface: %v", dummyVlanInterface)
+
+		if err == nil && len(r.SecondaryInterfaces) > 0 {
+			secondaryInterfaces, err = setupPodSecondaryInterfaces(driverClient, conf, k8sArgs, args.Netns, hostVethNamePrefix, r, mtu, log)
+		}
+	} else if r.DedicatedENI {
+		// The pod gets the whole ENI, with the address in its subnet and no host veth
+		v4Addr.Mask = net.CIDRMask(int(r.PodENISubnetPrefixLength), 32)
+		err = driverClient.SetupDedicatedENIPodNetwork(args.IfName, args.Netns, v4Addr, r.PodENIMAC, r.PodENISubnetGW, mtu, log)
 	} else {
 		// build hostVethName
 		// Note: the maximum length for linux interface name is 15
-		hostVethName = generateHostVethName(conf.VethPrefix, string(k8sArgs.K8S_POD_NAMESPACE), string(k8sArgs.K8S_POD_NAME))
-		err = driverClient.SetupPodNetwork(hostVethName, args.IfName, args.Netns, v4Addr, v6Addr, int(r.DeviceNumber), mtu, log)
+		hostVethName = networkutils.GenerateHostVethName(conf.VethPrefix, string(k8sArgs.K8S_POD_NAMESPACE), string(k8sArgs.K8S_POD_NAME))
+		if r.WarmVeth != "" && v4Addr != nil {
+			// ipamd pre-plumbed a veth pair for the IP, which only has to be moved into the pod
+			err = driverClient.SetupWarmVethPodNetwork(r.WarmVeth, networkutils.WarmVethPeerName(r.WarmVeth), hostVethName,
+				args.IfName, args.Netns, v4Addr, mtu, log)
+		} else {
+			err = driverClient.SetupPodNetwork(hostVethName, args.IfName, args.Netns, v4Addr, v6Addr, conf.podRouteTable(r.DeviceNumber), mtu, log)
+		}
+		if err == nil && len(r.EgressRoutes) > 0 && v4Addr != nil {
+			err = driverClient.SetupPodEgressRoutes(v4Addr, conf.podEgressRoutes(r), log)
+		}
+		if err == nil && r.Multicast {
+			err = driverClient.SetupPodMulticast(hostVethName, args.IfName, args.Netns, v4Addr, log)
+		}
+	}
+	vmNetwork := conf.RuntimeConfig.VMNetwork
+	if err == nil && vmNetwork != nil {
+		err = driverClient.SetupPodVMNetwork(hostVethName, args.IfName, vmNetwork.TapName, args.Netns, mtu, log)
 	}
 
 	if err != nil {
@@ -242,7 +457,7 @@ func add(args *skel.CmdArgs, cniTypes typeswrapper.CNITYPES, grpcClient grpcwrap
 			args.ContainerID, err)
 
 		// return allocated IP back to IP pool
-		r, delErr := c.DelNetwork(context.Background(), &pb.DelNetworkRequest{
+		r, delErr := c.DelNetwork(pb.WithTraceID(context.Background(), traceID), &pb.DelNetworkRequest{
 			ClientVersion:              version,
 			K8S_POD_NAME:               string(k8sArgs.K8S_POD_NAME),
 			K8S_POD_NAMESPACE:          string(k8sArgs.K8S_POD_NAMESPACE),
@@ -251,6 +466,7 @@ func add(args *skel.CmdArgs, cniTypes typeswrapper.CNITYPES, grpcClient grpcwrap
 			IfName:                     args.IfName,
 			NetworkName:                conf.Name,
 			Reason:                     "SetupNSFailed",
+			TraceID:                    traceID,
 		})
 
 		if delErr != nil {
@@ -261,25 +477,43 @@ func add(args *skel.CmdArgs, cniTypes typeswrapper.CNITYPES, grpcClient grpcwrap
 		}
 		return errors.Wrap(err, "add command: failed to setup network")
 	}
+	log.Infof("Set up pod network for container %s, trace ID %s: ipamd %v, network setup %v, total %v",
+		args.ContainerID, traceID, rpcDuration, time.Since(setupStart), time.Since(start))
 
 	containerInterfaceIndex := 1
+	gw, defaultRoute := podDefaultRoute(addrFamily)
+	interfaces := []*current.Interface{
+		{Name: hostVethName},
+		{Name: args.IfName, Sandbox: args.Netns},
+	}
+	if r.DedicatedENI {
+		containerInterfaceIndex = 0
+		gw = net.ParseIP(r.PodENISubnetGW)
+		defaultRoute.GW = gw
+		interfaces = []*current.Interface{{Name: args.IfName, Mac: r.PodENIMAC, Sandbox: args.Netns}}
+	}
 	ips := []*current.IPConfig{
 		{
 			Version:   addrFamily,
 			Address:   *addr,
+			Gateway:   gw,
 			Interface: &containerInterfaceIndex,
 		},
 	}
 
-	hostInterface := &current.Interface{Name: hostVethName}
-	containerInterface := &current.Interface{Name: args.IfName, Sandbox: args.Netns}
+	routes := []*types.Route{defaultRoute}
+	if hintGW, hintRoutes, ok := podRouteHints(r, log); ok {
+		ips[0].Gateway = hintGW
+		routes = hintRoutes
+	}
 
+	// The result is complete, so that the plugins chained after this one (bandwidth, portmap, firewall, ...) don't
+	// have to look up the pod network themselves
 	result := &current.Result{
-		IPs: ips,
-		Interfaces: []*current.Interface{
-			hostInterface,
-			containerInterface,
-		},
+		IPs:        ips,
+		Interfaces: interfaces,
+		Routes:     routes,
+		DNS:        podDNS(conf.DNS, r),
 	}
 
 	// We append dummyVlanInterface only for pods using branch ENI
@@ -287,16 +521,143 @@ func add(args *skel.CmdArgs, cniTypes typeswrapper.CNITYPES, grpcClient grpcwrap
 		result.Interfaces = append(result.Interfaces, dummyVlanInterface)
 	}
 
-	return cniTypes.PrintResult(result, conf.CNIVersion)
+	// The runtime attaches the tap device to the guest
+	if vmNetwork != nil {
+		result.Interfaces = append(result.Interfaces, &current.Interface{Name: vmNetwork.TapName, Sandbox: args.Netns})
+	}
+
+	for _, secondary := range secondaryInterfaces {
+		result.Interfaces = append(result.Interfaces, secondary.hostInterface, secondary.containerInterface)
+		secondaryInterfaceIndex := len(result.Interfaces) - 1
+		result.IPs = append(result.IPs, &current.IPConfig{
+			Version:   "4",
+			Address:   *secondary.addr,
+			Interface: &secondaryInterfaceIndex,
+		})
+		result.Interfaces = append(result.Interfaces, secondary.dummyVlanInterface)
+	}
+
+	return cniTypes.PrintResult(cniutils.ResultForVersion(result, conf.CNIVersion), conf.CNIVersion)
+}
+
+// podDefaultRoute returns the gateway and the default route of the pod interface for an address family
+func podDefaultRoute(addrFamily string) (net.IP, *types.Route) {
+	if addrFamily == "6" {
+		return podGatewayIPv6, &types.Route{Dst: net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)}, GW: podGatewayIPv6}
+	}
+	return podGatewayIPv4, &types.Route{Dst: net.IPNet{IP: net.IPv4zero, Mask: net.CIDRMask(0, 32)}, GW: podGatewayIPv4}
+}
+
+// podRouteHints returns the gateway and the routes of the pod interface sent by ipamd. ok is false with an ipamd which
+// doesn't send them, or invalid ones, so that they are derived from the address family instead.
+func podRouteHints(r *pb.AddNetworkReply, log logger.Logger) (gw net.IP, routes []*types.Route, ok bool) {
+	if r.PodGateway == "" || len(r.PodRoutes) == 0 {
+		return nil, nil, false
+	}
+	gw = net.ParseIP(r.PodGateway)
+	if gw == nil {
+		log.Warnf("Ignoring the route hints of ipamd, invalid pod gateway %q", r.PodGateway)
+		return nil, nil, false
+	}
+	for _, hint := range r.PodRoutes {
+		_, dst, err := net.ParseCIDR(hint.Dst)
+		if err != nil {
+			log.Warnf("Ignoring the route hints of ipamd, invalid destination %q: %v", hint.Dst, err)
+			return nil, nil, false
+		}
+		route := &types.Route{Dst: *dst}
+		if hint.GW != "" {
+			if route.GW = net.ParseIP(hint.GW); route.GW == nil {
+				log.Warnf("Ignoring the route hints of ipamd, invalid gateway %q", hint.GW)
+				return nil, nil, false
+			}
+		}
+		routes = append(routes, route)
+	}
+	return gw, routes, true
+}
+
+// podDNS returns the DNS configuration of the CNI result. The one of the network configuration takes precedence over
+// the VPC name servers returned by ipamd with ENABLE_CNI_DNS_RESULT.
+func podDNS(confDNS types.DNS, r *pb.AddNetworkReply) types.DNS {
+	if len(confDNS.Nameservers) > 0 || len(r.DNSNameservers) == 0 {
+		return confDNS
+	}
+	return types.DNS{
+		Nameservers: r.DNSNameservers,
+		Domain:      r.DNSDomain,
+		Search:      r.DNSSearch,
+		Options:     confDNS.Options,
+	}
+}
+
+// validTraceID is the format of the trace IDs accepted from the container runtime, which end up in the user agent of the
+// EC2 calls
+var validTraceID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// requestTraceID returns the trace ID passed by the container runtime, or a new one if it passed none or an invalid one
+func requestTraceID(conf *NetConf) string {
+	if conf != nil && validTraceID.MatchString(conf.RuntimeConfig.TraceID) {
+		return conf.RuntimeConfig.TraceID
+	}
+	return newTraceID()
+}
+
+// newTraceID returns a random ID to correlate the plugin log of a request with the ipamd log
+func newTraceID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// requestID returns the ID of a request to ipamd. It is derived from the sandbox so that the container runtime
+// retrying the request, which runs the plugin again, sends the same ID.
+func requestID(op, containerID, ifName, networkName string) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{op, containerID, ifName, networkName}, "/")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// podSecondaryInterface holds the CNI result entries of an additional branch ENI attached to a pod
+type podSecondaryInterface struct {
+	addr               *net.IPNet
+	hostInterface      *current.Interface
+	containerInterface *current.Interface
+	dummyVlanInterface *current.Interface
+}
+
+// setupPodSecondaryInterfaces attaches the additional branch ENIs returned by ipamd as secondary interfaces of the pod
+func setupPodSecondaryInterfaces(driverClient driver.NetworkAPIs, conf *NetConf, k8sArgs K8sArgs, netns string, hostVethNamePrefix string,
+	r *pb.AddNetworkReply, mtu int, log logger.Logger) ([]podSecondaryInterface, error) {
+	podNamespace, podName := string(k8sArgs.K8S_POD_NAMESPACE), string(k8sArgs.K8S_POD_NAME)
+	var secondaryInterfaces []podSecondaryInterface
+	for i, secondary := range r.SecondaryInterfaces {
+		addr := &net.IPNet{
+			IP:   net.ParseIP(secondary.IPv4Addr),
+			Mask: net.CIDRMask(32, 32),
+		}
+		hostVethName := generateSecondaryHostVethName(hostVethNamePrefix, podNamespace, podName, secondary.IfName)
+		err := driverClient.SetupBranchENIPodSecondaryNetwork(hostVethName, secondary.IfName, netns, addr, podSecondaryRouteTableBase+i+1,
+			int(secondary.VlanId), secondary.ENIMAC, secondary.SubnetGW, int(r.ParentIfIndex), mtu, conf.PodSGEnforcingMode, log)
+		if err != nil {
+			return secondaryInterfaces, errors.Wrapf(err, "failed to setup secondary interface %s", secondary.IfName)
+		}
+		dummyVlanInterfaceName := generateSecondaryHostVethName(dummyVlanInterfacePrefix, podNamespace, podName, secondary.IfName)
+		secondaryInterfaces = append(secondaryInterfaces, podSecondaryInterface{
+			addr:               addr,
+			hostInterface:      &current.Interface{Name: hostVethName},
+			containerInterface: &current.Interface{Name: secondary.IfName, Sandbox: netns},
+			dummyVlanInterface: &current.Interface{Name: dummyVlanInterfaceName, Mac: fmt.Sprint(secondary.VlanId)},
+		})
+	}
+	return secondaryInterfaces, nil
 }
 
-// generateHostVethName returns a name to be used on the host-side veth device.
-// The veth name is generated such that it aligns with the value expected
-// by Calico for NetworkPolicy enforcement.
-func generateHostVethName(prefix, namespace, podname string) string {
-	h := sha1.New()
-	h.Write([]byte(fmt.Sprintf("%s.%s", namespace, podname)))
-	return fmt.Sprintf("%s%s", prefix, hex.EncodeToString(h.Sum(nil))[:11])
+// generateSecondaryHostVethName returns the host-side veth device name of a secondary pod interface. '/' can't be
+// part of a pod name, so the names never collide with the ones of primary interfaces.
+func generateSecondaryHostVethName(prefix, namespace, podname, ifName string) string {
+	return networkutils.GenerateHostVethName(prefix, namespace, fmt.Sprintf("%s/%s", podname, ifName))
 }
 
 func cmdDel(args *skel.CmdArgs) error {
@@ -313,8 +674,9 @@ func del(args *skel.CmdArgs, cniTypes typeswrapper.CNITYPES, grpcClient grpcwrap
 		return errors.Wrap(err, "del cmd: error loading config from args")
 	}
 
-	log.Infof("Received CNI del request: ContainerID(%s) Netns(%s) IfName(%s) Args(%s) Path(%s) argsStdinData(%s)",
-		args.ContainerID, args.Netns, args.IfName, args.Args, args.Path, args.StdinData)
+	traceID := requestTraceID(conf)
+	log.Infof("Received CNI del request: ContainerID(%s) Netns(%s) IfName(%s) Args(%s) Path(%s) argsStdinData(%s) TraceID(%s)",
+		args.ContainerID, args.Netns, args.IfName, args.Args, args.Path, args.StdinData, traceID)
 
 	var k8sArgs K8sArgs
 	if err := cniTypes.LoadArgs(args.Args, &k8sArgs); err != nil {
@@ -334,7 +696,7 @@ func del(args *skel.CmdArgs, cniTypes typeswrapper.CNITYPES, grpcClient grpcwrap
 
 	// notify local IP address manager to free secondary IP
 	// Set up a connection to the server.
-	conn, err := grpcClient.Dial(ipamdAddress, grpc.WithInsecure())
+	conn, err := dialIPAMD(grpcClient, conf.IPAMDSocket, ipamdAddress)
 	if err != nil {
 		log.Errorf("Failed to connect to backend server for container %s: %v",
 			args.ContainerID, err)
@@ -345,7 +707,7 @@ func del(args *skel.CmdArgs, cniTypes typeswrapper.CNITYPES, grpcClient grpcwrap
 
 	c := rpcClient.NewCNIBackendClient(conn)
 
-	r, err := c.DelNetwork(context.Background(), &pb.DelNetworkRequest{
+	delRequest := &pb.DelNetworkRequest{
 		ClientVersion:              version,
 		K8S_POD_NAME:               string(k8sArgs.K8S_POD_NAME),
 		K8S_POD_NAMESPACE:          string(k8sArgs.K8S_POD_NAMESPACE),
@@ -354,6 +716,13 @@ func del(args *skel.CmdArgs, cniTypes typeswrapper.CNITYPES, grpcClient grpcwrap
 		ContainerID:                args.ContainerID,
 		IfName:                     args.IfName,
 		Reason:                     "PodDeleted",
+		TraceID:                    traceID,
+		RequestID:                  requestID("del", args.ContainerID, args.IfName, conf.Name),
+	}
+	var r *pb.DelNetworkReply
+	err = retryUnavailable(log, func() (err error) {
+		r, err = c.DelNetwork(pb.WithTraceID(context.Background(), traceID), delRequest)
+		return err
 	})
 
 	if err != nil {
@@ -362,6 +731,7 @@ func del(args *skel.CmdArgs, cniTypes typeswrapper.CNITYPES, grpcClient grpcwrap
 			// an IPAM plugin should generally release an IP allocation and return success even if the container network
 			// namespace no longer exists, unless that network namespace is critical for IPAM management
 			log.Infof("Container %s not found", args.ContainerID)
+			teardownWithPrevResult(driverClient, conf, k8sArgs, args.IfName, log)
 			return nil
 		}
 		log.Errorf("Error received from DelNetwork gRPC call for container %s: %v",
@@ -394,15 +764,34 @@ func del(args *skel.CmdArgs, cniTypes typeswrapper.CNITYPES, grpcClient grpcwrap
 			Mask: net.CIDRMask(maskLen, maskLen),
 		}
 
-		// vlanID != 0 means pod using security group
-		if r.PodVlanId != 0 {
+		if r.DedicatedENI {
+			if isNetnsEmpty(args.Netns) {
+				log.Infof("Ignoring TeardownDedicatedENIPodNetwork as Netns is empty for pod %s namespace %s", k8sArgs.K8S_POD_NAME, k8sArgs.K8S_POD_NAMESPACE)
+				return nil
+			}
+			err = driverClient.TeardownDedicatedENIPodNetwork(args.Netns, r.PodENIMAC, log)
+		} else if r.PodVlanId != 0 {
+			// vlanID != 0 means pod using security group
 			if isNetnsEmpty(args.Netns) {
 				log.Infof("Ignoring TeardownPodENI as Netns is empty for SG pod:%s namespace: %s containerID:%s", k8sArgs.K8S_POD_NAME, k8sArgs.K8S_POD_NAMESPACE, k8sArgs.K8S_POD_INFRA_CONTAINER_ID)
 				return nil
 			}
 			err = driverClient.TeardownBranchENIPodNetwork(addr, int(r.PodVlanId), conf.PodSGEnforcingMode, log)
+			for _, secondary := range r.SecondaryInterfaces {
+				if err != nil {
+					break
+				}
+				secondaryAddr := &net.IPNet{IP: net.ParseIP(secondary.IPv4Addr), Mask: net.CIDRMask(32, 32)}
+				err = driverClient.TeardownBranchENIPodNetwork(secondaryAddr, int(secondary.VlanId), conf.PodSGEnforcingMode, log)
+			}
 		} else {
-			err = driverClient.TeardownPodNetwork(addr, int(r.DeviceNumber), log)
+			routeTable := conf.podRouteTable(r.DeviceNumber)
+			err = driverClient.TeardownPodNetwork(addr, routeTable, log)
+			// The IP is already released, so leave the routes and rules to ipamd rather than fail a DEL the runtime may
+			// never retry
+			if err != nil && enqueueTeardown(c, args.ContainerID, addr, routeTable, err, traceID, log) {
+				return nil
+			}
 		}
 
 		if err != nil {
@@ -416,6 +805,36 @@ func del(args *skel.CmdArgs, cniTypes typeswrapper.CNITYPES, grpcClient grpcwrap
 	return nil
 }
 
+// enqueueTeardown hands the routes and rules of addr that failed to be torn down with teardownErr to ipamd, which
+// retries until they are gone, and returns true if ipamd took them
+func enqueueTeardown(c pb.CNIBackendClient, containerID string, addr *net.IPNet, routeTable int, teardownErr error,
+	traceID string, log logger.Logger) bool {
+	request := &pb.EnqueueTeardownRequest{
+		ClientVersion: version,
+		ContainerID:   containerID,
+		RouteTable:    int32(routeTable),
+		Error:         teardownErr.Error(),
+		TraceID:       traceID,
+	}
+	if addr.IP.To4() != nil {
+		request.IPv4Addr = addr.IP.String()
+	} else {
+		request.IPv6Addr = addr.IP.String()
+	}
+	r, err := c.EnqueueTeardown(pb.WithTraceID(context.Background(), traceID), request)
+	if err != nil {
+		log.Errorf("Failed to hand the teardown of %s to ipamd: %v", addr.String(), err)
+		return false
+	}
+	if !r.Success {
+		log.Errorf("Failed to hand the teardown of %s to ipamd: Success == false", addr.String())
+		return false
+	}
+	log.Warnf("Failed on TeardownPodNetwork for container ID %s, ipamd retries it with %d pending teardowns: %v",
+		containerID, r.QueueDepth, teardownErr)
+	return true
+}
+
 // tryDelWithPrevResult will try to process CNI delete request without IPAMD.
 // returns true if the del request is handled.
 func tryDelWithPrevResult(driverClient driver.NetworkAPIs, conf *NetConf, k8sArgs K8sArgs, contVethName string, netNS string, log logger.Logger) (bool, error) {
@@ -425,7 +844,7 @@ func tryDelWithPrevResult(driverClient driver.NetworkAPIs, conf *NetConf, k8sArg
 		return false, nil
 	}
 
-	dummyIfaceName := generateHostVethName(dummyVlanInterfacePrefix, string(k8sArgs.K8S_POD_NAMESPACE), string(k8sArgs.K8S_POD_NAME))
+	dummyIfaceName := networkutils.GenerateHostVethName(dummyVlanInterfacePrefix, string(k8sArgs.K8S_POD_NAMESPACE), string(k8sArgs.K8S_POD_NAME))
 	_, dummyIface, found := cniutils.FindInterfaceByName(prevResult.Interfaces, dummyIfaceName)
 	if !found {
 		return false, nil
@@ -452,9 +871,121 @@ func tryDelWithPrevResult(driverClient driver.NetworkAPIs, conf *NetConf, k8sArg
 	if err := driverClient.TeardownBranchENIPodNetwork(&containerIP, podVlanID, conf.PodSGEnforcingMode, log); err != nil {
 		return true, err
 	}
+
+	// Tear down the secondary interfaces, which have their own dummy interface carrying the vlanID
+	for _, iface := range prevResult.Interfaces {
+		if iface.Sandbox == "" || iface.Name == contVethName {
+			continue
+		}
+		secondaryDummyIfaceName := generateSecondaryHostVethName(dummyVlanInterfacePrefix, string(k8sArgs.K8S_POD_NAMESPACE), string(k8sArgs.K8S_POD_NAME), iface.Name)
+		_, secondaryDummyIface, found := cniutils.FindInterfaceByName(prevResult.Interfaces, secondaryDummyIfaceName)
+		if !found {
+			continue
+		}
+		secondaryVlanID, err := strconv.Atoi(secondaryDummyIface.Mac)
+		if err != nil || secondaryVlanID == 0 {
+			return true, errors.Errorf("malformed vlanID in prevResult for %s: %s", iface.Name, secondaryDummyIface.Mac)
+		}
+		secondaryIfaceIndex, _, _ := cniutils.FindInterfaceByName(prevResult.Interfaces, iface.Name)
+		secondaryIPs := cniutils.FindIPConfigsByIfaceIndex(prevResult.IPs, secondaryIfaceIndex)
+		if len(secondaryIPs) != 1 {
+			return true, errors.Errorf("found %d containerIP for %v in prevResult", len(secondaryIPs), iface.Name)
+		}
+		if err := driverClient.TeardownBranchENIPodNetwork(&secondaryIPs[0].Address, secondaryVlanID, conf.PodSGEnforcingMode, log); err != nil {
+			return true, err
+		}
+	}
 	return true, nil
 }
 
+// teardownWithPrevResult removes the host routes of a pod that ipamd no longer knows about, e.g. after its checkpoint
+// was lost, so that its IP doesn't stay routed to the old host veth. prevResult doesn't record the device number of the
+// ENI, so only the routes and rules in the main table are removed. The IP may belong to another pod by now, so only the
+// routes through the host veth of this pod are removed.
+func teardownWithPrevResult(driverClient driver.NetworkAPIs, conf *NetConf, k8sArgs K8sArgs, contVethName string, log logger.Logger) {
+	prevResult, ok := conf.PrevResult.(*current.Result)
+	if !ok {
+		return
+	}
+	hostVethName := networkutils.GenerateHostVethName(conf.VethPrefix, string(k8sArgs.K8S_POD_NAMESPACE), string(k8sArgs.K8S_POD_NAME))
+	if _, _, found := cniutils.FindInterfaceByName(prevResult.Interfaces, hostVethName); !found {
+		return
+	}
+	containerIfaceIndex, _, found := cniutils.FindInterfaceByName(prevResult.Interfaces, contVethName)
+	if !found {
+		return
+	}
+	for _, ipConfig := range cniutils.FindIPConfigsByIfaceIndex(prevResult.IPs, containerIfaceIndex) {
+		containerAddr := ipConfig.Address
+		if err := driverClient.TeardownStalePodNetwork(&containerAddr, hostVethName, log); err != nil {
+			log.Warnf("Failed to tear down pod network of %s from prevResult: %v", containerAddr.String(), err)
+		}
+	}
+}
+
+func cmdCheck(args *skel.CmdArgs) error {
+	return check(args, typeswrapper.New(), driver.New())
+}
+
+// check verifies that the pod network described by prevResult is still in place. ipamd isn't involved, so CHECK
+// keeps working while it restarts.
+func check(args *skel.CmdArgs, cniTypes typeswrapper.CNITYPES, driverClient driver.NetworkAPIs) error {
+	conf, log, err := LoadNetConf(args.StdinData)
+	if err != nil {
+		return errors.Wrap(err, "check cmd: error loading config from args")
+	}
+	log.Infof("Received CNI check request: ContainerID(%s) Netns(%s) IfName(%s) Args(%s) Path(%s) argsStdinData(%s)",
+		args.ContainerID, args.Netns, args.IfName, args.Args, args.Path, args.StdinData)
+
+	// CHECK is only called with cniVersion 0.4.0 or later, where the runtime has to pass prevResult
+	prevResult, ok := conf.PrevResult.(*current.Result)
+	if !ok {
+		return errors.New("check cmd: required prevResult missing")
+	}
+
+	var k8sArgs K8sArgs
+	if err := cniTypes.LoadArgs(args.Args, &k8sArgs); err != nil {
+		log.Errorf("Failed to load k8s config from args: %v", err)
+		return errors.Wrap(err, "check cmd: failed to load k8s config from args")
+	}
+	podNamespace, podName := string(k8sArgs.K8S_POD_NAMESPACE), string(k8sArgs.K8S_POD_NAME)
+
+	containerIfaceIndex, _, found := cniutils.FindInterfaceByName(prevResult.Interfaces, args.IfName)
+	if !found {
+		return errors.Errorf("check cmd: cannot find contVethName %s in prevResult", args.IfName)
+	}
+	containerIPs := cniutils.FindIPConfigsByIfaceIndex(prevResult.IPs, containerIfaceIndex)
+	if len(containerIPs) != 1 {
+		return errors.Errorf("check cmd: found %d containerIP for %v in prevResult", len(containerIPs), args.IfName)
+	}
+
+	// Pods with a dedicated ENI have no host veth, the ENI itself is the container interface
+	if len(prevResult.Interfaces) == 1 && prevResult.Interfaces[0].Mac != "" {
+		if err := driverClient.CheckDedicatedENIPodNetwork(args.IfName, args.Netns, &containerIPs[0].Address, log); err != nil {
+			log.Errorf("Failed CheckDedicatedENIPodNetwork for container %s: %v", args.ContainerID, err)
+			return errors.Wrap(err, "check cmd: pod network is not set up")
+		}
+		return nil
+	}
+
+	// Pods using branch ENIs have a dummy interface carrying their vlanID, and a different host veth prefix
+	hostVethNamePrefix := conf.VethPrefix
+	dummyIfaceName := networkutils.GenerateHostVethName(dummyVlanInterfacePrefix, podNamespace, podName)
+	if _, _, found := cniutils.FindInterfaceByName(prevResult.Interfaces, dummyIfaceName); found {
+		hostVethNamePrefix = sgpp.BuildHostVethNamePrefix(conf.VethPrefix, conf.PodSGEnforcingMode)
+	}
+	hostVethName := networkutils.GenerateHostVethName(hostVethNamePrefix, podNamespace, podName)
+	if _, _, found := cniutils.FindInterfaceByName(prevResult.Interfaces, hostVethName); !found {
+		return errors.Errorf("check cmd: cannot find host veth %s in prevResult", hostVethName)
+	}
+
+	if err := driverClient.CheckPodNetwork(hostVethName, args.IfName, args.Netns, &containerIPs[0].Address, log); err != nil {
+		log.Errorf("Failed CheckPodNetwork for container %s: %v", args.ContainerID, err)
+		return errors.Wrap(err, "check cmd: pod network is not set up")
+	}
+	return nil
+}
+
 // Scope usage of this function to only SG pods scenario
 // Don't process deletes when NetNS is empty
 // as it implies that veth for this request is already deleted
@@ -463,11 +994,94 @@ func isNetnsEmpty(Netns string) bool {
 	return Netns == ""
 }
 
+// cniCommandGC is the CNI_COMMAND of the GC verb added in CNI spec 1.1. The vendored skel predates it, so main
+// dispatches it itself.
+const cniCommandGC = "GC"
+
+func cmdGC(stdinData []byte) error {
+	return gc(stdinData, grpcwrapper.New(), rpcwrapper.New(), driver.New())
+}
+
+// gc asks ipamd to release the IPs of the sandboxes that are not among the valid attachments of the network, and tears
+// down the routes of their pods
+func gc(stdinData []byte, grpcClient grpcwrapper.GRPC, rpcClient rpcwrapper.RPC, driverClient driver.NetworkAPIs) error {
+	conf, log, err := LoadNetConf(stdinData)
+	if err != nil {
+		return errors.Wrap(err, "gc cmd: error loading config from args")
+	}
+
+	traceID := requestTraceID(conf)
+	log.Infof("Received CNI gc request: Network(%s) ValidAttachments(%d) TraceID(%s)", conf.Name, len(conf.ValidAttachments), traceID)
+
+	conn, err := dialIPAMD(grpcClient, conf.IPAMDSocket, ipamdAddress)
+	if err != nil {
+		log.Errorf("Failed to connect to backend server for gc: %v", err)
+		return errors.Wrap(err, "gc cmd: failed to connect to backend server")
+	}
+	defer conn.Close()
+
+	c := rpcClient.NewCNIBackendClient(conn)
+
+	validAttachments := make([]*pb.GCAttachment, 0, len(conf.ValidAttachments))
+	for _, attachment := range conf.ValidAttachments {
+		validAttachments = append(validAttachments, &pb.GCAttachment{ContainerID: attachment.ContainerID, IfName: attachment.IfName})
+	}
+	r, err := c.GarbageCollect(pb.WithTraceID(context.Background(), traceID), &pb.GarbageCollectRequest{
+		ClientVersion:    version,
+		NetworkName:      conf.Name,
+		ValidAttachments: validAttachments,
+		TraceID:          traceID,
+	})
+	if err != nil {
+		log.Errorf("Error received from GarbageCollect gRPC call: %v", err)
+		return errors.Wrap(err, "gc cmd: error received from GarbageCollect gRPC call")
+	}
+	if !r.Success {
+		log.Errorf("Failed to process gc request: Success == false")
+		return errors.New("gc cmd: failed to process gc request")
+	}
+
+	// The IPs are already released, so keep tearing down the other pods when one fails
+	var teardownErr error
+	for _, released := range r.ReleasedIPs {
+		addr := &net.IPNet{IP: net.ParseIP(released.IPv4Addr), Mask: net.CIDRMask(32, 32)}
+		if released.IPv6Addr != "" {
+			addr = &net.IPNet{IP: net.ParseIP(released.IPv6Addr), Mask: net.CIDRMask(128, 128)}
+		}
+		routeTable := conf.podRouteTable(released.DeviceNumber)
+		if err := driverClient.TeardownPodNetwork(addr, routeTable, log); err != nil &&
+			!enqueueTeardown(c, "", addr, routeTable, err, traceID, log) {
+			log.Errorf("Failed on TeardownPodNetwork for stale IP %s: %v", addr.String(), err)
+			teardownErr = errors.Wrap(err, "gc cmd: failed on tear down pod network")
+		}
+	}
+	log.Infof("Released %d stale IPs, trace ID %s", len(r.ReleasedIPs), traceID)
+	return teardownErr
+}
+
+// runGC runs the GC verb with the network configuration on stdin
+func runGC() *types.Error {
+	stdinData, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return types.NewError(types.ErrIOFailure, err.Error(), "")
+	}
+	if err := cmdGC(stdinData); err != nil {
+		return types.NewError(types.ErrInternal, err.Error(), "")
+	}
+	return nil
+}
+
 func main() {
 	log := logger.DefaultLogger()
 	about := fmt.Sprintf("AWS CNI %s", version)
 	exitCode := 0
-	if e := skel.PluginMainWithError(cmdAdd, nil, cmdDel, cniSpecVersion.All, about); e != nil {
+	var e *types.Error
+	if os.Getenv("CNI_COMMAND") == cniCommandGC {
+		e = runGC()
+	} else {
+		e = skel.PluginMainWithError(cmdAdd, cmdCheck, cmdDel, cniutils.AllSpecVersions, about)
+	}
+	if e != nil {
 		if err := e.Print(); err != nil {
 			log.Errorf("Failed to write error to stdout: %v", err)
 		}
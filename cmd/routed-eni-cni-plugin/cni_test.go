@@ -14,12 +14,16 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/sgpp"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/cniutils"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/containernetworking/cni/pkg/types/current"
@@ -28,9 +32,14 @@ import (
 	"github.com/containernetworking/cni/pkg/types"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
+	"github.com/aws/amazon-vpc-cni-k8s/cmd/routed-eni-cni-plugin/driver"
 	mock_driver "github.com/aws/amazon-vpc-cni-k8s/cmd/routed-eni-cni-plugin/driver/mocks"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/grpcwrapper"
 	mock_grpcwrapper "github.com/aws/amazon-vpc-cni-k8s/pkg/grpcwrapper/mocks"
 	mock_rpcwrapper "github.com/aws/amazon-vpc-cni-k8s/pkg/rpcwrapper/mocks"
 	mock_typeswrapper "github.com/aws/amazon-vpc-cni-k8s/pkg/typeswrapper/mocks"
@@ -49,6 +58,8 @@ const (
 	cniType        = "aws-cni"
 	ipAddr         = "10.0.1.15"
 	devNum         = 4
+	// devRouteTable is the route table of device devNum with the default route table base
+	devRouteTable = 5
 )
 
 var netConf = &NetConf{
@@ -102,7 +113,7 @@ func TestCmdAdd(t *testing.T) {
 		Mask: net.IPv4Mask(255, 255, 255, 255),
 	}
 	mocksNetwork.EXPECT().SetupPodNetwork(gomock.Any(), cmdArgs.IfName, cmdArgs.Netns,
-		v4Addr, nil, int(addNetworkReply.DeviceNumber), gomock.Any(), gomock.Any()).Return(nil)
+		v4Addr, nil, devRouteTable, gomock.Any(), gomock.Any()).Return(nil)
 
 	mocksTypes.EXPECT().PrintResult(gomock.Any(), gomock.Any()).Return(nil)
 
@@ -137,6 +148,40 @@ func TestCmdAddNetworkErr(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestCmdAddRetriedByRuntime(t *testing.T) {
+	ctrl, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork := setup(t)
+	defer ctrl.Finish()
+
+	stdinData, _ := json.Marshal(netConf)
+	conn, _ := grpc.Dial(ipamdAddress, grpc.WithInsecure())
+	mockC := mock_rpc.NewMockCNIBackendClient(ctrl)
+	mocksTypes.EXPECT().LoadArgs(gomock.Any(), gomock.Any()).Return(nil).Times(3)
+	mocksGRPC.EXPECT().Dial(gomock.Any(), gomock.Any()).Return(conn, nil).Times(3)
+	mocksRPC.EXPECT().NewCNIBackendClient(conn).Return(mockC).Times(3)
+
+	var requestIDs []string
+	mockC.EXPECT().AddNetwork(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, in *rpc.AddNetworkRequest, _ ...grpc.CallOption) (*rpc.AddNetworkReply, error) {
+			requestIDs = append(requestIDs, in.RequestID)
+			return nil, errors.New("Error on AddNetworkReply")
+		}).Times(3)
+
+	// The container runtime retries a failed add by running the plugin again, which sends the same request ID
+	for _, id := range []string{containerID, containerID, "another-container"} {
+		cmdArgs := &skel.CmdArgs{ContainerID: id,
+			Netns:     netNS,
+			IfName:    ifName,
+			StdinData: stdinData}
+		err := add(cmdArgs, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork)
+		assert.Error(t, err)
+	}
+	assert.Len(t, requestIDs, 3)
+	assert.NotEmpty(t, requestIDs[0])
+	assert.Equal(t, requestIDs[0], requestIDs[1])
+	assert.NotEqual(t, requestIDs[0], requestIDs[2])
+	assert.NotEqual(t, requestID("del", containerID, ifName, netConf.Name), requestIDs[0])
+}
+
 func TestCmdAddErrSetupPodNetwork(t *testing.T) {
 	ctrl, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork := setup(t)
 	defer ctrl.Finish()
@@ -165,7 +210,7 @@ func TestCmdAddErrSetupPodNetwork(t *testing.T) {
 	}
 
 	mocksNetwork.EXPECT().SetupPodNetwork(gomock.Any(), cmdArgs.IfName, cmdArgs.Netns,
-		addr, nil, int(addNetworkReply.DeviceNumber), gomock.Any(), gomock.Any()).Return(errors.New("error on SetupPodNetwork"))
+		addr, nil, devRouteTable, gomock.Any(), gomock.Any()).Return(errors.New("error on SetupPodNetwork"))
 
 	// when SetupPodNetwork fails, expect to return IP back to datastore
 	delNetworkReply := &rpc.DelNetworkReply{Success: true, IPv4Addr: ipAddr, DeviceNumber: devNum}
@@ -176,6 +221,198 @@ func TestCmdAddErrSetupPodNetwork(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestCmdAddWithMulticast(t *testing.T) {
+	ctrl, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork := setup(t)
+	defer ctrl.Finish()
+
+	stdinData, _ := json.Marshal(netConf)
+
+	cmdArgs := &skel.CmdArgs{ContainerID: containerID,
+		Netns:     netNS,
+		IfName:    ifName,
+		StdinData: stdinData}
+
+	mocksTypes.EXPECT().LoadArgs(gomock.Any(), gomock.Any()).Return(nil)
+
+	conn, _ := grpc.Dial(ipamdAddress, grpc.WithInsecure())
+
+	mocksGRPC.EXPECT().Dial(gomock.Any(), gomock.Any()).Return(conn, nil)
+	mockC := mock_rpc.NewMockCNIBackendClient(ctrl)
+	mocksRPC.EXPECT().NewCNIBackendClient(conn).Return(mockC)
+
+	addNetworkReply := &rpc.AddNetworkReply{Success: true, IPv4Addr: ipAddr, DeviceNumber: devNum, Multicast: true}
+	mockC.EXPECT().AddNetwork(gomock.Any(), gomock.Any()).Return(addNetworkReply, nil)
+
+	v4Addr := &net.IPNet{
+		IP:   net.ParseIP(addNetworkReply.IPv4Addr),
+		Mask: net.IPv4Mask(255, 255, 255, 255),
+	}
+	mocksNetwork.EXPECT().SetupPodNetwork(gomock.Any(), cmdArgs.IfName, cmdArgs.Netns,
+		v4Addr, nil, devRouteTable, gomock.Any(), gomock.Any()).Return(nil)
+	mocksNetwork.EXPECT().SetupPodMulticast(gomock.Any(), cmdArgs.IfName, cmdArgs.Netns, v4Addr, gomock.Any()).Return(nil)
+
+	mocksTypes.EXPECT().PrintResult(gomock.Any(), gomock.Any()).Return(nil)
+
+	err := add(cmdArgs, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork)
+	assert.Nil(t, err)
+}
+
+func TestCmdAddWithPodMTU(t *testing.T) {
+	ctrl, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork := setup(t)
+	defer ctrl.Finish()
+
+	stdinData, _ := json.Marshal(netConf)
+
+	cmdArgs := &skel.CmdArgs{ContainerID: containerID,
+		Netns:     netNS,
+		IfName:    ifName,
+		StdinData: stdinData}
+
+	mocksTypes.EXPECT().LoadArgs(gomock.Any(), gomock.Any()).Return(nil)
+
+	conn, _ := grpc.Dial(ipamdAddress, grpc.WithInsecure())
+
+	mocksGRPC.EXPECT().Dial(gomock.Any(), gomock.Any()).Return(conn, nil)
+	mockC := mock_rpc.NewMockCNIBackendClient(ctrl)
+	mocksRPC.EXPECT().NewCNIBackendClient(conn).Return(mockC)
+
+	addNetworkReply := &rpc.AddNetworkReply{Success: true, IPv4Addr: ipAddr, DeviceNumber: devNum, PodMTU: 1500}
+	mockC.EXPECT().AddNetwork(gomock.Any(), gomock.Any()).Return(addNetworkReply, nil)
+
+	v4Addr := &net.IPNet{
+		IP:   net.ParseIP(addNetworkReply.IPv4Addr),
+		Mask: net.IPv4Mask(255, 255, 255, 255),
+	}
+	mocksNetwork.EXPECT().SetupPodNetwork(gomock.Any(), cmdArgs.IfName, cmdArgs.Netns,
+		v4Addr, nil, devRouteTable, 1500, gomock.Any()).Return(nil)
+
+	mocksTypes.EXPECT().PrintResult(gomock.Any(), gomock.Any()).Return(nil)
+
+	err := add(cmdArgs, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork)
+	assert.Nil(t, err)
+}
+
+func TestCmdAddWithVMNetwork(t *testing.T) {
+	ctrl, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork := setup(t)
+	defer ctrl.Finish()
+
+	conf := *netConf
+	conf.RuntimeConfig = RuntimeConfig{VMNetwork: &VMNetwork{Mode: "tap"}}
+	stdinData, _ := json.Marshal(conf)
+
+	cmdArgs := &skel.CmdArgs{ContainerID: containerID,
+		Netns:     netNS,
+		IfName:    ifName,
+		StdinData: stdinData}
+
+	mocksTypes.EXPECT().LoadArgs(gomock.Any(), gomock.Any()).Return(nil)
+
+	conn, _ := grpc.Dial(ipamdAddress, grpc.WithInsecure())
+
+	mocksGRPC.EXPECT().Dial(gomock.Any(), gomock.Any()).Return(conn, nil)
+	mockC := mock_rpc.NewMockCNIBackendClient(ctrl)
+	mocksRPC.EXPECT().NewCNIBackendClient(conn).Return(mockC)
+
+	addNetworkReply := &rpc.AddNetworkReply{Success: true, IPv4Addr: ipAddr, DeviceNumber: devNum}
+	mockC.EXPECT().AddNetwork(gomock.Any(), gomock.Any()).Return(addNetworkReply, nil)
+
+	v4Addr := &net.IPNet{
+		IP:   net.ParseIP(addNetworkReply.IPv4Addr),
+		Mask: net.IPv4Mask(255, 255, 255, 255),
+	}
+	mocksNetwork.EXPECT().SetupPodNetwork(gomock.Any(), cmdArgs.IfName, cmdArgs.Netns,
+		v4Addr, nil, devRouteTable, gomock.Any(), gomock.Any()).Return(nil)
+	mocksNetwork.EXPECT().SetupPodVMNetwork(gomock.Any(), cmdArgs.IfName, "tap0", cmdArgs.Netns, gomock.Any(),
+		gomock.Any()).Return(nil)
+
+	mocksTypes.EXPECT().PrintResult(gomock.Any(), gomock.Any()).DoAndReturn(func(result types.Result, version string) error {
+		r := result.(*current.Result)
+		assert.Len(t, r.Interfaces, 3)
+		assert.Equal(t, &current.Interface{Name: "tap0", Sandbox: netNS}, r.Interfaces[2])
+		return nil
+	})
+
+	err := add(cmdArgs, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork)
+	assert.Nil(t, err)
+}
+
+func TestCmdAddWithWarmVeth(t *testing.T) {
+	ctrl, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork := setup(t)
+	defer ctrl.Finish()
+
+	stdinData, _ := json.Marshal(netConf)
+
+	cmdArgs := &skel.CmdArgs{ContainerID: containerID,
+		Netns:     netNS,
+		IfName:    ifName,
+		StdinData: stdinData}
+
+	mocksTypes.EXPECT().LoadArgs(gomock.Any(), gomock.Any()).Return(nil)
+
+	conn, _ := grpc.Dial(ipamdAddress, grpc.WithInsecure())
+
+	mocksGRPC.EXPECT().Dial(gomock.Any(), gomock.Any()).Return(conn, nil)
+	mockC := mock_rpc.NewMockCNIBackendClient(ctrl)
+	mocksRPC.EXPECT().NewCNIBackendClient(conn).Return(mockC)
+
+	addNetworkReply := &rpc.AddNetworkReply{Success: true, IPv4Addr: ipAddr, DeviceNumber: devNum, WarmVeth: "warmveth3"}
+	mockC.EXPECT().AddNetwork(gomock.Any(), gomock.Any()).Return(addNetworkReply, nil)
+
+	v4Addr := &net.IPNet{
+		IP:   net.ParseIP(addNetworkReply.IPv4Addr),
+		Mask: net.IPv4Mask(255, 255, 255, 255),
+	}
+	mocksNetwork.EXPECT().SetupWarmVethPodNetwork("warmveth3", "warmpeer3", gomock.Any(), cmdArgs.IfName, cmdArgs.Netns,
+		v4Addr, gomock.Any(), gomock.Any()).Return(nil)
+
+	mocksTypes.EXPECT().PrintResult(gomock.Any(), gomock.Any()).Return(nil)
+
+	err := add(cmdArgs, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork)
+	assert.Nil(t, err)
+}
+
+func TestCmdAddWithEgressRoutes(t *testing.T) {
+	ctrl, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork := setup(t)
+	defer ctrl.Finish()
+
+	stdinData, _ := json.Marshal(netConf)
+
+	cmdArgs := &skel.CmdArgs{ContainerID: containerID,
+		Netns:     netNS,
+		IfName:    ifName,
+		StdinData: stdinData}
+
+	mocksTypes.EXPECT().LoadArgs(gomock.Any(), gomock.Any()).Return(nil)
+
+	conn, _ := grpc.Dial(ipamdAddress, grpc.WithInsecure())
+
+	mocksGRPC.EXPECT().Dial(gomock.Any(), gomock.Any()).Return(conn, nil)
+	mockC := mock_rpc.NewMockCNIBackendClient(ctrl)
+	mocksRPC.EXPECT().NewCNIBackendClient(conn).Return(mockC)
+
+	addNetworkReply := &rpc.AddNetworkReply{Success: true, IPv4Addr: ipAddr, DeviceNumber: devNum,
+		EgressRoutes: []*rpc.EgressRoute{{CIDR: "10.0.0.0/8"}, {CIDR: "203.0.113.0/24", ViaPodENI: true}}}
+	mockC.EXPECT().AddNetwork(gomock.Any(), gomock.Any()).Return(addNetworkReply, nil)
+
+	v4Addr := &net.IPNet{
+		IP:   net.ParseIP(addNetworkReply.IPv4Addr),
+		Mask: net.IPv4Mask(255, 255, 255, 255),
+	}
+	mocksNetwork.EXPECT().SetupPodNetwork(gomock.Any(), cmdArgs.IfName, cmdArgs.Netns, v4Addr, nil, devRouteTable,
+		gomock.Any(), gomock.Any()).Return(nil)
+	_, onPrem, _ := net.ParseCIDR("10.0.0.0/8")
+	_, partner, _ := net.ParseCIDR("203.0.113.0/24")
+	mocksNetwork.EXPECT().SetupPodEgressRoutes(v4Addr, []driver.EgressRoute{
+		{Dst: onPrem, RouteTable: unix.RT_TABLE_MAIN},
+		{Dst: partner, RouteTable: devRouteTable},
+	}, gomock.Any()).Return(nil)
+
+	mocksTypes.EXPECT().PrintResult(gomock.Any(), gomock.Any()).Return(nil)
+
+	err := add(cmdArgs, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork)
+	assert.Nil(t, err)
+}
+
 func TestCmdDel(t *testing.T) {
 	ctrl, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork := setup(t)
 	defer ctrl.Finish()
@@ -204,10 +441,120 @@ func TestCmdDel(t *testing.T) {
 		Mask: net.IPv4Mask(255, 255, 255, 255),
 	}
 
-	mocksNetwork.EXPECT().TeardownPodNetwork(addr, int(delNetworkReply.DeviceNumber), gomock.Any()).Return(nil)
+	mocksNetwork.EXPECT().TeardownPodNetwork(addr, devRouteTable, gomock.Any()).Return(nil)
+
+	err := del(cmdArgs, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork)
+	assert.Nil(t, err)
+}
+
+func TestCmdDelWithRouteTableBase(t *testing.T) {
+	ctrl, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork := setup(t)
+	defer ctrl.Finish()
+
+	conf := *netConf
+	conf.RouteTableBase = "20"
+	stdinData, _ := json.Marshal(conf)
+
+	cmdArgs := &skel.CmdArgs{ContainerID: containerID,
+		Netns:     netNS,
+		IfName:    ifName,
+		StdinData: stdinData}
+
+	mocksTypes.EXPECT().LoadArgs(gomock.Any(), gomock.Any()).Return(nil)
+
+	conn, _ := grpc.Dial(ipamdAddress, grpc.WithInsecure())
+
+	mocksGRPC.EXPECT().Dial(gomock.Any(), gomock.Any()).Return(conn, nil)
+	mockC := mock_rpc.NewMockCNIBackendClient(ctrl)
+	mocksRPC.EXPECT().NewCNIBackendClient(conn).Return(mockC)
+
+	delNetworkReply := &rpc.DelNetworkReply{Success: true, IPv4Addr: ipAddr, DeviceNumber: devNum}
+	mockC.EXPECT().DelNetwork(gomock.Any(), gomock.Any()).Return(delNetworkReply, nil)
+
+	addr := &net.IPNet{
+		IP:   net.ParseIP(delNetworkReply.IPv4Addr),
+		Mask: net.IPv4Mask(255, 255, 255, 255),
+	}
+	mocksNetwork.EXPECT().TeardownPodNetwork(addr, 20+devNum, gomock.Any()).Return(nil)
+
+	err := del(cmdArgs, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork)
+	assert.Nil(t, err)
+}
+
+func TestCmdDelRetriesUnavailable(t *testing.T) {
+	ctrl, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork := setup(t)
+	defer ctrl.Finish()
+	defer func(interval time.Duration) { ipamdRequestRetryInterval = interval }(ipamdRequestRetryInterval)
+	ipamdRequestRetryInterval = 0
+
+	stdinData, _ := json.Marshal(netConf)
+	cmdArgs := &skel.CmdArgs{ContainerID: containerID,
+		Netns:     netNS,
+		IfName:    ifName,
+		StdinData: stdinData}
+
+	mocksTypes.EXPECT().LoadArgs(gomock.Any(), gomock.Any()).Return(nil)
+
+	conn, _ := grpc.Dial(ipamdAddress, grpc.WithInsecure())
+
+	mocksGRPC.EXPECT().Dial(gomock.Any(), gomock.Any()).Return(conn, nil)
+	mockC := mock_rpc.NewMockCNIBackendClient(ctrl)
+	mocksRPC.EXPECT().NewCNIBackendClient(conn).Return(mockC)
+
+	// The retry carries the request ID of the first attempt
+	var requestIDs []string
+	delNetworkReply := &rpc.DelNetworkReply{Success: true, IPv4Addr: ipAddr, DeviceNumber: devNum}
+	gomock.InOrder(
+		mockC.EXPECT().DelNetwork(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, in *rpc.DelNetworkRequest, _ ...grpc.CallOption) (*rpc.DelNetworkReply, error) {
+				requestIDs = append(requestIDs, in.RequestID)
+				return nil, status.Error(codes.Unavailable, "connection reset")
+			}),
+		mockC.EXPECT().DelNetwork(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, in *rpc.DelNetworkRequest, _ ...grpc.CallOption) (*rpc.DelNetworkReply, error) {
+				requestIDs = append(requestIDs, in.RequestID)
+				return delNetworkReply, nil
+			}),
+	)
+
+	addr := &net.IPNet{
+		IP:   net.ParseIP(delNetworkReply.IPv4Addr),
+		Mask: net.IPv4Mask(255, 255, 255, 255),
+	}
+	mocksNetwork.EXPECT().TeardownPodNetwork(addr, devRouteTable, gomock.Any()).Return(nil)
 
 	err := del(cmdArgs, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork)
 	assert.Nil(t, err)
+	assert.Len(t, requestIDs, 2)
+	assert.NotEmpty(t, requestIDs[0])
+	assert.Equal(t, requestIDs[0], requestIDs[1])
+}
+
+func TestLoadNetConfInvalidRouteTableBase(t *testing.T) {
+	conf := *netConf
+	conf.RouteTableBase = "254"
+	stdinData, _ := json.Marshal(conf)
+	_, _, err := LoadNetConf(stdinData)
+	assert.Error(t, err)
+}
+
+func TestLoadNetConfVMNetwork(t *testing.T) {
+	conf := *netConf
+	conf.RuntimeConfig = RuntimeConfig{VMNetwork: &VMNetwork{Mode: "tap"}}
+	stdinData, _ := json.Marshal(conf)
+	loaded, _, err := LoadNetConf(stdinData)
+	assert.NoError(t, err)
+	assert.Equal(t, "tap0", loaded.RuntimeConfig.VMNetwork.TapName)
+
+	conf.RuntimeConfig = RuntimeConfig{VMNetwork: &VMNetwork{Mode: "macvtap"}}
+	stdinData, _ = json.Marshal(conf)
+	_, _, err = LoadNetConf(stdinData)
+	assert.Error(t, err)
+
+	conf.RuntimeConfig = RuntimeConfig{VMNetwork: &VMNetwork{Mode: "tap", TapName: "tap-with-a-long-name"}}
+	stdinData, _ = json.Marshal(conf)
+	_, _, err = LoadNetConf(stdinData)
+	assert.Error(t, err)
 }
 
 func TestCmdDelErrDelNetwork(t *testing.T) {
@@ -231,22 +578,136 @@ func TestCmdDelErrDelNetwork(t *testing.T) {
 
 	delNetworkReply := &rpc.DelNetworkReply{Success: false, IPv4Addr: ipAddr, DeviceNumber: devNum}
 
-	mockC.EXPECT().DelNetwork(gomock.Any(), gomock.Any()).Return(delNetworkReply, errors.New("error on DelNetwork"))
+	mockC.EXPECT().DelNetwork(gomock.Any(), gomock.Any()).Return(delNetworkReply, errors.New("error on DelNetwork"))
+
+	err := del(cmdArgs, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork)
+	assert.Error(t, err)
+}
+
+func TestCmdDelErrTeardown(t *testing.T) {
+	ctrl, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork := setup(t)
+	defer ctrl.Finish()
+
+	stdinData, _ := json.Marshal(netConf)
+
+	cmdArgs := &skel.CmdArgs{ContainerID: containerID,
+		Netns:     netNS,
+		IfName:    ifName,
+		StdinData: stdinData}
+
+	mocksTypes.EXPECT().LoadArgs(gomock.Any(), gomock.Any()).Return(nil)
+
+	conn, _ := grpc.Dial(ipamdAddress, grpc.WithInsecure())
+
+	mocksGRPC.EXPECT().Dial(gomock.Any(), gomock.Any()).Return(conn, nil)
+	mockC := mock_rpc.NewMockCNIBackendClient(ctrl)
+	mocksRPC.EXPECT().NewCNIBackendClient(conn).Return(mockC)
+
+	delNetworkReply := &rpc.DelNetworkReply{Success: true, IPv4Addr: ipAddr, DeviceNumber: devNum}
+
+	mockC.EXPECT().DelNetwork(gomock.Any(), gomock.Any()).Return(delNetworkReply, nil)
+
+	addr := &net.IPNet{
+		IP:   net.ParseIP(delNetworkReply.IPv4Addr),
+		Mask: net.IPv4Mask(255, 255, 255, 255),
+	}
+
+	mocksNetwork.EXPECT().TeardownPodNetwork(addr, devRouteTable, gomock.Any()).Return(errors.New("error on teardown"))
+	// ipamd predating the teardown queue
+	mockC.EXPECT().EnqueueTeardown(gomock.Any(), gomock.Any()).Return(nil, errors.New("unknown method EnqueueTeardown"))
+
+	err := del(cmdArgs, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork)
+	assert.Error(t, err)
+}
+
+func TestCmdDelEnqueuesFailedTeardown(t *testing.T) {
+	ctrl, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork := setup(t)
+	defer ctrl.Finish()
+
+	stdinData, _ := json.Marshal(netConf)
+
+	cmdArgs := &skel.CmdArgs{ContainerID: containerID,
+		Netns:     netNS,
+		IfName:    ifName,
+		StdinData: stdinData}
+
+	mocksTypes.EXPECT().LoadArgs(gomock.Any(), gomock.Any()).Return(nil)
+
+	conn, _ := grpc.Dial(ipamdAddress, grpc.WithInsecure())
+
+	mocksGRPC.EXPECT().Dial(gomock.Any(), gomock.Any()).Return(conn, nil)
+	mockC := mock_rpc.NewMockCNIBackendClient(ctrl)
+	mocksRPC.EXPECT().NewCNIBackendClient(conn).Return(mockC)
+
+	delNetworkReply := &rpc.DelNetworkReply{Success: true, IPv4Addr: ipAddr, DeviceNumber: devNum}
+	mockC.EXPECT().DelNetwork(gomock.Any(), gomock.Any()).Return(delNetworkReply, nil)
+
+	addr := &net.IPNet{
+		IP:   net.ParseIP(delNetworkReply.IPv4Addr),
+		Mask: net.IPv4Mask(255, 255, 255, 255),
+	}
+	mocksNetwork.EXPECT().TeardownPodNetwork(addr, devRouteTable, gomock.Any()).Return(errors.New("device or resource busy"))
+	mockC.EXPECT().EnqueueTeardown(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, in *rpc.EnqueueTeardownRequest, opts ...grpc.CallOption) (*rpc.EnqueueTeardownReply, error) {
+			assert.Equal(t, containerID, in.ContainerID)
+			assert.Equal(t, ipAddr, in.IPv4Addr)
+			assert.Empty(t, in.IPv6Addr)
+			assert.Equal(t, int32(devRouteTable), in.RouteTable)
+			assert.Equal(t, "device or resource busy", in.Error)
+			return &rpc.EnqueueTeardownReply{Success: true, QueueDepth: 1}, nil
+		})
+
+	err := del(cmdArgs, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork)
+	assert.NoError(t, err)
+}
+
+func TestCmdAddForPodENINetwork(t *testing.T) {
+	ctrl, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork := setup(t)
+	defer ctrl.Finish()
+
+	stdinData, _ := json.Marshal(netConf)
+
+	cmdArgs := &skel.CmdArgs{ContainerID: containerID,
+		Netns:     netNS,
+		IfName:    ifName,
+		StdinData: stdinData}
+
+	mocksTypes.EXPECT().LoadArgs(gomock.Any(), gomock.Any()).Return(nil)
+
+	conn, _ := grpc.Dial(ipamdAddress, grpc.WithInsecure())
+
+	mocksGRPC.EXPECT().Dial(gomock.Any(), gomock.Any()).Return(conn, nil)
+	mockC := mock_rpc.NewMockCNIBackendClient(ctrl)
+	mocksRPC.EXPECT().NewCNIBackendClient(conn).Return(mockC)
+
+	addNetworkReply := &rpc.AddNetworkReply{Success: true, IPv4Addr: ipAddr, PodENISubnetGW: "10.0.0.1", PodVlanId: 1,
+		PodENIMAC: "eniHardwareAddr", ParentIfIndex: 2}
+	mockC.EXPECT().AddNetwork(gomock.Any(), gomock.Any()).Return(addNetworkReply, nil)
+
+	addr := &net.IPNet{
+		IP:   net.ParseIP(addNetworkReply.IPv4Addr),
+		Mask: net.IPv4Mask(255, 255, 255, 255),
+	}
+	mocksNetwork.EXPECT().SetupBranchENIPodNetwork(gomock.Any(), cmdArgs.IfName, cmdArgs.Netns, addr, nil, 1, "eniHardwareAddr",
+		"10.0.0.1", 2, gomock.Any(), sgpp.EnforcingModeStrict, gomock.Any()).Return(nil)
+
+	mocksTypes.EXPECT().PrintResult(gomock.Any(), gomock.Any()).Return(nil)
 
-	err := del(cmdArgs, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork)
-	assert.Error(t, err)
+	err := add(cmdArgs, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork)
+	assert.Nil(t, err)
 }
 
-func TestCmdDelErrTeardown(t *testing.T) {
+func TestCmdDelForPodENINetwork(t *testing.T) {
 	ctrl, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork := setup(t)
 	defer ctrl.Finish()
 
 	stdinData, _ := json.Marshal(netConf)
 
-	cmdArgs := &skel.CmdArgs{ContainerID: containerID,
-		Netns:     netNS,
-		IfName:    ifName,
-		StdinData: stdinData}
+	cmdArgs := &skel.CmdArgs{
+		ContainerID: containerID,
+		Netns:       netNS,
+		IfName:      ifName,
+		StdinData:   stdinData}
 
 	mocksTypes.EXPECT().LoadArgs(gomock.Any(), gomock.Any()).Return(nil)
 
@@ -256,7 +717,7 @@ func TestCmdDelErrTeardown(t *testing.T) {
 	mockC := mock_rpc.NewMockCNIBackendClient(ctrl)
 	mocksRPC.EXPECT().NewCNIBackendClient(conn).Return(mockC)
 
-	delNetworkReply := &rpc.DelNetworkReply{Success: true, IPv4Addr: ipAddr, DeviceNumber: devNum}
+	delNetworkReply := &rpc.DelNetworkReply{Success: true, IPv4Addr: ipAddr, PodVlanId: 1}
 
 	mockC.EXPECT().DelNetwork(gomock.Any(), gomock.Any()).Return(delNetworkReply, nil)
 
@@ -264,14 +725,13 @@ func TestCmdDelErrTeardown(t *testing.T) {
 		IP:   net.ParseIP(delNetworkReply.IPv4Addr),
 		Mask: net.IPv4Mask(255, 255, 255, 255),
 	}
-
-	mocksNetwork.EXPECT().TeardownPodNetwork(addr, int(delNetworkReply.DeviceNumber), gomock.Any()).Return(errors.New("error on teardown"))
+	mocksNetwork.EXPECT().TeardownBranchENIPodNetwork(addr, 1, sgpp.EnforcingModeStrict, gomock.Any()).Return(nil)
 
 	err := del(cmdArgs, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork)
-	assert.Error(t, err)
+	assert.Nil(t, err)
 }
 
-func TestCmdAddForPodENINetwork(t *testing.T) {
+func TestCmdAddForPodENINetworkWithSecondaryInterfaces(t *testing.T) {
 	ctrl, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork := setup(t)
 	defer ctrl.Finish()
 
@@ -291,7 +751,10 @@ func TestCmdAddForPodENINetwork(t *testing.T) {
 	mocksRPC.EXPECT().NewCNIBackendClient(conn).Return(mockC)
 
 	addNetworkReply := &rpc.AddNetworkReply{Success: true, IPv4Addr: ipAddr, PodENISubnetGW: "10.0.0.1", PodVlanId: 1,
-		PodENIMAC: "eniHardwareAddr", ParentIfIndex: 2}
+		PodENIMAC: "eniHardwareAddr", ParentIfIndex: 2,
+		SecondaryInterfaces: []*rpc.PodSecondaryInterface{
+			{IfName: "eth1", IPv4Addr: "10.0.1.10", VlanId: 2, ENIMAC: "eniHardwareAddr2", SubnetGW: "10.0.1.1"},
+		}}
 	mockC.EXPECT().AddNetwork(gomock.Any(), gomock.Any()).Return(addNetworkReply, nil)
 
 	addr := &net.IPNet{
@@ -300,14 +763,29 @@ func TestCmdAddForPodENINetwork(t *testing.T) {
 	}
 	mocksNetwork.EXPECT().SetupBranchENIPodNetwork(gomock.Any(), cmdArgs.IfName, cmdArgs.Netns, addr, nil, 1, "eniHardwareAddr",
 		"10.0.0.1", 2, gomock.Any(), sgpp.EnforcingModeStrict, gomock.Any()).Return(nil)
-
-	mocksTypes.EXPECT().PrintResult(gomock.Any(), gomock.Any()).Return(nil)
+	secondaryAddr := &net.IPNet{
+		IP:   net.ParseIP("10.0.1.10"),
+		Mask: net.IPv4Mask(255, 255, 255, 255),
+	}
+	mocksNetwork.EXPECT().SetupBranchENIPodSecondaryNetwork(gomock.Any(), "eth1", cmdArgs.Netns, secondaryAddr, 101, 2, "eniHardwareAddr2",
+		"10.0.1.1", 2, gomock.Any(), sgpp.EnforcingModeStrict, gomock.Any()).Return(nil)
+
+	mocksTypes.EXPECT().PrintResult(gomock.Any(), gomock.Any()).DoAndReturn(func(result types.Result, version string) error {
+		r := result.(*current.Result)
+		assert.Len(t, r.IPs, 2)
+		assert.Len(t, r.Interfaces, 6)
+		secondaryIP := r.IPs[1]
+		assert.Equal(t, *secondaryAddr, secondaryIP.Address)
+		assert.Equal(t, "eth1", r.Interfaces[*secondaryIP.Interface].Name)
+		assert.Equal(t, cmdArgs.Netns, r.Interfaces[*secondaryIP.Interface].Sandbox)
+		return nil
+	})
 
 	err := add(cmdArgs, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork)
 	assert.Nil(t, err)
 }
 
-func TestCmdDelForPodENINetwork(t *testing.T) {
+func TestCmdDelForPodENINetworkWithSecondaryInterfaces(t *testing.T) {
 	ctrl, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork := setup(t)
 	defer ctrl.Finish()
 
@@ -327,7 +805,10 @@ func TestCmdDelForPodENINetwork(t *testing.T) {
 	mockC := mock_rpc.NewMockCNIBackendClient(ctrl)
 	mocksRPC.EXPECT().NewCNIBackendClient(conn).Return(mockC)
 
-	delNetworkReply := &rpc.DelNetworkReply{Success: true, IPv4Addr: ipAddr, PodVlanId: 1}
+	delNetworkReply := &rpc.DelNetworkReply{Success: true, IPv4Addr: ipAddr, PodVlanId: 1,
+		SecondaryInterfaces: []*rpc.PodSecondaryInterface{
+			{IfName: "eth1", IPv4Addr: "10.0.1.10", VlanId: 2},
+		}}
 
 	mockC.EXPECT().DelNetwork(gomock.Any(), gomock.Any()).Return(delNetworkReply, nil)
 
@@ -335,7 +816,12 @@ func TestCmdDelForPodENINetwork(t *testing.T) {
 		IP:   net.ParseIP(delNetworkReply.IPv4Addr),
 		Mask: net.IPv4Mask(255, 255, 255, 255),
 	}
+	secondaryAddr := &net.IPNet{
+		IP:   net.ParseIP("10.0.1.10"),
+		Mask: net.IPv4Mask(255, 255, 255, 255),
+	}
 	mocksNetwork.EXPECT().TeardownBranchENIPodNetwork(addr, 1, sgpp.EnforcingModeStrict, gomock.Any()).Return(nil)
+	mocksNetwork.EXPECT().TeardownBranchENIPodNetwork(secondaryAddr, 2, sgpp.EnforcingModeStrict, gomock.Any()).Return(nil)
 
 	err := del(cmdArgs, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork)
 	assert.Nil(t, err)
@@ -743,3 +1229,523 @@ func Test_tryDelWithPrevResult(t *testing.T) {
 		})
 	}
 }
+
+type stubCNIBackendServer struct {
+	rpc.UnimplementedCNIBackendServer
+}
+
+func (*stubCNIBackendServer) AddNetwork(context.Context, *rpc.AddNetworkRequest) (*rpc.AddNetworkReply, error) {
+	return &rpc.AddNetworkReply{Success: true, IPv4Addr: ipAddr, DeviceNumber: devNum}, nil
+}
+
+// startStubIPAMD serves a stub CNI backend on a TCP port and on a unix socket, the way ipamd does
+func startStubIPAMD(tb testing.TB) (socketPath, address string) {
+	socketPath = filepath.Join(tb.TempDir(), "ipamd.sock")
+	socketListener, err := net.Listen("unix", socketPath)
+	assert.NoError(tb, err)
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(tb, err)
+
+	grpcServer := grpc.NewServer()
+	rpc.RegisterCNIBackendServer(grpcServer, &stubCNIBackendServer{})
+	go grpcServer.Serve(socketListener)
+	go grpcServer.Serve(tcpListener)
+	tb.Cleanup(grpcServer.Stop)
+	return socketPath, tcpListener.Addr().String()
+}
+
+// staleSocket returns the path of a unix socket that nothing listens on anymore, as left by a crashed ipamd
+func staleSocket(t *testing.T) string {
+	socketPath := filepath.Join(t.TempDir(), "stale.sock")
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: socketPath, Net: "unix"})
+	assert.NoError(t, err)
+	listener.SetUnlinkOnClose(false)
+	assert.NoError(t, listener.Close())
+	assert.FileExists(t, socketPath)
+	return socketPath
+}
+
+func TestDialIPAMD(t *testing.T) {
+	socketPath, address := startStubIPAMD(t)
+
+	for _, tc := range []struct {
+		name       string
+		socketPath string
+		address    string
+	}{
+		{"unix socket", socketPath, "127.0.0.1:1"},
+		{"TCP fallback when the socket is missing", filepath.Join(t.TempDir(), "missing.sock"), address},
+		{"TCP fallback when the socket is stale", staleSocket(t), address},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			conn, err := dialIPAMD(grpcwrapper.New(), tc.socketPath, tc.address)
+			assert.NoError(t, err)
+			defer conn.Close()
+
+			r, err := rpc.NewCNIBackendClient(conn).AddNetwork(context.Background(), &rpc.AddNetworkRequest{})
+			assert.NoError(t, err)
+			assert.Equal(t, ipAddr, r.IPv4Addr)
+		})
+	}
+}
+
+// BenchmarkDialIPAMD measures the per-ADD cost of connecting to ipamd and issuing the AddNetwork call, over the unix
+// socket and over TCP. Run with: go test ./cmd/routed-eni-cni-plugin/ -run '^$' -bench DialIPAMD -benchmem
+func BenchmarkDialIPAMD(b *testing.B) {
+	socketPath, address := startStubIPAMD(b)
+
+	for _, bc := range []struct {
+		name       string
+		socketPath string
+	}{
+		{"unix", socketPath},
+		{"tcp", filepath.Join(b.TempDir(), "missing.sock")},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				conn, err := dialIPAMD(grpcwrapper.New(), bc.socketPath, address)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := rpc.NewCNIBackendClient(conn).AddNetwork(context.Background(), &rpc.AddNetworkRequest{}); err != nil {
+					b.Fatal(err)
+				}
+				conn.Close()
+			}
+		})
+	}
+}
+
+// stdinDataWithPrevResult returns the netConf of a plugin chained after a plugin that returned prevResult
+func stdinDataWithPrevResult(t *testing.T, prevResult *current.Result) []byte {
+	conf := *netConf
+	conf.CNIVersion = "0.4.0"
+	conf.VethPrefix = "eni"
+	if prevResult != nil {
+		raw, err := json.Marshal(prevResult)
+		assert.NoError(t, err)
+		assert.NoError(t, json.Unmarshal(raw, &conf.RawPrevResult))
+	}
+	stdinData, err := json.Marshal(conf)
+	assert.NoError(t, err)
+	return stdinData
+}
+
+// loadSamplePodArgs fills in the K8sArgs of default/sample-pod
+func loadSamplePodArgs(args string, container interface{}) error {
+	k8sArgs := container.(*K8sArgs)
+	k8sArgs.K8S_POD_NAMESPACE = "default"
+	k8sArgs.K8S_POD_NAME = "sample-pod"
+	return nil
+}
+
+func TestCmdAddForDedicatedENI(t *testing.T) {
+	ctrl, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork := setup(t)
+	defer ctrl.Finish()
+
+	stdinData, _ := json.Marshal(netConf)
+
+	cmdArgs := &skel.CmdArgs{ContainerID: containerID,
+		Netns:     netNS,
+		IfName:    ifName,
+		StdinData: stdinData}
+
+	mocksTypes.EXPECT().LoadArgs(gomock.Any(), gomock.Any()).Return(nil)
+
+	conn, _ := grpc.Dial(ipamdAddress, grpc.WithInsecure())
+
+	mocksGRPC.EXPECT().Dial(gomock.Any(), gomock.Any()).Return(conn, nil)
+	mockC := mock_rpc.NewMockCNIBackendClient(ctrl)
+	mocksRPC.EXPECT().NewCNIBackendClient(conn).Return(mockC)
+
+	addNetworkReply := &rpc.AddNetworkReply{Success: true, IPv4Addr: ipAddr, DeviceNumber: -1, DedicatedENI: true,
+		PodENIMAC: "0e:00:00:00:00:01", PodENISubnetGW: "10.0.0.1", PodENISubnetPrefixLength: 24}
+	mockC.EXPECT().AddNetwork(gomock.Any(), gomock.Any()).Return(addNetworkReply, nil)
+
+	addr := &net.IPNet{
+		IP:   net.ParseIP(addNetworkReply.IPv4Addr),
+		Mask: net.CIDRMask(24, 32),
+	}
+	mocksNetwork.EXPECT().SetupDedicatedENIPodNetwork(cmdArgs.IfName, cmdArgs.Netns, addr, "0e:00:00:00:00:01", "10.0.0.1",
+		gomock.Any(), gomock.Any()).Return(nil)
+
+	mocksTypes.EXPECT().PrintResult(gomock.Any(), gomock.Any()).DoAndReturn(func(result types.Result, version string) error {
+		r := result.(*current.Result)
+		assert.Equal(t, []*current.Interface{{Name: ifName, Mac: "0e:00:00:00:00:01", Sandbox: netNS}}, r.Interfaces)
+		assert.Equal(t, 0, *r.IPs[0].Interface)
+		assert.Equal(t, addr.String(), r.IPs[0].Address.String())
+		assert.Equal(t, "10.0.0.1", r.IPs[0].Gateway.String())
+		assert.Equal(t, "10.0.0.1", r.Routes[0].GW.String())
+		return nil
+	})
+
+	err := add(cmdArgs, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork)
+	assert.Nil(t, err)
+}
+
+func TestCmdDelForDedicatedENI(t *testing.T) {
+	ctrl, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork := setup(t)
+	defer ctrl.Finish()
+
+	stdinData, _ := json.Marshal(netConf)
+
+	cmdArgs := &skel.CmdArgs{
+		ContainerID: containerID,
+		Netns:       netNS,
+		IfName:      ifName,
+		StdinData:   stdinData}
+
+	mocksTypes.EXPECT().LoadArgs(gomock.Any(), gomock.Any()).Return(nil)
+
+	conn, _ := grpc.Dial(ipamdAddress, grpc.WithInsecure())
+
+	mocksGRPC.EXPECT().Dial(gomock.Any(), gomock.Any()).Return(conn, nil)
+	mockC := mock_rpc.NewMockCNIBackendClient(ctrl)
+	mocksRPC.EXPECT().NewCNIBackendClient(conn).Return(mockC)
+
+	delNetworkReply := &rpc.DelNetworkReply{Success: true, IPv4Addr: ipAddr, DedicatedENI: true, PodENIMAC: "0e:00:00:00:00:01"}
+	mockC.EXPECT().DelNetwork(gomock.Any(), gomock.Any()).Return(delNetworkReply, nil)
+	mocksNetwork.EXPECT().TeardownDedicatedENIPodNetwork(cmdArgs.Netns, "0e:00:00:00:00:01", gomock.Any()).Return(nil)
+
+	err := del(cmdArgs, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork)
+	assert.Nil(t, err)
+}
+
+func TestCmdAddResult(t *testing.T) {
+	ctrl, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork := setup(t)
+	defer ctrl.Finish()
+
+	conf := *netConf
+	conf.DNS = types.DNS{Nameservers: []string{"10.100.0.10"}}
+	stdinData, _ := json.Marshal(conf)
+
+	cmdArgs := &skel.CmdArgs{ContainerID: containerID,
+		Netns:     netNS,
+		IfName:    ifName,
+		StdinData: stdinData}
+
+	mocksTypes.EXPECT().LoadArgs(gomock.Any(), gomock.Any()).Return(nil)
+
+	conn, _ := grpc.Dial(ipamdAddress, grpc.WithInsecure())
+
+	mocksGRPC.EXPECT().Dial(gomock.Any(), gomock.Any()).Return(conn, nil)
+	mockC := mock_rpc.NewMockCNIBackendClient(ctrl)
+	mocksRPC.EXPECT().NewCNIBackendClient(conn).Return(mockC)
+
+	addNetworkReply := &rpc.AddNetworkReply{Success: true, IPv4Addr: ipAddr, DeviceNumber: devNum}
+	mockC.EXPECT().AddNetwork(gomock.Any(), gomock.Any()).Return(addNetworkReply, nil)
+	mocksNetwork.EXPECT().SetupPodNetwork(gomock.Any(), cmdArgs.IfName, cmdArgs.Netns,
+		gomock.Any(), nil, devRouteTable, gomock.Any(), gomock.Any()).Return(nil)
+
+	mocksTypes.EXPECT().PrintResult(gomock.Any(), gomock.Any()).DoAndReturn(func(result types.Result, version string) error {
+		r := result.(*current.Result)
+		assert.Equal(t, "169.254.1.1", r.IPs[0].Gateway.String())
+		assert.Len(t, r.Routes, 1)
+		assert.Equal(t, "0.0.0.0/0", r.Routes[0].Dst.String())
+		assert.Equal(t, "169.254.1.1", r.Routes[0].GW.String())
+		assert.Equal(t, []string{"10.100.0.10"}, r.DNS.Nameservers)
+
+		// The result survives the conversion to the spec version of the chain
+		converted, err := r.GetAsVersion("0.3.1")
+		assert.NoError(t, err)
+		assert.Len(t, converted.(*current.Result).Routes, 1)
+		return nil
+	})
+
+	err := add(cmdArgs, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork)
+	assert.Nil(t, err)
+}
+
+func TestCmdAddResultSpecVersion100(t *testing.T) {
+	ctrl, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork := setup(t)
+	defer ctrl.Finish()
+
+	conf := *netConf
+	conf.CNIVersion = cniutils.SpecVersion100
+	stdinData, _ := json.Marshal(conf)
+
+	cmdArgs := &skel.CmdArgs{ContainerID: containerID,
+		Netns:     netNS,
+		IfName:    ifName,
+		StdinData: stdinData}
+
+	mocksTypes.EXPECT().LoadArgs(gomock.Any(), gomock.Any()).Return(nil)
+
+	conn, _ := grpc.Dial(ipamdAddress, grpc.WithInsecure())
+	mocksGRPC.EXPECT().Dial(gomock.Any(), gomock.Any()).Return(conn, nil)
+	mockC := mock_rpc.NewMockCNIBackendClient(ctrl)
+	mocksRPC.EXPECT().NewCNIBackendClient(conn).Return(mockC)
+
+	addNetworkReply := &rpc.AddNetworkReply{Success: true, IPv4Addr: ipAddr, DeviceNumber: devNum}
+	mockC.EXPECT().AddNetwork(gomock.Any(), gomock.Any()).Return(addNetworkReply, nil)
+	mocksNetwork.EXPECT().SetupPodNetwork(gomock.Any(), cmdArgs.IfName, cmdArgs.Netns,
+		gomock.Any(), nil, devRouteTable, gomock.Any(), gomock.Any()).Return(nil)
+
+	mocksTypes.EXPECT().PrintResult(gomock.Any(), cniutils.SpecVersion100).DoAndReturn(func(result types.Result, version string) error {
+		converted, err := result.GetAsVersion(version)
+		assert.NoError(t, err)
+		r := converted.(*cniutils.Result100)
+		assert.Equal(t, cniutils.SpecVersion100, r.CNIVersion)
+		assert.Equal(t, "169.254.1.1", r.IPs[0].Gateway.String())
+		assert.Len(t, r.Routes, 1)
+		return nil
+	})
+
+	err := add(cmdArgs, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork)
+	assert.Nil(t, err)
+}
+
+func TestCmdCheckSpecVersion100(t *testing.T) {
+	ctrl, mocksTypes, _, _, mocksNetwork := setup(t)
+	defer ctrl.Finish()
+
+	// A 1.0.0 prevResult, whose IP configurations have no version
+	stdinData := []byte(`{
+		"cniVersion": "1.0.0",
+		"name": "aws-cni",
+		"type": "aws-cni",
+		"vethPrefix": "eni",
+		"prevResult": {
+			"cniVersion": "1.0.0",
+			"interfaces": [{"name": "enicc21c2d7785"}, {"name": "eth0", "sandbox": "` + netNS + `"}],
+			"ips": [{"interface": 1, "address": "192.168.1.1/32", "gateway": "169.254.1.1"}]
+		}
+	}`)
+	cmdArgs := &skel.CmdArgs{ContainerID: containerID,
+		Netns:     netNS,
+		IfName:    ifName,
+		StdinData: stdinData}
+
+	containerAddr := net.IPNet{IP: net.ParseIP("192.168.1.1"), Mask: net.CIDRMask(32, 32)}
+	mocksTypes.EXPECT().LoadArgs(gomock.Any(), gomock.Any()).DoAndReturn(loadSamplePodArgs)
+	mocksNetwork.EXPECT().CheckPodNetwork("enicc21c2d7785", ifName, netNS, &containerAddr, gomock.Any()).Return(nil)
+
+	assert.NoError(t, check(cmdArgs, mocksTypes, mocksNetwork))
+}
+
+func TestPodDNS(t *testing.T) {
+	vpcDNS := &rpc.AddNetworkReply{DNSNameservers: []string{"10.0.0.2"}, DNSDomain: "ec2.internal", DNSSearch: []string{"ec2.internal"}}
+	confDNS := types.DNS{Nameservers: []string{"10.100.0.10"}}
+
+	// The network configuration takes precedence
+	assert.Equal(t, confDNS, podDNS(confDNS, vpcDNS))
+	assert.Equal(t, types.DNS{}, podDNS(types.DNS{}, &rpc.AddNetworkReply{}))
+	assert.Equal(t, types.DNS{Nameservers: []string{"10.0.0.2"}, Domain: "ec2.internal", Search: []string{"ec2.internal"},
+		Options: []string{"ndots:2"}}, podDNS(types.DNS{Options: []string{"ndots:2"}}, vpcDNS))
+}
+
+func TestPodRouteHints(t *testing.T) {
+	testLogCfg := logger.Configuration{
+		LogLevel:    "Debug",
+		LogLocation: "stdout",
+	}
+	testLogger := logger.New(&testLogCfg)
+
+	gw, routes, ok := podRouteHints(&rpc.AddNetworkReply{PodGateway: "169.254.1.1", PodRoutes: []*rpc.RouteHint{
+		{Dst: "169.254.1.1/32"}, {Dst: "0.0.0.0/0", GW: "169.254.1.1"}}}, testLogger)
+	assert.True(t, ok)
+	assert.Equal(t, "169.254.1.1", gw.String())
+	assert.Equal(t, []*types.Route{
+		{Dst: net.IPNet{IP: net.ParseIP("169.254.1.1").To4(), Mask: net.CIDRMask(32, 32)}},
+		{Dst: net.IPNet{IP: net.IPv4zero.To4(), Mask: net.CIDRMask(0, 32)}, GW: net.ParseIP("169.254.1.1")},
+	}, routes)
+
+	// An older ipamd sends no hints, and invalid ones are ignored
+	_, _, ok = podRouteHints(&rpc.AddNetworkReply{}, testLogger)
+	assert.False(t, ok)
+	_, _, ok = podRouteHints(&rpc.AddNetworkReply{PodGateway: "169.254.1.1", PodRoutes: []*rpc.RouteHint{{Dst: "default"}}},
+		testLogger)
+	assert.False(t, ok)
+	_, _, ok = podRouteHints(&rpc.AddNetworkReply{PodGateway: "gw", PodRoutes: []*rpc.RouteHint{{Dst: "0.0.0.0/0"}}},
+		testLogger)
+	assert.False(t, ok)
+}
+
+func TestCmdCheck(t *testing.T) {
+	containerAddr := net.IPNet{IP: net.ParseIP("192.168.1.1"), Mask: net.CIDRMask(32, 32)}
+	prevResult := &current.Result{
+		CNIVersion: "0.4.0",
+		Interfaces: []*current.Interface{
+			{Name: "enicc21c2d7785"},
+			{Name: "eth0", Sandbox: netNS},
+		},
+		IPs: []*current.IPConfig{
+			{Version: "4", Address: containerAddr, Interface: aws.Int(1)},
+		},
+	}
+	branchENIPrevResult := &current.Result{
+		CNIVersion: "0.4.0",
+		Interfaces: []*current.Interface{
+			{Name: "vlancc21c2d7785"},
+			{Name: "eth0", Sandbox: netNS},
+			{Name: "dummycc21c2d7785", Mac: "7"},
+		},
+		IPs: []*current.IPConfig{
+			{Version: "4", Address: containerAddr, Interface: aws.Int(1)},
+		},
+	}
+
+	dedicatedENIAddr := net.IPNet{IP: net.ParseIP("192.168.1.1"), Mask: net.CIDRMask(24, 32)}
+	dedicatedENIPrevResult := &current.Result{
+		CNIVersion: "0.4.0",
+		Interfaces: []*current.Interface{
+			{Name: "eth0", Mac: "0e:00:00:00:00:01", Sandbox: netNS},
+		},
+		IPs: []*current.IPConfig{
+			{Version: "4", Address: dedicatedENIAddr, Interface: aws.Int(0)},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		prevResult   *current.Result
+		hostVethName string
+		dedicatedENI bool
+		checkErr     error
+		wantErr      bool
+	}{
+		{
+			name:         "pod network is set up",
+			prevResult:   prevResult,
+			hostVethName: "enicc21c2d7785",
+		},
+		{
+			name:         "branch ENI pod network is set up",
+			prevResult:   branchENIPrevResult,
+			hostVethName: "vlancc21c2d7785",
+		},
+		{
+			name:         "dedicated ENI pod network is set up",
+			prevResult:   dedicatedENIPrevResult,
+			dedicatedENI: true,
+		},
+		{
+			name:         "pod network is broken",
+			prevResult:   prevResult,
+			hostVethName: "enicc21c2d7785",
+			checkErr:     errors.New("eth0 does not have a default route"),
+			wantErr:      true,
+		},
+		{
+			name:    "prevResult missing",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl, mocksTypes, _, _, mocksNetwork := setup(t)
+			defer ctrl.Finish()
+
+			cmdArgs := &skel.CmdArgs{ContainerID: containerID,
+				Netns:     netNS,
+				IfName:    ifName,
+				StdinData: stdinDataWithPrevResult(t, tt.prevResult)}
+
+			if tt.prevResult != nil {
+				mocksTypes.EXPECT().LoadArgs(gomock.Any(), gomock.Any()).DoAndReturn(loadSamplePodArgs)
+			}
+			if tt.hostVethName != "" {
+				mocksNetwork.EXPECT().CheckPodNetwork(tt.hostVethName, ifName, netNS, &containerAddr, gomock.Any()).Return(tt.checkErr)
+			}
+			if tt.dedicatedENI {
+				mocksNetwork.EXPECT().CheckDedicatedENIPodNetwork(ifName, netNS, &dedicatedENIAddr, gomock.Any()).Return(tt.checkErr)
+			}
+
+			err := check(cmdArgs, mocksTypes, mocksNetwork)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCmdDelUnknownPodWithPrevResult(t *testing.T) {
+	ctrl, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork := setup(t)
+	defer ctrl.Finish()
+
+	containerAddr := net.IPNet{IP: net.ParseIP("192.168.1.1"), Mask: net.CIDRMask(32, 32)}
+	cmdArgs := &skel.CmdArgs{ContainerID: containerID,
+		Netns:  netNS,
+		IfName: ifName,
+		StdinData: stdinDataWithPrevResult(t, &current.Result{
+			CNIVersion: "0.4.0",
+			Interfaces: []*current.Interface{
+				{Name: "enicc21c2d7785"},
+				{Name: "eth0", Sandbox: netNS},
+			},
+			IPs: []*current.IPConfig{
+				{Version: "4", Address: containerAddr, Interface: aws.Int(1)},
+			},
+		})}
+
+	mocksTypes.EXPECT().LoadArgs(gomock.Any(), gomock.Any()).DoAndReturn(loadSamplePodArgs)
+
+	conn, _ := grpc.Dial(ipamdAddress, grpc.WithInsecure())
+	mocksGRPC.EXPECT().Dial(gomock.Any(), gomock.Any()).Return(conn, nil)
+	mockC := mock_rpc.NewMockCNIBackendClient(ctrl)
+	mocksRPC.EXPECT().NewCNIBackendClient(conn).Return(mockC)
+	mockC.EXPECT().DelNetwork(gomock.Any(), gomock.Any()).Return(nil, errors.New("rpc error: datastore: unknown pod"))
+
+	// ipamd lost the pod, so its host routes are removed using prevResult, only through its host veth
+	mocksNetwork.EXPECT().TeardownStalePodNetwork(&containerAddr, "enicc21c2d7785", gomock.Any()).Return(nil)
+
+	err := del(cmdArgs, mocksTypes, mocksGRPC, mocksRPC, mocksNetwork)
+	assert.NoError(t, err)
+}
+
+func TestCmdGC(t *testing.T) {
+	ctrl, _, mocksGRPC, mocksRPC, mocksNetwork := setup(t)
+	defer ctrl.Finish()
+
+	stdinData := []byte(`{
+		"cniVersion": "1.1.0",
+		"name": "aws-cni",
+		"type": "aws-cni",
+		"cni.dev/valid-attachments": [{"containerID": "running", "ifname": "eth0"}]
+	}`)
+
+	conn, _ := grpc.Dial(ipamdAddress, grpc.WithInsecure())
+	mocksGRPC.EXPECT().Dial(gomock.Any(), gomock.Any()).Return(conn, nil)
+	mockC := mock_rpc.NewMockCNIBackendClient(ctrl)
+	mocksRPC.EXPECT().NewCNIBackendClient(conn).Return(mockC)
+
+	mockC.EXPECT().GarbageCollect(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, in *rpc.GarbageCollectRequest, opts ...grpc.CallOption) (*rpc.GarbageCollectReply, error) {
+			assert.Equal(t, "aws-cni", in.NetworkName)
+			assert.Len(t, in.ValidAttachments, 1)
+			assert.Equal(t, "running", in.ValidAttachments[0].ContainerID)
+			assert.Equal(t, "eth0", in.ValidAttachments[0].IfName)
+			return &rpc.GarbageCollectReply{
+				Success: true,
+				ReleasedIPs: []*rpc.ReleasedIP{
+					{IPv4Addr: "10.0.1.16", DeviceNumber: 1},
+					{IPv4Addr: "10.0.1.17", DeviceNumber: 2},
+				},
+			}, nil
+		})
+
+	// A failed teardown doesn't stop the others
+	mocksNetwork.EXPECT().TeardownPodNetwork(&net.IPNet{IP: net.ParseIP("10.0.1.16"), Mask: net.CIDRMask(32, 32)}, 2, gomock.Any()).
+		Return(errors.New("error on teardown"))
+	mockC.EXPECT().EnqueueTeardown(gomock.Any(), gomock.Any()).Return(&rpc.EnqueueTeardownReply{Success: false}, nil)
+	mocksNetwork.EXPECT().TeardownPodNetwork(&net.IPNet{IP: net.ParseIP("10.0.1.17"), Mask: net.CIDRMask(32, 32)}, 3, gomock.Any()).
+		Return(nil)
+
+	err := gc(stdinData, mocksGRPC, mocksRPC, mocksNetwork)
+	assert.Error(t, err)
+}
+
+func TestRequestTraceID(t *testing.T) {
+	conf := &NetConf{RuntimeConfig: RuntimeConfig{TraceID: "req-0123.abcd"}}
+	assert.Equal(t, "req-0123.abcd", requestTraceID(conf))
+
+	// IDs that would break the log lines or the EC2 user agent are replaced with a random one
+	conf.RuntimeConfig.TraceID = "bad id/with spaces"
+	traceID := requestTraceID(conf)
+	assert.NotEqual(t, "bad id/with spaces", traceID)
+	assert.Len(t, traceID, 16)
+
+	assert.Len(t, requestTraceID(nil), 16)
+}
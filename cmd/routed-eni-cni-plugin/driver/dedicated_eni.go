@@ -0,0 +1,150 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package driver
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
+)
+
+// dedicatedENILinkTimeout is how long to wait for a newly attached ENI to show up as a link on the host
+var dedicatedENILinkTimeout = 10 * time.Second
+
+// SetupDedicatedENIPodNetwork moves the link of a dedicated ENI from the host into the pod network namespace, renames
+// it to contIfName, and configures the ENI address and a default route through the subnet gateway
+func (n *linuxNetwork) SetupDedicatedENIPodNetwork(contIfName string, netnsPath string, v4Addr *net.IPNet, eniMAC string,
+	subnetGW string, mtu int, log logger.Logger) error {
+	log.Debugf("SetupDedicatedENIPodNetwork: contIfName=%s, netnsPath=%s, v4Addr=%v, eniMAC=%s, subnetGW=%s, mtu=%d",
+		contIfName, netnsPath, v4Addr, eniMAC, subnetGW, mtu)
+
+	gw := net.ParseIP(subnetGW)
+	if gw == nil {
+		return errors.Errorf("SetupDedicatedENIPodNetwork: invalid subnet gateway %q", subnetGW)
+	}
+	err := n.ns.WithNetNSPath(netnsPath, func(hostNS ns.NetNS) error {
+		err := hostNS.Do(func(podNS ns.NetNS) error {
+			link, err := n.waitForLinkByMAC(eniMAC, dedicatedENILinkTimeout)
+			if err != nil {
+				return err
+			}
+			if err := n.netLink.LinkSetDown(link); err != nil {
+				return errors.Wrapf(err, "failed to set link %s down", link.Attrs().Name)
+			}
+			return errors.Wrap(n.netLink.LinkSetNsFd(link, int(podNS.Fd())), "failed to move the ENI into the pod")
+		})
+		if err != nil {
+			return err
+		}
+
+		link, err := n.linkByMAC(eniMAC)
+		if err != nil {
+			return err
+		}
+		if err := n.netLink.LinkSetName(link, contIfName); err != nil {
+			return errors.Wrapf(err, "failed to rename the ENI to %s", contIfName)
+		}
+		if err := n.netLink.LinkSetMTU(link, mtu); err != nil {
+			return errors.Wrapf(err, "failed to set the MTU of %s", contIfName)
+		}
+		if err := n.netLink.AddrAdd(link, &netlink.Addr{IPNet: v4Addr}); err != nil {
+			return errors.Wrapf(err, "failed to add IP addr to %s", contIfName)
+		}
+		if err := n.netLink.LinkSetUp(link); err != nil {
+			return errors.Wrapf(err, "failed to set %s up", contIfName)
+		}
+		err = n.netLink.RouteReplace(&netlink.Route{
+			LinkIndex: link.Attrs().Index,
+			Scope:     netlink.SCOPE_UNIVERSE,
+			Dst:       &net.IPNet{IP: net.IPv4zero, Mask: net.CIDRMask(0, 32)},
+			Gw:        gw,
+		})
+		return errors.Wrap(err, "failed to add default route")
+	})
+	return errors.Wrap(err, "SetupDedicatedENIPodNetwork")
+}
+
+// TeardownDedicatedENIPodNetwork moves the link of a dedicated ENI out of the pod network namespace, under a name that
+// can't conflict with the host interfaces. Nothing is left to do if the namespace or the link are already gone.
+func (n *linuxNetwork) TeardownDedicatedENIPodNetwork(netnsPath string, eniMAC string, log logger.Logger) error {
+	log.Debugf("TeardownDedicatedENIPodNetwork: netnsPath=%s, eniMAC=%s", netnsPath, eniMAC)
+
+	err := n.ns.WithNetNSPath(netnsPath, func(hostNS ns.NetNS) error {
+		link, err := n.linkByMAC(eniMAC)
+		if err != nil {
+			log.Infof("Dedicated ENI %s is not in the pod network namespace anymore", eniMAC)
+			return nil
+		}
+		if err := n.netLink.LinkSetDown(link); err != nil {
+			return errors.Wrapf(err, "failed to set link %s down", link.Attrs().Name)
+		}
+		if err := n.netLink.LinkSetName(link, dedicatedENIHostLinkName(eniMAC)); err != nil {
+			return errors.Wrapf(err, "failed to rename link %s", link.Attrs().Name)
+		}
+		return errors.Wrap(n.netLink.LinkSetNsFd(link, int(hostNS.Fd())), "failed to move the ENI to the host")
+	})
+	if _, ok := err.(ns.NSPathNotExistErr); ok {
+		log.Infof("Pod network namespace %s is gone, the kernel moved the dedicated ENI back to the host", netnsPath)
+		return nil
+	}
+	return errors.Wrap(err, "TeardownDedicatedENIPodNetwork")
+}
+
+// CheckDedicatedENIPodNetwork verifies that the dedicated ENI of a pod still has the pod address and a default route
+func (n *linuxNetwork) CheckDedicatedENIPodNetwork(contIfName string, netnsPath string, containerAddr *net.IPNet, log logger.Logger) error {
+	log.Debugf("CheckDedicatedENIPodNetwork: contIfName=%s, netnsPath=%s, containerAddr=%v", contIfName, netnsPath, containerAddr)
+
+	err := n.ns.WithNetNSPath(netnsPath, func(ns.NetNS) error {
+		return n.checkContainerVeth(contIfName, containerAddr)
+	})
+	return errors.Wrap(err, "CheckDedicatedENIPodNetwork")
+}
+
+// linkByMAC returns the link with the MAC address in the current network namespace
+func (n *linuxNetwork) linkByMAC(mac string) (netlink.Link, error) {
+	links, err := n.netLink.LinkList()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list links")
+	}
+	for _, link := range links {
+		if strings.EqualFold(link.Attrs().HardwareAddr.String(), mac) {
+			return link, nil
+		}
+	}
+	return nil, errors.Errorf("no link with MAC address %s", mac)
+}
+
+// waitForLinkByMAC waits for a link with the MAC address to show up in the current network namespace
+func (n *linuxNetwork) waitForLinkByMAC(mac string, timeout time.Duration) (netlink.Link, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		link, err := n.linkByMAC(mac)
+		if err == nil || time.Now().After(deadline) {
+			return link, err
+		}
+		time.Sleep(WAIT_INTERVAL)
+	}
+}
+
+// dedicatedENIHostLinkName returns the name of a dedicated ENI moved back to the host: "eni" followed by its MAC
+// address without colons, which fits the 15 characters of an interface name
+func dedicatedENIHostLinkName(mac string) string {
+	return "eni" + strings.ReplaceAll(strings.ToLower(mac), ":", "")
+}
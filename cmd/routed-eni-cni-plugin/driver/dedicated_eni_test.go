@@ -0,0 +1,105 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package driver
+
+import (
+	"net"
+	"testing"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/cninswrapper/mock_ns"
+	mock_netlinkwrapper "github.com/aws/amazon-vpc-cni-k8s/pkg/netlinkwrapper/mocks"
+	mock_nswrapper "github.com/aws/amazon-vpc-cni-k8s/pkg/nswrapper/mocks"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
+)
+
+func TestSetupDedicatedENIPodNetwork(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mac, _ := net.ParseMAC("0e:00:00:00:00:01")
+	eni := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth2", Index: 7, HardwareAddr: mac}}
+	lo := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "lo", Index: 1}}
+	v4Addr := &net.IPNet{IP: net.ParseIP("10.0.0.42"), Mask: net.CIDRMask(24, 32)}
+
+	netLink := mock_netlinkwrapper.NewMockNetLink(ctrl)
+	nsWrapper := mock_nswrapper.NewMockNS(ctrl)
+	hostNS := mock_ns.NewMockNetNS(ctrl)
+	podNS := mock_ns.NewMockNetNS(ctrl)
+	nsWrapper.EXPECT().WithNetNSPath("/proc/42/ns/net", gomock.Any()).DoAndReturn(
+		func(nspath string, toRun func(ns.NetNS) error) error {
+			return toRun(hostNS)
+		})
+	hostNS.EXPECT().Do(gomock.Any()).DoAndReturn(func(toRun func(ns.NetNS) error) error {
+		return toRun(podNS)
+	})
+	podNS.EXPECT().Fd().Return(uintptr(42))
+
+	gomock.InOrder(
+		// In the host
+		netLink.EXPECT().LinkList().Return([]netlink.Link{lo, eni}, nil),
+		netLink.EXPECT().LinkSetDown(eni).Return(nil),
+		netLink.EXPECT().LinkSetNsFd(eni, 42).Return(nil),
+		// In the pod
+		netLink.EXPECT().LinkList().Return([]netlink.Link{lo, eni}, nil),
+		netLink.EXPECT().LinkSetName(eni, "eth0").Return(nil),
+		netLink.EXPECT().LinkSetMTU(eni, 9001).Return(nil),
+		netLink.EXPECT().AddrAdd(eni, &netlink.Addr{IPNet: v4Addr}).Return(nil),
+		netLink.EXPECT().LinkSetUp(eni).Return(nil),
+		netLink.EXPECT().RouteReplace(&netlink.Route{
+			LinkIndex: 7,
+			Scope:     netlink.SCOPE_UNIVERSE,
+			Dst:       &net.IPNet{IP: net.IPv4zero, Mask: net.CIDRMask(0, 32)},
+			Gw:        net.ParseIP("10.0.0.1"),
+		}).Return(nil),
+	)
+
+	n := &linuxNetwork{netLink: netLink, ns: nsWrapper}
+	err := n.SetupDedicatedENIPodNetwork("eth0", "/proc/42/ns/net", v4Addr, "0E:00:00:00:00:01", "10.0.0.1", 9001, logger.Get())
+	assert.NoError(t, err)
+}
+
+func TestTeardownDedicatedENIPodNetwork(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mac, _ := net.ParseMAC("0e:00:00:00:00:01")
+	eni := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth0", Index: 7, HardwareAddr: mac}}
+
+	netLink := mock_netlinkwrapper.NewMockNetLink(ctrl)
+	nsWrapper := mock_nswrapper.NewMockNS(ctrl)
+	hostNS := mock_ns.NewMockNetNS(ctrl)
+	nsWrapper.EXPECT().WithNetNSPath("/proc/42/ns/net", gomock.Any()).DoAndReturn(
+		func(nspath string, toRun func(ns.NetNS) error) error {
+			return toRun(hostNS)
+		}).Times(2)
+	hostNS.EXPECT().Fd().Return(uintptr(3))
+
+	gomock.InOrder(
+		netLink.EXPECT().LinkList().Return([]netlink.Link{eni}, nil),
+		netLink.EXPECT().LinkSetDown(eni).Return(nil),
+		netLink.EXPECT().LinkSetName(eni, "eni0e0000000001").Return(nil),
+		netLink.EXPECT().LinkSetNsFd(eni, 3).Return(nil),
+		// The ENI is already gone the second time
+		netLink.EXPECT().LinkList().Return(nil, nil),
+	)
+
+	n := &linuxNetwork{netLink: netLink, ns: nsWrapper}
+	assert.NoError(t, n.TeardownDedicatedENIPodNetwork("/proc/42/ns/net", "0e:00:00:00:00:01", logger.Get()))
+	assert.NoError(t, n.TeardownDedicatedENIPodNetwork("/proc/42/ns/net", "0e:00:00:00:00:01", logger.Get()))
+}
@@ -31,6 +31,7 @@ import (
 
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipwrapper"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/netlinkwrapper"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/networkutils"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/nswrapper"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/procsyswrapper"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
@@ -41,6 +42,8 @@ const (
 	vlanRulePriority = 10
 	// IP rules priority, leaving a 512 gap for the future
 	toContainerRulePriority = 512
+	// egress rules of the pods annotated with vpc.amazonaws.com/egress-routes, ahead of the 1024 rule
+	egressRulePriority = 768
 	// 1024 is reserved for (IP rule not to <VPC's subnet> table main)
 	fromContainerRulePriority = 1536
 
@@ -53,16 +56,49 @@ const (
 
 // NetworkAPIs defines network API calls
 type NetworkAPIs interface {
-	// SetupPodNetwork sets up pod network for normal ENI based pods
-	SetupPodNetwork(hostVethName string, contVethName string, netnsPath string, v4Addr *net.IPNet, v6Addr *net.IPNet, deviceNumber int, mtu int, log logger.Logger) error
+	// SetupPodNetwork sets up pod network for normal ENI based pods. routeTable is the route table of the ENI of the
+	// pod IP, or 0 for the primary ENI, which uses the main table.
+	SetupPodNetwork(hostVethName string, contVethName string, netnsPath string, v4Addr *net.IPNet, v6Addr *net.IPNet, routeTable int, mtu int, log logger.Logger) error
 	// TeardownPodNetwork clean up pod network for normal ENI based pods
-	TeardownPodNetwork(containerAddr *net.IPNet, deviceNumber int, log logger.Logger) error
+	TeardownPodNetwork(containerAddr *net.IPNet, routeTable int, log logger.Logger) error
+	// TeardownStalePodNetwork cleans up the main table route of a pod that ipamd no longer knows about, only through
+	// hostVethName, and leaves the IP alone when it is routed to another host veth
+	TeardownStalePodNetwork(containerAddr *net.IPNet, hostVethName string, log logger.Logger) error
 
 	// SetupBranchENIPodNetwork sets up pod network for branch ENI based pods
 	SetupBranchENIPodNetwork(hostVethName string, contVethName string, netnsPath string, v4Addr *net.IPNet, v6Addr *net.IPNet, vlanID int, eniMAC string,
 		subnetGW string, parentIfIndex int, mtu int, podSGEnforcingMode sgpp.EnforcingMode, log logger.Logger) error
 	// TeardownBranchENIPodNetwork cleans up pod network for branch ENI based pods
 	TeardownBranchENIPodNetwork(containerAddr *net.IPNet, vlanID int, podSGEnforcingMode sgpp.EnforcingMode, log logger.Logger) error
+
+	// SetupBranchENIPodSecondaryNetwork sets up an additional branch ENI as a secondary interface of the pod
+	SetupBranchENIPodSecondaryNetwork(hostVethName string, contVethName string, netnsPath string, v4Addr *net.IPNet, podRouteTable int,
+		vlanID int, eniMAC string, subnetGW string, parentIfIndex int, mtu int, podSGEnforcingMode sgpp.EnforcingMode, log logger.Logger) error
+
+	// CheckPodNetwork verifies that the network set up for a pod is still in place
+	CheckPodNetwork(hostVethName string, contVethName string, netnsPath string, containerAddr *net.IPNet, log logger.Logger) error
+
+	// SetupDedicatedENIPodNetwork moves a whole ENI into the pod network namespace as contIfName
+	SetupDedicatedENIPodNetwork(contIfName string, netnsPath string, v4Addr *net.IPNet, eniMAC string, subnetGW string, mtu int, log logger.Logger) error
+	// TeardownDedicatedENIPodNetwork moves the dedicated ENI of a pod back to the host network namespace
+	TeardownDedicatedENIPodNetwork(netnsPath string, eniMAC string, log logger.Logger) error
+	// CheckDedicatedENIPodNetwork verifies that the dedicated ENI of a pod still has the pod address and default route
+	CheckDedicatedENIPodNetwork(contIfName string, netnsPath string, containerAddr *net.IPNet, log logger.Logger) error
+
+	// SetupWarmVethPodNetwork sets up pod network for normal ENI based pods with a veth pair pre-plumbed by ipamd
+	SetupWarmVethPodNetwork(warmVeth string, warmPeer string, hostVethName string, contVethName string, netnsPath string,
+		v4Addr *net.IPNet, mtu int, log logger.Logger) error
+
+	// SetupPodEgressRoutes sends the traffic of a pod set up by SetupPodNetwork or SetupWarmVethPodNetwork to some
+	// destinations through other route tables than the one of the pod IP
+	SetupPodEgressRoutes(containerAddr *net.IPNet, egressRoutes []EgressRoute, log logger.Logger) error
+
+	// SetupPodMulticast enables multicast and broadcast on the veth pair of a pod set up by SetupPodNetwork
+	SetupPodMulticast(hostVethName string, contVethName string, netnsPath string, v4Addr *net.IPNet, log logger.Logger) error
+
+	// SetupPodVMNetwork adds a tap device bridged to the pod interface for the VM-based runtimes. hostVethName is empty
+	// for a dedicated ENI pod.
+	SetupPodVMNetwork(hostVethName string, contIfName string, tapName string, netnsPath string, mtu int, log logger.Logger) error
 }
 
 type linuxNetwork struct {
@@ -91,18 +127,22 @@ type createVethPairContext struct {
 	ip           ipwrapper.IP
 	mtu          int
 	procSys      procsyswrapper.ProcSys
+	// podRouteTable is the route table inside the pod holding the default route of the interface. Zero means the main
+	// table, which is used by the primary interface. Secondary interfaces use their own table, selected by source address.
+	podRouteTable int
 }
 
-func newCreateVethPairContext(contVethName string, hostVethName string, v4Addr *net.IPNet, v6Addr *net.IPNet, mtu int) *createVethPairContext {
+func newCreateVethPairContext(contVethName string, hostVethName string, v4Addr *net.IPNet, v6Addr *net.IPNet, mtu int, podRouteTable int) *createVethPairContext {
 	return &createVethPairContext{
-		contVethName: contVethName,
-		hostVethName: hostVethName,
-		v4Addr:       v4Addr,
-		v6Addr:       v6Addr,
-		netLink:      netlinkwrapper.NewNetLink(),
-		ip:           ipwrapper.NewIP(),
-		mtu:          mtu,
-		procSys:      procsyswrapper.NewProcSys(),
+		contVethName:  contVethName,
+		hostVethName:  hostVethName,
+		v4Addr:        v4Addr,
+		v6Addr:        v6Addr,
+		netLink:       netlinkwrapper.NewNetLink(),
+		ip:            ipwrapper.NewIP(),
+		mtu:           mtu,
+		procSys:       procsyswrapper.NewProcSys(),
+		podRouteTable: podRouteTable,
 	}
 }
 
@@ -186,7 +226,8 @@ func (createVethContext *createVethPairContext) run(hostNS ns.NetNS) error {
 	if err = createVethContext.netLink.RouteReplace(&netlink.Route{
 		LinkIndex: contVeth.Attrs().Index,
 		Scope:     netlink.SCOPE_LINK,
-		Dst:       gwNet}); err != nil {
+		Dst:       gwNet,
+		Table:     createVethContext.podRouteTable}); err != nil {
 		return errors.Wrap(err, "setup NS network: failed to add default gateway")
 	}
 
@@ -197,6 +238,7 @@ func (createVethContext *createVethPairContext) run(hostNS ns.NetNS) error {
 		Scope:     netlink.SCOPE_UNIVERSE,
 		Dst:       defNet,
 		Gw:        gw,
+		Table:     createVethContext.podRouteTable,
 	}); err != nil {
 		return errors.Wrap(err, "setup NS network: failed to add default route")
 	}
@@ -205,6 +247,18 @@ func (createVethContext *createVethPairContext) run(hostNS ns.NetNS) error {
 		return errors.Wrapf(err, "setup NS network: failed to add IP addr to %q", createVethContext.contVethName)
 	}
 
+	// The default route of a secondary interface lives in its own table, so only traffic sourced from its address
+	// leaves through it and the pod keeps using the primary interface otherwise.
+	if createVethContext.podRouteTable > 0 {
+		fromContainerRule := createVethContext.netLink.NewRule()
+		fromContainerRule.Src = addr.IPNet
+		fromContainerRule.Table = createVethContext.podRouteTable
+		fromContainerRule.Priority = fromContainerRulePriority
+		if err = createVethContext.netLink.RuleAdd(fromContainerRule); err != nil && !isRuleExistsError(err) {
+			return errors.Wrapf(err, "setup NS network: failed to add source rule for %q", createVethContext.contVethName)
+		}
+	}
+
 	// add static ARP entry for default gateway
 	// we are using routed mode on the host and container need this static ARP entry to resolve its default gateway.
 	// IP address family is derived from the IP address passed to the function (v4 or v6)
@@ -274,11 +328,11 @@ func waitForAddressesToBeStable(netLink netlinkwrapper.NetLink, ifName string, t
 // SetupPodNetwork wires up linux networking for a pod's network
 // we expect v4Addr and v6Addr to have correct IPAddress Family.
 func (n *linuxNetwork) SetupPodNetwork(hostVethName string, contVethName string, netnsPath string, v4Addr *net.IPNet, v6Addr *net.IPNet,
-	deviceNumber int, mtu int, log logger.Logger) error {
-	log.Debugf("SetupPodNetwork: hostVethName=%s, contVethName=%s, netnsPath=%s, v4Addr=%v, v6Addr=%v, deviceNumber=%d, mtu=%d",
-		hostVethName, contVethName, netnsPath, v4Addr, v6Addr, deviceNumber, mtu)
+	routeTable int, mtu int, log logger.Logger) error {
+	log.Debugf("SetupPodNetwork: hostVethName=%s, contVethName=%s, netnsPath=%s, v4Addr=%v, v6Addr=%v, routeTable=%d, mtu=%d",
+		hostVethName, contVethName, netnsPath, v4Addr, v6Addr, routeTable, mtu)
 
-	hostVeth, err := n.setupVeth(hostVethName, contVethName, netnsPath, v4Addr, v6Addr, mtu, log)
+	hostVeth, err := n.setupVeth(hostVethName, contVethName, netnsPath, v4Addr, v6Addr, mtu, 0, log)
 	if err != nil {
 		return errors.Wrapf(err, "SetupPodNetwork: failed to setup veth pair")
 	}
@@ -291,29 +345,143 @@ func (n *linuxNetwork) SetupPodNetwork(hostVethName string, contVethName string,
 	}
 
 	rtTable := unix.RT_TABLE_MAIN
-	if deviceNumber > 0 {
-		rtTable = deviceNumber + 1
+	if routeTable > 0 {
+		rtTable = routeTable
 	}
-	if err := n.setupIPBasedContainerRouteRules(hostVeth, containerAddr, rtTable, log); err != nil {
+	tx := networkutils.NewNetlinkTransaction(n.netLink)
+	if err := n.setupIPBasedContainerRouteRules(tx, hostVeth, containerAddr, rtTable, log); err != nil {
+		rollback(tx, log)
 		return errors.Wrapf(err, "SetupPodNetwork: unable to setup IP based container routes and rules")
 	}
 	return nil
 }
 
 // TeardownPodNetwork cleanup ip rules
-func (n *linuxNetwork) TeardownPodNetwork(containerAddr *net.IPNet, deviceNumber int, log logger.Logger) error {
-	log.Debugf("TeardownPodNetwork: containerAddr=%s, deviceNumber=%d", containerAddr.String(), deviceNumber)
+func (n *linuxNetwork) TeardownPodNetwork(containerAddr *net.IPNet, routeTable int, log logger.Logger) error {
+	log.Debugf("TeardownPodNetwork: containerAddr=%s, routeTable=%d", containerAddr.String(), routeTable)
 
 	rtTable := unix.RT_TABLE_MAIN
-	if deviceNumber > 0 {
-		rtTable = deviceNumber + 1
+	if routeTable > 0 {
+		rtTable = routeTable
 	}
 	if err := n.teardownIPBasedContainerRouteRules(containerAddr, rtTable, log); err != nil {
 		return errors.Wrapf(err, "TeardownPodNetwork: unable to teardown IP based container routes and rules")
 	}
+	if err := n.teardownPodEgressRoutes(containerAddr, log); err != nil {
+		return errors.Wrapf(err, "TeardownPodNetwork: unable to teardown egress rules")
+	}
+	return nil
+}
+
+// TeardownStalePodNetwork cleans up the network of a pod from its prevResult, when ipamd has no record of it. The IP
+// may have been handed to another pod since, e.g. when the DEL is repeated, so only the routes through the host veth
+// of this pod are deleted, and the rules of the IP only when no other host veth routes it.
+func (n *linuxNetwork) TeardownStalePodNetwork(containerAddr *net.IPNet, hostVethName string, log logger.Logger) error {
+	log.Debugf("TeardownStalePodNetwork: containerAddr=%s, hostVethName=%s", containerAddr.String(), hostVethName)
+
+	// The host veth is gone with the pod network namespace, and its routes with it
+	hostVethIndex := 0
+	hostVeth, err := n.netLink.LinkByName(hostVethName)
+	if err == nil {
+		hostVethIndex = hostVeth.Attrs().Index
+	} else if _, ok := err.(netlink.LinkNotFoundError); !ok {
+		return errors.Wrapf(err, "TeardownStalePodNetwork: failed to find host veth %s", hostVethName)
+	}
+
+	family := netlink.FAMILY_V4
+	if containerAddr.IP.To4() == nil {
+		family = netlink.FAMILY_V6
+	}
+	routes, err := n.netLink.RouteListFiltered(family, &netlink.Route{Dst: containerAddr, Table: unix.RT_TABLE_MAIN},
+		netlink.RT_FILTER_DST|netlink.RT_FILTER_TABLE)
+	if err != nil {
+		return errors.Wrapf(err, "TeardownStalePodNetwork: failed to list the routes of %s", containerAddr.String())
+	}
+	for _, route := range routes {
+		if hostVethIndex == 0 || route.LinkIndex != hostVethIndex {
+			log.Infof("Not tearing down %s, it is routed to link %d instead of %s", containerAddr.String(), route.LinkIndex, hostVethName)
+			return nil
+		}
+	}
+	for i := range routes {
+		if err := n.netLink.RouteDel(&routes[i]); err != nil && !netlinkwrapper.IsNotExistsError(err) {
+			log.Warnf("failed to delete container route, containerAddr=%s, hostVeth=%s: %v", containerAddr.String(), hostVethName, err)
+		}
+	}
+
+	toContainerRule := n.netLink.NewRule()
+	toContainerRule.Dst = containerAddr
+	toContainerRule.Priority = toContainerRulePriority
+	toContainerRule.Table = unix.RT_TABLE_MAIN
+	if err := n.netLink.RuleDel(toContainerRule); err != nil && !containsNoSuchRule(err) {
+		return errors.Wrapf(err, "TeardownStalePodNetwork: failed to delete toContainer rule, containerAddr=%s", containerAddr.String())
+	}
+	if err := n.teardownPodEgressRoutes(containerAddr, log); err != nil {
+		return errors.Wrapf(err, "TeardownStalePodNetwork: unable to teardown egress rules")
+	}
 	return nil
 }
 
+// CheckPodNetwork verifies that the host veth of a pod exists, and that the container side of the veth pair still has
+// the pod address and the default route through the dummy next hop
+func (n *linuxNetwork) CheckPodNetwork(hostVethName string, contVethName string, netnsPath string, containerAddr *net.IPNet,
+	log logger.Logger) error {
+	log.Debugf("CheckPodNetwork: hostVethName=%s, contVethName=%s, netnsPath=%s, containerAddr=%v",
+		hostVethName, contVethName, netnsPath, containerAddr)
+
+	if _, err := n.netLink.LinkByName(hostVethName); err != nil {
+		return errors.Wrapf(err, "CheckPodNetwork: failed to find host veth %s", hostVethName)
+	}
+	err := n.ns.WithNetNSPath(netnsPath, func(ns.NetNS) error {
+		return n.checkContainerVeth(contVethName, containerAddr)
+	})
+	return errors.Wrap(err, "CheckPodNetwork")
+}
+
+// checkContainerVeth runs within the container's namespace
+func (n *linuxNetwork) checkContainerVeth(contVethName string, containerAddr *net.IPNet) error {
+	contVeth, err := n.netLink.LinkByName(contVethName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find %s", contVethName)
+	}
+	family := netlink.FAMILY_V4
+	if containerAddr.IP.To4() == nil {
+		family = netlink.FAMILY_V6
+	}
+
+	addrs, err := n.netLink.AddrList(contVeth, family)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list the addresses of %s", contVethName)
+	}
+	foundAddr := false
+	for _, addr := range addrs {
+		if addr.IPNet != nil && addr.IPNet.String() == containerAddr.String() {
+			foundAddr = true
+			break
+		}
+	}
+	if !foundAddr {
+		return errors.Errorf("%s does not have address %s", contVethName, containerAddr)
+	}
+
+	routes, err := n.netLink.RouteList(contVeth, family)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list the routes of %s", contVethName)
+	}
+	for _, route := range routes {
+		if route.Gw == nil {
+			continue
+		}
+		if route.Dst == nil {
+			return nil
+		}
+		if ones, _ := route.Dst.Mask.Size(); ones == 0 {
+			return nil
+		}
+	}
+	return errors.Errorf("%s does not have a default route", contVethName)
+}
+
 // SetupBranchENIPodNetwork sets up the network ns for pods requesting its own security group
 // we expect v4Addr and v6Addr to have correct IPAddress Family.
 func (n *linuxNetwork) SetupBranchENIPodNetwork(hostVethName string, contVethName string, netnsPath string, v4Addr *net.IPNet, v6Addr *net.IPNet,
@@ -321,11 +489,36 @@ func (n *linuxNetwork) SetupBranchENIPodNetwork(hostVethName string, contVethNam
 	log.Debugf("SetupBranchENIPodNetwork: hostVethName=%s, contVethName=%s, netnsPath=%s, v4Addr=%v, v6Addr=%v, vlanID=%d, eniMAC=%s, subnetGW=%s, parentIfIndex=%d, mtu=%d, podSGEnforcingMode=%v",
 		hostVethName, contVethName, netnsPath, v4Addr, v6Addr, vlanID, eniMAC, subnetGW, parentIfIndex, mtu, podSGEnforcingMode)
 
-	hostVeth, err := n.setupVeth(hostVethName, contVethName, netnsPath, v4Addr, v6Addr, mtu, log)
+	hostVeth, err := n.setupVeth(hostVethName, contVethName, netnsPath, v4Addr, v6Addr, mtu, 0, log)
 	if err != nil {
 		return errors.Wrapf(err, "SetupBranchENIPodNetwork: failed to setup veth pair")
 	}
 
+	return n.setupBranchENIHostNetwork(hostVeth, hostVethName, v4Addr, v6Addr, vlanID, eniMAC, subnetGW, parentIfIndex, podSGEnforcingMode, log)
+}
+
+// SetupBranchENIPodSecondaryNetwork sets up an additional branch ENI of a pod as the contVethName interface. The host side
+// is wired the same way as for the primary branch ENI, while inside the pod the default route of the interface is put in
+// podRouteTable so it doesn't conflict with the primary interface.
+func (n *linuxNetwork) SetupBranchENIPodSecondaryNetwork(hostVethName string, contVethName string, netnsPath string, v4Addr *net.IPNet, podRouteTable int,
+	vlanID int, eniMAC string, subnetGW string, parentIfIndex int, mtu int, podSGEnforcingMode sgpp.EnforcingMode, log logger.Logger) error {
+	log.Debugf("SetupBranchENIPodSecondaryNetwork: hostVethName=%s, contVethName=%s, netnsPath=%s, v4Addr=%v, podRouteTable=%d, vlanID=%d, eniMAC=%s, subnetGW=%s, parentIfIndex=%d, mtu=%d, podSGEnforcingMode=%v",
+		hostVethName, contVethName, netnsPath, v4Addr, podRouteTable, vlanID, eniMAC, subnetGW, parentIfIndex, mtu, podSGEnforcingMode)
+
+	if podRouteTable <= 0 {
+		return errors.Errorf("SetupBranchENIPodSecondaryNetwork: invalid pod route table %d", podRouteTable)
+	}
+	hostVeth, err := n.setupVeth(hostVethName, contVethName, netnsPath, v4Addr, nil, mtu, podRouteTable, log)
+	if err != nil {
+		return errors.Wrapf(err, "SetupBranchENIPodSecondaryNetwork: failed to setup veth pair")
+	}
+
+	return n.setupBranchENIHostNetwork(hostVeth, hostVethName, v4Addr, nil, vlanID, eniMAC, subnetGW, parentIfIndex, podSGEnforcingMode, log)
+}
+
+// setupBranchENIHostNetwork sets up the vlan, routes and rules on the host for a veth backed by a branch ENI
+func (n *linuxNetwork) setupBranchENIHostNetwork(hostVeth netlink.Link, hostVethName string, v4Addr *net.IPNet, v6Addr *net.IPNet,
+	vlanID int, eniMAC string, subnetGW string, parentIfIndex int, podSGEnforcingMode sgpp.EnforcingMode, log logger.Logger) error {
 	// clean up any previous hostVeth ip rule recursively. (when pod with same name are recreated multiple times).
 	//
 	// per our understanding, previous we obtain vlanID from pod spec, it could be possible the vlanID is already updated when deleting old pod, thus the hostVeth been cleaned up during oldPod deletion is incorrect.
@@ -338,9 +531,12 @@ func (n *linuxNetwork) SetupBranchENIPodNetwork(hostVethName string, contVethNam
 		return errors.Wrapf(err, "SetupBranchENIPodNetwork: failed to delete hostVeth rule for %s", hostVethName)
 	}
 
+	// The vlan, routes and rules are set up as one batch, rolled back if one of them fails
+	tx := networkutils.NewNetlinkTransaction(n.netLink)
 	rtTable := vlanID + 100
-	vlanLink, err := n.setupVlan(vlanID, eniMAC, subnetGW, parentIfIndex, rtTable, log)
+	vlanLink, err := n.setupVlan(tx, vlanID, eniMAC, subnetGW, parentIfIndex, rtTable, log)
 	if err != nil {
+		rollback(tx, log)
 		return errors.Wrapf(err, "SetupBranchENIPodNetwork: failed to setup vlan")
 	}
 
@@ -353,11 +549,13 @@ func (n *linuxNetwork) SetupBranchENIPodNetwork(hostVethName string, contVethNam
 
 	switch podSGEnforcingMode {
 	case sgpp.EnforcingModeStrict:
-		if err := n.setupIIFBasedContainerRouteRules(hostVeth, containerAddr, vlanLink, rtTable, log); err != nil {
+		if err := n.setupIIFBasedContainerRouteRules(tx, hostVeth, containerAddr, vlanLink, rtTable, log); err != nil {
+			rollback(tx, log)
 			return errors.Wrapf(err, "SetupBranchENIPodNetwork: unable to setup IIF based container routes and rules")
 		}
 	case sgpp.EnforcingModeStandard:
-		if err := n.setupIPBasedContainerRouteRules(hostVeth, containerAddr, rtTable, log); err != nil {
+		if err := n.setupIPBasedContainerRouteRules(tx, hostVeth, containerAddr, rtTable, log); err != nil {
+			rollback(tx, log)
 			return errors.Wrapf(err, "SetupBranchENIPodNetwork: unable to setup IP based container routes and rules")
 		}
 	}
@@ -385,7 +583,8 @@ func (n *linuxNetwork) TeardownBranchENIPodNetwork(containerAddr *net.IPNet, vla
 }
 
 // setupVeth sets up veth for the pod.
-func (n *linuxNetwork) setupVeth(hostVethName string, contVethName string, netnsPath string, v4Addr *net.IPNet, v6Addr *net.IPNet, mtu int, log logger.Logger) (netlink.Link, error) {
+func (n *linuxNetwork) setupVeth(hostVethName string, contVethName string, netnsPath string, v4Addr *net.IPNet, v6Addr *net.IPNet, mtu int,
+	podRouteTable int, log logger.Logger) (netlink.Link, error) {
 	// Clean up if hostVeth exists.
 	if oldHostVeth, err := n.netLink.LinkByName(hostVethName); err == nil {
 		if err = n.netLink.LinkDel(oldHostVeth); err != nil {
@@ -394,7 +593,7 @@ func (n *linuxNetwork) setupVeth(hostVethName string, contVethName string, netns
 		log.Debugf("Successfully deleted old hostVeth %s", hostVethName)
 	}
 
-	createVethContext := newCreateVethPairContext(contVethName, hostVethName, v4Addr, v6Addr, mtu)
+	createVethContext := newCreateVethPairContext(contVethName, hostVethName, v4Addr, v6Addr, mtu, podRouteTable)
 	if err := n.ns.WithNetNSPath(netnsPath, createVethContext.run); err != nil {
 		return nil, errors.Wrap(err, "failed to setup veth network")
 	}
@@ -428,7 +627,7 @@ func (n *linuxNetwork) setupVeth(hostVethName string, contVethName string, netns
 }
 
 // setupVlan sets up the vlan interface for branchENI, and configures default routes in specified route table
-func (n *linuxNetwork) setupVlan(vlanID int, eniMAC string, subnetGW string, parentIfIndex int, rtTable int, log logger.Logger) (netlink.Link, error) {
+func (n *linuxNetwork) setupVlan(tx *networkutils.NetlinkTransaction, vlanID int, eniMAC string, subnetGW string, parentIfIndex int, rtTable int, log logger.Logger) (netlink.Link, error) {
 	vlanLinkName := buildVlanLinkName(vlanID)
 	// 1. clean up if vlan already exists (necessary when trunk ENI changes).
 	if oldVlan, err := n.netLink.LinkByName(vlanLinkName); err == nil {
@@ -440,7 +639,7 @@ func (n *linuxNetwork) setupVlan(vlanID int, eniMAC string, subnetGW string, par
 
 	// 2. add new vlan link
 	vlanLink := buildVlanLink(vlanLinkName, vlanID, parentIfIndex, eniMAC)
-	if err := n.netLink.LinkAdd(vlanLink); err != nil {
+	if err := tx.LinkAdd(vlanLink); err != nil {
 		return nil, errors.Wrapf(err, "failed to add vlan link %s", vlanLinkName)
 	}
 
@@ -452,7 +651,7 @@ func (n *linuxNetwork) setupVlan(vlanID int, eniMAC string, subnetGW string, par
 	// 4. create default routes for vlan
 	routes := buildRoutesForVlan(rtTable, vlanLink.Index, net.ParseIP(subnetGW))
 	for _, r := range routes {
-		if err := n.netLink.RouteReplace(&r); err != nil {
+		if err := tx.RouteReplace(&r); err != nil {
 			return nil, errors.Wrapf(err, "failed to replace route entry %s via %s", r.Dst.IP.String(), subnetGW)
 		}
 	}
@@ -473,14 +672,14 @@ func (n *linuxNetwork) teardownVlan(vlanID int, log logger.Logger) error {
 // setupIPBasedContainerRouteRules setups the routes and route rules for containers based on IP.
 // traffic to container(to containerAddr) will be routed via the `main` route table.
 // traffic from container(from containerAddr) will be routed via the specified rtTable.
-func (n *linuxNetwork) setupIPBasedContainerRouteRules(hostVeth netlink.Link, containerAddr *net.IPNet, rtTable int, log logger.Logger) error {
+func (n *linuxNetwork) setupIPBasedContainerRouteRules(tx *networkutils.NetlinkTransaction, hostVeth netlink.Link, containerAddr *net.IPNet, rtTable int, log logger.Logger) error {
 	route := netlink.Route{
 		LinkIndex: hostVeth.Attrs().Index,
 		Scope:     netlink.SCOPE_LINK,
 		Dst:       containerAddr,
 		Table:     unix.RT_TABLE_MAIN,
 	}
-	if err := n.netLink.RouteReplace(&route); err != nil {
+	if err := tx.RouteReplace(&route); err != nil {
 		return errors.Wrapf(err, "failed to setup container route, containerAddr=%s, hostVeth=%s, rtTable=%v",
 			containerAddr.String(), hostVeth.Attrs().Name, "main")
 	}
@@ -491,7 +690,7 @@ func (n *linuxNetwork) setupIPBasedContainerRouteRules(hostVeth netlink.Link, co
 	toContainerRule.Dst = containerAddr
 	toContainerRule.Priority = toContainerRulePriority
 	toContainerRule.Table = unix.RT_TABLE_MAIN
-	if err := n.netLink.RuleAdd(toContainerRule); err != nil && !isRuleExistsError(err) {
+	if err := tx.RuleAdd(toContainerRule); err != nil {
 		return errors.Wrapf(err, "failed to setup toContainer rule, containerAddr=%s, rtTable=%v", containerAddr.String(), "main")
 	}
 
@@ -502,7 +701,7 @@ func (n *linuxNetwork) setupIPBasedContainerRouteRules(hostVeth netlink.Link, co
 		fromContainerRule.Src = containerAddr
 		fromContainerRule.Priority = fromContainerRulePriority
 		fromContainerRule.Table = rtTable
-		if err := n.netLink.RuleAdd(fromContainerRule); err != nil && !isRuleExistsError(err) {
+		if err := tx.RuleAdd(fromContainerRule); err != nil {
 			return errors.Wrapf(err, "failed to setup fromContainer rule, containerAddr=%s, rtTable=%v", containerAddr.String(), rtTable)
 		}
 		log.Debugf("Successfully setup fromContainer rule, containerAddr=%s, rtTable=%v", containerAddr.String(), rtTable)
@@ -554,14 +753,14 @@ func (n *linuxNetwork) teardownIPBasedContainerRouteRules(containerAddr *net.IPN
 // setupIIFBasedContainerRouteRules setups the routes and route rules for containers based on input network interface.
 // traffic to container(iif hostVlan) will be routed via the specified rtTable.
 // traffic from container(iif hostVeth) will be routed via the specified rtTable.
-func (n *linuxNetwork) setupIIFBasedContainerRouteRules(hostVeth netlink.Link, containerAddr *net.IPNet, hostVlan netlink.Link, rtTable int, log logger.Logger) error {
+func (n *linuxNetwork) setupIIFBasedContainerRouteRules(tx *networkutils.NetlinkTransaction, hostVeth netlink.Link, containerAddr *net.IPNet, hostVlan netlink.Link, rtTable int, log logger.Logger) error {
 	route := netlink.Route{
 		LinkIndex: hostVeth.Attrs().Index,
 		Scope:     netlink.SCOPE_LINK,
 		Dst:       containerAddr,
 		Table:     rtTable,
 	}
-	if err := n.netLink.RouteReplace(&route); err != nil {
+	if err := tx.RouteReplace(&route); err != nil {
 		return errors.Wrapf(err, "failed to setup container route, containerAddr=%s, hostVeth=%s, rtTable=%v",
 			containerAddr.String(), hostVeth.Attrs().Name, rtTable)
 	}
@@ -572,7 +771,7 @@ func (n *linuxNetwork) setupIIFBasedContainerRouteRules(hostVeth netlink.Link, c
 	fromHostVlanRule.IifName = hostVlan.Attrs().Name
 	fromHostVlanRule.Priority = vlanRulePriority
 	fromHostVlanRule.Table = rtTable
-	if err := n.netLink.RuleAdd(fromHostVlanRule); err != nil && !isRuleExistsError(err) {
+	if err := tx.RuleAdd(fromHostVlanRule); err != nil {
 		return errors.Wrapf(err, "unable to setup fromHostVlan rule, hostVlan=%s, rtTable=%v", hostVlan.Attrs().Name, rtTable)
 	}
 	log.Debugf("Successfully setup fromHostVlan rule, hostVlan=%s, rtTable=%v", hostVlan.Attrs().Name, rtTable)
@@ -581,7 +780,7 @@ func (n *linuxNetwork) setupIIFBasedContainerRouteRules(hostVeth netlink.Link, c
 	fromHostVethRule.IifName = hostVeth.Attrs().Name
 	fromHostVethRule.Priority = vlanRulePriority
 	fromHostVethRule.Table = rtTable
-	if err := n.netLink.RuleAdd(fromHostVethRule); err != nil && !isRuleExistsError(err) {
+	if err := tx.RuleAdd(fromHostVethRule); err != nil {
 		return errors.Wrapf(err, "unable to setup fromHostVeth rule, hostVeth=%s, rtTable=%v", hostVeth.Attrs().Name, rtTable)
 	}
 	log.Debugf("Successfully setup fromHostVeth rule, hostVeth=%s, rtTable=%v", hostVeth.Attrs().Name, rtTable)
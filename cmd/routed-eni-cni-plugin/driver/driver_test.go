@@ -21,10 +21,12 @@ import (
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/cninswrapper/mock_ns"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/netlinkwrapper/mock_netlink"
 	mock_netlinkwrapper "github.com/aws/amazon-vpc-cni-k8s/pkg/netlinkwrapper/mocks"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/networkutils"
 	mock_nswrapper "github.com/aws/amazon-vpc-cni-k8s/pkg/nswrapper/mocks"
 	mock_procsyswrapper "github.com/aws/amazon-vpc-cni-k8s/pkg/procsyswrapper/mocks"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/sgpp"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
+	"github.com/containernetworking/plugins/pkg/ns"
 	"github.com/golang/mock/gomock"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
@@ -78,10 +80,18 @@ func Test_linuxNetwork_SetupPodNetwork(t *testing.T) {
 		route *netlink.Route
 		err   error
 	}
+	type routeDelCall struct {
+		route *netlink.Route
+		err   error
+	}
 	type ruleAddCall struct {
 		rule *netlink.Rule
 		err  error
 	}
+	type ruleDelCall struct {
+		rule *netlink.Rule
+		err  error
+	}
 	type withNetNSPathCall struct {
 		netNSPath string
 		err       error
@@ -99,7 +109,9 @@ func Test_linuxNetwork_SetupPodNetwork(t *testing.T) {
 		withNetNSPathCalls []withNetNSPathCall
 		procSysSetCalls    []procSysSetCall
 		routeReplaceCalls  []routeReplaceCall
+		routeDelCalls      []routeDelCall
 		ruleAddCalls       []ruleAddCall
+		ruleDelCalls       []ruleDelCall
 	}
 	type args struct {
 		hostVethName string
@@ -107,7 +119,7 @@ func Test_linuxNetwork_SetupPodNetwork(t *testing.T) {
 		netnsPath    string
 		v4Addr       *net.IPNet
 		v6Addr       *net.IPNet
-		deviceNumber int
+		routeTable   int
 		mtu          int
 	}
 	tests := []struct {
@@ -171,7 +183,7 @@ func Test_linuxNetwork_SetupPodNetwork(t *testing.T) {
 				netnsPath:    "/proc/42/ns/net",
 				v4Addr:       containerAddr,
 				v6Addr:       nil,
-				deviceNumber: 0,
+				routeTable:   0,
 				mtu:          9001,
 			},
 		},
@@ -233,7 +245,7 @@ func Test_linuxNetwork_SetupPodNetwork(t *testing.T) {
 				netnsPath:    "/proc/42/ns/net",
 				v4Addr:       containerAddr,
 				v6Addr:       nil,
-				deviceNumber: 3,
+				routeTable:   4,
 				mtu:          9001,
 			},
 		},
@@ -259,7 +271,7 @@ func Test_linuxNetwork_SetupPodNetwork(t *testing.T) {
 				netnsPath:    "/proc/42/ns/net",
 				v4Addr:       containerAddr,
 				v6Addr:       nil,
-				deviceNumber: 3,
+				routeTable:   4,
 				mtu:          9001,
 			},
 			wantErr: errors.New("SetupPodNetwork: failed to setup veth pair: failed to setup veth network: some error"),
@@ -315,11 +327,90 @@ func Test_linuxNetwork_SetupPodNetwork(t *testing.T) {
 				netnsPath:    "/proc/42/ns/net",
 				v4Addr:       containerAddr,
 				v6Addr:       nil,
-				deviceNumber: 3,
+				routeTable:   4,
 				mtu:          9001,
 			},
 			wantErr: errors.New("SetupPodNetwork: unable to setup IP based container routes and rules: failed to setup container route, containerAddr=192.168.100.42/32, hostVeth=eni8ea2c11fe35, rtTable=main: some error"),
 		},
+		{
+			name: "failed to setup fromContainer rule - rolls back the container route and toContainer rule",
+			fields: fields{
+				linkByNameCalls: []linkByNameCall{
+					{
+						linkName: "eni8ea2c11fe35",
+						err:      errors.New("not exists"),
+					},
+					{
+						linkName: "eni8ea2c11fe35",
+						link:     hostVethWithIndex9,
+					},
+				},
+				linkSetupCalls: []linkSetupCall{
+					{
+						link: hostVethWithIndex9,
+					},
+				},
+				routeReplaceCalls: []routeReplaceCall{
+					{
+						route: &netlink.Route{
+							LinkIndex: hostVethWithIndex9.Index,
+							Scope:     netlink.SCOPE_LINK,
+							Dst:       containerAddr,
+							Table:     unix.RT_TABLE_MAIN,
+						},
+					},
+				},
+				routeDelCalls: []routeDelCall{
+					{
+						route: &netlink.Route{
+							LinkIndex: hostVethWithIndex9.Index,
+							Scope:     netlink.SCOPE_LINK,
+							Dst:       containerAddr,
+							Table:     unix.RT_TABLE_MAIN,
+						},
+					},
+				},
+				ruleAddCalls: []ruleAddCall{
+					{
+						rule: toContainerRule,
+					},
+					{
+						rule: fromContainerRuleForRTTable4,
+						err:  errors.New("some error"),
+					},
+				},
+				ruleDelCalls: []ruleDelCall{
+					{
+						rule: toContainerRule,
+					},
+				},
+				withNetNSPathCalls: []withNetNSPathCall{
+					{
+						netNSPath: "/proc/42/ns/net",
+					},
+				},
+				procSysSetCalls: []procSysSetCall{
+					{
+						key:   "net/ipv6/conf/eni8ea2c11fe35/accept_ra",
+						value: "0",
+					},
+					{
+						key:   "net/ipv6/conf/eni8ea2c11fe35/accept_redirects",
+						value: "0",
+					},
+				},
+			},
+			args: args{
+				hostVethName: "eni8ea2c11fe35",
+				contVethName: "eth0",
+				netnsPath:    "/proc/42/ns/net",
+				v4Addr:       containerAddr,
+				v6Addr:       nil,
+				routeTable:   4,
+				mtu:          9001,
+			},
+			wantErr: errors.New("SetupPodNetwork: unable to setup IP based container routes and rules: failed to setup fromContainer rule, containerAddr=192.168.100.42/32, rtTable=4: some error"),
+		},
 	}
 
 	for _, tt := range tests {
@@ -341,9 +432,15 @@ func Test_linuxNetwork_SetupPodNetwork(t *testing.T) {
 			for _, call := range tt.fields.routeReplaceCalls {
 				netLink.EXPECT().RouteReplace(call.route).Return(call.err)
 			}
+			for _, call := range tt.fields.routeDelCalls {
+				netLink.EXPECT().RouteDel(call.route).Return(call.err)
+			}
 			for _, call := range tt.fields.ruleAddCalls {
 				netLink.EXPECT().RuleAdd(call.rule).Return(call.err)
 			}
+			for _, call := range tt.fields.ruleDelCalls {
+				netLink.EXPECT().RuleDel(call.rule).Return(call.err)
+			}
 
 			ns := mock_nswrapper.NewMockNS(ctrl)
 			for _, call := range tt.fields.withNetNSPathCalls {
@@ -361,7 +458,7 @@ func Test_linuxNetwork_SetupPodNetwork(t *testing.T) {
 				ns:      ns,
 				procSys: procSys,
 			}
-			err := n.SetupPodNetwork(tt.args.hostVethName, tt.args.contVethName, tt.args.netnsPath, tt.args.v4Addr, tt.args.v6Addr, tt.args.deviceNumber, tt.args.mtu, testLogger)
+			err := n.SetupPodNetwork(tt.args.hostVethName, tt.args.contVethName, tt.args.netnsPath, tt.args.v4Addr, tt.args.v6Addr, tt.args.routeTable, tt.args.mtu, testLogger)
 			if tt.wantErr != nil {
 				assert.EqualError(t, err, tt.wantErr.Error())
 			} else {
@@ -391,6 +488,10 @@ func Test_linuxNetwork_TeardownPodNetwork(t *testing.T) {
 	fromContainerRuleForRTTable4.Src = containerAddr
 	fromContainerRuleForRTTable4.Priority = fromContainerRulePriority
 	fromContainerRuleForRTTable4.Table = 4
+
+	egressRule := netlink.NewRule()
+	egressRule.Src = containerAddr
+	egressRule.Priority = egressRulePriority
 	type routeDelCall struct {
 		route *netlink.Route
 		err   error
@@ -406,7 +507,7 @@ func Test_linuxNetwork_TeardownPodNetwork(t *testing.T) {
 
 	type args struct {
 		containerAddr *net.IPNet
-		deviceNumber  int
+		routeTable    int
 	}
 	tests := []struct {
 		name    string
@@ -426,11 +527,15 @@ func Test_linuxNetwork_TeardownPodNetwork(t *testing.T) {
 					{
 						rule: toContainerRule,
 					},
+					{
+						rule: egressRule,
+						err:  syscall.ENOENT,
+					},
 				},
 			},
 			args: args{
 				containerAddr: containerAddr,
-				deviceNumber:  0,
+				routeTable:    0,
 			},
 		},
 		{
@@ -452,11 +557,18 @@ func Test_linuxNetwork_TeardownPodNetwork(t *testing.T) {
 						rule: fromContainerRuleForRTTable4,
 						err:  syscall.ENOENT,
 					},
+					{
+						rule: egressRule,
+					},
+					{
+						rule: egressRule,
+						err:  syscall.ENOENT,
+					},
 				},
 			},
 			args: args{
 				containerAddr: containerAddr,
-				deviceNumber:  3,
+				routeTable:    4,
 			},
 		},
 		{
@@ -471,7 +583,7 @@ func Test_linuxNetwork_TeardownPodNetwork(t *testing.T) {
 			},
 			args: args{
 				containerAddr: containerAddr,
-				deviceNumber:  3,
+				routeTable:    4,
 			},
 			wantErr: errors.New("TeardownPodNetwork: unable to teardown IP based container routes and rules: failed to delete toContainer rule, containerAddr=192.168.100.42/32, rtTable=main: some error"),
 		},
@@ -493,7 +605,7 @@ func Test_linuxNetwork_TeardownPodNetwork(t *testing.T) {
 			n := &linuxNetwork{
 				netLink: netLink,
 			}
-			err := n.TeardownPodNetwork(tt.args.containerAddr, tt.args.deviceNumber, testLogger)
+			err := n.TeardownPodNetwork(tt.args.containerAddr, tt.args.routeTable, testLogger)
 			if tt.wantErr != nil {
 				assert.EqualError(t, err, tt.wantErr.Error())
 			} else {
@@ -503,6 +615,168 @@ func Test_linuxNetwork_TeardownPodNetwork(t *testing.T) {
 	}
 }
 
+func Test_linuxNetwork_TeardownStalePodNetwork(t *testing.T) {
+	containerAddr := &net.IPNet{
+		IP:   net.ParseIP("192.168.100.42"),
+		Mask: net.CIDRMask(32, 32),
+	}
+	hostVeth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "eni8ea2c11fe35", Index: 9}}
+	ownRoute := netlink.Route{LinkIndex: 9, Scope: netlink.SCOPE_LINK, Dst: containerAddr, Table: unix.RT_TABLE_MAIN}
+	otherRoute := netlink.Route{LinkIndex: 11, Scope: netlink.SCOPE_LINK, Dst: containerAddr, Table: unix.RT_TABLE_MAIN}
+
+	toContainerRule := netlink.NewRule()
+	toContainerRule.Dst = containerAddr
+	toContainerRule.Priority = toContainerRulePriority
+	toContainerRule.Table = unix.RT_TABLE_MAIN
+	egressRule := netlink.NewRule()
+	egressRule.Src = containerAddr
+	egressRule.Priority = egressRulePriority
+
+	tests := []struct {
+		name        string
+		hostVethErr error
+		routes      []netlink.Route
+		wantDeleted bool
+	}{
+		{
+			name:        "routed through the host veth of the pod",
+			routes:      []netlink.Route{ownRoute},
+			wantDeleted: true,
+		},
+		{
+			name:   "routed to the host veth of another pod",
+			routes: []netlink.Route{otherRoute},
+		},
+		{
+			name:        "host veth and routes gone",
+			hostVethErr: netlink.LinkNotFoundError{},
+			wantDeleted: true,
+		},
+		{
+			name:        "host veth gone, routed to another pod",
+			hostVethErr: netlink.LinkNotFoundError{},
+			routes:      []netlink.Route{otherRoute},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			netLink := mock_netlinkwrapper.NewMockNetLink(ctrl)
+			netLink.EXPECT().NewRule().DoAndReturn(func() *netlink.Rule { return netlink.NewRule() }).AnyTimes()
+			if tt.hostVethErr != nil {
+				netLink.EXPECT().LinkByName("eni8ea2c11fe35").Return(nil, tt.hostVethErr)
+			} else {
+				netLink.EXPECT().LinkByName("eni8ea2c11fe35").Return(hostVeth, nil)
+			}
+			netLink.EXPECT().RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{Dst: containerAddr, Table: unix.RT_TABLE_MAIN},
+				netlink.RT_FILTER_DST|netlink.RT_FILTER_TABLE).Return(tt.routes, nil)
+			if tt.wantDeleted {
+				for i := range tt.routes {
+					netLink.EXPECT().RouteDel(&tt.routes[i]).Return(nil)
+				}
+				netLink.EXPECT().RuleDel(toContainerRule).Return(nil)
+				netLink.EXPECT().RuleDel(egressRule).Return(syscall.ENOENT)
+			}
+
+			n := &linuxNetwork{
+				netLink: netLink,
+			}
+			assert.NoError(t, n.TeardownStalePodNetwork(containerAddr, "eni8ea2c11fe35", testLogger))
+		})
+	}
+}
+
+func Test_linuxNetwork_CheckPodNetwork(t *testing.T) {
+	hostVeth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "eni8ea2c11fe35", Index: 9}}
+	contVeth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "eth0", Index: 3}}
+	containerAddr := &net.IPNet{
+		IP:   net.ParseIP("192.168.100.42"),
+		Mask: net.CIDRMask(32, 32),
+	}
+	defaultRoute := netlink.Route{
+		LinkIndex: 3,
+		Dst:       &net.IPNet{IP: net.IPv4zero, Mask: net.CIDRMask(0, 32)},
+		Gw:        net.IPv4(169, 254, 1, 1),
+	}
+	gatewayRoute := netlink.Route{
+		LinkIndex: 3,
+		Scope:     netlink.SCOPE_LINK,
+		Dst:       &net.IPNet{IP: net.IPv4(169, 254, 1, 1), Mask: net.CIDRMask(32, 32)},
+	}
+
+	tests := []struct {
+		name    string
+		hostErr error
+		addrs   []netlink.Addr
+		routes  []netlink.Route
+		checkNS bool
+		wantErr string
+	}{
+		{
+			name:    "pod network is set up",
+			addrs:   []netlink.Addr{{IPNet: containerAddr}},
+			routes:  []netlink.Route{gatewayRoute, defaultRoute},
+			checkNS: true,
+		},
+		{
+			name:    "host veth missing",
+			hostErr: errors.New("not found"),
+			wantErr: "CheckPodNetwork: failed to find host veth eni8ea2c11fe35: not found",
+		},
+		{
+			name:    "container address missing",
+			addrs:   []netlink.Addr{},
+			checkNS: true,
+			wantErr: "CheckPodNetwork: eth0 does not have address 192.168.100.42/32",
+		},
+		{
+			name:    "default route missing",
+			addrs:   []netlink.Addr{{IPNet: containerAddr}},
+			routes:  []netlink.Route{gatewayRoute},
+			checkNS: true,
+			wantErr: "CheckPodNetwork: eth0 does not have a default route",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			netLink := mock_netlinkwrapper.NewMockNetLink(ctrl)
+			nsWrapper := mock_nswrapper.NewMockNS(ctrl)
+			if tt.hostErr != nil {
+				netLink.EXPECT().LinkByName("eni8ea2c11fe35").Return(nil, tt.hostErr)
+			} else {
+				netLink.EXPECT().LinkByName("eni8ea2c11fe35").Return(hostVeth, nil)
+			}
+			if tt.checkNS {
+				nsWrapper.EXPECT().WithNetNSPath("/proc/42/ns/net", gomock.Any()).DoAndReturn(
+					func(nspath string, toRun func(ns.NetNS) error) error {
+						return toRun(nil)
+					})
+				netLink.EXPECT().LinkByName("eth0").Return(contVeth, nil)
+				netLink.EXPECT().AddrList(contVeth, netlink.FAMILY_V4).Return(tt.addrs, nil)
+				if tt.routes != nil {
+					netLink.EXPECT().RouteList(contVeth, netlink.FAMILY_V4).Return(tt.routes, nil)
+				}
+			}
+
+			n := &linuxNetwork{
+				netLink: netLink,
+				ns:      nsWrapper,
+			}
+			err := n.CheckPodNetwork("eni8ea2c11fe35", "eth0", "/proc/42/ns/net", containerAddr, testLogger)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
 func Test_linuxNetwork_SetupBranchENIPodNetwork(t *testing.T) {
 	vlanID := 7
 	eniMac := "00:00:5e:00:53:af"
@@ -568,6 +842,10 @@ func Test_linuxNetwork_SetupBranchENIPodNetwork(t *testing.T) {
 		route *netlink.Route
 		err   error
 	}
+	type routeDelCall struct {
+		route *netlink.Route
+		err   error
+	}
 	type ruleAddCall struct {
 		rule *netlink.Rule
 		err  error
@@ -593,6 +871,7 @@ func Test_linuxNetwork_SetupBranchENIPodNetwork(t *testing.T) {
 		linkDelCalls       []linkDelCall
 		linkSetupCalls     []linkSetupCall
 		routeReplaceCalls  []routeReplaceCall
+		routeDelCalls      []routeDelCall
 		ruleAddCalls       []ruleAddCall
 		ruleDelCalls       []ruleDelCall
 		withNetNSPathCalls []withNetNSPathCall
@@ -1037,6 +1316,30 @@ func Test_linuxNetwork_SetupBranchENIPodNetwork(t *testing.T) {
 						err:  syscall.ENOENT,
 					},
 				},
+				routeDelCalls: []routeDelCall{
+					{
+						route: &netlink.Route{
+							LinkIndex: vlanLinkPostAddWithIndex11.Index,
+							Dst:       &net.IPNet{IP: net.IPv4zero, Mask: net.CIDRMask(0, 32)},
+							Scope:     netlink.SCOPE_UNIVERSE,
+							Gw:        net.ParseIP(subnetGW),
+							Table:     107,
+						},
+					},
+					{
+						route: &netlink.Route{
+							LinkIndex: vlanLinkPostAddWithIndex11.Index,
+							Dst:       &net.IPNet{IP: net.ParseIP(subnetGW), Mask: net.CIDRMask(32, 32)},
+							Scope:     netlink.SCOPE_LINK,
+							Table:     107,
+						},
+					},
+				},
+				linkDelCalls: []linkDelCall{
+					{
+						link: vlanLinkPostAddWithIndex11,
+					},
+				},
 				withNetNSPathCalls: []withNetNSPathCall{
 					{
 						netNSPath: "/proc/42/ns/net",
@@ -1133,6 +1436,30 @@ func Test_linuxNetwork_SetupBranchENIPodNetwork(t *testing.T) {
 						err:  syscall.ENOENT,
 					},
 				},
+				routeDelCalls: []routeDelCall{
+					{
+						route: &netlink.Route{
+							LinkIndex: vlanLinkPostAddWithIndex11.Index,
+							Dst:       &net.IPNet{IP: net.IPv4zero, Mask: net.CIDRMask(0, 32)},
+							Scope:     netlink.SCOPE_UNIVERSE,
+							Gw:        net.ParseIP(subnetGW),
+							Table:     107,
+						},
+					},
+					{
+						route: &netlink.Route{
+							LinkIndex: vlanLinkPostAddWithIndex11.Index,
+							Dst:       &net.IPNet{IP: net.ParseIP(subnetGW), Mask: net.CIDRMask(32, 32)},
+							Scope:     netlink.SCOPE_LINK,
+							Table:     107,
+						},
+					},
+				},
+				linkDelCalls: []linkDelCall{
+					{
+						link: vlanLinkPostAddWithIndex11,
+					},
+				},
 				withNetNSPathCalls: []withNetNSPathCall{
 					{
 						netNSPath: "/proc/42/ns/net",
@@ -1195,6 +1522,9 @@ func Test_linuxNetwork_SetupBranchENIPodNetwork(t *testing.T) {
 			for _, call := range tt.fields.routeReplaceCalls {
 				netLink.EXPECT().RouteReplace(call.route).Return(call.err)
 			}
+			for _, call := range tt.fields.routeDelCalls {
+				netLink.EXPECT().RouteDel(call.route).Return(call.err)
+			}
 			for _, call := range tt.fields.ruleAddCalls {
 				netLink.EXPECT().RuleAdd(call.rule).Return(call.err)
 			}
@@ -1559,6 +1889,10 @@ func Test_createVethPairContext_run(t *testing.T) {
 	type nsFDCall struct {
 		fd uintptr
 	}
+	type ruleAddCall struct {
+		rule *netlink.Rule
+		err  error
+	}
 
 	type fields struct {
 		linkByNameCalls   []linkByNameCall
@@ -1572,13 +1906,15 @@ func Test_createVethPairContext_run(t *testing.T) {
 		linkSetNsFdCalls  []linkSetNsFdCall
 		procSysSetCalls   []procSysSetCall
 		nsFDCalls         []nsFDCall
+		ruleAddCalls      []ruleAddCall
 	}
 	type args struct {
-		contVethName string
-		hostVethName string
-		v4Addr       *net.IPNet
-		v6Addr       *net.IPNet
-		mtu          int
+		contVethName  string
+		hostVethName  string
+		v4Addr        *net.IPNet
+		v6Addr        *net.IPNet
+		mtu           int
+		podRouteTable int
 	}
 	tests := []struct {
 		name    string
@@ -1689,6 +2025,130 @@ func Test_createVethPairContext_run(t *testing.T) {
 				mtu:    9001,
 			},
 		},
+		{
+			name: "successfully created vethPair for secondary interface of ipv4 pods",
+			fields: fields{
+				linkByNameCalls: []linkByNameCall{
+					{
+						linkName: "eni8ea2c11fe35",
+						link:     hostVethWithIndex9,
+					},
+					{
+						linkName: "eth1",
+						link:     contVethWithIndex1,
+					},
+				},
+				linkAddCalls: []linkAddCall{
+					{
+						link: &netlink.Veth{
+							LinkAttrs: netlink.LinkAttrs{
+								Name:  "eth1",
+								Flags: net.FlagUp,
+								MTU:   9001,
+							},
+							PeerName: "eni8ea2c11fe35",
+						},
+					},
+				},
+				linkSetupCalls: []linkSetupCall{
+					{
+						link: hostVethWithIndex9,
+					},
+					{
+						link: contVethWithIndex1,
+					},
+				},
+				routeReplaceCalls: []routeReplaceCall{
+					{
+						route: &netlink.Route{
+							LinkIndex: contVethWithIndex1.Attrs().Index,
+							Scope:     netlink.SCOPE_LINK,
+							Dst: &net.IPNet{
+								IP:   net.IPv4(169, 254, 1, 1),
+								Mask: net.CIDRMask(32, 32),
+							},
+							Table: 101,
+						},
+					},
+				},
+				routeAddCalls: []routeAddCall{
+					{
+						route: &netlink.Route{
+							LinkIndex: contVethWithIndex1.Attrs().Index,
+							Scope:     netlink.SCOPE_UNIVERSE,
+							Dst: &net.IPNet{
+								IP:   net.IPv4zero,
+								Mask: net.CIDRMask(0, 32),
+							},
+							Gw:    net.IPv4(169, 254, 1, 1),
+							Table: 101,
+						},
+					},
+				},
+				addrAddCalls: []addrAddCall{
+					{
+						link: contVethWithIndex1,
+						addr: &netlink.Addr{
+							IPNet: &net.IPNet{
+								IP:   net.ParseIP("192.168.120.2"),
+								Mask: net.CIDRMask(32, 32),
+							},
+						},
+					},
+				},
+				ruleAddCalls: []ruleAddCall{
+					{
+						rule: &netlink.Rule{
+							Src: &net.IPNet{
+								IP:   net.ParseIP("192.168.120.2"),
+								Mask: net.CIDRMask(32, 32),
+							},
+							Table:             101,
+							Priority:          1536,
+							Goto:              -1,
+							Flow:              -1,
+							SuppressIfgroup:   -1,
+							SuppressPrefixlen: -1,
+							Mark:              -1,
+							Mask:              -1,
+						},
+					},
+				},
+				neighAddCalls: []neighAddCall{
+					{
+						neigh: &netlink.Neigh{
+							LinkIndex:    contVethWithIndex1.Attrs().Index,
+							State:        netlink.NUD_PERMANENT,
+							IP:           net.IPv4(169, 254, 1, 1),
+							HardwareAddr: hostVethWithIndex9.Attrs().HardwareAddr,
+						},
+					},
+				},
+				linkSetNsFdCalls: []linkSetNsFdCall{
+					{
+						link: hostVethWithIndex9,
+						fd:   3,
+					},
+				},
+				procSysSetCalls: []procSysSetCall{},
+				nsFDCalls: []nsFDCall{
+					{
+						fd: uintptr(3),
+					},
+				},
+			},
+			args: args{
+				contVethName: "eth1",
+				hostVethName: "eni8ea2c11fe35",
+				v4Addr: &net.IPNet{
+					IP:   net.ParseIP("192.168.120.2"),
+					Mask: net.CIDRMask(32, 32),
+				},
+				v6Addr:        nil,
+				mtu:           9001,
+				podRouteTable: 101,
+			},
+		},
 		{
 			name: "successfully created vethPair for ipv6 pods",
 			fields: fields{
@@ -2677,6 +3137,10 @@ func Test_createVethPairContext_run(t *testing.T) {
 			for _, call := range tt.fields.neighAddCalls {
 				netLink.EXPECT().NeighAdd(call.neigh).Return(call.err)
 			}
+			netLink.EXPECT().NewRule().DoAndReturn(func() *netlink.Rule { return netlink.NewRule() }).AnyTimes()
+			for _, call := range tt.fields.ruleAddCalls {
+				netLink.EXPECT().RuleAdd(call.rule).Return(call.err)
+			}
 			for _, call := range tt.fields.linkSetNsFdCalls {
 				netLink.EXPECT().LinkSetNsFd(call.link, call.fd).Return(call.err)
 			}
@@ -2692,13 +3156,14 @@ func Test_createVethPairContext_run(t *testing.T) {
 			}
 
 			createVethContext := &createVethPairContext{
-				contVethName: tt.args.contVethName,
-				hostVethName: tt.args.hostVethName,
-				v4Addr:       tt.args.v4Addr,
-				v6Addr:       tt.args.v6Addr,
-				mtu:          tt.args.mtu,
-				netLink:      netLink,
-				procSys:      procSys,
+				contVethName:  tt.args.contVethName,
+				hostVethName:  tt.args.hostVethName,
+				v4Addr:        tt.args.v4Addr,
+				v6Addr:        tt.args.v6Addr,
+				mtu:           tt.args.mtu,
+				netLink:       netLink,
+				procSys:       procSys,
+				podRouteTable: tt.args.podRouteTable,
 			}
 			err := createVethContext.run(hostNS)
 			if tt.wantErr != nil {
@@ -3108,7 +3573,7 @@ func Test_linuxNetwork_setupVeth(t *testing.T) {
 				ns:      ns,
 				procSys: procSys,
 			}
-			got, err := n.setupVeth(tt.args.hostVethName, tt.args.contVethName, tt.args.netnsPath, nil, nil, tt.args.mtu, testLogger)
+			got, err := n.setupVeth(tt.args.hostVethName, tt.args.contVethName, tt.args.netnsPath, nil, nil, tt.args.mtu, 0, testLogger)
 			if tt.wantErr != nil {
 				assert.EqualError(t, err, tt.wantErr.Error())
 			} else {
@@ -3428,7 +3893,7 @@ func Test_linuxNetwork_setupVlan(t *testing.T) {
 			n := &linuxNetwork{
 				netLink: netLink,
 			}
-			got, err := n.setupVlan(tt.args.vlanID, tt.args.eniMAC, tt.args.subnetGW, tt.args.parentIfIndex, tt.args.rtTable, testLogger)
+			got, err := n.setupVlan(networkutils.NewNetlinkTransaction(netLink), tt.args.vlanID, tt.args.eniMAC, tt.args.subnetGW, tt.args.parentIfIndex, tt.args.rtTable, testLogger)
 			if tt.wantErr != nil {
 				assert.EqualError(t, err, tt.wantErr.Error())
 			} else {
@@ -3797,7 +4262,7 @@ func Test_linuxNetwork_setupIPBasedContainerRouteRules(t *testing.T) {
 			n := &linuxNetwork{
 				netLink: netLink,
 			}
-			err := n.setupIPBasedContainerRouteRules(hostVeth, tt.args.containerAddr, tt.args.rtTable, testLogger)
+			err := n.setupIPBasedContainerRouteRules(networkutils.NewNetlinkTransaction(netLink), hostVeth, tt.args.containerAddr, tt.args.rtTable, testLogger)
 			if tt.wantErr != nil {
 				assert.EqualError(t, err, tt.wantErr.Error())
 			} else {
@@ -4232,7 +4697,7 @@ func Test_linuxNetwork_setupIIFBasedContainerRouteRules(t *testing.T) {
 			n := &linuxNetwork{
 				netLink: netLink,
 			}
-			err := n.setupIIFBasedContainerRouteRules(hostVeth, tt.args.containerAddr, hostVlan, tt.args.rtTable, testLogger)
+			err := n.setupIIFBasedContainerRouteRules(networkutils.NewNetlinkTransaction(netLink), hostVeth, tt.args.containerAddr, hostVlan, tt.args.rtTable, testLogger)
 			if tt.wantErr != nil {
 				assert.EqualError(t, err, tt.wantErr.Error())
 			} else {
@@ -0,0 +1,66 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package driver
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/networkutils"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
+)
+
+// EgressRoute sends the traffic of a pod to Dst through RouteTable, the route table of an ENI or the main table
+type EgressRoute struct {
+	Dst        *net.IPNet
+	RouteTable int
+}
+
+// SetupPodEgressRoutes adds a rule for each of egressRoutes looking up the traffic from containerAddr to its destination
+// in its route table. The rules come before the rule keeping the traffic to outside the VPC on the primary ENI and the
+// rule through the route table of the ENI of the pod IP, and after the rules to the pods. They are all rolled back if
+// one fails.
+func (n *linuxNetwork) SetupPodEgressRoutes(containerAddr *net.IPNet, egressRoutes []EgressRoute, log logger.Logger) error {
+	log.Debugf("SetupPodEgressRoutes: containerAddr=%s, egressRoutes=%v", containerAddr.String(), egressRoutes)
+
+	tx := networkutils.NewNetlinkTransaction(n.netLink)
+	for _, route := range egressRoutes {
+		egressRule := n.netLink.NewRule()
+		egressRule.Src = containerAddr
+		egressRule.Dst = route.Dst
+		egressRule.Priority = egressRulePriority
+		egressRule.Table = route.RouteTable
+		if err := tx.RuleAdd(egressRule); err != nil {
+			rollback(tx, log)
+			return errors.Wrapf(err, "SetupPodEgressRoutes: failed to setup egress rule, containerAddr=%s, dst=%s, rtTable=%v",
+				containerAddr.String(), route.Dst.String(), route.RouteTable)
+		}
+		log.Debugf("Successfully setup egress rule, containerAddr=%s, dst=%s, rtTable=%v",
+			containerAddr.String(), route.Dst.String(), route.RouteTable)
+	}
+	return nil
+}
+
+// teardownPodEgressRoutes deletes the rules added by SetupPodEgressRoutes for containerAddr, whatever their destination
+func (n *linuxNetwork) teardownPodEgressRoutes(containerAddr *net.IPNet, log logger.Logger) error {
+	egressRule := n.netLink.NewRule()
+	egressRule.Src = containerAddr
+	egressRule.Priority = egressRulePriority
+	if err := netLinkRuleDelAll(n.netLink, egressRule); err != nil {
+		return errors.Wrapf(err, "failed to delete egress rules, containerAddr=%s", containerAddr.String())
+	}
+	log.Debugf("Successfully deleted egress rules, containerAddr=%s", containerAddr.String())
+	return nil
+}
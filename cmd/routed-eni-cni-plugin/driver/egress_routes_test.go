@@ -0,0 +1,67 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package driver
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	mock_netlinkwrapper "github.com/aws/amazon-vpc-cni-k8s/pkg/netlinkwrapper/mocks"
+)
+
+func TestSetupPodEgressRoutes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	containerAddr := &net.IPNet{IP: net.ParseIP("192.168.100.42"), Mask: net.CIDRMask(32, 32)}
+	_, onPrem, _ := net.ParseCIDR("10.0.0.0/8")
+	_, partner, _ := net.ParseCIDR("203.0.113.0/24")
+	egressRoutes := []EgressRoute{
+		{Dst: onPrem, RouteTable: unix.RT_TABLE_MAIN},
+		{Dst: partner, RouteTable: 4},
+	}
+	egressRule := func(route EgressRoute) *netlink.Rule {
+		rule := netlink.NewRule()
+		rule.Src = containerAddr
+		rule.Dst = route.Dst
+		rule.Priority = egressRulePriority
+		rule.Table = route.RouteTable
+		return rule
+	}
+
+	netLink := mock_netlinkwrapper.NewMockNetLink(ctrl)
+	netLink.EXPECT().NewRule().DoAndReturn(func() *netlink.Rule { return netlink.NewRule() }).AnyTimes()
+	n := &linuxNetwork{netLink: netLink}
+
+	// A rule that exists already is kept
+	netLink.EXPECT().RuleAdd(egressRule(egressRoutes[0])).Return(syscall.EEXIST)
+	netLink.EXPECT().RuleAdd(egressRule(egressRoutes[1])).Return(nil)
+	assert.NoError(t, n.SetupPodEgressRoutes(containerAddr, egressRoutes, testLogger))
+
+	// The rules added before a failure are deleted
+	gomock.InOrder(
+		netLink.EXPECT().RuleAdd(egressRule(egressRoutes[0])).Return(nil),
+		netLink.EXPECT().RuleAdd(egressRule(egressRoutes[1])).Return(errors.New("some error")),
+		netLink.EXPECT().RuleDel(egressRule(egressRoutes[0])).Return(nil),
+	)
+	err := n.SetupPodEgressRoutes(containerAddr, egressRoutes, testLogger)
+	assert.EqualError(t, err, "SetupPodEgressRoutes: failed to setup egress rule, containerAddr=192.168.100.42/32, dst=203.0.113.0/24, rtTable=4: some error")
+}
@@ -22,6 +22,7 @@ import (
 	net "net"
 	reflect "reflect"
 
+	driver "github.com/aws/amazon-vpc-cni-k8s/cmd/routed-eni-cni-plugin/driver"
 	sgpp "github.com/aws/amazon-vpc-cni-k8s/pkg/sgpp"
 	logger "github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
 	gomock "github.com/golang/mock/gomock"
@@ -50,6 +51,34 @@ func (m *MockNetworkAPIs) EXPECT() *MockNetworkAPIsMockRecorder {
 	return m.recorder
 }
 
+// CheckDedicatedENIPodNetwork mocks base method
+func (m *MockNetworkAPIs) CheckDedicatedENIPodNetwork(arg0, arg1 string, arg2 *net.IPNet, arg3 logger.Logger) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckDedicatedENIPodNetwork", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckDedicatedENIPodNetwork indicates an expected call of CheckDedicatedENIPodNetwork
+func (mr *MockNetworkAPIsMockRecorder) CheckDedicatedENIPodNetwork(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckDedicatedENIPodNetwork", reflect.TypeOf((*MockNetworkAPIs)(nil).CheckDedicatedENIPodNetwork), arg0, arg1, arg2, arg3)
+}
+
+// CheckPodNetwork mocks base method
+func (m *MockNetworkAPIs) CheckPodNetwork(arg0, arg1, arg2 string, arg3 *net.IPNet, arg4 logger.Logger) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckPodNetwork", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckPodNetwork indicates an expected call of CheckPodNetwork
+func (mr *MockNetworkAPIsMockRecorder) CheckPodNetwork(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckPodNetwork", reflect.TypeOf((*MockNetworkAPIs)(nil).CheckPodNetwork), arg0, arg1, arg2, arg3, arg4)
+}
+
 // SetupBranchENIPodNetwork mocks base method
 func (m *MockNetworkAPIs) SetupBranchENIPodNetwork(arg0, arg1, arg2 string, arg3, arg4 *net.IPNet, arg5 int, arg6, arg7 string, arg8, arg9 int, arg10 sgpp.EnforcingMode, arg11 logger.Logger) error {
 	m.ctrl.T.Helper()
@@ -64,6 +93,62 @@ func (mr *MockNetworkAPIsMockRecorder) SetupBranchENIPodNetwork(arg0, arg1, arg2
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetupBranchENIPodNetwork", reflect.TypeOf((*MockNetworkAPIs)(nil).SetupBranchENIPodNetwork), arg0, arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9, arg10, arg11)
 }
 
+// SetupBranchENIPodSecondaryNetwork mocks base method
+func (m *MockNetworkAPIs) SetupBranchENIPodSecondaryNetwork(arg0, arg1, arg2 string, arg3 *net.IPNet, arg4, arg5 int, arg6, arg7 string, arg8, arg9 int, arg10 sgpp.EnforcingMode, arg11 logger.Logger) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetupBranchENIPodSecondaryNetwork", arg0, arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9, arg10, arg11)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetupBranchENIPodSecondaryNetwork indicates an expected call of SetupBranchENIPodSecondaryNetwork
+func (mr *MockNetworkAPIsMockRecorder) SetupBranchENIPodSecondaryNetwork(arg0, arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9, arg10, arg11 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetupBranchENIPodSecondaryNetwork", reflect.TypeOf((*MockNetworkAPIs)(nil).SetupBranchENIPodSecondaryNetwork), arg0, arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9, arg10, arg11)
+}
+
+// SetupDedicatedENIPodNetwork mocks base method
+func (m *MockNetworkAPIs) SetupDedicatedENIPodNetwork(arg0, arg1 string, arg2 *net.IPNet, arg3, arg4 string, arg5 int, arg6 logger.Logger) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetupDedicatedENIPodNetwork", arg0, arg1, arg2, arg3, arg4, arg5, arg6)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetupDedicatedENIPodNetwork indicates an expected call of SetupDedicatedENIPodNetwork
+func (mr *MockNetworkAPIsMockRecorder) SetupDedicatedENIPodNetwork(arg0, arg1, arg2, arg3, arg4, arg5, arg6 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetupDedicatedENIPodNetwork", reflect.TypeOf((*MockNetworkAPIs)(nil).SetupDedicatedENIPodNetwork), arg0, arg1, arg2, arg3, arg4, arg5, arg6)
+}
+
+// SetupPodEgressRoutes mocks base method
+func (m *MockNetworkAPIs) SetupPodEgressRoutes(arg0 *net.IPNet, arg1 []driver.EgressRoute, arg2 logger.Logger) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetupPodEgressRoutes", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetupPodEgressRoutes indicates an expected call of SetupPodEgressRoutes
+func (mr *MockNetworkAPIsMockRecorder) SetupPodEgressRoutes(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetupPodEgressRoutes", reflect.TypeOf((*MockNetworkAPIs)(nil).SetupPodEgressRoutes), arg0, arg1, arg2)
+}
+
+// SetupPodMulticast mocks base method
+func (m *MockNetworkAPIs) SetupPodMulticast(arg0, arg1, arg2 string, arg3 *net.IPNet, arg4 logger.Logger) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetupPodMulticast", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetupPodMulticast indicates an expected call of SetupPodMulticast
+func (mr *MockNetworkAPIsMockRecorder) SetupPodMulticast(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetupPodMulticast", reflect.TypeOf((*MockNetworkAPIs)(nil).SetupPodMulticast), arg0, arg1, arg2, arg3, arg4)
+}
+
 // SetupPodNetwork mocks base method
 func (m *MockNetworkAPIs) SetupPodNetwork(arg0, arg1, arg2 string, arg3, arg4 *net.IPNet, arg5, arg6 int, arg7 logger.Logger) error {
 	m.ctrl.T.Helper()
@@ -78,6 +163,34 @@ func (mr *MockNetworkAPIsMockRecorder) SetupPodNetwork(arg0, arg1, arg2, arg3, a
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetupPodNetwork", reflect.TypeOf((*MockNetworkAPIs)(nil).SetupPodNetwork), arg0, arg1, arg2, arg3, arg4, arg5, arg6, arg7)
 }
 
+// SetupPodVMNetwork mocks base method
+func (m *MockNetworkAPIs) SetupPodVMNetwork(arg0, arg1, arg2, arg3 string, arg4 int, arg5 logger.Logger) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetupPodVMNetwork", arg0, arg1, arg2, arg3, arg4, arg5)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetupPodVMNetwork indicates an expected call of SetupPodVMNetwork
+func (mr *MockNetworkAPIsMockRecorder) SetupPodVMNetwork(arg0, arg1, arg2, arg3, arg4, arg5 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetupPodVMNetwork", reflect.TypeOf((*MockNetworkAPIs)(nil).SetupPodVMNetwork), arg0, arg1, arg2, arg3, arg4, arg5)
+}
+
+// SetupWarmVethPodNetwork mocks base method
+func (m *MockNetworkAPIs) SetupWarmVethPodNetwork(arg0, arg1, arg2, arg3, arg4 string, arg5 *net.IPNet, arg6 int, arg7 logger.Logger) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetupWarmVethPodNetwork", arg0, arg1, arg2, arg3, arg4, arg5, arg6, arg7)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetupWarmVethPodNetwork indicates an expected call of SetupWarmVethPodNetwork
+func (mr *MockNetworkAPIsMockRecorder) SetupWarmVethPodNetwork(arg0, arg1, arg2, arg3, arg4, arg5, arg6, arg7 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetupWarmVethPodNetwork", reflect.TypeOf((*MockNetworkAPIs)(nil).SetupWarmVethPodNetwork), arg0, arg1, arg2, arg3, arg4, arg5, arg6, arg7)
+}
+
 // TeardownBranchENIPodNetwork mocks base method
 func (m *MockNetworkAPIs) TeardownBranchENIPodNetwork(arg0 *net.IPNet, arg1 int, arg2 sgpp.EnforcingMode, arg3 logger.Logger) error {
 	m.ctrl.T.Helper()
@@ -92,6 +205,20 @@ func (mr *MockNetworkAPIsMockRecorder) TeardownBranchENIPodNetwork(arg0, arg1, a
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TeardownBranchENIPodNetwork", reflect.TypeOf((*MockNetworkAPIs)(nil).TeardownBranchENIPodNetwork), arg0, arg1, arg2, arg3)
 }
 
+// TeardownDedicatedENIPodNetwork mocks base method
+func (m *MockNetworkAPIs) TeardownDedicatedENIPodNetwork(arg0, arg1 string, arg2 logger.Logger) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TeardownDedicatedENIPodNetwork", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TeardownDedicatedENIPodNetwork indicates an expected call of TeardownDedicatedENIPodNetwork
+func (mr *MockNetworkAPIsMockRecorder) TeardownDedicatedENIPodNetwork(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TeardownDedicatedENIPodNetwork", reflect.TypeOf((*MockNetworkAPIs)(nil).TeardownDedicatedENIPodNetwork), arg0, arg1, arg2)
+}
+
 // TeardownPodNetwork mocks base method
 func (m *MockNetworkAPIs) TeardownPodNetwork(arg0 *net.IPNet, arg1 int, arg2 logger.Logger) error {
 	m.ctrl.T.Helper()
@@ -105,3 +232,17 @@ func (mr *MockNetworkAPIsMockRecorder) TeardownPodNetwork(arg0, arg1, arg2 inter
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TeardownPodNetwork", reflect.TypeOf((*MockNetworkAPIs)(nil).TeardownPodNetwork), arg0, arg1, arg2)
 }
+
+// TeardownStalePodNetwork mocks base method
+func (m *MockNetworkAPIs) TeardownStalePodNetwork(arg0 *net.IPNet, arg1 string, arg2 logger.Logger) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TeardownStalePodNetwork", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TeardownStalePodNetwork indicates an expected call of TeardownStalePodNetwork
+func (mr *MockNetworkAPIsMockRecorder) TeardownStalePodNetwork(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TeardownStalePodNetwork", reflect.TypeOf((*MockNetworkAPIs)(nil).TeardownStalePodNetwork), arg0, arg1, arg2)
+}
@@ -0,0 +1,65 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package driver
+
+import (
+	"net"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
+)
+
+// multicastIPv4Net is the IPv4 multicast range, 224.0.0.0/4
+var multicastIPv4Net = &net.IPNet{IP: net.IPv4(224, 0, 0, 0), Mask: net.CIDRMask(4, 32)}
+
+// SetupPodMulticast enables multicast on both ends of the veth pair set up by SetupPodNetwork. Inside the pod, IPv4
+// multicast is routed straight out of the veth instead of through the dummy next hop, while IPv6 gets its ff00::/8
+// route from the kernel once the link is multicast capable. Broadcast needs no route, since the veth already has
+// IFF_BROADCAST.
+func (n *linuxNetwork) SetupPodMulticast(hostVethName string, contVethName string, netnsPath string, v4Addr *net.IPNet,
+	log logger.Logger) error {
+	log.Debugf("SetupPodMulticast: hostVethName=%s, contVethName=%s, netnsPath=%s, v4Addr=%v",
+		hostVethName, contVethName, netnsPath, v4Addr)
+
+	hostVeth, err := n.netLink.LinkByName(hostVethName)
+	if err != nil {
+		return errors.Wrapf(err, "SetupPodMulticast: failed to find host veth %s", hostVethName)
+	}
+	if err := n.netLink.LinkSetMulticastOn(hostVeth); err != nil {
+		return errors.Wrapf(err, "SetupPodMulticast: failed to enable multicast on %s", hostVethName)
+	}
+
+	err = n.ns.WithNetNSPath(netnsPath, func(ns.NetNS) error {
+		contVeth, err := n.netLink.LinkByName(contVethName)
+		if err != nil {
+			return errors.Wrapf(err, "failed to find %s", contVethName)
+		}
+		if err := n.netLink.LinkSetMulticastOn(contVeth); err != nil {
+			return errors.Wrapf(err, "failed to enable multicast on %s", contVethName)
+		}
+		if v4Addr == nil {
+			return nil
+		}
+		err = n.netLink.RouteReplace(&netlink.Route{
+			LinkIndex: contVeth.Attrs().Index,
+			Scope:     netlink.SCOPE_LINK,
+			Dst:       multicastIPv4Net,
+		})
+		return errors.Wrap(err, "failed to add multicast route")
+	})
+	return errors.Wrap(err, "SetupPodMulticast")
+}
@@ -0,0 +1,74 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package driver
+
+import (
+	"net"
+	"testing"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+
+	mock_netlinkwrapper "github.com/aws/amazon-vpc-cni-k8s/pkg/netlinkwrapper/mocks"
+	mock_nswrapper "github.com/aws/amazon-vpc-cni-k8s/pkg/nswrapper/mocks"
+)
+
+func TestSetupPodMulticast(t *testing.T) {
+	hostVeth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "eni8ea2c11fe35", Index: 3}}
+	contVeth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "eth0", Index: 1}}
+	v4Addr := &net.IPNet{IP: net.ParseIP("192.168.100.42"), Mask: net.CIDRMask(32, 32)}
+
+	tests := []struct {
+		name   string
+		v4Addr *net.IPNet
+	}{
+		{name: "IPv4", v4Addr: v4Addr},
+		// IPv6 gets its multicast route from the kernel
+		{name: "IPv6"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			netLink := mock_netlinkwrapper.NewMockNetLink(ctrl)
+			nsWrapper := mock_nswrapper.NewMockNS(ctrl)
+			nsWrapper.EXPECT().WithNetNSPath("/proc/42/ns/net", gomock.Any()).DoAndReturn(
+				func(nspath string, toRun func(ns.NetNS) error) error {
+					return toRun(nil)
+				})
+
+			calls := []*gomock.Call{
+				netLink.EXPECT().LinkByName("eni8ea2c11fe35").Return(hostVeth, nil),
+				netLink.EXPECT().LinkSetMulticastOn(hostVeth).Return(nil),
+				netLink.EXPECT().LinkByName("eth0").Return(contVeth, nil),
+				netLink.EXPECT().LinkSetMulticastOn(contVeth).Return(nil),
+			}
+			if tt.v4Addr != nil {
+				calls = append(calls, netLink.EXPECT().RouteReplace(&netlink.Route{
+					LinkIndex: 1,
+					Scope:     netlink.SCOPE_LINK,
+					Dst:       &net.IPNet{IP: net.IPv4(224, 0, 0, 0), Mask: net.CIDRMask(4, 32)},
+				}).Return(nil))
+			}
+			gomock.InOrder(calls...)
+
+			n := &linuxNetwork{netLink: netLink, ns: nsWrapper}
+			err := n.SetupPodMulticast("eni8ea2c11fe35", "eth0", "/proc/42/ns/net", tt.v4Addr, testLogger)
+			assert.NoError(t, err)
+		})
+	}
+}
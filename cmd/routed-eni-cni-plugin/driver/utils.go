@@ -4,6 +4,8 @@ import (
 	"syscall"
 
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/netlinkwrapper"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/networkutils"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
 	"github.com/vishvananda/netlink"
 )
 
@@ -33,3 +35,10 @@ func isRuleExistsError(err error) bool {
 	}
 	return false
 }
+
+// rollback undoes the netlink changes of a failed pod network setup, so that CHECK and DEL don't find them half done
+func rollback(tx *networkutils.NetlinkTransaction, log logger.Logger) {
+	if err := tx.Rollback(); err != nil {
+		log.Warnf("Failed to roll back the partial pod network setup: %v", err)
+	}
+}
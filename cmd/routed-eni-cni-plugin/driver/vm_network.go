@@ -0,0 +1,97 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package driver
+
+import (
+	"fmt"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
+)
+
+// SetupPodVMNetwork adds a tap device next to the pod interface contIfName for the VM-based runtimes, and redirects
+// every frame between the two with tc, so that the guest owns the pod address while the pod interface keeps its routes
+// on the host. The ENI of a dedicated ENI pod is the pod interface, so it works the same. The guest has none of the
+// static neighbors of the pod namespace, so the host veth, if any, answers its ARP requests for the gateway instead.
+func (n *linuxNetwork) SetupPodVMNetwork(hostVethName string, contIfName string, tapName string, netnsPath string, mtu int,
+	log logger.Logger) error {
+	log.Debugf("SetupPodVMNetwork: hostVethName=%s, contIfName=%s, tapName=%s, netnsPath=%s, mtu=%d",
+		hostVethName, contIfName, tapName, netnsPath, mtu)
+
+	if hostVethName != "" {
+		if err := n.procSys.Set(fmt.Sprintf("net/ipv4/conf/%s/proxy_arp", hostVethName), "1"); err != nil {
+			return errors.Wrapf(err, "SetupPodVMNetwork: failed to enable proxy ARP on %s", hostVethName)
+		}
+	}
+
+	err := n.ns.WithNetNSPath(netnsPath, func(ns.NetNS) error {
+		contIf, err := n.netLink.LinkByName(contIfName)
+		if err != nil {
+			return errors.Wrapf(err, "failed to find %s", contIfName)
+		}
+		tap := &netlink.Tuntap{
+			LinkAttrs: netlink.LinkAttrs{Name: tapName, MTU: mtu},
+			Mode:      netlink.TUNTAP_MODE_TAP,
+			Flags:     netlink.TUNTAP_MULTI_QUEUE_DEFAULTS | netlink.TUNTAP_VNET_HDR,
+		}
+		if err := n.netLink.LinkAdd(tap); err != nil {
+			return errors.Wrapf(err, "failed to add tap device %s", tapName)
+		}
+		tapLink, err := n.netLink.LinkByName(tapName)
+		if err != nil {
+			return errors.Wrapf(err, "failed to find tap device %s", tapName)
+		}
+		if err := n.netLink.LinkSetUp(tapLink); err != nil {
+			return errors.Wrapf(err, "failed to set tap device %s up", tapName)
+		}
+		if err := n.redirectIngress(contIf, tapLink); err != nil {
+			return errors.Wrapf(err, "failed to redirect %s to %s", contIfName, tapName)
+		}
+		return errors.Wrapf(n.redirectIngress(tapLink, contIf), "failed to redirect %s to %s", tapName, contIfName)
+	})
+	return errors.Wrap(err, "SetupPodVMNetwork")
+}
+
+// redirectIngress sends every frame received on from out of to, like `tc filter add dev from parent ffff: u32 match
+// u8 0 0 action mirred egress redirect dev to`
+func (n *linuxNetwork) redirectIngress(from netlink.Link, to netlink.Link) error {
+	ingress := &netlink.Ingress{
+		QdiscAttrs: netlink.QdiscAttrs{
+			LinkIndex: from.Attrs().Index,
+			Handle:    netlink.MakeHandle(0xffff, 0),
+			Parent:    netlink.HANDLE_INGRESS,
+		},
+	}
+	if err := n.netLink.QdiscAdd(ingress); err != nil {
+		return errors.Wrap(err, "failed to add ingress qdisc")
+	}
+	filter := &netlink.U32{
+		FilterAttrs: netlink.FilterAttrs{
+			LinkIndex: from.Attrs().Index,
+			Parent:    ingress.Handle,
+			Priority:  1,
+			Protocol:  unix.ETH_P_ALL,
+		},
+		Sel: &netlink.TcU32Sel{
+			Flags: netlink.TC_U32_TERMINAL,
+			Keys:  []netlink.TcU32Key{{}},
+		},
+		Actions: []netlink.Action{netlink.NewMirredAction(to.Attrs().Index)},
+	}
+	return errors.Wrap(n.netLink.FilterAdd(filter), "failed to add redirect filter")
+}
@@ -0,0 +1,85 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+
+	mock_netlinkwrapper "github.com/aws/amazon-vpc-cni-k8s/pkg/netlinkwrapper/mocks"
+	mock_nswrapper "github.com/aws/amazon-vpc-cni-k8s/pkg/nswrapper/mocks"
+	mock_procsyswrapper "github.com/aws/amazon-vpc-cni-k8s/pkg/procsyswrapper/mocks"
+)
+
+func TestSetupPodVMNetwork(t *testing.T) {
+	contVeth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "eth0", Index: 1}}
+	tap := &netlink.Tuntap{LinkAttrs: netlink.LinkAttrs{Name: "tap0", Index: 2}}
+
+	tests := []struct {
+		name         string
+		hostVethName string
+	}{
+		{name: "veth", hostVethName: "eni8ea2c11fe35"},
+		// A dedicated ENI pod has no host veth to answer ARP
+		{name: "dedicated ENI"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			netLink := mock_netlinkwrapper.NewMockNetLink(ctrl)
+			procSys := mock_procsyswrapper.NewMockProcSys(ctrl)
+			nsWrapper := mock_nswrapper.NewMockNS(ctrl)
+			nsWrapper.EXPECT().WithNetNSPath("/proc/42/ns/net", gomock.Any()).DoAndReturn(
+				func(nspath string, toRun func(ns.NetNS) error) error {
+					return toRun(nil)
+				})
+
+			if tt.hostVethName != "" {
+				procSys.EXPECT().Set("net/ipv4/conf/eni8ea2c11fe35/proxy_arp", "1").Return(nil)
+			}
+			var redirects []int
+			gomock.InOrder(
+				netLink.EXPECT().LinkByName("eth0").Return(contVeth, nil),
+				netLink.EXPECT().LinkAdd(gomock.Any()).DoAndReturn(func(link netlink.Link) error {
+					added := link.(*netlink.Tuntap)
+					assert.Equal(t, "tap0", added.Name)
+					assert.Equal(t, 9001, added.MTU)
+					assert.Equal(t, netlink.TUNTAP_MODE_TAP, added.Mode)
+					return nil
+				}),
+				netLink.EXPECT().LinkByName("tap0").Return(tap, nil),
+				netLink.EXPECT().LinkSetUp(tap).Return(nil),
+			)
+			netLink.EXPECT().QdiscAdd(gomock.Any()).Return(nil).Times(2)
+			netLink.EXPECT().FilterAdd(gomock.Any()).DoAndReturn(func(filter netlink.Filter) error {
+				u32 := filter.(*netlink.U32)
+				mirred := u32.Actions[0].(*netlink.MirredAction)
+				redirects = append(redirects, u32.LinkIndex, mirred.Ifindex)
+				return nil
+			}).Times(2)
+
+			n := &linuxNetwork{netLink: netLink, ns: nsWrapper, procSys: procSys}
+			err := n.SetupPodVMNetwork(tt.hostVethName, "eth0", "tap0", "/proc/42/ns/net", 9001, testLogger)
+			assert.NoError(t, err)
+			// eth0 to tap0, and back
+			assert.Equal(t, []int{1, 2, 2, 1}, redirects)
+		})
+	}
+}
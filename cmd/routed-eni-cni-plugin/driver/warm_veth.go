@@ -0,0 +1,132 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package driver
+
+import (
+	"net"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
+)
+
+// SetupWarmVethPodNetwork sets up the network of a pod with a veth pair pre-plumbed by ipamd for v4Addr. The host side
+// warmVeth already routes the pod IP and has its rules, so the peer warmPeer only has to be moved into the pod network
+// namespace and configured as contVethName, and the host side renamed to hostVethName.
+func (n *linuxNetwork) SetupWarmVethPodNetwork(warmVeth string, warmPeer string, hostVethName string, contVethName string,
+	netnsPath string, v4Addr *net.IPNet, mtu int, log logger.Logger) error {
+	log.Debugf("SetupWarmVethPodNetwork: warmVeth=%s, warmPeer=%s, hostVethName=%s, contVethName=%s, netnsPath=%s, v4Addr=%v, mtu=%d",
+		warmVeth, warmPeer, hostVethName, contVethName, netnsPath, v4Addr, mtu)
+
+	hostVeth, err := n.netLink.LinkByName(warmVeth)
+	if err != nil {
+		return errors.Wrapf(err, "SetupWarmVethPodNetwork: failed to find warm veth %s", warmVeth)
+	}
+	peer, err := n.netLink.LinkByName(warmPeer)
+	if err != nil {
+		return errors.Wrapf(err, "SetupWarmVethPodNetwork: failed to find warm veth peer %s", warmPeer)
+	}
+	// Clean up if hostVeth exists, as setupVeth does
+	if oldHostVeth, err := n.netLink.LinkByName(hostVethName); err == nil {
+		if err = n.netLink.LinkDel(oldHostVeth); err != nil {
+			return errors.Wrapf(err, "SetupWarmVethPodNetwork: failed to delete old hostVeth %s", hostVethName)
+		}
+		log.Debugf("Successfully deleted old hostVeth %s", hostVethName)
+	}
+
+	err = n.ns.WithNetNSPath(netnsPath, func(hostNS ns.NetNS) error {
+		err := hostNS.Do(func(podNS ns.NetNS) error {
+			return errors.Wrap(n.netLink.LinkSetNsFd(peer, int(podNS.Fd())), "failed to move the warm veth peer into the pod")
+		})
+		if err != nil {
+			return err
+		}
+		return n.setupWarmVethContainerSide(warmPeer, contVethName, v4Addr, hostVeth.Attrs().HardwareAddr, mtu)
+	})
+	if err != nil {
+		return errors.Wrap(err, "SetupWarmVethPodNetwork")
+	}
+
+	// Renaming needs the link down, which drops its routes, so the route to the pod is added back once it is up
+	if err := n.netLink.LinkSetDown(hostVeth); err != nil {
+		return errors.Wrapf(err, "SetupWarmVethPodNetwork: failed to set link %s down", warmVeth)
+	}
+	if err := n.netLink.LinkSetName(hostVeth, hostVethName); err != nil {
+		return errors.Wrapf(err, "SetupWarmVethPodNetwork: failed to rename %s to %s", warmVeth, hostVethName)
+	}
+	if err := n.netLink.LinkSetMTU(hostVeth, mtu); err != nil {
+		return errors.Wrapf(err, "SetupWarmVethPodNetwork: failed to set the MTU of %s", hostVethName)
+	}
+	if err := n.netLink.LinkSetUp(hostVeth); err != nil {
+		return errors.Wrapf(err, "SetupWarmVethPodNetwork: failed to set link %s up", hostVethName)
+	}
+	err = n.netLink.RouteReplace(&netlink.Route{
+		LinkIndex: hostVeth.Attrs().Index,
+		Scope:     netlink.SCOPE_LINK,
+		Dst:       v4Addr,
+		Table:     unix.RT_TABLE_MAIN,
+	})
+	return errors.Wrapf(err, "SetupWarmVethPodNetwork: failed to setup container route, containerAddr=%s, hostVeth=%s",
+		v4Addr, hostVethName)
+}
+
+// setupWarmVethContainerSide runs within the container's namespace, and configures the moved peer the way
+// createVethPairContext configures a new one
+func (n *linuxNetwork) setupWarmVethContainerSide(warmPeer string, contVethName string, v4Addr *net.IPNet,
+	hostVethMAC net.HardwareAddr, mtu int) error {
+	contVeth, err := n.netLink.LinkByName(warmPeer)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find link %s", warmPeer)
+	}
+	if err := n.netLink.LinkSetName(contVeth, contVethName); err != nil {
+		return errors.Wrapf(err, "failed to rename %s to %s", warmPeer, contVethName)
+	}
+	if err := n.netLink.LinkSetMTU(contVeth, mtu); err != nil {
+		return errors.Wrapf(err, "failed to set the MTU of %s", contVethName)
+	}
+	if err := n.netLink.LinkSetUp(contVeth); err != nil {
+		return errors.Wrapf(err, "failed to set link %s up", contVethName)
+	}
+
+	gw := net.IPv4(169, 254, 1, 1)
+	gwNet := &net.IPNet{IP: gw, Mask: net.CIDRMask(32, 32)}
+	if err := n.netLink.RouteReplace(&netlink.Route{
+		LinkIndex: contVeth.Attrs().Index,
+		Scope:     netlink.SCOPE_LINK,
+		Dst:       gwNet,
+	}); err != nil {
+		return errors.Wrap(err, "failed to add default gateway")
+	}
+	if err := n.netLink.RouteAdd(&netlink.Route{
+		LinkIndex: contVeth.Attrs().Index,
+		Scope:     netlink.SCOPE_UNIVERSE,
+		Dst:       &net.IPNet{IP: net.IPv4zero, Mask: net.CIDRMask(0, 32)},
+		Gw:        gw,
+	}); err != nil {
+		return errors.Wrap(err, "failed to add default route")
+	}
+	if err := n.netLink.AddrAdd(contVeth, &netlink.Addr{IPNet: v4Addr}); err != nil {
+		return errors.Wrapf(err, "failed to add IP addr to %s", contVethName)
+	}
+	err = n.netLink.NeighAdd(&netlink.Neigh{
+		LinkIndex:    contVeth.Attrs().Index,
+		State:        netlink.NUD_PERMANENT,
+		IP:           gw,
+		HardwareAddr: hostVethMAC,
+	})
+	return errors.Wrap(err, "failed to add static ARP")
+}
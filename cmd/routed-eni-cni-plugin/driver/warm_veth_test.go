@@ -0,0 +1,101 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package driver
+
+import (
+	"net"
+	"testing"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/cninswrapper/mock_ns"
+	mock_netlinkwrapper "github.com/aws/amazon-vpc-cni-k8s/pkg/netlinkwrapper/mocks"
+	mock_nswrapper "github.com/aws/amazon-vpc-cni-k8s/pkg/nswrapper/mocks"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
+)
+
+func TestSetupWarmVethPodNetwork(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mac, _ := net.ParseMAC("0e:00:00:00:00:01")
+	hostVeth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "warmveth3", Index: 12, HardwareAddr: mac}}
+	peer := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "warmpeer3", Index: 13}}
+	v4Addr := &net.IPNet{IP: net.ParseIP("10.0.0.42"), Mask: net.CIDRMask(32, 32)}
+	gw := net.IPv4(169, 254, 1, 1)
+
+	netLink := mock_netlinkwrapper.NewMockNetLink(ctrl)
+	nsWrapper := mock_nswrapper.NewMockNS(ctrl)
+	hostNS := mock_ns.NewMockNetNS(ctrl)
+	podNS := mock_ns.NewMockNetNS(ctrl)
+	nsWrapper.EXPECT().WithNetNSPath("/proc/42/ns/net", gomock.Any()).DoAndReturn(
+		func(nspath string, toRun func(ns.NetNS) error) error {
+			return toRun(hostNS)
+		})
+	hostNS.EXPECT().Do(gomock.Any()).DoAndReturn(func(toRun func(ns.NetNS) error) error {
+		return toRun(podNS)
+	})
+	podNS.EXPECT().Fd().Return(uintptr(42))
+
+	gomock.InOrder(
+		// In the host
+		netLink.EXPECT().LinkByName("warmveth3").Return(hostVeth, nil),
+		netLink.EXPECT().LinkByName("warmpeer3").Return(peer, nil),
+		netLink.EXPECT().LinkByName("eni8ea2c11fe35").Return(nil, netlink.LinkNotFoundError{}),
+		netLink.EXPECT().LinkSetNsFd(peer, 42).Return(nil),
+		// In the pod
+		netLink.EXPECT().LinkByName("warmpeer3").Return(peer, nil),
+		netLink.EXPECT().LinkSetName(peer, "eth0").Return(nil),
+		netLink.EXPECT().LinkSetMTU(peer, 9001).Return(nil),
+		netLink.EXPECT().LinkSetUp(peer).Return(nil),
+		netLink.EXPECT().RouteReplace(&netlink.Route{
+			LinkIndex: 13,
+			Scope:     netlink.SCOPE_LINK,
+			Dst:       &net.IPNet{IP: gw, Mask: net.CIDRMask(32, 32)},
+		}).Return(nil),
+		netLink.EXPECT().RouteAdd(&netlink.Route{
+			LinkIndex: 13,
+			Scope:     netlink.SCOPE_UNIVERSE,
+			Dst:       &net.IPNet{IP: net.IPv4zero, Mask: net.CIDRMask(0, 32)},
+			Gw:        gw,
+		}).Return(nil),
+		netLink.EXPECT().AddrAdd(peer, &netlink.Addr{IPNet: v4Addr}).Return(nil),
+		netLink.EXPECT().NeighAdd(&netlink.Neigh{
+			LinkIndex:    13,
+			State:        netlink.NUD_PERMANENT,
+			IP:           gw,
+			HardwareAddr: mac,
+		}).Return(nil),
+		// Back in the host
+		netLink.EXPECT().LinkSetDown(hostVeth).Return(nil),
+		netLink.EXPECT().LinkSetName(hostVeth, "eni8ea2c11fe35").Return(nil),
+		netLink.EXPECT().LinkSetMTU(hostVeth, 9001).Return(nil),
+		netLink.EXPECT().LinkSetUp(hostVeth).Return(nil),
+		netLink.EXPECT().RouteReplace(&netlink.Route{
+			LinkIndex: 12,
+			Scope:     netlink.SCOPE_LINK,
+			Dst:       v4Addr,
+			Table:     unix.RT_TABLE_MAIN,
+		}).Return(nil),
+	)
+
+	n := &linuxNetwork{netLink: netLink, ns: nsWrapper}
+	err := n.SetupWarmVethPodNetwork("warmveth3", "warmpeer3", "eni8ea2c11fe35", "eth0", "/proc/42/ns/net", v4Addr, 9001,
+		logger.Get())
+	assert.NoError(t, err)
+}
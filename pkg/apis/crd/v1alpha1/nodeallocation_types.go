@@ -0,0 +1,70 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NodeAllocationPod is the IP address of a pod
+type NodeAllocationPod struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	IP        string `json:"ip"`
+}
+
+// NodeAllocationENI is an ENI of the node, with its IPv4 and IPv6 CIDRs and the pods that have an IP from them
+type NodeAllocationENI struct {
+	ID           string              `json:"id"`
+	DeviceNumber int                 `json:"deviceNumber"`
+	Primary      bool                `json:"primary,omitempty"`
+	Trunk        bool                `json:"trunk,omitempty"`
+	CIDRs        []string            `json:"cidrs,omitempty"`
+	Pods         []NodeAllocationPod `json:"pods,omitempty"`
+}
+
+// NodeAllocationStatus defines the observed IP allocation state of a node
+type NodeAllocationStatus struct {
+	TotalIPs    int                 `json:"totalIPs"`
+	AssignedIPs int                 `json:"assignedIPs"`
+	ENIs        []NodeAllocationENI `json:"enis,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:subresource:status
+
+// NodeAllocation is the IP allocation state of the node of the same name, published by ipamd
+type NodeAllocation struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Status NodeAllocationStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// NodeAllocationList contains a list of NodeAllocation
+type NodeAllocationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NodeAllocation `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NodeAllocation{}, &NodeAllocationList{})
+}
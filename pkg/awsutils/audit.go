@@ -0,0 +1,50 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package awsutils
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/aws/request"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/audit"
+)
+
+// mutatingEC2Prefixes are the prefixes of the EC2 APIs that change something, which go to the audit log
+var mutatingEC2Prefixes = []string{"Assign", "Unassign", "Attach", "Detach", "Create", "Delete", "Modify"}
+
+func isMutatingEC2API(operation string) bool {
+	for _, prefix := range mutatingEC2Prefixes {
+		if strings.HasPrefix(operation, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordEC2Mutation adds the EC2 requests that change something to the audit log, once they completed, with the
+// correlation ID of their context or else their caller
+func recordEC2Mutation(r *request.Request) {
+	if !audit.Enabled() || !isMutatingEC2API(r.Operation.Name) {
+		return
+	}
+	// The parameters of the request on one line, without the ones not set
+	target := strings.Join(strings.Fields(awsutil.Prettify(r.Params)), " ")
+	correlationID := audit.CorrelationIDFromContext(r.Context())
+	if correlationID == "" {
+		correlationID = CallerFromContext(r.Context())
+	}
+	audit.Record(audit.CategoryEC2, r.Operation.Name, target, correlationID, r.Error)
+}
@@ -0,0 +1,57 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package awsutils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/audit"
+)
+
+func TestRecordEC2Mutation(t *testing.T) {
+	var buf bytes.Buffer
+	audit.SetLog(audit.NewLog(&buf, 10))
+	defer audit.SetLog(nil)
+
+	newRequest := func(ctx context.Context, name string, params interface{}) *request.Request {
+		r := &request.Request{Operation: &request.Operation{Name: name}, Params: params, HTTPRequest: &http.Request{}}
+		r.SetContext(ctx)
+		return r
+	}
+	recordEC2Mutation(newRequest(WithCaller(context.Background(), CallerScaleUp), "AssignPrivateIpAddresses",
+		&ec2.AssignPrivateIpAddressesInput{NetworkInterfaceId: aws.String("eni-1")}))
+	// Reads are not recorded
+	recordEC2Mutation(newRequest(context.Background(), "DescribeNetworkInterfaces", &ec2.DescribeNetworkInterfacesInput{}))
+	r := newRequest(audit.WithCorrelationID(context.Background(), "trace-1"), "DeleteNetworkInterface",
+		&ec2.DeleteNetworkInterfaceInput{NetworkInterfaceId: aws.String("eni-2")})
+	r.Error = errors.New("InvalidNetworkInterfaceID.NotFound")
+	recordEC2Mutation(r)
+
+	entries := audit.Tail(0)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "AssignPrivateIpAddresses", entries[0].Action)
+	assert.Equal(t, `{ NetworkInterfaceId: "eni-1" }`, entries[0].Target)
+	assert.Equal(t, CallerScaleUp, entries[0].CorrelationID)
+	assert.Equal(t, "trace-1", entries[1].CorrelationID)
+	assert.Equal(t, "InvalidNetworkInterfaceID.NotFound", entries[1].Error)
+}
@@ -18,22 +18,25 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"net"
+	"net/http"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils/awssession"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/ec2wrapper"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/eventrecorder"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/paths"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/retry"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
@@ -70,6 +73,8 @@ const (
 var (
 	// ErrENINotFound is an error when ENI is not found.
 	ErrENINotFound = errors.New("ENI is not found")
+	// ErrENINotOwned is returned when asked to free an ENI that the CNI of this cluster did not create
+	ErrENINotOwned = errors.New("ENI was not created by the CNI of this cluster")
 	// ErrAllSecondaryIPsNotFound is returned when not all secondary IPs on an ENI have been assigned
 	ErrAllSecondaryIPsNotFound = errors.New("All secondary IPs not found")
 	// ErrNoSecondaryIPsFound is returned when not all secondary IPs on an ENI have been assigned
@@ -111,43 +116,56 @@ var (
 // APIs defines interfaces calls for adding/getting/deleting ENIs/secondary IPs. The APIs are not thread-safe.
 type APIs interface {
 	// AllocENI creates an ENI and attaches it to the instance
-	AllocENI(useCustomCfg bool, sg []*string, subnet string) (eni string, err error)
+	AllocENI(ctx context.Context, useCustomCfg bool, sg []*string, subnet string) (eni string, err error)
 
 	// FreeENI detaches ENI interface and deletes it
-	FreeENI(eniName string) error
+	FreeENI(ctx context.Context, eniName string) error
 
 	// TagENI Tags ENI with current tags to contain expected tags.
-	TagENI(eniID string, currentTags map[string]string) error
+	TagENI(ctx context.Context, eniID string, currentTags map[string]string) error
+
+	// AddENITags adds tags to an ENI, replacing the value of the ones it already has
+	AddENITags(ctx context.Context, eniID string, tags map[string]string) error
 
 	// GetAttachedENIs retrieves eni information from instance metadata service
 	GetAttachedENIs() (eniList []ENIMetadata, err error)
 
 	// GetIPv4sFromEC2 returns the IPv4 addresses for a given ENI
-	GetIPv4sFromEC2(eniID string) (addrList []*ec2.NetworkInterfacePrivateIpAddress, err error)
+	GetIPv4sFromEC2(ctx context.Context, eniID string) (addrList []*ec2.NetworkInterfacePrivateIpAddress, err error)
 
 	// GetIPv4PrefixesFromEC2 returns the IPv4 prefixes for a given ENI
-	GetIPv4PrefixesFromEC2(eniID string) (addrList []*ec2.Ipv4PrefixSpecification, err error)
+	GetIPv4PrefixesFromEC2(ctx context.Context, eniID string) (addrList []*ec2.Ipv4PrefixSpecification, err error)
 
 	// GetIPv6PrefixesFromEC2 returns the IPv6 prefixes for a given ENI
-	GetIPv6PrefixesFromEC2(eniID string) (addrList []*ec2.Ipv6PrefixSpecification, err error)
+	GetIPv6PrefixesFromEC2(ctx context.Context, eniID string) (addrList []*ec2.Ipv6PrefixSpecification, err error)
 
 	// DescribeAllENIs calls EC2 and returns a fully populated DescribeAllENIsResult struct and an error
-	DescribeAllENIs() (DescribeAllENIsResult, error)
+	DescribeAllENIs(ctx context.Context) (DescribeAllENIsResult, error)
 
 	// AllocIPAddress allocates an IP address for an ENI
-	AllocIPAddress(eniID string) error
+	AllocIPAddress(ctx context.Context, eniID string) error
 
 	// AllocIPAddresses allocates numIPs IP addresses on a ENI
-	AllocIPAddresses(eniID string, numIPs int) (*ec2.AssignPrivateIpAddressesOutput, error)
+	AllocIPAddresses(ctx context.Context, eniID string, numIPs int) (*ec2.AssignPrivateIpAddressesOutput, error)
+
+	// AllocSecondaryIPAddresses allocates numIPs secondary IP addresses on a ENI, even with prefix delegation enabled
+	AllocSecondaryIPAddresses(ctx context.Context, eniID string, numIPs int) (*ec2.AssignPrivateIpAddressesOutput, error)
+
+	// AllocIPv4PrefixesFromReservations allocates numPrefixes prefixes on a ENI from the free blocks of the prefix CIDR
+	// reservations of its subnet with a tag
+	AllocIPv4PrefixesFromReservations(ctx context.Context, eniID string, numPrefixes int, tagKey, tagValue string) (*ec2.AssignPrivateIpAddressesOutput, error)
+
+	// AllocIPAddressesByIP assigns the given secondary IP addresses to an ENI
+	AllocIPAddressesByIP(ctx context.Context, eniID string, ips []string) error
 
 	// DeallocIPAddresses deallocates the list of IP addresses from a ENI
-	DeallocIPAddresses(eniID string, ips []string) error
+	DeallocIPAddresses(ctx context.Context, eniID string, ips []string) error
 
 	// DeallocPrefixAddresses deallocates the list of IP addresses from a ENI
-	DeallocPrefixAddresses(eniID string, ips []string) error
+	DeallocPrefixAddresses(ctx context.Context, eniID string, ips []string) error
 
 	//AllocIPv6Prefixes allocates IPv6 prefixes to the ENI passed in
-	AllocIPv6Prefixes(eniID string) ([]*string, error)
+	AllocIPv6Prefixes(ctx context.Context, eniID string) ([]*string, error)
 
 	// GetVPCIPv4CIDRs returns VPC's IPv4 CIDRs from instance metadata
 	GetVPCIPv4CIDRs() ([]string, error)
@@ -177,7 +195,7 @@ type APIs interface {
 	IsUnmanagedENI(eniID string) bool
 
 	// WaitForENIAndIPsAttached waits until the ENI has been attached and the secondary IPs have been added
-	WaitForENIAndIPsAttached(eni string, wantedSecondaryIPs int) (ENIMetadata, error)
+	WaitForENIAndIPsAttached(ctx context.Context, eni string, wantedSecondaryIPs int) (ENIMetadata, error)
 
 	//SetCNIunmanaged ENI
 	SetCNIUnmanagedENIs(eniID []string) error
@@ -188,8 +206,23 @@ type APIs interface {
 	//IsPrimaryENI
 	IsPrimaryENI(eniID string) bool
 
+	// IsEFAENI returns true if the ENI was reported as an EFA interface by the last DescribeAllENIs call
+	IsEFAENI(eniID string) bool
+
+	// GetEFAENIs returns the IDs of the EFA interfaces attached to the instance
+	GetEFAENIs() []string
+
 	//RefreshSGIDs
-	RefreshSGIDs(mac string) error
+	RefreshSGIDs(ctx context.Context, mac string) error
+
+	// GetSecurityGroups returns the security groups of the primary ENI, found by the last RefreshSGIDs
+	GetSecurityGroups() []string
+
+	// GetENISecurityGroups returns the security groups of the ENI with the MAC address from the instance metadata
+	GetENISecurityGroups(ctx context.Context, mac string) ([]string, error)
+
+	// SetENISecurityGroups replaces the security groups of an ENI
+	SetENISecurityGroups(ctx context.Context, eniID string, sgIDs []string) error
 
 	//GetInstanceHypervisorFamily returns the hypervisor family for the instance
 	GetInstanceHypervisorFamily() string
@@ -197,16 +230,57 @@ type APIs interface {
 	//GetInstanceType returns the EC2 instance type
 	GetInstanceType() string
 
+	// GetRegion returns the region of the instance
+	GetRegion() string
+
 	//Update cached prefix delegation flag
 	InitCachedPrefixDelegation(bool)
 
+	// InitCachedMultiCardENIs sets whether the ENIs on network cards other than the first one are used for pods
+	InitCachedMultiCardENIs(bool)
+
 	// GetInstanceID returns the instance ID
 	GetInstanceID() string
 
-	// FetchInstanceTypeLimits Verify if the InstanceNetworkingLimits has the ENI limits else make EC2 call to fill cache.
+	// CheckEC2Permissions issues dry-run EC2 calls and returns the actions that were denied
+	CheckEC2Permissions(checkENIProvisioning bool) map[string]error
+
+	// GetSubnetAvailableIPCount returns the number of free IP addresses in the subnet, the primary ENI's one if empty
+	GetSubnetAvailableIPCount(subnetID string) (int, error)
+
+	// GetSubnetPrefixAvailability estimates the /28 prefixes that can still be assigned from a subnet, the primary
+	// ENI's one if empty
+	GetSubnetPrefixAvailability(ctx context.Context, subnetID string) (*SubnetPrefixAvailability, error)
+
+	// GetMissingSecurityGroups returns the security groups which don't exist in the VPC
+	GetMissingSecurityGroups(sgIDs []string) ([]string, error)
+
+	// GetInstancePlacement returns the Availability Zone, Local Zone or Outpost of the instance
+	GetInstancePlacement(ctx context.Context) (Placement, error)
+
+	// CheckSubnetPlacement returns a *SubnetPlacementError if the ENIs of the instance can't be created in the subnet
+	CheckSubnetPlacement(ctx context.Context, subnetID string) error
+
+	// GetVPCDNSConfig returns the DNS configuration that the DHCP options set of the VPC gives to instances
+	GetVPCDNSConfig() (VPCDNSConfig, error)
+
+	// FetchInstanceTypeLimits looks up the ENI limits with EC2, falling back to the persisted or vendored limits.
 	FetchInstanceTypeLimits() error
 
+	// GetInstanceTypeLimits returns the ENI limits of an instance type, looked up the way FetchInstanceTypeLimits does
+	GetInstanceTypeLimits(ctx context.Context, instanceType string) (InstanceTypeLimits, error)
+
 	IsPrefixDelegationSupported() bool
+
+	// GetEC2ReachabilityError returns the error of the last EC2 call if it didn't reach EC2, nil otherwise
+	GetEC2ReachabilityError() error
+
+	// GetLastEC2Throttle returns when an EC2 call was last throttled, the zero time if it never was
+	GetLastEC2Throttle() time.Time
+
+	// GetTargetLifecycleState returns the lifecycle state the Auto Scaling group is moving the instance to, or "" if
+	// the instance isn't in an Auto Scaling group
+	GetTargetLifecycleState(ctx context.Context) (string, error)
 }
 
 // EC2InstanceMetadataCache caches instance metadata
@@ -227,13 +301,47 @@ type EC2InstanceMetadataCache struct {
 	unmanagedENIs          StringSet
 	useCustomNetworking    bool
 	cniunmanagedENIs       StringSet
+	efaENIs                StringSet
 	enablePrefixDelegation bool
+	useMultiCardENIs       bool
 
 	clusterName       string
 	additionalENITags map[string]string
+	nodeGroupLock     sync.Mutex
+	nodeGroup         string
+	nodeGroupFound    bool
+
+	instanceTypeLimits     *InstanceTypeLimits
+	instanceTypeLimitsFile string
+	// the limits of the instance types found by DescribeInstanceTypes since ipamd started
+	describedLimitsLock sync.Mutex
+	describedLimits     map[string]InstanceTypeLimits
 
 	imds   TypedIMDS
 	ec2SVC ec2wrapper.EC2
+
+	// retryBudget limits the retries of all the EC2 calls of the node, nil if unlimited
+	retryBudget *retryBudget
+
+	ec2ReachabilityLock sync.RWMutex
+	ec2ReachabilityErr  error
+	ec2LastThrottle     time.Time
+
+	// ENIs found through EC2 that IMDS doesn't reflect yet, and the subnet CIDRs needed to describe them
+	imdsPendingLock sync.Mutex
+	imdsPendingENIs map[string]imdsPendingENI
+	subnetIPv4CIDRs map[string]string
+
+	placementLock    sync.Mutex
+	subnetPlacements map[string]Placement
+
+	// eniAttachTimeout and eniDetachTimeout bound the waits for ENI attachments and detachments, 0 if unbounded
+	eniAttachTimeout time.Duration
+	eniDetachTimeout time.Duration
+
+	// ENIs created by AllocENI whose attachment failed, to attach by the next AllocENI
+	pendingENIsLock sync.Mutex
+	pendingENIs     []pendingENI
 }
 
 // ENIMetadata contains information about an ENI
@@ -247,6 +355,10 @@ type ENIMetadata struct {
 	// DeviceNumber is the  device number of network interface
 	DeviceNumber int // 0 means it is primary interface
 
+	// NetworkCard is the index of the network card the network interface is attached to. The device numbers of the
+	// network interfaces on card n start at n times the ENI limit of a card, so that they get their own route tables.
+	NetworkCard int
+
 	// SubnetIPv4CIDR is the IPv4 CIDR of network interface
 	SubnetIPv4CIDR string
 
@@ -266,12 +378,23 @@ type ENIMetadata struct {
 	IPv6Prefixes []*ec2.Ipv6PrefixSpecification
 }
 
+// VPCDNSConfig is the DNS configuration of a DHCP options set
+type VPCDNSConfig struct {
+	Nameservers []string
+	// Domain is the first domain name of the DHCP options set, and Search all of them
+	Domain string
+	Search []string
+}
+
 // InstanceTypeLimits keeps track of limits for an instance type
 type InstanceTypeLimits struct {
 	ENILimit       int
 	IPv4Limit      int
 	HypervisorType string
 	IsBareMetal    bool
+	// NetworkCards is the number of network cards of the instance type, when it has more than one. ENILimit is then the
+	// limit of each card.
+	NetworkCards int
 }
 
 // PrimaryIPv4Address returns the primary IPv4 address of this node
@@ -293,7 +416,7 @@ type DescribeAllENIsResult struct {
 	TagMap          map[string]TagMap
 	TrunkENI        string
 	EFAENIs         map[string]bool
-	MultiCardENIIDs []string
+	MultiCardENIIDs []string // ENIs on network cards that are not used for pods
 }
 
 // msSince returns milliseconds since start.
@@ -306,6 +429,12 @@ func prometheusRegister() {
 		prometheus.MustRegister(awsAPILatency)
 		prometheus.MustRegister(awsAPIErr)
 		prometheus.MustRegister(awsUtilsErr)
+		prometheus.MustRegister(ec2APICallsByCaller)
+		prometheus.MustRegister(imdsENISyncLag)
+		prometheus.MustRegister(eniMetadataEC2Fallback)
+		prometheus.MustRegister(eniStuckOperations)
+		prometheus.MustRegister(pendingENIOperations)
+		prometheus.MustRegister(ec2RetriesDenied)
 		prometheusRegistered = true
 	}
 }
@@ -379,24 +508,40 @@ func (i instrumentedIMDS) GetMetadataWithContext(ctx context.Context, p string)
 
 // New creates an EC2InstanceMetadataCache
 func New(useCustomNetworking, disableENIProvisioning, v4Enabled, v6Enabled bool) (*EC2InstanceMetadataCache, error) {
+	cache := &EC2InstanceMetadataCache{retryBudget: newRetryBudget(getEC2RetryBudget(), time.Now)}
+	provider, err := newEC2Provider(cache.recordEC2Reachability, cache.recordEC2Throttle, cache.retryBudget)
+	if err != nil {
+		return nil, err
+	}
+	return cache.init(provider, useCustomNetworking, disableENIProvisioning, v4Enabled, v6Enabled)
+}
+
+// NewWithProvider creates an EC2InstanceMetadataCache that manages the ENIs of the node through provider instead of
+// the instance metadata service and the EC2 API
+func NewWithProvider(provider Provider, useCustomNetworking, disableENIProvisioning, v4Enabled, v6Enabled bool) (*EC2InstanceMetadataCache, error) {
+	return (&EC2InstanceMetadataCache{}).init(provider, useCustomNetworking, disableENIProvisioning, v4Enabled, v6Enabled)
+}
+
+// init reads the instance metadata of the node from provider, and starts the cleanup of the leaked ENIs
+func (cache *EC2InstanceMetadataCache) init(provider Provider, useCustomNetworking, disableENIProvisioning, v4Enabled, v6Enabled bool) (*EC2InstanceMetadataCache, error) {
 	//ctx is passed to initWithEC2Metadata func to cancel spawned go-routines when tests are run
 	ctx := context.Background()
 
 	// Initializes prometheus metrics
 	prometheusRegister()
 
-	sess := awssession.New()
-	ec2Metadata := ec2metadata.New(sess)
-
-	cache := &EC2InstanceMetadataCache{}
-	cache.imds = TypedIMDS{instrumentedIMDS{ec2Metadata}}
+	cache.imds = TypedIMDS{instrumentedIMDS{provider}}
+	cache.ec2SVC = provider
 	cache.clusterName = os.Getenv(clusterNameEnvVar)
 	cache.additionalENITags = loadAdditionalENITags()
+	cache.eniAttachTimeout = getENIOperationTimeout(envENIAttachTimeout, defaultENIAttachTimeout)
+	cache.eniDetachTimeout = getENIOperationTimeout(envENIDetachTimeout, defaultENIDetachTimeout)
+	// Persist the limits found by DescribeInstanceTypes across ipamd restarts
+	cache.instanceTypeLimitsFile = paths.InstanceTypeLimitsFile()
 
-	region, err := ec2Metadata.Region()
+	region, err := provider.Region()
 	if err != nil {
-		log.Errorf("Failed to retrieve region data from instance metadata %v", err)
-		return nil, errors.Wrap(err, "instance metadata: failed to retrieve region data")
+		return nil, errors.Wrap(err, "failed to retrieve the region")
 	}
 	cache.region = region
 	log.Debugf("Discovered region: %s", cache.region)
@@ -407,11 +552,6 @@ func New(useCustomNetworking, disableENIProvisioning, v4Enabled, v6Enabled bool)
 	cache.v4Enabled = v4Enabled
 	cache.v6Enabled = v6Enabled
 
-	awsCfg := aws.NewConfig().WithRegion(region)
-	sess = sess.Copy(awsCfg)
-
-	ec2SVC := ec2wrapper.New(sess)
-	cache.ec2SVC = ec2SVC
 	err = cache.initWithEC2Metadata(ctx)
 	if err != nil {
 		return nil, err
@@ -427,11 +567,92 @@ func New(useCustomNetworking, disableENIProvisioning, v4Enabled, v6Enabled bool)
 	return cache, nil
 }
 
+// recordEC2Reachability remembers if the last EC2 call, after its retries, failed to reach EC2. An error returned by
+// EC2 itself, like throttling or a denied permission, still proves that EC2 is reachable, unlike a failure to send the
+// request or a server error.
+func (cache *EC2InstanceMetadataCache) recordEC2Reachability(r *request.Request) {
+	var err error
+	if r.Error != nil && (r.HTTPResponse == nil || r.HTTPResponse.StatusCode == 0 ||
+		r.HTTPResponse.StatusCode >= http.StatusInternalServerError) {
+		if aerr, ok := r.Error.(awserr.Error); ok && aerr.Code() == request.CanceledErrorCode {
+			return
+		}
+		err = r.Error
+	}
+	cache.ec2ReachabilityLock.Lock()
+	defer cache.ec2ReachabilityLock.Unlock()
+	cache.ec2ReachabilityErr = err
+}
+
+// GetEC2ReachabilityError returns the error of the last EC2 call if it didn't reach EC2, nil otherwise
+func (cache *EC2InstanceMetadataCache) GetEC2ReachabilityError() error {
+	cache.ec2ReachabilityLock.RLock()
+	defer cache.ec2ReachabilityLock.RUnlock()
+	return cache.ec2ReachabilityErr
+}
+
+// recordEC2Throttle remembers when an attempt of an EC2 call was last throttled, even if a retry went through
+func (cache *EC2InstanceMetadataCache) recordEC2Throttle(r *request.Request) {
+	if r.Error == nil || !request.IsErrorThrottle(r.Error) {
+		return
+	}
+	cache.ec2ReachabilityLock.Lock()
+	defer cache.ec2ReachabilityLock.Unlock()
+	cache.ec2LastThrottle = time.Now()
+}
+
+// GetLastEC2Throttle returns when an EC2 call was last throttled, the zero time if it never was
+func (cache *EC2InstanceMetadataCache) GetLastEC2Throttle() time.Time {
+	cache.ec2ReachabilityLock.RLock()
+	defer cache.ec2ReachabilityLock.RUnlock()
+	return cache.ec2LastThrottle
+}
+
+// GetTargetLifecycleState returns the lifecycle state the Auto Scaling group is moving the instance to, or "" if the
+// instance isn't in an Auto Scaling group
+func (cache *EC2InstanceMetadataCache) GetTargetLifecycleState(ctx context.Context) (string, error) {
+	state, err := cache.imds.GetTargetLifecycleState(ctx)
+	if err != nil {
+		awsAPIErrInc("GetTargetLifecycleState", err)
+		return "", err
+	}
+	return state, nil
+}
+
 func (cache *EC2InstanceMetadataCache) InitCachedPrefixDelegation(enablePrefixDelegation bool) {
 	cache.enablePrefixDelegation = enablePrefixDelegation
 	log.Infof("Prefix Delegation enabled %v", cache.enablePrefixDelegation)
 }
 
+func (cache *EC2InstanceMetadataCache) InitCachedMultiCardENIs(useMultiCardENIs bool) {
+	cache.useMultiCardENIs = useMultiCardENIs
+	log.Infof("Multi-card ENIs enabled %v, network cards in use: %d", cache.useMultiCardENIs, cache.managedNetworkCards())
+}
+
+// managedNetworkCards returns the number of network cards whose ENIs are used for pods, starting from the first one
+func (cache *EC2InstanceMetadataCache) managedNetworkCards() int {
+	eniLimits := cache.getInstanceTypeLimits()
+	if !cache.useMultiCardENIs || eniLimits.NetworkCards <= 1 || eniLimits.ENILimit <= 0 {
+		return 1
+	}
+	// The route table of an ENI is its device number + 1, and has to stay below the tables used for branch ENIs
+	networkCards := eniLimits.NetworkCards
+	if networkCards*eniLimits.ENILimit > maxENIs {
+		networkCards = maxENIs / eniLimits.ENILimit
+		log.Warnf("Only the ENIs of the first %d out of %d network cards can be used for pods", networkCards,
+			eniLimits.NetworkCards)
+	}
+	return networkCards
+}
+
+// deviceNumber returns the node wide device number of the ENI attached to networkCard at deviceIndex
+func (cache *EC2InstanceMetadataCache) deviceNumber(networkCard, deviceIndex int) int {
+	if networkCard == 0 {
+		return deviceIndex
+	}
+	return networkCard*cache.getInstanceTypeLimits().ENILimit + deviceIndex
+}
+
 // InitWithEC2metadata initializes the EC2InstanceMetadataCache with the data retrieved from EC2 metadata service
 func (cache *EC2InstanceMetadataCache) initWithEC2Metadata(ctx context.Context) error {
 	var err error
@@ -501,8 +722,7 @@ func (cache *EC2InstanceMetadataCache) initWithEC2Metadata(ctx context.Context)
 }
 
 // RefreshSGIDs retrieves security groups
-func (cache *EC2InstanceMetadataCache) RefreshSGIDs(mac string) error {
-	ctx := context.TODO()
+func (cache *EC2InstanceMetadataCache) RefreshSGIDs(ctx context.Context, mac string) error {
 
 	sgIDs, err := cache.imds.GetSecurityGroupIDs(ctx, mac)
 	if err != nil {
@@ -554,7 +774,7 @@ func (cache *EC2InstanceMetadataCache) RefreshSGIDs(mac string) error {
 				NetworkInterfaceId: aws.String(eniID),
 			}
 			start := time.Now()
-			_, err = cache.ec2SVC.ModifyNetworkInterfaceAttributeWithContext(context.Background(), attributeInput)
+			_, err = cache.ec2SVC.ModifyNetworkInterfaceAttributeWithContext(ctx, attributeInput)
 			awsAPILatency.WithLabelValues("ModifyNetworkInterfaceAttribute", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
 			if err != nil {
 				if aerr, ok := err.(awserr.Error); ok {
@@ -574,6 +794,38 @@ func (cache *EC2InstanceMetadataCache) RefreshSGIDs(mac string) error {
 	return nil
 }
 
+// GetSecurityGroups returns the security groups of the primary ENI, found by the last RefreshSGIDs
+func (cache *EC2InstanceMetadataCache) GetSecurityGroups() []string {
+	return cache.securityGroups.SortedList()
+}
+
+// GetENISecurityGroups returns the security groups of the ENI with the MAC address from the instance metadata
+func (cache *EC2InstanceMetadataCache) GetENISecurityGroups(ctx context.Context, mac string) ([]string, error) {
+	sgIDs, err := cache.imds.GetSecurityGroupIDs(ctx, mac)
+	if err != nil {
+		awsAPIErrInc("GetSecurityGroupIDs", err)
+		return nil, err
+	}
+	return sgIDs, nil
+}
+
+// SetENISecurityGroups replaces the security groups of an ENI
+func (cache *EC2InstanceMetadataCache) SetENISecurityGroups(ctx context.Context, eniID string, sgIDs []string) error {
+	attributeInput := &ec2.ModifyNetworkInterfaceAttributeInput{
+		Groups:             aws.StringSlice(sgIDs),
+		NetworkInterfaceId: aws.String(eniID),
+	}
+	start := time.Now()
+	_, err := cache.ec2SVC.ModifyNetworkInterfaceAttributeWithContext(ctx, attributeInput)
+	awsAPILatency.WithLabelValues("ModifyNetworkInterfaceAttribute", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
+	if err != nil {
+		CheckAPIErrorAndBroadcastEvent(err, "ec2:ModifyNetworkInterfaceAttribute")
+		awsAPIErrInc("ModifyNetworkInterfaceAttribute", err)
+		return errors.Wrapf(err, "failed to set the security groups of ENI %s", eniID)
+	}
+	return nil
+}
+
 // GetAttachedENIs retrieves ENI information from meta data service
 func (cache *EC2InstanceMetadataCache) GetAttachedENIs() (eniList []ENIMetadata, err error) {
 	ctx := context.TODO()
@@ -594,7 +846,7 @@ func (cache *EC2InstanceMetadataCache) GetAttachedENIs() (eniList []ENIMetadata,
 			return nil, errors.Wrapf(err, "get attached ENIs: failed to retrieve ENI metadata for ENI: %s", mac)
 		}
 	}
-	return enis, nil
+	return cache.mergeIMDSPendingENIs(enis), nil
 }
 
 func (cache *EC2InstanceMetadataCache) getENIMetadata(eniMAC string) (ENIMetadata, error) {
@@ -627,7 +879,14 @@ func (cache *EC2InstanceMetadataCache) getENIMetadata(eniMAC string) (ENIMetadat
 		deviceNum = 0
 	}
 
-	log.Debugf("Found ENI: %s, MAC %s, device %d", eniID, eniMAC, deviceNum)
+	networkCard, err := cache.imds.GetNetworkCard(ctx, eniMAC)
+	if err != nil {
+		awsAPIErrInc("GetNetworkCard", err)
+		return ENIMetadata{}, err
+	}
+	deviceNum = cache.deviceNumber(networkCard, deviceNum)
+
+	log.Debugf("Found ENI: %s, MAC %s, network card %d, device %d", eniID, eniMAC, networkCard, deviceNum)
 
 	cidr, err := cache.imds.GetSubnetIPv4CIDRBlock(ctx, eniMAC)
 	if err != nil {
@@ -686,6 +945,7 @@ func (cache *EC2InstanceMetadataCache) getENIMetadata(eniMAC string) (ENIMetadat
 		ENIID:          eniID,
 		MAC:            eniMAC,
 		DeviceNumber:   deviceNum,
+		NetworkCard:    networkCard,
 		SubnetIPv4CIDR: cidr.String(),
 		IPv4Addresses:  ec2ip4s,
 		IPv4Prefixes:   ec2ipv4Prefixes,
@@ -694,64 +954,72 @@ func (cache *EC2InstanceMetadataCache) getENIMetadata(eniMAC string) (ENIMetadat
 }
 
 // awsGetFreeDeviceNumber calls EC2 API DescribeInstances to get the next free device index
-func (cache *EC2InstanceMetadataCache) awsGetFreeDeviceNumber() (int, error) {
+func (cache *EC2InstanceMetadataCache) awsGetFreeDeviceNumber(ctx context.Context) (networkCard int, deviceIndex int, err error) {
 	input := &ec2.DescribeInstancesInput{
 		InstanceIds: []*string{aws.String(cache.instanceID)},
 	}
 
 	start := time.Now()
-	result, err := cache.ec2SVC.DescribeInstancesWithContext(context.Background(), input)
+	result, err := cache.ec2SVC.DescribeInstancesWithContext(ctx, input)
 	awsAPILatency.WithLabelValues("DescribeInstances", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
 	if err != nil {
 		CheckAPIErrorAndBroadcastEvent(err, "ec2:DescribeInstances")
 		awsAPIErrInc("DescribeInstances", err)
 		log.Errorf("awsGetFreeDeviceNumber: Unable to retrieve instance data from EC2 control plane %v", err)
-		return 0, errors.Wrap(err,
+		return 0, 0, errors.Wrap(err,
 			"find a free device number for ENI: not able to retrieve instance data from EC2 control plane")
 	}
 
 	if len(result.Reservations) != 1 {
-		return 0, errors.Errorf("awsGetFreeDeviceNumber: invalid instance id %s", cache.instanceID)
+		return 0, 0, errors.Errorf("awsGetFreeDeviceNumber: invalid instance id %s", cache.instanceID)
 	}
 
 	inst := result.Reservations[0].Instances[0]
-	var device [maxENIs]bool
+	type device struct{ networkCard, deviceIndex int }
+	used := make(map[device]bool)
 	for _, eni := range inst.NetworkInterfaces {
-		if aws.Int64Value(eni.Attachment.DeviceIndex) > maxENIs {
-			log.Warnf("The Device Index %d of the attached ENI %s > instance max slot %d",
-				aws.Int64Value(eni.Attachment.DeviceIndex), aws.StringValue(eni.NetworkInterfaceId),
-				maxENIs)
-		} else {
-			log.Debugf("Discovered device number is used: %d", aws.Int64Value(eni.Attachment.DeviceIndex))
-			device[aws.Int64Value(eni.Attachment.DeviceIndex)] = true
-		}
-	}
-
-	for freeDeviceIndex := 0; freeDeviceIndex < maxENIs; freeDeviceIndex++ {
-		if !device[freeDeviceIndex] {
-			log.Debugf("Found a free device number: %d", freeDeviceIndex)
-			return freeDeviceIndex, nil
+		d := device{int(aws.Int64Value(eni.Attachment.NetworkCardIndex)), int(aws.Int64Value(eni.Attachment.DeviceIndex))}
+		log.Debugf("Discovered device number is used: %d on network card %d", d.deviceIndex, d.networkCard)
+		used[d] = true
+	}
+
+	// Without multi-card ENIs, every ENI is attached to the first network card
+	networkCards := cache.managedNetworkCards()
+	devicesPerCard := maxENIs
+	if networkCards > 1 {
+		devicesPerCard = cache.getInstanceTypeLimits().ENILimit
+	}
+	for networkCard := 0; networkCard < networkCards; networkCard++ {
+		for deviceIndex := 0; deviceIndex < devicesPerCard; deviceIndex++ {
+			if !used[device{networkCard, deviceIndex}] {
+				log.Debugf("Found a free device number: %d on network card %d", deviceIndex, networkCard)
+				return networkCard, deviceIndex, nil
+			}
 		}
 	}
-	return 0, errors.New("awsGetFreeDeviceNumber: no available device number")
+	return 0, 0, errors.New("awsGetFreeDeviceNumber: no available device number")
 }
 
 // AllocENI creates an ENI and attaches it to the instance
 // returns: newly created ENI ID
-func (cache *EC2InstanceMetadataCache) AllocENI(useCustomCfg bool, sg []*string, subnet string) (string, error) {
-	eniID, err := cache.createENI(useCustomCfg, sg, subnet)
+func (cache *EC2InstanceMetadataCache) AllocENI(ctx context.Context, useCustomCfg bool, sg []*string, subnet string) (string, error) {
+	// An ENI left over by an earlier failed attachment is attached before creating a new one
+	key := pendingENIKey(useCustomCfg, sg, subnet)
+	eniID, attachmentID, err := cache.attachPendingENI(ctx, key)
 	if err != nil {
-		return "", errors.Wrap(err, "AllocENI: failed to create ENI")
+		return "", errors.Wrap(err, "AllocENI: error attaching pending ENI")
 	}
+	if eniID == "" {
+		eniID, err = cache.createENI(ctx, useCustomCfg, sg, subnet)
+		if err != nil {
+			return "", errors.Wrap(err, "AllocENI: failed to create ENI")
+		}
 
-	attachmentID, err := cache.attachENI(eniID)
-	if err != nil {
-		derr := cache.deleteENI(eniID, maxENIBackoffDelay)
-		if derr != nil {
-			awsUtilsErrInc("AllocENIDeleteErr", err)
-			log.Errorf("Failed to delete newly created untagged ENI! %v", err)
+		attachmentID, err = cache.attachENI(ctx, eniID)
+		if err != nil {
+			cache.keepPendingENI(ctx, eniID, key, err)
+			return "", errors.Wrap(err, "AllocENI: error attaching ENI")
 		}
-		return "", errors.Wrap(err, "AllocENI: error attaching ENI")
 	}
 
 	// Also change the ENI's attribute so that the ENI will be deleted when the instance is deleted.
@@ -764,12 +1032,12 @@ func (cache *EC2InstanceMetadataCache) AllocENI(useCustomCfg bool, sg []*string,
 	}
 
 	start := time.Now()
-	_, err = cache.ec2SVC.ModifyNetworkInterfaceAttributeWithContext(context.Background(), attributeInput)
+	_, err = cache.ec2SVC.ModifyNetworkInterfaceAttributeWithContext(ctx, attributeInput)
 	awsAPILatency.WithLabelValues("ModifyNetworkInterfaceAttribute", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
 	if err != nil {
 		CheckAPIErrorAndBroadcastEvent(err, "ec2:ModifyNetworkInterfaceAttribute")
 		awsAPIErrInc("ModifyNetworkInterfaceAttribute", err)
-		err := cache.FreeENI(eniID)
+		err := cache.FreeENI(ctx, eniID)
 		if err != nil {
 			awsUtilsErrInc("ENICleanupUponModifyNetworkErr", err)
 		}
@@ -781,9 +1049,9 @@ func (cache *EC2InstanceMetadataCache) AllocENI(useCustomCfg bool, sg []*string,
 }
 
 // attachENI calls EC2 API to attach the ENI and returns the attachment id
-func (cache *EC2InstanceMetadataCache) attachENI(eniID string) (string, error) {
+func (cache *EC2InstanceMetadataCache) attachENI(ctx context.Context, eniID string) (string, error) {
 	// attach to instance
-	freeDevice, err := cache.awsGetFreeDeviceNumber()
+	networkCard, freeDevice, err := cache.awsGetFreeDeviceNumber(ctx)
 	if err != nil {
 		return "", errors.Wrap(err, "attachENI: failed to get a free device number")
 	}
@@ -793,8 +1061,11 @@ func (cache *EC2InstanceMetadataCache) attachENI(eniID string) (string, error) {
 		InstanceId:         aws.String(cache.instanceID),
 		NetworkInterfaceId: aws.String(eniID),
 	}
+	if networkCard > 0 {
+		attachInput.NetworkCardIndex = aws.Int64(int64(networkCard))
+	}
 	start := time.Now()
-	attachOutput, err := cache.ec2SVC.AttachNetworkInterfaceWithContext(context.Background(), attachInput)
+	attachOutput, err := cache.ec2SVC.AttachNetworkInterfaceWithContext(ctx, attachInput)
 	awsAPILatency.WithLabelValues("AttachNetworkInterface", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
 	if err != nil {
 		CheckAPIErrorAndBroadcastEvent(err, "ec2:AttachNetworkInterface")
@@ -806,12 +1077,12 @@ func (cache *EC2InstanceMetadataCache) attachENI(eniID string) (string, error) {
 }
 
 // return ENI id, error
-func (cache *EC2InstanceMetadataCache) createENI(useCustomCfg bool, sg []*string, subnet string) (string, error) {
+func (cache *EC2InstanceMetadataCache) createENI(ctx context.Context, useCustomCfg bool, sg []*string, subnet string) (string, error) {
 	eniDescription := eniDescriptionPrefix + cache.instanceID
 	tags := map[string]string{
 		eniCreatedAtTagKey: time.Now().Format(time.RFC3339),
 	}
-	for key, value := range cache.buildENITags() {
+	for key, value := range cache.buildENITags(ctx) {
 		tags[key] = value
 	}
 	tagSpec := []*ec2.TagSpecification{
@@ -836,6 +1107,15 @@ func (cache *EC2InstanceMetadataCache) createENI(useCustomCfg bool, sg []*string
 			log.Warnf("No custom networking security group found, will use the node's primary ENI's SG: %v", aws.StringValueSlice(input.Groups))
 		}
 		input.SubnetId = aws.String(subnet)
+		// EC2 rejects a subnet of another zone or Outpost with an error that doesn't say why
+		if subnet != cache.subnetID {
+			var placementErr *SubnetPlacementError
+			if err := cache.CheckSubnetPlacement(ctx, subnet); errors.As(err, &placementErr) {
+				return "", err
+			} else if err != nil {
+				log.Warnf("Unable to check the placement of subnet %s: %v", subnet, err)
+			}
+		}
 	} else {
 		log.Info("Using same config as the primary interface for the new ENI")
 	}
@@ -843,7 +1123,7 @@ func (cache *EC2InstanceMetadataCache) createENI(useCustomCfg bool, sg []*string
 	log.Infof("Creating ENI with security groups: %v in subnet: %s", aws.StringValueSlice(input.Groups), aws.StringValue(input.SubnetId))
 
 	start := time.Now()
-	result, err := cache.ec2SVC.CreateNetworkInterfaceWithContext(context.Background(), input)
+	result, err := cache.ec2SVC.CreateNetworkInterfaceWithContext(ctx, input)
 	awsAPILatency.WithLabelValues("CreateNetworkInterface", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
 	if err != nil {
 		CheckAPIErrorAndBroadcastEvent(err, "ec2:CreateNetworkInterface")
@@ -855,8 +1135,9 @@ func (cache *EC2InstanceMetadataCache) createENI(useCustomCfg bool, sg []*string
 	return aws.StringValue(result.NetworkInterface.NetworkInterfaceId), nil
 }
 
-// buildENITags computes the desired AWS Tags for eni
-func (cache *EC2InstanceMetadataCache) buildENITags() map[string]string {
+// buildENITags computes the desired AWS Tags for eni, rendering the templates of the additional tags with the caller
+// and the pod set on ctx
+func (cache *EC2InstanceMetadataCache) buildENITags(ctx context.Context) map[string]string {
 	tags := map[string]string{
 		eniNodeTagKey: cache.instanceID,
 	}
@@ -866,44 +1147,67 @@ func (cache *EC2InstanceMetadataCache) buildENITags() map[string]string {
 	if cache.clusterName != "" {
 		tags[eniClusterTagKey] = cache.clusterName
 	}
+	var data *ENITagData
 	for key, value := range cache.additionalENITags {
-		tags[key] = value
+		if !isENITagTemplate(value) {
+			tags[key] = value
+			continue
+		}
+		if data == nil {
+			eniTagData := cache.eniTagData(ctx)
+			data = &eniTagData
+		}
+		rendered, err := renderENITag(key, value, *data)
+		if err != nil {
+			log.Warnf("Skipping ENI tag: %v", err)
+			continue
+		}
+		tags[key] = rendered
 	}
 	return tags
 }
 
-func (cache *EC2InstanceMetadataCache) TagENI(eniID string, currentTags map[string]string) error {
+// TagENI adds the missing tags to an ENI and fixes the ones with a wrong value. The templated additional tags are only
+// added when missing, since their value depends on what the ENI was created for.
+func (cache *EC2InstanceMetadataCache) TagENI(ctx context.Context, eniID string, currentTags map[string]string) error {
 	tagChanges := make(map[string]string)
-	for tagKey, tagValue := range cache.buildENITags() {
-		if currentTagValue, ok := currentTags[tagKey]; !ok || currentTagValue != tagValue {
+	for tagKey, tagValue := range cache.buildENITags(ctx) {
+		currentTagValue, ok := currentTags[tagKey]
+		if ok && isENITagTemplate(cache.additionalENITags[tagKey]) {
+			continue
+		}
+		if !ok || currentTagValue != tagValue {
 			tagChanges[tagKey] = tagValue
 		}
 	}
 	if len(tagChanges) == 0 {
 		return nil
 	}
+	log.Debugf("Tagging ENI %s with missing tags: %v", eniID, tagChanges)
+	return cache.AddENITags(ctx, eniID, tagChanges)
+}
 
+// AddENITags adds tags to an ENI, replacing the value of the ones it already has
+func (cache *EC2InstanceMetadataCache) AddENITags(ctx context.Context, eniID string, tags map[string]string) error {
 	input := &ec2.CreateTagsInput{
 		Resources: []*string{
 			aws.String(eniID),
 		},
-		Tags: convertTagsToSDKTags(tagChanges),
+		Tags: convertTagsToSDKTags(tags),
 	}
-
-	log.Debugf("Tagging ENI %s with missing tags: %v", eniID, tagChanges)
-	return retry.NWithBackoff(retry.NewSimpleBackoff(500*time.Millisecond, maxENIBackoffDelay, 0.3, 2), 5, func() error {
+	return retry.NWithBackoff(retry.NewSimpleBackoff(500*time.Millisecond, maxENIBackoffDelay, 0.3, 2), 5, cache.withRetryBudget(ctx, "CreateTags", 1, 5, func() error {
 		start := time.Now()
-		_, err := cache.ec2SVC.CreateTagsWithContext(context.Background(), input)
+		_, err := cache.ec2SVC.CreateTagsWithContext(ctx, input)
 		awsAPILatency.WithLabelValues("CreateTags", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
 		if err != nil {
 			CheckAPIErrorAndBroadcastEvent(err, "ec2:CreateTags")
 			awsAPIErrInc("CreateTags", err)
-			log.Warnf("Failed to tag the newly created ENI %s:", eniID)
+			log.Warnf("Failed to tag ENI %s: %v", eniID, err)
 			return err
 		}
 		log.Debugf("Successfully tagged ENI: %s", eniID)
 		return nil
-	})
+	}))
 }
 
 // containsPrivateIPAddressLimitExceededError returns whether exceeds ENI's IP address limit
@@ -925,15 +1229,15 @@ func awsUtilsErrInc(fn string, err error) {
 }
 
 // FreeENI detaches and deletes the ENI interface
-func (cache *EC2InstanceMetadataCache) FreeENI(eniName string) error {
-	return cache.freeENI(eniName, 2*time.Second, maxENIBackoffDelay)
+func (cache *EC2InstanceMetadataCache) FreeENI(ctx context.Context, eniName string) error {
+	return cache.freeENI(ctx, eniName, 2*time.Second, maxENIBackoffDelay)
 }
 
-func (cache *EC2InstanceMetadataCache) freeENI(eniName string, sleepDelayAfterDetach time.Duration, maxBackoffDelay time.Duration) error {
+func (cache *EC2InstanceMetadataCache) freeENI(ctx context.Context, eniName string, sleepDelayAfterDetach time.Duration, maxBackoffDelay time.Duration) error {
 	log.Infof("Trying to free ENI: %s", eniName)
 
 	// Find out attachment
-	attachID, err := cache.getENIAttachmentID(eniName)
+	eni, err := cache.describeENI(ctx, eniName)
 	if err != nil {
 		if err == ErrENINotFound {
 			log.Infof("ENI %s not found. It seems to be already freed", eniName)
@@ -943,6 +1247,15 @@ func (cache *EC2InstanceMetadataCache) freeENI(eniName string, sleepDelayAfterDe
 		log.Errorf("Failed to retrieve ENI %s attachment id: %v", eniName, err)
 		return errors.Wrap(err, "FreeENI: failed to retrieve ENI's attachment id")
 	}
+	if err := cache.verifyENIOwnership(eni); err != nil {
+		awsUtilsErrInc("FreeENINotOwned", err)
+		log.Warnf("Not freeing ENI %s: %v", eniName, err)
+		return err
+	}
+	var attachID *string
+	if eni.Attachment != nil {
+		attachID = eni.Attachment.AttachmentId
+	}
 	log.Debugf("Found ENI %s attachment id: %s ", eniName, aws.StringValue(attachID))
 
 	detachInput := &ec2.DetachNetworkInterfaceInput{
@@ -950,9 +1263,9 @@ func (cache *EC2InstanceMetadataCache) freeENI(eniName string, sleepDelayAfterDe
 	}
 
 	// Retry detaching the ENI from the instance
-	err = retry.NWithBackoff(retry.NewSimpleBackoff(time.Millisecond*200, maxBackoffDelay, 0.15, 2.0), maxENIEC2APIRetries, func() error {
+	err = retry.NWithBackoff(retry.NewSimpleBackoff(time.Millisecond*200, maxBackoffDelay, 0.15, 2.0), maxENIEC2APIRetries, cache.withRetryBudget(ctx, "DetachNetworkInterface", 1, maxENIEC2APIRetries, func() error {
 		start := time.Now()
-		_, ec2Err := cache.ec2SVC.DetachNetworkInterfaceWithContext(context.Background(), detachInput)
+		_, ec2Err := cache.ec2SVC.DetachNetworkInterfaceWithContext(ctx, detachInput)
 		awsAPILatency.WithLabelValues("DetachNetworkInterface", fmt.Sprint(ec2Err != nil), awsReqStatus(ec2Err)).Observe(msSince(start))
 		if ec2Err != nil {
 			CheckAPIErrorAndBroadcastEvent(err, "ec2:DetachNetworkInterface")
@@ -962,7 +1275,7 @@ func (cache *EC2InstanceMetadataCache) freeENI(eniName string, sleepDelayAfterDe
 		}
 		log.Infof("Successfully detached ENI: %s", eniName)
 		return nil
-	})
+	}))
 
 	if err != nil {
 		log.Errorf("Failed to detach ENI %s %v", eniName, err)
@@ -971,7 +1284,13 @@ func (cache *EC2InstanceMetadataCache) freeENI(eniName string, sleepDelayAfterDe
 
 	// It does take awhile for EC2 to detach ENI from instance, so we wait 2s before trying the delete.
 	time.Sleep(sleepDelayAfterDetach)
-	err = cache.deleteENI(eniName, maxBackoffDelay)
+	if cache.eniDetachTimeout > 0 {
+		if err := cache.ensureENIDetached(ctx, eniName, attachID); err != nil {
+			awsUtilsErrInc("FreeENIStuckDetaching", err)
+			return errors.Wrapf(err, "FreeENI: failed to free ENI: %s", eniName)
+		}
+	}
+	err = cache.deleteENI(ctx, eniName, maxBackoffDelay)
 	if err != nil {
 		awsUtilsErrInc("FreeENIDeleteErr", err)
 		return errors.Wrapf(err, "FreeENI: failed to free ENI: %s", eniName)
@@ -982,13 +1301,29 @@ func (cache *EC2InstanceMetadataCache) freeENI(eniName string, sleepDelayAfterDe
 }
 
 // getENIAttachmentID calls EC2 to fetch the attachmentID of a given ENI
-func (cache *EC2InstanceMetadataCache) getENIAttachmentID(eniID string) (*string, error) {
+func (cache *EC2InstanceMetadataCache) getENIAttachmentID(ctx context.Context, eniID string) (*string, error) {
+	eni, err := cache.describeENI(ctx, eniID)
+	if err != nil {
+		return nil, err
+	}
+	// We cannot assume that the NetworkInterface.Attachment field is a non-nil
+	// pointer to a NetworkInterfaceAttachment struct.
+	// Ref: https://github.com/aws/amazon-vpc-cni-k8s/issues/914
+	var attachID *string
+	if eni.Attachment != nil {
+		attachID = eni.Attachment.AttachmentId
+	}
+	return attachID, nil
+}
+
+// describeENI calls EC2 to describe a given ENI
+func (cache *EC2InstanceMetadataCache) describeENI(ctx context.Context, eniID string) (*ec2.NetworkInterface, error) {
 	eniIds := make([]*string, 0)
 	eniIds = append(eniIds, aws.String(eniID))
 	input := &ec2.DescribeNetworkInterfacesInput{NetworkInterfaceIds: eniIds}
 
 	start := time.Now()
-	result, err := cache.ec2SVC.DescribeNetworkInterfacesWithContext(context.Background(), input)
+	result, err := cache.ec2SVC.DescribeNetworkInterfacesWithContext(ctx, input)
 	awsAPILatency.WithLabelValues("DescribeNetworkInterfaces", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
@@ -1005,26 +1340,17 @@ func (cache *EC2InstanceMetadataCache) getENIAttachmentID(eniID string) (*string
 	if len(result.NetworkInterfaces) == 0 {
 		return nil, ErrNoNetworkInterfaces
 	}
-	firstNI := result.NetworkInterfaces[0]
-
-	// We cannot assume that the NetworkInterface.Attachment field is a non-nil
-	// pointer to a NetworkInterfaceAttachment struct.
-	// Ref: https://github.com/aws/amazon-vpc-cni-k8s/issues/914
-	var attachID *string
-	if firstNI.Attachment != nil {
-		attachID = firstNI.Attachment.AttachmentId
-	}
-	return attachID, nil
+	return result.NetworkInterfaces[0], nil
 }
 
-func (cache *EC2InstanceMetadataCache) deleteENI(eniName string, maxBackoffDelay time.Duration) error {
+func (cache *EC2InstanceMetadataCache) deleteENI(ctx context.Context, eniName string, maxBackoffDelay time.Duration) error {
 	log.Debugf("Trying to delete ENI: %s", eniName)
 	deleteInput := &ec2.DeleteNetworkInterfaceInput{
 		NetworkInterfaceId: aws.String(eniName),
 	}
-	err := retry.NWithBackoff(retry.NewSimpleBackoff(time.Millisecond*500, maxBackoffDelay, 0.15, 2.0), maxENIEC2APIRetries, func() error {
+	err := retry.NWithBackoff(retry.NewSimpleBackoff(time.Millisecond*500, maxBackoffDelay, 0.15, 2.0), maxENIEC2APIRetries, cache.withRetryBudget(ctx, "DeleteNetworkInterface", 1, maxENIEC2APIRetries, func() error {
 		start := time.Now()
-		_, ec2Err := cache.ec2SVC.DeleteNetworkInterfaceWithContext(context.Background(), deleteInput)
+		_, ec2Err := cache.ec2SVC.DeleteNetworkInterfaceWithContext(ctx, deleteInput)
 		awsAPILatency.WithLabelValues("DeleteNetworkInterface", fmt.Sprint(ec2Err != nil), awsReqStatus(ec2Err)).Observe(msSince(start))
 		if ec2Err != nil {
 			if aerr, ok := ec2Err.(awserr.Error); ok {
@@ -1041,18 +1367,18 @@ func (cache *EC2InstanceMetadataCache) deleteENI(eniName string, maxBackoffDelay
 		}
 		log.Infof("Successfully deleted ENI: %s", eniName)
 		return nil
-	})
+	}))
 	return err
 }
 
 // GetIPv4sFromEC2 calls EC2 and returns a list of all addresses on the ENI
-func (cache *EC2InstanceMetadataCache) GetIPv4sFromEC2(eniID string) (addrList []*ec2.NetworkInterfacePrivateIpAddress, err error) {
+func (cache *EC2InstanceMetadataCache) GetIPv4sFromEC2(ctx context.Context, eniID string) (addrList []*ec2.NetworkInterfacePrivateIpAddress, err error) {
 	eniIds := make([]*string, 0)
 	eniIds = append(eniIds, aws.String(eniID))
 	input := &ec2.DescribeNetworkInterfacesInput{NetworkInterfaceIds: eniIds}
 
 	start := time.Now()
-	result, err := cache.ec2SVC.DescribeNetworkInterfacesWithContext(context.Background(), input)
+	result, err := cache.ec2SVC.DescribeNetworkInterfacesWithContext(ctx, input)
 	awsAPILatency.WithLabelValues("DescribeNetworkInterfaces", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
@@ -1076,12 +1402,12 @@ func (cache *EC2InstanceMetadataCache) GetIPv4sFromEC2(eniID string) (addrList [
 }
 
 // GetIPv4PrefixesFromEC2 calls EC2 and returns a list of all addresses on the ENI
-func (cache *EC2InstanceMetadataCache) GetIPv4PrefixesFromEC2(eniID string) (addrList []*ec2.Ipv4PrefixSpecification, err error) {
+func (cache *EC2InstanceMetadataCache) GetIPv4PrefixesFromEC2(ctx context.Context, eniID string) (addrList []*ec2.Ipv4PrefixSpecification, err error) {
 	eniIds := []*string{aws.String(eniID)}
 	input := &ec2.DescribeNetworkInterfacesInput{NetworkInterfaceIds: eniIds}
 
 	start := time.Now()
-	result, err := cache.ec2SVC.DescribeNetworkInterfacesWithContext(context.Background(), input)
+	result, err := cache.ec2SVC.DescribeNetworkInterfacesWithContext(ctx, input)
 	awsAPILatency.WithLabelValues("DescribeNetworkInterfaces", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
@@ -1106,12 +1432,12 @@ func (cache *EC2InstanceMetadataCache) GetIPv4PrefixesFromEC2(eniID string) (add
 }
 
 // GetIPv6PrefixesFromEC2 calls EC2 and returns a list of all addresses on the ENI
-func (cache *EC2InstanceMetadataCache) GetIPv6PrefixesFromEC2(eniID string) (addrList []*ec2.Ipv6PrefixSpecification, err error) {
+func (cache *EC2InstanceMetadataCache) GetIPv6PrefixesFromEC2(ctx context.Context, eniID string) (addrList []*ec2.Ipv6PrefixSpecification, err error) {
 	eniIds := []*string{aws.String(eniID)}
 	input := &ec2.DescribeNetworkInterfacesInput{NetworkInterfaceIds: eniIds}
 
 	start := time.Now()
-	result, err := cache.ec2SVC.DescribeNetworkInterfacesWithContext(context.Background(), input)
+	result, err := cache.ec2SVC.DescribeNetworkInterfacesWithContext(ctx, input)
 	awsAPILatency.WithLabelValues("DescribeNetworkInterfaces", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
@@ -1135,7 +1461,7 @@ func (cache *EC2InstanceMetadataCache) GetIPv6PrefixesFromEC2(eniID string) (add
 }
 
 // DescribeAllENIs calls EC2 to refresh the ENIMetadata and tags for all attached ENIs
-func (cache *EC2InstanceMetadataCache) DescribeAllENIs() (DescribeAllENIsResult, error) {
+func (cache *EC2InstanceMetadataCache) DescribeAllENIs(ctx context.Context) (DescribeAllENIsResult, error) {
 	// Fetch all local ENI info from metadata
 	allENIs, err := cache.GetAttachedENIs()
 	if err != nil {
@@ -1154,7 +1480,7 @@ func (cache *EC2InstanceMetadataCache) DescribeAllENIs() (DescribeAllENIsResult,
 	for retryCount := 0; retryCount < maxENIEC2APIRetries && len(eniIDs) > 0; retryCount++ {
 		input := &ec2.DescribeNetworkInterfacesInput{NetworkInterfaceIds: aws.StringSlice(eniIDs)}
 		start := time.Now()
-		ec2Response, err = cache.ec2SVC.DescribeNetworkInterfacesWithContext(context.Background(), input)
+		ec2Response, err = cache.ec2SVC.DescribeNetworkInterfacesWithContext(ctx, input)
 		awsAPILatency.WithLabelValues("DescribeNetworkInterfaces", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
 		if err == nil {
 			// No error, exit the loop
@@ -1181,7 +1507,10 @@ func (cache *EC2InstanceMetadataCache) DescribeAllENIs() (DescribeAllENIsResult,
 				continue
 			}
 		}
-		// For other errors sleep a short while before the next retry
+		// For other errors sleep a short while before the next retry, if the retry budget allows it
+		if retryCount+1 < maxENIEC2APIRetries && !cache.allowRetry(ctx, "DescribeNetworkInterfaces") {
+			break
+		}
 		time.Sleep(time.Duration(retryCount*10) * time.Millisecond)
 	}
 
@@ -1206,7 +1535,7 @@ func (cache *EC2InstanceMetadataCache) DescribeAllENIs() (DescribeAllENIsResult,
 			log.Warn("Primary ENI will not get deleted when node terminates because 'delete_on_termination' is set to false")
 		}
 		eniID := aws.StringValue(ec2res.NetworkInterfaceId)
-		if aws.Int64Value(ec2res.Attachment.NetworkCardIndex) > 0 {
+		if int(aws.Int64Value(ec2res.Attachment.NetworkCardIndex)) >= cache.managedNetworkCards() {
 			multiCardENIIDs = append(multiCardENIIDs, eniID)
 		}
 
@@ -1220,12 +1549,19 @@ func (cache *EC2InstanceMetadataCache) DescribeAllENIs() (DescribeAllENIsResult,
 			trunkENI = eniID
 		}
 		if interfaceType == "efa" {
+			log.Infof("%s is an EFA interface", eniID)
 			efaENIs[eniID] = true
 		}
 		// Check IPv4 addresses
 		logOutOfSyncState(eniID, eniMetadata.IPv4Addresses, ec2res.PrivateIpAddresses)
 		tagMap[eniMetadata.ENIID] = convertSDKTagsToTags(ec2res.TagSet)
 	}
+	efaENIIDs := make([]string, 0, len(efaENIs))
+	for eniID := range efaENIs {
+		efaENIIDs = append(efaENIIDs, eniID)
+	}
+	cache.efaENIs.Set(efaENIIDs)
+
 	return DescribeAllENIsResult{
 		ENIMetadata:     verifiedENIs,
 		TagMap:          tagMap,
@@ -1281,10 +1617,17 @@ func loadAdditionalENITags() map[string]string {
 		log.Warnf("failed to parse additional ENI Tags from env %v due to %v", additionalEniTagsEnvVar, err)
 		return nil
 	}
-	for key := range additionalENITags {
+	for key, value := range additionalENITags {
 		if strings.Contains(key, reservedTagKeyPrefix) {
 			log.Warnf("ignoring tagKey %v from additional ENI Tags as it contains reserved prefix %v", key, reservedTagKeyPrefix)
 			delete(additionalENITags, key)
+			continue
+		}
+		if isENITagTemplate(value) {
+			if _, err := renderENITag(key, value, ENITagData{}); err != nil {
+				log.Warnf("ignoring tagKey %v from additional ENI Tags: %v", key, err)
+				delete(additionalENITags, key)
+			}
 		}
 	}
 	return additionalENITags
@@ -1337,7 +1680,7 @@ func logOutOfSyncState(eniID string, imdsIPv4s, ec2IPv4s []*ec2.NetworkInterface
 }
 
 // AllocIPAddress allocates an IP address for an ENI
-func (cache *EC2InstanceMetadataCache) AllocIPAddress(eniID string) error {
+func (cache *EC2InstanceMetadataCache) AllocIPAddress(ctx context.Context, eniID string) error {
 	log.Infof("Trying to allocate an IP address on ENI: %s", eniID)
 
 	input := &ec2.AssignPrivateIpAddressesInput{
@@ -1346,7 +1689,7 @@ func (cache *EC2InstanceMetadataCache) AllocIPAddress(eniID string) error {
 	}
 
 	start := time.Now()
-	output, err := cache.ec2SVC.AssignPrivateIpAddressesWithContext(context.Background(), input)
+	output, err := cache.ec2SVC.AssignPrivateIpAddressesWithContext(ctx, input)
 	awsAPILatency.WithLabelValues("AssignPrivateIpAddresses", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
 	if err != nil {
 		CheckAPIErrorAndBroadcastEvent(err, "ec2:AssignPrivateIpAddresses")
@@ -1359,66 +1702,198 @@ func (cache *EC2InstanceMetadataCache) AllocIPAddress(eniID string) error {
 	return nil
 }
 
+// FetchInstanceTypeLimits looks up the ENI and IP limits of the instance type with DescribeInstanceTypes, so that new
+// instance types work without a CNI release. The result is persisted under /var/run so that a restart can still find the
+// limits when the EC2 API is not reachable. The vendored InstanceNetworkingLimits table is only used as a last resort.
 func (cache *EC2InstanceMetadataCache) FetchInstanceTypeLimits() error {
-	_, ok := InstanceNetworkingLimits[cache.instanceType]
-	if ok {
-		return nil
+	eniLimits, err := cache.lookupInstanceTypeLimits(WithCaller(context.Background(), CallerStartup), cache.instanceType)
+	if err != nil {
+		return err
+	}
+	cache.instanceTypeLimits = &eniLimits
+	return nil
+}
+
+// GetInstanceTypeLimits returns the ENI and IP limits of an instance type. The limits of this instance are the ones found
+// by FetchInstanceTypeLimits; other instance types are looked up with DescribeInstanceTypes, falling back to the limits
+// found earlier and then to the vendored InstanceNetworkingLimits table.
+func (cache *EC2InstanceMetadataCache) GetInstanceTypeLimits(ctx context.Context, instanceType string) (InstanceTypeLimits, error) {
+	if instanceType == cache.instanceType && cache.instanceTypeLimits != nil {
+		return *cache.instanceTypeLimits, nil
+	}
+	return cache.lookupInstanceTypeLimits(ctx, instanceType)
+}
+
+// lookupInstanceTypeLimits looks up the limits of an instance type with DescribeInstanceTypes, then in the limits found
+// earlier, then in the vendored table
+func (cache *EC2InstanceMetadataCache) lookupInstanceTypeLimits(ctx context.Context, instanceType string) (InstanceTypeLimits, error) {
+	eniLimits, err := cache.describeInstanceTypeLimits(ctx, instanceType)
+	if err == nil {
+		cache.describedLimitsLock.Lock()
+		if cache.describedLimits == nil {
+			cache.describedLimits = make(map[string]InstanceTypeLimits)
+		}
+		cache.describedLimits[instanceType] = eniLimits
+		cache.describedLimitsLock.Unlock()
+		// The file only holds the limits of this instance, for the next start of ipamd
+		if instanceType == cache.instanceType {
+			if err := cache.storeInstanceTypeLimits(eniLimits); err != nil {
+				log.Warnf("Failed to persist instance type limits to %s: %v", cache.instanceTypeLimitsFile, err)
+			}
+		}
+		return eniLimits, nil
 	}
+	log.Warnf("Failed to look up the limits of instance type %s from EC2: %v", instanceType, err)
 
-	log.Debugf("Instance type limits are missing from vpc_ip_limits.go hence making an EC2 call to fetch the limits")
-	var eniLimits InstanceTypeLimits
-	describeInstanceTypesInput := &ec2.DescribeInstanceTypesInput{InstanceTypes: []*string{aws.String(cache.instanceType)}}
-	output, err := cache.ec2SVC.DescribeInstanceTypesWithContext(context.Background(), describeInstanceTypesInput)
+	if eniLimits, ok := cache.loadInstanceTypeLimits(instanceType); ok {
+		log.Infof("Using the limits of instance type %s found earlier", instanceType)
+		return eniLimits, nil
+	}
+	if eniLimits, ok := InstanceNetworkingLimits[instanceType]; ok {
+		log.Infof("Using the limits of instance type %s from vpc_ip_limits.go", instanceType)
+		return eniLimits, nil
+	}
+	return InstanceTypeLimits{}, err
+}
+
+func (cache *EC2InstanceMetadataCache) describeInstanceTypeLimits(ctx context.Context, instanceType string) (InstanceTypeLimits, error) {
+	describeInstanceTypesInput := &ec2.DescribeInstanceTypesInput{InstanceTypes: []*string{aws.String(instanceType)}}
+	output, err := cache.ec2SVC.DescribeInstanceTypesWithContext(ctx, describeInstanceTypesInput)
 	if err != nil || len(output.InstanceTypes) != 1 {
 		CheckAPIErrorAndBroadcastEvent(err, "ec2:DescribeInstanceTypes")
-		return errors.New(fmt.Sprintf("Failed calling DescribeInstanceTypes for `%s`: %v", cache.instanceType, err))
+		return InstanceTypeLimits{}, errors.New(fmt.Sprintf("Failed calling DescribeInstanceTypes for `%s`: %v", instanceType, err))
 	}
 	info := output.InstanceTypes[0]
 	// Ignore any missing values
-	instanceType := aws.StringValue(info.InstanceType)
+	describedType := aws.StringValue(info.InstanceType)
+	if info.NetworkInfo == nil {
+		return InstanceTypeLimits{}, errors.New(fmt.Sprintf("%s: %s", UnknownInstanceType, instanceType))
+	}
 	eniLimit := int(aws.Int64Value(info.NetworkInfo.MaximumNetworkInterfaces))
+	// ENIs are attached to a network card, keep the limit of the default card like the vendored limits do
+	networkCards := 0
+	if len(info.NetworkInfo.NetworkCards) > 1 {
+		networkCards = len(info.NetworkInfo.NetworkCards)
+		defaultCard := int(aws.Int64Value(info.NetworkInfo.DefaultNetworkCardIndex))
+		for _, card := range info.NetworkInfo.NetworkCards {
+			if int(aws.Int64Value(card.NetworkCardIndex)) == defaultCard {
+				eniLimit = int(aws.Int64Value(card.MaximumNetworkInterfaces))
+			}
+		}
+	}
 	ipv4Limit := int(aws.Int64Value(info.NetworkInfo.Ipv4AddressesPerInterface))
 	hypervisorType := aws.StringValue(info.Hypervisor)
 	isBareMetalInstance := aws.BoolValue(info.BareMetal)
 	//Not checking for empty hypervisorType since have seen certain instances not getting this filled.
-	if instanceType != "" && eniLimit > 0 && ipv4Limit > 0 {
-		eniLimits = InstanceTypeLimits{
-			ENILimit:       eniLimit,
-			IPv4Limit:      ipv4Limit,
-			HypervisorType: hypervisorType,
-			IsBareMetal:    isBareMetalInstance,
-		}
+	if describedType == "" || eniLimit <= 0 || ipv4Limit <= 0 {
+		return InstanceTypeLimits{}, errors.New(fmt.Sprintf("%s: %s", UnknownInstanceType, instanceType))
+	}
+	return InstanceTypeLimits{
+		ENILimit:       eniLimit,
+		IPv4Limit:      ipv4Limit,
+		HypervisorType: hypervisorType,
+		IsBareMetal:    isBareMetalInstance,
+		NetworkCards:   networkCards,
+	}, nil
+}
 
-		InstanceNetworkingLimits[instanceType] = eniLimits
-	} else {
-		return errors.New(fmt.Sprintf("%s: %s", UnknownInstanceType, cache.instanceType))
+// instanceTypeLimitsCache is the on-disk format of the persisted instance type limits
+type instanceTypeLimitsCache struct {
+	InstanceType string             `json:"instanceType"`
+	Limits       InstanceTypeLimits `json:"limits"`
+}
+
+func (cache *EC2InstanceMetadataCache) storeInstanceTypeLimits(limits InstanceTypeLimits) error {
+	if cache.instanceTypeLimitsFile == "" {
+		return nil
+	}
+	data, err := json.Marshal(instanceTypeLimitsCache{InstanceType: cache.instanceType, Limits: limits})
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(cache.instanceTypeLimitsFile), filepath.Base(cache.instanceTypeLimitsFile)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), cache.instanceTypeLimitsFile); err != nil {
+		os.Remove(f.Name())
+		return err
 	}
 	return nil
 }
 
+// loadInstanceTypeLimits returns the limits of an instance type found by an earlier DescribeInstanceTypes, since ipamd
+// started or, for this instance, persisted by the previous ipamd
+func (cache *EC2InstanceMetadataCache) loadInstanceTypeLimits(instanceType string) (InstanceTypeLimits, bool) {
+	cache.describedLimitsLock.Lock()
+	eniLimits, ok := cache.describedLimits[instanceType]
+	cache.describedLimitsLock.Unlock()
+	if ok {
+		return eniLimits, true
+	}
+	if instanceType != cache.instanceType || cache.instanceTypeLimitsFile == "" {
+		return InstanceTypeLimits{}, false
+	}
+	data, err := ioutil.ReadFile(cache.instanceTypeLimitsFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("Failed to read instance type limits from %s: %v", cache.instanceTypeLimitsFile, err)
+		}
+		return InstanceTypeLimits{}, false
+	}
+	var cached instanceTypeLimitsCache
+	if err := json.Unmarshal(data, &cached); err != nil {
+		log.Warnf("Ignoring invalid instance type limits in %s: %v", cache.instanceTypeLimitsFile, err)
+		return InstanceTypeLimits{}, false
+	}
+	// The instance type only changes across a stop/start, but don't trust a file written for another type
+	if cached.InstanceType != cache.instanceType || cached.Limits.ENILimit <= 0 || cached.Limits.IPv4Limit <= 0 {
+		return InstanceTypeLimits{}, false
+	}
+	return cached.Limits, true
+}
+
+// getInstanceTypeLimits returns the limits found by FetchInstanceTypeLimits, or the vendored ones if it was not called
+func (cache *EC2InstanceMetadataCache) getInstanceTypeLimits() InstanceTypeLimits {
+	if cache.instanceTypeLimits != nil {
+		return *cache.instanceTypeLimits
+	}
+	eniLimits, _ := InstanceNetworkingLimits[cache.instanceType]
+	return eniLimits
+}
+
 // GetENIIPv4Limit return IP address limit per ENI based on EC2 instance type
 func (cache *EC2InstanceMetadataCache) GetENIIPv4Limit() int {
-	eniLimits, _ := InstanceNetworkingLimits[cache.instanceType]
+	eniLimits := cache.getInstanceTypeLimits()
 	// Subtract one from the IPv4Limit since we don't use the primary IP on each ENI for pods.
 	return eniLimits.IPv4Limit - 1
 }
 
-// GetENILimit returns the number of ENIs can be attached to an instance
+// GetENILimit returns the number of ENIs can be attached to an instance, on all the network cards used for pods
 func (cache *EC2InstanceMetadataCache) GetENILimit() int {
-	eniLimits, _ := InstanceNetworkingLimits[cache.instanceType]
-	return eniLimits.ENILimit
+	eniLimits := cache.getInstanceTypeLimits()
+	return eniLimits.ENILimit * cache.managedNetworkCards()
 }
 
 // GetInstanceHypervisorFamily returns hypervisor of EC2 instance type
 func (cache *EC2InstanceMetadataCache) GetInstanceHypervisorFamily() string {
-	eniLimits, _ := InstanceNetworkingLimits[cache.instanceType]
+	eniLimits := cache.getInstanceTypeLimits()
 	log.Debugf("Instance hypervisor family %s", eniLimits.HypervisorType)
 	return eniLimits.HypervisorType
 }
 
 // IsInstanceBareMetal derives bare metal value of the instance
 func (cache *EC2InstanceMetadataCache) IsInstanceBareMetal() bool {
-	instanceProperties, _ := InstanceNetworkingLimits[cache.instanceType]
+	instanceProperties := cache.getInstanceTypeLimits()
 	log.Debugf("Bare Metal Instance %s", instanceProperties.IsBareMetal)
 	return instanceProperties.IsBareMetal
 }
@@ -1428,6 +1903,11 @@ func (cache *EC2InstanceMetadataCache) GetInstanceType() string {
 	return cache.instanceType
 }
 
+// GetRegion returns the region of the instance
+func (cache *EC2InstanceMetadataCache) GetRegion() string {
+	return cache.region
+}
+
 // IsPrefixDelegationSupported return true if the instance type supports Prefix Assignment/Delegation
 func (cache *EC2InstanceMetadataCache) IsPrefixDelegationSupported() bool {
 	log.Debugf("Check if instance supports Prefix Delegation")
@@ -1439,7 +1919,17 @@ func (cache *EC2InstanceMetadataCache) IsPrefixDelegationSupported() bool {
 }
 
 // AllocIPAddresses allocates numIPs of IP address on an ENI
-func (cache *EC2InstanceMetadataCache) AllocIPAddresses(eniID string, numIPs int) (*ec2.AssignPrivateIpAddressesOutput, error) {
+func (cache *EC2InstanceMetadataCache) AllocIPAddresses(ctx context.Context, eniID string, numIPs int) (*ec2.AssignPrivateIpAddressesOutput, error) {
+	return cache.allocIPAddresses(ctx, eniID, numIPs, cache.enablePrefixDelegation)
+}
+
+// AllocSecondaryIPAddresses allocates numIPs secondary IP addresses on an ENI, whether prefix delegation is enabled or
+// not. It is used when the subnet is too fragmented to hand out a /28 prefix.
+func (cache *EC2InstanceMetadataCache) AllocSecondaryIPAddresses(ctx context.Context, eniID string, numIPs int) (*ec2.AssignPrivateIpAddressesOutput, error) {
+	return cache.allocIPAddresses(ctx, eniID, numIPs, false)
+}
+
+func (cache *EC2InstanceMetadataCache) allocIPAddresses(ctx context.Context, eniID string, numIPs int, usePrefixes bool) (*ec2.AssignPrivateIpAddressesOutput, error) {
 	var needIPs = numIPs
 
 	ipLimit := cache.GetENIIPv4Limit()
@@ -1454,10 +1944,10 @@ func (cache *EC2InstanceMetadataCache) AllocIPAddresses(eniID string, numIPs int
 	}
 
 	log.Infof("Trying to allocate %d IP addresses on ENI %s", needIPs, eniID)
-	log.Debugf("PD enabled - %t", cache.enablePrefixDelegation)
+	log.Debugf("PD enabled - %t, using prefixes - %t", cache.enablePrefixDelegation, usePrefixes)
 	input := &ec2.AssignPrivateIpAddressesInput{}
 
-	if cache.enablePrefixDelegation {
+	if usePrefixes {
 		needPrefixes := needIPs
 		input = &ec2.AssignPrivateIpAddressesInput{
 			NetworkInterfaceId: aws.String(eniID),
@@ -1472,7 +1962,7 @@ func (cache *EC2InstanceMetadataCache) AllocIPAddresses(eniID string, numIPs int
 	}
 
 	start := time.Now()
-	output, err := cache.ec2SVC.AssignPrivateIpAddressesWithContext(context.Background(), input)
+	output, err := cache.ec2SVC.AssignPrivateIpAddressesWithContext(ctx, input)
 	awsAPILatency.WithLabelValues("AssignPrivateIpAddresses", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
 	if err != nil {
 		CheckAPIErrorAndBroadcastEvent(err, "ec2:AssignPrivateIpAddresses")
@@ -1486,7 +1976,7 @@ func (cache *EC2InstanceMetadataCache) AllocIPAddresses(eniID string, numIPs int
 		return nil, err
 	}
 	if output != nil {
-		if cache.enablePrefixDelegation {
+		if usePrefixes {
 			log.Infof("Allocated %d private IP prefixes", len(output.AssignedIpv4Prefixes))
 		} else {
 			log.Infof("Allocated %d private IP addresses", len(output.AssignedPrivateIpAddresses))
@@ -1495,14 +1985,34 @@ func (cache *EC2InstanceMetadataCache) AllocIPAddresses(eniID string, numIPs int
 	return output, nil
 }
 
-func (cache *EC2InstanceMetadataCache) AllocIPv6Prefixes(eniID string) ([]*string, error) {
+// AllocIPAddressesByIP assigns the given secondary IP addresses to an ENI. EC2 fails the whole call if one of them
+// is not free in the subnet of the ENI.
+func (cache *EC2InstanceMetadataCache) AllocIPAddressesByIP(ctx context.Context, eniID string, ips []string) error {
+	log.Infof("Trying to assign IP addresses %v to ENI %s", ips, eniID)
+	input := &ec2.AssignPrivateIpAddressesInput{
+		NetworkInterfaceId: aws.String(eniID),
+		PrivateIpAddresses: aws.StringSlice(ips),
+	}
+	start := time.Now()
+	_, err := cache.ec2SVC.AssignPrivateIpAddressesWithContext(ctx, input)
+	awsAPILatency.WithLabelValues("AssignPrivateIpAddresses", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
+	if err != nil {
+		CheckAPIErrorAndBroadcastEvent(err, "ec2:AssignPrivateIpAddresses")
+		log.Errorf("Failed to assign IP addresses %v to ENI %s: %v", ips, eniID, err)
+		awsAPIErrInc("AssignPrivateIpAddresses", err)
+		return errors.Wrapf(err, "failed to assign IP addresses %v to ENI %s", ips, eniID)
+	}
+	return nil
+}
+
+func (cache *EC2InstanceMetadataCache) AllocIPv6Prefixes(ctx context.Context, eniID string) ([]*string, error) {
 	//We only need to allocate one IPv6 prefix per ENI.
 	input := &ec2.AssignIpv6AddressesInput{
 		NetworkInterfaceId: aws.String(eniID),
 		Ipv6PrefixCount:    aws.Int64(1),
 	}
 	start := time.Now()
-	output, err := cache.ec2SVC.AssignIpv6AddressesWithContext(context.Background(), input)
+	output, err := cache.ec2SVC.AssignIpv6AddressesWithContext(ctx, input)
 	awsAPILatency.WithLabelValues("AssignIpv6AddressesWithContext", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
 	if err != nil {
 		CheckAPIErrorAndBroadcastEvent(err, "ec2:AssignPrivateIpv6Addresses")
@@ -1516,57 +2026,75 @@ func (cache *EC2InstanceMetadataCache) AllocIPv6Prefixes(eniID string) ([]*strin
 	return output.AssignedIpv6Prefixes, nil
 }
 
-// WaitForENIAndIPsAttached waits until the ENI has been attached and the secondary IPs have been added
-func (cache *EC2InstanceMetadataCache) WaitForENIAndIPsAttached(eni string, wantedCidrs int) (eniMetadata ENIMetadata, err error) {
-	return cache.waitForENIAndIPsAttached(eni, wantedCidrs, maxENIBackoffDelay)
+// WaitForENIAndIPsAttached waits until the ENI has been attached and the secondary IPs have been added. When IMDS is
+// slow to reflect them, it falls back to asking EC2.
+func (cache *EC2InstanceMetadataCache) WaitForENIAndIPsAttached(ctx context.Context, eni string, wantedCidrs int) (eniMetadata ENIMetadata, err error) {
+	return cache.waitForENIAndIPsAttached(ctx, eni, wantedCidrs, maxENIBackoffDelay)
 }
 
-func (cache *EC2InstanceMetadataCache) waitForENIAndIPsAttached(eni string, wantedCidrs int, maxBackoffDelay time.Duration) (eniMetadata ENIMetadata, err error) {
+func (cache *EC2InstanceMetadataCache) waitForENIAndIPsAttached(ctx context.Context, eni string, wantedCidrs int, maxBackoffDelay time.Duration) (eniMetadata ENIMetadata, err error) {
 	start := time.Now()
 	attempt := 0
-	// Wait until the ENI shows up in the instance metadata service and has at least some secondary IPs
-	err = retry.NWithBackoff(retry.NewSimpleBackoff(time.Millisecond*100, maxBackoffDelay, 0.15, 2.0), maxENIEC2APIRetries, func() error {
+	fromEC2 := false
+	waitCtx := ctx
+	if cache.eniAttachTimeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, cache.eniAttachTimeout)
+		defer cancel()
+	}
+	// Wait until the ENI shows up in the instance metadata service, or in EC2 if IMDS lags behind, and has at least
+	// some secondary IPs
+	// The attempts after imdsAttemptsBeforeEC2Fallback call EC2, and their retries draw from the retry budget
+	err = retry.NWithBackoffCtx(waitCtx, retry.NewSimpleBackoff(time.Millisecond*100, maxBackoffDelay, 0.15, 2.0), maxENIEC2APIRetries, cache.withRetryBudget(waitCtx, "DescribeNetworkInterfaces", imdsAttemptsBeforeEC2Fallback+1, maxENIEC2APIRetries, func() error {
 		attempt++
-		enis, err := cache.GetAttachedENIs()
-		if err != nil {
-			log.Warnf("Failed to increase pool, error trying to discover attached ENIs on attempt %d/%d: %v ", attempt, maxENIEC2APIRetries, err)
-			return ErrNoNetworkInterfaces
-		}
-		// Verify that the ENI we are waiting for is in the returned list
-		for _, returnedENI := range enis {
-			if eni == returnedENI.ENIID {
-				// Check how many Secondary IPs or Prefixes have been attached
-				var eniIPCount int
-				log.Debugf("ENI ID: %v IP Addr: %s, IPv4Prefixes:- %v, IPv6Prefixes:- %v", returnedENI.ENIID,
-					returnedENI.IPv4Addresses, returnedENI.IPv4Prefixes, returnedENI.IPv6Prefixes)
-				if cache.enablePrefixDelegation {
-					eniIPCount = len(returnedENI.IPv4Prefixes)
-					if cache.v6Enabled {
-						eniIPCount = len(returnedENI.IPv6Prefixes)
-					}
-				} else {
-					//Ignore primary IP of the ENI
-					//wantedCidrs will be at most 1 less then the IP limit for the ENI because of the primary IP in secondary pod
-					eniIPCount = len(returnedENI.IPv4Addresses) - 1
+		var returnedENI ENIMetadata
+		if attempt > imdsAttemptsBeforeEC2Fallback {
+			var err error
+			returnedENI, err = cache.getENIMetadataFromEC2(waitCtx, eni)
+			if err != nil {
+				log.Debugf("Not able to find the right ENI in EC2 yet (attempt %d/%d): %v", attempt, maxENIEC2APIRetries, err)
+				return err
+			}
+			fromEC2 = true
+		} else {
+			enis, err := cache.GetAttachedENIs()
+			if err != nil {
+				log.Warnf("Failed to increase pool, error trying to discover attached ENIs on attempt %d/%d: %v ", attempt, maxENIEC2APIRetries, err)
+				return ErrNoNetworkInterfaces
+			}
+			// Verify that the ENI we are waiting for is in the returned list
+			found := false
+			for _, e := range enis {
+				if eni == e.ENIID {
+					returnedENI = e
+					found = true
+					break
 				}
+			}
+			if !found {
+				log.Debugf("Not able to find the right ENI yet (attempt %d/%d)", attempt, maxENIEC2APIRetries)
+				return ErrENINotFound
+			}
+		}
 
-				if eniIPCount < 1 {
-					log.Debugf("No secondary IPv4 addresses/prefixes available yet on ENI %s", returnedENI.ENIID)
-					return ErrNoSecondaryIPsFound
-				}
+		// Check how many Secondary IPs or Prefixes have been attached
+		log.Debugf("ENI ID: %v IP Addr: %s, IPv4Prefixes:- %v, IPv6Prefixes:- %v", returnedENI.ENIID,
+			returnedENI.IPv4Addresses, returnedENI.IPv4Prefixes, returnedENI.IPv6Prefixes)
+		//wantedCidrs will be at most 1 less then the IP limit for the ENI because of the primary IP in secondary pod
+		eniIPCount := cache.eniCidrCount(returnedENI)
+		if eniIPCount < 1 {
+			log.Debugf("No secondary IPv4 addresses/prefixes available yet on ENI %s", returnedENI.ENIID)
+			return ErrNoSecondaryIPsFound
+		}
 
-				// At least some are attached
-				eniMetadata = returnedENI
+		// At least some are attached
+		eniMetadata = returnedENI
 
-				if eniIPCount >= wantedCidrs {
-					return nil
-				}
-				return ErrAllSecondaryIPsNotFound
-			}
+		if eniIPCount >= wantedCidrs {
+			return nil
 		}
-		log.Debugf("Not able to find the right ENI yet (attempt %d/%d)", attempt, maxENIEC2APIRetries)
-		return ErrENINotFound
-	})
+		return ErrAllSecondaryIPsNotFound
+	}))
 	awsAPILatency.WithLabelValues("waitForENIAndIPsAttached", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
 	if err != nil {
 		// If we have at least 1 Secondary IP, by now return what we have without an error
@@ -1574,21 +2102,37 @@ func (cache *EC2InstanceMetadataCache) waitForENIAndIPsAttached(eni string, want
 			if !cache.enablePrefixDelegation && len(eniMetadata.IPv4Addresses) > 1 {
 				// We have some Secondary IPs, return the ones we have
 				log.Warnf("This ENI only has %d IP addresses, we wanted %d", len(eniMetadata.IPv4Addresses), wantedCidrs)
+				cache.eniMetadataFound(eniMetadata, fromEC2, start)
 				return eniMetadata, nil
 			} else if cache.enablePrefixDelegation && len(eniMetadata.IPv4Prefixes) > 1 {
 				// We have some prefixes, return the ones we have
 				log.Warnf("This ENI only has %d Prefixes, we wanted %d", len(eniMetadata.IPv4Prefixes), wantedCidrs)
+				cache.eniMetadataFound(eniMetadata, fromEC2, start)
 				return eniMetadata, nil
 			}
 		}
 		awsAPIErrInc("waitENIAttachedFailedToAssignIPs", err)
-		return ENIMetadata{}, errors.New("waitForENIAndIPsAttached: giving up trying to retrieve ENIs from metadata service")
+		if waitCtx.Err() == context.DeadlineExceeded {
+			cache.recoverStuckAttachment(ctx, eni)
+		}
+		return ENIMetadata{}, errors.New("waitForENIAndIPsAttached: giving up trying to retrieve ENIs from metadata service and EC2")
 	}
+	cache.eniMetadataFound(eniMetadata, fromEC2, start)
 	return eniMetadata, nil
 }
 
+// eniMetadataFound records how long IMDS took to reflect a new ENI, or that it had to be read from EC2
+func (cache *EC2InstanceMetadataCache) eniMetadataFound(eniMetadata ENIMetadata, fromEC2 bool, start time.Time) {
+	if fromEC2 {
+		log.Infof("IMDS did not reflect ENI %s after %d attempts, using its metadata from EC2", eniMetadata.ENIID, imdsAttemptsBeforeEC2Fallback)
+		cache.addIMDSPendingENI(eniMetadata, start)
+		return
+	}
+	imdsENISyncLag.Observe(time.Since(start).Seconds())
+}
+
 // DeallocIPAddresses frees IP address on an ENI
-func (cache *EC2InstanceMetadataCache) DeallocIPAddresses(eniID string, ips []string) error {
+func (cache *EC2InstanceMetadataCache) DeallocIPAddresses(ctx context.Context, eniID string, ips []string) error {
 	if len(ips) == 0 {
 		return nil
 	}
@@ -1601,7 +2145,7 @@ func (cache *EC2InstanceMetadataCache) DeallocIPAddresses(eniID string, ips []st
 	}
 
 	start := time.Now()
-	_, err := cache.ec2SVC.UnassignPrivateIpAddressesWithContext(context.Background(), input)
+	_, err := cache.ec2SVC.UnassignPrivateIpAddressesWithContext(ctx, input)
 	awsAPILatency.WithLabelValues("UnassignPrivateIpAddresses", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
 	if err != nil {
 		CheckAPIErrorAndBroadcastEvent(err, "ec2:UnassignPrivateIpAddresses")
@@ -1614,7 +2158,7 @@ func (cache *EC2InstanceMetadataCache) DeallocIPAddresses(eniID string, ips []st
 }
 
 // DeallocPrefixAddresses frees Prefixes on an ENI
-func (cache *EC2InstanceMetadataCache) DeallocPrefixAddresses(eniID string, prefixes []string) error {
+func (cache *EC2InstanceMetadataCache) DeallocPrefixAddresses(ctx context.Context, eniID string, prefixes []string) error {
 	if len(prefixes) == 0 {
 		return nil
 	}
@@ -1627,7 +2171,7 @@ func (cache *EC2InstanceMetadataCache) DeallocPrefixAddresses(eniID string, pref
 	}
 
 	start := time.Now()
-	_, err := cache.ec2SVC.UnassignPrivateIpAddressesWithContext(context.Background(), input)
+	_, err := cache.ec2SVC.UnassignPrivateIpAddressesWithContext(ctx, input)
 	awsAPILatency.WithLabelValues("UnassignPrivateIpAddresses", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
 	if err != nil {
 		CheckAPIErrorAndBroadcastEvent(err, "ec2:UnassignPrivateIpAddresses")
@@ -1649,14 +2193,15 @@ func (cache *EC2InstanceMetadataCache) cleanUpLeakedENIsInternal(startupDelay ti
 	time.Sleep(startupDelay)
 
 	log.Debug("Checking for leaked AWS CNI ENIs.")
-	networkInterfaces, err := cache.getLeakedENIs()
+	ctx := WithCaller(context.Background(), CallerLeakedENICleanup)
+	networkInterfaces, err := cache.getLeakedENIs(ctx)
 	if err != nil {
 		log.Warnf("Unable to get leaked ENIs: %v", err)
 	} else {
 		// Clean up all the leaked ones we found
 		for _, networkInterface := range networkInterfaces {
 			eniID := aws.StringValue(networkInterface.NetworkInterfaceId)
-			err = cache.deleteENI(eniID, maxENIBackoffDelay)
+			err = cache.deleteENI(ctx, eniID, maxENIBackoffDelay)
 			if err != nil {
 				awsUtilsErrInc("cleanUpLeakedENIDeleteErr", err)
 				log.Warnf("Failed to clean up leaked ENI %s: %v", eniID, err)
@@ -1667,7 +2212,7 @@ func (cache *EC2InstanceMetadataCache) cleanUpLeakedENIsInternal(startupDelay ti
 	}
 }
 
-func (cache *EC2InstanceMetadataCache) tagENIcreateTS(eniID string, maxBackoffDelay time.Duration) {
+func (cache *EC2InstanceMetadataCache) tagENIcreateTS(ctx context.Context, eniID string, maxBackoffDelay time.Duration) {
 	// Tag the ENI with "node.k8s.amazonaws.com/createdAt=currentTime"
 	tags := []*ec2.Tag{
 		{
@@ -1685,9 +2230,9 @@ func (cache *EC2InstanceMetadataCache) tagENIcreateTS(eniID string, maxBackoffDe
 		Tags: tags,
 	}
 
-	_ = retry.NWithBackoff(retry.NewSimpleBackoff(500*time.Millisecond, maxBackoffDelay, 0.3, 2), 5, func() error {
+	_ = retry.NWithBackoff(retry.NewSimpleBackoff(500*time.Millisecond, maxBackoffDelay, 0.3, 2), 5, cache.withRetryBudget(ctx, "CreateTags", 1, 5, func() error {
 		start := time.Now()
-		_, err := cache.ec2SVC.CreateTagsWithContext(context.Background(), input)
+		_, err := cache.ec2SVC.CreateTagsWithContext(ctx, input)
 		awsAPILatency.WithLabelValues("CreateTags", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
 		if err != nil {
 			CheckAPIErrorAndBroadcastEvent(err, "ec2:CreateTags")
@@ -1697,12 +2242,27 @@ func (cache *EC2InstanceMetadataCache) tagENIcreateTS(eniID string, maxBackoffDe
 		}
 		log.Debugf("Successfully tagged ENI: %s", eniID)
 		return nil
-	})
+	}))
+}
+
+// verifyENIOwnership returns ErrENINotOwned unless the ENI was created by the CNI, as shown by its "aws-K8S-"
+// description, and, when CLUSTER_NAME is set, is tagged with this cluster. It keeps ipamd from deleting the ENIs that
+// other controllers attach to the instance.
+func (cache *EC2InstanceMetadataCache) verifyENIOwnership(eni *ec2.NetworkInterface) error {
+	if description := aws.StringValue(eni.Description); !strings.HasPrefix(description, eniDescriptionPrefix) {
+		return errors.Wrapf(ErrENINotOwned, "description %q doesn't start with %s", description, eniDescriptionPrefix)
+	}
+	if cache.clusterName != "" {
+		if cluster := convertSDKTagsToTags(eni.TagSet)[eniClusterTagKey]; cluster != cache.clusterName {
+			return errors.Wrapf(ErrENINotOwned, "tag %s is %q instead of %q", eniClusterTagKey, cluster, cache.clusterName)
+		}
+	}
+	return nil
 }
 
 // getLeakedENIs calls DescribeNetworkInterfaces to get all available ENIs that were allocated by
 // the AWS CNI plugin, but were not deleted.
-func (cache *EC2InstanceMetadataCache) getLeakedENIs() ([]*ec2.NetworkInterface, error) {
+func (cache *EC2InstanceMetadataCache) getLeakedENIs(ctx context.Context) ([]*ec2.NetworkInterface, error) {
 	leakedENIFilters := []*ec2.Filter{
 		{
 			Name: aws.String("tag-key"),
@@ -1733,8 +2293,9 @@ func (cache *EC2InstanceMetadataCache) getLeakedENIs() ([]*ec2.NetworkInterface,
 
 	var networkInterfaces []*ec2.NetworkInterface
 	filterFn := func(networkInterface *ec2.NetworkInterface) error {
-		// Verify the description starts with "aws-K8S-"
-		if !strings.HasPrefix(aws.StringValue(networkInterface.Description), eniDescriptionPrefix) {
+		// Verify the description starts with "aws-K8S-" and the cluster tag matches
+		if err := cache.verifyENIOwnership(networkInterface); err != nil {
+			log.Debugf("Not cleaning up ENI %s: %v", aws.StringValue(networkInterface.NetworkInterfaceId), err)
 			return nil
 		}
 		// Check that it's not a newly created ENI
@@ -1744,7 +2305,7 @@ func (cache *EC2InstanceMetadataCache) getLeakedENIs() ([]*ec2.NetworkInterface,
 			parsedTime, err := time.Parse(time.RFC3339, value)
 			if err != nil {
 				log.Warnf("ParsedTime format %s is wrong so retagging with current TS", parsedTime)
-				cache.tagENIcreateTS(aws.StringValue(networkInterface.NetworkInterfaceId), maxENIBackoffDelay)
+				cache.tagENIcreateTS(ctx, aws.StringValue(networkInterface.NetworkInterfaceId), maxENIBackoffDelay)
 			}
 			if time.Since(parsedTime) < eniDeleteCooldownTime {
 				log.Infof("Found an ENI created less than 5 minutes ago, so not cleaning it up")
@@ -1755,14 +2316,14 @@ func (cache *EC2InstanceMetadataCache) getLeakedENIs() ([]*ec2.NetworkInterface,
 			/* Set a time if we didn't find one. This is to prevent accidentally deleting ENIs that are in the
 			 * process of being attached by CNI versions v1.5.x or earlier.
 			 */
-			cache.tagENIcreateTS(aws.StringValue(networkInterface.NetworkInterfaceId), maxENIBackoffDelay)
+			cache.tagENIcreateTS(ctx, aws.StringValue(networkInterface.NetworkInterfaceId), maxENIBackoffDelay)
 			return nil
 		}
 		networkInterfaces = append(networkInterfaces, networkInterface)
 		return nil
 	}
 
-	err := cache.getENIsFromPaginatedDescribeNetworkInterfaces(input, filterFn)
+	err := cache.getENIsFromPaginatedDescribeNetworkInterfaces(ctx, input, filterFn)
 
 	if err != nil {
 		return nil, errors.Wrap(err, "awsutils: unable to obtain filtered list of network interfaces")
@@ -1777,6 +2338,194 @@ func (cache *EC2InstanceMetadataCache) getLeakedENIs() ([]*ec2.NetworkInterface,
 	return networkInterfaces, nil
 }
 
+// CheckEC2Permissions issues dry-run calls for the EC2 actions ipamd uses, and returns the ones that were denied with
+// UnauthorizedOperation. Calls failing for other reasons are inconclusive and not reported. AssignPrivateIpAddresses and
+// UnassignPrivateIpAddresses don't support dry runs, so they can't be checked.
+func (cache *EC2InstanceMetadataCache) CheckEC2Permissions(checkENIProvisioning bool) map[string]error {
+	ctx := WithCaller(context.Background(), CallerStartup)
+	dryRun := aws.Bool(true)
+	primaryENI := aws.String(cache.primaryENI)
+	checks := map[string]func() error{
+		"ec2:DescribeNetworkInterfaces": func() error {
+			_, err := cache.ec2SVC.DescribeNetworkInterfacesWithContext(ctx, &ec2.DescribeNetworkInterfacesInput{
+				DryRun: dryRun, NetworkInterfaceIds: []*string{primaryENI}})
+			return err
+		},
+	}
+	if checkENIProvisioning {
+		checks["ec2:CreateNetworkInterface"] = func() error {
+			_, err := cache.ec2SVC.CreateNetworkInterfaceWithContext(ctx, &ec2.CreateNetworkInterfaceInput{
+				DryRun: dryRun, SubnetId: aws.String(cache.subnetID)})
+			return err
+		}
+		checks["ec2:AttachNetworkInterface"] = func() error {
+			_, err := cache.ec2SVC.AttachNetworkInterfaceWithContext(ctx, &ec2.AttachNetworkInterfaceInput{
+				DryRun: dryRun, NetworkInterfaceId: primaryENI, InstanceId: aws.String(cache.instanceID), DeviceIndex: aws.Int64(1)})
+			return err
+		}
+		checks["ec2:DeleteNetworkInterface"] = func() error {
+			_, err := cache.ec2SVC.DeleteNetworkInterfaceWithContext(ctx, &ec2.DeleteNetworkInterfaceInput{
+				DryRun: dryRun, NetworkInterfaceId: primaryENI})
+			return err
+		}
+		checks["ec2:CreateTags"] = func() error {
+			_, err := cache.ec2SVC.CreateTagsWithContext(ctx, &ec2.CreateTagsInput{
+				DryRun:    dryRun,
+				Resources: []*string{primaryENI},
+				Tags:      []*ec2.Tag{{Key: aws.String(eniNodeTagKey), Value: aws.String(cache.instanceID)}},
+			})
+			return err
+		}
+	}
+
+	denied := make(map[string]error)
+	for action, check := range checks {
+		err := check()
+		if aerr, ok := err.(awserr.Error); ok {
+			switch aerr.Code() {
+			case "DryRunOperation":
+				continue
+			case "UnauthorizedOperation":
+				denied[action] = err
+				continue
+			}
+		}
+		if err != nil {
+			log.Debugf("Dry run of %s was inconclusive: %v", action, err)
+		}
+	}
+	return denied
+}
+
+// GetSubnetAvailableIPCount returns the number of free IP addresses in the subnet, the primary ENI's one if empty
+func (cache *EC2InstanceMetadataCache) GetSubnetAvailableIPCount(subnetID string) (int, error) {
+	if subnetID == "" {
+		subnetID = cache.subnetID
+	}
+	start := time.Now()
+	output, err := cache.ec2SVC.DescribeSubnetsWithContext(WithCaller(context.Background(), CallerStartup), &ec2.DescribeSubnetsInput{
+		SubnetIds: []*string{aws.String(subnetID)}})
+	awsAPILatency.WithLabelValues("DescribeSubnets", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
+	if err != nil {
+		awsAPIErrInc("DescribeSubnets", err)
+		return 0, errors.Wrapf(err, "failed to describe subnet %s", subnetID)
+	}
+	if len(output.Subnets) != 1 {
+		return 0, errors.Errorf("subnet %s not found", subnetID)
+	}
+	return int(aws.Int64Value(output.Subnets[0].AvailableIpAddressCount)), nil
+}
+
+// GetMissingSecurityGroups returns the security groups which don't exist in the VPC
+func (cache *EC2InstanceMetadataCache) GetMissingSecurityGroups(sgIDs []string) ([]string, error) {
+	if len(sgIDs) == 0 {
+		return nil, nil
+	}
+	// Filter on the IDs instead of passing GroupIds, which fails the whole call on the first unknown group
+	start := time.Now()
+	output, err := cache.ec2SVC.DescribeSecurityGroupsWithContext(WithCaller(context.Background(), CallerStartup), &ec2.DescribeSecurityGroupsInput{
+		Filters: []*ec2.Filter{{Name: aws.String("group-id"), Values: aws.StringSlice(sgIDs)}}})
+	awsAPILatency.WithLabelValues("DescribeSecurityGroups", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
+	if err != nil {
+		awsAPIErrInc("DescribeSecurityGroups", err)
+		return nil, errors.Wrap(err, "failed to describe security groups")
+	}
+	found := sets.NewString()
+	for _, sg := range output.SecurityGroups {
+		found.Insert(aws.StringValue(sg.GroupId))
+	}
+	var missing []string
+	for _, sgID := range sgIDs {
+		if !found.Has(sgID) {
+			missing = append(missing, sgID)
+		}
+	}
+	return missing, nil
+}
+
+// GetVPCDNSConfig returns the DNS configuration that the DHCP options set of the VPC gives to instances. The
+// AmazonProvidedDNS name server is resolved to the address of the Route 53 Resolver, the base of the VPC CIDR plus two.
+func (cache *EC2InstanceMetadataCache) GetVPCDNSConfig() (VPCDNSConfig, error) {
+	vpcID, err := cache.imds.GetVPCID(context.TODO(), cache.primaryENImac)
+	if err != nil {
+		awsAPIErrInc("GetVPCID", err)
+		return VPCDNSConfig{}, err
+	}
+
+	start := time.Now()
+	vpcs, err := cache.ec2SVC.DescribeVpcsWithContext(WithCaller(context.Background(), CallerDNSConfig), &ec2.DescribeVpcsInput{
+		VpcIds: []*string{aws.String(vpcID)}})
+	awsAPILatency.WithLabelValues("DescribeVpcs", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
+	if err != nil {
+		CheckAPIErrorAndBroadcastEvent(err, "ec2:DescribeVpcs")
+		awsAPIErrInc("DescribeVpcs", err)
+		return VPCDNSConfig{}, errors.Wrapf(err, "failed to describe VPC %s", vpcID)
+	}
+	if len(vpcs.Vpcs) != 1 {
+		return VPCDNSConfig{}, errors.Errorf("VPC %s not found", vpcID)
+	}
+	vpc := vpcs.Vpcs[0]
+	amazonProvidedDNS, err := amazonProvidedDNSAddress(aws.StringValue(vpc.CidrBlock))
+	if err != nil {
+		return VPCDNSConfig{}, err
+	}
+	// A VPC without a DHCP options set uses the Route 53 Resolver
+	dhcpOptionsID := aws.StringValue(vpc.DhcpOptionsId)
+	if dhcpOptionsID == "" || dhcpOptionsID == "default" {
+		return VPCDNSConfig{Nameservers: []string{amazonProvidedDNS}}, nil
+	}
+
+	start = time.Now()
+	dhcpOptions, err := cache.ec2SVC.DescribeDhcpOptionsWithContext(WithCaller(context.Background(), CallerDNSConfig), &ec2.DescribeDhcpOptionsInput{
+		DhcpOptionsIds: []*string{aws.String(dhcpOptionsID)}})
+	awsAPILatency.WithLabelValues("DescribeDhcpOptions", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
+	if err != nil {
+		CheckAPIErrorAndBroadcastEvent(err, "ec2:DescribeDhcpOptions")
+		awsAPIErrInc("DescribeDhcpOptions", err)
+		return VPCDNSConfig{}, errors.Wrapf(err, "failed to describe DHCP options set %s", dhcpOptionsID)
+	}
+	if len(dhcpOptions.DhcpOptions) != 1 {
+		return VPCDNSConfig{}, errors.Errorf("DHCP options set %s not found", dhcpOptionsID)
+	}
+
+	var dnsConfig VPCDNSConfig
+	for _, config := range dhcpOptions.DhcpOptions[0].DhcpConfigurations {
+		for _, value := range config.Values {
+			switch aws.StringValue(config.Key) {
+			case "domain-name-servers":
+				nameserver := aws.StringValue(value.Value)
+				if nameserver == "AmazonProvidedDNS" {
+					nameserver = amazonProvidedDNS
+				}
+				dnsConfig.Nameservers = append(dnsConfig.Nameservers, nameserver)
+			case "domain-name":
+				// The domain name option can hold several domains separated by spaces
+				dnsConfig.Search = append(dnsConfig.Search, strings.Fields(aws.StringValue(value.Value))...)
+			}
+		}
+	}
+	if len(dnsConfig.Search) > 0 {
+		dnsConfig.Domain = dnsConfig.Search[0]
+	}
+	return dnsConfig, nil
+}
+
+// amazonProvidedDNSAddress returns the address of the Route 53 Resolver in the VPC with the primary CIDR vpcCIDR
+func amazonProvidedDNSAddress(vpcCIDR string) (string, error) {
+	_, ipNet, err := net.ParseCIDR(vpcCIDR)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid VPC CIDR %s", vpcCIDR)
+	}
+	ip := ipNet.IP.To4()
+	if ip == nil {
+		return "", errors.Errorf("VPC CIDR %s is not an IPv4 CIDR", vpcCIDR)
+	}
+	resolver := make(net.IP, len(ip))
+	copy(resolver, ip)
+	resolver[3] += 2
+	return resolver.String(), nil
+}
+
 // GetVPCIPv4CIDRs returns VPC CIDRs
 func (cache *EC2InstanceMetadataCache) GetVPCIPv4CIDRs() ([]string, error) {
 	ctx := context.TODO()
@@ -1848,7 +2597,7 @@ func (cache *EC2InstanceMetadataCache) IsUnmanagedENI(eniID string) bool {
 }
 
 func (cache *EC2InstanceMetadataCache) getENIsFromPaginatedDescribeNetworkInterfaces(
-	input *ec2.DescribeNetworkInterfacesInput, filterFn func(networkInterface *ec2.NetworkInterface) error) error {
+	ctx context.Context, input *ec2.DescribeNetworkInterfacesInput, filterFn func(networkInterface *ec2.NetworkInterface) error) error {
 	pageNum := 0
 	var innerErr error
 	pageFn := func(output *ec2.DescribeNetworkInterfacesOutput, lastPage bool) (nextPage bool) {
@@ -1864,7 +2613,7 @@ func (cache *EC2InstanceMetadataCache) getENIsFromPaginatedDescribeNetworkInterf
 		return true
 	}
 
-	if err := cache.ec2SVC.DescribeNetworkInterfacesPagesWithContext(context.TODO(), input, pageFn); err != nil {
+	if err := cache.ec2SVC.DescribeNetworkInterfacesPagesWithContext(ctx, input, pageFn); err != nil {
 		CheckAPIErrorAndBroadcastEvent(err, "ec2:DescribeNetworkInterfaces")
 		awsAPIErrInc("DescribeNetworkInterfaces", err)
 		return err
@@ -1888,6 +2637,19 @@ func (cache *EC2InstanceMetadataCache) IsCNIUnmanagedENI(eniID string) bool {
 	return false
 }
 
+// IsEFAENI returns if the eni is an EFA interface
+func (cache *EC2InstanceMetadataCache) IsEFAENI(eniID string) bool {
+	if len(eniID) != 0 {
+		return cache.efaENIs.Has(eniID)
+	}
+	return false
+}
+
+// GetEFAENIs returns a sorted list of the attached EFA interfaces
+func (cache *EC2InstanceMetadataCache) GetEFAENIs() []string {
+	return cache.efaENIs.SortedList()
+}
+
 // IsPrimaryENI returns if the eni is unmanaged
 func (cache *EC2InstanceMetadataCache) IsPrimaryENI(eniID string) bool {
 	if len(eniID) != 0 && eniID == cache.GetPrimaryENI() {
@@ -17,18 +17,23 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 
 	mock_ec2wrapper "github.com/aws/amazon-vpc-cni-k8s/pkg/ec2wrapper/mocks"
 )
@@ -218,7 +223,7 @@ func TestAWSGetFreeDeviceNumberOnErr(t *testing.T) {
 	mockEC2.EXPECT().DescribeInstancesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("error on DescribeInstancesWithContext"))
 
 	ins := &EC2InstanceMetadataCache{ec2SVC: mockEC2}
-	_, err := ins.awsGetFreeDeviceNumber()
+	_, _, err := ins.awsGetFreeDeviceNumber(context.Background())
 	assert.Error(t, err)
 }
 
@@ -241,7 +246,7 @@ func TestAWSGetFreeDeviceNumberNoDevice(t *testing.T) {
 	mockEC2.EXPECT().DescribeInstancesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(result, nil)
 
 	ins := &EC2InstanceMetadataCache{ec2SVC: mockEC2}
-	_, err := ins.awsGetFreeDeviceNumber()
+	_, _, err := ins.awsGetFreeDeviceNumber(context.Background())
 	assert.Error(t, err)
 }
 
@@ -301,7 +306,7 @@ func TestGetENIAttachmentID(t *testing.T) {
 		mockEC2.EXPECT().DescribeNetworkInterfacesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(tc.output, tc.awsErr)
 
 		ins := &EC2InstanceMetadataCache{ec2SVC: mockEC2}
-		id, err := ins.getENIAttachmentID("test-eni")
+		id, err := ins.getENIAttachmentID(context.Background(), "test-eni")
 		assert.Equal(t, tc.expErr, err)
 		assert.Equal(t, tc.expID, id)
 	}
@@ -344,7 +349,7 @@ func TestDescribeAllENIs(t *testing.T) {
 	for _, tc := range testCases {
 		mockEC2.EXPECT().DescribeNetworkInterfacesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Times(tc.n).Return(result, tc.awsErr)
 		ins := &EC2InstanceMetadataCache{imds: TypedIMDS{mockMetadata}, ec2SVC: mockEC2}
-		metaData, err := ins.DescribeAllENIs()
+		metaData, err := ins.DescribeAllENIs(context.Background())
 		assert.Equal(t, tc.expErr, err, tc.name)
 		assert.Equal(t, tc.exptags, metaData.TagMap, tc.name)
 	}
@@ -384,7 +389,7 @@ func TestAllocENI(t *testing.T) {
 		ec2SVC: mockEC2,
 		imds:   TypedIMDS{mockMetadata},
 	}
-	_, err := ins.AllocENI(false, nil, "")
+	_, err := ins.AllocENI(context.Background(), false, nil, "")
 	assert.NoError(t, err)
 }
 
@@ -417,7 +422,7 @@ func TestAllocENINoFreeDevice(t *testing.T) {
 		ec2SVC: mockEC2,
 		imds:   TypedIMDS{mockMetadata},
 	}
-	_, err := ins.AllocENI(false, nil, "")
+	_, err := ins.AllocENI(context.Background(), false, nil, "")
 	assert.Error(t, err)
 }
 
@@ -452,7 +457,7 @@ func TestAllocENIMaxReached(t *testing.T) {
 		ec2SVC: mockEC2,
 		imds:   TypedIMDS{mockMetadata},
 	}
-	_, err := ins.AllocENI(false, nil, "")
+	_, err := ins.AllocENI(context.Background(), false, nil, "")
 	assert.Error(t, err)
 }
 
@@ -463,13 +468,13 @@ func TestFreeENI(t *testing.T) {
 	attachmentID := eniAttachID
 	attachment := &ec2.NetworkInterfaceAttachment{AttachmentId: &attachmentID}
 	result := &ec2.DescribeNetworkInterfacesOutput{
-		NetworkInterfaces: []*ec2.NetworkInterface{{Attachment: attachment}}}
+		NetworkInterfaces: []*ec2.NetworkInterface{{Attachment: attachment, Description: aws.String(eniDescriptionPrefix + instanceID)}}}
 	mockEC2.EXPECT().DescribeNetworkInterfacesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(result, nil)
 	mockEC2.EXPECT().DetachNetworkInterfaceWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil)
 	mockEC2.EXPECT().DeleteNetworkInterfaceWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil)
 
 	ins := &EC2InstanceMetadataCache{ec2SVC: mockEC2}
-	err := ins.freeENI("test-eni", time.Millisecond, time.Millisecond)
+	err := ins.freeENI(context.Background(), "test-eni", time.Millisecond, time.Millisecond)
 	assert.NoError(t, err)
 }
 
@@ -480,7 +485,7 @@ func TestFreeENIRetry(t *testing.T) {
 	attachmentID := eniAttachID
 	attachment := &ec2.NetworkInterfaceAttachment{AttachmentId: &attachmentID}
 	result := &ec2.DescribeNetworkInterfacesOutput{
-		NetworkInterfaces: []*ec2.NetworkInterface{{Attachment: attachment}}}
+		NetworkInterfaces: []*ec2.NetworkInterface{{Attachment: attachment, Description: aws.String(eniDescriptionPrefix + instanceID)}}}
 	mockEC2.EXPECT().DescribeNetworkInterfacesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(result, nil)
 
 	// retry 2 times
@@ -489,7 +494,7 @@ func TestFreeENIRetry(t *testing.T) {
 	mockEC2.EXPECT().DeleteNetworkInterfaceWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil)
 
 	ins := &EC2InstanceMetadataCache{ec2SVC: mockEC2}
-	err := ins.freeENI("test-eni", time.Millisecond, time.Millisecond)
+	err := ins.freeENI(context.Background(), "test-eni", time.Millisecond, time.Millisecond)
 	assert.NoError(t, err)
 }
 
@@ -500,7 +505,7 @@ func TestFreeENIRetryMax(t *testing.T) {
 	attachmentID := eniAttachID
 	attachment := &ec2.NetworkInterfaceAttachment{AttachmentId: &attachmentID}
 	result := &ec2.DescribeNetworkInterfacesOutput{
-		NetworkInterfaces: []*ec2.NetworkInterface{{Attachment: attachment}}}
+		NetworkInterfaces: []*ec2.NetworkInterface{{Attachment: attachment, Description: aws.String(eniDescriptionPrefix + instanceID)}}}
 	mockEC2.EXPECT().DescribeNetworkInterfacesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(result, nil)
 	mockEC2.EXPECT().DetachNetworkInterfaceWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil)
 
@@ -509,10 +514,65 @@ func TestFreeENIRetryMax(t *testing.T) {
 	}
 
 	ins := &EC2InstanceMetadataCache{ec2SVC: mockEC2}
-	err := ins.freeENI("test-eni", time.Millisecond, time.Millisecond)
+	err := ins.freeENI(context.Background(), "test-eni", time.Millisecond, time.Millisecond)
 	assert.Error(t, err)
 }
 
+func TestFreeENIRetryBudget(t *testing.T) {
+	ctrl, mockEC2 := setup(t)
+	defer ctrl.Finish()
+
+	attachmentID := eniAttachID
+	attachment := &ec2.NetworkInterfaceAttachment{AttachmentId: &attachmentID}
+	result := &ec2.DescribeNetworkInterfacesOutput{
+		NetworkInterfaces: []*ec2.NetworkInterface{{Attachment: attachment, Description: aws.String(eniDescriptionPrefix + instanceID)}}}
+	mockEC2.EXPECT().DescribeNetworkInterfacesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(result, nil)
+	mockEC2.EXPECT().DetachNetworkInterfaceWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil)
+
+	// The leaked ENI cleanup leaves half of the budget to the others, 5 retries after the first attempt
+	deleteErr := errors.New("testing retrying delete")
+	mockEC2.EXPECT().DeleteNetworkInterfaceWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, deleteErr).Times(6)
+
+	now := time.Now()
+	ins := &EC2InstanceMetadataCache{ec2SVC: mockEC2, retryBudget: newRetryBudget(10, func() time.Time { return now })}
+	ctx := WithCaller(context.Background(), CallerLeakedENICleanup)
+	denied := testutil.ToFloat64(ec2RetriesDenied.WithLabelValues("DeleteNetworkInterface", CallerLeakedENICleanup))
+	err := ins.freeENI(ctx, "test-eni", time.Millisecond, time.Millisecond)
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, deleteErr))
+	assert.Equal(t, denied+1, testutil.ToFloat64(ec2RetriesDenied.WithLabelValues("DeleteNetworkInterface", CallerLeakedENICleanup)))
+}
+
+func TestFreeENINotOwned(t *testing.T) {
+	ctrl, mockEC2 := setup(t)
+	defer ctrl.Finish()
+
+	ins := &EC2InstanceMetadataCache{ec2SVC: mockEC2, clusterName: "prod"}
+	attachment := &ec2.NetworkInterfaceAttachment{AttachmentId: aws.String(eniAttachID)}
+	for _, eni := range []*ec2.NetworkInterface{
+		// Attached by another controller
+		{Attachment: attachment, Description: aws.String("efs-mount-target")},
+		// Created by the CNI of another cluster
+		{Attachment: attachment, Description: aws.String(eniDescriptionPrefix + instanceID),
+			TagSet: []*ec2.Tag{{Key: aws.String(eniClusterTagKey), Value: aws.String("staging")}}},
+	} {
+		mockEC2.EXPECT().DescribeNetworkInterfacesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(&ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: []*ec2.NetworkInterface{eni}}, nil)
+		err := ins.freeENI(context.Background(), "test-eni", time.Millisecond, time.Millisecond)
+		assert.True(t, errors.Is(err, ErrENINotOwned))
+	}
+
+	// Created by the CNI of this cluster
+	mockEC2.EXPECT().DescribeNetworkInterfacesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(&ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: []*ec2.NetworkInterface{{
+			Attachment: attachment, Description: aws.String(eniDescriptionPrefix + instanceID),
+			TagSet: []*ec2.Tag{{Key: aws.String(eniClusterTagKey), Value: aws.String("prod")}},
+		}}}, nil)
+	mockEC2.EXPECT().DetachNetworkInterfaceWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil)
+	mockEC2.EXPECT().DeleteNetworkInterfaceWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil)
+	assert.NoError(t, ins.freeENI(context.Background(), "test-eni", time.Millisecond, time.Millisecond))
+}
+
 func TestFreeENIDescribeErr(t *testing.T) {
 	ctrl, mockEC2 := setup(t)
 	defer ctrl.Finish()
@@ -520,7 +580,7 @@ func TestFreeENIDescribeErr(t *testing.T) {
 	mockEC2.EXPECT().DescribeNetworkInterfacesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("Error on DescribeNetworkInterfacesWithContext"))
 
 	ins := &EC2InstanceMetadataCache{ec2SVC: mockEC2}
-	err := ins.FreeENI("test-eni")
+	err := ins.FreeENI(context.Background(), "test-eni")
 	assert.Error(t, err)
 }
 
@@ -547,6 +607,201 @@ func TestDescribeInstanceTypes(t *testing.T) {
 	assert.Equal(t, 98, pv4Limit)
 }
 
+func TestFetchInstanceTypeLimitsPersisted(t *testing.T) {
+	ctrl, mockEC2 := setup(t)
+	defer ctrl.Finish()
+
+	limitsFile := filepath.Join(t.TempDir(), "instance-type-limits.json")
+	mockEC2.EXPECT().DescribeInstanceTypesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(&ec2.DescribeInstanceTypesOutput{
+		InstanceTypes: []*ec2.InstanceTypeInfo{
+			{InstanceType: aws.String("not-there"), Hypervisor: aws.String("nitro"), NetworkInfo: &ec2.NetworkInfo{
+				MaximumNetworkInterfaces:  aws.Int64(9),
+				Ipv4AddressesPerInterface: aws.Int64(99)},
+			},
+		},
+	}, nil)
+	ins := &EC2InstanceMetadataCache{ec2SVC: mockEC2, instanceType: "not-there", instanceTypeLimitsFile: limitsFile}
+	assert.NoError(t, ins.FetchInstanceTypeLimits())
+
+	// A restart without access to EC2 uses the persisted limits
+	mockEC2.EXPECT().DescribeInstanceTypesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("no EC2"))
+	ins = &EC2InstanceMetadataCache{ec2SVC: mockEC2, instanceType: "not-there", instanceTypeLimitsFile: limitsFile}
+	assert.NoError(t, ins.FetchInstanceTypeLimits())
+	assert.Equal(t, 9, ins.GetENILimit())
+	assert.Equal(t, 98, ins.GetENIIPv4Limit())
+	assert.Equal(t, "nitro", ins.GetInstanceHypervisorFamily())
+
+	// Limits persisted for another instance type are ignored
+	mockEC2.EXPECT().DescribeInstanceTypesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("no EC2"))
+	ins = &EC2InstanceMetadataCache{ec2SVC: mockEC2, instanceType: "not-there-either", instanceTypeLimitsFile: limitsFile}
+	assert.Error(t, ins.FetchInstanceTypeLimits())
+}
+
+func TestFetchInstanceTypeLimitsVendoredFallback(t *testing.T) {
+	ctrl, mockEC2 := setup(t)
+	defer ctrl.Finish()
+
+	mockEC2.EXPECT().DescribeInstanceTypesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("no EC2"))
+	ins := &EC2InstanceMetadataCache{ec2SVC: mockEC2, instanceType: "t3.xlarge",
+		instanceTypeLimitsFile: filepath.Join(t.TempDir(), "instance-type-limits.json")}
+	assert.NoError(t, ins.FetchInstanceTypeLimits())
+	assert.Equal(t, InstanceNetworkingLimits["t3.xlarge"].ENILimit, ins.GetENILimit())
+
+	// DescribeInstanceTypes takes precedence over the vendored table
+	mockEC2.EXPECT().DescribeInstanceTypesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(&ec2.DescribeInstanceTypesOutput{
+		InstanceTypes: []*ec2.InstanceTypeInfo{
+			{InstanceType: aws.String("t3.xlarge"), NetworkInfo: &ec2.NetworkInfo{
+				MaximumNetworkInterfaces:  aws.Int64(5),
+				Ipv4AddressesPerInterface: aws.Int64(15)},
+			},
+		},
+	}, nil)
+	assert.NoError(t, ins.FetchInstanceTypeLimits())
+	assert.Equal(t, 5, ins.GetENILimit())
+}
+
+func TestGetInstanceTypeLimits(t *testing.T) {
+	ctrl, mockEC2 := setup(t)
+	defer ctrl.Finish()
+
+	ins := &EC2InstanceMetadataCache{ec2SVC: mockEC2, instanceType: "m5.large",
+		instanceTypeLimits: &InstanceTypeLimits{ENILimit: 3, IPv4Limit: 10}}
+
+	// This instance uses the limits found at startup
+	limits, err := ins.GetInstanceTypeLimits(context.Background(), "m5.large")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, limits.ENILimit)
+
+	// Another instance type missing from the vendored table is looked up with EC2, then found again without it
+	mockEC2.EXPECT().DescribeInstanceTypesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(&ec2.DescribeInstanceTypesOutput{
+		InstanceTypes: []*ec2.InstanceTypeInfo{
+			{InstanceType: aws.String("not-there"), NetworkInfo: &ec2.NetworkInfo{
+				MaximumNetworkInterfaces:  aws.Int64(9),
+				Ipv4AddressesPerInterface: aws.Int64(99)},
+			},
+		},
+	}, nil)
+	limits, err = ins.GetInstanceTypeLimits(context.Background(), "not-there")
+	assert.NoError(t, err)
+	assert.Equal(t, 9, limits.ENILimit)
+
+	mockEC2.EXPECT().DescribeInstanceTypesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("no EC2")).Times(3)
+	limits, err = ins.GetInstanceTypeLimits(context.Background(), "not-there")
+	assert.NoError(t, err)
+	assert.Equal(t, 99, limits.IPv4Limit)
+
+	// Then the vendored table
+	limits, err = ins.GetInstanceTypeLimits(context.Background(), "t3.xlarge")
+	assert.NoError(t, err)
+	assert.Equal(t, InstanceNetworkingLimits["t3.xlarge"].ENILimit, limits.ENILimit)
+
+	_, err = ins.GetInstanceTypeLimits(context.Background(), "not-there-either")
+	assert.Error(t, err)
+}
+
+func TestFetchInstanceTypeLimitsMultiCard(t *testing.T) {
+	ctrl, mockEC2 := setup(t)
+	defer ctrl.Finish()
+
+	networkCards := make([]*ec2.NetworkCardInfo, 4)
+	for i := range networkCards {
+		networkCards[i] = &ec2.NetworkCardInfo{NetworkCardIndex: aws.Int64(int64(i)), MaximumNetworkInterfaces: aws.Int64(15)}
+	}
+	mockEC2.EXPECT().DescribeInstanceTypesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(&ec2.DescribeInstanceTypesOutput{
+		InstanceTypes: []*ec2.InstanceTypeInfo{
+			{InstanceType: aws.String("p4d.24xlarge"), NetworkInfo: &ec2.NetworkInfo{
+				MaximumNetworkInterfaces:  aws.Int64(60),
+				Ipv4AddressesPerInterface: aws.Int64(50),
+				DefaultNetworkCardIndex:   aws.Int64(0),
+				NetworkCards:              networkCards},
+			},
+		},
+	}, nil)
+	ins := &EC2InstanceMetadataCache{ec2SVC: mockEC2, instanceType: "p4d.24xlarge"}
+	assert.NoError(t, ins.FetchInstanceTypeLimits())
+	assert.Equal(t, 4, ins.instanceTypeLimits.NetworkCards)
+	// Only the first network card is used by default
+	assert.Equal(t, 15, ins.GetENILimit())
+
+	ins.InitCachedMultiCardENIs(true)
+	assert.Equal(t, 60, ins.GetENILimit())
+	assert.Equal(t, 3, ins.deviceNumber(0, 3))
+	assert.Equal(t, 33, ins.deviceNumber(2, 3))
+
+	// Route tables of the ENIs have to stay below the ones of the branch ENIs
+	ins.instanceTypeLimits = &InstanceTypeLimits{ENILimit: 15, IPv4Limit: 50, NetworkCards: 8}
+	assert.Equal(t, 6, ins.managedNetworkCards())
+}
+
+func TestAWSGetFreeDeviceNumberMultiCard(t *testing.T) {
+	ctrl, mockEC2 := setup(t)
+	defer ctrl.Finish()
+
+	attachment := func(networkCard, deviceIndex int64) *ec2.InstanceNetworkInterface {
+		return &ec2.InstanceNetworkInterface{Attachment: &ec2.InstanceNetworkInterfaceAttachment{
+			NetworkCardIndex: aws.Int64(networkCard), DeviceIndex: aws.Int64(deviceIndex)}}
+	}
+	result := &ec2.DescribeInstancesOutput{
+		Reservations: []*ec2.Reservation{{Instances: []*ec2.Instance{{NetworkInterfaces: []*ec2.InstanceNetworkInterface{
+			attachment(0, 0), attachment(0, 1), attachment(1, 0),
+		}}}}}}
+	ins := &EC2InstanceMetadataCache{ec2SVC: mockEC2,
+		instanceTypeLimits: &InstanceTypeLimits{ENILimit: 2, IPv4Limit: 50, NetworkCards: 2}}
+
+	mockEC2.EXPECT().DescribeInstancesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(result, nil)
+	networkCard, deviceIndex, err := ins.awsGetFreeDeviceNumber(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, networkCard)
+	assert.Equal(t, 2, deviceIndex)
+
+	ins.InitCachedMultiCardENIs(true)
+	mockEC2.EXPECT().DescribeInstancesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(result, nil)
+	networkCard, deviceIndex, err = ins.awsGetFreeDeviceNumber(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, networkCard)
+	assert.Equal(t, 1, deviceIndex)
+}
+
+func TestGetVPCDNSConfig(t *testing.T) {
+	ctrl, mockEC2 := setup(t)
+	defer ctrl.Finish()
+
+	mockMetadata := testMetadata(map[string]interface{}{
+		metadataMACPath + primaryMAC + "/vpc-id": "vpc-0123456789",
+	})
+	ins := &EC2InstanceMetadataCache{imds: TypedIMDS{mockMetadata}, ec2SVC: mockEC2, primaryENImac: primaryMAC}
+	vpc := &ec2.Vpc{VpcId: aws.String("vpc-0123456789"), CidrBlock: aws.String("10.0.0.0/16"), DhcpOptionsId: aws.String("dopt-1")}
+	mockEC2.EXPECT().DescribeVpcsWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(
+		&ec2.DescribeVpcsOutput{Vpcs: []*ec2.Vpc{vpc}}, nil)
+	mockEC2.EXPECT().DescribeDhcpOptionsWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(
+		&ec2.DescribeDhcpOptionsOutput{DhcpOptions: []*ec2.DhcpOptions{{DhcpConfigurations: []*ec2.DhcpConfiguration{
+			{Key: aws.String("domain-name-servers"), Values: []*ec2.AttributeValue{
+				{Value: aws.String("AmazonProvidedDNS")}, {Value: aws.String("10.1.0.53")}}},
+			{Key: aws.String("domain-name"), Values: []*ec2.AttributeValue{{Value: aws.String("corp.example.com ec2.internal")}}},
+			{Key: aws.String("ntp-servers"), Values: []*ec2.AttributeValue{{Value: aws.String("169.254.169.123")}}},
+		}}}}, nil)
+
+	dnsConfig, err := ins.GetVPCDNSConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, VPCDNSConfig{
+		Nameservers: []string{"10.0.0.2", "10.1.0.53"},
+		Domain:      "corp.example.com",
+		Search:      []string{"corp.example.com", "ec2.internal"},
+	}, dnsConfig)
+
+	// A VPC without DHCP options set uses the Route 53 Resolver
+	vpc.DhcpOptionsId = aws.String("default")
+	mockEC2.EXPECT().DescribeVpcsWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(
+		&ec2.DescribeVpcsOutput{Vpcs: []*ec2.Vpc{vpc}}, nil)
+	dnsConfig, err = ins.GetVPCDNSConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, VPCDNSConfig{Nameservers: []string{"10.0.0.2"}}, dnsConfig)
+
+	mockEC2.EXPECT().DescribeVpcsWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("no EC2"))
+	_, err = ins.GetVPCDNSConfig()
+	assert.Error(t, err)
+}
+
 func TestAllocIPAddress(t *testing.T) {
 	ctrl, mockEC2 := setup(t)
 	defer ctrl.Finish()
@@ -554,7 +809,7 @@ func TestAllocIPAddress(t *testing.T) {
 	mockEC2.EXPECT().AssignPrivateIpAddressesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(&ec2.AssignPrivateIpAddressesOutput{}, nil)
 
 	ins := &EC2InstanceMetadataCache{ec2SVC: mockEC2}
-	err := ins.AllocIPAddress("eni-id")
+	err := ins.AllocIPAddress(context.Background(), "eni-id")
 	assert.NoError(t, err)
 }
 
@@ -565,7 +820,7 @@ func TestAllocIPAddressOnErr(t *testing.T) {
 	mockEC2.EXPECT().AssignPrivateIpAddressesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("Error on AssignPrivateIpAddressesWithContext"))
 
 	ins := &EC2InstanceMetadataCache{ec2SVC: mockEC2}
-	err := ins.AllocIPAddress("eni-id")
+	err := ins.AllocIPAddress(context.Background(), "eni-id")
 	assert.Error(t, err)
 }
 
@@ -581,7 +836,7 @@ func TestAllocIPAddresses(t *testing.T) {
 	mockEC2.EXPECT().AssignPrivateIpAddressesWithContext(gomock.Any(), input, gomock.Any()).Return(nil, nil)
 
 	ins := &EC2InstanceMetadataCache{ec2SVC: mockEC2, instanceType: "c5n.18xlarge"}
-	_, err := ins.AllocIPAddresses(eniID, 5)
+	_, err := ins.AllocIPAddresses(context.Background(), eniID, 5)
 	assert.NoError(t, err)
 
 	// when required IP numbers(50) is higher than ENI's limit(49)
@@ -597,14 +852,65 @@ func TestAllocIPAddresses(t *testing.T) {
 	mockEC2.EXPECT().AssignPrivateIpAddressesWithContext(gomock.Any(), input, gomock.Any()).Return(&output, nil)
 
 	ins = &EC2InstanceMetadataCache{ec2SVC: mockEC2, instanceType: "c5n.18xlarge"}
-	_, err = ins.AllocIPAddresses(eniID, 50)
+	_, err = ins.AllocIPAddresses(context.Background(), eniID, 50)
 	assert.NoError(t, err)
 
 	// Adding 0 should do nothing
-	_, err = ins.AllocIPAddresses(eniID, 0)
+	_, err = ins.AllocIPAddresses(context.Background(), eniID, 0)
+	assert.NoError(t, err)
+}
+
+func TestENISecurityGroups(t *testing.T) {
+	ctrl, mockEC2 := setup(t)
+	defer ctrl.Finish()
+
+	ins := &EC2InstanceMetadataCache{imds: TypedIMDS{testMetadata(nil)}, ec2SVC: mockEC2}
+	sgIDs, err := ins.GetENISecurityGroups(context.Background(), primaryMAC)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{sg1, sg2}, sgIDs)
+
+	input := &ec2.ModifyNetworkInterfaceAttributeInput{
+		Groups:             aws.StringSlice([]string{sg1}),
+		NetworkInterfaceId: aws.String(eniID),
+	}
+	mockEC2.EXPECT().ModifyNetworkInterfaceAttributeWithContext(gomock.Any(), input, gomock.Any()).Return(nil, nil)
+	assert.NoError(t, ins.SetENISecurityGroups(context.Background(), eniID, []string{sg1}))
+	mockEC2.EXPECT().ModifyNetworkInterfaceAttributeWithContext(gomock.Any(), input, gomock.Any()).Return(nil, errors.New("InvalidGroup.NotFound"))
+	assert.Error(t, ins.SetENISecurityGroups(context.Background(), eniID, []string{sg1}))
+}
+
+func TestAllocSecondaryIPAddresses(t *testing.T) {
+	ctrl, mockEC2 := setup(t)
+	defer ctrl.Finish()
+
+	// Secondary IPs are asked for even with prefix delegation enabled
+	input := &ec2.AssignPrivateIpAddressesInput{
+		NetworkInterfaceId:             aws.String(eniID),
+		SecondaryPrivateIpAddressCount: aws.Int64(16),
+	}
+	mockEC2.EXPECT().AssignPrivateIpAddressesWithContext(gomock.Any(), input, gomock.Any()).Return(&ec2.AssignPrivateIpAddressesOutput{}, nil)
+
+	ins := &EC2InstanceMetadataCache{ec2SVC: mockEC2, instanceType: "c5n.18xlarge", enablePrefixDelegation: true}
+	_, err := ins.AllocSecondaryIPAddresses(context.Background(), eniID, 16)
 	assert.NoError(t, err)
 }
 
+func TestAllocIPAddressesByIP(t *testing.T) {
+	ctrl, mockEC2 := setup(t)
+	defer ctrl.Finish()
+
+	input := &ec2.AssignPrivateIpAddressesInput{
+		NetworkInterfaceId: aws.String(eniID),
+		PrivateIpAddresses: aws.StringSlice([]string{"10.0.0.10", "10.0.0.11"}),
+	}
+	mockEC2.EXPECT().AssignPrivateIpAddressesWithContext(gomock.Any(), input, gomock.Any()).Return(nil, nil)
+	mockEC2.EXPECT().AssignPrivateIpAddressesWithContext(gomock.Any(), input, gomock.Any()).Return(nil, errors.New("PrivateIpAddressInUse"))
+
+	ins := &EC2InstanceMetadataCache{ec2SVC: mockEC2, instanceType: "c5n.18xlarge"}
+	assert.NoError(t, ins.AllocIPAddressesByIP(context.Background(), eniID, []string{"10.0.0.10", "10.0.0.11"}))
+	assert.Error(t, ins.AllocIPAddressesByIP(context.Background(), eniID, []string{"10.0.0.10", "10.0.0.11"}))
+}
+
 func TestAllocIPAddressesAlreadyFull(t *testing.T) {
 	ctrl, mockEC2 := setup(t)
 	defer ctrl.Finish()
@@ -618,7 +924,7 @@ func TestAllocIPAddressesAlreadyFull(t *testing.T) {
 	retErr := awserr.New("PrivateIpAddressLimitExceeded", "Too many IPs already allocated", nil)
 	mockEC2.EXPECT().AssignPrivateIpAddressesWithContext(gomock.Any(), input, gomock.Any()).Return(nil, retErr)
 	// If EC2 says that all IPs are already attached, we do nothing
-	_, err := ins.AllocIPAddresses(eniID, 14)
+	_, err := ins.AllocIPAddresses(context.Background(), eniID, 14)
 	assert.NoError(t, err)
 }
 
@@ -634,11 +940,11 @@ func TestAllocPrefixAddresses(t *testing.T) {
 	mockEC2.EXPECT().AssignPrivateIpAddressesWithContext(gomock.Any(), input, gomock.Any()).Return(nil, nil)
 
 	ins := &EC2InstanceMetadataCache{ec2SVC: mockEC2, instanceType: "c5n.18xlarge", enablePrefixDelegation: true}
-	_, err := ins.AllocIPAddresses(eniID, 1)
+	_, err := ins.AllocIPAddresses(context.Background(), eniID, 1)
 	assert.NoError(t, err)
 
 	// Adding 0 should do nothing
-	_, err = ins.AllocIPAddresses(eniID, 0)
+	_, err = ins.AllocIPAddresses(context.Background(), eniID, 0)
 	assert.NoError(t, err)
 }
 
@@ -655,7 +961,7 @@ func TestAllocPrefixesAlreadyFull(t *testing.T) {
 	retErr := awserr.New("PrivateIpAddressLimitExceeded", "Too many IPs already allocated", nil)
 	mockEC2.EXPECT().AssignPrivateIpAddressesWithContext(gomock.Any(), input, gomock.Any()).Return(nil, retErr)
 	// If EC2 says that all IPs are already attached, we do nothing
-	_, err := ins.AllocIPAddresses(eniID, 1)
+	_, err := ins.AllocIPAddresses(context.Background(), eniID, 1)
 	assert.NoError(t, err)
 }
 
@@ -742,8 +1048,9 @@ func TestEC2InstanceMetadataCache_waitForENIAndIPsAttached(t *testing.T) {
 				metadataMACPath + eni2MAC + metadataSubnetCIDR: subnetCIDR,
 				metadataMACPath + eni2MAC + metadataIPv4s:      eniIPs,
 			})
-			cache := &EC2InstanceMetadataCache{imds: TypedIMDS{mockMetadata}, ec2SVC: mockEC2}
-			gotEniMetadata, err := cache.waitForENIAndIPsAttached(tt.args.eni, tt.args.wantedSecondaryIPs, tt.args.maxBackoffDelay)
+			expectENIFromEC2(mockEC2, strings.Fields(eniIPs), nil, nil)
+			cache := &EC2InstanceMetadataCache{imds: TypedIMDS{mockMetadata}, ec2SVC: mockEC2, instanceID: instanceID}
+			gotEniMetadata, err := cache.waitForENIAndIPsAttached(context.Background(), tt.args.eni, tt.args.wantedSecondaryIPs, tt.args.maxBackoffDelay)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("waitForENIAndIPsAttached() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -837,9 +1144,14 @@ func TestEC2InstanceMetadataCache_waitForENIAndPrefixesAttached(t *testing.T) {
 				metadataMACPath + eni2MAC + metadataIPv4s:      eniIPs,
 				metadataMACPath + eni2MAC + metaDataPrefixPath: eniPrefixes,
 			})
-			cache := &EC2InstanceMetadataCache{imds: TypedIMDS{mockMetadata}, ec2SVC: mockEC2,
+			if tt.args.v6Enabled {
+				expectENIFromEC2(mockEC2, []string{eniIPs}, nil, strings.Fields(eniPrefixes))
+			} else {
+				expectENIFromEC2(mockEC2, []string{eniIPs}, strings.Fields(eniPrefixes), nil)
+			}
+			cache := &EC2InstanceMetadataCache{imds: TypedIMDS{mockMetadata}, ec2SVC: mockEC2, instanceID: instanceID,
 				enablePrefixDelegation: true, v4Enabled: tt.args.v4Enabled, v6Enabled: tt.args.v6Enabled}
-			gotEniMetadata, err := cache.waitForENIAndIPsAttached(tt.args.eni, tt.args.wantedSecondaryIPs, tt.args.maxBackoffDelay)
+			gotEniMetadata, err := cache.waitForENIAndIPsAttached(context.Background(), tt.args.eni, tt.args.wantedSecondaryIPs, tt.args.maxBackoffDelay)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("waitForENIAndIPsAttached() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -950,7 +1262,7 @@ func TestEC2InstanceMetadataCache_buildENITags(t *testing.T) {
 				clusterName:       tt.fields.clusterName,
 				additionalENITags: tt.fields.additionalENITags,
 			}
-			got := cache.buildENITags()
+			got := cache.buildENITags(context.Background())
 			assert.Equal(t, tt.want, got)
 		})
 	}
@@ -1383,7 +1695,7 @@ func TestEC2InstanceMetadataCache_getLeakedENIs(t *testing.T) {
 					})
 			}
 			ins := &EC2InstanceMetadataCache{ec2SVC: mockEC2, clusterName: tt.fields.clusterName}
-			got, err := ins.getLeakedENIs()
+			got, err := ins.getLeakedENIs(context.Background())
 			if tt.wantErr != nil {
 				assert.EqualError(t, err, tt.wantErr.Error())
 			} else {
@@ -1535,7 +1847,7 @@ func TestEC2InstanceMetadataCache_TagENI(t *testing.T) {
 				clusterName:       tt.fields.clusterName,
 				additionalENITags: tt.fields.additionalENITags,
 			}
-			err := cache.TagENI(tt.args.eniID, tt.args.currentTags)
+			err := cache.TagENI(context.Background(), tt.args.eniID, tt.args.currentTags)
 			if tt.wantErr != nil {
 				assert.EqualError(t, err, tt.wantErr.Error())
 			} else {
@@ -1717,3 +2029,57 @@ func Test_loadAdditionalENITags(t *testing.T) {
 		})
 	}
 }
+
+func TestEC2InstanceMetadataCache_CheckEC2Permissions(t *testing.T) {
+	ctrl, mockEC2 := setup(t)
+	defer ctrl.Finish()
+
+	dryRunOK := awserr.New("DryRunOperation", "Request would have succeeded", nil)
+	unauthorized := awserr.New("UnauthorizedOperation", "You are not authorized to perform this operation", nil)
+	mockEC2.EXPECT().DescribeNetworkInterfacesWithContext(gomock.Any(), gomock.Any()).Return(nil, dryRunOK)
+	mockEC2.EXPECT().CreateNetworkInterfaceWithContext(gomock.Any(), gomock.Any()).Return(nil, unauthorized)
+	mockEC2.EXPECT().AttachNetworkInterfaceWithContext(gomock.Any(), gomock.Any()).Return(nil, dryRunOK)
+	mockEC2.EXPECT().DeleteNetworkInterfaceWithContext(gomock.Any(), gomock.Any()).Return(nil, dryRunOK)
+	mockEC2.EXPECT().CreateTagsWithContext(gomock.Any(), gomock.Any()).Return(nil, errors.New("throttled"))
+
+	ins := &EC2InstanceMetadataCache{ec2SVC: mockEC2, primaryENI: primaryeniID, instanceID: instanceID, subnetID: subnetID}
+	denied := ins.CheckEC2Permissions(true)
+	assert.Equal(t, 1, len(denied))
+	assert.Contains(t, denied, "ec2:CreateNetworkInterface")
+}
+
+func TestEC2InstanceMetadataCache_RecordEC2Reachability(t *testing.T) {
+	cache := &EC2InstanceMetadataCache{}
+	sendErr := awserr.New(request.ErrCodeRequestError, "send request failed", errors.New("dial tcp: i/o timeout"))
+
+	cache.recordEC2Reachability(&request.Request{Error: sendErr, HTTPResponse: &http.Response{StatusCode: 0}})
+	assert.Equal(t, sendErr, cache.GetEC2ReachabilityError())
+
+	// A canceled call tells nothing about EC2
+	cache.recordEC2Reachability(&request.Request{Error: awserr.New(request.CanceledErrorCode, "request context canceled", context.Canceled)})
+	assert.Equal(t, sendErr, cache.GetEC2ReachabilityError())
+
+	// Throttling is an answer from EC2
+	cache.recordEC2Reachability(&request.Request{Error: awserr.New("RequestLimitExceeded", "throttled", nil),
+		HTTPResponse: &http.Response{StatusCode: http.StatusBadRequest}})
+	assert.NoError(t, cache.GetEC2ReachabilityError())
+
+	serverErr := awserr.New("InternalError", "internal error", nil)
+	cache.recordEC2Reachability(&request.Request{Error: serverErr, HTTPResponse: &http.Response{StatusCode: http.StatusInternalServerError}})
+	assert.Equal(t, serverErr, cache.GetEC2ReachabilityError())
+
+	cache.recordEC2Reachability(&request.Request{HTTPResponse: &http.Response{StatusCode: http.StatusOK}})
+	assert.NoError(t, cache.GetEC2ReachabilityError())
+}
+
+func TestEC2InstanceMetadataCache_RecordEC2Throttle(t *testing.T) {
+	cache := &EC2InstanceMetadataCache{}
+	assert.True(t, cache.GetLastEC2Throttle().IsZero())
+
+	cache.recordEC2Throttle(&request.Request{Error: awserr.New("UnauthorizedOperation", "denied", nil)})
+	assert.True(t, cache.GetLastEC2Throttle().IsZero())
+
+	before := time.Now()
+	cache.recordEC2Throttle(&request.Request{Error: awserr.New("RequestLimitExceeded", "throttled", nil)})
+	assert.False(t, cache.GetLastEC2Throttle().Before(before))
+}
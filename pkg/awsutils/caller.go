@@ -0,0 +1,63 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package awsutils
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The subsystems of ipamd that call EC2, used as the caller label of awscni_ec2api_calls_by_caller
+const (
+	CallerStartup          = "startup"
+	CallerReconciler       = "reconciler"
+	CallerScaleUp          = "scale-up"
+	CallerScaleDown        = "scale-down"
+	CallerBranchENI        = "branch-eni"
+	CallerLeakedENICleanup = "leaked-eni-cleanup"
+	CallerDNSConfig        = "dns-config"
+	CallerSubnetProbe      = "subnet-prefix-probe"
+	CallerMaxPods          = "max-pods"
+	CallerUnknown          = "unknown"
+)
+
+var ec2APICallsByCaller = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "awscni_ec2api_calls_by_caller",
+		Help: "The number of EC2 API requests sent, including retries, by API and by the ipamd subsystem making them",
+	},
+	[]string{"api", "caller"},
+)
+
+type callerKey struct{}
+
+// WithCaller returns a copy of ctx that attributes the EC2 calls made with it to caller
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFromContext returns the caller set on ctx by WithCaller, or CallerUnknown
+func CallerFromContext(ctx context.Context) string {
+	if caller, ok := ctx.Value(callerKey{}).(string); ok {
+		return caller
+	}
+	return CallerUnknown
+}
+
+// recordEC2Caller counts each attempt of an EC2 request, since retries use up the API quota as well
+func recordEC2Caller(r *request.Request) {
+	ec2APICallsByCaller.WithLabelValues(r.Operation.Name, CallerFromContext(r.Context())).Inc()
+}
@@ -0,0 +1,55 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package awsutils
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallerFromContext(t *testing.T) {
+	assert.Equal(t, CallerUnknown, CallerFromContext(context.Background()))
+	assert.Equal(t, CallerScaleUp, CallerFromContext(WithCaller(context.Background(), CallerScaleUp)))
+	// The innermost caller wins
+	ctx := WithCaller(WithCaller(context.Background(), CallerReconciler), CallerBranchENI)
+	assert.Equal(t, CallerBranchENI, CallerFromContext(ctx))
+}
+
+func TestRecordEC2Caller(t *testing.T) {
+	calls := func(api, caller string) float64 {
+		return testutil.ToFloat64(ec2APICallsByCaller.WithLabelValues(api, caller))
+	}
+	newRequest := func(ctx context.Context) *request.Request {
+		r := &request.Request{Operation: &request.Operation{Name: "AssignPrivateIpAddresses"}, HTTPRequest: &http.Request{}}
+		if ctx != nil {
+			r.SetContext(ctx)
+		}
+		return r
+	}
+	scaleUp, unknown := calls("AssignPrivateIpAddresses", CallerScaleUp), calls("AssignPrivateIpAddresses", CallerUnknown)
+
+	// Each attempt of a request is counted
+	r := newRequest(WithCaller(context.Background(), CallerScaleUp))
+	recordEC2Caller(r)
+	recordEC2Caller(r)
+	assert.Equal(t, scaleUp+2, calls("AssignPrivateIpAddresses", CallerScaleUp))
+
+	recordEC2Caller(newRequest(nil))
+	assert.Equal(t, unknown+1, calls("AssignPrivateIpAddresses", CallerUnknown))
+}
@@ -0,0 +1,132 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package awsutils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+)
+
+// nodeGroupTagKey is the instance tag holding the name of the EKS managed node group of the instance
+const nodeGroupTagKey = "eks:nodegroup-name"
+
+// ENITagData is the data that the values of ADDITIONAL_ENI_TAGS can use as Go templates, for instance
+// {"cost-center": "{{.ClusterName}}/{{.NodeGroup}}"}
+type ENITagData struct {
+	ClusterName      string
+	NodeName         string
+	NodeGroup        string
+	AvailabilityZone string
+	InstanceID       string
+	InstanceType     string
+	// Reason is the ipamd subsystem that created the ENI, scale-up for the warm pool or branch-eni for an ENI dedicated
+	// to a pod, or the one that tagged an ENI that it did not create
+	Reason string
+	// PodNamespace and PodName are the pod an ENI is dedicated to, empty for the ENIs of the warm pool
+	PodNamespace string
+	PodName      string
+}
+
+type podKey struct{}
+
+type podName struct {
+	namespace, name string
+}
+
+// WithPod returns a copy of ctx for the EC2 calls made on behalf of a pod, so that the ENIs created with it can be
+// tagged with the pod namespace and name
+func WithPod(ctx context.Context, namespace, name string) context.Context {
+	return context.WithValue(ctx, podKey{}, podName{namespace: namespace, name: name})
+}
+
+// isENITagTemplate returns true if an ADDITIONAL_ENI_TAGS value is a template
+func isENITagTemplate(value string) bool {
+	return strings.Contains(value, "{{")
+}
+
+// renderENITag executes the template of an ADDITIONAL_ENI_TAGS value
+func renderENITag(key, value string, data ENITagData) (string, error) {
+	tmpl, err := template.New(key).Option("missingkey=error").Parse(value)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid template for ENI tag %s", key)
+	}
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return "", errors.Wrapf(err, "invalid template for ENI tag %s", key)
+	}
+	return rendered.String(), nil
+}
+
+// eniTagData returns the template data of the ENI tags set with ctx
+func (cache *EC2InstanceMetadataCache) eniTagData(ctx context.Context) ENITagData {
+	data := ENITagData{
+		ClusterName:      cache.clusterName,
+		NodeName:         os.Getenv("MY_NODE_NAME"),
+		AvailabilityZone: cache.availabilityZone,
+		InstanceID:       cache.instanceID,
+		InstanceType:     cache.instanceType,
+		Reason:           CallerFromContext(ctx),
+	}
+	if pod, ok := ctx.Value(podKey{}).(podName); ok {
+		data.PodNamespace, data.PodName = pod.namespace, pod.name
+	}
+	for _, value := range cache.additionalENITags {
+		if isENITagTemplate(value) && strings.Contains(value, ".NodeGroup") {
+			data.NodeGroup = cache.getNodeGroup(ctx)
+			break
+		}
+	}
+	return data
+}
+
+// getNodeGroup returns the EKS managed node group of the instance, from its tags. It is looked up once, and only when a
+// tag template needs it.
+func (cache *EC2InstanceMetadataCache) getNodeGroup(ctx context.Context) string {
+	cache.nodeGroupLock.Lock()
+	defer cache.nodeGroupLock.Unlock()
+	if cache.nodeGroupFound {
+		return cache.nodeGroup
+	}
+	start := time.Now()
+	output, err := cache.ec2SVC.DescribeInstancesWithContext(ctx, &ec2.DescribeInstancesInput{
+		InstanceIds: []*string{aws.String(cache.instanceID)},
+	})
+	awsAPILatency.WithLabelValues("DescribeInstances", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
+	if err != nil {
+		CheckAPIErrorAndBroadcastEvent(err, "ec2:DescribeInstances")
+		awsAPIErrInc("DescribeInstances", err)
+		log.Warnf("Failed to get the node group of instance %s for the ENI tags: %v", cache.instanceID, err)
+		return ""
+	}
+	for _, reservation := range output.Reservations {
+		for _, instance := range reservation.Instances {
+			for _, tag := range instance.Tags {
+				if aws.StringValue(tag.Key) == nodeGroupTagKey {
+					cache.nodeGroup = aws.StringValue(tag.Value)
+				}
+			}
+		}
+	}
+	cache.nodeGroupFound = true
+	return cache.nodeGroup
+}
@@ -0,0 +1,106 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package awsutils
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildENITagsWithTemplates(t *testing.T) {
+	ctrl, mockEC2 := setup(t)
+	defer ctrl.Finish()
+	defer os.Unsetenv("MY_NODE_NAME")
+	_ = os.Setenv("MY_NODE_NAME", "ip-10-0-0-1.ec2.internal")
+
+	cache := &EC2InstanceMetadataCache{
+		ec2SVC:           mockEC2,
+		instanceID:       instanceID,
+		instanceType:     "m5.large",
+		availabilityZone: az,
+		clusterName:      "prod",
+		additionalENITags: map[string]string{
+			"team":        "networking",
+			"cost-center": "{{.ClusterName}}/{{.NodeGroup}}",
+			"created-for": "{{.Reason}}{{if .PodName}}:{{.PodNamespace}}/{{.PodName}}{{end}}",
+			"node":        "{{.NodeName}} {{.InstanceType}} {{.AvailabilityZone}}",
+		},
+	}
+
+	// The node group is looked up once
+	mockEC2.EXPECT().DescribeInstancesWithContext(gomock.Any(), &ec2.DescribeInstancesInput{InstanceIds: []*string{aws.String(instanceID)}}).
+		Return(&ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{Instances: []*ec2.Instance{{
+			Tags: []*ec2.Tag{{Key: aws.String(nodeGroupTagKey), Value: aws.String("ng-1")}},
+		}}}}}, nil)
+
+	tags := cache.buildENITags(WithCaller(context.Background(), CallerScaleUp))
+	assert.Equal(t, map[string]string{
+		eniNodeTagKey:    instanceID,
+		eniClusterTagKey: "prod",
+		"team":           "networking",
+		"cost-center":    "prod/ng-1",
+		"created-for":    "scale-up",
+		"node":           "ip-10-0-0-1.ec2.internal m5.large " + az,
+	}, tags)
+
+	ctx := WithPod(WithCaller(context.Background(), CallerBranchENI), "payments", "api-0")
+	assert.Equal(t, "branch-eni:payments/api-0", cache.buildENITags(ctx)["created-for"])
+}
+
+func TestTagENIKeepsTemplatedTags(t *testing.T) {
+	ctrl, mockEC2 := setup(t)
+	defer ctrl.Finish()
+
+	cache := &EC2InstanceMetadataCache{
+		ec2SVC:     mockEC2,
+		instanceID: instanceID,
+		additionalENITags: map[string]string{
+			"team":        "networking",
+			"created-for": "{{.Reason}}",
+		},
+	}
+
+	// The templated tag set when the ENI was created is kept, the static one is fixed
+	mockEC2.EXPECT().CreateTagsWithContext(gomock.Any(), &ec2.CreateTagsInput{
+		Resources: []*string{aws.String("eni-1")},
+		Tags:      []*ec2.Tag{{Key: aws.String("team"), Value: aws.String("networking")}},
+	}).Return(&ec2.CreateTagsOutput{}, nil)
+	assert.NoError(t, cache.TagENI(WithCaller(context.Background(), CallerReconciler), "eni-1", map[string]string{
+		eniNodeTagKey: instanceID,
+		"team":        "storage",
+		"created-for": "scale-up",
+	}))
+
+	// A missing templated tag is added
+	mockEC2.EXPECT().CreateTagsWithContext(gomock.Any(), &ec2.CreateTagsInput{
+		Resources: []*string{aws.String("eni-2")},
+		Tags:      []*ec2.Tag{{Key: aws.String("created-for"), Value: aws.String("reconciler")}},
+	}).Return(&ec2.CreateTagsOutput{}, nil)
+	assert.NoError(t, cache.TagENI(WithCaller(context.Background(), CallerReconciler), "eni-2", map[string]string{
+		eniNodeTagKey: instanceID,
+		"team":        "networking",
+	}))
+}
+
+func TestLoadAdditionalENITagsTemplates(t *testing.T) {
+	defer os.Unsetenv(additionalEniTagsEnvVar)
+	_ = os.Setenv(additionalEniTagsEnvVar, `{"owner": "{{.ClusterName}}", "unclosed": "{{.ClusterName", "unknown": "{{.Owner}}"}`)
+	assert.Equal(t, map[string]string{"owner": "{{.ClusterName}}"}, loadAdditionalENITags())
+}
@@ -0,0 +1,495 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fakeprovider
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// CreateNetworkInterfaceWithContext creates an ENI in the subnet of the instance
+func (p *Provider) CreateNetworkInterfaceWithContext(ctx aws.Context, input *ec2.CreateNetworkInterfaceInput, opts ...request.Option) (*ec2.CreateNetworkInterfaceOutput, error) {
+	if err := p.call("CreateNetworkInterface", input.DryRun); err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if subnetID := aws.StringValue(input.SubnetId); subnetID != p.cfg.SubnetID {
+		return nil, awserr.New("InvalidSubnetID.NotFound", fmt.Sprintf("The subnet ID '%s' does not exist", subnetID), nil)
+	}
+	tags := make(map[string]string)
+	for _, spec := range input.TagSpecifications {
+		for _, tag := range spec.Tags {
+			tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+	}
+	e, err := p.createENI(aws.StringValue(input.Description), aws.StringValueSlice(input.Groups), tags)
+	if err != nil {
+		return nil, err
+	}
+	return &ec2.CreateNetworkInterfaceOutput{NetworkInterface: p.toSDK(e)}, nil
+}
+
+// DescribeInstancesWithContext describes the instance
+func (p *Provider) DescribeInstancesWithContext(ctx aws.Context, input *ec2.DescribeInstancesInput, opts ...request.Option) (*ec2.DescribeInstancesOutput, error) {
+	if err := p.call("DescribeInstances", input.DryRun); err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, instanceID := range input.InstanceIds {
+		if aws.StringValue(instanceID) != p.cfg.InstanceID {
+			return nil, awserr.New("InvalidInstanceID.NotFound",
+				fmt.Sprintf("The instance ID '%s' does not exist", aws.StringValue(instanceID)), nil)
+		}
+	}
+	instance := &ec2.Instance{
+		InstanceId:   aws.String(p.cfg.InstanceID),
+		InstanceType: aws.String(p.cfg.InstanceType),
+		SubnetId:     aws.String(p.cfg.SubnetID),
+		VpcId:        aws.String(p.cfg.VPCID),
+		Placement:    &ec2.Placement{AvailabilityZone: aws.String(p.cfg.AvailabilityZone)},
+	}
+	for _, e := range p.attachedENIs() {
+		networkInterface := p.toSDK(e)
+		instance.NetworkInterfaces = append(instance.NetworkInterfaces, &ec2.InstanceNetworkInterface{
+			NetworkInterfaceId: networkInterface.NetworkInterfaceId,
+			MacAddress:         networkInterface.MacAddress,
+			PrivateIpAddress:   networkInterface.PrivateIpAddress,
+			SubnetId:           networkInterface.SubnetId,
+			Attachment: &ec2.InstanceNetworkInterfaceAttachment{
+				AttachmentId:        networkInterface.Attachment.AttachmentId,
+				DeviceIndex:         networkInterface.Attachment.DeviceIndex,
+				NetworkCardIndex:    networkInterface.Attachment.NetworkCardIndex,
+				DeleteOnTermination: networkInterface.Attachment.DeleteOnTermination,
+				Status:              networkInterface.Attachment.Status,
+			},
+		})
+	}
+	return &ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{Instances: []*ec2.Instance{instance}}}}, nil
+}
+
+// ModifyInstanceMetadataOptionsWithContext accepts any metadata options
+func (p *Provider) ModifyInstanceMetadataOptionsWithContext(ctx aws.Context, input *ec2.ModifyInstanceMetadataOptionsInput, opts ...request.Option) (*ec2.ModifyInstanceMetadataOptionsOutput, error) {
+	if err := p.call("ModifyInstanceMetadataOptions", input.DryRun); err != nil {
+		return nil, err
+	}
+	return &ec2.ModifyInstanceMetadataOptionsOutput{InstanceId: aws.String(p.cfg.InstanceID)}, nil
+}
+
+// DescribeInstanceTypesWithContext returns the configured limits for the instance type of the instance
+func (p *Provider) DescribeInstanceTypesWithContext(ctx aws.Context, input *ec2.DescribeInstanceTypesInput, opts ...request.Option) (*ec2.DescribeInstanceTypesOutput, error) {
+	if err := p.call("DescribeInstanceTypes", input.DryRun); err != nil {
+		return nil, err
+	}
+	output := &ec2.DescribeInstanceTypesOutput{}
+	for _, instanceType := range input.InstanceTypes {
+		if aws.StringValue(instanceType) != p.cfg.InstanceType {
+			continue
+		}
+		output.InstanceTypes = append(output.InstanceTypes, &ec2.InstanceTypeInfo{
+			InstanceType: aws.String(p.cfg.InstanceType),
+			Hypervisor:   aws.String(ec2.InstanceTypeHypervisorNitro),
+			BareMetal:    aws.Bool(false),
+			NetworkInfo: &ec2.NetworkInfo{
+				MaximumNetworkInterfaces:  aws.Int64(int64(p.cfg.ENILimit)),
+				Ipv4AddressesPerInterface: aws.Int64(int64(p.cfg.IPv4Limit)),
+			},
+		})
+	}
+	return output, nil
+}
+
+// AttachNetworkInterfaceWithContext attaches an ENI to the instance
+func (p *Provider) AttachNetworkInterfaceWithContext(ctx aws.Context, input *ec2.AttachNetworkInterfaceInput, opts ...request.Option) (*ec2.AttachNetworkInterfaceOutput, error) {
+	if err := p.call("AttachNetworkInterface", input.DryRun); err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if instanceID := aws.StringValue(input.InstanceId); instanceID != p.cfg.InstanceID {
+		return nil, awserr.New("InvalidInstanceID.NotFound", fmt.Sprintf("The instance ID '%s' does not exist", instanceID), nil)
+	}
+	e, err := p.getENI(input.NetworkInterfaceId)
+	if err != nil {
+		return nil, err
+	}
+	if e.deviceIndex >= 0 {
+		return nil, awserr.New("InvalidNetworkInterface.InUse", fmt.Sprintf("Interface: [%s] in use.", e.id), nil)
+	}
+	attached := p.attachedENIs()
+	if len(attached) >= p.cfg.ENILimit {
+		return nil, awserr.New("AttachmentLimitExceeded",
+			fmt.Sprintf("Interface count %d exceeds the limit for %s", len(attached)+1, p.cfg.InstanceType), nil)
+	}
+	deviceIndex := int(aws.Int64Value(input.DeviceIndex))
+	for _, other := range attached {
+		if other.deviceIndex == deviceIndex {
+			return nil, awserr.New("InvalidParameterValue",
+				fmt.Sprintf("Instance '%s' already has an interface attached at device index '%d'.", p.cfg.InstanceID, deviceIndex), nil)
+		}
+	}
+	e.deviceIndex = deviceIndex
+	e.attachmentID = p.newID("eni-attach")
+	return &ec2.AttachNetworkInterfaceOutput{AttachmentId: aws.String(e.attachmentID), NetworkCardIndex: aws.Int64(0)}, nil
+}
+
+// DeleteNetworkInterfaceWithContext deletes a detached ENI, and releases its addresses
+func (p *Provider) DeleteNetworkInterfaceWithContext(ctx aws.Context, input *ec2.DeleteNetworkInterfaceInput, opts ...request.Option) (*ec2.DeleteNetworkInterfaceOutput, error) {
+	if err := p.call("DeleteNetworkInterface", input.DryRun); err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	e, err := p.getENI(input.NetworkInterfaceId)
+	if err != nil {
+		return nil, err
+	}
+	if e.deviceIndex >= 0 {
+		return nil, awserr.New("InvalidNetworkInterface.InUse", fmt.Sprintf("The network interface '%s' is currently in use.", e.id), nil)
+	}
+	for _, ip := range e.ips {
+		p.releaseIP(ip)
+	}
+	for _, prefix := range e.prefixes {
+		p.releasePrefix(prefix)
+	}
+	delete(p.enis, e.id)
+	return &ec2.DeleteNetworkInterfaceOutput{}, nil
+}
+
+// DetachNetworkInterfaceWithContext detaches a secondary ENI from the instance
+func (p *Provider) DetachNetworkInterfaceWithContext(ctx aws.Context, input *ec2.DetachNetworkInterfaceInput, opts ...request.Option) (*ec2.DetachNetworkInterfaceOutput, error) {
+	if err := p.call("DetachNetworkInterface", input.DryRun); err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	attachmentID := aws.StringValue(input.AttachmentId)
+	for _, e := range p.attachedENIs() {
+		if e.attachmentID != attachmentID {
+			continue
+		}
+		if e.deviceIndex == 0 {
+			return nil, awserr.New("OperationNotPermitted", "The network interface at device index 0 cannot be detached.", nil)
+		}
+		e.deviceIndex = -1
+		e.attachmentID = ""
+		return &ec2.DetachNetworkInterfaceOutput{}, nil
+	}
+	return nil, awserr.New("InvalidAttachmentID.NotFound", fmt.Sprintf("The attachment ID '%s' does not exist", attachmentID), nil)
+}
+
+// AssignPrivateIpAddressesWithContext assigns secondary IPv4 addresses or /28 prefixes to an ENI, up to the IPv4 limit
+// of the instance type
+func (p *Provider) AssignPrivateIpAddressesWithContext(ctx aws.Context, input *ec2.AssignPrivateIpAddressesInput, opts ...request.Option) (*ec2.AssignPrivateIpAddressesOutput, error) {
+	if err := p.call("AssignPrivateIpAddresses", nil); err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	e, err := p.getENI(input.NetworkInterfaceId)
+	if err != nil {
+		return nil, err
+	}
+	numIPs := int(aws.Int64Value(input.SecondaryPrivateIpAddressCount)) + len(input.PrivateIpAddresses)
+	numPrefixes := int(aws.Int64Value(input.Ipv4PrefixCount))
+	if len(e.ips)+len(e.prefixes)+numIPs+numPrefixes > p.cfg.IPv4Limit {
+		return nil, awserr.New("PrivateIpAddressLimitExceeded",
+			fmt.Sprintf("Number of private addresses will exceed limit for network interface '%s'", e.id), nil)
+	}
+
+	output := &ec2.AssignPrivateIpAddressesOutput{NetworkInterfaceId: aws.String(e.id)}
+	for _, ip := range input.PrivateIpAddresses {
+		n, ok := p.subnetAddress(aws.StringValue(ip))
+		if !ok || p.usedIPs[n] {
+			return nil, awserr.New("InvalidParameterValue",
+				fmt.Sprintf("Address %s is in use or not in subnet %s", aws.StringValue(ip), p.cfg.SubnetID), nil)
+		}
+		p.usedIPs[n] = true
+		e.ips = append(e.ips, aws.StringValue(ip))
+		output.AssignedPrivateIpAddresses = append(output.AssignedPrivateIpAddresses, &ec2.AssignedPrivateIpAddress{PrivateIpAddress: ip})
+	}
+	for i := 0; i < int(aws.Int64Value(input.SecondaryPrivateIpAddressCount)); i++ {
+		ip, err := p.allocIP()
+		if err != nil {
+			return nil, err
+		}
+		e.ips = append(e.ips, ip)
+		output.AssignedPrivateIpAddresses = append(output.AssignedPrivateIpAddresses, &ec2.AssignedPrivateIpAddress{PrivateIpAddress: aws.String(ip)})
+	}
+	for i := 0; i < numPrefixes; i++ {
+		prefix, err := p.allocPrefix()
+		if err != nil {
+			return nil, err
+		}
+		e.prefixes = append(e.prefixes, prefix)
+		output.AssignedIpv4Prefixes = append(output.AssignedIpv4Prefixes, &ec2.Ipv4PrefixSpecification{Ipv4Prefix: aws.String(prefix)})
+	}
+	return output, nil
+}
+
+// UnassignPrivateIpAddressesWithContext unassigns secondary IPv4 addresses or prefixes from an ENI
+func (p *Provider) UnassignPrivateIpAddressesWithContext(ctx aws.Context, input *ec2.UnassignPrivateIpAddressesInput, opts ...request.Option) (*ec2.UnassignPrivateIpAddressesOutput, error) {
+	if err := p.call("UnassignPrivateIpAddresses", nil); err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	e, err := p.getENI(input.NetworkInterfaceId)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := remove(e.ips[1:], aws.StringValueSlice(input.PrivateIpAddresses))
+	if err != nil {
+		return nil, err
+	}
+	prefixes, err := remove(e.prefixes, aws.StringValueSlice(input.Ipv4Prefixes))
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range input.PrivateIpAddresses {
+		p.releaseIP(aws.StringValue(ip))
+	}
+	for _, prefix := range input.Ipv4Prefixes {
+		p.releasePrefix(aws.StringValue(prefix))
+	}
+	e.ips = append(e.ips[:1], ips...)
+	e.prefixes = prefixes
+	return &ec2.UnassignPrivateIpAddressesOutput{}, nil
+}
+
+// remove returns values without the removed ones, which all must be in values
+func remove(values, removed []string) ([]string, error) {
+	removedSet := make(map[string]bool, len(removed))
+	for _, value := range removed {
+		removedSet[value] = true
+	}
+	var kept []string
+	for _, value := range values {
+		if removedSet[value] {
+			delete(removedSet, value)
+			continue
+		}
+		kept = append(kept, value)
+	}
+	for value := range removedSet {
+		return nil, awserr.New("InvalidParameterValue", fmt.Sprintf("Some of the specified addresses are not assigned to interface: %s", value), nil)
+	}
+	return kept, nil
+}
+
+// AssignIpv6AddressesWithContext fails, the provider doesn't model IPv6
+func (p *Provider) AssignIpv6AddressesWithContext(ctx aws.Context, input *ec2.AssignIpv6AddressesInput, opts ...request.Option) (*ec2.AssignIpv6AddressesOutput, error) {
+	if err := p.call("AssignIpv6Addresses", nil); err != nil {
+		return nil, err
+	}
+	return nil, awserr.New("UnsupportedOperation", "IPv6 is not supported by the fake provider", nil)
+}
+
+// UnassignIpv6AddressesWithContext fails, the provider doesn't model IPv6
+func (p *Provider) UnassignIpv6AddressesWithContext(ctx aws.Context, input *ec2.UnassignIpv6AddressesInput, opts ...request.Option) (*ec2.UnassignIpv6AddressesOutput, error) {
+	if err := p.call("UnassignIpv6Addresses", nil); err != nil {
+		return nil, err
+	}
+	return nil, awserr.New("UnsupportedOperation", "IPv6 is not supported by the fake provider", nil)
+}
+
+// DescribeNetworkInterfacesWithContext describes ENIs by ID, or all the ENIs matching the filters
+func (p *Provider) DescribeNetworkInterfacesWithContext(ctx aws.Context, input *ec2.DescribeNetworkInterfacesInput, opts ...request.Option) (*ec2.DescribeNetworkInterfacesOutput, error) {
+	if err := p.call("DescribeNetworkInterfaces", input.DryRun); err != nil {
+		return nil, err
+	}
+	return p.describeNetworkInterfaces(input)
+}
+
+// DescribeNetworkInterfacesPagesWithContext describes the ENIs in a single page
+func (p *Provider) DescribeNetworkInterfacesPagesWithContext(ctx aws.Context, input *ec2.DescribeNetworkInterfacesInput, fn func(*ec2.DescribeNetworkInterfacesOutput, bool) bool, opts ...request.Option) error {
+	if err := p.call("DescribeNetworkInterfaces", input.DryRun); err != nil {
+		return err
+	}
+	output, err := p.describeNetworkInterfaces(input)
+	if err != nil {
+		return err
+	}
+	fn(output, true)
+	return nil
+}
+
+func (p *Provider) describeNetworkInterfaces(input *ec2.DescribeNetworkInterfacesInput) (*ec2.DescribeNetworkInterfacesOutput, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	var enis []*eni
+	if len(input.NetworkInterfaceIds) > 0 {
+		for _, eniID := range input.NetworkInterfaceIds {
+			e, err := p.getENI(eniID)
+			if err != nil {
+				return nil, err
+			}
+			enis = append(enis, e)
+		}
+	} else {
+		for _, e := range p.enis {
+			enis = append(enis, e)
+		}
+	}
+	output := &ec2.DescribeNetworkInterfacesOutput{}
+	for _, e := range enis {
+		matched, err := p.matches(e, input.Filters)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			output.NetworkInterfaces = append(output.NetworkInterfaces, p.toSDK(e))
+		}
+	}
+	return output, nil
+}
+
+// ModifyNetworkInterfaceAttributeWithContext changes the security groups or the delete on termination flag of an ENI
+func (p *Provider) ModifyNetworkInterfaceAttributeWithContext(ctx aws.Context, input *ec2.ModifyNetworkInterfaceAttributeInput, opts ...request.Option) (*ec2.ModifyNetworkInterfaceAttributeOutput, error) {
+	if err := p.call("ModifyNetworkInterfaceAttribute", input.DryRun); err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	e, err := p.getENI(input.NetworkInterfaceId)
+	if err != nil {
+		return nil, err
+	}
+	if len(input.Groups) > 0 {
+		e.groups = aws.StringValueSlice(input.Groups)
+	}
+	if input.Attachment != nil && input.Attachment.DeleteOnTermination != nil {
+		if aws.StringValue(input.Attachment.AttachmentId) != e.attachmentID {
+			return nil, awserr.New("InvalidAttachmentID.NotFound",
+				fmt.Sprintf("The attachment ID '%s' does not exist", aws.StringValue(input.Attachment.AttachmentId)), nil)
+		}
+		e.deleteOnTermination = aws.BoolValue(input.Attachment.DeleteOnTermination)
+	}
+	return &ec2.ModifyNetworkInterfaceAttributeOutput{}, nil
+}
+
+// CreateTagsWithContext adds tags to ENIs
+func (p *Provider) CreateTagsWithContext(ctx aws.Context, input *ec2.CreateTagsInput, opts ...request.Option) (*ec2.CreateTagsOutput, error) {
+	if err := p.call("CreateTags", input.DryRun); err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	var enis []*eni
+	for _, resource := range input.Resources {
+		e, err := p.getENI(resource)
+		if err != nil {
+			return nil, err
+		}
+		enis = append(enis, e)
+	}
+	for _, e := range enis {
+		for _, tag := range input.Tags {
+			e.tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+	}
+	return &ec2.CreateTagsOutput{}, nil
+}
+
+// DescribeSubnetsWithContext describes the subnet of the instance
+func (p *Provider) DescribeSubnetsWithContext(ctx aws.Context, input *ec2.DescribeSubnetsInput, opts ...request.Option) (*ec2.DescribeSubnetsOutput, error) {
+	if err := p.call("DescribeSubnets", input.DryRun); err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, subnetID := range input.SubnetIds {
+		if aws.StringValue(subnetID) != p.cfg.SubnetID {
+			return nil, awserr.New("InvalidSubnetID.NotFound",
+				fmt.Sprintf("The subnet ID '%s' does not exist", aws.StringValue(subnetID)), nil)
+		}
+	}
+	first, last := p.subnetRange()
+	return &ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{{
+		SubnetId:                aws.String(p.cfg.SubnetID),
+		VpcId:                   aws.String(p.cfg.VPCID),
+		CidrBlock:               aws.String(p.subnet.String()),
+		AvailabilityZone:        aws.String(p.cfg.AvailabilityZone),
+		AvailableIpAddressCount: aws.Int64(int64(last-first+1) - int64(len(p.usedIPs))),
+	}}}, nil
+}
+
+// GetSubnetCidrReservationsWithContext returns no reservation, the provider doesn't model subnet CIDR reservations
+func (p *Provider) GetSubnetCidrReservationsWithContext(ctx aws.Context, input *ec2.GetSubnetCidrReservationsInput, opts ...request.Option) (*ec2.GetSubnetCidrReservationsOutput, error) {
+	if err := p.call("GetSubnetCidrReservations", input.DryRun); err != nil {
+		return nil, err
+	}
+	if aws.StringValue(input.SubnetId) != p.cfg.SubnetID {
+		return nil, awserr.New("InvalidSubnetID.NotFound",
+			fmt.Sprintf("The subnet ID '%s' does not exist", aws.StringValue(input.SubnetId)), nil)
+	}
+	return &ec2.GetSubnetCidrReservationsOutput{}, nil
+}
+
+// DescribeSecurityGroupsWithContext describes the security groups of the VPC matching the group-id filter
+func (p *Provider) DescribeSecurityGroupsWithContext(ctx aws.Context, input *ec2.DescribeSecurityGroupsInput, opts ...request.Option) (*ec2.DescribeSecurityGroupsOutput, error) {
+	if err := p.call("DescribeSecurityGroups", input.DryRun); err != nil {
+		return nil, err
+	}
+	wanted := make(map[string]bool)
+	for _, groupID := range input.GroupIds {
+		wanted[aws.StringValue(groupID)] = true
+	}
+	for _, filter := range input.Filters {
+		if aws.StringValue(filter.Name) != "group-id" {
+			return nil, awserr.New("InvalidParameterValue", fmt.Sprintf("The filter '%s' is invalid", aws.StringValue(filter.Name)), nil)
+		}
+		for _, groupID := range filter.Values {
+			wanted[aws.StringValue(groupID)] = true
+		}
+	}
+	output := &ec2.DescribeSecurityGroupsOutput{}
+	for _, groupID := range p.cfg.SecurityGroups {
+		if len(wanted) == 0 || wanted[groupID] {
+			output.SecurityGroups = append(output.SecurityGroups, &ec2.SecurityGroup{GroupId: aws.String(groupID), VpcId: aws.String(p.cfg.VPCID)})
+		}
+	}
+	return output, nil
+}
+
+// DescribeVpcsWithContext describes the VPC of the instance, which uses the default DHCP options
+func (p *Provider) DescribeVpcsWithContext(ctx aws.Context, input *ec2.DescribeVpcsInput, opts ...request.Option) (*ec2.DescribeVpcsOutput, error) {
+	if err := p.call("DescribeVpcs", input.DryRun); err != nil {
+		return nil, err
+	}
+	for _, vpcID := range input.VpcIds {
+		if aws.StringValue(vpcID) != p.cfg.VPCID {
+			return nil, awserr.New("InvalidVpcID.NotFound", fmt.Sprintf("The vpc ID '%s' does not exist", aws.StringValue(vpcID)), nil)
+		}
+	}
+	return &ec2.DescribeVpcsOutput{Vpcs: []*ec2.Vpc{{
+		VpcId:         aws.String(p.cfg.VPCID),
+		CidrBlock:     aws.String(p.cfg.VPCCIDR),
+		DhcpOptionsId: aws.String("default"),
+	}}}, nil
+}
+
+// DescribeDhcpOptionsWithContext fails, the VPC uses the default DHCP options
+func (p *Provider) DescribeDhcpOptionsWithContext(ctx aws.Context, input *ec2.DescribeDhcpOptionsInput, opts ...request.Option) (*ec2.DescribeDhcpOptionsOutput, error) {
+	if err := p.call("DescribeDhcpOptions", input.DryRun); err != nil {
+		return nil, err
+	}
+	return nil, awserr.New("InvalidDhcpOptionID.NotFound", "The dhcp options set does not exist", nil)
+}
@@ -0,0 +1,388 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package fakeprovider is an in-memory awsutils.Provider for scale testing the IPAM core without EC2. It models a single
+// instance in a single subnet, keeps the instance metadata consistent with the EC2 API, and enforces the ENI and IP
+// limits of the instance type.
+package fakeprovider
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ec2wrapper"
+)
+
+const (
+	// EC2 reserves the first four and the last address of a subnet
+	subnetReservedHead = 4
+	subnetReservedTail = 1
+	prefixLength       = 28
+	prefixSize         = 1 << (32 - prefixLength)
+)
+
+// Config is the instance and the network that the Provider models
+type Config struct {
+	Region           string
+	AvailabilityZone string
+	InstanceID       string
+	InstanceType     string
+	// ENILimit and IPv4Limit are the limits DescribeInstanceTypes returns for InstanceType
+	ENILimit  int
+	IPv4Limit int
+
+	VPCID          string
+	VPCCIDR        string
+	SubnetID       string
+	SubnetCIDR     string
+	SecurityGroups []string
+
+	// Latency is added to every EC2 call
+	Latency time.Duration
+}
+
+// DefaultConfig returns the Config of an m5.large instance in a /19 subnet
+func DefaultConfig() Config {
+	return Config{
+		Region:           "us-west-2",
+		AvailabilityZone: "us-west-2a",
+		InstanceID:       "i-0fa4e000000000001",
+		InstanceType:     "m5.large",
+		ENILimit:         3,
+		IPv4Limit:        10,
+		VPCID:            "vpc-0fa4e000000000001",
+		VPCCIDR:          "10.0.0.0/16",
+		SubnetID:         "subnet-0fa4e000000000001",
+		SubnetCIDR:       "10.0.0.0/19",
+		SecurityGroups:   []string{"sg-0fa4e000000000001"},
+	}
+}
+
+type eni struct {
+	id           string
+	mac          string
+	description  string
+	groups       []string
+	tags         map[string]string
+	attachmentID string
+	// deviceIndex is -1 when the ENI is not attached
+	deviceIndex         int
+	deleteOnTermination bool
+	// ips holds the private IPv4 addresses of the ENI, the primary one first
+	ips      []string
+	prefixes []string
+}
+
+// Provider is an in-memory awsutils.Provider
+type Provider struct {
+	cfg    Config
+	subnet *net.IPNet
+
+	lock    sync.Mutex
+	enis    map[string]*eni
+	usedIPs map[uint32]bool
+	nextID  int
+	calls   map[string]int
+}
+
+var _ ec2wrapper.EC2 = &Provider{}
+
+// New returns a Provider with an instance that has its primary ENI attached
+func New(cfg Config) (*Provider, error) {
+	_, subnet, err := net.ParseCIDR(cfg.SubnetCIDR)
+	if err != nil || subnet.IP.To4() == nil {
+		return nil, errors.Errorf("invalid IPv4 subnet CIDR %q", cfg.SubnetCIDR)
+	}
+	if _, _, err := net.ParseCIDR(cfg.VPCCIDR); err != nil {
+		return nil, errors.Errorf("invalid VPC CIDR %q", cfg.VPCCIDR)
+	}
+	if cfg.ENILimit < 1 || cfg.IPv4Limit < 1 {
+		return nil, errors.Errorf("invalid limits of %d ENIs and %d IPv4 addresses per ENI", cfg.ENILimit, cfg.IPv4Limit)
+	}
+	p := &Provider{
+		cfg:     cfg,
+		subnet:  subnet,
+		enis:    make(map[string]*eni),
+		usedIPs: make(map[uint32]bool),
+		calls:   make(map[string]int),
+	}
+	primary, err := p.createENI("Primary network interface", cfg.SecurityGroups, nil)
+	if err != nil {
+		return nil, err
+	}
+	primary.deviceIndex = 0
+	primary.attachmentID = p.newID("eni-attach")
+	primary.deleteOnTermination = true
+	return p, nil
+}
+
+// Calls returns the number of calls made to an EC2 API, e.g. "AssignPrivateIpAddresses"
+func (p *Provider) Calls(api string) int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.calls[api]
+}
+
+// AllCalls returns the number of calls made to each EC2 API
+func (p *Provider) AllCalls() map[string]int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	calls := make(map[string]int, len(p.calls))
+	for api, count := range p.calls {
+		calls[api] = count
+	}
+	return calls
+}
+
+// Region returns the region of the instance
+func (p *Provider) Region() (string, error) {
+	return p.cfg.Region, nil
+}
+
+// call counts a call to an EC2 API and waits for the configured latency. It returns the DryRunOperation error of a
+// dry run.
+func (p *Provider) call(api string, dryRun *bool) error {
+	p.lock.Lock()
+	p.calls[api]++
+	p.lock.Unlock()
+	if p.cfg.Latency > 0 {
+		time.Sleep(p.cfg.Latency)
+	}
+	if aws.BoolValue(dryRun) {
+		return awserr.New("DryRunOperation", "Request would have succeeded, but DryRun flag is set.", nil)
+	}
+	return nil
+}
+
+func (p *Provider) newID(prefix string) string {
+	p.nextID++
+	return fmt.Sprintf("%s-%017x", prefix, p.nextID)
+}
+
+func ipToUint32(ip net.IP) uint32 {
+	return binary.BigEndian.Uint32(ip.To4())
+}
+
+func uint32ToIP(n uint32) net.IP {
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, n)
+	return ip
+}
+
+// subnetRange returns the first and last usable addresses of the subnet
+func (p *Provider) subnetRange() (uint32, uint32) {
+	ones, bits := p.subnet.Mask.Size()
+	base := ipToUint32(p.subnet.IP)
+	return base + subnetReservedHead, base + uint32(1)<<uint(bits-ones) - 1 - subnetReservedTail
+}
+
+// subnetAddress returns the usable address of the subnet ip is, and false if ip is not one
+func (p *Provider) subnetAddress(ip string) (uint32, bool) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil || parsed.To4() == nil {
+		return 0, false
+	}
+	first, last := p.subnetRange()
+	n := ipToUint32(parsed)
+	return n, n >= first && n <= last
+}
+
+func (p *Provider) allocIP() (string, error) {
+	first, last := p.subnetRange()
+	for n := first; n <= last; n++ {
+		if !p.usedIPs[n] {
+			p.usedIPs[n] = true
+			return uint32ToIP(n).String(), nil
+		}
+	}
+	return "", awserr.New("InsufficientFreeAddressesInSubnet", "The specified subnet does not have enough free addresses to satisfy the request.", nil)
+}
+
+func (p *Provider) allocPrefix() (string, error) {
+	first, last := p.subnetRange()
+	for base := ipToUint32(p.subnet.IP); base+prefixSize-1 <= last; base += prefixSize {
+		if base < first {
+			continue
+		}
+		free := true
+		for n := base; n < base+prefixSize; n++ {
+			if p.usedIPs[n] {
+				free = false
+				break
+			}
+		}
+		if free {
+			for n := base; n < base+prefixSize; n++ {
+				p.usedIPs[n] = true
+			}
+			return fmt.Sprintf("%s/%d", uint32ToIP(base), prefixLength), nil
+		}
+	}
+	return "", awserr.New("InsufficientCidrBlocks", "There are not enough free cidr blocks in the specified subnet to satisfy the request.", nil)
+}
+
+func (p *Provider) releaseIP(ip string) {
+	delete(p.usedIPs, ipToUint32(net.ParseIP(ip)))
+}
+
+func (p *Provider) releasePrefix(prefix string) {
+	_, ipNet, _ := net.ParseCIDR(prefix)
+	base := ipToUint32(ipNet.IP)
+	for n := base; n < base+prefixSize; n++ {
+		delete(p.usedIPs, n)
+	}
+}
+
+func (p *Provider) createENI(description string, groups []string, tags map[string]string) (*eni, error) {
+	ip, err := p.allocIP()
+	if err != nil {
+		return nil, err
+	}
+	id := p.newID("eni")
+	e := &eni{
+		id:          id,
+		mac:         fmt.Sprintf("02:fa:4e:%02x:%02x:%02x", byte(p.nextID>>16), byte(p.nextID>>8), byte(p.nextID)),
+		description: description,
+		groups:      groups,
+		tags:        make(map[string]string),
+		deviceIndex: -1,
+		ips:         []string{ip},
+	}
+	for key, value := range tags {
+		e.tags[key] = value
+	}
+	p.enis[id] = e
+	return e, nil
+}
+
+func eniNotFound(eniID string) error {
+	return awserr.New("InvalidNetworkInterfaceID.NotFound",
+		fmt.Sprintf("The networkInterface ID '%s' does not exist", eniID), nil)
+}
+
+func (p *Provider) getENI(eniID *string) (*eni, error) {
+	e, ok := p.enis[aws.StringValue(eniID)]
+	if !ok {
+		return nil, eniNotFound(aws.StringValue(eniID))
+	}
+	return e, nil
+}
+
+// attachedENIs returns the attached ENIs, ordered by device index
+func (p *Provider) attachedENIs() []*eni {
+	var enis []*eni
+	for _, e := range p.enis {
+		if e.deviceIndex >= 0 {
+			enis = append(enis, e)
+		}
+	}
+	sort.Slice(enis, func(i, j int) bool { return enis[i].deviceIndex < enis[j].deviceIndex })
+	return enis
+}
+
+func (p *Provider) toSDK(e *eni) *ec2.NetworkInterface {
+	networkInterface := &ec2.NetworkInterface{
+		NetworkInterfaceId: aws.String(e.id),
+		MacAddress:         aws.String(e.mac),
+		Description:        aws.String(e.description),
+		SubnetId:           aws.String(p.cfg.SubnetID),
+		VpcId:              aws.String(p.cfg.VPCID),
+		AvailabilityZone:   aws.String(p.cfg.AvailabilityZone),
+		InterfaceType:      aws.String(ec2.NetworkInterfaceTypeInterface),
+		Status:             aws.String(ec2.NetworkInterfaceStatusAvailable),
+		PrivateIpAddress:   aws.String(e.ips[0]),
+	}
+	for _, group := range e.groups {
+		networkInterface.Groups = append(networkInterface.Groups, &ec2.GroupIdentifier{GroupId: aws.String(group)})
+	}
+	keys := make([]string, 0, len(e.tags))
+	for key := range e.tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		networkInterface.TagSet = append(networkInterface.TagSet, &ec2.Tag{Key: aws.String(key), Value: aws.String(e.tags[key])})
+	}
+	for i, ip := range e.ips {
+		networkInterface.PrivateIpAddresses = append(networkInterface.PrivateIpAddresses, &ec2.NetworkInterfacePrivateIpAddress{
+			Primary:          aws.Bool(i == 0),
+			PrivateIpAddress: aws.String(ip),
+		})
+	}
+	for _, prefix := range e.prefixes {
+		networkInterface.Ipv4Prefixes = append(networkInterface.Ipv4Prefixes, &ec2.Ipv4PrefixSpecification{Ipv4Prefix: aws.String(prefix)})
+	}
+	if e.deviceIndex >= 0 {
+		networkInterface.Status = aws.String(ec2.NetworkInterfaceStatusInUse)
+		networkInterface.Attachment = &ec2.NetworkInterfaceAttachment{
+			AttachmentId:        aws.String(e.attachmentID),
+			DeviceIndex:         aws.Int64(int64(e.deviceIndex)),
+			NetworkCardIndex:    aws.Int64(0),
+			InstanceId:          aws.String(p.cfg.InstanceID),
+			DeleteOnTermination: aws.Bool(e.deleteOnTermination),
+			Status:              aws.String(ec2.AttachmentStatusAttached),
+		}
+	}
+	return networkInterface
+}
+
+// matches returns true if the ENI passes all the filters of a DescribeNetworkInterfaces call
+func (p *Provider) matches(e *eni, filters []*ec2.Filter) (bool, error) {
+	networkInterface := p.toSDK(e)
+	for _, filter := range filters {
+		name := aws.StringValue(filter.Name)
+		var values []string
+		switch {
+		case name == "tag-key":
+			for key := range e.tags {
+				values = append(values, key)
+			}
+		case strings.HasPrefix(name, "tag:"):
+			if value, ok := e.tags[strings.TrimPrefix(name, "tag:")]; ok {
+				values = append(values, value)
+			}
+		case name == "status":
+			values = append(values, aws.StringValue(networkInterface.Status))
+		case name == "subnet-id":
+			values = append(values, p.cfg.SubnetID)
+		case name == "attachment.instance-id":
+			if e.deviceIndex >= 0 {
+				values = append(values, p.cfg.InstanceID)
+			}
+		default:
+			return false, awserr.New("InvalidParameterValue", fmt.Sprintf("The filter '%s' is invalid", name), nil)
+		}
+		matched := false
+		for _, value := range values {
+			for _, wanted := range filter.Values {
+				if value == aws.StringValue(wanted) {
+					matched = true
+				}
+			}
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
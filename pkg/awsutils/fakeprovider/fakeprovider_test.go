@@ -0,0 +1,145 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fakeprovider_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils/fakeprovider"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/eventrecorder"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/paths"
+)
+
+var _ awsutils.Provider = &fakeprovider.Provider{}
+
+func TestNewInvalidConfig(t *testing.T) {
+	cfg := fakeprovider.DefaultConfig()
+	cfg.SubnetCIDR = "fd00::/64"
+	_, err := fakeprovider.New(cfg)
+	assert.Error(t, err)
+
+	cfg = fakeprovider.DefaultConfig()
+	cfg.ENILimit = 0
+	_, err = fakeprovider.New(cfg)
+	assert.Error(t, err)
+}
+
+func TestAWSUtilsWithProvider(t *testing.T) {
+	defer os.Unsetenv(paths.EnvRunDir)
+	_ = os.Setenv(paths.EnvRunDir, t.TempDir())
+	eventrecorder.InitMockEventRecorder(nil)
+	ctx := context.Background()
+
+	provider, err := fakeprovider.New(fakeprovider.DefaultConfig())
+	require.NoError(t, err)
+	cache, err := awsutils.NewWithProvider(provider, false, true, true, false)
+	require.NoError(t, err)
+	assert.Equal(t, "i-0fa4e000000000001", cache.GetInstanceID())
+	assert.Equal(t, "10.0.0.4", cache.GetLocalIPv4().String())
+	require.NoError(t, cache.FetchInstanceTypeLimits())
+	assert.Equal(t, 3, cache.GetENILimit())
+	assert.Equal(t, 9, cache.GetENIIPv4Limit())
+	require.NoError(t, cache.RefreshSGIDs(ctx, cache.GetPrimaryENImac()))
+	available, err := cache.GetSubnetAvailableIPCount("")
+	assert.NoError(t, err)
+
+	eniID, err := cache.AllocENI(ctx, false, nil, "")
+	require.NoError(t, err)
+	output, err := cache.AllocIPAddresses(ctx, eniID, 5)
+	assert.NoError(t, err)
+	assert.Len(t, output.AssignedPrivateIpAddresses, 5)
+
+	result, err := cache.DescribeAllENIs(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, result.ENIMetadata, 2)
+	for _, eni := range result.ENIMetadata {
+		if eni.ENIID == eniID {
+			assert.Equal(t, 1, eni.DeviceNumber)
+			assert.Len(t, eni.IPv4Addresses, 6)
+			assert.Equal(t, "10.0.0.0/19", eni.SubnetIPv4CIDR)
+		}
+	}
+	assert.Equal(t, "i-0fa4e000000000001", result.TagMap[eniID]["node.k8s.amazonaws.com/instance_id"])
+
+	assert.NoError(t, cache.DeallocIPAddresses(ctx, eniID, []string{aws.StringValue(output.AssignedPrivateIpAddresses[0].PrivateIpAddress)}))
+	ips, err := cache.GetIPv4sFromEC2(ctx, eniID)
+	assert.NoError(t, err)
+	assert.Len(t, ips, 5)
+
+	// The instance type limits are enforced
+	_, err = cache.AllocENI(ctx, false, nil, "")
+	assert.NoError(t, err)
+	_, err = cache.AllocENI(ctx, false, nil, "")
+	assert.Error(t, err)
+	assert.Equal(t, 3, provider.Calls("AttachNetworkInterface"))
+
+	assert.NoError(t, cache.FreeENI(ctx, eniID))
+	enis, err := cache.GetAttachedENIs()
+	assert.NoError(t, err)
+	assert.Len(t, enis, 2)
+	newAvailable, err := cache.GetSubnetAvailableIPCount("")
+	assert.NoError(t, err)
+	assert.Equal(t, available-1, newAvailable)
+}
+
+func TestPrefixes(t *testing.T) {
+	ctx := context.Background()
+	provider, err := fakeprovider.New(fakeprovider.DefaultConfig())
+	require.NoError(t, err)
+	mac, err := provider.GetMetadataWithContext(ctx, "mac")
+	require.NoError(t, err)
+	eniID, err := provider.GetMetadataWithContext(ctx, "network/interfaces/macs/"+mac+"/interface-id")
+	require.NoError(t, err)
+
+	_, err = provider.GetMetadataWithContext(ctx, "network/interfaces/macs/"+mac+"/ipv4-prefix")
+	assert.True(t, awsutils.IsNotFound(err))
+
+	output, err := provider.AssignPrivateIpAddressesWithContext(ctx, &ec2.AssignPrivateIpAddressesInput{
+		NetworkInterfaceId: aws.String(eniID),
+		Ipv4PrefixCount:    aws.Int64(2),
+	})
+	require.NoError(t, err)
+	// The primary IP is in the first /28, so the prefixes come after it
+	assert.Equal(t, "10.0.0.16/28", aws.StringValue(output.AssignedIpv4Prefixes[0].Ipv4Prefix))
+	assert.Equal(t, "10.0.0.32/28", aws.StringValue(output.AssignedIpv4Prefixes[1].Ipv4Prefix))
+	prefixes, err := provider.GetMetadataWithContext(ctx, "network/interfaces/macs/"+mac+"/ipv4-prefix")
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.16/28\n10.0.0.32/28", prefixes)
+
+	_, err = provider.AssignPrivateIpAddressesWithContext(ctx, &ec2.AssignPrivateIpAddressesInput{
+		NetworkInterfaceId: aws.String(eniID),
+		Ipv4PrefixCount:    aws.Int64(8),
+	})
+	if assert.Error(t, err) {
+		assert.Equal(t, "PrivateIpAddressLimitExceeded", err.(awserr.Error).Code())
+	}
+
+	_, err = provider.UnassignPrivateIpAddressesWithContext(ctx, &ec2.UnassignPrivateIpAddressesInput{
+		NetworkInterfaceId: aws.String(eniID),
+		Ipv4Prefixes:       []*string{output.AssignedIpv4Prefixes[0].Ipv4Prefix},
+	})
+	assert.NoError(t, err)
+	prefixes, err = provider.GetMetadataWithContext(ctx, "network/interfaces/macs/"+mac+"/ipv4-prefix")
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.32/28", prefixes)
+}
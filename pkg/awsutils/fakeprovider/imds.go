@@ -0,0 +1,89 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fakeprovider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+const macsPrefix = "network/interfaces/macs/"
+
+// GetMetadataWithContext returns the instance metadata at path p, which always reflects the state of the ENIs
+func (p *Provider) GetMetadataWithContext(ctx context.Context, path string) (string, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	attached := p.attachedENIs()
+	primary := attached[0]
+	switch path {
+	case "placement/availability-zone":
+		return p.cfg.AvailabilityZone, nil
+	case "instance-type":
+		return p.cfg.InstanceType, nil
+	case "instance-id":
+		return p.cfg.InstanceID, nil
+	case "mac":
+		return primary.mac, nil
+	case "local-ipv4":
+		return primary.ips[0], nil
+	case "network/interfaces/macs", "network/interfaces/macs/":
+		macs := make([]string, len(attached))
+		for i, e := range attached {
+			macs[i] = e.mac + "/"
+		}
+		return strings.Join(macs, "\n"), nil
+	}
+
+	if strings.HasPrefix(path, macsPrefix) {
+		parts := strings.SplitN(strings.TrimPrefix(path, macsPrefix), "/", 2)
+		for _, e := range attached {
+			if len(parts) == 2 && e.mac == parts[0] {
+				if value, ok := p.interfaceMetadata(e, parts[1]); ok {
+					return value, nil
+				}
+			}
+		}
+	}
+	return "", awserr.NewRequestFailure(awserr.New("NotFound", fmt.Sprintf("%s not found", path), nil), http.StatusNotFound, "")
+}
+
+// interfaceMetadata returns the metadata key of an attached ENI
+func (p *Provider) interfaceMetadata(e *eni, key string) (string, bool) {
+	switch key {
+	case "interface-id":
+		return e.id, true
+	case "device-number":
+		return strconv.Itoa(e.deviceIndex), true
+	case "subnet-id":
+		return p.cfg.SubnetID, true
+	case "vpc-id":
+		return p.cfg.VPCID, true
+	case "security-group-ids":
+		return strings.Join(e.groups, "\n"), true
+	case "local-ipv4s":
+		return strings.Join(e.ips, "\n"), true
+	case "ipv4-prefix":
+		return strings.Join(e.prefixes, "\n"), len(e.prefixes) > 0
+	case "subnet-ipv4-cidr-block":
+		return p.subnet.String(), true
+	case "vpc-ipv4-cidr-blocks":
+		return p.cfg.VPCCIDR, true
+	}
+	return "", false
+}
@@ -106,6 +106,23 @@ func (imds TypedIMDS) GetInstanceID(ctx context.Context) (string, error) {
 	return instanceID, err
 }
 
+// GetTargetLifecycleState returns the lifecycle state the Auto Scaling group is moving the instance to, e.g.
+// InService or Terminated, or "" if the instance isn't in an Auto Scaling group.
+func (imds TypedIMDS) GetTargetLifecycleState(ctx context.Context) (string, error) {
+	state, err := imds.GetMetadataWithContext(ctx, "autoscaling/target-lifecycle-state")
+	if err != nil {
+		if imdsErr, ok := err.(*imdsRequestError); ok {
+			if IsNotFound(imdsErr.err) {
+				return "", nil
+			}
+			log.Warnf("%v", err)
+			return state, imdsErr.err
+		}
+		return "", err
+	}
+	return state, err
+}
+
 // GetMAC returns the first/primary network interface mac address.
 func (imds TypedIMDS) GetMAC(ctx context.Context) (string, error) {
 	mac, err := imds.GetMetadataWithContext(ctx, "mac")
@@ -172,6 +189,24 @@ func (imds TypedIMDS) GetDeviceNumber(ctx context.Context, mac string) (int, err
 	return imds.getInt(ctx, key)
 }
 
+// GetNetworkCard returns the index of the network card the interface is attached to. Instances with a single network
+// card don't report it, and 0 is returned.
+func (imds TypedIMDS) GetNetworkCard(ctx context.Context, mac string) (int, error) {
+	key := fmt.Sprintf("network/interfaces/macs/%s/network-card", mac)
+	data, err := imds.GetMetadataWithContext(ctx, key)
+	if err != nil {
+		if imdsErr, ok := err.(*imdsRequestError); ok {
+			if IsNotFound(imdsErr.err) {
+				return 0, nil
+			}
+			log.Warnf("%v", err)
+			return 0, imdsErr.err
+		}
+		return 0, err
+	}
+	return strconv.Atoi(data)
+}
+
 // GetSubnetID returns the ID of the subnet in which the interface resides.
 func (imds TypedIMDS) GetSubnetID(ctx context.Context, mac string) (string, error) {
 	key := fmt.Sprintf("network/interfaces/macs/%s/subnet-id", mac)
@@ -186,6 +221,20 @@ func (imds TypedIMDS) GetSubnetID(ctx context.Context, mac string) (string, erro
 	return subnetID, err
 }
 
+// GetVPCID returns the ID of the VPC in which the interface resides.
+func (imds TypedIMDS) GetVPCID(ctx context.Context, mac string) (string, error) {
+	key := fmt.Sprintf("network/interfaces/macs/%s/vpc-id", mac)
+	vpcID, err := imds.GetMetadataWithContext(ctx, key)
+	if err != nil {
+		if imdsErr, ok := err.(*imdsRequestError); ok {
+			log.Warnf("%v", err)
+			return vpcID, imdsErr.err
+		}
+		return "", err
+	}
+	return vpcID, err
+}
+
 // GetSecurityGroupIDs returns the IDs of the security groups to which the network interface belongs.
 func (imds TypedIMDS) GetSecurityGroupIDs(ctx context.Context, mac string) ([]string, error) {
 	key := fmt.Sprintf("network/interfaces/macs/%s/security-group-ids", mac)
@@ -0,0 +1,214 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package awsutils
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// imdsAttemptsBeforeEC2Fallback is the number of times waitForENIAndIPsAttached polls IMDS for a new ENI before
+	// it asks EC2 instead. With the 100ms initial backoff, this is about 3 seconds.
+	imdsAttemptsBeforeEC2Fallback = 5
+
+	// imdsSyncTimeout is how long GetAttachedENIs keeps reporting an ENI found through EC2 while IMDS doesn't list it
+	imdsSyncTimeout = 15 * time.Minute
+)
+
+var (
+	imdsENISyncLag = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "awscni_imds_eni_sync_lag_seconds",
+			Help:    "Time from the attachment of an ENI and its IPs until IMDS reflects them",
+			Buckets: prometheus.ExponentialBuckets(0.5, 2, 12),
+		},
+	)
+	eniMetadataEC2Fallback = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "awscni_eni_metadata_ec2_fallback_count",
+			Help: "The number of times the metadata of a new ENI was read from EC2 because IMDS did not reflect it yet",
+		},
+	)
+)
+
+// imdsPendingENI is an ENI found through EC2 that IMDS did not fully reflect yet
+type imdsPendingENI struct {
+	eniMetadata ENIMetadata
+	since       time.Time
+}
+
+// eniCidrCount returns the number of secondary IPs, or prefixes with prefix delegation, of the ENI
+func (cache *EC2InstanceMetadataCache) eniCidrCount(eni ENIMetadata) int {
+	if cache.enablePrefixDelegation {
+		if cache.v6Enabled {
+			return len(eni.IPv6Prefixes)
+		}
+		return len(eni.IPv4Prefixes)
+	}
+	// Ignore primary IP of the ENI
+	return len(eni.IPv4Addresses) - 1
+}
+
+// getENIMetadataFromEC2 builds the metadata of an ENI attached to this instance from DescribeNetworkInterfaces, in
+// the same shape as getENIMetadata does from IMDS. It returns ErrENINotFound while the ENI is not attached.
+func (cache *EC2InstanceMetadataCache) getENIMetadataFromEC2(ctx context.Context, eniID string) (ENIMetadata, error) {
+	input := &ec2.DescribeNetworkInterfacesInput{
+		NetworkInterfaceIds: []*string{aws.String(eniID)},
+	}
+	start := time.Now()
+	result, err := cache.ec2SVC.DescribeNetworkInterfacesWithContext(ctx, input)
+	awsAPILatency.WithLabelValues("DescribeNetworkInterfaces", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
+	if err != nil {
+		CheckAPIErrorAndBroadcastEvent(err, "ec2:DescribeNetworkInterfaces")
+		awsAPIErrInc("DescribeNetworkInterfaces", err)
+		return ENIMetadata{}, errors.Wrapf(err, "failed to describe network interface %s", eniID)
+	}
+	if len(result.NetworkInterfaces) != 1 {
+		return ENIMetadata{}, ErrENINotFound
+	}
+	eni := result.NetworkInterfaces[0]
+	attachment := eni.Attachment
+	if attachment == nil || aws.StringValue(attachment.InstanceId) != cache.instanceID ||
+		aws.StringValue(attachment.Status) != ec2.AttachmentStatusAttached {
+		return ENIMetadata{}, ErrENINotFound
+	}
+
+	cidr, err := cache.getSubnetIPv4CIDR(ctx, aws.StringValue(eni.SubnetId))
+	if err != nil {
+		return ENIMetadata{}, err
+	}
+
+	// IMDS lists the primary IP first
+	var ec2ip4s []*ec2.NetworkInterfacePrivateIpAddress
+	for _, ip := range eni.PrivateIpAddresses {
+		ec2ip4 := &ec2.NetworkInterfacePrivateIpAddress{
+			Primary:          aws.Bool(aws.BoolValue(ip.Primary)),
+			PrivateIpAddress: ip.PrivateIpAddress,
+		}
+		if aws.BoolValue(ip.Primary) {
+			ec2ip4s = append([]*ec2.NetworkInterfacePrivateIpAddress{ec2ip4}, ec2ip4s...)
+		} else {
+			ec2ip4s = append(ec2ip4s, ec2ip4)
+		}
+	}
+
+	var ec2ipv4Prefixes []*ec2.Ipv4PrefixSpecification
+	var ec2ipv6Prefixes []*ec2.Ipv6PrefixSpecification
+	if cache.v6Enabled {
+		for _, prefix := range eni.Ipv6Prefixes {
+			ec2ipv6Prefixes = append(ec2ipv6Prefixes, &ec2.Ipv6PrefixSpecification{Ipv6Prefix: prefix.Ipv6Prefix})
+		}
+	} else if cache.v4Enabled {
+		for _, prefix := range eni.Ipv4Prefixes {
+			ec2ipv4Prefixes = append(ec2ipv4Prefixes, &ec2.Ipv4PrefixSpecification{Ipv4Prefix: prefix.Ipv4Prefix})
+		}
+	}
+
+	networkCard := int(aws.Int64Value(attachment.NetworkCardIndex))
+	return ENIMetadata{
+		ENIID:          eniID,
+		MAC:            aws.StringValue(eni.MacAddress),
+		DeviceNumber:   cache.deviceNumber(networkCard, int(aws.Int64Value(attachment.DeviceIndex))),
+		NetworkCard:    networkCard,
+		SubnetIPv4CIDR: cidr,
+		IPv4Addresses:  ec2ip4s,
+		IPv4Prefixes:   ec2ipv4Prefixes,
+		IPv6Prefixes:   ec2ipv6Prefixes,
+	}, nil
+}
+
+// getSubnetIPv4CIDR returns the IPv4 CIDR of a subnet, which doesn't change, so it is only looked up once
+func (cache *EC2InstanceMetadataCache) getSubnetIPv4CIDR(ctx context.Context, subnetID string) (string, error) {
+	cache.imdsPendingLock.Lock()
+	cidr, ok := cache.subnetIPv4CIDRs[subnetID]
+	cache.imdsPendingLock.Unlock()
+	if ok {
+		return cidr, nil
+	}
+
+	start := time.Now()
+	output, err := cache.ec2SVC.DescribeSubnetsWithContext(ctx, &ec2.DescribeSubnetsInput{
+		SubnetIds: []*string{aws.String(subnetID)}})
+	awsAPILatency.WithLabelValues("DescribeSubnets", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
+	if err != nil {
+		awsAPIErrInc("DescribeSubnets", err)
+		return "", errors.Wrapf(err, "failed to describe subnet %s", subnetID)
+	}
+	if len(output.Subnets) != 1 {
+		return "", errors.Errorf("subnet %s not found", subnetID)
+	}
+	cidr = aws.StringValue(output.Subnets[0].CidrBlock)
+
+	cache.imdsPendingLock.Lock()
+	defer cache.imdsPendingLock.Unlock()
+	if cache.subnetIPv4CIDRs == nil {
+		cache.subnetIPv4CIDRs = make(map[string]string)
+	}
+	cache.subnetIPv4CIDRs[subnetID] = cidr
+	return cidr, nil
+}
+
+// addIMDSPendingENI remembers an ENI found through EC2 since, so that GetAttachedENIs reports it until IMDS does
+func (cache *EC2InstanceMetadataCache) addIMDSPendingENI(eniMetadata ENIMetadata, since time.Time) {
+	eniMetadataEC2Fallback.Inc()
+	cache.imdsPendingLock.Lock()
+	defer cache.imdsPendingLock.Unlock()
+	if cache.imdsPendingENIs == nil {
+		cache.imdsPendingENIs = make(map[string]imdsPendingENI)
+	}
+	cache.imdsPendingENIs[eniMetadata.ENIID] = imdsPendingENI{eniMetadata: eniMetadata, since: since}
+}
+
+// mergeIMDSPendingENIs adds the ENIs found through EC2 that are still missing from the ENIs listed by IMDS, so that
+// the reconciler doesn't take them for detached. An ENI stops being pending once IMDS lists it with at least the
+// IPs or prefixes EC2 returned, or after imdsSyncTimeout.
+func (cache *EC2InstanceMetadataCache) mergeIMDSPendingENIs(enis []ENIMetadata) []ENIMetadata {
+	cache.imdsPendingLock.Lock()
+	defer cache.imdsPendingLock.Unlock()
+	if len(cache.imdsPendingENIs) == 0 {
+		return enis
+	}
+
+	imdsENIs := make(map[string]ENIMetadata, len(enis))
+	for _, eni := range enis {
+		imdsENIs[eni.ENIID] = eni
+	}
+	for eniID, pending := range cache.imdsPendingENIs {
+		lag := time.Since(pending.since)
+		if imdsENI, ok := imdsENIs[eniID]; ok {
+			if cache.eniCidrCount(imdsENI) >= cache.eniCidrCount(pending.eniMetadata) {
+				log.Infof("IMDS caught up with ENI %s after %v", eniID, lag)
+				imdsENISyncLag.Observe(lag.Seconds())
+				delete(cache.imdsPendingENIs, eniID)
+			}
+			continue
+		}
+		if lag > imdsSyncTimeout {
+			log.Errorf("IMDS still doesn't list ENI %s after %v, no longer reporting it from EC2", eniID, lag)
+			awsUtilsErrInc("imdsSyncTimeout", errors.Errorf("ENI %s missing from IMDS", eniID))
+			delete(cache.imdsPendingENIs, eniID)
+			continue
+		}
+		log.Debugf("ENI %s is not in IMDS yet, using its EC2 metadata", eniID)
+		enis = append(enis, pending.eniMetadata)
+	}
+	return enis
+}
@@ -0,0 +1,136 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package awsutils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	mock_ec2wrapper "github.com/aws/amazon-vpc-cni-k8s/pkg/ec2wrapper/mocks"
+)
+
+// expectENIFromEC2 makes EC2 describe eni2 as attached to the instance at device index 1, with ips, the first being
+// the primary one, and prefixes
+func expectENIFromEC2(mockEC2 *mock_ec2wrapper.MockEC2, ips []string, ipv4Prefixes []string, ipv6Prefixes []string) {
+	eni := &ec2.NetworkInterface{
+		NetworkInterfaceId: aws.String(eni2ID),
+		MacAddress:         aws.String(eni2MAC),
+		SubnetId:           aws.String(subnetID),
+		Attachment: &ec2.NetworkInterfaceAttachment{
+			InstanceId:       aws.String(instanceID),
+			Status:           aws.String(ec2.AttachmentStatusAttached),
+			DeviceIndex:      aws.Int64(1),
+			NetworkCardIndex: aws.Int64(0),
+		},
+	}
+	// EC2 doesn't necessarily list the primary IP first
+	for _, ip := range append(ips[1:], ips[0]) {
+		eni.PrivateIpAddresses = append(eni.PrivateIpAddresses, &ec2.NetworkInterfacePrivateIpAddress{
+			Primary: aws.Bool(ip == ips[0]), PrivateIpAddress: aws.String(ip)})
+	}
+	for _, prefix := range ipv4Prefixes {
+		eni.Ipv4Prefixes = append(eni.Ipv4Prefixes, &ec2.Ipv4PrefixSpecification{Ipv4Prefix: aws.String(prefix)})
+	}
+	for _, prefix := range ipv6Prefixes {
+		eni.Ipv6Prefixes = append(eni.Ipv6Prefixes, &ec2.Ipv6PrefixSpecification{Ipv6Prefix: aws.String(prefix)})
+	}
+	mockEC2.EXPECT().DescribeNetworkInterfacesWithContext(gomock.Any(), &ec2.DescribeNetworkInterfacesInput{
+		NetworkInterfaceIds: []*string{aws.String(eni2ID)}}).
+		Return(&ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: []*ec2.NetworkInterface{eni}}, nil).AnyTimes()
+	mockEC2.EXPECT().DescribeSubnetsWithContext(gomock.Any(), gomock.Any()).
+		Return(&ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{{SubnetId: aws.String(subnetID), CidrBlock: aws.String(subnetCIDR)}}}, nil).
+		MaxTimes(1)
+}
+
+func TestWaitForENIAndIPsAttachedFallsBackToEC2(t *testing.T) {
+	ctrl, mockEC2 := setup(t)
+	defer ctrl.Finish()
+
+	// IMDS only knows the primary ENI
+	cache := &EC2InstanceMetadataCache{imds: TypedIMDS{testMetadata(nil)}, ec2SVC: mockEC2, instanceID: instanceID, v4Enabled: true}
+	expectENIFromEC2(mockEC2, []string{eni2PrivateIP, "10.0.0.20", "10.0.0.21"}, nil, nil)
+
+	eniMetadata, err := cache.waitForENIAndIPsAttached(context.Background(), eni2ID, 2, 5*time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, eni2MAC, eniMetadata.MAC)
+	assert.Equal(t, 1, eniMetadata.DeviceNumber)
+	assert.Equal(t, subnetCIDR, eniMetadata.SubnetIPv4CIDR)
+	assert.Len(t, eniMetadata.IPv4Addresses, 3)
+	assert.Equal(t, eni2PrivateIP, aws.StringValue(eniMetadata.IPv4Addresses[0].PrivateIpAddress))
+	assert.True(t, aws.BoolValue(eniMetadata.IPv4Addresses[0].Primary))
+
+	// Until IMDS lists it, the ENI is reported from EC2, so that the reconciler doesn't remove it
+	enis, err := cache.GetAttachedENIs()
+	assert.NoError(t, err)
+	assert.Len(t, enis, 2)
+	assert.Equal(t, eniMetadata, enis[1])
+
+	// IMDS lists the ENI, but not all the IPs yet
+	cache.imds = TypedIMDS{testMetadata(map[string]interface{}{
+		metadataMACPath: primaryMAC + " " + eni2MAC,
+		metadataMACPath + eni2MAC + metadataDeviceNum:  eni2Device,
+		metadataMACPath + eni2MAC + metadataInterface:  eni2ID,
+		metadataMACPath + eni2MAC + metadataSubnetCIDR: subnetCIDR,
+		metadataMACPath + eni2MAC + metadataIPv4s:      eni2PrivateIP + " 10.0.0.20",
+	})}
+	enis, err = cache.GetAttachedENIs()
+	assert.NoError(t, err)
+	assert.Len(t, enis, 2)
+	assert.Len(t, enis[1].IPv4Addresses, 2)
+	assert.Len(t, cache.imdsPendingENIs, 1)
+
+	// IMDS caught up
+	cache.imds = TypedIMDS{testMetadata(map[string]interface{}{
+		metadataMACPath: primaryMAC + " " + eni2MAC,
+		metadataMACPath + eni2MAC + metadataDeviceNum:  eni2Device,
+		metadataMACPath + eni2MAC + metadataInterface:  eni2ID,
+		metadataMACPath + eni2MAC + metadataSubnetCIDR: subnetCIDR,
+		metadataMACPath + eni2MAC + metadataIPv4s:      eni2PrivateIP + " 10.0.0.20 10.0.0.21",
+	})}
+	enis, err = cache.GetAttachedENIs()
+	assert.NoError(t, err)
+	assert.Len(t, enis, 2)
+	assert.Empty(t, cache.imdsPendingENIs)
+}
+
+func TestMergeIMDSPendingENIsTimeout(t *testing.T) {
+	cache := &EC2InstanceMetadataCache{}
+	cache.addIMDSPendingENI(ENIMetadata{ENIID: eni2ID}, time.Now().Add(-imdsSyncTimeout-time.Minute))
+	primary := []ENIMetadata{{ENIID: primaryeniID}}
+	assert.Equal(t, primary, cache.mergeIMDSPendingENIs(primary))
+	assert.Empty(t, cache.imdsPendingENIs)
+}
+
+func TestGetENIMetadataFromEC2NotAttached(t *testing.T) {
+	ctrl, mockEC2 := setup(t)
+	defer ctrl.Finish()
+
+	cache := &EC2InstanceMetadataCache{ec2SVC: mockEC2, instanceID: instanceID}
+	mockEC2.EXPECT().DescribeNetworkInterfacesWithContext(gomock.Any(), gomock.Any()).Return(
+		&ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: []*ec2.NetworkInterface{{
+			NetworkInterfaceId: aws.String(eni2ID),
+			Attachment: &ec2.NetworkInterfaceAttachment{
+				InstanceId: aws.String(instanceID),
+				Status:     aws.String(ec2.AttachmentStatusAttaching),
+			},
+		}}}, nil)
+	_, err := cache.getENIMetadataFromEC2(context.Background(), eni2ID)
+	assert.Equal(t, ErrENINotFound, err)
+}
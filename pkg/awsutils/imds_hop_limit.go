@@ -0,0 +1,135 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package awsutils
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils/awssession"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ec2wrapper"
+)
+
+// containerIMDSHopLimit is the IMDSv2 hop limit that lets the responses of the instance metadata service reach a
+// container network namespace, one hop away from the instance
+const containerIMDSHopLimit = 2
+
+// sysClassNetDir lists the network interfaces of the network namespace of ipamd
+var sysClassNetDir = "/sys/class/net"
+
+// IMDSHopLimitError is returned by New when the instance metadata service can't be reached from a container network
+// namespace. With IMDSv2 required and a hop limit of 1, the responses to the token requests of a container are dropped.
+type IMDSHopLimitError struct {
+	Err error
+}
+
+func (e *IMDSHopLimitError) Error() string {
+	return fmt.Sprintf("instance metadata is unreachable from the container network namespace of aws-node: %v. "+
+		"If the instance requires IMDSv2 with a hop limit of 1, the responses never reach containers: run aws-node with "+
+		"hostNetwork, raise the hop limit with \"aws ec2 modify-instance-metadata-options --instance-id <instance ID> "+
+		"--http-put-response-hop-limit %d\", or set ENABLE_IMDS_HOP_LIMIT_FIX=true to let aws-node raise it", e.Err, containerIMDSHopLimit)
+}
+
+func (e *IMDSHopLimitError) Unwrap() error {
+	return e.Err
+}
+
+// inContainerNetworkNamespace returns true if no physical network device is visible, which is the case of a pod that
+// doesn't use the host network. The ENIs of the instance have a device, unlike the veth and other virtual interfaces.
+func inContainerNetworkNamespace() bool {
+	links, err := ioutil.ReadDir(sysClassNetDir)
+	if err != nil {
+		log.Debugf("Unable to list the network interfaces in %s: %v", sysClassNetDir, err)
+		return false
+	}
+	for _, link := range links {
+		if _, err := os.Stat(filepath.Join(sysClassNetDir, link.Name(), "device")); err == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// FixIMDSHopLimit raises the IMDSv2 hop limit of the instance of a node to 2, using the EC2 API, when IMDSv2 is
+// required and the hop limit is 1. The instance and its region are read from the provider ID of the node, since the
+// instance metadata is unreachable. It returns an error if the metadata options don't explain the failure.
+func FixIMDSHopLimit(providerID string) error {
+	region, instanceID, err := parseProviderID(providerID)
+	if err != nil {
+		return err
+	}
+	if envRegion := os.Getenv("AWS_REGION"); envRegion != "" {
+		region = envRegion
+	}
+	sess := awssession.New().Copy(aws.NewConfig().WithRegion(region))
+	return fixIMDSHopLimit(ec2wrapper.New(sess), instanceID)
+}
+
+func fixIMDSHopLimit(ec2SVC ec2wrapper.EC2, instanceID string) error {
+	ctx, cancel := context.WithTimeout(WithCaller(context.Background(), CallerStartup), time.Minute)
+	defer cancel()
+
+	start := time.Now()
+	result, err := ec2SVC.DescribeInstancesWithContext(ctx, &ec2.DescribeInstancesInput{InstanceIds: []*string{aws.String(instanceID)}})
+	awsAPILatency.WithLabelValues("DescribeInstances", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
+	if err != nil {
+		awsAPIErrInc("DescribeInstances", err)
+		return errors.Wrapf(err, "failed to get the metadata options of instance %s", instanceID)
+	}
+	if len(result.Reservations) != 1 || len(result.Reservations[0].Instances) != 1 {
+		return errors.Errorf("instance %s not found", instanceID)
+	}
+	options := result.Reservations[0].Instances[0].MetadataOptions
+	if options == nil || aws.StringValue(options.HttpTokens) != ec2.HttpTokensStateRequired ||
+		aws.Int64Value(options.HttpPutResponseHopLimit) >= containerIMDSHopLimit {
+		return errors.Errorf("the metadata options of instance %s don't block containers: %v", instanceID, options)
+	}
+
+	log.Infof("Raising the IMDSv2 hop limit of instance %s from %d to %d", instanceID,
+		aws.Int64Value(options.HttpPutResponseHopLimit), containerIMDSHopLimit)
+	start = time.Now()
+	_, err = ec2SVC.ModifyInstanceMetadataOptionsWithContext(ctx, &ec2.ModifyInstanceMetadataOptionsInput{
+		InstanceId:              aws.String(instanceID),
+		HttpPutResponseHopLimit: aws.Int64(containerIMDSHopLimit),
+	})
+	awsAPILatency.WithLabelValues("ModifyInstanceMetadataOptions", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
+	if err != nil {
+		awsAPIErrInc("ModifyInstanceMetadataOptions", err)
+		return errors.Wrapf(err, "failed to raise the IMDSv2 hop limit of instance %s", instanceID)
+	}
+	return nil
+}
+
+// parseProviderID returns the region and the instance ID of a node provider ID, aws:///<availability zone>/<instance ID>
+func parseProviderID(providerID string) (region, instanceID string, err error) {
+	parts := strings.Split(strings.TrimPrefix(providerID, "aws://"), "/")
+	if !strings.HasPrefix(providerID, "aws://") || len(parts) != 3 || parts[1] == "" || !strings.HasPrefix(parts[2], "i-") {
+		return "", "", errors.Errorf("invalid node provider ID %q", providerID)
+	}
+	// us-west-2a is in us-west-2, and so is the local zone us-west-2-lax-1a
+	zoneParts := strings.Split(strings.TrimRight(parts[1], "abcdefghijklmnopqrstuvwxyz"), "-")
+	if len(zoneParts) < 3 {
+		return "", "", errors.Errorf("invalid availability zone %q in node provider ID %q", parts[1], providerID)
+	}
+	return strings.Join(zoneParts[:3], "-"), parts[2], nil
+}
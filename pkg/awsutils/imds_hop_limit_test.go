@@ -0,0 +1,95 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package awsutils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProviderID(t *testing.T) {
+	region, instanceID, err := parseProviderID("aws:///us-west-2a/i-0123456789abcdef0")
+	assert.NoError(t, err)
+	assert.Equal(t, "us-west-2", region)
+	assert.Equal(t, "i-0123456789abcdef0", instanceID)
+
+	region, _, err = parseProviderID("aws:///us-west-2-lax-1a/i-0123456789abcdef0")
+	assert.NoError(t, err)
+	assert.Equal(t, "us-west-2", region)
+
+	for _, providerID := range []string{"", "gce:///us-west-2a/i-0123456789abcdef0", "aws:///us-west-2a", "aws:////i-0123456789abcdef0", "aws:///a/i-0123456789abcdef0"} {
+		_, _, err = parseProviderID(providerID)
+		assert.Error(t, err, providerID)
+	}
+}
+
+func TestInContainerNetworkNamespace(t *testing.T) {
+	defer func(dir string) { sysClassNetDir = dir }(sysClassNetDir)
+	sysClassNetDir = t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(sysClassNetDir, "lo"), 0755))
+	assert.NoError(t, os.Mkdir(filepath.Join(sysClassNetDir, "eth0"), 0755))
+	assert.True(t, inContainerNetworkNamespace())
+
+	// An ENI has a device
+	assert.NoError(t, os.Mkdir(filepath.Join(sysClassNetDir, "eth0", "device"), 0755))
+	assert.False(t, inContainerNetworkNamespace())
+
+	sysClassNetDir = filepath.Join(sysClassNetDir, "missing")
+	assert.False(t, inContainerNetworkNamespace())
+}
+
+func TestFixIMDSHopLimit(t *testing.T) {
+	ctrl, mockEC2 := setup(t)
+	defer ctrl.Finish()
+
+	describeResult := func(httpTokens string, hopLimit int64) *ec2.DescribeInstancesOutput {
+		return &ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{Instances: []*ec2.Instance{{
+			MetadataOptions: &ec2.InstanceMetadataOptionsResponse{
+				HttpTokens:              aws.String(httpTokens),
+				HttpPutResponseHopLimit: aws.Int64(hopLimit),
+			},
+		}}}}}
+	}
+
+	mockEC2.EXPECT().DescribeInstancesWithContext(gomock.Any(), gomock.Any()).Return(describeResult(ec2.HttpTokensStateRequired, 1), nil)
+	mockEC2.EXPECT().ModifyInstanceMetadataOptionsWithContext(gomock.Any(), &ec2.ModifyInstanceMetadataOptionsInput{
+		InstanceId:              aws.String(instanceID),
+		HttpPutResponseHopLimit: aws.Int64(2),
+	}).Return(&ec2.ModifyInstanceMetadataOptionsOutput{}, nil)
+	assert.NoError(t, fixIMDSHopLimit(mockEC2, instanceID))
+
+	// The hop limit is not the cause of the failure
+	mockEC2.EXPECT().DescribeInstancesWithContext(gomock.Any(), gomock.Any()).Return(describeResult(ec2.HttpTokensStateOptional, 1), nil)
+	assert.Error(t, fixIMDSHopLimit(mockEC2, instanceID))
+	mockEC2.EXPECT().DescribeInstancesWithContext(gomock.Any(), gomock.Any()).Return(describeResult(ec2.HttpTokensStateRequired, 2), nil)
+	assert.Error(t, fixIMDSHopLimit(mockEC2, instanceID))
+
+	mockEC2.EXPECT().DescribeInstancesWithContext(gomock.Any(), gomock.Any()).Return(nil, errors.New("denied"))
+	assert.Error(t, fixIMDSHopLimit(mockEC2, instanceID))
+}
+
+func TestIMDSHopLimitError(t *testing.T) {
+	cause := errors.New("EC2MetadataError: failed to get IMDSv2 token")
+	err := error(&IMDSHopLimitError{Err: cause})
+	assert.True(t, errors.Is(err, cause))
+	assert.Contains(t, err.Error(), "--http-put-response-hop-limit 2")
+	assert.Contains(t, err.Error(), "ENABLE_IMDS_HOP_LIMIT_FIX")
+}
@@ -65,6 +65,23 @@ func TestGetInstanceID(t *testing.T) {
 	}
 }
 
+func TestGetTargetLifecycleState(t *testing.T) {
+	f := TypedIMDS{FakeIMDS(map[string]interface{}{
+		"autoscaling/target-lifecycle-state": "Terminated",
+	})}
+
+	state, err := f.GetTargetLifecycleState(context.TODO())
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Terminated", state)
+	}
+
+	// Not in an Auto Scaling group
+	state, err = TypedIMDS{FakeIMDS(map[string]interface{}{})}.GetTargetLifecycleState(context.TODO())
+	if assert.NoError(t, err) {
+		assert.Equal(t, "", state)
+	}
+}
+
 func TestGetMAC(t *testing.T) {
 	f := TypedIMDS{FakeIMDS(map[string]interface{}{
 		"mac": "02:68:f3:f6:c7:ef",
@@ -115,6 +132,23 @@ func TestGetDeviceNumber(t *testing.T) {
 	}
 }
 
+func TestGetNetworkCard(t *testing.T) {
+	f := TypedIMDS{FakeIMDS(map[string]interface{}{
+		"network/interfaces/macs/02:c5:f8:3e:6b:27/network-card": "2",
+	})}
+
+	n, err := f.GetNetworkCard(context.TODO(), "02:c5:f8:3e:6b:27")
+	if assert.NoError(t, err) {
+		assert.Equal(t, n, 2)
+	}
+
+	// Instances with a single network card don't report it
+	n, err = f.GetNetworkCard(context.TODO(), "00:00:de:ad:be:ef")
+	if assert.NoError(t, err) {
+		assert.Equal(t, n, 0)
+	}
+}
+
 func TestGetSubnetID(t *testing.T) {
 	f := TypedIMDS{FakeIMDS(map[string]interface{}{
 		"network/interfaces/macs/02:c5:f8:3e:6b:27/subnet-id": "subnet-0afaed81bf542db37",
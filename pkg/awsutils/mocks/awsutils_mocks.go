@@ -19,8 +19,10 @@
 package mock_awsutils
 
 import (
+	context "context"
 	net "net"
 	reflect "reflect"
+	time "time"
 
 	awsutils "github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils"
 	ec2 "github.com/aws/aws-sdk-go/service/ec2"
@@ -50,106 +52,192 @@ func (m *MockAPIs) EXPECT() *MockAPIsMockRecorder {
 	return m.recorder
 }
 
+// AddENITags mocks base method
+func (m *MockAPIs) AddENITags(arg0 context.Context, arg1 string, arg2 map[string]string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddENITags", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddENITags indicates an expected call of AddENITags
+func (mr *MockAPIsMockRecorder) AddENITags(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddENITags", reflect.TypeOf((*MockAPIs)(nil).AddENITags), arg0, arg1, arg2)
+}
+
 // AllocENI mocks base method
-func (m *MockAPIs) AllocENI(arg0 bool, arg1 []*string, arg2 string) (string, error) {
+func (m *MockAPIs) AllocENI(arg0 context.Context, arg1 bool, arg2 []*string, arg3 string) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AllocENI", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "AllocENI", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // AllocENI indicates an expected call of AllocENI
-func (mr *MockAPIsMockRecorder) AllocENI(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockAPIsMockRecorder) AllocENI(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllocENI", reflect.TypeOf((*MockAPIs)(nil).AllocENI), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllocENI", reflect.TypeOf((*MockAPIs)(nil).AllocENI), arg0, arg1, arg2, arg3)
 }
 
 // AllocIPAddress mocks base method
-func (m *MockAPIs) AllocIPAddress(arg0 string) error {
+func (m *MockAPIs) AllocIPAddress(arg0 context.Context, arg1 string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AllocIPAddress", arg0)
+	ret := m.ctrl.Call(m, "AllocIPAddress", arg0, arg1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // AllocIPAddress indicates an expected call of AllocIPAddress
-func (mr *MockAPIsMockRecorder) AllocIPAddress(arg0 interface{}) *gomock.Call {
+func (mr *MockAPIsMockRecorder) AllocIPAddress(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllocIPAddress", reflect.TypeOf((*MockAPIs)(nil).AllocIPAddress), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllocIPAddress", reflect.TypeOf((*MockAPIs)(nil).AllocIPAddress), arg0, arg1)
 }
 
 // AllocIPAddresses mocks base method
-func (m *MockAPIs) AllocIPAddresses(arg0 string, arg1 int) (*ec2.AssignPrivateIpAddressesOutput, error) {
+func (m *MockAPIs) AllocIPAddresses(arg0 context.Context, arg1 string, arg2 int) (*ec2.AssignPrivateIpAddressesOutput, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AllocIPAddresses", arg0, arg1)
+	ret := m.ctrl.Call(m, "AllocIPAddresses", arg0, arg1, arg2)
 	ret0, _ := ret[0].(*ec2.AssignPrivateIpAddressesOutput)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // AllocIPAddresses indicates an expected call of AllocIPAddresses
-func (mr *MockAPIsMockRecorder) AllocIPAddresses(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockAPIsMockRecorder) AllocIPAddresses(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllocIPAddresses", reflect.TypeOf((*MockAPIs)(nil).AllocIPAddresses), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllocIPAddresses", reflect.TypeOf((*MockAPIs)(nil).AllocIPAddresses), arg0, arg1, arg2)
+}
+
+// AllocIPAddressesByIP mocks base method
+func (m *MockAPIs) AllocIPAddressesByIP(arg0 context.Context, arg1 string, arg2 []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AllocIPAddressesByIP", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AllocIPAddressesByIP indicates an expected call of AllocIPAddressesByIP
+func (mr *MockAPIsMockRecorder) AllocIPAddressesByIP(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllocIPAddressesByIP", reflect.TypeOf((*MockAPIs)(nil).AllocIPAddressesByIP), arg0, arg1, arg2)
+}
+
+// AllocIPv4PrefixesFromReservations mocks base method
+func (m *MockAPIs) AllocIPv4PrefixesFromReservations(arg0 context.Context, arg1 string, arg2 int, arg3, arg4 string) (*ec2.AssignPrivateIpAddressesOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AllocIPv4PrefixesFromReservations", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(*ec2.AssignPrivateIpAddressesOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AllocIPv4PrefixesFromReservations indicates an expected call of AllocIPv4PrefixesFromReservations
+func (mr *MockAPIsMockRecorder) AllocIPv4PrefixesFromReservations(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllocIPv4PrefixesFromReservations", reflect.TypeOf((*MockAPIs)(nil).AllocIPv4PrefixesFromReservations), arg0, arg1, arg2, arg3, arg4)
 }
 
 // AllocIPv6Prefixes mocks base method
-func (m *MockAPIs) AllocIPv6Prefixes(arg0 string) ([]*string, error) {
+func (m *MockAPIs) AllocIPv6Prefixes(arg0 context.Context, arg1 string) ([]*string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AllocIPv6Prefixes", arg0)
+	ret := m.ctrl.Call(m, "AllocIPv6Prefixes", arg0, arg1)
 	ret0, _ := ret[0].([]*string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // AllocIPv6Prefixes indicates an expected call of AllocIPv6Prefixes
-func (mr *MockAPIsMockRecorder) AllocIPv6Prefixes(arg0 interface{}) *gomock.Call {
+func (mr *MockAPIsMockRecorder) AllocIPv6Prefixes(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllocIPv6Prefixes", reflect.TypeOf((*MockAPIs)(nil).AllocIPv6Prefixes), arg0, arg1)
+}
+
+// AllocSecondaryIPAddresses mocks base method
+func (m *MockAPIs) AllocSecondaryIPAddresses(arg0 context.Context, arg1 string, arg2 int) (*ec2.AssignPrivateIpAddressesOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AllocSecondaryIPAddresses", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*ec2.AssignPrivateIpAddressesOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AllocSecondaryIPAddresses indicates an expected call of AllocSecondaryIPAddresses
+func (mr *MockAPIsMockRecorder) AllocSecondaryIPAddresses(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllocIPv6Prefixes", reflect.TypeOf((*MockAPIs)(nil).AllocIPv6Prefixes), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllocSecondaryIPAddresses", reflect.TypeOf((*MockAPIs)(nil).AllocSecondaryIPAddresses), arg0, arg1, arg2)
+}
+
+// CheckEC2Permissions mocks base method
+func (m *MockAPIs) CheckEC2Permissions(arg0 bool) map[string]error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckEC2Permissions", arg0)
+	ret0, _ := ret[0].(map[string]error)
+	return ret0
+}
+
+// CheckEC2Permissions indicates an expected call of CheckEC2Permissions
+func (mr *MockAPIsMockRecorder) CheckEC2Permissions(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckEC2Permissions", reflect.TypeOf((*MockAPIs)(nil).CheckEC2Permissions), arg0)
+}
+
+// CheckSubnetPlacement mocks base method
+func (m *MockAPIs) CheckSubnetPlacement(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckSubnetPlacement", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckSubnetPlacement indicates an expected call of CheckSubnetPlacement
+func (mr *MockAPIsMockRecorder) CheckSubnetPlacement(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckSubnetPlacement", reflect.TypeOf((*MockAPIs)(nil).CheckSubnetPlacement), arg0, arg1)
 }
 
 // DeallocIPAddresses mocks base method
-func (m *MockAPIs) DeallocIPAddresses(arg0 string, arg1 []string) error {
+func (m *MockAPIs) DeallocIPAddresses(arg0 context.Context, arg1 string, arg2 []string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeallocIPAddresses", arg0, arg1)
+	ret := m.ctrl.Call(m, "DeallocIPAddresses", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeallocIPAddresses indicates an expected call of DeallocIPAddresses
-func (mr *MockAPIsMockRecorder) DeallocIPAddresses(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockAPIsMockRecorder) DeallocIPAddresses(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeallocIPAddresses", reflect.TypeOf((*MockAPIs)(nil).DeallocIPAddresses), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeallocIPAddresses", reflect.TypeOf((*MockAPIs)(nil).DeallocIPAddresses), arg0, arg1, arg2)
 }
 
 // DeallocPrefixAddresses mocks base method
-func (m *MockAPIs) DeallocPrefixAddresses(arg0 string, arg1 []string) error {
+func (m *MockAPIs) DeallocPrefixAddresses(arg0 context.Context, arg1 string, arg2 []string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeallocPrefixAddresses", arg0, arg1)
+	ret := m.ctrl.Call(m, "DeallocPrefixAddresses", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeallocPrefixAddresses indicates an expected call of DeallocPrefixAddresses
-func (mr *MockAPIsMockRecorder) DeallocPrefixAddresses(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockAPIsMockRecorder) DeallocPrefixAddresses(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeallocPrefixAddresses", reflect.TypeOf((*MockAPIs)(nil).DeallocPrefixAddresses), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeallocPrefixAddresses", reflect.TypeOf((*MockAPIs)(nil).DeallocPrefixAddresses), arg0, arg1, arg2)
 }
 
 // DescribeAllENIs mocks base method
-func (m *MockAPIs) DescribeAllENIs() (awsutils.DescribeAllENIsResult, error) {
+func (m *MockAPIs) DescribeAllENIs(arg0 context.Context) (awsutils.DescribeAllENIsResult, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DescribeAllENIs")
+	ret := m.ctrl.Call(m, "DescribeAllENIs", arg0)
 	ret0, _ := ret[0].(awsutils.DescribeAllENIsResult)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // DescribeAllENIs indicates an expected call of DescribeAllENIs
-func (mr *MockAPIsMockRecorder) DescribeAllENIs() *gomock.Call {
+func (mr *MockAPIsMockRecorder) DescribeAllENIs(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeAllENIs", reflect.TypeOf((*MockAPIs)(nil).DescribeAllENIs))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeAllENIs", reflect.TypeOf((*MockAPIs)(nil).DescribeAllENIs), arg0)
 }
 
 // FetchInstanceTypeLimits mocks base method
@@ -167,17 +255,17 @@ func (mr *MockAPIsMockRecorder) FetchInstanceTypeLimits() *gomock.Call {
 }
 
 // FreeENI mocks base method
-func (m *MockAPIs) FreeENI(arg0 string) error {
+func (m *MockAPIs) FreeENI(arg0 context.Context, arg1 string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FreeENI", arg0)
+	ret := m.ctrl.Call(m, "FreeENI", arg0, arg1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // FreeENI indicates an expected call of FreeENI
-func (mr *MockAPIsMockRecorder) FreeENI(arg0 interface{}) *gomock.Call {
+func (mr *MockAPIsMockRecorder) FreeENI(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FreeENI", reflect.TypeOf((*MockAPIs)(nil).FreeENI), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FreeENI", reflect.TypeOf((*MockAPIs)(nil).FreeENI), arg0, arg1)
 }
 
 // GetAttachedENIs mocks base method
@@ -195,6 +283,34 @@ func (mr *MockAPIsMockRecorder) GetAttachedENIs() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAttachedENIs", reflect.TypeOf((*MockAPIs)(nil).GetAttachedENIs))
 }
 
+// GetEC2ReachabilityError mocks base method
+func (m *MockAPIs) GetEC2ReachabilityError() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEC2ReachabilityError")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GetEC2ReachabilityError indicates an expected call of GetEC2ReachabilityError
+func (mr *MockAPIsMockRecorder) GetEC2ReachabilityError() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEC2ReachabilityError", reflect.TypeOf((*MockAPIs)(nil).GetEC2ReachabilityError))
+}
+
+// GetEFAENIs mocks base method
+func (m *MockAPIs) GetEFAENIs() []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEFAENIs")
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// GetEFAENIs indicates an expected call of GetEFAENIs
+func (mr *MockAPIsMockRecorder) GetEFAENIs() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEFAENIs", reflect.TypeOf((*MockAPIs)(nil).GetEFAENIs))
+}
+
 // GetENIIPv4Limit mocks base method
 func (m *MockAPIs) GetENIIPv4Limit() int {
 	m.ctrl.T.Helper()
@@ -223,49 +339,64 @@ func (mr *MockAPIsMockRecorder) GetENILimit() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetENILimit", reflect.TypeOf((*MockAPIs)(nil).GetENILimit))
 }
 
+// GetENISecurityGroups mocks base method
+func (m *MockAPIs) GetENISecurityGroups(arg0 context.Context, arg1 string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetENISecurityGroups", arg0, arg1)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetENISecurityGroups indicates an expected call of GetENISecurityGroups
+func (mr *MockAPIsMockRecorder) GetENISecurityGroups(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetENISecurityGroups", reflect.TypeOf((*MockAPIs)(nil).GetENISecurityGroups), arg0, arg1)
+}
+
 // GetIPv4PrefixesFromEC2 mocks base method
-func (m *MockAPIs) GetIPv4PrefixesFromEC2(arg0 string) ([]*ec2.Ipv4PrefixSpecification, error) {
+func (m *MockAPIs) GetIPv4PrefixesFromEC2(arg0 context.Context, arg1 string) ([]*ec2.Ipv4PrefixSpecification, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetIPv4PrefixesFromEC2", arg0)
+	ret := m.ctrl.Call(m, "GetIPv4PrefixesFromEC2", arg0, arg1)
 	ret0, _ := ret[0].([]*ec2.Ipv4PrefixSpecification)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetIPv4PrefixesFromEC2 indicates an expected call of GetIPv4PrefixesFromEC2
-func (mr *MockAPIsMockRecorder) GetIPv4PrefixesFromEC2(arg0 interface{}) *gomock.Call {
+func (mr *MockAPIsMockRecorder) GetIPv4PrefixesFromEC2(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIPv4PrefixesFromEC2", reflect.TypeOf((*MockAPIs)(nil).GetIPv4PrefixesFromEC2), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIPv4PrefixesFromEC2", reflect.TypeOf((*MockAPIs)(nil).GetIPv4PrefixesFromEC2), arg0, arg1)
 }
 
 // GetIPv4sFromEC2 mocks base method
-func (m *MockAPIs) GetIPv4sFromEC2(arg0 string) ([]*ec2.NetworkInterfacePrivateIpAddress, error) {
+func (m *MockAPIs) GetIPv4sFromEC2(arg0 context.Context, arg1 string) ([]*ec2.NetworkInterfacePrivateIpAddress, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetIPv4sFromEC2", arg0)
+	ret := m.ctrl.Call(m, "GetIPv4sFromEC2", arg0, arg1)
 	ret0, _ := ret[0].([]*ec2.NetworkInterfacePrivateIpAddress)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetIPv4sFromEC2 indicates an expected call of GetIPv4sFromEC2
-func (mr *MockAPIsMockRecorder) GetIPv4sFromEC2(arg0 interface{}) *gomock.Call {
+func (mr *MockAPIsMockRecorder) GetIPv4sFromEC2(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIPv4sFromEC2", reflect.TypeOf((*MockAPIs)(nil).GetIPv4sFromEC2), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIPv4sFromEC2", reflect.TypeOf((*MockAPIs)(nil).GetIPv4sFromEC2), arg0, arg1)
 }
 
 // GetIPv6PrefixesFromEC2 mocks base method
-func (m *MockAPIs) GetIPv6PrefixesFromEC2(arg0 string) ([]*ec2.Ipv6PrefixSpecification, error) {
+func (m *MockAPIs) GetIPv6PrefixesFromEC2(arg0 context.Context, arg1 string) ([]*ec2.Ipv6PrefixSpecification, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetIPv6PrefixesFromEC2", arg0)
+	ret := m.ctrl.Call(m, "GetIPv6PrefixesFromEC2", arg0, arg1)
 	ret0, _ := ret[0].([]*ec2.Ipv6PrefixSpecification)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetIPv6PrefixesFromEC2 indicates an expected call of GetIPv6PrefixesFromEC2
-func (mr *MockAPIsMockRecorder) GetIPv6PrefixesFromEC2(arg0 interface{}) *gomock.Call {
+func (mr *MockAPIsMockRecorder) GetIPv6PrefixesFromEC2(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIPv6PrefixesFromEC2", reflect.TypeOf((*MockAPIs)(nil).GetIPv6PrefixesFromEC2), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIPv6PrefixesFromEC2", reflect.TypeOf((*MockAPIs)(nil).GetIPv6PrefixesFromEC2), arg0, arg1)
 }
 
 // GetInstanceHypervisorFamily mocks base method
@@ -296,6 +427,21 @@ func (mr *MockAPIsMockRecorder) GetInstanceID() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInstanceID", reflect.TypeOf((*MockAPIs)(nil).GetInstanceID))
 }
 
+// GetInstancePlacement mocks base method
+func (m *MockAPIs) GetInstancePlacement(arg0 context.Context) (awsutils.Placement, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetInstancePlacement", arg0)
+	ret0, _ := ret[0].(awsutils.Placement)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetInstancePlacement indicates an expected call of GetInstancePlacement
+func (mr *MockAPIsMockRecorder) GetInstancePlacement(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInstancePlacement", reflect.TypeOf((*MockAPIs)(nil).GetInstancePlacement), arg0)
+}
+
 // GetInstanceType mocks base method
 func (m *MockAPIs) GetInstanceType() string {
 	m.ctrl.T.Helper()
@@ -310,6 +456,35 @@ func (mr *MockAPIsMockRecorder) GetInstanceType() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInstanceType", reflect.TypeOf((*MockAPIs)(nil).GetInstanceType))
 }
 
+// GetInstanceTypeLimits mocks base method
+func (m *MockAPIs) GetInstanceTypeLimits(arg0 context.Context, arg1 string) (awsutils.InstanceTypeLimits, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetInstanceTypeLimits", arg0, arg1)
+	ret0, _ := ret[0].(awsutils.InstanceTypeLimits)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetInstanceTypeLimits indicates an expected call of GetInstanceTypeLimits
+func (mr *MockAPIsMockRecorder) GetInstanceTypeLimits(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInstanceTypeLimits", reflect.TypeOf((*MockAPIs)(nil).GetInstanceTypeLimits), arg0, arg1)
+}
+
+// GetLastEC2Throttle mocks base method
+func (m *MockAPIs) GetLastEC2Throttle() time.Time {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLastEC2Throttle")
+	ret0, _ := ret[0].(time.Time)
+	return ret0
+}
+
+// GetLastEC2Throttle indicates an expected call of GetLastEC2Throttle
+func (mr *MockAPIsMockRecorder) GetLastEC2Throttle() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLastEC2Throttle", reflect.TypeOf((*MockAPIs)(nil).GetLastEC2Throttle))
+}
+
 // GetLocalIPv4 mocks base method
 func (m *MockAPIs) GetLocalIPv4() net.IP {
 	m.ctrl.T.Helper()
@@ -324,6 +499,21 @@ func (mr *MockAPIsMockRecorder) GetLocalIPv4() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLocalIPv4", reflect.TypeOf((*MockAPIs)(nil).GetLocalIPv4))
 }
 
+// GetMissingSecurityGroups mocks base method
+func (m *MockAPIs) GetMissingSecurityGroups(arg0 []string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMissingSecurityGroups", arg0)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMissingSecurityGroups indicates an expected call of GetMissingSecurityGroups
+func (mr *MockAPIsMockRecorder) GetMissingSecurityGroups(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMissingSecurityGroups", reflect.TypeOf((*MockAPIs)(nil).GetMissingSecurityGroups), arg0)
+}
+
 // GetPrimaryENI mocks base method
 func (m *MockAPIs) GetPrimaryENI() string {
 	m.ctrl.T.Helper()
@@ -352,6 +542,94 @@ func (mr *MockAPIsMockRecorder) GetPrimaryENImac() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPrimaryENImac", reflect.TypeOf((*MockAPIs)(nil).GetPrimaryENImac))
 }
 
+// GetRegion mocks base method
+func (m *MockAPIs) GetRegion() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRegion")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GetRegion indicates an expected call of GetRegion
+func (mr *MockAPIsMockRecorder) GetRegion() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRegion", reflect.TypeOf((*MockAPIs)(nil).GetRegion))
+}
+
+// GetSecurityGroups mocks base method
+func (m *MockAPIs) GetSecurityGroups() []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSecurityGroups")
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// GetSecurityGroups indicates an expected call of GetSecurityGroups
+func (mr *MockAPIsMockRecorder) GetSecurityGroups() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSecurityGroups", reflect.TypeOf((*MockAPIs)(nil).GetSecurityGroups))
+}
+
+// GetSubnetAvailableIPCount mocks base method
+func (m *MockAPIs) GetSubnetAvailableIPCount(arg0 string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSubnetAvailableIPCount", arg0)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSubnetAvailableIPCount indicates an expected call of GetSubnetAvailableIPCount
+func (mr *MockAPIsMockRecorder) GetSubnetAvailableIPCount(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSubnetAvailableIPCount", reflect.TypeOf((*MockAPIs)(nil).GetSubnetAvailableIPCount), arg0)
+}
+
+// GetSubnetPrefixAvailability mocks base method
+func (m *MockAPIs) GetSubnetPrefixAvailability(arg0 context.Context, arg1 string) (*awsutils.SubnetPrefixAvailability, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSubnetPrefixAvailability", arg0, arg1)
+	ret0, _ := ret[0].(*awsutils.SubnetPrefixAvailability)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSubnetPrefixAvailability indicates an expected call of GetSubnetPrefixAvailability
+func (mr *MockAPIsMockRecorder) GetSubnetPrefixAvailability(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSubnetPrefixAvailability", reflect.TypeOf((*MockAPIs)(nil).GetSubnetPrefixAvailability), arg0, arg1)
+}
+
+// GetTargetLifecycleState mocks base method
+func (m *MockAPIs) GetTargetLifecycleState(arg0 context.Context) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTargetLifecycleState", arg0)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTargetLifecycleState indicates an expected call of GetTargetLifecycleState
+func (mr *MockAPIsMockRecorder) GetTargetLifecycleState(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTargetLifecycleState", reflect.TypeOf((*MockAPIs)(nil).GetTargetLifecycleState), arg0)
+}
+
+// GetVPCDNSConfig mocks base method
+func (m *MockAPIs) GetVPCDNSConfig() (awsutils.VPCDNSConfig, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVPCDNSConfig")
+	ret0, _ := ret[0].(awsutils.VPCDNSConfig)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVPCDNSConfig indicates an expected call of GetVPCDNSConfig
+func (mr *MockAPIsMockRecorder) GetVPCDNSConfig() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVPCDNSConfig", reflect.TypeOf((*MockAPIs)(nil).GetVPCDNSConfig))
+}
+
 // GetVPCIPv4CIDRs mocks base method
 func (m *MockAPIs) GetVPCIPv4CIDRs() ([]string, error) {
 	m.ctrl.T.Helper()
@@ -382,6 +660,18 @@ func (mr *MockAPIsMockRecorder) GetVPCIPv6CIDRs() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVPCIPv6CIDRs", reflect.TypeOf((*MockAPIs)(nil).GetVPCIPv6CIDRs))
 }
 
+// InitCachedMultiCardENIs mocks base method
+func (m *MockAPIs) InitCachedMultiCardENIs(arg0 bool) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "InitCachedMultiCardENIs", arg0)
+}
+
+// InitCachedMultiCardENIs indicates an expected call of InitCachedMultiCardENIs
+func (mr *MockAPIsMockRecorder) InitCachedMultiCardENIs(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InitCachedMultiCardENIs", reflect.TypeOf((*MockAPIs)(nil).InitCachedMultiCardENIs), arg0)
+}
+
 // InitCachedPrefixDelegation mocks base method
 func (m *MockAPIs) InitCachedPrefixDelegation(arg0 bool) {
 	m.ctrl.T.Helper()
@@ -408,6 +698,20 @@ func (mr *MockAPIsMockRecorder) IsCNIUnmanagedENI(arg0 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsCNIUnmanagedENI", reflect.TypeOf((*MockAPIs)(nil).IsCNIUnmanagedENI), arg0)
 }
 
+// IsEFAENI mocks base method
+func (m *MockAPIs) IsEFAENI(arg0 string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsEFAENI", arg0)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsEFAENI indicates an expected call of IsEFAENI
+func (mr *MockAPIsMockRecorder) IsEFAENI(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsEFAENI", reflect.TypeOf((*MockAPIs)(nil).IsEFAENI), arg0)
+}
+
 // IsPrefixDelegationSupported mocks base method
 func (m *MockAPIs) IsPrefixDelegationSupported() bool {
 	m.ctrl.T.Helper()
@@ -451,17 +755,17 @@ func (mr *MockAPIsMockRecorder) IsUnmanagedENI(arg0 interface{}) *gomock.Call {
 }
 
 // RefreshSGIDs mocks base method
-func (m *MockAPIs) RefreshSGIDs(arg0 string) error {
+func (m *MockAPIs) RefreshSGIDs(arg0 context.Context, arg1 string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "RefreshSGIDs", arg0)
+	ret := m.ctrl.Call(m, "RefreshSGIDs", arg0, arg1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // RefreshSGIDs indicates an expected call of RefreshSGIDs
-func (mr *MockAPIsMockRecorder) RefreshSGIDs(arg0 interface{}) *gomock.Call {
+func (mr *MockAPIsMockRecorder) RefreshSGIDs(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshSGIDs", reflect.TypeOf((*MockAPIs)(nil).RefreshSGIDs), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshSGIDs", reflect.TypeOf((*MockAPIs)(nil).RefreshSGIDs), arg0, arg1)
 }
 
 // SetCNIUnmanagedENIs mocks base method
@@ -478,6 +782,20 @@ func (mr *MockAPIsMockRecorder) SetCNIUnmanagedENIs(arg0 interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetCNIUnmanagedENIs", reflect.TypeOf((*MockAPIs)(nil).SetCNIUnmanagedENIs), arg0)
 }
 
+// SetENISecurityGroups mocks base method
+func (m *MockAPIs) SetENISecurityGroups(arg0 context.Context, arg1 string, arg2 []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetENISecurityGroups", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetENISecurityGroups indicates an expected call of SetENISecurityGroups
+func (mr *MockAPIsMockRecorder) SetENISecurityGroups(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetENISecurityGroups", reflect.TypeOf((*MockAPIs)(nil).SetENISecurityGroups), arg0, arg1, arg2)
+}
+
 // SetUnmanagedENIs mocks base method
 func (m *MockAPIs) SetUnmanagedENIs(arg0 []string) {
 	m.ctrl.T.Helper()
@@ -491,30 +809,30 @@ func (mr *MockAPIsMockRecorder) SetUnmanagedENIs(arg0 interface{}) *gomock.Call
 }
 
 // TagENI mocks base method
-func (m *MockAPIs) TagENI(arg0 string, arg1 map[string]string) error {
+func (m *MockAPIs) TagENI(arg0 context.Context, arg1 string, arg2 map[string]string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "TagENI", arg0, arg1)
+	ret := m.ctrl.Call(m, "TagENI", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // TagENI indicates an expected call of TagENI
-func (mr *MockAPIsMockRecorder) TagENI(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockAPIsMockRecorder) TagENI(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TagENI", reflect.TypeOf((*MockAPIs)(nil).TagENI), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TagENI", reflect.TypeOf((*MockAPIs)(nil).TagENI), arg0, arg1, arg2)
 }
 
 // WaitForENIAndIPsAttached mocks base method
-func (m *MockAPIs) WaitForENIAndIPsAttached(arg0 string, arg1 int) (awsutils.ENIMetadata, error) {
+func (m *MockAPIs) WaitForENIAndIPsAttached(arg0 context.Context, arg1 string, arg2 int) (awsutils.ENIMetadata, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "WaitForENIAndIPsAttached", arg0, arg1)
+	ret := m.ctrl.Call(m, "WaitForENIAndIPsAttached", arg0, arg1, arg2)
 	ret0, _ := ret[0].(awsutils.ENIMetadata)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // WaitForENIAndIPsAttached indicates an expected call of WaitForENIAndIPsAttached
-func (mr *MockAPIsMockRecorder) WaitForENIAndIPsAttached(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockAPIsMockRecorder) WaitForENIAndIPsAttached(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WaitForENIAndIPsAttached", reflect.TypeOf((*MockAPIs)(nil).WaitForENIAndIPsAttached), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WaitForENIAndIPsAttached", reflect.TypeOf((*MockAPIs)(nil).WaitForENIAndIPsAttached), arg0, arg1, arg2)
 }
@@ -0,0 +1,190 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package awsutils
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// maxPendingENIs is how many ENIs whose attachment failed are kept to be attached by a later AllocENI. The others
+	// are deleted right away.
+	maxPendingENIs = 2
+	// maxPendingENIAttachAttempts is how many times the attachment of a pending ENI is tried before it is deleted
+	maxPendingENIAttachAttempts = 3
+)
+
+var pendingENIOperations = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "awscni_pending_eni_count",
+		Help: "The number of ENIs whose attachment failed after they were created, by what became of them",
+	},
+	[]string{"outcome"},
+)
+
+// pendingENI is an ENI created by AllocENI whose attachment failed, e.g. on a throttled or timed out
+// AttachNetworkInterface call. It is attached by the next AllocENI for the same subnet and security groups rather than
+// deleted and created again.
+type pendingENI struct {
+	id       string
+	key      string
+	attempts int
+}
+
+// pendingENIKey returns what a pending ENI has to match to be reused
+func pendingENIKey(useCustomCfg bool, sg []*string, subnet string) string {
+	sgIDs := aws.StringValueSlice(sg)
+	sort.Strings(sgIDs)
+	return fmt.Sprintf("%t/%s/%s", useCustomCfg, subnet, strings.Join(sgIDs, ","))
+}
+
+// isPendingAttachError returns whether an attachment that failed with err may succeed when tried again, as when the
+// call was throttled or timed out, or the new ENI was not yet visible to EC2. An ENI whose attachment failed because the
+// instance has no room for it is deleted instead.
+func isPendingAttachError(err error) bool {
+	cause := errors.Cause(err)
+	if cause == context.DeadlineExceeded {
+		return true
+	}
+	aerr, ok := cause.(awserr.Error)
+	if !ok {
+		return false
+	}
+	switch aerr.Code() {
+	case "IncorrectState", "InvalidNetworkInterfaceID.NotFound":
+		return true
+	}
+	return request.IsErrorRetryable(aerr) || request.IsErrorThrottle(aerr)
+}
+
+// keepPendingENI keeps an ENI whose attachment failed with err for the next AllocENI, or deletes it when the attachment
+// is not worth trying again or enough are kept
+func (cache *EC2InstanceMetadataCache) keepPendingENI(ctx context.Context, eniID string, key string, err error) {
+	cache.pendingENIsLock.Lock()
+	if isPendingAttachError(err) && len(cache.pendingENIs) < maxPendingENIs {
+		cache.pendingENIs = append(cache.pendingENIs, pendingENI{id: eniID, key: key, attempts: 1})
+		cache.pendingENIsLock.Unlock()
+		log.Infof("Keeping ENI %s, whose attachment failed, to attach it again", eniID)
+		pendingENIOperations.WithLabelValues("kept").Inc()
+		return
+	}
+	cache.pendingENIsLock.Unlock()
+	cache.deletePendingENI(ctx, eniID)
+}
+
+// deletePendingENI deletes an ENI that was never attached
+func (cache *EC2InstanceMetadataCache) deletePendingENI(ctx context.Context, eniID string) {
+	if err := cache.deleteENI(ctx, eniID, maxENIBackoffDelay); err != nil {
+		awsUtilsErrInc("AllocENIDeleteErr", err)
+		log.Errorf("Failed to delete newly created untagged ENI %s! %v", eniID, err)
+		return
+	}
+	pendingENIOperations.WithLabelValues("deleted").Inc()
+}
+
+// takePendingENI removes the first pending ENI that matches key from the list
+func (cache *EC2InstanceMetadataCache) takePendingENI(key string) (pendingENI, bool) {
+	cache.pendingENIsLock.Lock()
+	defer cache.pendingENIsLock.Unlock()
+	for i, eni := range cache.pendingENIs {
+		if eni.key == key {
+			cache.pendingENIs = append(cache.pendingENIs[:i], cache.pendingENIs[i+1:]...)
+			return eni, true
+		}
+	}
+	return pendingENI{}, false
+}
+
+// attachPendingENI attaches an ENI kept by an earlier AllocENI for the same subnet and security groups, and returns
+// its ID and attachment ID, or "" if there is none to attach. An ENI found attached to the instance, because the
+// attachment went through although the call failed, is returned with its attachment. An ENI whose attachment fails
+// again is kept for the next AllocENI until it ran out of attempts, and the error is returned rather than creating
+// another ENI that is as likely to fail.
+func (cache *EC2InstanceMetadataCache) attachPendingENI(ctx context.Context, key string) (string, string, error) {
+	for {
+		pending, ok := cache.takePendingENI(key)
+		if !ok {
+			return "", "", nil
+		}
+		eni, err := cache.describeENI(ctx, pending.id)
+		if err == ErrENINotFound {
+			log.Infof("Pending ENI %s no longer exists", pending.id)
+			pendingENIOperations.WithLabelValues("gone").Inc()
+			continue
+		}
+		if err != nil {
+			cache.requeuePendingENI(pending)
+			return "", "", errors.Wrapf(err, "failed to describe pending ENI %s", pending.id)
+		}
+
+		if eni.Attachment != nil {
+			if aws.StringValue(eni.Attachment.InstanceId) != cache.instanceID {
+				log.Warnf("Pending ENI %s is attached to instance %s, forgetting it", pending.id,
+					aws.StringValue(eni.Attachment.InstanceId))
+				pendingENIOperations.WithLabelValues("gone").Inc()
+				continue
+			}
+			log.Infof("Pending ENI %s is attached to the instance already, using it", pending.id)
+			pendingENIOperations.WithLabelValues("adopted").Inc()
+			return pending.id, aws.StringValue(eni.Attachment.AttachmentId), nil
+		}
+		if aws.StringValue(eni.Status) != ec2.NetworkInterfaceStatusAvailable {
+			cache.requeuePendingENI(pending)
+			return "", "", errors.Errorf("pending ENI %s is %s", pending.id, aws.StringValue(eni.Status))
+		}
+
+		attachmentID, err := cache.attachENI(ctx, pending.id)
+		if err == nil {
+			log.Infof("Attached pending ENI %s", pending.id)
+			pendingENIOperations.WithLabelValues("attached").Inc()
+			return pending.id, attachmentID, nil
+		}
+		pending.attempts++
+		if !isPendingAttachError(err) || pending.attempts >= maxPendingENIAttachAttempts {
+			log.Warnf("Deleting pending ENI %s after %d failed attachments", pending.id, pending.attempts)
+			cache.deletePendingENI(ctx, pending.id)
+		} else {
+			cache.requeuePendingENI(pending)
+		}
+		return "", "", err
+	}
+}
+
+// requeuePendingENI puts back a pending ENI taken by attachPendingENI
+func (cache *EC2InstanceMetadataCache) requeuePendingENI(eni pendingENI) {
+	cache.pendingENIsLock.Lock()
+	defer cache.pendingENIsLock.Unlock()
+	cache.pendingENIs = append(cache.pendingENIs, eni)
+}
+
+// getPendingENIs returns the IDs of the pending ENIs
+func (cache *EC2InstanceMetadataCache) getPendingENIs() []string {
+	cache.pendingENIsLock.Lock()
+	defer cache.pendingENIsLock.Unlock()
+	ids := make([]string, 0, len(cache.pendingENIs))
+	for _, eni := range cache.pendingENIs {
+		ids = append(ids, eni.id)
+	}
+	return ids
+}
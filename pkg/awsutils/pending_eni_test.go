@@ -0,0 +1,171 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package awsutils
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	mock_ec2wrapper "github.com/aws/amazon-vpc-cni-k8s/pkg/ec2wrapper/mocks"
+)
+
+// expectFreeDevice makes EC2 describe the instance with device number 1 free
+func expectFreeDevice(mockEC2 *mock_ec2wrapper.MockEC2) {
+	deviceNum := int64(0)
+	result := &ec2.DescribeInstancesOutput{
+		Reservations: []*ec2.Reservation{{Instances: []*ec2.Instance{{NetworkInterfaces: []*ec2.InstanceNetworkInterface{
+			{Attachment: &ec2.InstanceNetworkInterfaceAttachment{DeviceIndex: &deviceNum}},
+		}}}}}}
+	mockEC2.EXPECT().DescribeInstancesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(result, nil)
+}
+
+func TestAllocENIAttachesPendingENI(t *testing.T) {
+	ctrl, mockEC2 := setup(t)
+	defer ctrl.Finish()
+
+	ins := &EC2InstanceMetadataCache{
+		ec2SVC:     mockEC2,
+		imds:       TypedIMDS{testMetadata(nil)},
+		instanceID: instanceID,
+	}
+
+	// The attachment is throttled, so the new ENI is kept rather than deleted
+	eni := ec2.CreateNetworkInterfaceOutput{NetworkInterface: &ec2.NetworkInterface{NetworkInterfaceId: aws.String(eni2ID)}}
+	mockEC2.EXPECT().CreateNetworkInterfaceWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(&eni, nil)
+	expectFreeDevice(mockEC2)
+	mockEC2.EXPECT().AttachNetworkInterfaceWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil,
+		awserr.New("Throttling", "Rate exceeded", nil))
+
+	_, err := ins.AllocENI(context.Background(), false, nil, "subnet-1")
+	assert.Error(t, err)
+	assert.Equal(t, []string{eni2ID}, ins.getPendingENIs())
+
+	// The next AllocENI attaches it instead of creating another one
+	mockEC2.EXPECT().DescribeNetworkInterfacesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(
+		&ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: []*ec2.NetworkInterface{{
+			NetworkInterfaceId: aws.String(eni2ID),
+			Status:             aws.String(ec2.NetworkInterfaceStatusAvailable),
+		}}}, nil)
+	expectFreeDevice(mockEC2)
+	mockEC2.EXPECT().AttachNetworkInterfaceWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(
+		&ec2.AttachNetworkInterfaceOutput{AttachmentId: aws.String(eniAttachID)}, nil)
+	mockEC2.EXPECT().ModifyNetworkInterfaceAttributeWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil)
+
+	allocated, err := ins.AllocENI(context.Background(), false, nil, "subnet-1")
+	assert.NoError(t, err)
+	assert.Equal(t, eni2ID, allocated)
+	assert.Empty(t, ins.getPendingENIs())
+}
+
+func TestAllocENIAdoptsAttachedPendingENI(t *testing.T) {
+	ctrl, mockEC2 := setup(t)
+	defer ctrl.Finish()
+
+	ins := &EC2InstanceMetadataCache{
+		ec2SVC:      mockEC2,
+		instanceID:  instanceID,
+		pendingENIs: []pendingENI{{id: eni2ID, key: pendingENIKey(false, nil, "subnet-1"), attempts: 1}},
+	}
+
+	// The failed call attached the ENI after all
+	mockEC2.EXPECT().DescribeNetworkInterfacesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(
+		&ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: []*ec2.NetworkInterface{{
+			NetworkInterfaceId: aws.String(eni2ID),
+			Status:             aws.String(ec2.NetworkInterfaceStatusInUse),
+			Attachment: &ec2.NetworkInterfaceAttachment{
+				AttachmentId: aws.String(eniAttachID),
+				InstanceId:   aws.String(instanceID),
+			},
+		}}}, nil)
+	mockEC2.EXPECT().ModifyNetworkInterfaceAttributeWithContext(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, input *ec2.ModifyNetworkInterfaceAttributeInput, _ ...interface{}) (*ec2.ModifyNetworkInterfaceAttributeOutput, error) {
+			assert.Equal(t, eniAttachID, aws.StringValue(input.Attachment.AttachmentId))
+			return nil, nil
+		})
+
+	allocated, err := ins.AllocENI(context.Background(), false, nil, "subnet-1")
+	assert.NoError(t, err)
+	assert.Equal(t, eni2ID, allocated)
+	assert.Empty(t, ins.getPendingENIs())
+}
+
+func TestAttachPendingENI(t *testing.T) {
+	key := pendingENIKey(false, nil, "subnet-1")
+	for _, tc := range []struct {
+		name        string
+		attempts    int
+		describeErr error
+		attachErr   error
+		wantPending []string
+		wantDeleted bool
+		wantErr     bool
+	}{
+		{name: "gone", describeErr: awserr.New("InvalidNetworkInterfaceID.NotFound", "", nil)},
+		{name: "throttled again", attempts: 1, attachErr: awserr.New("Throttling", "", nil), wantPending: []string{eni2ID}, wantErr: true},
+		{name: "out of attempts", attempts: maxPendingENIAttachAttempts - 1, attachErr: awserr.New("Throttling", "", nil), wantDeleted: true, wantErr: true},
+		{name: "not worth retrying", attempts: 1, attachErr: errors.New("AttachmentLimitExceeded"), wantDeleted: true, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl, mockEC2 := setup(t)
+			defer ctrl.Finish()
+
+			ins := &EC2InstanceMetadataCache{
+				ec2SVC:      mockEC2,
+				instanceID:  instanceID,
+				pendingENIs: []pendingENI{{id: eni2ID, key: key, attempts: tc.attempts}},
+			}
+			mockEC2.EXPECT().DescribeNetworkInterfacesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(
+				&ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: []*ec2.NetworkInterface{{
+					NetworkInterfaceId: aws.String(eni2ID),
+					Status:             aws.String(ec2.NetworkInterfaceStatusAvailable),
+				}}}, tc.describeErr)
+			if tc.describeErr == nil {
+				expectFreeDevice(mockEC2)
+				mockEC2.EXPECT().AttachNetworkInterfaceWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, tc.attachErr)
+			}
+			if tc.wantDeleted {
+				mockEC2.EXPECT().DeleteNetworkInterfaceWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil)
+			}
+
+			eniID, _, err := ins.attachPendingENI(context.Background(), key)
+			assert.Equal(t, tc.wantErr, err != nil)
+			assert.Empty(t, eniID)
+			assert.ElementsMatch(t, tc.wantPending, ins.getPendingENIs())
+		})
+	}
+}
+
+func TestKeepPendingENI(t *testing.T) {
+	ctrl, mockEC2 := setup(t)
+	defer ctrl.Finish()
+
+	ins := &EC2InstanceMetadataCache{ec2SVC: mockEC2}
+	throttled := awserr.New("Throttling", "", nil)
+	for i := 0; i < maxPendingENIs; i++ {
+		ins.keepPendingENI(context.Background(), eniID, "key", throttled)
+	}
+	assert.Len(t, ins.getPendingENIs(), maxPendingENIs)
+
+	// Once enough ENIs are kept the others are deleted
+	mockEC2.EXPECT().DeleteNetworkInterfaceWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil)
+	ins.keepPendingENI(context.Background(), eni2ID, "key", throttled)
+	assert.NotContains(t, ins.getPendingENIs(), eni2ID)
+}
@@ -0,0 +1,141 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package awsutils
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+)
+
+// Placement is where an instance or a subnet is: an Availability Zone, a Local Zone, or an Outpost in one of them
+type Placement struct {
+	AvailabilityZone string
+	// LocalZone is true if AvailabilityZone is a Local Zone, like us-west-2-lax-1a
+	LocalZone bool
+	// OutpostArn is empty outside of an Outpost
+	OutpostArn string
+}
+
+func (p Placement) String() string {
+	switch {
+	case p.OutpostArn != "":
+		return fmt.Sprintf("Outpost %s in %s", p.OutpostArn, p.AvailabilityZone)
+	case p.LocalZone:
+		return fmt.Sprintf("Local Zone %s", p.AvailabilityZone)
+	}
+	return fmt.Sprintf("Availability Zone %s", p.AvailabilityZone)
+}
+
+// SubnetPlacementError is returned for a subnet that the ENIs of the instance can't be created in, since it isn't in
+// the zone or the Outpost of the instance
+type SubnetPlacementError struct {
+	SubnetID string
+	Subnet   Placement
+	Instance Placement
+}
+
+func (e *SubnetPlacementError) Error() string {
+	return fmt.Sprintf("subnet %s is in %s but the instance is in %s, ENIs of the instance can only be created in a subnet of its %s",
+		e.SubnetID, e.Subnet, e.Instance, placementKind(e.Instance))
+}
+
+func placementKind(p Placement) string {
+	switch {
+	case p.OutpostArn != "":
+		return "Outpost"
+	case p.LocalZone:
+		return "Local Zone"
+	}
+	return "Availability Zone"
+}
+
+// isLocalZone returns true if zone is a Local Zone of region: us-west-2-lax-1a is one, us-west-2a isn't
+func isLocalZone(zone, region string) bool {
+	return region != "" && strings.HasPrefix(zone, region+"-")
+}
+
+// getSubnetPlacement returns the placement of a subnet, which doesn't change, so it is only looked up once
+func (cache *EC2InstanceMetadataCache) getSubnetPlacement(ctx context.Context, subnetID string) (Placement, error) {
+	cache.placementLock.Lock()
+	placement, ok := cache.subnetPlacements[subnetID]
+	cache.placementLock.Unlock()
+	if ok {
+		return placement, nil
+	}
+
+	start := time.Now()
+	output, err := cache.ec2SVC.DescribeSubnetsWithContext(ctx, &ec2.DescribeSubnetsInput{
+		SubnetIds: []*string{aws.String(subnetID)}})
+	awsAPILatency.WithLabelValues("DescribeSubnets", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
+	if err != nil {
+		awsAPIErrInc("DescribeSubnets", err)
+		return Placement{}, errors.Wrapf(err, "failed to describe subnet %s", subnetID)
+	}
+	if len(output.Subnets) != 1 {
+		return Placement{}, errors.Errorf("subnet %s not found", subnetID)
+	}
+	zone := aws.StringValue(output.Subnets[0].AvailabilityZone)
+	placement = Placement{
+		AvailabilityZone: zone,
+		LocalZone:        isLocalZone(zone, cache.region),
+		OutpostArn:       aws.StringValue(output.Subnets[0].OutpostArn),
+	}
+
+	cache.placementLock.Lock()
+	defer cache.placementLock.Unlock()
+	if cache.subnetPlacements == nil {
+		cache.subnetPlacements = make(map[string]Placement)
+	}
+	cache.subnetPlacements[subnetID] = placement
+	return placement, nil
+}
+
+// GetInstancePlacement returns the placement of the instance. The zone comes from the instance metadata, and the
+// Outpost from the subnet of the primary ENI, since an instance on an Outpost can only have its ENIs in subnets of
+// that Outpost.
+func (cache *EC2InstanceMetadataCache) GetInstancePlacement(ctx context.Context) (Placement, error) {
+	primary, err := cache.getSubnetPlacement(ctx, cache.subnetID)
+	if err != nil {
+		return Placement{}, err
+	}
+	return Placement{
+		AvailabilityZone: cache.availabilityZone,
+		LocalZone:        isLocalZone(cache.availabilityZone, cache.region),
+		OutpostArn:       primary.OutpostArn,
+	}, nil
+}
+
+// CheckSubnetPlacement returns a *SubnetPlacementError if the ENIs of the instance can't be created in subnetID: the
+// subnet has to be in the zone of the instance, and on its Outpost if it runs on one. Other errors mean that the
+// placement couldn't be looked up.
+func (cache *EC2InstanceMetadataCache) CheckSubnetPlacement(ctx context.Context, subnetID string) error {
+	instance, err := cache.GetInstancePlacement(ctx)
+	if err != nil {
+		return err
+	}
+	subnet, err := cache.getSubnetPlacement(ctx, subnetID)
+	if err != nil {
+		return err
+	}
+	if subnet.AvailabilityZone != instance.AvailabilityZone || subnet.OutpostArn != instance.OutpostArn {
+		return &SubnetPlacementError{SubnetID: subnetID, Subnet: subnet, Instance: instance}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package awsutils
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	mock_ec2wrapper "github.com/aws/amazon-vpc-cni-k8s/pkg/ec2wrapper/mocks"
+)
+
+const outpostArn = "arn:aws:outposts:us-east-1:123456789012:outpost/op-0123456789abcdef0"
+
+func expectSubnet(mockEC2 *mock_ec2wrapper.MockEC2, subnetID, zone, outpostArn string) {
+	subnet := &ec2.Subnet{SubnetId: aws.String(subnetID), AvailabilityZone: aws.String(zone)}
+	if outpostArn != "" {
+		subnet.OutpostArn = aws.String(outpostArn)
+	}
+	mockEC2.EXPECT().DescribeSubnetsWithContext(gomock.Any(), &ec2.DescribeSubnetsInput{SubnetIds: []*string{aws.String(subnetID)}}).
+		Return(&ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{subnet}}, nil)
+}
+
+func TestIsLocalZone(t *testing.T) {
+	assert.False(t, isLocalZone("us-west-2a", "us-west-2"))
+	assert.True(t, isLocalZone("us-west-2-lax-1a", "us-west-2"))
+	assert.False(t, isLocalZone("us-west-2-lax-1a", ""))
+}
+
+func TestCheckSubnetPlacement(t *testing.T) {
+	ctrl, mockEC2 := setup(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	cache := &EC2InstanceMetadataCache{ec2SVC: mockEC2, subnetID: subnetID, availabilityZone: az, region: "us-east-1"}
+	expectSubnet(mockEC2, subnetID, az, "")
+	expectSubnet(mockEC2, "subnet-same-zone", az, "")
+	expectSubnet(mockEC2, "subnet-local-zone", "us-east-1-bos-1a", "")
+	expectSubnet(mockEC2, "subnet-outpost", az, outpostArn)
+
+	instance, err := cache.GetInstancePlacement(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "Availability Zone us-east-1a", instance.String())
+	// The placement of a subnet is only looked up once
+	assert.NoError(t, cache.CheckSubnetPlacement(ctx, "subnet-same-zone"))
+	assert.NoError(t, cache.CheckSubnetPlacement(ctx, "subnet-same-zone"))
+
+	var placementErr *SubnetPlacementError
+	err = cache.CheckSubnetPlacement(ctx, "subnet-local-zone")
+	if assert.True(t, errors.As(err, &placementErr)) {
+		assert.Equal(t, Placement{AvailabilityZone: "us-east-1-bos-1a", LocalZone: true}, placementErr.Subnet)
+		assert.Contains(t, err.Error(), "subnet subnet-local-zone is in Local Zone us-east-1-bos-1a")
+	}
+	err = cache.CheckSubnetPlacement(ctx, "subnet-outpost")
+	if assert.True(t, errors.As(err, &placementErr)) {
+		assert.Equal(t, outpostArn, placementErr.Subnet.OutpostArn)
+	}
+
+	mockEC2.EXPECT().DescribeSubnetsWithContext(gomock.Any(), gomock.Any()).Return(nil, errors.New("UnauthorizedOperation"))
+	err = cache.CheckSubnetPlacement(ctx, "subnet-unknown")
+	assert.Error(t, err)
+	assert.False(t, errors.As(err, &placementErr))
+}
+
+func TestCheckSubnetPlacementOnOutpost(t *testing.T) {
+	ctrl, mockEC2 := setup(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	cache := &EC2InstanceMetadataCache{ec2SVC: mockEC2, subnetID: subnetID, availabilityZone: az, region: "us-east-1"}
+	expectSubnet(mockEC2, subnetID, az, outpostArn)
+	expectSubnet(mockEC2, "subnet-outpost", az, outpostArn)
+	expectSubnet(mockEC2, "subnet-region", az, "")
+
+	instance, err := cache.GetInstancePlacement(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, outpostArn, instance.OutpostArn)
+	assert.NoError(t, cache.CheckSubnetPlacement(ctx, "subnet-outpost"))
+
+	// The ENI isn't created in a subnet of the region
+	_, err = cache.AllocENI(ctx, true, nil, "subnet-region")
+	var placementErr *SubnetPlacementError
+	assert.True(t, errors.As(err, &placementErr))
+}
@@ -0,0 +1,93 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package awsutils
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/pkg/errors"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils/awssession"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ec2wrapper"
+)
+
+// Provider is the cloud that the ENIs and IPs of the node are managed in: the instance metadata of the node and the EC2
+// API. Nodes use the EC2 provider. Other providers let the IPAM core run against EC2 variants that behave differently,
+// or without EC2 at all, like the in-memory provider of package fakeprovider used for scale testing.
+type Provider interface {
+	EC2MetadataIface
+	ec2wrapper.EC2
+
+	// Region returns the region of the node
+	Region() (string, error)
+}
+
+// ec2Provider talks to the instance metadata service of the node and to the EC2 API of its region
+type ec2Provider struct {
+	*ec2metadata.EC2Metadata
+	ec2wrapper.EC2
+	region string
+}
+
+// Region returns the region found in the instance metadata
+func (p *ec2Provider) Region() (string, error) {
+	return p.region, nil
+}
+
+// newEC2Provider returns the Provider of EC2 nodes. recordReachability is called on the completion of every EC2 call,
+// and recordThrottle after every failed attempt of an EC2 call. The retries of the SDK draw from budget, unless nil.
+func newEC2Provider(recordReachability, recordThrottle func(r *request.Request), budget *retryBudget) (Provider, error) {
+	sess := awssession.New()
+	ec2Metadata := ec2metadata.New(sess)
+
+	region, err := ec2Metadata.Region()
+	if err != nil {
+		log.Errorf("Failed to retrieve region data from instance metadata %v", err)
+		if inContainerNetworkNamespace() {
+			return nil, &IMDSHopLimitError{Err: err}
+		}
+		return nil, errors.Wrap(err, "instance metadata: failed to retrieve region data")
+	}
+
+	awsCfg := aws.NewConfig().WithRegion(region)
+	sess = sess.Copy(awsCfg)
+	sess.Handlers.Complete.PushBackNamed(request.NamedHandler{
+		Name: "amazon-vpc-cni-k8s/ec2-reachability",
+		Fn:   recordReachability,
+	})
+	sess.Handlers.AfterRetry.PushFrontNamed(request.NamedHandler{
+		Name: "amazon-vpc-cni-k8s/ec2-throttle",
+		Fn:   recordThrottle,
+	})
+	if budget != nil {
+		sess.Handlers.AfterRetry.PushFrontNamed(request.NamedHandler{
+			Name: "amazon-vpc-cni-k8s/ec2-retry-budget",
+			Fn:   budget.limitRetry,
+		})
+	}
+	sess.Handlers.Send.PushFrontNamed(request.NamedHandler{
+		Name: "amazon-vpc-cni-k8s/ec2-caller",
+		Fn:   recordEC2Caller,
+	})
+	sess.Handlers.Build.PushBackNamed(request.NamedHandler{
+		Name: "amazon-vpc-cni-k8s/ec2-trace-id",
+		Fn:   addTraceIDToUserAgent,
+	})
+	sess.Handlers.Complete.PushBackNamed(request.NamedHandler{
+		Name: "amazon-vpc-cni-k8s/ec2-audit",
+		Fn:   recordEC2Mutation,
+	})
+	return &ec2Provider{EC2Metadata: ec2Metadata, EC2: ec2wrapper.New(sess), region: region}, nil
+}
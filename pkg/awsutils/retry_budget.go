@@ -0,0 +1,196 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package awsutils
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/retry"
+)
+
+const (
+	// envEC2RetryBudget is the number of EC2 retries the node can make per minute, all EC2 calls together. 0 lets every
+	// call use all of its retries. Defaults to 60.
+	envEC2RetryBudget     = "EC2_RETRY_BUDGET"
+	defaultEC2RetryBudget = 60
+)
+
+// retryPriority orders the EC2 calls that compete for the retry budget
+type retryPriority int
+
+const (
+	// retryPriorityBackground calls can wait for the next pass of their loop: warm pool growth and cleanups
+	retryPriorityBackground retryPriority = iota
+	// retryPriorityDefault calls keep the node in sync with EC2
+	retryPriorityDefault
+	// retryPriorityPodBlocking calls are made for a pod that is waiting for them
+	retryPriorityPodBlocking
+)
+
+// retryBudgetReserve is the share of the retry budget that a priority leaves to the higher ones
+var retryBudgetReserve = map[retryPriority]float64{
+	retryPriorityBackground:  0.5,
+	retryPriorityDefault:     0.2,
+	retryPriorityPodBlocking: 0,
+}
+
+var ec2RetriesDenied = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "awscni_ec2api_retries_denied_count",
+		Help: "The number of EC2 API retries skipped because the node ran out of retry budget, by API and by caller",
+	},
+	[]string{"api", "caller"},
+)
+
+// retryPriorityFromContext returns the priority of the EC2 calls made with ctx. Calls made for a CNI request, which
+// carry its trace ID, and for the dedicated ENI of a pod block a pod. Warm pool growth does not, pods use the IPs left
+// in the pool in the meantime.
+func retryPriorityFromContext(ctx context.Context) retryPriority {
+	if TraceIDFromContext(ctx) != "" {
+		return retryPriorityPodBlocking
+	}
+	switch CallerFromContext(ctx) {
+	case CallerBranchENI:
+		return retryPriorityPodBlocking
+	case CallerScaleUp, CallerLeakedENICleanup, CallerSubnetProbe:
+		return retryPriorityBackground
+	default:
+		return retryPriorityDefault
+	}
+}
+
+// retryBudget is a token bucket of EC2 retries shared by all the EC2 calls of the node, so that a call failing over and
+// over doesn't use up the EC2 API quota of the others. The budget refills at size retries per minute, and the calls of
+// each priority stop retrying when the budget falls to the reserve of the higher priorities.
+type retryBudget struct {
+	lock   sync.Mutex
+	size   float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// newRetryBudget returns a full budget of size retries per minute, or nil if size is 0
+func newRetryBudget(size int, now func() time.Time) *retryBudget {
+	if size == 0 {
+		return nil
+	}
+	return &retryBudget{size: float64(size), tokens: float64(size), last: now(), now: now}
+}
+
+// getEC2RetryBudget returns the size of the retry budget set by EC2_RETRY_BUDGET
+func getEC2RetryBudget() int {
+	inputStr, found := os.LookupEnv(envEC2RetryBudget)
+	if !found {
+		return defaultEC2RetryBudget
+	}
+	if input, err := strconv.Atoi(inputStr); err == nil && input >= 0 {
+		return input
+	}
+	log.Warnf("Ignoring invalid %s %q, using %d", envEC2RetryBudget, inputStr, defaultEC2RetryBudget)
+	return defaultEC2RetryBudget
+}
+
+// take uses up one retry of the budget, and returns false if the calls of priority can't retry anymore
+func (b *retryBudget) take(priority retryPriority) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	now := b.now()
+	b.tokens += now.Sub(b.last).Minutes() * b.size
+	if b.tokens > b.size {
+		b.tokens = b.size
+	}
+	b.last = now
+	if b.tokens < 1+retryBudgetReserve[priority]*b.size {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// limitRetry runs after every failed attempt of an EC2 call, and cancels the retry the SDK is about to make if the
+// budget doesn't allow it. The call then fails with the error of its last attempt.
+func (b *retryBudget) limitRetry(r *request.Request) {
+	if r.Retryable == nil {
+		r.Retryable = aws.Bool(r.ShouldRetry(r))
+	}
+	if !r.WillRetry() || b.take(retryPriorityFromContext(r.Context())) {
+		return
+	}
+	r.Retryable = aws.Bool(false)
+	caller := CallerFromContext(r.Context())
+	ec2RetriesDenied.WithLabelValues(r.Operation.Name, caller).Inc()
+	log.Debugf("Out of EC2 retry budget, not retrying %s of %s: %v", r.Operation.Name, caller, r.Error)
+}
+
+// outOfRetryBudgetError stops a retry loop of awsutils with the error of its last attempt
+type outOfRetryBudgetError struct {
+	error
+}
+
+// Retry returns false, the loop can't retry anymore
+func (outOfRetryBudgetError) Retry() bool {
+	return false
+}
+
+// Cause returns the error of the last attempt
+func (e outOfRetryBudgetError) Cause() error {
+	return e.error
+}
+
+// Unwrap returns the error of the last attempt
+func (e outOfRetryBudgetError) Unwrap() error {
+	return e.error
+}
+
+// allowRetry uses up one retry of the budget for a retry of api made by awsutils rather than by the SDK, and returns
+// false if the calls of ctx can't retry anymore
+func (cache *EC2InstanceMetadataCache) allowRetry(ctx context.Context, api string) bool {
+	if cache.retryBudget == nil || cache.retryBudget.take(retryPriorityFromContext(ctx)) {
+		return true
+	}
+	caller := CallerFromContext(ctx)
+	ec2RetriesDenied.WithLabelValues(api, caller).Inc()
+	log.Debugf("Out of EC2 retry budget, not retrying %s of %s", api, caller)
+	return false
+}
+
+// withRetryBudget wraps fn, an attempt of a retry loop of at most tries attempts calling api, 0 if unbounded. Every
+// retry from the attempt firstEC2Attempt on, which calls EC2, uses up one retry of the budget, and the loop stops with
+// the error of its last attempt when the budget doesn't allow it.
+func (cache *EC2InstanceMetadataCache) withRetryBudget(ctx context.Context, api string, firstEC2Attempt, tries int, fn func() error) func() error {
+	attempt := 0
+	return func() error {
+		attempt++
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if retriable, ok := err.(retry.Retriable); ok && !retriable.Retry() {
+			return err
+		}
+		if attempt == tries || attempt+1 < firstEC2Attempt || cache.allowRetry(ctx, api) {
+			return err
+		}
+		return outOfRetryBudgetError{err}
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package awsutils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/retry"
+)
+
+func TestRetryPriorityFromContext(t *testing.T) {
+	assert.Equal(t, retryPriorityDefault, retryPriorityFromContext(context.Background()))
+	assert.Equal(t, retryPriorityBackground, retryPriorityFromContext(WithCaller(context.Background(), CallerScaleUp)))
+	assert.Equal(t, retryPriorityPodBlocking, retryPriorityFromContext(WithCaller(context.Background(), CallerBranchENI)))
+	// A CNI request outranks the loop it is served from
+	ctx := WithTraceID(WithCaller(context.Background(), CallerScaleUp), "trace")
+	assert.Equal(t, retryPriorityPodBlocking, retryPriorityFromContext(ctx))
+}
+
+func TestRetryBudgetReservesRetriesForPods(t *testing.T) {
+	now := time.Now()
+	budget := newRetryBudget(10, func() time.Time { return now })
+
+	// Warm pool growth leaves half of the budget to the others
+	for i := 0; i < 5; i++ {
+		assert.True(t, budget.take(retryPriorityBackground))
+	}
+	assert.False(t, budget.take(retryPriorityBackground))
+	// The reconciler leaves a fifth of it to the pods
+	for i := 0; i < 3; i++ {
+		assert.True(t, budget.take(retryPriorityDefault))
+	}
+	assert.False(t, budget.take(retryPriorityDefault))
+	// Pods can use all of it
+	assert.True(t, budget.take(retryPriorityPodBlocking))
+	assert.True(t, budget.take(retryPriorityPodBlocking))
+	assert.False(t, budget.take(retryPriorityPodBlocking))
+
+	// The budget refills over a minute
+	now = now.Add(30 * time.Second)
+	assert.True(t, budget.take(retryPriorityDefault))
+	now = now.Add(time.Hour)
+	for i := 0; i < 5; i++ {
+		assert.True(t, budget.take(retryPriorityBackground))
+	}
+	assert.False(t, budget.take(retryPriorityBackground))
+}
+
+func TestRetryBudgetLimitRetry(t *testing.T) {
+	now := time.Now()
+	budget := newRetryBudget(2, func() time.Time { return now })
+	newFailedRequest := func(ctx context.Context) *request.Request {
+		r := &request.Request{
+			Operation:   &request.Operation{Name: "AssignPrivateIpAddresses"},
+			HTTPRequest: &http.Request{Body: request.NoBody},
+			Retryer:     client.DefaultRetryer{NumMaxRetries: 3},
+			Error:       errors.New("throttled"),
+			Retryable:   aws.Bool(true),
+		}
+		r.SetContext(ctx)
+		return r
+	}
+	ctx := WithCaller(context.Background(), CallerScaleUp)
+	denied := testutil.ToFloat64(ec2RetriesDenied.WithLabelValues("AssignPrivateIpAddresses", CallerScaleUp))
+
+	r := newFailedRequest(ctx)
+	budget.limitRetry(r)
+	assert.True(t, aws.BoolValue(r.Retryable))
+
+	r = newFailedRequest(ctx)
+	budget.limitRetry(r)
+	assert.False(t, aws.BoolValue(r.Retryable))
+	assert.Equal(t, denied+1, testutil.ToFloat64(ec2RetriesDenied.WithLabelValues("AssignPrivateIpAddresses", CallerScaleUp)))
+
+	// A call that wouldn't retry anyway doesn't use the budget
+	r = newFailedRequest(WithTraceID(ctx, "trace"))
+	r.Retryable = aws.Bool(false)
+	budget.limitRetry(r)
+	r = newFailedRequest(WithTraceID(ctx, "trace"))
+	budget.limitRetry(r)
+	assert.True(t, aws.BoolValue(r.Retryable))
+}
+
+func TestNewRetryBudgetDisabled(t *testing.T) {
+	assert.Nil(t, newRetryBudget(0, time.Now))
+}
+
+func TestWithRetryBudget(t *testing.T) {
+	now := time.Now()
+	cache := &EC2InstanceMetadataCache{retryBudget: newRetryBudget(1, func() time.Time { return now })}
+	attempts := 0
+	attemptErr := errors.New("ENI not found yet")
+	fn := cache.withRetryBudget(WithTraceID(context.Background(), "trace"), "DescribeNetworkInterfaces", 3, 5, func() error {
+		attempts++
+		return attemptErr
+	})
+
+	// The retries into the attempts before the first EC2 one are free, the next ones use up the budget
+	err := retry.NWithBackoff(retry.NewSimpleBackoff(time.Millisecond, time.Millisecond, 0, 1), 5, fn)
+	assert.Equal(t, 3, attempts)
+	assert.True(t, errors.Is(err, attemptErr))
+	retriable, ok := err.(retry.Retriable)
+	assert.True(t, ok)
+	assert.False(t, retriable.Retry())
+
+	// Without a budget, the loop makes all of its attempts
+	attempts = 0
+	cache.retryBudget = nil
+	err = retry.NWithBackoff(retry.NewSimpleBackoff(time.Millisecond, time.Millisecond, 0, 1), 5,
+		cache.withRetryBudget(context.Background(), "DescribeNetworkInterfaces", 3, 5, func() error {
+			attempts++
+			return attemptErr
+		}))
+	assert.Equal(t, 5, attempts)
+	assert.Equal(t, attemptErr, err)
+}
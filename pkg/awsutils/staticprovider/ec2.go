@@ -0,0 +1,347 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package staticprovider
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// dryRun returns the DryRunOperation error of a dry run
+func dryRun(flag *bool) error {
+	if aws.BoolValue(flag) {
+		return awserr.New("DryRunOperation", "Request would have succeeded, but DryRun flag is set.", nil)
+	}
+	return nil
+}
+
+// CreateNetworkInterfaceWithContext fails, the node can't get more ENIs
+func (p *Provider) CreateNetworkInterfaceWithContext(ctx aws.Context, input *ec2.CreateNetworkInterfaceInput, opts ...request.Option) (*ec2.CreateNetworkInterfaceOutput, error) {
+	return nil, unsupported("CreateNetworkInterface")
+}
+
+// DescribeInstancesWithContext describes the node with its single ENI
+func (p *Provider) DescribeInstancesWithContext(ctx aws.Context, input *ec2.DescribeInstancesInput, opts ...request.Option) (*ec2.DescribeInstancesOutput, error) {
+	if err := dryRun(input.DryRun); err != nil {
+		return nil, err
+	}
+	for _, instanceID := range input.InstanceIds {
+		if aws.StringValue(instanceID) != p.cfg.InstanceID {
+			return nil, awserr.New("InvalidInstanceID.NotFound",
+				fmt.Sprintf("The instance ID '%s' does not exist", aws.StringValue(instanceID)), nil)
+		}
+	}
+	networkInterface := p.networkInterface()
+	instance := &ec2.Instance{
+		InstanceId:   aws.String(p.cfg.InstanceID),
+		InstanceType: aws.String(InstanceType),
+		SubnetId:     aws.String(subnetID),
+		VpcId:        aws.String(vpcID),
+		Placement:    &ec2.Placement{AvailabilityZone: aws.String(p.cfg.AvailabilityZone)},
+		NetworkInterfaces: []*ec2.InstanceNetworkInterface{{
+			NetworkInterfaceId: networkInterface.NetworkInterfaceId,
+			MacAddress:         networkInterface.MacAddress,
+			PrivateIpAddress:   networkInterface.PrivateIpAddress,
+			SubnetId:           networkInterface.SubnetId,
+			Attachment: &ec2.InstanceNetworkInterfaceAttachment{
+				AttachmentId:        networkInterface.Attachment.AttachmentId,
+				DeviceIndex:         networkInterface.Attachment.DeviceIndex,
+				NetworkCardIndex:    networkInterface.Attachment.NetworkCardIndex,
+				DeleteOnTermination: networkInterface.Attachment.DeleteOnTermination,
+				Status:              networkInterface.Attachment.Status,
+			},
+		}},
+	}
+	return &ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{Instances: []*ec2.Instance{instance}}}}, nil
+}
+
+// ModifyInstanceMetadataOptionsWithContext fails, the node has no instance metadata service
+func (p *Provider) ModifyInstanceMetadataOptionsWithContext(ctx aws.Context, input *ec2.ModifyInstanceMetadataOptionsInput, opts ...request.Option) (*ec2.ModifyInstanceMetadataOptionsOutput, error) {
+	return nil, unsupported("ModifyInstanceMetadataOptions")
+}
+
+// DescribeInstanceTypesWithContext returns a limit of a single ENI, which holds the host IP and the whole pool
+func (p *Provider) DescribeInstanceTypesWithContext(ctx aws.Context, input *ec2.DescribeInstanceTypesInput, opts ...request.Option) (*ec2.DescribeInstanceTypesOutput, error) {
+	if err := dryRun(input.DryRun); err != nil {
+		return nil, err
+	}
+	output := &ec2.DescribeInstanceTypesOutput{}
+	for _, instanceType := range input.InstanceTypes {
+		if aws.StringValue(instanceType) != InstanceType {
+			continue
+		}
+		output.InstanceTypes = append(output.InstanceTypes, &ec2.InstanceTypeInfo{
+			InstanceType: aws.String(InstanceType),
+			Hypervisor:   aws.String(ec2.InstanceTypeHypervisorNitro),
+			BareMetal:    aws.Bool(false),
+			NetworkInfo: &ec2.NetworkInfo{
+				MaximumNetworkInterfaces:  aws.Int64(1),
+				Ipv4AddressesPerInterface: aws.Int64(int64(len(p.ips) + 1)),
+			},
+		})
+	}
+	return output, nil
+}
+
+// AttachNetworkInterfaceWithContext fails, the node can't get more ENIs
+func (p *Provider) AttachNetworkInterfaceWithContext(ctx aws.Context, input *ec2.AttachNetworkInterfaceInput, opts ...request.Option) (*ec2.AttachNetworkInterfaceOutput, error) {
+	return nil, unsupported("AttachNetworkInterface")
+}
+
+// DeleteNetworkInterfaceWithContext fails, the ENI of the host interface can't be deleted
+func (p *Provider) DeleteNetworkInterfaceWithContext(ctx aws.Context, input *ec2.DeleteNetworkInterfaceInput, opts ...request.Option) (*ec2.DeleteNetworkInterfaceOutput, error) {
+	return nil, unsupported("DeleteNetworkInterface")
+}
+
+// DetachNetworkInterfaceWithContext fails, the ENI of the host interface can't be detached
+func (p *Provider) DetachNetworkInterfaceWithContext(ctx aws.Context, input *ec2.DetachNetworkInterfaceInput, opts ...request.Option) (*ec2.DetachNetworkInterfaceOutput, error) {
+	return nil, unsupported("DetachNetworkInterface")
+}
+
+// AssignPrivateIpAddressesWithContext fails, the whole pool is always assigned to the ENI
+func (p *Provider) AssignPrivateIpAddressesWithContext(ctx aws.Context, input *ec2.AssignPrivateIpAddressesInput, opts ...request.Option) (*ec2.AssignPrivateIpAddressesOutput, error) {
+	return nil, awserr.New("PrivateIpAddressLimitExceeded",
+		fmt.Sprintf("Number of private addresses will exceed limit for network interface '%s'", eniID), nil)
+}
+
+// UnassignPrivateIpAddressesWithContext fails, the addresses of the pool can't be released
+func (p *Provider) UnassignPrivateIpAddressesWithContext(ctx aws.Context, input *ec2.UnassignPrivateIpAddressesInput, opts ...request.Option) (*ec2.UnassignPrivateIpAddressesOutput, error) {
+	return nil, unsupported("UnassignPrivateIpAddresses")
+}
+
+// AssignIpv6AddressesWithContext fails, static pools are IPv4 only
+func (p *Provider) AssignIpv6AddressesWithContext(ctx aws.Context, input *ec2.AssignIpv6AddressesInput, opts ...request.Option) (*ec2.AssignIpv6AddressesOutput, error) {
+	return nil, unsupported("AssignIpv6Addresses")
+}
+
+// UnassignIpv6AddressesWithContext fails, static pools are IPv4 only
+func (p *Provider) UnassignIpv6AddressesWithContext(ctx aws.Context, input *ec2.UnassignIpv6AddressesInput, opts ...request.Option) (*ec2.UnassignIpv6AddressesOutput, error) {
+	return nil, unsupported("UnassignIpv6Addresses")
+}
+
+// DescribeNetworkInterfacesWithContext describes the ENI of the host interface if it matches the input
+func (p *Provider) DescribeNetworkInterfacesWithContext(ctx aws.Context, input *ec2.DescribeNetworkInterfacesInput, opts ...request.Option) (*ec2.DescribeNetworkInterfacesOutput, error) {
+	if err := dryRun(input.DryRun); err != nil {
+		return nil, err
+	}
+	return p.describeNetworkInterfaces(input)
+}
+
+// DescribeNetworkInterfacesPagesWithContext describes the ENI of the host interface in a single page
+func (p *Provider) DescribeNetworkInterfacesPagesWithContext(ctx aws.Context, input *ec2.DescribeNetworkInterfacesInput, fn func(*ec2.DescribeNetworkInterfacesOutput, bool) bool, opts ...request.Option) error {
+	if err := dryRun(input.DryRun); err != nil {
+		return err
+	}
+	output, err := p.describeNetworkInterfaces(input)
+	if err != nil {
+		return err
+	}
+	fn(output, true)
+	return nil
+}
+
+func (p *Provider) describeNetworkInterfaces(input *ec2.DescribeNetworkInterfacesInput) (*ec2.DescribeNetworkInterfacesOutput, error) {
+	for _, id := range input.NetworkInterfaceIds {
+		if aws.StringValue(id) != eniID {
+			return nil, eniNotFound(aws.StringValue(id))
+		}
+	}
+	output := &ec2.DescribeNetworkInterfacesOutput{}
+	matched, err := p.matches(input.Filters)
+	if err != nil {
+		return nil, err
+	}
+	if matched {
+		output.NetworkInterfaces = append(output.NetworkInterfaces, p.networkInterface())
+	}
+	return output, nil
+}
+
+// ModifyNetworkInterfaceAttributeWithContext accepts any change of the ENI, which has no security groups
+func (p *Provider) ModifyNetworkInterfaceAttributeWithContext(ctx aws.Context, input *ec2.ModifyNetworkInterfaceAttributeInput, opts ...request.Option) (*ec2.ModifyNetworkInterfaceAttributeOutput, error) {
+	if err := dryRun(input.DryRun); err != nil {
+		return nil, err
+	}
+	if id := aws.StringValue(input.NetworkInterfaceId); id != eniID {
+		return nil, eniNotFound(id)
+	}
+	return &ec2.ModifyNetworkInterfaceAttributeOutput{}, nil
+}
+
+// CreateTagsWithContext adds tags to the ENI
+func (p *Provider) CreateTagsWithContext(ctx aws.Context, input *ec2.CreateTagsInput, opts ...request.Option) (*ec2.CreateTagsOutput, error) {
+	if err := dryRun(input.DryRun); err != nil {
+		return nil, err
+	}
+	for _, resource := range input.Resources {
+		if id := aws.StringValue(resource); id != eniID {
+			return nil, eniNotFound(id)
+		}
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, tag := range input.Tags {
+		p.tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return &ec2.CreateTagsOutput{}, nil
+}
+
+// DescribeSubnetsWithContext describes the subnet of the host interface, which has no address left to allocate
+func (p *Provider) DescribeSubnetsWithContext(ctx aws.Context, input *ec2.DescribeSubnetsInput, opts ...request.Option) (*ec2.DescribeSubnetsOutput, error) {
+	if err := dryRun(input.DryRun); err != nil {
+		return nil, err
+	}
+	for _, id := range input.SubnetIds {
+		if aws.StringValue(id) != subnetID {
+			return nil, awserr.New("InvalidSubnetID.NotFound", fmt.Sprintf("The subnet ID '%s' does not exist", aws.StringValue(id)), nil)
+		}
+	}
+	return &ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{{
+		SubnetId:                aws.String(subnetID),
+		VpcId:                   aws.String(vpcID),
+		CidrBlock:               aws.String(p.cfg.Interface.CIDR),
+		AvailabilityZone:        aws.String(p.cfg.AvailabilityZone),
+		AvailableIpAddressCount: aws.Int64(0),
+	}}}, nil
+}
+
+// GetSubnetCidrReservationsWithContext returns no reservation
+func (p *Provider) GetSubnetCidrReservationsWithContext(ctx aws.Context, input *ec2.GetSubnetCidrReservationsInput, opts ...request.Option) (*ec2.GetSubnetCidrReservationsOutput, error) {
+	if err := dryRun(input.DryRun); err != nil {
+		return nil, err
+	}
+	if aws.StringValue(input.SubnetId) != subnetID {
+		return nil, awserr.New("InvalidSubnetID.NotFound", fmt.Sprintf("The subnet ID '%s' does not exist", aws.StringValue(input.SubnetId)), nil)
+	}
+	return &ec2.GetSubnetCidrReservationsOutput{}, nil
+}
+
+// DescribeSecurityGroupsWithContext returns no security group
+func (p *Provider) DescribeSecurityGroupsWithContext(ctx aws.Context, input *ec2.DescribeSecurityGroupsInput, opts ...request.Option) (*ec2.DescribeSecurityGroupsOutput, error) {
+	if err := dryRun(input.DryRun); err != nil {
+		return nil, err
+	}
+	return &ec2.DescribeSecurityGroupsOutput{}, nil
+}
+
+// DescribeVpcsWithContext describes a VPC with the first routable CIDR and the default DHCP options
+func (p *Provider) DescribeVpcsWithContext(ctx aws.Context, input *ec2.DescribeVpcsInput, opts ...request.Option) (*ec2.DescribeVpcsOutput, error) {
+	if err := dryRun(input.DryRun); err != nil {
+		return nil, err
+	}
+	for _, id := range input.VpcIds {
+		if aws.StringValue(id) != vpcID {
+			return nil, awserr.New("InvalidVpcID.NotFound", fmt.Sprintf("The vpc ID '%s' does not exist", aws.StringValue(id)), nil)
+		}
+	}
+	return &ec2.DescribeVpcsOutput{Vpcs: []*ec2.Vpc{{
+		VpcId:         aws.String(vpcID),
+		CidrBlock:     aws.String(p.cidrs[0]),
+		DhcpOptionsId: aws.String("default"),
+	}}}, nil
+}
+
+// DescribeDhcpOptionsWithContext fails, the VPC uses the default DHCP options
+func (p *Provider) DescribeDhcpOptionsWithContext(ctx aws.Context, input *ec2.DescribeDhcpOptionsInput, opts ...request.Option) (*ec2.DescribeDhcpOptionsOutput, error) {
+	if err := dryRun(input.DryRun); err != nil {
+		return nil, err
+	}
+	return nil, awserr.New("InvalidDhcpOptionID.NotFound", "The dhcp options set does not exist", nil)
+}
+
+// networkInterface returns the ENI of the host interface as EC2 describes it
+func (p *Provider) networkInterface() *ec2.NetworkInterface {
+	networkInterface := &ec2.NetworkInterface{
+		NetworkInterfaceId: aws.String(eniID),
+		MacAddress:         aws.String(p.cfg.Interface.MAC),
+		Description:        aws.String("Host interface"),
+		SubnetId:           aws.String(subnetID),
+		VpcId:              aws.String(vpcID),
+		AvailabilityZone:   aws.String(p.cfg.AvailabilityZone),
+		InterfaceType:      aws.String(ec2.NetworkInterfaceTypeInterface),
+		Status:             aws.String(ec2.NetworkInterfaceStatusInUse),
+		PrivateIpAddress:   aws.String(p.cfg.Interface.IP),
+		Attachment: &ec2.NetworkInterfaceAttachment{
+			AttachmentId:        aws.String(attachmentID),
+			DeviceIndex:         aws.Int64(0),
+			NetworkCardIndex:    aws.Int64(0),
+			InstanceId:          aws.String(p.cfg.InstanceID),
+			DeleteOnTermination: aws.Bool(false),
+			Status:              aws.String(ec2.AttachmentStatusAttached),
+		},
+	}
+	for i, ip := range p.localIPv4s() {
+		networkInterface.PrivateIpAddresses = append(networkInterface.PrivateIpAddresses, &ec2.NetworkInterfacePrivateIpAddress{
+			Primary:          aws.Bool(i == 0),
+			PrivateIpAddress: aws.String(ip),
+		})
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	keys := make([]string, 0, len(p.tags))
+	for key := range p.tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		networkInterface.TagSet = append(networkInterface.TagSet, &ec2.Tag{Key: aws.String(key), Value: aws.String(p.tags[key])})
+	}
+	return networkInterface
+}
+
+// matches returns true if the ENI passes all the filters of a DescribeNetworkInterfaces call
+func (p *Provider) matches(filters []*ec2.Filter) (bool, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, filter := range filters {
+		name := aws.StringValue(filter.Name)
+		var values []string
+		switch {
+		case name == "tag-key":
+			for key := range p.tags {
+				values = append(values, key)
+			}
+		case strings.HasPrefix(name, "tag:"):
+			if value, ok := p.tags[strings.TrimPrefix(name, "tag:")]; ok {
+				values = append(values, value)
+			}
+		case name == "status":
+			values = append(values, ec2.NetworkInterfaceStatusInUse)
+		case name == "subnet-id":
+			values = append(values, subnetID)
+		case name == "attachment.instance-id":
+			values = append(values, p.cfg.InstanceID)
+		default:
+			return false, awserr.New("InvalidParameterValue", fmt.Sprintf("The filter '%s' is invalid", name), nil)
+		}
+		matched := false
+		for _, value := range values {
+			for _, wanted := range filter.Values {
+				if value == aws.StringValue(wanted) {
+					matched = true
+				}
+			}
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
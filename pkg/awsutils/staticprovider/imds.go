@@ -0,0 +1,66 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package staticprovider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+const macsPrefix = "network/interfaces/macs/"
+
+// GetMetadataWithContext returns the instance metadata at path p for the host interface and the static pool
+func (p *Provider) GetMetadataWithContext(ctx context.Context, path string) (string, error) {
+	mac := p.cfg.Interface.MAC
+	switch path {
+	case "placement/availability-zone":
+		return p.cfg.AvailabilityZone, nil
+	case "instance-type":
+		return InstanceType, nil
+	case "instance-id":
+		return p.cfg.InstanceID, nil
+	case "mac":
+		return mac, nil
+	case "local-ipv4":
+		return p.cfg.Interface.IP, nil
+	case "network/interfaces/macs", "network/interfaces/macs/":
+		return mac + "/", nil
+	}
+
+	if strings.HasPrefix(path, macsPrefix+mac+"/") {
+		switch strings.TrimPrefix(path, macsPrefix+mac+"/") {
+		case "interface-id":
+			return eniID, nil
+		case "device-number":
+			return "0", nil
+		case "subnet-id":
+			return subnetID, nil
+		case "vpc-id":
+			return vpcID, nil
+		case "security-group-ids":
+			return "", nil
+		case "local-ipv4s":
+			return strings.Join(p.localIPv4s(), "\n"), nil
+		case "subnet-ipv4-cidr-block":
+			return p.cfg.Interface.CIDR, nil
+		case "vpc-ipv4-cidr-blocks":
+			return p.routableCIDRs(), nil
+		}
+	}
+	return "", awserr.NewRequestFailure(awserr.New("NotFound", fmt.Sprintf("%s not found", path), nil), http.StatusNotFound, "")
+}
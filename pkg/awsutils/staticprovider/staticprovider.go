@@ -0,0 +1,227 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package staticprovider is an awsutils.Provider for nodes outside of EC2, like EKS Hybrid and on-premises nodes. It
+// presents the host interface of the node as the primary ENI of an instance, with the addresses of a statically
+// configured pool of CIDRs as its secondary IPs, so that ipamd hands them out to pods without any EC2 call.
+package staticprovider
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ec2wrapper"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/netlinkwrapper"
+)
+
+const (
+	// MaxPoolSize is the largest number of pod IPs a static pool can hold
+	MaxPoolSize = 1024
+
+	// InstanceType is the instance type of every node of the provider
+	InstanceType = "static-ip-pool"
+
+	eniID        = "eni-static"
+	attachmentID = "eni-attach-static"
+	subnetID     = "subnet-static"
+	vpcID        = "vpc-static"
+)
+
+// Interface is the host interface the pods of the node reach the network through
+type Interface struct {
+	MAC string
+	IP  string
+	// CIDR is the subnet of IP
+	CIDR string
+}
+
+// Config is the node and the static pool that the Provider presents
+type Config struct {
+	Region           string
+	AvailabilityZone string
+	InstanceID       string
+	Interface        Interface
+	// PoolCIDRs are the IPv4 CIDRs that the pod IPs of the node are taken from. The network and broadcast addresses
+	// of CIDRs larger than a /31 are left out.
+	PoolCIDRs []string
+	// RoutableCIDRs are reached by the pods without SNAT, and are presented as the CIDRs of the VPC. They default to
+	// the pool CIDRs and the subnet of the host interface.
+	RoutableCIDRs []string
+}
+
+// Provider is an awsutils.Provider with a single ENI that holds all the IPs of a static pool
+type Provider struct {
+	cfg   Config
+	ips   []string
+	cidrs []string
+
+	lock sync.Mutex
+	tags map[string]string
+}
+
+var _ ec2wrapper.EC2 = &Provider{}
+
+// New returns a Provider for the static pool of cfg
+func New(cfg Config) (*Provider, error) {
+	hostIP := net.ParseIP(cfg.Interface.IP)
+	if hostIP == nil || hostIP.To4() == nil {
+		return nil, errors.Errorf("invalid IPv4 address %q of the host interface", cfg.Interface.IP)
+	}
+	if _, err := net.ParseMAC(cfg.Interface.MAC); err != nil {
+		return nil, errors.Errorf("invalid MAC address %q of the host interface", cfg.Interface.MAC)
+	}
+	if _, _, err := net.ParseCIDR(cfg.Interface.CIDR); err != nil {
+		return nil, errors.Errorf("invalid subnet %q of the host interface", cfg.Interface.CIDR)
+	}
+	ips, err := expandPool(cfg.PoolCIDRs, hostIP.String())
+	if err != nil {
+		return nil, err
+	}
+	cidrs := cfg.RoutableCIDRs
+	if len(cidrs) == 0 {
+		cidrs = append(append(cidrs, cfg.PoolCIDRs...), cfg.Interface.CIDR)
+	}
+	for _, cidr := range cidrs {
+		if _, ipNet, err := net.ParseCIDR(cidr); err != nil || ipNet.IP.To4() == nil {
+			return nil, errors.Errorf("invalid routable IPv4 CIDR %q", cidr)
+		}
+	}
+	return &Provider{cfg: cfg, ips: ips, cidrs: cidrs, tags: make(map[string]string)}, nil
+}
+
+// expandPool returns the addresses of the pool CIDRs, without duplicates and without the host IP
+func expandPool(poolCIDRs []string, hostIP string) ([]string, error) {
+	if len(poolCIDRs) == 0 {
+		return nil, errors.New("no pool CIDR is configured")
+	}
+	seen := map[string]bool{hostIP: true}
+	var ips []string
+	for _, cidr := range poolCIDRs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil || ipNet.IP.To4() == nil {
+			return nil, errors.Errorf("invalid pool IPv4 CIDR %q", cidr)
+		}
+		ones, bits := ipNet.Mask.Size()
+		size := uint64(1) << uint(bits-ones)
+		first, last := uint64(0), size-1
+		if size > 2 {
+			first, last = 1, size-2
+		}
+		if uint64(len(ips))+last-first+1 > MaxPoolSize {
+			return nil, errors.Errorf("the pool CIDRs hold more than %d addresses", MaxPoolSize)
+		}
+		base := binary.BigEndian.Uint32(ipNet.IP.To4())
+		for n := first; n <= last; n++ {
+			ip := make(net.IP, net.IPv4len)
+			binary.BigEndian.PutUint32(ip, base+uint32(n))
+			if !seen[ip.String()] {
+				seen[ip.String()] = true
+				ips = append(ips, ip.String())
+			}
+		}
+	}
+	if len(ips) == 0 {
+		return nil, errors.New("the pool CIDRs hold no address besides the host IP")
+	}
+	return ips, nil
+}
+
+// PoolSize returns the number of pod IPs of the pool
+func (p *Provider) PoolSize() int {
+	return len(p.ips)
+}
+
+// Region returns the configured region
+func (p *Provider) Region() (string, error) {
+	return p.cfg.Region, nil
+}
+
+// FindInterface returns the host interface called name, or the interface of the IPv4 default route if name is empty
+func FindInterface(netLink netlinkwrapper.NetLink, name string) (Interface, error) {
+	var link netlink.Link
+	var err error
+	if name != "" {
+		link, err = netLink.LinkByName(name)
+		if err != nil {
+			return Interface{}, errors.Wrapf(err, "failed to find interface %s", name)
+		}
+	} else {
+		link, err = defaultRouteLink(netLink)
+		if err != nil {
+			return Interface{}, err
+		}
+	}
+	addrs, err := netLink.AddrList(link, netlink.FAMILY_V4)
+	if err != nil {
+		return Interface{}, errors.Wrapf(err, "failed to list the addresses of interface %s", link.Attrs().Name)
+	}
+	for _, addr := range addrs {
+		if addr.IPNet == nil || !addr.IP.IsGlobalUnicast() {
+			continue
+		}
+		subnet := net.IPNet{IP: addr.IP.Mask(addr.Mask), Mask: addr.Mask}
+		return Interface{
+			MAC:  link.Attrs().HardwareAddr.String(),
+			IP:   addr.IP.String(),
+			CIDR: subnet.String(),
+		}, nil
+	}
+	return Interface{}, errors.Errorf("interface %s has no IPv4 address", link.Attrs().Name)
+}
+
+// defaultRouteLink returns the interface of the IPv4 default route of the main table
+func defaultRouteLink(netLink netlinkwrapper.NetLink) (netlink.Link, error) {
+	routes, err := netLink.RouteList(nil, netlink.FAMILY_V4)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list the IPv4 routes")
+	}
+	links, err := netLink.LinkList()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list the interfaces")
+	}
+	for _, route := range routes {
+		if route.Dst != nil {
+			continue
+		}
+		for _, link := range links {
+			if link.Attrs().Index == route.LinkIndex {
+				return link, nil
+			}
+		}
+	}
+	return nil, errors.New("no interface found for the IPv4 default route")
+}
+
+func unsupported(api string) error {
+	return awserr.New("UnsupportedOperation", fmt.Sprintf("%s is not supported with a static IP pool", api), nil)
+}
+
+func eniNotFound(id string) error {
+	return awserr.New("InvalidNetworkInterfaceID.NotFound", fmt.Sprintf("The networkInterface ID '%s' does not exist", id), nil)
+}
+
+// localIPv4s returns the addresses of the ENI, the host IP first
+func (p *Provider) localIPv4s() []string {
+	return append([]string{p.cfg.Interface.IP}, p.ips...)
+}
+
+func (p *Provider) routableCIDRs() string {
+	return strings.Join(p.cidrs, "\n")
+}
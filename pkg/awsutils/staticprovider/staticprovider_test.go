@@ -0,0 +1,158 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package staticprovider_test
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vishvananda/netlink"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils/staticprovider"
+	mock_netlinkwrapper "github.com/aws/amazon-vpc-cni-k8s/pkg/netlinkwrapper/mocks"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/eventrecorder"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/paths"
+)
+
+var _ awsutils.Provider = &staticprovider.Provider{}
+
+func testConfig() staticprovider.Config {
+	return staticprovider.Config{
+		Region:           "us-west-2",
+		AvailabilityZone: "onprem-1",
+		InstanceID:       "hybrid-node-1",
+		Interface:        staticprovider.Interface{MAC: "52:54:00:12:34:56", IP: "192.168.1.10", CIDR: "192.168.1.0/24"},
+		PoolCIDRs:        []string{"10.85.0.0/29", "10.85.0.4/30", "10.85.1.1/32"},
+	}
+}
+
+func TestNewInvalidConfig(t *testing.T) {
+	for _, mutate := range []func(cfg *staticprovider.Config){
+		func(cfg *staticprovider.Config) { cfg.Interface.IP = "fd00::1" },
+		func(cfg *staticprovider.Config) { cfg.Interface.MAC = "" },
+		func(cfg *staticprovider.Config) { cfg.PoolCIDRs = nil },
+		func(cfg *staticprovider.Config) { cfg.PoolCIDRs = []string{"fd00::/120"} },
+		func(cfg *staticprovider.Config) { cfg.PoolCIDRs = []string{"10.0.0.0/16"} },
+		func(cfg *staticprovider.Config) { cfg.PoolCIDRs = []string{"192.168.1.10/32"} },
+		func(cfg *staticprovider.Config) { cfg.RoutableCIDRs = []string{"not-a-cidr"} },
+	} {
+		cfg := testConfig()
+		mutate(&cfg)
+		_, err := staticprovider.New(cfg)
+		assert.Error(t, err)
+	}
+}
+
+func TestPool(t *testing.T) {
+	ctx := context.Background()
+	provider, err := staticprovider.New(testConfig())
+	require.NoError(t, err)
+	// The network and broadcast addresses of the /29 are left out, and the /30 overlaps it
+	assert.Equal(t, 7, provider.PoolSize())
+
+	mac, err := provider.GetMetadataWithContext(ctx, "mac")
+	require.NoError(t, err)
+	ips, err := provider.GetMetadataWithContext(ctx, "network/interfaces/macs/"+mac+"/local-ipv4s")
+	assert.NoError(t, err)
+	assert.Equal(t, "192.168.1.10\n10.85.0.1\n10.85.0.2\n10.85.0.3\n10.85.0.4\n10.85.0.5\n10.85.0.6\n10.85.1.1", ips)
+	cidrs, err := provider.GetMetadataWithContext(ctx, "network/interfaces/macs/"+mac+"/vpc-ipv4-cidr-blocks")
+	assert.NoError(t, err)
+	assert.Equal(t, "10.85.0.0/29\n10.85.0.4/30\n10.85.1.1/32\n192.168.1.0/24", cidrs)
+	_, err = provider.GetMetadataWithContext(ctx, "network/interfaces/macs/"+mac+"/ipv4-prefix")
+	assert.True(t, awsutils.IsNotFound(err))
+}
+
+func TestAWSUtilsWithProvider(t *testing.T) {
+	defer os.Unsetenv(paths.EnvRunDir)
+	_ = os.Setenv(paths.EnvRunDir, t.TempDir())
+	eventrecorder.InitMockEventRecorder(nil)
+	ctx := context.Background()
+
+	cfg := testConfig()
+	cfg.RoutableCIDRs = []string{"10.85.0.0/16", "192.168.0.0/16"}
+	provider, err := staticprovider.New(cfg)
+	require.NoError(t, err)
+	cache, err := awsutils.NewWithProvider(provider, false, true, true, false)
+	require.NoError(t, err)
+	assert.Equal(t, "hybrid-node-1", cache.GetInstanceID())
+	assert.Equal(t, "192.168.1.10", cache.GetLocalIPv4().String())
+	require.NoError(t, cache.FetchInstanceTypeLimits())
+	assert.Equal(t, 1, cache.GetENILimit())
+	assert.Equal(t, 7, cache.GetENIIPv4Limit())
+	require.NoError(t, cache.RefreshSGIDs(ctx, cache.GetPrimaryENImac()))
+	vpcCIDRs, err := cache.GetVPCIPv4CIDRs()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.85.0.0/16", "192.168.0.0/16"}, vpcCIDRs)
+	assert.Empty(t, cache.CheckEC2Permissions(false))
+
+	result, err := cache.DescribeAllENIs(ctx)
+	require.NoError(t, err)
+	require.Len(t, result.ENIMetadata, 1)
+	eni := result.ENIMetadata[0]
+	assert.Equal(t, cache.GetPrimaryENI(), eni.ENIID)
+	assert.Equal(t, "52:54:00:12:34:56", eni.MAC)
+	assert.Len(t, eni.IPv4Addresses, 8)
+	assert.Equal(t, "192.168.1.0/24", eni.SubnetIPv4CIDR)
+
+	// The pool is fixed, the ENI is always full
+	output, err := cache.AllocIPAddresses(ctx, eni.ENIID, 1)
+	assert.NoError(t, err)
+	assert.Nil(t, output)
+	_, err = cache.AllocENI(ctx, false, nil, "")
+	assert.Error(t, err)
+	assert.Error(t, cache.DeallocIPAddresses(ctx, eni.ENIID, []string{"10.85.0.1"}))
+}
+
+func TestFindInterface(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockNetLink := mock_netlinkwrapper.NewMockNetLink(ctrl)
+
+	hwAddr, _ := net.ParseMAC("52:54:00:12:34:56")
+	lo := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Index: 1, Name: "lo"}}
+	uplink := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Index: 2, Name: "enp1s0", HardwareAddr: hwAddr}}
+	_, subnet, _ := net.ParseCIDR("192.168.1.0/24")
+	addrs := []netlink.Addr{
+		{IPNet: &net.IPNet{IP: net.ParseIP("169.254.0.1"), Mask: net.CIDRMask(16, 32)}},
+		{IPNet: &net.IPNet{IP: net.ParseIP("192.168.1.10"), Mask: subnet.Mask}},
+	}
+	expected := staticprovider.Interface{MAC: "52:54:00:12:34:56", IP: "192.168.1.10", CIDR: "192.168.1.0/24"}
+
+	mockNetLink.EXPECT().RouteList(nil, netlink.FAMILY_V4).Return([]netlink.Route{
+		{Dst: subnet, LinkIndex: 1},
+		{LinkIndex: 2},
+	}, nil)
+	mockNetLink.EXPECT().LinkList().Return([]netlink.Link{lo, uplink}, nil)
+	mockNetLink.EXPECT().AddrList(uplink, netlink.FAMILY_V4).Return(addrs, nil)
+	iface, err := staticprovider.FindInterface(mockNetLink, "")
+	assert.NoError(t, err)
+	assert.Equal(t, expected, iface)
+
+	mockNetLink.EXPECT().LinkByName("enp1s0").Return(uplink, nil)
+	mockNetLink.EXPECT().AddrList(uplink, netlink.FAMILY_V4).Return(addrs, nil)
+	iface, err = staticprovider.FindInterface(mockNetLink, "enp1s0")
+	assert.NoError(t, err)
+	assert.Equal(t, expected, iface)
+
+	mockNetLink.EXPECT().RouteList(nil, netlink.FAMILY_V4).Return([]netlink.Route{{Dst: subnet, LinkIndex: 1}}, nil)
+	mockNetLink.EXPECT().LinkList().Return([]netlink.Link{lo, uplink}, nil)
+	_, err = staticprovider.FindInterface(mockNetLink, "")
+	assert.Error(t, err)
+}
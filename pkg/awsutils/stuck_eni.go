@@ -0,0 +1,160 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package awsutils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/retry"
+)
+
+const (
+	// envENIAttachTimeout is how long ipamd waits for a new ENI and its IPs to show up before giving up. An ENI still
+	// "attaching" by then is force-detached and deleted. 0 waits for the retries to run out. Defaults to 120.
+	envENIAttachTimeout     = "ENI_ATTACH_TIMEOUT_SECONDS"
+	defaultENIAttachTimeout = 2 * time.Minute
+
+	// envENIDetachTimeout is how long ipamd waits for a detached ENI to become available before deleting it. An ENI
+	// still "detaching" by then is force-detached, and tagged and left to the leaked ENI cleanup if that doesn't work
+	// either. 0 only retries the delete. Defaults to 120.
+	envENIDetachTimeout     = "ENI_DETACH_TIMEOUT_SECONDS"
+	defaultENIDetachTimeout = 2 * time.Minute
+
+	// eniStuckTagKey is set to the time an ENI stuck in "attaching" or "detaching" was abandoned
+	eniStuckTagKey = "node.k8s.amazonaws.com/stuck-since"
+
+	// eniStatusPollMaxDelay is the longest time between two checks of the status of an ENI
+	eniStatusPollMaxDelay = 10 * time.Second
+)
+
+// ErrENIStuck is returned when an ENI stuck in "detaching" could not be force-detached
+var ErrENIStuck = errors.New("ENI is stuck in detaching")
+
+var eniStuckOperations = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "awscni_eni_stuck_operation_count",
+		Help: "The number of ENI attachments and detachments that did not complete before their timeout, by recovery",
+	},
+	[]string{"operation", "recovery"},
+)
+
+// getENIOperationTimeout returns the timeout in seconds of the env var key, 0 to disable it
+func getENIOperationTimeout(key string, defaultTimeout time.Duration) time.Duration {
+	inputStr, found := os.LookupEnv(key)
+	if !found {
+		return defaultTimeout
+	}
+	if input, err := strconv.Atoi(inputStr); err == nil && input >= 0 {
+		return time.Duration(input) * time.Second
+	}
+	log.Warnf("Ignoring invalid %s %q, using %v", key, inputStr, defaultTimeout)
+	return defaultTimeout
+}
+
+// waitForENIAvailable polls EC2 until the ENI is detached, or deleted, and returns false if it still isn't after
+// timeout
+func (cache *EC2InstanceMetadataCache) waitForENIAvailable(ctx context.Context, eniID string, timeout time.Duration) bool {
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	available := false
+	_ = retry.WithBackoffCtx(waitCtx, retry.NewSimpleBackoff(timeout/20, eniStatusPollMaxDelay, 0.15, 2.0), cache.withRetryBudget(waitCtx, "DescribeNetworkInterfaces", 1, 0, func() error {
+		eni, err := cache.describeENI(waitCtx, eniID)
+		if err == ErrENINotFound || (err == nil && aws.StringValue(eni.Status) == ec2.NetworkInterfaceStatusAvailable) {
+			available = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		log.Debugf("ENI %s is %s", eniID, aws.StringValue(eni.Status))
+		return errors.New("ENI is not available yet")
+	}))
+	return available
+}
+
+// forceDetachENI detaches an ENI whose attachment or detachment did not complete
+func (cache *EC2InstanceMetadataCache) forceDetachENI(ctx context.Context, eniID string, attachID *string) error {
+	start := time.Now()
+	_, err := cache.ec2SVC.DetachNetworkInterfaceWithContext(ctx, &ec2.DetachNetworkInterfaceInput{
+		AttachmentId: attachID,
+		Force:        aws.Bool(true),
+	})
+	awsAPILatency.WithLabelValues("DetachNetworkInterface", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
+	if err != nil {
+		CheckAPIErrorAndBroadcastEvent(err, "ec2:DetachNetworkInterface")
+		awsAPIErrInc("DetachNetworkInterface", err)
+		return errors.Wrapf(err, "failed to force detach ENI %s", eniID)
+	}
+	log.Infof("Force detached ENI %s", eniID)
+	return nil
+}
+
+// abandonStuckENI tags an ENI that could not be detached, so that it can be told apart from the leaked ENIs, which
+// the cleanup deletes once it is available
+func (cache *EC2InstanceMetadataCache) abandonStuckENI(ctx context.Context, eniID string) {
+	log.Errorf("Abandoning ENI %s, which is stuck in its attachment, to the leaked ENI cleanup", eniID)
+	if err := cache.AddENITags(ctx, eniID, map[string]string{eniStuckTagKey: time.Now().Format(time.RFC3339)}); err != nil {
+		log.Warnf("Failed to tag stuck ENI %s: %v", eniID, err)
+	}
+}
+
+// ensureENIDetached waits for a detached ENI to become available, so that it can be deleted. An ENI still detaching
+// after the detach timeout is force-detached, or abandoned, so that the caller doesn't retry the delete for minutes.
+func (cache *EC2InstanceMetadataCache) ensureENIDetached(ctx context.Context, eniID string, attachID *string) error {
+	if cache.waitForENIAvailable(ctx, eniID, cache.eniDetachTimeout) {
+		return nil
+	}
+	log.Warnf("ENI %s is still detaching after %v, forcing the detachment", eniID, cache.eniDetachTimeout)
+	if err := cache.forceDetachENI(ctx, eniID, attachID); err != nil {
+		log.Warnf("%v", err)
+	} else if cache.waitForENIAvailable(ctx, eniID, cache.eniDetachTimeout) {
+		eniStuckOperations.WithLabelValues("detach", "force_detached").Inc()
+		return nil
+	}
+	eniStuckOperations.WithLabelValues("detach", "abandoned").Inc()
+	cache.abandonStuckENI(ctx, eniID)
+	return errors.Wrapf(ErrENIStuck, "ENI %s", eniID)
+}
+
+// recoverStuckAttachment frees a new ENI whose attachment did not complete before the attach timeout, so that the next
+// pool increase starts from a clean state. ENIs that are attached, but whose IPs are not visible yet, are kept.
+func (cache *EC2InstanceMetadataCache) recoverStuckAttachment(ctx context.Context, eniID string) {
+	eni, err := cache.describeENI(ctx, eniID)
+	if err != nil {
+		log.Warnf("Failed to check the attachment of ENI %s: %v", eniID, err)
+		return
+	}
+	if eni.Attachment == nil || aws.StringValue(eni.Attachment.Status) != ec2.AttachmentStatusAttaching {
+		return
+	}
+	log.Warnf("ENI %s is still attaching after %v, forcing the detachment", eniID, cache.eniAttachTimeout)
+	if err := cache.forceDetachENI(ctx, eniID, eni.Attachment.AttachmentId); err == nil &&
+		cache.waitForENIAvailable(ctx, eniID, defaultENIDetachTimeout) {
+		if err := cache.deleteENI(ctx, eniID, maxENIBackoffDelay); err == nil {
+			eniStuckOperations.WithLabelValues("attach", "force_detached").Inc()
+			return
+		}
+	}
+	eniStuckOperations.WithLabelValues("attach", "abandoned").Inc()
+	cache.abandonStuckENI(ctx, eniID)
+}
@@ -0,0 +1,131 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package awsutils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	mock_ec2wrapper "github.com/aws/amazon-vpc-cni-k8s/pkg/ec2wrapper/mocks"
+)
+
+// expectENIStatus makes EC2 describe the ENI as attached with the attachment status until it is force detached, and
+// as available after that
+func expectENIStatus(mockEC2 *mock_ec2wrapper.MockEC2, attachmentStatus string, forceDetachErr error) {
+	forceDetached := false
+	mockEC2.EXPECT().DescribeNetworkInterfacesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(_ context.Context, _ *ec2.DescribeNetworkInterfacesInput, _ ...interface{}) (*ec2.DescribeNetworkInterfacesOutput, error) {
+			eni := &ec2.NetworkInterface{
+				NetworkInterfaceId: aws.String(eni2ID),
+				Description:        aws.String(eniDescriptionPrefix + instanceID),
+				Status:             aws.String(ec2.NetworkInterfaceStatusInUse),
+				Attachment: &ec2.NetworkInterfaceAttachment{
+					AttachmentId: aws.String(eniAttachID),
+					Status:       aws.String(attachmentStatus),
+				},
+			}
+			if forceDetached {
+				eni.Status = aws.String(ec2.NetworkInterfaceStatusAvailable)
+				eni.Attachment = nil
+			}
+			return &ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: []*ec2.NetworkInterface{eni}}, nil
+		})
+	mockEC2.EXPECT().DetachNetworkInterfaceWithContext(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(_ context.Context, input *ec2.DetachNetworkInterfaceInput, _ ...interface{}) (*ec2.DetachNetworkInterfaceOutput, error) {
+			if !aws.BoolValue(input.Force) {
+				return &ec2.DetachNetworkInterfaceOutput{}, nil
+			}
+			forceDetached = forceDetachErr == nil
+			return &ec2.DetachNetworkInterfaceOutput{}, forceDetachErr
+		})
+}
+
+func TestFreeENIForceDetachesStuckENI(t *testing.T) {
+	ctrl, mockEC2 := setup(t)
+	defer ctrl.Finish()
+
+	expectENIStatus(mockEC2, ec2.AttachmentStatusDetaching, nil)
+	mockEC2.EXPECT().DeleteNetworkInterfaceWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil)
+
+	ins := &EC2InstanceMetadataCache{ec2SVC: mockEC2, eniDetachTimeout: 20 * time.Millisecond}
+	err := ins.freeENI(context.Background(), eni2ID, time.Millisecond, time.Millisecond)
+	assert.NoError(t, err)
+}
+
+func TestFreeENIAbandonsStuckENI(t *testing.T) {
+	ctrl, mockEC2 := setup(t)
+	defer ctrl.Finish()
+
+	expectENIStatus(mockEC2, ec2.AttachmentStatusDetaching, errors.New("force detach failed"))
+	mockEC2.EXPECT().CreateTagsWithContext(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, input *ec2.CreateTagsInput, _ ...interface{}) (*ec2.CreateTagsOutput, error) {
+			assert.Equal(t, eniStuckTagKey, aws.StringValue(input.Tags[0].Key))
+			return &ec2.CreateTagsOutput{}, nil
+		})
+
+	ins := &EC2InstanceMetadataCache{ec2SVC: mockEC2, eniDetachTimeout: 20 * time.Millisecond}
+	err := ins.freeENI(context.Background(), eni2ID, time.Millisecond, time.Millisecond)
+	assert.True(t, errors.Is(err, ErrENIStuck))
+}
+
+func TestRecoverStuckAttachment(t *testing.T) {
+	ctrl, mockEC2 := setup(t)
+	defer ctrl.Finish()
+
+	expectENIStatus(mockEC2, ec2.AttachmentStatusAttaching, nil)
+	mockEC2.EXPECT().DeleteNetworkInterfaceWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil)
+
+	ins := &EC2InstanceMetadataCache{ec2SVC: mockEC2, eniAttachTimeout: 20 * time.Millisecond}
+	ins.recoverStuckAttachment(context.Background(), eni2ID)
+}
+
+func TestRecoverStuckAttachmentKeepsAttachedENI(t *testing.T) {
+	ctrl, mockEC2 := setup(t)
+	defer ctrl.Finish()
+
+	// No detach nor delete is expected
+	mockEC2.EXPECT().DescribeNetworkInterfacesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(
+		&ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: []*ec2.NetworkInterface{{
+			NetworkInterfaceId: aws.String(eni2ID),
+			Attachment:         &ec2.NetworkInterfaceAttachment{Status: aws.String(ec2.AttachmentStatusAttached)},
+		}}}, nil)
+
+	ins := &EC2InstanceMetadataCache{ec2SVC: mockEC2, eniAttachTimeout: 20 * time.Millisecond}
+	ins.recoverStuckAttachment(context.Background(), eni2ID)
+}
+
+func TestGetENIOperationTimeout(t *testing.T) {
+	for _, tt := range []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", defaultENIDetachTimeout},
+		{"0", 0},
+		{"30", 30 * time.Second},
+		{"-1", defaultENIDetachTimeout},
+		{"soon", defaultENIDetachTimeout},
+	} {
+		if tt.value != "" {
+			t.Setenv(envENIDetachTimeout, tt.value)
+		}
+		assert.Equal(t, tt.want, getENIOperationTimeout(envENIDetachTimeout, defaultENIDetachTimeout), tt.value)
+	}
+}
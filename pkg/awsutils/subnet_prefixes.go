@@ -0,0 +1,335 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package awsutils
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+)
+
+// ipv4PrefixSize is the number of addresses in the /28 prefixes assigned to ENIs with prefix delegation
+const ipv4PrefixSize = 16
+
+// SubnetPrefixAvailability estimates how many /28 prefixes EC2 can still assign from a subnet. A subnet can have free
+// IPs but no free prefix when its secondary IPs are spread over all the /28 blocks.
+type SubnetPrefixAvailability struct {
+	SubnetID   string
+	SubnetCIDR string
+	// AvailableIPs is the number of free IP addresses EC2 reports for the subnet
+	AvailableIPs int
+	// FreePrefixes is the number of /28 blocks of the subnet without any address in use, nor in an explicit CIDR
+	// reservation
+	FreePrefixes int
+	// ReservedFreePrefixes is the number of free prefixes in the prefix CIDR reservations of the subnet
+	ReservedFreePrefixes int
+	// ReservationsChecked is false if the CIDR reservations of the subnet could not be described, then addresses in
+	// explicit reservations are counted as free
+	ReservationsChecked bool
+}
+
+// GetSubnetPrefixAvailability estimates the /28 prefixes that can still be assigned from subnetID, the primary ENI's
+// subnet if empty, from the addresses and prefixes of all the ENIs in the subnet and its CIDR reservations
+func (cache *EC2InstanceMetadataCache) GetSubnetPrefixAvailability(ctx context.Context, subnetID string) (*SubnetPrefixAvailability, error) {
+	if subnetID == "" {
+		subnetID = cache.subnetID
+	}
+	start := time.Now()
+	subnets, err := cache.ec2SVC.DescribeSubnetsWithContext(ctx, &ec2.DescribeSubnetsInput{SubnetIds: []*string{aws.String(subnetID)}})
+	awsAPILatency.WithLabelValues("DescribeSubnets", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
+	if err != nil {
+		awsAPIErrInc("DescribeSubnets", err)
+		return nil, errors.Wrapf(err, "failed to describe subnet %s", subnetID)
+	}
+	if len(subnets.Subnets) != 1 {
+		return nil, errors.Errorf("subnet %s not found", subnetID)
+	}
+	subnet := subnets.Subnets[0]
+	_, subnetCIDR, err := net.ParseCIDR(aws.StringValue(subnet.CidrBlock))
+	if err != nil || subnetCIDR.IP.To4() == nil {
+		return nil, errors.Errorf("invalid IPv4 CIDR %q of subnet %s", aws.StringValue(subnet.CidrBlock), subnetID)
+	}
+	blocks := newPrefixBlocks(subnetCIDR)
+
+	input := &ec2.DescribeNetworkInterfacesInput{
+		Filters: []*ec2.Filter{{Name: aws.String("subnet-id"), Values: []*string{aws.String(subnetID)}}},
+	}
+	err = cache.getENIsFromPaginatedDescribeNetworkInterfaces(ctx, input, func(eni *ec2.NetworkInterface) error {
+		for _, addr := range eni.PrivateIpAddresses {
+			blocks.markUsed(aws.StringValue(addr.PrivateIpAddress) + "/32")
+		}
+		for _, prefix := range eni.Ipv4Prefixes {
+			blocks.markUsed(aws.StringValue(prefix.Ipv4Prefix))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe the ENIs of subnet %s", subnetID)
+	}
+
+	availability := &SubnetPrefixAvailability{
+		SubnetID:     subnetID,
+		SubnetCIDR:   subnetCIDR.String(),
+		AvailableIPs: int(aws.Int64Value(subnet.AvailableIpAddressCount)),
+	}
+	reservations, err := cache.getSubnetCidrReservations(ctx, subnetID, nil)
+	if err != nil {
+		log.Debugf("Unable to get the CIDR reservations of subnet %s, counting them as free: %v", subnetID, err)
+	} else {
+		availability.ReservationsChecked = true
+		for _, reservation := range reservations {
+			if aws.StringValue(reservation.ReservationType) == ec2.SubnetCidrReservationTypePrefix {
+				blocks.markReserved(aws.StringValue(reservation.Cidr))
+			} else {
+				blocks.markUsed(aws.StringValue(reservation.Cidr))
+			}
+		}
+	}
+	availability.FreePrefixes, availability.ReservedFreePrefixes = blocks.free()
+	return availability, nil
+}
+
+// AllocIPv4PrefixesFromReservations assigns numPrefixes /28 prefixes to an ENI, picked from the free blocks of the prefix
+// CIDR reservations of its subnet that have the tag tagKey, with the value tagValue unless it is empty. It fails with
+// an InsufficientCidrBlocks error when these reservations have no free block left.
+func (cache *EC2InstanceMetadataCache) AllocIPv4PrefixesFromReservations(ctx context.Context, eniID string, numPrefixes int, tagKey, tagValue string) (*ec2.AssignPrivateIpAddressesOutput, error) {
+	if numPrefixes < 1 {
+		return nil, nil
+	}
+	start := time.Now()
+	enis, err := cache.ec2SVC.DescribeNetworkInterfacesWithContext(ctx, &ec2.DescribeNetworkInterfacesInput{NetworkInterfaceIds: []*string{aws.String(eniID)}})
+	awsAPILatency.WithLabelValues("DescribeNetworkInterfaces", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
+	if err != nil {
+		awsAPIErrInc("DescribeNetworkInterfaces", err)
+		return nil, errors.Wrapf(err, "failed to describe ENI %s", eniID)
+	}
+	if len(enis.NetworkInterfaces) != 1 {
+		return nil, errors.Errorf("ENI %s not found", eniID)
+	}
+	subnetID := aws.StringValue(enis.NetworkInterfaces[0].SubnetId)
+
+	filters := []*ec2.Filter{{Name: aws.String("reservationType"), Values: aws.StringSlice([]string{ec2.SubnetCidrReservationTypePrefix})}}
+	if tagValue != "" {
+		filters = append(filters, &ec2.Filter{Name: aws.String("tag:" + tagKey), Values: aws.StringSlice([]string{tagValue})})
+	} else {
+		filters = append(filters, &ec2.Filter{Name: aws.String("tag-key"), Values: aws.StringSlice([]string{tagKey})})
+	}
+	reservations, err := cache.getSubnetCidrReservations(ctx, subnetID, filters)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get the CIDR reservations of subnet %s", subnetID)
+	}
+	if len(reservations) == 0 {
+		return nil, awserr.New("InsufficientCidrBlocks",
+			fmt.Sprintf("subnet %s has no prefix CIDR reservation with the tag %s", subnetID, tagKey), nil)
+	}
+
+	// Only the reserved blocks matter, and their addresses are all in the subnet
+	blocks := newPrefixBlocksFromReservations(reservations)
+	input := &ec2.DescribeNetworkInterfacesInput{
+		Filters: []*ec2.Filter{{Name: aws.String("subnet-id"), Values: []*string{aws.String(subnetID)}}},
+	}
+	err = cache.getENIsFromPaginatedDescribeNetworkInterfaces(ctx, input, func(eni *ec2.NetworkInterface) error {
+		for _, addr := range eni.PrivateIpAddresses {
+			blocks.markUsed(aws.StringValue(addr.PrivateIpAddress) + "/32")
+		}
+		for _, prefix := range eni.Ipv4Prefixes {
+			blocks.markUsed(aws.StringValue(prefix.Ipv4Prefix))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe the ENIs of subnet %s", subnetID)
+	}
+	prefixes := blocks.freeReserved(numPrefixes)
+	if len(prefixes) == 0 {
+		return nil, awserr.New("InsufficientCidrBlocks",
+			fmt.Sprintf("the prefix CIDR reservations of subnet %s with the tag %s have no free /28 block", subnetID, tagKey), nil)
+	}
+
+	log.Infof("Trying to assign the reserved prefixes %v to ENI %s", prefixes, eniID)
+	start = time.Now()
+	output, err := cache.ec2SVC.AssignPrivateIpAddressesWithContext(ctx, &ec2.AssignPrivateIpAddressesInput{
+		NetworkInterfaceId: aws.String(eniID),
+		Ipv4Prefixes:       aws.StringSlice(prefixes),
+	})
+	awsAPILatency.WithLabelValues("AssignPrivateIpAddresses", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
+	if err != nil {
+		CheckAPIErrorAndBroadcastEvent(err, "ec2:AssignPrivateIpAddresses")
+		log.Errorf("Failed to assign the reserved prefixes %v to ENI %s: %v", prefixes, eniID, err)
+		awsAPIErrInc("AssignPrivateIpAddresses", err)
+		return nil, err
+	}
+	log.Infof("Allocated %d reserved private IP prefixes", len(output.AssignedIpv4Prefixes))
+	return output, nil
+}
+
+// getSubnetCidrReservations returns the IPv4 CIDR reservations of a subnet that match filters
+func (cache *EC2InstanceMetadataCache) getSubnetCidrReservations(ctx context.Context, subnetID string, filters []*ec2.Filter) ([]*ec2.SubnetCidrReservation, error) {
+	var reservations []*ec2.SubnetCidrReservation
+	input := &ec2.GetSubnetCidrReservationsInput{SubnetId: aws.String(subnetID), Filters: filters}
+	for {
+		start := time.Now()
+		output, err := cache.ec2SVC.GetSubnetCidrReservationsWithContext(ctx, input)
+		awsAPILatency.WithLabelValues("GetSubnetCidrReservations", fmt.Sprint(err != nil), awsReqStatus(err)).Observe(msSince(start))
+		if err != nil {
+			awsAPIErrInc("GetSubnetCidrReservations", err)
+			return nil, err
+		}
+		reservations = append(reservations, output.SubnetIpv4CidrReservations...)
+		if aws.StringValue(output.NextToken) == "" {
+			return reservations, nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
+
+// prefixBlocks tracks which of the aligned /28 blocks of a subnet are used, and which are in a prefix reservation
+type prefixBlocks struct {
+	base     uint32
+	used     []bool
+	reserved []bool
+}
+
+// newPrefixBlocks returns the /28 blocks of subnet, with the first four and the last addresses that EC2 reserves
+// marked as used
+func newPrefixBlocks(subnet *net.IPNet) *prefixBlocks {
+	ones, bits := subnet.Mask.Size()
+	count := 0
+	if size := 1 << uint(bits-ones); size >= ipv4PrefixSize {
+		count = size / ipv4PrefixSize
+	}
+	blocks := &prefixBlocks{
+		base:     binary.BigEndian.Uint32(subnet.IP.To4()),
+		used:     make([]bool, count),
+		reserved: make([]bool, count),
+	}
+	if count > 0 {
+		blocks.used[0] = true
+		blocks.used[count-1] = true
+	}
+	return blocks
+}
+
+// newPrefixBlocksFromReservations returns the /28 blocks from the first to the last address of reservations, with the
+// blocks fully in one of them marked as reserved
+func newPrefixBlocksFromReservations(reservations []*ec2.SubnetCidrReservation) *prefixBlocks {
+	var first, last uint32
+	for i, reservation := range reservations {
+		from, to, ok := cidrRange(aws.StringValue(reservation.Cidr))
+		if !ok {
+			continue
+		}
+		if i == 0 || from < first {
+			first = from
+		}
+		if i == 0 || to > last {
+			last = to
+		}
+	}
+	first -= first % ipv4PrefixSize
+	count := int((uint64(last)-uint64(first))/ipv4PrefixSize) + 1
+	blocks := &prefixBlocks{base: first, used: make([]bool, count), reserved: make([]bool, count)}
+	for _, reservation := range reservations {
+		blocks.markReserved(aws.StringValue(reservation.Cidr))
+	}
+	return blocks
+}
+
+// cidrRange returns the first and last addresses of an IPv4 CIDR
+func cidrRange(cidr string) (uint32, uint32, bool) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil || ipNet.IP.To4() == nil {
+		return 0, 0, false
+	}
+	ones, bits := ipNet.Mask.Size()
+	first := binary.BigEndian.Uint32(ipNet.IP.To4())
+	return first, first + uint32(1<<uint(bits-ones)) - 1, true
+}
+
+// blockRange returns the indexes of the first and last blocks that cidr overlaps, and false if it doesn't overlap any
+func (b *prefixBlocks) blockRange(cidr string) (int, int, bool) {
+	first, last, ok := cidrRange(cidr)
+	if !ok {
+		return 0, 0, false
+	}
+	end := b.base + uint32(len(b.used)*ipv4PrefixSize) - 1
+	if len(b.used) == 0 || last < b.base || first > end {
+		return 0, 0, false
+	}
+	if first < b.base {
+		first = b.base
+	}
+	if last > end {
+		last = end
+	}
+	return int((first - b.base) / ipv4PrefixSize), int((last - b.base) / ipv4PrefixSize), true
+}
+
+func (b *prefixBlocks) markUsed(cidr string) {
+	if from, to, ok := b.blockRange(cidr); ok {
+		for i := from; i <= to; i++ {
+			b.used[i] = true
+		}
+	}
+}
+
+// markReserved marks the blocks fully in cidr as reserved
+func (b *prefixBlocks) markReserved(cidr string) {
+	first, last, ok := cidrRange(cidr)
+	if !ok {
+		return
+	}
+	for i := range b.reserved {
+		start := b.base + uint32(i*ipv4PrefixSize)
+		if start >= first && start+ipv4PrefixSize-1 <= last {
+			b.reserved[i] = true
+		}
+	}
+}
+
+// freeReserved returns up to n unused blocks in a prefix reservation, as CIDRs
+func (b *prefixBlocks) freeReserved(n int) []string {
+	var prefixes []string
+	for i, used := range b.used {
+		if len(prefixes) == n {
+			break
+		}
+		if !used && b.reserved[i] {
+			ip := make(net.IP, net.IPv4len)
+			binary.BigEndian.PutUint32(ip, b.base+uint32(i*ipv4PrefixSize))
+			prefixes = append(prefixes, fmt.Sprintf("%s/28", ip))
+		}
+	}
+	return prefixes
+}
+
+// free returns the number of unused blocks, and how many of them are in a prefix reservation
+func (b *prefixBlocks) free() (free int, reserved int) {
+	for i, used := range b.used {
+		if !used {
+			free++
+			if b.reserved[i] {
+				reserved++
+			}
+		}
+	}
+	return free, reserved
+}
@@ -0,0 +1,197 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package awsutils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSubnetPrefixAvailability(t *testing.T) {
+	tests := []struct {
+		name            string
+		reservations    []*ec2.SubnetCidrReservation
+		reservationsErr error
+		want            SubnetPrefixAvailability
+	}{
+		{
+			name: "no reservation",
+			want: SubnetPrefixAvailability{FreePrefixes: 1, ReservationsChecked: true},
+		},
+		{
+			name: "explicit reservation",
+			reservations: []*ec2.SubnetCidrReservation{
+				{Cidr: aws.String("10.0.0.40/29"), ReservationType: aws.String(ec2.SubnetCidrReservationTypeExplicit)},
+			},
+			want: SubnetPrefixAvailability{FreePrefixes: 0, ReservationsChecked: true},
+		},
+		{
+			name: "prefix reservation",
+			reservations: []*ec2.SubnetCidrReservation{
+				{Cidr: aws.String("10.0.0.32/27"), ReservationType: aws.String(ec2.SubnetCidrReservationTypePrefix)},
+			},
+			want: SubnetPrefixAvailability{FreePrefixes: 1, ReservedFreePrefixes: 1, ReservationsChecked: true},
+		},
+		{
+			name:            "reservations not readable",
+			reservationsErr: errors.New("UnauthorizedOperation"),
+			want:            SubnetPrefixAvailability{FreePrefixes: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl, mockEC2 := setup(t)
+			defer ctrl.Finish()
+
+			// The /26 has four /28 blocks: EC2 reserves addresses in the first and the last, and an ENI uses the second
+			mockEC2.EXPECT().DescribeSubnetsWithContext(gomock.Any(), &ec2.DescribeSubnetsInput{SubnetIds: []*string{aws.String(subnetID)}}).
+				Return(&ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{{
+					SubnetId: aws.String(subnetID), CidrBlock: aws.String("10.0.0.0/26"), AvailableIpAddressCount: aws.Int64(56),
+				}}}, nil)
+			setupDescribeNetworkInterfacesPagesWithContextMock(t, mockEC2, []*ec2.NetworkInterface{{
+				PrivateIpAddresses: []*ec2.NetworkInterfacePrivateIpAddress{{PrivateIpAddress: aws.String("10.0.0.20")}},
+			}}, nil, 1)
+			mockEC2.EXPECT().GetSubnetCidrReservationsWithContext(gomock.Any(), &ec2.GetSubnetCidrReservationsInput{SubnetId: aws.String(subnetID)}).
+				Return(&ec2.GetSubnetCidrReservationsOutput{SubnetIpv4CidrReservations: tt.reservations}, tt.reservationsErr)
+
+			cache := &EC2InstanceMetadataCache{ec2SVC: mockEC2, subnetID: subnetID}
+			availability, err := cache.GetSubnetPrefixAvailability(context.Background(), "")
+			assert.NoError(t, err)
+			tt.want.SubnetID = subnetID
+			tt.want.SubnetCIDR = "10.0.0.0/26"
+			tt.want.AvailableIPs = 56
+			assert.Equal(t, tt.want, *availability)
+		})
+	}
+}
+
+func TestAllocIPv4PrefixesFromReservations(t *testing.T) {
+	tests := []struct {
+		name         string
+		tagValue     string
+		reservations []*ec2.SubnetCidrReservation
+		want         []string
+		wantErr      bool
+	}{
+		{
+			name:     "free reserved blocks",
+			tagValue: "pods",
+			reservations: []*ec2.SubnetCidrReservation{
+				{Cidr: aws.String("10.0.0.64/26"), ReservationType: aws.String(ec2.SubnetCidrReservationTypePrefix)},
+			},
+			want: []string{"10.0.0.64/28", "10.0.0.96/28"},
+		},
+		{
+			name: "any tag value",
+			reservations: []*ec2.SubnetCidrReservation{
+				{Cidr: aws.String("10.0.0.80/28"), ReservationType: aws.String(ec2.SubnetCidrReservationTypePrefix)},
+				{Cidr: aws.String("10.0.0.128/28"), ReservationType: aws.String(ec2.SubnetCidrReservationTypePrefix)},
+			},
+			want: []string{"10.0.0.128/28"},
+		},
+		{
+			name:    "no reservation",
+			wantErr: true,
+		},
+		{
+			name: "reservation full",
+			reservations: []*ec2.SubnetCidrReservation{
+				{Cidr: aws.String("10.0.0.80/28"), ReservationType: aws.String(ec2.SubnetCidrReservationTypePrefix)},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl, mockEC2 := setup(t)
+			defer ctrl.Finish()
+
+			mockEC2.EXPECT().DescribeNetworkInterfacesWithContext(gomock.Any(), &ec2.DescribeNetworkInterfacesInput{NetworkInterfaceIds: []*string{aws.String(eniID)}}).
+				Return(&ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: []*ec2.NetworkInterface{{SubnetId: aws.String(subnetID)}}}, nil)
+			tagFilter := &ec2.Filter{Name: aws.String("tag-key"), Values: aws.StringSlice([]string{"cni"})}
+			if tt.tagValue != "" {
+				tagFilter = &ec2.Filter{Name: aws.String("tag:cni"), Values: aws.StringSlice([]string{tt.tagValue})}
+			}
+			mockEC2.EXPECT().GetSubnetCidrReservationsWithContext(gomock.Any(), &ec2.GetSubnetCidrReservationsInput{
+				SubnetId: aws.String(subnetID),
+				Filters: []*ec2.Filter{
+					{Name: aws.String("reservationType"), Values: aws.StringSlice([]string{ec2.SubnetCidrReservationTypePrefix})},
+					tagFilter,
+				},
+			}).Return(&ec2.GetSubnetCidrReservationsOutput{SubnetIpv4CidrReservations: tt.reservations}, nil)
+			if len(tt.reservations) > 0 {
+				// Another node already has 10.0.0.80/28, and a secondary IP of the subnet is in 10.0.0.112/28
+				setupDescribeNetworkInterfacesPagesWithContextMock(t, mockEC2, []*ec2.NetworkInterface{{
+					PrivateIpAddresses: []*ec2.NetworkInterfacePrivateIpAddress{{PrivateIpAddress: aws.String("10.0.0.120")}},
+					Ipv4Prefixes:       []*ec2.Ipv4PrefixSpecification{{Ipv4Prefix: aws.String("10.0.0.80/28")}},
+				}}, nil, 1)
+			}
+			if tt.want != nil {
+				var assigned []*ec2.Ipv4PrefixSpecification
+				for _, prefix := range tt.want {
+					assigned = append(assigned, &ec2.Ipv4PrefixSpecification{Ipv4Prefix: aws.String(prefix)})
+				}
+				mockEC2.EXPECT().AssignPrivateIpAddressesWithContext(gomock.Any(), &ec2.AssignPrivateIpAddressesInput{
+					NetworkInterfaceId: aws.String(eniID),
+					Ipv4Prefixes:       aws.StringSlice(tt.want),
+				}).Return(&ec2.AssignPrivateIpAddressesOutput{AssignedIpv4Prefixes: assigned}, nil)
+			}
+
+			cache := &EC2InstanceMetadataCache{ec2SVC: mockEC2}
+			output, err := cache.AllocIPv4PrefixesFromReservations(context.Background(), eniID, 2, "cni", tt.tagValue)
+			if tt.wantErr {
+				var awsErr awserr.Error
+				assert.ErrorAs(t, err, &awsErr)
+				assert.Equal(t, "InsufficientCidrBlocks", awsErr.Code())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, output.AssignedIpv4Prefixes, len(tt.want))
+		})
+	}
+}
+
+func TestPrefixBlocks(t *testing.T) {
+	subnet := &net.IPNet{IP: net.ParseIP("10.0.0.0").To4(), Mask: net.CIDRMask(24, 32)}
+	blocks := newPrefixBlocks(subnet)
+	free, _ := blocks.free()
+	assert.Equal(t, 14, free)
+
+	// Prefixes and addresses outside of the subnet are ignored
+	blocks.markUsed("10.0.1.16/28")
+	blocks.markUsed("10.0.0.48/28")
+	blocks.markUsed("10.0.0.100/32")
+	blocks.markReserved("10.0.0.128/25")
+	free, reserved := blocks.free()
+	assert.Equal(t, 12, free)
+	assert.Equal(t, 7, reserved)
+	assert.Equal(t, []string{"10.0.0.128/28", "10.0.0.144/28"}, blocks.freeReserved(2))
+
+	// Only the blocks fully in a reservation are reserved
+	blocks.markReserved("10.0.0.72/29")
+	_, reserved = blocks.free()
+	assert.Equal(t, 7, reserved)
+
+	// A subnet smaller than a /28 has no prefix at all
+	free, _ = newPrefixBlocks(&net.IPNet{IP: net.ParseIP("10.0.0.0").To4(), Mask: net.CIDRMask(29, 32)}).free()
+	assert.Equal(t, 0, free)
+}
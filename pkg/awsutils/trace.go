@@ -0,0 +1,44 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package awsutils
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+type traceIDKey struct{}
+
+// WithTraceID returns a copy of ctx whose EC2 calls carry the trace ID of the CNI request they are made for
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+// TraceIDFromContext returns the trace ID set on ctx by WithTraceID, or an empty string
+func TraceIDFromContext(ctx context.Context) string {
+	traceID, _ := ctx.Value(traceIDKey{}).(string)
+	return traceID
+}
+
+// addTraceIDToUserAgent appends the trace ID of the request context to the user agent, which CloudTrail records, and
+// logs the call, so that the EC2 calls made for a pod can be found from its trace ID
+func addTraceIDToUserAgent(r *request.Request) {
+	traceID := TraceIDFromContext(r.Context())
+	if traceID == "" {
+		return
+	}
+	log.Debugf("Calling EC2 %s, trace ID %s", r.Operation.Name, traceID)
+	request.AddToUserAgent(r, "trace/"+traceID)
+}
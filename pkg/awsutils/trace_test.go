@@ -0,0 +1,41 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package awsutils
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddTraceIDToUserAgent(t *testing.T) {
+	newRequest := func(ctx context.Context) *request.Request {
+		r := &request.Request{Operation: &request.Operation{Name: "AssignPrivateIpAddresses"},
+			HTTPRequest: &http.Request{Header: http.Header{}}}
+		r.SetContext(ctx)
+		r.HTTPRequest.Header.Set("User-Agent", "aws-sdk-go")
+		return r
+	}
+
+	r := newRequest(context.Background())
+	addTraceIDToUserAgent(r)
+	assert.Equal(t, "aws-sdk-go", r.HTTPRequest.Header.Get("User-Agent"))
+
+	r = newRequest(WithTraceID(context.Background(), "0123abcd"))
+	addTraceIDToUserAgent(r)
+	assert.Equal(t, "aws-sdk-go trace/0123abcd", r.HTTPRequest.Header.Get("User-Agent"))
+}
@@ -0,0 +1,126 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cniinstaller
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/paths"
+)
+
+// ConflistConfig holds the settings of the aws-node daemon that are templated into the network configuration
+type ConflistConfig struct {
+	VethPrefix            string
+	MTU                   string
+	PodSGEnforcingMode    string
+	PluginLogFile         string
+	PluginLogLevel        string
+	EgressV4PluginLogFile string
+	EgressV4PluginEnabled string
+	RandomizeSNAT         string
+	NodeIP                string
+	IPAMDSocket           string
+	RouteTableBase        string
+	EnableBandwidthPlugin bool
+	// Overrides are the user additions to the network configuration, if any
+	Overrides *ConflistOverrides
+}
+
+// ConflistConfigFromEnv reads the network configuration settings from the environment of aws-node, with the same
+// defaults as the daemon
+func ConflistConfigFromEnv() ConflistConfig {
+	eniMTU := getEnv("AWS_VPC_ENI_MTU", "9001")
+	return ConflistConfig{
+		VethPrefix:            getEnv("AWS_VPC_K8S_CNI_VETHPREFIX", "eni"),
+		MTU:                   getEnv("POD_MTU", eniMTU),
+		PodSGEnforcingMode:    getEnv("POD_SECURITY_GROUP_ENFORCING_MODE", "strict"),
+		PluginLogFile:         getEnv("AWS_VPC_K8S_PLUGIN_LOG_FILE", "/var/log/aws-routed-eni/plugin.log"),
+		PluginLogLevel:        getEnv("AWS_VPC_K8S_PLUGIN_LOG_LEVEL", "Debug"),
+		EgressV4PluginLogFile: getEnv("AWS_VPC_K8S_EGRESS_V4_PLUGIN_LOG_FILE", "/var/log/aws-routed-eni/egress-v4-plugin.log"),
+		EgressV4PluginEnabled: getEnv("ENABLE_IPv6", "false"),
+		RandomizeSNAT:         getEnv("AWS_VPC_K8S_CNI_RANDOMIZESNAT", "prng"),
+		NodeIP:                os.Getenv("NODE_IP"),
+		IPAMDSocket:           paths.IPAMDSocket(),
+		RouteTableBase:        getEnv("AWS_VPC_K8S_CNI_ROUTE_TABLE_BASE", "1"),
+		EnableBandwidthPlugin: getEnv("ENABLE_BANDWIDTH_PLUGIN", "false") == "true",
+	}
+}
+
+func getEnv(name, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// GenerateConflist fills the placeholders of the network configuration template, appends the optional plugins to its
+// plugin chain and applies the user overrides
+func GenerateConflist(template []byte, cfg ConflistConfig) ([]byte, error) {
+	conflist := strings.NewReplacer(
+		"__VETHPREFIX__", cfg.VethPrefix,
+		"__MTU__", cfg.MTU,
+		"__PODSGENFORCINGMODE__", cfg.PodSGEnforcingMode,
+		"__PLUGINLOGFILE__", cfg.PluginLogFile,
+		"__PLUGINLOGLEVEL__", cfg.PluginLogLevel,
+		"__EGRESSV4PLUGINLOGFILE__", cfg.EgressV4PluginLogFile,
+		"__EGRESSV4PLUGINENABLED__", cfg.EgressV4PluginEnabled,
+		"__RANDOMIZESNAT__", cfg.RandomizeSNAT,
+		"__NODEIP__", cfg.NodeIP,
+		"__IPAMDSOCKET__", cfg.IPAMDSocket,
+		"__ROUTETABLEBASE__", cfg.RouteTableBase,
+	).Replace(string(template))
+
+	var parsed map[string]interface{}
+	if err := json.Unmarshal([]byte(conflist), &parsed); err != nil {
+		return nil, errors.Wrap(err, "the generated network configuration is not valid JSON")
+	}
+	plugins, ok := parsed["plugins"].([]interface{})
+	if !ok {
+		return nil, errors.New("the generated network configuration has no plugin list")
+	}
+	if !cfg.EnableBandwidthPlugin && cfg.Overrides == nil {
+		return []byte(conflist), nil
+	}
+	if cfg.EnableBandwidthPlugin {
+		plugins = append(plugins, map[string]interface{}{
+			"type":         "bandwidth",
+			"capabilities": map[string]interface{}{"bandwidth": true},
+		})
+	}
+	if cfg.Overrides != nil {
+		pluginTypes := make(map[string]bool)
+		for _, plugin := range plugins {
+			if plugin, ok := plugin.(map[string]interface{}); ok {
+				if pluginType, ok := plugin["type"].(string); ok {
+					pluginTypes[pluginType] = true
+				}
+			}
+		}
+		if err := cfg.Overrides.validate(pluginTypes); err != nil {
+			return nil, errors.Wrap(err, "invalid conflist overrides")
+		}
+		for field, value := range cfg.Overrides.Fields {
+			parsed[field] = value
+		}
+		for _, plugin := range cfg.Overrides.Plugins {
+			plugins = append(plugins, plugin)
+		}
+	}
+	parsed["plugins"] = plugins
+	return json.MarshalIndent(parsed, "", "  ")
+}
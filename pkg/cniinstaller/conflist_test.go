@@ -0,0 +1,84 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cniinstaller
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateConflist(t *testing.T) {
+	template, err := ioutil.ReadFile("../../misc/" + ConflistName)
+	assert.NoError(t, err)
+	cfg := ConflistConfig{
+		VethPrefix:            "eni",
+		MTU:                   "1500",
+		PodSGEnforcingMode:    "strict",
+		PluginLogFile:         "/var/log/aws-routed-eni/plugin.log",
+		PluginLogLevel:        "Info",
+		EgressV4PluginLogFile: "/var/log/aws-routed-eni/egress-v4-plugin.log",
+		EgressV4PluginEnabled: "false",
+		RandomizeSNAT:         "prng",
+		NodeIP:                "10.0.0.10",
+		IPAMDSocket:           "/run/aws-node/ipamd.sock",
+		RouteTableBase:        "20",
+	}
+
+	var conflist struct {
+		Plugins []map[string]interface{}
+	}
+	data, err := GenerateConflist(template, cfg)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "__")
+	assert.NoError(t, json.Unmarshal(data, &conflist))
+	assert.Len(t, conflist.Plugins, 3)
+	assert.Equal(t, "1500", conflist.Plugins[0]["mtu"])
+	assert.Equal(t, "Info", conflist.Plugins[0]["pluginLogLevel"])
+	assert.Equal(t, "/run/aws-node/ipamd.sock", conflist.Plugins[0]["ipamdSocket"])
+	assert.Equal(t, "20", conflist.Plugins[0]["routeTableBase"])
+	assert.Equal(t, "10.0.0.10", conflist.Plugins[1]["nodeIP"])
+
+	cfg.EnableBandwidthPlugin = true
+	data, err = GenerateConflist(template, cfg)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(data, &conflist))
+	assert.Len(t, conflist.Plugins, 4)
+	assert.Equal(t, "bandwidth", conflist.Plugins[3]["type"])
+
+	_, err = GenerateConflist([]byte(`{"plugins": [{"vethPrefix": "__VETHPREFIX__"}]`), cfg)
+	assert.Error(t, err)
+}
+
+func TestConflistConfigFromEnv(t *testing.T) {
+	for _, env := range []string{"AWS_VPC_ENI_MTU", "POD_MTU", "ENABLE_BANDWIDTH_PLUGIN"} {
+		defer os.Unsetenv(env)
+		_ = os.Unsetenv(env)
+	}
+	cfg := ConflistConfigFromEnv()
+	assert.Equal(t, "9001", cfg.MTU)
+	assert.Equal(t, "eni", cfg.VethPrefix)
+	assert.False(t, cfg.EnableBandwidthPlugin)
+
+	_ = os.Setenv("AWS_VPC_ENI_MTU", "1500")
+	assert.Equal(t, "1500", ConflistConfigFromEnv().MTU)
+	_ = os.Setenv("POD_MTU", "1400")
+	_ = os.Setenv("ENABLE_BANDWIDTH_PLUGIN", "true")
+	cfg = ConflistConfigFromEnv()
+	assert.Equal(t, "1400", cfg.MTU)
+	assert.True(t, cfg.EnableBandwidthPlugin)
+}
@@ -0,0 +1,127 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package cniinstaller installs the CNI plugin binaries and the CNI network configuration on the host, replacing each
+// file atomically and keeping the replaced version so that the installation can be rolled back.
+package cniinstaller
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// ConflistName is the name of the CNI network configuration file
+const ConflistName = "10-aws.conflist"
+
+// replacedFile is a file overwritten or created by the installer
+type replacedFile struct {
+	path    string
+	existed bool
+	data    []byte
+	mode    os.FileMode
+}
+
+// Installer installs the CNI files in the host directories
+type Installer struct {
+	binDir   string
+	confDir  string
+	replaced []replacedFile
+}
+
+// New returns an Installer writing the binaries to binDir and the network configuration to confDir
+func New(binDir, confDir string) *Installer {
+	return &Installer{binDir: binDir, confDir: confDir}
+}
+
+// InstallBinary copies the executable at src to the binary directory, under the same name
+func (i *Installer) InstallBinary(src string) error {
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %s", src)
+	}
+	return i.install(filepath.Join(i.binDir, filepath.Base(src)), data, 0755)
+}
+
+// InstallConflist writes the network configuration to the configuration directory
+func (i *Installer) InstallConflist(data []byte) error {
+	return i.install(filepath.Join(i.confDir, ConflistName), data, 0644)
+}
+
+func (i *Installer) install(path string, data []byte, mode os.FileMode) error {
+	previous := replacedFile{path: path}
+	info, err := os.Stat(path)
+	switch {
+	case err == nil:
+		previous.existed = true
+		previous.mode = info.Mode().Perm()
+		if previous.data, err = ioutil.ReadFile(path); err != nil {
+			return errors.Wrapf(err, "failed to read the installed %s", path)
+		}
+	case !os.IsNotExist(err):
+		return errors.Wrapf(err, "failed to stat %s", path)
+	}
+	if err := writeFileAtomic(path, data, mode); err != nil {
+		return err
+	}
+	i.replaced = append(i.replaced, previous)
+	return nil
+}
+
+// Rollback restores the files replaced by the installer, in reverse order, and removes the files it created
+func (i *Installer) Rollback() error {
+	var rollbackErr error
+	for n := len(i.replaced) - 1; n >= 0; n-- {
+		previous := i.replaced[n]
+		var err error
+		if previous.existed {
+			err = writeFileAtomic(previous.path, previous.data, previous.mode)
+		} else if err = os.Remove(previous.path); os.IsNotExist(err) {
+			err = nil
+		}
+		if err != nil && rollbackErr == nil {
+			rollbackErr = errors.Wrapf(err, "failed to roll back %s", previous.path)
+		}
+	}
+	i.replaced = nil
+	return rollbackErr
+}
+
+// writeFileAtomic writes data to a temporary file in the directory of path and renames it to path, so that a reader,
+// like kubelet or the container runtime executing a plugin, never sees a partially written file
+func writeFileAtomic(path string, data []byte, mode os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return errors.Wrapf(err, "failed to create a temporary file for %s", path)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(mode)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to write %s", tmp.Name())
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return errors.Wrapf(err, "failed to rename %s to %s", tmp.Name(), path)
+	}
+	return nil
+}
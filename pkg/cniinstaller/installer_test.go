@@ -0,0 +1,62 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cniinstaller
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstallAndRollback(t *testing.T) {
+	srcDir, binDir, confDir := t.TempDir(), t.TempDir(), t.TempDir()
+	for name, data := range map[string]string{"aws-cni": "new aws-cni", "egress-v4-cni": "new egress-v4-cni"} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(srcDir, name), []byte(data), 0644))
+	}
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(binDir, "aws-cni"), []byte("old aws-cni"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(confDir, ConflistName), []byte("old conflist"), 0644))
+
+	installer := New(binDir, confDir)
+	assert.NoError(t, installer.InstallBinary(filepath.Join(srcDir, "aws-cni")))
+	assert.NoError(t, installer.InstallBinary(filepath.Join(srcDir, "egress-v4-cni")))
+	assert.NoError(t, installer.InstallConflist([]byte("new conflist")))
+	assert.Error(t, installer.InstallBinary(filepath.Join(srcDir, "missing")))
+
+	assertFile(t, filepath.Join(binDir, "aws-cni"), "new aws-cni", 0755)
+	assertFile(t, filepath.Join(binDir, "egress-v4-cni"), "new egress-v4-cni", 0755)
+	assertFile(t, filepath.Join(confDir, ConflistName), "new conflist", 0644)
+
+	assert.NoError(t, installer.Rollback())
+	assertFile(t, filepath.Join(binDir, "aws-cni"), "old aws-cni", 0755)
+	assertFile(t, filepath.Join(confDir, ConflistName), "old conflist", 0644)
+	_, err := os.Stat(filepath.Join(binDir, "egress-v4-cni"))
+	assert.True(t, os.IsNotExist(err))
+
+	// No temporary file is left behind
+	files, err := ioutil.ReadDir(binDir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func assertFile(t *testing.T, path, data string, mode os.FileMode) {
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, data, string(content))
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, mode, info.Mode().Perm())
+}
@@ -0,0 +1,113 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cniinstaller
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// envConflistOverridesFile is the path of an optional YAML or JSON file, usually mounted from a ConfigMap, with
+	// the fields and the chained plugins added to the network configuration
+	envConflistOverridesFile     = "AWS_VPC_CNI_CONFLIST_OVERRIDES_FILE"
+	defaultConflistOverridesFile = "/etc/amazon-vpc-cni/conflist-overrides.yaml"
+)
+
+// reservedConflistFields can't be overridden: ipamd matches the network name when it garbage collects pod IPs, and the
+// plugin chain is only appended to
+var reservedConflistFields = map[string]bool{"name": true, "plugins": true}
+
+// ConflistOverrides are the user additions to the network configuration: top-level fields set on the conflist, and
+// plugins chained after the built-in ones
+type ConflistOverrides struct {
+	Fields  map[string]interface{}   `json:"fields,omitempty"`
+	Plugins []map[string]interface{} `json:"plugins,omitempty"`
+}
+
+// LoadConflistOverrides reads the overrides file. It returns nil if there is no file.
+func LoadConflistOverrides() (*ConflistOverrides, error) {
+	path := getEnv(envConflistOverridesFile, defaultConflistOverridesFile)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to read the conflist overrides %s", path)
+	}
+	var overrides ConflistOverrides
+	if err := yaml.UnmarshalStrict(data, &overrides); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the conflist overrides %s", path)
+	}
+	return &overrides, nil
+}
+
+// validate checks the overrides against the CNI network configuration schema. pluginTypes are the types of the plugins
+// already in the chain, which can't be added twice.
+func (o *ConflistOverrides) validate(pluginTypes map[string]bool) error {
+	for field, value := range o.Fields {
+		if reservedConflistFields[field] {
+			return errors.Errorf("field %q can't be overridden", field)
+		}
+		if err := validateFieldType(field, field, value); err != nil {
+			return err
+		}
+	}
+	for n, plugin := range o.Plugins {
+		pluginType, ok := plugin["type"].(string)
+		if !ok || pluginType == "" {
+			return errors.Errorf("plugin %d has no type", n)
+		}
+		if pluginTypes[pluginType] {
+			return errors.Errorf("plugin %q is already in the plugin chain", pluginType)
+		}
+		pluginTypes[pluginType] = true
+		for field, value := range plugin {
+			if err := validateFieldType(fmt.Sprintf("%s.%s", pluginType, field), field, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateFieldType checks the type of the fields defined by the CNI specification. path names the field in errors.
+func validateFieldType(path, field string, value interface{}) error {
+	var ok bool
+	switch field {
+	case "cniVersion", "name", "type":
+		_, ok = value.(string)
+	case "disableCheck":
+		_, ok = value.(bool)
+	case "capabilities":
+		var capabilities map[string]interface{}
+		if capabilities, ok = value.(map[string]interface{}); ok {
+			for _, enabled := range capabilities {
+				if _, ok = enabled.(bool); !ok {
+					break
+				}
+			}
+		}
+	default:
+		ok = true
+	}
+	if !ok {
+		return errors.Errorf("field %q has an invalid value %v", path, value)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cniinstaller
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConflistOverrides(t *testing.T) {
+	defer os.Unsetenv(envConflistOverridesFile)
+	path := filepath.Join(t.TempDir(), "conflist-overrides.yaml")
+	_ = os.Setenv(envConflistOverridesFile, path)
+
+	overrides, err := LoadConflistOverrides()
+	assert.NoError(t, err)
+	assert.Nil(t, overrides)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`
+fields:
+  cniVersion: 0.3.1
+plugins:
+- type: firewall
+  backend: iptables
+`), 0644))
+	overrides, err = LoadConflistOverrides()
+	assert.NoError(t, err)
+	assert.Equal(t, &ConflistOverrides{
+		Fields:  map[string]interface{}{"cniVersion": "0.3.1"},
+		Plugins: []map[string]interface{}{{"type": "firewall", "backend": "iptables"}},
+	}, overrides)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("plugin:\n- type: firewall\n"), 0644))
+	_, err = LoadConflistOverrides()
+	assert.Error(t, err)
+}
+
+func TestGenerateConflistWithOverrides(t *testing.T) {
+	template, err := ioutil.ReadFile("../../misc/" + ConflistName)
+	assert.NoError(t, err)
+	cfg := ConflistConfig{EnableBandwidthPlugin: true, Overrides: &ConflistOverrides{
+		Fields: map[string]interface{}{"cniVersion": "0.3.1", "disableCheck": false},
+		Plugins: []map[string]interface{}{
+			{"type": "firewall", "backend": "iptables"},
+			{"type": "tuning", "capabilities": map[string]interface{}{"mac": true}},
+		},
+	}}
+
+	var conflist struct {
+		CNIVersion   string
+		DisableCheck bool
+		Name         string
+		Plugins      []map[string]interface{}
+	}
+	data, err := GenerateConflist(template, cfg)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(data, &conflist))
+	assert.Equal(t, "0.3.1", conflist.CNIVersion)
+	assert.False(t, conflist.DisableCheck)
+	assert.Equal(t, "aws-cni", conflist.Name)
+	var pluginTypes []interface{}
+	for _, plugin := range conflist.Plugins {
+		pluginTypes = append(pluginTypes, plugin["type"])
+	}
+	assert.Equal(t, []interface{}{"aws-cni", "egress-v4-cni", "portmap", "bandwidth", "firewall", "tuning"}, pluginTypes)
+
+	for _, overrides := range []*ConflistOverrides{
+		{Fields: map[string]interface{}{"name": "other"}},
+		{Fields: map[string]interface{}{"disableCheck": "yes"}},
+		{Plugins: []map[string]interface{}{{"backend": "iptables"}}},
+		{Plugins: []map[string]interface{}{{"type": "bandwidth"}}},
+		{Plugins: []map[string]interface{}{{"type": "tuning", "capabilities": map[string]interface{}{"mac": "true"}}}},
+	} {
+		cfg.Overrides = overrides
+		_, err = GenerateConflist(template, cfg)
+		assert.Error(t, err)
+	}
+}
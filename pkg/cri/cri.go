@@ -16,7 +16,10 @@ package cri
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
 	"google.golang.org/grpc"
@@ -24,10 +27,25 @@ import (
 )
 
 const (
-	criSocketPath    = "unix:///var/run/cri.sock"
-	dockerSocketPath = "unix:///var/run/dockershim.sock"
+	// envCRISocketPaths overrides defaultSocketPaths with a comma separated list of CRI socket paths, in order of
+	// preference
+	envCRISocketPaths = "CRI_SOCKET_PATHS"
+
+	// probeTimeout is how long a socket has to answer the CRI Version call
+	probeTimeout = 5 * time.Second
 )
 
+// defaultSocketPaths are the CRI sockets tried in order. /var/run/cri.sock is where the aws-node manifest mounts the
+// socket of the node, the others are where containerd, CRI-O, k3s and Bottlerocket put theirs.
+var defaultSocketPaths = []string{
+	"/var/run/cri.sock",
+	"/var/run/dockershim.sock",
+	"/run/containerd/containerd.sock",
+	"/var/run/crio/crio.sock",
+	"/run/k3s/containerd/containerd.sock",
+	"/run/dockershim.sock",
+}
+
 // PodSandboxMetadata contains metadata about pod sandboxes.
 type PodSandboxMetadata struct {
 	// Pod's namespace
@@ -53,24 +71,75 @@ type APIs interface {
 	GetRunningPodSandboxes(log logger.Logger) ([]SandboxInfo, error)
 }
 
-// Client is an empty struct
-type Client struct{}
+// Client queries the first CRI socket, out of socketPaths, that answers
+type Client struct {
+	socketPaths []string
+}
 
-// New creates a new CRI client
+// New creates a new CRI client, using the socket paths of CRI_SOCKET_PATHS or the default ones
 func New() *Client {
-	return &Client{}
+	return NewWithSocketPaths(socketPathsFromEnv())
+}
+
+// NewWithSocketPaths creates a new CRI client that tries the socket paths in order
+func NewWithSocketPaths(socketPaths []string) *Client {
+	return &Client{socketPaths: socketPaths}
+}
+
+func socketPathsFromEnv() []string {
+	value := os.Getenv(envCRISocketPaths)
+	if value == "" {
+		return defaultSocketPaths
+	}
+	var socketPaths []string
+	for _, path := range strings.Split(value, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			socketPaths = append(socketPaths, strings.TrimPrefix(path, "unix://"))
+		}
+	}
+	return socketPaths
+}
+
+// connect returns a connection to the first socket that is a unix socket and answers the CRI Version call. The error
+// lists why each socket was skipped.
+func (c *Client) connect(ctx context.Context, log logger.Logger) (*grpc.ClientConn, error) {
+	var skipped []string
+	for _, path := range c.socketPaths {
+		info, err := os.Stat(path)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s: %v", path, err))
+			continue
+		}
+		if info.Mode()&os.ModeSocket == 0 {
+			skipped = append(skipped, fmt.Sprintf("%s: not a socket", path))
+			continue
+		}
+
+		probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+		conn, err := grpc.DialContext(probeCtx, "unix://"+path, grpc.WithInsecure(), grpc.WithNoProxy(), grpc.WithBlock(),
+			grpc.FailOnNonTempDialError(true))
+		if err == nil {
+			var version *runtimeapi.VersionResponse
+			version, err = runtimeapi.NewRuntimeServiceClient(conn).Version(probeCtx, &runtimeapi.VersionRequest{})
+			if err == nil {
+				cancel()
+				log.Debugf("Using CRI socket %q of %s %s", path, version.GetRuntimeName(), version.GetRuntimeVersion())
+				return conn, nil
+			}
+			conn.Close()
+		}
+		cancel()
+		skipped = append(skipped, fmt.Sprintf("%s: %v", path, err))
+	}
+	return nil, fmt.Errorf("no usable CRI socket, set %s to the path of the CRI socket: %s",
+		envCRISocketPaths, strings.Join(skipped, "; "))
 }
 
 // GetRunningPodSandboxes get running sandboxIDs
 func (c *Client) GetRunningPodSandboxes(log logger.Logger) ([]SandboxInfo, error) {
 	ctx := context.TODO()
 
-	socketPath := dockerSocketPath
-	if info, err := os.Stat("/var/run/cri.sock"); err == nil && !info.IsDir() {
-		socketPath = criSocketPath
-	}
-	log.Debugf("Getting running pod sandboxes from %q", socketPath)
-	conn, err := grpc.Dial(socketPath, grpc.WithInsecure(), grpc.WithNoProxy(), grpc.WithBlock())
+	conn, err := c.connect(ctx, log)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,91 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cri
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
+)
+
+var testLog = logger.New(&logger.Configuration{LogLevel: "Debug", LogLocation: "stdout"})
+
+type fakeRuntimeService struct {
+	runtimeapi.UnimplementedRuntimeServiceServer
+	name string
+}
+
+func (s *fakeRuntimeService) Version(ctx context.Context, req *runtimeapi.VersionRequest) (*runtimeapi.VersionResponse, error) {
+	return &runtimeapi.VersionResponse{RuntimeName: s.name, RuntimeVersion: "1.0"}, nil
+}
+
+// serveCRI serves a fake CRI runtime service on a unix socket at path
+func serveCRI(t *testing.T, path, name string) {
+	listener, err := net.Listen("unix", path)
+	assert.NoError(t, err)
+	server := grpc.NewServer()
+	runtimeapi.RegisterRuntimeServiceServer(server, &fakeRuntimeService{name: name})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+}
+
+func TestConnect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cri")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	missing := filepath.Join(dir, "missing.sock")
+	notSocket := filepath.Join(dir, "cri.sock")
+	assert.NoError(t, os.Mkdir(notSocket, 0755))
+	// A socket nothing listens on anymore
+	stale := filepath.Join(dir, "stale.sock")
+	listener, err := net.Listen("unix", stale)
+	assert.NoError(t, err)
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	listener.Close()
+	containerd := filepath.Join(dir, "containerd.sock")
+	serveCRI(t, containerd, "containerd")
+	crio := filepath.Join(dir, "crio.sock")
+	serveCRI(t, crio, "cri-o")
+
+	client := NewWithSocketPaths([]string{missing, notSocket, stale, containerd, crio})
+	conn, err := client.connect(context.Background(), testLog)
+	assert.NoError(t, err)
+	assert.Equal(t, "unix://"+containerd, conn.Target())
+	conn.Close()
+
+	client = NewWithSocketPaths([]string{missing, notSocket})
+	_, err = client.connect(context.Background(), testLog)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), missing)
+	assert.Contains(t, err.Error(), notSocket+": not a socket")
+}
+
+func TestSocketPathsFromEnv(t *testing.T) {
+	os.Unsetenv(envCRISocketPaths)
+	assert.Equal(t, defaultSocketPaths, socketPathsFromEnv())
+
+	os.Setenv(envCRISocketPaths, "unix:///run/crio/crio.sock, /run/containerd/containerd.sock,")
+	defer os.Unsetenv(envCRISocketPaths)
+	assert.Equal(t, []string{"/run/crio/crio.sock", "/run/containerd/containerd.sock"}, socketPathsFromEnv())
+}
@@ -24,6 +24,7 @@ import (
 type EC2 interface {
 	CreateNetworkInterfaceWithContext(ctx aws.Context, input *ec2svc.CreateNetworkInterfaceInput, opts ...request.Option) (*ec2svc.CreateNetworkInterfaceOutput, error)
 	DescribeInstancesWithContext(ctx aws.Context, input *ec2svc.DescribeInstancesInput, opts ...request.Option) (*ec2svc.DescribeInstancesOutput, error)
+	ModifyInstanceMetadataOptionsWithContext(ctx aws.Context, input *ec2svc.ModifyInstanceMetadataOptionsInput, opts ...request.Option) (*ec2svc.ModifyInstanceMetadataOptionsOutput, error)
 	DescribeInstanceTypesWithContext(ctx aws.Context, input *ec2svc.DescribeInstanceTypesInput, opts ...request.Option) (*ec2svc.DescribeInstanceTypesOutput, error)
 	AttachNetworkInterfaceWithContext(ctx aws.Context, input *ec2svc.AttachNetworkInterfaceInput, opts ...request.Option) (*ec2svc.AttachNetworkInterfaceOutput, error)
 	DeleteNetworkInterfaceWithContext(ctx aws.Context, input *ec2svc.DeleteNetworkInterfaceInput, opts ...request.Option) (*ec2svc.DeleteNetworkInterfaceOutput, error)
@@ -35,6 +36,11 @@ type EC2 interface {
 	DescribeNetworkInterfacesWithContext(ctx aws.Context, input *ec2svc.DescribeNetworkInterfacesInput, opts ...request.Option) (*ec2svc.DescribeNetworkInterfacesOutput, error)
 	ModifyNetworkInterfaceAttributeWithContext(ctx aws.Context, input *ec2svc.ModifyNetworkInterfaceAttributeInput, opts ...request.Option) (*ec2svc.ModifyNetworkInterfaceAttributeOutput, error)
 	CreateTagsWithContext(ctx aws.Context, input *ec2svc.CreateTagsInput, opts ...request.Option) (*ec2svc.CreateTagsOutput, error)
+	DescribeSubnetsWithContext(ctx aws.Context, input *ec2svc.DescribeSubnetsInput, opts ...request.Option) (*ec2svc.DescribeSubnetsOutput, error)
+	GetSubnetCidrReservationsWithContext(ctx aws.Context, input *ec2svc.GetSubnetCidrReservationsInput, opts ...request.Option) (*ec2svc.GetSubnetCidrReservationsOutput, error)
+	DescribeSecurityGroupsWithContext(ctx aws.Context, input *ec2svc.DescribeSecurityGroupsInput, opts ...request.Option) (*ec2svc.DescribeSecurityGroupsOutput, error)
+	DescribeVpcsWithContext(ctx aws.Context, input *ec2svc.DescribeVpcsInput, opts ...request.Option) (*ec2svc.DescribeVpcsOutput, error)
+	DescribeDhcpOptionsWithContext(ctx aws.Context, input *ec2svc.DescribeDhcpOptionsInput, opts ...request.Option) (*ec2svc.DescribeDhcpOptionsOutput, error)
 	DescribeNetworkInterfacesPagesWithContext(ctx aws.Context, input *ec2svc.DescribeNetworkInterfacesInput, fn func(*ec2svc.DescribeNetworkInterfacesOutput, bool) bool, opts ...request.Option) error
 }
 
@@ -0,0 +1,132 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+)
+
+// allocationQueueRetryInterval is how often a queued AddNetwork request retries, since released IPs become assignable
+// again at the end of their cooling period without any notification
+const allocationQueueRetryInterval = time.Second
+
+// allocationQueue holds the AddNetwork requests that found no free IP while the node is at its ENI limit, until
+// capacity frees up or their timeout expires
+type allocationQueue struct {
+	timeout time.Duration
+
+	lock    sync.Mutex
+	waiting int
+	freed   chan struct{} // freed is closed and replaced each time capacity frees up
+}
+
+func newAllocationQueue(timeout time.Duration) *allocationQueue {
+	return &allocationQueue{timeout: timeout, freed: make(chan struct{})}
+}
+
+// notify wakes up the queued requests to retry. It is safe to call on a nil allocationQueue.
+func (q *allocationQueue) notify() {
+	if q == nil {
+		return
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.waiting == 0 {
+		return
+	}
+	close(q.freed)
+	q.freed = make(chan struct{})
+}
+
+// enter adds a request to the queue
+func (q *allocationQueue) enter() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.waiting++
+	allocationQueueLength.Set(float64(q.waiting))
+}
+
+func (q *allocationQueue) leave() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.waiting--
+	allocationQueueLength.Set(float64(q.waiting))
+}
+
+// next returns the channel that is closed the next time capacity frees up
+func (q *allocationQueue) next() <-chan struct{} {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.freed
+}
+
+// length returns the number of queued requests
+func (q *allocationQueue) length() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.waiting
+}
+
+// atENILimit returns true if no more ENIs can be attached, keeping a slot for the trunk ENI if it is still expected
+func (c *IPAMContext) atENILimit() bool {
+	reserveSlotForTrunkENI := 0
+	if c.enablePodENI && c.dataStore.GetTrunkENI() == "" {
+		reserveSlotForTrunkENI = 1
+	}
+	return c.dataStore.GetENIs() >= c.maxENI-c.unmanagedENI-reserveSlotForTrunkENI
+}
+
+// waitForCapacity queues the AddNetwork request of a pod that found no free IP while the node is at its ENI limit.
+// It retries assign each time capacity frees up, and every allocationQueueRetryInterval, until it stops failing with
+// ErrNoAvailableIPs. It returns a ResourceExhausted status when the queue timeout expires, and the status of ctx when
+// the request is cancelled.
+func (c *IPAMContext) waitForCapacity(ctx context.Context, podName, podNamespace string, assign func() error) error {
+	q := c.allocationQueue
+	q.enter()
+	defer q.leave()
+	log.Infof("No free IP for pod %s/%s and the ENI limit of %d is reached, queueing the request for up to %v",
+		podNamespace, podName, c.maxENI, q.timeout)
+
+	timeout := time.NewTimer(q.timeout)
+	defer timeout.Stop()
+	retry := time.NewTicker(allocationQueueRetryInterval)
+	defer retry.Stop()
+	for {
+		select {
+		case <-q.next():
+		case <-retry.C:
+		case <-timeout.C:
+			allocationQueueTimeouts.Inc()
+			return status.Errorf(codes.ResourceExhausted,
+				"capacity exceeded, queued: no free IP for pod %s/%s within %v and the ENI limit of %d is reached",
+				podNamespace, podName, q.timeout, c.maxENI)
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		if err := assign(); !errors.Is(err, datastore.ErrNoAvailableIPs) {
+			if err == nil {
+				log.Infof("Queued request of pod %s/%s got an IP", podNamespace, podName)
+			}
+			return err
+		}
+	}
+}
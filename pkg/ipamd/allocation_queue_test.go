@@ -0,0 +1,126 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/eventrecorder"
+	pb "github.com/aws/amazon-vpc-cni-k8s/rpc"
+)
+
+// fullNodeContext returns an IPAMContext at its ENI limit, with the only IP assigned to pod-1
+func fullNodeContext(t *testing.T, m *testMocks, queueTimeout time.Duration) *IPAMContext {
+	eventrecorder.InitMockEventRecorder(m.cachedK8SClient)
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP("10.0.0.1"), Mask: net.CIDRMask(32, 32)}, false))
+	_, _, err := ds.AssignPodIPv4Address(datastore.IPAMKey{NetworkName: "aws-cni", ContainerID: "cid-1", IfName: "eth0"},
+		datastore.IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "pod-1"})
+	assert.NoError(t, err)
+	return &IPAMContext{
+		awsClient:       m.awsutils,
+		networkClient:   m.network,
+		dataStore:       ds,
+		enableIPv4:      true,
+		maxENI:          1,
+		allocationQueue: newAllocationQueue(queueTimeout),
+	}
+}
+
+func addPod2Request() *pb.AddNetworkRequest {
+	return &pb.AddNetworkRequest{
+		ClientVersion:     "1.2.3",
+		K8S_POD_NAME:      "pod-2",
+		K8S_POD_NAMESPACE: "default",
+		ContainerID:       "cid-2",
+		IfName:            "eth0",
+		NetworkName:       "aws-cni",
+	}
+}
+
+func TestAtENILimit(t *testing.T) {
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	c := &IPAMContext{dataStore: ds, maxENI: 2}
+	assert.False(t, c.atENILimit())
+
+	// The last slot is kept for the trunk ENI
+	c.enablePodENI = true
+	assert.True(t, c.atENILimit())
+}
+
+func TestAddNetworkQueuedAtENILimit(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	mockContext := fullNodeContext(t, m, 5*time.Second)
+	s := &server{version: "1.2.3", ipamContext: mockContext}
+
+	type result struct {
+		reply *pb.AddNetworkReply
+		err   error
+	}
+	done := make(chan result)
+	go func() {
+		reply, err := s.AddNetwork(context.Background(), addPod2Request())
+		done <- result{reply, err}
+	}()
+	assert.Eventually(t, func() bool { return mockContext.allocationQueue.length() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// The pool grows on the existing ENI
+	m.awsutils.EXPECT().GetVPCIPv4CIDRs().Return([]string{"10.0.0.0/16"}, nil)
+	m.network.EXPECT().UseExternalSNAT().Return(true)
+	assert.NoError(t, mockContext.dataStore.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP("10.0.0.2"), Mask: net.CIDRMask(32, 32)}, false))
+	mockContext.allocationQueue.notify()
+
+	r := <-done
+	assert.NoError(t, r.err)
+	assert.True(t, r.reply.Success)
+	assert.Equal(t, "10.0.0.2", r.reply.IPv4Addr)
+	assert.Equal(t, 0, mockContext.allocationQueue.length())
+}
+
+func TestAddNetworkQueueTimeout(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	s := &server{version: "1.2.3", ipamContext: fullNodeContext(t, m, 50*time.Millisecond)}
+	_, err := s.AddNetwork(context.Background(), addPod2Request())
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Contains(t, err.Error(), "capacity exceeded, queued")
+}
+
+func TestGetENILimitQueueTimeout(t *testing.T) {
+	defer os.Unsetenv(envENILimitQueueTimeout)
+
+	// Requests are not queued unless the timeout is set
+	_ = os.Unsetenv(envENILimitQueueTimeout)
+	assert.Equal(t, time.Duration(0), getENILimitQueueTimeout())
+
+	_ = os.Setenv(envENILimitQueueTimeout, "10")
+	assert.Equal(t, 10*time.Second, getENILimitQueueTimeout())
+
+	_ = os.Setenv(envENILimitQueueTimeout, "-1")
+	assert.Equal(t, time.Duration(0), getENILimitQueueTimeout())
+}
@@ -0,0 +1,49 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/audit"
+)
+
+// auditLogRequestHandler serves the last `n` entries of the audit log recorded since ipamd started, or all of the ones
+// kept in memory without n. The log file has the older ones.
+func auditLogRequestHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !audit.Enabled() {
+			http.Error(w, "the audit log is not enabled", http.StatusNotFound)
+			return
+		}
+		var n int
+		if value := r.URL.Query().Get("n"); value != "" {
+			var err error
+			if n, err = strconv.Atoi(value); err != nil || n < 1 {
+				http.Error(w, fmt.Sprintf("invalid n %q, expected a positive integer", value), http.StatusBadRequest)
+				return
+			}
+		}
+		responseJSON, err := json.Marshal(audit.Tail(n))
+		if err != nil {
+			log.Errorf("Failed to marshal the audit log: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		logErr(w.Write(responseJSON))
+	}
+}
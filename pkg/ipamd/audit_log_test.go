@@ -0,0 +1,68 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/audit"
+)
+
+func TestAuditLogRequestHandler(t *testing.T) {
+	request := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		auditLogRequestHandler()(w, httptest.NewRequest(http.MethodGet, url, nil))
+		return w
+	}
+	assert.Equal(t, http.StatusNotFound, request("/v1/audit-log").Code)
+
+	var buf bytes.Buffer
+	audit.SetLog(audit.NewLog(&buf, 10))
+	defer audit.SetLog(nil)
+
+	// The datastore records the assignments of the pods
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP("10.0.0.1"), Mask: net.CIDRMask(32, 32)}, false))
+	key := datastore.IPAMKey{NetworkName: "aws-cni", ContainerID: "cid-1", IfName: "eth0"}
+	_, _, err := ds.AssignPodIPv4Address(key, datastore.IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "pod-1"})
+	assert.NoError(t, err)
+	_, _, _, err = ds.UnassignPodIPAddress(key)
+	assert.NoError(t, err)
+
+	w := request("/v1/audit-log")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var entries []audit.Entry
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "assign", entries[0].Action)
+	assert.Equal(t, "10.0.0.1 to default/pod-1", entries[0].Target)
+	assert.Equal(t, "cid-1", entries[0].CorrelationID)
+	assert.Equal(t, "unassign", entries[1].Action)
+
+	w = request("/v1/audit-log?n=1")
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "unassign", entries[0].Action)
+
+	assert.Equal(t, http.StatusBadRequest, request("/v1/audit-log?n=all").Code)
+}
@@ -0,0 +1,150 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"fmt"
+	"sort"
+)
+
+// The features whose combination is validated against the instance at startup
+const (
+	featureIPv4             = "IPv4"
+	featureIPv6             = "IPv6"
+	featurePrefixDelegation = "PrefixDelegation"
+	featureCustomNetworking = "CustomNetworking"
+	featurePodENI           = "PodENI"
+	featureExternalSNAT     = "ExternalSNAT"
+	featureStaticIPPool     = "StaticIPPool"
+)
+
+// NodeCapabilities is what the instance supports
+type NodeCapabilities struct {
+	InstanceType string
+	Region       string
+	// Nitro is true for Nitro and bare metal instances, the only ones with prefixes and trunk ENIs
+	Nitro    bool
+	ENILimit int
+}
+
+// CapabilityReport is the outcome of the validation of the enabled features against the instance, served on the
+// /v1/capabilities introspection endpoint
+type CapabilityReport struct {
+	Node     NodeCapabilities
+	Features []string
+	// Disabled are the enabled features that are turned off because the instance doesn't support them
+	Disabled []string `json:",omitempty"`
+	// Errors are the unsupported combinations, which stop ipamd
+	Errors   []string `json:",omitempty"`
+	Warnings []string `json:",omitempty"`
+}
+
+// nodeCapabilities looks up what the instance supports
+func (c *IPAMContext) nodeCapabilities() NodeCapabilities {
+	return NodeCapabilities{
+		InstanceType: c.awsClient.GetInstanceType(),
+		Region:       c.awsClient.GetRegion(),
+		Nitro:        c.awsClient.IsPrefixDelegationSupported(),
+		ENILimit:     c.awsClient.GetENILimit(),
+	}
+}
+
+// enabledFeatures returns the features set in the environment
+func (c *IPAMContext) enabledFeatures() map[string]bool {
+	return map[string]bool{
+		featureIPv4:             c.enableIPv4,
+		featureIPv6:             c.enableIPv6,
+		featurePrefixDelegation: c.enablePrefixDelegation,
+		featureCustomNetworking: c.useCustomNetworking,
+		featurePodENI:           c.enablePodENI,
+		featureExternalSNAT:     c.networkClient.UseExternalSNAT(),
+		featureStaticIPPool:     c.staticIPPool,
+	}
+}
+
+// negotiateCapabilities checks a combination of features against the instance. A combination that can't work is an
+// error, so that ipamd stops at startup rather than at the first allocation. A feature that only can't work on this
+// instance type, in a cluster with a mix of them, is turned off or reported as a warning.
+func negotiateCapabilities(features map[string]bool, node NodeCapabilities) *CapabilityReport {
+	report := &CapabilityReport{Node: node}
+	for feature, enabled := range features {
+		if enabled {
+			report.Features = append(report.Features, feature)
+		}
+	}
+	sort.Strings(report.Features)
+	fail := func(format string, args ...interface{}) {
+		report.Errors = append(report.Errors, fmt.Sprintf(format, args...))
+	}
+	warn := func(format string, args ...interface{}) {
+		report.Warnings = append(report.Warnings, fmt.Sprintf(format, args...))
+	}
+	instance := fmt.Sprintf("instance type %s in %s", node.InstanceType, node.Region)
+
+	switch {
+	case features[featureIPv4] && features[featureIPv6]:
+		fail("%s and %s are both true, dual stack is not supported", envEnableIPv4, envEnableIPv6)
+	case !features[featureIPv4] && !features[featureIPv6]:
+		fail("%s and %s are both false, one of them has to be true", envEnableIPv4, envEnableIPv6)
+	}
+
+	if features[featureIPv6] {
+		if !features[featurePrefixDelegation] {
+			fail("%s needs %s=true", envEnableIPv6, envEnableIpv4PrefixDelegation)
+		}
+		if features[featurePodENI] {
+			fail("%s is not supported with %s", envEnablePodENI, envEnableIPv6)
+		}
+		if features[featureCustomNetworking] {
+			fail("%s is not supported with %s", envCustomNetworkCfg, envEnableIPv6)
+		}
+		if features[featureExternalSNAT] {
+			warn("AWS_VPC_K8S_CNI_EXTERNALSNAT is ignored with %s, IPv6 pod traffic isn't SNATed", envEnableIPv6)
+		}
+	}
+
+	if features[featurePrefixDelegation] && !node.Nitro {
+		if features[featureIPv6] {
+			fail("%s needs prefix delegation, which %s doesn't support as it isn't a Nitro instance", envEnableIPv6, instance)
+		} else {
+			warn("Prefix delegation is not supported on %s as it isn't a Nitro instance, falling back to secondary IP mode", instance)
+			report.Disabled = append(report.Disabled, featurePrefixDelegation)
+		}
+	}
+
+	trunkENI := features[featurePodENI] && node.Nitro && !features[featureIPv6]
+	if features[featurePodENI] && !node.Nitro {
+		warn("%s is set but %s doesn't support trunk ENIs as it isn't a Nitro instance, pods with security groups "+
+			"can't run on this node", envEnablePodENI, instance)
+	}
+
+	// Custom networking leaves the primary ENI to the node and pod ENI takes one for the trunk. The static IP pool
+	// attaches no ENI.
+	if !features[featureStaticIPPool] && node.ENILimit > 0 {
+		reserved := 0
+		var reasons []string
+		if features[featureCustomNetworking] {
+			reserved++
+			reasons = append(reasons, envCustomNetworkCfg)
+		}
+		if trunkENI {
+			reserved++
+			reasons = append(reasons, envEnablePodENI)
+		}
+		if node.ENILimit <= reserved {
+			fail("%s attaches %d ENIs, which leaves none for pod IPs with %v", instance, node.ENILimit, reasons)
+		}
+	}
+	return report
+}
@@ -0,0 +1,66 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateCapabilities(t *testing.T) {
+	nitro := NodeCapabilities{InstanceType: "m5.large", Region: "us-west-2", Nitro: true, ENILimit: 3}
+	xen := NodeCapabilities{InstanceType: "m4.large", Region: "us-west-2", ENILimit: 2}
+	tests := []struct {
+		name     string
+		features []string
+		node     NodeCapabilities
+		disabled []string
+		errors   int
+		warnings int
+	}{
+		{name: "IPv4", features: []string{featureIPv4}, node: xen},
+		{name: "dual stack", features: []string{featureIPv4, featureIPv6, featurePrefixDelegation}, node: nitro, errors: 1},
+		{name: "no IP family", node: nitro, errors: 1},
+		{name: "custom networking with prefix delegation",
+			features: []string{featureIPv4, featurePrefixDelegation, featureCustomNetworking, featurePodENI}, node: nitro},
+		{name: "prefix delegation on xen", features: []string{featureIPv4, featurePrefixDelegation}, node: xen,
+			disabled: []string{featurePrefixDelegation}, warnings: 1},
+		{name: "IPv6", features: []string{featureIPv6, featurePrefixDelegation}, node: nitro},
+		{name: "IPv6 without prefix delegation", features: []string{featureIPv6}, node: nitro, errors: 1},
+		{name: "IPv6 on xen", features: []string{featureIPv6, featurePrefixDelegation}, node: xen, errors: 1},
+		{name: "IPv6 with pod ENI and custom networking",
+			features: []string{featureIPv6, featurePrefixDelegation, featurePodENI, featureCustomNetworking}, node: nitro,
+			errors: 2},
+		{name: "IPv6 with external SNAT", features: []string{featureIPv6, featurePrefixDelegation, featureExternalSNAT},
+			node: nitro, warnings: 1},
+		{name: "pod ENI on xen", features: []string{featureIPv4, featurePodENI}, node: xen, warnings: 1},
+		{name: "no ENI left for pods", features: []string{featureIPv4, featureCustomNetworking, featurePodENI},
+			node: NodeCapabilities{InstanceType: "t3.nano", Nitro: true, ENILimit: 2}, errors: 1},
+		{name: "static IP pool", features: []string{featureIPv4, featureStaticIPPool}, node: NodeCapabilities{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			features := make(map[string]bool)
+			for _, feature := range tt.features {
+				features[feature] = true
+			}
+			report := negotiateCapabilities(features, tt.node)
+			assert.ElementsMatch(t, tt.features, report.Features)
+			assert.Equal(t, tt.disabled, report.Disabled)
+			assert.Len(t, report.Errors, tt.errors, "%v", report.Errors)
+			assert.Len(t, report.Warnings, tt.warnings, "%v", report.Warnings)
+		})
+	}
+}
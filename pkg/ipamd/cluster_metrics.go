@@ -0,0 +1,285 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/k8sapi"
+)
+
+const (
+	// clusterMetricsLeaseName is the Lease in kube-system held by the aws-node pod that aggregates the cluster metrics
+	clusterMetricsLeaseName = "aws-node-cluster-metrics"
+
+	// clusterMetricsInterval is the interval between two scrapes of the peers by the aggregator
+	clusterMetricsInterval = 30 * time.Second
+
+	// clusterMetricsScrapeConcurrency is the number of peers scraped at the same time
+	clusterMetricsScrapeConcurrency = 10
+
+	clusterMetricsLeaseDuration = 30 * time.Second
+	clusterMetricsRenewDeadline = 20 * time.Second
+	clusterMetricsRetryPeriod   = 5 * time.Second
+)
+
+// clusterPoolMetrics are the node metrics summed over the cluster by the aggregator, published as
+// awscni_cluster_<name without the awscni_ prefix>
+var clusterPoolMetrics = map[string]string{
+	"awscni_total_ip_addresses":      "The total number of IP addresses in the cluster",
+	"awscni_assigned_ip_addresses":   "The number of IP addresses assigned to pods in the cluster",
+	"awscni_ip_max":                  "The maximum number of IP addresses of the nodes of the cluster",
+	"awscni_eni_allocated":           "The number of ENIs allocated in the cluster",
+	"awscni_eni_max":                 "The maximum number of ENIs of the nodes of the cluster",
+	"awscni_total_ipv4_prefixes":     "The total number of IPv4 prefixes in the cluster",
+	"awscni_ipamd_action_inprogress": "The number of ipamd actions in progress in the cluster",
+	"awscni_ipamd_error_count":       "The number of errors encountered by ipamd in the cluster",
+	"awscni_aws_api_error_count":     "The number of errors returned by AWS APIs in the cluster",
+}
+
+// clusterMetricsAggregator sums the pool metrics of the aws-node pods of the cluster. It is a prometheus collector
+// that only reports metrics while its ipamd is the leader, so that only one pod of the cluster serves them.
+type clusterMetricsAggregator struct {
+	scrape func(ctx context.Context, pod string) ([]byte, error)
+
+	lock             sync.RWMutex
+	leading          bool
+	sums             map[string]float64 // sums is nil until the first scrape after taking the lead
+	nodesReporting   int
+	nodesUnreachable int
+}
+
+// Describe sends no descriptors: the aggregator is an unchecked collector, as its metrics disappear when it isn't
+// the leader
+func (a *clusterMetricsAggregator) Describe(chan<- *prometheus.Desc) {}
+
+// Collect sends the cluster metrics of the last scrape, if the aggregator is the leader
+func (a *clusterMetricsAggregator) Collect(ch chan<- prometheus.Metric) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	if !a.leading || a.sums == nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(prometheus.NewDesc("awscni_cluster_nodes_reporting",
+		"The number of aws-node pods whose metrics are in the cluster metrics", nil, nil),
+		prometheus.GaugeValue, float64(a.nodesReporting))
+	ch <- prometheus.MustNewConstMetric(prometheus.NewDesc("awscni_cluster_nodes_unreachable",
+		"The number of aws-node pods whose metrics could not be scraped", nil, nil),
+		prometheus.GaugeValue, float64(a.nodesUnreachable))
+	for name, help := range clusterPoolMetrics {
+		ch <- prometheus.MustNewConstMetric(prometheus.NewDesc(clusterMetricName(name), help, nil, nil),
+			prometheus.GaugeValue, a.sums[name])
+	}
+}
+
+// setLeading records whether the aggregator is the leader, dropping the metrics of the previous term
+func (a *clusterMetricsAggregator) setLeading(leading bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.leading = leading
+	a.sums = nil
+}
+
+// aggregate scrapes the metrics endpoint of pods and sums their pool metrics. The pods that can't be scraped are
+// counted as unreachable.
+func (a *clusterMetricsAggregator) aggregate(ctx context.Context, pods []string) {
+	var (
+		wg          sync.WaitGroup
+		resultsLock sync.Mutex
+		sums        = make(map[string]float64, len(clusterPoolMetrics))
+		reporting   int
+		unreachable int
+	)
+	sem := make(chan struct{}, clusterMetricsScrapeConcurrency)
+	for _, pod := range pods {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(pod string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			values, err := a.scrapePoolMetrics(ctx, pod)
+			resultsLock.Lock()
+			defer resultsLock.Unlock()
+			if err != nil {
+				log.Debugf("Failed to scrape the metrics of %s: %v", pod, err)
+				unreachable++
+				return
+			}
+			reporting++
+			for name, value := range values {
+				sums[name] += value
+			}
+		}(pod)
+	}
+	wg.Wait()
+	if unreachable > 0 {
+		log.Warnf("Failed to scrape the metrics of %d of %d aws-node pods", unreachable, len(pods))
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.sums = sums
+	a.nodesReporting = reporting
+	a.nodesUnreachable = unreachable
+}
+
+// scrapePoolMetrics returns the sum of the samples of each pool metric exposed by pod
+func (a *clusterMetricsAggregator) scrapePoolMetrics(ctx context.Context, pod string) (map[string]float64, error) {
+	out, err := a.scrape(ctx, pod)
+	if err != nil {
+		return nil, err
+	}
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(bytes.NewReader(out))
+	if err != nil {
+		return nil, fmt.Errorf("parsing metrics: %w", err)
+	}
+	values := make(map[string]float64, len(clusterPoolMetrics))
+	for name := range clusterPoolMetrics {
+		family, ok := families[name]
+		if !ok {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			switch family.GetType() {
+			case dto.MetricType_GAUGE:
+				values[name] += metric.GetGauge().GetValue()
+			case dto.MetricType_COUNTER:
+				values[name] += metric.GetCounter().GetValue()
+			}
+		}
+	}
+	return values, nil
+}
+
+// clusterMetricName returns the name of the cluster metric summing the node metric name
+func clusterMetricName(name string) string {
+	return "awscni_cluster_" + strings.TrimPrefix(name, "awscni_")
+}
+
+// clusterMetricsPeers returns the names of the running aws-node pods of the cluster
+func (c *IPAMContext) clusterMetricsPeers(ctx context.Context) ([]string, error) {
+	var pods corev1.PodList
+	if err := c.rawK8SClient.List(ctx, &pods, client.InNamespace(metav1.NamespaceSystem),
+		client.MatchingLabels{"k8s-app": "aws-node"}); err != nil {
+		return nil, err
+	}
+	var peers []string
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning {
+			peers = append(peers, pod.Name)
+		}
+	}
+	sort.Strings(peers)
+	return peers, nil
+}
+
+// runClusterMetricsAggregator scrapes the peers every clusterMetricsInterval until ctx is done
+func (c *IPAMContext) runClusterMetricsAggregator(ctx context.Context) {
+	ticker := time.NewTicker(clusterMetricsInterval)
+	defer ticker.Stop()
+	for {
+		peers, err := c.clusterMetricsPeers(ctx)
+		if err != nil {
+			log.Warnf("Failed to list the aws-node pods for the cluster metrics: %v", err)
+		} else {
+			c.clusterMetrics.aggregate(ctx, peers)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// StartClusterMetricsAggregator runs for the lease of the cluster metrics aggregator and, while holding it, serves the
+// pool metrics of all the aws-node pods on /cluster-metrics, as an alternative to the cni-metrics-helper deployment
+func (c *IPAMContext) StartClusterMetricsAggregator() {
+	if c.clusterMetrics == nil {
+		return
+	}
+	if c.myNodeName == "" {
+		log.Errorf("Cluster metrics aggregator disabled: %s is not set", envNodeName)
+		return
+	}
+	clientSet, err := k8sapi.GetKubeClientSet()
+	if err != nil {
+		log.Errorf("Cluster metrics aggregator disabled: failed to create the kube client: %v", err)
+		return
+	}
+	c.clusterMetrics.scrape = podMetricsScraper(clientSet)
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Namespace: metav1.NamespaceSystem, Name: clusterMetricsLeaseName},
+		Client:     clientSet.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: c.myNodeName},
+	}
+	for {
+		// RunOrDie returns when the lead is lost, after which ipamd runs for the lease again
+		leaderelection.RunOrDie(context.Background(), leaderelection.LeaderElectionConfig{
+			Lock:          lock,
+			LeaseDuration: clusterMetricsLeaseDuration,
+			RenewDeadline: clusterMetricsRenewDeadline,
+			RetryPeriod:   clusterMetricsRetryPeriod,
+			Name:          clusterMetricsLeaseName,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: func(ctx context.Context) {
+					log.Info("Aggregating the cluster metrics")
+					c.clusterMetrics.setLeading(true)
+					c.runClusterMetricsAggregator(ctx)
+				},
+				OnStoppedLeading: func() {
+					log.Info("Stopped aggregating the cluster metrics")
+					c.clusterMetrics.setLeading(false)
+				},
+				OnNewLeader: func(identity string) {
+					log.Infof("The cluster metrics are aggregated by the aws-node pod of %s", identity)
+				},
+			},
+		})
+	}
+}
+
+// podMetricsScraper returns a scraper of the metrics endpoint of the aws-node pods through the API server proxy, which
+// works whatever the network path between the nodes
+func podMetricsScraper(clientSet kubernetes.Interface) func(ctx context.Context, pod string) ([]byte, error) {
+	return func(ctx context.Context, pod string) ([]byte, error) {
+		return clientSet.CoreV1().RESTClient().Get().
+			Namespace(metav1.NamespaceSystem).
+			Resource("pods").
+			SubResource("proxy").
+			Name(fmt.Sprintf("%s:%d", pod, metricsPort)).
+			Suffix("metrics").
+			Do(ctx).Raw()
+	}
+}
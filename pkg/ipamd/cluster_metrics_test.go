@@ -0,0 +1,109 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestClusterMetricsAggregate(t *testing.T) {
+	nodeMetrics := map[string]string{
+		"aws-node-a": `# TYPE awscni_total_ip_addresses gauge
+awscni_total_ip_addresses 10
+# TYPE awscni_assigned_ip_addresses gauge
+awscni_assigned_ip_addresses 4
+# TYPE awscni_ipamd_error_count counter
+awscni_ipamd_error_count{fn="nodeInit"} 1
+awscni_ipamd_error_count{fn="increaseIPPool"} 2
+`,
+		"aws-node-b": `# TYPE awscni_total_ip_addresses gauge
+awscni_total_ip_addresses 20
+# TYPE awscni_assigned_ip_addresses gauge
+awscni_assigned_ip_addresses 15
+`,
+		"aws-node-c": "not prometheus metrics",
+	}
+	aggregator := &clusterMetricsAggregator{
+		scrape: func(ctx context.Context, pod string) ([]byte, error) {
+			if out, ok := nodeMetrics[pod]; ok {
+				return []byte(out), nil
+			}
+			return nil, errors.New("connection refused")
+		},
+	}
+
+	// Only the leader reports the cluster metrics
+	aggregator.aggregate(context.Background(), []string{"aws-node-a", "aws-node-b", "aws-node-c", "aws-node-d"})
+	assert.Equal(t, 0, testutil.CollectAndCount(aggregator))
+
+	aggregator.setLeading(true)
+	assert.Equal(t, 0, testutil.CollectAndCount(aggregator))
+	aggregator.aggregate(context.Background(), []string{"aws-node-a", "aws-node-b", "aws-node-c", "aws-node-d"})
+	assert.Equal(t, len(clusterPoolMetrics)+2, testutil.CollectAndCount(aggregator))
+	expected := `
+# HELP awscni_cluster_assigned_ip_addresses The number of IP addresses assigned to pods in the cluster
+# TYPE awscni_cluster_assigned_ip_addresses gauge
+awscni_cluster_assigned_ip_addresses 19
+# HELP awscni_cluster_ipamd_error_count The number of errors encountered by ipamd in the cluster
+# TYPE awscni_cluster_ipamd_error_count gauge
+awscni_cluster_ipamd_error_count 3
+# HELP awscni_cluster_nodes_reporting The number of aws-node pods whose metrics are in the cluster metrics
+# TYPE awscni_cluster_nodes_reporting gauge
+awscni_cluster_nodes_reporting 2
+# HELP awscni_cluster_nodes_unreachable The number of aws-node pods whose metrics could not be scraped
+# TYPE awscni_cluster_nodes_unreachable gauge
+awscni_cluster_nodes_unreachable 2
+# HELP awscni_cluster_total_ip_addresses The total number of IP addresses in the cluster
+# TYPE awscni_cluster_total_ip_addresses gauge
+awscni_cluster_total_ip_addresses 30
+`
+	assert.NoError(t, testutil.CollectAndCompare(aggregator, strings.NewReader(expected),
+		"awscni_cluster_assigned_ip_addresses", "awscni_cluster_ipamd_error_count", "awscni_cluster_nodes_reporting",
+		"awscni_cluster_nodes_unreachable", "awscni_cluster_total_ip_addresses"))
+
+	aggregator.setLeading(false)
+	assert.Equal(t, 0, testutil.CollectAndCount(aggregator))
+}
+
+func TestClusterMetricsPeers(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+	ctx := context.Background()
+
+	for _, pod := range []*corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "aws-node-b", Namespace: metav1.NamespaceSystem, Labels: map[string]string{"k8s-app": "aws-node"}},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "aws-node-a", Namespace: metav1.NamespaceSystem, Labels: map[string]string{"k8s-app": "aws-node"}},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "aws-node-pending", Namespace: metav1.NamespaceSystem, Labels: map[string]string{"k8s-app": "aws-node"}},
+			Status: corev1.PodStatus{Phase: corev1.PodPending}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: metav1.NamespaceSystem, Labels: map[string]string{"k8s-app": "kube-dns"}},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning}},
+	} {
+		assert.NoError(t, m.rawK8SClient.Create(ctx, pod))
+	}
+
+	mockContext := &IPAMContext{rawK8SClient: m.rawK8SClient}
+	peers, err := mockContext.clusterMetricsPeers(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"aws-node-a", "aws-node-b"}, peers)
+}
@@ -0,0 +1,202 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/networkutils"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
+)
+
+const (
+	configSourceNode    = "node"
+	configSourceFile    = "file"
+	configSourceEnv     = "env"
+	configSourceDefault = "default"
+)
+
+// reloadableConfigKeys are the settings that can be set in the config file. Anything else needs a restart and has to
+// be set on the daemonset.
+var reloadableConfigKeys = []string{envWarmIPTarget, envWarmENITarget, envMinimumIPTarget, envWarmPrefixTarget,
+	envLogLevel, envExcludeSNATCIDRs}
+
+// nodeOverrideKeys maps the warm targets to the node annotation or label that overrides them
+var nodeOverrideKeys = map[string]string{
+	envWarmIPTarget:     warmIPTargetNodeKey,
+	envWarmENITarget:    warmENITargetNodeKey,
+	envMinimumIPTarget:  minimumIPTargetNodeKey,
+	envWarmPrefixTarget: warmPrefixTargetNodeKey,
+}
+
+// configFile holds the last content of the config file
+type configFile struct {
+	lock   sync.RWMutex
+	path   string
+	raw    []byte
+	values map[string]string
+}
+
+var fileConfig = &configFile{path: configFilePath()}
+
+// ConfigValue is an effective setting and where it comes from: a node override, the config file, the env or the default
+type ConfigValue struct {
+	Value  string
+	Source string
+}
+
+func configFilePath() string {
+	if path := os.Getenv(envConfigFile); path != "" {
+		return path
+	}
+	return defaultConfigFile
+}
+
+// lookupConfig returns the value of a setting from the config file if set there, and from the env otherwise
+func lookupConfig(name string) (string, bool) {
+	if value, found := fileConfig.get(name); found {
+		return value, true
+	}
+	return os.LookupEnv(name)
+}
+
+func (f *configFile) get(name string) (string, bool) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	value, found := f.values[name]
+	return value, found
+}
+
+// reload reads the config file again and returns true if its content changed. A missing file is the same as an
+// empty one.
+func (f *configFile) reload() (bool, error) {
+	raw, err := ioutil.ReadFile(f.path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, errors.Wrapf(err, "failed to read config file %s", f.path)
+	}
+
+	f.lock.RLock()
+	unchanged := bytes.Equal(raw, f.raw)
+	f.lock.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	var parsed map[string]interface{}
+	if err = yaml.Unmarshal(raw, &parsed); err != nil {
+		return false, errors.Wrapf(err, "failed to parse config file %s", f.path)
+	}
+	values := make(map[string]string)
+	for name, value := range parsed {
+		if !isReloadableConfigKey(name) {
+			log.Warnf("Ignoring %s in config file %s, it can only be set on the aws-node daemonset", name, f.path)
+			continue
+		}
+		values[name] = fmt.Sprint(value)
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.raw = raw
+	f.values = values
+	return true, nil
+}
+
+func isReloadableConfigKey(name string) bool {
+	for _, key := range reloadableConfigKeys {
+		if key == name {
+			return true
+		}
+	}
+	return false
+}
+
+// reloadConfigFile re-reads the config file and applies the log level. The warm targets are picked up by
+// updateWarmTargetsFromNode and the SNAT exclusions by updateExcludeSNATCIDRsFromConfigFile.
+func (c *IPAMContext) reloadConfigFile() {
+	changed, err := fileConfig.reload()
+	if err != nil {
+		log.Warnf("Keeping the previous configuration: %v", err)
+		ipamdErrInc("reloadConfigFile")
+		return
+	}
+	if !changed {
+		return
+	}
+	log.Infof("Loaded config file %s", fileConfig.path)
+	atomic.StoreInt32(&c.configFileSNATStale, 1)
+	logLevel, _ := lookupConfig(envLogLevel)
+	if logLevel == "" {
+		logLevel = logger.GetLogLevel()
+	}
+	logger.SetLogLevel(logLevel)
+}
+
+// updateExcludeSNATCIDRsFromConfigFile hands the AWS_VPC_K8S_CNI_EXCLUDE_SNAT_CIDRS value to the network client after
+// the config file changed. It returns true if the host iptables rules need to be reprogrammed.
+func (c *IPAMContext) updateExcludeSNATCIDRsFromConfigFile() bool {
+	if !atomic.CompareAndSwapInt32(&c.configFileSNATStale, 1, 0) {
+		return false
+	}
+	value, _ := lookupConfig(envExcludeSNATCIDRs)
+	var cidrs []string
+	if !c.networkClient.UseExternalSNAT() {
+		cidrs = networkutils.ParseExcludeSNATCIDRs(value)
+	}
+	changed := c.networkClient.SetExcludeSNATCIDRs(cidrs)
+	if changed {
+		log.Infof("SNAT exclusions changed to %v", cidrs)
+	}
+	return changed
+}
+
+// getEffectiveConfig returns the reloadable settings with their values and sources
+func (c *IPAMContext) getEffectiveConfig() map[string]ConfigValue {
+	node := &corev1.Node{}
+	err := c.cachedK8SClient.Get(context.TODO(), types.NamespacedName{Name: c.myNodeName}, node)
+	if err != nil {
+		log.Debugf("Failed to get node for warm target overrides: %v", err)
+		node = nil
+	}
+
+	config := make(map[string]ConfigValue)
+	for _, name := range reloadableConfigKeys {
+		if key, ok := nodeOverrideKeys[name]; ok && node != nil {
+			if value, found, _ := nodeWarmTargetOverride(node, key); found {
+				config[name] = ConfigValue{Value: strconv.Itoa(value), Source: configSourceNode}
+				continue
+			}
+		}
+		if value, found := fileConfig.get(name); found {
+			config[name] = ConfigValue{Value: value, Source: configSourceFile}
+		} else if value, found := os.LookupEnv(name); found {
+			config[name] = ConfigValue{Value: value, Source: configSourceEnv}
+		} else {
+			config[name] = ConfigValue{Source: configSourceDefault}
+		}
+	}
+	return config
+}
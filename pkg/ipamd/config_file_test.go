@@ -0,0 +1,116 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func useTestConfigFile(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	previous := fileConfig
+	fileConfig = &configFile{path: path}
+	t.Cleanup(func() { fileConfig = previous })
+	return path
+}
+
+func TestConfigFileReload(t *testing.T) {
+	path := useTestConfigFile(t)
+	_ = os.Setenv(envWarmENITarget, "2")
+	defer os.Unsetenv(envWarmENITarget)
+	_ = os.Unsetenv(envWarmIPTarget)
+
+	// A missing file is not an error
+	changed, err := fileConfig.reload()
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, 2, getWarmENITarget())
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("WARM_IP_TARGET: 5\nWARM_ENI_TARGET: \"0\"\nENABLE_IPv6: true\n"), 0644))
+	changed, err = fileConfig.reload()
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, 5, getWarmIPTarget())
+	assert.Equal(t, 0, getWarmENITarget()) // the file takes precedence over the env
+	_, found := lookupConfig(envEnableIPv6)
+	assert.False(t, found) // not reloadable
+
+	changed, err = fileConfig.reload()
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	// A broken file keeps the previous values
+	assert.NoError(t, ioutil.WriteFile(path, []byte("WARM_IP_TARGET: [5"), 0644))
+	_, err = fileConfig.reload()
+	assert.Error(t, err)
+	assert.Equal(t, 5, getWarmIPTarget())
+
+	assert.NoError(t, os.Remove(path))
+	changed, err = fileConfig.reload()
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, noWarmIPTarget, getWarmIPTarget())
+	assert.Equal(t, 2, getWarmENITarget())
+}
+
+func TestUpdateExcludeSNATCIDRsFromConfigFile(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+	path := useTestConfigFile(t)
+
+	mockContext := &IPAMContext{networkClient: m.network}
+
+	// Nothing to do until the file changes
+	assert.False(t, mockContext.updateExcludeSNATCIDRsFromConfigFile())
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("AWS_VPC_K8S_CNI_EXCLUDE_SNAT_CIDRS: 10.12.0.0/16,10.13.0.0/16\n"), 0644))
+	mockContext.reloadConfigFile()
+	m.network.EXPECT().UseExternalSNAT().Return(false)
+	m.network.EXPECT().SetExcludeSNATCIDRs([]string{"10.12.0.0/16", "10.13.0.0/16"}).Return(true)
+	assert.True(t, mockContext.updateExcludeSNATCIDRsFromConfigFile())
+	assert.False(t, mockContext.updateExcludeSNATCIDRsFromConfigFile())
+}
+
+func TestGetEffectiveConfig(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+	path := useTestConfigFile(t)
+	_ = os.Setenv(envWarmENITarget, "2")
+	defer os.Unsetenv(envWarmENITarget)
+	_ = os.Unsetenv(envWarmIPTarget)
+	_ = os.Unsetenv(envMinimumIPTarget)
+
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:        myNodeName,
+		Annotations: map[string]string{minimumIPTargetNodeKey: "20"},
+	}}
+	assert.NoError(t, m.cachedK8SClient.Create(context.Background(), node))
+	assert.NoError(t, ioutil.WriteFile(path, []byte("WARM_IP_TARGET: 5\n"), 0644))
+	_, _ = fileConfig.reload()
+
+	mockContext := &IPAMContext{cachedK8SClient: m.cachedK8SClient, myNodeName: myNodeName}
+	config := mockContext.getEffectiveConfig()
+	assert.Equal(t, ConfigValue{Value: "20", Source: configSourceNode}, config[envMinimumIPTarget])
+	assert.Equal(t, ConfigValue{Value: "5", Source: configSourceFile}, config[envWarmIPTarget])
+	assert.Equal(t, ConfigValue{Value: "2", Source: configSourceEnv}, config[envWarmENITarget])
+	assert.Equal(t, ConfigValue{Source: configSourceDefault}, config[envWarmPrefixTarget])
+}
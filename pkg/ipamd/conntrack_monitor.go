@@ -0,0 +1,110 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/eventrecorder"
+)
+
+const (
+	// conntrackPollInterval is how often the conntrack table usage is read
+	conntrackPollInterval = 30 * time.Second
+
+	// conntrackRecoveryMargin is how many points below the pressure threshold the usage has to fall to clear the
+	// pressure, so that a table hovering around the threshold doesn't raise an event on every poll
+	conntrackRecoveryMargin = 10
+
+	conntrackReasonPressure  = "ConntrackTablePressure"
+	conntrackReasonRecovered = "ConntrackTableRecovered"
+	conntrackReasonRaised    = "ConntrackMaxRaised"
+)
+
+// StartConntrackMonitor periodically reports the usage of the conntrack table of the node, raises an event on the node
+// when it gets close to full, as the kernel then silently drops the packets of new connections, and applies the
+// configured mitigations
+func (c *IPAMContext) StartConntrackMonitor() {
+	for {
+		if !c.checkConntrackUsage() {
+			return
+		}
+		time.Sleep(conntrackPollInterval)
+	}
+}
+
+// checkConntrackUsage reads the conntrack table usage once, and returns false if there is no conntrack table to
+// monitor
+func (c *IPAMContext) checkConntrackUsage() bool {
+	count, max, err := c.networkClient.GetConntrackUsage()
+	if err != nil {
+		log.Infof("Not monitoring the conntrack table: %v", err)
+		return false
+	}
+	conntrackEntries.Set(float64(count))
+	conntrackMax.Set(float64(max))
+	if max <= 0 {
+		return true
+	}
+
+	percent := count * 100 / max
+	underPressure := c.conntrackUnderPressure()
+	switch {
+	case !underPressure && percent >= c.conntrackPressureThreshold:
+		atomic.StoreInt32(&c.conntrackPressure, 1)
+		message := fmt.Sprintf("The conntrack table is %d%% full (%d of %d entries), new connections are dropped "+
+			"once it is full", percent, count, max)
+		log.Warn(message)
+		eventrecorder.Get().SendNodeEvent(corev1.EventTypeWarning, conntrackReasonPressure, message)
+	case underPressure && percent < c.conntrackPressureThreshold-conntrackRecoveryMargin:
+		atomic.StoreInt32(&c.conntrackPressure, 0)
+		message := fmt.Sprintf("The conntrack table is %d%% full (%d of %d entries)", percent, count, max)
+		log.Info(message)
+		eventrecorder.Get().SendNodeEvent(corev1.EventTypeNormal, conntrackReasonRecovered, message)
+	}
+	if percent >= c.conntrackPressureThreshold {
+		c.raiseConntrackMax(max)
+	}
+	return true
+}
+
+// raiseConntrackMax doubles the size of the conntrack table, up to CONNTRACK_MAX_LIMIT
+func (c *IPAMContext) raiseConntrackMax(max int) {
+	if c.conntrackMaxLimit <= max {
+		return
+	}
+	newMax := max * 2
+	if newMax > c.conntrackMaxLimit {
+		newMax = c.conntrackMaxLimit
+	}
+	if err := c.networkClient.SetConntrackMax(newMax); err != nil {
+		log.Errorf("Failed to raise the size of the conntrack table: %v", err)
+		ipamdErrInc("raiseConntrackMax")
+		return
+	}
+	conntrackMaxRaised.Inc()
+	conntrackMax.Set(float64(newMax))
+	message := fmt.Sprintf("Raised the size of the conntrack table from %d to %d entries", max, newMax)
+	log.Info(message)
+	eventrecorder.Get().SendNodeEvent(corev1.EventTypeNormal, conntrackReasonRaised, message)
+}
+
+// conntrackUnderPressure returns whether the conntrack table was above the pressure threshold at the last poll
+func (c *IPAMContext) conntrackUnderPressure() bool {
+	return atomic.LoadInt32(&c.conntrackPressure) == 1
+}
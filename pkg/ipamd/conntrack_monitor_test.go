@@ -0,0 +1,80 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/eventrecorder"
+)
+
+func TestCheckConntrackUsage(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	fakeRecorder := eventrecorder.InitMockEventRecorder(m.cachedK8SClient)
+	mockContext := &IPAMContext{
+		networkClient:              m.network,
+		conntrackPressureThreshold: 90,
+		conntrackMaxLimit:          300000,
+	}
+
+	m.network.EXPECT().GetConntrackUsage().Return(1000, 131072, nil)
+	assert.True(t, mockContext.checkConntrackUsage())
+	assert.False(t, mockContext.conntrackUnderPressure())
+	assert.Equal(t, float64(1000), testutil.ToFloat64(conntrackEntries))
+	assert.Len(t, fakeRecorder.Events, 0)
+
+	// Above the threshold, the table is doubled up to the limit
+	m.network.EXPECT().GetConntrackUsage().Return(120000, 131072, nil)
+	m.network.EXPECT().SetConntrackMax(262144).Return(nil)
+	assert.True(t, mockContext.checkConntrackUsage())
+	assert.True(t, mockContext.conntrackUnderPressure())
+	assert.Len(t, fakeRecorder.Events, 2)
+	assert.Equal(t, "Warning ConntrackTablePressure The conntrack table is 91% full (120000 of 131072 entries), "+
+		"new connections are dropped once it is full", <-fakeRecorder.Events)
+	assert.Equal(t, "Normal ConntrackMaxRaised Raised the size of the conntrack table from 131072 to 262144 entries",
+		<-fakeRecorder.Events)
+	assert.Equal(t, float64(262144), testutil.ToFloat64(conntrackMax))
+
+	m.network.EXPECT().GetConntrackUsage().Return(250000, 262144, nil)
+	m.network.EXPECT().SetConntrackMax(300000).Return(nil)
+	assert.True(t, mockContext.checkConntrackUsage())
+	assert.Len(t, fakeRecorder.Events, 1)
+	<-fakeRecorder.Events
+
+	// At the limit, the pressure is only reported
+	m.network.EXPECT().GetConntrackUsage().Return(290000, 300000, nil)
+	assert.True(t, mockContext.checkConntrackUsage())
+	assert.Len(t, fakeRecorder.Events, 0)
+
+	// Just below the threshold is not enough to recover
+	m.network.EXPECT().GetConntrackUsage().Return(260000, 300000, nil)
+	assert.True(t, mockContext.checkConntrackUsage())
+	assert.True(t, mockContext.conntrackUnderPressure())
+
+	m.network.EXPECT().GetConntrackUsage().Return(100000, 300000, nil)
+	assert.True(t, mockContext.checkConntrackUsage())
+	assert.False(t, mockContext.conntrackUnderPressure())
+	assert.Equal(t, "Normal ConntrackTableRecovered The conntrack table is 33% full (100000 of 300000 entries)",
+		<-fakeRecorder.Events)
+
+	// Without the conntrack module, there is nothing to monitor
+	m.network.EXPECT().GetConntrackUsage().Return(0, 0, errors.New("no such file or directory"))
+	assert.False(t, mockContext.checkConntrackUsage())
+}
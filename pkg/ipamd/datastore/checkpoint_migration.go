@@ -0,0 +1,162 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datastore
+
+import (
+	"fmt"
+	"net"
+	"os"
+)
+
+// Sources of the allocations read by MigrateBackingStore
+const (
+	CheckpointSourceFile = "file"
+	CheckpointSourceCRI  = "cri"
+	CheckpointSourceNone = "none"
+)
+
+// checkpointUpgrade converts a checkpoint to the format version To
+type checkpointUpgrade struct {
+	To      string
+	Convert func(data *CheckpointData) error
+}
+
+// checkpointUpgrades is keyed by the format version a checkpointUpgrade converts from. Add an entry here whenever
+// CheckpointFormatVersion changes, so that nodes can skip CNI versions on upgrade without losing allocations.
+var checkpointUpgrades = map[string]checkpointUpgrade{}
+
+// CheckpointMigrationOptions controls MigrateBackingStore
+type CheckpointMigrationOptions struct {
+	// IPv6 is set when the allocations are IPv6 addresses
+	IPv6 bool
+	// ReadCRI reads the allocations from CRI when there is no checkpoint file, which is where CNI 1.6 and earlier
+	// kept them
+	ReadCRI bool
+	// DryRun only reports what the migration would do, without writing the checkpoint
+	DryRun bool
+}
+
+// CheckpointMigrationReport describes what MigrateBackingStore found and did
+type CheckpointMigrationReport struct {
+	Source        string
+	SourceVersion string `json:",omitempty"`
+	TargetVersion string
+	Allocations   int
+	// Dropped lists the allocations that were invalid or duplicated another allocation of the same IP
+	Dropped []string `json:",omitempty"`
+	Changed bool
+	Written bool
+}
+
+// MigrateBackingStore reads the checkpoint in any format version this datastore knows, converts it to
+// CheckpointFormatVersion and drops invalid and duplicate allocations. The result is written back unless nothing changed
+// or opts.DryRun is set.
+func (ds *DataStore) MigrateBackingStore(opts CheckpointMigrationOptions) (*CheckpointMigrationReport, error) {
+	_, report, err := ds.migrateBackingStore(opts)
+	return report, err
+}
+
+func (ds *DataStore) migrateBackingStore(opts CheckpointMigrationOptions) (*CheckpointData, *CheckpointMigrationReport, error) {
+	report := &CheckpointMigrationReport{TargetVersion: CheckpointFormatVersion}
+
+	var data CheckpointData
+	err := ds.backingStore.Restore(&data)
+	switch {
+	case os.IsNotExist(err) && !opts.ReadCRI:
+		report.Source = CheckpointSourceNone
+		return nil, report, nil
+	case os.IsNotExist(err):
+		report.Source = CheckpointSourceCRI
+		entries, err := ds.readCRIAllocations(opts.IPv6)
+		if err != nil {
+			return nil, nil, fmt.Errorf("datastore: error reading allocations from CRI: %v", err)
+		}
+		data = CheckpointData{Version: CheckpointFormatVersion, Allocations: entries}
+		report.Changed = true
+	case err != nil:
+		return nil, nil, fmt.Errorf("datastore: error reading backing store: %v", err)
+	default:
+		report.Source = CheckpointSourceFile
+		report.SourceVersion = data.Version
+		if err := upgradeCheckpoint(&data); err != nil {
+			return nil, nil, err
+		}
+		report.Changed = report.SourceVersion != CheckpointFormatVersion
+	}
+
+	data.Allocations, report.Dropped = validateAllocations(data.Allocations, opts.IPv6)
+	report.Allocations = len(data.Allocations)
+	if len(report.Dropped) > 0 {
+		report.Changed = true
+	}
+
+	if report.Changed && !opts.DryRun {
+		if err := ds.backingStore.Checkpoint(&data); err != nil {
+			return nil, nil, fmt.Errorf("datastore: error writing migrated backing store: %v", err)
+		}
+		report.Written = true
+	}
+	return &data, report, nil
+}
+
+// upgradeCheckpoint converts data to CheckpointFormatVersion, one format version at a time
+func upgradeCheckpoint(data *CheckpointData) error {
+	seen := make(map[string]bool)
+	for data.Version != CheckpointFormatVersion {
+		upgrade, ok := checkpointUpgrades[data.Version]
+		if !ok || seen[data.Version] {
+			return fmt.Errorf("datastore: unknown backing store format (%s != %s) - wrong CNI/ipamd version? (Rebooting this node will restart local pods and probably help)", data.Version, CheckpointFormatVersion)
+		}
+		seen[data.Version] = true
+		if err := upgrade.Convert(data); err != nil {
+			return fmt.Errorf("datastore: error converting backing store from %s to %s: %v", data.Version, upgrade.To, err)
+		}
+		data.Version = upgrade.To
+	}
+	return nil
+}
+
+// validateAllocations drops the allocations without a container ID or a valid IP. When an IP is allocated more than
+// once, only the most recent allocation is kept, since the older ones belong to sandboxes that were never deleted.
+func validateAllocations(allocations []CheckpointEntry, isv6Enabled bool) ([]CheckpointEntry, []string) {
+	var dropped []string
+	valid := make([]CheckpointEntry, 0, len(allocations))
+	byIP := make(map[string]int)
+	for _, allocation := range allocations {
+		ip := allocation.IPv4
+		if isv6Enabled {
+			ip = allocation.IPv6
+		}
+		switch {
+		case allocation.ContainerID == "":
+			dropped = append(dropped, fmt.Sprintf("%s: no container ID", ip))
+			continue
+		case net.ParseIP(ip) == nil:
+			dropped = append(dropped, fmt.Sprintf("%s: invalid IP %q", allocation.ContainerID, ip))
+			continue
+		}
+		if i, ok := byIP[ip]; ok {
+			older := allocation
+			if allocation.AllocationTimestamp > valid[i].AllocationTimestamp {
+				older = valid[i]
+				valid[i] = allocation
+			}
+			dropped = append(dropped, fmt.Sprintf("%s: IP %s is also allocated to a newer sandbox", older.ContainerID, ip))
+			continue
+		}
+		byIP[ip] = len(valid)
+		valid = append(valid, allocation)
+	}
+	return valid, dropped
+}
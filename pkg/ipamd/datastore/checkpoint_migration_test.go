@@ -0,0 +1,253 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datastore
+
+import (
+	"errors"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/cri"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
+)
+
+func TestMigrateBackingStore(t *testing.T) {
+	// A format version from the past, that stored the pod name in the network name
+	checkpointUpgrades["vpc-cni-ipam/0"] = checkpointUpgrade{
+		To: CheckpointFormatVersion,
+		Convert: func(data *CheckpointData) error {
+			for i := range data.Allocations {
+				data.Allocations[i].Metadata.K8SPodName = data.Allocations[i].NetworkName
+				data.Allocations[i].NetworkName = "net0"
+			}
+			return nil
+		},
+	}
+	defer delete(checkpointUpgrades, "vpc-cni-ipam/0")
+
+	current := CheckpointData{
+		Version: CheckpointFormatVersion,
+		Allocations: []CheckpointEntry{
+			{IPAMKey: IPAMKey{"net0", "sandbox-1", "eth0"}, IPv4: "10.0.0.1"},
+		},
+	}
+
+	t.Run("current version is not rewritten", func(t *testing.T) {
+		checkpoint := NewTestCheckpoint(current)
+		ds := NewDataStore(Testlog, checkpoint, false)
+		report, err := ds.MigrateBackingStore(CheckpointMigrationOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, CheckpointSourceFile, report.Source)
+		assert.Equal(t, 1, report.Allocations)
+		assert.False(t, report.Changed)
+		assert.False(t, report.Written)
+	})
+
+	t.Run("older version is upgraded", func(t *testing.T) {
+		checkpoint := NewTestCheckpoint(CheckpointData{
+			Version: "vpc-cni-ipam/0",
+			Allocations: []CheckpointEntry{
+				{IPAMKey: IPAMKey{"pod-1", "sandbox-1", "eth0"}, IPv4: "10.0.0.1"},
+			},
+		})
+		ds := NewDataStore(Testlog, checkpoint, false)
+		report, err := ds.MigrateBackingStore(CheckpointMigrationOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, "vpc-cni-ipam/0", report.SourceVersion)
+		assert.True(t, report.Written)
+
+		var data CheckpointData
+		assert.NoError(t, checkpoint.Restore(&data))
+		assert.Equal(t, CheckpointFormatVersion, data.Version)
+		assert.Equal(t, "net0", data.Allocations[0].NetworkName)
+		assert.Equal(t, "pod-1", data.Allocations[0].Metadata.K8SPodName)
+	})
+
+	t.Run("dry run does not write", func(t *testing.T) {
+		old := CheckpointData{
+			Version: "vpc-cni-ipam/0",
+			Allocations: []CheckpointEntry{
+				{IPAMKey: IPAMKey{"pod-1", "sandbox-1", "eth0"}, IPv4: "10.0.0.1"},
+			},
+		}
+		checkpoint := NewTestCheckpoint(old)
+		ds := NewDataStore(Testlog, checkpoint, false)
+		report, err := ds.MigrateBackingStore(CheckpointMigrationOptions{DryRun: true})
+		assert.NoError(t, err)
+		assert.True(t, report.Changed)
+		assert.False(t, report.Written)
+		assert.Equal(t, old, checkpoint.Data)
+	})
+
+	t.Run("unknown version", func(t *testing.T) {
+		ds := NewDataStore(Testlog, NewTestCheckpoint(CheckpointData{Version: "vpc-cni-ipam/99"}), false)
+		_, err := ds.MigrateBackingStore(CheckpointMigrationOptions{})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid and duplicate allocations are dropped", func(t *testing.T) {
+		checkpoint := NewTestCheckpoint(CheckpointData{
+			Version: CheckpointFormatVersion,
+			Allocations: []CheckpointEntry{
+				{IPAMKey: IPAMKey{"net0", "sandbox-1", "eth0"}, IPv4: "10.0.0.1", AllocationTimestamp: 1},
+				{IPAMKey: IPAMKey{"net0", "sandbox-2", "eth0"}, IPv4: "10.0.0.1", AllocationTimestamp: 2},
+				{IPAMKey: IPAMKey{"net0", "sandbox-3", "eth0"}, IPv4: "not-an-ip"},
+				{IPAMKey: IPAMKey{"net0", "", "eth0"}, IPv4: "10.0.0.3"},
+			},
+		})
+		ds := NewDataStore(Testlog, checkpoint, false)
+		report, err := ds.MigrateBackingStore(CheckpointMigrationOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, report.Allocations)
+		assert.Len(t, report.Dropped, 3)
+		assert.True(t, report.Written)
+
+		var data CheckpointData
+		assert.NoError(t, checkpoint.Restore(&data))
+		assert.Len(t, data.Allocations, 1)
+		assert.Equal(t, "sandbox-2", data.Allocations[0].ContainerID)
+	})
+
+	t.Run("no checkpoint file", func(t *testing.T) {
+		ds := NewDataStore(Testlog, NullCheckpoint{}, false)
+		report, err := ds.MigrateBackingStore(CheckpointMigrationOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, CheckpointSourceNone, report.Source)
+		assert.False(t, report.Written)
+	})
+
+	t.Run("no checkpoint file, read from CRI", func(t *testing.T) {
+		checkpoint := NewTestCheckpoint(nil)
+		ds := NewDataStore(Testlog, &unreadableCheckpoint{checkpoint, os.ErrNotExist}, false)
+		ds.cri = fakeCRI{
+			{ID: "sandbox-1", IPs: []string{"10.0.0.1"}, Metadata: &cri.PodSandboxMetadata{Namespace: "default", Name: "pod-1"}},
+		}
+		report, err := ds.MigrateBackingStore(CheckpointMigrationOptions{ReadCRI: true})
+		assert.NoError(t, err)
+		assert.Equal(t, CheckpointSourceCRI, report.Source)
+		assert.Equal(t, 1, report.Allocations)
+		assert.True(t, report.Written)
+
+		data := checkpoint.Data.(*CheckpointData)
+		assert.Equal(t, "pod-1", data.Allocations[0].Metadata.K8SPodName)
+		assert.Equal(t, backfillNetworkName, data.Allocations[0].NetworkName)
+	})
+}
+
+// fakeCRI returns a fixed list of sandboxes
+type fakeCRI []cri.SandboxInfo
+
+func (c fakeCRI) GetRunningPodSandboxes(log logger.Logger) ([]cri.SandboxInfo, error) {
+	return c, nil
+}
+
+// unreadableCheckpoint fails to restore with restoreErr, but keeps what is written to it
+type unreadableCheckpoint struct {
+	*TestCheckpoint
+	restoreErr error
+}
+
+func (c *unreadableCheckpoint) Restore(into interface{}) error {
+	return c.restoreErr
+}
+
+func TestReadBackingStoreMigratesCheckpoint(t *testing.T) {
+	checkpointUpgrades["vpc-cni-ipam/0"] = checkpointUpgrade{
+		To:      CheckpointFormatVersion,
+		Convert: func(data *CheckpointData) error { return nil },
+	}
+	defer delete(checkpointUpgrades, "vpc-cni-ipam/0")
+
+	checkpoint := NewTestCheckpoint(CheckpointData{
+		Version: "vpc-cni-ipam/0",
+		Allocations: []CheckpointEntry{
+			{IPAMKey: IPAMKey{"net0", "sandbox-1", "eth0"}, IPv4: "10.0.0.1"},
+		},
+	})
+	ds := NewDataStore(Testlog, checkpoint, false)
+	ds.CheckpointMigrationPhase = 2
+	assert.NoError(t, ds.AddENI("eni-1", 1, true, false, false))
+	ipv4Addr := net.IPNet{IP: net.ParseIP("10.0.0.1"), Mask: net.IPv4Mask(255, 255, 255, 255)}
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", ipv4Addr, false))
+
+	assert.NoError(t, ds.ReadBackingStore(false))
+	assert.Equal(t, 1, ds.assigned)
+}
+
+func TestReadBackingStoreRecovery(t *testing.T) {
+	recovery := func(isv6Enabled bool) ([]RecoveredAddress, error) {
+		return []RecoveredAddress{{IP: "10.0.0.1", HostVeth: "eni1a2b3c4d5e6"}}, nil
+	}
+	newDataStore := func(checkpoint Checkpointer) *DataStore {
+		ds := NewDataStore(Testlog, checkpoint, false)
+		ds.CheckpointMigrationPhase = 2
+		assert.NoError(t, ds.AddENI("eni-1", 1, true, false, false))
+		ipv4Addr := net.IPNet{IP: net.ParseIP("10.0.0.1"), Mask: net.IPv4Mask(255, 255, 255, 255)}
+		assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", ipv4Addr, false))
+		return ds
+	}
+
+	t.Run("corrupt checkpoint without recovery", func(t *testing.T) {
+		ds := newDataStore(&unreadableCheckpoint{NewTestCheckpoint(nil), errors.New("unexpected EOF")})
+		assert.Error(t, ds.ReadBackingStore(false))
+	})
+
+	t.Run("corrupt checkpoint", func(t *testing.T) {
+		ds := newDataStore(&unreadableCheckpoint{NewTestCheckpoint(nil), errors.New("unexpected EOF")})
+		ds.SetAllocationRecovery(recovery)
+		assert.NoError(t, ds.ReadBackingStore(false))
+		assert.Equal(t, 1, ds.assigned)
+	})
+
+	t.Run("missing checkpoint", func(t *testing.T) {
+		checkpoint := NewTestCheckpoint(nil)
+		ds := newDataStore(&unreadableCheckpoint{checkpoint, os.ErrNotExist})
+		ds.SetAllocationRecovery(recovery)
+		assert.NoError(t, ds.ReadBackingStore(false))
+		assert.Equal(t, 1, ds.assigned)
+
+		// The recovered state is written to the checkpoint
+		data := checkpoint.Data.(*CheckpointData)
+		assert.Equal(t, RecoveredIPAMKey("eni1a2b3c4d5e6"), data.Allocations[0].IPAMKey)
+
+		// and the IP is released by the pod's host veth
+		_, ip, _, err := ds.UnassignPodIPAddress(RecoveredIPAMKey("eni1a2b3c4d5e6"))
+		assert.NoError(t, err)
+		assert.Equal(t, "10.0.0.1", ip)
+	})
+
+	t.Run("CRI failure", func(t *testing.T) {
+		ds := newDataStore(NullCheckpoint{})
+		ds.CheckpointMigrationPhase = 1
+		ds.cri = failingCRI{}
+		assert.Error(t, ds.ReadBackingStore(false))
+
+		ds = newDataStore(NullCheckpoint{})
+		ds.CheckpointMigrationPhase = 1
+		ds.cri = failingCRI{}
+		ds.SetAllocationRecovery(recovery)
+		assert.NoError(t, ds.ReadBackingStore(false))
+		assert.Equal(t, 1, ds.assigned)
+	})
+}
+
+// failingCRI can't be reached
+type failingCRI struct{}
+
+func (c failingCRI) GetRunningPodSandboxes(log logger.Logger) ([]cri.SandboxInfo, error) {
+	return nil, errors.New("connection refused")
+}
@@ -16,11 +16,13 @@ package datastore
 import (
 	"fmt"
 	"net"
-	"os"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/cri"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/audit"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
@@ -78,9 +80,27 @@ const checkpointMigrationPhase = 1
 const backfillNetworkName = "_migrated-from-cri"
 const backfillNetworkIface = "unknown"
 
+// recoveredNetworkName is the network name of the allocations recovered from the pod routes. Their container ID is the
+// name of the host-side veth device, see RecoveredIPAMKey.
+const recoveredNetworkName = "_recovered-from-routes"
+
+// importedNetworkName is the network name of the addresses reserved for the pods of an imported pool state, until the
+// pods start on the node. Their container ID is the namespace and name of the pod, see ImportedIPAMKey.
+const importedNetworkName = "_imported-pool-state"
+
+// warmVethNetworkName is the network name of the addresses of the veth pairs pre-plumbed by ipamd, until a pod takes
+// one. Their container ID is the name of the host-side veth device, see WarmVethIPAMKey.
+const warmVethNetworkName = "_warm-veth"
+
 // ErrUnknownPod is an error when there is no pod in data store matching pod name, namespace, sandbox id
 var ErrUnknownPod = errors.New("datastore: unknown pod")
 
+// ErrNoAvailableIPs is an error when the data store has no free IP address left to assign to a pod
+var ErrNoAvailableIPs = errors.New("no available IP/Prefix addresses")
+
+// ErrPinnedAddressUnavailable is an error when none of the free IP addresses satisfy the AddressPin of a pod
+var ErrPinnedAddressUnavailable = errors.New("no available IP address matches the address pin")
+
 var (
 	enis = prometheus.NewGauge(
 		prometheus.GaugeOpts{
@@ -125,6 +145,20 @@ var (
 		},
 		[]string{"cidr"},
 	)
+	rekeyedSandboxes = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "awscni_rekeyed_sandboxes",
+			Help: "The number of sandboxes whose addresses were handed over to a new sandbox of the same pod",
+		},
+	)
+	lockWaitSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "awscni_datastore_lock_wait_seconds",
+			Help:    "The time spent waiting for the datastore lock",
+			Buckets: prometheus.ExponentialBuckets(0.00001, 4, 10),
+		},
+		[]string{"fn", "mode"},
+	)
 	prometheusRegistered = false
 )
 
@@ -152,6 +186,9 @@ func (k IPAMKey) String() string {
 type IPAMMetadata struct {
 	K8SPodNamespace string `json:"k8sPodNamespace,omitempty"`
 	K8SPodName      string `json:"k8sPodName,omitempty"`
+	// K8SPodUID is set when the CNI plugin passes it, and tells apart the sandboxes of a pod from the ones of an
+	// earlier pod of the same name
+	K8SPodUID string `json:"k8sPodUID,omitempty"`
 }
 
 // ENI represents a single ENI. Exported fields will be marshaled for introspection.
@@ -167,6 +204,9 @@ type ENI struct {
 	IsEFA bool
 	// DeviceNumber is the device number of ENI (0 means the primary ENI)
 	DeviceNumber int
+	// MixedMode is true when prefix delegation is enabled but the ENI also hands out secondary IPs, because the subnet
+	// was too fragmented to assign a /28 prefix to it
+	MixedMode bool
 	// IPv4Addresses shows whether each address is assigned, the key is IP address, which must
 	// be in dot-decimal notation with no leading zeros and no whitespace(eg: "10.1.0.253")
 	// Key is the IP address - PD: "IP/28" and SIP: "IP/32"
@@ -183,6 +223,9 @@ type AddressInfo struct {
 	IPAMMetadata   IPAMMetadata
 	AssignedTime   time.Time
 	UnassignedTime time.Time
+	// QuarantinedUntil is when the address goes back in rotation after QuarantineIP, zero if it is not quarantined
+	QuarantinedUntil time.Time `json:",omitempty"`
+	QuarantineReason string    `json:",omitempty"`
 }
 
 // CidrInfo
@@ -195,6 +238,8 @@ type CidrInfo struct {
 	IsPrefix bool
 	//IP Address Family of the Cidr
 	AddressFamily string
+	//Class tells whether the IPv4 addresses of the Cidr are routable outside the VPC
+	Class RoutabilityClass `json:",omitempty"`
 }
 
 func (cidr *CidrInfo) Size() int {
@@ -247,8 +292,9 @@ func (cidr *CidrInfo) AssignedIPAddressesInCidr() int {
 }
 
 type CidrStats struct {
-	AssignedIPs int
-	CooldownIPs int
+	AssignedIPs    int
+	CooldownIPs    int
+	QuarantinedIPs int
 }
 
 // Gets number of assigned IPs and the IPs in cooldown from a given CIDR
@@ -257,6 +303,8 @@ func (cidr *CidrInfo) GetIPStatsFromCidr() CidrStats {
 	for _, addr := range cidr.IPAddresses {
 		if addr.Assigned() {
 			stats.AssignedIPs++
+		} else if addr.quarantined() {
+			stats.QuarantinedIPs++
 		} else if addr.inCoolingPeriod() {
 			stats.CooldownIPs++
 		}
@@ -303,6 +351,8 @@ type PodIPInfo struct {
 	IP string
 	// DeviceNumber is the device number of the ENI
 	DeviceNumber int
+	// Metadata is the pod metadata recorded when the IP was assigned
+	Metadata IPAMMetadata
 }
 
 // DataStore contains node level ENI/IP
@@ -311,12 +361,63 @@ type DataStore struct {
 	assigned                 int
 	allocatedPrefix          int
 	eniPool                  ENIPool
-	lock                     sync.Mutex
+	lock                     sync.RWMutex
 	log                      logger.Logger
 	CheckpointMigrationPhase int
 	backingStore             Checkpointer
 	cri                      cri.APIs
 	isPDEnabled              bool
+	allocationRecovery       AllocationRecovery
+	// trunkCapacity is the number of branch ENIs the trunk ENI can hold, 0 if not known
+	trunkCapacity int
+	// branchENIs is the number of branch ENIs of each pod on the trunk ENI
+	branchENIs map[IPAMMetadata]int
+	// requests are the recently completed requests with a request ID, for their retries
+	requests map[string]requestRecord
+	// nonRoutableCIDRs hold the IPv4 addresses of ClassNonRoutable
+	nonRoutableCIDRs []net.IPNet
+}
+
+// RecoveredAddress is the IP address of a pod found without the checkpoint or CRI
+type RecoveredAddress struct {
+	IP       string
+	HostVeth string
+}
+
+// AllocationRecovery returns the IP addresses in use by pods, from a source that survives the loss of the checkpoint
+type AllocationRecovery func(isv6Enabled bool) ([]RecoveredAddress, error)
+
+// SetAllocationRecovery sets the source ReadBackingStore falls back to when the checkpoint is missing or can't be read,
+// or when CRI can't be queried
+func (ds *DataStore) SetAllocationRecovery(recovery AllocationRecovery) {
+	ds.allocationRecovery = recovery
+}
+
+// RecoveredIPAMKey returns the key of an allocation recovered by the AllocationRecovery
+func RecoveredIPAMKey(hostVeth string) IPAMKey {
+	return IPAMKey{
+		NetworkName: recoveredNetworkName,
+		ContainerID: hostVeth,
+		IfName:      backfillNetworkIface,
+	}
+}
+
+// ImportedIPAMKey returns the key of an address reserved for a pod of an imported pool state
+func ImportedIPAMKey(namespace, name string) IPAMKey {
+	return IPAMKey{
+		NetworkName: importedNetworkName,
+		ContainerID: namespace + "/" + name,
+		IfName:      backfillNetworkIface,
+	}
+}
+
+// WarmVethIPAMKey returns the key of the address of a pre-plumbed veth pair
+func WarmVethIPAMKey(hostVeth string) IPAMKey {
+	return IPAMKey{
+		NetworkName: warmVethNetworkName,
+		ContainerID: hostVeth,
+		IfName:      backfillNetworkIface,
+	}
 }
 
 // ENIInfos contains ENI IP information
@@ -327,6 +428,8 @@ type ENIInfos struct {
 	AssignedIPs int
 	// ENIs contains ENI IP pool information
 	ENIs map[string]ENI
+	// NextOffset is the offset of the next page of ENIs of a QueryENIInfos, or 0 if this is the last one
+	NextOffset int `json:",omitempty"`
 }
 
 func prometheusRegister() {
@@ -338,10 +441,32 @@ func prometheusRegister() {
 		prometheus.MustRegister(forceRemovedIPs)
 		prometheus.MustRegister(totalPrefixes)
 		prometheus.MustRegister(ipsPerCidr)
+		prometheus.MustRegister(lockWaitSeconds)
+		prometheus.MustRegister(rekeyedSandboxes)
+		prometheus.MustRegister(retriedRequests)
+		prometheus.MustRegister(trunkBranchENIsUsed)
+		prometheus.MustRegister(trunkBranchENIsFree)
+		prometheus.MustRegister(quarantinedIPs)
+		prometheus.MustRegister(ipQuarantines)
 		prometheusRegistered = true
 	}
 }
 
+// writeLock takes the datastore lock for writing, recording how long fn waited for it
+func (ds *DataStore) writeLock(fn string) {
+	start := time.Now()
+	ds.lock.Lock()
+	lockWaitSeconds.WithLabelValues(fn, "write").Observe(time.Since(start).Seconds())
+}
+
+// readLock takes the datastore lock for reading, recording how long fn waited for it. Read paths such as
+// introspection and the pool stats can then run alongside each other, and only wait for ADD/DEL and the reconciler.
+func (ds *DataStore) readLock(fn string) {
+	start := time.Now()
+	ds.lock.RLock()
+	lockWaitSeconds.WithLabelValues(fn, "read").Observe(time.Since(start).Seconds())
+}
+
 // NewDataStore returns DataStore structure
 func NewDataStore(log logger.Logger, backingStore Checkpointer, isPDEnabled bool) *DataStore {
 	prometheusRegister()
@@ -352,6 +477,8 @@ func NewDataStore(log logger.Logger, backingStore Checkpointer, isPDEnabled bool
 		cri:                      cri.New(),
 		CheckpointMigrationPhase: checkpointMigrationPhase,
 		isPDEnabled:              isPDEnabled,
+		branchENIs:               make(map[IPAMMetadata]int),
+		requests:                 make(map[string]requestRecord),
 	}
 }
 
@@ -364,6 +491,10 @@ const CheckpointFormatVersion = "vpc-cni-ipam/1"
 type CheckpointData struct {
 	Version     string            `json:"version"`
 	Allocations []CheckpointEntry `json:"allocations"`
+	// Requests are the recently completed requests with a request ID
+	Requests []CheckpointRequest `json:"requests,omitempty"`
+	// MixedModeENIs are the ENIs that hand out secondary IPs next to their prefixes, see ENI.MixedMode
+	MixedModeENIs []string `json:"mixedModeENIs,omitempty"`
 }
 
 // CheckpointEntry is a "row" in the conceptual IPAM datastore, as stored
@@ -381,48 +512,16 @@ type CheckpointEntry struct {
 // store.
 func (ds *DataStore) ReadBackingStore(isv6Enabled bool) error {
 	var data CheckpointData
+	recovered := false
 
 	switch ds.CheckpointMigrationPhase {
 	case 1:
 		// Phase1: Read from CRI
 		ds.log.Infof("Reading ipam state from CRI")
-		var ipv4Addr, ipv6Addr string
-		sandboxes, err := ds.cri.GetRunningPodSandboxes(ds.log)
+		entries, err := ds.readCRIAllocations(isv6Enabled)
 		if err != nil {
-			return err
-		}
-
-		entries := make([]CheckpointEntry, 0, len(sandboxes))
-		for _, s := range sandboxes {
-			ds.log.Debugf("Adding container ID: %v", s.ID)
-
-			metadata := IPAMMetadata{}
-			// both containerd and dockershim populates the metadata, just be cautious to have this null check.
-			if s.Metadata != nil {
-				metadata.K8SPodNamespace = s.Metadata.Namespace
-				metadata.K8SPodName = s.Metadata.Name
-			}
-
-			// note: ideally each sandbox should only contain one IP only,
-			// looping through them here is just to keep legacy code's behavior.
-			for _, ip := range s.IPs {
-				if isv6Enabled {
-					ipv6Addr = ip
-				} else {
-					ipv4Addr = ip
-				}
-				entries = append(entries, CheckpointEntry{
-					// NB: These Backfill values are also assumed in UnassignPodIPAddress
-					IPAMKey: IPAMKey{
-						NetworkName: backfillNetworkName,
-						ContainerID: s.ID,
-						IfName:      backfillNetworkIface,
-					},
-					IPv4:                ipv4Addr,
-					IPv6:                ipv6Addr,
-					AllocationTimestamp: s.CreationTimestamp,
-					Metadata:            metadata,
-				})
+			if entries, err = ds.recoverAllocations(isv6Enabled, err); err != nil {
+				return err
 			}
 		}
 		data = CheckpointData{
@@ -431,32 +530,54 @@ func (ds *DataStore) ReadBackingStore(isv6Enabled bool) error {
 		}
 
 	case 2:
-		// Phase2: Read from checkpoint file
+		// Phase2: Read from checkpoint file, converting it from older versions
 		ds.log.Infof("Reading ipam state from backing store")
 
-		err := ds.backingStore.Restore(&data)
+		migrated, report, err := ds.migrateBackingStore(CheckpointMigrationOptions{IPv6: isv6Enabled})
 		ds.log.Debugf("backing store restore returned err %v", err)
-		if os.IsNotExist(err) {
+		switch {
+		case err != nil || report.Source == CheckpointSourceNone && ds.allocationRecovery != nil:
+			if err == nil {
+				err = errors.New("no backing store")
+			}
+			entries, err := ds.recoverAllocations(isv6Enabled, err)
+			if err != nil {
+				return err
+			}
+			data = CheckpointData{
+				Version:     CheckpointFormatVersion,
+				Allocations: entries,
+			}
+			recovered = true
+		case report.Source == CheckpointSourceNone:
 			// Assume that no file == no containers are
 			// currently in use, eg a fresh reboot just
 			// cleared everything out.  This is ok, and a
 			// no-op.
 			return nil
-		} else if err != nil {
-			return fmt.Errorf("datastore: error reading backing store: %v", err)
-		}
-
-		if data.Version != CheckpointFormatVersion {
-			return fmt.Errorf("datastore: unknown backing store format (%s != %s) - wrong CNI/ipamd version? (Rebooting this node will restart local pods and probably help)", data.Version, CheckpointFormatVersion)
+		default:
+			if report.Changed {
+				ds.log.Infof("Migrated backing store from version %s to %s, dropped allocations: %v",
+					report.SourceVersion, report.TargetVersion, report.Dropped)
+			}
+			data = *migrated
 		}
 
 	default:
 		panic(fmt.Sprintf("Unexpected value of checkpointMigrationPhase: %v", ds.CheckpointMigrationPhase))
 	}
 
-	ds.lock.Lock()
+	ds.writeLock("ReadBackingStore")
 	defer ds.lock.Unlock()
 
+	ds.restoreRequestsUnsafe(data.Requests)
+	for _, eniID := range data.MixedModeENIs {
+		if eni, ok := ds.eniPool[eniID]; ok {
+			ds.log.Infof("ENI %s is in mixed mode, handing out secondary IPs next to prefixes", eniID)
+			eni.MixedMode = true
+		}
+	}
+
 	for _, allocation := range data.Allocations {
 		ipv4Addr := net.ParseIP(allocation.IPv4)
 		ipv6Addr := net.ParseIP(allocation.IPv6)
@@ -496,8 +617,8 @@ func (ds *DataStore) ReadBackingStore(isv6Enabled bool) error {
 		}
 	}
 
-	if ds.CheckpointMigrationPhase == 1 {
-		// For phase1: write whatever we just read above from
+	if ds.CheckpointMigrationPhase == 1 || recovered {
+		// For phase1 and recovered state: write whatever we just read above from
 		// CRI to backingstore immediately - just in case we
 		// _never_ see an add/del request before we upgrade to
 		// phase2.
@@ -510,6 +631,80 @@ func (ds *DataStore) ReadBackingStore(isv6Enabled bool) error {
 	return nil
 }
 
+// recoverAllocations returns the allocations found by the AllocationRecovery, after the usual source of the allocations
+// failed with cause
+func (ds *DataStore) recoverAllocations(isv6Enabled bool, cause error) ([]CheckpointEntry, error) {
+	if ds.allocationRecovery == nil {
+		return nil, cause
+	}
+	ds.log.Infof("Recovering ipam state from the pod routes: %v", cause)
+	addresses, err := ds.allocationRecovery(isv6Enabled)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to recover ipam state after %v", cause)
+	}
+
+	entries := make([]CheckpointEntry, 0, len(addresses))
+	now := time.Now().UnixNano()
+	for _, address := range addresses {
+		entry := CheckpointEntry{
+			IPAMKey:             RecoveredIPAMKey(address.HostVeth),
+			AllocationTimestamp: now,
+		}
+		if isv6Enabled {
+			entry.IPv6 = address.IP
+		} else {
+			entry.IPv4 = address.IP
+		}
+		ds.log.Infof("Recovered IP %s of host veth %s", address.IP, address.HostVeth)
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// readCRIAllocations returns the allocations of the running sandboxes known to CRI
+func (ds *DataStore) readCRIAllocations(isv6Enabled bool) ([]CheckpointEntry, error) {
+	var ipv4Addr, ipv6Addr string
+	sandboxes, err := ds.cri.GetRunningPodSandboxes(ds.log)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]CheckpointEntry, 0, len(sandboxes))
+	for _, s := range sandboxes {
+		ds.log.Debugf("Adding container ID: %v", s.ID)
+
+		metadata := IPAMMetadata{}
+		// both containerd and dockershim populates the metadata, just be cautious to have this null check.
+		if s.Metadata != nil {
+			metadata.K8SPodNamespace = s.Metadata.Namespace
+			metadata.K8SPodName = s.Metadata.Name
+		}
+
+		// note: ideally each sandbox should only contain one IP only,
+		// looping through them here is just to keep legacy code's behavior.
+		for _, ip := range s.IPs {
+			if isv6Enabled {
+				ipv6Addr = ip
+			} else {
+				ipv4Addr = ip
+			}
+			entries = append(entries, CheckpointEntry{
+				// NB: These Backfill values are also assumed in UnassignPodIPAddress
+				IPAMKey: IPAMKey{
+					NetworkName: backfillNetworkName,
+					ContainerID: s.ID,
+					IfName:      backfillNetworkIface,
+				},
+				IPv4:                ipv4Addr,
+				IPv6:                ipv6Addr,
+				AllocationTimestamp: s.CreationTimestamp,
+				Metadata:            metadata,
+			})
+		}
+	}
+	return entries, nil
+}
+
 func (ds *DataStore) writeBackingStoreUnsafe() error {
 	allocations := make([]CheckpointEntry, 0, ds.assigned)
 
@@ -544,9 +739,19 @@ func (ds *DataStore) writeBackingStoreUnsafe() error {
 		}
 	}
 
+	var mixedModeENIs []string
+	for _, eni := range ds.eniPool {
+		if eni.MixedMode {
+			mixedModeENIs = append(mixedModeENIs, eni.ID)
+		}
+	}
+	sort.Strings(mixedModeENIs)
+
 	data := CheckpointData{
-		Version:     CheckpointFormatVersion,
-		Allocations: allocations,
+		Version:       CheckpointFormatVersion,
+		Allocations:   allocations,
+		Requests:      ds.checkpointRequestsUnsafe(),
+		MixedModeENIs: mixedModeENIs,
 	}
 
 	return ds.backingStore.Checkpoint(&data)
@@ -554,7 +759,7 @@ func (ds *DataStore) writeBackingStoreUnsafe() error {
 
 // AddENI add ENI to data store
 func (ds *DataStore) AddENI(eniID string, deviceNumber int, isPrimary, isTrunk, isEFA bool) error {
-	ds.lock.Lock()
+	ds.writeLock("AddENI")
 	defer ds.lock.Unlock()
 
 	ds.log.Debugf("DataStore Add an ENI %s", eniID)
@@ -578,7 +783,7 @@ func (ds *DataStore) AddENI(eniID string, deviceNumber int, isPrimary, isTrunk,
 
 // AddIPv4AddressToStore adds IPv4 CIDR of an ENI to data store
 func (ds *DataStore) AddIPv4CidrToStore(eniID string, ipv4Cidr net.IPNet, isPrefix bool) error {
-	ds.lock.Lock()
+	ds.writeLock("AddIPv4CidrToStore")
 	defer ds.lock.Unlock()
 
 	strIPv4Cidr := ipv4Cidr.String()
@@ -600,6 +805,7 @@ func (ds *DataStore) AddIPv4CidrToStore(eniID string, ipv4Cidr net.IPNet, isPref
 		IPAddresses:   make(map[string]*AddressInfo),
 		IsPrefix:      isPrefix,
 		AddressFamily: "4",
+		Class:         ds.routabilityClassUnsafe(ipv4Cidr),
 	}
 
 	curENI.AvailableIPv4Cidrs[strIPv4Cidr] = newCidrInfo
@@ -616,7 +822,7 @@ func (ds *DataStore) AddIPv4CidrToStore(eniID string, ipv4Cidr net.IPNet, isPref
 }
 
 func (ds *DataStore) DelIPv4CidrFromStore(eniID string, cidr net.IPNet, force bool) error {
-	ds.lock.Lock()
+	ds.writeLock("DelIPv4CidrFromStore")
 	defer ds.lock.Unlock()
 
 	curENI, ok := ds.eniPool[eniID]
@@ -667,7 +873,7 @@ func (ds *DataStore) DelIPv4CidrFromStore(eniID string, cidr net.IPNet, force bo
 
 // AddIPv6AddressToStore adds IPv6 CIDR of an ENI to data store
 func (ds *DataStore) AddIPv6CidrToStore(eniID string, ipv6Cidr net.IPNet, isPrefix bool) error {
-	ds.lock.Lock()
+	ds.writeLock("AddIPv6CidrToStore")
 	defer ds.lock.Unlock()
 
 	strIPv6Cidr := ipv6Cidr.String()
@@ -707,30 +913,289 @@ func (ds *DataStore) AddIPv6CidrToStore(eniID string, ipv6Cidr net.IPNet, isPref
 	return nil
 }
 
-func (ds *DataStore) AssignPodIPAddress(ipamKey IPAMKey, ipamMetadata IPAMMetadata, isIPv4Enabled bool, isIPv6Enabled bool) (ipv4Address string,
-	ipv6Address string, deviceNumber int, err error) {
-	//Currently it's either v4 or v6. Dual Stack mode isn't supported.
+// AddressFamily is a set of IP address families to assign to a pod
+type AddressFamily uint8
+
+const (
+	// FamilyIPv4 selects an IPv4 address
+	FamilyIPv4 AddressFamily = 1 << iota
+	// FamilyIPv6 selects an IPv6 address
+	FamilyIPv6
+)
+
+// AddressFamilies returns the set of the enabled address families
+func AddressFamilies(isIPv4Enabled, isIPv6Enabled bool) AddressFamily {
+	var families AddressFamily
 	if isIPv4Enabled {
-		ipv4Address, deviceNumber, err = ds.AssignPodIPv4Address(ipamKey, ipamMetadata)
-	} else if isIPv6Enabled {
-		ipv6Address, deviceNumber, err = ds.AssignPodIPv6Address(ipamKey, ipamMetadata)
+		families |= FamilyIPv4
+	}
+	if isIPv6Enabled {
+		families |= FamilyIPv6
+	}
+	return families
+}
+
+// Has returns true if family is in the set
+func (f AddressFamily) Has(family AddressFamily) bool {
+	return f&family != 0
+}
+
+// PodAddresses are the addresses assigned to a pod, one per requested address family
+type PodAddresses struct {
+	IPv4         string
+	IPv6         string
+	DeviceNumber int
+}
+
+// assignedAddress is an address assigned by assignPodIPAddress, kept to unwind the assignment if a later step fails
+type assignedAddress struct {
+	cidr *CidrInfo
+	addr *AddressInfo
+}
+
+// AssignPodIPAddress assigns an address of each family in families to the pod, from the same datastore snapshot. The
+// assignment is all or nothing: if one family has no free address, none is assigned. A sandbox that already has
+// addresses gets them back.
+func (ds *DataStore) AssignPodIPAddress(ipamKey IPAMKey, ipamMetadata IPAMMetadata, families AddressFamily) (PodAddresses, error) {
+	return ds.assignPodIPAddress(ipamKey, ipamMetadata, families, nil, "")
+}
+
+// AssignPodIPAddressForRequest is AssignPodIPAddress, or AssignPodIPv4AddressPinned if pin is set, for the CNI request
+// requestID. Its addresses are recorded for the retries of the request, in the same checkpoint write as the assignment.
+func (ds *DataStore) AssignPodIPAddressForRequest(requestID string, ipamKey IPAMKey, ipamMetadata IPAMMetadata, families AddressFamily, pin *AddressPin) (PodAddresses, error) {
+	if pin != nil {
+		if err := pin.Validate(); err != nil {
+			return PodAddresses{DeviceNumber: -1}, err
+		}
+		families = FamilyIPv4
 	}
-	return ipv4Address, ipv6Address, deviceNumber, err
+	return ds.assignPodIPAddress(ipamKey, ipamMetadata, families, pin, requestID)
 }
 
 // AssignPodIPv6Address assigns an IPv6 address to pod. Returns the assigned IPv6 address along with device number
 func (ds *DataStore) AssignPodIPv6Address(ipamKey IPAMKey, ipamMetadata IPAMMetadata) (ipv6Address string, deviceNumber int, err error) {
-	ds.lock.Lock()
+	addresses, err := ds.assignPodIPAddress(ipamKey, ipamMetadata, FamilyIPv6, nil, "")
+	return addresses.IPv6, addresses.DeviceNumber, err
+}
+
+func (ds *DataStore) assignPodIPAddress(ipamKey IPAMKey, ipamMetadata IPAMMetadata, families AddressFamily, pin *AddressPin, requestID string) (PodAddresses, error) {
+	ds.writeLock("AssignPodIPAddress")
 	defer ds.lock.Unlock()
 
-	if !ds.isPDEnabled {
-		return "", -1, fmt.Errorf("PD is not enabled. V6 is only supported in PD mode")
+	ds.log.Debugf("AssignPodIPAddress: IP address pool stats: total: %d, assigned %d", ds.total, ds.assigned)
+	if families == 0 {
+		return PodAddresses{DeviceNumber: -1}, errors.New("no address family to assign")
+	}
+
+	if addresses, found := ds.findPodAddressesUnsafe(ipamKey); found {
+		ds.log.Infof("AssignPodIPAddress: duplicate pod assign for sandbox %s", ipamKey)
+		// The addresses were checkpointed when assigned, only a request that wasn't recorded yet needs a write
+		if _, recorded := ds.recordRequestUnsafe(requestID, RequestAdd, ipamKey, addresses); !recorded {
+			if err := ds.writeBackingStoreUnsafe(); err != nil {
+				ds.log.Warnf("Failed to checkpoint request %s: %v", requestID, err)
+			}
+		}
+		return addresses, nil
+	}
+
+	// The container runtime can recreate the sandbox of a pod without deleting the previous one, e.g. after a
+	// restart. The pod keeps its addresses rather than getting new ones and leaking the previous ones.
+	if staleKey, found := ds.findPodSandboxUnsafe(ipamKey, ipamMetadata); found {
+		ds.log.Infof("AssignPodIPAddress: pod %s/%s moved from sandbox %s to sandbox %s",
+			ipamMetadata.K8SPodNamespace, ipamMetadata.K8SPodName, staleKey, ipamKey)
+		staleAddresses, _ := ds.findPodAddressesUnsafe(staleKey)
+		undo, _ := ds.recordRequestUnsafe(requestID, RequestAdd, ipamKey, staleAddresses)
+		addresses, err := ds.rekeyPodIPAddressUnsafe(staleKey, ipamKey, nil)
+		if err != nil {
+			undo()
+		}
+		return addresses, err
+	}
+
+	addresses := PodAddresses{DeviceNumber: -1}
+	var assigned []assignedAddress
+	unwind := func() {
+		for _, a := range assigned {
+			ds.unassignPodIPAddressUnsafe(a.addr)
+			//Remove the IP from eni DB
+			delete(a.cidr.IPAddresses, a.addr.Address)
+			//Update prometheus for ips per cidr
+			ipsPerCidr.With(prometheus.Labels{"cidr": a.cidr.Cidr.String()}).Dec()
+		}
+	}
+
+	if families.Has(FamilyIPv4) {
+		eni, cidr, addr, err := ds.assignPodIPv4AddressUnsafe(ipamKey, ipamMetadata, pin)
+		if err != nil {
+			return PodAddresses{DeviceNumber: -1}, err
+		}
+		assigned = append(assigned, assignedAddress{cidr: cidr, addr: addr})
+		addresses.IPv4 = addr.Address
+		addresses.DeviceNumber = eni.DeviceNumber
+	}
+	if families.Has(FamilyIPv6) {
+		eni, cidr, addr, err := ds.assignPodIPv6AddressUnsafe(ipamKey, ipamMetadata)
+		if err != nil {
+			unwind()
+			return PodAddresses{DeviceNumber: -1}, err
+		}
+		assigned = append(assigned, assignedAddress{cidr: cidr, addr: addr})
+		addresses.IPv6 = addr.Address
+		addresses.DeviceNumber = eni.DeviceNumber
+	}
+
+	undo, _ := ds.recordRequestUnsafe(requestID, RequestAdd, ipamKey, addresses)
+	if err := ds.writeBackingStoreUnsafe(); err != nil {
+		ds.log.Warnf("Failed to update backing store: %v", err)
+		// Important! Unwind assignment
+		unwind()
+		undo()
+		return PodAddresses{DeviceNumber: -1}, err
+	}
+	return addresses, nil
+}
+
+// findPodAddressesUnsafe returns the addresses already assigned to the sandbox, if any
+func (ds *DataStore) findPodAddressesUnsafe(ipamKey IPAMKey) (PodAddresses, bool) {
+	addresses := PodAddresses{DeviceNumber: -1}
+	found := false
+	for _, eni := range ds.eniPool {
+		for _, cidr := range eni.AvailableIPv4Cidrs {
+			for _, addr := range cidr.IPAddresses {
+				if addr.IPAMKey == ipamKey {
+					addresses.IPv4 = addr.Address
+					addresses.DeviceNumber = eni.DeviceNumber
+					found = true
+				}
+			}
+		}
+		for _, cidr := range eni.IPv6Cidrs {
+			for _, addr := range cidr.IPAddresses {
+				if addr.IPAMKey == ipamKey {
+					addresses.IPv6 = addr.Address
+					addresses.DeviceNumber = eni.DeviceNumber
+					found = true
+				}
+			}
+		}
+	}
+	return addresses, found
+}
+
+// findPodSandboxUnsafe returns the other sandbox of the pod with the same network and interface that holds
+// addresses, if any. Only pods with a UID are matched, since a pod recreated with the same name is another pod.
+func (ds *DataStore) findPodSandboxUnsafe(ipamKey IPAMKey, ipamMetadata IPAMMetadata) (IPAMKey, bool) {
+	if ipamMetadata.K8SPodUID == "" {
+		return IPAMKey{}, false
+	}
+	for _, eni := range ds.eniPool {
+		for _, cidrs := range []map[string]*CidrInfo{eni.AvailableIPv4Cidrs, eni.IPv6Cidrs} {
+			for _, cidr := range cidrs {
+				for _, addr := range cidr.IPAddresses {
+					if addr.Assigned() && addr.IPAMMetadata == ipamMetadata && addr.IPAMKey != ipamKey &&
+						addr.IPAMKey.NetworkName == ipamKey.NetworkName && addr.IPAMKey.IfName == ipamKey.IfName {
+						return addr.IPAMKey, true
+					}
+				}
+			}
+		}
+	}
+	return IPAMKey{}, false
+}
+
+// RekeyPodIPAddress hands the addresses of the sandbox oldKey over to the sandbox newKey, keeping their assignment
+// time and metadata. It returns ErrUnknownPod if oldKey has no address.
+func (ds *DataStore) RekeyPodIPAddress(oldKey, newKey IPAMKey) (PodAddresses, error) {
+	ds.writeLock("RekeyPodIPAddress")
+	defer ds.lock.Unlock()
+
+	if _, found := ds.findPodAddressesUnsafe(newKey); found {
+		return PodAddresses{DeviceNumber: -1}, errors.Errorf("sandbox %s already has addresses", newKey)
+	}
+	return ds.rekeyPodIPAddressUnsafe(oldKey, newKey, nil)
+}
+
+// ClaimImportedPodIPAddress hands the address reserved by an imported pool state for the pod of ipamMetadata over to
+// the sandbox ipamKey, with the metadata of the sandbox. It returns ErrUnknownPod if no address is reserved for the pod.
+func (ds *DataStore) ClaimImportedPodIPAddress(ipamKey IPAMKey, ipamMetadata IPAMMetadata) (PodAddresses, error) {
+	ds.writeLock("ClaimImportedPodIPAddress")
+	defer ds.lock.Unlock()
+
+	if _, found := ds.findPodAddressesUnsafe(ipamKey); found {
+		return PodAddresses{DeviceNumber: -1}, errors.Errorf("sandbox %s already has addresses", ipamKey)
+	}
+	importedKey := ImportedIPAMKey(ipamMetadata.K8SPodNamespace, ipamMetadata.K8SPodName)
+	return ds.rekeyPodIPAddressUnsafe(importedKey, ipamKey, &ipamMetadata)
+}
+
+// ClaimWarmVethIPAddress hands the address of a pre-plumbed veth pair over to the sandbox ipamKey, with the metadata of
+// the sandbox, and returns the name of the host-side veth device. It returns ErrUnknownPod if no pre-plumbed veth pair
+// is left.
+func (ds *DataStore) ClaimWarmVethIPAddress(ipamKey IPAMKey, ipamMetadata IPAMMetadata) (string, PodAddresses, error) {
+	ds.writeLock("ClaimWarmVethIPAddress")
+	defer ds.lock.Unlock()
+
+	if _, found := ds.findPodAddressesUnsafe(ipamKey); found {
+		return "", PodAddresses{DeviceNumber: -1}, errors.Errorf("sandbox %s already has addresses", ipamKey)
+	}
+	for _, eni := range ds.eniPool {
+		for _, cidr := range eni.AvailableIPv4Cidrs {
+			for _, addr := range cidr.IPAddresses {
+				if addr.IPAMKey.NetworkName != warmVethNetworkName {
+					continue
+				}
+				warmKey := addr.IPAMKey
+				addresses, err := ds.rekeyPodIPAddressUnsafe(warmKey, ipamKey, &ipamMetadata)
+				return warmKey.ContainerID, addresses, err
+			}
+		}
+	}
+	return "", PodAddresses{DeviceNumber: -1}, ErrUnknownPod
+}
+
+// rekeyPodIPAddressUnsafe moves the addresses of oldKey to newKey, and replaces their metadata unless ipamMetadata is
+// nil
+func (ds *DataStore) rekeyPodIPAddressUnsafe(oldKey, newKey IPAMKey, ipamMetadata *IPAMMetadata) (PodAddresses, error) {
+	var rekeyed []*AddressInfo
+	var oldMetadata IPAMMetadata
+	for _, eni := range ds.eniPool {
+		for _, cidrs := range []map[string]*CidrInfo{eni.AvailableIPv4Cidrs, eni.IPv6Cidrs} {
+			for _, cidr := range cidrs {
+				for _, addr := range cidr.IPAddresses {
+					if addr.IPAMKey == oldKey {
+						addr.IPAMKey = newKey
+						oldMetadata = addr.IPAMMetadata
+						if ipamMetadata != nil {
+							addr.IPAMMetadata = *ipamMetadata
+						}
+						rekeyed = append(rekeyed, addr)
+					}
+				}
+			}
+		}
+	}
+	if len(rekeyed) == 0 {
+		return PodAddresses{DeviceNumber: -1}, ErrUnknownPod
+	}
+	if err := ds.writeBackingStoreUnsafe(); err != nil {
+		ds.log.Warnf("Failed to update backing store: %v", err)
+		for _, addr := range rekeyed {
+			addr.IPAMKey = oldKey
+			addr.IPAMMetadata = oldMetadata
+		}
+		return PodAddresses{DeviceNumber: -1}, err
 	}
-	ds.log.Debugf("AssignIPv6Address: IPv6 address pool stats: assigned %d", ds.assigned)
+	rekeyedSandboxes.Inc()
+	addresses, _ := ds.findPodAddressesUnsafe(newKey)
+	ds.log.Infof("RekeyPodIPAddress: sandbox %s's addresses %s %s moved to sandbox %s",
+		oldKey, addresses.IPv4, addresses.IPv6, newKey)
+	return addresses, nil
+}
 
-	if eni, _, addr := ds.eniPool.FindAddressForSandbox(ipamKey); addr != nil {
-		ds.log.Infof("AssignPodIPv6Address: duplicate pod assign for sandbox %s", ipamKey)
-		return addr.Address, eni.DeviceNumber, nil
+// assignPodIPv6AddressUnsafe assigns a free IPv6 address from the delegated prefixes, without checkpointing it
+func (ds *DataStore) assignPodIPv6AddressUnsafe(ipamKey IPAMKey, ipamMetadata IPAMMetadata) (*ENI, *CidrInfo, *AddressInfo, error) {
+	if !ds.isPDEnabled {
+		return nil, nil, nil, fmt.Errorf("PD is not enabled. V6 is only supported in PD mode")
 	}
 
 	//In IPv6 Prefix Delegation mode, eniPool will only have Primary ENI.
@@ -742,7 +1207,7 @@ func (ds *DataStore) AssignPodIPv6Address(ipamKey IPAMKey, ipamMetadata IPAMMeta
 			if !V6Cidr.IsPrefix {
 				continue
 			}
-			ipv6Address, err = ds.getFreeIPv6AddrFromCidr(V6Cidr)
+			ipv6Address, err := ds.getFreeIPv6AddrFromCidr(V6Cidr)
 			if err != nil {
 				ds.log.Debugf("Unable to get IP address from prefix: %v", err)
 				//In v6 mode, we (should) only have one CIDR/Prefix. So, we can bail out but we will let the loop
@@ -750,44 +1215,119 @@ func (ds *DataStore) AssignPodIPv6Address(ipamKey IPAMKey, ipamMetadata IPAMMeta
 				continue
 			}
 			ds.log.Debugf("New v6 IP from PD pool- %s", ipv6Address)
-			addr := &AddressInfo{Address: ipv6Address}
-			V6Cidr.IPAddresses[ipv6Address] = addr
+			if V6Cidr.IPAddresses == nil {
+				V6Cidr.IPAddresses = make(map[string]*AddressInfo)
+			}
+			//Update prometheus for ips per cidr, UnassignPodIPAddress decrements it
+			ipsPerCidr.With(prometheus.Labels{"cidr": V6Cidr.Cidr.String()}).Inc()
 
-			ds.assignPodIPAddressUnsafe(addr, ipamKey, ipamMetadata, time.Now())
-			if err := ds.writeBackingStoreUnsafe(); err != nil {
-				ds.log.Warnf("Failed to update backing store: %v", err)
-				// Important! Unwind assignment
-				ds.unassignPodIPAddressUnsafe(addr)
-				//Remove the IP from eni DB
-				delete(V6Cidr.IPAddresses, addr.Address)
-				return "", -1, err
+			addr := V6Cidr.IPAddresses[ipv6Address]
+			if addr == nil {
+				addr = &AddressInfo{Address: ipv6Address}
 			}
-			return addr.Address, eni.DeviceNumber, nil
+			V6Cidr.IPAddresses[ipv6Address] = addr
+			ds.assignPodIPAddressUnsafe(addr, ipamKey, ipamMetadata, time.Now())
+			return eni, V6Cidr, addr, nil
+		}
+	}
+	ds.log.Errorf("DataStore has no available IPv6 addresses")
+	return nil, nil, nil, errors.Wrap(ErrNoAvailableIPs, "assignPodIPv6AddressUnsafe")
+}
+
+// AddressPin restricts the IP address a pod can get. CIDR, if set, must contain the CIDR the address is taken from, so in
+// prefix delegation mode a /28 pins the pod to that prefix. ENIIDs, if set, are the ENIs the address can be taken from.
+// Class, if set, is the routability class of the address.
+type AddressPin struct {
+	CIDR   *net.IPNet
+	ENIIDs []string
+	Class  RoutabilityClass
+}
+
+// Validate checks that the pin can be satisfied by an IPv4 address
+func (pin *AddressPin) Validate() error {
+	if pin.CIDR == nil && len(pin.ENIIDs) == 0 && pin.Class == "" {
+		return errors.New("address pin has neither a CIDR, ENIs nor a routability class")
+	}
+	if pin.CIDR != nil && pin.CIDR.IP.To4() == nil {
+		return errors.Errorf("pinned CIDR %s is not an IPv4 CIDR", pin.CIDR.String())
+	}
+	if pin.Class != "" {
+		if _, err := ParseRoutabilityClass(string(pin.Class)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pin *AddressPin) allowsENI(eniID string) bool {
+	if len(pin.ENIIDs) == 0 {
+		return true
+	}
+	for _, id := range pin.ENIIDs {
+		if id == eniID {
+			return true
 		}
 	}
-	return "", -1, errors.New("assignPodIPv6AddressUnsafe: no available IP addresses")
+	return false
+}
+
+func (pin *AddressPin) allowsCidr(cidr *CidrInfo) bool {
+	if pin.Class != "" && cidr.Class != pin.Class {
+		return false
+	}
+	if pin.CIDR == nil {
+		return true
+	}
+	pinOnes, _ := pin.CIDR.Mask.Size()
+	cidrOnes, _ := cidr.Cidr.Mask.Size()
+	return pinOnes <= cidrOnes && pin.CIDR.Contains(cidr.Cidr.IP)
+}
+
+func (pin *AddressPin) String() string {
+	var parts []string
+	if pin.CIDR != nil {
+		parts = append(parts, "CIDR "+pin.CIDR.String())
+	}
+	if len(pin.ENIIDs) > 0 {
+		parts = append(parts, "ENIs "+strings.Join(pin.ENIIDs, ","))
+	}
+	if pin.Class != "" {
+		parts = append(parts, "class "+string(pin.Class))
+	}
+	return strings.Join(parts, ", ")
 }
 
 // AssignPodIPv4Address assigns an IPv4 address to pod
 // It returns the assigned IPv4 address, device number, error
 func (ds *DataStore) AssignPodIPv4Address(ipamKey IPAMKey, ipamMetadata IPAMMetadata) (ipv4address string, deviceNumber int, err error) {
-	ds.lock.Lock()
-	defer ds.lock.Unlock()
-
-	ds.log.Debugf("AssignIPv4Address: IP address pool stats: total: %d, assigned %d", ds.total, ds.assigned)
+	addresses, err := ds.assignPodIPAddress(ipamKey, ipamMetadata, FamilyIPv4, nil, "")
+	return addresses.IPv4, addresses.DeviceNumber, err
+}
 
-	if eni, _, addr := ds.eniPool.FindAddressForSandbox(ipamKey); addr != nil {
-		ds.log.Infof("AssignPodIPv4Address: duplicate pod assign for sandbox %s", ipamKey)
-		return addr.Address, eni.DeviceNumber, nil
+// AssignPodIPv4AddressPinned assigns an IPv4 address that satisfies the pin to pod
+func (ds *DataStore) AssignPodIPv4AddressPinned(ipamKey IPAMKey, ipamMetadata IPAMMetadata, pin *AddressPin) (ipv4address string, deviceNumber int, err error) {
+	if err := pin.Validate(); err != nil {
+		return "", -1, err
 	}
+	addresses, err := ds.assignPodIPAddress(ipamKey, ipamMetadata, FamilyIPv4, pin, "")
+	return addresses.IPv4, addresses.DeviceNumber, err
+}
 
+// assignPodIPv4AddressUnsafe assigns a free IPv4 address that satisfies pin, if set, without checkpointing it
+func (ds *DataStore) assignPodIPv4AddressUnsafe(ipamKey IPAMKey, ipamMetadata IPAMMetadata, pin *AddressPin) (*ENI, *CidrInfo, *AddressInfo, error) {
 	for _, eni := range ds.eniPool {
+		if pin != nil && !pin.allowsENI(eni.ID) {
+			continue
+		}
 		for _, availableCidr := range eni.AvailableIPv4Cidrs {
 			var addr *AddressInfo
 			var strPrivateIPv4 string
 			var err error
 
-			if (ds.isPDEnabled && availableCidr.IsPrefix) || (!ds.isPDEnabled && !availableCidr.IsPrefix) {
+			if pin != nil && !pin.allowsCidr(availableCidr) {
+				continue
+			}
+			if ds.isUsableIPv4Cidr(eni, availableCidr) {
 				strPrivateIPv4, err = ds.getFreeIPv4AddrfromCidr(availableCidr)
 				if err != nil {
 					ds.log.Debugf("Unable to get IP address from CIDR: %v", err)
@@ -816,29 +1356,22 @@ func (ds *DataStore) AssignPodIPv4Address(ipamKey IPAMKey, ipamMetadata IPAMMeta
 
 			availableCidr.IPAddresses[strPrivateIPv4] = addr
 			ds.assignPodIPAddressUnsafe(addr, ipamKey, ipamMetadata, time.Now())
-
-			if err := ds.writeBackingStoreUnsafe(); err != nil {
-				ds.log.Warnf("Failed to update backing store: %v", err)
-				// Important! Unwind assignment
-				ds.unassignPodIPAddressUnsafe(addr)
-				//Remove the IP from eni DB
-				delete(availableCidr.IPAddresses, addr.Address)
-				//Update prometheus for ips per cidr
-				ipsPerCidr.With(prometheus.Labels{"cidr": availableCidr.Cidr.String()}).Dec()
-				return "", -1, err
-			}
-			return addr.Address, eni.DeviceNumber, nil
+			return eni, availableCidr, addr, nil
 		}
 		ds.log.Debugf("AssignPodIPv4Address: ENI %s does not have available addresses", eni.ID)
 	}
 
+	if pin != nil {
+		ds.log.Errorf("DataStore has no available IP/Prefix addresses matching %s", pin)
+		return nil, nil, nil, errors.Wrap(ErrPinnedAddressUnavailable, pin.String())
+	}
 	ds.log.Errorf("DataStore has no available IP/Prefix addresses")
-	return "", -1, errors.New("assignPodIPv4AddressUnsafe: no available IP/Prefix addresses")
+	return nil, nil, nil, errors.Wrap(ErrNoAvailableIPs, "assignPodIPv4AddressUnsafe")
 }
 
 // assignPodIPAddressUnsafe mark Address as assigned.
 func (ds *DataStore) assignPodIPAddressUnsafe(addr *AddressInfo, ipamKey IPAMKey, ipamMetadata IPAMMetadata, assignedTime time.Time) {
-	ds.log.Infof("AssignPodIPv4Address: Assign IP %v to sandbox %s",
+	ds.log.Infof("AssignPodIPAddress: Assign IP %v to sandbox %s",
 		addr.Address, ipamKey)
 
 	if addr.Assigned() {
@@ -847,6 +1380,8 @@ func (ds *DataStore) assignPodIPAddressUnsafe(addr *AddressInfo, ipamKey IPAMKey
 	addr.IPAMKey = ipamKey // This marks the addr as assigned
 	addr.IPAMMetadata = ipamMetadata
 	addr.AssignedTime = assignedTime
+	audit.Record(audit.CategoryDatastore, "assign", fmt.Sprintf("%s to %s/%s", addr.Address,
+		ipamMetadata.K8SPodNamespace, ipamMetadata.K8SPodName), ipamKey.ContainerID, nil)
 
 	ds.assigned++
 	// Prometheus gauge
@@ -861,6 +1396,8 @@ func (ds *DataStore) unassignPodIPAddressUnsafe(addr *AddressInfo) {
 	}
 	ds.log.Infof("UnAssignPodIPAddress: Unassign IP %v from sandbox %s",
 		addr.Address, addr.IPAMKey)
+	audit.Record(audit.CategoryDatastore, "unassign", fmt.Sprintf("%s from %s/%s", addr.Address,
+		addr.IPAMMetadata.K8SPodNamespace, addr.IPAMMetadata.K8SPodName), addr.IPAMKey.ContainerID, nil)
 	addr.IPAMKey = IPAMKey{} // unassign the addr
 	addr.IPAMMetadata = IPAMMetadata{}
 	ds.assigned--
@@ -878,6 +1415,8 @@ type DataStoreStats struct {
 	AssignedIPs int
 	// Number of addresses in cooldown
 	CooldownIPs int
+	// Number of unassigned addresses that are quarantined
+	QuarantinedIPs int
 }
 
 func (stats *DataStoreStats) String() string {
@@ -886,13 +1425,13 @@ func (stats *DataStoreStats) String() string {
 }
 
 func (stats *DataStoreStats) AvailableAddresses() int {
-	return stats.TotalIPs - stats.AssignedIPs
+	return stats.TotalIPs - stats.AssignedIPs - stats.QuarantinedIPs
 }
 
 // GetIPStats returns DataStoreStats for addressFamily
 func (ds *DataStore) GetIPStats(addressFamily string) *DataStoreStats {
-	ds.lock.Lock()
-	defer ds.lock.Unlock()
+	ds.readLock("GetIPStats")
+	defer ds.lock.RUnlock()
 
 	stats := &DataStoreStats{
 		TotalPrefixes: ds.allocatedPrefix,
@@ -903,24 +1442,51 @@ func (ds *DataStore) GetIPStats(addressFamily string) *DataStoreStats {
 			AssignedCIDRs = eni.IPv6Cidrs
 		}
 		for _, cidr := range AssignedCIDRs {
-			if addressFamily == "4" && ((ds.isPDEnabled && cidr.IsPrefix) || (!ds.isPDEnabled && !cidr.IsPrefix)) {
+			if (addressFamily == "4" && ds.isUsableIPv4Cidr(eni, cidr)) ||
+				addressFamily == "6" {
 				cidrStats := cidr.GetIPStatsFromCidr()
 				stats.AssignedIPs += cidrStats.AssignedIPs
 				stats.CooldownIPs += cidrStats.CooldownIPs
 				stats.TotalIPs += cidr.Size()
-			} else if addressFamily == "6" {
-				stats.AssignedIPs += cidr.AssignedIPAddressesInCidr()
-				stats.TotalIPs += cidr.Size()
+				stats.QuarantinedIPs += cidrStats.QuarantinedIPs
 			}
 		}
 	}
+	// Only one address family is in use, so this also expires the quarantines from the gauge
+	quarantinedIPs.Set(float64(stats.QuarantinedIPs))
 	return stats
 }
 
+// isUsableIPv4Cidr returns true if pods can get IPs from cidr of eni: prefixes with prefix delegation, secondary IPs
+// without it, and both on an ENI in mixed mode
+func (ds *DataStore) isUsableIPv4Cidr(eni *ENI, cidr *CidrInfo) bool {
+	return cidr.IsPrefix == ds.isPDEnabled || (eni.MixedMode && !cidr.IsPrefix)
+}
+
+// SetENIMixedMode records that eniID hands out secondary IPs next to its prefixes. The mode is checkpointed, so that the
+// secondary IPs are still handed out after ipamd restarts.
+func (ds *DataStore) SetENIMixedMode(eniID string) error {
+	ds.writeLock("SetENIMixedMode")
+	defer ds.lock.Unlock()
+
+	eni, ok := ds.eniPool[eniID]
+	if !ok {
+		return errors.New(UnknownENIError)
+	}
+	if !eni.MixedMode {
+		ds.log.Infof("ENI %s is now in mixed mode, handing out secondary IPs next to prefixes", eniID)
+		eni.MixedMode = true
+		if err := ds.writeBackingStoreUnsafe(); err != nil {
+			ds.log.Warnf("Failed to checkpoint the mixed mode of ENI %s: %v", eniID, err)
+		}
+	}
+	return nil
+}
+
 // GetTrunkENI returns the trunk ENI ID or an empty string
 func (ds *DataStore) GetTrunkENI() string {
-	ds.lock.Lock()
-	defer ds.lock.Unlock()
+	ds.readLock("GetTrunkENI")
+	defer ds.lock.RUnlock()
 	for _, eni := range ds.eniPool {
 		if eni.IsTrunk {
 			return eni.ID
@@ -931,8 +1497,8 @@ func (ds *DataStore) GetTrunkENI() string {
 
 // GetEFAENIs returns the a map containing all attached EFA ENIs
 func (ds *DataStore) GetEFAENIs() map[string]bool {
-	ds.lock.Lock()
-	defer ds.lock.Unlock()
+	ds.readLock("GetEFAENIs")
+	defer ds.lock.RUnlock()
 	ret := make(map[string]bool)
 	for _, eni := range ds.eniPool {
 		if eni.IsEFA {
@@ -949,7 +1515,7 @@ func (ds *DataStore) isRequiredForWarmIPTarget(warmIPTarget int, eni *ENI) bool
 	for _, other := range ds.eniPool {
 		if other.ID != eni.ID {
 			for _, otherPrefixes := range other.AvailableIPv4Cidrs {
-				if (ds.isPDEnabled && otherPrefixes.IsPrefix) || (!ds.isPDEnabled && !otherPrefixes.IsPrefix) {
+				if ds.isUsableIPv4Cidr(other, otherPrefixes) {
 					otherWarmIPs += otherPrefixes.Size() - otherPrefixes.AssignedIPAddressesInCidr()
 				}
 			}
@@ -971,7 +1537,7 @@ func (ds *DataStore) isRequiredForMinimumIPTarget(minimumIPTarget int, eni *ENI)
 	for _, other := range ds.eniPool {
 		if other.ID != eni.ID {
 			for _, otherPrefixes := range other.AvailableIPv4Cidrs {
-				if (ds.isPDEnabled && otherPrefixes.IsPrefix) || (!ds.isPDEnabled && !otherPrefixes.IsPrefix) {
+				if ds.isUsableIPv4Cidr(other, otherPrefixes) {
 					otherIPs += otherPrefixes.Size()
 				}
 			}
@@ -1079,8 +1645,8 @@ func (e *ENI) hasPods() bool {
 
 // GetENINeedsIP finds an ENI in the datastore that needs more IP addresses allocated
 func (ds *DataStore) GetENINeedsIP(maxIPperENI int, skipPrimary bool) *ENI {
-	ds.lock.Lock()
-	defer ds.lock.Unlock()
+	ds.readLock("GetENINeedsIP")
+	defer ds.lock.RUnlock()
 	for _, eni := range ds.eniPool {
 		if skipPrimary && eni.IsPrimary {
 			ds.log.Debugf("Skip the primary ENI for need IP check")
@@ -1099,7 +1665,7 @@ func (ds *DataStore) GetENINeedsIP(maxIPperENI int, skipPrimary bool) *ENI {
 // It returns the name of the ENI which has been removed from the data store and needs to be deleted,
 // or empty string if no ENI could be removed.
 func (ds *DataStore) RemoveUnusedENIFromStore(warmIPTarget, minimumIPTarget, warmPrefixTarget int) string {
-	ds.lock.Lock()
+	ds.writeLock("RemoveUnusedENIFromStore")
 	defer ds.lock.Unlock()
 
 	deletableENI := ds.getDeletableENI(warmIPTarget, minimumIPTarget, warmPrefixTarget)
@@ -1129,7 +1695,7 @@ func (ds *DataStore) RemoveUnusedENIFromStore(warmIPTarget, minimumIPTarget, war
 
 // RemoveENIFromDataStore removes an ENI from the datastore. It returns nil on success, or an error.
 func (ds *DataStore) RemoveENIFromDataStore(eniID string, force bool) error {
-	ds.lock.Lock()
+	ds.writeLock("RemoveENIFromDataStore")
 	defer ds.lock.Unlock()
 
 	eni, ok := ds.eniPool[eniID]
@@ -1153,10 +1719,6 @@ func (ds *DataStore) RemoveENIFromDataStore(eniID string, force bool) error {
 					ds.unassignPodIPAddressUnsafe(addr)
 				}
 			}
-			ds.total -= assignedaddr.Size()
-			if assignedaddr.IsPrefix {
-				ds.allocatedPrefix--
-			}
 		}
 		if err := ds.writeBackingStoreUnsafe(); err != nil {
 			ds.log.Warnf("Unable to update backing store: %v", err)
@@ -1164,6 +1726,7 @@ func (ds *DataStore) RemoveENIFromDataStore(eniID string, force bool) error {
 		}
 	}
 
+	// The addresses of the ENI leave the pool once, whether or not pods were force unassigned from them above
 	for _, assignedaddr := range eni.AvailableIPv4Cidrs {
 		ds.total -= assignedaddr.Size()
 		if assignedaddr.IsPrefix {
@@ -1182,8 +1745,20 @@ func (ds *DataStore) RemoveENIFromDataStore(eniID string, force bool) error {
 
 // UnassignPodIPAddress a) find out the IP address based on PodName and PodNameSpace
 // b)  mark IP address as unassigned c) returns IP address, ENI's device number, error
+// All the addresses of a dual-stack sandbox are unassigned, only one of them is returned.
 func (ds *DataStore) UnassignPodIPAddress(ipamKey IPAMKey) (e *ENI, ip string, deviceNumber int, err error) {
-	ds.lock.Lock()
+	return ds.unassignPodIPAddress(ipamKey, "", IPAMKey{})
+}
+
+// UnassignPodIPAddressForRequest is UnassignPodIPAddress for the CNI request requestID of the sandbox requestKey. The
+// released addresses are recorded for the retries of the request, in the same checkpoint write as the unassignment.
+// ipamKey differs from requestKey when the addresses were recovered under another key.
+func (ds *DataStore) UnassignPodIPAddressForRequest(requestID string, requestKey, ipamKey IPAMKey) (e *ENI, ip string, deviceNumber int, err error) {
+	return ds.unassignPodIPAddress(ipamKey, requestID, requestKey)
+}
+
+func (ds *DataStore) unassignPodIPAddress(ipamKey IPAMKey, requestID string, requestKey IPAMKey) (e *ENI, ip string, deviceNumber int, err error) {
+	ds.writeLock("UnassignPodIPAddress")
 	defer ds.lock.Unlock()
 	ds.log.Debugf("UnassignPodIPAddress: IP address pool stats: total:%d, assigned %d, sandbox %s",
 		ds.total, ds.assigned, ipamKey)
@@ -1211,18 +1786,49 @@ func (ds *DataStore) UnassignPodIPAddress(ipamKey IPAMKey) (e *ENI, ip string, d
 		return nil, "", 0, ErrUnknownPod
 	}
 
+	// A dual-stack sandbox also has an address of the other family, released along
+	released := []assignedAddress{{cidr: availableCidr, addr: addr}}
+	for _, otherENI := range ds.eniPool {
+		for _, cidrs := range []map[string]*CidrInfo{otherENI.AvailableIPv4Cidrs, otherENI.IPv6Cidrs} {
+			for _, cidr := range cidrs {
+				for _, otherAddr := range cidr.IPAddresses {
+					if otherAddr != addr && otherAddr.IPAMKey == ipamKey {
+						released = append(released, assignedAddress{cidr: cidr, addr: otherAddr})
+					}
+				}
+			}
+		}
+	}
+
+	releasedAddresses := PodAddresses{DeviceNumber: eni.DeviceNumber}
+	for _, r := range released {
+		if net.ParseIP(r.addr.Address).To4() != nil {
+			releasedAddresses.IPv4 = r.addr.Address
+		} else {
+			releasedAddresses.IPv6 = r.addr.Address
+		}
+	}
+
 	originalIPAMMetadata := addr.IPAMMetadata
-	originalAssignedTime := addr.AssignedTime
-	ds.unassignPodIPAddressUnsafe(addr)
+	originalAssignedTimes := make([]time.Time, len(released))
+	for i, r := range released {
+		originalAssignedTimes[i] = r.addr.AssignedTime
+		ds.unassignPodIPAddressUnsafe(r.addr)
+	}
+	undo, _ := ds.recordRequestUnsafe(requestID, RequestDel, requestKey, releasedAddresses)
 	if err := ds.writeBackingStoreUnsafe(); err != nil {
 		// Unwind un-assignment
-		ds.assignPodIPAddressUnsafe(addr, ipamKey, originalIPAMMetadata, originalAssignedTime)
+		for i, r := range released {
+			ds.assignPodIPAddressUnsafe(r.addr, ipamKey, originalIPAMMetadata, originalAssignedTimes[i])
+		}
+		undo()
 		return nil, "", 0, err
 	}
-	addr.UnassignedTime = time.Now()
-
-	//Update prometheus for ips per cidr
-	ipsPerCidr.With(prometheus.Labels{"cidr": availableCidr.Cidr.String()}).Dec()
+	for _, r := range released {
+		r.addr.UnassignedTime = time.Now()
+		//Update prometheus for ips per cidr
+		ipsPerCidr.With(prometheus.Labels{"cidr": r.cidr.Cidr.String()}).Dec()
+	}
 	ds.log.Infof("UnassignPodIPAddress: sandbox %s's ipAddr %s, DeviceNumber %d",
 		ipamKey, addr.Address, eni.DeviceNumber)
 	return eni, addr.Address, eni.DeviceNumber, nil
@@ -1231,32 +1837,75 @@ func (ds *DataStore) UnassignPodIPAddress(ipamKey IPAMKey) (e *ENI, ip string, d
 // AllocatedIPs returns a recent snapshot of allocated sandbox<->IPs.
 // Note result may already be stale by the time you look at it.
 func (ds *DataStore) AllocatedIPs() []PodIPInfo {
-	ds.lock.Lock()
-	defer ds.lock.Unlock()
+	ds.readLock("AllocatedIPs")
+	defer ds.lock.RUnlock()
 
 	ret := make([]PodIPInfo, 0, ds.eniPool.AssignedIPv4Addresses())
 	for _, eni := range ds.eniPool {
-		for _, assignedaddr := range eni.AvailableIPv4Cidrs {
-			for _, addr := range assignedaddr.IPAddresses {
-				if addr.Assigned() {
-					info := PodIPInfo{
-						IPAMKey:      addr.IPAMKey,
-						IP:           addr.Address,
-						DeviceNumber: eni.DeviceNumber,
-					}
-					ret = append(ret, info)
+		ret = eni.appendAllocatedIPs(ret, eni.AvailableIPv4Cidrs)
+	}
+	return ret
+}
+
+// AllocatedIPv6s returns a recent snapshot of allocated sandbox<->IPv6 addresses.
+// Note result may already be stale by the time you look at it.
+func (ds *DataStore) AllocatedIPv6s() []PodIPInfo {
+	ds.readLock("AllocatedIPv6s")
+	defer ds.lock.RUnlock()
+
+	var ret []PodIPInfo
+	for _, eni := range ds.eniPool {
+		ret = eni.appendAllocatedIPs(ret, eni.IPv6Cidrs)
+	}
+	return ret
+}
+
+// IsIPAssigned returns true if the IPv4 or IPv6 address ip is assigned to a pod.
+// Note result may already be stale by the time you look at it.
+func (ds *DataStore) IsIPAssigned(ip net.IP) bool {
+	ds.readLock("IsIPAssigned")
+	defer ds.lock.RUnlock()
+	return ds.isIPAssignedUnsafe(ip)
+}
+
+func (ds *DataStore) isIPAssignedUnsafe(ip net.IP) bool {
+	for _, eni := range ds.eniPool {
+		for _, cidrs := range []map[string]*CidrInfo{eni.AvailableIPv4Cidrs, eni.IPv6Cidrs} {
+			for _, cidr := range cidrs {
+				if !cidr.Cidr.Contains(ip) {
+					continue
+				}
+				if addr, ok := cidr.IPAddresses[ip.String()]; ok && addr.Assigned() {
+					return true
 				}
 			}
 		}
 	}
+	return false
+}
+
+func (e *ENI) appendAllocatedIPs(ret []PodIPInfo, cidrs map[string]*CidrInfo) []PodIPInfo {
+	for _, assignedaddr := range cidrs {
+		for _, addr := range assignedaddr.IPAddresses {
+			if addr.Assigned() {
+				info := PodIPInfo{
+					IPAMKey:      addr.IPAMKey,
+					IP:           addr.Address,
+					DeviceNumber: e.DeviceNumber,
+					Metadata:     addr.IPAMMetadata,
+				}
+				ret = append(ret, info)
+			}
+		}
+	}
 	return ret
 }
 
 // FreeableIPs returns a list of unused and potentially freeable IPs.
 // Note result may already be stale by the time you look at it.
 func (ds *DataStore) FreeableIPs(eniID string) []net.IPNet {
-	ds.lock.Lock()
-	defer ds.lock.Unlock()
+	ds.readLock("FreeableIPs")
+	defer ds.lock.RUnlock()
 
 	eni := ds.eniPool[eniID]
 	if eni == nil {
@@ -1277,8 +1926,8 @@ func (ds *DataStore) FreeableIPs(eniID string) []net.IPNet {
 // FreeablePrefixes returns a list of unused and potentially freeable IPs.
 // Note result may already be stale by the time you look at it.
 func (ds *DataStore) FreeablePrefixes(eniID string) []net.IPNet {
-	ds.lock.Lock()
-	defer ds.lock.Unlock()
+	ds.readLock("FreeablePrefixes")
+	defer ds.lock.RUnlock()
 
 	eni := ds.eniPool[eniID]
 	if eni == nil {
@@ -1297,8 +1946,8 @@ func (ds *DataStore) FreeablePrefixes(eniID string) []net.IPNet {
 
 // GetENIInfos provides ENI and IP information about the datastore
 func (ds *DataStore) GetENIInfos() *ENIInfos {
-	ds.lock.Lock()
-	defer ds.lock.Unlock()
+	ds.readLock("GetENIInfos")
+	defer ds.lock.RUnlock()
 
 	var eniInfos = ENIInfos{
 		TotalIPs:    ds.total,
@@ -1341,15 +1990,15 @@ func (ds *DataStore) GetENIInfos() *ENIInfos {
 
 // GetENIs provides the number of ENI in the datastore
 func (ds *DataStore) GetENIs() int {
-	ds.lock.Lock()
-	defer ds.lock.Unlock()
+	ds.readLock("GetENIs")
+	defer ds.lock.RUnlock()
 	return len(ds.eniPool)
 }
 
 // GetENICIDRs returns the known (allocated & unallocated) ENI secondary IPs and Prefixes
 func (ds *DataStore) GetENICIDRs(eniID string) ([]string, []string, error) {
-	ds.lock.Lock()
-	defer ds.lock.Unlock()
+	ds.readLock("GetENICIDRs")
+	defer ds.lock.RUnlock()
 
 	eni, ok := ds.eniPool[eniID]
 	if !ok {
@@ -1370,8 +2019,8 @@ func (ds *DataStore) GetENICIDRs(eniID string) ([]string, []string, error) {
 
 // GetFreePrefixes return free prefixes
 func (ds *DataStore) GetFreePrefixes() int {
-	ds.lock.Lock()
-	defer ds.lock.Unlock()
+	ds.readLock("GetFreePrefixes")
+	defer ds.lock.RUnlock()
 
 	freePrefixes := 0
 	for _, other := range ds.eniPool {
@@ -1425,7 +2074,7 @@ func (ds *DataStore) getUnusedIP(availableCidr *CidrInfo) (string, error) {
 	//Check if there is any IP out of cooldown
 	var cachedIP string
 	for _, addr := range availableCidr.IPAddresses {
-		if !addr.Assigned() && !addr.inCoolingPeriod() {
+		if !addr.Assigned() && !addr.inCoolingPeriod() && !addr.quarantined() {
 			//if the IP is out of cooldown and not assigned then cache the first available IP
 			//continue cleaning up the DB, this is to avoid stale entries and a new thread :)
 			if cachedIP == "" {
@@ -1477,8 +2126,8 @@ func GetPrefixDelegationDefaults() (int, int, int) {
 // FindFreeableCidrs finds and returns Cidrs that are not assigned to Pods but are attached
 // to ENIs on the node.
 func (ds *DataStore) FindFreeableCidrs(eniID string) []CidrInfo {
-	ds.lock.Lock()
-	defer ds.lock.Unlock()
+	ds.readLock("FindFreeableCidrs")
+	defer ds.lock.RUnlock()
 
 	eni := ds.eniPool[eniID]
 	if eni == nil {
@@ -1509,8 +2158,8 @@ func DivCeil(x, y int) int {
 // CheckFreeableENIexists will return true if there is an ENI which is unused.
 // Could have just called getDeletaleENI, this is just to optimize a bit.
 func (ds *DataStore) CheckFreeableENIexists() bool {
-	ds.lock.Lock()
-	defer ds.lock.Unlock()
+	ds.readLock("CheckFreeableENIexists")
+	defer ds.lock.RUnlock()
 
 	for _, eni := range ds.eniPool {
 		if eni.IsPrimary {
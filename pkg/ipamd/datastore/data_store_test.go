@@ -21,6 +21,7 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
 
@@ -149,6 +150,38 @@ func TestDeleteENIwithPDEnabled(t *testing.T) {
 
 }
 
+func TestDeleteENIPoolStats(t *testing.T) {
+	ds := NewDataStore(Testlog, NullCheckpoint{}, true)
+	for i, eniID := range []string{"eni-1", "eni-2", "eni-3"} {
+		assert.NoError(t, ds.AddENI(eniID, i+1, i == 0, false, false))
+		for j := 0; j < 2; j++ {
+			prefix := net.IPNet{IP: net.IPv4(10, 0, byte(i), byte(j*16)), Mask: net.CIDRMask(28, 32)}
+			assert.NoError(t, ds.AddIPv4CidrToStore(eniID, prefix, true))
+		}
+	}
+	assert.Equal(t, 96, ds.total)
+	assert.Equal(t, 6, ds.allocatedPrefix)
+
+	// A non-forced removal of an unused ENI gives back its prefixes once
+	assert.NoError(t, ds.RemoveENIFromDataStore("eni-2", false))
+	assert.Equal(t, 64, ds.total)
+	assert.Equal(t, 4, ds.allocatedPrefix)
+
+	// So does a forced removal of an ENI with pods
+	_, device, err := ds.AssignPodIPv4Address(IPAMKey{"net1", "sandbox1", "eth0"},
+		IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "sample-pod"})
+	assert.NoError(t, err)
+	eniID := "eni-1"
+	if device == 3 {
+		eniID = "eni-3"
+	}
+	assert.NoError(t, ds.RemoveENIFromDataStore(eniID, true))
+	assert.Equal(t, 32, ds.total)
+	assert.Equal(t, 2, ds.allocatedPrefix)
+	assert.Equal(t, 0, ds.assigned)
+	assert.Empty(t, ds.CheckInvariants())
+}
+
 func TestAddENIIPv4Address(t *testing.T) {
 	ds := NewDataStore(Testlog, NullCheckpoint{}, false)
 
@@ -881,6 +914,64 @@ func TestPodIPv4AddressWithPDEnabled(t *testing.T) {
 	assert.Equal(t, ds.assigned, 2)
 }
 
+func TestPodIPv4AddressPinned(t *testing.T) {
+	ds := NewDataStore(Testlog, NullCheckpoint{}, true)
+
+	err := ds.AddENI("eni-1", 1, true, false, false)
+	assert.NoError(t, err)
+	err = ds.AddENI("eni-2", 2, false, false, false)
+	assert.NoError(t, err)
+
+	_, prefix1, _ := net.ParseCIDR("10.1.1.0/28")
+	err = ds.AddIPv4CidrToStore("eni-1", *prefix1, true)
+	assert.NoError(t, err)
+	_, prefix2, _ := net.ParseCIDR("10.1.2.0/28")
+	err = ds.AddIPv4CidrToStore("eni-2", *prefix2, true)
+	assert.NoError(t, err)
+
+	// Pinned to the prefix of eni-2
+	ip, device, err := ds.AssignPodIPv4AddressPinned(IPAMKey{"net0", "sandbox-1", "eth0"}, IPAMMetadata{},
+		&AddressPin{CIDR: prefix2})
+	assert.NoError(t, err)
+	assert.True(t, prefix2.Contains(net.ParseIP(ip)))
+	assert.Equal(t, 2, device)
+
+	// Pinned to eni-1
+	ip, device, err = ds.AssignPodIPv4AddressPinned(IPAMKey{"net0", "sandbox-2", "eth0"}, IPAMMetadata{},
+		&AddressPin{ENIIDs: []string{"eni-1"}})
+	assert.NoError(t, err)
+	assert.True(t, prefix1.Contains(net.ParseIP(ip)))
+	assert.Equal(t, 1, device)
+
+	// A /16 containing both prefixes, narrowed down to eni-2
+	_, both, _ := net.ParseCIDR("10.1.0.0/16")
+	ip, _, err = ds.AssignPodIPv4AddressPinned(IPAMKey{"net0", "sandbox-3", "eth0"}, IPAMMetadata{},
+		&AddressPin{CIDR: both, ENIIDs: []string{"eni-2"}})
+	assert.NoError(t, err)
+	assert.True(t, prefix2.Contains(net.ParseIP(ip)))
+
+	// A CIDR smaller than the prefix can't be satisfied
+	_, small, _ := net.ParseCIDR("10.1.1.0/30")
+	_, _, err = ds.AssignPodIPv4AddressPinned(IPAMKey{"net0", "sandbox-4", "eth0"}, IPAMMetadata{},
+		&AddressPin{CIDR: small})
+	assert.True(t, errors.Is(err, ErrPinnedAddressUnavailable))
+
+	// No ENI holds this prefix
+	_, other, _ := net.ParseCIDR("10.1.3.0/28")
+	_, _, err = ds.AssignPodIPv4AddressPinned(IPAMKey{"net0", "sandbox-4", "eth0"}, IPAMMetadata{},
+		&AddressPin{CIDR: other})
+	assert.True(t, errors.Is(err, ErrPinnedAddressUnavailable))
+
+	// Invalid pins
+	_, _, err = ds.AssignPodIPv4AddressPinned(IPAMKey{"net0", "sandbox-4", "eth0"}, IPAMMetadata{}, &AddressPin{})
+	assert.Error(t, err)
+	_, v6, _ := net.ParseCIDR("2001:db8::/64")
+	_, _, err = ds.AssignPodIPv4AddressPinned(IPAMKey{"net0", "sandbox-4", "eth0"}, IPAMMetadata{},
+		&AddressPin{CIDR: v6})
+	assert.Error(t, err)
+	assert.Equal(t, 3, ds.assigned)
+}
+
 func TestGetIPStatsV4(t *testing.T) {
 	ds := NewDataStore(Testlog, NullCheckpoint{}, false)
 
@@ -932,6 +1023,74 @@ func TestGetIPStatsV4(t *testing.T) {
 	)
 }
 
+func TestSetENIMixedMode(t *testing.T) {
+	ds := NewDataStore(Testlog, NullCheckpoint{}, true)
+	_ = ds.AddENI("eni-1", 1, true, false, false)
+
+	ipv4Addr := net.IPNet{IP: net.ParseIP("10.0.0.20"), Mask: net.IPv4Mask(255, 255, 255, 255)}
+	_ = ds.AddIPv4CidrToStore("eni-1", ipv4Addr, false)
+
+	// With prefix delegation, secondary IPs are not handed out until the ENI is in mixed mode
+	key1 := IPAMKey{"net0", "sandbox-1", "eth0"}
+	_, _, err := ds.AssignPodIPv4Address(key1, IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "sample-pod-1"})
+	assert.Error(t, err)
+	assert.Equal(t, 0, ds.GetIPStats("4").TotalIPs)
+
+	assert.EqualError(t, ds.SetENIMixedMode("eni-2"), UnknownENIError)
+	assert.NoError(t, ds.SetENIMixedMode("eni-1"))
+	assert.True(t, ds.GetENIInfos().ENIs["eni-1"].MixedMode)
+
+	ip, deviceNumber, err := ds.AssignPodIPv4Address(key1, IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "sample-pod-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.20", ip)
+	assert.Equal(t, 1, deviceNumber)
+	assert.Equal(t,
+		DataStoreStats{
+			TotalIPs:      1,
+			TotalPrefixes: 0,
+			AssignedIPs:   1,
+		},
+		*ds.GetIPStats("4"),
+	)
+}
+
+func TestENIMixedModeRestart(t *testing.T) {
+	checkpoint := NewTestCheckpoint(CheckpointData{Version: CheckpointFormatVersion})
+	prefix := net.IPNet{IP: net.ParseIP("10.0.0.0"), Mask: net.CIDRMask(28, 32)}
+	secondaryIP := net.IPNet{IP: net.ParseIP("10.0.1.20"), Mask: net.CIDRMask(32, 32)}
+	// setupENI adds the prefixes and the secondary IPs found on the ENIs before the backing store is read
+	setupENIs := func(ds *DataStore) {
+		ds.CheckpointMigrationPhase = 2
+		for i, eniID := range []string{"eni-1", "eni-2"} {
+			assert.NoError(t, ds.AddENI(eniID, i, i == 0, false, false))
+		}
+		assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", prefix, true))
+		assert.NoError(t, ds.AddIPv4CidrToStore("eni-2", secondaryIP, false))
+	}
+
+	ds := NewDataStore(Testlog, checkpoint, true)
+	setupENIs(ds)
+	assert.Equal(t, 16, ds.GetIPStats("4").TotalIPs)
+	assert.NoError(t, ds.SetENIMixedMode("eni-2"))
+	assert.Equal(t, 17, ds.GetIPStats("4").TotalIPs)
+
+	// After ipamd restarts, the secondary IP is still handed out and counted
+	restarted := NewDataStore(Testlog, checkpoint, true)
+	setupENIs(restarted)
+	assert.NoError(t, restarted.ReadBackingStore(false))
+	assert.True(t, restarted.GetENIInfos().ENIs["eni-2"].MixedMode)
+	assert.False(t, restarted.GetENIInfos().ENIs["eni-1"].MixedMode)
+	assert.Equal(t, 17, restarted.GetIPStats("4").TotalIPs)
+
+	// An ENI that went away since is dropped from the checkpoint
+	restarted = NewDataStore(Testlog, checkpoint, true)
+	restarted.CheckpointMigrationPhase = 2
+	assert.NoError(t, restarted.AddENI("eni-1", 0, true, false, false))
+	assert.NoError(t, restarted.ReadBackingStore(false))
+	assert.NoError(t, restarted.SetENIMixedMode("eni-1"))
+	assert.Equal(t, []string{"eni-1"}, checkpoint.Data.(*CheckpointData).MixedModeENIs)
+}
+
 func TestGetIPStatsV4WithPD(t *testing.T) {
 	ds := NewDataStore(Testlog, NullCheckpoint{}, true)
 
@@ -1004,6 +1163,204 @@ func TestGetIPStatsV6(t *testing.T) {
 	)
 }
 
+func TestPodIPv6AddressCooldownAndCheckpoint(t *testing.T) {
+	checkpoint := NewTestCheckpoint(struct{}{})
+	ds := NewDataStore(Testlog, checkpoint, true)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	prefix := net.IPNet{IP: net.ParseIP("2001:db8::"), Mask: net.CIDRMask(80, 128)}
+	assert.NoError(t, ds.AddIPv6CidrToStore("eni-1", prefix, true))
+
+	key1 := IPAMKey{"netv6", "sandbox-1", "eth0"}
+	key2 := IPAMKey{"netv6", "sandbox-2", "eth0"}
+	ip1, _, err := ds.AssignPodIPv6Address(key1, IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "sample-pod-1"})
+	assert.NoError(t, err)
+	ip2, _, err := ds.AssignPodIPv6Address(key2, IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "sample-pod-2"})
+	assert.NoError(t, err)
+
+	// Both allocations are checkpointed with their IPv6 address
+	data := checkpoint.Data.(*CheckpointData)
+	assert.Len(t, data.Allocations, 2)
+	for _, allocation := range data.Allocations {
+		assert.Empty(t, allocation.IPv4)
+		assert.Contains(t, []string{ip1, ip2}, allocation.IPv6)
+	}
+
+	// A released address stays in cooldown, and isn't handed out again right away
+	_, _, _, err = ds.UnassignPodIPAddress(key1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, ds.GetIPStats("6").CooldownIPs)
+	assert.Equal(t, 1, ds.GetIPStats("6").AssignedIPs)
+	ip3, _, err := ds.AssignPodIPv6Address(IPAMKey{"netv6", "sandbox-3", "eth0"}, IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "sample-pod-3"})
+	assert.NoError(t, err)
+	assert.NotEqual(t, ip1, ip3)
+
+	// A failed checkpoint unwinds the assignment
+	checkpoint.Error = errors.New("fake checkpoint error")
+	_, _, err = ds.AssignPodIPv6Address(IPAMKey{"netv6", "sandbox-4", "eth0"}, IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "sample-pod-4"})
+	assert.Error(t, err)
+	assert.Equal(t, 2, ds.GetIPStats("6").AssignedIPs)
+	checkpoint.Error = nil
+
+	// A restarted datastore recovers the allocations from the checkpoint
+	restarted := NewDataStore(Testlog, checkpoint, true)
+	restarted.CheckpointMigrationPhase = 2
+	assert.NoError(t, restarted.AddENI("eni-1", 0, true, false, false))
+	assert.NoError(t, restarted.AddIPv6CidrToStore("eni-1", prefix, true))
+	assert.NoError(t, restarted.ReadBackingStore(true))
+	assert.Equal(t, 2, restarted.GetIPStats("6").AssignedIPs)
+	assert.True(t, restarted.IsIPAssigned(net.ParseIP(ip2)))
+	assert.True(t, restarted.IsIPAssigned(net.ParseIP(ip3)))
+	ip, _, err := restarted.AssignPodIPv6Address(key2, IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "sample-pod-2"})
+	assert.NoError(t, err)
+	assert.Equal(t, ip2, ip)
+}
+
+func TestAssignPodIPAddressFamilies(t *testing.T) {
+	ds := NewDataStore(Testlog, NullCheckpoint{}, true)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP("10.0.0.16"), Mask: net.CIDRMask(28, 32)}, true))
+
+	assert.Equal(t, FamilyIPv4, AddressFamilies(true, false))
+	assert.Equal(t, FamilyIPv4|FamilyIPv6, AddressFamilies(true, true))
+	_, err := ds.AssignPodIPAddress(IPAMKey{"net0", "sandbox-0", "eth0"}, IPAMMetadata{}, AddressFamilies(false, false))
+	assert.Error(t, err)
+
+	// No IPv6 prefix yet, so nothing is assigned at all
+	key := IPAMKey{"net0", "sandbox-1", "eth0"}
+	metadata := IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "sample-pod-1"}
+	_, err = ds.AssignPodIPAddress(key, metadata, FamilyIPv4|FamilyIPv6)
+	assert.True(t, errors.Is(err, ErrNoAvailableIPs))
+	assert.Equal(t, 0, ds.GetIPStats("4").AssignedIPs)
+
+	assert.NoError(t, ds.AddIPv6CidrToStore("eni-1", net.IPNet{IP: net.ParseIP("2001:db8::"), Mask: net.CIDRMask(80, 128)}, true))
+	addresses, err := ds.AssignPodIPAddress(key, metadata, FamilyIPv4|FamilyIPv6)
+	assert.NoError(t, err)
+	assert.True(t, net.ParseIP(addresses.IPv4).To4() != nil)
+	assert.True(t, net.ParseIP(addresses.IPv6).To4() == nil)
+	assert.Equal(t, 0, addresses.DeviceNumber)
+
+	// A duplicate assignment returns both addresses
+	duplicate, err := ds.AssignPodIPAddress(key, metadata, FamilyIPv4|FamilyIPv6)
+	assert.NoError(t, err)
+	assert.Equal(t, addresses, duplicate)
+
+	// Both are released together
+	_, _, _, err = ds.UnassignPodIPAddress(key)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, ds.GetIPStats("4").AssignedIPs)
+	assert.Equal(t, 0, ds.GetIPStats("6").AssignedIPs)
+	assert.Equal(t, 1, ds.GetIPStats("6").CooldownIPs)
+}
+
+func TestRekeyPodIPAddress(t *testing.T) {
+	checkpoint := NewTestCheckpoint(struct{}{})
+	ds := NewDataStore(Testlog, checkpoint, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	for _, ip := range []string{"1.1.1.1", "1.1.1.2", "1.1.1.3"} {
+		assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)}, false))
+	}
+
+	key1 := IPAMKey{"net0", "sandbox-1", "eth0"}
+	metadata := IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "sample-pod-1", K8SPodUID: "uid-1"}
+	ip, _, err := ds.AssignPodIPv4Address(key1, metadata)
+	assert.NoError(t, err)
+
+	// A new sandbox of the same pod gets the address of the previous one
+	key2 := IPAMKey{"net0", "sandbox-2", "eth0"}
+	rekeyed, _, err := ds.AssignPodIPv4Address(key2, metadata)
+	assert.NoError(t, err)
+	assert.Equal(t, ip, rekeyed)
+	assert.Equal(t, 1, ds.assigned)
+	expectedAllocations := []CheckpointEntry{{IPAMKey: key2, IPv4: ip, Metadata: metadata}}
+	allocations := checkpoint.Data.(*CheckpointData).Allocations
+	assert.True(t, cmp.Equal(allocations, expectedAllocations, cmpopts.IgnoreFields(CheckpointEntry{}, "AllocationTimestamp")),
+		cmp.Diff(allocations, expectedAllocations))
+	_, _, _, err = ds.UnassignPodIPAddress(key1)
+	assert.Equal(t, ErrUnknownPod, err)
+
+	// Another pod of the same name, or a pod without UID, gets a new address
+	recreated := IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "sample-pod-1", K8SPodUID: "uid-2"}
+	other, _, err := ds.AssignPodIPv4Address(IPAMKey{"net0", "sandbox-3", "eth0"}, recreated)
+	assert.NoError(t, err)
+	assert.NotEqual(t, ip, other)
+	noUID := IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "sample-pod-2"}
+	_, _, err = ds.AssignPodIPv4Address(IPAMKey{"net0", "sandbox-4", "eth0"}, noUID)
+	assert.NoError(t, err)
+	_, _, err = ds.AssignPodIPv4Address(IPAMKey{"net0", "sandbox-5", "eth0"}, noUID)
+	assert.True(t, errors.Is(err, ErrNoAvailableIPs))
+
+	// A checkpoint error keeps the previous sandbox
+	checkpoint.Error = errors.New("fake checkpoint error")
+	_, err = ds.RekeyPodIPAddress(key2, IPAMKey{"net0", "sandbox-6", "eth0"})
+	assert.Error(t, err)
+	checkpoint.Error = nil
+
+	_, err = ds.RekeyPodIPAddress(key2, IPAMKey{"net0", "sandbox-3", "eth0"})
+	assert.Error(t, err)
+	_, err = ds.RekeyPodIPAddress(key1, IPAMKey{"net0", "sandbox-6", "eth0"})
+	assert.Equal(t, ErrUnknownPod, err)
+	addresses, err := ds.RekeyPodIPAddress(key2, IPAMKey{"net0", "sandbox-6", "eth0"})
+	assert.NoError(t, err)
+	assert.Equal(t, ip, addresses.IPv4)
+	assert.Equal(t, 3, ds.assigned)
+}
+
+func TestClaimWarmVethIPAddress(t *testing.T) {
+	ds := NewDataStore(Testlog, NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 1, true, false, false))
+	for _, ip := range []string{"1.1.1.1", "1.1.1.2"} {
+		assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)}, false))
+	}
+
+	key := IPAMKey{"net0", "sandbox-1", "eth0"}
+	metadata := IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "sample-pod-1", K8SPodUID: "uid-1"}
+	_, _, err := ds.ClaimWarmVethIPAddress(key, metadata)
+	assert.Equal(t, ErrUnknownPod, err)
+
+	warm, err := ds.AssignPodIPAddress(WarmVethIPAMKey("warmveth0"), IPAMMetadata{}, FamilyIPv4)
+	assert.NoError(t, err)
+	hostVeth, addresses, err := ds.ClaimWarmVethIPAddress(key, metadata)
+	assert.NoError(t, err)
+	assert.Equal(t, "warmveth0", hostVeth)
+	assert.Equal(t, warm, addresses)
+	assert.Equal(t, 1, ds.assigned)
+	infos := ds.AllocatedIPs()
+	assert.Len(t, infos, 1)
+	assert.Equal(t, key, infos[0].IPAMKey)
+	assert.Equal(t, metadata, infos[0].Metadata)
+
+	// The pre-plumbed veth pair is gone, and a sandbox with addresses can't take another one
+	_, _, err = ds.ClaimWarmVethIPAddress(IPAMKey{"net0", "sandbox-2", "eth0"}, metadata)
+	assert.Equal(t, ErrUnknownPod, err)
+	_, err = ds.AssignPodIPAddress(WarmVethIPAMKey("warmveth1"), IPAMMetadata{}, FamilyIPv4)
+	assert.NoError(t, err)
+	_, _, err = ds.ClaimWarmVethIPAddress(key, metadata)
+	assert.Error(t, err)
+}
+
+func TestIsIPAssigned(t *testing.T) {
+	ds := NewDataStore(Testlog, NullCheckpoint{}, false)
+	_ = ds.AddENI("eni-1", 1, true, false, false)
+	_ = ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP("1.1.1.1"), Mask: net.IPv4Mask(255, 255, 255, 255)}, false)
+	_ = ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP("1.1.1.2"), Mask: net.IPv4Mask(255, 255, 255, 255)}, false)
+	ip, _, err := ds.AssignPodIPv4Address(IPAMKey{"net0", "sandbox-1", "eth0"}, IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "sample-pod-1"})
+	assert.NoError(t, err)
+
+	assert.True(t, ds.IsIPAssigned(net.ParseIP(ip)))
+	assert.False(t, ds.IsIPAssigned(net.ParseIP("1.1.1.3")))
+	_, _, _, err = ds.UnassignPodIPAddress(IPAMKey{"net0", "sandbox-1", "eth0"})
+	assert.NoError(t, err)
+	assert.False(t, ds.IsIPAssigned(net.ParseIP(ip)))
+
+	v6ds := NewDataStore(Testlog, NullCheckpoint{}, true)
+	_ = v6ds.AddENI("eni-1", 1, true, false, false)
+	_ = v6ds.AddIPv6CidrToStore("eni-1", net.IPNet{IP: net.ParseIP("2001:db8::"), Mask: net.CIDRMask(80, 128)}, true)
+	ip, _, err = v6ds.AssignPodIPv6Address(IPAMKey{"netv6", "sandbox-1", "eth0"}, IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "sample-pod-1"})
+	assert.NoError(t, err)
+	assert.True(t, v6ds.IsIPAssigned(net.ParseIP(ip)))
+	assert.False(t, v6ds.IsIPAssigned(net.ParseIP("2001:db8::ffff")))
+}
+
 func TestWarmENIInteractions(t *testing.T) {
 	ds := NewDataStore(Testlog, NullCheckpoint{}, false)
 
@@ -1070,3 +1427,15 @@ func TestWarmENIInteractions(t *testing.T) {
 	assert.Equal(t, "", thirdRemovedEni)
 	assert.Equal(t, 3, ds.GetENIs())
 }
+
+func TestLockWaitMetrics(t *testing.T) {
+	lockWaitSeconds.Reset()
+	ds := NewDataStore(Testlog, NullCheckpoint{}, false)
+
+	err := ds.AddENI("eni-1", 1, true, false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, ds.GetENIs())
+
+	// One series for AddENI taking the write lock, one for GetENIs taking the read lock
+	assert.Equal(t, 2, testutil.CollectAndCount(lockWaitSeconds))
+}
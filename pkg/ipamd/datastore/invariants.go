@@ -0,0 +1,190 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datastore
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+)
+
+// Invariants checked by CheckInvariants
+const (
+	InvariantTotal           = "Total"
+	InvariantAssigned        = "Assigned"
+	InvariantPrefixes        = "Prefixes"
+	InvariantUniqueIP        = "UniqueIP"
+	InvariantIPInCidr        = "IPInCidr"
+	InvariantCheckpointMatch = "CheckpointMatch"
+)
+
+// InvariantViolation is an inconsistency found in the datastore by CheckInvariants
+type InvariantViolation struct {
+	Invariant string
+	ENI       string `json:",omitempty"`
+	Cidr      string `json:",omitempty"`
+	IP        string `json:",omitempty"`
+	IPAMKey   string `json:",omitempty"`
+	Message   string
+}
+
+func (v InvariantViolation) String() string {
+	return fmt.Sprintf("%s: %s (eni: %q, cidr: %q, ip: %q, ipamKey: %q)", v.Invariant, v.Message, v.ENI, v.Cidr, v.IP, v.IPAMKey)
+}
+
+// addressLocation is where an address was seen while walking the ENI pool
+type addressLocation struct {
+	eni  string
+	cidr string
+}
+
+// CheckInvariants walks the datastore and returns everything that is inconsistent: the total and assigned counters
+// against the CIDRs and addresses they count, IPs found in more than one CIDR or outside of their own, and the
+// allocations of the checkpoint against the assigned addresses in memory. A consistent datastore returns no
+// violations. The checkpoint is skipped when there is none to compare with.
+func (ds *DataStore) CheckInvariants() []InvariantViolation {
+	ds.readLock("CheckInvariants")
+	defer ds.lock.RUnlock()
+
+	var violations []InvariantViolation
+	total, assigned, prefixes := 0, 0, 0
+	seen := make(map[string]addressLocation)
+	memAllocations := make(map[string]IPAMKey)
+
+	for _, eniID := range ds.sortedENIIDsUnsafe() {
+		eni := ds.eniPool[eniID]
+		for _, cidrs := range []map[string]*CidrInfo{eni.AvailableIPv4Cidrs, eni.IPv6Cidrs} {
+			for cidrKey, cidr := range cidrs {
+				total += cidr.Size()
+				if cidr.IsPrefix {
+					prefixes++
+				}
+				for ip, addr := range cidr.IPAddresses {
+					if prev, ok := seen[ip]; ok {
+						violations = append(violations, InvariantViolation{
+							Invariant: InvariantUniqueIP,
+							ENI:       eniID,
+							Cidr:      cidrKey,
+							IP:        ip,
+							Message:   fmt.Sprintf("IP is also in CIDR %s of ENI %s", prev.cidr, prev.eni),
+						})
+					}
+					seen[ip] = addressLocation{eni: eniID, cidr: cidrKey}
+					if parsed := net.ParseIP(ip); parsed == nil || !cidr.Cidr.Contains(parsed) {
+						violations = append(violations, InvariantViolation{
+							Invariant: InvariantIPInCidr,
+							ENI:       eniID,
+							Cidr:      cidrKey,
+							IP:        ip,
+							Message:   "IP is not in its CIDR",
+						})
+					}
+					if !addr.Assigned() {
+						continue
+					}
+					assigned++
+					memAllocations[ip] = addr.IPAMKey
+				}
+			}
+		}
+	}
+
+	if total != ds.total {
+		violations = append(violations, InvariantViolation{
+			Invariant: InvariantTotal,
+			Message:   fmt.Sprintf("total is %d, the CIDRs add up to %d", ds.total, total),
+		})
+	}
+	if assigned != ds.assigned {
+		violations = append(violations, InvariantViolation{
+			Invariant: InvariantAssigned,
+			Message:   fmt.Sprintf("assigned is %d, %d addresses are assigned", ds.assigned, assigned),
+		})
+	}
+	if prefixes != ds.allocatedPrefix {
+		violations = append(violations, InvariantViolation{
+			Invariant: InvariantPrefixes,
+			Message:   fmt.Sprintf("allocated prefixes is %d, %d prefixes are in the pool", ds.allocatedPrefix, prefixes),
+		})
+	}
+	return append(violations, ds.checkCheckpointUnsafe(memAllocations)...)
+}
+
+// checkCheckpointUnsafe compares the allocations of the checkpoint to the assigned addresses in memory, keyed by IP
+func (ds *DataStore) checkCheckpointUnsafe(memAllocations map[string]IPAMKey) []InvariantViolation {
+	var data CheckpointData
+	err := ds.backingStore.Restore(&data)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return []InvariantViolation{{
+			Invariant: InvariantCheckpointMatch,
+			Message:   fmt.Sprintf("failed to read the checkpoint: %v", err),
+		}}
+	}
+	if data.Version != CheckpointFormatVersion {
+		// Only an unmigrated checkpoint has another version, and it has nothing to compare with yet
+		return nil
+	}
+
+	var violations []InvariantViolation
+	checkpointed := make(map[string]bool, len(data.Allocations))
+	for _, entry := range data.Allocations {
+		ip := entry.IPv4
+		if ip == "" {
+			ip = entry.IPv6
+		}
+		checkpointed[ip] = true
+		ipamKey, ok := memAllocations[ip]
+		switch {
+		case !ok:
+			violations = append(violations, InvariantViolation{
+				Invariant: InvariantCheckpointMatch,
+				IP:        ip,
+				IPAMKey:   entry.IPAMKey.String(),
+				Message:   "IP is allocated in the checkpoint but not in memory",
+			})
+		case ipamKey != entry.IPAMKey:
+			violations = append(violations, InvariantViolation{
+				Invariant: InvariantCheckpointMatch,
+				IP:        ip,
+				IPAMKey:   ipamKey.String(),
+				Message:   fmt.Sprintf("IP is allocated to %s in the checkpoint", entry.IPAMKey.String()),
+			})
+		}
+	}
+	for ip, ipamKey := range memAllocations {
+		if !checkpointed[ip] {
+			violations = append(violations, InvariantViolation{
+				Invariant: InvariantCheckpointMatch,
+				IP:        ip,
+				IPAMKey:   ipamKey.String(),
+				Message:   "IP is allocated in memory but not in the checkpoint",
+			})
+		}
+	}
+	return violations
+}
+
+// sortedENIIDsUnsafe returns the IDs of the ENIs in the pool in order, so that violations are reported consistently
+func (ds *DataStore) sortedENIIDsUnsafe() []string {
+	eniIDs := make([]string, 0, len(ds.eniPool))
+	for eniID := range ds.eniPool {
+		eniIDs = append(eniIDs, eniID)
+	}
+	sort.Strings(eniIDs)
+	return eniIDs
+}
@@ -0,0 +1,115 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datastore
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckInvariants(t *testing.T) {
+	checkpoint := NewTestCheckpoint(CheckpointData{Version: CheckpointFormatVersion})
+	ds := NewDataStore(Testlog, checkpoint, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	assert.NoError(t, ds.AddENI("eni-2", 1, false, false, false))
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)}, false))
+	}
+	key := IPAMKey{NetworkName: "aws-cni", ContainerID: "cid-1", IfName: "eth0"}
+	ip, _, err := ds.AssignPodIPv4Address(key, IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "pod-1"})
+	assert.NoError(t, err)
+	// Added after the assignment, so that the pod IP is on eni-1
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-2", net.IPNet{IP: net.ParseIP("10.0.1.1"), Mask: net.CIDRMask(32, 32)}, false))
+	assert.Empty(t, ds.CheckInvariants())
+
+	// Counters out of sync with the pool
+	ds.total++
+	ds.assigned++
+	violations := ds.CheckInvariants()
+	assert.Len(t, violations, 2)
+	assert.Equal(t, InvariantTotal, violations[0].Invariant)
+	assert.Equal(t, InvariantAssigned, violations[1].Invariant)
+	ds.total--
+	ds.assigned--
+
+	// The same IP in two CIDRs, outside of the second one
+	ds.eniPool["eni-2"].AvailableIPv4Cidrs["10.0.1.1/32"].IPAddresses[ip] = &AddressInfo{Address: ip}
+	violations = ds.CheckInvariants()
+	assert.Len(t, violations, 2)
+	assert.Equal(t, InvariantViolation{
+		Invariant: InvariantUniqueIP,
+		ENI:       "eni-2",
+		Cidr:      "10.0.1.1/32",
+		IP:        ip,
+		Message:   fmt.Sprintf("IP is also in CIDR %s/32 of ENI eni-1", ip),
+	}, violations[0])
+	assert.Equal(t, InvariantIPInCidr, violations[1].Invariant)
+	delete(ds.eniPool["eni-2"].AvailableIPv4Cidrs["10.0.1.1/32"].IPAddresses, ip)
+
+	// A checkpoint that lost the allocation
+	checkpoint.Data = &CheckpointData{Version: CheckpointFormatVersion}
+	violations = ds.CheckInvariants()
+	assert.Equal(t, []InvariantViolation{{
+		Invariant: InvariantCheckpointMatch,
+		IP:        ip,
+		IPAMKey:   key.String(),
+		Message:   "IP is allocated in memory but not in the checkpoint",
+	}}, violations)
+
+	// Without a checkpoint there is nothing to compare with
+	ds.backingStore = NullCheckpoint{}
+	assert.Empty(t, ds.CheckInvariants())
+}
+
+// FuzzDataStoreInvariants applies a random sequence of datastore operations and checks the invariants after each one
+func FuzzDataStoreInvariants(f *testing.F) {
+	f.Add([]byte{0, 1, 1, 1, 2, 3, 2, 4, 3, 3, 4, 1, 5, 0})
+	f.Add([]byte{0, 0, 1, 0, 1, 1, 2, 0, 2, 1, 2, 2, 3, 1, 4, 0, 5, 1, 6, 1})
+	f.Add([]byte{7, 0, 1, 2, 1, 3, 2, 0, 2, 1, 2, 2, 2, 3, 5, 0, 4, 2, 3, 0})
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		isPDEnabled := len(ops)%2 == 1
+		ds := NewDataStore(Testlog, NewTestCheckpoint(CheckpointData{Version: CheckpointFormatVersion}), isPDEnabled)
+		for i := 0; i+1 < len(ops); i += 2 {
+			op, arg := ops[i]%7, int(ops[i+1])
+			eniID := fmt.Sprintf("eni-%d", arg%3)
+			key := IPAMKey{NetworkName: "aws-cni", ContainerID: fmt.Sprintf("cid-%d", arg%8), IfName: "eth0"}
+			cidr := net.IPNet{IP: net.IPv4(10, 0, byte(arg%3), byte(arg%4)*16), Mask: net.CIDRMask(28, 32)}
+			if !isPDEnabled {
+				cidr = net.IPNet{IP: net.IPv4(10, 0, byte(arg%3), byte(arg%8)), Mask: net.CIDRMask(32, 32)}
+			}
+			switch op {
+			case 0:
+				_ = ds.AddENI(eniID, arg%3, arg%3 == 0, false, false)
+			case 1:
+				_ = ds.AddIPv4CidrToStore(eniID, cidr, isPDEnabled)
+			case 2:
+				_, _, _ = ds.AssignPodIPv4Address(key, IPAMMetadata{K8SPodNamespace: "default", K8SPodName: key.ContainerID})
+			case 3:
+				_, _, _, _ = ds.UnassignPodIPAddress(key)
+			case 4:
+				_ = ds.DelIPv4CidrFromStore(eniID, cidr, arg%2 == 0)
+			case 5:
+				_ = ds.RemoveENIFromDataStore(eniID, arg%2 == 0)
+			case 6:
+				_ = ds.RemoveUnusedENIFromStore(0, 0, 0)
+			}
+			if violations := ds.CheckInvariants(); len(violations) > 0 {
+				t.Fatalf("op %d(%d) left the datastore inconsistent: %v", op, arg, violations)
+			}
+		}
+	})
+}
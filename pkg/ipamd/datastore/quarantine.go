@@ -0,0 +1,177 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datastore
+
+import (
+	"net"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultQuarantinePeriod is how long an IP stays quarantined when no period is given
+const DefaultQuarantinePeriod = time.Hour
+
+// ErrUnknownAddress is an error when an IP to quarantine is in none of the CIDRs of the data store
+var ErrUnknownAddress = errors.New("datastore: IP is not in any CIDR of the data store")
+
+var (
+	quarantinedIPs = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "awscni_quarantined_ip_addresses",
+			Help: "The number of free IP addresses taken out of rotation",
+		},
+	)
+	ipQuarantines = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "awscni_ip_quarantine_count",
+			Help: "The number of times an IP address was quarantined or released from quarantine",
+		},
+		[]string{"action"},
+	)
+)
+
+// QuarantinedIP is an IP address that is not handed out to pods until Until
+type QuarantinedIP struct {
+	IP     string
+	ENI    string
+	Reason string
+	Until  time.Time
+	// Assigned is true if a pod still has the IP, it is not handed out again once the pod releases it
+	Assigned bool
+}
+
+// quarantined returns true if the address is out of rotation
+func (addr AddressInfo) quarantined() bool {
+	return time.Now().Before(addr.QuarantinedUntil)
+}
+
+// QuarantineIP takes ip out of rotation for period, or DefaultQuarantinePeriod if period isn't positive, without
+// releasing it from its ENI. A pod that has the IP keeps it. Quarantining an IP again sets a new period and reason.
+func (ds *DataStore) QuarantineIP(ip string, period time.Duration, reason string) (QuarantinedIP, error) {
+	ds.writeLock("QuarantineIP")
+	defer ds.lock.Unlock()
+
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return QuarantinedIP{}, errors.Errorf("invalid IP %q", ip)
+	}
+	eni, cidr := ds.findCidrForIPUnsafe(parsed)
+	if cidr == nil {
+		return QuarantinedIP{}, errors.Wrap(ErrUnknownAddress, ip)
+	}
+	if period <= 0 {
+		period = DefaultQuarantinePeriod
+	}
+	if cidr.IPAddresses == nil {
+		cidr.IPAddresses = make(map[string]*AddressInfo)
+	}
+	addr := cidr.IPAddresses[parsed.String()]
+	if addr == nil {
+		addr = &AddressInfo{Address: parsed.String()}
+		cidr.IPAddresses[addr.Address] = addr
+	}
+	addr.QuarantinedUntil = time.Now().Add(period)
+	addr.QuarantineReason = reason
+	ds.log.Infof("Quarantined IP %s of ENI %s until %s: %s", addr.Address, eni.ID, addr.QuarantinedUntil.Format(time.RFC3339), reason)
+	ipQuarantines.WithLabelValues("quarantine").Inc()
+	ds.updateQuarantineMetricUnsafe()
+	return quarantinedIPInfo(eni, addr), nil
+}
+
+// UnquarantineIP puts a quarantined ip back in rotation, it returns false if ip was not quarantined
+func (ds *DataStore) UnquarantineIP(ip string) (bool, error) {
+	ds.writeLock("UnquarantineIP")
+	defer ds.lock.Unlock()
+
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false, errors.Errorf("invalid IP %q", ip)
+	}
+	_, cidr := ds.findCidrForIPUnsafe(parsed)
+	if cidr == nil {
+		return false, errors.Wrap(ErrUnknownAddress, ip)
+	}
+	addr := cidr.IPAddresses[parsed.String()]
+	if addr == nil || !addr.quarantined() {
+		return false, nil
+	}
+	addr.QuarantinedUntil = time.Time{}
+	addr.QuarantineReason = ""
+	ds.log.Infof("Released IP %s from quarantine", addr.Address)
+	ipQuarantines.WithLabelValues("unquarantine").Inc()
+	ds.updateQuarantineMetricUnsafe()
+	return true, nil
+}
+
+// QuarantinedIPs returns the IPs that are quarantined, sorted by IP
+func (ds *DataStore) QuarantinedIPs() []QuarantinedIP {
+	ds.readLock("QuarantinedIPs")
+	defer ds.lock.RUnlock()
+	return ds.quarantinedIPsUnsafe()
+}
+
+func (ds *DataStore) quarantinedIPsUnsafe() []QuarantinedIP {
+	var result []QuarantinedIP
+	for _, eni := range ds.eniPool {
+		for _, cidrs := range []map[string]*CidrInfo{eni.AvailableIPv4Cidrs, eni.IPv6Cidrs} {
+			for _, cidr := range cidrs {
+				for _, addr := range cidr.IPAddresses {
+					if addr.quarantined() {
+						result = append(result, quarantinedIPInfo(eni, addr))
+					}
+				}
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].IP < result[j].IP })
+	return result
+}
+
+func quarantinedIPInfo(eni *ENI, addr *AddressInfo) QuarantinedIP {
+	return QuarantinedIP{
+		IP:       addr.Address,
+		ENI:      eni.ID,
+		Reason:   addr.QuarantineReason,
+		Until:    addr.QuarantinedUntil,
+		Assigned: addr.Assigned(),
+	}
+}
+
+// findCidrForIPUnsafe returns the ENI and the CIDR that contain ip, or nil if none does
+func (ds *DataStore) findCidrForIPUnsafe(ip net.IP) (*ENI, *CidrInfo) {
+	for _, eni := range ds.eniPool {
+		for _, cidrs := range []map[string]*CidrInfo{eni.AvailableIPv4Cidrs, eni.IPv6Cidrs} {
+			for _, cidr := range cidrs {
+				if cidr.Cidr.Contains(ip) {
+					return eni, cidr
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+// updateQuarantineMetricUnsafe sets the quarantined IPs gauge, GetIPStats keeps it up to date as quarantines expire
+func (ds *DataStore) updateQuarantineMetricUnsafe() {
+	count := 0
+	for _, ip := range ds.quarantinedIPsUnsafe() {
+		if !ip.Assigned {
+			count++
+		}
+	}
+	quarantinedIPs.Set(float64(count))
+}
@@ -0,0 +1,84 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datastore
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuarantineIP(t *testing.T) {
+	ds := NewDataStore(Testlog, NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP("10.0.0.1"), Mask: net.CIDRMask(32, 32)}, false))
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP("10.0.0.2"), Mask: net.CIDRMask(32, 32)}, false))
+
+	_, err := ds.QuarantineIP("10.0.0.3", 0, "duplicate address")
+	assert.ErrorIs(t, err, ErrUnknownAddress)
+	_, err = ds.QuarantineIP("not-an-ip", 0, "duplicate address")
+	assert.Error(t, err)
+
+	quarantined, err := ds.QuarantineIP("10.0.0.1", 0, "duplicate address")
+	assert.NoError(t, err)
+	assert.Equal(t, "eni-1", quarantined.ENI)
+	assert.WithinDuration(t, time.Now().Add(DefaultQuarantinePeriod), quarantined.Until, time.Minute)
+	assert.Equal(t, []QuarantinedIP{quarantined}, ds.QuarantinedIPs())
+	assert.Equal(t, float64(1), testutil.ToFloat64(quarantinedIPs))
+
+	// Pods only get the other IP, and the quarantined one is not available for the warm targets
+	stats := ds.GetIPStats("4")
+	assert.Equal(t, 1, stats.QuarantinedIPs)
+	assert.Equal(t, 1, stats.AvailableAddresses())
+	ip, _, err := ds.AssignPodIPv4Address(IPAMKey{"net0", "sandbox-1", "eth0"}, IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "pod-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.2", ip)
+	_, _, err = ds.AssignPodIPv4Address(IPAMKey{"net0", "sandbox-2", "eth0"}, IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "pod-2"})
+	assert.ErrorIs(t, err, ErrNoAvailableIPs)
+
+	released, err := ds.UnquarantineIP("10.0.0.1")
+	assert.NoError(t, err)
+	assert.True(t, released)
+	released, err = ds.UnquarantineIP("10.0.0.1")
+	assert.NoError(t, err)
+	assert.False(t, released)
+	assert.Empty(t, ds.QuarantinedIPs())
+	assert.Equal(t, float64(0), testutil.ToFloat64(quarantinedIPs))
+	ip, _, err = ds.AssignPodIPv4Address(IPAMKey{"net0", "sandbox-2", "eth0"}, IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "pod-2"})
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.1", ip)
+}
+
+func TestQuarantineIPExpires(t *testing.T) {
+	ds := NewDataStore(Testlog, NullCheckpoint{}, true)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP("10.0.0.16"), Mask: net.CIDRMask(28, 32)}, true))
+
+	// An assigned IP stays with its pod, and is not free to quarantine
+	ip, _, err := ds.AssignPodIPv4Address(IPAMKey{"net0", "sandbox-1", "eth0"}, IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "pod-1"})
+	assert.NoError(t, err)
+	quarantined, err := ds.QuarantineIP(ip, time.Minute, "duplicate address")
+	assert.NoError(t, err)
+	assert.True(t, quarantined.Assigned)
+	assert.Equal(t, 0, ds.GetIPStats("4").QuarantinedIPs)
+
+	_, err = ds.QuarantineIP("10.0.0.20", time.Nanosecond, "duplicate address")
+	assert.NoError(t, err)
+	time.Sleep(time.Millisecond)
+	assert.Len(t, ds.QuarantinedIPs(), 1)
+	assert.Equal(t, 15, ds.GetIPStats("4").AvailableAddresses())
+}
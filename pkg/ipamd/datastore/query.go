@@ -0,0 +1,209 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datastore
+
+import (
+	"bytes"
+	"net"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Address states selected by AddressQuery.State
+const (
+	AddressStateAssigned   = "assigned"
+	AddressStateUnassigned = "unassigned"
+)
+
+// AddressQuery selects the ENIs and addresses returned by QueryENIInfos and QueryAddresses. The zero value selects
+// everything.
+type AddressQuery struct {
+	// ENIID selects a single ENI
+	ENIID string
+	// Namespace selects the addresses assigned to the pods of a namespace
+	Namespace string
+	// State selects the assigned or the unassigned addresses. Unassigned addresses are the ones released by a pod
+	// that are still in the datastore; the free addresses of a CIDR are never listed.
+	State string
+	// Offset is the number of results to skip, and Limit the maximum number of results, 0 for no limit
+	Offset int
+	Limit  int
+}
+
+// Validate checks the state and page of the query
+func (q AddressQuery) Validate() error {
+	if q.State != "" && q.State != AddressStateAssigned && q.State != AddressStateUnassigned {
+		return errors.Errorf("invalid state %q, expected %s or %s", q.State, AddressStateAssigned, AddressStateUnassigned)
+	}
+	if q.Offset < 0 || q.Limit < 0 {
+		return errors.New("offset and limit can't be negative")
+	}
+	return nil
+}
+
+// filtersAddresses returns true if the query selects addresses, not only ENIs
+func (q AddressQuery) filtersAddresses() bool {
+	return q.Namespace != "" || q.State != ""
+}
+
+func (q AddressQuery) matches(addr *AddressInfo) bool {
+	switch q.State {
+	case AddressStateAssigned:
+		if !addr.Assigned() {
+			return false
+		}
+	case AddressStateUnassigned:
+		if addr.Assigned() {
+			return false
+		}
+	}
+	return q.Namespace == "" || addr.Assigned() && addr.IPAMMetadata.K8SPodNamespace == q.Namespace
+}
+
+// page returns the bounds of the page of the query in n results, and the offset of the next page, or 0 if it is the
+// last one
+func (q AddressQuery) page(n int) (start, end, next int) {
+	start, end = q.Offset, n
+	if start > n {
+		start = n
+	}
+	if q.Limit > 0 && start+q.Limit < n {
+		end = start + q.Limit
+		next = end
+	}
+	return start, end, next
+}
+
+// AddressEntry is an address of the datastore, with the ENI and CIDR it is in
+type AddressEntry struct {
+	ENIID    string
+	Cidr     string
+	Assigned bool
+	AddressInfo
+}
+
+// AddressPage is a page of the addresses selected by an AddressQuery
+type AddressPage struct {
+	Addresses []AddressEntry
+	// NextOffset is the offset of the next page, or 0 if this is the last one
+	NextOffset int `json:",omitempty"`
+}
+
+// QueryENIInfos returns the ENIs selected by the query, sorted by ID and paged by its offset and limit. When the query
+// selects addresses, the ENIs and CIDRs only have the selected addresses, and the ones without any are left out. The
+// IP counts are the ones of the whole datastore.
+func (ds *DataStore) QueryENIInfos(q AddressQuery) *ENIInfos {
+	ds.readLock("QueryENIInfos")
+	defer ds.lock.RUnlock()
+
+	var selected []ENI
+	for _, eniID := range ds.sortedENIIDsUnsafe() {
+		if q.ENIID != "" && eniID != q.ENIID {
+			continue
+		}
+		eniInfo := ds.eniPool[eniID]
+		eni := *eniInfo
+		eni.AvailableIPv4Cidrs = q.copyCidrs(eniInfo.AvailableIPv4Cidrs)
+		eni.IPv6Cidrs = q.copyCidrs(eniInfo.IPv6Cidrs)
+		if q.filtersAddresses() && len(eni.AvailableIPv4Cidrs) == 0 && len(eni.IPv6Cidrs) == 0 {
+			continue
+		}
+		selected = append(selected, eni)
+	}
+
+	start, end, next := q.page(len(selected))
+	eniInfos := &ENIInfos{
+		TotalIPs:    ds.total,
+		AssignedIPs: ds.assigned,
+		ENIs:        make(map[string]ENI, end-start),
+		NextOffset:  next,
+	}
+	for _, eni := range selected[start:end] {
+		eniInfos.ENIs[eni.ID] = eni
+	}
+	return eniInfos
+}
+
+// copyCidrs returns a deep copy of the CIDRs with the addresses selected by the query. When the query selects
+// addresses, CIDRs without any are left out.
+func (q AddressQuery) copyCidrs(cidrs map[string]*CidrInfo) map[string]*CidrInfo {
+	copied := make(map[string]*CidrInfo, len(cidrs))
+	for key, cidr := range cidrs {
+		cidrCopy := &CidrInfo{
+			Cidr:          cidr.Cidr,
+			IPAddresses:   make(map[string]*AddressInfo),
+			IsPrefix:      cidr.IsPrefix,
+			AddressFamily: cidr.AddressFamily,
+		}
+		for ip, addr := range cidr.IPAddresses {
+			if q.matches(addr) {
+				addrCopy := *addr
+				cidrCopy.IPAddresses[ip] = &addrCopy
+			}
+		}
+		if q.filtersAddresses() && len(cidrCopy.IPAddresses) == 0 {
+			continue
+		}
+		copied[key] = cidrCopy
+	}
+	return copied
+}
+
+// QueryAddresses returns the addresses selected by the query, sorted by ENI and address and paged by its offset and
+// limit
+func (ds *DataStore) QueryAddresses(q AddressQuery) AddressPage {
+	ds.readLock("QueryAddresses")
+	defer ds.lock.RUnlock()
+
+	var entries []AddressEntry
+	for _, eniID := range ds.sortedENIIDsUnsafe() {
+		if q.ENIID != "" && eniID != q.ENIID {
+			continue
+		}
+		eni := ds.eniPool[eniID]
+		var eniEntries []AddressEntry
+		for _, cidrs := range []map[string]*CidrInfo{eni.AvailableIPv4Cidrs, eni.IPv6Cidrs} {
+			for cidrKey, cidr := range cidrs {
+				for _, addr := range cidr.IPAddresses {
+					if !q.matches(addr) {
+						continue
+					}
+					eniEntries = append(eniEntries, AddressEntry{
+						ENIID:       eniID,
+						Cidr:        cidrKey,
+						Assigned:    addr.Assigned(),
+						AddressInfo: *addr,
+					})
+				}
+			}
+		}
+		sort.Slice(eniEntries, func(i, j int) bool {
+			return compareIPs(eniEntries[i].Address, eniEntries[j].Address) < 0
+		})
+		entries = append(entries, eniEntries...)
+	}
+
+	start, end, next := q.page(len(entries))
+	return AddressPage{Addresses: append([]AddressEntry{}, entries[start:end]...), NextOffset: next}
+}
+
+// compareIPs orders IP addresses numerically, IPv4 before IPv6
+func compareIPs(a, b string) int {
+	ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+	if ipA4, ipB4 := ipA.To4(), ipB.To4(); ipA4 != nil && ipB4 != nil {
+		return bytes.Compare(ipA4, ipB4)
+	}
+	return bytes.Compare(ipA.To16(), ipB.To16())
+}
@@ -0,0 +1,126 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datastore
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryAddresses(t *testing.T) {
+	ds := NewDataStore(Testlog, NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	assert.NoError(t, ds.AddENI("eni-2", 1, false, false, false))
+	pods := []struct {
+		eni, ip, namespace, name string
+	}{
+		{"eni-1", "10.0.0.1", "a", "pod-1"},
+		{"eni-1", "10.0.0.3", "b", "pod-2"},
+		{"eni-2", "10.0.1.1", "a", "pod-3"},
+		{"eni-1", "10.0.0.2", "b", "pod-4"},
+	}
+	for _, pod := range pods {
+		cidr := net.IPNet{IP: net.ParseIP(pod.ip), Mask: net.CIDRMask(32, 32)}
+		assert.NoError(t, ds.AddIPv4CidrToStore(pod.eni, cidr, false))
+		_, _, err := ds.AssignPodIPv4AddressPinned(IPAMKey{NetworkName: "aws-cni", ContainerID: pod.name, IfName: "eth0"},
+			IPAMMetadata{K8SPodNamespace: pod.namespace, K8SPodName: pod.name}, &AddressPin{CIDR: &cidr})
+		assert.NoError(t, err)
+	}
+	// pod-4 is gone, its address stays in the datastore unassigned
+	_, _, _, err := ds.UnassignPodIPAddress(IPAMKey{NetworkName: "aws-cni", ContainerID: "pod-4", IfName: "eth0"})
+	assert.NoError(t, err)
+
+	addresses := func(q AddressQuery) ([]string, int) {
+		page := ds.QueryAddresses(q)
+		ips := []string{}
+		for _, entry := range page.Addresses {
+			ips = append(ips, entry.Address)
+		}
+		return ips, page.NextOffset
+	}
+	for _, tc := range []struct {
+		name     string
+		query    AddressQuery
+		expected []string
+		next     int
+	}{
+		{"all", AddressQuery{}, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.1.1"}, 0},
+		{"eni", AddressQuery{ENIID: "eni-2"}, []string{"10.0.1.1"}, 0},
+		{"namespace", AddressQuery{Namespace: "a"}, []string{"10.0.0.1", "10.0.1.1"}, 0},
+		{"namespace skips released", AddressQuery{Namespace: "b"}, []string{"10.0.0.3"}, 0},
+		{"assigned", AddressQuery{State: AddressStateAssigned}, []string{"10.0.0.1", "10.0.0.3", "10.0.1.1"}, 0},
+		{"unassigned", AddressQuery{State: AddressStateUnassigned}, []string{"10.0.0.2"}, 0},
+		{"first page", AddressQuery{Limit: 3}, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, 3},
+		{"last page", AddressQuery{Offset: 3, Limit: 3}, []string{"10.0.1.1"}, 0},
+		{"past the end", AddressQuery{Offset: 10}, []string{}, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ips, next := addresses(tc.query)
+			assert.Equal(t, tc.expected, ips)
+			assert.Equal(t, tc.next, next)
+		})
+	}
+
+	page := ds.QueryAddresses(AddressQuery{ENIID: "eni-2"})
+	assert.Equal(t, "10.0.1.1/32", page.Addresses[0].Cidr)
+	assert.True(t, page.Addresses[0].Assigned)
+	assert.Equal(t, "pod-3", page.Addresses[0].IPAMMetadata.K8SPodName)
+}
+
+func TestQueryENIInfos(t *testing.T) {
+	ds := NewDataStore(Testlog, NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	assert.NoError(t, ds.AddENI("eni-2", 1, false, false, false))
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)}, false))
+	}
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-2", net.IPNet{IP: net.ParseIP("10.0.1.1"), Mask: net.CIDRMask(32, 32)}, false))
+	cidr := net.IPNet{IP: net.ParseIP("10.0.0.1"), Mask: net.CIDRMask(32, 32)}
+	_, _, err := ds.AssignPodIPv4AddressPinned(IPAMKey{NetworkName: "aws-cni", ContainerID: "cid-1", IfName: "eth0"},
+		IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "pod-1"}, &AddressPin{CIDR: &cidr})
+	assert.NoError(t, err)
+
+	// Without address filters, all the CIDRs are listed
+	infos := ds.QueryENIInfos(AddressQuery{})
+	assert.Equal(t, 3, infos.TotalIPs)
+	assert.Equal(t, 1, infos.AssignedIPs)
+	assert.Len(t, infos.ENIs, 2)
+	assert.Len(t, infos.ENIs["eni-1"].AvailableIPv4Cidrs, 2)
+	assert.Zero(t, infos.NextOffset)
+
+	infos = ds.QueryENIInfos(AddressQuery{Namespace: "default"})
+	assert.Len(t, infos.ENIs, 1)
+	assert.Len(t, infos.ENIs["eni-1"].AvailableIPv4Cidrs, 1)
+	assert.Contains(t, infos.ENIs["eni-1"].AvailableIPv4Cidrs["10.0.0.1/32"].IPAddresses, "10.0.0.1")
+
+	infos = ds.QueryENIInfos(AddressQuery{Limit: 1})
+	assert.Len(t, infos.ENIs, 1)
+	assert.Contains(t, infos.ENIs, "eni-1")
+	assert.Equal(t, 1, infos.NextOffset)
+	infos = ds.QueryENIInfos(AddressQuery{Offset: 1, Limit: 1})
+	assert.Contains(t, infos.ENIs, "eni-2")
+	assert.Zero(t, infos.NextOffset)
+
+	// The copy doesn't change with the datastore
+	infos = ds.QueryENIInfos(AddressQuery{ENIID: "eni-1"})
+	_, _, _, err = ds.UnassignPodIPAddress(IPAMKey{NetworkName: "aws-cni", ContainerID: "cid-1", IfName: "eth0"})
+	assert.NoError(t, err)
+	assert.True(t, infos.ENIs["eni-1"].AvailableIPv4Cidrs["10.0.0.1/32"].IPAddresses["10.0.0.1"].Assigned())
+
+	assert.Error(t, AddressQuery{State: "free"}.Validate())
+	assert.Error(t, AddressQuery{Limit: -1}.Validate())
+	assert.NoError(t, AddressQuery{State: AddressStateUnassigned, Limit: 10}.Validate())
+}
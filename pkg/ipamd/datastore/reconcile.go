@@ -0,0 +1,112 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datastore
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// reconcileGracePeriod protects the IPs assigned by an ADD that was still running when the container runtime built
+// its list of valid attachments
+const reconcileGracePeriod = time.Minute
+
+// staleAddress is an assigned address that ReconcileAgainst releases
+type staleAddress struct {
+	eni          *ENI
+	cidr         *CidrInfo
+	addr         *AddressInfo
+	ipamKey      IPAMKey
+	ipamMetadata IPAMMetadata
+	assignedTime time.Time
+}
+
+// ReconcileAgainst releases the IPs assigned to the sandboxes of networkName that are not in sandboxSet, the
+// attachments the container runtime still knows about. Allocations of other networks and the ones recovered from the
+// pod routes are left alone, since the runtime doesn't know them under the same key. The released IPs are returned, so
+// that the routes of their pods can be torn down.
+func (ds *DataStore) ReconcileAgainst(networkName string, sandboxSet map[IPAMKey]bool) ([]PodIPInfo, error) {
+	ds.writeLock("ReconcileAgainst")
+	defer ds.lock.Unlock()
+
+	// Allocations backfilled from CRI don't have the true network name and ifname, only the container ID
+	validContainers := make(map[string]bool, len(sandboxSet))
+	for ipamKey := range sandboxSet {
+		validContainers[ipamKey.ContainerID] = true
+	}
+
+	now := time.Now()
+	var stale []staleAddress
+	for _, eni := range ds.eniPool {
+		for _, cidrs := range []map[string]*CidrInfo{eni.AvailableIPv4Cidrs, eni.IPv6Cidrs} {
+			for _, cidr := range cidrs {
+				for _, addr := range cidr.IPAddresses {
+					if !addr.Assigned() || now.Sub(addr.AssignedTime) < reconcileGracePeriod {
+						continue
+					}
+					switch addr.IPAMKey.NetworkName {
+					case networkName:
+						if sandboxSet[addr.IPAMKey] {
+							continue
+						}
+					case backfillNetworkName:
+						if validContainers[addr.IPAMKey.ContainerID] {
+							continue
+						}
+					default:
+						continue
+					}
+					stale = append(stale, staleAddress{
+						eni:          eni,
+						cidr:         cidr,
+						addr:         addr,
+						ipamKey:      addr.IPAMKey,
+						ipamMetadata: addr.IPAMMetadata,
+						assignedTime: addr.AssignedTime,
+					})
+				}
+			}
+		}
+	}
+	if len(stale) == 0 {
+		return nil, nil
+	}
+
+	for _, s := range stale {
+		ds.unassignPodIPAddressUnsafe(s.addr)
+	}
+	if err := ds.writeBackingStoreUnsafe(); err != nil {
+		// Unwind un-assignment
+		for _, s := range stale {
+			ds.assignPodIPAddressUnsafe(s.addr, s.ipamKey, s.ipamMetadata, s.assignedTime)
+		}
+		return nil, err
+	}
+
+	released := make([]PodIPInfo, 0, len(stale))
+	for _, s := range stale {
+		s.addr.UnassignedTime = now
+		ipsPerCidr.With(prometheus.Labels{"cidr": s.cidr.Cidr.String()}).Dec()
+		ds.log.Infof("ReconcileAgainst: released ipAddr %s of stale sandbox %s, DeviceNumber %d",
+			s.addr.Address, s.ipamKey, s.eni.DeviceNumber)
+		released = append(released, PodIPInfo{
+			IPAMKey:      s.ipamKey,
+			IP:           s.addr.Address,
+			DeviceNumber: s.eni.DeviceNumber,
+			Metadata:     s.ipamMetadata,
+		})
+	}
+	return released, nil
+}
@@ -0,0 +1,76 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datastore
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconcileAgainst(t *testing.T) {
+	checkpoint := NewTestCheckpoint(struct{}{})
+	ds := NewDataStore(Testlog, checkpoint, false)
+	assert.NoError(t, ds.AddENI("eni-1", 1, true, false, false))
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5", "10.0.0.6"} {
+		assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)}, false))
+	}
+
+	valid := IPAMKey{NetworkName: "aws-cni", ContainerID: "valid", IfName: "eth0"}
+	stale := IPAMKey{NetworkName: "aws-cni", ContainerID: "stale", IfName: "eth0"}
+	recent := IPAMKey{NetworkName: "aws-cni", ContainerID: "recent", IfName: "eth0"}
+	otherNetwork := IPAMKey{NetworkName: "other", ContainerID: "other", IfName: "eth0"}
+	backfilled := IPAMKey{NetworkName: backfillNetworkName, ContainerID: "valid", IfName: backfillNetworkIface}
+	recovered := RecoveredIPAMKey("eni1a2b3c4d5e6")
+	for _, key := range []IPAMKey{valid, stale, recent, otherNetwork, backfilled, recovered} {
+		_, _, err := ds.AssignPodIPv4Address(key, IPAMMetadata{K8SPodNamespace: "default", K8SPodName: key.ContainerID})
+		assert.NoError(t, err)
+	}
+	// Everything but recent was assigned before the grace period
+	for _, cidr := range ds.eniPool["eni-1"].AvailableIPv4Cidrs {
+		for _, addr := range cidr.IPAddresses {
+			if addr.IPAMKey != recent {
+				addr.AssignedTime = time.Now().Add(-2 * reconcileGracePeriod)
+			}
+		}
+	}
+
+	sandboxSet := map[IPAMKey]bool{valid: true}
+
+	checkpoint.Error = errors.New("disk full")
+	_, err := ds.ReconcileAgainst("aws-cni", sandboxSet)
+	assert.Error(t, err)
+	assert.Equal(t, 6, ds.assigned)
+
+	checkpoint.Error = nil
+	released, err := ds.ReconcileAgainst("aws-cni", sandboxSet)
+	assert.NoError(t, err)
+	assert.Len(t, released, 1)
+	assert.Equal(t, stale, released[0].IPAMKey)
+	assert.Equal(t, 1, released[0].DeviceNumber)
+	assert.Equal(t, "stale", released[0].Metadata.K8SPodName)
+	assert.Equal(t, 5, ds.assigned)
+
+	// A backfilled allocation is matched by its container ID
+	released, err = ds.ReconcileAgainst("aws-cni", map[IPAMKey]bool{})
+	assert.NoError(t, err)
+	assert.Len(t, released, 2)
+	assert.Equal(t, 3, ds.assigned)
+
+	data := checkpoint.Data.(*CheckpointData)
+	assert.Len(t, data.Allocations, 3)
+}
@@ -0,0 +1,174 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datastore
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrRequestConflict is an error when a request ID was used for another sandbox or operation
+var ErrRequestConflict = errors.New("datastore: request conflicts with an earlier request of the same ID")
+
+// requestRecordTTL is how long a completed request is remembered for its retries
+const requestRecordTTL = 10 * time.Minute
+
+// RequestOp is the operation of a CNI request
+type RequestOp string
+
+const (
+	// RequestAdd is an AddNetwork request
+	RequestAdd RequestOp = "add"
+	// RequestDel is a DelNetwork request
+	RequestDel RequestOp = "del"
+)
+
+var retriedRequests = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "awscni_retried_requests",
+		Help: "The number of retried requests, by operation and whether they were replayed, stale or conflicted",
+	},
+	[]string{"op", "result"},
+)
+
+// requestRecord is the result of a completed request
+type requestRecord struct {
+	op          RequestOp
+	ipamKey     IPAMKey
+	addresses   PodAddresses
+	completedAt time.Time
+}
+
+// CheckpointRequest is a completed request, as stored in checkpoints so that its retries are replayed across restarts
+type CheckpointRequest struct {
+	IPAMKey
+	ID           string    `json:"id"`
+	Op           RequestOp `json:"op"`
+	IPv4         string    `json:"ipv4,omitempty"`
+	IPv6         string    `json:"ipv6,omitempty"`
+	DeviceNumber int       `json:"deviceNumber"`
+	CompletedAt  int64     `json:"completedAt"`
+}
+
+// CheckRequest looks up an earlier request of the same ID. found is true when the request already completed, with
+// the addresses it assigned or released. A record whose addresses changed hands since is stale, e.g. the add of a
+// sandbox that was deleted since, or the delete of a sandbox that was added again or whose addresses were assigned
+// again since, and the request is handled as a new one. ErrRequestConflict is returned when the ID was used for
+// another sandbox or operation.
+func (ds *DataStore) CheckRequest(requestID string, op RequestOp, ipamKey IPAMKey) (addresses PodAddresses, found bool, err error) {
+	ds.readLock("CheckRequest")
+	defer ds.lock.RUnlock()
+
+	notFound := PodAddresses{DeviceNumber: -1}
+	record, ok := ds.requests[requestID]
+	if !ok || time.Since(record.completedAt) > requestRecordTTL {
+		return notFound, false, nil
+	}
+	if record.op != op || record.ipamKey != ipamKey {
+		ds.log.Warnf("Request %s: %s of sandbox %s, was %s of sandbox %s", requestID, op, ipamKey, record.op, record.ipamKey)
+		retriedRequests.With(prometheus.Labels{"op": string(op), "result": "conflict"}).Inc()
+		return notFound, false, ErrRequestConflict
+	}
+	stale := func(format string, args ...interface{}) (PodAddresses, bool, error) {
+		ds.log.Infof("Request %s: "+format+", handling it as a new request", append([]interface{}{requestID}, args...)...)
+		retriedRequests.With(prometheus.Labels{"op": string(op), "result": "stale"}).Inc()
+		return notFound, false, nil
+	}
+	switch op {
+	case RequestAdd:
+		if current, assigned := ds.findPodAddressesUnsafe(ipamKey); !assigned || current != record.addresses {
+			return stale("the addresses %s %s of sandbox %s were released since",
+				record.addresses.IPv4, record.addresses.IPv6, ipamKey)
+		}
+	case RequestDel:
+		if _, assigned := ds.findPodAddressesUnsafe(ipamKey); assigned {
+			return stale("sandbox %s was assigned addresses again since", ipamKey)
+		}
+		for _, ip := range []string{record.addresses.IPv4, record.addresses.IPv6} {
+			if ip != "" && ds.isIPAssignedUnsafe(net.ParseIP(ip)) {
+				return stale("the address %s released from sandbox %s was assigned again since", ip, ipamKey)
+			}
+		}
+	}
+	ds.log.Infof("Request %s: replaying %s of sandbox %s", requestID, op, ipamKey)
+	retriedRequests.With(prometheus.Labels{"op": string(op), "result": "replayed"}).Inc()
+	return record.addresses, true, nil
+}
+
+// recordRequestUnsafe records the addresses assigned or released by the request requestID, if set, for its retries. The
+// record is persisted by the next checkpoint write. It returns a func that undoes the record, and whether the same
+// record was already there.
+func (ds *DataStore) recordRequestUnsafe(requestID string, op RequestOp, ipamKey IPAMKey, addresses PodAddresses) (undo func(), recorded bool) {
+	if requestID == "" {
+		return func() {}, true
+	}
+	previous, found := ds.requests[requestID]
+	if found && previous.op == op && previous.ipamKey == ipamKey && previous.addresses == addresses &&
+		time.Since(previous.completedAt) <= requestRecordTTL {
+		return func() {}, true
+	}
+	ds.requests[requestID] = requestRecord{op: op, ipamKey: ipamKey, addresses: addresses, completedAt: time.Now()}
+	return func() {
+		if found {
+			ds.requests[requestID] = previous
+		} else {
+			delete(ds.requests, requestID)
+		}
+	}, false
+}
+
+// checkpointRequestsUnsafe forgets the expired requests, and returns the others as stored in checkpoints
+func (ds *DataStore) checkpointRequestsUnsafe() []CheckpointRequest {
+	var requests []CheckpointRequest
+	now := time.Now()
+	for id, record := range ds.requests {
+		if now.Sub(record.completedAt) > requestRecordTTL {
+			delete(ds.requests, id)
+			continue
+		}
+		requests = append(requests, CheckpointRequest{
+			IPAMKey:      record.ipamKey,
+			ID:           id,
+			Op:           record.op,
+			IPv4:         record.addresses.IPv4,
+			IPv6:         record.addresses.IPv6,
+			DeviceNumber: record.addresses.DeviceNumber,
+			CompletedAt:  record.completedAt.UnixNano(),
+		})
+	}
+	return requests
+}
+
+// restoreRequestsUnsafe restores the requests of a checkpoint that did not expire yet
+func (ds *DataStore) restoreRequestsUnsafe(requests []CheckpointRequest) {
+	for _, request := range requests {
+		completedAt := time.Unix(0, request.CompletedAt)
+		if time.Since(completedAt) > requestRecordTTL {
+			continue
+		}
+		ds.requests[request.ID] = requestRecord{
+			op:      request.Op,
+			ipamKey: request.IPAMKey,
+			addresses: PodAddresses{
+				IPv4:         request.IPv4,
+				IPv6:         request.IPv6,
+				DeviceNumber: request.DeviceNumber,
+			},
+			completedAt: completedAt,
+		}
+	}
+}
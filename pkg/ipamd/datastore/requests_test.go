@@ -0,0 +1,145 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datastore
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckRequest(t *testing.T) {
+	ds := NewDataStore(Testlog, NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP("1.1.1.1"), Mask: net.CIDRMask(32, 32)}, false))
+
+	key := IPAMKey{"net0", "sandbox-1", "eth0"}
+	_, found, err := ds.CheckRequest("add-1", RequestAdd, key)
+	assert.NoError(t, err)
+	assert.False(t, found)
+	addresses, err := ds.AssignPodIPAddressForRequest("add-1", key, IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "sample-pod-1"}, FamilyIPv4, nil)
+	assert.NoError(t, err)
+
+	// A retry is replayed, the same ID for another sandbox or operation is a conflict
+	replayed, found, err := ds.CheckRequest("add-1", RequestAdd, key)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, addresses, replayed)
+	_, _, err = ds.CheckRequest("add-1", RequestAdd, IPAMKey{"net0", "sandbox-2", "eth0"})
+	assert.Equal(t, ErrRequestConflict, err)
+	_, _, err = ds.CheckRequest("add-1", RequestDel, key)
+	assert.Equal(t, ErrRequestConflict, err)
+
+	_, _, _, err = ds.UnassignPodIPAddressForRequest("del-1", key, key)
+	assert.NoError(t, err)
+
+	// The sandbox is gone, an add of the same ID after its delete is a new request
+	_, found, err = ds.CheckRequest("add-1", RequestAdd, key)
+	assert.NoError(t, err)
+	assert.False(t, found)
+	replayed, found, err = ds.CheckRequest("del-1", RequestDel, key)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, addresses, replayed)
+
+	// Once the address belongs to another sandbox, replaying the delete would tear down its routes
+	ds.eniPool["eni-1"].AvailableIPv4Cidrs["1.1.1.1/32"].IPAddresses["1.1.1.1"].UnassignedTime = time.Time{}
+	otherKey := IPAMKey{"net0", "sandbox-2", "eth0"}
+	_, err = ds.AssignPodIPAddress(otherKey, IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "sample-pod-2"}, FamilyIPv4)
+	assert.NoError(t, err)
+	_, found, err = ds.CheckRequest("del-1", RequestDel, key)
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	// Expired records are forgotten
+	ds.requests["del-1"] = requestRecord{op: RequestDel, ipamKey: key, addresses: addresses, completedAt: time.Now().Add(-2 * requestRecordTTL)}
+	_, found, err = ds.CheckRequest("del-1", RequestDel, key)
+	assert.NoError(t, err)
+	assert.False(t, found)
+	_, _, _, err = ds.UnassignPodIPAddressForRequest("del-2", otherKey, otherKey)
+	assert.NoError(t, err)
+	assert.NotContains(t, ds.requests, "del-1")
+}
+
+// countingCheckpoint counts the checkpoint writes
+type countingCheckpoint struct {
+	*TestCheckpoint
+	writes int
+}
+
+func (c *countingCheckpoint) Checkpoint(data interface{}) error {
+	c.writes++
+	return c.TestCheckpoint.Checkpoint(data)
+}
+
+func TestRequestsCheckpoint(t *testing.T) {
+	checkpoint := &countingCheckpoint{TestCheckpoint: NewTestCheckpoint(CheckpointData{Version: CheckpointFormatVersion})}
+	ds := NewDataStore(Testlog, checkpoint, false)
+	ds.CheckpointMigrationPhase = 2
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	for _, ip := range []string{"1.1.1.1", "1.1.1.2", "1.1.1.3"} {
+		assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)}, false))
+	}
+
+	// The request is recorded in the checkpoint write of the assignment, and a retry writes nothing
+	key := IPAMKey{"net0", "sandbox-1", "eth0"}
+	metadata := IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "sample-pod-1"}
+	writes := checkpoint.writes
+	addresses, err := ds.AssignPodIPAddressForRequest("add-1", key, metadata, FamilyIPv4, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, writes+1, checkpoint.writes)
+	_, err = ds.AssignPodIPAddressForRequest("add-1", key, metadata, FamilyIPv4, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, writes+1, checkpoint.writes)
+
+	// So is the delete of another sandbox, in the checkpoint write of the unassignment
+	otherKey := IPAMKey{"net0", "sandbox-2", "eth0"}
+	_, err = ds.AssignPodIPAddress(otherKey, IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "sample-pod-2"}, FamilyIPv4)
+	assert.NoError(t, err)
+	writes = checkpoint.writes
+	_, released, _, err := ds.UnassignPodIPAddressForRequest("del-2", otherKey, otherKey)
+	assert.NoError(t, err)
+	assert.Equal(t, writes+1, checkpoint.writes)
+
+	// A failed checkpoint write records nothing
+	checkpoint.Error = errors.New("disk full")
+	_, err = ds.AssignPodIPAddressForRequest("add-3", IPAMKey{"net0", "sandbox-3", "eth0"}, metadata, FamilyIPv4, nil)
+	assert.Equal(t, checkpoint.Error, err)
+	assert.NotContains(t, ds.requests, "add-3")
+	checkpoint.Error = nil
+
+	// ipamd restarted, a retry of a request completed before is still replayed, and expired ones are forgotten
+	data := checkpoint.Data.(*CheckpointData)
+	data.Requests = append(data.Requests, CheckpointRequest{IPAMKey: key, ID: "add-0", Op: RequestAdd,
+		IPv4: addresses.IPv4, CompletedAt: time.Now().Add(-2 * requestRecordTTL).UnixNano()})
+	restarted := NewDataStore(Testlog, checkpoint, false)
+	restarted.CheckpointMigrationPhase = 2
+	assert.NoError(t, restarted.AddENI("eni-1", 0, true, false, false))
+	for _, ip := range []string{"1.1.1.1", "1.1.1.2", "1.1.1.3"} {
+		assert.NoError(t, restarted.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)}, false))
+	}
+	assert.NoError(t, restarted.ReadBackingStore(false))
+	replayed, found, err := restarted.CheckRequest("add-1", RequestAdd, key)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, addresses, replayed)
+	replayed, found, err = restarted.CheckRequest("del-2", RequestDel, otherKey)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, released, replayed.IPv4)
+	assert.NotContains(t, restarted.requests, "add-0")
+}
@@ -0,0 +1,63 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datastore
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+// RoutabilityClass tells whether the IPv4 addresses of a CIDR can be reached from outside the VPC
+type RoutabilityClass string
+
+const (
+	// ClassRoutable addresses are reachable from the networks connected to the VPC, like peered VPCs or on-premises
+	ClassRoutable RoutabilityClass = "routable"
+	// ClassNonRoutable addresses, typically from a 100.64.0.0/10 (CG-NAT) secondary CIDR of the VPC, are only reachable
+	// inside the VPC, so the traffic of their pods to other networks is always SNATed to the node
+	ClassNonRoutable RoutabilityClass = "non-routable"
+)
+
+// ParseRoutabilityClass returns the class named value
+func ParseRoutabilityClass(value string) (RoutabilityClass, error) {
+	switch class := RoutabilityClass(value); class {
+	case ClassRoutable, ClassNonRoutable:
+		return class, nil
+	}
+	return "", errors.Errorf("unknown routability class %q, expected %q or %q", value, ClassRoutable, ClassNonRoutable)
+}
+
+// SetNonRoutableCIDRs sets the IPv4 CIDRs whose addresses are of ClassNonRoutable, all the other addresses being of
+// ClassRoutable, and classifies the CIDRs already in the data store again
+func (ds *DataStore) SetNonRoutableCIDRs(cidrs []net.IPNet) {
+	ds.writeLock("SetNonRoutableCIDRs")
+	defer ds.lock.Unlock()
+	ds.nonRoutableCIDRs = cidrs
+	for _, eni := range ds.eniPool {
+		for _, cidr := range eni.AvailableIPv4Cidrs {
+			cidr.Class = ds.routabilityClassUnsafe(cidr.Cidr)
+		}
+	}
+}
+
+// routabilityClassUnsafe returns the class of the addresses of cidr
+func (ds *DataStore) routabilityClassUnsafe(cidr net.IPNet) RoutabilityClass {
+	for _, nonRoutable := range ds.nonRoutableCIDRs {
+		if nonRoutable.Contains(cidr.IP) {
+			return ClassNonRoutable
+		}
+	}
+	return ClassRoutable
+}
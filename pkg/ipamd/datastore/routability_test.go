@@ -0,0 +1,77 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datastore
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPodIPv4AddressRoutabilityClass(t *testing.T) {
+	ds := NewDataStore(Testlog, NullCheckpoint{}, false)
+	_, cgnat, _ := net.ParseCIDR("100.64.0.0/16")
+	ds.SetNonRoutableCIDRs([]net.IPNet{*cgnat})
+
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	assert.NoError(t, ds.AddENI("eni-2", 1, false, false, false))
+	routable := net.IPNet{IP: net.ParseIP("10.0.0.1"), Mask: net.CIDRMask(32, 32)}
+	nonRoutable := net.IPNet{IP: net.ParseIP("100.64.0.1"), Mask: net.CIDRMask(32, 32)}
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", routable, false))
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-2", nonRoutable, false))
+	assert.Equal(t, ClassRoutable, ds.eniPool["eni-1"].AvailableIPv4Cidrs[routable.String()].Class)
+	assert.Equal(t, ClassNonRoutable, ds.eniPool["eni-2"].AvailableIPv4Cidrs[nonRoutable.String()].Class)
+
+	ip, device, err := ds.AssignPodIPv4AddressPinned(IPAMKey{"net0", "sandbox-1", "eth0"}, IPAMMetadata{},
+		&AddressPin{Class: ClassNonRoutable})
+	assert.NoError(t, err)
+	assert.Equal(t, "100.64.0.1", ip)
+	assert.Equal(t, 1, device)
+
+	// The only non-routable IP is taken, the routable one doesn't do
+	_, _, err = ds.AssignPodIPv4AddressPinned(IPAMKey{"net0", "sandbox-2", "eth0"}, IPAMMetadata{},
+		&AddressPin{Class: ClassNonRoutable})
+	assert.True(t, errors.Is(err, ErrPinnedAddressUnavailable))
+
+	ip, _, err = ds.AssignPodIPv4AddressPinned(IPAMKey{"net0", "sandbox-2", "eth0"}, IPAMMetadata{},
+		&AddressPin{Class: ClassRoutable})
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.1", ip)
+
+	_, _, err = ds.AssignPodIPv4AddressPinned(IPAMKey{"net0", "sandbox-3", "eth0"}, IPAMMetadata{},
+		&AddressPin{Class: "public"})
+	assert.Error(t, err)
+}
+
+func TestSetNonRoutableCIDRsReclassifies(t *testing.T) {
+	ds := NewDataStore(Testlog, NullCheckpoint{}, true)
+	assert.NoError(t, ds.AddENI("eni-1", 1, false, false, false))
+	_, prefix, _ := net.ParseCIDR("100.64.1.0/28")
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", *prefix, true))
+	assert.Equal(t, ClassRoutable, ds.eniPool["eni-1"].AvailableIPv4Cidrs[prefix.String()].Class)
+
+	_, cgnat, _ := net.ParseCIDR("100.64.0.0/10")
+	ds.SetNonRoutableCIDRs([]net.IPNet{*cgnat})
+	assert.Equal(t, ClassNonRoutable, ds.eniPool["eni-1"].AvailableIPv4Cidrs[prefix.String()].Class)
+}
+
+func TestParseRoutabilityClass(t *testing.T) {
+	class, err := ParseRoutabilityClass("non-routable")
+	assert.NoError(t, err)
+	assert.Equal(t, ClassNonRoutable, class)
+	_, err = ParseRoutabilityClass("Routable")
+	assert.Error(t, err)
+}
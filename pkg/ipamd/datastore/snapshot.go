@@ -0,0 +1,209 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datastore
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Snapshot is a copy of the ENIs and the allocations of the datastore at some time, meant to be saved in support
+// bundles and compared with DiffSnapshots, e.g. before and after an incident
+type Snapshot struct {
+	Time        time.Time
+	ENIs        []SnapshotENI
+	Allocations []SnapshotAllocation
+}
+
+// SnapshotENI is an ENI of a Snapshot and the CIDRs in its pool
+type SnapshotENI struct {
+	ID           string
+	DeviceNumber int
+	IsPrimary    bool `json:",omitempty"`
+	IsTrunk      bool `json:",omitempty"`
+	IsEFA        bool `json:",omitempty"`
+	MixedMode    bool `json:",omitempty"`
+	Cidrs        []string
+}
+
+// SnapshotAllocation is an assigned address of a Snapshot
+type SnapshotAllocation struct {
+	IP           string
+	ENI          string
+	IPAMKey      IPAMKey
+	IPAMMetadata IPAMMetadata
+	AssignedTime time.Time
+}
+
+// GetSnapshot returns a Snapshot of the datastore, with the ENIs sorted by ID and the allocations by IP
+func (ds *DataStore) GetSnapshot() Snapshot {
+	ds.readLock("GetSnapshot")
+	defer ds.lock.RUnlock()
+
+	snapshot := Snapshot{Time: time.Now()}
+	for _, eniID := range ds.sortedENIIDsUnsafe() {
+		eni := ds.eniPool[eniID]
+		snapshotENI := SnapshotENI{
+			ID:           eni.ID,
+			DeviceNumber: eni.DeviceNumber,
+			IsPrimary:    eni.IsPrimary,
+			IsTrunk:      eni.IsTrunk,
+			IsEFA:        eni.IsEFA,
+			MixedMode:    eni.MixedMode,
+		}
+		for _, cidrs := range []map[string]*CidrInfo{eni.AvailableIPv4Cidrs, eni.IPv6Cidrs} {
+			for cidrKey, cidr := range cidrs {
+				snapshotENI.Cidrs = append(snapshotENI.Cidrs, cidrKey)
+				for ip, addr := range cidr.IPAddresses {
+					if !addr.Assigned() {
+						continue
+					}
+					snapshot.Allocations = append(snapshot.Allocations, SnapshotAllocation{
+						IP:           ip,
+						ENI:          eniID,
+						IPAMKey:      addr.IPAMKey,
+						IPAMMetadata: addr.IPAMMetadata,
+						AssignedTime: addr.AssignedTime,
+					})
+				}
+			}
+		}
+		sort.Strings(snapshotENI.Cidrs)
+		snapshot.ENIs = append(snapshot.ENIs, snapshotENI)
+	}
+	sort.Slice(snapshot.Allocations, func(i, j int) bool {
+		return snapshot.Allocations[i].IP < snapshot.Allocations[j].IP
+	})
+	return snapshot
+}
+
+// SnapshotDiff is what changed between two snapshots
+type SnapshotDiff struct {
+	AddedENIs          []SnapshotENI
+	RemovedENIs        []SnapshotENI
+	ChangedENIs        []SnapshotENIChange
+	AddedAllocations   []SnapshotAllocation
+	RemovedAllocations []SnapshotAllocation
+	ChangedAllocations []SnapshotAllocationChange
+}
+
+// SnapshotENIChange is an ENI in both snapshots whose pool changed
+type SnapshotENIChange struct {
+	Before SnapshotENI
+	After  SnapshotENI
+}
+
+// SnapshotAllocationChange is an IP assigned in both snapshots, to another sandbox or at another time
+type SnapshotAllocationChange struct {
+	Before SnapshotAllocation
+	After  SnapshotAllocation
+}
+
+// DiffSnapshots returns the ENIs and the allocations added, removed or changed from before to after
+func DiffSnapshots(before, after Snapshot) SnapshotDiff {
+	var diff SnapshotDiff
+
+	beforeENIs := make(map[string]SnapshotENI, len(before.ENIs))
+	for _, eni := range before.ENIs {
+		beforeENIs[eni.ID] = eni
+	}
+	afterENIs := make(map[string]bool, len(after.ENIs))
+	for _, eni := range after.ENIs {
+		afterENIs[eni.ID] = true
+		prev, ok := beforeENIs[eni.ID]
+		switch {
+		case !ok:
+			diff.AddedENIs = append(diff.AddedENIs, eni)
+		case !reflect.DeepEqual(prev, eni):
+			diff.ChangedENIs = append(diff.ChangedENIs, SnapshotENIChange{Before: prev, After: eni})
+		}
+	}
+	for _, eni := range before.ENIs {
+		if !afterENIs[eni.ID] {
+			diff.RemovedENIs = append(diff.RemovedENIs, eni)
+		}
+	}
+
+	beforeAllocations := make(map[string]SnapshotAllocation, len(before.Allocations))
+	for _, allocation := range before.Allocations {
+		beforeAllocations[allocation.IP] = allocation
+	}
+	afterAllocations := make(map[string]bool, len(after.Allocations))
+	for _, allocation := range after.Allocations {
+		afterAllocations[allocation.IP] = true
+		prev, ok := beforeAllocations[allocation.IP]
+		switch {
+		case !ok:
+			diff.AddedAllocations = append(diff.AddedAllocations, allocation)
+		case prev.ENI != allocation.ENI || prev.IPAMKey != allocation.IPAMKey ||
+			prev.IPAMMetadata != allocation.IPAMMetadata || !prev.AssignedTime.Equal(allocation.AssignedTime):
+			diff.ChangedAllocations = append(diff.ChangedAllocations,
+				SnapshotAllocationChange{Before: prev, After: allocation})
+		}
+	}
+	for _, allocation := range before.Allocations {
+		if !afterAllocations[allocation.IP] {
+			diff.RemovedAllocations = append(diff.RemovedAllocations, allocation)
+		}
+	}
+	return diff
+}
+
+// Empty returns true if the snapshots are the same
+func (d SnapshotDiff) Empty() bool {
+	return len(d.AddedENIs)+len(d.RemovedENIs)+len(d.ChangedENIs)+len(d.AddedAllocations)+
+		len(d.RemovedAllocations)+len(d.ChangedAllocations) == 0
+}
+
+func (e SnapshotENI) String() string {
+	return fmt.Sprintf("ENI %s device %d cidrs [%s]", e.ID, e.DeviceNumber, strings.Join(e.Cidrs, " "))
+}
+
+func (a SnapshotAllocation) String() string {
+	return fmt.Sprintf("IP %s on %s to %s/%s (%s) assigned at %s", a.IP, a.ENI, a.IPAMMetadata.K8SPodNamespace,
+		a.IPAMMetadata.K8SPodName, a.IPAMKey, a.AssignedTime.Format(time.RFC3339))
+}
+
+// Print writes the diff one change per line: + for an addition, - for a removal, and - then + for a change
+func (d SnapshotDiff) Print(w io.Writer) error {
+	var lines []string
+	for _, eni := range d.AddedENIs {
+		lines = append(lines, "+ "+eni.String())
+	}
+	for _, eni := range d.RemovedENIs {
+		lines = append(lines, "- "+eni.String())
+	}
+	for _, change := range d.ChangedENIs {
+		lines = append(lines, "- "+change.Before.String(), "+ "+change.After.String())
+	}
+	for _, allocation := range d.AddedAllocations {
+		lines = append(lines, "+ "+allocation.String())
+	}
+	for _, allocation := range d.RemovedAllocations {
+		lines = append(lines, "- "+allocation.String())
+	}
+	for _, change := range d.ChangedAllocations {
+		lines = append(lines, "- "+change.Before.String(), "+ "+change.After.String())
+	}
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
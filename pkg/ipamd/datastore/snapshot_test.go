@@ -0,0 +1,72 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datastore
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotDiff(t *testing.T) {
+	ds := NewDataStore(Testlog, NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)}, false))
+	}
+	key1 := IPAMKey{NetworkName: "aws-cni", ContainerID: "cid-1", IfName: "eth0"}
+	_, _, err := ds.AssignPodIPv4Address(key1, IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "pod-1"})
+	assert.NoError(t, err)
+
+	before := ds.GetSnapshot()
+	assert.Len(t, before.ENIs, 1)
+	assert.Equal(t, []string{"10.0.0.1/32", "10.0.0.2/32"}, before.ENIs[0].Cidrs)
+	assert.Len(t, before.Allocations, 1)
+	assert.True(t, DiffSnapshots(before, ds.GetSnapshot()).Empty())
+
+	// A snapshot survives the round trip through a support bundle
+	data, err := json.Marshal(before)
+	assert.NoError(t, err)
+	var loaded Snapshot
+	assert.NoError(t, json.Unmarshal(data, &loaded))
+	assert.True(t, DiffSnapshots(loaded, before).Empty())
+
+	assert.NoError(t, ds.AddENI("eni-2", 1, false, false, false))
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-2", net.IPNet{IP: net.ParseIP("10.0.1.1"), Mask: net.CIDRMask(32, 32)}, false))
+	_, _, _, err = ds.UnassignPodIPAddress(key1)
+	assert.NoError(t, err)
+	key2 := IPAMKey{NetworkName: "aws-cni", ContainerID: "cid-2", IfName: "eth0"}
+	_, _, err = ds.AssignPodIPv4Address(key2, IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "pod-2"})
+	assert.NoError(t, err)
+
+	diff := DiffSnapshots(before, ds.GetSnapshot())
+	assert.False(t, diff.Empty())
+	assert.Len(t, diff.AddedENIs, 1)
+	assert.Equal(t, "eni-2", diff.AddedENIs[0].ID)
+	assert.Empty(t, diff.RemovedENIs)
+	assert.Empty(t, diff.ChangedENIs)
+	// pod-2 gets either the address released by pod-1, which is then changed, or another one
+	assert.Equal(t, 2, len(diff.AddedAllocations)+len(diff.RemovedAllocations)+2*len(diff.ChangedAllocations))
+
+	var out bytes.Buffer
+	assert.NoError(t, diff.Print(&out))
+	assert.Contains(t, out.String(), "+ ENI eni-2 device 1 cidrs [10.0.1.1/32]")
+	assert.Contains(t, out.String(), "default/pod-2")
+
+	reverse := DiffSnapshots(ds.GetSnapshot(), before)
+	assert.Len(t, reverse.RemovedENIs, 1)
+}
@@ -0,0 +1,129 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datastore
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrTrunkFull is an error when the trunk ENI has no free slot left for another branch ENI
+var ErrTrunkFull = errors.New("datastore: no free branch ENI slot on the trunk ENI")
+
+var (
+	trunkBranchENIsUsed = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "awscni_trunk_branch_enis_used",
+			Help: "The number of branch ENIs of pods on the trunk ENI",
+		},
+	)
+	trunkBranchENIsFree = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "awscni_trunk_branch_enis_free",
+			Help: "The number of free branch ENI slots on the trunk ENI",
+		},
+	)
+)
+
+// TrunkStats contains the branch ENI slot usage of the trunk ENI
+type TrunkStats struct {
+	// Capacity is the number of branch ENIs the trunk ENI can hold, 0 if it is not known yet
+	Capacity int
+	// Used is the number of branch ENIs of the pods on the node
+	Used int
+}
+
+// Free returns the number of free branch ENI slots, or -1 if the capacity is not known
+func (stats TrunkStats) Free() int {
+	if stats.Capacity == 0 {
+		return -1
+	}
+	if stats.Used >= stats.Capacity {
+		return 0
+	}
+	return stats.Capacity - stats.Used
+}
+
+// SetTrunkCapacity sets the number of branch ENIs the trunk ENI can hold
+func (ds *DataStore) SetTrunkCapacity(capacity int) {
+	ds.writeLock("SetTrunkCapacity")
+	defer ds.lock.Unlock()
+	if ds.trunkCapacity != capacity {
+		ds.log.Infof("Trunk ENI branch ENI capacity changed from %d to %d", ds.trunkCapacity, capacity)
+	}
+	ds.trunkCapacity = capacity
+	ds.updateTrunkMetricsUnsafe()
+}
+
+// SetPodBranchENIs records the number of branch ENIs of a pod. A count of 0 forgets the pod.
+func (ds *DataStore) SetPodBranchENIs(pod IPAMMetadata, count int) {
+	ds.writeLock("SetPodBranchENIs")
+	defer ds.lock.Unlock()
+	if count > 0 {
+		ds.branchENIs[pod] = count
+	} else {
+		delete(ds.branchENIs, pod)
+	}
+	ds.updateTrunkMetricsUnsafe()
+}
+
+// SyncBranchENIs replaces the branch ENIs of all pods, so that allocations missed while ipamd was down are counted
+func (ds *DataStore) SyncBranchENIs(branchENIs map[IPAMMetadata]int) {
+	ds.writeLock("SyncBranchENIs")
+	defer ds.lock.Unlock()
+	if !reflect.DeepEqual(branchENIs, ds.branchENIs) {
+		ds.log.Debugf("Branch ENIs of pods resynced from %v to %v", ds.branchENIs, branchENIs)
+	}
+	ds.branchENIs = make(map[IPAMMetadata]int, len(branchENIs))
+	for pod, count := range branchENIs {
+		if count > 0 {
+			ds.branchENIs[pod] = count
+		}
+	}
+	ds.updateTrunkMetricsUnsafe()
+}
+
+// GetTrunkStats returns the branch ENI slot usage of the trunk ENI
+func (ds *DataStore) GetTrunkStats() TrunkStats {
+	ds.readLock("GetTrunkStats")
+	defer ds.lock.RUnlock()
+	return ds.trunkStatsUnsafe()
+}
+
+// CheckTrunkCapacity returns ErrTrunkFull if the trunk ENI is known to have no free branch ENI slot
+func (ds *DataStore) CheckTrunkCapacity() (TrunkStats, error) {
+	stats := ds.GetTrunkStats()
+	if stats.Free() == 0 {
+		return stats, ErrTrunkFull
+	}
+	return stats, nil
+}
+
+func (ds *DataStore) trunkStatsUnsafe() TrunkStats {
+	stats := TrunkStats{Capacity: ds.trunkCapacity}
+	for _, count := range ds.branchENIs {
+		stats.Used += count
+	}
+	return stats
+}
+
+func (ds *DataStore) updateTrunkMetricsUnsafe() {
+	stats := ds.trunkStatsUnsafe()
+	trunkBranchENIsUsed.Set(float64(stats.Used))
+	if free := stats.Free(); free >= 0 {
+		trunkBranchENIsFree.Set(float64(free))
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datastore
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrunkStats(t *testing.T) {
+	ds := NewDataStore(Testlog, NullCheckpoint{}, false)
+	pod1 := IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "pod-1"}
+	pod2 := IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "pod-2"}
+
+	// Without a known capacity the trunk is never reported full
+	ds.SetPodBranchENIs(pod1, 2)
+	stats, err := ds.CheckTrunkCapacity()
+	assert.NoError(t, err)
+	assert.Equal(t, TrunkStats{Capacity: 0, Used: 2}, stats)
+	assert.Equal(t, -1, stats.Free())
+
+	ds.SetTrunkCapacity(3)
+	assert.Equal(t, 1, ds.GetTrunkStats().Free())
+	assert.Equal(t, float64(2), testutil.ToFloat64(trunkBranchENIsUsed))
+	assert.Equal(t, float64(1), testutil.ToFloat64(trunkBranchENIsFree))
+
+	ds.SetPodBranchENIs(pod2, 1)
+	stats, err = ds.CheckTrunkCapacity()
+	assert.ErrorIs(t, err, ErrTrunkFull)
+	assert.Equal(t, TrunkStats{Capacity: 3, Used: 3}, stats)
+	assert.Equal(t, float64(0), testutil.ToFloat64(trunkBranchENIsFree))
+
+	ds.SetPodBranchENIs(pod1, 0)
+	_, err = ds.CheckTrunkCapacity()
+	assert.NoError(t, err)
+	assert.Equal(t, float64(1), testutil.ToFloat64(trunkBranchENIsUsed))
+
+	// A resync replaces the recorded pods
+	ds.SyncBranchENIs(map[IPAMMetadata]int{pod1: 1, pod2: 0})
+	assert.Equal(t, TrunkStats{Capacity: 3, Used: 1}, ds.GetTrunkStats())
+	assert.Equal(t, float64(2), testutil.ToFloat64(trunkBranchENIsFree))
+}
@@ -0,0 +1,48 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"time"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+)
+
+// datastoreInvariantsCheckInterval is how often the datastore invariants are checked when ENABLE_DATASTORE_DEBUG is set
+const datastoreInvariantsCheckInterval = time.Minute
+
+// StartDatastoreInvariantsChecker periodically checks the datastore invariants and logs the violations. It returns
+// right away unless ENABLE_DATASTORE_DEBUG is set.
+func (c *IPAMContext) StartDatastoreInvariantsChecker() {
+	if !c.enableDatastoreDebug {
+		return
+	}
+	log.Infof("Starting the datastore invariants checker")
+	for {
+		time.Sleep(datastoreInvariantsCheckInterval)
+		c.checkDatastoreInvariants()
+	}
+}
+
+// checkDatastoreInvariants checks the datastore invariants and logs each violation with its context
+func (c *IPAMContext) checkDatastoreInvariants() []datastore.InvariantViolation {
+	violations := c.dataStore.CheckInvariants()
+	for _, violation := range violations {
+		log.Errorf("Datastore invariant violated: %s", violation.String())
+	}
+	if len(violations) > 0 {
+		ipamdErrInc("checkDatastoreInvariants")
+	}
+	return violations
+}
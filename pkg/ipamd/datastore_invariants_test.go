@@ -0,0 +1,54 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+)
+
+func TestDatastoreInvariantsRequestHandler(t *testing.T) {
+	checkpoint := datastore.NewTestCheckpoint(datastore.CheckpointData{Version: datastore.CheckpointFormatVersion})
+	ds := datastore.NewDataStore(log, checkpoint, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP("10.0.0.1"), Mask: net.CIDRMask(32, 32)}, false))
+	ip, _, err := ds.AssignPodIPv4Address(datastore.IPAMKey{NetworkName: "aws-cni", ContainerID: "cid-1", IfName: "eth0"},
+		datastore.IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "pod-1"})
+	assert.NoError(t, err)
+	mockContext := &IPAMContext{dataStore: ds}
+
+	getViolations := func() []datastore.InvariantViolation {
+		w := httptest.NewRecorder()
+		datastoreInvariantsRequestHandler(mockContext)(w, httptest.NewRequest(http.MethodGet, "/v1/datastore-invariants", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		var violations []datastore.InvariantViolation
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &violations))
+		return violations
+	}
+	assert.Empty(t, getViolations())
+
+	// The allocation is lost from the checkpoint
+	checkpoint.Data = &datastore.CheckpointData{Version: datastore.CheckpointFormatVersion}
+	violations := getViolations()
+	assert.Len(t, violations, 1)
+	assert.Equal(t, datastore.InvariantCheckpointMatch, violations[0].Invariant)
+	assert.Equal(t, ip, violations[0].IP)
+}
@@ -0,0 +1,260 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+)
+
+const (
+	// dedicatedENIAnnotation asks, with ENABLE_DEDICATED_ENI, for an ENI of the pod's own that is moved into the pod
+	// network namespace, instead of an IP of a shared ENI behind a veth
+	dedicatedENIAnnotation = "vpc.amazonaws.com/dedicated-eni"
+	// dedicatedENIPodTagKey is the ENI tag recording the <namespace>/<name> of the pod owning a dedicated ENI, so that it
+	// is found again after a restart of ipamd
+	dedicatedENIPodTagKey = "vpc.amazonaws.com/dedicated-eni-pod"
+	// dedicatedENIAttachRetries is the number of times the instance metadata is checked for a new dedicated ENI
+	dedicatedENIAttachRetries = 30
+)
+
+// dedicatedENIAttachInterval is the time between two checks of the instance metadata for a new dedicated ENI
+var dedicatedENIAttachInterval = time.Second
+
+// dedicatedENI is an ENI given to a single pod
+type dedicatedENI struct {
+	ENIID              string
+	MAC                string
+	IPv4Addr           string
+	SubnetGW           string
+	SubnetPrefixLength int
+}
+
+// newDedicatedENI fills a dedicatedENI from the instance metadata of the ENI
+func newDedicatedENI(eni awsutils.ENIMetadata) (*dedicatedENI, error) {
+	_, subnet, err := net.ParseCIDR(eni.SubnetIPv4CIDR)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid subnet CIDR %q of ENI %s", eni.SubnetIPv4CIDR, eni.ENIID)
+	}
+	subnetGW, err := branchENISubnetGW(subnet.String())
+	if err != nil {
+		return nil, err
+	}
+	ipv4Addr := eni.PrimaryIPv4Address()
+	if ipv4Addr == "" {
+		return nil, errors.Errorf("ENI %s has no primary IPv4 address", eni.ENIID)
+	}
+	prefixLength, _ := subnet.Mask.Size()
+	return &dedicatedENI{
+		ENIID:              eni.ENIID,
+		MAC:                eni.MAC,
+		IPv4Addr:           ipv4Addr,
+		SubnetGW:           subnetGW,
+		SubnetPrefixLength: prefixLength,
+	}, nil
+}
+
+// loadDedicatedENIs finds the dedicated ENIs of pods among the attached ENIs, using their pod tag
+func (c *IPAMContext) loadDedicatedENIs(enis []awsutils.ENIMetadata, tagMap map[string]awsutils.TagMap) {
+	c.dedicatedENILock.Lock()
+	defer c.dedicatedENILock.Unlock()
+	c.dedicatedENIs = make(map[datastore.IPAMMetadata]*dedicatedENI)
+	for _, eni := range enis {
+		owner, found := tagMap[eni.ENIID][dedicatedENIPodTagKey]
+		if !found {
+			continue
+		}
+		parts := strings.SplitN(owner, "/", 2)
+		if len(parts) != 2 {
+			log.Warnf("Ignoring dedicated ENI %s with invalid pod tag %q", eni.ENIID, owner)
+			continue
+		}
+		dedicated, err := newDedicatedENI(eni)
+		if err != nil {
+			log.Warnf("Ignoring dedicated ENI %s of pod %s: %v", eni.ENIID, owner, err)
+			continue
+		}
+		log.Infof("Found dedicated ENI %s of pod %s", eni.ENIID, owner)
+		c.dedicatedENIs[datastore.IPAMMetadata{K8SPodNamespace: parts[0], K8SPodName: parts[1]}] = dedicated
+	}
+}
+
+// isDedicatedENI returns true if the ENI is dedicated to a pod, and so must be left out of the IP pool
+func (c *IPAMContext) isDedicatedENI(eniID string) bool {
+	c.dedicatedENILock.Lock()
+	defer c.dedicatedENILock.Unlock()
+	for _, eni := range c.dedicatedENIs {
+		if eni.ENIID == eniID {
+			return true
+		}
+	}
+	return false
+}
+
+// podDedicatedENI returns the dedicated ENI of a pod annotated with vpc.amazonaws.com/dedicated-eni=true, allocating
+// one if it has none yet. It returns nil for other pods.
+func (c *IPAMContext) podDedicatedENI(ctx context.Context, podName, namespace string) (*dedicatedENI, error) {
+	var pod corev1.Pod
+	err := c.rawK8SClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: podName}, &pod)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get pod")
+	}
+	if pod.Annotations[dedicatedENIAnnotation] != "true" {
+		return nil, nil
+	}
+
+	key := datastore.IPAMMetadata{K8SPodNamespace: namespace, K8SPodName: podName}
+	c.dedicatedENILock.Lock()
+	eni, found := c.dedicatedENIs[key]
+	c.dedicatedENILock.Unlock()
+	if found {
+		// A new sandbox of the pod keeps its ENI, and so its MAC address
+		return eni, nil
+	}
+	return c.allocDedicatedENI(key)
+}
+
+// allocDedicatedENI creates and attaches a new ENI for a pod. The ENI is tagged as unmanaged, so that it stays out of
+// the IP pool after a restart of ipamd too.
+func (c *IPAMContext) allocDedicatedENI(pod datastore.IPAMMetadata) (*dedicatedENI, error) {
+	// The EC2 calls outlive the CNI request, so that a timed out ADD doesn't leave a half set up ENI behind
+	ctx := awsutils.WithCaller(context.Background(), awsutils.CallerBranchENI)
+	eniID, err := c.allocENI(awsutils.WithPod(ctx, pod.K8SPodNamespace, pod.K8SPodName))
+	if err != nil {
+		ipamdErrInc("allocDedicatedENI")
+		return nil, errors.Wrap(err, "failed to allocate a dedicated ENI")
+	}
+	// Keep the pool reconciliation away from the ENI while it attaches
+	c.dedicatedENILock.Lock()
+	if c.dedicatedENIs == nil {
+		c.dedicatedENIs = make(map[datastore.IPAMMetadata]*dedicatedENI)
+	}
+	c.dedicatedENIs[pod] = &dedicatedENI{ENIID: eniID}
+	c.dedicatedENILock.Unlock()
+
+	eni, err := c.waitForDedicatedENI(ctx, eniID, pod)
+	c.dedicatedENILock.Lock()
+	if err != nil {
+		delete(c.dedicatedENIs, pod)
+	} else {
+		c.dedicatedENIs[pod] = eni
+	}
+	c.dedicatedENILock.Unlock()
+	if err != nil {
+		ipamdErrInc("allocDedicatedENI")
+		if freeErr := c.awsClient.FreeENI(ctx, eniID); freeErr != nil {
+			log.Errorf("Failed to free dedicated ENI %s after a failed setup: %v", eniID, freeErr)
+		}
+		return nil, errors.Wrapf(err, "failed to set up dedicated ENI %s", eniID)
+	}
+	log.Infof("Allocated dedicated ENI %s with IP %s to pod %s/%s", eni.ENIID, eni.IPv4Addr, pod.K8SPodNamespace, pod.K8SPodName)
+	return eni, nil
+}
+
+// waitForDedicatedENI tags a new dedicated ENI and waits for it to show up in the instance metadata
+func (c *IPAMContext) waitForDedicatedENI(ctx context.Context, eniID string, pod datastore.IPAMMetadata) (*dedicatedENI, error) {
+	err := c.awsClient.AddENITags(ctx, eniID, map[string]string{
+		eniNoManageTagKey:     "true",
+		dedicatedENIPodTagKey: pod.K8SPodNamespace + "/" + pod.K8SPodName,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < dedicatedENIAttachRetries; i++ {
+		enis, err := c.awsClient.GetAttachedENIs()
+		if err != nil {
+			log.Debugf("Failed to get the attached ENIs while waiting for dedicated ENI %s: %v", eniID, err)
+		}
+		for _, eni := range enis {
+			if eni.ENIID == eniID && eni.PrimaryIPv4Address() != "" {
+				return newDedicatedENI(eni)
+			}
+		}
+		time.Sleep(dedicatedENIAttachInterval)
+	}
+	return nil, errors.Errorf("ENI %s did not show up in the instance metadata", eniID)
+}
+
+// releaseDedicatedENI returns the dedicated ENI of a pod whose sandbox is deleted, or nil if it has none. The ENI is
+// freed once the pod is gone, while a pod that only gets a new sandbox keeps it.
+func (c *IPAMContext) releaseDedicatedENI(ctx context.Context, podName, namespace string) *dedicatedENI {
+	key := datastore.IPAMMetadata{K8SPodNamespace: namespace, K8SPodName: podName}
+	c.dedicatedENILock.Lock()
+	eni, found := c.dedicatedENIs[key]
+	c.dedicatedENILock.Unlock()
+	if !found {
+		return nil
+	}
+	if c.podGone(ctx, key, true) {
+		c.dedicatedENILock.Lock()
+		delete(c.dedicatedENIs, key)
+		c.dedicatedENILock.Unlock()
+		go c.freeDedicatedENI(awsutils.WithCaller(context.Background(), awsutils.CallerBranchENI), eni, key)
+	}
+	return eni
+}
+
+// reclaimDedicatedENIs frees the dedicated ENIs of pods deleted while their release was missed, e.g. while ipamd was
+// down
+func (c *IPAMContext) reclaimDedicatedENIs(ctx context.Context) {
+	c.dedicatedENILock.Lock()
+	dedicatedENIs := make(map[datastore.IPAMMetadata]*dedicatedENI, len(c.dedicatedENIs))
+	for key, eni := range c.dedicatedENIs {
+		dedicatedENIs[key] = eni
+	}
+	c.dedicatedENILock.Unlock()
+
+	for key, eni := range dedicatedENIs {
+		// An ENI still attaching has no MAC yet and is left alone
+		if eni.MAC == "" || !c.podGone(ctx, key, false) {
+			continue
+		}
+		c.dedicatedENILock.Lock()
+		delete(c.dedicatedENIs, key)
+		c.dedicatedENILock.Unlock()
+		c.freeDedicatedENI(ctx, eni, key)
+	}
+}
+
+// podGone returns true if the pod doesn't exist anymore, or optionally if it is being deleted
+func (c *IPAMContext) podGone(ctx context.Context, pod datastore.IPAMMetadata, orTerminating bool) bool {
+	var p corev1.Pod
+	err := c.rawK8SClient.Get(ctx, types.NamespacedName{Namespace: pod.K8SPodNamespace, Name: pod.K8SPodName}, &p)
+	if err != nil {
+		if !k8serror.IsNotFound(err) {
+			log.Debugf("Failed to get pod %s/%s: %v", pod.K8SPodNamespace, pod.K8SPodName, err)
+		}
+		return k8serror.IsNotFound(err)
+	}
+	return orTerminating && p.DeletionTimestamp != nil
+}
+
+// freeDedicatedENI detaches and deletes the dedicated ENI of a pod
+func (c *IPAMContext) freeDedicatedENI(ctx context.Context, eni *dedicatedENI, pod datastore.IPAMMetadata) {
+	log.Infof("Freeing dedicated ENI %s of pod %s/%s", eni.ENIID, pod.K8SPodNamespace, pod.K8SPodName)
+	if err := c.awsClient.FreeENI(ctx, eni.ENIID); err != nil {
+		log.Errorf("Failed to free dedicated ENI %s: %v", eni.ENIID, err)
+		ipamdErrInc("freeDedicatedENI")
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+)
+
+func TestDedicatedENI(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+	ctx := context.Background()
+	defer func(interval time.Duration) { dedicatedENIAttachInterval = interval }(dedicatedENIAttachInterval)
+	dedicatedENIAttachInterval = time.Millisecond
+
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "default",
+		Annotations: map[string]string{dedicatedENIAnnotation: "true"}}}
+	assert.NoError(t, m.rawK8SClient.Create(ctx, pod))
+	assert.NoError(t, m.rawK8SClient.Create(ctx, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-2", Namespace: "default"}}))
+
+	mockContext := &IPAMContext{
+		awsClient:    m.awsutils,
+		rawK8SClient: m.rawK8SClient,
+		enableIPv4:   true,
+	}
+
+	// A pod without the annotation gets no ENI
+	eni, err := mockContext.podDedicatedENI(ctx, "pod-2", "default")
+	assert.NoError(t, err)
+	assert.Nil(t, eni)
+
+	attached := awsutils.ENIMetadata{
+		ENIID:          secENIid,
+		MAC:            secMAC,
+		DeviceNumber:   1,
+		SubnetIPv4CIDR: "10.0.64.0/18",
+		IPv4Addresses: []*ec2.NetworkInterfacePrivateIpAddress{
+			{Primary: aws.Bool(true), PrivateIpAddress: aws.String("10.0.64.10")},
+		},
+	}
+	m.awsutils.EXPECT().AllocENI(gomock.Any(), false, nil, "").Return(secENIid, nil)
+	m.awsutils.EXPECT().AddENITags(gomock.Any(), secENIid, map[string]string{
+		eniNoManageTagKey:     "true",
+		dedicatedENIPodTagKey: "default/pod-1",
+	}).Return(nil)
+	notAttached := m.awsutils.EXPECT().GetAttachedENIs()
+	notAttached.Return([]awsutils.ENIMetadata{{ENIID: primaryENIid, MAC: primaryMAC}}, nil)
+	m.awsutils.EXPECT().GetAttachedENIs().Return([]awsutils.ENIMetadata{{ENIID: primaryENIid, MAC: primaryMAC}, attached}, nil).After(notAttached)
+	eni, err = mockContext.podDedicatedENI(ctx, "pod-1", "default")
+	assert.NoError(t, err)
+	expected := &dedicatedENI{ENIID: secENIid, MAC: secMAC, IPv4Addr: "10.0.64.10", SubnetGW: "10.0.64.1", SubnetPrefixLength: 18}
+	assert.Equal(t, expected, eni)
+	assert.True(t, mockContext.isDedicatedENI(secENIid))
+	assert.False(t, mockContext.isDedicatedENI(primaryENIid))
+
+	// A new sandbox of the pod keeps its ENI
+	eni, err = mockContext.podDedicatedENI(ctx, "pod-1", "default")
+	assert.NoError(t, err)
+	assert.Equal(t, expected, eni)
+	assert.Equal(t, expected, mockContext.releaseDedicatedENI(ctx, "pod-1", "default"))
+	assert.True(t, mockContext.isDedicatedENI(secENIid))
+	assert.Nil(t, mockContext.releaseDedicatedENI(ctx, "pod-2", "default"))
+
+	// The ENI is found again from its tags after a restart
+	mockContext.loadDedicatedENIs([]awsutils.ENIMetadata{{ENIID: primaryENIid, MAC: primaryMAC}, attached},
+		map[string]awsutils.TagMap{secENIid: {eniNoManageTagKey: "true", dedicatedENIPodTagKey: "default/pod-1"}})
+	assert.Equal(t, map[datastore.IPAMMetadata]*dedicatedENI{{K8SPodNamespace: "default", K8SPodName: "pod-1"}: expected},
+		mockContext.dedicatedENIs)
+
+	// The ENI of a deleted pod is freed
+	mockContext.reclaimDedicatedENIs(ctx)
+	assert.NoError(t, m.rawK8SClient.Delete(ctx, pod))
+	m.awsutils.EXPECT().FreeENI(gomock.Any(), secENIid).Return(nil)
+	mockContext.reclaimDedicatedENIs(ctx)
+	assert.False(t, mockContext.isDedicatedENI(secENIid))
+}
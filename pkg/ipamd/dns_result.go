@@ -0,0 +1,89 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils"
+	"github.com/aws/amazon-vpc-cni-k8s/rpc"
+)
+
+// dnsConfigRefreshInterval is how often the DHCP options set of the VPC is looked up again
+const dnsConfigRefreshInterval = 10 * time.Minute
+
+// cniDNSNameservers returns the name servers set through CNI_DNS_NAMESERVERS, skipping the invalid ones
+func cniDNSNameservers() []string {
+	var nameservers []string
+	for _, value := range strings.Split(os.Getenv(envCNIDNSNameservers), ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if net.ParseIP(value) == nil {
+			log.Errorf("Ignoring invalid name server %q in %s", value, envCNIDNSNameservers)
+			continue
+		}
+		nameservers = append(nameservers, value)
+	}
+	return nameservers
+}
+
+// initCNIDNSResult sets up the DNS configuration returned for the CNI result: the name servers of CNI_DNS_NAMESERVERS
+// if set, or else the ones of the DHCP options set of the VPC, which are refreshed in the background.
+func (c *IPAMContext) initCNIDNSResult() {
+	if !c.enableCNIDNSResult {
+		return
+	}
+	if nameservers := cniDNSNameservers(); len(nameservers) > 0 {
+		log.Infof("Returning the name servers %v in the CNI result", nameservers)
+		c.setCNIDNSConfig(awsutils.VPCDNSConfig{Nameservers: nameservers})
+		return
+	}
+	go wait.Forever(c.refreshVPCDNSConfig, dnsConfigRefreshInterval)
+}
+
+func (c *IPAMContext) refreshVPCDNSConfig() {
+	dnsConfig, err := c.awsClient.GetVPCDNSConfig()
+	if err != nil {
+		log.Warnf("Failed to get the DNS configuration of the VPC: %v", err)
+		ipamdErrInc("refreshVPCDNSConfig")
+		return
+	}
+	log.Debugf("DNS configuration of the VPC: %+v", dnsConfig)
+	c.setCNIDNSConfig(dnsConfig)
+}
+
+func (c *IPAMContext) setCNIDNSConfig(dnsConfig awsutils.VPCDNSConfig) {
+	c.dnsConfigLock.Lock()
+	defer c.dnsConfigLock.Unlock()
+	c.dnsConfig = dnsConfig
+}
+
+// setDNSResult adds the DNS configuration to an AddNetworkReply when ENABLE_CNI_DNS_RESULT is set
+func (c *IPAMContext) setDNSResult(resp *rpc.AddNetworkReply) {
+	if !c.enableCNIDNSResult {
+		return
+	}
+	c.dnsConfigLock.RLock()
+	defer c.dnsConfigLock.RUnlock()
+	resp.DNSNameservers = c.dnsConfig.Nameservers
+	resp.DNSDomain = c.dnsConfig.Domain
+	resp.DNSSearch = c.dnsConfig.Search
+}
@@ -0,0 +1,62 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils"
+	"github.com/aws/amazon-vpc-cni-k8s/rpc"
+)
+
+func TestCNIDNSNameservers(t *testing.T) {
+	os.Unsetenv(envCNIDNSNameservers)
+	assert.Empty(t, cniDNSNameservers())
+
+	os.Setenv(envCNIDNSNameservers, "10.0.0.2, not-an-ip,,fd00:ec2::253")
+	defer os.Unsetenv(envCNIDNSNameservers)
+	assert.Equal(t, []string{"10.0.0.2", "fd00:ec2::253"}, cniDNSNameservers())
+}
+
+func TestDNSResult(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	mockContext := &IPAMContext{awsClient: m.awsutils}
+	vpcDNS := awsutils.VPCDNSConfig{Nameservers: []string{"10.0.0.2"}, Domain: "ec2.internal", Search: []string{"ec2.internal"}}
+	m.awsutils.EXPECT().GetVPCDNSConfig().Return(vpcDNS, nil)
+	mockContext.refreshVPCDNSConfig()
+
+	// Nothing is returned unless ENABLE_CNI_DNS_RESULT is set
+	var resp rpc.AddNetworkReply
+	mockContext.setDNSResult(&resp)
+	assert.Empty(t, resp.DNSNameservers)
+
+	mockContext.enableCNIDNSResult = true
+	mockContext.setDNSResult(&resp)
+	assert.Equal(t, []string{"10.0.0.2"}, resp.DNSNameservers)
+	assert.Equal(t, "ec2.internal", resp.DNSDomain)
+	assert.Equal(t, []string{"ec2.internal"}, resp.DNSSearch)
+
+	// A failed refresh keeps the last DNS configuration
+	m.awsutils.EXPECT().GetVPCDNSConfig().Return(awsutils.VPCDNSConfig{}, errors.New("no EC2"))
+	mockContext.refreshVPCDNSConfig()
+	resp = rpc.AddNetworkReply{}
+	mockContext.setDNSResult(&resp)
+	assert.Equal(t, []string{"10.0.0.2"}, resp.DNSNameservers)
+}
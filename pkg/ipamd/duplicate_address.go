@@ -0,0 +1,57 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+)
+
+// detectDuplicateAddress probes the address that assign gave to the pod of ipamKey, returned by assigned. While another
+// host answers for it, it quarantines the address and assigns another one, until duplicateAddressTimeout is spent. An
+// address that can't be probed is kept.
+func (c *IPAMContext) detectDuplicateAddress(ipamKey datastore.IPAMKey, assigned func() string, assign func() error) error {
+	deadline := time.Now().Add(c.duplicateAddressTimeout)
+	for {
+		ip := net.ParseIP(assigned())
+		remaining := time.Until(deadline)
+		if ip == nil || remaining <= 0 {
+			return nil
+		}
+		mac, err := c.networkClient.ProbeAddress(ip, remaining)
+		if err != nil {
+			log.Warnf("Unable to probe %s for duplicates, assigning it anyway: %v", ip, err)
+			return nil
+		}
+		if mac == nil {
+			return nil
+		}
+		duplicateAddresses.Inc()
+		log.Errorf("Another host, %s, answered for %s, assigning another IP to sandbox %s", mac, ip, ipamKey)
+		if _, err := c.dataStore.QuarantineIP(ip.String(), 0, fmt.Sprintf("duplicate address, answered by %s", mac)); err != nil {
+			return errors.Wrapf(err, "failed to quarantine the duplicate address %s", ip)
+		}
+		if _, _, _, err := c.dataStore.UnassignPodIPAddress(ipamKey); err != nil {
+			return errors.Wrapf(err, "failed to unassign the duplicate address %s", ip)
+		}
+		if err := assign(); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+	pb "github.com/aws/amazon-vpc-cni-k8s/rpc"
+)
+
+func TestAddNetworkDuplicateAddressDetection(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)}, false))
+	}
+	mockContext := &IPAMContext{
+		awsClient:               m.awsutils,
+		networkClient:           m.network,
+		dataStore:               ds,
+		enableIPv4:              true,
+		duplicateAddressTimeout: time.Second,
+	}
+	s := &server{version: "1.2.3", ipamContext: mockContext}
+
+	// Another host answers for the first IP the pod gets, so it gets the other one
+	remote, _ := net.ParseMAC("02:00:00:00:00:99")
+	var duplicate net.IP
+	gomock.InOrder(
+		m.network.EXPECT().ProbeAddress(gomock.Any(), gomock.Any()).DoAndReturn(func(ip net.IP, timeout time.Duration) (net.HardwareAddr, error) {
+			duplicate = ip
+			return remote, nil
+		}),
+		m.network.EXPECT().ProbeAddress(gomock.Any(), gomock.Any()).Return(nil, nil),
+	)
+	m.awsutils.EXPECT().GetVPCIPv4CIDRs().Return([]string{"10.0.0.0/16"}, nil)
+	m.network.EXPECT().UseExternalSNAT().Return(true)
+	resp, err := s.AddNetwork(context.Background(), &pb.AddNetworkRequest{
+		ClientVersion:     "1.2.3",
+		K8S_POD_NAME:      "pod-1",
+		K8S_POD_NAMESPACE: "default",
+		ContainerID:       "cid-pod-1",
+		IfName:            "eth0",
+		NetworkName:       "aws-cni",
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.Success)
+	assert.NotEqual(t, duplicate.String(), resp.IPv4Addr)
+	quarantined := ds.QuarantinedIPs()
+	assert.Len(t, quarantined, 1)
+	assert.Equal(t, duplicate.String(), quarantined[0].IP)
+	assert.False(t, quarantined[0].Assigned)
+
+	// Without any free IP left, the pod fails instead of getting the duplicate one
+	m.network.EXPECT().ProbeAddress(gomock.Any(), gomock.Any()).Return(remote, nil)
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP("10.0.0.3"), Mask: net.CIDRMask(32, 32)}, false))
+	resp, err = s.AddNetwork(context.Background(), &pb.AddNetworkRequest{
+		ClientVersion:     "1.2.3",
+		K8S_POD_NAME:      "pod-2",
+		K8S_POD_NAMESPACE: "default",
+		ContainerID:       "cid-pod-2",
+		IfName:            "eth0",
+		NetworkName:       "aws-cni",
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.Success)
+	assert.Len(t, ds.QuarantinedIPs(), 2)
+}
+
+func TestDetectDuplicateAddressProbeError(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	mockContext := &IPAMContext{networkClient: m.network, duplicateAddressTimeout: time.Second}
+	m.network.EXPECT().ProbeAddress(net.ParseIP("10.0.0.1"), gomock.Any()).Return(nil, errors.New("operation not permitted"))
+	err := mockContext.detectDuplicateAddress(datastore.IPAMKey{}, func() string { return "10.0.0.1" }, func() error {
+		t.Fatal("an IP that can't be probed is kept")
+		return nil
+	})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,97 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/networkutils"
+)
+
+// eniLinkCheckInterval is how often the links of the secondary ENIs are checked
+const eniLinkCheckInterval = 30 * time.Second
+
+// eniLink is what setupENI set up the network of a secondary ENI with, for the ENI link watcher to set it up again
+type eniLink struct {
+	mac          string
+	deviceNumber int
+	primaryIP    string
+	subnetCIDR   string
+	name         string
+}
+
+// recordENILink records a secondary ENI whose network was set up
+func (c *IPAMContext) recordENILink(eni string, link eniLink) {
+	c.primaryIPLock.Lock()
+	defer c.primaryIPLock.Unlock()
+	if c.eniLinks == nil {
+		c.eniLinks = make(map[string]eniLink)
+	}
+	c.eniLinks[eni] = link
+}
+
+// StartENILinkWatcher periodically sets up again the network of the secondary ENIs whose link was renamed, bounced or
+// enslaved by udev or systemd-networkd after the ENI was attached, which otherwise leaves a dead route table until
+// aws-node restarts
+func (c *IPAMContext) StartENILinkWatcher() {
+	for {
+		time.Sleep(eniLinkCheckInterval)
+		c.checkENILinks()
+	}
+}
+
+func (c *IPAMContext) checkENILinks() {
+	c.primaryIPLock.Lock()
+	links := make(map[string]eniLink, len(c.eniLinks))
+	for eni, link := range c.eniLinks {
+		links[eni] = link
+	}
+	c.primaryIPLock.Unlock()
+
+	current := c.dataStore.GetENIInfos().ENIs
+	for eni, link := range links {
+		if _, ok := current[eni]; !ok {
+			// The ENI was freed
+			c.primaryIPLock.Lock()
+			delete(c.eniLinks, eni)
+			c.primaryIPLock.Unlock()
+			continue
+		}
+		name, problem, err := c.networkClient.CheckENINetwork(link.primaryIP, link.mac, link.deviceNumber)
+		if err != nil {
+			log.Warnf("Failed to check the network of ENI %s: %v", eni, err)
+			ipamdErrInc("checkENILinks")
+			continue
+		}
+		if name != "" && name != link.name {
+			if link.name != "" {
+				log.Infof("The link of ENI %s was renamed from %s to %s", eni, link.name, name)
+			}
+			link.name = name
+			c.recordENILink(eni, link)
+		}
+		// A missing link is a detached ENI, which the pool reconcile removes
+		if problem == "" || problem == networkutils.ENILinkMissing {
+			continue
+		}
+		log.Warnf("The network of ENI %s (%s) is broken: %s, setting it up again", eni, name, problem)
+		eniLinkRepairs.With(prometheus.Labels{"problem": problem}).Inc()
+		if err := c.networkClient.SetupENINetwork(link.primaryIP, link.mac, link.deviceNumber, link.subnetCIDR); err != nil {
+			log.Errorf("Failed to set up the network of ENI %s again: %v", eni, err)
+			ipamdErrInc("checkENILinks")
+		}
+	}
+}
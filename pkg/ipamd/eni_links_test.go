@@ -0,0 +1,65 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/networkutils"
+)
+
+func TestCheckENILinks(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 1, false, false, false))
+	mockContext := &IPAMContext{networkClient: m.network, dataStore: ds}
+	link := eniLink{mac: "02:00:00:00:00:01", deviceNumber: 1, primaryIP: "10.0.0.10", subnetCIDR: "10.0.0.0/24"}
+	mockContext.recordENILink("eni-1", link)
+	// A freed ENI is forgotten
+	mockContext.recordENILink("eni-2", eniLink{mac: "02:00:00:00:00:02", deviceNumber: 2})
+
+	repairs := func(problem string) float64 {
+		return testutil.ToFloat64(eniLinkRepairs.With(prometheus.Labels{"problem": problem}))
+	}
+	before := repairs(networkutils.ENILinkRouteMissing)
+
+	m.network.EXPECT().CheckENINetwork("10.0.0.10", "02:00:00:00:00:01", 1).Return("eth1", "", nil)
+	mockContext.checkENILinks()
+	assert.Equal(t, "eth1", mockContext.eniLinks["eni-1"].name)
+	assert.NotContains(t, mockContext.eniLinks, "eni-2")
+
+	// udev renamed the link, which flushed its routes
+	m.network.EXPECT().CheckENINetwork("10.0.0.10", "02:00:00:00:00:01", 1).
+		Return("ens6", networkutils.ENILinkRouteMissing, nil)
+	m.network.EXPECT().SetupENINetwork("10.0.0.10", "02:00:00:00:00:01", 1, "10.0.0.0/24").Return(nil)
+	mockContext.checkENILinks()
+	assert.Equal(t, "ens6", mockContext.eniLinks["eni-1"].name)
+	assert.Equal(t, before+1, repairs(networkutils.ENILinkRouteMissing))
+
+	// A detached ENI is left to the pool reconcile
+	m.network.EXPECT().CheckENINetwork("10.0.0.10", "02:00:00:00:00:01", 1).Return("", networkutils.ENILinkMissing, nil)
+	mockContext.checkENILinks()
+
+	m.network.EXPECT().CheckENINetwork("10.0.0.10", "02:00:00:00:00:01", 1).Return("", "", errors.New("netlink failure"))
+	mockContext.checkENILinks()
+	assert.Equal(t, before+1, repairs(networkutils.ENILinkRouteMissing))
+}
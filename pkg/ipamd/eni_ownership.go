@@ -0,0 +1,34 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+// markENINotOwned keeps an ENI that the CNI of this cluster did not create out of the IP pool, after it was removed
+// from the datastore to be freed. The ENI stays attached, and is left alone until ipamd restarts.
+func (c *IPAMContext) markENINotOwned(eniID string) {
+	log.Warnf("ENI %s was not created by the CNI of this cluster, so it won't be freed or used for pods. Tag it with %s=true to keep ipamd away from it",
+		eniID, eniNoManageTagKey)
+	c.notOwnedENIsLock.Lock()
+	defer c.notOwnedENIsLock.Unlock()
+	if c.notOwnedENIs == nil {
+		c.notOwnedENIs = make(map[string]bool)
+	}
+	c.notOwnedENIs[eniID] = true
+}
+
+// isNotOwnedENI returns true if FreeENI refused to free the ENI
+func (c *IPAMContext) isNotOwnedENI(eniID string) bool {
+	c.notOwnedENIsLock.RLock()
+	defer c.notOwnedENIsLock.RUnlock()
+	return c.notOwnedENIs[eniID]
+}
@@ -0,0 +1,49 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils"
+	mock_awsutils "github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils/mocks"
+)
+
+func TestIPAMContext_filterUnmanagedENIs_notOwnedENIs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	eni1, eni2, eni3 := getDummyENIMetadata()
+	allENIs := []awsutils.ENIMetadata{eni1, eni2, eni3}
+
+	mockAWSUtils := mock_awsutils.NewMockAPIs(ctrl)
+	mockAWSUtils.EXPECT().IsUnmanagedENI(gomock.Any()).Return(false).AnyTimes()
+	mockAWSUtils.EXPECT().IsCNIUnmanagedENI(gomock.Any()).Return(false).AnyTimes()
+
+	c := &IPAMContext{
+		awsClient: mockAWSUtils,
+		maxENI:    4,
+	}
+	assert.False(t, c.isNotOwnedENI(eni2.ENIID))
+	assert.Equal(t, allENIs, c.filterUnmanagedENIs(allENIs))
+
+	// An ENI that FreeENI refused to free is kept out of the pool
+	c.markENINotOwned(eni2.ENIID)
+	assert.True(t, c.isNotOwnedENI(eni2.ENIID))
+	assert.Equal(t, []awsutils.ENIMetadata{eni1, eni3}, c.filterUnmanagedENIs(allENIs))
+	assert.Equal(t, 1, c.unmanagedENI)
+}
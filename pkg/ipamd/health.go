@@ -0,0 +1,218 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	healthProbeEC2        = "EC2"
+	healthProbeDatastore  = "Datastore"
+	healthProbeCNIBinary  = "CNIBinary"
+	healthProbeIptables   = "Iptables"
+	healthProbeIPHeadroom = "IPHeadroom"
+
+	healthReasonEC2Unreachable         = "EC2Unreachable"
+	healthReasonDatastoreNotReconciled = "DatastoreNotReconciled"
+	healthReasonCNIVersionMismatch     = "CNIVersionMismatch"
+	healthReasonIptablesFailed         = "IptablesProgrammingFailed"
+	healthReasonInsufficientIPs        = "InsufficientIPs"
+
+	// grpcReadinessServiceName is the gRPC health service that reports readiness, grpcHealthServiceName and the
+	// server as a whole report liveness
+	grpcReadinessServiceName = grpcHealthServiceName + ".readiness"
+)
+
+// HealthProbe is the result of one of the probes behind the /healthz and /readyz endpoints
+type HealthProbe struct {
+	Name    string
+	Healthy bool
+	Reason  string `json:",omitempty"`
+	Message string `json:",omitempty"`
+	// Liveness is true if a failure of the probe is only fixed by restarting aws-node, and so fails /healthz as well
+	Liveness bool
+}
+
+// HealthReport is the body of the /healthz and /readyz endpoints. OK is false if any of the probes failed.
+type HealthReport struct {
+	OK     bool
+	Time   time.Time
+	Probes []HealthProbe
+}
+
+// healthState holds the outcome of the work ipamd does in the background that the probes report on
+type healthState struct {
+	lock                sync.RWMutex
+	datastoreReconciled bool
+	cniVersionErr       error
+	iptablesErr         error
+	ipHeadroomReached   bool
+}
+
+// setDatastoreReconciled records that the datastore restored from the checkpoint was reconciled with the ENIs and IPs
+// attached to the instance
+func (c *IPAMContext) setDatastoreReconciled() {
+	c.health.lock.Lock()
+	defer c.health.lock.Unlock()
+	c.health.datastoreReconciled = true
+}
+
+// setCNIVersionHealth records whether the last request of the CNI binary matched the version of ipamd. A mismatched
+// binary is left behind when its copy failed at startup, and is only replaced when aws-node restarts.
+func (c *IPAMContext) setCNIVersionHealth(err error) {
+	c.health.lock.Lock()
+	defer c.health.lock.Unlock()
+	c.health.cniVersionErr = err
+}
+
+// setIptablesHealth records whether the last programming of the host iptables rules succeeded
+func (c *IPAMContext) setIptablesHealth(err error) {
+	c.health.lock.Lock()
+	defer c.health.lock.Unlock()
+	c.health.iptablesErr = err
+}
+
+func (c *IPAMContext) runHealthProbes() []HealthProbe {
+	ec2 := HealthProbe{Name: healthProbeEC2, Healthy: true}
+	if err := c.awsClient.GetEC2ReachabilityError(); err != nil {
+		ec2.Healthy = false
+		ec2.Reason = healthReasonEC2Unreachable
+		ec2.Message = err.Error()
+	}
+
+	var ipHeadroom *HealthProbe
+	if c.minimumReadyIPTarget > 0 {
+		probe := c.ipHeadroomProbe()
+		ipHeadroom = &probe
+	}
+
+	c.health.lock.RLock()
+	defer c.health.lock.RUnlock()
+	datastore := HealthProbe{Name: healthProbeDatastore, Healthy: c.health.datastoreReconciled}
+	if !datastore.Healthy {
+		datastore.Reason = healthReasonDatastoreNotReconciled
+		datastore.Message = "the datastore was not reconciled with the ENIs of the instance yet"
+	}
+	cniBinary := HealthProbe{Name: healthProbeCNIBinary, Healthy: c.health.cniVersionErr == nil, Liveness: true}
+	if !cniBinary.Healthy {
+		cniBinary.Reason = healthReasonCNIVersionMismatch
+		cniBinary.Message = c.health.cniVersionErr.Error()
+	}
+	iptables := HealthProbe{Name: healthProbeIptables, Healthy: c.health.iptablesErr == nil, Liveness: true}
+	if !iptables.Healthy {
+		iptables.Reason = healthReasonIptablesFailed
+		iptables.Message = c.health.iptablesErr.Error()
+	}
+	probes := []HealthProbe{ec2, datastore, cniBinary, iptables}
+	if ipHeadroom != nil {
+		probes = append(probes, *ipHeadroom)
+	}
+	return probes
+}
+
+// ipHeadroomProbe fails until the datastore had MINIMUM_READY_IP_TARGET assignable IPs once. It doesn't fail again
+// when pods use them up afterwards, which is what the warm targets are for.
+func (c *IPAMContext) ipHeadroomProbe() HealthProbe {
+	c.health.lock.Lock()
+	defer c.health.lock.Unlock()
+	probe := HealthProbe{Name: healthProbeIPHeadroom, Healthy: true}
+	if c.health.ipHeadroomReached {
+		return probe
+	}
+	family := ipV4AddrFamily
+	if c.enableIPv6 {
+		family = ipV6AddrFamily
+	}
+	available := c.dataStore.GetIPStats(family).AvailableAddresses()
+	if available >= c.minimumReadyIPTarget {
+		c.health.ipHeadroomReached = true
+		return probe
+	}
+	probe.Healthy = false
+	probe.Reason = healthReasonInsufficientIPs
+	probe.Message = fmt.Sprintf("%d assignable IPs, MINIMUM_READY_IP_TARGET is %d", available, c.minimumReadyIPTarget)
+	return probe
+}
+
+// getHealthReport runs the probes. The liveness report only has the probes whose failure needs a restart of aws-node,
+// the readiness report has all of them.
+func (c *IPAMContext) getHealthReport(readiness bool) *HealthReport {
+	report := &HealthReport{OK: true, Time: time.Now()}
+	for _, probe := range c.runHealthProbes() {
+		if !readiness && !probe.Liveness {
+			continue
+		}
+		if !probe.Healthy {
+			report.OK = false
+		}
+		report.Probes = append(report.Probes, probe)
+	}
+	return report
+}
+
+// healthRequestHandler serves the liveness or readiness report, with a 503 status if a probe failed
+func healthRequestHandler(ipam *IPAMContext, readiness bool) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := ipam.getHealthReport(readiness)
+		responseJSON, err := json.Marshal(report)
+		if err != nil {
+			log.Errorf("Failed to marshal health report: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if !report.OK {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		logErr(w.Write(responseJSON))
+	}
+}
+
+// healthServer implements the gRPC health protocol used by grpc-health-probe on top of the health probes, so that the
+// liveness and readiness probes of aws-node exit with a failure when ipamd is unhealthy
+type healthServer struct {
+	healthpb.UnimplementedHealthServer
+	ipamContext *IPAMContext
+}
+
+func (s *healthServer) Check(ctx context.Context, in *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	var report *HealthReport
+	switch in.Service {
+	case "", grpcHealthServiceName:
+		report = s.ipamContext.getHealthReport(false)
+	case grpcReadinessServiceName:
+		report = s.ipamContext.getHealthReport(true)
+	default:
+		return nil, status.Errorf(codes.NotFound, "unknown service %q", in.Service)
+	}
+	if !report.OK {
+		for _, probe := range report.Probes {
+			if !probe.Healthy {
+				log.Warnf("Health probe %s failed for service %q: %s: %s", probe.Name, in.Service, probe.Reason, probe.Message)
+			}
+		}
+		return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_NOT_SERVING}, nil
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
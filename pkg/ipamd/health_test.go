@@ -0,0 +1,130 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func getHealth(t *testing.T, c *IPAMContext, readiness bool) (int, *HealthReport) {
+	w := httptest.NewRecorder()
+	healthRequestHandler(c, readiness)(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	var report HealthReport
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	return w.Code, &report
+}
+
+func failedProbeReasons(report *HealthReport) []string {
+	var reasons []string
+	for _, probe := range report.Probes {
+		if !probe.Healthy {
+			reasons = append(reasons, probe.Reason)
+		}
+	}
+	return reasons
+}
+
+func TestHealthProbes(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	mockContext := &IPAMContext{awsClient: m.awsutils}
+	s := &healthServer{ipamContext: mockContext}
+
+	// The datastore isn't reconciled yet, which only fails readiness
+	m.awsutils.EXPECT().GetEC2ReachabilityError().Return(errors.New("dial tcp: i/o timeout")).Times(2)
+	code, report := getHealth(t, mockContext, false)
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, report.OK)
+	assert.Len(t, report.Probes, 2)
+	code, report = getHealth(t, mockContext, true)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, []string{healthReasonEC2Unreachable, healthReasonDatastoreNotReconciled}, failedProbeReasons(report))
+
+	mockContext.setDatastoreReconciled()
+	m.awsutils.EXPECT().GetEC2ReachabilityError().Return(nil).AnyTimes()
+	code, report = getHealth(t, mockContext, true)
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, report.OK)
+	assert.Len(t, report.Probes, 4)
+
+	resp, err := s.Check(context.Background(), &healthpb.HealthCheckRequest{Service: grpcReadinessServiceName})
+	assert.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+	// A mismatched CNI binary and failed iptables programming need a restart
+	rpcServer := &server{version: "1.2.3", ipamContext: mockContext}
+	assert.Error(t, rpcServer.validateVersion("1.2.2"))
+	mockContext.setIptablesHealth(errors.New("iptables: Resource temporarily unavailable"))
+	code, report = getHealth(t, mockContext, false)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, []string{healthReasonCNIVersionMismatch, healthReasonIptablesFailed}, failedProbeReasons(report))
+	for _, service := range []string{"", grpcHealthServiceName, grpcReadinessServiceName} {
+		resp, err = s.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		assert.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.Status)
+	}
+
+	assert.NoError(t, rpcServer.validateVersion("1.2.3"))
+	mockContext.setIptablesHealth(nil)
+	resp, err = s.Check(context.Background(), &healthpb.HealthCheckRequest{Service: grpcHealthServiceName})
+	assert.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+	_, err = s.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "unknown"})
+	assert.Error(t, err)
+}
+
+func TestHealthProbeIPHeadroom(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	mockContext := &IPAMContext{
+		awsClient:            m.awsutils,
+		dataStore:            datastore.NewDataStore(log, datastore.NullCheckpoint{}, false),
+		minimumReadyIPTarget: 2,
+	}
+	mockContext.setDatastoreReconciled()
+	m.awsutils.EXPECT().GetEC2ReachabilityError().Return(nil).AnyTimes()
+
+	// IPHeadroom only counts for readiness
+	code, _ := getHealth(t, mockContext, false)
+	assert.Equal(t, http.StatusOK, code)
+
+	assert.NoError(t, mockContext.dataStore.AddENI("eni-1", 0, true, false, false))
+	assert.NoError(t, mockContext.dataStore.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP(ipaddr01), Mask: net.IPv4Mask(255, 255, 255, 255)}, false))
+	code, report := getHealth(t, mockContext, true)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, []string{healthReasonInsufficientIPs}, failedProbeReasons(report))
+
+	assert.NoError(t, mockContext.dataStore.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP(ipaddr02), Mask: net.IPv4Mask(255, 255, 255, 255)}, false))
+	code, _ = getHealth(t, mockContext, true)
+	assert.Equal(t, http.StatusOK, code)
+
+	// Pods using up the IPs afterwards don't make the node unready again
+	assert.NoError(t, mockContext.dataStore.DelIPv4CidrFromStore("eni-1", net.IPNet{IP: net.ParseIP(ipaddr02), Mask: net.IPv4Mask(255, 255, 255, 255)}, true))
+	code, _ = getHealth(t, mockContext, true)
+	assert.Equal(t, http.StatusOK, code)
+}
@@ -0,0 +1,107 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/networkutils"
+)
+
+// HostVeth maps the host-side veth device of a pod to the pod. Pods whose IP was recovered from the routes, and warm
+// veths that no pod took yet, only have the veth name and IP.
+type HostVeth struct {
+	Name            string
+	K8SPodNamespace string `json:",omitempty"`
+	K8SPodName      string `json:",omitempty"`
+	ContainerID     string `json:",omitempty"`
+	IP              string
+}
+
+// allocatedPodIPs returns the pod IPs of the address family of the cluster
+func (c *IPAMContext) allocatedPodIPs() []datastore.PodIPInfo {
+	if c.enableIPv6 {
+		return c.dataStore.AllocatedIPv6s()
+	}
+	return c.dataStore.AllocatedIPs()
+}
+
+// hostVeths returns the host-side veth devices of the pods with an IP from the datastore, sorted by name. Pods with a
+// branch or dedicated ENI are not in the datastore.
+func (c *IPAMContext) hostVeths() []HostVeth {
+	var veths []HostVeth
+	for _, info := range c.allocatedPodIPs() {
+		if info.IPAMKey == datastore.RecoveredIPAMKey(info.IPAMKey.ContainerID) ||
+			info.IPAMKey == datastore.WarmVethIPAMKey(info.IPAMKey.ContainerID) {
+			veths = append(veths, HostVeth{Name: info.IPAMKey.ContainerID, IP: info.IP})
+			continue
+		}
+		veths = append(veths, HostVeth{
+			Name:            c.networkClient.GetHostVethName(info.Metadata.K8SPodNamespace, info.Metadata.K8SPodName),
+			K8SPodNamespace: info.Metadata.K8SPodNamespace,
+			K8SPodName:      info.Metadata.K8SPodName,
+			ContainerID:     info.IPAMKey.ContainerID,
+			IP:              info.IP,
+		})
+	}
+	sort.Slice(veths, func(i, j int) bool { return veths[i].Name < veths[j].Name })
+	return veths
+}
+
+// hostVethCollision returns the pod, other than the given one, whose host veth name is the same as the one of the
+// given pod, or nil. The name is a truncated hash of the namespace and name of the pod, so two pods can get the same
+// name, and setting up the second one would take the veth of the first.
+func (c *IPAMContext) hostVethCollision(pod datastore.IPAMMetadata) *datastore.IPAMMetadata {
+	// The prefix is the same for all pods, comparing the hashes is enough
+	name := networkutils.GenerateHostVethName("", pod.K8SPodNamespace, pod.K8SPodName)
+	for _, info := range c.allocatedPodIPs() {
+		other := info.Metadata
+		if other.K8SPodName == "" || other.K8SPodNamespace == pod.K8SPodNamespace && other.K8SPodName == pod.K8SPodName {
+			continue
+		}
+		if networkutils.GenerateHostVethName("", other.K8SPodNamespace, other.K8SPodName) == name {
+			return &other
+		}
+	}
+	return nil
+}
+
+// hostVethsRequestHandler serves the host-side veth devices of the pods, or with the `name` query parameter, the one
+// with that name
+func hostVethsRequestHandler(ipam *IPAMContext) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var response interface{}
+		veths := ipam.hostVeths()
+		response = veths
+		if name := r.URL.Query().Get("name"); name != "" {
+			i := sort.Search(len(veths), func(i int) bool { return veths[i].Name >= name })
+			if i == len(veths) || veths[i].Name != name {
+				http.Error(w, fmt.Sprintf("no pod with host veth %q", name), http.StatusNotFound)
+				return
+			}
+			response = veths[i]
+		}
+		responseJSON, err := json.Marshal(response)
+		if err != nil {
+			log.Errorf("Failed to marshal host veths: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		logErr(w.Write(responseJSON))
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/networkutils"
+)
+
+func TestHostVeths(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)}, false))
+	}
+	podIP, _, err := ds.AssignPodIPv4Address(datastore.IPAMKey{NetworkName: "aws-cni", ContainerID: "cid-1", IfName: "eth0"},
+		datastore.IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "a.b"})
+	assert.NoError(t, err)
+	recoveredIP, _, err := ds.AssignPodIPv4Address(datastore.RecoveredIPAMKey("eni0123456789a"), datastore.IPAMMetadata{})
+	assert.NoError(t, err)
+
+	m.network.EXPECT().GetHostVethName("default", "a.b").DoAndReturn(func(namespace, podName string) string {
+		return networkutils.GenerateHostVethName("eni", namespace, podName)
+	}).AnyTimes()
+	mockContext := &IPAMContext{networkClient: m.network, dataStore: ds, enableIPv4: true}
+	podVeth := HostVeth{
+		Name:            networkutils.GenerateHostVethName("eni", "default", "a.b"),
+		K8SPodNamespace: "default",
+		K8SPodName:      "a.b",
+		ContainerID:     "cid-1",
+		IP:              podIP,
+	}
+	recoveredVeth := HostVeth{Name: "eni0123456789a", IP: recoveredIP}
+	assert.Equal(t, []HostVeth{recoveredVeth, podVeth}, mockContext.hostVeths())
+
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		hostVethsRequestHandler(mockContext)(w, httptest.NewRequest(http.MethodGet, url, nil))
+		return w
+	}
+	w := get("/v1/host-veths")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var veths []HostVeth
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &veths))
+	assert.Equal(t, []HostVeth{recoveredVeth, podVeth}, veths)
+
+	w = get("/v1/host-veths?name=" + podVeth.Name)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var veth HostVeth
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &veth))
+	assert.Equal(t, podVeth, veth)
+
+	assert.Equal(t, http.StatusNotFound, get("/v1/host-veths?name=eni00000000000").Code)
+
+	// The same pod with a new sandbox, or another pod, is fine
+	assert.Nil(t, mockContext.hostVethCollision(datastore.IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "a.b"}))
+	assert.Nil(t, mockContext.hostVethCollision(datastore.IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "a.b", K8SPodUID: "uid-1"}))
+	assert.Nil(t, mockContext.hostVethCollision(datastore.IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "c"}))
+	// default.a + . + b hashes the same as default + . + a.b
+	assert.Equal(t, &datastore.IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "a.b"},
+		mockContext.hostVethCollision(datastore.IPAMMetadata{K8SPodNamespace: "default.a", K8SPodName: "b"}))
+}
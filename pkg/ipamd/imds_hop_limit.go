@@ -0,0 +1,45 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"os"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils"
+)
+
+// fixIMDSHopLimit raises the IMDSv2 hop limit of the instance when ENABLE_IMDS_HOP_LIMIT_FIX is set and err is the
+// failure to reach the instance metadata from a container network namespace. It returns true if the hop limit was
+// raised, and the AWS client can be created again.
+func (c *IPAMContext) fixIMDSHopLimit(ctx context.Context, err error) bool {
+	var hopLimitErr *awsutils.IMDSHopLimitError
+	if !errors.As(err, &hopLimitErr) || !enableIMDSHopLimitFix() {
+		return false
+	}
+	var node corev1.Node
+	if err := c.rawK8SClient.Get(ctx, types.NamespacedName{Name: os.Getenv(envNodeName)}, &node); err != nil {
+		log.Errorf("Unable to raise the IMDSv2 hop limit, failed to get the node: %v", err)
+		return false
+	}
+	if err := awsutils.FixIMDSHopLimit(node.Spec.ProviderID); err != nil {
+		log.Errorf("Unable to raise the IMDSv2 hop limit: %v", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,43 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils"
+)
+
+func TestFixIMDSHopLimit(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+	defer os.Unsetenv(envEnableIMDSHopLimitFix)
+	mockContext := &IPAMContext{rawK8SClient: m.rawK8SClient}
+	hopLimitErr := &awsutils.IMDSHopLimitError{Err: errors.New("failed to get IMDSv2 token")}
+
+	// Opt-in only
+	_ = os.Unsetenv(envEnableIMDSHopLimitFix)
+	assert.False(t, mockContext.fixIMDSHopLimit(context.Background(), hopLimitErr))
+
+	_ = os.Setenv(envEnableIMDSHopLimitFix, "true")
+	// Other failures are not fixed by the hop limit
+	assert.False(t, mockContext.fixIMDSHopLimit(context.Background(), errors.New("region not found")))
+	// The node is needed to find the instance
+	assert.False(t, mockContext.fixIMDSHopLimit(context.Background(), hopLimitErr))
+}
@@ -15,6 +15,7 @@ package ipamd
 
 import (
 	"encoding/json"
+	"fmt"
 	"net"
 	"net/http"
 	"os"
@@ -23,6 +24,7 @@ import (
 	"time"
 
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/eniconfig"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/networkutils"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/retry"
 )
@@ -42,6 +44,13 @@ type rootResponse struct {
 	AvailableCommands []string
 }
 
+// efaENIsResponse lists the EFA interfaces attached to the instance, e.g. for coordination with the EFA device plugin
+type efaENIsResponse struct {
+	// ExcludedFromPodIPs is true if the EFA ENIs are kept out of the pod IP pool
+	ExcludedFromPodIPs bool
+	ENIs               []string
+}
+
 // LoggingHandler is a object for handling http request
 type LoggingHandler struct {
 	h http.Handler
@@ -84,9 +93,25 @@ func (c *IPAMContext) ServeIntrospection() {
 func (c *IPAMContext) setupIntrospectionServer() *http.Server {
 	serverFunctions := map[string]func(w http.ResponseWriter, r *http.Request){
 		"/v1/enis":                      eniV1RequestHandler(c),
+		"/v1/pods":                      podsV1RequestHandler(c),
 		"/v1/eni-configs":               eniConfigRequestHandler(c),
 		"/v1/networkutils-env-settings": networkEnvV1RequestHandler(),
 		"/v1/ipamd-env-settings":        ipamdEnvV1RequestHandler(),
+		"/v1/efa-enis":                  efaENIsRequestHandler(c),
+		"/v1/readiness":                 readinessRequestHandler(c),
+		"/v1/capabilities":              capabilitiesRequestHandler(c),
+		"/v1/config":                    configRequestHandler(c),
+		"/v1/datastore-invariants":      datastoreInvariantsRequestHandler(c),
+		"/v1/datastore-snapshot":        datastoreSnapshotRequestHandler(c),
+		"/v1/pool-decisions":            poolDecisionsRequestHandler(c),
+		"/v1/host-veths":                hostVethsRequestHandler(c),
+		"/v1/pool-state":                poolStateRequestHandler(c),
+		"/v1/teardown-queue":            teardownQueueRequestHandler(c),
+		"/v1/subnet-prefixes":           subnetPrefixesRequestHandler(c),
+		"/v1/quarantine":                quarantineRequestHandler(c),
+		"/v1/audit-log":                 auditLogRequestHandler(),
+		"/healthz":                      healthRequestHandler(c, false),
+		"/readyz":                       healthRequestHandler(c, true),
 	}
 	paths := make([]string, 0, len(serverFunctions))
 	for path := range serverFunctions {
@@ -129,9 +154,35 @@ func (c *IPAMContext) setupIntrospectionServer() *http.Server {
 	return server
 }
 
+// parseAddressQuery reads the `eni`, `namespace`, `state`, `offset` and `limit` query parameters of the /v1/enis and
+// /v1/pods endpoints
+func parseAddressQuery(r *http.Request) (datastore.AddressQuery, error) {
+	values := r.URL.Query()
+	q := datastore.AddressQuery{
+		ENIID:     values.Get("eni"),
+		Namespace: values.Get("namespace"),
+		State:     values.Get("state"),
+	}
+	for name, value := range map[string]*int{"offset": &q.Offset, "limit": &q.Limit} {
+		if s := values.Get(name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return q, fmt.Errorf("invalid %s %q, expected a number", name, s)
+			}
+			*value = n
+		}
+	}
+	return q, q.Validate()
+}
+
 func eniV1RequestHandler(ipam *IPAMContext) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		responseJSON, err := json.Marshal(ipam.dataStore.GetENIInfos())
+		q, err := parseAddressQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		responseJSON, err := json.Marshal(ipam.dataStore.QueryENIInfos(q))
 		if err != nil {
 			log.Errorf("Failed to marshal ENI data: %v", err)
 			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
@@ -141,6 +192,108 @@ func eniV1RequestHandler(ipam *IPAMContext) func(http.ResponseWriter, *http.Requ
 	}
 }
 
+func podsV1RequestHandler(ipam *IPAMContext) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseAddressQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		responseJSON, err := json.Marshal(ipam.dataStore.QueryAddresses(q))
+		if err != nil {
+			log.Errorf("Failed to marshal pod addresses: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		logErr(w.Write(responseJSON))
+	}
+}
+
+func efaENIsRequestHandler(ipam *IPAMContext) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response := efaENIsResponse{
+			ExcludedFromPodIPs: ipam.excludeEFAENIs,
+			ENIs:               ipam.awsClient.GetEFAENIs(),
+		}
+		responseJSON, err := json.Marshal(response)
+		if err != nil {
+			log.Errorf("Failed to marshal EFA ENI data: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		logErr(w.Write(responseJSON))
+	}
+}
+
+func readinessRequestHandler(ipam *IPAMContext) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := ipam.getReadinessReport()
+		if report == nil {
+			http.Error(w, "startup validation has not completed", http.StatusServiceUnavailable)
+			return
+		}
+		responseJSON, err := json.Marshal(report)
+		if err != nil {
+			log.Errorf("Failed to marshal readiness report: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		logErr(w.Write(responseJSON))
+	}
+}
+
+func capabilitiesRequestHandler(ipam *IPAMContext) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		responseJSON, err := json.Marshal(ipam.capabilityReport)
+		if err != nil {
+			log.Errorf("Failed to marshal capability report: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		logErr(w.Write(responseJSON))
+	}
+}
+
+func datastoreInvariantsRequestHandler(ipam *IPAMContext) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		violations := ipam.checkDatastoreInvariants()
+		if violations == nil {
+			violations = []datastore.InvariantViolation{}
+		}
+		responseJSON, err := json.Marshal(violations)
+		if err != nil {
+			log.Errorf("Failed to marshal datastore invariant violations: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		logErr(w.Write(responseJSON))
+	}
+}
+
+func datastoreSnapshotRequestHandler(ipam *IPAMContext) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		responseJSON, err := json.Marshal(ipam.dataStore.GetSnapshot())
+		if err != nil {
+			log.Errorf("Failed to marshal datastore snapshot: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		logErr(w.Write(responseJSON))
+	}
+}
+
+func configRequestHandler(ipam *IPAMContext) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		responseJSON, err := json.Marshal(ipam.getEffectiveConfig())
+		if err != nil {
+			log.Errorf("Failed to marshal effective config: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		logErr(w.Write(responseJSON))
+	}
+}
+
 func eniConfigRequestHandler(ipam *IPAMContext) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -0,0 +1,62 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+)
+
+func TestAddressQueryRequestHandlers(t *testing.T) {
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	assert.NoError(t, ds.AddENI("eni-2", 1, false, false, false))
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP("10.0.0.1"), Mask: net.CIDRMask(32, 32)}, false))
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-2", net.IPNet{IP: net.ParseIP("10.0.1.1"), Mask: net.CIDRMask(32, 32)}, false))
+	_, _, err := ds.AssignPodIPv4Address(datastore.IPAMKey{NetworkName: "aws-cni", ContainerID: "cid-1", IfName: "eth0"},
+		datastore.IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "pod-1"})
+	assert.NoError(t, err)
+	mockContext := &IPAMContext{dataStore: ds, enableIPv4: true}
+
+	get := func(handler func(*IPAMContext) func(http.ResponseWriter, *http.Request), url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(mockContext)(w, httptest.NewRequest(http.MethodGet, url, nil))
+		return w
+	}
+
+	w := get(podsV1RequestHandler, "/v1/pods?namespace=default")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var page datastore.AddressPage
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Len(t, page.Addresses, 1)
+	assert.Equal(t, "pod-1", page.Addresses[0].IPAMMetadata.K8SPodName)
+
+	w = get(eniV1RequestHandler, "/v1/enis?limit=1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var infos datastore.ENIInfos
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &infos))
+	assert.Len(t, infos.ENIs, 1)
+	assert.Equal(t, 1, infos.NextOffset)
+
+	assert.Equal(t, http.StatusBadRequest, get(eniV1RequestHandler, "/v1/enis?state=free").Code)
+	assert.Equal(t, http.StatusBadRequest, get(podsV1RequestHandler, "/v1/pods?limit=ten").Code)
+	assert.Equal(t, http.StatusBadRequest, get(podsV1RequestHandler, "/v1/pods?offset=-1").Code)
+}
@@ -0,0 +1,110 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/eventrecorder"
+)
+
+const (
+	// ipExhaustionReasonDatastore is used when a pod can't get an IP because the datastore has none left
+	ipExhaustionReasonDatastore = "IPAddressesExhausted"
+	// ipExhaustionReasonSubnet is used when EC2 can't allocate more IPs or prefixes from the subnet
+	ipExhaustionReasonSubnet = "InsufficientSubnetAddresses"
+	// ipExhaustionReasonRecovered is used once the node has free IPs again
+	ipExhaustionReasonRecovered = "IPAddressesAvailable"
+)
+
+// reportIPExhaustion raises a warning event on the node, and sets the IP_EXHAUSTION_NODE_CONDITION condition if
+// configured. Only the first report is sent until clearIPExhaustionIfRecovered finds free IPs again, so that a
+// stream of failing pod sandboxes doesn't turn into a stream of API calls.
+func (c *IPAMContext) reportIPExhaustion(reason, message string) {
+	c.ipExhaustionLock.Lock()
+	alreadyExhausted := c.ipExhausted
+	c.ipExhausted = true
+	c.ipExhaustionLock.Unlock()
+	if alreadyExhausted {
+		return
+	}
+
+	log.Warnf("Node is out of IP addresses (%s): %s", reason, message)
+	eventrecorder.Get().SendNodeEvent(corev1.EventTypeWarning, reason, message)
+	c.setIPExhaustionCondition(corev1.ConditionTrue, reason, message)
+}
+
+// clearIPExhaustionIfRecovered clears a previous IP exhaustion report once the datastore has free IPs and we are
+// out of the insufficient CIDR cooldown
+func (c *IPAMContext) clearIPExhaustionIfRecovered() {
+	c.ipExhaustionLock.Lock()
+	if !c.ipExhausted || c.inInsufficientCidrCoolingPeriod() ||
+		c.dataStore.GetIPStats(ipV4AddrFamily).AvailableAddresses() == 0 {
+		c.ipExhaustionLock.Unlock()
+		return
+	}
+	c.ipExhausted = false
+	c.ipExhaustionLock.Unlock()
+
+	message := "IP addresses are available again"
+	log.Infof("Node has free IP addresses again")
+	eventrecorder.Get().SendNodeEvent(corev1.EventTypeNormal, ipExhaustionReasonRecovered, message)
+	c.setIPExhaustionCondition(corev1.ConditionFalse, ipExhaustionReasonRecovered, message)
+}
+
+// setIPExhaustionCondition sets the configured IP exhaustion condition on the node's status
+func (c *IPAMContext) setIPExhaustionCondition(status corev1.ConditionStatus, reason, message string) {
+	if c.ipExhaustionCondition == "" {
+		return
+	}
+	ctx := context.TODO()
+	node := &corev1.Node{}
+	err := c.cachedK8SClient.Get(ctx, types.NamespacedName{Name: c.myNodeName}, node)
+	if err != nil {
+		log.Errorf("Failed to get node to set condition %s: %v", c.ipExhaustionCondition, err)
+		return
+	}
+
+	now := metav1.Now()
+	condition := corev1.NodeCondition{
+		Type:               corev1.NodeConditionType(c.ipExhaustionCondition),
+		Status:             status,
+		LastHeartbeatTime:  now,
+		LastTransitionTime: now,
+		Reason:             reason,
+		Message:            message,
+	}
+	updateNode := node.DeepCopy()
+	found := false
+	for i := range updateNode.Status.Conditions {
+		if updateNode.Status.Conditions[i].Type == condition.Type {
+			updateNode.Status.Conditions[i] = condition
+			found = true
+		}
+	}
+	if !found {
+		updateNode.Status.Conditions = append(updateNode.Status.Conditions, condition)
+	}
+
+	if err = c.cachedK8SClient.Status().Patch(ctx, updateNode, client.StrategicMergeFrom(node)); err != nil {
+		log.Errorf("Failed to set node condition %s to %s: %v", c.ipExhaustionCondition, status, err)
+		return
+	}
+	log.Debugf("Set node condition %s to %s", c.ipExhaustionCondition, status)
+}
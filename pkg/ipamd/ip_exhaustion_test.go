@@ -0,0 +1,77 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/eventrecorder"
+)
+
+func TestIPExhaustionNodeCondition(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+	ctx := context.Background()
+
+	fakeRecorder := eventrecorder.InitMockEventRecorder(m.cachedK8SClient)
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: myNodeName}}
+	assert.NoError(t, m.cachedK8SClient.Create(ctx, node))
+
+	mockContext := &IPAMContext{
+		cachedK8SClient:       m.cachedK8SClient,
+		dataStore:             datastore.NewDataStore(log, datastore.NullCheckpoint{}, false),
+		myNodeName:            myNodeName,
+		ipExhaustionCondition: "NetworkUnavailable",
+	}
+	getCondition := func() *corev1.NodeCondition {
+		node := &corev1.Node{}
+		assert.NoError(t, m.cachedK8SClient.Get(ctx, types.NamespacedName{Name: myNodeName}, node))
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == corev1.NodeNetworkUnavailable {
+				return &condition
+			}
+		}
+		return nil
+	}
+
+	// Repeated failures only raise one event
+	mockContext.reportIPExhaustion(ipExhaustionReasonDatastore, "no free IP")
+	mockContext.reportIPExhaustion(ipExhaustionReasonDatastore, "no free IP")
+	assert.Len(t, fakeRecorder.Events, 1)
+	assert.Equal(t, "Warning IPAddressesExhausted no free IP", <-fakeRecorder.Events)
+	condition := getCondition()
+	assert.NotNil(t, condition)
+	assert.Equal(t, corev1.ConditionTrue, condition.Status)
+	assert.Equal(t, ipExhaustionReasonDatastore, condition.Reason)
+
+	// Still no free IPs, nothing changes
+	mockContext.clearIPExhaustionIfRecovered()
+	assert.Len(t, fakeRecorder.Events, 0)
+	assert.Equal(t, corev1.ConditionTrue, getCondition().Status)
+
+	_ = mockContext.dataStore.AddENI("eni-1", 0, true, false, false)
+	_ = mockContext.dataStore.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP(ipaddr01), Mask: net.IPv4Mask(255, 255, 255, 255)}, false)
+	mockContext.clearIPExhaustionIfRecovered()
+	assert.Len(t, fakeRecorder.Events, 1)
+	assert.Equal(t, corev1.ConditionFalse, getCondition().Status)
+	assert.Equal(t, ipExhaustionReasonRecovered, getCondition().Reason)
+}
@@ -0,0 +1,101 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+)
+
+const (
+	// podPinnedCIDRKey is the pod annotation that pins the IP address of the pod to a CIDR, e.g. a /28 prefix
+	podPinnedCIDRKey = "vpc.amazonaws.com/pinned-cidr"
+	// podPinnedENITagKey is the pod annotation that pins the IP address of the pod to the ENIs with a tag, as key=value
+	podPinnedENITagKey = "vpc.amazonaws.com/pinned-eni-tag"
+)
+
+// setENITags stores the tags of the attached ENIs, used to resolve the pinned-eni-tag annotation
+func (c *IPAMContext) setENITags(tagMap map[string]awsutils.TagMap) {
+	c.eniTagsLock.Lock()
+	defer c.eniTagsLock.Unlock()
+	c.eniTags = tagMap
+}
+
+// eniIDsWithTag returns the IDs of the ENIs that have the tag, sorted
+func (c *IPAMContext) eniIDsWithTag(key, value string) []string {
+	c.eniTagsLock.RLock()
+	defer c.eniTagsLock.RUnlock()
+	var eniIDs []string
+	for eniID, tags := range c.eniTags {
+		if tagValue, ok := tags[key]; ok && tagValue == value {
+			eniIDs = append(eniIDs, eniID)
+		}
+	}
+	sort.Strings(eniIDs)
+	return eniIDs
+}
+
+// getPodAddressPin returns the address pin requested by the annotations of the pod, and by the routability class of
+// the pod, or nil if there is none
+func (c *IPAMContext) getPodAddressPin(podName, podNamespace string) (*datastore.AddressPin, error) {
+	pod, err := c.GetPod(podName, podNamespace)
+	if err != nil {
+		return nil, err
+	}
+	var pin *datastore.AddressPin
+	if c.enablePodIPPinning {
+		if pin, err = c.podAddressPin(pod); err != nil {
+			return nil, err
+		}
+	}
+	return c.withPodRoutabilityClass(pin, pod)
+}
+
+func (c *IPAMContext) podAddressPin(pod *corev1.Pod) (*datastore.AddressPin, error) {
+	cidrValue, hasCIDR := pod.Annotations[podPinnedCIDRKey]
+	tagValue, hasTag := pod.Annotations[podPinnedENITagKey]
+	if !hasCIDR && !hasTag {
+		return nil, nil
+	}
+
+	pin := &datastore.AddressPin{}
+	if hasCIDR {
+		_, cidr, err := net.ParseCIDR(strings.TrimSpace(cidrValue))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s annotation", podPinnedCIDRKey)
+		}
+		pin.CIDR = cidr
+	}
+	if hasTag {
+		parts := strings.SplitN(strings.TrimSpace(tagValue), "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.Errorf("invalid %s annotation %q, expected key=value", podPinnedENITagKey, tagValue)
+		}
+		pin.ENIIDs = c.eniIDsWithTag(parts[0], parts[1])
+		if len(pin.ENIIDs) == 0 {
+			return nil, errors.Errorf("no ENI attached to the node has the tag %s", tagValue)
+		}
+	}
+	if err := pin.Validate(); err != nil {
+		return nil, err
+	}
+	return pin, nil
+}
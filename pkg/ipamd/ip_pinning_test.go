@@ -0,0 +1,100 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils"
+)
+
+func TestPodAddressPin(t *testing.T) {
+	c := &IPAMContext{}
+	c.setENITags(map[string]awsutils.TagMap{
+		"eni-1": {"acl-group": "payments"},
+		"eni-2": {"acl-group": "payments", "team": "a"},
+		"eni-3": {"acl-group": "other"},
+	})
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantCIDR    string
+		wantENIs    []string
+		wantErr     bool
+	}{
+		{name: "no annotations"},
+		{
+			name:        "CIDR",
+			annotations: map[string]string{podPinnedCIDRKey: "10.0.1.16/28"},
+			wantCIDR:    "10.0.1.16/28",
+		},
+		{
+			name:        "ENI tag",
+			annotations: map[string]string{podPinnedENITagKey: "acl-group=payments"},
+			wantENIs:    []string{"eni-1", "eni-2"},
+		},
+		{
+			name:        "CIDR and ENI tag",
+			annotations: map[string]string{podPinnedCIDRKey: "10.0.1.16/28", podPinnedENITagKey: "team=a"},
+			wantCIDR:    "10.0.1.16/28",
+			wantENIs:    []string{"eni-2"},
+		},
+		{
+			name:        "invalid CIDR",
+			annotations: map[string]string{podPinnedCIDRKey: "10.0.1.16"},
+			wantErr:     true,
+		},
+		{
+			name:        "IPv6 CIDR",
+			annotations: map[string]string{podPinnedCIDRKey: "2001:db8::/64"},
+			wantErr:     true,
+		},
+		{
+			name:        "tag without value",
+			annotations: map[string]string{podPinnedENITagKey: "acl-group"},
+			wantErr:     true,
+		},
+		{
+			name:        "no ENI with the tag",
+			annotations: map[string]string{podPinnedENITagKey: "acl-group=none"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default", Annotations: tt.annotations}}
+			pin, err := c.podAddressPin(pod)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			if tt.wantCIDR == "" && tt.wantENIs == nil {
+				assert.Nil(t, pin)
+				return
+			}
+			if tt.wantCIDR != "" {
+				assert.Equal(t, tt.wantCIDR, pin.CIDR.String())
+			} else {
+				assert.Nil(t, pin.CIDR)
+			}
+			assert.Equal(t, tt.wantENIs, pin.ENIIDs)
+		})
+	}
+}
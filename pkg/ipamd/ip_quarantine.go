@@ -0,0 +1,78 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+)
+
+// quarantineRequestHandler serves the quarantined IPs on GET. POST quarantines the IP of the `ip` query parameter for
+// the `period` duration, e.g. `30m`, with an optional `reason`, and DELETE puts it back in rotation.
+func quarantineRequestHandler(ipam *IPAMContext) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var response interface{}
+		var err error
+		switch r.Method {
+		case http.MethodGet:
+			response = ipam.dataStore.QuarantinedIPs()
+		case http.MethodPost:
+			response, err = quarantineIP(ipam, r)
+		case http.MethodDelete:
+			var released bool
+			released, err = ipam.dataStore.UnquarantineIP(r.URL.Query().Get("ip"))
+			response = map[string]bool{"Released": released}
+		default:
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		if errors.Is(err, datastore.ErrUnknownAddress) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		responseJSON, err := json.Marshal(response)
+		if err != nil {
+			log.Errorf("Failed to marshal quarantined IPs: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		logErr(w.Write(responseJSON))
+	}
+}
+
+func quarantineIP(ipam *IPAMContext, r *http.Request) (datastore.QuarantinedIP, error) {
+	values := r.URL.Query()
+	var period time.Duration
+	if s := values.Get("period"); s != "" {
+		var err error
+		if period, err = time.ParseDuration(s); err != nil {
+			return datastore.QuarantinedIP{}, fmt.Errorf("invalid period %q: %v", s, err)
+		}
+	}
+	reason := values.Get("reason")
+	if reason == "" {
+		reason = "quarantined through the introspection endpoint"
+	}
+	return ipam.dataStore.QuarantineIP(values.Get("ip"), period, reason)
+}
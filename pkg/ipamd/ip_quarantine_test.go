@@ -0,0 +1,60 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+)
+
+func TestQuarantineRequestHandler(t *testing.T) {
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP("10.0.0.1"), Mask: net.CIDRMask(32, 32)}, false))
+	mockContext := &IPAMContext{dataStore: ds}
+
+	request := func(method, url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		quarantineRequestHandler(mockContext)(w, httptest.NewRequest(method, url, nil))
+		return w
+	}
+
+	w := request(http.MethodPost, "/v1/quarantine?ip=10.0.0.1&period=30m&reason=dad")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var quarantined datastore.QuarantinedIP
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &quarantined))
+	assert.Equal(t, "dad", quarantined.Reason)
+	assert.WithinDuration(t, time.Now().Add(30*time.Minute), quarantined.Until, time.Minute)
+
+	w = request(http.MethodGet, "/v1/quarantine")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list []datastore.QuarantinedIP
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Len(t, list, 1)
+
+	assert.Equal(t, http.StatusOK, request(http.MethodDelete, "/v1/quarantine?ip=10.0.0.1").Code)
+	assert.Empty(t, ds.QuarantinedIPs())
+
+	assert.Equal(t, http.StatusNotFound, request(http.MethodPost, "/v1/quarantine?ip=10.0.0.2").Code)
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodPost, "/v1/quarantine?ip=10.0.0.1&period=soon").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, request(http.MethodPut, "/v1/quarantine").Code)
+}
@@ -32,16 +32,22 @@ import (
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/util/retry"
 
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/apis/crd/v1alpha1"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/eniconfig"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/lifecycle"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/netlinkwrapper"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/networkutils"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/paths"
 )
 
 // The package ipamd is a long running daemon which manages a warm pool of available IP addresses.
@@ -55,6 +61,9 @@ const (
 	nodeIPPoolReconcileInterval = 60 * time.Second
 	decreaseIPPoolInterval      = 30 * time.Second
 
+	// maxConcurrentENISetup bounds the number of ENIs set up at the same time during node init
+	maxConcurrentENISetup = 4
+
 	// ipReconcileCooldown is the amount of time that an IP address must wait until it can be added to the data store
 	// during reconciliation after being discovered on the EC2 instance metadata.
 	ipReconcileCooldown = 60 * time.Second
@@ -117,9 +126,8 @@ const (
 	// disableENIProvisioning is used to specify that ENI doesn't need to be synced during initializing a pod.
 	envDisableENIProvisioning = "DISABLE_NETWORK_RESOURCE_PROVISIONING"
 
-	// Specify where ipam should persist its current IP<->container allocations.
-	envBackingStorePath     = "AWS_VPC_K8S_CNI_BACKING_STORE"
-	defaultBackingStorePath = "/var/run/aws-node/ipam.json"
+	// Specify where ipam should persist its current IP<->container allocations. Defaults to ipam.json in the run dir.
+	envBackingStorePath = "AWS_VPC_K8S_CNI_BACKING_STORE"
 
 	// envEnablePodENI is used to attach a Trunk ENI to every node. Required in order to give Branch ENIs to pods.
 	envEnablePodENI = "ENABLE_POD_ENI"
@@ -165,6 +173,257 @@ const (
 	// The empty string one helps close a trace at pod shutdown where it looks like the pod still has its IP when the IP has been released
 	envAnnotatePodIP = "ANNOTATE_POD_IP"
 
+	// envExcludeSNATCIDRsConfigMap names a ConfigMap, as "<namespace>/<name>" or just "<name>" in kube-system, holding
+	// additional IPv4 CIDRs to exclude from SNAT under the excludeSNATCIDRsConfigMapKey key. ipamd polls the ConfigMap
+	// and reprograms the host iptables rules when the list changes, so exclusions (e.g. on-prem ranges reached over
+	// TGW/DX) can be updated without restarting the aws-node pods. The CIDRs are added on top of
+	// AWS_VPC_K8S_CNI_EXCLUDE_SNAT_CIDRS. Defaults to empty, which disables the feature.
+	envExcludeSNATCIDRsConfigMap = "AWS_VPC_K8S_CNI_EXCLUDE_SNAT_CIDRS_CONFIGMAP"
+
+	// excludeSNATCIDRsConfigMapKey is the data key in the SNAT exclusion ConfigMap, holding a comma or whitespace
+	// separated list of IPv4 CIDRs
+	excludeSNATCIDRsConfigMapKey = "excludeSNATCIDRs"
+
+	// snatCIDRsConfigMapKey is the data key in the SNAT exclusion ConfigMap holding the IPv4 CIDRs that are SNATed to
+	// the primary IP of the node even with AWS_VPC_K8S_CNI_EXTERNALSNAT, on top of AWS_VPC_K8S_CNI_SNAT_CIDRS
+	snatCIDRsConfigMapKey = "snatCIDRs"
+
+	// envNamespaceSNATConfigMap names a ConfigMap, as "<namespace>/<name>" or just "<name>" in kube-system, mapping
+	// namespaces to a secondary IP of the primary ENI under the namespaceSNATConfigMapKey key. The non-VPC traffic of
+	// the pods in a mapped namespace is SNATed to that IP instead of the primary IP of the node, so that it leaves
+	// through the Elastic IP associated with it. The IPs are never handed out to pods. Defaults to empty, which
+	// disables the feature.
+	envNamespaceSNATConfigMap = "AWS_VPC_K8S_CNI_NAMESPACE_SNAT_CONFIGMAP"
+
+	// namespaceSNATConfigMapKey is the data key in the namespace SNAT ConfigMap, holding a comma or whitespace separated
+	// list of <namespace>=<IPv4 address> entries
+	namespaceSNATConfigMapKey = "namespaceSNATIPs"
+
+	// envNamespaceRoutabilityClasses is a comma or whitespace separated list of <namespace>=<class> entries, giving the
+	// routability class, routable or non-routable, of the IPs of the pods of a namespace when the pods don't request
+	// one with the vpc.amazonaws.com/routability-class annotation. It only applies when
+	// AWS_VPC_K8S_CNI_NON_ROUTABLE_CIDRS is set. Defaults to empty, pods then take an IP of any class.
+	envNamespaceRoutabilityClasses = "AWS_VPC_K8S_CNI_NAMESPACE_ROUTABILITY_CLASSES"
+
+	defaultConfigMapNamespace = "kube-system"
+
+	// envExcludeEFAENIs is used to keep Elastic Fabric Adapter interfaces out of the pod IP pool. When set (the
+	// default), EFA ENIs are treated like unmanaged ENIs: no secondary IPs or prefixes are allocated on them and their
+	// addresses are never handed out to pods, so that they stay dedicated to the EFA device plugin.
+	envExcludeEFAENIs = "EXCLUDE_EFA_ENIS"
+
+	// envIPExhaustionNodeCondition is the type of a node condition that ipamd sets to True while the node is out of
+	// IP addresses, and back to False once addresses are available again. No condition is set when it is empty (the
+	// default). Setting it to NetworkUnavailable makes Kubernetes taint the node so that new pods go elsewhere.
+	envIPExhaustionNodeCondition = "IP_EXHAUSTION_NODE_CONDITION"
+
+	// envConfigFile is the path of an optional YAML file mapping env var names to values, usually mounted from a
+	// ConfigMap. The settings in reloadableConfigKeys are read from it before the environment and are reloaded while
+	// ipamd is running, so they can be changed without restarting the aws-node pods.
+	envConfigFile     = "AWS_VPC_CNI_CONFIG_FILE"
+	defaultConfigFile = "/etc/amazon-vpc-cni/config.yaml"
+
+	// envLogLevel and envExcludeSNATCIDRs are read by the logger and networkutils packages at startup, ipamd only
+	// needs them to apply config file changes
+	envLogLevel         = "AWS_VPC_K8S_CNI_LOGLEVEL"
+	envExcludeSNATCIDRs = "AWS_VPC_K8S_CNI_EXCLUDE_SNAT_CIDRS"
+
+	// envAnnotateNodeIPCapacity is used to annotate the node with its pod IP capacity and matching max pods, for
+	// provisioners like Karpenter that need accurate density estimates. Defaults to false.
+	envAnnotateNodeIPCapacity = "ANNOTATE_NODE_IP_CAPACITY"
+
+	// envPublishNodeAllocation is used to publish the ENIs, CIDRs and pod IPs of the node in the status of a
+	// NodeAllocation custom resource named after the node. Defaults to false.
+	envPublishNodeAllocation = "PUBLISH_NODE_ALLOCATION"
+
+	// envEnableSecurityGroupReconciliation is used to set the security groups of the secondary ENIs back to the ones
+	// of the ENIConfig with custom networking, or of the primary ENI, when they differ. Defaults to false, drifted
+	// ENIs are only counted.
+	envEnableSecurityGroupReconciliation = "ENABLE_SECURITY_GROUP_RECONCILIATION"
+
+	// envEnablePodIPPinning is used to let pods pin their IP address to a CIDR or to tagged ENIs with the
+	// vpc.amazonaws.com/pinned-cidr and vpc.amazonaws.com/pinned-eni-tag annotations. Defaults to false.
+	envEnablePodIPPinning = "ENABLE_POD_IP_PINNING"
+
+	// envDisableRouteRecovery is used to stop ipamd from rebuilding the pod IP allocations from the host routes to the
+	// pods when the checkpoint file is missing or corrupt, or CRI can't be queried. Defaults to false.
+	envDisableRouteRecovery = "DISABLE_ROUTE_RECOVERY"
+
+	// envEnableStaleRuleScrubber is used to periodically delete the ip rules and routes of pod IPs that are no longer
+	// assigned in the datastore. Defaults to false.
+	envEnableStaleRuleScrubber = "ENABLE_STALE_RULE_SCRUBBER"
+
+	// envStaleRuleScrubberDryRun is used to only log and count the stale ip rules and routes instead of deleting them.
+	// Defaults to false.
+	envStaleRuleScrubberDryRun = "STALE_RULE_SCRUBBER_DRY_RUN"
+
+	// envEnableMultiCardENIs is used to attach ENIs to all the network cards of instance types with more than one, like
+	// p4d and p5, and use them for pods. Defaults to false, in which case the ENIs on other cards are left alone.
+	envEnableMultiCardENIs = "ENABLE_MULTI_CARD_ENIS"
+
+	// envEnableCNIDNSResult is used to return the name servers of the VPC DHCP options set, or the ones of
+	// CNI_DNS_NAMESERVERS, in the DNS section of the CNI result. Defaults to false.
+	envEnableCNIDNSResult = "ENABLE_CNI_DNS_RESULT"
+
+	// envCNIDNSNameservers is a comma separated list of name servers to return in the CNI result instead of the ones of
+	// the VPC DHCP options set
+	envCNIDNSNameservers = "CNI_DNS_NAMESERVERS"
+
+	// envEnableIptablesTamperEvents is used to raise a warning event on the node when the host iptables rules were
+	// modified by another agent and had to be reprogrammed. Defaults to false, the metric is always updated.
+	envEnableIptablesTamperEvents = "ENABLE_IPTABLES_TAMPER_EVENTS"
+
+	// envEnableNAT64 is used to route the NAT64 prefix out of the primary ENI in IPv6 mode, so that pods can reach
+	// IPv4-only endpoints through DNS64 and a NAT64 gateway. Defaults to false.
+	envEnableNAT64 = "ENABLE_NAT64"
+
+	// envNAT64Prefix is the NAT64 prefix to route, defaults to the well-known prefix 64:ff9b::/96
+	envNAT64Prefix = "NAT64_PREFIX"
+
+	// envNAT64Gateway is the IPv6 address of the NAT64 gateway. Defaults to the IPv6 default gateway of the primary ENI,
+	// i.e. the VPC router, for NAT64 through the route table of the subnet.
+	envNAT64Gateway = "NAT64_GATEWAY"
+
+	// envEnableDedicatedENI lets pods annotated with vpc.amazonaws.com/dedicated-eni=true get an ENI of their own, moved
+	// into the pod network namespace. Defaults to false.
+	envEnableDedicatedENI = "ENABLE_DEDICATED_ENI"
+
+	// envEnablePodMulticast lets pods annotated with vpc.amazonaws.com/multicast=true get multicast and broadcast enabled
+	// on their veth. Defaults to false.
+	envEnablePodMulticast = "ENABLE_POD_MULTICAST"
+
+	// envEnablePodMTUOverride lets nodes and pods set with vpc.amazonaws.com/pod-mtu use their own pod MTU instead of
+	// the one of the CNI config. Defaults to false.
+	envEnablePodMTUOverride = "ENABLE_POD_MTU_OVERRIDE"
+
+	// envEnablePodEgressRoutes lets pods annotated with vpc.amazonaws.com/egress-routes send the traffic to some CIDRs
+	// through another ENI than the rest of their egress. Defaults to false.
+	envEnablePodEgressRoutes = "ENABLE_POD_EGRESS_ROUTES"
+
+	// envPrefixReservationTag makes ipamd take the IPv4 prefixes only from the free blocks of the prefix CIDR
+	// reservations of the ENI subnet that have this tag, as "<key>=<value>" or just "<key>" for any value, so that
+	// nothing else in the subnet fragments the space set aside for pods. Not set by default.
+	envPrefixReservationTag = "PREFIX_RESERVATION_TAG"
+
+	// envEnableDuplicateAddressDetection makes ipamd send an ARP probe, or an IPv6 neighbor solicitation, for the IP
+	// of a pod before returning it, and quarantine the IP and pick another one when another host answers for it.
+	// Defaults to false.
+	envEnableDuplicateAddressDetection = "ENABLE_DUPLICATE_ADDRESS_DETECTION"
+
+	// envDuplicateAddressDetectionTimeout is the number of milliseconds that the probes of a pod ADD wait for answers
+	// in total
+	envDuplicateAddressDetectionTimeout     = "DUPLICATE_ADDRESS_DETECTION_TIMEOUT_MS"
+	defaultDuplicateAddressDetectionTimeout = 100 * time.Millisecond
+
+	// envEnablePodIMDSBlock makes ipamd drop the traffic from the host veth of each pod it adds to the instance
+	// metadata service, except for the pods of the namespaces in envPodIMDSBlockAllowedNamespaces. Defaults to false.
+	envEnablePodIMDSBlock = "ENABLE_POD_IMDS_BLOCK"
+
+	// envPodIMDSBlockAllowedNamespaces is the comma separated list of the namespaces whose pods keep access to the
+	// instance metadata service when envEnablePodIMDSBlock is set
+	envPodIMDSBlockAllowedNamespaces = "POD_IMDS_BLOCK_ALLOWED_NAMESPACES"
+
+	// envEnablePodIngressAllowlist makes the pods with a host veth only accept the traffic forwarded from the CIDRs of
+	// envPodIngressAllowedCIDRs, and the replies to their own connections, as a baseline for the clusters without a
+	// network policy engine. Pods annotated with vpc.amazonaws.com/ingress-allowlist: "false" are exempt. Defaults to
+	// false.
+	envEnablePodIngressAllowlist = "ENABLE_POD_INGRESS_ALLOWLIST"
+
+	// envPodIngressAllowedCIDRs is the comma separated list of the CIDRs that the pods accept traffic from with
+	// envEnablePodIngressAllowlist. Defaults to the VPC CIDRs.
+	envPodIngressAllowedCIDRs = "POD_INGRESS_ALLOWED_CIDRS"
+
+	// envEnablePodConnectionLimits makes the host veth of the pods annotated with
+	// vpc.amazonaws.com/egress-connection-rate or vpc.amazonaws.com/egress-max-connections drop the new connections
+	// above these limits, so that a single pod can't fill the conntrack table of the node. Defaults to false.
+	envEnablePodConnectionLimits = "ENABLE_POD_CONNECTION_LIMITS"
+
+	// envConntrackPressureThreshold is the percentage of the conntrack table of the node in use above which ipamd
+	// raises a ConntrackTablePressure event on the node and applies the mitigations below. Defaults to 90.
+	envConntrackPressureThreshold     = "CONNTRACK_PRESSURE_THRESHOLD_PERCENT"
+	defaultConntrackPressureThreshold = 90
+
+	// envConntrackMaxLimit is the size up to which ipamd doubles nf_conntrack_max when the conntrack table is under
+	// pressure. Defaults to 0, which leaves nf_conntrack_max unchanged.
+	envConntrackMaxLimit = "CONNTRACK_MAX_LIMIT"
+
+	// envConntrackPressurePodMaxConnections is the vpc.amazonaws.com/egress-max-connections limit given to the pods
+	// created without one while the conntrack table is under pressure, with envEnablePodConnectionLimits. Defaults to
+	// 0, no limit.
+	envConntrackPressurePodMaxConnections = "CONNTRACK_PRESSURE_POD_MAX_CONNECTIONS"
+
+	// envEnableClusterMetricsAggregator makes the aws-node pods elect one of them, through a Lease in kube-system, to
+	// scrape the metrics of all the others and serve the cluster-wide pool metrics on /cluster-metrics of the metrics
+	// port. Defaults to false.
+	envEnableClusterMetricsAggregator = "ENABLE_CLUSTER_METRICS_AGGREGATOR"
+
+	// envEnableProgressiveScaleUp is used to allocate an ENI in the same pass as the IPs on the existing ENIs when
+	// WARM_IP_TARGET or MINIMUM_IP_TARGET need more IPs than they can hold, depending on the EC2 throttling, free ENI
+	// slots and subnet headroom. Defaults to false.
+	envEnableProgressiveScaleUp = "ENABLE_PROGRESSIVE_SCALE_UP"
+
+	// envStaticIPPoolCIDRs is the comma separated list of IPv4 CIDRs that the pod IPs of a node outside of EC2, like an
+	// EKS Hybrid node, are taken from, without any EC2 call. Unset by default.
+	envStaticIPPoolCIDRs = "STATIC_IP_POOL_CIDRS"
+
+	// envStaticIPPoolRoutableCIDRs is the comma separated list of IPv4 CIDRs that pods with a static IP pool reach
+	// without SNAT, in place of the VPC CIDRs. Defaults to the pool CIDRs and the subnet of the host interface.
+	envStaticIPPoolRoutableCIDRs = "STATIC_IP_POOL_ROUTABLE_CIDRS"
+
+	// envStaticIPPoolInterface is the name of the host interface that pods with a static IP pool reach the network
+	// through. Defaults to the interface of the IPv4 default route.
+	envStaticIPPoolInterface = "STATIC_IP_POOL_INTERFACE"
+
+	// envPoolStateImportFile is the path of a pool state exported by the /v1/pool-state endpoint of another node, whose
+	// pod IPs are reserved on the ENIs of this node in the same subnets on start. Unset by default.
+	envPoolStateImportFile = "POOL_STATE_IMPORT_FILE"
+
+	// envEnablePodNetworkMetrics is used to export the veth counters and conntrack flow counts of each pod on the
+	// metrics endpoint. Defaults to false.
+	envEnablePodNetworkMetrics = "ENABLE_POD_NETWORK_METRICS"
+
+	// envEnableDatastoreDebug is used to periodically check the datastore invariants and log the violations. Defaults
+	// to false.
+	envEnableDatastoreDebug = "ENABLE_DATASTORE_DEBUG"
+
+	// envEnableIMDSHopLimitFix is used to raise the IMDSv2 hop limit of the instance to 2 with the EC2 API when aws-node
+	// runs in a container network namespace that the instance metadata responses can't reach. Defaults to false.
+	envEnableIMDSHopLimitFix = "ENABLE_IMDS_HOP_LIMIT_FIX"
+
+	// envDelUnassignBatchInterval is the number of seconds that the EC2 unassign calls triggered by pod deletions are
+	// held back, so that they are made in batches. 0, the default, unassigns right away.
+	envDelUnassignBatchInterval     = "DEL_UNASSIGN_BATCH_INTERVAL"
+	defaultDelUnassignBatchInterval = 0
+
+	// envDelUnassignBatchSize is the number of pod deletions that flush a batch of EC2 unassign calls before the batch
+	// interval is over. 0 only flushes at the end of the interval.
+	envDelUnassignBatchSize     = "DEL_UNASSIGN_BATCH_SIZE"
+	defaultDelUnassignBatchSize = 50
+
+	// envENILimitQueueTimeout is the number of seconds that an AddNetwork request waits for a free IP when the node is
+	// at its ENI limit, before failing with a ResourceExhausted status. 0, the default, fails right away.
+	envENILimitQueueTimeout     = "ENI_LIMIT_QUEUE_TIMEOUT"
+	defaultENILimitQueueTimeout = 0
+
+	// envPoolDecisionLogSize is the number of warm pool decisions kept in the decision log served on
+	// /v1/pool-decisions. 0 disables the log.
+	envPoolDecisionLogSize     = "POOL_DECISION_LOG_SIZE"
+	defaultPoolDecisionLogSize = 500
+
+	// envWarmVethPoolSize is the number of veth pairs that ipamd keeps pre-plumbed for warm IPs, so that the CNI
+	// plugin only moves one into a new pod rather than creating it. Experimental, IPv4 only. 0, the default, disables
+	// the pool.
+	envWarmVethPoolSize = "WARM_VETH_POOL_SIZE"
+
+	// envMinimumReadyIPTarget is the number of assignable IPs that ipamd needs before it reports ready for the first
+	// time, so that pods aren't scheduled onto a node whose ADDs would fail. 0, the default, doesn't wait for IPs.
+	envMinimumReadyIPTarget = "MINIMUM_READY_IP_TARGET"
+
+	// envStartupTaintKey is the key of a NoSchedule taint that ipamd puts on the node at startup and removes once it
+	// reports ready, e.g. node.vpc.amazonaws.com/network-unavailable. The taint can also be registered by kubelet with
+	// --register-with-taints. No taint is managed when it is empty (the default).
+	envStartupTaintKey = "STARTUP_TAINT_KEY"
+
 	// aws error codes for insufficient IP address scenario
 	INSUFFICIENT_CIDR_BLOCKS    = "InsufficientCidrBlocks"
 	INSUFFICIENT_FREE_IP_SUBNET = "InsufficientFreeAddressesInSubnet"
@@ -172,6 +431,9 @@ const (
 
 var log = logger.Get()
 
+// poolLog logs the failures of the warm pool manager, which retries every few seconds, at most once a minute each
+var poolLog = logger.NewRateLimitedLogger(log, time.Minute)
+
 var (
 	ipamdErr = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
@@ -226,6 +488,146 @@ var (
 		},
 		[]string{"fn"},
 	)
+	addNetworkLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "awscni_add_network_latency_seconds",
+			Help:    "The time ipamd spends handling AddNetwork requests, by stage",
+			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+		},
+		[]string{"stage"},
+	)
+	staleRulesRemoved = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "awscni_stale_rules_removed_count",
+			Help: "The number of stale pod ip rules and routes removed, or found in dry-run mode",
+		},
+		[]string{"kind", "dry_run"},
+	)
+	iptablesTamperCnt = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "awscni_iptables_tamper_count",
+			Help: "The number of times the host iptables rules were found modified by another agent and reprogrammed",
+		},
+	)
+	routeTableConflicts = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "awscni_route_table_conflict_count",
+			Help: "The number of routes another agent added to the route table of an ENI, found when setting up the ENI",
+		},
+	)
+	prefixFragmentationFallbacks = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "awscni_prefix_fragmentation_fallback_count",
+			Help: "The number of times an ENI got secondary IPs instead of a prefix because its subnet had no free /28 block",
+		},
+	)
+	duplicateAddresses = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "awscni_duplicate_address_count",
+			Help: "The number of pod IPs that another host answered for, which were quarantined",
+		},
+	)
+	podIMDSBlockedPackets = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "awscni_pod_imds_blocked_packet_count",
+			Help: "The number of packets from pods to the instance metadata service that were dropped",
+		},
+	)
+	scaleUpDecisions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "awscni_scale_up_decision_count",
+			Help: "The number of progressive scale up decisions on whether to allocate an ENI after filling the existing ENIs",
+		},
+		[]string{"decision"},
+	)
+	hostVethCollisions = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "awscni_host_veth_collision_count",
+			Help: "The number of pods rejected because their host veth name was the same as the one of another pod",
+		},
+	)
+	sysctlDrift = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "awscni_sysctl_drift_count",
+			Help: "The number of times a kernel setting applied by ipamd was found changed and set back",
+		},
+		[]string{"setting"},
+	)
+	eniLinkRepairs = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "awscni_eni_link_repair_count",
+			Help: "The number of times the network of a secondary ENI was found broken and set up again",
+		},
+		[]string{"problem"},
+	)
+	allocationQueueLength = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "awscni_eni_limit_queue_length",
+			Help: "The number of AddNetwork requests waiting for a free IP while the node is at its ENI limit",
+		},
+	)
+	securityGroupDriftedENIs = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "awscni_security_group_drifted_enis",
+			Help: "The number of ENIs whose security groups differed from the configured ones at the last check",
+		},
+	)
+	securityGroupsReconciled = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "awscni_security_group_reconcile_count",
+			Help: "The number of times the security groups of an ENI were set back to the configured ones",
+		},
+	)
+	warmVethClaims = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "awscni_warm_veth_claim_count",
+			Help: "The number of pods that took a pre-plumbed veth pair (hit), or found none left (miss)",
+		},
+		[]string{"result"},
+	)
+	subnetFreePrefixes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "awscni_subnet_free_prefixes",
+			Help: "The estimated number of /28 prefixes that can still be assigned from the subnet new ENIs are created in",
+		},
+		[]string{"subnet"},
+	)
+	teardownQueueDepth = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "awscni_teardown_queue_depth",
+			Help: "The number of pod teardowns failed by the CNI plugin that ipamd retries",
+		},
+	)
+	teardownQueueOldestAge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "awscni_teardown_queue_oldest_age_seconds",
+			Help: "The time since the oldest pod teardown retried by ipamd was queued",
+		},
+	)
+	allocationQueueTimeouts = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "awscni_eni_limit_queue_timeout_count",
+			Help: "The number of queued AddNetwork requests that got no IP before their timeout",
+		},
+	)
+	conntrackEntries = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "awscni_conntrack_entries",
+			Help: "The number of entries of the conntrack table of the node",
+		},
+	)
+	conntrackMax = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "awscni_conntrack_max",
+			Help: "The size of the conntrack table of the node",
+		},
+	)
+	conntrackMaxRaised = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "awscni_conntrack_max_raised_count",
+			Help: "The number of times ipamd raised the size of the conntrack table under pressure",
+		},
+	)
 	prometheusRegistered = false
 )
 
@@ -248,19 +650,96 @@ type IPAMContext struct {
 	minimumIPTarget      int
 	warmPrefixTarget     int
 	primaryIP            map[string]string // primaryIP is a map from ENI ID to primary IP of that ENI
+	primaryIPLock        sync.Mutex        // primaryIPLock protects primaryIP, eniSubnets and eniLinks while ENIs are set up concurrently in nodeInit
+	eniSubnets           map[int]string    // eniSubnets is a map from ENI device number to the IPv4 subnet of that ENI
 	lastNodeIPPoolAction time.Time
 	lastDecreaseIPPool   time.Time
 	// reconcileCooldownCache keeps timestamps of the last time an IP address was unassigned from an ENI,
 	// so that we don't reconcile and add it back too quickly if IMDS lags behind reality.
-	reconcileCooldownCache    ReconcileCooldownCache
-	terminating               int32 // Flag to warn that the pod is about to shut down.
-	disableENIProvisioning    bool
-	enablePodENI              bool
-	myNodeName                string
-	enablePrefixDelegation    bool
-	lastInsufficientCidrError time.Time
-	enableManageUntaggedMode  bool
-	enablePodIPAnnotation     bool
+	reconcileCooldownCache     ReconcileCooldownCache
+	terminating                int32 // Flag to warn that the pod is about to shut down.
+	disableENIProvisioning     bool
+	enablePodENI               bool
+	myNodeName                 string
+	enablePrefixDelegation     bool
+	lastInsufficientCidrError  time.Time
+	enableManageUntaggedMode   bool
+	enablePodIPAnnotation      bool
+	excludeSNATCIDRsConfigMap  *types.NamespacedName
+	excludeEFAENIs             bool
+	readinessLock              sync.RWMutex
+	readinessReport            *ReadinessReport
+	ipExhaustionCondition      string
+	ipExhaustionLock           sync.Mutex // ipExhaustionLock protects ipExhausted, which is also set from AddNetwork
+	ipExhausted                bool
+	configFileSNATStale        int32 // Set when the config file changed and the SNAT exclusions have to be reapplied
+	annotateNodeCapacity       bool
+	publishedIPCapacity        int
+	enableNodeAllocation       bool
+	enableSGReconciliation     bool
+	nodeAllocationPublishedAt  time.Time
+	publishedNodeAllocation    *v1alpha1.NodeAllocationStatus // publishedNodeAllocation is nil until the first update
+	enablePodIPPinning         bool
+	eniTagsLock                sync.RWMutex
+	eniTags                    map[string]awsutils.TagMap
+	enableRouteRecovery        bool
+	podIPWatchers              *podIPWatchers
+	enableStaleRuleScrubber    bool
+	staleRuleScrubberDryRun    bool
+	enableCNIDNSResult         bool
+	dnsConfigLock              sync.RWMutex
+	dnsConfig                  awsutils.VPCDNSConfig
+	namespaceSNATConfigMap     *types.NamespacedName
+	namespaceSNATLock          sync.RWMutex
+	namespaceSNATIPs           map[string]string // namespaceSNATIPs maps namespaces to the IP their pods are SNATed to
+	hostIptablesLock           sync.Mutex        // hostIptablesLock serializes the updates of the host iptables rules
+	nonRoutableCIDRs           []net.IPNet
+	namespaceClasses           map[string]datastore.RoutabilityClass
+	eniLinks                   map[string]eniLink
+	minimumReadyIPTarget       int
+	startupTaintKey            string
+	capabilityReport           *CapabilityReport
+	enableIptablesTamperEvents bool
+	enableNAT64                bool
+	trunkFullLock              sync.Mutex // trunkFullLock protects trunkFull, which is also set from AddNetwork
+	trunkFull                  bool
+	enableDedicatedENI         bool
+	dedicatedENILock           sync.Mutex // dedicatedENILock serializes the allocation and release of dedicated ENIs
+	dedicatedENIs              map[datastore.IPAMMetadata]*dedicatedENI
+	enablePodMulticast         bool
+	enablePodMTUOverride       bool
+	enablePodEgressRoutes      bool
+	prefixReservationTagKey    string // prefixReservationTagKey is the tag of the prefix reservations to use, if any
+	prefixReservationTagValue  string
+	duplicateAddressTimeout    time.Duration // duplicateAddressTimeout is the probe budget of a pod ADD, 0 if disabled
+	enablePodIMDSBlock         bool
+	imdsAllowedNamespaces      map[string]bool // imdsAllowedNamespaces are the namespaces not blocked by enablePodIMDSBlock
+	enablePodIngressAllowlist  bool
+	enablePodConnectionLimits  bool
+	conntrackPressurePodConns  int   // conntrackPressurePodConns is the max connections of the pods created under pressure
+	conntrackPressure          int32 // Set while the conntrack table is above conntrackPressureThreshold
+	conntrackPressureThreshold int
+	conntrackMaxLimit          int
+	eniMTU                     int // eniMTU is the MTU of the ENIs, which no pod MTU can exceed
+	health                     healthState
+	enableDatastoreDebug       bool
+	enableProgressiveScaleUp   bool
+	staticIPPool               bool
+	poolStateImportFile        string
+	poolStateImportDeadline    time.Time // poolStateImportDeadline is when the unclaimed imported IPs are released
+	lifecycleWatcher           *lifecycle.Watcher
+	delUnassignBatcher         *delUnassignBatcher // delUnassignBatcher is nil when the DelNetwork unassigns aren't batched
+	allocationQueue            *allocationQueue    // allocationQueue is nil when AddNetwork doesn't wait at the ENI limit
+	poolDecisions              *poolDecisionLog    // poolDecisions is nil when the decision log is disabled
+	notOwnedENIsLock           sync.RWMutex
+	notOwnedENIs               map[string]bool // notOwnedENIs are the ENIs that FreeENI refused to free, kept out of the pool
+	warmVethPoolSize           int
+	warmVethLock               sync.Mutex           // warmVethLock serializes the warm veth claims and pool snapshots
+	warmVethClaimedAt          map[string]time.Time // warmVethClaimedAt maps the claimed warm veths to their claim time
+	teardownQueue              *teardownQueue
+	subnetPrefixesLock         sync.RWMutex
+	subnetPrefixes             *awsutils.SubnetPrefixAvailability // subnetPrefixes is nil until the subnet is probed
+	clusterMetrics             *clusterMetricsAggregator          // clusterMetrics is nil when the aggregator mode is disabled
 }
 
 // setUnmanagedENIs will rebuild the set of ENI IDs for ENIs tagged as "no_manage"
@@ -339,6 +818,28 @@ func prometheusRegister() {
 		prometheus.MustRegister(addIPCnt)
 		prometheus.MustRegister(delIPCnt)
 		prometheus.MustRegister(podENIErr)
+		prometheus.MustRegister(addNetworkLatency)
+		prometheus.MustRegister(staleRulesRemoved)
+		prometheus.MustRegister(iptablesTamperCnt)
+		prometheus.MustRegister(routeTableConflicts)
+		prometheus.MustRegister(prefixFragmentationFallbacks)
+		prometheus.MustRegister(duplicateAddresses)
+		prometheus.MustRegister(podIMDSBlockedPackets)
+		prometheus.MustRegister(sysctlDrift)
+		prometheus.MustRegister(eniLinkRepairs)
+		prometheus.MustRegister(hostVethCollisions)
+		prometheus.MustRegister(scaleUpDecisions)
+		prometheus.MustRegister(allocationQueueLength)
+		prometheus.MustRegister(allocationQueueTimeouts)
+		prometheus.MustRegister(securityGroupDriftedENIs)
+		prometheus.MustRegister(securityGroupsReconciled)
+		prometheus.MustRegister(warmVethClaims)
+		prometheus.MustRegister(teardownQueueDepth)
+		prometheus.MustRegister(subnetFreePrefixes)
+		prometheus.MustRegister(teardownQueueOldestAge)
+		prometheus.MustRegister(conntrackEntries)
+		prometheus.MustRegister(conntrackMax)
+		prometheus.MustRegister(conntrackMaxRaised)
 		prometheusRegistered = true
 	}
 }
@@ -355,6 +856,12 @@ func containsInsufficientCIDRsOrSubnetIPs(err error) bool {
 	return false
 }
 
+// containsInsufficientCidrBlocks returns whether the subnet has free IPs left, but no contiguous /28 block for a prefix
+func containsInsufficientCidrBlocks(err error) bool {
+	var awsErr awserr.Error
+	return errors.As(err, &awsErr) && awsErr.Code() == INSUFFICIENT_CIDR_BLOCKS
+}
+
 // inInsufficientCidrCoolingPeriod checks whether IPAMD is in insufficientCidrErrorCooldown
 func (c *IPAMContext) inInsufficientCidrCoolingPeriod() bool {
 	return time.Since(c.lastInsufficientCidrError) <= insufficientCidrErrorCooldown
@@ -375,24 +882,94 @@ func New(rawK8SClient client.Client, cachedK8SClient client.Client) (*IPAMContex
 	c.enableIPv6 = isIPv6Enabled()
 
 	c.disableENIProvisioning = disablingENIProvisioning()
+	c.poolStateImportFile = os.Getenv(envPoolStateImportFile)
 
-	client, err := awsutils.New(c.useCustomNetworking, c.disableENIProvisioning, c.enableIPv4, c.enableIPv6)
+	staticProvider, err := newStaticIPPoolProvider(netlinkwrapper.NewNetLink(), os.Getenv(envNodeName))
+	if err != nil {
+		return nil, errors.Wrap(err, "ipamd: invalid static IP pool")
+	}
+	var client *awsutils.EC2InstanceMetadataCache
+	if staticProvider != nil {
+		log.Infof("Using the static IP pool of %d addresses from %s", staticProvider.PoolSize(), envStaticIPPoolCIDRs)
+		// The pool never grows or shrinks, so there is nothing to provision
+		c.staticIPPool = true
+		c.disableENIProvisioning = true
+		client, err = awsutils.NewWithProvider(staticProvider, c.useCustomNetworking, c.disableENIProvisioning, c.enableIPv4, c.enableIPv6)
+	} else {
+		client, err = awsutils.New(c.useCustomNetworking, c.disableENIProvisioning, c.enableIPv4, c.enableIPv6)
+		if err != nil && c.fixIMDSHopLimit(context.TODO(), err) {
+			client, err = awsutils.New(c.useCustomNetworking, c.disableENIProvisioning, c.enableIPv4, c.enableIPv6)
+		}
+	}
 	if err != nil {
 		return nil, errors.Wrap(err, "ipamd: can not initialize with AWS SDK interface")
 	}
 	c.awsClient = client
+	c.lifecycleWatcher = lifecycle.NewWatcher(client, lifecycleRefreshInterval)
 
 	c.primaryIP = make(map[string]string)
 	c.reconcileCooldownCache.cache = make(map[string]time.Time)
+	c.reloadConfigFile()
 	//WARM and Min IP/Prefix targets are ignored in IPv6 mode
 	c.warmENITarget = getWarmENITarget()
 	c.warmIPTarget = getWarmIPTarget()
 	c.minimumIPTarget = getMinimumIPTarget()
 	c.warmPrefixTarget = getWarmPrefixTarget()
+	c.minimumReadyIPTarget = getMinimumReadyIPTarget()
 
 	c.enablePodENI = enablePodENI()
 	c.enableManageUntaggedMode = enableManageUntaggedMode()
 	c.enablePodIPAnnotation = enablePodIPAnnotation()
+	c.excludeSNATCIDRsConfigMap = excludeSNATCIDRsConfigMap()
+	c.excludeEFAENIs = excludeEFAENIs()
+	c.ipExhaustionCondition = ipExhaustionNodeCondition()
+	c.annotateNodeCapacity = enableNodeCapacityAnnotation()
+	c.enableNodeAllocation = enableNodeAllocation()
+	c.enableSGReconciliation = enableSecurityGroupReconciliation()
+	c.warmVethPoolSize = getWarmVethPoolSize()
+	c.warmVethClaimedAt = make(map[string]time.Time)
+	c.enablePodIPPinning = enablePodIPPinning()
+	c.enableRouteRecovery = !disableRouteRecovery()
+	c.podIPWatchers = newPodIPWatchers()
+	c.enableStaleRuleScrubber = enableStaleRuleScrubber()
+	c.staleRuleScrubberDryRun = staleRuleScrubberDryRun()
+	c.enableCNIDNSResult = enableCNIDNSResult()
+	c.namespaceSNATConfigMap = namespaceSNATConfigMap()
+	c.nonRoutableCIDRs = nonRoutableCIDRs()
+	c.namespaceClasses = parseNamespaceRoutabilityClasses(os.Getenv(envNamespaceRoutabilityClasses))
+	c.enableIptablesTamperEvents = enableIptablesTamperEvents()
+	c.enableNAT64 = enableNAT64()
+	c.enableDedicatedENI = enableDedicatedENI()
+	c.enablePodMulticast = enablePodMulticast()
+	c.enablePodMTUOverride = enablePodMTUOverride()
+	c.enablePodEgressRoutes = enablePodEgressRoutes()
+	c.prefixReservationTagKey, c.prefixReservationTagValue = prefixReservationTag()
+	if enableDuplicateAddressDetection() {
+		c.duplicateAddressTimeout = getDuplicateAddressDetectionTimeout()
+	}
+	c.enablePodIMDSBlock = enablePodIMDSBlock()
+	c.imdsAllowedNamespaces = podIMDSBlockAllowedNamespaces()
+	c.enablePodIngressAllowlist = enablePodIngressAllowlist()
+	c.enablePodConnectionLimits = enablePodConnectionLimits()
+	c.conntrackPressureThreshold = getConntrackPressureThreshold()
+	c.conntrackMaxLimit = getNonNegativeIntEnv(envConntrackMaxLimit)
+	c.conntrackPressurePodConns = getNonNegativeIntEnv(envConntrackPressurePodMaxConnections)
+	if enableClusterMetricsAggregator() {
+		c.clusterMetrics = &clusterMetricsAggregator{}
+	}
+	c.eniMTU = networkutils.GetEthernetMTU("")
+	c.enableDatastoreDebug = enableDatastoreDebug()
+	c.enableProgressiveScaleUp = enableProgressiveScaleUp()
+	if interval := getDelUnassignBatchInterval(); interval > 0 {
+		c.delUnassignBatcher = newDelUnassignBatcher(interval, getDelUnassignBatchSize(), c.flushReleasedCidrs)
+	}
+	if timeout := getENILimitQueueTimeout(); timeout > 0 {
+		c.allocationQueue = newAllocationQueue(timeout)
+	}
+	if size := getPoolDecisionLogSize(); size > 0 {
+		c.poolDecisions = newPoolDecisionLog(size, datastore.NewJSONFile(paths.PoolDecisionLog()))
+	}
+	c.teardownQueue = newTeardownQueue(datastore.NewJSONFile(paths.TeardownQueue()))
 
 	err = c.awsClient.FetchInstanceTypeLimits()
 	if err != nil {
@@ -407,9 +984,17 @@ func New(rawK8SClient client.Client, cachedK8SClient client.Client) (*IPAMContex
 	}
 
 	c.awsClient.InitCachedPrefixDelegation(c.enablePrefixDelegation)
+	c.awsClient.InitCachedMultiCardENIs(enableMultiCardENIs())
 	c.myNodeName = os.Getenv("MY_NODE_NAME")
+	c.startupTaintKey = os.Getenv(envStartupTaintKey)
+	c.applyStartupTaint(context.TODO())
+	c.updateWarmTargetsFromNode(context.TODO())
 	checkpointer := datastore.NewJSONFile(dsBackingStorePath())
 	c.dataStore = datastore.NewDataStore(log, checkpointer, c.enablePrefixDelegation)
+	c.dataStore.SetNonRoutableCIDRs(c.nonRoutableCIDRs)
+	if c.enableRouteRecovery {
+		c.dataStore.SetAllocationRecovery(c.podRouteAllocations)
+	}
 
 	err = c.nodeInit()
 	if err != nil {
@@ -420,16 +1005,22 @@ func New(rawK8SClient client.Client, cachedK8SClient client.Client) (*IPAMContex
 
 	// retrieve security groups
 	if c.enableIPv4 && !c.disableENIProvisioning {
-		err = c.awsClient.RefreshSGIDs(mac)
+		err = c.awsClient.RefreshSGIDs(awsutils.WithCaller(context.Background(), awsutils.CallerStartup), mac)
 		if err != nil {
 			return nil, err
 		}
 
 		// Refresh security groups and VPC CIDR blocks in the background
 		// Ignoring errors since we will retry in 30s
-		go wait.Forever(func() { _ = c.awsClient.RefreshSGIDs(mac) }, 30*time.Second)
+		ctx := awsutils.WithCaller(context.Background(), awsutils.CallerReconciler)
+		go wait.Forever(func() { _ = c.awsClient.RefreshSGIDs(ctx, mac) }, 30*time.Second)
+		go wait.Forever(func() { c.reconcileSecurityGroups(ctx) }, securityGroupReconcileInterval)
 	}
 
+	c.initCNIDNSResult()
+	if enablePodNetworkMetrics() {
+		prometheus.MustRegister(&podNetworkCollector{ipam: c})
+	}
 	return c, nil
 }
 
@@ -438,12 +1029,14 @@ func (c *IPAMContext) nodeInit() error {
 	defer ipamdActionsInprogress.WithLabelValues("nodeInit").Sub(float64(1))
 	var err error
 	var vpcV4CIDRs []string
-	ctx := context.TODO()
+	ctx := awsutils.WithCaller(context.TODO(), awsutils.CallerStartup)
 
 	log.Debugf("Start node init")
 
 	primaryV4IP := c.awsClient.GetLocalIPv4()
 	err = c.initENIAndIPLimits()
+	// The namespace SNAT IPs have to be known before the IPs of the ENIs are added to the datastore
+	c.loadNamespaceSNATIPs(ctx)
 	if c.enableIPv4 {
 		//Subnets currently will have both v4 and v6 CIDRs. Once EC2 launches v6 only Subnets, that will no longer
 		//be true and so it is safe (and only required) to get the v4 CIDR info only when IPv4 mode is enabled.
@@ -459,7 +1052,25 @@ func (c *IPAMContext) nodeInit() error {
 		return errors.Wrap(err, "ipamd init: failed to set up host network")
 	}
 
-	metadataResult, err := c.awsClient.DescribeAllENIs()
+	if err := c.networkClient.SetupPodIMDSBlock(c.enablePodIMDSBlock, c.enableIPv6); err != nil {
+		return errors.Wrap(err, "ipamd init: failed to set up the pod IMDS block")
+	}
+	if err := c.setupPodIngressAllowlist(vpcV4CIDRs); err != nil {
+		return errors.Wrap(err, "ipamd init: failed to set up the pod ingress allowlist")
+	}
+	if err := c.networkClient.SetupPodConnectionLimits(c.enablePodConnectionLimits, c.enableIPv6); err != nil {
+		return errors.Wrap(err, "ipamd init: failed to set up the pod connection limits")
+	}
+
+	if c.enableNAT64 {
+		if !c.enableIPv6 {
+			log.Warnf("Ignoring %s, NAT64 is only used in IPv6 mode", envEnableNAT64)
+		} else if err := c.setupNAT64Route(ctx); err != nil {
+			return errors.Wrap(err, "ipamd init: failed to set up the NAT64 route")
+		}
+	}
+
+	metadataResult, err := c.awsClient.DescribeAllENIs(ctx)
 	if err != nil {
 		return errors.Wrap(err, "ipamd init: failed to retrieve attached ENIs info")
 	}
@@ -467,43 +1078,12 @@ func (c *IPAMContext) nodeInit() error {
 	log.Debugf("DescribeAllENIs success: ENIs: %d, tagged: %d", len(metadataResult.ENIMetadata), len(metadataResult.TagMap))
 	c.awsClient.SetCNIUnmanagedENIs(metadataResult.MultiCardENIIDs)
 	c.setUnmanagedENIs(metadataResult.TagMap)
+	c.setENITags(metadataResult.TagMap)
+	c.loadDedicatedENIs(metadataResult.ENIMetadata, metadataResult.TagMap)
 	enis := c.filterUnmanagedENIs(metadataResult.ENIMetadata)
 
-	for _, eni := range enis {
-		log.Debugf("Discovered ENI %s, trying to set it up", eni.ENIID)
-
-		isTrunkENI := eni.ENIID == metadataResult.TrunkENI
-		isEFAENI := metadataResult.EFAENIs[eni.ENIID]
-		if !isTrunkENI && !c.disableENIProvisioning {
-			if err := c.awsClient.TagENI(eni.ENIID, metadataResult.TagMap[eni.ENIID]); err != nil {
-				return errors.Wrapf(err, "ipamd init: failed to tag managed ENI %v", eni.ENIID)
-			}
-		}
-
-		// Retry ENI sync
-		retry := 0
-		for {
-			retry++
-			if err = c.setupENI(eni.ENIID, eni, isTrunkENI, isEFAENI); err == nil {
-				log.Infof("ENI %s set up.", eni.ENIID)
-				break
-			}
-
-			if retry > maxRetryCheckENI {
-				log.Warnf("Reached max retry: Unable to discover attached IPs for ENI from metadata service (attempted %d/%d): %v", retry, maxRetryCheckENI, err)
-				ipamdErrInc("waitENIAttachedMaxRetryExceeded")
-				break
-			}
-
-			log.Warnf("Error trying to set up ENI %s: %v", eni.ENIID, err)
-			if strings.Contains(err.Error(), "setupENINetwork: failed to find the link which uses MAC address") {
-				// If we can't find the matching link for this MAC address, there is no point in retrying for this ENI.
-				log.Debug("Unable to match link for this ENI, going to the next one.")
-				break
-			}
-			log.Debugf("Unable to discover IPs for this ENI yet (attempt %d/%d)", retry, maxRetryCheckENI)
-			time.Sleep(eniAttachTime)
-		}
+	if err := c.setupENIsOnInit(ctx, enis, metadataResult); err != nil {
+		return err
 	}
 
 	if err := c.dataStore.ReadBackingStore(c.enableIPv6); err != nil {
@@ -523,16 +1103,25 @@ func (c *IPAMContext) nodeInit() error {
 	if c.enablePrefixDelegation {
 		//During upgrade or if prefix delgation knob is disabled to enabled then we
 		//might have secondary IPs attached to ENIs so doing a cleanup if not used before moving on
-		c.tryUnassignIPsFromENIs()
+		c.tryUnassignIPsFromENIs(ctx)
 	} else {
 		//When prefix delegation knob is enabled to disabled then we might
 		//have unused prefixes attached to the ENIs so need to cleanup
-		c.tryUnassignPrefixesFromENIs()
+		c.tryUnassignPrefixesFromENIs(ctx)
+	}
+
+	if c.poolStateImportFile != "" {
+		c.importPoolState(ctx, enis)
 	}
 
 	if err = c.configureIPRulesForPods(); err != nil {
 		return err
 	}
+	if c.namespaceSNATConfigMap != nil && c.networkClient.SetPodSNATIPs(c.podSNATIPs()) {
+		if err := c.updateHostIptablesRules(vpcV4CIDRs); err != nil {
+			log.Warnf("Failed to program the namespace SNAT rules: %v", err)
+		}
+	}
 	// Spawning updateCIDRsRulesOnChange go-routine
 	go wait.Forever(func() {
 		vpcV4CIDRs = c.updateCIDRsRulesOnChange(vpcV4CIDRs)
@@ -573,13 +1162,14 @@ func (c *IPAMContext) nodeInit() error {
 
 	if !c.disableENIProvisioning {
 		// For a new node, attach Cidrs (secondary ips/prefixes)
-		increasedPool, err := c.tryAssignCidrs()
+		increasedPool, err := c.tryAssignCidrs(ctx)
 		if err == nil && increasedPool {
 			c.updateLastNodeIPPoolAction()
 		} else if err != nil {
 			if containsInsufficientCIDRsOrSubnetIPs(err) {
 				log.Errorf("Unable to attach IPs/Prefixes for the ENI, subnet doesn't seem to have enough IPs/Prefixes. Consider using new subnet or carve a reserved range using create-subnet-cidr-reservation")
 				c.lastInsufficientCidrError = time.Now()
+				c.reportIPExhaustion(ipExhaustionReasonSubnet, err.Error())
 				return nil
 			}
 			return err
@@ -617,19 +1207,74 @@ func (c *IPAMContext) updateCIDRsRulesOnChange(oldVPCCIDRs []string) []string {
 		return oldVPCCIDRs
 	}
 
+	snatChanged := c.updateExcludeSNATCIDRsFromConfigMap(context.TODO())
+	if c.updateExcludeSNATCIDRsFromConfigFile() {
+		snatChanged = true
+	}
+	if c.updateNamespaceSNATFromConfigMap(context.TODO()) {
+		snatChanged = true
+	}
+
 	old := sets.NewString(oldVPCCIDRs...)
 	new := sets.NewString(newVPCCIDRs...)
-	if !old.Equal(new) {
-		primaryIP := c.awsClient.GetLocalIPv4()
-		err = c.networkClient.UpdateHostIptablesRules(newVPCCIDRs, c.awsClient.GetPrimaryENImac(), &primaryIP, c.enableIPv4,
-			c.enableIPv6)
+	if !old.Equal(new) || snatChanged {
+		err = c.updateHostIptablesRules(newVPCCIDRs)
 		if err != nil {
 			log.Warnf("unable to update host iptables rules for VPC CIDRs due to error: %v", err)
 		}
+	} else {
+		c.repairHostIptablesRules(newVPCCIDRs)
 	}
 	return newVPCCIDRs
 }
 
+// updateHostIptablesRules reprograms the host iptables rules. It can be called from AddNetwork and DelNetwork, for
+// the namespace SNAT rules, so the updates are serialized.
+func (c *IPAMContext) updateHostIptablesRules(vpcCIDRs []string) error {
+	c.hostIptablesLock.Lock()
+	defer c.hostIptablesLock.Unlock()
+	primaryIP := c.awsClient.GetLocalIPv4()
+	err := c.networkClient.UpdateHostIptablesRules(vpcCIDRs, c.awsClient.GetPrimaryENImac(), &primaryIP, c.enableIPv4,
+		c.enableIPv6)
+	c.setIptablesHealth(err)
+	return err
+}
+
+// updateExcludeSNATCIDRsFromConfigMap reads the SNAT exclusion ConfigMap, if configured, and hands the excluded CIDRs
+// and the ones SNATed despite external SNAT to the network client. It returns true if the host iptables rules need to
+// be reprogrammed.
+func (c *IPAMContext) updateExcludeSNATCIDRsFromConfigMap(ctx context.Context) bool {
+	if c.excludeSNATCIDRsConfigMap == nil {
+		return false
+	}
+	var cidrs, snatCIDRs []string
+	var configMap corev1.ConfigMap
+	err := c.rawK8SClient.Get(ctx, *c.excludeSNATCIDRsConfigMap, &configMap)
+	if err != nil {
+		if !k8serror.IsNotFound(err) {
+			log.Warnf("skipping periodic update to SNAT exclusions, failed to get ConfigMap %s: %v",
+				c.excludeSNATCIDRsConfigMap.String(), err)
+			ipamdErrInc("updateExcludeSNATCIDRsFromConfigMap")
+			return false
+		}
+		// A deleted ConfigMap removes all dynamic exclusions
+		log.Debugf("SNAT exclusion ConfigMap %s not found", c.excludeSNATCIDRsConfigMap.String())
+	} else {
+		cidrs = networkutils.ParseExcludeSNATCIDRs(configMap.Data[excludeSNATCIDRsConfigMapKey])
+		snatCIDRs = networkutils.ParseExcludeSNATCIDRs(configMap.Data[snatCIDRsConfigMapKey])
+	}
+
+	changed := c.networkClient.SetDynamicExcludeSNATCIDRs(cidrs)
+	if changed {
+		log.Infof("SNAT exclusions from ConfigMap %s changed to %v", c.excludeSNATCIDRsConfigMap.String(), cidrs)
+	}
+	if c.networkClient.SetDynamicSNATCIDRs(snatCIDRs) {
+		log.Infof("SNAT CIDRs from ConfigMap %s changed to %v", c.excludeSNATCIDRsConfigMap.String(), snatCIDRs)
+		changed = true
+	}
+	return changed
+}
+
 func (c *IPAMContext) updateIPStats(unmanaged int) {
 	ipMax.Set(float64(c.maxIPsPerENI * (c.maxENI - unmanaged)))
 	enisMax.Set(float64(c.maxENI - unmanaged))
@@ -640,34 +1285,44 @@ func (c *IPAMContext) StartNodeIPPoolManager() {
 	if c.enableIPv6 {
 		//Nothing to do in IPv6 Mode. IPv6 is only supported in Prefix delegation mode
 		//and VPC CNI will only attach one V6 Prefix.
+		c.setDatastoreReconciled()
 		return
 	}
 	sleepDuration := ipPoolMonitorInterval / 2
 	ctx := context.Background()
 	for {
+		c.reloadConfigFile()
+		c.updateWarmTargetsFromNode(ctx)
 		if !c.disableENIProvisioning {
 			time.Sleep(sleepDuration)
 			c.updateIPPoolIfRequired(ctx)
 		}
 		time.Sleep(sleepDuration)
-		c.nodeIPPoolReconcile(ctx, nodeIPPoolReconcileInterval)
+		c.nodeIPPoolReconcile(awsutils.WithCaller(ctx, awsutils.CallerReconciler), nodeIPPoolReconcileInterval)
+		c.clearIPExhaustionIfRecovered()
+		c.releaseUnclaimedPoolStateImports()
+		c.replenishWarmVeths()
+		c.publishPodCapacity(ctx)
+		c.publishNodeAllocation(ctx)
+		c.syncTrunkBranchENIs(ctx)
+		c.reclaimDedicatedENIs(awsutils.WithCaller(ctx, awsutils.CallerBranchENI))
 	}
 }
 
 func (c *IPAMContext) updateIPPoolIfRequired(ctx context.Context) {
 	c.askForTrunkENIIfNeeded(ctx)
 	if c.isDatastorePoolTooLow() {
-		c.increaseDatastorePool(ctx)
+		c.increaseDatastorePool(awsutils.WithCaller(ctx, awsutils.CallerScaleUp))
 	} else if c.isDatastorePoolTooHigh() {
-		c.decreaseDatastorePool(decreaseIPPoolInterval)
+		c.decreaseDatastorePool(awsutils.WithCaller(ctx, awsutils.CallerScaleDown), decreaseIPPoolInterval)
 	}
 	if c.shouldRemoveExtraENIs() {
-		c.tryFreeENI()
+		c.tryFreeENI(awsutils.WithCaller(ctx, awsutils.CallerScaleDown))
 	}
 }
 
 // decreaseDatastorePool runs every `interval` and attempts to return unused ENIs and IPs
-func (c *IPAMContext) decreaseDatastorePool(interval time.Duration) {
+func (c *IPAMContext) decreaseDatastorePool(ctx context.Context, interval time.Duration) {
 	ipamdActionsInprogress.WithLabelValues("decreaseDatastorePool").Add(float64(1))
 	defer ipamdActionsInprogress.WithLabelValues("decreaseDatastorePool").Sub(float64(1))
 
@@ -679,7 +1334,10 @@ func (c *IPAMContext) decreaseDatastorePool(interval time.Duration) {
 	}
 
 	log.Debugf("Starting to decrease Datastore pool")
-	c.tryUnassignCidrsFromAll()
+	decision := c.newPoolDecision(poolOperationDecrease)
+	defer c.recordPoolDecision(decision)
+	c.tryUnassignCidrsFromAll(ctx)
+	decision.Action = c.poolChangeAction(decision, "released")
 
 	c.lastDecreaseIPPool = now
 	c.lastNodeIPPoolAction = now
@@ -689,29 +1347,38 @@ func (c *IPAMContext) decreaseDatastorePool(interval time.Duration) {
 }
 
 // tryFreeENI always tries to free one ENI
-func (c *IPAMContext) tryFreeENI() {
-	if c.isTerminating() || c.isNodeNonSchedulable() {
-		log.Debug("AWS CNI is terminating, not detaching any ENIs")
+func (c *IPAMContext) tryFreeENI(ctx context.Context) {
+	decision := c.newPoolDecision(poolOperationFreeENI)
+	defer c.recordPoolDecision(decision)
+	if reason := c.eniDetachBlocker(ctx); reason != "" {
+		log.Debugf("Not detaching any ENIs: %s", reason)
+		decision.Reason = reason
 		return
 	}
 
 	eni := c.dataStore.RemoveUnusedENIFromStore(c.warmIPTarget, c.minimumIPTarget, c.warmPrefixTarget)
 	if eni == "" {
+		decision.Reason = "no ENI can be freed without going below the warm targets"
 		return
 	}
 
 	log.Debugf("Start freeing ENI %s", eni)
-	err := c.awsClient.FreeENI(eni)
+	decision.Action = "freed ENI " + eni
+	err := c.awsClient.FreeENI(ctx, eni)
 	if err != nil {
 		ipamdErrInc("decreaseIPPoolFreeENIFailed")
 		log.Errorf("Failed to free ENI %s, err: %v", eni, err)
+		decision.Error = err.Error()
+		if errors.Is(err, awsutils.ErrENINotOwned) {
+			c.markENINotOwned(eni)
+		}
 		return
 	}
 }
 
 // tryUnassignIPsorPrefixesFromAll determines if there are IPs to free when we have extra IPs beyond the target and warmIPTargetDefined
 // is enabled, deallocate extra IP addresses
-func (c *IPAMContext) tryUnassignCidrsFromAll() {
+func (c *IPAMContext) tryUnassignCidrsFromAll(ctx context.Context) {
 
 	_, over, warmTargetDefined := c.datastoreTargetState()
 
@@ -755,7 +1422,7 @@ func (c *IPAMContext) tryUnassignCidrsFromAll() {
 			}
 
 			// Deallocate Cidrs from the instance if they aren't used by pods.
-			c.DeallocCidrs(eniID, deletedCidrs)
+			c.DeallocCidrs(ctx, eniID, deletedCidrs)
 		}
 	}
 }
@@ -764,10 +1431,13 @@ func (c *IPAMContext) increaseDatastorePool(ctx context.Context) {
 	log.Debug("Starting to increase pool size")
 	ipamdActionsInprogress.WithLabelValues("increaseDatastorePool").Add(float64(1))
 	defer ipamdActionsInprogress.WithLabelValues("increaseDatastorePool").Sub(float64(1))
+	decision := c.newPoolDecision(poolOperationIncrease)
+	defer c.recordPoolDecision(decision)
 
 	short, _, warmIPTargetDefined := c.datastoreTargetState()
 	if warmIPTargetDefined && short == 0 {
 		log.Debugf("Skipping increase Datastore pool, warm target reached")
+		decision.Reason = "warm IP target reached"
 		return
 	}
 
@@ -775,31 +1445,42 @@ func (c *IPAMContext) increaseDatastorePool(ctx context.Context) {
 		shortPrefix, warmTargetDefined := c.datastorePrefixTargetState()
 		if warmTargetDefined && shortPrefix == 0 {
 			log.Debugf("Skipping increase Datastore pool, warm prefix target reached")
+			decision.Reason = "warm prefix target reached"
 			return
 		}
 	}
 
 	if c.isTerminating() || c.isNodeNonSchedulable() {
 		log.Debug("AWS CNI is terminating, will not try to attach any new IPs or ENIs right now")
+		decision.Reason = "node is terminating or not schedulable"
 		return
 	}
 	// Try to add more Cidrs to existing ENIs first.
 	if c.inInsufficientCidrCoolingPeriod() {
 		log.Debugf("Recently we had InsufficientCidr error hence will wait for %v before retrying", insufficientCidrErrorCooldown)
+		decision.Reason = "waiting after an insufficient CIDR error"
 		return
 	}
 
-	increasedPool, err := c.tryAssignCidrs()
+	need, room, progressive := c.scaleUpNeed()
+	increasedPool, err := c.tryAssignCidrs(ctx)
 	if err != nil {
-		log.Errorf(err.Error())
+		poolLog.Errorf(err.Error())
+		decision.Error = err.Error()
 		if containsInsufficientCIDRsOrSubnetIPs(err) {
-			log.Errorf("Unable to attach IPs/Prefixes for the ENI, subnet doesn't seem to have enough IPs/Prefixes. Consider using new subnet or carve a reserved range using create-subnet-cidr-reservation")
+			poolLog.Errorf("Unable to attach IPs/Prefixes for the ENI, subnet doesn't seem to have enough IPs/Prefixes. Consider using new subnet or carve a reserved range using create-subnet-cidr-reservation")
 			c.lastInsufficientCidrError = time.Now()
+			c.reportIPExhaustion(ipExhaustionReasonSubnet, err.Error())
+			decision.Reason = "subnet is out of addresses"
 			return
 		}
 	}
 	if increasedPool {
 		c.updateLastNodeIPPoolAction()
+		decision.Action = c.poolChangeAction(decision, "assigned")
+		if progressive {
+			c.scaleUpAfterFill(ctx, decision, need, room)
+		}
 	} else {
 		// Check if we need to make room for the VPC Resource Controller to attach a trunk ENI
 		reserveSlotForTrunkENI := 0
@@ -808,18 +1489,24 @@ func (c *IPAMContext) increaseDatastorePool(ctx context.Context) {
 		}
 		// If we did not add an IP, try to add an ENI instead.
 		if c.dataStore.GetENIs() < (c.maxENI - c.unmanagedENI - reserveSlotForTrunkENI) {
+			decision.Action = "allocated an ENI"
 			if err = c.tryAllocateENI(ctx); err == nil {
 				c.updateLastNodeIPPoolAction()
+				decision.Error = ""
+			} else {
+				decision.Error = err.Error()
 			}
 		} else {
 			log.Debugf("Skipping ENI allocation as the max ENI limit of %d is already reached (accounting for %d unmanaged ENIs and %d trunk ENIs)",
 				c.maxENI, c.unmanagedENI, reserveSlotForTrunkENI)
+			decision.Reason = "max ENI limit reached"
 		}
 	}
 }
 
 func (c *IPAMContext) updateLastNodeIPPoolAction() {
 	c.lastNodeIPPoolAction = time.Now()
+	c.allocationQueue.notify()
 	stats := c.dataStore.GetIPStats(ipV4AddrFamily)
 	if !c.enablePrefixDelegation {
 		log.Debugf("Successfully increased IP pool: %s", stats)
@@ -830,66 +1517,73 @@ func (c *IPAMContext) updateLastNodeIPPoolAction() {
 }
 
 func (c *IPAMContext) tryAllocateENI(ctx context.Context) error {
-	var securityGroups []*string
-	var subnet string
-
-	if c.useCustomNetworking {
-		eniCfg, err := eniconfig.MyENIConfig(ctx, c.cachedK8SClient)
-
-		if err != nil {
-			log.Errorf("Failed to get pod ENI config")
-			return err
-		}
-
-		log.Infof("ipamd: using custom network config: %v, %s", eniCfg.SecurityGroups, eniCfg.Subnet)
-		for _, sgID := range eniCfg.SecurityGroups {
-			log.Debugf("Found security-group id: %s", sgID)
-			securityGroups = append(securityGroups, aws.String(sgID))
-		}
-		subnet = eniCfg.Subnet
-	}
-
-	eni, err := c.awsClient.AllocENI(c.useCustomNetworking, securityGroups, subnet)
+	eni, err := c.allocENI(ctx)
 	if err != nil {
-		log.Errorf("Failed to increase pool size due to not able to allocate ENI %v", err)
+		poolLog.Errorf("Failed to increase pool size due to not able to allocate ENI %v", err)
 		ipamdErrInc("increaseIPPoolAllocENI")
 		return err
 	}
 
 	resourcesToAllocate := c.GetENIResourcesToAllocate()
 
-	_, err = c.awsClient.AllocIPAddresses(eni, resourcesToAllocate)
+	_, err = c.allocIPAddresses(ctx, eni, resourcesToAllocate)
 	if err != nil {
-		log.Warnf("Failed to allocate %d IP addresses on an ENI: %v", resourcesToAllocate, err)
+		poolLog.Warnf("Failed to allocate %d IP addresses on an ENI: %v", resourcesToAllocate, err)
 		// Continue to process the allocated IP addresses
 		ipamdErrInc("increaseIPPoolAllocIPAddressesFailed")
 		if containsInsufficientCIDRsOrSubnetIPs(err) {
-			log.Errorf("Unable to attach IPs/Prefixes for the ENI, subnet doesn't seem to have enough IPs/Prefixes. Consider using new subnet or carve a reserved range using create-subnet-cidr-reservation")
+			poolLog.Errorf("Unable to attach IPs/Prefixes for the ENI, subnet doesn't seem to have enough IPs/Prefixes. Consider using new subnet or carve a reserved range using create-subnet-cidr-reservation")
 			c.lastInsufficientCidrError = time.Now()
+			c.reportIPExhaustion(ipExhaustionReasonSubnet, err.Error())
 			return err
 		}
 	}
 
-	eniMetadata, err := c.awsClient.WaitForENIAndIPsAttached(eni, resourcesToAllocate)
+	eniMetadata, err := c.awsClient.WaitForENIAndIPsAttached(ctx, eni, resourcesToAllocate)
 	if err != nil {
 		ipamdErrInc("increaseIPPoolwaitENIAttachedFailed")
-		log.Errorf("Failed to increase pool size: Unable to discover attached ENI from metadata service %v", err)
+		poolLog.Errorf("Failed to increase pool size: Unable to discover attached ENI from metadata service %v", err)
 		return err
 	}
 
 	// The CNI does not create trunk or EFA ENIs, so they will always be false here
-	err = c.setupENI(eni, eniMetadata, false, false)
+	err = c.setupENI(ctx, eni, eniMetadata, false, false)
 	if err != nil {
 		ipamdErrInc("increaseIPPoolsetupENIFailed")
-		log.Errorf("Failed to increase pool size: %v", err)
+		poolLog.Errorf("Failed to increase pool size: %v", err)
 		return err
 	}
 	return err
 }
 
+// allocENI creates and attaches an ENI, in the subnet and with the security groups of the ENIConfig of the node when
+// custom networking is used
+func (c *IPAMContext) allocENI(ctx context.Context) (string, error) {
+	var securityGroups []*string
+	var subnet string
+
+	if c.useCustomNetworking {
+		eniCfg, err := eniconfig.MyENIConfig(ctx, c.cachedK8SClient)
+
+		if err != nil {
+			poolLog.Errorf("Failed to get pod ENI config")
+			return "", err
+		}
+
+		log.Infof("ipamd: using custom network config: %v, %s", eniCfg.SecurityGroups, eniCfg.Subnet)
+		for _, sgID := range eniCfg.SecurityGroups {
+			log.Debugf("Found security-group id: %s", sgID)
+			securityGroups = append(securityGroups, aws.String(sgID))
+		}
+		subnet = eniCfg.Subnet
+	}
+
+	return c.awsClient.AllocENI(ctx, c.useCustomNetworking, securityGroups, subnet)
+}
+
 // For an ENI, try to fill in missing IPs on an existing ENI with PD disabled
 // try to fill in missing Prefixes on an existing ENI with PD enabled
-func (c *IPAMContext) tryAssignCidrs() (increasedPool bool, err error) {
+func (c *IPAMContext) tryAssignCidrs(ctx context.Context) (increasedPool bool, err error) {
 	short, _, warmIPTargetDefined := c.datastoreTargetState()
 	if warmIPTargetDefined && short == 0 {
 		log.Infof("Warm IP target set and short is 0 so not assigning Cidrs (IPs or Prefixes)")
@@ -905,14 +1599,14 @@ func (c *IPAMContext) tryAssignCidrs() (increasedPool bool, err error) {
 	}
 
 	if !c.enablePrefixDelegation {
-		return c.tryAssignIPs()
+		return c.tryAssignIPs(ctx)
 	} else {
-		return c.tryAssignPrefixes()
+		return c.tryAssignPrefixes(ctx)
 	}
 }
 
 // For an ENI, try to fill in missing IPs on an existing ENI
-func (c *IPAMContext) tryAssignIPs() (increasedPool bool, err error) {
+func (c *IPAMContext) tryAssignIPs(ctx context.Context) (increasedPool bool, err error) {
 	// If WARM_IP_TARGET is set, only proceed if we are short of target
 	short, _, warmIPTargetDefined := c.datastoreTargetState()
 	if warmIPTargetDefined && short == 0 {
@@ -932,11 +1626,11 @@ func (c *IPAMContext) tryAssignIPs() (increasedPool bool, err error) {
 		currentNumberOfAllocatedIPs := len(eni.AvailableIPv4Cidrs)
 		// Try to allocate all available IPs for this ENI
 		resourcesToAllocate := min((c.maxIPsPerENI - currentNumberOfAllocatedIPs), toAllocate)
-		output, err := c.awsClient.AllocIPAddresses(eni.ID, resourcesToAllocate)
+		output, err := c.awsClient.AllocIPAddresses(ctx, eni.ID, resourcesToAllocate)
 		if err != nil {
 			log.Warnf("failed to allocate all available IP addresses on ENI %s, err: %v", eni.ID, err)
 			// Try to just get one more IP
-			output, err = c.awsClient.AllocIPAddresses(eni.ID, 1)
+			output, err = c.awsClient.AllocIPAddresses(ctx, eni.ID, 1)
 			if err != nil {
 				ipamdErrInc("increaseIPPoolAllocIPAddressesFailed")
 				return false, errors.Wrap(err, fmt.Sprintf("failed to allocate one IP addresses on ENI %s, err ", eni.ID))
@@ -959,12 +1653,12 @@ func (c *IPAMContext) tryAssignIPs() (increasedPool bool, err error) {
 	return false, nil
 }
 
-func (c *IPAMContext) assignIPv6Prefix(eniID string) (err error) {
+func (c *IPAMContext) assignIPv6Prefix(ctx context.Context, eniID string) (err error) {
 	log.Debugf("Assigning an IPv6Prefix for ENI: %s", eniID)
 	//Let's make an EC2 API call to get a list of IPv6 prefixes (if any) that are already attached to the
 	//current ENI. We will make this call only once during boot up/init and doing so will shield us from any
 	//IMDS out of sync issues. We only need one v6 prefix per ENI/Node.
-	ec2v6Prefixes, err := c.awsClient.GetIPv6PrefixesFromEC2(eniID)
+	ec2v6Prefixes, err := c.awsClient.GetIPv6PrefixesFromEC2(ctx, eniID)
 	if err != nil {
 		log.Errorf("assignIPv6Prefix; err: %s", err)
 		return err
@@ -980,7 +1674,7 @@ func (c *IPAMContext) assignIPv6Prefix(eniID string) (err error) {
 	if len(ec2v6Prefixes) == 0 {
 		//Allocate and attach a v6 Prefix to Primary ENI
 		log.Debugf("No IPv6 Prefix(es) found for ENI: %s", eniID)
-		strPrefixes, err := c.awsClient.AllocIPv6Prefixes(eniID)
+		strPrefixes, err := c.awsClient.AllocIPv6Prefixes(ctx, eniID)
 		if err != nil {
 			return err
 		}
@@ -998,7 +1692,7 @@ func (c *IPAMContext) assignIPv6Prefix(eniID string) (err error) {
 	return nil
 }
 
-func (c *IPAMContext) tryAssignPrefixes() (increasedPool bool, err error) {
+func (c *IPAMContext) tryAssignPrefixes(ctx context.Context) (increasedPool bool, err error) {
 	toAllocate := c.getPrefixesNeeded()
 	// Returns an ENI which has space for more prefixes to be attached, but this
 	// ENI might not suffice the WARM_IP_TARGET/WARM_PREFIX_TARGET
@@ -1006,12 +1700,15 @@ func (c *IPAMContext) tryAssignPrefixes() (increasedPool bool, err error) {
 	if eni != nil {
 		currentNumberOfAllocatedPrefixes := len(eni.AvailableIPv4Cidrs)
 		resourcesToAllocate := min((c.maxPrefixesPerENI - currentNumberOfAllocatedPrefixes), toAllocate)
-		output, err := c.awsClient.AllocIPAddresses(eni.ID, resourcesToAllocate)
+		output, err := c.allocIPAddresses(ctx, eni.ID, resourcesToAllocate)
 		if err != nil {
 			log.Warnf("failed to allocate all available IPv4 Prefixes on ENI %s, err: %v", eni.ID, err)
 			// Try to just get one more prefix
-			output, err = c.awsClient.AllocIPAddresses(eni.ID, 1)
+			output, err = c.allocIPAddresses(ctx, eni.ID, 1)
 			if err != nil {
+				if containsInsufficientCidrBlocks(err) {
+					return c.tryAssignIPsInsteadOfPrefixes(ctx, eni, resourcesToAllocate)
+				}
 				ipamdErrInc("increaseIPPoolAllocIPAddressesFailed")
 				return false, errors.Wrap(err, fmt.Sprintf("failed to allocate one IPv4 prefix on ENI %s, err: %v", eni.ID, err))
 			}
@@ -1027,11 +1724,116 @@ func (c *IPAMContext) tryAssignPrefixes() (increasedPool bool, err error) {
 	return false, nil
 }
 
+// allocIPAddresses allocates IPs or prefixes on an ENI, with the prefixes taken from the tagged subnet CIDR
+// reservations when PREFIX_RESERVATION_TAG is set
+func (c *IPAMContext) allocIPAddresses(ctx context.Context, eniID string, numIPs int) (*ec2.AssignPrivateIpAddressesOutput, error) {
+	if c.enablePrefixDelegation && c.prefixReservationTagKey != "" {
+		return c.awsClient.AllocIPv4PrefixesFromReservations(ctx, eniID, numIPs, c.prefixReservationTagKey, c.prefixReservationTagValue)
+	}
+	return c.awsClient.AllocIPAddresses(ctx, eniID, numIPs)
+}
+
+// tryAssignIPsInsteadOfPrefixes assigns secondary IPs worth up to numPrefixes prefixes to an ENI whose subnet is too
+// fragmented for a /28 prefix, and puts the ENI in mixed mode so that pods get them. Prefixes are asked for again after
+// insufficientCidrErrorCooldown.
+func (c *IPAMContext) tryAssignIPsInsteadOfPrefixes(ctx context.Context, eni *datastore.ENI, numPrefixes int) (increasedPool bool, err error) {
+	_, numIPsPerPrefix, _ := datastore.GetPrefixDelegationDefaults()
+	numIPs := min(c.maxPrefixesPerENI-len(eni.AvailableIPv4Cidrs), numPrefixes*numIPsPerPrefix)
+	log.Warnf("No free /28 block in the subnet of ENI %s, falling back to %d secondary IPs", eni.ID, numIPs)
+	output, err := c.awsClient.AllocSecondaryIPAddresses(ctx, eni.ID, numIPs)
+	if err != nil {
+		ipamdErrInc("increaseIPPoolAllocIPAddressesFailed")
+		return false, errors.Wrapf(err, "failed to allocate secondary IPs instead of a prefix on ENI %s", eni.ID)
+	}
+	if output == nil || len(output.AssignedPrivateIpAddresses) == 0 {
+		ipamdErrInc("increaseIPPoolGetENIaddressesFailed")
+		return false, errors.Errorf("no secondary IPs assigned instead of a prefix on ENI %s", eni.ID)
+	}
+	prefixFragmentationFallbacks.Inc()
+	if err := c.dataStore.SetENIMixedMode(eni.ID); err != nil {
+		return false, errors.Wrapf(err, "failed to put ENI %s in mixed mode", eni.ID)
+	}
+
+	var ec2ip4s []*ec2.NetworkInterfacePrivateIpAddress
+	for _, ec2Addr := range output.AssignedPrivateIpAddresses {
+		ec2ip4s = append(ec2ip4s, &ec2.NetworkInterfacePrivateIpAddress{PrivateIpAddress: aws.String(aws.StringValue(ec2Addr.PrivateIpAddress))})
+	}
+	c.addENIsecondaryIPsToDataStore(ec2ip4s, eni.ID)
+	// Don't ask for prefixes again on every pool check while the subnet stays fragmented
+	c.lastInsufficientCidrError = time.Now()
+	return true, nil
+}
+
+// setupENIsOnInit sets up the ENIs found at startup, up to maxConcurrentENISetup of them at a time, so that instances
+// with many ENIs don't wait for each ENI's route table, rules and datastore setup in turn. Failures to tag ENIs are
+// aggregated and returned, failures to set up an ENI are only logged.
+func (c *IPAMContext) setupENIsOnInit(ctx context.Context, enis []awsutils.ENIMetadata, metadataResult awsutils.DescribeAllENIsResult) error {
+	var wg sync.WaitGroup
+	var errsLock sync.Mutex
+	var errs []error
+	sem := make(chan struct{}, maxConcurrentENISetup)
+	for _, eni := range enis {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(eni awsutils.ENIMetadata) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := c.setupENIOnInit(ctx, eni, metadataResult); err != nil {
+				errsLock.Lock()
+				errs = append(errs, err)
+				errsLock.Unlock()
+			}
+		}(eni)
+	}
+	wg.Wait()
+	return utilerrors.NewAggregate(errs)
+}
+
+func (c *IPAMContext) setupENIOnInit(ctx context.Context, eni awsutils.ENIMetadata, metadataResult awsutils.DescribeAllENIsResult) error {
+	log.Debugf("Discovered ENI %s, trying to set it up", eni.ENIID)
+
+	isTrunkENI := eni.ENIID == metadataResult.TrunkENI
+	isEFAENI := metadataResult.EFAENIs[eni.ENIID]
+	if !isTrunkENI && !c.disableENIProvisioning {
+		if err := c.awsClient.TagENI(ctx, eni.ENIID, metadataResult.TagMap[eni.ENIID]); err != nil {
+			return errors.Wrapf(err, "ipamd init: failed to tag managed ENI %v", eni.ENIID)
+		}
+	}
+
+	// Retry ENI sync
+	retry := 0
+	for {
+		retry++
+		err := c.setupENI(ctx, eni.ENIID, eni, isTrunkENI, isEFAENI)
+		if err == nil {
+			log.Infof("ENI %s set up.", eni.ENIID)
+			return nil
+		}
+
+		if retry > maxRetryCheckENI {
+			log.Warnf("Reached max retry: Unable to discover attached IPs for ENI from metadata service (attempted %d/%d): %v", retry, maxRetryCheckENI, err)
+			ipamdErrInc("waitENIAttachedMaxRetryExceeded")
+			return nil
+		}
+
+		log.Warnf("Error trying to set up ENI %s: %v", eni.ENIID, err)
+		if strings.Contains(err.Error(), "setupENINetwork: failed to find the link which uses MAC address") {
+			// If we can't find the matching link for this MAC address, there is no point in retrying for this ENI.
+			log.Debug("Unable to match link for this ENI, going to the next one.")
+			return nil
+		}
+		log.Debugf("Unable to discover IPs for this ENI yet (attempt %d/%d)", retry, maxRetryCheckENI)
+		time.Sleep(eniAttachTime)
+	}
+}
+
 // setupENI does following:
 // 1) add ENI to datastore
 // 2) set up linux ENI related networking stack.
 // 3) add all ENI's secondary IP addresses to datastore
-func (c *IPAMContext) setupENI(eni string, eniMetadata awsutils.ENIMetadata, isTrunkENI, isEFAENI bool) error {
+func (c *IPAMContext) setupENI(ctx context.Context, eni string, eniMetadata awsutils.ENIMetadata, isTrunkENI, isEFAENI bool) error {
 	primaryENI := c.awsClient.GetPrimaryENI()
 	// Add the ENI to the datastore
 	err := c.dataStore.AddENI(eni, eniMetadata.DeviceNumber, eni == primaryENI, isTrunkENI, isEFAENI)
@@ -1039,29 +1841,42 @@ func (c *IPAMContext) setupENI(eni string, eniMetadata awsutils.ENIMetadata, isT
 		return errors.Wrapf(err, "failed to add ENI %s to data store", eni)
 	}
 	// Store the primary IP of the ENI
-	c.primaryIP[eni] = eniMetadata.PrimaryIPv4Address()
+	primaryIP := eniMetadata.PrimaryIPv4Address()
+	c.primaryIPLock.Lock()
+	c.primaryIP[eni] = primaryIP
+	c.primaryIPLock.Unlock()
+	c.setENISubnet(eniMetadata.DeviceNumber, eniMetadata.SubnetIPv4CIDR)
 
 	if c.enableIPv6 && eni == primaryENI {
 		//In v6 PD Mode, VPC CNI will only manage primary ENI. Once we start supporting secondary IP and custom
 		//networking modes for v6, we will relax this restriction. We filter out all the ENIs except Primary ENI
 		//in v6 mode (prior to landing here), but included the primary ENI check as a safety net.
-		err := c.assignIPv6Prefix(eni)
+		err := c.assignIPv6Prefix(ctx, eni)
 		if err != nil {
 			return errors.Wrapf(err, "Failed to allocate IPv6 Prefixes to Primary ENI")
 		}
 	} else {
 		// For secondary ENIs, set up the network
 		if eni != primaryENI {
-			err = c.networkClient.SetupENINetwork(c.primaryIP[eni], eniMetadata.MAC, eniMetadata.DeviceNumber, eniMetadata.SubnetIPv4CIDR)
+			c.checkRouteTableConflicts(eni, eniMetadata)
+			err = c.networkClient.SetupENINetwork(primaryIP, eniMetadata.MAC, eniMetadata.DeviceNumber, eniMetadata.SubnetIPv4CIDR)
 			if err != nil {
 				// Failed to set up the ENI
 				errRemove := c.dataStore.RemoveENIFromDataStore(eni, true)
 				if errRemove != nil {
 					log.Warnf("failed to remove ENI %s: %v", eni, errRemove)
 				}
+				c.primaryIPLock.Lock()
 				delete(c.primaryIP, eni)
+				c.primaryIPLock.Unlock()
 				return errors.Wrapf(err, "failed to set up ENI %s network", eni)
 			}
+			c.recordENILink(eni, eniLink{
+				mac:          eniMetadata.MAC,
+				deviceNumber: eniMetadata.DeviceNumber,
+				primaryIP:    primaryIP,
+				subnetCIDR:   eniMetadata.SubnetIPv4CIDR,
+			})
 		}
 		log.Infof("Found ENIs having %d secondary IPs and %d Prefixes", len(eniMetadata.IPv4Addresses), len(eniMetadata.IPv4Prefixes))
 		//Either case add the IPs and prefixes to datastore.
@@ -1078,6 +1893,10 @@ func (c *IPAMContext) addENIsecondaryIPsToDataStore(ec2PrivateIpAddrs []*ec2.Net
 		if aws.BoolValue(ec2PrivateIpAddr.Primary) {
 			continue
 		}
+		if c.isNamespaceSNATIP(aws.StringValue(ec2PrivateIpAddr.PrivateIpAddress)) {
+			log.Infof("Skipping IP %s on ENI %s, it is used for namespace SNAT", aws.StringValue(ec2PrivateIpAddr.PrivateIpAddress), eni)
+			continue
+		}
 		cidr := net.IPNet{IP: net.ParseIP(aws.StringValue(ec2PrivateIpAddr.PrivateIpAddress)), Mask: net.IPv4Mask(255, 255, 255, 255)}
 		err := c.dataStore.AddIPv4CidrToStore(eni, cidr, false)
 		if err != nil && err.Error() != datastore.IPAlreadyInStoreError {
@@ -1155,7 +1974,7 @@ func (c *IPAMContext) getMaxENI() (int, error) {
 }
 
 func getWarmENITarget() int {
-	inputStr, found := os.LookupEnv(envWarmENITarget)
+	inputStr, found := lookupConfig(envWarmENITarget)
 
 	if !found {
 		return defaultWarmENITarget
@@ -1172,7 +1991,7 @@ func getWarmENITarget() int {
 }
 
 func getWarmPrefixTarget() int {
-	inputStr, found := os.LookupEnv(envWarmPrefixTarget)
+	inputStr, found := lookupConfig(envWarmPrefixTarget)
 
 	if !found {
 		return defaultWarmPrefixTarget
@@ -1312,7 +2131,7 @@ func (c *IPAMContext) nodeIPPoolReconcile(ctx context.Context, interval time.Dur
 	var eniTagMap map[string]awsutils.TagMap
 	if needToUpdateTags {
 		log.Debugf("A new ENI added but not by ipamd, updating tags by calling EC2")
-		metadataResult, err := c.awsClient.DescribeAllENIs()
+		metadataResult, err := c.awsClient.DescribeAllENIs(ctx)
 		if err != nil {
 			log.Warnf("Failed to call EC2 to describe ENIs, aborting reconcile: %v", err)
 			return
@@ -1333,6 +2152,7 @@ func (c *IPAMContext) nodeIPPoolReconcile(ctx context.Context, interval time.Dur
 		efaENIs = metadataResult.EFAENIs
 		eniTagMap = metadataResult.TagMap
 		c.setUnmanagedENIs(metadataResult.TagMap)
+		c.setENITags(metadataResult.TagMap)
 		c.awsClient.SetCNIUnmanagedENIs(metadataResult.MultiCardENIIDs)
 		attachedENIs = c.filterUnmanagedENIs(metadataResult.ENIMetadata)
 	}
@@ -1344,11 +2164,11 @@ func (c *IPAMContext) nodeIPPoolReconcile(ctx context.Context, interval time.Dur
 			// If the attached ENI is in the data store
 			log.Debugf("Reconcile existing ENI %s IP pool", attachedENI.ENIID)
 			// Reconcile IP pool
-			c.eniIPPoolReconcile(eniIPPool, attachedENI, attachedENI.ENIID)
+			c.eniIPPoolReconcile(ctx, eniIPPool, attachedENI, attachedENI.ENIID)
 			// If the attached ENI is in the data store
 			log.Debugf("Reconcile existing ENI %s IP prefixes", attachedENI.ENIID)
 			// Reconcile IP pool
-			c.eniPrefixPoolReconcile(eniPrefixPool, attachedENI, attachedENI.ENIID)
+			c.eniPrefixPoolReconcile(ctx, eniPrefixPool, attachedENI, attachedENI.ENIID)
 			// Mark action, remove this ENI from currentENIs map
 			delete(currentENIs, attachedENI.ENIID)
 			continue
@@ -1357,7 +2177,7 @@ func (c *IPAMContext) nodeIPPoolReconcile(ctx context.Context, interval time.Dur
 		isTrunkENI := attachedENI.ENIID == trunkENI
 		isEFAENI := efaENIs[attachedENI.ENIID]
 		if !isTrunkENI && !c.disableENIProvisioning {
-			if err := c.awsClient.TagENI(attachedENI.ENIID, eniTagMap[attachedENI.ENIID]); err != nil {
+			if err := c.awsClient.TagENI(ctx, attachedENI.ENIID, eniTagMap[attachedENI.ENIID]); err != nil {
 				log.Errorf("IP pool reconcile: failed to tag managed ENI %v: %v", attachedENI.ENIID, err)
 				ipamdErrInc("eniReconcileAdd")
 				continue
@@ -1366,7 +2186,7 @@ func (c *IPAMContext) nodeIPPoolReconcile(ctx context.Context, interval time.Dur
 
 		// Add new ENI
 		log.Debugf("Reconcile and add a new ENI %s", attachedENI)
-		err = c.setupENI(attachedENI.ENIID, attachedENI, isTrunkENI, isEFAENI)
+		err = c.setupENI(ctx, attachedENI.ENIID, attachedENI, isTrunkENI, isEFAENI)
 		if err != nil {
 			log.Errorf("IP pool reconcile: Failed to set up ENI %s network: %v", attachedENI.ENIID, err)
 			ipamdErrInc("eniReconcileAdd")
@@ -1391,21 +2211,23 @@ func (c *IPAMContext) nodeIPPoolReconcile(ctx context.Context, interval time.Dur
 		reconcileCnt.With(prometheus.Labels{"fn": "eniReconcileDel"}).Inc()
 	}
 	c.lastNodeIPPoolAction = time.Now()
+	c.setDatastoreReconciled()
 
 	log.Debug("Successfully Reconciled ENI/IP pool")
 	c.logPoolStats(c.dataStore.GetIPStats(ipV4AddrFamily))
 }
 
-func (c *IPAMContext) eniIPPoolReconcile(ipPool []string, attachedENI awsutils.ENIMetadata, eni string) {
+func (c *IPAMContext) eniIPPoolReconcile(ctx context.Context, ipPool []string, attachedENI awsutils.ENIMetadata, eni string) {
 	attachedENIIPs := attachedENI.IPv4Addresses
 	needEC2Reconcile := true
 	// Here we can't trust attachedENI since the IMDS metadata can be stale. We need to check with EC2 API.
-	// +1 is for the primary IP of the ENI that is not added to the ipPool and not available for pods to use.
-	if 1+len(ipPool) != len(attachedENIIPs) {
+	// +1 is for the primary IP of the ENI that is not added to the ipPool and not available for pods to use. The
+	// namespace SNAT IPs aren't added to the ipPool either.
+	if 1+len(ipPool)+c.unpooledNamespaceSNATIPs(attachedENIIPs, ipPool) != len(attachedENIIPs) {
 		log.Warnf("Instance metadata does not match data store! ipPool: %v, metadata: %v", ipPool, attachedENIIPs)
 		log.Debugf("We need to check the ENI status by calling the EC2 control plane.")
 		// Call EC2 to verify IPs on this ENI
-		ec2Addresses, err := c.awsClient.GetIPv4sFromEC2(eni)
+		ec2Addresses, err := c.awsClient.GetIPv4sFromEC2(ctx, eni)
 		if err != nil {
 			log.Errorf("Failed to fetch ENI IP addresses! Aborting reconcile of ENI %s", eni)
 			return
@@ -1415,7 +2237,7 @@ func (c *IPAMContext) eniIPPoolReconcile(ipPool []string, attachedENI awsutils.E
 	}
 
 	// Add all known attached IPs to the datastore
-	seenIPs := c.verifyAndAddIPsToDatastore(eni, attachedENIIPs, needEC2Reconcile)
+	seenIPs := c.verifyAndAddIPsToDatastore(ctx, eni, attachedENIIPs, needEC2Reconcile)
 
 	// Sweep phase, delete remaining IPs since they should not remain in the datastore
 	for _, existingIP := range ipPool {
@@ -1437,7 +2259,7 @@ func (c *IPAMContext) eniIPPoolReconcile(ipPool []string, attachedENI awsutils.E
 	}
 }
 
-func (c *IPAMContext) eniPrefixPoolReconcile(ipPool []string, attachedENI awsutils.ENIMetadata, eni string) {
+func (c *IPAMContext) eniPrefixPoolReconcile(ctx context.Context, ipPool []string, attachedENI awsutils.ENIMetadata, eni string) {
 	attachedENIIPs := attachedENI.IPv4Prefixes
 	needEC2Reconcile := true
 	// Here we can't trust attachedENI since the IMDS metadata can be stale. We need to check with EC2 API.
@@ -1447,7 +2269,7 @@ func (c *IPAMContext) eniPrefixPoolReconcile(ipPool []string, attachedENI awsuti
 		log.Warnf("Instance metadata does not match data store! ipPool: %v, metadata: %v", ipPool, attachedENIIPs)
 		log.Debugf("We need to check the ENI status by calling the EC2 control plane.")
 		// Call EC2 to verify IPs on this ENI
-		ec2Addresses, err := c.awsClient.GetIPv4PrefixesFromEC2(eni)
+		ec2Addresses, err := c.awsClient.GetIPv4PrefixesFromEC2(ctx, eni)
 		if err != nil {
 			log.Errorf("Failed to fetch ENI IP addresses! Aborting reconcile of ENI %s", eni)
 			return
@@ -1457,7 +2279,7 @@ func (c *IPAMContext) eniPrefixPoolReconcile(ipPool []string, attachedENI awsuti
 	}
 
 	// Add all known attached IPs to the datastore
-	seenIPs := c.verifyAndAddPrefixesToDatastore(eni, attachedENIIPs, needEC2Reconcile)
+	seenIPs := c.verifyAndAddPrefixesToDatastore(ctx, eni, attachedENIIPs, needEC2Reconcile)
 
 	// Sweep phase, delete remaining Prefixes since they should not remain in the datastore
 	for _, existingIP := range ipPool {
@@ -1485,7 +2307,7 @@ func (c *IPAMContext) eniPrefixPoolReconcile(ipPool []string, attachedENI awsuti
 
 // verifyAndAddIPsToDatastore updates the datastore with the known secondary IPs. IPs who are out of cooldown gets added
 // back to the datastore after being verified against EC2.
-func (c *IPAMContext) verifyAndAddIPsToDatastore(eni string, attachedENIIPs []*ec2.NetworkInterfacePrivateIpAddress, needEC2Reconcile bool) map[string]bool {
+func (c *IPAMContext) verifyAndAddIPsToDatastore(ctx context.Context, eni string, attachedENIIPs []*ec2.NetworkInterfacePrivateIpAddress, needEC2Reconcile bool) map[string]bool {
 	var ec2VerifiedAddresses []*ec2.NetworkInterfacePrivateIpAddress
 	seenIPs := make(map[string]bool)
 	for _, privateIPv4 := range attachedENIIPs {
@@ -1494,6 +2316,14 @@ func (c *IPAMContext) verifyAndAddIPsToDatastore(eni string, attachedENIIPs []*e
 			log.Infof("Reconcile and skip primary IP %s on ENI %s", strPrivateIPv4, eni)
 			continue
 		}
+		if c.isNamespaceSNATIP(strPrivateIPv4) {
+			// Unless a pod still has it, the IP is not marked as seen so that it is removed from the datastore
+			if c.dataStore.IsIPAssigned(net.ParseIP(strPrivateIPv4)) {
+				log.Warnf("Namespace SNAT IP %s on ENI %s is still assigned to a pod", strPrivateIPv4, eni)
+				seenIPs[strPrivateIPv4] = true
+			}
+			continue
+		}
 
 		// Check if this IP was recently freed
 		ipv4Addr := net.IPNet{IP: net.ParseIP(strPrivateIPv4), Mask: net.IPv4Mask(255, 255, 255, 255)}
@@ -1510,7 +2340,7 @@ func (c *IPAMContext) verifyAndAddIPsToDatastore(eni string, attachedENIIPs []*e
 					if ec2VerifiedAddresses == nil {
 						var err error
 						// Call EC2 to verify IPs on this ENI
-						ec2VerifiedAddresses, err = c.awsClient.GetIPv4sFromEC2(eni)
+						ec2VerifiedAddresses, err = c.awsClient.GetIPv4sFromEC2(ctx, eni)
 						if err != nil {
 							log.Errorf("Failed to fetch ENI IP addresses from EC2! %v", err)
 							// Do not delete this IP from the datastore or cooldown until we have confirmed with EC2
@@ -1556,7 +2386,7 @@ func (c *IPAMContext) verifyAndAddIPsToDatastore(eni string, attachedENIIPs []*e
 
 // verifyAndAddPrefixesToDatastore updates the datastore with the known Prefixes. Prefixes who are out of cooldown gets added
 // back to the datastore after being verified against EC2.
-func (c *IPAMContext) verifyAndAddPrefixesToDatastore(eni string, attachedENIPrefixes []*ec2.Ipv4PrefixSpecification, needEC2Reconcile bool) map[string]bool {
+func (c *IPAMContext) verifyAndAddPrefixesToDatastore(ctx context.Context, eni string, attachedENIPrefixes []*ec2.Ipv4PrefixSpecification, needEC2Reconcile bool) map[string]bool {
 	var ec2VerifiedAddresses []*ec2.Ipv4PrefixSpecification
 	seenIPs := make(map[string]bool)
 	for _, privateIPv4Cidr := range attachedENIPrefixes {
@@ -1583,7 +2413,7 @@ func (c *IPAMContext) verifyAndAddPrefixesToDatastore(eni string, attachedENIPre
 					if ec2VerifiedAddresses == nil {
 						var err error
 						// Call EC2 to verify Prefixes on this ENI
-						ec2VerifiedAddresses, err = c.awsClient.GetIPv4PrefixesFromEC2(eni)
+						ec2VerifiedAddresses, err = c.awsClient.GetIPv4PrefixesFromEC2(ctx, eni)
 						if err != nil {
 							log.Errorf("Failed to fetch ENI IP addresses from EC2! %v", err)
 							// Do not delete this Prefix from the datastore or cooldown until we have confirmed with EC2
@@ -1642,11 +2472,22 @@ func dsBackingStorePath() string {
 	if value := os.Getenv(envBackingStorePath); value != "" {
 		return value
 	}
-	return defaultBackingStorePath
+	return paths.Checkpoint()
+}
+
+// MigrateCheckpoint converts the checkpoint file to the current format version, reading the allocations from CRI when
+// there is no checkpoint file yet. With dryRun, the checkpoint file is left untouched.
+func MigrateCheckpoint(dryRun bool) (*datastore.CheckpointMigrationReport, error) {
+	ds := datastore.NewDataStore(log, datastore.NewJSONFile(dsBackingStorePath()), false)
+	return ds.MigrateBackingStore(datastore.CheckpointMigrationOptions{
+		IPv6:    isIPv6Enabled(),
+		ReadCRI: true,
+		DryRun:  dryRun,
+	})
 }
 
 func getWarmIPTarget() int {
-	inputStr, found := os.LookupEnv(envWarmIPTarget)
+	inputStr, found := lookupConfig(envWarmIPTarget)
 
 	if !found {
 		return noWarmIPTarget
@@ -1662,7 +2503,7 @@ func getWarmIPTarget() int {
 }
 
 func getMinimumIPTarget() int {
-	inputStr, found := os.LookupEnv(envMinimumIPTarget)
+	inputStr, found := lookupConfig(envMinimumIPTarget)
 
 	if !found {
 		return noMinimumIPTarget
@@ -1677,6 +2518,78 @@ func getMinimumIPTarget() int {
 	return noMinimumIPTarget
 }
 
+func getMinimumReadyIPTarget() int {
+	inputStr, found := os.LookupEnv(envMinimumReadyIPTarget)
+	if !found {
+		return 0
+	}
+	if input, err := strconv.Atoi(inputStr); err == nil && input >= 0 {
+		log.Debugf("Using MINIMUM_READY_IP_TARGET %v", input)
+		return input
+	}
+	return 0
+}
+
+func getDelUnassignBatchInterval() time.Duration {
+	inputStr, found := os.LookupEnv(envDelUnassignBatchInterval)
+	if !found {
+		return defaultDelUnassignBatchInterval
+	}
+	if input, err := strconv.Atoi(inputStr); err == nil && input >= 0 {
+		log.Debugf("Using DEL_UNASSIGN_BATCH_INTERVAL %v", input)
+		return time.Duration(input) * time.Second
+	}
+	return defaultDelUnassignBatchInterval
+}
+
+func getDelUnassignBatchSize() int {
+	inputStr, found := os.LookupEnv(envDelUnassignBatchSize)
+	if !found {
+		return defaultDelUnassignBatchSize
+	}
+	if input, err := strconv.Atoi(inputStr); err == nil && input >= 0 {
+		log.Debugf("Using DEL_UNASSIGN_BATCH_SIZE %v", input)
+		return input
+	}
+	return defaultDelUnassignBatchSize
+}
+
+func getENILimitQueueTimeout() time.Duration {
+	inputStr, found := os.LookupEnv(envENILimitQueueTimeout)
+	if !found {
+		return defaultENILimitQueueTimeout
+	}
+	if input, err := strconv.Atoi(inputStr); err == nil && input >= 0 {
+		log.Debugf("Using ENI_LIMIT_QUEUE_TIMEOUT %v", input)
+		return time.Duration(input) * time.Second
+	}
+	return defaultENILimitQueueTimeout
+}
+
+func getPoolDecisionLogSize() int {
+	inputStr, found := os.LookupEnv(envPoolDecisionLogSize)
+	if !found {
+		return defaultPoolDecisionLogSize
+	}
+	if input, err := strconv.Atoi(inputStr); err == nil && input >= 0 {
+		log.Debugf("Using POOL_DECISION_LOG_SIZE %v", input)
+		return input
+	}
+	return defaultPoolDecisionLogSize
+}
+
+func getWarmVethPoolSize() int {
+	inputStr, found := os.LookupEnv(envWarmVethPoolSize)
+	if !found {
+		return 0
+	}
+	if input, err := strconv.Atoi(inputStr); err == nil && input >= 0 {
+		log.Debugf("Using WARM_VETH_POOL_SIZE %v", input)
+		return input
+	}
+	return 0
+}
+
 func disablingENIProvisioning() bool {
 	return getEnvBoolWithDefault(envDisableENIProvisioning, false)
 }
@@ -1705,6 +2618,190 @@ func enablePodIPAnnotation() bool {
 	return getEnvBoolWithDefault(envAnnotatePodIP, false)
 }
 
+func excludeEFAENIs() bool {
+	return getEnvBoolWithDefault(envExcludeEFAENIs, true)
+}
+
+func enableNodeCapacityAnnotation() bool {
+	return getEnvBoolWithDefault(envAnnotateNodeIPCapacity, false)
+}
+
+func enableSecurityGroupReconciliation() bool {
+	return getEnvBoolWithDefault(envEnableSecurityGroupReconciliation, false)
+}
+
+func enableNodeAllocation() bool {
+	return getEnvBoolWithDefault(envPublishNodeAllocation, false)
+}
+
+func enablePodIPPinning() bool {
+	return getEnvBoolWithDefault(envEnablePodIPPinning, false)
+}
+
+func disableRouteRecovery() bool {
+	return getEnvBoolWithDefault(envDisableRouteRecovery, false)
+}
+
+func enableStaleRuleScrubber() bool {
+	return getEnvBoolWithDefault(envEnableStaleRuleScrubber, false)
+}
+
+func staleRuleScrubberDryRun() bool {
+	return getEnvBoolWithDefault(envStaleRuleScrubberDryRun, false)
+}
+
+func enableMultiCardENIs() bool {
+	return getEnvBoolWithDefault(envEnableMultiCardENIs, false)
+}
+
+func enableCNIDNSResult() bool {
+	return getEnvBoolWithDefault(envEnableCNIDNSResult, false)
+}
+
+func enableIptablesTamperEvents() bool {
+	return getEnvBoolWithDefault(envEnableIptablesTamperEvents, false)
+}
+
+func enableNAT64() bool {
+	return getEnvBoolWithDefault(envEnableNAT64, false)
+}
+
+func enableDedicatedENI() bool {
+	return getEnvBoolWithDefault(envEnableDedicatedENI, false)
+}
+
+func enablePodMulticast() bool {
+	return getEnvBoolWithDefault(envEnablePodMulticast, false)
+}
+
+func enablePodEgressRoutes() bool {
+	return getEnvBoolWithDefault(envEnablePodEgressRoutes, false)
+}
+
+// prefixReservationTag returns the key and value of PREFIX_RESERVATION_TAG, with an empty value for just a key
+func prefixReservationTag() (string, string) {
+	value := strings.TrimSpace(os.Getenv(envPrefixReservationTag))
+	if parts := strings.SplitN(value, "=", 2); len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return value, ""
+}
+
+func enableDuplicateAddressDetection() bool {
+	return getEnvBoolWithDefault(envEnableDuplicateAddressDetection, false)
+}
+
+func getDuplicateAddressDetectionTimeout() time.Duration {
+	inputStr, found := os.LookupEnv(envDuplicateAddressDetectionTimeout)
+	if !found {
+		return defaultDuplicateAddressDetectionTimeout
+	}
+	if input, err := strconv.Atoi(inputStr); err == nil && input > 0 {
+		log.Debugf("Using DUPLICATE_ADDRESS_DETECTION_TIMEOUT_MS %v", input)
+		return time.Duration(input) * time.Millisecond
+	}
+	return defaultDuplicateAddressDetectionTimeout
+}
+
+func enablePodIMDSBlock() bool {
+	return getEnvBoolWithDefault(envEnablePodIMDSBlock, false)
+}
+
+func podIMDSBlockAllowedNamespaces() map[string]bool {
+	namespaces := map[string]bool{}
+	for _, namespace := range strings.Split(os.Getenv(envPodIMDSBlockAllowedNamespaces), ",") {
+		if namespace = strings.TrimSpace(namespace); namespace != "" {
+			namespaces[namespace] = true
+		}
+	}
+	return namespaces
+}
+
+func enablePodIngressAllowlist() bool {
+	return getEnvBoolWithDefault(envEnablePodIngressAllowlist, false)
+}
+
+func enablePodConnectionLimits() bool {
+	return getEnvBoolWithDefault(envEnablePodConnectionLimits, false)
+}
+
+func getConntrackPressureThreshold() int {
+	inputStr, found := os.LookupEnv(envConntrackPressureThreshold)
+	if !found {
+		return defaultConntrackPressureThreshold
+	}
+	if input, err := strconv.Atoi(inputStr); err == nil && input > 0 && input <= 100 {
+		log.Debugf("Using %s %v", envConntrackPressureThreshold, input)
+		return input
+	}
+	log.Warnf("Ignoring invalid %s %q, using %d", envConntrackPressureThreshold, inputStr,
+		defaultConntrackPressureThreshold)
+	return defaultConntrackPressureThreshold
+}
+
+// getNonNegativeIntEnv returns the value of the env var key, or 0 if it is not set or invalid
+func getNonNegativeIntEnv(key string) int {
+	inputStr, found := os.LookupEnv(key)
+	if !found {
+		return 0
+	}
+	if input, err := strconv.Atoi(inputStr); err == nil && input >= 0 {
+		log.Debugf("Using %s %v", key, input)
+		return input
+	}
+	log.Warnf("Ignoring invalid %s %q", key, inputStr)
+	return 0
+}
+
+func enableClusterMetricsAggregator() bool {
+	return getEnvBoolWithDefault(envEnableClusterMetricsAggregator, false)
+}
+
+func enablePodMTUOverride() bool {
+	return getEnvBoolWithDefault(envEnablePodMTUOverride, false)
+}
+
+func enableProgressiveScaleUp() bool {
+	return getEnvBoolWithDefault(envEnableProgressiveScaleUp, false)
+}
+
+func enablePodNetworkMetrics() bool {
+	return getEnvBoolWithDefault(envEnablePodNetworkMetrics, false)
+}
+
+func enableDatastoreDebug() bool {
+	return getEnvBoolWithDefault(envEnableDatastoreDebug, false)
+}
+
+func enableIMDSHopLimitFix() bool {
+	return getEnvBoolWithDefault(envEnableIMDSHopLimitFix, false)
+}
+
+func ipExhaustionNodeCondition() string {
+	return strings.TrimSpace(os.Getenv(envIPExhaustionNodeCondition))
+}
+
+func excludeSNATCIDRsConfigMap() *types.NamespacedName {
+	return configMapFromEnv(envExcludeSNATCIDRsConfigMap)
+}
+
+func namespaceSNATConfigMap() *types.NamespacedName {
+	return configMapFromEnv(envNamespaceSNATConfigMap)
+}
+
+// configMapFromEnv returns the ConfigMap named by an env var, as "<namespace>/<name>" or just "<name>" in kube-system
+func configMapFromEnv(env string) *types.NamespacedName {
+	value := strings.TrimSpace(os.Getenv(env))
+	if value == "" {
+		return nil
+	}
+	name := types.NamespacedName{Namespace: defaultConfigMapNamespace, Name: value}
+	if parts := strings.SplitN(value, "/", 2); len(parts) == 2 {
+		name.Namespace, name.Name = parts[0], parts[1]
+	}
+	return &name
+}
+
 // filterUnmanagedENIs filters out ENIs marked with the "node.k8s.amazonaws.com/no_manage" tag
 func (c *IPAMContext) filterUnmanagedENIs(enis []awsutils.ENIMetadata) []awsutils.ENIMetadata {
 	numFiltered := 0
@@ -1722,10 +2819,22 @@ func (c *IPAMContext) filterUnmanagedENIs(enis []awsutils.ENIMetadata) []awsutil
 			log.Debugf("Skipping ENI %s: since it is unmanaged", eni.ENIID)
 			numFiltered++
 			continue
+		} else if c.isDedicatedENI(eni.ENIID) {
+			log.Debugf("Skipping ENI %s: since it is dedicated to a pod", eni.ENIID)
+			numFiltered++
+			continue
+		} else if c.isNotOwnedENI(eni.ENIID) {
+			log.Debugf("Skipping ENI %s: since it was not created by the CNI of this cluster", eni.ENIID)
+			numFiltered++
+			continue
 		} else if c.awsClient.IsCNIUnmanagedENI(eni.ENIID) {
 			log.Debugf("Skipping ENI %s: since on non-zero network card", eni.ENIID)
 			numFiltered++
 			continue
+		} else if c.excludeEFAENIs && c.awsClient.IsEFAENI(eni.ENIID) {
+			log.Debugf("Skipping ENI %s: since it is an EFA interface", eni.ENIID)
+			numFiltered++
+			continue
 		}
 		ret = append(ret, eni)
 	}
@@ -1968,15 +3077,15 @@ func (c *IPAMContext) AnnotatePod(podName, podNamespace, key, val string) error
 	return err
 }
 
-func (c *IPAMContext) tryUnassignIPsFromENIs() {
+func (c *IPAMContext) tryUnassignIPsFromENIs(ctx context.Context) {
 	log.Debugf("In tryUnassignIPsFromENIs")
 	eniInfos := c.dataStore.GetENIInfos()
 	for eniID := range eniInfos.ENIs {
-		c.tryUnassignIPFromENI(eniID)
+		c.tryUnassignIPFromENI(ctx, eniID)
 	}
 }
 
-func (c *IPAMContext) tryUnassignIPFromENI(eniID string) {
+func (c *IPAMContext) tryUnassignIPFromENI(ctx context.Context, eniID string) {
 	freeableIPs := c.dataStore.FreeableIPs(eniID)
 
 	if len(freeableIPs) == 0 {
@@ -2000,21 +3109,21 @@ func (c *IPAMContext) tryUnassignIPFromENI(eniID string) {
 	}
 
 	// Deallocate IPs from the instance if they aren't used by pods.
-	if err := c.awsClient.DeallocIPAddresses(eniID, deletedIPs); err != nil {
+	if err := c.awsClient.DeallocIPAddresses(ctx, eniID, deletedIPs); err != nil {
 		log.Warnf("Failed to decrease IP pool by removing IPs %v from ENI %s: %s", deletedIPs, eniID, err)
 	} else {
 		log.Debugf("Successfully decreased IP pool by removing IPs %v from ENI %s", deletedIPs, eniID)
 	}
 }
 
-func (c *IPAMContext) tryUnassignPrefixesFromENIs() {
+func (c *IPAMContext) tryUnassignPrefixesFromENIs(ctx context.Context) {
 	eniInfos := c.dataStore.GetENIInfos()
 	for eniID := range eniInfos.ENIs {
-		c.tryUnassignPrefixFromENI(eniID)
+		c.tryUnassignPrefixFromENI(ctx, eniID)
 	}
 }
 
-func (c *IPAMContext) tryUnassignPrefixFromENI(eniID string) {
+func (c *IPAMContext) tryUnassignPrefixFromENI(ctx context.Context, eniID string) {
 	freeablePrefixes := c.dataStore.FreeablePrefixes(eniID)
 	if len(freeablePrefixes) == 0 {
 		return
@@ -2035,7 +3144,7 @@ func (c *IPAMContext) tryUnassignPrefixFromENI(eniID string) {
 	}
 
 	// Deallocate IPs from the instance if they aren't used by pods.
-	if err := c.awsClient.DeallocPrefixAddresses(eniID, deletedPrefixes); err != nil {
+	if err := c.awsClient.DeallocPrefixAddresses(ctx, eniID, deletedPrefixes); err != nil {
 		log.Warnf("Failed to delete prefix %v from ENI %s: %s", deletedPrefixes, eniID, err)
 	} else {
 		log.Debugf("Successfully prefix removing IPs %v from ENI %s", deletedPrefixes, eniID)
@@ -2123,7 +3232,7 @@ func (c *IPAMContext) warmPrefixTargetDefined() bool {
 }
 
 // DeallocCidrs frees IPs and Prefixes from EC2
-func (c *IPAMContext) DeallocCidrs(eniID string, deletableCidrs []datastore.CidrInfo) {
+func (c *IPAMContext) DeallocCidrs(ctx context.Context, eniID string, deletableCidrs []datastore.CidrInfo) {
 	var deletableIPs []string
 	var deletablePrefixes []string
 
@@ -2143,11 +3252,11 @@ func (c *IPAMContext) DeallocCidrs(eniID string, deletableCidrs []datastore.Cidr
 		}
 	}
 
-	if err := c.awsClient.DeallocPrefixAddresses(eniID, deletablePrefixes); err != nil {
+	if err := c.awsClient.DeallocPrefixAddresses(ctx, eniID, deletablePrefixes); err != nil {
 		log.Warnf("Failed to free Prefixes %v from ENI %s: %s", deletablePrefixes, eniID, err)
 	}
 
-	if err := c.awsClient.DeallocIPAddresses(eniID, deletableIPs); err != nil {
+	if err := c.awsClient.DeallocIPAddresses(ctx, eniID, deletableIPs); err != nil {
 		log.Warnf("Failed to free IPs %v from ENI %s: %s", deletableIPs, eniID, err)
 	}
 }
@@ -2198,30 +3307,50 @@ func (c *IPAMContext) initENIAndIPLimits() (err error) {
 }
 
 func (c *IPAMContext) isConfigValid() bool {
-	//Validate that only one among v4 and v6 is enabled.
-	if c.enableIPv4 && c.enableIPv6 {
-		log.Errorf("IPv4 and IPv6 are both enabled. VPC CNI currently doesn't support dual stack mode")
+	//Validate the combination of IP family, Prefix Delegation, Custom Networking, Security Group Per Pod and external
+	//SNAT against the instance.
+	c.capabilityReport = negotiateCapabilities(c.enabledFeatures(), c.nodeCapabilities())
+	for _, warning := range c.capabilityReport.Warnings {
+		log.Warnf("%s", warning)
+	}
+	if len(c.capabilityReport.Errors) > 0 {
+		for _, err := range c.capabilityReport.Errors {
+			log.Errorf("Unsupported configuration: %s. Please set the env variables accordingly.", err)
+		}
 		return false
-	} else if !c.enableIPv4 && !c.enableIPv6 {
-		log.Errorf("IPv4 and IPv6 are both disabled. One of them have to be enabled")
+	}
+
+	//Validate that a static IP pool is only used in IPv4 secondary IP mode, which needs no EC2 call.
+	if c.staticIPPool && (c.enableIPv6 || c.enablePrefixDelegation || c.useCustomNetworking || c.enablePodENI) {
+		log.Errorf("%s is supported only in IPv4 secondary IP mode. Prefix Delegation, Security Group Per Pod and "+
+			"Custom Networking are not supported with a static IP pool. Please set the env variables accordingly.", envStaticIPPoolCIDRs)
 		return false
 	}
 
-	//Validate PD mode is enabled if VPC CNI is operating in IPv6 mode. SGPP and Custom networking are not supported in IPv6 mode.
-	if c.enableIPv6 && (c.enablePodENI || c.useCustomNetworking || !c.enablePrefixDelegation) {
-		log.Errorf("IPv6 is supported only in Prefix Delegation mode. Security Group Per Pod and " +
-			"Custom Networking are not supported in IPv6 mode. Please set the env variables accordingly.")
+	//Validate that a pool state is only imported in IPv4 secondary IP mode, where single IPs can be reserved.
+	if c.poolStateImportFile != "" && (c.enableIPv6 || c.enablePrefixDelegation || c.staticIPPool) {
+		log.Errorf("%s is supported only in IPv4 secondary IP mode. Prefix Delegation and static IP pools are not "+
+			"supported with a pool state import. Please set the env variables accordingly.", envPoolStateImportFile)
 		return false
 	}
 
-	//Validate Prefix Delegation against v4 and v6 modes.
-	if c.enablePrefixDelegation && !c.awsClient.IsPrefixDelegationSupported() {
-		if c.enableIPv6 {
-			log.Errorf("Prefix Delegation is not supported on non-nitro instance %s. IPv6 is only supported in Prefix delegation Mode. ", c.awsClient.GetInstanceType())
-			return false
+	//Validate that veth pairs are only pre-plumbed in IPv4 mode, where the pod IP is routed to the host veth.
+	if c.warmVethPoolSize > 0 && c.enableIPv6 {
+		log.Errorf("%s is supported only in IPv4 mode. Please set the env variables accordingly.", envWarmVethPoolSize)
+		return false
+	}
+
+	//Validate that prefixes are only taken from subnet CIDR reservations in IPv4 Prefix Delegation mode.
+	if c.prefixReservationTagKey != "" && (c.enableIPv6 || !c.enablePrefixDelegation) {
+		log.Errorf("%s is supported only in IPv4 Prefix Delegation mode. Please set the env variables accordingly.", envPrefixReservationTag)
+		return false
+	}
+
+	//Fall back to the default (secondary IP) mode when Prefix Delegation isn't supported by the instance.
+	for _, feature := range c.capabilityReport.Disabled {
+		if feature == featurePrefixDelegation {
+			c.enablePrefixDelegation = false
 		}
-		log.Warnf("Prefix delegation is not supported on non-nitro instance %s hence falling back to default (secondary IP) mode", c.awsClient.GetInstanceType())
-		c.enablePrefixDelegation = false
 	}
 
 	return true
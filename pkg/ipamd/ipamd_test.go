@@ -24,6 +24,7 @@ import (
 	"testing"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
@@ -137,18 +138,22 @@ func TestNodeInit(t *testing.T) {
 	var cidrs []string
 	m.awsutils.EXPECT().GetENILimit().Return(4)
 	m.awsutils.EXPECT().GetENIIPv4Limit().Return(14)
-	m.awsutils.EXPECT().GetIPv4sFromEC2(eni1.ENIID).AnyTimes().Return(eni1.IPv4Addresses, nil)
-	m.awsutils.EXPECT().GetIPv4sFromEC2(eni2.ENIID).AnyTimes().Return(eni2.IPv4Addresses, nil)
+	m.awsutils.EXPECT().GetIPv4sFromEC2(gomock.Any(), eni1.ENIID).AnyTimes().Return(eni1.IPv4Addresses, nil)
+	m.awsutils.EXPECT().GetIPv4sFromEC2(gomock.Any(), eni2.ENIID).AnyTimes().Return(eni2.IPv4Addresses, nil)
 	m.awsutils.EXPECT().IsUnmanagedENI(eni1.ENIID).Return(false).AnyTimes()
 	m.awsutils.EXPECT().IsUnmanagedENI(eni2.ENIID).Return(false).AnyTimes()
-	m.awsutils.EXPECT().TagENI(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	m.awsutils.EXPECT().TagENI(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 	m.awsutils.EXPECT().IsCNIUnmanagedENI(eni1.ENIID).Return(false).AnyTimes()
 	m.awsutils.EXPECT().IsCNIUnmanagedENI(eni2.ENIID).Return(false).AnyTimes()
 
 	primaryIP := net.ParseIP(ipaddr01)
 	m.awsutils.EXPECT().GetVPCIPv4CIDRs().AnyTimes().Return(cidrs, nil)
+	m.network.EXPECT().HostIptablesRulesModified().AnyTimes().Return(false, nil)
 	m.awsutils.EXPECT().GetPrimaryENImac().Return("")
 	m.network.EXPECT().SetupHostNetwork(cidrs, "", &primaryIP, false, true, false).Return(nil)
+	m.network.EXPECT().SetupPodIMDSBlock(false, false).Return(nil)
+	m.network.EXPECT().SetupPodIngressAllowlist(false, nil, false).Return(nil)
+	m.network.EXPECT().SetupPodConnectionLimits(false, false).Return(nil)
 
 	m.awsutils.EXPECT().GetPrimaryENI().AnyTimes().Return(primaryENIid)
 
@@ -160,7 +165,8 @@ func TestNodeInit(t *testing.T) {
 		EFAENIs:         make(map[string]bool),
 		MultiCardENIIDs: nil,
 	}
-	m.awsutils.EXPECT().DescribeAllENIs().Return(resp, nil)
+	m.awsutils.EXPECT().DescribeAllENIs(gomock.Any()).Return(resp, nil)
+	m.network.EXPECT().FindRouteTableConflicts(secMAC, secDevice).Return(nil, nil)
 	m.network.EXPECT().SetupENINetwork(gomock.Any(), secMAC, secDevice, secSubnet)
 
 	m.awsutils.EXPECT().SetCNIUnmanagedENIs(resp.MultiCardENIIDs).AnyTimes()
@@ -180,12 +186,41 @@ func TestNodeInit(t *testing.T) {
 	_ = m.cachedK8SClient.Create(ctx, &fakeNode)
 
 	// Add IPs
-	m.awsutils.EXPECT().AllocIPAddresses(gomock.Any(), gomock.Any())
+	m.awsutils.EXPECT().AllocIPAddresses(gomock.Any(), gomock.Any(), gomock.Any())
 
 	err := mockContext.nodeInit()
 	assert.NoError(t, err)
 }
 
+func TestSetupENIsOnInitAggregatesErrors(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	mockContext := &IPAMContext{
+		awsClient:     m.awsutils,
+		networkClient: m.network,
+		primaryIP:     make(map[string]string),
+		dataStore:     datastore.NewDataStore(log, datastore.NullCheckpoint{}, false),
+	}
+
+	eni1, eni2, eni3 := getDummyENIMetadata()
+	m.awsutils.EXPECT().GetPrimaryENI().AnyTimes().Return(primaryENIid)
+	m.awsutils.EXPECT().TagENI(gomock.Any(), eni1.ENIID, gomock.Any()).Return(nil)
+	m.awsutils.EXPECT().TagENI(gomock.Any(), eni2.ENIID, gomock.Any()).Return(errors.New("tag error"))
+	m.awsutils.EXPECT().TagENI(gomock.Any(), eni3.ENIID, gomock.Any()).Return(errors.New("tag error"))
+
+	resp := awsutils.DescribeAllENIsResult{
+		ENIMetadata: []awsutils.ENIMetadata{eni1, eni2, eni3},
+		TagMap:      map[string]awsutils.TagMap{},
+		EFAENIs:     make(map[string]bool),
+	}
+	err := mockContext.setupENIsOnInit(context.Background(), resp.ENIMetadata, resp)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), eni2.ENIID)
+	assert.Contains(t, err.Error(), eni3.ENIID)
+	assert.Equal(t, eni1.PrimaryIPv4Address(), mockContext.primaryIP[eni1.ENIID])
+}
+
 func TestNodeInitwithPDenabledIPv4Mode(t *testing.T) {
 	m := setup(t)
 	defer m.ctrl.Finish()
@@ -223,18 +258,22 @@ func TestNodeInitwithPDenabledIPv4Mode(t *testing.T) {
 	var cidrs []string
 	m.awsutils.EXPECT().GetENILimit().Return(4)
 	m.awsutils.EXPECT().GetENIIPv4Limit().Return(14)
-	m.awsutils.EXPECT().GetIPv4PrefixesFromEC2(eni1.ENIID).AnyTimes().Return(eni1.IPv4Prefixes, nil)
-	m.awsutils.EXPECT().GetIPv4PrefixesFromEC2(eni2.ENIID).AnyTimes().Return(eni2.IPv4Prefixes, nil)
+	m.awsutils.EXPECT().GetIPv4PrefixesFromEC2(gomock.Any(), eni1.ENIID).AnyTimes().Return(eni1.IPv4Prefixes, nil)
+	m.awsutils.EXPECT().GetIPv4PrefixesFromEC2(gomock.Any(), eni2.ENIID).AnyTimes().Return(eni2.IPv4Prefixes, nil)
 	m.awsutils.EXPECT().IsUnmanagedENI(eni1.ENIID).Return(false).AnyTimes()
 	m.awsutils.EXPECT().IsUnmanagedENI(eni2.ENIID).Return(false).AnyTimes()
-	m.awsutils.EXPECT().TagENI(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	m.awsutils.EXPECT().TagENI(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 	m.awsutils.EXPECT().IsCNIUnmanagedENI(eni1.ENIID).Return(false).AnyTimes()
 	m.awsutils.EXPECT().IsCNIUnmanagedENI(eni2.ENIID).Return(false).AnyTimes()
 
 	primaryIP := net.ParseIP(ipaddr01)
 	m.awsutils.EXPECT().GetVPCIPv4CIDRs().AnyTimes().Return(cidrs, nil)
+	m.network.EXPECT().HostIptablesRulesModified().AnyTimes().Return(false, nil)
 	m.awsutils.EXPECT().GetPrimaryENImac().Return("")
 	m.network.EXPECT().SetupHostNetwork(cidrs, "", &primaryIP, false, true, false).Return(nil)
+	m.network.EXPECT().SetupPodIMDSBlock(false, false).Return(nil)
+	m.network.EXPECT().SetupPodIngressAllowlist(false, nil, false).Return(nil)
+	m.network.EXPECT().SetupPodConnectionLimits(false, false).Return(nil)
 
 	m.awsutils.EXPECT().GetPrimaryENI().AnyTimes().Return(primaryENIid)
 
@@ -245,7 +284,8 @@ func TestNodeInitwithPDenabledIPv4Mode(t *testing.T) {
 		TrunkENI:    "",
 		EFAENIs:     make(map[string]bool),
 	}
-	m.awsutils.EXPECT().DescribeAllENIs().Return(resp, nil)
+	m.awsutils.EXPECT().DescribeAllENIs(gomock.Any()).Return(resp, nil)
+	m.network.EXPECT().FindRouteTableConflicts(secMAC, secDevice).Return(nil, nil)
 	m.network.EXPECT().SetupENINetwork(gomock.Any(), secMAC, secDevice, secSubnet)
 
 	m.awsutils.EXPECT().GetLocalIPv4().Return(primaryIP)
@@ -305,12 +345,15 @@ func TestNodeInitwithPDenabledIPv6Mode(t *testing.T) {
 
 	var cidrs []string
 	m.awsutils.EXPECT().IsUnmanagedENI(eni1.ENIID).Return(false).AnyTimes()
-	m.awsutils.EXPECT().TagENI(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	m.awsutils.EXPECT().TagENI(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 	m.awsutils.EXPECT().IsCNIUnmanagedENI(eni1.ENIID).Return(false).AnyTimes()
 
 	primaryIP := net.ParseIP(ipaddr01)
 	m.network.EXPECT().SetupHostNetwork(cidrs, eni1.MAC, &primaryIP, false, false, true).Return(nil)
-	m.awsutils.EXPECT().GetIPv6PrefixesFromEC2(eni1.ENIID).AnyTimes().Return(eni1.IPv6Prefixes, nil)
+	m.network.EXPECT().SetupPodIMDSBlock(false, true).Return(nil)
+	m.network.EXPECT().SetupPodIngressAllowlist(false, nil, true).Return(nil)
+	m.network.EXPECT().SetupPodConnectionLimits(false, true).Return(nil)
+	m.awsutils.EXPECT().GetIPv6PrefixesFromEC2(gomock.Any(), eni1.ENIID).AnyTimes().Return(eni1.IPv6Prefixes, nil)
 	m.awsutils.EXPECT().GetPrimaryENI().AnyTimes().Return(primaryENIid)
 	m.awsutils.EXPECT().GetPrimaryENImac().Return(eni1.MAC)
 	m.awsutils.EXPECT().IsPrimaryENI(primaryENIid).Return(true).AnyTimes()
@@ -322,7 +365,7 @@ func TestNodeInitwithPDenabledIPv6Mode(t *testing.T) {
 		TrunkENI:    "",
 		EFAENIs:     make(map[string]bool),
 	}
-	m.awsutils.EXPECT().DescribeAllENIs().Return(resp, nil)
+	m.awsutils.EXPECT().DescribeAllENIs(gomock.Any()).Return(resp, nil)
 	m.awsutils.EXPECT().GetLocalIPv4().Return(primaryIP)
 	m.awsutils.EXPECT().SetCNIUnmanagedENIs(resp.MultiCardENIIDs).AnyTimes()
 
@@ -504,9 +547,9 @@ func testIncreaseIPPool(t *testing.T, useENIConfig bool) {
 	}
 
 	if useENIConfig {
-		m.awsutils.EXPECT().AllocENI(true, sg, podENIConfig.Subnet).Return(eni2, nil)
+		m.awsutils.EXPECT().AllocENI(gomock.Any(), true, sg, podENIConfig.Subnet).Return(eni2, nil)
 	} else {
-		m.awsutils.EXPECT().AllocENI(false, nil, "").Return(eni2, nil)
+		m.awsutils.EXPECT().AllocENI(gomock.Any(), false, nil, "").Return(eni2, nil)
 	}
 
 	eniMetadata := []awsutils.ENIMetadata{
@@ -541,9 +584,10 @@ func testIncreaseIPPool(t *testing.T, useENIConfig bool) {
 	}
 
 	m.awsutils.EXPECT().GetPrimaryENI().Return(primaryENIid)
-	m.awsutils.EXPECT().WaitForENIAndIPsAttached(secENIid, 14).Return(eniMetadata[1], nil)
+	m.awsutils.EXPECT().WaitForENIAndIPsAttached(gomock.Any(), secENIid, 14).Return(eniMetadata[1], nil)
+	m.network.EXPECT().FindRouteTableConflicts(secMAC, secDevice).Return(nil, nil)
 	m.network.EXPECT().SetupENINetwork(gomock.Any(), secMAC, secDevice, secSubnet)
-	m.awsutils.EXPECT().AllocIPAddresses(eni2, 14)
+	m.awsutils.EXPECT().AllocIPAddresses(gomock.Any(), eni2, 14)
 
 	if mockContext.useCustomNetworking {
 		mockContext.myNodeName = myNodeName
@@ -627,9 +671,9 @@ func testIncreasePrefixPool(t *testing.T, useENIConfig bool) {
 	}
 
 	if useENIConfig {
-		m.awsutils.EXPECT().AllocENI(true, sg, podENIConfig.Subnet).Return(eni2, nil)
+		m.awsutils.EXPECT().AllocENI(gomock.Any(), true, sg, podENIConfig.Subnet).Return(eni2, nil)
 	} else {
-		m.awsutils.EXPECT().AllocENI(false, nil, "").Return(eni2, nil)
+		m.awsutils.EXPECT().AllocENI(gomock.Any(), false, nil, "").Return(eni2, nil)
 	}
 
 	eniMetadata := []awsutils.ENIMetadata{
@@ -668,9 +712,10 @@ func testIncreasePrefixPool(t *testing.T, useENIConfig bool) {
 	}
 
 	m.awsutils.EXPECT().GetPrimaryENI().Return(primaryENIid)
-	m.awsutils.EXPECT().WaitForENIAndIPsAttached(secENIid, 1).Return(eniMetadata[1], nil)
+	m.awsutils.EXPECT().WaitForENIAndIPsAttached(gomock.Any(), secENIid, 1).Return(eniMetadata[1], nil)
+	m.network.EXPECT().FindRouteTableConflicts(secMAC, secDevice).Return(nil, nil)
 	m.network.EXPECT().SetupENINetwork(gomock.Any(), secMAC, secDevice, secSubnet)
-	m.awsutils.EXPECT().AllocIPAddresses(eni2, 1)
+	m.awsutils.EXPECT().AllocIPAddresses(gomock.Any(), eni2, 1)
 
 	if mockContext.useCustomNetworking {
 		mockContext.myNodeName = myNodeName
@@ -703,6 +748,92 @@ func testIncreasePrefixPool(t *testing.T, useENIConfig bool) {
 	mockContext.increaseDatastorePool(ctx)
 }
 
+func TestTryAssignPrefixesFallsBackToSecondaryIPs(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+	ctx := context.Background()
+
+	mockContext := &IPAMContext{
+		awsClient:              m.awsutils,
+		maxIPsPerENI:           14,
+		maxPrefixesPerENI:      14,
+		warmPrefixTarget:       1,
+		primaryIP:              make(map[string]string),
+		enablePrefixDelegation: true,
+	}
+	mockContext.dataStore = testDatastorewithPrefix()
+	_ = mockContext.dataStore.AddENI(primaryENIid, primaryDevice, true, false, false)
+
+	// Secondary IPs worth one prefix, capped to the free slots of the ENI
+	fragmented := awserr.New(INSUFFICIENT_CIDR_BLOCKS, "There are not enough free cidr blocks in the specified subnet", nil)
+	output := &ec2.AssignPrivateIpAddressesOutput{
+		AssignedPrivateIpAddresses: []*ec2.AssignedPrivateIpAddress{
+			{PrivateIpAddress: aws.String(ipaddr11)},
+			{PrivateIpAddress: aws.String(ipaddr12)},
+		},
+	}
+	gomock.InOrder(
+		m.awsutils.EXPECT().AllocIPAddresses(gomock.Any(), primaryENIid, 1).Return(nil, fragmented),
+		m.awsutils.EXPECT().AllocIPAddresses(gomock.Any(), primaryENIid, 1).Return(nil, fragmented),
+		m.awsutils.EXPECT().AllocSecondaryIPAddresses(gomock.Any(), primaryENIid, 14).Return(output, nil),
+	)
+	increased, err := mockContext.tryAssignPrefixes(ctx)
+	assert.NoError(t, err)
+	assert.True(t, increased)
+	assert.True(t, mockContext.dataStore.GetENIInfos().ENIs[primaryENIid].MixedMode)
+	assert.Equal(t, 2, mockContext.dataStore.GetIPStats(ipV4AddrFamily).TotalIPs)
+	assert.True(t, mockContext.inInsufficientCidrCoolingPeriod())
+
+	// Running out of IPs in the subnet is not fragmentation
+	exhausted := awserr.New(INSUFFICIENT_FREE_IP_SUBNET, "The specified subnet does not have enough free addresses", nil)
+	m.awsutils.EXPECT().AllocIPAddresses(gomock.Any(), primaryENIid, 1).Return(nil, exhausted).Times(2)
+	increased, err = mockContext.tryAssignPrefixes(ctx)
+	assert.Error(t, err)
+	assert.False(t, increased)
+}
+
+func TestTryAssignPrefixesFromReservations(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+	ctx := context.Background()
+
+	mockContext := &IPAMContext{
+		awsClient:                 m.awsutils,
+		maxIPsPerENI:              14,
+		maxPrefixesPerENI:         14,
+		warmPrefixTarget:          1,
+		primaryIP:                 make(map[string]string),
+		enablePrefixDelegation:    true,
+		prefixReservationTagKey:   "cni",
+		prefixReservationTagValue: "pods",
+	}
+	mockContext.dataStore = testDatastorewithPrefix()
+	_ = mockContext.dataStore.AddENI(primaryENIid, primaryDevice, true, false, false)
+
+	output := &ec2.AssignPrivateIpAddressesOutput{
+		AssignedIpv4Prefixes: []*ec2.Ipv4PrefixSpecification{{Ipv4Prefix: aws.String(prefix01)}},
+	}
+	m.awsutils.EXPECT().AllocIPv4PrefixesFromReservations(gomock.Any(), primaryENIid, 1, "cni", "pods").Return(output, nil)
+	increased, err := mockContext.tryAssignPrefixes(ctx)
+	assert.NoError(t, err)
+	assert.True(t, increased)
+	assert.Equal(t, 16, mockContext.dataStore.GetIPStats(ipV4AddrFamily).TotalIPs)
+}
+
+func TestPrefixReservationTag(t *testing.T) {
+	defer os.Unsetenv(envPrefixReservationTag)
+	for value, want := range map[string][2]string{
+		"":          {"", ""},
+		"cni":       {"cni", ""},
+		"cni=pods":  {"cni", "pods"},
+		" cni=a=b ": {"cni", "a=b"},
+	} {
+		_ = os.Setenv(envPrefixReservationTag, value)
+		key, tagValue := prefixReservationTag()
+		assert.Equal(t, want, [2]string{key, tagValue}, value)
+	}
+}
+
 func TestTryAddIPToENI(t *testing.T) {
 	_ = os.Unsetenv(envCustomNetworkCfg)
 	m := setup(t)
@@ -732,8 +863,8 @@ func TestTryAddIPToENI(t *testing.T) {
 
 	mockContext.dataStore = testDatastore()
 
-	m.awsutils.EXPECT().AllocENI(false, nil, "").Return(secENIid, nil)
-	m.awsutils.EXPECT().AllocIPAddresses(secENIid, warmIPTarget)
+	m.awsutils.EXPECT().AllocENI(gomock.Any(), false, nil, "").Return(secENIid, nil)
+	m.awsutils.EXPECT().AllocIPAddresses(gomock.Any(), secENIid, warmIPTarget)
 	eniMetadata := []awsutils.ENIMetadata{
 		{
 			ENIID:          primaryENIid,
@@ -764,8 +895,9 @@ func TestTryAddIPToENI(t *testing.T) {
 			},
 		},
 	}
-	m.awsutils.EXPECT().WaitForENIAndIPsAttached(secENIid, 3).Return(eniMetadata[1], nil)
+	m.awsutils.EXPECT().WaitForENIAndIPsAttached(gomock.Any(), secENIid, 3).Return(eniMetadata[1], nil)
 	m.awsutils.EXPECT().GetPrimaryENI().Return(primaryENIid)
+	m.network.EXPECT().FindRouteTableConflicts(secMAC, secDevice).Return(nil, nil)
 	m.network.EXPECT().SetupENINetwork(gomock.Any(), secMAC, secDevice, secSubnet)
 
 	mockContext.myNodeName = myNodeName
@@ -803,7 +935,7 @@ func TestNodeIPPoolReconcile(t *testing.T) {
 	m.awsutils.EXPECT().GetPrimaryENI().AnyTimes().Return(primaryENIid)
 	m.awsutils.EXPECT().IsUnmanagedENI(primaryENIid).AnyTimes().Return(false)
 	m.awsutils.EXPECT().IsCNIUnmanagedENI(primaryENIid).AnyTimes().Return(false)
-	m.awsutils.EXPECT().TagENI(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	m.awsutils.EXPECT().TagENI(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 	eniMetadataList := []awsutils.ENIMetadata{primaryENIMetadata}
 	m.awsutils.EXPECT().GetAttachedENIs().Return(eniMetadataList, nil)
 	resp := awsutils.DescribeAllENIsResult{
@@ -813,7 +945,7 @@ func TestNodeIPPoolReconcile(t *testing.T) {
 		EFAENIs:         make(map[string]bool),
 		MultiCardENIIDs: nil,
 	}
-	m.awsutils.EXPECT().DescribeAllENIs().Return(resp, nil)
+	m.awsutils.EXPECT().DescribeAllENIs(gomock.Any()).Return(resp, nil)
 
 	m.awsutils.EXPECT().SetCNIUnmanagedENIs(resp.MultiCardENIIDs).AnyTimes()
 	mockContext.nodeIPPoolReconcile(ctx, 0)
@@ -837,7 +969,7 @@ func TestNodeIPPoolReconcile(t *testing.T) {
 		},
 	}
 	m.awsutils.EXPECT().GetAttachedENIs().Return(oneIPUnassigned, nil)
-	m.awsutils.EXPECT().GetIPv4sFromEC2(primaryENIid).Return(oneIPUnassigned[0].IPv4Addresses, nil)
+	m.awsutils.EXPECT().GetIPv4sFromEC2(gomock.Any(), primaryENIid).Return(oneIPUnassigned[0].IPv4Addresses, nil)
 
 	mockContext.nodeIPPoolReconcile(ctx, 0)
 	curENIs = mockContext.dataStore.GetENIInfos()
@@ -860,7 +992,8 @@ func TestNodeIPPoolReconcile(t *testing.T) {
 		EFAENIs:         make(map[string]bool),
 		MultiCardENIIDs: nil,
 	}
-	m.awsutils.EXPECT().DescribeAllENIs().Return(resp2, nil)
+	m.awsutils.EXPECT().DescribeAllENIs(gomock.Any()).Return(resp2, nil)
+	m.network.EXPECT().FindRouteTableConflicts(secMAC, secDevice).Return(nil, nil)
 	m.network.EXPECT().SetupENINetwork(gomock.Any(), secMAC, secDevice, primarySubnet)
 	m.awsutils.EXPECT().SetCNIUnmanagedENIs(resp2.MultiCardENIIDs).AnyTimes()
 
@@ -903,7 +1036,7 @@ func TestNodePrefixPoolReconcile(t *testing.T) {
 	m.awsutils.EXPECT().GetPrimaryENI().AnyTimes().Return(primaryENIid)
 	m.awsutils.EXPECT().IsUnmanagedENI(primaryENIid).AnyTimes().Return(false)
 	m.awsutils.EXPECT().IsCNIUnmanagedENI(primaryENIid).AnyTimes().Return(false)
-	m.awsutils.EXPECT().TagENI(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	m.awsutils.EXPECT().TagENI(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 	eniMetadataList := []awsutils.ENIMetadata{primaryENIMetadata}
 	m.awsutils.EXPECT().GetAttachedENIs().Return(eniMetadataList, nil)
 	resp := awsutils.DescribeAllENIsResult{
@@ -912,7 +1045,7 @@ func TestNodePrefixPoolReconcile(t *testing.T) {
 		TrunkENI:    "",
 		EFAENIs:     make(map[string]bool),
 	}
-	m.awsutils.EXPECT().DescribeAllENIs().Return(resp, nil)
+	m.awsutils.EXPECT().DescribeAllENIs(gomock.Any()).Return(resp, nil)
 
 	m.awsutils.EXPECT().SetCNIUnmanagedENIs(resp.MultiCardENIIDs).AnyTimes()
 	mockContext.nodeIPPoolReconcile(ctx, 0)
@@ -938,8 +1071,8 @@ func TestNodePrefixPoolReconcile(t *testing.T) {
 		},
 	}
 	m.awsutils.EXPECT().GetAttachedENIs().Return(oneIPUnassigned, nil)
-	m.awsutils.EXPECT().GetIPv4PrefixesFromEC2(primaryENIid).Return(oneIPUnassigned[0].IPv4Prefixes, nil)
-	//m.awsutils.EXPECT().GetIPv4sFromEC2(primaryENIid).Return(oneIPUnassigned[0].IPv4Addresses, nil)
+	m.awsutils.EXPECT().GetIPv4PrefixesFromEC2(gomock.Any(), primaryENIid).Return(oneIPUnassigned[0].IPv4Prefixes, nil)
+	//m.awsutils.EXPECT().GetIPv4sFromEC2(gomock.Any(), primaryENIid).Return(oneIPUnassigned[0].IPv4Addresses, nil)
 
 	mockContext.nodeIPPoolReconcile(ctx, 0)
 	curENIs = mockContext.dataStore.GetENIInfos()
@@ -961,7 +1094,8 @@ func TestNodePrefixPoolReconcile(t *testing.T) {
 		TrunkENI:    "",
 		EFAENIs:     make(map[string]bool),
 	}
-	m.awsutils.EXPECT().DescribeAllENIs().Return(resp2, nil)
+	m.awsutils.EXPECT().DescribeAllENIs(gomock.Any()).Return(resp2, nil)
+	m.network.EXPECT().FindRouteTableConflicts(secMAC, secDevice).Return(nil, nil)
 	m.network.EXPECT().SetupENINetwork(gomock.Any(), secMAC, secDevice, primarySubnet)
 	m.awsutils.EXPECT().SetCNIUnmanagedENIs(resp2.MultiCardENIIDs).AnyTimes()
 
@@ -981,6 +1115,62 @@ func TestNodePrefixPoolReconcile(t *testing.T) {
 	assert.Equal(t, 0, curENIs.TotalIPs)
 }
 
+func TestUpdateCIDRsRulesOnChangeWithExcludeSNATConfigMap(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+	ctx := context.Background()
+
+	mockContext := &IPAMContext{
+		awsClient:                 m.awsutils,
+		rawK8SClient:              m.rawK8SClient,
+		networkClient:             m.network,
+		enableIPv4:                true,
+		excludeSNATCIDRsConfigMap: &types.NamespacedName{Namespace: "kube-system", Name: "snat-exclusions"},
+	}
+
+	vpcCIDRs := []string{"10.0.0.0/16"}
+	primaryIP := net.ParseIP(ipaddr01)
+	m.awsutils.EXPECT().GetVPCIPv4CIDRs().Return(vpcCIDRs, nil).Times(3)
+	m.awsutils.EXPECT().GetLocalIPv4().Return(primaryIP)
+	m.awsutils.EXPECT().GetPrimaryENImac().Return(primaryMAC)
+
+	// ConfigMap not created yet, nothing to update
+	m.network.EXPECT().SetDynamicExcludeSNATCIDRs(nil).Return(false)
+	m.network.EXPECT().SetDynamicSNATCIDRs(nil).Return(false)
+	m.network.EXPECT().HostIptablesRulesModified().Return(false, nil)
+	assert.Equal(t, vpcCIDRs, mockContext.updateCIDRsRulesOnChange(vpcCIDRs))
+
+	configMap := v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "snat-exclusions", Namespace: "kube-system"},
+		Data:       map[string]string{excludeSNATCIDRsConfigMapKey: "192.168.0.0/16,172.16.0.0/12", snatCIDRsConfigMapKey: "10.200.0.0/16"},
+	}
+	_ = m.rawK8SClient.Create(ctx, &configMap)
+
+	m.network.EXPECT().SetDynamicExcludeSNATCIDRs([]string{"192.168.0.0/16", "172.16.0.0/12"}).Return(true)
+	m.network.EXPECT().SetDynamicSNATCIDRs([]string{"10.200.0.0/16"}).Return(true)
+	m.network.EXPECT().UpdateHostIptablesRules(vpcCIDRs, primaryMAC, &primaryIP, true, false).Return(nil)
+	assert.Equal(t, vpcCIDRs, mockContext.updateCIDRsRulesOnChange(vpcCIDRs))
+
+	// Unchanged ConfigMap does not reprogram iptables
+	m.network.EXPECT().SetDynamicExcludeSNATCIDRs([]string{"192.168.0.0/16", "172.16.0.0/12"}).Return(false)
+	m.network.EXPECT().SetDynamicSNATCIDRs([]string{"10.200.0.0/16"}).Return(false)
+	m.network.EXPECT().HostIptablesRulesModified().Return(false, nil)
+	assert.Equal(t, vpcCIDRs, mockContext.updateCIDRsRulesOnChange(vpcCIDRs))
+}
+
+func TestExcludeSNATCIDRsConfigMap(t *testing.T) {
+	defer os.Unsetenv(envExcludeSNATCIDRsConfigMap)
+
+	_ = os.Unsetenv(envExcludeSNATCIDRsConfigMap)
+	assert.Nil(t, excludeSNATCIDRsConfigMap())
+
+	_ = os.Setenv(envExcludeSNATCIDRsConfigMap, "snat-exclusions")
+	assert.Equal(t, &types.NamespacedName{Namespace: "kube-system", Name: "snat-exclusions"}, excludeSNATCIDRsConfigMap())
+
+	_ = os.Setenv(envExcludeSNATCIDRsConfigMap, "networking/snat-exclusions")
+	assert.Equal(t, &types.NamespacedName{Namespace: "networking", Name: "snat-exclusions"}, excludeSNATCIDRsConfigMap())
+}
+
 func TestGetWarmENITarget(t *testing.T) {
 	m := setup(t)
 	defer m.ctrl.Finish()
@@ -1375,6 +1565,34 @@ func TestIPAMContext_filterUnmanagedENIs(t *testing.T) {
 	}
 }
 
+func TestIPAMContext_filterUnmanagedENIs_excludeEFAENIs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	eni1, eni2, eni3 := getDummyENIMetadata()
+	allENIs := []awsutils.ENIMetadata{eni1, eni2, eni3}
+
+	mockAWSUtils := mock_awsutils.NewMockAPIs(ctrl)
+	mockAWSUtils.EXPECT().IsUnmanagedENI(gomock.Any()).Return(false).AnyTimes()
+	mockAWSUtils.EXPECT().IsCNIUnmanagedENI(gomock.Any()).Return(false).AnyTimes()
+	mockAWSUtils.EXPECT().IsEFAENI(gomock.Any()).DoAndReturn(func(eni string) bool {
+		return eni == eni2.ENIID
+	}).AnyTimes()
+
+	c := &IPAMContext{
+		awsClient:      mockAWSUtils,
+		maxENI:         4,
+		excludeEFAENIs: true,
+	}
+	assert.Equal(t, []awsutils.ENIMetadata{eni1, eni3}, c.filterUnmanagedENIs(allENIs))
+	assert.Equal(t, 1, c.unmanagedENI)
+
+	// EFA ENIs are kept when the exclusion is disabled
+	c.excludeEFAENIs = false
+	assert.Equal(t, allENIs, c.filterUnmanagedENIs(allENIs))
+	assert.Equal(t, 0, c.unmanagedENI)
+}
+
 func TestIPAMContext_filterUnmanagedENIs_disableManageUntaggedMode(t *testing.T) {
 	ctrl := gomock.NewController(t)
 
@@ -1535,7 +1753,7 @@ func TestNodeIPPoolReconcileBadIMDSData(t *testing.T) {
 	}, nil)
 
 	// eniIPPoolReconcile() calls EC2 to get the actual count, but that call fails
-	m.awsutils.EXPECT().GetIPv4sFromEC2(primaryENIid).Return(nil, errors.New("ec2 API call failed"))
+	m.awsutils.EXPECT().GetIPv4sFromEC2(gomock.Any(), primaryENIid).Return(nil, errors.New("ec2 API call failed"))
 	mockContext.nodeIPPoolReconcile(ctx, 0)
 	curENIs = mockContext.dataStore.GetENIInfos()
 	assert.Equal(t, 1, len(curENIs.ENIs))
@@ -1557,7 +1775,7 @@ func TestNodeIPPoolReconcileBadIMDSData(t *testing.T) {
 	}, nil)
 
 	// eniIPPoolReconcile() calls EC2 to get the actual count that should still be 2
-	m.awsutils.EXPECT().GetIPv4sFromEC2(primaryENIid).Return(primaryENIMetadata.IPv4Addresses, nil)
+	m.awsutils.EXPECT().GetIPv4sFromEC2(gomock.Any(), primaryENIid).Return(primaryENIMetadata.IPv4Addresses, nil)
 	mockContext.nodeIPPoolReconcile(ctx, 0)
 	curENIs = mockContext.dataStore.GetENIInfos()
 	assert.Equal(t, 1, len(curENIs.ENIs))
@@ -1621,7 +1839,7 @@ func TestNodePrefixPoolReconcileBadIMDSData(t *testing.T) {
 	}, nil)
 
 	// eniIPPoolReconcile() calls EC2 to get the actual count, but that call fails
-	m.awsutils.EXPECT().GetIPv4PrefixesFromEC2(primaryENIid).Return(nil, errors.New("ec2 API call failed"))
+	m.awsutils.EXPECT().GetIPv4PrefixesFromEC2(gomock.Any(), primaryENIid).Return(nil, errors.New("ec2 API call failed"))
 	mockContext.nodeIPPoolReconcile(ctx, 0)
 	curENIs = mockContext.dataStore.GetENIInfos()
 	assert.Equal(t, 1, len(curENIs.ENIs))
@@ -1643,7 +1861,7 @@ func TestNodePrefixPoolReconcileBadIMDSData(t *testing.T) {
 	}, nil)
 
 	// eniIPPoolReconcile() calls EC2 to get the actual count that should still be 16
-	m.awsutils.EXPECT().GetIPv4PrefixesFromEC2(primaryENIid).Return(primaryENIMetadata.IPv4Prefixes, nil)
+	m.awsutils.EXPECT().GetIPv4PrefixesFromEC2(gomock.Any(), primaryENIid).Return(primaryENIMetadata.IPv4Prefixes, nil)
 	mockContext.nodeIPPoolReconcile(ctx, 0)
 	curENIs = mockContext.dataStore.GetENIInfos()
 	assert.Equal(t, 1, len(curENIs.ENIs))
@@ -1785,16 +2003,17 @@ func TestIPAMContext_setupENI(t *testing.T) {
 		},
 	}
 	m.awsutils.EXPECT().GetPrimaryENI().Return(primaryENIid)
-	err := mockContext.setupENI(primaryENIMetadata.ENIID, primaryENIMetadata, false, false)
+	err := mockContext.setupENI(context.Background(), primaryENIMetadata.ENIID, primaryENIMetadata, false, false)
 	assert.NoError(t, err)
 	// Primary ENI added
 	assert.Equal(t, 1, len(mockContext.primaryIP))
 
 	newENIMetadata := getSecondaryENIMetadata()
 	m.awsutils.EXPECT().GetPrimaryENI().Return(primaryENIid)
+	m.network.EXPECT().FindRouteTableConflicts(secMAC, secDevice).Return(nil, nil)
 	m.network.EXPECT().SetupENINetwork(gomock.Any(), secMAC, secDevice, primarySubnet).Return(errors.New("not able to set route 0.0.0.0/0 via 10.10.10.1 table 2"))
 
-	err = mockContext.setupENI(newENIMetadata.ENIID, newENIMetadata, false, false)
+	err = mockContext.setupENI(context.Background(), newENIMetadata.ENIID, newENIMetadata, false, false)
 	assert.Error(t, err)
 	assert.Equal(t, 1, len(mockContext.primaryIP))
 }
@@ -1831,16 +2050,17 @@ func TestIPAMContext_setupENIwithPDenabled(t *testing.T) {
 		},
 	}
 	m.awsutils.EXPECT().GetPrimaryENI().Return(primaryENIid)
-	err := mockContext.setupENI(primaryENIMetadata.ENIID, primaryENIMetadata, false, false)
+	err := mockContext.setupENI(context.Background(), primaryENIMetadata.ENIID, primaryENIMetadata, false, false)
 	assert.NoError(t, err)
 	// Primary ENI added
 	assert.Equal(t, 1, len(mockContext.primaryIP))
 
 	newENIMetadata := getSecondaryENIMetadata()
 	m.awsutils.EXPECT().GetPrimaryENI().Return(primaryENIid)
+	m.network.EXPECT().FindRouteTableConflicts(secMAC, secDevice).Return(nil, nil)
 	m.network.EXPECT().SetupENINetwork(gomock.Any(), secMAC, secDevice, primarySubnet).Return(errors.New("not able to set route 0.0.0.0/0 via 10.10.10.1 table 2"))
 
-	err = mockContext.setupENI(newENIMetadata.ENIID, newENIMetadata, false, false)
+	err = mockContext.setupENI(context.Background(), newENIMetadata.ENIID, newENIMetadata, false, false)
 	assert.Error(t, err)
 	assert.Equal(t, 1, len(mockContext.primaryIP))
 }
@@ -1913,6 +2133,9 @@ func TestIsConfigValid(t *testing.T) {
 		customNetworkingEnabled bool
 		podENIEnabled           bool
 		isNitroInstance         bool
+		staticIPPool            bool
+		eniLimit                int
+		externalSNAT            bool
 	}
 
 	tests := []struct {
@@ -2001,6 +2224,65 @@ func TestIsConfigValid(t *testing.T) {
 			},
 			want: true,
 		},
+		{
+			name: "static IP pool in v4 secondary IP mode",
+			fields: fields{
+				ipV4Enabled:  true,
+				staticIPPool: true,
+			},
+			want: true,
+		},
+		{
+			name: "static IP pool in v4 PD mode",
+			fields: fields{
+				ipV4Enabled:             true,
+				prefixDelegationEnabled: true,
+				staticIPPool:            true,
+			},
+			want: false,
+		},
+		{
+			name: "v4 enabled in PD mode on Non-Nitro instance falls back to secondary IP mode",
+			fields: fields{
+				ipV4Enabled:             true,
+				prefixDelegationEnabled: true,
+				isNitroInstance:         false,
+			},
+			want: true,
+		},
+		{
+			name: "custom networking and ppsg leave no ENI for pod IPs",
+			fields: fields{
+				ipV4Enabled:             true,
+				customNetworkingEnabled: true,
+				podENIEnabled:           true,
+				isNitroInstance:         true,
+				eniLimit:                2,
+			},
+			want: false,
+		},
+		{
+			name: "custom networking and ppsg with ENIs left for pod IPs",
+			fields: fields{
+				ipV4Enabled:             true,
+				customNetworkingEnabled: true,
+				podENIEnabled:           true,
+				isNitroInstance:         true,
+				eniLimit:                3,
+			},
+			want: true,
+		},
+		{
+			name: "external SNAT in v6 mode is ignored",
+			fields: fields{
+				ipV4Enabled:             false,
+				ipV6Enabled:             true,
+				prefixDelegationEnabled: true,
+				isNitroInstance:         true,
+				externalSNAT:            true,
+			},
+			want: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -2008,14 +2290,12 @@ func TestIsConfigValid(t *testing.T) {
 			m := setup(t)
 			defer m.ctrl.Finish()
 
-			if tt.fields.prefixDelegationEnabled && !(tt.fields.podENIEnabled && tt.fields.ipV6Enabled) {
-				if tt.fields.isNitroInstance {
-					m.awsutils.EXPECT().IsPrefixDelegationSupported().Return(true)
-				} else {
-					m.awsutils.EXPECT().GetInstanceType().Return("dummy-instance")
-					m.awsutils.EXPECT().IsPrefixDelegationSupported().Return(false)
-				}
-			}
+			// The capability check looks up the instance and the SNAT mode once, whatever the features
+			m.awsutils.EXPECT().GetInstanceType().Return("dummy-instance")
+			m.awsutils.EXPECT().GetRegion().Return("us-west-2")
+			m.awsutils.EXPECT().IsPrefixDelegationSupported().Return(tt.fields.isNitroInstance)
+			m.awsutils.EXPECT().GetENILimit().Return(tt.fields.eniLimit)
+			m.network.EXPECT().UseExternalSNAT().Return(tt.fields.externalSNAT)
 			ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, tt.fields.prefixDelegationEnabled)
 
 			mockContext := &IPAMContext{
@@ -2026,11 +2306,15 @@ func TestIsConfigValid(t *testing.T) {
 				enablePrefixDelegation: tt.fields.prefixDelegationEnabled,
 				enablePodENI:           tt.fields.podENIEnabled,
 				useCustomNetworking:    tt.fields.customNetworkingEnabled,
+				staticIPPool:           tt.fields.staticIPPool,
 				dataStore:              ds,
 			}
 
 			resp := mockContext.isConfigValid()
 			assert.Equal(t, tt.want, resp)
+			if resp && tt.fields.prefixDelegationEnabled {
+				assert.Equal(t, tt.fields.isNitroInstance, mockContext.enablePrefixDelegation)
+			}
 		})
 	}
 
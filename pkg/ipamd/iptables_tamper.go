@@ -0,0 +1,52 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/eventrecorder"
+)
+
+// iptablesTamperedReason is the reason of the node event raised when the host iptables rules had to be reprogrammed
+const iptablesTamperedReason = "IptablesRulesModified"
+
+// repairHostIptablesRules reprograms the host iptables rules if another agent, e.g. a restarted kube-proxy or some
+// security tooling, modified or flushed them since ipamd last programmed them. Lost SNAT rules otherwise silently
+// break the non-VPC traffic of the pods until aws-node restarts.
+func (c *IPAMContext) repairHostIptablesRules(vpcCIDRs []string) {
+	// Hold the lock so that an update in flight isn't mistaken for a modification
+	c.hostIptablesLock.Lock()
+	modified, err := c.networkClient.HostIptablesRulesModified()
+	c.hostIptablesLock.Unlock()
+	if err != nil {
+		log.Warnf("Failed to check the host iptables rules: %v", err)
+		ipamdErrInc("repairHostIptablesRules")
+		return
+	}
+	if !modified {
+		return
+	}
+
+	message := "Host iptables rules of the CNI were modified by another agent, reprogramming them"
+	log.Warn(message)
+	iptablesTamperCnt.Inc()
+	if c.enableIptablesTamperEvents {
+		eventrecorder.Get().SendNodeEvent(corev1.EventTypeWarning, iptablesTamperedReason, message)
+	}
+	if err := c.updateHostIptablesRules(vpcCIDRs); err != nil {
+		log.Errorf("Failed to reprogram the host iptables rules: %v", err)
+		ipamdErrInc("repairHostIptablesRules")
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/eventrecorder"
+)
+
+func TestRepairHostIptablesRules(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	fakeRecorder := eventrecorder.InitMockEventRecorder(m.cachedK8SClient)
+	mockContext := &IPAMContext{
+		awsClient:                  m.awsutils,
+		networkClient:              m.network,
+		enableIPv4:                 true,
+		enableIptablesTamperEvents: true,
+	}
+	vpcCIDRs := []string{"10.0.0.0/16"}
+	tampered := testutil.ToFloat64(iptablesTamperCnt)
+
+	// Untouched rules are left alone
+	m.network.EXPECT().HostIptablesRulesModified().Return(false, nil)
+	mockContext.repairHostIptablesRules(vpcCIDRs)
+	assert.Equal(t, tampered, testutil.ToFloat64(iptablesTamperCnt))
+
+	// A failed check doesn't reprogram anything either
+	m.network.EXPECT().HostIptablesRulesModified().Return(false, errors.New("iptables lock held"))
+	mockContext.repairHostIptablesRules(vpcCIDRs)
+	assert.Equal(t, tampered, testutil.ToFloat64(iptablesTamperCnt))
+	assert.Len(t, fakeRecorder.Events, 0)
+
+	primaryIP := net.ParseIP(ipaddr01)
+	m.network.EXPECT().HostIptablesRulesModified().Return(true, nil)
+	m.awsutils.EXPECT().GetLocalIPv4().Return(primaryIP)
+	m.awsutils.EXPECT().GetPrimaryENImac().Return(primaryMAC)
+	m.network.EXPECT().UpdateHostIptablesRules(vpcCIDRs, primaryMAC, &primaryIP, true, false).Return(nil)
+	mockContext.repairHostIptablesRules(vpcCIDRs)
+	assert.Equal(t, tampered+1, testutil.ToFloat64(iptablesTamperCnt))
+	assert.Len(t, fakeRecorder.Events, 1)
+	assert.Contains(t, <-fakeRecorder.Events, "Warning "+iptablesTamperedReason)
+}
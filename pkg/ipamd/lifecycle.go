@@ -0,0 +1,52 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/lifecycle"
+)
+
+// lifecycleRefreshInterval is how often the target lifecycle state of the instance is read from IMDS
+const lifecycleRefreshInterval = 30 * time.Second
+
+// StartLifecycleWatcher polls the target lifecycle state of the instance, so that no ENI is detached while the Auto
+// Scaling group takes the instance out of service
+func (c *IPAMContext) StartLifecycleWatcher() {
+	c.lifecycleWatcher.Run(context.Background())
+}
+
+// eniDetachBlocker returns why ENIs must not be detached right now, or "" if it's safe. While the node is drained, its
+// pods are evicted and their IPs released, which would free ENIs that the pods rescheduled after an uncordon, or the
+// replacement instance of an Auto Scaling rebalance, need again.
+func (c *IPAMContext) eniDetachBlocker(ctx context.Context) string {
+	if c.isTerminating() {
+		return "aws-node is terminating"
+	}
+	node := &corev1.Node{}
+	if err := c.cachedK8SClient.Get(ctx, types.NamespacedName{Name: c.myNodeName}, node); err != nil {
+		log.Errorf("Failed to get node while checking if ENIs can be detached: %v", err)
+	} else if reason := lifecycle.NodeDrainReason(node); reason != "" {
+		return reason
+	}
+	if c.lifecycleWatcher != nil {
+		return c.lifecycleWatcher.ScalingReason()
+	}
+	return ""
+}
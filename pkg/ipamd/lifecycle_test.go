@@ -0,0 +1,60 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/lifecycle"
+)
+
+func TestENIDetachBlocker(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+	ctx := context.Background()
+
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: myNodeName}}
+	assert.NoError(t, m.cachedK8SClient.Create(ctx, node))
+	mockContext := &IPAMContext{
+		awsClient:        m.awsutils,
+		cachedK8SClient:  m.cachedK8SClient,
+		myNodeName:       myNodeName,
+		lifecycleWatcher: lifecycle.NewWatcher(m.awsutils, lifecycleRefreshInterval),
+		dataStore:        datastore.NewDataStore(log, datastore.NullCheckpoint{}, false),
+		poolDecisions:    newPoolDecisionLog(10, datastore.NullCheckpoint{}),
+	}
+	assert.Equal(t, "", mockContext.eniDetachBlocker(ctx))
+
+	m.awsutils.EXPECT().GetTargetLifecycleState(gomock.Any()).Return("Terminated", nil)
+	mockContext.lifecycleWatcher.Refresh(ctx)
+	assert.Equal(t, "instance target lifecycle state is Terminated", mockContext.eniDetachBlocker(ctx))
+
+	m.awsutils.EXPECT().GetTargetLifecycleState(gomock.Any()).Return(lifecycle.InService, nil)
+	mockContext.lifecycleWatcher.Refresh(ctx)
+	node.Spec.Unschedulable = true
+	assert.NoError(t, m.cachedK8SClient.Update(ctx, node))
+	assert.Equal(t, "node is cordoned", mockContext.eniDetachBlocker(ctx))
+
+	// No ENI is freed, and the decision says why
+	mockContext.tryFreeENI(ctx)
+	assert.Equal(t, "node is cordoned", mockContext.poolDecisions.list(time.Time{}, poolOperationFreeENI)[0].Reason)
+}
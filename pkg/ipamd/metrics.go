@@ -20,6 +20,7 @@ import (
 	"time"
 
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/retry"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
@@ -55,6 +56,11 @@ func (c *IPAMContext) ServeMetrics() {
 func (c *IPAMContext) setupMetricsServer() *http.Server {
 	serveMux := http.NewServeMux()
 	serveMux.Handle("/metrics", promhttp.Handler())
+	if c.clusterMetrics != nil {
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(c.clusterMetrics)
+		serveMux.Handle("/cluster-metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	}
 	server := &http.Server{
 		Addr:         ":" + strconv.Itoa(metricsPort),
 		Handler:      serveMux,
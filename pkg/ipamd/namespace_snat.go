@@ -0,0 +1,151 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// parseNamespaceSNATIPs parses a comma or whitespace separated list of <namespace>=<IPv4 address> entries. Invalid
+// entries are logged and skipped.
+func parseNamespaceSNATIPs(value string) map[string]string {
+	namespaceSNATIPs := make(map[string]string)
+	entries := strings.FieldsFunc(value, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+	for _, entry := range entries {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			log.Errorf("Ignoring namespace SNAT entry %q, expected <namespace>=<IPv4 address>", entry)
+			continue
+		}
+		ip := net.ParseIP(parts[1])
+		if ip == nil || ip.To4() == nil {
+			log.Errorf("Ignoring namespace SNAT entry %q, %q is not a valid IPv4 address", entry, parts[1])
+			continue
+		}
+		namespaceSNATIPs[parts[0]] = ip.String()
+	}
+	return namespaceSNATIPs
+}
+
+// loadNamespaceSNATIPs reads the namespace SNAT ConfigMap, if configured
+func (c *IPAMContext) loadNamespaceSNATIPs(ctx context.Context) {
+	if c.namespaceSNATConfigMap == nil {
+		return
+	}
+	var namespaceSNATIPs map[string]string
+	var configMap corev1.ConfigMap
+	err := c.rawK8SClient.Get(ctx, *c.namespaceSNATConfigMap, &configMap)
+	if err != nil {
+		if !k8serror.IsNotFound(err) {
+			log.Warnf("skipping periodic update to namespace SNAT, failed to get ConfigMap %s: %v",
+				c.namespaceSNATConfigMap.String(), err)
+			ipamdErrInc("loadNamespaceSNATIPs")
+			return
+		}
+		// A deleted ConfigMap removes all the namespace SNAT IPs
+		log.Debugf("Namespace SNAT ConfigMap %s not found", c.namespaceSNATConfigMap.String())
+	} else {
+		namespaceSNATIPs = parseNamespaceSNATIPs(configMap.Data[namespaceSNATConfigMapKey])
+	}
+
+	c.namespaceSNATLock.Lock()
+	defer c.namespaceSNATLock.Unlock()
+	if (len(namespaceSNATIPs) == 0 && len(c.namespaceSNATIPs) == 0) || reflect.DeepEqual(namespaceSNATIPs, c.namespaceSNATIPs) {
+		return
+	}
+	log.Infof("Namespace SNAT IPs from ConfigMap %s changed to %v", c.namespaceSNATConfigMap.String(), namespaceSNATIPs)
+	c.namespaceSNATIPs = namespaceSNATIPs
+}
+
+// updateNamespaceSNATFromConfigMap reloads the namespace SNAT ConfigMap, if configured, and hands the SNAT IPs of the
+// pods to the network client. It returns true if the host iptables rules need to be reprogrammed.
+func (c *IPAMContext) updateNamespaceSNATFromConfigMap(ctx context.Context) bool {
+	if c.namespaceSNATConfigMap == nil {
+		return false
+	}
+	c.loadNamespaceSNATIPs(ctx)
+	return c.networkClient.SetPodSNATIPs(c.podSNATIPs())
+}
+
+// namespaceSNATIP returns the IP that the pods of a namespace are SNATed to, or "" if they use the primary IP
+func (c *IPAMContext) namespaceSNATIP(namespace string) string {
+	c.namespaceSNATLock.RLock()
+	defer c.namespaceSNATLock.RUnlock()
+	return c.namespaceSNATIPs[namespace]
+}
+
+// isNamespaceSNATIP returns true if ip is used for namespace SNAT, and so must not be handed out to pods
+func (c *IPAMContext) isNamespaceSNATIP(ip string) bool {
+	c.namespaceSNATLock.RLock()
+	defer c.namespaceSNATLock.RUnlock()
+	for _, snatIP := range c.namespaceSNATIPs {
+		if snatIP == ip {
+			return true
+		}
+	}
+	return false
+}
+
+// unpooledNamespaceSNATIPs counts the namespace SNAT IPs attached to an ENI that are not in its IP pool
+func (c *IPAMContext) unpooledNamespaceSNATIPs(attachedENIIPs []*ec2.NetworkInterfacePrivateIpAddress, ipPool []string) int {
+	pooled := make(map[string]bool, len(ipPool))
+	for _, ip := range ipPool {
+		pooled[ip] = true
+	}
+	count := 0
+	for _, addr := range attachedENIIPs {
+		ip := aws.StringValue(addr.PrivateIpAddress)
+		if !pooled[ip] && c.isNamespaceSNATIP(ip) {
+			count++
+		}
+	}
+	return count
+}
+
+// podSNATIPs returns the IP that each pod of a mapped namespace is SNATed to, keyed by pod IP
+func (c *IPAMContext) podSNATIPs() map[string]string {
+	podSNATIPs := make(map[string]string)
+	for _, info := range c.dataStore.AllocatedIPs() {
+		if snatIP := c.namespaceSNATIP(info.Metadata.K8SPodNamespace); snatIP != "" {
+			podSNATIPs[info.IP] = snatIP
+		}
+	}
+	return podSNATIPs
+}
+
+// syncPodSNATIPs reprograms the namespace SNAT rules after a pod of namespace got or released its IP, so that the
+// traffic of a new pod leaves through the right address from the start, and a released IP doesn't keep the SNAT
+// rule of its previous pod.
+func (c *IPAMContext) syncPodSNATIPs(namespace string) {
+	if c.namespaceSNATIP(namespace) == "" || !c.networkClient.SetPodSNATIPs(c.podSNATIPs()) {
+		return
+	}
+	vpcCIDRs, err := c.awsClient.GetVPCIPv4CIDRs()
+	if err == nil {
+		err = c.updateHostIptablesRules(vpcCIDRs)
+	}
+	if err != nil {
+		log.Warnf("Failed to update the namespace SNAT rules of namespace %s: %v", namespace, err)
+		ipamdErrInc("syncPodSNATIPs")
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+)
+
+func TestParseNamespaceSNATIPs(t *testing.T) {
+	assert.Equal(t, map[string]string{}, parseNamespaceSNATIPs(""))
+	assert.Equal(t, map[string]string{"payments": "10.0.0.100", "billing": "10.0.0.101"},
+		parseNamespaceSNATIPs("payments=10.0.0.100,\n billing=10.0.0.101 default notanip=10.0.0 =10.0.0.102 v6=fd00::1"))
+}
+
+func TestNamespaceSNATConfigMap(t *testing.T) {
+	defer os.Unsetenv(envNamespaceSNATConfigMap)
+
+	_ = os.Unsetenv(envNamespaceSNATConfigMap)
+	assert.Nil(t, namespaceSNATConfigMap())
+
+	_ = os.Setenv(envNamespaceSNATConfigMap, "networking/namespace-snat")
+	assert.Equal(t, &types.NamespacedName{Namespace: "networking", Name: "namespace-snat"}, namespaceSNATConfigMap())
+}
+
+func TestUpdateNamespaceSNATFromConfigMap(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+	ctx := context.Background()
+
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP("10.0.0.1"), Mask: net.CIDRMask(32, 32)}, false))
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP("10.0.0.2"), Mask: net.CIDRMask(32, 32)}, false))
+	paymentsIP, _, err := ds.AssignPodIPv4Address(datastore.IPAMKey{NetworkName: "aws-cni", ContainerID: "cid-1", IfName: "eth0"},
+		datastore.IPAMMetadata{K8SPodNamespace: "payments", K8SPodName: "pod-1"})
+	assert.NoError(t, err)
+	_, _, err = ds.AssignPodIPv4Address(datastore.IPAMKey{NetworkName: "aws-cni", ContainerID: "cid-2", IfName: "eth0"},
+		datastore.IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "pod-2"})
+	assert.NoError(t, err)
+
+	mockContext := &IPAMContext{
+		awsClient:              m.awsutils,
+		rawK8SClient:           m.rawK8SClient,
+		networkClient:          m.network,
+		dataStore:              ds,
+		enableIPv4:             true,
+		namespaceSNATConfigMap: &types.NamespacedName{Namespace: "kube-system", Name: "namespace-snat"},
+	}
+
+	// ConfigMap not created yet, no pod is SNATed to its own IP
+	m.network.EXPECT().SetPodSNATIPs(map[string]string{}).Return(false)
+	assert.False(t, mockContext.updateNamespaceSNATFromConfigMap(ctx))
+
+	configMap := v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "namespace-snat", Namespace: "kube-system"},
+		Data:       map[string]string{namespaceSNATConfigMapKey: "payments=10.0.0.100"},
+	}
+	assert.NoError(t, m.rawK8SClient.Create(ctx, &configMap))
+	m.network.EXPECT().SetPodSNATIPs(map[string]string{paymentsIP: "10.0.0.100"}).Return(true)
+	assert.True(t, mockContext.updateNamespaceSNATFromConfigMap(ctx))
+	assert.True(t, mockContext.isNamespaceSNATIP("10.0.0.100"))
+	assert.False(t, mockContext.isNamespaceSNATIP(paymentsIP))
+
+	// The SNAT IP is kept out of the datastore
+	mockContext.addENIsecondaryIPsToDataStore([]*ec2.NetworkInterfacePrivateIpAddress{
+		{PrivateIpAddress: aws.String("10.0.0.100")}, {PrivateIpAddress: aws.String("10.0.0.3")}}, "eni-1")
+	assert.Equal(t, 3, ds.GetIPStats(ipV4AddrFamily).TotalIPs)
+	assert.Equal(t, 1, mockContext.unpooledNamespaceSNATIPs([]*ec2.NetworkInterfacePrivateIpAddress{
+		{PrivateIpAddress: aws.String("10.0.0.100")}, {PrivateIpAddress: aws.String("10.0.0.3")}}, []string{"10.0.0.3"}))
+
+	// A new pod in the namespace is SNATed right away
+	podIP, _, err := ds.AssignPodIPv4Address(datastore.IPAMKey{NetworkName: "aws-cni", ContainerID: "cid-3", IfName: "eth0"},
+		datastore.IPAMMetadata{K8SPodNamespace: "payments", K8SPodName: "pod-3"})
+	assert.NoError(t, err)
+	vpcCIDRs := []string{"10.0.0.0/16"}
+	primaryIP := net.ParseIP(ipaddr01)
+	m.network.EXPECT().SetPodSNATIPs(map[string]string{paymentsIP: "10.0.0.100", podIP: "10.0.0.100"}).Return(true)
+	m.awsutils.EXPECT().GetVPCIPv4CIDRs().Return(vpcCIDRs, nil)
+	m.awsutils.EXPECT().GetLocalIPv4().Return(primaryIP)
+	m.awsutils.EXPECT().GetPrimaryENImac().Return(primaryMAC)
+	m.network.EXPECT().UpdateHostIptablesRules(vpcCIDRs, primaryMAC, &primaryIP, true, false).Return(nil)
+	mockContext.syncPodSNATIPs("payments")
+
+	// Pods of other namespaces don't touch the rules
+	mockContext.syncPodSNATIPs("default")
+
+	// A deleted ConfigMap removes the mapping
+	assert.NoError(t, m.rawK8SClient.Delete(ctx, &configMap))
+	m.network.EXPECT().SetPodSNATIPs(map[string]string{}).Return(true)
+	assert.True(t, mockContext.updateNamespaceSNATFromConfigMap(ctx))
+	assert.False(t, mockContext.isNamespaceSNATIP("10.0.0.100"))
+}
@@ -0,0 +1,82 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// defaultNAT64Prefix is the well-known prefix of RFC 6052, the one synthesized by the DNS64 of Route 53 Resolver
+	defaultNAT64Prefix = "64:ff9b::/96"
+
+	// nat64GatewayNodeKey is the node annotation overriding NAT64_GATEWAY for a single node, e.g. to use the NAT64
+	// gateway of its availability zone. Labels can't hold IPv6 addresses, so there is no label equivalent.
+	nat64GatewayNodeKey = "vpc.amazonaws.com/nat64-gateway"
+)
+
+// setupNAT64Route routes the NAT64 prefix out of the primary ENI, so that pods of IPv6-only clusters can reach
+// IPv4-only endpoints without an IPv4 address of their own
+func (c *IPAMContext) setupNAT64Route(ctx context.Context) error {
+	prefix, err := nat64Prefix()
+	if err != nil {
+		return err
+	}
+	gateway, err := c.nat64Gateway(ctx)
+	if err != nil {
+		return err
+	}
+	return c.networkClient.SetupNAT64Route(c.awsClient.GetPrimaryENImac(), prefix, gateway)
+}
+
+// nat64Prefix returns the IPv6 prefix set through NAT64_PREFIX, or the well-known prefix
+func nat64Prefix() (*net.IPNet, error) {
+	value := strings.TrimSpace(os.Getenv(envNAT64Prefix))
+	if value == "" {
+		value = defaultNAT64Prefix
+	}
+	ip, prefix, err := net.ParseCIDR(value)
+	if err != nil || ip.To4() != nil {
+		return nil, errors.Errorf("%s=%q is not an IPv6 CIDR", envNAT64Prefix, value)
+	}
+	return prefix, nil
+}
+
+// nat64Gateway returns the NAT64 gateway set on the node, or through NAT64_GATEWAY. It returns nil if neither is set,
+// in which case the route goes through the IPv6 default gateway of the primary ENI.
+func (c *IPAMContext) nat64Gateway(ctx context.Context) (net.IP, error) {
+	name, value := envNAT64Gateway, strings.TrimSpace(os.Getenv(envNAT64Gateway))
+	node := &corev1.Node{}
+	err := c.cachedK8SClient.Get(ctx, types.NamespacedName{Name: c.myNodeName}, node)
+	if err != nil {
+		log.Warnf("Failed to get node to look up the %s annotation, using %s: %v", nat64GatewayNodeKey, envNAT64Gateway, err)
+	} else if override, found := node.Annotations[nat64GatewayNodeKey]; found {
+		name, value = nat64GatewayNodeKey, strings.TrimSpace(override)
+	}
+	if value == "" {
+		return nil, nil
+	}
+	gateway := net.ParseIP(value)
+	if gateway == nil || gateway.To4() != nil {
+		return nil, errors.Errorf("%s=%q is not an IPv6 address", name, value)
+	}
+	return gateway, nil
+}
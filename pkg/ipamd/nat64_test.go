@@ -0,0 +1,81 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNAT64Prefix(t *testing.T) {
+	defer os.Unsetenv(envNAT64Prefix)
+
+	_ = os.Unsetenv(envNAT64Prefix)
+	prefix, err := nat64Prefix()
+	assert.NoError(t, err)
+	assert.Equal(t, "64:ff9b::/96", prefix.String())
+
+	_ = os.Setenv(envNAT64Prefix, "2001:db8:64::/96")
+	prefix, err = nat64Prefix()
+	assert.NoError(t, err)
+	assert.Equal(t, "2001:db8:64::/96", prefix.String())
+
+	_ = os.Setenv(envNAT64Prefix, "10.0.0.0/8")
+	_, err = nat64Prefix()
+	assert.Error(t, err)
+}
+
+func TestSetupNAT64Route(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+	defer os.Unsetenv(envNAT64Gateway)
+	ctx := context.Background()
+
+	mockContext := &IPAMContext{
+		awsClient:       m.awsutils,
+		networkClient:   m.network,
+		cachedK8SClient: m.cachedK8SClient,
+		myNodeName:      myNodeName,
+		enableIPv6:      true,
+		enableNAT64:     true,
+	}
+	_, prefix, _ := net.ParseCIDR(defaultNAT64Prefix)
+	m.awsutils.EXPECT().GetPrimaryENImac().Return(primaryMAC).AnyTimes()
+
+	// Neither the node nor the env var set a gateway, the default gateway of the primary ENI is used
+	_ = os.Unsetenv(envNAT64Gateway)
+	m.network.EXPECT().SetupNAT64Route(primaryMAC, prefix, nil).Return(nil)
+	assert.NoError(t, mockContext.setupNAT64Route(ctx))
+
+	_ = os.Setenv(envNAT64Gateway, "2001:db8::64")
+	m.network.EXPECT().SetupNAT64Route(primaryMAC, prefix, net.ParseIP("2001:db8::64")).Return(nil)
+	assert.NoError(t, mockContext.setupNAT64Route(ctx))
+
+	// The node annotation takes precedence
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: myNodeName,
+		Annotations: map[string]string{nat64GatewayNodeKey: "2001:db8:1::64"}}}
+	assert.NoError(t, m.cachedK8SClient.Create(ctx, node))
+	m.network.EXPECT().SetupNAT64Route(primaryMAC, prefix, net.ParseIP("2001:db8:1::64")).Return(nil)
+	assert.NoError(t, mockContext.setupNAT64Route(ctx))
+
+	node.Annotations[nat64GatewayNodeKey] = "10.0.0.64"
+	assert.NoError(t, m.cachedK8SClient.Update(ctx, node))
+	assert.Error(t, mockContext.setupNAT64Route(ctx))
+}
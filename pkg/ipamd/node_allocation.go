@@ -0,0 +1,131 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/apis/crd/v1alpha1"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+)
+
+// nodeAllocationMinInterval is the minimum time between two updates of the NodeAllocation of the node, so that pod
+// churn on many nodes doesn't turn into a stream of API server writes
+const nodeAllocationMinInterval = 30 * time.Second
+
+// nodeAllocationStatus returns the ENIs, CIDRs and pod IPs of the datastore. Only the namespace, name and IP of the
+// pods are published, and the allocations recovered from the routes, which have no pod, are only counted.
+func (c *IPAMContext) nodeAllocationStatus() v1alpha1.NodeAllocationStatus {
+	eniInfos := c.dataStore.GetENIInfos()
+	status := v1alpha1.NodeAllocationStatus{
+		TotalIPs:    eniInfos.TotalIPs,
+		AssignedIPs: eniInfos.AssignedIPs,
+	}
+	for _, eni := range eniInfos.ENIs {
+		allocationENI := v1alpha1.NodeAllocationENI{
+			ID:           eni.ID,
+			DeviceNumber: eni.DeviceNumber,
+			Primary:      eni.IsPrimary,
+			Trunk:        eni.IsTrunk,
+		}
+		for _, cidrs := range []map[string]*datastore.CidrInfo{eni.AvailableIPv4Cidrs, eni.IPv6Cidrs} {
+			for cidrKey, cidr := range cidrs {
+				allocationENI.CIDRs = append(allocationENI.CIDRs, cidrKey)
+				for _, addr := range cidr.IPAddresses {
+					if !addr.Assigned() || addr.IPAMMetadata.K8SPodName == "" {
+						continue
+					}
+					allocationENI.Pods = append(allocationENI.Pods, v1alpha1.NodeAllocationPod{
+						Namespace: addr.IPAMMetadata.K8SPodNamespace,
+						Name:      addr.IPAMMetadata.K8SPodName,
+						IP:        addr.Address,
+					})
+				}
+			}
+		}
+		sort.Strings(allocationENI.CIDRs)
+		sort.Slice(allocationENI.Pods, func(i, j int) bool {
+			if allocationENI.Pods[i].Namespace != allocationENI.Pods[j].Namespace {
+				return allocationENI.Pods[i].Namespace < allocationENI.Pods[j].Namespace
+			}
+			return allocationENI.Pods[i].Name < allocationENI.Pods[j].Name
+		})
+		status.ENIs = append(status.ENIs, allocationENI)
+	}
+	sort.Slice(status.ENIs, func(i, j int) bool { return status.ENIs[i].DeviceNumber < status.ENIs[j].DeviceNumber })
+	return status
+}
+
+// publishNodeAllocation updates the status of the NodeAllocation of the node when the allocation state changed, at
+// most once per nodeAllocationMinInterval
+func (c *IPAMContext) publishNodeAllocation(ctx context.Context) {
+	if !c.enableNodeAllocation || c.enableIPv6 || time.Since(c.nodeAllocationPublishedAt) < nodeAllocationMinInterval {
+		return
+	}
+	status := c.nodeAllocationStatus()
+	if c.publishedNodeAllocation != nil && reflect.DeepEqual(status, *c.publishedNodeAllocation) {
+		return
+	}
+	// A failed update waits for the next interval too
+	c.nodeAllocationPublishedAt = time.Now()
+	if err := c.updateNodeAllocation(ctx, status); err != nil {
+		log.Warnf("Failed to publish the NodeAllocation of node %s: %v", c.myNodeName, err)
+		ipamdErrInc("publishNodeAllocation")
+		return
+	}
+	c.publishedNodeAllocation = &status
+	log.Debugf("Published the NodeAllocation of node %s: %d ENIs, %d of %d IPs assigned",
+		c.myNodeName, len(status.ENIs), status.AssignedIPs, status.TotalIPs)
+}
+
+// updateNodeAllocation sets the status of the NodeAllocation of the node, and creates it first if needed. The
+// NodeAllocation is owned by the node, so it is deleted with it. The raw client is used, so that ipamd doesn't watch
+// the NodeAllocations of the whole cluster.
+func (c *IPAMContext) updateNodeAllocation(ctx context.Context, status v1alpha1.NodeAllocationStatus) error {
+	allocation := &v1alpha1.NodeAllocation{}
+	err := c.rawK8SClient.Get(ctx, types.NamespacedName{Name: c.myNodeName}, allocation)
+	if k8serror.IsNotFound(err) {
+		node := &corev1.Node{}
+		if err := c.cachedK8SClient.Get(ctx, types.NamespacedName{Name: c.myNodeName}, node); err != nil {
+			return errors.Wrap(err, "failed to get the node")
+		}
+		allocation = &v1alpha1.NodeAllocation{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: c.myNodeName,
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: "v1",
+					Kind:       "Node",
+					Name:       node.Name,
+					UID:        node.UID,
+				}},
+			},
+		}
+		if err := c.rawK8SClient.Create(ctx, allocation); err != nil {
+			return errors.Wrap(err, "failed to create the NodeAllocation")
+		}
+	} else if err != nil {
+		return errors.Wrap(err, "failed to get the NodeAllocation")
+	}
+	allocation.Status = status
+	return errors.Wrap(c.rawK8SClient.Status().Update(ctx, allocation), "failed to update the NodeAllocation status")
+}
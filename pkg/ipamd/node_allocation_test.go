@@ -0,0 +1,96 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/apis/crd/v1alpha1"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+)
+
+func TestPublishNodeAllocation(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+	ctx := context.Background()
+
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: myNodeName, UID: "node-uid"}}
+	assert.NoError(t, m.cachedK8SClient.Create(ctx, node))
+
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)}, false))
+	}
+	podIP, _, err := ds.AssignPodIPv4Address(datastore.IPAMKey{NetworkName: "aws-cni", ContainerID: "cid-1", IfName: "eth0"},
+		datastore.IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "pod-1"})
+	assert.NoError(t, err)
+	// Recovered allocations have no pod and are only counted
+	_, _, err = ds.AssignPodIPv4Address(datastore.RecoveredIPAMKey("eni0123456789a"), datastore.IPAMMetadata{})
+	assert.NoError(t, err)
+
+	mockContext := &IPAMContext{
+		rawK8SClient:         m.rawK8SClient,
+		cachedK8SClient:      m.cachedK8SClient,
+		dataStore:            ds,
+		myNodeName:           myNodeName,
+		enableIPv4:           true,
+		enableNodeAllocation: true,
+	}
+	getAllocation := func() *v1alpha1.NodeAllocation {
+		allocation := &v1alpha1.NodeAllocation{}
+		assert.NoError(t, m.rawK8SClient.Get(ctx, types.NamespacedName{Name: myNodeName}, allocation))
+		return allocation
+	}
+
+	mockContext.publishNodeAllocation(ctx)
+	allocation := getAllocation()
+	assert.Equal(t, []metav1.OwnerReference{{APIVersion: "v1", Kind: "Node", Name: myNodeName, UID: "node-uid"}},
+		allocation.OwnerReferences)
+	assert.Equal(t, v1alpha1.NodeAllocationStatus{
+		TotalIPs:    2,
+		AssignedIPs: 2,
+		ENIs: []v1alpha1.NodeAllocationENI{{
+			ID:      "eni-1",
+			Primary: true,
+			CIDRs:   []string{"10.0.0.1/32", "10.0.0.2/32"},
+			Pods:    []v1alpha1.NodeAllocationPod{{Namespace: "default", Name: "pod-1", IP: podIP}},
+		}},
+	}, allocation.Status)
+
+	// Changes wait for the interval to pass
+	_, _, _, err = ds.UnassignPodIPAddress(datastore.IPAMKey{NetworkName: "aws-cni", ContainerID: "cid-1", IfName: "eth0"})
+	assert.NoError(t, err)
+	mockContext.publishNodeAllocation(ctx)
+	assert.Equal(t, 2, getAllocation().Status.AssignedIPs)
+
+	mockContext.nodeAllocationPublishedAt = time.Now().Add(-nodeAllocationMinInterval)
+	mockContext.publishNodeAllocation(ctx)
+	allocation = getAllocation()
+	assert.Equal(t, 1, allocation.Status.AssignedIPs)
+	assert.Empty(t, allocation.Status.ENIs[0].Pods)
+
+	// Without changes, nothing is written
+	mockContext.nodeAllocationPublishedAt = time.Now().Add(-nodeAllocationMinInterval)
+	mockContext.publishNodeAllocation(ctx)
+	assert.Equal(t, allocation.ResourceVersion, getAllocation().ResourceVersion)
+}
@@ -0,0 +1,116 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/maxpods"
+)
+
+const (
+	// podIPCapacityAnnotation is the number of pod IP addresses the node can get, after taking out the primary ENI with
+	// custom networking, the trunk ENI with security groups for pods, and unmanaged ENIs
+	podIPCapacityAnnotation = "vpc.amazonaws.com/pod-ip-capacity"
+	// maxPodsAnnotation is the matching kubelet --max-pods value, see the maxpods package
+	maxPodsAnnotation = "vpc.amazonaws.com/max-pods"
+)
+
+// usableENIs returns the number of ENIs that can hold pod IPs
+func (c *IPAMContext) usableENIs() int {
+	enis := c.maxENI - c.unmanagedENI
+	if c.enablePodENI {
+		// The trunk ENI is attached, or a slot is kept free for it
+		enis--
+	}
+	if c.useCustomNetworking {
+		enis--
+	}
+	return max(enis, 0)
+}
+
+// podCapacity returns the pod IP capacity and max pods of the node
+func (c *IPAMContext) podCapacity() (ipCapacity int, maxPods int) {
+	ipCapacity = c.usableENIs() * c.maxIPsPerENI
+	// The limits per ENI exclude the primary IP, and usableENIs already accounts for custom networking
+	limitPerENI := c.maxIPsPerENI
+	if c.enablePrefixDelegation {
+		limitPerENI = c.maxPrefixesPerENI
+	}
+	maxPods = maxpods.Calculate(maxpods.Options{
+		ENILimit:         c.usableENIs(),
+		IPv4Limit:        limitPerENI + 1,
+		CPUs:             runtime.NumCPU(),
+		PrefixDelegation: c.enablePrefixDelegation,
+	})
+	return ipCapacity, maxPods
+}
+
+// publishPodCapacity annotates the node with its pod IP capacity when it changed, so that node provisioners get
+// accurate density estimates under prefix delegation and custom networking
+func (c *IPAMContext) publishPodCapacity(ctx context.Context) {
+	if !c.annotateNodeCapacity || c.enableIPv6 {
+		return
+	}
+	ipCapacity, maxPods := c.podCapacity()
+	if ipCapacity == c.publishedIPCapacity {
+		return
+	}
+	err := c.SetNodeAnnotations(ctx, map[string]string{
+		podIPCapacityAnnotation: strconv.Itoa(ipCapacity),
+		maxPodsAnnotation:       strconv.Itoa(maxPods),
+	})
+	if err != nil {
+		log.Warnf("Failed to publish the pod IP capacity of the node: %v", err)
+		ipamdErrInc("publishPodCapacity")
+		return
+	}
+	log.Infof("Published pod IP capacity %d and max pods %d", ipCapacity, maxPods)
+	c.publishedIPCapacity = ipCapacity
+}
+
+// SetNodeAnnotations sets annotations on the node
+func (c *IPAMContext) SetNodeAnnotations(ctx context.Context, annotations map[string]string) error {
+	request := types.NamespacedName{
+		Name: c.myNodeName,
+	}
+
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		node := &corev1.Node{}
+		err := c.cachedK8SClient.Get(ctx, request, node)
+		if err != nil {
+			log.Errorf("Failed to get node: %v", err)
+			return err
+		}
+
+		updateNode := node.DeepCopy()
+		if updateNode.Annotations == nil {
+			updateNode.Annotations = make(map[string]string)
+		}
+		for key, value := range annotations {
+			updateNode.Annotations[key] = value
+		}
+		if err = c.cachedK8SClient.Update(ctx, updateNode); err != nil {
+			log.Errorf("Failed to update annotations of node %s: %v", c.myNodeName, err)
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,56 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestPublishPodCapacity(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+	ctx := context.Background()
+
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: myNodeName}}
+	assert.NoError(t, m.cachedK8SClient.Create(ctx, node))
+
+	mockContext := &IPAMContext{
+		cachedK8SClient:      m.cachedK8SClient,
+		myNodeName:           myNodeName,
+		maxENI:               3,
+		maxIPsPerENI:         9,
+		useCustomNetworking:  true,
+		annotateNodeCapacity: true,
+	}
+
+	mockContext.publishPodCapacity(ctx)
+	assert.NoError(t, m.cachedK8SClient.Get(ctx, types.NamespacedName{Name: myNodeName}, node))
+	assert.Equal(t, "18", node.Annotations[podIPCapacityAnnotation])
+	assert.Equal(t, "20", node.Annotations[maxPodsAnnotation])
+
+	// Unchanged capacity doesn't update the node again
+	mockContext.publishPodCapacity(ctx)
+
+	mockContext.unmanagedENI = 1
+	mockContext.publishPodCapacity(ctx)
+	assert.NoError(t, m.cachedK8SClient.Get(ctx, types.NamespacedName{Name: myNodeName}, node))
+	assert.Equal(t, "9", node.Annotations[podIPCapacityAnnotation])
+	assert.Equal(t, "11", node.Annotations[maxPodsAnnotation])
+}
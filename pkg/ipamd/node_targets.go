@@ -0,0 +1,81 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Node annotations (or labels) overriding the warm target env vars for a single node, so that different node groups
+// can be tuned without running separate aws-node daemonsets. An annotation takes precedence over a label with the
+// same key, and removing both reverts the node to the env var value.
+const (
+	warmIPTargetNodeKey     = "vpc.amazonaws.com/warm-ip-target"
+	warmENITargetNodeKey    = "vpc.amazonaws.com/warm-eni-target"
+	minimumIPTargetNodeKey  = "vpc.amazonaws.com/minimum-ip-target"
+	warmPrefixTargetNodeKey = "vpc.amazonaws.com/warm-prefix-target"
+)
+
+// updateWarmTargetsFromNode applies the warm target overrides set on the node. It is called from the pool manager
+// loop, which is the only reader of the warm targets after init.
+func (c *IPAMContext) updateWarmTargetsFromNode(ctx context.Context) {
+	node := &corev1.Node{}
+	err := c.cachedK8SClient.Get(ctx, types.NamespacedName{Name: c.myNodeName}, node)
+	if err != nil {
+		log.Debugf("Skipping warm target overrides, failed to get node: %v", err)
+		return
+	}
+	c.warmIPTarget = resolveWarmTarget(node, warmIPTargetNodeKey, envWarmIPTarget, c.warmIPTarget, getWarmIPTarget())
+	c.warmENITarget = resolveWarmTarget(node, warmENITargetNodeKey, envWarmENITarget, c.warmENITarget, getWarmENITarget())
+	c.minimumIPTarget = resolveWarmTarget(node, minimumIPTargetNodeKey, envMinimumIPTarget, c.minimumIPTarget, getMinimumIPTarget())
+	c.warmPrefixTarget = resolveWarmTarget(node, warmPrefixTargetNodeKey, envWarmPrefixTarget, c.warmPrefixTarget, getWarmPrefixTarget())
+}
+
+// resolveWarmTarget returns the node override for key if there is a valid one, and envValue otherwise
+func resolveWarmTarget(node *corev1.Node, key, envName string, current, envValue int) int {
+	target := envValue
+	override, found, err := nodeWarmTargetOverride(node, key)
+	if err != nil {
+		log.Warnf("Ignoring invalid %s override on node %s, using %d: %v", envName, node.Name, envValue, err)
+	} else if found {
+		target = override
+	}
+	if target != current {
+		log.Infof("%s of the node changed from %d to %d", envName, current, target)
+	}
+	return target
+}
+
+// nodeWarmTargetOverride returns the value of the annotation or label key on the node. found is false if neither is
+// set, and an error is returned if the value isn't a non-negative integer.
+func nodeWarmTargetOverride(node *corev1.Node, key string) (value int, found bool, err error) {
+	raw, found := node.Annotations[key]
+	if !found {
+		raw, found = node.Labels[key]
+	}
+	if !found {
+		return 0, false, nil
+	}
+	value, err = strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || value < 0 {
+		return 0, false, fmt.Errorf("%s=%q is not a non-negative integer", key, raw)
+	}
+	return value, true, nil
+}
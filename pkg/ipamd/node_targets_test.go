@@ -0,0 +1,72 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestUpdateWarmTargetsFromNode(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+	ctx := context.Background()
+
+	_ = os.Setenv(envWarmIPTarget, "5")
+	defer os.Unsetenv(envWarmIPTarget)
+	_ = os.Unsetenv(envWarmENITarget)
+	_ = os.Unsetenv(envMinimumIPTarget)
+	_ = os.Unsetenv(envWarmPrefixTarget)
+
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: myNodeName,
+			Annotations: map[string]string{
+				warmIPTargetNodeKey:    "10",
+				minimumIPTargetNodeKey: "invalid",
+			},
+			Labels: map[string]string{
+				warmIPTargetNodeKey:  "20",
+				warmENITargetNodeKey: "0",
+			},
+		},
+	}
+	assert.NoError(t, m.cachedK8SClient.Create(ctx, node))
+
+	mockContext := &IPAMContext{
+		cachedK8SClient: m.cachedK8SClient,
+		myNodeName:      myNodeName,
+		warmIPTarget:    5,
+		warmENITarget:   defaultWarmENITarget,
+	}
+
+	mockContext.updateWarmTargetsFromNode(ctx)
+	assert.Equal(t, 10, mockContext.warmIPTarget) // the annotation wins over the label
+	assert.Equal(t, 0, mockContext.warmENITarget)
+	assert.Equal(t, noMinimumIPTarget, mockContext.minimumIPTarget) // invalid values fall back to the env var
+	assert.Equal(t, defaultWarmPrefixTarget, mockContext.warmPrefixTarget)
+
+	// Removing the overrides reverts to the env vars
+	node.Annotations = nil
+	node.Labels = nil
+	assert.NoError(t, m.cachedK8SClient.Update(ctx, node))
+	mockContext.updateWarmTargetsFromNode(ctx)
+	assert.Equal(t, 5, mockContext.warmIPTarget)
+	assert.Equal(t, defaultWarmENITarget, mockContext.warmENITarget)
+}
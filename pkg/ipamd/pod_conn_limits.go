@@ -0,0 +1,90 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/networkutils"
+)
+
+const (
+	// podConnectionRateKey is the pod annotation setting the new connections per second the pod can open with
+	// ENABLE_POD_CONNECTION_LIMITS
+	podConnectionRateKey = "vpc.amazonaws.com/egress-connection-rate"
+	// podMaxConnectionsKey is the pod annotation setting the number of connections the pod can have open at the same
+	// time with ENABLE_POD_CONNECTION_LIMITS
+	podMaxConnectionsKey = "vpc.amazonaws.com/egress-max-connections"
+)
+
+// podConnectionLimits returns the connection limits annotated on pod. Invalid limits are ignored.
+func podConnectionLimits(pod *corev1.Pod) networkutils.PodConnectionLimits {
+	parse := func(key string) int {
+		raw, found := pod.Annotations[key]
+		if !found {
+			return 0
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil || limit <= 0 {
+			log.Warnf("Ignoring invalid %s=%q of pod %s/%s, the limit must be a positive integer", key, raw,
+				pod.Namespace, pod.Name)
+			return 0
+		}
+		return limit
+	}
+	return networkutils.PodConnectionLimits{
+		NewConnectionsPerSecond: parse(podConnectionRateKey),
+		MaxConnections:          parse(podMaxConnectionsKey),
+	}
+}
+
+// limitPodConnections makes the host veth of a pod drop the new connections above the limits annotated on the pod, or
+// above CONNTRACK_PRESSURE_POD_MAX_CONNECTIONS if the pod has no connection cap and the conntrack table is under
+// pressure. Like the IMDS block, the rules can be added before the CNI plugin creates the host veth.
+func (c *IPAMContext) limitPodConnections(podName, podNamespace string) error {
+	if !c.enablePodConnectionLimits {
+		return nil
+	}
+	pod, err := c.GetPod(podName, podNamespace)
+	if err != nil {
+		return err
+	}
+	limits := podConnectionLimits(pod)
+	if limits.MaxConnections == 0 && c.conntrackPressurePodConns > 0 && c.conntrackUnderPressure() {
+		log.Infof("Conntrack table under pressure, limiting pod %s/%s to %d connections", podNamespace, podName,
+			c.conntrackPressurePodConns)
+		limits.MaxConnections = c.conntrackPressurePodConns
+	}
+	if limits == (networkutils.PodConnectionLimits{}) {
+		return nil
+	}
+	log.Infof("Limiting the connections of pod %s/%s to %+v", podNamespace, podName, limits)
+	hostVeth := c.networkClient.GetHostVethName(podNamespace, podName)
+	return c.networkClient.LimitPodConnections(hostVeth, limits, c.enableIPv6)
+}
+
+// unlimitPodConnections deletes the rules added by limitPodConnections, if any
+func (c *IPAMContext) unlimitPodConnections(podName, podNamespace string) {
+	if !c.enablePodConnectionLimits {
+		return
+	}
+	hostVeth := c.networkClient.GetHostVethName(podNamespace, podName)
+	if err := c.networkClient.UnlimitPodConnections(hostVeth, c.enableIPv6); err != nil {
+		log.Warnf("Failed to delete the connection limit rules of pod %s/%s: %v", podNamespace, podName, err)
+		ipamdErrInc("unlimitPodConnections")
+	}
+}
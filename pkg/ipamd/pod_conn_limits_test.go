@@ -0,0 +1,133 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/networkutils"
+	pb "github.com/aws/amazon-vpc-cni-k8s/rpc"
+)
+
+func TestPodConnectionLimits(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "default"}}
+	assert.Equal(t, networkutils.PodConnectionLimits{}, podConnectionLimits(pod))
+
+	pod.Annotations = map[string]string{podConnectionRateKey: " 100", podMaxConnectionsKey: "1000"}
+	assert.Equal(t, networkutils.PodConnectionLimits{NewConnectionsPerSecond: 100, MaxConnections: 1000},
+		podConnectionLimits(pod))
+
+	pod.Annotations = map[string]string{podConnectionRateKey: "-1", podMaxConnectionsKey: "lots"}
+	assert.Equal(t, networkutils.PodConnectionLimits{}, podConnectionLimits(pod))
+}
+
+func TestAddNetworkPodConnectionLimits(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	assert.NoError(t, m.rawK8SClient.Create(context.Background(), &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "default",
+			Annotations: map[string]string{podMaxConnectionsKey: "500"}},
+	}))
+	assert.NoError(t, m.rawK8SClient.Create(context.Background(), &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-2", Namespace: "default"},
+	}))
+
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)}, false))
+	}
+	mockContext := &IPAMContext{
+		awsClient:                 m.awsutils,
+		networkClient:             m.network,
+		rawK8SClient:              m.rawK8SClient,
+		dataStore:                 ds,
+		enableIPv4:                true,
+		enablePodConnectionLimits: true,
+	}
+	s := &server{version: "1.2.3", ipamContext: mockContext}
+
+	m.awsutils.EXPECT().GetVPCIPv4CIDRs().Return([]string{"10.0.0.0/16"}, nil).Times(2)
+	m.network.EXPECT().UseExternalSNAT().Return(true).Times(2)
+	m.network.EXPECT().GetHostVethName("default", "pod-1").Return("eni1")
+	m.network.EXPECT().LimitPodConnections("eni1", networkutils.PodConnectionLimits{MaxConnections: 500}, false).Return(nil)
+	resp, err := s.AddNetwork(context.Background(), &pb.AddNetworkRequest{
+		ClientVersion:     "1.2.3",
+		K8S_POD_NAME:      "pod-1",
+		K8S_POD_NAMESPACE: "default",
+		ContainerID:       "cid-pod-1",
+		IfName:            "eth0",
+		NetworkName:       "aws-cni",
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.Success)
+
+	// A pod without limits gets no rules
+	resp, err = s.AddNetwork(context.Background(), &pb.AddNetworkRequest{
+		ClientVersion:     "1.2.3",
+		K8S_POD_NAME:      "pod-2",
+		K8S_POD_NAMESPACE: "default",
+		ContainerID:       "cid-pod-2",
+		IfName:            "eth0",
+		NetworkName:       "aws-cni",
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.Success)
+
+	m.network.EXPECT().GetHostVethName("default", "pod-1").Return("eni1")
+	m.network.EXPECT().UnlimitPodConnections("eni1", false).Return(nil)
+	delResp, err := s.DelNetwork(context.Background(), &pb.DelNetworkRequest{
+		ClientVersion:     "1.2.3",
+		K8S_POD_NAME:      "pod-1",
+		K8S_POD_NAMESPACE: "default",
+		ContainerID:       "cid-pod-1",
+		IfName:            "eth0",
+		NetworkName:       "aws-cni",
+	})
+	assert.NoError(t, err)
+	assert.True(t, delResp.Success)
+}
+
+func TestLimitPodConnectionsUnderConntrackPressure(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	assert.NoError(t, m.rawK8SClient.Create(context.Background(), &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "default",
+			Annotations: map[string]string{podConnectionRateKey: "10"}},
+	}))
+	mockContext := &IPAMContext{
+		networkClient:             m.network,
+		rawK8SClient:              m.rawK8SClient,
+		enablePodConnectionLimits: true,
+		conntrackPressurePodConns: 200,
+	}
+
+	m.network.EXPECT().GetHostVethName("default", "pod-1").Return("eni1").Times(2)
+	m.network.EXPECT().LimitPodConnections("eni1", networkutils.PodConnectionLimits{NewConnectionsPerSecond: 10}, false).Return(nil)
+	assert.NoError(t, mockContext.limitPodConnections("pod-1", "default"))
+
+	mockContext.conntrackPressure = 1
+	m.network.EXPECT().LimitPodConnections("eni1",
+		networkutils.PodConnectionLimits{NewConnectionsPerSecond: 10, MaxConnections: 200}, false).Return(nil)
+	assert.NoError(t, mockContext.limitPodConnections("pod-1", "default"))
+}
@@ -0,0 +1,71 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"net"
+	"strings"
+
+	"github.com/aws/amazon-vpc-cni-k8s/rpc"
+)
+
+// podEgressRoutesKey is the pod annotation listing, with ENABLE_POD_EGRESS_ROUTES, the destinations whose traffic
+// leaves through another ENI than the rest of the pod egress. It is a comma separated list of IPv4 CIDRs, each followed
+// by `=primary` to go through the node primary ENI, the default, or by `=pod-eni` to go through the ENI of the pod IP,
+// e.g. `10.0.0.0/8=primary,203.0.113.0/24=pod-eni`.
+const podEgressRoutesKey = "vpc.amazonaws.com/egress-routes"
+
+const (
+	egressViaPrimaryENI = "primary"
+	egressViaPodENI     = "pod-eni"
+)
+
+// getPodEgressRoutes returns the egress routes annotated on the pod. Invalid entries are skipped.
+func (c *IPAMContext) getPodEgressRoutes(podName, podNamespace string) ([]*rpc.EgressRoute, error) {
+	pod, err := c.GetPod(podName, podNamespace)
+	if err != nil {
+		return nil, err
+	}
+	raw, found := pod.Annotations[podEgressRoutesKey]
+	if !found {
+		return nil, nil
+	}
+	return parsePodEgressRoutes(raw, "pod "+podNamespace+"/"+podName), nil
+}
+
+func parsePodEgressRoutes(raw, source string) []*rpc.EgressRoute {
+	var routes []*rpc.EgressRoute
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		cidr, via := entry, egressViaPrimaryENI
+		if i := strings.Index(entry, "="); i >= 0 {
+			cidr, via = strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil || ipNet.IP.To4() == nil {
+			log.Warnf("Ignoring invalid CIDR %q in %s of %s, expected an IPv4 CIDR", cidr, podEgressRoutesKey, source)
+			continue
+		}
+		if via != egressViaPrimaryENI && via != egressViaPodENI {
+			log.Warnf("Ignoring %s in %s of %s, expected %s or %s after the CIDR", entry, podEgressRoutesKey, source,
+				egressViaPrimaryENI, egressViaPodENI)
+			continue
+		}
+		routes = append(routes, &rpc.EgressRoute{CIDR: ipNet.String(), ViaPodENI: via == egressViaPodENI})
+	}
+	return routes
+}
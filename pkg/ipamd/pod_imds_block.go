@@ -0,0 +1,80 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"time"
+)
+
+// podIMDSBlockPollInterval is how often the packets dropped by the pod IMDS block rules are counted
+const podIMDSBlockPollInterval = time.Minute
+
+// blockPodIMDSAccess drops the traffic from the host veth of a pod to the instance metadata service, unless the
+// namespace of the pod is allowed. The rule only matches on the name of the host veth, so it can be added before the
+// CNI plugin creates it.
+func (c *IPAMContext) blockPodIMDSAccess(podName, podNamespace string) error {
+	if !c.enablePodIMDSBlock || c.imdsAllowedNamespaces[podNamespace] {
+		return nil
+	}
+	hostVeth := c.networkClient.GetHostVethName(podNamespace, podName)
+	return c.networkClient.BlockPodIMDSAccess(hostVeth, c.enableIPv6)
+}
+
+// unblockPodIMDSAccess deletes the rule added by blockPodIMDSAccess, if any
+func (c *IPAMContext) unblockPodIMDSAccess(podName, podNamespace string) {
+	if !c.enablePodIMDSBlock {
+		return
+	}
+	hostVeth := c.networkClient.GetHostVethName(podNamespace, podName)
+	if err := c.networkClient.UnblockPodIMDSAccess(hostVeth, c.enableIPv6); err != nil {
+		log.Warnf("Failed to delete the IMDS block rule of pod %s/%s: %v", podNamespace, podName, err)
+		ipamdErrInc("unblockPodIMDSAccess")
+	}
+}
+
+// StartPodIMDSBlockMonitor periodically adds the packets dropped by the pod IMDS block rules since the last poll to
+// the blocked packet metric
+func (c *IPAMContext) StartPodIMDSBlockMonitor() {
+	if !c.enablePodIMDSBlock {
+		return
+	}
+	last := map[string]uint64{}
+	for {
+		time.Sleep(podIMDSBlockPollInterval)
+		last = c.countPodIMDSBlockedPackets(last)
+	}
+}
+
+// countPodIMDSBlockedPackets adds the packets dropped for each host veth since the counts of last to the metric, and
+// returns the current counts
+func (c *IPAMContext) countPodIMDSBlockedPackets(last map[string]uint64) map[string]uint64 {
+	current, err := c.networkClient.GetPodIMDSBlockedPackets(c.enableIPv6)
+	if err != nil {
+		log.Errorf("Failed to count the packets dropped by the pod IMDS block: %v", err)
+		ipamdErrInc("countPodIMDSBlockedPackets")
+		return last
+	}
+	for hostVeth, packets := range current {
+		previous := last[hostVeth]
+		// A lower count means the rule was deleted and added back for a new pod
+		if packets < previous {
+			previous = 0
+		}
+		if packets > previous {
+			log.Infof("Dropped %d packets from %s to the instance metadata service", packets-previous, hostVeth)
+			podIMDSBlockedPackets.Add(float64(packets - previous))
+		}
+	}
+	return current
+}
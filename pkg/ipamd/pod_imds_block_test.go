@@ -0,0 +1,103 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+	pb "github.com/aws/amazon-vpc-cni-k8s/rpc"
+)
+
+func TestAddNetworkPodIMDSBlock(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)}, false))
+	}
+	mockContext := &IPAMContext{
+		awsClient:             m.awsutils,
+		networkClient:         m.network,
+		dataStore:             ds,
+		enableIPv4:            true,
+		enablePodIMDSBlock:    true,
+		imdsAllowedNamespaces: map[string]bool{"kube-system": true},
+	}
+	s := &server{version: "1.2.3", ipamContext: mockContext}
+
+	m.awsutils.EXPECT().GetVPCIPv4CIDRs().Return([]string{"10.0.0.0/16"}, nil).Times(2)
+	m.network.EXPECT().UseExternalSNAT().Return(true).Times(2)
+	m.network.EXPECT().GetHostVethName("default", "pod-1").Return("eni1")
+	m.network.EXPECT().BlockPodIMDSAccess("eni1", false).Return(nil)
+	resp, err := s.AddNetwork(context.Background(), &pb.AddNetworkRequest{
+		ClientVersion:     "1.2.3",
+		K8S_POD_NAME:      "pod-1",
+		K8S_POD_NAMESPACE: "default",
+		ContainerID:       "cid-pod-1",
+		IfName:            "eth0",
+		NetworkName:       "aws-cni",
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.Success)
+
+	// The pods of the allowed namespaces keep access
+	resp, err = s.AddNetwork(context.Background(), &pb.AddNetworkRequest{
+		ClientVersion:     "1.2.3",
+		K8S_POD_NAME:      "pod-2",
+		K8S_POD_NAMESPACE: "kube-system",
+		ContainerID:       "cid-pod-2",
+		IfName:            "eth0",
+		NetworkName:       "aws-cni",
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.Success)
+
+	m.network.EXPECT().GetHostVethName("default", "pod-1").Return("eni1")
+	m.network.EXPECT().UnblockPodIMDSAccess("eni1", false).Return(nil)
+	delResp, err := s.DelNetwork(context.Background(), &pb.DelNetworkRequest{
+		ClientVersion:     "1.2.3",
+		K8S_POD_NAME:      "pod-1",
+		K8S_POD_NAMESPACE: "default",
+		ContainerID:       "cid-pod-1",
+		IfName:            "eth0",
+		NetworkName:       "aws-cni",
+	})
+	assert.NoError(t, err)
+	assert.True(t, delResp.Success)
+}
+
+func TestCountPodIMDSBlockedPackets(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	mockContext := &IPAMContext{networkClient: m.network}
+	before := testutil.ToFloat64(podIMDSBlockedPackets)
+
+	m.network.EXPECT().GetPodIMDSBlockedPackets(false).Return(map[string]uint64{"eni1": 5, "eni2": 2}, nil)
+	last := mockContext.countPodIMDSBlockedPackets(map[string]uint64{})
+	assert.Equal(t, before+7, testutil.ToFloat64(podIMDSBlockedPackets))
+
+	// The rule of eni2 was added back for another pod, so its count started over
+	m.network.EXPECT().GetPodIMDSBlockedPackets(false).Return(map[string]uint64{"eni1": 6, "eni2": 1}, nil)
+	mockContext.countPodIMDSBlockedPackets(last)
+	assert.Equal(t, before+9, testutil.ToFloat64(podIMDSBlockedPackets))
+}
@@ -0,0 +1,92 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"net"
+	"os"
+	"strings"
+)
+
+// podIngressAllowlistKey is the pod annotation that, set to "false", exempts a pod from ENABLE_POD_INGRESS_ALLOWLIST
+const podIngressAllowlistKey = "vpc.amazonaws.com/ingress-allowlist"
+
+// podIngressAllowedCIDRs returns the CIDRs of POD_INGRESS_ALLOWED_CIDRS of the IP family of the pods, or the VPC
+// CIDRs if it has none
+func (c *IPAMContext) podIngressAllowedCIDRs(vpcV4CIDRs []string) ([]string, error) {
+	var cidrs []string
+	for _, entry := range strings.Split(os.Getenv(envPodIngressAllowedCIDRs), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil || (ipNet.IP.To4() == nil) != c.enableIPv6 {
+			log.Warnf("Ignoring invalid CIDR %q in %s", entry, envPodIngressAllowedCIDRs)
+			continue
+		}
+		cidrs = append(cidrs, ipNet.String())
+	}
+	if len(cidrs) > 0 {
+		return cidrs, nil
+	}
+	if c.enableIPv6 {
+		return c.awsClient.GetVPCIPv6CIDRs()
+	}
+	return vpcV4CIDRs, nil
+}
+
+// setupPodIngressAllowlist sets up the allowlist of the pod ingress, or deletes it when ENABLE_POD_INGRESS_ALLOWLIST
+// is not set
+func (c *IPAMContext) setupPodIngressAllowlist(vpcV4CIDRs []string) error {
+	var cidrs []string
+	if c.enablePodIngressAllowlist {
+		var err error
+		if cidrs, err = c.podIngressAllowedCIDRs(vpcV4CIDRs); err != nil {
+			return err
+		}
+		log.Infof("Pods only accept traffic from %v", cidrs)
+	}
+	return c.networkClient.SetupPodIngressAllowlist(c.enablePodIngressAllowlist, cidrs, c.enableIPv6)
+}
+
+// restrictPodIngress makes the host veth of a pod only forward the traffic from the allowed CIDRs to it, unless the
+// pod opted out. Like the IMDS block, the rule can be added before the CNI plugin creates the host veth.
+func (c *IPAMContext) restrictPodIngress(podName, podNamespace string) error {
+	if !c.enablePodIngressAllowlist {
+		return nil
+	}
+	pod, err := c.GetPod(podName, podNamespace)
+	if err != nil {
+		return err
+	}
+	if pod.Annotations[podIngressAllowlistKey] == "false" {
+		log.Infof("Pod %s/%s opted out of the ingress allowlist", podNamespace, podName)
+		return nil
+	}
+	hostVeth := c.networkClient.GetHostVethName(podNamespace, podName)
+	return c.networkClient.RestrictPodIngress(hostVeth, c.enableIPv6)
+}
+
+// unrestrictPodIngress deletes the rule added by restrictPodIngress, if any
+func (c *IPAMContext) unrestrictPodIngress(podName, podNamespace string) {
+	if !c.enablePodIngressAllowlist {
+		return
+	}
+	hostVeth := c.networkClient.GetHostVethName(podNamespace, podName)
+	if err := c.networkClient.UnrestrictPodIngress(hostVeth, c.enableIPv6); err != nil {
+		log.Warnf("Failed to delete the ingress allowlist rule of pod %s/%s: %v", podNamespace, podName, err)
+		ipamdErrInc("unrestrictPodIngress")
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+	pb "github.com/aws/amazon-vpc-cni-k8s/rpc"
+)
+
+func TestPodIngressAllowedCIDRs(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	c := &IPAMContext{awsClient: m.awsutils}
+	cidrs, err := c.podIngressAllowedCIDRs([]string{"10.0.0.0/16"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.0/16"}, cidrs)
+
+	t.Setenv(envPodIngressAllowedCIDRs, " 10.0.0.0/8, bad,100.64.0.7/16,fd00::/8")
+	cidrs, err = c.podIngressAllowedCIDRs([]string{"10.0.0.0/16"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.0/8", "100.64.0.0/16"}, cidrs)
+
+	c.enableIPv6 = true
+	cidrs, err = c.podIngressAllowedCIDRs(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"fd00::/8"}, cidrs)
+
+	t.Setenv(envPodIngressAllowedCIDRs, "")
+	m.awsutils.EXPECT().GetVPCIPv6CIDRs().Return([]string{"2600:1f14::/56"}, nil)
+	cidrs, err = c.podIngressAllowedCIDRs(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2600:1f14::/56"}, cidrs)
+}
+
+func TestAddNetworkPodIngressAllowlist(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	assert.NoError(t, m.rawK8SClient.Create(context.Background(), &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "default"},
+	}))
+	assert.NoError(t, m.rawK8SClient.Create(context.Background(), &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-2", Namespace: "default",
+			Annotations: map[string]string{podIngressAllowlistKey: "false"}},
+	}))
+
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)}, false))
+	}
+	mockContext := &IPAMContext{
+		awsClient:                 m.awsutils,
+		networkClient:             m.network,
+		rawK8SClient:              m.rawK8SClient,
+		dataStore:                 ds,
+		enableIPv4:                true,
+		enablePodIngressAllowlist: true,
+	}
+	s := &server{version: "1.2.3", ipamContext: mockContext}
+
+	m.awsutils.EXPECT().GetVPCIPv4CIDRs().Return([]string{"10.0.0.0/16"}, nil).Times(2)
+	m.network.EXPECT().UseExternalSNAT().Return(true).Times(2)
+	m.network.EXPECT().GetHostVethName("default", "pod-1").Return("eni1")
+	m.network.EXPECT().RestrictPodIngress("eni1", false).Return(nil)
+	resp, err := s.AddNetwork(context.Background(), &pb.AddNetworkRequest{
+		ClientVersion:     "1.2.3",
+		K8S_POD_NAME:      "pod-1",
+		K8S_POD_NAMESPACE: "default",
+		ContainerID:       "cid-pod-1",
+		IfName:            "eth0",
+		NetworkName:       "aws-cni",
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.Success)
+
+	// The annotated pod opted out
+	resp, err = s.AddNetwork(context.Background(), &pb.AddNetworkRequest{
+		ClientVersion:     "1.2.3",
+		K8S_POD_NAME:      "pod-2",
+		K8S_POD_NAMESPACE: "default",
+		ContainerID:       "cid-pod-2",
+		IfName:            "eth0",
+		NetworkName:       "aws-cni",
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.Success)
+
+	m.network.EXPECT().GetHostVethName("default", "pod-1").Return("eni1")
+	m.network.EXPECT().UnrestrictPodIngress("eni1", false).Return(nil)
+	delResp, err := s.DelNetwork(context.Background(), &pb.DelNetworkRequest{
+		ClientVersion:     "1.2.3",
+		K8S_POD_NAME:      "pod-1",
+		K8S_POD_NAMESPACE: "default",
+		ContainerID:       "cid-pod-1",
+		IfName:            "eth0",
+		NetworkName:       "aws-cni",
+	})
+	assert.NoError(t, err)
+	assert.True(t, delResp.Success)
+}
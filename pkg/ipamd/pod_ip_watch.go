@@ -0,0 +1,179 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/aws/amazon-vpc-cni-k8s/rpc"
+)
+
+// podIPWatcherBufferSize is the number of events a WatchPodIPs stream can fall behind before it is closed
+const podIPWatcherBufferSize = 256
+
+// podIPWatchers fans pod IP events out to the WatchPodIPs streams. Publishing never blocks AddNetwork or DelNetwork:
+// a watcher whose buffer is full is dropped, and has to resubscribe to get a new snapshot.
+type podIPWatchers struct {
+	lock     sync.Mutex
+	watchers map[chan *rpc.PodIPEvent]struct{}
+}
+
+func newPodIPWatchers() *podIPWatchers {
+	return &podIPWatchers{watchers: make(map[chan *rpc.PodIPEvent]struct{})}
+}
+
+func (w *podIPWatchers) subscribe() chan *rpc.PodIPEvent {
+	ch := make(chan *rpc.PodIPEvent, podIPWatcherBufferSize)
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.watchers[ch] = struct{}{}
+	return ch
+}
+
+func (w *podIPWatchers) unsubscribe(ch chan *rpc.PodIPEvent) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if _, ok := w.watchers[ch]; ok {
+		delete(w.watchers, ch)
+		close(ch)
+	}
+}
+
+// active returns true when at least one stream is watching. It is safe to call on a nil podIPWatchers.
+func (w *podIPWatchers) active() bool {
+	if w == nil {
+		return false
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return len(w.watchers) > 0
+}
+
+func (w *podIPWatchers) publish(event *rpc.PodIPEvent) {
+	if w == nil {
+		return
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	for ch := range w.watchers {
+		select {
+		case ch <- event:
+		default:
+			log.Warnf("Dropping pod IP watcher that is more than %d events behind", podIPWatcherBufferSize)
+			delete(w.watchers, ch)
+			close(ch)
+		}
+	}
+}
+
+// podLabels returns the labels of a pod, or nil if the pod can't be read
+func (c *IPAMContext) podLabels(podName, namespace string) map[string]string {
+	pod, err := c.GetPod(podName, namespace)
+	if err != nil {
+		log.Warnf("Failed to get the labels of pod %s/%s for the pod IP watchers: %v", namespace, podName, err)
+		return nil
+	}
+	return pod.Labels
+}
+
+// publishPodIPAssigned tells the WatchPodIPs streams that a pod got its IPs. The pod labels are only looked up when
+// somebody is watching.
+func (c *IPAMContext) publishPodIPAssigned(podName, namespace, containerID, ipv4Addr, ipv6Addr string) {
+	if !c.podIPWatchers.active() {
+		return
+	}
+	c.podIPWatchers.publish(&rpc.PodIPEvent{
+		EventType:         rpc.PodIPEvent_ASSIGNED,
+		K8S_POD_NAME:      podName,
+		K8S_POD_NAMESPACE: namespace,
+		ContainerID:       containerID,
+		IPv4Addr:          ipv4Addr,
+		IPv6Addr:          ipv6Addr,
+		Labels:            c.podLabels(podName, namespace),
+	})
+}
+
+// publishPodIPReleased tells the WatchPodIPs streams that a pod IP went back to the pool
+func (c *IPAMContext) publishPodIPReleased(podName, namespace, containerID, ipv4Addr, ipv6Addr string) {
+	c.podIPWatchers.publish(&rpc.PodIPEvent{
+		EventType:         rpc.PodIPEvent_RELEASED,
+		K8S_POD_NAME:      podName,
+		K8S_POD_NAMESPACE: namespace,
+		ContainerID:       containerID,
+		IPv4Addr:          ipv4Addr,
+		IPv6Addr:          ipv6Addr,
+	})
+}
+
+// podIPSnapshot returns an ASSIGNED event for every pod IP in the datastore
+func (c *IPAMContext) podIPSnapshot() []*rpc.PodIPEvent {
+	allocated := c.allocatedPodIPs()
+	events := make([]*rpc.PodIPEvent, 0, len(allocated))
+	for _, info := range allocated {
+		event := &rpc.PodIPEvent{
+			EventType:         rpc.PodIPEvent_ASSIGNED,
+			K8S_POD_NAME:      info.Metadata.K8SPodName,
+			K8S_POD_NAMESPACE: info.Metadata.K8SPodNamespace,
+			ContainerID:       info.IPAMKey.ContainerID,
+		}
+		if c.enableIPv6 {
+			event.IPv6Addr = info.IP
+		} else {
+			event.IPv4Addr = info.IP
+		}
+		if info.Metadata.K8SPodName != "" {
+			event.Labels = c.podLabels(info.Metadata.K8SPodName, info.Metadata.K8SPodNamespace)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// WatchPodIPs streams the pod IPs of this node to network policy agents, so that they don't have to watch the API
+// server for them. The stream starts with an ASSIGNED event for every pod IP already in use and a SYNCED event, and
+// then follows AddNetwork and DelNetwork.
+func (s *server) WatchPodIPs(in *rpc.WatchPodIPsRequest, stream rpc.CNIBackend_WatchPodIPsServer) error {
+	log.Info("Received WatchPodIPs")
+	c := s.ipamContext
+	// Subscribe before the snapshot, so no event falls in between. An IP assigned in between is sent twice.
+	events := c.podIPWatchers.subscribe()
+	defer c.podIPWatchers.unsubscribe(events)
+
+	for _, event := range c.podIPSnapshot() {
+		if err := stream.Send(event); err != nil {
+			return err
+		}
+	}
+	if err := stream.Send(&rpc.PodIPEvent{EventType: rpc.PodIPEvent_SYNCED}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "pod IP watcher fell behind, resubscribe")
+			}
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			log.Info("WatchPodIPs stream closed")
+			return nil
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+	pb "github.com/aws/amazon-vpc-cni-k8s/rpc"
+)
+
+// fakeWatchPodIPsServer collects the events sent on a WatchPodIPs stream
+type fakeWatchPodIPsServer struct {
+	grpc.ServerStream
+	ctx    context.Context
+	events chan *pb.PodIPEvent
+}
+
+func (s *fakeWatchPodIPsServer) Send(event *pb.PodIPEvent) error {
+	s.events <- event
+	return nil
+}
+
+func (s *fakeWatchPodIPsServer) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeWatchPodIPsServer) next(t *testing.T) *pb.PodIPEvent {
+	select {
+	case event := <-s.events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a pod IP event")
+		return nil
+	}
+}
+
+func TestWatchPodIPs(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	for _, name := range []string{"pod-1", "pod-2"} {
+		assert.NoError(t, m.rawK8SClient.Create(context.Background(), &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{"app": name}},
+		}))
+	}
+
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)}, false))
+	}
+	pod1IP, _, err := ds.AssignPodIPv4Address(datastore.IPAMKey{NetworkName: "aws-cni", ContainerID: "cid-1", IfName: "eth0"},
+		datastore.IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "pod-1"})
+	assert.NoError(t, err)
+	pod2IP := "10.0.0.1"
+	if pod1IP == pod2IP {
+		pod2IP = "10.0.0.2"
+	}
+
+	mockContext := &IPAMContext{
+		awsClient:     m.awsutils,
+		networkClient: m.network,
+		rawK8SClient:  m.rawK8SClient,
+		dataStore:     ds,
+		enableIPv4:    true,
+		podIPWatchers: newPodIPWatchers(),
+	}
+	s := &server{version: "1.2.3", ipamContext: mockContext}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &fakeWatchPodIPsServer{ctx: ctx, events: make(chan *pb.PodIPEvent, 10)}
+	done := make(chan error)
+	go func() {
+		done <- s.WatchPodIPs(&pb.WatchPodIPsRequest{}, stream)
+	}()
+
+	event := stream.next(t)
+	assert.Equal(t, pb.PodIPEvent_ASSIGNED, event.EventType)
+	assert.Equal(t, "pod-1", event.K8S_POD_NAME)
+	assert.Equal(t, pod1IP, event.IPv4Addr)
+	assert.Equal(t, map[string]string{"app": "pod-1"}, event.Labels)
+	assert.Equal(t, pb.PodIPEvent_SYNCED, stream.next(t).EventType)
+
+	m.awsutils.EXPECT().GetVPCIPv4CIDRs().Return([]string{"10.0.0.0/16"}, nil)
+	m.network.EXPECT().UseExternalSNAT().Return(true)
+	_, err = s.AddNetwork(context.Background(), &pb.AddNetworkRequest{
+		ClientVersion:     "1.2.3",
+		K8S_POD_NAME:      "pod-2",
+		K8S_POD_NAMESPACE: "default",
+		ContainerID:       "cid-2",
+		IfName:            "eth0",
+		NetworkName:       "aws-cni",
+	})
+	assert.NoError(t, err)
+	event = stream.next(t)
+	assert.Equal(t, pb.PodIPEvent_ASSIGNED, event.EventType)
+	assert.Equal(t, "pod-2", event.K8S_POD_NAME)
+	assert.Equal(t, pod2IP, event.IPv4Addr)
+	assert.Equal(t, map[string]string{"app": "pod-2"}, event.Labels)
+
+	_, err = s.DelNetwork(context.Background(), &pb.DelNetworkRequest{
+		ClientVersion:     "1.2.3",
+		K8S_POD_NAME:      "pod-1",
+		K8S_POD_NAMESPACE: "default",
+		ContainerID:       "cid-1",
+		IfName:            "eth0",
+		NetworkName:       "aws-cni",
+	})
+	assert.NoError(t, err)
+	event = stream.next(t)
+	assert.Equal(t, pb.PodIPEvent_RELEASED, event.EventType)
+	assert.Equal(t, pod1IP, event.IPv4Addr)
+
+	cancel()
+	assert.NoError(t, <-done)
+	assert.False(t, mockContext.podIPWatchers.active())
+}
+
+func TestPodIPWatchersDropSlowWatcher(t *testing.T) {
+	watchers := newPodIPWatchers()
+	slow := watchers.subscribe()
+	for i := 0; i < podIPWatcherBufferSize; i++ {
+		watchers.publish(&pb.PodIPEvent{})
+	}
+	assert.True(t, watchers.active())
+
+	watchers.publish(&pb.PodIPEvent{})
+	assert.False(t, watchers.active())
+	for range slow {
+	}
+	// Unsubscribing a dropped watcher is a no-op
+	watchers.unsubscribe(slow)
+}
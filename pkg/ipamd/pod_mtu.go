@@ -0,0 +1,76 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// podMTUKey is the node annotation or label, and the pod annotation, setting the MTU of the pod interfaces with
+// ENABLE_POD_MTU_OVERRIDE. A pod annotation takes precedence over the node, so that a node group can use jumbo frames
+// while a few pods talking over a VPN don't.
+const podMTUKey = "vpc.amazonaws.com/pod-mtu"
+
+const (
+	// minimumPodMTU is the smallest datagram every IPv4 host must accept, as for AWS_VPC_ENI_MTU
+	minimumPodMTU = 576
+	// minimumPodMTUIPv6 is the smallest link MTU allowed by IPv6
+	minimumPodMTUIPv6 = 1280
+)
+
+// getPodMTU returns the pod MTU set on the pod or its node, or 0 to keep the MTU of the CNI config. A MTU above the one
+// of the ENIs is lowered to it, since the pod packets leave through them.
+func (c *IPAMContext) getPodMTU(ctx context.Context, podName, podNamespace string) (int, error) {
+	pod, err := c.GetPod(podName, podNamespace)
+	if err != nil {
+		return 0, err
+	}
+	raw, found := pod.Annotations[podMTUKey]
+	source := "pod " + podNamespace + "/" + podName
+	if !found {
+		node := &corev1.Node{}
+		if err := c.cachedK8SClient.Get(ctx, types.NamespacedName{Name: c.myNodeName}, node); err != nil {
+			log.Debugf("Skipping the node pod MTU, failed to get node: %v", err)
+			return 0, nil
+		}
+		raw, found = node.Annotations[podMTUKey]
+		if !found {
+			raw, found = node.Labels[podMTUKey]
+		}
+		source = "node " + node.Name
+	}
+	if !found {
+		return 0, nil
+	}
+
+	minimumMTU := minimumPodMTU
+	if c.enableIPv6 {
+		minimumMTU = minimumPodMTUIPv6
+	}
+	mtu, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || mtu < minimumMTU {
+		log.Warnf("Ignoring invalid %s=%q of %s, the MTU must be at least %d", podMTUKey, raw, source, minimumMTU)
+		return 0, nil
+	}
+	if mtu > c.eniMTU {
+		log.Warnf("Lowering %s=%d of %s to the ENI MTU %d", podMTUKey, mtu, source, c.eniMTU)
+		return c.eniMTU, nil
+	}
+	return mtu, nil
+}
@@ -0,0 +1,76 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetPodMTU(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+	ctx := context.Background()
+
+	mockContext := &IPAMContext{
+		rawK8SClient:    m.rawK8SClient,
+		cachedK8SClient: m.cachedK8SClient,
+		myNodeName:      myNodeName,
+		enableIPv4:      true,
+		eniMTU:          9001,
+	}
+	for name, mtu := range map[string]string{"jumbo": "9001", "vpn": "1400", "too-high": "9200", "too-low": "500", "invalid": "abc"} {
+		assert.NoError(t, m.rawK8SClient.Create(ctx, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Annotations: map[string]string{podMTUKey: mtu}},
+		}))
+	}
+	assert.NoError(t, m.rawK8SClient.Create(ctx, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "plain", Namespace: "default"}}))
+
+	for pod, expected := range map[string]int{"jumbo": 9001, "vpn": 1400, "too-high": 9001, "too-low": 0, "invalid": 0, "plain": 0} {
+		mtu, err := mockContext.getPodMTU(ctx, pod, "default")
+		assert.NoError(t, err)
+		assert.Equal(t, expected, mtu, pod)
+	}
+
+	// A pod without its own MTU gets the one of the node, an annotation winning over a label
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: myNodeName, Labels: map[string]string{podMTUKey: "1500"}}}
+	assert.NoError(t, m.cachedK8SClient.Create(ctx, node))
+	mtu, err := mockContext.getPodMTU(ctx, "plain", "default")
+	assert.NoError(t, err)
+	assert.Equal(t, 1500, mtu)
+	mtu, err = mockContext.getPodMTU(ctx, "vpn", "default")
+	assert.NoError(t, err)
+	assert.Equal(t, 1400, mtu)
+
+	node.Annotations = map[string]string{podMTUKey: "8000"}
+	assert.NoError(t, m.cachedK8SClient.Update(ctx, node))
+	mtu, err = mockContext.getPodMTU(ctx, "plain", "default")
+	assert.NoError(t, err)
+	assert.Equal(t, 8000, mtu)
+
+	// IPv6 needs at least 1280
+	mockContext.enableIPv6 = true
+	node.Annotations = map[string]string{podMTUKey: "1000"}
+	assert.NoError(t, m.cachedK8SClient.Update(ctx, node))
+	mtu, err = mockContext.getPodMTU(ctx, "plain", "default")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, mtu)
+
+	_, err = mockContext.getPodMTU(ctx, "missing", "default")
+	assert.Error(t, err)
+}
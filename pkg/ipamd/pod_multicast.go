@@ -0,0 +1,33 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+// podMulticastKey is the pod annotation used, with ENABLE_POD_MULTICAST, to opt in to multicast and broadcast on the pod
+// veth.
+//
+// Only pods using a veth and an IP of the node ENIs support it. The traffic of a branch ENI pod (security groups for
+// pods) is forced through its VLAN, and the VPC doesn't deliver broadcast nor, outside of Transit Gateway multicast
+// domains, multicast to an ENI, so branch ENI and dedicated ENI pods are left as is. Since every veth is routed as a
+// point-to-point /32 link, forwarding multicast between pods or to the node ENIs takes a multicast router on the host,
+// e.g. smcroute or pimd.
+const podMulticastKey = "vpc.amazonaws.com/multicast"
+
+// getPodMulticast returns true if the pod is annotated with vpc.amazonaws.com/multicast=true
+func (c *IPAMContext) getPodMulticast(podName, podNamespace string) (bool, error) {
+	pod, err := c.GetPod(podName, podNamespace)
+	if err != nil {
+		return false, err
+	}
+	return pod.Annotations[podMulticastKey] == "true", nil
+}
@@ -0,0 +1,82 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+	pb "github.com/aws/amazon-vpc-cni-k8s/rpc"
+)
+
+func TestAddNetworkPodMulticast(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	assert.NoError(t, m.rawK8SClient.Create(context.Background(), &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "default", Annotations: map[string]string{podMulticastKey: "true"}},
+	}))
+	assert.NoError(t, m.rawK8SClient.Create(context.Background(), &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-2", Namespace: "default"},
+	}))
+
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)}, false))
+	}
+	mockContext := &IPAMContext{
+		awsClient:          m.awsutils,
+		networkClient:      m.network,
+		rawK8SClient:       m.rawK8SClient,
+		dataStore:          ds,
+		enableIPv4:         true,
+		enablePodMulticast: true,
+	}
+	s := &server{version: "1.2.3", ipamContext: mockContext}
+
+	for pod, multicast := range map[string]bool{"pod-1": true, "pod-2": false} {
+		m.awsutils.EXPECT().GetVPCIPv4CIDRs().Return([]string{"10.0.0.0/16"}, nil)
+		m.network.EXPECT().UseExternalSNAT().Return(true)
+		resp, err := s.AddNetwork(context.Background(), &pb.AddNetworkRequest{
+			ClientVersion:     "1.2.3",
+			K8S_POD_NAME:      pod,
+			K8S_POD_NAMESPACE: "default",
+			ContainerID:       "cid-" + pod,
+			IfName:            "eth0",
+			NetworkName:       "aws-cni",
+		})
+		assert.NoError(t, err)
+		assert.True(t, resp.Success)
+		assert.Equal(t, multicast, resp.Multicast, pod)
+	}
+
+	// A pod that can't be found is not started without its multicast setting
+	resp, err := s.AddNetwork(context.Background(), &pb.AddNetworkRequest{
+		ClientVersion:     "1.2.3",
+		K8S_POD_NAME:      "pod-3",
+		K8S_POD_NAMESPACE: "default",
+		ContainerID:       "cid-pod-3",
+		IfName:            "eth0",
+		NetworkName:       "aws-cni",
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.Success)
+}
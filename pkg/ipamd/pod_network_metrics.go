@@ -0,0 +1,99 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	podNetworkLabels           = []string{"namespace", "pod"}
+	podNetworkReceiveBytesDesc = prometheus.NewDesc("awscni_pod_network_receive_bytes_total",
+		"The number of bytes received by the pod", podNetworkLabels, nil)
+	podNetworkTransmitBytesDesc = prometheus.NewDesc("awscni_pod_network_transmit_bytes_total",
+		"The number of bytes transmitted by the pod", podNetworkLabels, nil)
+	podNetworkReceiveDropsDesc = prometheus.NewDesc("awscni_pod_network_receive_drops_total",
+		"The number of packets to the pod dropped on its veth", podNetworkLabels, nil)
+	podNetworkTransmitDropsDesc = prometheus.NewDesc("awscni_pod_network_transmit_drops_total",
+		"The number of packets from the pod dropped on its veth", podNetworkLabels, nil)
+	podNetworkConntrackFlowsDesc = prometheus.NewDesc("awscni_pod_network_conntrack_flows",
+		"The number of conntrack flows of the pod IP", podNetworkLabels, nil)
+)
+
+// podNetworkCollector exports the counters of the host-side veth device and the conntrack flow count of each pod with
+// an IP from the datastore. They are read when the metrics are scraped, so there is nothing to clean up when a pod
+// goes away.
+type podNetworkCollector struct {
+	ipam *IPAMContext
+}
+
+// podNetwork is a pod and the IPs of its host veth
+type podNetwork struct {
+	namespace string
+	name      string
+	ips       []string
+}
+
+func (p *podNetworkCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- podNetworkReceiveBytesDesc
+	ch <- podNetworkTransmitBytesDesc
+	ch <- podNetworkReceiveDropsDesc
+	ch <- podNetworkTransmitDropsDesc
+	ch <- podNetworkConntrackFlowsDesc
+}
+
+func (p *podNetworkCollector) Collect(ch chan<- prometheus.Metric) {
+	c := p.ipam
+	// A pod can hold more than one IP of its veth, e.g. while the IP of a previous sandbox is not released yet
+	pods := make(map[string]*podNetwork)
+	for _, veth := range c.hostVeths() {
+		if veth.K8SPodName == "" {
+			continue
+		}
+		pod, ok := pods[veth.Name]
+		if !ok {
+			pod = &podNetwork{namespace: veth.K8SPodNamespace, name: veth.K8SPodName}
+			pods[veth.Name] = pod
+		}
+		pod.ips = append(pod.ips, veth.IP)
+	}
+	if len(pods) == 0 {
+		return
+	}
+
+	flows, err := c.networkClient.CountConntrackFlows(c.enableIPv6)
+	if err != nil {
+		log.Warnf("Failed to count the conntrack flows of the pods: %v", err)
+		ipamdErrInc("collectPodNetworkMetrics")
+	}
+	for hostVeth, pod := range pods {
+		stats, err := c.networkClient.GetPodNetworkStats(hostVeth)
+		if err != nil {
+			// The pod may be set up or torn down right now
+			log.Debugf("Skipping the network metrics of pod %s/%s: %v", pod.namespace, pod.name, err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(podNetworkReceiveBytesDesc, prometheus.CounterValue, float64(stats.RxBytes), pod.namespace, pod.name)
+		ch <- prometheus.MustNewConstMetric(podNetworkTransmitBytesDesc, prometheus.CounterValue, float64(stats.TxBytes), pod.namespace, pod.name)
+		ch <- prometheus.MustNewConstMetric(podNetworkReceiveDropsDesc, prometheus.CounterValue, float64(stats.RxDropped), pod.namespace, pod.name)
+		ch <- prometheus.MustNewConstMetric(podNetworkTransmitDropsDesc, prometheus.CounterValue, float64(stats.TxDropped), pod.namespace, pod.name)
+		if flows != nil {
+			count := 0
+			for _, ip := range pod.ips {
+				count += flows[ip]
+			}
+			ch <- prometheus.MustNewConstMetric(podNetworkConntrackFlowsDesc, prometheus.GaugeValue, float64(count), pod.namespace, pod.name)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/networkutils"
+)
+
+func TestPodNetworkCollector(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP("10.0.0.1"), Mask: net.CIDRMask(32, 32)}, false))
+	podIP, _, err := ds.AssignPodIPv4Address(datastore.IPAMKey{NetworkName: "aws-cni", ContainerID: "cid-1", IfName: "eth0"},
+		datastore.IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "pod-1"})
+	assert.NoError(t, err)
+
+	collector := &podNetworkCollector{ipam: &IPAMContext{networkClient: m.network, dataStore: ds, enableIPv4: true}}
+	m.network.EXPECT().GetHostVethName("default", "pod-1").Return("eni8ea2c11fe35").Times(2)
+	m.network.EXPECT().CountConntrackFlows(false).Return(map[string]int{podIP: 3, "10.0.0.9": 1}, nil)
+	m.network.EXPECT().GetPodNetworkStats("eni8ea2c11fe35").Return(&networkutils.PodNetworkStats{
+		RxBytes: 100, TxBytes: 200, RxDropped: 1, TxDropped: 2}, nil).Times(2)
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP awscni_pod_network_conntrack_flows The number of conntrack flows of the pod IP
+# TYPE awscni_pod_network_conntrack_flows gauge
+awscni_pod_network_conntrack_flows{namespace="default",pod="pod-1"} 3
+# HELP awscni_pod_network_receive_bytes_total The number of bytes received by the pod
+# TYPE awscni_pod_network_receive_bytes_total counter
+awscni_pod_network_receive_bytes_total{namespace="default",pod="pod-1"} 100
+# HELP awscni_pod_network_transmit_drops_total The number of packets from the pod dropped on its veth
+# TYPE awscni_pod_network_transmit_drops_total counter
+awscni_pod_network_transmit_drops_total{namespace="default",pod="pod-1"} 2
+`), "awscni_pod_network_conntrack_flows", "awscni_pod_network_receive_bytes_total", "awscni_pod_network_transmit_drops_total"))
+
+	// The veth counters are still exported without conntrack
+	m.network.EXPECT().CountConntrackFlows(false).Return(nil, errors.New("netlink error"))
+	assert.Equal(t, 4, testutil.CollectAndCount(collector))
+}
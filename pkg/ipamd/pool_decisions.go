@@ -0,0 +1,192 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+)
+
+const (
+	poolOperationIncrease = "increase"
+	poolOperationDecrease = "decrease"
+	poolOperationFreeENI  = "freeENI"
+
+	poolActionNone = "none"
+)
+
+// PoolTargets are the warm pool settings a decision was taken with
+type PoolTargets struct {
+	WarmENITarget    int
+	WarmIPTarget     int
+	MinimumIPTarget  int
+	WarmPrefixTarget int
+	MaxENI           int
+}
+
+// PoolDecision is a decision of the warm pool manager to grow or shrink the pool, with the state it was taken in.
+// Identical consecutive decisions are kept as one, with the state of the first one.
+type PoolDecision struct {
+	// Time of the first of the identical decisions
+	Time time.Time
+	// LastTime of the identical decisions
+	LastTime time.Time
+	// Count of the identical decisions
+	Count int
+	// Operation is increase, decrease or freeENI
+	Operation string
+	// Action taken, "none" if the pool was left as is
+	Action string
+	// Reason for not acting
+	Reason string `json:",omitempty"`
+	// Error of the action
+	Error   string `json:",omitempty"`
+	Stats   datastore.DataStoreStats
+	ENIs    int
+	Targets PoolTargets
+}
+
+// sameOutcome returns true if both decisions have the same operation and outcome
+func (d *PoolDecision) sameOutcome(other *PoolDecision) bool {
+	return d.Operation == other.Operation && d.Action == other.Action && d.Reason == other.Reason && d.Error == other.Error
+}
+
+// poolDecisionLog keeps the last decisions of the warm pool manager, and persists them so that they survive ipamd
+// restarts
+type poolDecisionLog struct {
+	lock         sync.Mutex
+	size         int
+	decisions    []PoolDecision
+	checkpointer datastore.Checkpointer
+}
+
+func newPoolDecisionLog(size int, checkpointer datastore.Checkpointer) *poolDecisionLog {
+	l := &poolDecisionLog{size: size, checkpointer: checkpointer}
+	if err := checkpointer.Restore(&l.decisions); err != nil && !os.IsNotExist(err) {
+		log.Warnf("Failed to restore the pool decision log: %v", err)
+	}
+	if len(l.decisions) > size {
+		l.decisions = l.decisions[len(l.decisions)-size:]
+	}
+	return l
+}
+
+// record adds a decision to the log, or counts it as a repeat of the last one. The log is only persisted when a
+// decision is added, so the repeat count of the last decision can be behind on disk.
+func (l *poolDecisionLog) record(decision *PoolDecision) {
+	if l == nil {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if n := len(l.decisions); n > 0 && l.decisions[n-1].sameOutcome(decision) {
+		l.decisions[n-1].LastTime = decision.Time
+		l.decisions[n-1].Count++
+		return
+	}
+	decision.LastTime = decision.Time
+	decision.Count = 1
+	l.decisions = append(l.decisions, *decision)
+	if len(l.decisions) > l.size {
+		l.decisions = append([]PoolDecision(nil), l.decisions[len(l.decisions)-l.size:]...)
+	}
+	if err := l.checkpointer.Checkpoint(l.decisions); err != nil {
+		log.Warnf("Failed to persist the pool decision log: %v", err)
+	}
+}
+
+// list returns the decisions of operation, or of all operations if it is empty, that were last taken at or after since
+func (l *poolDecisionLog) list(since time.Time, operation string) []PoolDecision {
+	decisions := []PoolDecision{}
+	if l == nil {
+		return decisions
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for _, decision := range l.decisions {
+		if decision.LastTime.Before(since) || (operation != "" && decision.Operation != operation) {
+			continue
+		}
+		decisions = append(decisions, decision)
+	}
+	return decisions
+}
+
+// newPoolDecision returns a decision of operation holding the current state of the pool
+func (c *IPAMContext) newPoolDecision(operation string) *PoolDecision {
+	return &PoolDecision{
+		Time:      time.Now(),
+		Operation: operation,
+		Action:    poolActionNone,
+		Stats:     *c.dataStore.GetIPStats(ipV4AddrFamily),
+		ENIs:      c.dataStore.GetENIs(),
+		Targets: PoolTargets{
+			WarmENITarget:    c.warmENITarget,
+			WarmIPTarget:     c.warmIPTarget,
+			MinimumIPTarget:  c.minimumIPTarget,
+			WarmPrefixTarget: c.warmPrefixTarget,
+			MaxENI:           c.maxENI,
+		},
+	}
+}
+
+// recordPoolDecision adds decision to the pool decision log, if enabled
+func (c *IPAMContext) recordPoolDecision(decision *PoolDecision) {
+	c.poolDecisions.record(decision)
+}
+
+// poolChangeAction describes how many IPs, or prefixes in prefix delegation mode, were assigned or released since
+// decision was taken
+func (c *IPAMContext) poolChangeAction(decision *PoolDecision, verb string) string {
+	stats := c.dataStore.GetIPStats(ipV4AddrFamily)
+	change, unit := stats.TotalIPs-decision.Stats.TotalIPs, "IPs"
+	if c.enablePrefixDelegation {
+		change, unit = stats.TotalPrefixes-decision.Stats.TotalPrefixes, "prefixes"
+	}
+	if change < 0 {
+		change = -change
+	}
+	if change == 0 {
+		return poolActionNone
+	}
+	return fmt.Sprintf("%s %d %s", verb, change, unit)
+}
+
+// poolDecisionsRequestHandler serves the pool decision log, filtered by the optional `since` (RFC 3339 time) and
+// `operation` query parameters
+func poolDecisionsRequestHandler(ipam *IPAMContext) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var since time.Time
+		if value := r.URL.Query().Get("since"); value != "" {
+			var err error
+			if since, err = time.Parse(time.RFC3339, value); err != nil {
+				http.Error(w, fmt.Sprintf("invalid since %q, expected an RFC 3339 time", value), http.StatusBadRequest)
+				return
+			}
+		}
+		responseJSON, err := json.Marshal(ipam.poolDecisions.list(since, r.URL.Query().Get("operation")))
+		if err != nil {
+			log.Errorf("Failed to marshal pool decisions: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		logErr(w.Write(responseJSON))
+	}
+}
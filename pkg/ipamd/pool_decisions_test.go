@@ -0,0 +1,128 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+)
+
+func TestPoolDecisionLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pool-decisions.json")
+	decisions := newPoolDecisionLog(2, datastore.NewJSONFile(path))
+	start := time.Now()
+
+	decisions.record(&PoolDecision{Time: start, Operation: poolOperationIncrease, Action: "assigned 1 IPs"})
+	decisions.record(&PoolDecision{Time: start.Add(time.Second), Operation: poolOperationIncrease, Action: poolActionNone, Reason: "max ENI limit reached"})
+	// Identical consecutive decisions are counted
+	decisions.record(&PoolDecision{Time: start.Add(2 * time.Second), Operation: poolOperationIncrease, Action: poolActionNone, Reason: "max ENI limit reached"})
+	list := decisions.list(time.Time{}, "")
+	assert.Len(t, list, 2)
+	assert.Equal(t, 2, list[1].Count)
+	assert.True(t, list[1].LastTime.Equal(start.Add(2*time.Second)))
+
+	// The oldest decision is dropped
+	decisions.record(&PoolDecision{Time: start.Add(3 * time.Second), Operation: poolOperationFreeENI, Action: "freed ENI eni-2"})
+	list = decisions.list(time.Time{}, "")
+	assert.Len(t, list, 2)
+	assert.Equal(t, "max ENI limit reached", list[0].Reason)
+	assert.Equal(t, "freed ENI eni-2", list[1].Action)
+
+	assert.Len(t, decisions.list(start.Add(3*time.Second), ""), 1)
+	assert.Len(t, decisions.list(time.Time{}, poolOperationIncrease), 1)
+
+	// The log survives restarts
+	restored := newPoolDecisionLog(1, datastore.NewJSONFile(path))
+	list = restored.list(time.Time{}, "")
+	assert.Len(t, list, 1)
+	assert.Equal(t, "freed ENI eni-2", list[0].Action)
+
+	// A disabled log records nothing
+	var disabled *poolDecisionLog
+	disabled.record(&PoolDecision{Time: start})
+	assert.Empty(t, disabled.list(time.Time{}, ""))
+}
+
+func TestTryFreeENIRecordsDecision(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP("10.0.0.1"), Mask: net.CIDRMask(32, 32)}, false))
+	mockContext := &IPAMContext{
+		awsClient:       m.awsutils,
+		cachedK8SClient: m.cachedK8SClient,
+		dataStore:       ds,
+		maxENI:          3,
+		warmIPTarget:    noWarmIPTarget,
+		minimumIPTarget: noMinimumIPTarget,
+		poolDecisions:   newPoolDecisionLog(10, datastore.NullCheckpoint{}),
+	}
+
+	mockContext.tryFreeENI(context.Background())
+
+	list := mockContext.poolDecisions.list(time.Time{}, "")
+	assert.Len(t, list, 1)
+	assert.Equal(t, poolOperationFreeENI, list[0].Operation)
+	assert.Equal(t, poolActionNone, list[0].Action)
+	assert.Equal(t, "no ENI can be freed without going below the warm targets", list[0].Reason)
+	assert.Equal(t, 1, list[0].Stats.TotalIPs)
+	assert.Equal(t, 1, list[0].ENIs)
+	assert.Equal(t, 3, list[0].Targets.MaxENI)
+}
+
+func TestPoolDecisionsRequestHandler(t *testing.T) {
+	start := time.Date(2022, 6, 1, 3, 0, 0, 0, time.UTC)
+	mockContext := &IPAMContext{poolDecisions: newPoolDecisionLog(10, datastore.NullCheckpoint{})}
+	mockContext.poolDecisions.record(&PoolDecision{Time: start, Operation: poolOperationIncrease, Action: "allocated an ENI"})
+	mockContext.poolDecisions.record(&PoolDecision{Time: start.Add(time.Hour), Operation: poolOperationFreeENI, Action: "freed ENI eni-2"})
+
+	get := func(url string) (int, []PoolDecision) {
+		w := httptest.NewRecorder()
+		poolDecisionsRequestHandler(mockContext)(w, httptest.NewRequest(http.MethodGet, url, nil))
+		var decisions []PoolDecision
+		if w.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &decisions))
+		}
+		return w.Code, decisions
+	}
+
+	code, decisions := get("/v1/pool-decisions")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, decisions, 2)
+
+	code, decisions = get("/v1/pool-decisions?since=2022-06-01T03:30:00Z")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, decisions, 1)
+	assert.Equal(t, "freed ENI eni-2", decisions[0].Action)
+
+	code, decisions = get("/v1/pool-decisions?operation=increase")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, decisions, 1)
+	assert.Equal(t, "allocated an ENI", decisions[0].Action)
+
+	code, _ = get("/v1/pool-decisions?since=yesterday")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
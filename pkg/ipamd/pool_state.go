@@ -0,0 +1,268 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/pkg/errors"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+)
+
+// poolStateImportTTL is how long the addresses reserved by an imported pool state wait for their pods to start on
+// the node, before they are released
+const poolStateImportTTL = 10 * time.Minute
+
+// PoolState is the allocation state of a node, exported to pre-reserve the IPs of its pods on a replacement node in
+// the same subnets
+type PoolState struct {
+	InstanceID string
+	Pods       []PoolStatePod
+}
+
+// PoolStatePod is the IPv4 address of a pod and the ENI and subnet it is on
+type PoolStatePod struct {
+	K8SPodNamespace string
+	K8SPodName      string
+	K8SPodUID       string `json:",omitempty"`
+	IPv4            string
+	ENIID           string `json:",omitempty"`
+	SubnetIPv4CIDR  string `json:",omitempty"`
+}
+
+// poolState returns the IPv4 addresses of the pods in the datastore, sorted by namespace and name. Allocations without
+// a pod, like the ones recovered from the routes, are left out.
+func (c *IPAMContext) poolState() (*PoolState, error) {
+	enis, err := c.awsClient.GetAttachedENIs()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get the attached ENIs")
+	}
+	ipENIs := make(map[string]awsutils.ENIMetadata)
+	for _, eni := range enis {
+		for _, addr := range eni.IPv4Addresses {
+			ipENIs[aws.StringValue(addr.PrivateIpAddress)] = eni
+		}
+	}
+
+	state := &PoolState{InstanceID: c.awsClient.GetInstanceID(), Pods: []PoolStatePod{}}
+	for _, info := range c.dataStore.AllocatedIPs() {
+		if info.Metadata.K8SPodName == "" {
+			continue
+		}
+		eni := ipENIs[info.IP]
+		state.Pods = append(state.Pods, PoolStatePod{
+			K8SPodNamespace: info.Metadata.K8SPodNamespace,
+			K8SPodName:      info.Metadata.K8SPodName,
+			K8SPodUID:       info.Metadata.K8SPodUID,
+			IPv4:            info.IP,
+			ENIID:           eni.ENIID,
+			SubnetIPv4CIDR:  eni.SubnetIPv4CIDR,
+		})
+	}
+	sort.Slice(state.Pods, func(i, j int) bool {
+		if state.Pods[i].K8SPodNamespace != state.Pods[j].K8SPodNamespace {
+			return state.Pods[i].K8SPodNamespace < state.Pods[j].K8SPodNamespace
+		}
+		return state.Pods[i].K8SPodName < state.Pods[j].K8SPodName
+	})
+	return state, nil
+}
+
+// poolStateRequestHandler serves the allocation state of the node, to be imported on a replacement node with
+// POOL_STATE_IMPORT_FILE
+func poolStateRequestHandler(ipam *IPAMContext) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		state, err := ipam.poolState()
+		if err != nil {
+			log.Errorf("Failed to get the pool state: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		responseJSON, err := json.Marshal(state)
+		if err != nil {
+			log.Errorf("Failed to marshal pool state: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		logErr(w.Write(responseJSON))
+	}
+}
+
+// readPoolState reads a pool state exported by another node
+func readPoolState(path string) (*PoolState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var state PoolState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, errors.Wrapf(err, "invalid pool state in %s", path)
+	}
+	return &state, nil
+}
+
+// importPoolState reserves the IPs of the pods of the pool state in c.poolStateImportFile on the ENIs of the node in
+// the same subnets, so that the pods get the same IPs when they start here. An IP is reserved under the namespace and
+// name of its pod, see datastore.ImportedIPAMKey, until the pod claims it or poolStateImportTTL expires. IPs that
+// are in no subnet of the node, or that EC2 can't assign, are skipped.
+func (c *IPAMContext) importPoolState(ctx context.Context, enis []awsutils.ENIMetadata) {
+	state, err := readPoolState(c.poolStateImportFile)
+	if err != nil {
+		log.Errorf("Failed to import the pool state: %v", err)
+		ipamdErrInc("importPoolState")
+		return
+	}
+	log.Infof("Importing the pool state of %d pods of instance %s", len(state.Pods), state.InstanceID)
+	c.poolStateImportDeadline = time.Now().Add(poolStateImportTTL)
+
+	// IPs already on an ENI only need to be reserved, the others are assigned to an ENI of their subnet first
+	onENI := make(map[string]string)
+	free := make(map[string]int, len(enis))
+	for _, eni := range enis {
+		for _, addr := range eni.IPv4Addresses {
+			onENI[aws.StringValue(addr.PrivateIpAddress)] = eni.ENIID
+		}
+		free[eni.ENIID] = c.maxIPsPerENI - (len(eni.IPv4Addresses) - 1)
+	}
+	pods := make(map[string]PoolStatePod, len(state.Pods))
+	toAssign := make(map[string][]string)
+	for _, pod := range state.Pods {
+		ip := net.ParseIP(pod.IPv4)
+		if ip == nil || ip.To4() == nil || pod.K8SPodName == "" {
+			log.Warnf("Skipping invalid pool state entry %+v", pod)
+			continue
+		}
+		if c.dataStore.IsIPAssigned(ip) {
+			continue
+		}
+		if _, ok := onENI[pod.IPv4]; ok {
+			pods[pod.IPv4] = pod
+			continue
+		}
+		eniID := importENI(enis, free, ip)
+		if eniID == "" {
+			log.Warnf("No ENI with room in the subnet of IP %s of pod %s/%s", pod.IPv4, pod.K8SPodNamespace, pod.K8SPodName)
+			continue
+		}
+		free[eniID]--
+		pods[pod.IPv4] = pod
+		toAssign[eniID] = append(toAssign[eniID], pod.IPv4)
+	}
+
+	for eniID, ips := range toAssign {
+		for _, ip := range c.assignImportedIPs(ctx, eniID, ips) {
+			_, ipv4Cidr, _ := net.ParseCIDR(ip + "/32")
+			if err := c.dataStore.AddIPv4CidrToStore(eniID, *ipv4Cidr, false); err != nil && err.Error() != datastore.IPAlreadyInStoreError {
+				log.Warnf("Failed to add imported IP %s of ENI %s to the datastore: %v", ip, eniID, err)
+				continue
+			}
+			onENI[ip] = eniID
+		}
+	}
+
+	reserved := 0
+	for ip, pod := range pods {
+		eniID, ok := onENI[ip]
+		if !ok {
+			continue
+		}
+		_, ipv4Cidr, _ := net.ParseCIDR(ip + "/32")
+		pin := &datastore.AddressPin{CIDR: ipv4Cidr, ENIIDs: []string{eniID}}
+		ipamKey := datastore.ImportedIPAMKey(pod.K8SPodNamespace, pod.K8SPodName)
+		ipamMetadata := datastore.IPAMMetadata{K8SPodNamespace: pod.K8SPodNamespace, K8SPodName: pod.K8SPodName}
+		if _, _, err := c.dataStore.AssignPodIPv4AddressPinned(ipamKey, ipamMetadata, pin); err != nil {
+			log.Warnf("Failed to reserve IP %s for pod %s/%s: %v", ip, pod.K8SPodNamespace, pod.K8SPodName, err)
+			continue
+		}
+		reserved++
+	}
+	log.Infof("Reserved %d IPs of the imported pool state until %s", reserved, c.poolStateImportDeadline.Format(time.RFC3339))
+}
+
+// importENI returns the ID of an ENI with a free slot whose subnet contains ip, or an empty string
+func importENI(enis []awsutils.ENIMetadata, free map[string]int, ip net.IP) string {
+	for _, eni := range enis {
+		_, subnet, err := net.ParseCIDR(eni.SubnetIPv4CIDR)
+		if err != nil || !subnet.Contains(ip) || free[eni.ENIID] <= 0 {
+			continue
+		}
+		return eni.ENIID
+	}
+	return ""
+}
+
+// assignImportedIPs assigns the IPs to the ENI and returns the ones that were assigned. EC2 fails the whole call if
+// one IP is taken in the subnet, so they are then assigned one at a time.
+func (c *IPAMContext) assignImportedIPs(ctx context.Context, eniID string, ips []string) []string {
+	if err := c.awsClient.AllocIPAddressesByIP(ctx, eniID, ips); err == nil {
+		return ips
+	}
+	if len(ips) == 1 {
+		return nil
+	}
+	var assigned []string
+	for _, ip := range ips {
+		if err := c.awsClient.AllocIPAddressesByIP(ctx, eniID, []string{ip}); err != nil {
+			log.Warnf("Failed to assign imported IP %s to ENI %s: %v", ip, eniID, err)
+			continue
+		}
+		assigned = append(assigned, ip)
+	}
+	return assigned
+}
+
+// claimImportedIP hands the IP reserved for the pod by an imported pool state over to its sandbox, if there is one
+func (c *IPAMContext) claimImportedIP(ipamKey datastore.IPAMKey, ipamMetadata datastore.IPAMMetadata) {
+	if c.poolStateImportFile == "" {
+		return
+	}
+	addresses, err := c.dataStore.ClaimImportedPodIPAddress(ipamKey, ipamMetadata)
+	if errors.Is(err, datastore.ErrUnknownPod) {
+		return
+	}
+	if err != nil {
+		log.Warnf("Failed to claim the imported IP of pod %s/%s: %v", ipamMetadata.K8SPodNamespace, ipamMetadata.K8SPodName, err)
+		return
+	}
+	log.Infof("Pod %s/%s claimed its imported IP %s", ipamMetadata.K8SPodNamespace, ipamMetadata.K8SPodName, addresses.IPv4)
+}
+
+// releaseUnclaimedPoolStateImports releases the IPs reserved by an imported pool state that no pod claimed before
+// poolStateImportTTL expired. They go back to the pool as free IPs.
+func (c *IPAMContext) releaseUnclaimedPoolStateImports() {
+	if c.poolStateImportDeadline.IsZero() || time.Now().Before(c.poolStateImportDeadline) {
+		return
+	}
+	for _, info := range c.dataStore.AllocatedIPs() {
+		ipamKey := datastore.ImportedIPAMKey(info.Metadata.K8SPodNamespace, info.Metadata.K8SPodName)
+		if info.IPAMKey != ipamKey {
+			continue
+		}
+		if _, _, _, err := c.dataStore.UnassignPodIPAddress(ipamKey); err != nil {
+			log.Warnf("Failed to release the unclaimed imported IP %s: %v", info.IP, err)
+			continue
+		}
+		log.Infof("Released imported IP %s, pod %s/%s didn't claim it in time", info.IP, info.Metadata.K8SPodNamespace, info.Metadata.K8SPodName)
+	}
+	c.poolStateImportDeadline = time.Time{}
+}
@@ -0,0 +1,166 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+	pb "github.com/aws/amazon-vpc-cni-k8s/rpc"
+)
+
+func TestPoolState(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP("10.0.0.5"), Mask: net.CIDRMask(32, 32)}, false))
+	_, _, err := ds.AssignPodIPv4Address(datastore.IPAMKey{NetworkName: "aws-cni", ContainerID: "cid-1", IfName: "eth0"},
+		datastore.IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "pod-1", K8SPodUID: "uid-1"})
+	assert.NoError(t, err)
+
+	m.awsutils.EXPECT().GetAttachedENIs().Return([]awsutils.ENIMetadata{{
+		ENIID:          "eni-1",
+		SubnetIPv4CIDR: "10.0.0.0/24",
+		IPv4Addresses: []*ec2.NetworkInterfacePrivateIpAddress{
+			{PrivateIpAddress: aws.String("10.0.0.4"), Primary: aws.Bool(true)},
+			{PrivateIpAddress: aws.String("10.0.0.5"), Primary: aws.Bool(false)},
+		},
+	}}, nil)
+	m.awsutils.EXPECT().GetInstanceID().Return("i-1")
+	mockContext := &IPAMContext{awsClient: m.awsutils, dataStore: ds, enableIPv4: true}
+
+	w := httptest.NewRecorder()
+	poolStateRequestHandler(mockContext)(w, httptest.NewRequest(http.MethodGet, "/v1/pool-state", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var state PoolState
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &state))
+	assert.Equal(t, PoolState{
+		InstanceID: "i-1",
+		Pods: []PoolStatePod{{
+			K8SPodNamespace: "default",
+			K8SPodName:      "pod-1",
+			K8SPodUID:       "uid-1",
+			IPv4:            "10.0.0.5",
+			ENIID:           "eni-1",
+			SubnetIPv4CIDR:  "10.0.0.0/24",
+		}},
+	}, state)
+}
+
+func TestImportPoolState(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	state := PoolState{InstanceID: "i-old", Pods: []PoolStatePod{
+		{K8SPodNamespace: "default", K8SPodName: "on-eni", IPv4: "10.0.0.5"},
+		{K8SPodNamespace: "default", K8SPodName: "assigned", IPv4: "10.0.1.10"},
+		{K8SPodNamespace: "default", K8SPodName: "taken", IPv4: "10.0.1.11"},
+		{K8SPodNamespace: "default", K8SPodName: "other-subnet", IPv4: "10.9.0.1"},
+	}}
+	data, err := json.Marshal(state)
+	assert.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "pool-state.json")
+	assert.NoError(t, os.WriteFile(path, data, 0600))
+
+	// The IPs already on the ENIs were added to the datastore by setupENIsOnInit
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	assert.NoError(t, ds.AddENI("eni-2", 1, false, false, false))
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP("10.0.0.5"), Mask: net.CIDRMask(32, 32)}, false))
+	enis := []awsutils.ENIMetadata{{
+		ENIID:          "eni-1",
+		SubnetIPv4CIDR: "10.0.0.0/24",
+		IPv4Addresses: []*ec2.NetworkInterfacePrivateIpAddress{
+			{PrivateIpAddress: aws.String("10.0.0.4"), Primary: aws.Bool(true)},
+			{PrivateIpAddress: aws.String("10.0.0.5"), Primary: aws.Bool(false)},
+		},
+	}, {
+		ENIID:          "eni-2",
+		SubnetIPv4CIDR: "10.0.1.0/24",
+		IPv4Addresses: []*ec2.NetworkInterfacePrivateIpAddress{
+			{PrivateIpAddress: aws.String("10.0.1.4"), Primary: aws.Bool(true)},
+		},
+	}}
+
+	ctx := context.Background()
+	gomock.InOrder(
+		m.awsutils.EXPECT().AllocIPAddressesByIP(gomock.Any(), "eni-2", []string{"10.0.1.10", "10.0.1.11"}).Return(errors.New("in use")),
+		m.awsutils.EXPECT().AllocIPAddressesByIP(gomock.Any(), "eni-2", []string{"10.0.1.10"}).Return(nil),
+		m.awsutils.EXPECT().AllocIPAddressesByIP(gomock.Any(), "eni-2", []string{"10.0.1.11"}).Return(errors.New("in use")),
+	)
+	mockContext := &IPAMContext{
+		awsClient:           m.awsutils,
+		networkClient:       m.network,
+		dataStore:           ds,
+		enableIPv4:          true,
+		maxIPsPerENI:        3,
+		poolStateImportFile: path,
+	}
+	mockContext.importPoolState(ctx, enis)
+
+	reserved := make(map[string]datastore.IPAMKey)
+	for _, info := range ds.AllocatedIPs() {
+		reserved[info.IP] = info.IPAMKey
+	}
+	assert.Equal(t, map[string]datastore.IPAMKey{
+		"10.0.0.5":  datastore.ImportedIPAMKey("default", "on-eni"),
+		"10.0.1.10": datastore.ImportedIPAMKey("default", "assigned"),
+	}, reserved)
+
+	// The pod claims its reserved IP when it starts on the node
+	s := &server{version: "1.2.3", ipamContext: mockContext}
+	m.awsutils.EXPECT().GetVPCIPv4CIDRs().Return([]string{"10.0.0.0/16"}, nil)
+	m.network.EXPECT().UseExternalSNAT().Return(true)
+	resp, err := s.AddNetwork(ctx, &pb.AddNetworkRequest{
+		ClientVersion:     "1.2.3",
+		K8S_POD_NAME:      "assigned",
+		K8S_POD_NAMESPACE: "default",
+		K8S_POD_UID:       "uid-1",
+		ContainerID:       "cid-1",
+		IfName:            "eth0",
+		NetworkName:       "aws-cni",
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.Success)
+	assert.Equal(t, "10.0.1.10", resp.IPv4Addr)
+	assert.Equal(t, 2, ds.GetIPStats(ipV4AddrFamily).AssignedIPs)
+
+	// The IPs of the pods that didn't start are released once the import expires
+	mockContext.releaseUnclaimedPoolStateImports()
+	assert.Equal(t, 2, ds.GetIPStats(ipV4AddrFamily).AssignedIPs)
+	mockContext.poolStateImportDeadline = time.Now().Add(-time.Second)
+	mockContext.releaseUnclaimedPoolStateImports()
+	allocated := ds.AllocatedIPs()
+	assert.Len(t, allocated, 1)
+	assert.Equal(t, "10.0.1.10", allocated[0].IP)
+	assert.Equal(t, datastore.IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "assigned", K8SPodUID: "uid-1"}, allocated[0].Metadata)
+	assert.True(t, mockContext.poolStateImportDeadline.IsZero())
+}
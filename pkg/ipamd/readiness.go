@@ -0,0 +1,261 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/eniconfig"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/eventrecorder"
+)
+
+const (
+	readinessPass = "Pass"
+	readinessWarn = "Warn"
+	readinessFail = "Fail"
+
+	misconfigurationEventReason = "MisconfigurationDetected"
+)
+
+// ReadinessCheck is the result of one of the checks run at startup
+type ReadinessCheck struct {
+	Name    string
+	Status  string
+	Message string `json:",omitempty"`
+}
+
+// ReadinessReport is the result of the startup validation. Ready is false if any check failed, warnings don't count.
+type ReadinessReport struct {
+	Ready  bool
+	Time   time.Time
+	Checks []ReadinessCheck
+}
+
+// intEnvVars and boolEnvVars are validated at startup, since an unparsable value silently falls back to the default
+var (
+	intEnvVars  = []string{envWarmIPTarget, envMinimumIPTarget, envWarmENITarget, envMaxENI, envWarmPrefixTarget}
+	boolEnvVars = []string{envCustomNetworkCfg, envDisableENIProvisioning, envEnablePodENI, envEnableIpv4PrefixDelegation,
+		envEnableIPv4, envEnableIPv6, envManageUntaggedENI, envAnnotatePodIP, envExcludeEFAENIs}
+)
+
+// RunStartupValidation checks the node for misconfigurations that otherwise only surface as runtime errors, stores the
+// report for the /v1/readiness introspection endpoint, and raises a warning event on the aws-node pod if a check failed.
+func (c *IPAMContext) RunStartupValidation() {
+	report := c.validateStartup(context.TODO())
+	c.setReadinessReport(report)
+
+	var failed []string
+	for _, check := range report.Checks {
+		switch check.Status {
+		case readinessFail:
+			log.Errorf("Startup check %s failed: %s", check.Name, check.Message)
+			failed = append(failed, fmt.Sprintf("%s: %s", check.Name, check.Message))
+		case readinessWarn:
+			log.Warnf("Startup check %s: %s", check.Name, check.Message)
+		}
+	}
+	if len(failed) > 0 {
+		eventrecorder.Get().BroadcastEvent(v1.EventTypeWarning, misconfigurationEventReason, strings.Join(failed, "; "))
+	}
+}
+
+func (c *IPAMContext) validateStartup(ctx context.Context) *ReadinessReport {
+	report := &ReadinessReport{
+		Time: time.Now(),
+		Checks: []ReadinessCheck{
+			c.checkEC2Permissions(),
+			c.checkSubnetHeadroom(ctx),
+			c.checkSubnetPrefixes(ctx),
+			c.checkSubnetPlacement(ctx),
+			c.checkSecurityGroups(ctx),
+			checkEnvVars(),
+			c.checkKernelSettings(),
+		},
+	}
+	report.Ready = true
+	for _, check := range report.Checks {
+		if check.Status == readinessFail {
+			report.Ready = false
+		}
+	}
+	return report
+}
+
+func (c *IPAMContext) checkEC2Permissions() ReadinessCheck {
+	check := ReadinessCheck{Name: "EC2Permissions", Status: readinessPass}
+	denied := c.awsClient.CheckEC2Permissions(!c.disableENIProvisioning)
+	if len(denied) == 0 {
+		return check
+	}
+	actions := make([]string, 0, len(denied))
+	for action := range denied {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	check.Status = readinessFail
+	check.Message = fmt.Sprintf("missing IAM permissions for %s", strings.Join(actions, ", "))
+	return check
+}
+
+// checkSubnetHeadroom checks that the subnet new ENIs are created in still has free IP addresses
+func (c *IPAMContext) checkSubnetHeadroom(ctx context.Context) ReadinessCheck {
+	check := ReadinessCheck{Name: "SubnetHeadroom", Status: readinessPass}
+	if c.disableENIProvisioning {
+		check.Message = "ENI provisioning is disabled"
+		return check
+	}
+	subnetID, err := c.newENISubnetID(ctx)
+	if err != nil {
+		check.Status = readinessWarn
+		check.Message = fmt.Sprintf("unable to find the ENIConfig of the node: %v", err)
+		return check
+	}
+	available, err := c.awsClient.GetSubnetAvailableIPCount(subnetID)
+	switch {
+	case err != nil:
+		// ec2:DescribeSubnets isn't part of the managed CNI policy, so this is only a warning
+		check.Status = readinessWarn
+		check.Message = fmt.Sprintf("unable to check the subnet: %v", err)
+	case available == 0:
+		check.Status = readinessFail
+		check.Message = "the subnet has no free IP addresses"
+	case available < c.maxIPsPerENI:
+		check.Status = readinessWarn
+		check.Message = fmt.Sprintf("the subnet has %d free IP addresses, fewer than one ENI holds", available)
+	default:
+		check.Message = fmt.Sprintf("the subnet has %d free IP addresses", available)
+	}
+	return check
+}
+
+// checkSubnetPlacement checks that new ENIs can be created in the subnet of the ENIConfig, which has to be in the zone
+// of the instance, and on its Outpost if it runs on one. Without custom networking, new ENIs use the subnet of the
+// primary ENI, which is compatible by definition.
+func (c *IPAMContext) checkSubnetPlacement(ctx context.Context) ReadinessCheck {
+	check := ReadinessCheck{Name: "SubnetPlacement", Status: readinessPass}
+	if c.disableENIProvisioning {
+		check.Message = "ENI provisioning is disabled"
+		return check
+	}
+	instance, err := c.awsClient.GetInstancePlacement(ctx)
+	if err != nil {
+		check.Status = readinessWarn
+		check.Message = fmt.Sprintf("unable to find the placement of the instance: %v", err)
+		return check
+	}
+	if !c.useCustomNetworking {
+		check.Message = fmt.Sprintf("the instance is in %s", instance)
+		return check
+	}
+	subnetID, err := c.newENISubnetID(ctx)
+	if err != nil {
+		check.Status = readinessWarn
+		check.Message = fmt.Sprintf("unable to find the ENIConfig of the node: %v", err)
+		return check
+	}
+	var placementErr *awsutils.SubnetPlacementError
+	err = c.awsClient.CheckSubnetPlacement(ctx, subnetID)
+	switch {
+	case errors.As(err, &placementErr):
+		check.Status = readinessFail
+		check.Message = fmt.Sprintf("ENIConfig %v", err)
+	case err != nil:
+		check.Status = readinessWarn
+		check.Message = fmt.Sprintf("unable to check the placement of the ENIConfig subnet %s: %v", subnetID, err)
+	default:
+		check.Message = fmt.Sprintf("the instance and the ENIConfig subnet %s are in %s", subnetID, instance)
+	}
+	return check
+}
+
+// checkSecurityGroups checks that the security groups of the ENIConfig exist. Without custom networking, new ENIs use
+// the security groups of the primary ENI, which exist by definition.
+func (c *IPAMContext) checkSecurityGroups(ctx context.Context) ReadinessCheck {
+	check := ReadinessCheck{Name: "SecurityGroups", Status: readinessPass}
+	if !c.useCustomNetworking || c.disableENIProvisioning {
+		return check
+	}
+	eniCfg, err := eniconfig.MyENIConfig(ctx, c.cachedK8SClient)
+	if err != nil {
+		check.Status = readinessFail
+		check.Message = fmt.Sprintf("custom networking is enabled but the ENIConfig of the node can't be found: %v", err)
+		return check
+	}
+	missing, err := c.awsClient.GetMissingSecurityGroups(eniCfg.SecurityGroups)
+	switch {
+	case err != nil:
+		check.Status = readinessWarn
+		check.Message = fmt.Sprintf("unable to check the ENIConfig security groups: %v", err)
+	case len(missing) > 0:
+		check.Status = readinessFail
+		check.Message = fmt.Sprintf("the ENIConfig security groups %s don't exist", strings.Join(missing, ", "))
+	}
+	return check
+}
+
+func checkEnvVars() ReadinessCheck {
+	check := ReadinessCheck{Name: "Configuration", Status: readinessPass}
+	var invalid []string
+	for _, envName := range intEnvVars {
+		if value, found := lookupConfig(envName); found {
+			if n, err := strconv.Atoi(value); err != nil || n < 0 {
+				invalid = append(invalid, fmt.Sprintf("%s=%q is not a non-negative integer", envName, value))
+			}
+		}
+	}
+	for _, envName := range boolEnvVars {
+		if value := os.Getenv(envName); value != "" {
+			if _, err := strconv.ParseBool(value); err != nil {
+				invalid = append(invalid, fmt.Sprintf("%s=%q is not a boolean", envName, value))
+			}
+		}
+	}
+	if len(invalid) > 0 {
+		check.Status = readinessFail
+		check.Message = strings.Join(invalid, ", ") + ", the default is used instead"
+	}
+	return check
+}
+
+func (c *IPAMContext) checkKernelSettings() ReadinessCheck {
+	check := ReadinessCheck{Name: "KernelSettings", Status: readinessPass}
+	problems := c.networkClient.CheckKernelSettings(c.awsClient.GetPrimaryENImac(), c.enableIPv4, c.enableIPv6)
+	if len(problems) > 0 {
+		check.Status = readinessFail
+		check.Message = strings.Join(problems, ", ")
+	}
+	return check
+}
+
+func (c *IPAMContext) setReadinessReport(report *ReadinessReport) {
+	c.readinessLock.Lock()
+	defer c.readinessLock.Unlock()
+	c.readinessReport = report
+}
+
+func (c *IPAMContext) getReadinessReport() *ReadinessReport {
+	c.readinessLock.RLock()
+	defer c.readinessLock.RUnlock()
+	return c.readinessReport
+}
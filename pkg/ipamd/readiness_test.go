@@ -0,0 +1,169 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/apis/crd/v1alpha1"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils"
+)
+
+func TestValidateStartup(t *testing.T) {
+	tests := []struct {
+		name           string
+		denied         map[string]error
+		availableIPs   int
+		subnetErr      error
+		kernelProblems []string
+		warmIPTarget   string
+		wantReady      bool
+		wantStatus     map[string]string
+	}{
+		{
+			name:         "all checks pass",
+			availableIPs: 100,
+			wantReady:    true,
+			wantStatus: map[string]string{"EC2Permissions": readinessPass, "SubnetHeadroom": readinessPass, "SubnetPlacement": readinessPass,
+				"Configuration": readinessPass, "KernelSettings": readinessPass},
+		},
+		{
+			name:         "missing permission",
+			denied:       map[string]error{"ec2:CreateNetworkInterface": errors.New("UnauthorizedOperation")},
+			availableIPs: 100,
+			wantReady:    false,
+			wantStatus:   map[string]string{"EC2Permissions": readinessFail},
+		},
+		{
+			name:         "subnet almost exhausted",
+			availableIPs: 5,
+			wantReady:    true,
+			wantStatus:   map[string]string{"SubnetHeadroom": readinessWarn},
+		},
+		{
+			name:         "subnet exhausted",
+			availableIPs: 0,
+			wantReady:    false,
+			wantStatus:   map[string]string{"SubnetHeadroom": readinessFail},
+		},
+		{
+			name:       "subnet check not permitted",
+			subnetErr:  errors.New("UnauthorizedOperation"),
+			wantReady:  true,
+			wantStatus: map[string]string{"SubnetHeadroom": readinessWarn},
+		},
+		{
+			name:           "ip forwarding disabled",
+			availableIPs:   100,
+			kernelProblems: []string{"net/ipv4/ip_forward is 0, expected 1"},
+			wantReady:      false,
+			wantStatus:     map[string]string{"KernelSettings": readinessFail},
+		},
+		{
+			name:         "invalid env var",
+			availableIPs: 100,
+			warmIPTarget: "five",
+			wantReady:    false,
+			wantStatus:   map[string]string{"Configuration": readinessFail},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := setup(t)
+			defer m.ctrl.Finish()
+
+			for _, envName := range append(intEnvVars, boolEnvVars...) {
+				_ = os.Unsetenv(envName)
+			}
+			if tt.warmIPTarget != "" {
+				_ = os.Setenv(envWarmIPTarget, tt.warmIPTarget)
+				defer os.Unsetenv(envWarmIPTarget)
+			}
+			m.awsutils.EXPECT().CheckEC2Permissions(true).Return(tt.denied)
+			m.awsutils.EXPECT().GetSubnetAvailableIPCount("").Return(tt.availableIPs, tt.subnetErr)
+			m.awsutils.EXPECT().GetInstancePlacement(gomock.Any()).Return(awsutils.Placement{AvailabilityZone: "us-west-2a"}, nil)
+			m.awsutils.EXPECT().GetPrimaryENImac().Return(primaryMAC)
+			m.network.EXPECT().CheckKernelSettings(primaryMAC, true, false).Return(tt.kernelProblems)
+
+			mockContext := &IPAMContext{
+				awsClient:     m.awsutils,
+				networkClient: m.network,
+				maxIPsPerENI:  14,
+				enableIPv4:    true,
+			}
+
+			report := mockContext.validateStartup(context.TODO())
+			assert.Equal(t, tt.wantReady, report.Ready)
+			status := make(map[string]string)
+			for _, check := range report.Checks {
+				status[check.Name] = check.Status
+			}
+			for name, want := range tt.wantStatus {
+				assert.Equal(t, want, status[name], name)
+			}
+		})
+	}
+}
+
+func TestCheckSubnetPlacement(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+	ctx := context.Background()
+	_ = os.Setenv(envNodeName, myNodeName)
+	defer os.Unsetenv(envNodeName)
+
+	assert.NoError(t, m.cachedK8SClient.Create(ctx, &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: myNodeName, Labels: map[string]string{"k8s.amazonaws.com/eniConfig": "lax"}},
+	}))
+	assert.NoError(t, m.cachedK8SClient.Create(ctx, &v1alpha1.ENIConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "lax"},
+		Spec:       v1alpha1.ENIConfigSpec{Subnet: "subnet-lax"},
+	}))
+	mockContext := &IPAMContext{
+		awsClient:           m.awsutils,
+		cachedK8SClient:     m.cachedK8SClient,
+		useCustomNetworking: true,
+	}
+	instance := awsutils.Placement{AvailabilityZone: "us-west-2a"}
+
+	m.awsutils.EXPECT().GetInstancePlacement(gomock.Any()).Return(instance, nil)
+	m.awsutils.EXPECT().CheckSubnetPlacement(gomock.Any(), "subnet-lax").Return(nil)
+	assert.Equal(t, readinessPass, mockContext.checkSubnetPlacement(ctx).Status)
+
+	// A Local Zone subnet can't hold the ENIs of an instance in a parent zone
+	m.awsutils.EXPECT().GetInstancePlacement(gomock.Any()).Return(instance, nil)
+	m.awsutils.EXPECT().CheckSubnetPlacement(gomock.Any(), "subnet-lax").Return(&awsutils.SubnetPlacementError{
+		SubnetID: "subnet-lax",
+		Subnet:   awsutils.Placement{AvailabilityZone: "us-west-2-lax-1a", LocalZone: true},
+		Instance: instance,
+	})
+	check := mockContext.checkSubnetPlacement(ctx)
+	assert.Equal(t, readinessFail, check.Status)
+	assert.Contains(t, check.Message, "Local Zone us-west-2-lax-1a")
+
+	m.awsutils.EXPECT().GetInstancePlacement(gomock.Any()).Return(instance, nil)
+	m.awsutils.EXPECT().CheckSubnetPlacement(gomock.Any(), "subnet-lax").Return(errors.New("UnauthorizedOperation"))
+	assert.Equal(t, readinessWarn, mockContext.checkSubnetPlacement(ctx).Status)
+
+	mockContext.disableENIProvisioning = true
+	assert.Equal(t, readinessPass, mockContext.checkSubnetPlacement(ctx).Status)
+}
@@ -0,0 +1,111 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"net"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/networkutils"
+)
+
+// podRoutabilityClassKey is the pod annotation requesting an IP address of a routability class, routable or
+// non-routable
+const podRoutabilityClassKey = "vpc.amazonaws.com/routability-class"
+
+// nonRoutableCIDRs returns the CIDRs of AWS_VPC_K8S_CNI_NON_ROUTABLE_CIDRS
+func nonRoutableCIDRs() []net.IPNet {
+	var cidrs []net.IPNet
+	for _, value := range networkutils.NonRoutableCIDRs() {
+		if _, cidr, err := net.ParseCIDR(value); err == nil && cidr.IP.To4() != nil {
+			cidrs = append(cidrs, *cidr)
+		}
+	}
+	return cidrs
+}
+
+// parseNamespaceRoutabilityClasses parses a comma or whitespace separated list of <namespace>=<class> entries.
+// Invalid entries are logged and skipped.
+func parseNamespaceRoutabilityClasses(value string) map[string]datastore.RoutabilityClass {
+	classes := make(map[string]datastore.RoutabilityClass)
+	entries := strings.FieldsFunc(value, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+	for _, entry := range entries {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			log.Errorf("Ignoring namespace routability class entry %q, expected <namespace>=<class>", entry)
+			continue
+		}
+		class, err := datastore.ParseRoutabilityClass(parts[1])
+		if err != nil {
+			log.Errorf("Ignoring namespace routability class entry %q: %v", entry, err)
+			continue
+		}
+		classes[parts[0]] = class
+	}
+	return classes
+}
+
+// routabilityClassesEnabled returns true if some CIDRs are non-routable, so that pods can request a class
+func (c *IPAMContext) routabilityClassesEnabled() bool {
+	return len(c.nonRoutableCIDRs) > 0
+}
+
+// podRoutabilityClass returns the class requested by the annotation of the pod, or else mapped to its namespace, or ""
+// if the pod can take an address of any class
+func (c *IPAMContext) podRoutabilityClass(pod *corev1.Pod) (datastore.RoutabilityClass, error) {
+	if value, ok := pod.Annotations[podRoutabilityClassKey]; ok {
+		class, err := datastore.ParseRoutabilityClass(strings.TrimSpace(value))
+		if err != nil {
+			return "", errors.Wrapf(err, "invalid %s annotation", podRoutabilityClassKey)
+		}
+		return class, nil
+	}
+	return c.namespaceClasses[pod.Namespace], nil
+}
+
+// withPodRoutabilityClass adds the class requested for the pod to pin, which may be nil
+func (c *IPAMContext) withPodRoutabilityClass(pin *datastore.AddressPin, pod *corev1.Pod) (*datastore.AddressPin, error) {
+	if !c.routabilityClassesEnabled() {
+		return pin, nil
+	}
+	class, err := c.podRoutabilityClass(pod)
+	if err != nil || class == "" {
+		return pin, err
+	}
+	if pin == nil {
+		pin = &datastore.AddressPin{}
+	}
+	pin.Class = class
+	return pin, nil
+}
+
+// isNonRoutableIP returns true if ip is in a non-routable CIDR, so that the traffic of its pod outside the VPC must be
+// SNATed to the node
+func (c *IPAMContext) isNonRoutableIP(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, cidr := range c.nonRoutableCIDRs {
+		if cidr.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+)
+
+func TestParseNamespaceRoutabilityClasses(t *testing.T) {
+	assert.Equal(t,
+		map[string]datastore.RoutabilityClass{"batch": datastore.ClassNonRoutable, "payments": datastore.ClassRoutable},
+		parseNamespaceRoutabilityClasses("batch=non-routable,\n payments=routable other=public =routable ops"))
+}
+
+func TestPodRoutabilityClassPin(t *testing.T) {
+	_, cgnat, _ := net.ParseCIDR("100.64.0.0/10")
+	c := &IPAMContext{
+		namespaceClasses: map[string]datastore.RoutabilityClass{"batch": datastore.ClassNonRoutable},
+	}
+	pod := func(namespace string, annotations map[string]string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: namespace, Annotations: annotations}}
+	}
+
+	// Without non-routable CIDRs, there are no classes to choose from
+	pin, err := c.withPodRoutabilityClass(nil, pod("batch", nil))
+	assert.NoError(t, err)
+	assert.Nil(t, pin)
+
+	c.nonRoutableCIDRs = []net.IPNet{*cgnat}
+	pin, err = c.withPodRoutabilityClass(nil, pod("batch", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, &datastore.AddressPin{Class: datastore.ClassNonRoutable}, pin)
+
+	// The annotation of the pod wins over its namespace, and adds to its other pins
+	_, cidr, _ := net.ParseCIDR("10.0.1.16/28")
+	pin, err = c.withPodRoutabilityClass(&datastore.AddressPin{CIDR: cidr},
+		pod("batch", map[string]string{podRoutabilityClassKey: "routable"}))
+	assert.NoError(t, err)
+	assert.Equal(t, &datastore.AddressPin{CIDR: cidr, Class: datastore.ClassRoutable}, pin)
+
+	pin, err = c.withPodRoutabilityClass(nil, pod("default", nil))
+	assert.NoError(t, err)
+	assert.Nil(t, pin)
+
+	_, err = c.withPodRoutabilityClass(nil, pod("default", map[string]string{podRoutabilityClassKey: "public"}))
+	assert.Error(t, err)
+
+	assert.True(t, c.isNonRoutableIP("100.64.3.4"))
+	assert.False(t, c.isNonRoutableIP("10.0.1.17"))
+	assert.False(t, c.isNonRoutableIP(""))
+}
@@ -0,0 +1,92 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"net"
+
+	"github.com/aws/amazon-vpc-cni-k8s/rpc"
+)
+
+const (
+	// podGatewayIPv4 is the link-local next hop of the IPv4 pods behind a host veth, answered by the host side of it
+	podGatewayIPv4 = "169.254.1.1"
+	// podGatewayIPv6 is the link-local next hop of the IPv6 pods behind a host veth
+	podGatewayIPv6 = "fe80::1"
+)
+
+// setENISubnet records the subnet of the ENI at a device number, for the route hints of the pods using its IPs
+func (c *IPAMContext) setENISubnet(deviceNumber int, subnetCIDR string) {
+	c.primaryIPLock.Lock()
+	defer c.primaryIPLock.Unlock()
+	if c.eniSubnets == nil {
+		c.eniSubnets = make(map[int]string)
+	}
+	c.eniSubnets[deviceNumber] = subnetCIDR
+}
+
+// eniSubnet returns the subnet of the ENI at a device number, or "" if it is unknown
+func (c *IPAMContext) eniSubnet(deviceNumber int) string {
+	c.primaryIPLock.Lock()
+	defer c.primaryIPLock.Unlock()
+	return c.eniSubnets[deviceNumber]
+}
+
+// setPodRouteHints fills the gateway, the routes and the ENI subnet of the pod in an AddNetwork reply, so that the
+// plugin and the runtimes programming a guest network, such as kata, don't derive them on their own. subnetCIDR is the
+// subnet of a branch ENI, the one of the other pods is looked up from their ENI.
+func (c *IPAMContext) setPodRouteHints(resp *rpc.AddNetworkReply, subnetCIDR string) {
+	if !resp.Success || (resp.IPv4Addr == "" && resp.IPv6Addr == "") {
+		return
+	}
+	resp.ENIMTU = int32(c.eniMTU)
+	switch {
+	case resp.DedicatedENI:
+		// The ENI is moved into the pod, which then reaches its subnet gateway directly
+		resp.PodGateway = resp.PodENISubnetGW
+		resp.PodRoutes = []*rpc.RouteHint{{Dst: "0.0.0.0/0", GW: resp.PodENISubnetGW}}
+		resp.ENISubnetGW = resp.PodENISubnetGW
+		if gw := net.ParseIP(resp.PodENISubnetGW); gw != nil && resp.PodENISubnetPrefixLength > 0 {
+			mask := net.CIDRMask(int(resp.PodENISubnetPrefixLength), 32)
+			resp.ENISubnetCIDR = (&net.IPNet{IP: gw.Mask(mask), Mask: mask}).String()
+		}
+		return
+	case resp.IPv4Addr != "":
+		resp.PodGateway = podGatewayIPv4
+		resp.PodRoutes = []*rpc.RouteHint{
+			{Dst: podGatewayIPv4 + "/32"},
+			{Dst: "0.0.0.0/0", GW: podGatewayIPv4},
+		}
+	default:
+		resp.PodGateway = podGatewayIPv6
+		resp.PodRoutes = []*rpc.RouteHint{{Dst: "::/0", GW: podGatewayIPv6}}
+		// Only the IPv4 subnets of the ENIs are known
+		return
+	}
+	if resp.PodVlanId != 0 {
+		resp.ENISubnetCIDR = subnetCIDR
+		resp.ENISubnetGW = resp.PodENISubnetGW
+		return
+	}
+	resp.ENISubnetCIDR = c.eniSubnet(int(resp.DeviceNumber))
+	if resp.ENISubnetCIDR == "" {
+		return
+	}
+	subnetGW, err := branchENISubnetGW(resp.ENISubnetCIDR)
+	if err != nil {
+		log.Warnf("Unable to get the gateway of the subnet %s: %v", resp.ENISubnetCIDR, err)
+		return
+	}
+	resp.ENISubnetGW = subnetGW
+}
@@ -0,0 +1,70 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/amazon-vpc-cni-k8s/rpc"
+)
+
+func TestSetPodRouteHints(t *testing.T) {
+	c := &IPAMContext{eniMTU: 9001}
+	c.setENISubnet(1, "10.0.32.0/19")
+
+	resp := &rpc.AddNetworkReply{Success: true, IPv4Addr: "10.0.40.7", DeviceNumber: 1}
+	c.setPodRouteHints(resp, "")
+	assert.Equal(t, "169.254.1.1", resp.PodGateway)
+	assert.Equal(t, []*rpc.RouteHint{{Dst: "169.254.1.1/32"}, {Dst: "0.0.0.0/0", GW: "169.254.1.1"}}, resp.PodRoutes)
+	assert.Equal(t, "10.0.32.0/19", resp.ENISubnetCIDR)
+	assert.Equal(t, "10.0.32.1", resp.ENISubnetGW)
+	assert.Equal(t, int32(9001), resp.ENIMTU)
+
+	// The subnet of an ENI which isn't set up yet is unknown
+	resp = &rpc.AddNetworkReply{Success: true, IPv4Addr: "10.0.64.7", DeviceNumber: 2}
+	c.setPodRouteHints(resp, "")
+	assert.Equal(t, "169.254.1.1", resp.PodGateway)
+	assert.Empty(t, resp.ENISubnetCIDR)
+	assert.Empty(t, resp.ENISubnetGW)
+
+	// A branch ENI pod goes through the host veth too, the subnet is the one of the branch ENI
+	resp = &rpc.AddNetworkReply{Success: true, IPv4Addr: "10.1.0.7", DeviceNumber: -1, PodVlanId: 3, PodENISubnetGW: "10.1.0.1"}
+	c.setPodRouteHints(resp, "10.1.0.0/24")
+	assert.Equal(t, "169.254.1.1", resp.PodGateway)
+	assert.Equal(t, "10.1.0.0/24", resp.ENISubnetCIDR)
+	assert.Equal(t, "10.1.0.1", resp.ENISubnetGW)
+
+	// A dedicated ENI pod reaches its subnet gateway directly
+	resp = &rpc.AddNetworkReply{Success: true, IPv4Addr: "10.2.0.7", DeviceNumber: -1, DedicatedENI: true, PodENISubnetGW: "10.2.0.1",
+		PodENISubnetPrefixLength: 20}
+	c.setPodRouteHints(resp, "")
+	assert.Equal(t, "10.2.0.1", resp.PodGateway)
+	assert.Equal(t, []*rpc.RouteHint{{Dst: "0.0.0.0/0", GW: "10.2.0.1"}}, resp.PodRoutes)
+	assert.Equal(t, "10.2.0.0/20", resp.ENISubnetCIDR)
+	assert.Equal(t, "10.2.0.1", resp.ENISubnetGW)
+
+	resp = &rpc.AddNetworkReply{Success: true, IPv6Addr: "2001:db8::7"}
+	c.setPodRouteHints(resp, "")
+	assert.Equal(t, "fe80::1", resp.PodGateway)
+	assert.Equal(t, []*rpc.RouteHint{{Dst: "::/0", GW: "fe80::1"}}, resp.PodRoutes)
+	assert.Empty(t, resp.ENISubnetCIDR)
+
+	// A failed assignment has no hints
+	resp = &rpc.AddNetworkReply{IPv4Addr: "10.0.40.7", DeviceNumber: 1}
+	c.setPodRouteHints(resp, "")
+	assert.Empty(t, resp.PodGateway)
+	assert.Zero(t, resp.ENIMTU)
+}
@@ -0,0 +1,36 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+)
+
+// podRouteAllocations is the datastore.AllocationRecovery of ipamd. The CNI plugin routes each pod IP to the host veth of
+// the pod, and these routes survive the loss of /var/run/aws-node and of the CRI socket.
+func (c *IPAMContext) podRouteAllocations(isv6Enabled bool) ([]datastore.RecoveredAddress, error) {
+	routes, err := c.networkClient.GetPodRoutes(isv6Enabled)
+	if err != nil {
+		return nil, err
+	}
+	addresses := make([]datastore.RecoveredAddress, 0, len(routes))
+	for _, route := range routes {
+		addresses = append(addresses, datastore.RecoveredAddress{
+			IP:       route.IP.String(),
+			HostVeth: route.HostVeth,
+		})
+	}
+	log.Infof("Found %d pod routes to recover the ipam state from", len(addresses))
+	return addresses, nil
+}
@@ -0,0 +1,67 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/networkutils"
+	pb "github.com/aws/amazon-vpc-cni-k8s/rpc"
+)
+
+func TestRouteRecovery(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	const hostVeth = "enicc21c2d7785"
+	m.network.EXPECT().GetPodRoutes(false).Return([]networkutils.PodRoute{
+		{IP: net.ParseIP("10.0.0.5"), HostVeth: hostVeth},
+	}, nil)
+	m.network.EXPECT().GetHostVethName("default", "sample-pod").Return(hostVeth)
+
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	ds.CheckpointMigrationPhase = 2
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP("10.0.0.5"), Mask: net.CIDRMask(32, 32)}, false))
+
+	mockContext := &IPAMContext{
+		awsClient:           m.awsutils,
+		networkClient:       m.network,
+		dataStore:           ds,
+		enableIPv4:          true,
+		enableRouteRecovery: true,
+	}
+	ds.SetAllocationRecovery(mockContext.podRouteAllocations)
+	assert.NoError(t, ds.ReadBackingStore(false))
+	assert.Equal(t, 1, ds.GetIPStats("4").AssignedIPs)
+
+	s := &server{version: "1.2.3", ipamContext: mockContext}
+	resp, err := s.DelNetwork(context.Background(), &pb.DelNetworkRequest{
+		ClientVersion:     "1.2.3",
+		K8S_POD_NAME:      "sample-pod",
+		K8S_POD_NAMESPACE: "default",
+		ContainerID:       "cid",
+		IfName:            "eth0",
+		NetworkName:       "aws-cni",
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.Success)
+	assert.Equal(t, "10.0.0.5", resp.IPv4Addr)
+	assert.Equal(t, 0, ds.GetIPStats("4").AssignedIPs)
+}
@@ -0,0 +1,35 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils"
+)
+
+// checkRouteTableConflicts reports the routes other agents of the node, e.g. VPN agents, added to the route table of a
+// secondary ENI before its network is set up. Setting up the ENI replaces its default route, so the conflict is only
+// reported, and AWS_VPC_K8S_CNI_ROUTE_TABLE_BASE moves the ENI route tables out of the way.
+func (c *IPAMContext) checkRouteTableConflicts(eni string, eniMetadata awsutils.ENIMetadata) {
+	conflicts, err := c.networkClient.FindRouteTableConflicts(eniMetadata.MAC, eniMetadata.DeviceNumber)
+	if err != nil {
+		log.Warnf("Failed to check the route table of ENI %s for conflicts: %v", eni, err)
+		ipamdErrInc("checkRouteTableConflicts")
+		return
+	}
+	for _, conflict := range conflicts {
+		log.Errorf("The route table of ENI %s with device index %d is used by another agent: %s", eni,
+			eniMetadata.DeviceNumber, conflict)
+	}
+	routeTableConflicts.Add(float64(len(conflicts)))
+}
@@ -0,0 +1,46 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils"
+)
+
+func TestCheckRouteTableConflicts(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	mockContext := &IPAMContext{networkClient: m.network}
+	eniMetadata := awsutils.ENIMetadata{ENIID: secENIid, MAC: secMAC, DeviceNumber: secDevice}
+	before := testutil.ToFloat64(routeTableConflicts)
+
+	m.network.EXPECT().FindRouteTableConflicts(secMAC, secDevice).Return(nil, nil)
+	mockContext.checkRouteTableConflicts(secENIid, eniMetadata)
+	assert.Equal(t, before, testutil.ToFloat64(routeTableConflicts))
+
+	m.network.EXPECT().FindRouteTableConflicts(secMAC, secDevice).Return(
+		[]string{"table 2 has route {Ifindex: 7 Dst: 172.16.0.0/12 Src: <nil> Gw: <nil> Flags: [] Table: 2}"}, nil)
+	mockContext.checkRouteTableConflicts(secENIid, eniMetadata)
+	assert.Equal(t, before+1, testutil.ToFloat64(routeTableConflicts))
+
+	m.network.EXPECT().FindRouteTableConflicts(secMAC, secDevice).Return(nil, errors.New("netlink error"))
+	mockContext.checkRouteTableConflicts(secENIid, eniMetadata)
+	assert.Equal(t, before+1, testutil.ToFloat64(routeTableConflicts))
+}
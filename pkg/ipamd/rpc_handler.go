@@ -15,24 +15,30 @@ package ipamd
 
 import (
 	"encoding/json"
+	"fmt"
 	"net"
 	"os"
 	"os/signal"
+	"runtime"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/health"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
 
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/maxpods"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/networkutils"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/audit"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/paths"
 	"github.com/aws/amazon-vpc-cni-k8s/rpc"
 	k8serror "k8s.io/apimachinery/pkg/api/errors"
 )
@@ -42,6 +48,11 @@ const (
 	grpcHealthServiceName = "grpc.health.v1.aws-node"
 
 	vpccniPodIPKey = "vpc.amazonaws.com/pod-ips"
+
+	// podSecondaryInterfacesKey is the pod annotation used to opt in to having every branch ENI beyond the first one
+	// listed in the vpc.amazonaws.com/pod-eni annotation attached as an additional interface (eth1, eth2, ...)
+	// inside the pod.
+	podSecondaryInterfacesKey = "vpc.amazonaws.com/pod-secondary-interfaces"
 )
 
 // server controls RPC service responses.
@@ -61,10 +72,14 @@ type PodENIData struct {
 
 // AddNetwork processes CNI add network request and return an IP address for container
 func (s *server) AddNetwork(ctx context.Context, in *rpc.AddNetworkRequest) (*rpc.AddNetworkReply, error) {
-	log.Infof("Received AddNetwork for NS %s, Sandbox %s, ifname %s",
-		in.Netns, in.ContainerID, in.IfName)
+	start := time.Now()
+	defer observeAddNetworkLatency("total", start)
+	log.Infof("Received AddNetwork for NS %s, Sandbox %s, ifname %s, trace ID %s",
+		in.Netns, in.ContainerID, in.IfName, in.TraceID)
 	log.Debugf("AddNetworkRequest: %s", in)
 	addIPCnt.Inc()
+	// The audit log ties the EC2 calls of the request to the datastore changes of the sandbox
+	ctx = audit.WithCorrelationID(ctx, in.ContainerID)
 
 	// Do this early, but after logging trace
 	if err := s.validateVersion(in.ClientVersion); err != nil {
@@ -74,18 +89,22 @@ func (s *server) AddNetwork(ctx context.Context, in *rpc.AddNetworkRequest) (*rp
 
 	failureResponse := rpc.AddNetworkReply{Success: false}
 	var deviceNumber, vlanID, trunkENILinkIndex int
-	var ipv4Addr, ipv6Addr, branchENIMAC, podENISubnetGW string
+	var ipv4Addr, ipv6Addr, branchENIMAC, podENISubnetGW, podENISubnetCIDR string
+	var secondaryInterfaces []*rpc.PodSecondaryInterface
+	var warmVeth string
 	var err error
 	if !s.ipamContext.enableIPv6 && s.ipamContext.enablePodENI {
 		// Check pod spec for Branch ENI
+		getPodStart := time.Now()
 		pod, err := s.ipamContext.GetPod(in.K8S_POD_NAME, in.K8S_POD_NAMESPACE)
+		observeAddNetworkLatency("get_pod", getPodStart)
 		if err != nil {
 			log.Warnf("Send AddNetworkReply: Failed to get pod: %v", err)
 			return &failureResponse, nil
 		}
 		limits := pod.Spec.Containers[0].Resources.Limits
 		for resName := range limits {
-			if strings.HasPrefix(string(resName), "vpc.amazonaws.com/pod-eni") {
+			if strings.HasPrefix(string(resName), podENIResourceName) {
 				// Check that we have a trunk
 				trunkENI := s.ipamContext.dataStore.GetTrunkENI()
 				if trunkENI == "" {
@@ -97,7 +116,7 @@ func (s *server) AddNetwork(ctx context.Context, in *rpc.AddNetworkRequest) (*rp
 					log.Warn("Send AddNetworkReply: No trunk ENI Link Index found, cannot add a pod ENI")
 					return &failureResponse, nil
 				}
-				val, branch := pod.Annotations["vpc.amazonaws.com/pod-eni"]
+				val, branch := pod.Annotations[podENIResourceName]
 				if branch {
 					// Parse JSON data
 					var podENIData []PodENIData
@@ -116,16 +135,33 @@ func (s *server) AddNetwork(ctx context.Context, in *rpc.AddNetworkRequest) (*rp
 						log.Errorf("Failed to parse pod-ENI annotation: %s", val)
 						return &failureResponse, nil
 					}
-					currentGW := strings.Split(firstENI.SubnetCIDR, "/")[0]
-					// Increment value CIDR value
-					nextGWIP, err := networkutils.IncrementIPv4Addr(net.ParseIP(currentGW))
+					podENISubnetCIDR = firstENI.SubnetCIDR
+					podENISubnetGW, err = branchENISubnetGW(podENISubnetCIDR)
 					if err != nil {
-						log.Errorf("Unable to get next Gateway IP for branch ENI from %s: %v", currentGW, err)
+						log.Errorf("Unable to get next Gateway IP for branch ENI: %v", err)
 						return &failureResponse, nil
 					}
-					podENISubnetGW = nextGWIP.String()
 					deviceNumber = -1 // Not needed for branch ENI, they depend on trunkENIDeviceIndex
+
+					if pod.Annotations[podSecondaryInterfacesKey] == "true" {
+						secondaryInterfaces, err = buildPodSecondaryInterfaces(podENIData[1:])
+						if err != nil {
+							log.Errorf("Failed to build secondary interfaces from pod-ENI annotation %s: %v", val, err)
+							return &failureResponse, nil
+						}
+					}
+					s.ipamContext.dataStore.SetPodBranchENIs(datastore.IPAMMetadata{
+						K8SPodNamespace: in.K8S_POD_NAMESPACE,
+						K8SPodName:      in.K8S_POD_NAME,
+					}, len(podENIData))
 				} else {
+					// Without a free slot on the trunk the branch ENI is never coming, so fail fast
+					if stats, err := s.ipamContext.dataStore.CheckTrunkCapacity(); errors.Is(err, datastore.ErrTrunkFull) {
+						s.ipamContext.reportTrunkFull(in.K8S_POD_NAME, in.K8S_POD_NAMESPACE, stats)
+						return nil, status.Errorf(codes.ResourceExhausted,
+							"trunk ENI %s has no free branch ENI slot (%d of %d in use), cannot add a pod ENI",
+							trunkENI, stats.Used, stats.Capacity)
+					}
 					log.Infof("Send AddNetworkReply: failed to get Branch ENI resource")
 					return &failureResponse, nil
 				}
@@ -133,6 +169,48 @@ func (s *server) AddNetwork(ctx context.Context, in *rpc.AddNetworkRequest) (*rp
 		}
 	}
 
+	var dedicated *dedicatedENI
+	if s.ipamContext.enableDedicatedENI && s.ipamContext.enableIPv4 && ipv4Addr == "" {
+		dedicated, err = s.ipamContext.podDedicatedENI(ctx, in.K8S_POD_NAME, in.K8S_POD_NAMESPACE)
+		if err != nil {
+			log.Errorf("Send AddNetworkReply: Failed to get a dedicated ENI for the pod: %v", err)
+			return &failureResponse, nil
+		}
+		if dedicated != nil {
+			ipv4Addr = dedicated.IPv4Addr
+			branchENIMAC = dedicated.MAC
+			podENISubnetGW = dedicated.SubnetGW
+			deviceNumber = -1 // The ENI is moved into the pod, there are no host routes
+		}
+	}
+
+	var multicast bool
+	if s.ipamContext.enablePodMulticast && vlanID == 0 && dedicated == nil {
+		multicast, err = s.ipamContext.getPodMulticast(in.K8S_POD_NAME, in.K8S_POD_NAMESPACE)
+		if err != nil {
+			log.Warnf("Send AddNetworkReply: Failed to get the multicast setting of the pod: %v", err)
+			return &failureResponse, nil
+		}
+	}
+
+	var egressRoutes []*rpc.EgressRoute
+	if s.ipamContext.enablePodEgressRoutes && s.ipamContext.enableIPv4 && vlanID == 0 && dedicated == nil {
+		egressRoutes, err = s.ipamContext.getPodEgressRoutes(in.K8S_POD_NAME, in.K8S_POD_NAMESPACE)
+		if err != nil {
+			log.Warnf("Send AddNetworkReply: Failed to get the egress routes of the pod: %v", err)
+			return &failureResponse, nil
+		}
+	}
+
+	var podMTU int
+	if s.ipamContext.enablePodMTUOverride {
+		podMTU, err = s.ipamContext.getPodMTU(ctx, in.K8S_POD_NAME, in.K8S_POD_NAMESPACE)
+		if err != nil {
+			log.Warnf("Send AddNetworkReply: Failed to get the MTU of the pod: %v", err)
+			return &failureResponse, nil
+		}
+	}
+
 	if s.ipamContext.enableIPv4 && ipv4Addr == "" ||
 		s.ipamContext.enableIPv6 && ipv6Addr == "" {
 		if in.ContainerID == "" || in.IfName == "" || in.NetworkName == "" {
@@ -147,8 +225,80 @@ func (s *server) AddNetwork(ctx context.Context, in *rpc.AddNetworkRequest) (*rp
 		ipamMetadata := datastore.IPAMMetadata{
 			K8SPodNamespace: in.K8S_POD_NAMESPACE,
 			K8SPodName:      in.K8S_POD_NAME,
+			K8SPodUID:       in.K8S_POD_UID,
+		}
+		if in.RequestID != "" {
+			// A retry assigns the same addresses from the datastore, only a conflict needs handling
+			if _, _, err := s.ipamContext.dataStore.CheckRequest(in.RequestID, datastore.RequestAdd, ipamKey); err != nil {
+				log.Errorf("Send AddNetworkReply: request %s: %v", in.RequestID, err)
+				return nil, status.Errorf(codes.Aborted, "request %s: %v", in.RequestID, err)
+			}
+		}
+		if other := s.ipamContext.hostVethCollision(ipamMetadata); other != nil {
+			hostVethCollisions.Inc()
+			log.Errorf("Send AddNetworkReply: the host veth of pod %s/%s would be the same as the one of pod %s/%s",
+				in.K8S_POD_NAMESPACE, in.K8S_POD_NAME, other.K8SPodNamespace, other.K8SPodName)
+			return nil, status.Errorf(codes.AlreadyExists, "the host veth of pod %s/%s would be the same as the one of pod %s/%s",
+				in.K8S_POD_NAMESPACE, in.K8S_POD_NAME, other.K8SPodNamespace, other.K8SPodName)
+		}
+		// A pod of an imported pool state takes the IP reserved for it, which the assign below then returns
+		s.ipamContext.claimImportedIP(ipamKey, ipamMetadata)
+		var pin *datastore.AddressPin
+		if (s.ipamContext.enablePodIPPinning || s.ipamContext.routabilityClassesEnabled()) && s.ipamContext.enableIPv4 {
+			pin, err = s.ipamContext.getPodAddressPin(in.K8S_POD_NAME, in.K8S_POD_NAMESPACE)
+			if err != nil {
+				log.Warnf("Send AddNetworkReply: Failed to get the address pin of the pod: %v", err)
+				return &failureResponse, nil
+			}
+		}
+		// Without a pin, the pod takes a pre-plumbed veth pair if one is left, whose IP the assign below then returns
+		if pin == nil {
+			warmVeth = s.ipamContext.claimWarmVeth(ipamKey, ipamMetadata)
+		}
+		// The addresses are recorded for the retries of the request along with their assignment
+		assign := func() error {
+			var addresses datastore.PodAddresses
+			addresses, err = s.ipamContext.dataStore.AssignPodIPAddressForRequest(in.RequestID, ipamKey, ipamMetadata,
+				datastore.AddressFamilies(s.ipamContext.enableIPv4, s.ipamContext.enableIPv6), pin)
+			ipv4Addr, ipv6Addr, deviceNumber = addresses.IPv4, addresses.IPv6, addresses.DeviceNumber
+			return err
+		}
+		assignStart := time.Now()
+		err = assign()
+		observeAddNetworkLatency("datastore_assign", assignStart)
+		if errors.Is(err, datastore.ErrNoAvailableIPs) {
+			s.ipamContext.reportIPExhaustion(ipExhaustionReasonDatastore,
+				fmt.Sprintf("No free IP address for pod %s/%s: %v", in.K8S_POD_NAMESPACE, in.K8S_POD_NAME, err))
+			// At the ENI limit, only released IPs can serve the pod, so wait for one rather than fail right away
+			if pin == nil && s.ipamContext.allocationQueue != nil && s.ipamContext.atENILimit() {
+				err = s.ipamContext.waitForCapacity(ctx, in.K8S_POD_NAME, in.K8S_POD_NAMESPACE, assign)
+				if _, isStatus := status.FromError(err); isStatus && err != nil {
+					log.Warnf("Send AddNetworkReply: %v", err)
+					return nil, err
+				}
+			}
+		}
+		// A pre-plumbed veth pair is already routed to its IP, so it is not probed
+		if err == nil && s.ipamContext.duplicateAddressTimeout > 0 && warmVeth == "" {
+			dadStart := time.Now()
+			err = s.ipamContext.detectDuplicateAddress(ipamKey, func() string {
+				if s.ipamContext.enableIPv4 {
+					return ipv4Addr
+				}
+				return ipv6Addr
+			}, assign)
+			observeAddNetworkLatency("duplicate_address_detection", dadStart)
+		}
+		// Branch and dedicated ENI pods do not take this path, their traffic does not go through a host veth
+		if err == nil {
+			err = s.ipamContext.blockPodIMDSAccess(in.K8S_POD_NAME, in.K8S_POD_NAMESPACE)
+		}
+		if err == nil {
+			err = s.ipamContext.restrictPodIngress(in.K8S_POD_NAME, in.K8S_POD_NAMESPACE)
+		}
+		if err == nil {
+			err = s.ipamContext.limitPodConnections(in.K8S_POD_NAME, in.K8S_POD_NAMESPACE)
 		}
-		ipv4Addr, ipv6Addr, deviceNumber, err = s.ipamContext.dataStore.AssignPodIPAddress(ipamKey, ipamMetadata, s.ipamContext.enableIPv4, s.ipamContext.enableIPv6)
 	}
 
 	var pbVPCV4cidrs, pbVPCV6cidrs []string
@@ -161,8 +311,10 @@ func (s *server) AddNetwork(ctx context.Context, in *rpc.AddNetworkRequest) (*rp
 		for _, cidr := range pbVPCV4cidrs {
 			log.Debugf("VPC CIDR %s", cidr)
 		}
-		useExternalSNAT = s.ipamContext.networkClient.UseExternalSNAT()
-		if !useExternalSNAT {
+		// The traffic of a pod with a non-routable IP always leaves the VPC through the node, which SNATs it
+		nonRoutable := s.ipamContext.isNonRoutableIP(ipv4Addr)
+		useExternalSNAT = s.ipamContext.networkClient.UseExternalSNAT() && !nonRoutable
+		if !useExternalSNAT && !nonRoutable {
 			for _, cidr := range s.ipamContext.networkClient.GetExcludeSNATCIDRs() {
 				log.Debugf("CIDR SNAT Exclusion %s", cidr)
 				pbVPCV4cidrs = append(pbVPCV4cidrs, cidr)
@@ -179,7 +331,9 @@ func (s *server) AddNetwork(ctx context.Context, in *rpc.AddNetworkRequest) (*rp
 	}
 
 	if s.ipamContext.enablePodIPAnnotation {
+		annotateStart := time.Now()
 		s.ipamContext.AnnotatePod(in.K8S_POD_NAME, in.K8S_POD_NAMESPACE, vpccniPodIPKey, ipv4Addr)
+		observeAddNetworkLatency("annotate_pod", annotateStart)
 	}
 	resp := rpc.AddNetworkReply{
 		Success:         err == nil,
@@ -193,22 +347,121 @@ func (s *server) AddNetwork(ctx context.Context, in *rpc.AddNetworkRequest) (*rp
 		PodENIMAC:       branchENIMAC,
 		PodENISubnetGW:  podENISubnetGW,
 		ParentIfIndex:   int32(trunkENILinkIndex),
+		Multicast:       multicast,
+		PodMTU:          int32(podMTU),
+		EgressRoutes:    egressRoutes,
+		WarmVeth:        warmVeth,
+
+		SecondaryInterfaces: secondaryInterfaces,
+	}
+	if dedicated != nil {
+		resp.DedicatedENI = true
+		resp.PodENISubnetPrefixLength = int32(dedicated.SubnetPrefixLength)
+	}
+	s.ipamContext.setDNSResult(&resp)
+	s.ipamContext.setPodRouteHints(&resp, podENISubnetCIDR)
+
+	if err == nil && ipv4Addr != "" {
+		s.ipamContext.syncPodSNATIPs(in.K8S_POD_NAMESPACE)
+	}
+	if err == nil && (ipv4Addr != "" || ipv6Addr != "") {
+		s.ipamContext.publishPodIPAssigned(in.K8S_POD_NAME, in.K8S_POD_NAMESPACE, in.ContainerID, ipv4Addr, ipv6Addr)
 	}
 
 	log.Infof("Send AddNetworkReply: IPv4Addr %s, IPv6Addr: %s, DeviceNumber: %d, err: %v", ipv4Addr, ipv6Addr, deviceNumber, err)
 	return &resp, nil
 }
 
+// branchENISubnetGW returns the gateway of a branch ENI subnet, which is the first address after the network address
+func branchENISubnetGW(subnetCIDR string) (string, error) {
+	currentGW := strings.Split(subnetCIDR, "/")[0]
+	// Increment value CIDR value
+	nextGWIP, err := networkutils.IncrementIPv4Addr(net.ParseIP(currentGW))
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to get next gateway IP from %s", currentGW)
+	}
+	return nextGWIP.String(), nil
+}
+
+// buildPodSecondaryInterfaces maps the additional branch ENIs of a pod to the interfaces eth1, eth2, ... inside the pod
+func buildPodSecondaryInterfaces(podENIData []PodENIData) ([]*rpc.PodSecondaryInterface, error) {
+	var secondaryInterfaces []*rpc.PodSecondaryInterface
+	for i, eni := range podENIData {
+		if eni.PrivateIP == "" || eni.IfAddress == "" || eni.VlanID == 0 {
+			return secondaryInterfaces, errors.Errorf("incomplete branch ENI data for %s", eni.ENIID)
+		}
+		subnetGW, err := branchENISubnetGW(eni.SubnetCIDR)
+		if err != nil {
+			return secondaryInterfaces, err
+		}
+		secondaryInterfaces = append(secondaryInterfaces, &rpc.PodSecondaryInterface{
+			IfName:   fmt.Sprintf("eth%d", i+1),
+			IPv4Addr: eni.PrivateIP,
+			VlanId:   int32(eni.VlanID),
+			ENIMAC:   eni.IfAddress,
+			SubnetGW: subnetGW,
+		})
+	}
+	return secondaryInterfaces, nil
+}
+
+// observeAddNetworkLatency records the time spent in an AddNetwork stage since start
+func observeAddNetworkLatency(stage string, start time.Time) {
+	addNetworkLatency.WithLabelValues(stage).Observe(time.Since(start).Seconds())
+}
+
 func (s *server) validateVersion(clientVersion string) error {
 	if s.version != clientVersion {
+		s.ipamContext.setCNIVersionHealth(fmt.Errorf("the CNI binary has version %q, ipamd has version %q", clientVersion, s.version))
 		return status.Errorf(codes.FailedPrecondition, "wrong client version %q (!= %q)", clientVersion, s.version)
 	}
+	s.ipamContext.setCNIVersionHealth(nil)
 	return nil
 }
 
+// GetMaxPods returns the kubelet --max-pods value for an instance type, or for this instance if none is given
+func (s *server) GetMaxPods(ctx context.Context, in *rpc.GetMaxPodsRequest) (*rpc.GetMaxPodsReply, error) {
+	log.Infof("Received GetMaxPods for instance type %q", in.InstanceType)
+	c := s.ipamContext
+	instanceType := in.InstanceType
+	opts := maxpods.Options{
+		CPUs:             int(in.CPUs),
+		MaxENI:           int(in.MaxENI),
+		PrefixDelegation: in.PrefixDelegation,
+		CustomNetworking: in.CustomNetworking,
+		IPv6:             in.IPv6,
+	}
+	if instanceType == "" {
+		instanceType = c.awsClient.GetInstanceType()
+		opts = maxpods.Options{
+			CPUs:             runtime.NumCPU(),
+			MaxENI:           c.maxENI,
+			PrefixDelegation: c.enablePrefixDelegation,
+			CustomNetworking: c.useCustomNetworking,
+			IPv6:             c.enableIPv6,
+		}
+	}
+
+	lookup := func(instanceType string) (awsutils.InstanceTypeLimits, error) {
+		return c.awsClient.GetInstanceTypeLimits(awsutils.WithCaller(ctx, awsutils.CallerMaxPods), instanceType)
+	}
+	maxPods, limits, err := maxpods.ForInstanceType(instanceType, opts, lookup)
+	if err != nil {
+		log.Warnf("Failed to look up the limits of instance type %s: %v", instanceType, err)
+		return nil, status.Errorf(codes.NotFound, "unknown instance type %s", instanceType)
+	}
+	return &rpc.GetMaxPodsReply{
+		MaxPods:      int32(maxPods),
+		InstanceType: instanceType,
+		ENILimit:     int32(limits.ENILimit),
+		IPv4Limit:    int32(limits.IPv4Limit),
+	}, nil
+}
+
 func (s *server) DelNetwork(ctx context.Context, in *rpc.DelNetworkRequest) (*rpc.DelNetworkReply, error) {
-	log.Infof("Received DelNetwork for Sandbox %s", in.ContainerID)
+	log.Infof("Received DelNetwork for Sandbox %s, trace ID %s", in.ContainerID, in.TraceID)
 	log.Debugf("DelNetworkRequest: %s", in)
+	ctx = audit.WithCorrelationID(ctx, in.ContainerID)
 	delIPCnt.With(prometheus.Labels{"reason": in.Reason}).Inc()
 	var ipv4Addr, ipv6Addr, cidrStr string
 
@@ -223,7 +476,32 @@ func (s *server) DelNetwork(ctx context.Context, in *rpc.DelNetworkRequest) (*rp
 		IfName:      in.IfName,
 		NetworkName: in.NetworkName,
 	}
-	eni, ip, deviceNumber, err := s.ipamContext.dataStore.UnassignPodIPAddress(ipamKey)
+	if in.RequestID != "" {
+		// A retry gets the addresses released the first time, so that the CNI plugin can still tear down their routes
+		released, found, err := s.ipamContext.dataStore.CheckRequest(in.RequestID, datastore.RequestDel, ipamKey)
+		if err != nil {
+			log.Errorf("Send DelNetworkReply: request %s: %v", in.RequestID, err)
+			return nil, status.Errorf(codes.Aborted, "request %s: %v", in.RequestID, err)
+		}
+		if found {
+			log.Infof("Send DelNetworkReply: replayed request %s, IPv4Addr %s, IPv6Addr %s", in.RequestID, released.IPv4, released.IPv6)
+			return &rpc.DelNetworkReply{Success: true, IPv4Addr: released.IPv4, IPv6Addr: released.IPv6,
+				DeviceNumber: int32(released.DeviceNumber)}, nil
+		}
+	}
+	// The released addresses are recorded for the retries of the request along with their unassignment
+	eni, ip, deviceNumber, err := s.ipamContext.dataStore.UnassignPodIPAddressForRequest(in.RequestID, ipamKey, ipamKey)
+	if err == nil {
+		s.ipamContext.unblockPodIMDSAccess(in.K8S_POD_NAME, in.K8S_POD_NAMESPACE)
+		s.ipamContext.unrestrictPodIngress(in.K8S_POD_NAME, in.K8S_POD_NAMESPACE)
+		s.ipamContext.unlimitPodConnections(in.K8S_POD_NAME, in.K8S_POD_NAMESPACE)
+	}
+	if err == datastore.ErrUnknownPod && s.ipamContext.enableRouteRecovery {
+		// The IP may have been recovered from the pod routes, under the name of the host veth
+		hostVeth := s.ipamContext.networkClient.GetHostVethName(in.K8S_POD_NAMESPACE, in.K8S_POD_NAME)
+		eni, ip, deviceNumber, err = s.ipamContext.dataStore.UnassignPodIPAddressForRequest(in.RequestID, ipamKey,
+			datastore.RecoveredIPAMKey(hostVeth))
+	}
 	if s.ipamContext.enableIPv4 {
 		ipv4Addr = ip
 		cidr := net.IPNet{IP: net.ParseIP(ip), Mask: net.IPv4Mask(255, 255, 255, 255)}
@@ -240,14 +518,26 @@ func (s *server) DelNetwork(ctx context.Context, in *rpc.DelNetworkRequest) (*rp
 		// secondary IP. Hence now see if we need free up a prefix is no other pods are using it.
 		if s.ipamContext.enablePrefixDelegation && eni.AvailableIPv4Cidrs[cidrStr] != nil && eni.AvailableIPv4Cidrs[cidrStr].IsPrefix == false {
 			log.Debugf("IP belongs to secondary pool with PD enabled so free IP from EC2")
-			s.ipamContext.tryUnassignIPFromENI(eni.ID)
+			s.ipamContext.unassignReleasedCidrs(eni.ID, false)
 		} else if !s.ipamContext.enablePrefixDelegation && eni.AvailableIPv4Cidrs[cidrStr] == nil {
 			log.Debugf("IP belongs to prefix pool with PD disabled so try free prefix from EC2")
-			s.ipamContext.tryUnassignPrefixFromENI(eni.ID)
+			s.ipamContext.unassignReleasedCidrs(eni.ID, true)
+		}
+	}
+
+	if err == datastore.ErrUnknownPod {
+		if dedicated := s.ipamContext.releaseDedicatedENI(ctx, in.K8S_POD_NAME, in.K8S_POD_NAMESPACE); dedicated != nil {
+			log.Infof("Send DelNetworkReply: dedicated ENI %s with IP %s", dedicated.ENIID, dedicated.IPv4Addr)
+			return &rpc.DelNetworkReply{Success: true, IPv4Addr: dedicated.IPv4Addr, DedicatedENI: true, PodENIMAC: dedicated.MAC}, nil
 		}
 	}
 
 	if err == datastore.ErrUnknownPod && s.ipamContext.enablePodENI {
+		// The VPC Resource Controller releases the branch ENIs of a deleted pod
+		s.ipamContext.dataStore.SetPodBranchENIs(datastore.IPAMMetadata{
+			K8SPodNamespace: in.K8S_POD_NAMESPACE,
+			K8SPodName:      in.K8S_POD_NAME,
+		}, 0)
 		pod, err := s.ipamContext.GetPod(in.K8S_POD_NAME, in.K8S_POD_NAMESPACE)
 		if err != nil {
 			if k8serror.IsNotFound(err) {
@@ -257,7 +547,7 @@ func (s *server) DelNetwork(ctx context.Context, in *rpc.DelNetworkRequest) (*rp
 			log.Warnf("Send DelNetworkReply: Failed to get pod spec: %v", err)
 			return &rpc.DelNetworkReply{Success: false}, err
 		}
-		val, branch := pod.Annotations["vpc.amazonaws.com/pod-eni"]
+		val, branch := pod.Annotations[podENIResourceName]
 		if branch {
 			// Parse JSON data
 			var podENIData []PodENIData
@@ -265,22 +555,113 @@ func (s *server) DelNetwork(ctx context.Context, in *rpc.DelNetworkRequest) (*rp
 			if err != nil || len(podENIData) < 1 {
 				log.Errorf("Failed to unmarshal PodENIData JSON: %v", err)
 			}
+			var secondaryInterfaces []*rpc.PodSecondaryInterface
+			if err == nil && pod.Annotations[podSecondaryInterfacesKey] == "true" {
+				// Teardown only needs the VLAN ID and the IP, so a bad gateway is not fatal here
+				secondaryInterfaces, _ = buildPodSecondaryInterfaces(podENIData[1:])
+			}
 			return &rpc.DelNetworkReply{
-				Success:   true,
-				PodVlanId: int32(podENIData[0].VlanID),
-				IPv4Addr:  podENIData[0].PrivateIP}, err
+				Success:             true,
+				PodVlanId:           int32(podENIData[0].VlanID),
+				IPv4Addr:            podENIData[0].PrivateIP,
+				SecondaryInterfaces: secondaryInterfaces}, err
 		}
 	}
 
 	if s.ipamContext.enablePodIPAnnotation {
 		s.ipamContext.AnnotatePod(in.K8S_POD_NAME, in.K8S_POD_NAMESPACE, vpccniPodIPKey, "")
 	}
+	if err == nil {
+		if ipv4Addr != "" {
+			s.ipamContext.syncPodSNATIPs(in.K8S_POD_NAMESPACE)
+		}
+		s.ipamContext.allocationQueue.notify()
+		s.ipamContext.publishPodIPReleased(in.K8S_POD_NAME, in.K8S_POD_NAMESPACE, in.ContainerID, ipv4Addr, ipv6Addr)
+	}
 
 	log.Infof("Send DelNetworkReply: IPv4Addr %s, DeviceNumber: %d, err: %v", ip, deviceNumber, err)
 
 	return &rpc.DelNetworkReply{Success: err == nil, IPv4Addr: ipv4Addr, IPv6Addr: ipv6Addr, DeviceNumber: int32(deviceNumber)}, err
 }
 
+// GarbageCollect handles CNI GC: it releases the IPs of the sandboxes of a network that are not among the valid
+// attachments of the container runtime, and returns them so that the CNI plugin can tear down their routes
+func (s *server) GarbageCollect(ctx context.Context, in *rpc.GarbageCollectRequest) (*rpc.GarbageCollectReply, error) {
+	log.Infof("Received GarbageCollect for network %s with %d valid attachments, trace ID %s",
+		in.NetworkName, len(in.ValidAttachments), in.TraceID)
+
+	// Do this early, but after logging trace
+	if err := s.validateVersion(in.ClientVersion); err != nil {
+		log.Warnf("Rejecting GarbageCollect request: %v", err)
+		return nil, err
+	}
+	if in.NetworkName == "" {
+		return nil, status.Error(codes.InvalidArgument, "network name is required")
+	}
+
+	sandboxSet := make(map[datastore.IPAMKey]bool, len(in.ValidAttachments))
+	for _, attachment := range in.ValidAttachments {
+		sandboxSet[datastore.IPAMKey{
+			NetworkName: in.NetworkName,
+			ContainerID: attachment.ContainerID,
+			IfName:      attachment.IfName,
+		}] = true
+	}
+	released, err := s.ipamContext.dataStore.ReconcileAgainst(in.NetworkName, sandboxSet)
+	if err != nil {
+		log.Errorf("Send GarbageCollectReply: failed to release stale IPs: %v", err)
+		return &rpc.GarbageCollectReply{Success: false}, err
+	}
+
+	reply := &rpc.GarbageCollectReply{Success: true}
+	for _, info := range released {
+		releasedIP := &rpc.ReleasedIP{DeviceNumber: int32(info.DeviceNumber)}
+		if s.ipamContext.enableIPv6 {
+			releasedIP.IPv6Addr = info.IP
+		} else {
+			releasedIP.IPv4Addr = info.IP
+		}
+		reply.ReleasedIPs = append(reply.ReleasedIPs, releasedIP)
+		if releasedIP.IPv4Addr != "" {
+			s.ipamContext.syncPodSNATIPs(info.Metadata.K8SPodNamespace)
+		}
+		s.ipamContext.publishPodIPReleased(info.Metadata.K8SPodName, info.Metadata.K8SPodNamespace, info.IPAMKey.ContainerID,
+			releasedIP.IPv4Addr, releasedIP.IPv6Addr)
+	}
+	log.Infof("Send GarbageCollectReply: released %d IPs", len(reply.ReleasedIPs))
+	return reply, nil
+}
+
+// EnqueueTeardown queues the routes and rules of a deleted pod that the CNI plugin failed to tear down, for ipamd to
+// retry until they are gone
+func (s *server) EnqueueTeardown(ctx context.Context, in *rpc.EnqueueTeardownRequest) (*rpc.EnqueueTeardownReply, error) {
+	log.Infof("Received EnqueueTeardown for container %s, IPv4 %s, IPv6 %s, route table %d, trace ID %s: %s",
+		in.ContainerID, in.IPv4Addr, in.IPv6Addr, in.RouteTable, in.TraceID, in.Error)
+
+	// Do this early, but after logging trace
+	if err := s.validateVersion(in.ClientVersion); err != nil {
+		log.Warnf("Rejecting EnqueueTeardown request: %v", err)
+		return nil, err
+	}
+	ip, maskLen := in.IPv4Addr, 32
+	if in.IPv6Addr != "" {
+		ip, maskLen = in.IPv6Addr, 128
+	}
+	if net.ParseIP(ip) == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid pod IP %q", ip)
+	}
+
+	depth := s.ipamContext.teardownQueue.add(PendingTeardown{
+		ContainerID: in.ContainerID,
+		IP:          fmt.Sprintf("%s/%d", ip, maskLen),
+		RouteTable:  int(in.RouteTable),
+		EnqueuedAt:  time.Now(),
+		LastError:   in.Error,
+	})
+	log.Infof("Send EnqueueTeardownReply: %d pending teardowns", depth)
+	return &rpc.EnqueueTeardownReply{Success: true, QueueDepth: int32(depth)}, nil
+}
+
 // RunRPCHandler handles request from gRPC
 func (c *IPAMContext) RunRPCHandler(version string) error {
 	log.Infof("Serving RPC Handler version %s on %s", version, ipamdgRPCaddress)
@@ -289,18 +670,29 @@ func (c *IPAMContext) RunRPCHandler(version string) error {
 		log.Errorf("Failed to listen gRPC port: %v", err)
 		return errors.Wrap(err, "ipamd: failed to listen to gRPC port")
 	}
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(traceIDInterceptor))
 	rpc.RegisterCNIBackendServer(grpcServer, &server{version: version, ipamContext: c})
-	healthServer := health.NewServer()
-	// If ipamd can talk to the API server and to the EC2 API, the pod is healthy.
-	// No need to ever change this to HealthCheckResponse_NOT_SERVING since it's a local service only
-	healthServer.SetServingStatus(grpcHealthServiceName, healthpb.HealthCheckResponse_SERVING)
-	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	// The liveness and readiness of the pod are reported by the health probes
+	healthpb.RegisterHealthServer(grpcServer, &healthServer{ipamContext: c})
 
 	// Register reflection service on gRPC server.
 	reflection.Register(grpcServer)
 	// Add shutdown hook
 	go c.shutdownListener()
+	// The unix socket on the run dir host mount is dialed by the CNI binary instead of ipamdgRPCaddress when it exists,
+	// skipping the TCP handshake on every ADD/DEL
+	socket := paths.IPAMDSocket()
+	if socketListener, err := listenUnixSocket(socket); err != nil {
+		// The CNI binary falls back to the TCP address when the socket is missing
+		log.Warnf("Failed to listen on gRPC socket %s: %v", socket, err)
+	} else {
+		log.Infof("Serving RPC Handler on %s", socket)
+		go func() {
+			if err := grpcServer.Serve(socketListener); err != nil {
+				log.Errorf("Failed to start server on gRPC socket: %v", err)
+			}
+		}()
+	}
 	if err := grpcServer.Serve(listener); err != nil {
 		log.Errorf("Failed to start server on gRPC port: %v", err)
 		return errors.Wrap(err, "ipamd: failed to start server on gPRC port")
@@ -308,6 +700,24 @@ func (c *IPAMContext) RunRPCHandler(version string) error {
 	return nil
 }
 
+// traceIDInterceptor passes the trace ID that the CNI plugin sends in the request metadata on to the EC2 calls made
+// for the request
+func traceIDInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	if traceID := rpc.TraceIDFromIncomingContext(ctx); traceID != "" {
+		ctx = awsutils.WithTraceID(ctx, traceID)
+	}
+	return handler(ctx, req)
+}
+
+// listenUnixSocket listens on path, removing the socket left behind by a previous ipamd
+func listenUnixSocket(path string) (net.Listener, error) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return net.Listen("unix", path)
+}
+
 // shutdownListener - Listen to signals and set ipamd to be in status "terminating"
 func (c *IPAMContext) shutdownListener() {
 	log.Info("Setting up shutdown hook.")
@@ -15,14 +15,26 @@ package ipamd
 
 import (
 	"context"
+	"errors"
 	"net"
 	"testing"
+	"time"
 
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/eventrecorder"
 
 	pb "github.com/aws/amazon-vpc-cni-k8s/rpc"
 
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestServer_VersionCheck(t *testing.T) {
@@ -106,6 +118,8 @@ func TestServer_AddNetwork(t *testing.T) {
 		ipV4Enabled              bool
 		ipV6Enabled              bool
 		prefixDelegationEnabled  bool
+		podIPPinningEnabled      bool
+		podAnnotations           map[string]string
 	}
 	tests := []struct {
 		name    string
@@ -138,6 +152,8 @@ func TestServer_AddNetwork(t *testing.T) {
 				DeviceNumber:    int32(0),
 				UseExternalSNAT: true,
 				VPCv4CIDRs:      []string{"10.10.0.0/16"},
+				PodGateway:      "169.254.1.1",
+				PodRoutes:       []*pb.RouteHint{{Dst: "169.254.1.1/32"}, {Dst: "0.0.0.0/0", GW: "169.254.1.1"}},
 			},
 		},
 		{
@@ -170,6 +186,8 @@ func TestServer_AddNetwork(t *testing.T) {
 				DeviceNumber:    int32(0),
 				UseExternalSNAT: false,
 				VPCv4CIDRs:      []string{"10.10.0.0/16", "10.12.0.0/16", "10.13.0.0/16"},
+				PodGateway:      "169.254.1.1",
+				PodRoutes:       []*pb.RouteHint{{Dst: "169.254.1.1/32"}, {Dst: "0.0.0.0/0", GW: "169.254.1.1"}},
 			},
 		},
 		{
@@ -204,6 +222,8 @@ func TestServer_AddNetwork(t *testing.T) {
 				IPv6Addr:     "2001:db8::",
 				DeviceNumber: int32(0),
 				VPCv6CIDRs:   []string{"2001:db8::/56"},
+				PodGateway:   "fe80::1",
+				PodRoutes:    []*pb.RouteHint{{Dst: "::/0", GW: "fe80::1"}},
 			},
 		},
 		{
@@ -235,11 +255,58 @@ func TestServer_AddNetwork(t *testing.T) {
 				DeviceNumber: int32(-1),
 			},
 		},
+		{
+			name: "successfully allocated pinned IPv4Address",
+			fields: fields{
+				ipV4AddressByENIID: map[string][]string{
+					"eni-1": {"192.168.1.100"},
+					"eni-2": {"192.168.2.100"},
+				},
+				getVPCIPv4CIDRsCalls: []getVPCIPv4CIDRsCall{
+					{
+						cidrs: []string{"10.10.0.0/16"},
+					},
+				},
+				useExternalSNATCalls: []useExternalSNATCall{
+					{
+						useExternalSNAT: true,
+					},
+				},
+				ipV4Enabled:         true,
+				podIPPinningEnabled: true,
+				podAnnotations:      map[string]string{podPinnedCIDRKey: "192.168.2.0/24"},
+			},
+			want: &pb.AddNetworkReply{
+				Success:         true,
+				IPv4Addr:        "192.168.2.100",
+				DeviceNumber:    int32(0),
+				UseExternalSNAT: true,
+				VPCv4CIDRs:      []string{"10.10.0.0/16"},
+				PodGateway:      "169.254.1.1",
+				PodRoutes:       []*pb.RouteHint{{Dst: "169.254.1.1/32"}, {Dst: "0.0.0.0/0", GW: "169.254.1.1"}},
+			},
+		},
+		{
+			name: "failed allocating pinned IPv4Address",
+			fields: fields{
+				ipV4AddressByENIID: map[string][]string{
+					"eni-1": {"192.168.1.100"},
+				},
+				ipV4Enabled:         true,
+				podIPPinningEnabled: true,
+				podAnnotations:      map[string]string{podPinnedCIDRKey: "192.168.2.0/24"},
+			},
+			want: &pb.AddNetworkReply{
+				Success:      false,
+				DeviceNumber: int32(-1),
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			m := setup(t)
 			defer m.ctrl.Finish()
+			eventrecorder.InitMockEventRecorder(m.cachedK8SClient)
 
 			for _, call := range tt.fields.getVPCIPv4CIDRsCalls {
 				m.awsutils.EXPECT().GetVPCIPv4CIDRs().Return(call.cidrs, call.err)
@@ -280,6 +347,12 @@ func TestServer_AddNetwork(t *testing.T) {
 				enableIPv6:             tt.fields.ipV6Enabled,
 				enablePrefixDelegation: tt.fields.prefixDelegationEnabled,
 				dataStore:              ds,
+				rawK8SClient:           m.rawK8SClient,
+				enablePodIPPinning:     tt.fields.podIPPinningEnabled,
+			}
+			if tt.fields.podAnnotations != nil {
+				pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default", Annotations: tt.fields.podAnnotations}}
+				assert.NoError(t, m.rawK8SClient.Create(context.Background(), pod))
 			}
 
 			s := &server{
@@ -288,13 +361,17 @@ func TestServer_AddNetwork(t *testing.T) {
 			}
 
 			req := &pb.AddNetworkRequest{
-				ClientVersion: "1.2.3",
-				Netns:         "netns",
-				NetworkName:   "net0",
-				ContainerID:   "cid",
-				IfName:        "eni",
+				ClientVersion:     "1.2.3",
+				Netns:             "netns",
+				NetworkName:       "net0",
+				ContainerID:       "cid",
+				IfName:            "eni",
+				TraceID:           "trace",
+				K8S_POD_NAME:      "pod",
+				K8S_POD_NAMESPACE: "default",
 			}
 
+			addNetworkLatency.Reset()
 			resp, err := s.AddNetwork(context.Background(), req)
 			if tt.wantErr != nil {
 				assert.EqualError(t, err, tt.wantErr.Error())
@@ -302,6 +379,246 @@ func TestServer_AddNetwork(t *testing.T) {
 				assert.NoError(t, err)
 				assert.Equal(t, tt.want, resp)
 			}
+			// The total and datastore_assign stages are always recorded
+			assert.Equal(t, 2, testutil.CollectAndCount(addNetworkLatency))
+		})
+	}
+}
+
+func TestBuildPodSecondaryInterfaces(t *testing.T) {
+	podENIData := []PodENIData{
+		{ENIID: "eni-1", IfAddress: "0a:00:00:00:00:01", PrivateIP: "10.0.1.10", VlanID: 2, SubnetCIDR: "10.0.1.0/24"},
+		{ENIID: "eni-2", IfAddress: "0a:00:00:00:00:02", PrivateIP: "10.0.2.10", VlanID: 3, SubnetCIDR: "10.0.2.0/24"},
+	}
+	secondaryInterfaces, err := buildPodSecondaryInterfaces(podENIData)
+	assert.NoError(t, err)
+	assert.Equal(t, []*pb.PodSecondaryInterface{
+		{IfName: "eth1", IPv4Addr: "10.0.1.10", VlanId: 2, ENIMAC: "0a:00:00:00:00:01", SubnetGW: "10.0.1.1"},
+		{IfName: "eth2", IPv4Addr: "10.0.2.10", VlanId: 3, ENIMAC: "0a:00:00:00:00:02", SubnetGW: "10.0.2.1"},
+	}, secondaryInterfaces)
+
+	_, err = buildPodSecondaryInterfaces([]PodENIData{{ENIID: "eni-3", PrivateIP: "10.0.3.10"}})
+	assert.Error(t, err)
+}
+
+func TestServer_GetMaxPods(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	mockContext := &IPAMContext{
+		awsClient: m.awsutils,
+		maxENI:    2,
+	}
+	rpcServer := server{ipamContext: mockContext}
+
+	m5Large := awsutils.InstanceTypeLimits{ENILimit: 3, IPv4Limit: 10}
+
+	// This instance, with the ipamd configuration
+	m.awsutils.EXPECT().GetInstanceType().Return("m5.large")
+	m.awsutils.EXPECT().GetInstanceTypeLimits(gomock.Any(), "m5.large").Return(m5Large, nil)
+	resp, err := rpcServer.GetMaxPods(context.TODO(), &pb.GetMaxPodsRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(20), resp.MaxPods)
+	assert.Equal(t, "m5.large", resp.InstanceType)
+
+	m.awsutils.EXPECT().GetInstanceTypeLimits(gomock.Any(), "m5.large").Return(m5Large, nil).Times(2)
+	resp, err = rpcServer.GetMaxPods(context.TODO(), &pb.GetMaxPodsRequest{InstanceType: "m5.large", CustomNetworking: true})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(20), resp.MaxPods)
+	assert.Equal(t, int32(3), resp.ENILimit)
+
+	resp, err = rpcServer.GetMaxPods(context.TODO(), &pb.GetMaxPodsRequest{InstanceType: "m5.large", PrefixDelegation: true, CPUs: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(110), resp.MaxPods)
+
+	// An instance type missing from the vendored table, found by DescribeInstanceTypes
+	m.awsutils.EXPECT().GetInstanceTypeLimits(gomock.Any(), "x9.new").Return(awsutils.InstanceTypeLimits{ENILimit: 4, IPv4Limit: 15}, nil)
+	resp, err = rpcServer.GetMaxPods(context.TODO(), &pb.GetMaxPodsRequest{InstanceType: "x9.new"})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(58), resp.MaxPods)
+
+	m.awsutils.EXPECT().GetInstanceTypeLimits(gomock.Any(), "x9.unknown").Return(awsutils.InstanceTypeLimits{}, errors.New("unknown"))
+	_, err = rpcServer.GetMaxPods(context.TODO(), &pb.GetMaxPodsRequest{InstanceType: "x9.unknown"})
+	assert.Error(t, err)
+}
+
+func TestServer_AddNetworkNewSandbox(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)}, false))
+	}
+	mockContext := &IPAMContext{
+		awsClient:     m.awsutils,
+		networkClient: m.network,
+		dataStore:     ds,
+		enableIPv4:    true,
+	}
+	s := &server{version: "1.2.3", ipamContext: mockContext}
+
+	addNetwork := func(containerID, uid string) *pb.AddNetworkReply {
+		m.awsutils.EXPECT().GetVPCIPv4CIDRs().Return([]string{"10.0.0.0/16"}, nil)
+		m.network.EXPECT().UseExternalSNAT().Return(true)
+		resp, err := s.AddNetwork(context.Background(), &pb.AddNetworkRequest{
+			ClientVersion:     "1.2.3",
+			K8S_POD_NAME:      "pod-1",
+			K8S_POD_NAMESPACE: "default",
+			K8S_POD_UID:       uid,
+			ContainerID:       containerID,
+			IfName:            "eth0",
+			NetworkName:       "aws-cni",
 		})
+		assert.NoError(t, err)
+		assert.True(t, resp.Success)
+		return resp
 	}
+	first := addNetwork("cid-1", "uid-1")
+
+	// The container runtime recreated the sandbox without deleting the first one
+	second := addNetwork("cid-2", "uid-1")
+	assert.Equal(t, first.IPv4Addr, second.IPv4Addr)
+	assert.Equal(t, 1, ds.GetIPStats(ipV4AddrFamily).AssignedIPs)
+	allocated := ds.AllocatedIPs()
+	assert.Len(t, allocated, 1)
+	assert.Equal(t, "cid-2", allocated[0].IPAMKey.ContainerID)
+
+	// The pod was recreated with the same name, it is another pod
+	third := addNetwork("cid-3", "uid-2")
+	assert.NotEqual(t, first.IPv4Addr, third.IPv4Addr)
+	assert.Equal(t, 2, ds.GetIPStats(ipV4AddrFamily).AssignedIPs)
+}
+
+func TestServer_RetriedRequests(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP("10.0.0.1"), Mask: net.CIDRMask(32, 32)}, false))
+	mockContext := &IPAMContext{
+		awsClient:     m.awsutils,
+		networkClient: m.network,
+		dataStore:     ds,
+		enableIPv4:    true,
+	}
+	s := &server{version: "1.2.3", ipamContext: mockContext}
+
+	addRequest := &pb.AddNetworkRequest{
+		ClientVersion:     "1.2.3",
+		K8S_POD_NAME:      "pod-1",
+		K8S_POD_NAMESPACE: "default",
+		ContainerID:       "cid-1",
+		IfName:            "eth0",
+		NetworkName:       "aws-cni",
+		RequestID:         "add-1",
+	}
+	m.awsutils.EXPECT().GetVPCIPv4CIDRs().Return([]string{"10.0.0.0/16"}, nil).Times(2)
+	m.network.EXPECT().UseExternalSNAT().Return(true).Times(2)
+	added, err := s.AddNetwork(context.Background(), addRequest)
+	assert.NoError(t, err)
+	retried, err := s.AddNetwork(context.Background(), addRequest)
+	assert.NoError(t, err)
+	assert.Equal(t, added.IPv4Addr, retried.IPv4Addr)
+
+	// The request ID of another sandbox is rejected
+	_, err = s.AddNetwork(context.Background(), &pb.AddNetworkRequest{
+		ClientVersion:     "1.2.3",
+		K8S_POD_NAME:      "pod-2",
+		K8S_POD_NAMESPACE: "default",
+		ContainerID:       "cid-2",
+		IfName:            "eth0",
+		NetworkName:       "aws-cni",
+		RequestID:         "add-1",
+	})
+	assert.Equal(t, codes.Aborted, status.Code(err))
+
+	// A retried delete gets the released address again, for the CNI plugin to tear down its routes
+	delRequest := &pb.DelNetworkRequest{
+		ClientVersion:     "1.2.3",
+		K8S_POD_NAME:      "pod-1",
+		K8S_POD_NAMESPACE: "default",
+		ContainerID:       "cid-1",
+		IfName:            "eth0",
+		NetworkName:       "aws-cni",
+		RequestID:         "del-1",
+	}
+	deleted, err := s.DelNetwork(context.Background(), delRequest)
+	assert.NoError(t, err)
+	assert.Equal(t, added.IPv4Addr, deleted.IPv4Addr)
+	retriedDel, err := s.DelNetwork(context.Background(), delRequest)
+	assert.NoError(t, err)
+	assert.True(t, retriedDel.Success)
+	assert.Equal(t, deleted.IPv4Addr, retriedDel.IPv4Addr)
+	assert.Equal(t, deleted.DeviceNumber, retriedDel.DeviceNumber)
+
+	// After the delete, an add of the same sandbox is a new request, and so is the next delete
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP("10.0.0.2"), Mask: net.CIDRMask(32, 32)}, false))
+	m.awsutils.EXPECT().GetVPCIPv4CIDRs().Return([]string{"10.0.0.0/16"}, nil)
+	m.network.EXPECT().UseExternalSNAT().Return(true)
+	readded, err := s.AddNetwork(context.Background(), addRequest)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.2", readded.IPv4Addr)
+	deleted, err = s.DelNetwork(context.Background(), delRequest)
+	assert.NoError(t, err)
+	assert.Equal(t, readded.IPv4Addr, deleted.IPv4Addr)
+	assert.Equal(t, 0, ds.GetIPStats(ipV4AddrFamily).AssignedIPs)
+}
+
+func TestServer_GarbageCollect(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	assignedAt := time.Now().Add(-time.Hour).UnixNano()
+	checkpoint := datastore.NewTestCheckpoint(datastore.CheckpointData{
+		Version: datastore.CheckpointFormatVersion,
+		Allocations: []datastore.CheckpointEntry{
+			{IPAMKey: datastore.IPAMKey{NetworkName: "aws-cni", ContainerID: "running", IfName: "eth0"}, IPv4: "10.0.0.1", AllocationTimestamp: assignedAt},
+			{IPAMKey: datastore.IPAMKey{NetworkName: "aws-cni", ContainerID: "gone", IfName: "eth0"}, IPv4: "10.0.0.2", AllocationTimestamp: assignedAt},
+		},
+	})
+	ds := datastore.NewDataStore(log, checkpoint, false)
+	ds.CheckpointMigrationPhase = 2
+	assert.NoError(t, ds.AddENI("eni-1", 2, false, false, false))
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)}, false))
+	}
+	assert.NoError(t, ds.ReadBackingStore(false))
+
+	rpcServer := server{version: "1.2.3", ipamContext: &IPAMContext{dataStore: ds, enableIPv4: true}}
+
+	_, err := rpcServer.GarbageCollect(context.TODO(), &pb.GarbageCollectRequest{ClientVersion: "1.2.3"})
+	assert.Error(t, err)
+
+	resp, err := rpcServer.GarbageCollect(context.TODO(), &pb.GarbageCollectRequest{
+		ClientVersion:    "1.2.3",
+		NetworkName:      "aws-cni",
+		ValidAttachments: []*pb.GCAttachment{{ContainerID: "running", IfName: "eth0"}},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.Success)
+	assert.Len(t, resp.ReleasedIPs, 1)
+	assert.Equal(t, "10.0.0.2", resp.ReleasedIPs[0].IPv4Addr)
+	assert.Equal(t, int32(2), resp.ReleasedIPs[0].DeviceNumber)
+	assert.Equal(t, 1, ds.GetIPStats("4").AssignedIPs)
+}
+
+func TestTraceIDInterceptor(t *testing.T) {
+	var traceID string
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		traceID = awsutils.TraceIDFromContext(ctx)
+		return nil, nil
+	}
+
+	outgoing := pb.WithTraceID(context.Background(), "0123abcd")
+	md, _ := metadata.FromOutgoingContext(outgoing)
+	_, err := traceIDInterceptor(metadata.NewIncomingContext(context.Background(), md), nil, &grpc.UnaryServerInfo{}, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, "0123abcd", traceID)
+
+	_, err = traceIDInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{}, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, "", traceID)
 }
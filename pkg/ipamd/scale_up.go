@@ -0,0 +1,140 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/eniconfig"
+)
+
+const (
+	// eniAllocationCalls is the number of EC2 calls made to create, attach, configure and fill an ENI
+	eniAllocationCalls = 4
+
+	// ec2ThrottleWindow is how long after a throttled EC2 call ipamd considers EC2 throttled
+	ec2ThrottleWindow = time.Minute
+
+	// ec2ThrottleCostFactor multiplies the cost of EC2 calls while EC2 is throttled
+	ec2ThrottleCostFactor = 4
+
+	// maxENICallsPerIP is the highest cost, in EC2 calls per IP address, at which an ENI is allocated right after
+	// filling the existing ENIs, rather than on the next reconciliation
+	maxENICallsPerIP = 1.0
+)
+
+// The progressive scale up decisions, the values of the decision label of awscni_scale_up_decision_count
+const (
+	scaleUpFill               = "fill"
+	scaleUpFillAndAllocateENI = "fill_and_allocate_eni"
+	scaleUpDeferENIThrottled  = "defer_eni_ec2_throttled"
+	scaleUpDeferENICost       = "defer_eni_cost"
+	scaleUpDeferENISubnet     = "defer_eni_subnet_headroom"
+)
+
+// scaleUpNeed returns how many IPs the pool is short of, and how many the next ENI to fill can still take. The need is
+// only known with WARM_IP_TARGET or MINIMUM_IP_TARGET in secondary IP mode, elsewhere the pool grows one ENI or prefix
+// at a time.
+func (c *IPAMContext) scaleUpNeed() (need, room int, ok bool) {
+	if !c.enableProgressiveScaleUp || c.enablePrefixDelegation {
+		return 0, 0, false
+	}
+	short, _, warmIPTargetDefined := c.datastoreTargetState()
+	if !warmIPTargetDefined || short == 0 {
+		return 0, 0, false
+	}
+	if eni := c.dataStore.GetENINeedsIP(c.maxIPsPerENI, c.useCustomNetworking); eni != nil {
+		room = max(c.maxIPsPerENI-len(eni.AvailableIPv4Cidrs), 0)
+	}
+	return short, room, true
+}
+
+// freeENISlots returns the number of ENIs ipamd can still attach, keeping a slot for the trunk ENI if it's needed
+func (c *IPAMContext) freeENISlots() int {
+	reserveSlotForTrunkENI := 0
+	if c.enablePodENI && c.dataStore.GetTrunkENI() == "" {
+		reserveSlotForTrunkENI = 1
+	}
+	return max(c.maxENI-c.unmanagedENI-reserveSlotForTrunkENI-c.dataStore.GetENIs(), 0)
+}
+
+// planScaleUp decides whether to allocate an ENI right after filling the existing ENIs with room of the need IPs.
+// Filling an ENI takes a single EC2 call, an ENI eniAllocationCalls, so an ENI is only allocated in the same pass when
+// the IPs still needed make up for its calls: it costs more while EC2 throttles ipamd, and when it takes the last ENI
+// slot. An ENI is never allocated without enough free IPs in its subnet for it to be filled.
+func (c *IPAMContext) planScaleUp(ctx context.Context, need, room int) string {
+	remaining := need - room
+	slots := c.freeENISlots()
+	if remaining <= 0 || slots == 0 {
+		return scaleUpFill
+	}
+	cost := float64(eniAllocationCalls) / float64(min(remaining, c.maxIPsPerENI-1))
+	if slots == 1 {
+		cost *= 2
+	}
+	throttled := time.Since(c.awsClient.GetLastEC2Throttle()) < ec2ThrottleWindow
+	if throttled {
+		cost *= ec2ThrottleCostFactor
+	}
+	if cost > maxENICallsPerIP {
+		if throttled {
+			return scaleUpDeferENIThrottled
+		}
+		return scaleUpDeferENICost
+	}
+	subnetID, err := c.newENISubnetID(ctx)
+	if err == nil {
+		var available int
+		// ec2:DescribeSubnets isn't part of the managed CNI policy, an unknown headroom doesn't hold the ENI back
+		if available, err = c.awsClient.GetSubnetAvailableIPCount(subnetID); err == nil && available < c.maxIPsPerENI {
+			return scaleUpDeferENISubnet
+		}
+	}
+	if err != nil {
+		log.Debugf("Unable to check the subnet headroom before allocating an ENI: %v", err)
+	}
+	return scaleUpFillAndAllocateENI
+}
+
+// newENISubnetID returns the subnet of the ENIConfig of the node with custom networking, or "" for the subnet of the
+// primary ENI
+func (c *IPAMContext) newENISubnetID(ctx context.Context) (string, error) {
+	if !c.useCustomNetworking {
+		return "", nil
+	}
+	eniCfg, err := eniconfig.MyENIConfig(ctx, c.cachedK8SClient)
+	if err != nil {
+		return "", err
+	}
+	return eniCfg.Subnet, nil
+}
+
+// scaleUpAfterFill allocates an ENI right after the existing ENIs were filled if planScaleUp decides so
+func (c *IPAMContext) scaleUpAfterFill(ctx context.Context, decision *PoolDecision, need, room int) {
+	plan := c.planScaleUp(ctx, need, room)
+	scaleUpDecisions.With(prometheus.Labels{"decision": plan}).Inc()
+	if plan != scaleUpFillAndAllocateENI {
+		log.Debugf("Not allocating an ENI for the %d IPs still needed after filling the ENIs: %s", need-room, plan)
+		return
+	}
+	if err := c.tryAllocateENI(ctx); err != nil {
+		decision.Error = err.Error()
+		return
+	}
+	c.updateLastNodeIPPoolAction()
+	decision.Action += " and allocated an ENI"
+}
@@ -0,0 +1,106 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+)
+
+func TestScaleUpNeed(t *testing.T) {
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)}, false))
+	}
+	mockContext := &IPAMContext{
+		dataStore:       ds,
+		maxIPsPerENI:    10,
+		warmIPTarget:    20,
+		minimumIPTarget: noMinimumIPTarget,
+	}
+
+	_, _, ok := mockContext.scaleUpNeed()
+	assert.False(t, ok)
+
+	mockContext.enableProgressiveScaleUp = true
+	need, room, ok := mockContext.scaleUpNeed()
+	assert.True(t, ok)
+	assert.Equal(t, 18, need)
+	assert.Equal(t, 8, room)
+
+	// The need is unknown with WARM_ENI_TARGET
+	mockContext.warmIPTarget = noWarmIPTarget
+	_, _, ok = mockContext.scaleUpNeed()
+	assert.False(t, ok)
+}
+
+func TestPlanScaleUp(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+	ctx := context.Background()
+
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	mockContext := &IPAMContext{
+		awsClient:    m.awsutils,
+		dataStore:    ds,
+		maxIPsPerENI: 10,
+		maxENI:       4,
+	}
+
+	// The existing ENIs cover the need
+	assert.Equal(t, scaleUpFill, mockContext.planScaleUp(ctx, 5, 8))
+
+	// An ENI for 2 IPs isn't worth its calls
+	m.awsutils.EXPECT().GetLastEC2Throttle().Return(time.Time{})
+	assert.Equal(t, scaleUpDeferENICost, mockContext.planScaleUp(ctx, 10, 8))
+
+	m.awsutils.EXPECT().GetLastEC2Throttle().Return(time.Time{})
+	m.awsutils.EXPECT().GetSubnetAvailableIPCount("").Return(100, nil)
+	assert.Equal(t, scaleUpFillAndAllocateENI, mockContext.planScaleUp(ctx, 20, 8))
+
+	// The subnet can't fill an ENI
+	m.awsutils.EXPECT().GetLastEC2Throttle().Return(time.Time{})
+	m.awsutils.EXPECT().GetSubnetAvailableIPCount("").Return(5, nil)
+	assert.Equal(t, scaleUpDeferENISubnet, mockContext.planScaleUp(ctx, 20, 8))
+
+	// Without ec2:DescribeSubnets the headroom is unknown
+	m.awsutils.EXPECT().GetLastEC2Throttle().Return(time.Time{})
+	m.awsutils.EXPECT().GetSubnetAvailableIPCount("").Return(0, errors.New("UnauthorizedOperation"))
+	assert.Equal(t, scaleUpFillAndAllocateENI, mockContext.planScaleUp(ctx, 20, 8))
+
+	// EC2 throttled recently
+	m.awsutils.EXPECT().GetLastEC2Throttle().Return(time.Now().Add(-10 * time.Second))
+	assert.Equal(t, scaleUpDeferENIThrottled, mockContext.planScaleUp(ctx, 20, 8))
+
+	// The last ENI slot is only taken for a full ENI
+	mockContext.maxENI = 2
+	m.awsutils.EXPECT().GetLastEC2Throttle().Return(time.Time{})
+	assert.Equal(t, scaleUpDeferENICost, mockContext.planScaleUp(ctx, 12, 8))
+	m.awsutils.EXPECT().GetLastEC2Throttle().Return(time.Time{})
+	m.awsutils.EXPECT().GetSubnetAvailableIPCount("").Return(100, nil)
+	assert.Equal(t, scaleUpFillAndAllocateENI, mockContext.planScaleUp(ctx, 20, 8))
+
+	// No ENI slot left
+	mockContext.maxENI = 1
+	assert.Equal(t, scaleUpFill, mockContext.planScaleUp(ctx, 20, 8))
+}
@@ -0,0 +1,95 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/eniconfig"
+)
+
+// securityGroupReconcileInterval is how often the security groups of the ENIs are compared with the configured ones
+const securityGroupReconcileInterval = time.Minute
+
+// desiredSecurityGroups returns the sorted security groups the ENIs of the node should have: the ones of the ENIConfig
+// with custom networking, and otherwise, or if the ENIConfig has none, the ones of the primary ENI
+func (c *IPAMContext) desiredSecurityGroups(ctx context.Context) ([]string, error) {
+	if c.useCustomNetworking {
+		eniCfg, err := eniconfig.MyENIConfig(ctx, c.cachedK8SClient)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get the ENIConfig of the node")
+		}
+		if len(eniCfg.SecurityGroups) != 0 {
+			sgIDs := append([]string{}, eniCfg.SecurityGroups...)
+			sort.Strings(sgIDs)
+			return sgIDs, nil
+		}
+	}
+	return c.awsClient.GetSecurityGroups(), nil
+}
+
+// reconcileSecurityGroups compares the security groups of the secondary ENIs in the datastore with the configured
+// ones, so that a change of the ENIConfig security groups, or of the ENIs out of band, doesn't only apply to new ENIs.
+// The drifted ENIs are counted, and with ENABLE_SECURITY_GROUP_RECONCILIATION, set back to the configured security
+// groups. The primary, trunk and EFA ENIs are left alone.
+func (c *IPAMContext) reconcileSecurityGroups(ctx context.Context) {
+	desired, err := c.desiredSecurityGroups(ctx)
+	if err != nil {
+		log.Warnf("Failed to reconcile the security groups of the ENIs: %v", err)
+		return
+	}
+	if len(desired) == 0 {
+		return
+	}
+	attached, err := c.awsClient.GetAttachedENIs()
+	if err != nil {
+		log.Warnf("Failed to get the attached ENIs to reconcile their security groups: %v", err)
+		return
+	}
+	poolENIs := c.dataStore.GetENIInfos().ENIs
+
+	drifted := 0
+	for _, eni := range attached {
+		poolENI, ok := poolENIs[eni.ENIID]
+		if !ok || poolENI.IsPrimary || poolENI.IsTrunk || poolENI.IsEFA {
+			continue
+		}
+		current, err := c.awsClient.GetENISecurityGroups(ctx, eni.MAC)
+		if err != nil {
+			log.Debugf("Failed to get the security groups of ENI %s: %v", eni.ENIID, err)
+			continue
+		}
+		if sets.NewString(current...).Equal(sets.NewString(desired...)) {
+			continue
+		}
+		drifted++
+		log.Infof("ENI %s has the security groups %v instead of %v", eni.ENIID, current, desired)
+		if !c.enableSGReconciliation {
+			continue
+		}
+		if err := c.awsClient.SetENISecurityGroups(ctx, eni.ENIID, desired); err != nil {
+			log.Warnf("Failed to set the security groups of ENI %s: %v", eni.ENIID, err)
+			ipamdErrInc("reconcileSecurityGroups")
+			continue
+		}
+		securityGroupsReconciled.Inc()
+		log.Infof("Set the security groups of ENI %s to %v", eni.ENIID, desired)
+	}
+	securityGroupDriftedENIs.Set(float64(drifted))
+}
@@ -0,0 +1,79 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/apis/crd/v1alpha1"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+)
+
+func TestReconcileSecurityGroups(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+	ctx := context.Background()
+
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	assert.NoError(t, ds.AddENI("eni-2", 1, false, false, false))
+	assert.NoError(t, ds.AddENI("eni-3", 2, false, false, false))
+	attached := []awsutils.ENIMetadata{
+		{ENIID: "eni-1", MAC: "mac-1", DeviceNumber: 0},
+		{ENIID: "eni-2", MAC: "mac-2", DeviceNumber: 1},
+		{ENIID: "eni-3", MAC: "mac-3", DeviceNumber: 2},
+		// Not in the datastore, e.g. unmanaged
+		{ENIID: "eni-4", MAC: "mac-4", DeviceNumber: 3},
+	}
+	mockContext := &IPAMContext{awsClient: m.awsutils, cachedK8SClient: m.cachedK8SClient, dataStore: ds}
+	reconciled := testutil.ToFloat64(securityGroupsReconciled)
+
+	// Drift is only counted by default
+	m.awsutils.EXPECT().GetSecurityGroups().Return([]string{"sg-1", "sg-2"})
+	m.awsutils.EXPECT().GetAttachedENIs().Return(attached, nil)
+	m.awsutils.EXPECT().GetENISecurityGroups(gomock.Any(), "mac-2").Return([]string{"sg-2", "sg-1"}, nil)
+	m.awsutils.EXPECT().GetENISecurityGroups(gomock.Any(), "mac-3").Return([]string{"sg-1"}, nil)
+	mockContext.reconcileSecurityGroups(ctx)
+	assert.Equal(t, float64(1), testutil.ToFloat64(securityGroupDriftedENIs))
+	assert.Equal(t, reconciled, testutil.ToFloat64(securityGroupsReconciled))
+
+	// With custom networking, the ENIs get the security groups of the ENIConfig
+	_ = os.Setenv(envNodeName, myNodeName)
+	defer os.Unsetenv(envNodeName)
+	assert.NoError(t, m.cachedK8SClient.Create(ctx, &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: myNodeName, Labels: map[string]string{"k8s.amazonaws.com/eniConfig": "az1"}},
+	}))
+	assert.NoError(t, m.cachedK8SClient.Create(ctx, &v1alpha1.ENIConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "az1"},
+		Spec:       v1alpha1.ENIConfigSpec{Subnet: "subnet-1", SecurityGroups: []string{"sg-pods"}},
+	}))
+	mockContext.useCustomNetworking = true
+	mockContext.enableSGReconciliation = true
+	m.awsutils.EXPECT().GetAttachedENIs().Return(attached, nil)
+	m.awsutils.EXPECT().GetENISecurityGroups(gomock.Any(), "mac-2").Return([]string{"sg-1", "sg-2"}, nil)
+	m.awsutils.EXPECT().GetENISecurityGroups(gomock.Any(), "mac-3").Return([]string{"sg-pods"}, nil)
+	m.awsutils.EXPECT().SetENISecurityGroups(gomock.Any(), "eni-2", []string{"sg-pods"}).Return(nil)
+	mockContext.reconcileSecurityGroups(ctx)
+	assert.Equal(t, float64(1), testutil.ToFloat64(securityGroupDriftedENIs))
+	assert.Equal(t, reconciled+1, testutil.ToFloat64(securityGroupsReconciled))
+}
@@ -0,0 +1,159 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/networkutils"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/eventrecorder"
+)
+
+const (
+	simulatorNodeName      = "ipamd-sim"
+	simulatorPodNamespace  = "default"
+	simulatorDecisionCount = 10000
+)
+
+// SimulatorConfig is the warm pool configuration of a simulated node
+type SimulatorConfig struct {
+	WarmENITarget          int
+	WarmIPTarget           int
+	MinimumIPTarget        int
+	WarmPrefixTarget       int
+	MaxENI                 int
+	EnablePrefixDelegation bool
+}
+
+// Simulator runs the warm pool management of ipamd for a node behind an awsutils provider, usually a simulated EC2, without Kubernetes or host networking, so that warm targets can be tuned offline. The pool is managed in real
+// time: the address cooling period, the minimum ENI lifetime and the pool decrease interval still apply.
+type Simulator struct {
+	ipamContext *IPAMContext
+}
+
+// simulatorNetwork is the host network of a simulated node, where ENIs need no setup
+type simulatorNetwork struct {
+	networkutils.NetworkAPIs
+}
+
+func (simulatorNetwork) SetupENINetwork(eniIP string, mac string, deviceNumber int, subnetCIDR string) error {
+	return nil
+}
+
+func (simulatorNetwork) FindRouteTableConflicts(mac string, deviceNumber int) ([]string, error) {
+	return nil, nil
+}
+
+// NewSimulator sets up the pool of the node of provider, like ipamd does on startup
+func NewSimulator(provider awsutils.Provider, cfg SimulatorConfig) (*Simulator, error) {
+	ctx := awsutils.WithCaller(context.Background(), awsutils.CallerStartup)
+	k8sClient := testclient.NewClientBuilder().WithObjects(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: simulatorNodeName},
+	}).Build()
+	// Drain the events of the node, like IP exhaustion, which are not reported
+	events := eventrecorder.InitMockEventRecorder(k8sClient).Events
+	go func() {
+		for range events {
+		}
+	}()
+	awsClient, err := awsutils.NewWithProvider(provider, false, false, true, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize the AWS client")
+	}
+
+	c := &IPAMContext{
+		awsClient:              awsClient,
+		rawK8SClient:           k8sClient,
+		cachedK8SClient:        k8sClient,
+		networkClient:          simulatorNetwork{},
+		enableIPv4:             true,
+		enablePrefixDelegation: cfg.EnablePrefixDelegation,
+		warmENITarget:          cfg.WarmENITarget,
+		warmIPTarget:           cfg.WarmIPTarget,
+		minimumIPTarget:        cfg.MinimumIPTarget,
+		warmPrefixTarget:       cfg.WarmPrefixTarget,
+		myNodeName:             simulatorNodeName,
+		primaryIP:              make(map[string]string),
+		poolDecisions:          newPoolDecisionLog(simulatorDecisionCount, datastore.NullCheckpoint{}),
+	}
+	c.reconcileCooldownCache.cache = make(map[string]time.Time)
+	c.dataStore = datastore.NewDataStore(log, datastore.NullCheckpoint{}, cfg.EnablePrefixDelegation)
+	awsClient.InitCachedPrefixDelegation(cfg.EnablePrefixDelegation)
+	if err := c.initENIAndIPLimits(); err != nil {
+		return nil, err
+	}
+	if cfg.MaxENI > 0 && cfg.MaxENI < c.maxENI {
+		c.maxENI = cfg.MaxENI
+	}
+
+	metadataResult, err := awsClient.DescribeAllENIs(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve attached ENIs info")
+	}
+	c.setUnmanagedENIs(metadataResult.TagMap)
+	c.setENITags(metadataResult.TagMap)
+	if err := c.setupENIsOnInit(ctx, c.filterUnmanagedENIs(metadataResult.ENIMetadata), metadataResult); err != nil {
+		return nil, err
+	}
+	return &Simulator{ipamContext: c}, nil
+}
+
+// ManagePool runs an iteration of the pool manager: the warm pool is grown or shrunk, then reconciled with the ENIs
+// attached to the node
+func (s *Simulator) ManagePool(ctx context.Context) {
+	c := s.ipamContext
+	c.updateIPPoolIfRequired(ctx)
+	c.nodeIPPoolReconcile(awsutils.WithCaller(ctx, awsutils.CallerReconciler), nodeIPPoolReconcileInterval)
+}
+
+func simulatorPodKey(name string) datastore.IPAMKey {
+	return datastore.IPAMKey{NetworkName: "aws-cni", ContainerID: name, IfName: "eth0"}
+}
+
+// AddPod assigns an IP to a pod, and fails if the warm pool has none left
+func (s *Simulator) AddPod(name string) (string, error) {
+	ip, _, err := s.ipamContext.dataStore.AssignPodIPv4Address(simulatorPodKey(name),
+		datastore.IPAMMetadata{K8SPodNamespace: simulatorPodNamespace, K8SPodName: name})
+	return ip, err
+}
+
+// DelPod releases the IP of a pod
+func (s *Simulator) DelPod(name string) error {
+	_, _, _, err := s.ipamContext.dataStore.UnassignPodIPAddress(simulatorPodKey(name))
+	return err
+}
+
+// Stats returns the stats of the warm pool
+func (s *Simulator) Stats() *datastore.DataStoreStats {
+	return s.ipamContext.dataStore.GetIPStats(ipV4AddrFamily)
+}
+
+// ENIs returns the number of ENIs in the warm pool
+func (s *Simulator) ENIs() int {
+	return s.ipamContext.dataStore.GetENIs()
+}
+
+// PoolDecisions returns the decisions taken by the pool manager
+func (s *Simulator) PoolDecisions() []PoolDecision {
+	return s.ipamContext.poolDecisions.list(time.Time{}, "")
+}
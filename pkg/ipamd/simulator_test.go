@@ -0,0 +1,65 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils/fakeprovider"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/paths"
+)
+
+func TestSimulator(t *testing.T) {
+	defer os.Unsetenv(paths.EnvRunDir)
+	_ = os.Setenv(paths.EnvRunDir, t.TempDir())
+	ctx := context.Background()
+
+	provider, err := fakeprovider.New(fakeprovider.DefaultConfig())
+	require.NoError(t, err)
+	sim, err := ipamd.NewSimulator(provider, ipamd.SimulatorConfig{WarmENITarget: 1})
+	require.NoError(t, err)
+
+	// The pool starts empty, pods starve until the pool manager fills the primary ENI
+	_, err = sim.AddPod("pod-0")
+	assert.Error(t, err)
+	sim.ManagePool(ctx)
+	assert.Equal(t, 1, sim.ENIs())
+	assert.Equal(t, 9, sim.Stats().TotalIPs)
+
+	// Each full ENI gets a new warm ENI, up to the ENI limit
+	pods := 0
+	for eni := 1; eni <= 3; eni++ {
+		for ; pods < 9*eni; pods++ {
+			_, err = sim.AddPod(fmt.Sprintf("pod-%d", pods))
+			assert.NoError(t, err)
+		}
+		sim.ManagePool(ctx)
+	}
+	assert.Equal(t, 3, sim.ENIs())
+	assert.Equal(t, 27, sim.Stats().AssignedIPs)
+	assert.Equal(t, 2, provider.Calls("CreateNetworkInterface"))
+	_, err = sim.AddPod("pod-27")
+	assert.Error(t, err)
+
+	assert.NoError(t, sim.DelPod("pod-0"))
+	assert.Equal(t, 26, sim.Stats().AssignedIPs)
+	assert.NotEmpty(t, sim.PoolDecisions())
+}
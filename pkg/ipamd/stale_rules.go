@@ -0,0 +1,54 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// staleRuleScrubInterval is how often the ip rules and routes of the pods are checked against the datastore
+const staleRuleScrubInterval = 5 * time.Minute
+
+// StartStaleRuleScrubber periodically deletes the ip rules and routes left behind for pod IPs that are no longer
+// assigned, when the CNI DEL of a pod crashed or never ran. It returns right away unless ENABLE_STALE_RULE_SCRUBBER
+// is set.
+func (c *IPAMContext) StartStaleRuleScrubber() {
+	if !c.enableStaleRuleScrubber {
+		return
+	}
+	log.Infof("Starting the stale rule scrubber, dry run: %v", c.staleRuleScrubberDryRun)
+	for {
+		time.Sleep(staleRuleScrubInterval)
+		c.scrubStaleRules()
+	}
+}
+
+func (c *IPAMContext) scrubStaleRules() {
+	report, err := c.networkClient.ScrubStaleRules(c.dataStore.IsIPAssigned, c.enableIPv6, c.staleRuleScrubberDryRun)
+	dryRun := strconv.FormatBool(c.staleRuleScrubberDryRun)
+	staleRulesRemoved.With(prometheus.Labels{"kind": "rule", "dry_run": dryRun}).Add(float64(report.Rules))
+	staleRulesRemoved.With(prometheus.Labels{"kind": "route", "dry_run": dryRun}).Add(float64(report.Routes))
+	if err != nil {
+		log.Errorf("Failed to scrub stale pod rules: %v", err)
+		ipamdErrInc("scrubStaleRules")
+		return
+	}
+	if report.Rules > 0 || report.Routes > 0 {
+		log.Infof("Found %d stale pod rules and %d stale pod routes, dry run: %v",
+			report.Rules, report.Routes, c.staleRuleScrubberDryRun)
+	}
+}
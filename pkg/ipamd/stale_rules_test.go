@@ -0,0 +1,71 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/networkutils"
+)
+
+func TestScrubStaleRules(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)}, false))
+	}
+	assigned, _, err := ds.AssignPodIPv4Address(datastore.IPAMKey{NetworkName: "aws-cni", ContainerID: "cid-1", IfName: "eth0"},
+		datastore.IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "pod-1"})
+	assert.NoError(t, err)
+
+	mockContext := &IPAMContext{
+		networkClient:           m.network,
+		dataStore:               ds,
+		enableIPv4:              true,
+		staleRuleScrubberDryRun: true,
+	}
+	removed := func(kind, dryRun string) float64 {
+		return testutil.ToFloat64(staleRulesRemoved.With(prometheus.Labels{"kind": kind, "dry_run": dryRun}))
+	}
+	rulesBefore, routesBefore := removed("rule", "true"), removed("route", "true")
+
+	m.network.EXPECT().ScrubStaleRules(gomock.Any(), false, true).DoAndReturn(
+		func(isAssigned func(net.IP) bool, v6Enabled, dryRun bool) (networkutils.StaleRuleReport, error) {
+			assert.True(t, isAssigned(net.ParseIP(assigned)))
+			assert.False(t, isAssigned(net.ParseIP("10.0.0.9")))
+			return networkutils.StaleRuleReport{Rules: 2, Routes: 1}, nil
+		})
+	mockContext.scrubStaleRules()
+	assert.Equal(t, rulesBefore+2, removed("rule", "true"))
+	assert.Equal(t, routesBefore+1, removed("route", "true"))
+
+	// The entries removed before a failure are still counted
+	mockContext.staleRuleScrubberDryRun = false
+	rulesBefore = removed("rule", "false")
+	m.network.EXPECT().ScrubStaleRules(gomock.Any(), false, false).Return(
+		networkutils.StaleRuleReport{Rules: 1}, errors.New("netlink error"))
+	mockContext.scrubStaleRules()
+	assert.Equal(t, rulesBefore+1, removed("rule", "false"))
+}
@@ -0,0 +1,107 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// startupTaintCheckInterval is how often the readiness is checked before the startup taint is removed
+const startupTaintCheckInterval = 5 * time.Second
+
+// applyStartupTaint puts the STARTUP_TAINT_KEY taint on the node, so that no pod is scheduled on it before ipamd has
+// the IPs to set up its network. A failure only leaves the node without the taint.
+func (c *IPAMContext) applyStartupTaint(ctx context.Context) {
+	if c.startupTaintKey == "" {
+		return
+	}
+	if err := c.setStartupTaint(ctx, true); err != nil {
+		log.Warnf("Failed to taint node %s with %s: %v", c.myNodeName, c.startupTaintKey, err)
+		return
+	}
+	log.Infof("Tainted node %s with %s until ipamd is ready", c.myNodeName, c.startupTaintKey)
+}
+
+// StartStartupTaintManager removes the STARTUP_TAINT_KEY taint once ipamd reports ready, whether ipamd or kubelet put
+// it on the node
+func (c *IPAMContext) StartStartupTaintManager() {
+	if c.startupTaintKey == "" {
+		return
+	}
+	for {
+		time.Sleep(startupTaintCheckInterval)
+		if c.removeStartupTaintIfReady(context.TODO()) {
+			return
+		}
+	}
+}
+
+// removeStartupTaintIfReady returns true once there is nothing left to do: the taint was removed, or aws-node isn't
+// allowed to patch the node and retrying won't help
+func (c *IPAMContext) removeStartupTaintIfReady(ctx context.Context) bool {
+	if !c.getHealthReport(true).OK {
+		return false
+	}
+	err := c.setStartupTaint(ctx, false)
+	if err == nil {
+		log.Infof("Removed taint %s from node %s", c.startupTaintKey, c.myNodeName)
+		return true
+	}
+	if k8serror.IsForbidden(errors.Cause(err)) {
+		log.Errorf("Failed to remove taint %s from node %s, the aws-node cluster role needs the patch verb on nodes: %v",
+			c.startupTaintKey, c.myNodeName, err)
+		return true
+	}
+	log.Warnf("Failed to remove taint %s from node %s, will retry: %v", c.startupTaintKey, c.myNodeName, err)
+	ipamdErrInc("removeStartupTaint")
+	return false
+}
+
+// setStartupTaint adds or removes the startup taint with a patch of the node taints, retried when the node changed
+// since it was read
+func (c *IPAMContext) setStartupTaint(ctx context.Context, present bool) error {
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		node := &corev1.Node{}
+		if err := c.cachedK8SClient.Get(ctx, types.NamespacedName{Name: c.myNodeName}, node); err != nil {
+			return errors.Wrap(err, "failed to get node")
+		}
+		var taints []corev1.Taint
+		found := false
+		for _, taint := range node.Spec.Taints {
+			if taint.Key == c.startupTaintKey {
+				found = true
+				continue
+			}
+			taints = append(taints, taint)
+		}
+		if found == present {
+			return nil
+		}
+		if present {
+			taints = append(taints, corev1.Taint{Key: c.startupTaintKey, Effect: corev1.TaintEffectNoSchedule})
+		}
+		updateNode := node.DeepCopy()
+		updateNode.Spec.Taints = taints
+		// The optimistic lock makes a concurrent change of the taints fail with a conflict rather than be overwritten
+		return c.cachedK8SClient.Patch(ctx, updateNode, client.MergeFromWithOptions(node, client.MergeFromWithOptimisticLock{}))
+	})
+}
@@ -0,0 +1,66 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestStartupTaint(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+	ctx := context.Background()
+
+	const taintKey = "node.vpc.amazonaws.com/network-unavailable"
+	otherTaint := corev1.Taint{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule}
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: myNodeName},
+		Spec:       corev1.NodeSpec{Taints: []corev1.Taint{otherTaint}},
+	}
+	assert.NoError(t, m.cachedK8SClient.Create(ctx, node))
+	getTaints := func() []corev1.Taint {
+		node := &corev1.Node{}
+		assert.NoError(t, m.cachedK8SClient.Get(ctx, types.NamespacedName{Name: myNodeName}, node))
+		return node.Spec.Taints
+	}
+
+	mockContext := &IPAMContext{
+		awsClient:       m.awsutils,
+		cachedK8SClient: m.cachedK8SClient,
+		myNodeName:      myNodeName,
+		startupTaintKey: taintKey,
+	}
+	m.awsutils.EXPECT().GetEC2ReachabilityError().Return(nil).AnyTimes()
+
+	// Applying the taint twice only adds it once
+	mockContext.applyStartupTaint(ctx)
+	mockContext.applyStartupTaint(ctx)
+	startupTaint := corev1.Taint{Key: taintKey, Effect: corev1.TaintEffectNoSchedule}
+	assert.Equal(t, []corev1.Taint{otherTaint, startupTaint}, getTaints())
+
+	// The taint stays until ipamd is ready
+	assert.False(t, mockContext.removeStartupTaintIfReady(ctx))
+	assert.Equal(t, []corev1.Taint{otherTaint, startupTaint}, getTaints())
+
+	mockContext.setDatastoreReconciled()
+	assert.True(t, mockContext.removeStartupTaintIfReady(ctx))
+	assert.Equal(t, []corev1.Taint{otherTaint}, getTaints())
+	assert.True(t, mockContext.removeStartupTaintIfReady(ctx))
+}
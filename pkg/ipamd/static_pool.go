@@ -0,0 +1,50 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils/staticprovider"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/netlinkwrapper"
+)
+
+// parseCIDRList splits a comma or whitespace separated list of CIDRs
+func parseCIDRList(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+}
+
+// newStaticIPPoolProvider returns the provider of the static IP pool set through STATIC_IP_POOL_CIDRS, or nil if the
+// node takes its IPs from EC2. The node presents its host interface as its primary ENI, with the pool as its
+// secondary IPs, so that the datastore, the gRPC server and the routing of the pods work as on EC2 nodes.
+func newStaticIPPoolProvider(netLink netlinkwrapper.NetLink, nodeName string) (*staticprovider.Provider, error) {
+	poolCIDRs := parseCIDRList(os.Getenv(envStaticIPPoolCIDRs))
+	if len(poolCIDRs) == 0 {
+		return nil, nil
+	}
+	hostInterface, err := staticprovider.FindInterface(netLink, strings.TrimSpace(os.Getenv(envStaticIPPoolInterface)))
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Static IP pool uses host interface with MAC %s and IP %s", hostInterface.MAC, hostInterface.IP)
+	return staticprovider.New(staticprovider.Config{
+		Region:        os.Getenv("AWS_REGION"),
+		InstanceID:    nodeName,
+		Interface:     hostInterface,
+		PoolCIDRs:     poolCIDRs,
+		RoutableCIDRs: parseCIDRList(os.Getenv(envStaticIPPoolRoutableCIDRs)),
+	})
+}
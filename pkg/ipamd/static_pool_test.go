@@ -0,0 +1,63 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"net"
+	"os"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+
+	mock_netlinkwrapper "github.com/aws/amazon-vpc-cni-k8s/pkg/netlinkwrapper/mocks"
+)
+
+func TestParseCIDRList(t *testing.T) {
+	assert.Empty(t, parseCIDRList(""))
+	assert.Equal(t, []string{"10.85.0.0/24", "10.86.0.0/24", "10.87.0.0/24"}, parseCIDRList(" 10.85.0.0/24,10.86.0.0/24\n10.87.0.0/24,"))
+}
+
+func TestNewStaticIPPoolProvider(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockNetLink := mock_netlinkwrapper.NewMockNetLink(ctrl)
+	defer os.Unsetenv(envStaticIPPoolCIDRs)
+	defer os.Unsetenv(envStaticIPPoolInterface)
+
+	_ = os.Unsetenv(envStaticIPPoolCIDRs)
+	provider, err := newStaticIPPoolProvider(mockNetLink, myNodeName)
+	assert.NoError(t, err)
+	assert.Nil(t, provider)
+
+	hwAddr, _ := net.ParseMAC("52:54:00:12:34:56")
+	uplink := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Index: 2, Name: "enp1s0", HardwareAddr: hwAddr}}
+	addrs := []netlink.Addr{{IPNet: &net.IPNet{IP: net.ParseIP("192.168.1.10"), Mask: net.CIDRMask(24, 32)}}}
+	_ = os.Setenv(envStaticIPPoolCIDRs, "10.85.0.0/28,10.85.1.0/28")
+	_ = os.Setenv(envStaticIPPoolInterface, "enp1s0")
+	mockNetLink.EXPECT().LinkByName("enp1s0").Return(uplink, nil)
+	mockNetLink.EXPECT().AddrList(uplink, netlink.FAMILY_V4).Return(addrs, nil)
+	provider, err = newStaticIPPoolProvider(mockNetLink, myNodeName)
+	assert.NoError(t, err)
+	if assert.NotNil(t, provider) {
+		assert.Equal(t, 28, provider.PoolSize())
+	}
+
+	_ = os.Setenv(envStaticIPPoolCIDRs, "10.85.0.0/28,fd00::/120")
+	mockNetLink.EXPECT().LinkByName("enp1s0").Return(uplink, nil)
+	mockNetLink.EXPECT().AddrList(uplink, netlink.FAMILY_V4).Return(addrs, nil)
+	_, err = newStaticIPPoolProvider(mockNetLink, myNodeName)
+	assert.Error(t, err)
+}
@@ -0,0 +1,117 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils"
+)
+
+// subnetPrefixProbeInterval is how often the /28 prefixes left in the subnet are estimated with prefix delegation.
+// Each probe describes all the ENIs of the subnet.
+const subnetPrefixProbeInterval = 10 * time.Minute
+
+// StartSubnetPrefixProbe periodically estimates how many /28 prefixes can still be assigned from the subnet new ENIs
+// are created in, so that operators know prefix delegation will fail before pods do. It returns right away unless
+// IPv4 prefix delegation is enabled. The startup validation runs the first probe.
+func (c *IPAMContext) StartSubnetPrefixProbe() {
+	if !c.probesSubnetPrefixes() {
+		return
+	}
+	ctx := awsutils.WithCaller(context.TODO(), awsutils.CallerSubnetProbe)
+	for {
+		time.Sleep(subnetPrefixProbeInterval)
+		if _, err := c.probeSubnetPrefixes(ctx); err != nil {
+			log.Warnf("Unable to estimate the /28 prefixes left in the subnet: %v", err)
+		}
+	}
+}
+
+func (c *IPAMContext) probesSubnetPrefixes() bool {
+	return c.enablePrefixDelegation && c.enableIPv4 && !c.disableENIProvisioning
+}
+
+// probeSubnetPrefixes estimates the /28 prefixes left in the subnet new ENIs are created in, and publishes the result
+// in the awscni_subnet_free_prefixes metric and the /v1/subnet-prefixes introspection endpoint
+func (c *IPAMContext) probeSubnetPrefixes(ctx context.Context) (*awsutils.SubnetPrefixAvailability, error) {
+	subnetID, err := c.newENISubnetID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find the ENIConfig of the node: %v", err)
+	}
+	availability, err := c.awsClient.GetSubnetPrefixAvailability(ctx, subnetID)
+	if err != nil {
+		return nil, err
+	}
+	if availability.FreePrefixes == 0 && availability.AvailableIPs > 0 {
+		log.Warnf("Subnet %s has %d free IPs but no free /28 prefix, new prefixes will fall back to secondary IPs",
+			availability.SubnetID, availability.AvailableIPs)
+	}
+	subnetFreePrefixes.With(prometheus.Labels{"subnet": availability.SubnetID}).Set(float64(availability.FreePrefixes))
+	c.subnetPrefixesLock.Lock()
+	c.subnetPrefixes = availability
+	c.subnetPrefixesLock.Unlock()
+	return availability, nil
+}
+
+// checkSubnetPrefixes checks that the subnet new ENIs are created in still has /28 prefixes to assign
+func (c *IPAMContext) checkSubnetPrefixes(ctx context.Context) ReadinessCheck {
+	check := ReadinessCheck{Name: "SubnetPrefixes", Status: readinessPass}
+	if !c.probesSubnetPrefixes() {
+		check.Message = "IPv4 prefix delegation is disabled"
+		return check
+	}
+	availability, err := c.probeSubnetPrefixes(awsutils.WithCaller(ctx, awsutils.CallerStartup))
+	switch {
+	case err != nil:
+		check.Status = readinessWarn
+		check.Message = fmt.Sprintf("unable to estimate the /28 prefixes left in the subnet: %v", err)
+	case availability.FreePrefixes == 0:
+		// ENIs then get secondary IPs instead, so this is only a warning
+		check.Status = readinessWarn
+		check.Message = fmt.Sprintf("the subnet has %d free IP addresses but no free /28 prefix", availability.AvailableIPs)
+	default:
+		check.Message = fmt.Sprintf("the subnet has about %d free /28 prefixes", availability.FreePrefixes)
+	}
+	return check
+}
+
+func (c *IPAMContext) getSubnetPrefixes() *awsutils.SubnetPrefixAvailability {
+	c.subnetPrefixesLock.RLock()
+	defer c.subnetPrefixesLock.RUnlock()
+	return c.subnetPrefixes
+}
+
+func subnetPrefixesRequestHandler(ipam *IPAMContext) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		availability := ipam.getSubnetPrefixes()
+		if availability == nil {
+			http.Error(w, "the subnet has not been probed for /28 prefixes", http.StatusServiceUnavailable)
+			return
+		}
+		responseJSON, err := json.Marshal(availability)
+		if err != nil {
+			log.Errorf("Failed to marshal the subnet prefix availability: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		logErr(w.Write(responseJSON))
+	}
+}
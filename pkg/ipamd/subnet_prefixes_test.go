@@ -0,0 +1,71 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils"
+)
+
+func TestCheckSubnetPrefixes(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+	ctx := context.Background()
+
+	mockContext := &IPAMContext{awsClient: m.awsutils, enableIPv4: true}
+	check := mockContext.checkSubnetPrefixes(ctx)
+	assert.Equal(t, readinessPass, check.Status)
+	assert.Equal(t, "IPv4 prefix delegation is disabled", check.Message)
+
+	// The subnet isn't probed before the startup validation
+	w := httptest.NewRecorder()
+	subnetPrefixesRequestHandler(mockContext)(w, httptest.NewRequest(http.MethodGet, "/v1/subnet-prefixes", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	mockContext.enablePrefixDelegation = true
+	fragmented := &awsutils.SubnetPrefixAvailability{SubnetID: "subnet-fragmented", AvailableIPs: 40, ReservationsChecked: true}
+	m.awsutils.EXPECT().GetSubnetPrefixAvailability(gomock.Any(), "").Return(fragmented, nil)
+	check = mockContext.checkSubnetPrefixes(ctx)
+	assert.Equal(t, readinessWarn, check.Status)
+	assert.Equal(t, "the subnet has 40 free IP addresses but no free /28 prefix", check.Message)
+	assert.Equal(t, 0.0, testutil.ToFloat64(subnetFreePrefixes.WithLabelValues("subnet-fragmented")))
+
+	w = httptest.NewRecorder()
+	subnetPrefixesRequestHandler(mockContext)(w, httptest.NewRequest(http.MethodGet, "/v1/subnet-prefixes", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var got awsutils.SubnetPrefixAvailability
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, *fragmented, got)
+
+	m.awsutils.EXPECT().GetSubnetPrefixAvailability(gomock.Any(), "").Return(&awsutils.SubnetPrefixAvailability{SubnetID: "subnet-fragmented", FreePrefixes: 12}, nil)
+	check = mockContext.checkSubnetPrefixes(ctx)
+	assert.Equal(t, readinessPass, check.Status)
+	assert.Equal(t, 12.0, testutil.ToFloat64(subnetFreePrefixes.WithLabelValues("subnet-fragmented")))
+
+	// A failed probe keeps the last result
+	m.awsutils.EXPECT().GetSubnetPrefixAvailability(gomock.Any(), "").Return(nil, errors.New("UnauthorizedOperation"))
+	check = mockContext.checkSubnetPrefixes(ctx)
+	assert.Equal(t, readinessWarn, check.Status)
+	assert.Equal(t, 12, mockContext.getSubnetPrefixes().FreePrefixes)
+}
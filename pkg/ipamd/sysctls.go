@@ -0,0 +1,44 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// sysctlReconcileInterval is how often the kernel settings applied to the interfaces are checked for drift
+const sysctlReconcileInterval = time.Minute
+
+// StartSysctlReconciler periodically sets back the kernel settings of the interfaces, e.g. rp_filter, that other
+// agents or a sysctl reload changed after ipamd applied them
+func (c *IPAMContext) StartSysctlReconciler() {
+	for {
+		time.Sleep(sysctlReconcileInterval)
+		c.reconcileSysctls()
+	}
+}
+
+func (c *IPAMContext) reconcileSysctls() {
+	drifted, err := c.networkClient.ReconcileSysctls()
+	for _, key := range drifted {
+		log.Warnf("Kernel setting %s was changed, set it back", key)
+		sysctlDrift.With(prometheus.Labels{"setting": key}).Inc()
+	}
+	if err != nil {
+		log.Errorf("Failed to reconcile the kernel settings: %v", err)
+		ipamdErrInc("reconcileSysctls")
+	}
+}
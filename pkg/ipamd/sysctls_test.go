@@ -0,0 +1,47 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconcileSysctls(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	mockContext := &IPAMContext{networkClient: m.network}
+	const key = "net/ipv4/conf/eth0/rp_filter"
+	drift := func() float64 {
+		return testutil.ToFloat64(sysctlDrift.With(prometheus.Labels{"setting": key}))
+	}
+	before := drift()
+
+	m.network.EXPECT().ReconcileSysctls().Return(nil, nil)
+	mockContext.reconcileSysctls()
+	assert.Equal(t, before, drift())
+
+	m.network.EXPECT().ReconcileSysctls().Return([]string{key}, nil)
+	mockContext.reconcileSysctls()
+	assert.Equal(t, before+1, drift())
+
+	m.network.EXPECT().ReconcileSysctls().Return([]string{key}, errors.New("failed to reconcile settings"))
+	mockContext.reconcileSysctls()
+	assert.Equal(t, before+2, drift())
+}
@@ -0,0 +1,181 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+)
+
+const (
+	// teardownRetryInterval is how often the teardowns the CNI plugin failed are retried
+	teardownRetryInterval = 30 * time.Second
+
+	// teardownMaxAge is how long a teardown is retried before it is given up on, leaving the rules to the stale rule
+	// scrubber
+	teardownMaxAge = 24 * time.Hour
+)
+
+// PendingTeardown is the teardown of the routes and rules of a deleted pod that the CNI plugin failed on DEL, for
+// instance on an EBUSY from netlink, and that ipamd retries
+type PendingTeardown struct {
+	ContainerID string
+	// IP of the pod, in CIDR notation
+	IP         string
+	RouteTable int
+	EnqueuedAt time.Time
+	Attempts   int
+	// LastError is the error of the last attempt, or the one of the CNI plugin before the first retry
+	LastError string `json:",omitempty"`
+}
+
+// teardownQueue holds the pending teardowns, and persists them so that they survive ipamd restarts
+type teardownQueue struct {
+	lock         sync.Mutex
+	teardowns    []PendingTeardown
+	checkpointer datastore.Checkpointer
+}
+
+func newTeardownQueue(checkpointer datastore.Checkpointer) *teardownQueue {
+	q := &teardownQueue{checkpointer: checkpointer}
+	if err := checkpointer.Restore(&q.teardowns); err != nil && !os.IsNotExist(err) {
+		log.Warnf("Failed to restore the teardown queue: %v", err)
+	}
+	q.updateMetrics(time.Now())
+	return q
+}
+
+// add queues teardown, in place of a pending teardown of the same IP, and returns the number of pending teardowns
+func (q *teardownQueue) add(teardown PendingTeardown) int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	teardowns := []PendingTeardown{teardown}
+	for _, pending := range q.teardowns {
+		if pending.IP != teardown.IP {
+			teardowns = append(teardowns, pending)
+		}
+	}
+	q.teardowns = teardowns
+	q.persist()
+	q.updateMetrics(time.Now())
+	return len(q.teardowns)
+}
+
+// retry runs each pending teardown through teardown, and keeps the ones that fail for the next retry. The ones that
+// drop returns true for are removed without being run.
+func (q *teardownQueue) retry(teardown func(*net.IPNet, int) error, drop func(*PendingTeardown, time.Time) bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if len(q.teardowns) == 0 {
+		return
+	}
+	now := time.Now()
+	var teardowns []PendingTeardown
+	for _, pending := range q.teardowns {
+		if drop(&pending, now) {
+			continue
+		}
+		_, addr, err := net.ParseCIDR(pending.IP)
+		if err == nil {
+			err = teardown(addr, pending.RouteTable)
+		}
+		if err == nil {
+			log.Infof("Tore down the routes and rules of %s, container %s, after %d retries", pending.IP,
+				pending.ContainerID, pending.Attempts+1)
+			continue
+		}
+		pending.Attempts++
+		pending.LastError = err.Error()
+		log.Warnf("Failed to tear down the routes and rules of %s, container %s, will retry: %v", pending.IP,
+			pending.ContainerID, err)
+		teardowns = append(teardowns, pending)
+	}
+	q.teardowns = teardowns
+	q.persist()
+	q.updateMetrics(now)
+}
+
+// list returns a copy of the pending teardowns
+func (q *teardownQueue) list() []PendingTeardown {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return append([]PendingTeardown{}, q.teardowns...)
+}
+
+func (q *teardownQueue) persist() {
+	if err := q.checkpointer.Checkpoint(q.teardowns); err != nil {
+		log.Warnf("Failed to persist the teardown queue: %v", err)
+	}
+}
+
+// updateMetrics sets the depth of the queue and the age of its oldest teardown
+func (q *teardownQueue) updateMetrics(now time.Time) {
+	var oldest time.Duration
+	for _, pending := range q.teardowns {
+		if age := now.Sub(pending.EnqueuedAt); age > oldest {
+			oldest = age
+		}
+	}
+	teardownQueueDepth.Set(float64(len(q.teardowns)))
+	teardownQueueOldestAge.Set(oldest.Seconds())
+}
+
+// StartTeardownQueue periodically retries the teardowns the CNI plugin failed
+func (c *IPAMContext) StartTeardownQueue() {
+	for {
+		time.Sleep(teardownRetryInterval)
+		c.retryTeardowns()
+	}
+}
+
+func (c *IPAMContext) retryTeardowns() {
+	c.teardownQueue.retry(c.networkClient.TeardownPodRouteRules, func(pending *PendingTeardown, now time.Time) bool {
+		ip, _, err := net.ParseCIDR(pending.IP)
+		if err != nil {
+			log.Errorf("Dropping the teardown of invalid IP %q, container %s", pending.IP, pending.ContainerID)
+			return true
+		}
+		// A new pod got the IP, and its routes and rules with it
+		if c.dataStore.IsIPAssigned(ip) {
+			log.Infof("Dropping the teardown of %s, container %s, as the IP is assigned again", pending.IP, pending.ContainerID)
+			return true
+		}
+		if now.Sub(pending.EnqueuedAt) > teardownMaxAge {
+			log.Errorf("Giving up on the teardown of %s, container %s, after %d retries: %s", pending.IP,
+				pending.ContainerID, pending.Attempts, pending.LastError)
+			ipamdErrInc("retryTeardowns")
+			return true
+		}
+		return false
+	})
+}
+
+// teardownQueueRequestHandler serves the pending teardowns
+func teardownQueueRequestHandler(ipam *IPAMContext) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		responseJSON, err := json.Marshal(ipam.teardownQueue.list())
+		if err != nil {
+			log.Errorf("Failed to marshal the teardown queue: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		logErr(w.Write(responseJSON))
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+	"github.com/aws/amazon-vpc-cni-k8s/rpc"
+)
+
+func TestRetryTeardowns(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	path := filepath.Join(t.TempDir(), "teardown-queue.json")
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 1, false, false, false))
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP("10.0.0.5"), Mask: net.CIDRMask(32, 32)}, false))
+	mockContext := &IPAMContext{
+		networkClient: m.network,
+		dataStore:     ds,
+		teardownQueue: newTeardownQueue(datastore.NewJSONFile(path)),
+	}
+	s := &server{version: "1.2.3", ipamContext: mockContext}
+
+	reply, err := s.EnqueueTeardown(context.Background(), &rpc.EnqueueTeardownRequest{
+		ClientVersion: "1.2.3", ContainerID: "cid-1", IPv4Addr: "10.0.0.6", RouteTable: 2, Error: "device or resource busy"})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), reply.QueueDepth)
+	// A second DEL of the same IP replaces the first
+	reply, err = s.EnqueueTeardown(context.Background(), &rpc.EnqueueTeardownRequest{
+		ClientVersion: "1.2.3", ContainerID: "cid-1", IPv4Addr: "10.0.0.6", RouteTable: 2, Error: "device or resource busy"})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), reply.QueueDepth)
+	reply, err = s.EnqueueTeardown(context.Background(), &rpc.EnqueueTeardownRequest{
+		ClientVersion: "1.2.3", ContainerID: "cid-2", IPv4Addr: "10.0.0.5", RouteTable: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), reply.QueueDepth)
+	_, err = s.EnqueueTeardown(context.Background(), &rpc.EnqueueTeardownRequest{ClientVersion: "1.2.3", ContainerID: "cid-3"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// 10.0.0.5 is assigned to a new pod, which owns its routes and rules now. The other teardown fails once more.
+	key := datastore.IPAMKey{NetworkName: "aws-cni", ContainerID: "cid-4", IfName: "eth0"}
+	_, err = ds.AssignPodIPAddress(key, datastore.IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "pod-4"}, datastore.FamilyIPv4)
+	assert.NoError(t, err)
+	podAddr := &net.IPNet{IP: net.ParseIP("10.0.0.6").To4(), Mask: net.CIDRMask(32, 32)}
+	m.network.EXPECT().TeardownPodRouteRules(podAddr, 2).Return(syscall.EBUSY)
+	mockContext.retryTeardowns()
+	pending := mockContext.teardownQueue.list()
+	assert.Len(t, pending, 1)
+	assert.Equal(t, "cid-1", pending[0].ContainerID)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.Equal(t, syscall.EBUSY.Error(), pending[0].LastError)
+
+	// The queue survives restarts, and the teardown succeeds
+	mockContext.teardownQueue = newTeardownQueue(datastore.NewJSONFile(path))
+	m.network.EXPECT().TeardownPodRouteRules(podAddr, 2).Return(nil)
+	mockContext.retryTeardowns()
+	assert.Empty(t, mockContext.teardownQueue.list())
+}
+
+func TestRetryTeardownsGivesUp(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	mockContext := &IPAMContext{
+		networkClient: m.network,
+		dataStore:     datastore.NewDataStore(log, datastore.NullCheckpoint{}, false),
+		teardownQueue: newTeardownQueue(datastore.NullCheckpoint{}),
+	}
+	mockContext.teardownQueue.add(PendingTeardown{
+		ContainerID: "cid-1",
+		IP:          "10.0.0.6/32",
+		RouteTable:  2,
+		EnqueuedAt:  time.Now().Add(-2 * teardownMaxAge),
+	})
+	mockContext.retryTeardowns()
+	assert.Empty(t, mockContext.teardownQueue.list())
+}
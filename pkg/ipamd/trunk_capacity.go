@@ -0,0 +1,110 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/eventrecorder"
+)
+
+const (
+	// podENIResourceName is the extended resource the VPC Resource Controller advertises for the branch ENI slots
+	// of the trunk ENI, and the annotation it sets with the branch ENIs of a pod
+	podENIResourceName = "vpc.amazonaws.com/pod-eni"
+	// trunkFullReason is used when a pod asks for a branch ENI and the trunk ENI has no free slot
+	trunkFullReason = "TrunkENIFull"
+)
+
+// syncTrunkBranchENIs refreshes the branch ENI capacity of the trunk ENI from the node, and the branch ENIs in use
+// from the pods on the node, so that branch ENIs attached while ipamd was down are counted too.
+func (c *IPAMContext) syncTrunkBranchENIs(ctx context.Context) {
+	if !c.enablePodENI || c.dataStore.GetTrunkENI() == "" {
+		return
+	}
+	node := &corev1.Node{}
+	err := c.cachedK8SClient.Get(ctx, types.NamespacedName{Name: c.myNodeName}, node)
+	if err != nil {
+		log.Debugf("Skipping trunk ENI capacity update, failed to get node: %v", err)
+		return
+	}
+	if capacity, ok := node.Status.Allocatable[podENIResourceName]; ok {
+		c.dataStore.SetTrunkCapacity(int(capacity.Value()))
+	}
+
+	var podList corev1.PodList
+	err = c.rawK8SClient.List(ctx, &podList, &client.ListOptions{
+		FieldSelector: fields.SelectorFromSet(fields.Set{"spec.nodeName": c.myNodeName}),
+	})
+	if err != nil {
+		log.Warnf("Skipping branch ENI resync, failed to list the pods of the node: %v", err)
+		ipamdErrInc("syncTrunkBranchENIs")
+		return
+	}
+	branchENIs := make(map[datastore.IPAMMetadata]int)
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.Spec.NodeName != c.myNodeName || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		if count := podBranchENICount(pod); count > 0 {
+			branchENIs[datastore.IPAMMetadata{K8SPodNamespace: pod.Namespace, K8SPodName: pod.Name}] = count
+		}
+	}
+	c.dataStore.SyncBranchENIs(branchENIs)
+
+	if c.dataStore.GetTrunkStats().Free() != 0 {
+		c.trunkFullLock.Lock()
+		c.trunkFull = false
+		c.trunkFullLock.Unlock()
+	}
+}
+
+// podBranchENICount returns the number of branch ENIs in the pod-eni annotation of a pod
+func podBranchENICount(pod *corev1.Pod) int {
+	val, found := pod.Annotations[podENIResourceName]
+	if !found {
+		return 0
+	}
+	var podENIData []PodENIData
+	if err := json.Unmarshal([]byte(val), &podENIData); err != nil || len(podENIData) == 0 {
+		// The annotation is only set once a branch ENI is attached, so count it even if it can't be parsed
+		return 1
+	}
+	return len(podENIData)
+}
+
+// reportTrunkFull raises a warning event on the node the first time a pod can't get a branch ENI because the trunk
+// ENI is full, until syncTrunkBranchENIs finds a free slot again
+func (c *IPAMContext) reportTrunkFull(podName, namespace string, stats datastore.TrunkStats) {
+	c.trunkFullLock.Lock()
+	alreadyFull := c.trunkFull
+	c.trunkFull = true
+	c.trunkFullLock.Unlock()
+	if alreadyFull {
+		return
+	}
+	message := fmt.Sprintf("Trunk ENI has no free branch ENI slot (%d of %d in use), cannot give pod %s/%s a pod ENI",
+		stats.Used, stats.Capacity, namespace, podName)
+	log.Warn(message)
+	eventrecorder.Get().SendNodeEvent(corev1.EventTypeWarning, trunkFullReason, message)
+}
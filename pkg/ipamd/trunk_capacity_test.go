@@ -0,0 +1,119 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/eventrecorder"
+	pb "github.com/aws/amazon-vpc-cni-k8s/rpc"
+)
+
+func TestPodBranchENICount(t *testing.T) {
+	assert.Equal(t, 0, podBranchENICount(&corev1.Pod{}))
+	assert.Equal(t, 2, podBranchENICount(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+		podENIResourceName: `[{"eniId":"eni-1","vlanId":1},{"eniId":"eni-2","vlanId":2}]`}}}))
+	assert.Equal(t, 1, podBranchENICount(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+		podENIResourceName: "garbage"}}}))
+}
+
+func TestTrunkCapacityGating(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+	ctx := context.Background()
+	fakeRecorder := eventrecorder.InitMockEventRecorder(m.cachedK8SClient)
+
+	assert.NoError(t, m.cachedK8SClient.Create(ctx, &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: myNodeName},
+		Status: corev1.NodeStatus{Allocatable: corev1.ResourceList{
+			podENIResourceName: resource.MustParse("2"),
+		}},
+	}))
+	podENILimits := corev1.ResourceRequirements{Limits: corev1.ResourceList{podENIResourceName: resource.MustParse("1")}}
+	pods := []*corev1.Pod{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "default", Annotations: map[string]string{
+				podENIResourceName: `[{"eniId":"eni-b1","ifAddress":"0e:00:00:00:00:01","privateIp":"10.0.1.10","vlanId":1,"subnetCidr":"10.0.1.0/24"},` +
+					`{"eniId":"eni-b2","ifAddress":"0e:00:00:00:00:02","privateIp":"10.0.1.11","vlanId":2,"subnetCidr":"10.0.1.0/24"}]`}},
+			Spec: corev1.PodSpec{NodeName: myNodeName, Containers: []corev1.Container{{Name: "c", Resources: podENILimits}}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod-2", Namespace: "default"},
+			Spec:       corev1.PodSpec{NodeName: myNodeName, Containers: []corev1.Container{{Name: "c", Resources: podENILimits}}},
+		},
+	}
+	for _, pod := range pods {
+		assert.NoError(t, m.rawK8SClient.Create(ctx, pod))
+	}
+
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI(primaryENIid, 0, true, false, false))
+	assert.NoError(t, ds.AddENI(secENIid, 1, false, true, false))
+	mockContext := &IPAMContext{
+		awsClient:       m.awsutils,
+		networkClient:   m.network,
+		rawK8SClient:    m.rawK8SClient,
+		cachedK8SClient: m.cachedK8SClient,
+		dataStore:       ds,
+		enableIPv4:      true,
+		enablePodENI:    true,
+		myNodeName:      myNodeName,
+	}
+	mockContext.syncTrunkBranchENIs(ctx)
+	assert.Equal(t, datastore.TrunkStats{Capacity: 2, Used: 2}, ds.GetTrunkStats())
+
+	// pod-2 is still waiting for its branch ENI, but there is no slot left for it
+	m.awsutils.EXPECT().GetAttachedENIs().Return([]awsutils.ENIMetadata{{ENIID: secENIid, MAC: secMAC}}, nil)
+	m.network.EXPECT().GetLinkByMac(secMAC, gomock.Any()).Return(&netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Index: 3}}, nil)
+	s := &server{version: "1.2.3", ipamContext: mockContext}
+	_, err := s.AddNetwork(ctx, &pb.AddNetworkRequest{
+		ClientVersion:     "1.2.3",
+		K8S_POD_NAME:      "pod-2",
+		K8S_POD_NAMESPACE: "default",
+		ContainerID:       "cid-2",
+		IfName:            "eth0",
+		NetworkName:       "aws-cni",
+	})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.True(t, mockContext.trunkFull)
+	assert.Len(t, fakeRecorder.Events, 1)
+	assert.Contains(t, <-fakeRecorder.Events, "Warning "+trunkFullReason)
+
+	// Deleting pod-1 frees its slots
+	_, err = s.DelNetwork(ctx, &pb.DelNetworkRequest{
+		ClientVersion:     "1.2.3",
+		K8S_POD_NAME:      "pod-1",
+		K8S_POD_NAMESPACE: "default",
+		ContainerID:       "cid-1",
+		IfName:            "eth0",
+		NetworkName:       "aws-cni",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, datastore.TrunkStats{Capacity: 2, Used: 0}, ds.GetTrunkStats())
+	assert.NoError(t, m.rawK8SClient.Delete(ctx, pods[0]))
+	mockContext.syncTrunkBranchENIs(ctx)
+	assert.False(t, mockContext.trunkFull)
+}
@@ -0,0 +1,129 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils"
+)
+
+// delUnassignBatcher coalesces the EC2 unassign calls triggered by DelNetwork, so that a mass pod deletion doesn't
+// make one call per pod. The ENIs that released an IP are collected and flushed together, once the batch interval
+// since the first queued release has passed, or as soon as batchSize releases are queued.
+type delUnassignBatcher struct {
+	lock       sync.Mutex
+	interval   time.Duration
+	batchSize  int
+	releases   int
+	ipENIs     map[string]struct{} // ipENIs are the ENIs to unassign freeable secondary IPs from
+	prefixENIs map[string]struct{} // prefixENIs are the ENIs to unassign freeable prefixes from
+	timer      *time.Timer
+
+	flushLock sync.Mutex // flushLock serializes the flushes, so that two batches don't free the same ENI at once
+	flush     func(ipENIs, prefixENIs []string)
+}
+
+func newDelUnassignBatcher(interval time.Duration, batchSize int, flush func(ipENIs, prefixENIs []string)) *delUnassignBatcher {
+	return &delUnassignBatcher{
+		interval:   interval,
+		batchSize:  batchSize,
+		ipENIs:     make(map[string]struct{}),
+		prefixENIs: make(map[string]struct{}),
+		flush:      flush,
+	}
+}
+
+// queue records that an IP of eniID was released, from a prefix if prefix is set
+func (b *delUnassignBatcher) queue(eniID string, prefix bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if prefix {
+		b.prefixENIs[eniID] = struct{}{}
+	} else {
+		b.ipENIs[eniID] = struct{}{}
+	}
+	b.releases++
+	if b.batchSize > 0 && b.releases >= b.batchSize {
+		go b.run()
+		return
+	}
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.interval, b.run)
+	}
+}
+
+// run flushes the queued ENIs, if any
+func (b *delUnassignBatcher) run() {
+	b.flushLock.Lock()
+	defer b.flushLock.Unlock()
+	ipENIs, prefixENIs, releases := b.take()
+	if releases == 0 {
+		return
+	}
+	log.Debugf("Unassigning the IPs released by %d pod deletions from ENIs %v and prefixes from ENIs %v", releases, ipENIs, prefixENIs)
+	b.flush(ipENIs, prefixENIs)
+}
+
+// take empties the batch and returns its ENIs and number of releases
+func (b *delUnassignBatcher) take() ([]string, []string, int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	ipENIs, prefixENIs, releases := sortedKeys(b.ipENIs), sortedKeys(b.prefixENIs), b.releases
+	b.ipENIs = make(map[string]struct{})
+	b.prefixENIs = make(map[string]struct{})
+	b.releases = 0
+	return ipENIs, prefixENIs, releases
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// unassignReleasedCidrs unassigns the freeable IPs, or prefixes if prefix is set, of an ENI that DelNetwork released
+// an IP of. The calls are batched with those of the other pod deletions, unless batching is disabled.
+func (c *IPAMContext) unassignReleasedCidrs(eniID string, prefix bool) {
+	if c.delUnassignBatcher == nil {
+		if prefix {
+			c.flushReleasedCidrs(nil, []string{eniID})
+		} else {
+			c.flushReleasedCidrs([]string{eniID}, nil)
+		}
+		return
+	}
+	c.delUnassignBatcher.queue(eniID, prefix)
+}
+
+// flushReleasedCidrs unassigns the freeable secondary IPs of ipENIs and the freeable prefixes of prefixENIs from EC2
+func (c *IPAMContext) flushReleasedCidrs(ipENIs, prefixENIs []string) {
+	ctx := awsutils.WithCaller(context.Background(), awsutils.CallerScaleDown)
+	for _, eniID := range ipENIs {
+		c.tryUnassignIPFromENI(ctx, eniID)
+	}
+	for _, eniID := range prefixENIs {
+		c.tryUnassignPrefixFromENI(ctx, eniID)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+)
+
+type unassignBatch struct {
+	ipENIs, prefixENIs []string
+}
+
+func newTestBatcher(interval time.Duration, batchSize int) (*delUnassignBatcher, chan unassignBatch) {
+	batches := make(chan unassignBatch, 10)
+	return newDelUnassignBatcher(interval, batchSize, func(ipENIs, prefixENIs []string) {
+		batches <- unassignBatch{ipENIs, prefixENIs}
+	}), batches
+}
+
+func nextBatch(t *testing.T, batches chan unassignBatch) unassignBatch {
+	select {
+	case batch := <-batches:
+		return batch
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an unassign batch")
+		return unassignBatch{}
+	}
+}
+
+func TestDelUnassignBatcherInterval(t *testing.T) {
+	b, batches := newTestBatcher(50*time.Millisecond, 0)
+	b.queue("eni-2", false)
+	b.queue("eni-1", false)
+	b.queue("eni-2", false)
+	b.queue("eni-3", true)
+	assert.Equal(t, unassignBatch{[]string{"eni-1", "eni-2"}, []string{"eni-3"}}, nextBatch(t, batches))
+
+	// A new release starts a new batch
+	b.queue("eni-1", false)
+	assert.Equal(t, unassignBatch{[]string{"eni-1"}, []string{}}, nextBatch(t, batches))
+}
+
+func TestDelUnassignBatcherSize(t *testing.T) {
+	b, batches := newTestBatcher(time.Hour, 3)
+	b.queue("eni-1", false)
+	b.queue("eni-1", false)
+	select {
+	case <-batches:
+		t.Fatal("batch flushed before reaching its size")
+	case <-time.After(50 * time.Millisecond):
+	}
+	b.queue("eni-2", false)
+	assert.Equal(t, unassignBatch{[]string{"eni-1", "eni-2"}, []string{}}, nextBatch(t, batches))
+}
+
+func TestUnassignReleasedCidrsUnbatched(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP("10.0.0.1"), Mask: net.CIDRMask(32, 32)}, false))
+	mockContext := &IPAMContext{awsClient: m.awsutils, dataStore: ds}
+
+	m.awsutils.EXPECT().DeallocIPAddresses(gomock.Any(), "eni-1", []string{"10.0.0.1"}).Return(nil)
+	mockContext.unassignReleasedCidrs("eni-1", false)
+	assert.Equal(t, 0, ds.GetIPStats(ipV4AddrFamily).TotalIPs)
+}
+
+func TestGetDelUnassignBatchInterval(t *testing.T) {
+	defer os.Unsetenv(envDelUnassignBatchInterval)
+
+	// Deletions are not batched unless the interval is set
+	_ = os.Unsetenv(envDelUnassignBatchInterval)
+	assert.Equal(t, time.Duration(0), getDelUnassignBatchInterval())
+
+	_ = os.Setenv(envDelUnassignBatchInterval, "5")
+	assert.Equal(t, 5*time.Second, getDelUnassignBatchInterval())
+
+	_ = os.Setenv(envDelUnassignBatchInterval, "-1")
+	assert.Equal(t, time.Duration(0), getDelUnassignBatchInterval())
+}
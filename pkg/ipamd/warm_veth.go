@@ -0,0 +1,167 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/networkutils"
+)
+
+// warmVethClaimGracePeriod is how long the host side of a claimed warm veth pair may keep its warm name, until the CNI
+// plugin renames it, before it is deleted as an orphan
+const warmVethClaimGracePeriod = time.Minute
+
+// claimWarmVeth hands a veth pair pre-plumbed by replenishWarmVeths, and its IP, over to the sandbox, and returns the
+// name of its host side. It returns an empty string when no pre-plumbed veth pair is left, or the sandbox already has
+// an IP, and the pod then gets a new veth pair from the CNI plugin.
+func (c *IPAMContext) claimWarmVeth(ipamKey datastore.IPAMKey, ipamMetadata datastore.IPAMMetadata) string {
+	if c.warmVethPoolSize == 0 {
+		return ""
+	}
+	c.warmVethLock.Lock()
+	defer c.warmVethLock.Unlock()
+
+	hostVeth, addresses, err := c.dataStore.ClaimWarmVethIPAddress(ipamKey, ipamMetadata)
+	if errors.Is(err, datastore.ErrUnknownPod) {
+		warmVethClaims.With(prometheus.Labels{"result": "miss"}).Inc()
+		return ""
+	}
+	if err != nil {
+		log.Debugf("Pod %s/%s takes no warm veth: %v", ipamMetadata.K8SPodNamespace, ipamMetadata.K8SPodName, err)
+		return ""
+	}
+	warmVethClaims.With(prometheus.Labels{"result": "hit"}).Inc()
+	c.warmVethClaimedAt[hostVeth] = time.Now()
+	log.Infof("Pod %s/%s took warm veth %s with IP %s", ipamMetadata.K8SPodNamespace, ipamMetadata.K8SPodName,
+		hostVeth, addresses.IPv4)
+	return hostVeth
+}
+
+// replenishWarmVeths keeps c.warmVethPoolSize veth pairs pre-plumbed for free IPs. Each IP is assigned in the datastore
+// under the name of the host side of its veth pair, see datastore.WarmVethIPAMKey, until a pod claims it. Veth pairs
+// that lost their IP, e.g. because the CNI plugin failed to set up the pod that claimed them, are deleted, and IPs
+// that lost their veth pair, e.g. after a reboot, are released, as are the ones above the pool size.
+func (c *IPAMContext) replenishWarmVeths() {
+	c.warmVethLock.Lock()
+	warmIPs := make(map[string]datastore.PodIPInfo)
+	for _, info := range c.dataStore.AllocatedIPs() {
+		if info.IPAMKey == datastore.WarmVethIPAMKey(info.IPAMKey.ContainerID) {
+			warmIPs[info.IPAMKey.ContainerID] = info
+		}
+	}
+	claimed := make(map[string]bool, len(c.warmVethClaimedAt))
+	for hostVeth, claimedAt := range c.warmVethClaimedAt {
+		if time.Since(claimedAt) > warmVethClaimGracePeriod {
+			delete(c.warmVethClaimedAt, hostVeth)
+			continue
+		}
+		claimed[hostVeth] = true
+	}
+	c.warmVethLock.Unlock()
+	if c.warmVethPoolSize == 0 && len(warmIPs) == 0 {
+		return
+	}
+
+	links, err := c.networkClient.ListWarmVeths()
+	if err != nil {
+		log.Warnf("Failed to list the warm veths: %v", err)
+		ipamdErrInc("replenishWarmVeths")
+		return
+	}
+	inUse := make(map[string]bool, len(links))
+	for _, hostVeth := range links {
+		if _, warm := warmIPs[hostVeth]; warm {
+			if len(warmIPs) > c.warmVethPoolSize && c.releaseWarmVethIP(hostVeth) {
+				delete(warmIPs, hostVeth)
+				c.deleteWarmVeth(hostVeth)
+				continue
+			}
+		} else if !claimed[hostVeth] {
+			c.deleteWarmVeth(hostVeth)
+			continue
+		}
+		inUse[hostVeth] = true
+	}
+	for hostVeth := range warmIPs {
+		if !inUse[hostVeth] {
+			c.releaseWarmVethIP(hostVeth)
+			delete(warmIPs, hostVeth)
+		}
+	}
+
+	for index := 0; len(warmIPs) < c.warmVethPoolSize; index++ {
+		hostVeth := networkutils.WarmVethName(index)
+		if inUse[hostVeth] {
+			continue
+		}
+		info, err := c.setupWarmVeth(hostVeth)
+		if err != nil {
+			if !errors.Is(err, datastore.ErrNoAvailableIPs) {
+				log.Warnf("Failed to set up warm veth %s: %v", hostVeth, err)
+				ipamdErrInc("replenishWarmVeths")
+			}
+			return
+		}
+		warmIPs[hostVeth] = info
+		inUse[hostVeth] = true
+	}
+}
+
+// setupWarmVeth assigns a free IP to a new warm veth pair and plumbs it. No pod can claim the veth pair before it is
+// plumbed.
+func (c *IPAMContext) setupWarmVeth(hostVeth string) (datastore.PodIPInfo, error) {
+	c.warmVethLock.Lock()
+	defer c.warmVethLock.Unlock()
+
+	ipamKey := datastore.WarmVethIPAMKey(hostVeth)
+	addresses, err := c.dataStore.AssignPodIPAddress(ipamKey, datastore.IPAMMetadata{}, datastore.FamilyIPv4)
+	if err != nil {
+		return datastore.PodIPInfo{}, err
+	}
+	ipNet := &net.IPNet{IP: net.ParseIP(addresses.IPv4), Mask: net.CIDRMask(32, 32)}
+	if err := c.networkClient.SetupWarmVeth(hostVeth, ipNet, addresses.DeviceNumber); err != nil {
+		c.deleteWarmVeth(hostVeth)
+		c.releaseWarmVethIP(hostVeth)
+		return datastore.PodIPInfo{}, errors.Wrapf(err, "failed to plumb IP %s", addresses.IPv4)
+	}
+	log.Debugf("Set up warm veth %s for IP %s", hostVeth, addresses.IPv4)
+	return datastore.PodIPInfo{IPAMKey: ipamKey, IP: addresses.IPv4, DeviceNumber: addresses.DeviceNumber}, nil
+}
+
+func (c *IPAMContext) deleteWarmVeth(hostVeth string) {
+	if err := c.networkClient.DeleteWarmVeth(hostVeth); err != nil {
+		log.Warnf("Failed to delete warm veth %s: %v", hostVeth, err)
+		return
+	}
+	log.Debugf("Deleted warm veth %s", hostVeth)
+}
+
+// releaseWarmVethIP releases the IP of a warm veth pair and returns true, unless a pod claimed it in the meantime
+func (c *IPAMContext) releaseWarmVethIP(hostVeth string) bool {
+	_, ip, _, err := c.dataStore.UnassignPodIPAddress(datastore.WarmVethIPAMKey(hostVeth))
+	if err != nil {
+		if !errors.Is(err, datastore.ErrUnknownPod) {
+			log.Warnf("Failed to release the IP of warm veth %s: %v", hostVeth, err)
+		}
+		return false
+	}
+	log.Debugf("Released IP %s of warm veth %s", ip, hostVeth)
+	return true
+}
@@ -0,0 +1,117 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipamd
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipamd/datastore"
+)
+
+func TestReplenishWarmVeths(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 1, false, false, false))
+	for _, ip := range []string{"10.0.0.5", "10.0.0.6", "10.0.0.7"} {
+		assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)}, false))
+	}
+	mockContext := &IPAMContext{
+		networkClient:     m.network,
+		dataStore:         ds,
+		warmVethPoolSize:  2,
+		warmVethClaimedAt: make(map[string]time.Time),
+	}
+
+	// The pool is filled, skipping the name of a veth pair that a pod claimed but didn't rename yet
+	mockContext.warmVethClaimedAt["warmveth0"] = time.Now()
+	m.network.EXPECT().ListWarmVeths().Return([]string{"warmveth0"}, nil)
+	m.network.EXPECT().SetupWarmVeth("warmveth1", gomock.Any(), 1).Return(nil)
+	m.network.EXPECT().SetupWarmVeth("warmveth2", gomock.Any(), 1).Return(nil)
+	mockContext.replenishWarmVeths()
+	assert.Equal(t, 2, countWarmVeths(ds))
+
+	// A pod takes one, and the pool is filled up to the last free IP. The claimed veth pair was renamed, and the
+	// one of a failed pod setup is deleted once its claim expired.
+	key := datastore.IPAMKey{NetworkName: "aws-cni", ContainerID: "cid-1", IfName: "eth0"}
+	metadata := datastore.IPAMMetadata{K8SPodNamespace: "default", K8SPodName: "pod-1"}
+	hostVeth := mockContext.claimWarmVeth(key, metadata)
+	assert.Contains(t, []string{"warmveth1", "warmveth2"}, hostVeth)
+	addresses, err := ds.AssignPodIPAddress(key, metadata, datastore.FamilyIPv4)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, addresses.DeviceNumber)
+	mockContext.warmVethClaimedAt["warmveth0"] = time.Now().Add(-2 * warmVethClaimGracePeriod)
+	remaining := "warmveth1"
+	if hostVeth == remaining {
+		remaining = "warmveth2"
+	}
+	m.network.EXPECT().ListWarmVeths().Return([]string{"warmveth0", remaining}, nil)
+	m.network.EXPECT().DeleteWarmVeth("warmveth0").Return(nil)
+	m.network.EXPECT().SetupWarmVeth("warmveth0", gomock.Any(), 1).Return(nil)
+	mockContext.replenishWarmVeths()
+	assert.Equal(t, 2, countWarmVeths(ds))
+
+	// No pre-plumbed veth pair is left for the next pod, and one that lost its link gives its IP back, which
+	// can't be plumbed again before its cooldown
+	assert.Equal(t, "", mockContext.claimWarmVeth(key, metadata))
+	m.network.EXPECT().ListWarmVeths().Return([]string{"warmveth0"}, nil)
+	mockContext.replenishWarmVeths()
+	assert.Equal(t, 1, countWarmVeths(ds))
+
+	// Disabling the pool releases the remaining veth pair
+	mockContext.warmVethPoolSize = 0
+	m.network.EXPECT().ListWarmVeths().Return([]string{"warmveth0"}, nil)
+	m.network.EXPECT().DeleteWarmVeth("warmveth0").Return(nil)
+	mockContext.replenishWarmVeths()
+	assert.Equal(t, 0, countWarmVeths(ds))
+	mockContext.replenishWarmVeths()
+}
+
+func TestReplenishWarmVethsSetupFailure(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	ds := datastore.NewDataStore(log, datastore.NullCheckpoint{}, false)
+	assert.NoError(t, ds.AddENI("eni-1", 0, true, false, false))
+	assert.NoError(t, ds.AddIPv4CidrToStore("eni-1", net.IPNet{IP: net.ParseIP("10.0.0.5"), Mask: net.CIDRMask(32, 32)}, false))
+	mockContext := &IPAMContext{
+		networkClient:     m.network,
+		dataStore:         ds,
+		warmVethPoolSize:  1,
+		warmVethClaimedAt: make(map[string]time.Time),
+	}
+
+	m.network.EXPECT().ListWarmVeths().Return(nil, nil)
+	m.network.EXPECT().SetupWarmVeth("warmveth0", gomock.Any(), 0).Return(errors.New("fake error"))
+	m.network.EXPECT().DeleteWarmVeth("warmveth0").Return(nil)
+	mockContext.replenishWarmVeths()
+	assert.Equal(t, 0, countWarmVeths(ds))
+}
+
+func countWarmVeths(ds *datastore.DataStore) int {
+	count := 0
+	for _, info := range ds.AllocatedIPs() {
+		if info.IPAMKey == datastore.WarmVethIPAMKey(info.IPAMKey.ContainerID) {
+			count++
+		}
+	}
+	return count
+}
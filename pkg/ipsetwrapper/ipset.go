@@ -0,0 +1,92 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package ipsetwrapper is a wrapper around the ipset command
+package ipsetwrapper
+
+import (
+	"bufio"
+	"bytes"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// IPSet is the ipset command wrapper
+type IPSet interface {
+	// Create is equivalent to `ipset create -exist $name $setType`
+	Create(name, setType string) error
+	// List returns the entries of a set, as printed by `ipset save $name`
+	List(name string) ([]string, error)
+	// Add is equivalent to `ipset add -exist $name $entry`
+	Add(name, entry string) error
+	// Del is equivalent to `ipset del -exist $name $entry`
+	Del(name, entry string) error
+}
+
+type ipSet struct {
+	path string
+}
+
+// NewIPSet returns a new IPSet
+func NewIPSet() IPSet {
+	return &ipSet{path: "ipset"}
+}
+
+func (s *ipSet) run(args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command(s.path, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s %s: %s", s.path, strings.Join(args, " "), strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+func (s *ipSet) Create(name, setType string) error {
+	_, err := s.run("create", "-exist", name, setType)
+	return err
+}
+
+func (s *ipSet) List(name string) ([]string, error) {
+	out, err := s.run("save", name)
+	if err != nil {
+		return nil, err
+	}
+	return parseSave(name, out), nil
+}
+
+// parseSave returns the entries of the add lines of `ipset save` output
+func parseSave(name string, out []byte) []string {
+	var entries []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 3 && fields[0] == "add" && fields[1] == name {
+			entries = append(entries, fields[2])
+		}
+	}
+	return entries
+}
+
+func (s *ipSet) Add(name, entry string) error {
+	_, err := s.run("add", "-exist", name, entry)
+	return err
+}
+
+func (s *ipSet) Del(name, entry string) error {
+	_, err := s.run("del", "-exist", name, entry)
+	return err
+}
@@ -0,0 +1,37 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ipsetwrapper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSave(t *testing.T) {
+	out := []byte(`create AWS-SNAT-CIDRS hash:net family inet hashsize 1024 maxelem 65536
+add AWS-SNAT-CIDRS 10.1.0.0/16
+add AWS-SNAT-CIDRS 192.168.1.1
+add OTHER 172.16.0.0/12
+`)
+	assert.Equal(t, []string{"10.1.0.0/16", "192.168.1.1"}, parseSave("AWS-SNAT-CIDRS", out))
+	assert.Nil(t, parseSave("AWS-SNAT-CIDRS", nil))
+}
+
+func TestRunError(t *testing.T) {
+	s := &ipSet{path: "false"}
+	_, err := s.List("AWS-SNAT-CIDRS")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "false save AWS-SNAT-CIDRS")
+}
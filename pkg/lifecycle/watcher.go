@@ -0,0 +1,124 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package lifecycle tells whether the node is being taken out of service, from its cordon and drain taints and the
+// lifecycle state its Auto Scaling group is moving the instance to
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
+)
+
+// InService is the target lifecycle state of an instance the Auto Scaling group keeps running
+const InService = "InService"
+
+var log = logger.Get()
+
+// drainTaintKeys are the taints that node drainers and autoscalers put on a node they are about to empty
+var drainTaintKeys = []string{
+	"node.kubernetes.io/unschedulable",
+	"ToBeDeletedByClusterAutoscaler",
+	"karpenter.sh/disruption",
+}
+
+// drainTaintKeyPrefixes are the prefixes of the drain taints whose key depends on the event, like the ones of
+// aws-node-termination-handler
+var drainTaintKeyPrefixes = []string{
+	"aws-node-termination-handler/",
+}
+
+// NodeDrainReason returns why the node is being emptied, or "" if it isn't cordoned or tainted by a drainer
+func NodeDrainReason(node *corev1.Node) string {
+	if node.Spec.Unschedulable {
+		return "node is cordoned"
+	}
+	for _, taint := range node.Spec.Taints {
+		for _, key := range drainTaintKeys {
+			if taint.Key == key {
+				return fmt.Sprintf("node has the drain taint %s", taint.Key)
+			}
+		}
+		for _, prefix := range drainTaintKeyPrefixes {
+			if strings.HasPrefix(taint.Key, prefix) {
+				return fmt.Sprintf("node has the drain taint %s", taint.Key)
+			}
+		}
+	}
+	return ""
+}
+
+// TargetLifecycleStateGetter returns the lifecycle state the Auto Scaling group is moving the instance to, or "" if
+// the instance isn't in an Auto Scaling group
+type TargetLifecycleStateGetter interface {
+	GetTargetLifecycleState(ctx context.Context) (string, error)
+}
+
+// Watcher polls the target lifecycle state of the instance
+type Watcher struct {
+	getter   TargetLifecycleStateGetter
+	interval time.Duration
+
+	lock  sync.RWMutex
+	state string
+}
+
+// NewWatcher returns a Watcher polling getter every interval once it runs
+func NewWatcher(getter TargetLifecycleStateGetter, interval time.Duration) *Watcher {
+	return &Watcher{getter: getter, interval: interval}
+}
+
+// Run polls the target lifecycle state until ctx is done
+func (w *Watcher) Run(ctx context.Context) {
+	for {
+		w.Refresh(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.interval):
+		}
+	}
+}
+
+// Refresh reads the target lifecycle state. The last known state is kept if it can't be read.
+func (w *Watcher) Refresh(ctx context.Context) {
+	state, err := w.getter.GetTargetLifecycleState(ctx)
+	if err != nil {
+		log.Warnf("Failed to get the target lifecycle state of the instance: %v", err)
+		return
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if state != w.state {
+		log.Infof("Target lifecycle state of the instance changed from %q to %q", w.state, state)
+	}
+	w.state = state
+}
+
+// ScalingReason returns why the Auto Scaling group is taking the instance out of service, or "" if the instance is
+// in service, not in an Auto Scaling group or its state is not known yet
+func (w *Watcher) ScalingReason() string {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	if w.state == "" || w.state == InService {
+		return ""
+	}
+	return fmt.Sprintf("instance target lifecycle state is %s", w.state)
+}
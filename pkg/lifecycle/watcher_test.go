@@ -0,0 +1,74 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestNodeDrainReason(t *testing.T) {
+	node := &corev1.Node{}
+	assert.Equal(t, "", NodeDrainReason(node))
+
+	node.Spec.Taints = []corev1.Taint{{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule}}
+	assert.Equal(t, "", NodeDrainReason(node))
+
+	node.Spec.Unschedulable = true
+	assert.Equal(t, "node is cordoned", NodeDrainReason(node))
+
+	node.Spec.Unschedulable = false
+	node.Spec.Taints = append(node.Spec.Taints, corev1.Taint{Key: "ToBeDeletedByClusterAutoscaler", Effect: corev1.TaintEffectNoSchedule})
+	assert.Equal(t, "node has the drain taint ToBeDeletedByClusterAutoscaler", NodeDrainReason(node))
+
+	node.Spec.Taints = []corev1.Taint{{Key: "aws-node-termination-handler/asg-lifecycle-termination", Effect: corev1.TaintEffectNoExecute}}
+	assert.Equal(t, "node has the drain taint aws-node-termination-handler/asg-lifecycle-termination", NodeDrainReason(node))
+}
+
+type fakeGetter struct {
+	state string
+	err   error
+}
+
+func (f *fakeGetter) GetTargetLifecycleState(ctx context.Context) (string, error) {
+	return f.state, f.err
+}
+
+func TestWatcher(t *testing.T) {
+	getter := &fakeGetter{}
+	w := NewWatcher(getter, 0)
+	assert.Equal(t, "", w.ScalingReason())
+
+	getter.state = InService
+	w.Refresh(context.Background())
+	assert.Equal(t, "", w.ScalingReason())
+
+	getter.state = "Terminated"
+	w.Refresh(context.Background())
+	assert.Equal(t, "instance target lifecycle state is Terminated", w.ScalingReason())
+
+	// The last known state is kept
+	getter.err = errors.New("IMDS unreachable")
+	w.Refresh(context.Background())
+	assert.Equal(t, "instance target lifecycle state is Terminated", w.ScalingReason())
+
+	// Not in an Auto Scaling group
+	getter.state, getter.err = "", nil
+	w.Refresh(context.Background())
+	assert.Equal(t, "", w.ScalingReason())
+}
@@ -0,0 +1,111 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package maxpods computes the kubelet --max-pods value that matches the number of pod IP addresses the VPC CNI can
+// hand out on an instance, so that bootstrap scripts and node provisioners don't have to duplicate the math.
+package maxpods
+
+import (
+	"fmt"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils"
+)
+
+const (
+	// hostNetworkPods are the pods using host networking that run on every node (aws-node and kube-proxy)
+	hostNetworkPods = 2
+	// ipsPerPrefix is the number of IPv4 addresses in a /28 prefix
+	ipsPerPrefix = 16
+
+	// With prefix delegation and IPv6 the IP addresses are no longer the limit, so max pods is capped at the values
+	// recommended by EKS: 110 for instances with fewer than largeInstanceCPUs vCPUs, 250 otherwise.
+	smallInstanceMaxPods = 110
+	largeInstanceMaxPods = 250
+	largeInstanceCPUs    = 30
+)
+
+// Options describe the instance and the CNI configuration
+type Options struct {
+	// ENILimit and IPv4Limit are the maximum number of ENIs and IPv4 addresses per ENI of the instance type
+	ENILimit  int
+	IPv4Limit int
+	// CPUs is the number of vCPUs of the instance, used to cap max pods with prefix delegation and IPv6. When 0, the
+	// instance is assumed to be small.
+	CPUs int
+	// MaxENI is the MAX_ENI setting, ignored when 0 or above ENILimit
+	MaxENI int
+	// PrefixDelegation is the ENABLE_PREFIX_DELEGATION setting
+	PrefixDelegation bool
+	// CustomNetworking is the AWS_VPC_K8S_CNI_CUSTOM_NETWORK_CFG setting, which keeps pods off the primary ENI
+	CustomNetworking bool
+	// IPv6 is the ENABLE_IPv6 setting
+	IPv6 bool
+}
+
+// Calculate returns the max pods for the instance
+func Calculate(opts Options) int {
+	maxPodsCap := smallInstanceMaxPods
+	if opts.CPUs >= largeInstanceCPUs {
+		maxPodsCap = largeInstanceMaxPods
+	}
+	if opts.IPv6 {
+		return maxPodsCap
+	}
+
+	enis := opts.ENILimit
+	if opts.MaxENI > 0 && opts.MaxENI < enis {
+		enis = opts.MaxENI
+	}
+	if opts.CustomNetworking {
+		enis--
+	}
+	if enis <= 0 || opts.IPv4Limit <= 1 {
+		return hostNetworkPods
+	}
+
+	// The primary IP address of each ENI is not used for pods
+	ipsPerENI := opts.IPv4Limit - 1
+	if !opts.PrefixDelegation {
+		return enis*ipsPerENI + hostNetworkPods
+	}
+	maxPods := enis*ipsPerENI*ipsPerPrefix + hostNetworkPods
+	if maxPods > maxPodsCap {
+		maxPods = maxPodsCap
+	}
+	return maxPods
+}
+
+// LimitsLookup returns the ENI and IP limits of an instance type
+type LimitsLookup func(instanceType string) (awsutils.InstanceTypeLimits, error)
+
+// VendoredLimits looks up the limits of an instance type in the table vendored with the CNI, for callers that can't
+// call EC2
+func VendoredLimits(instanceType string) (awsutils.InstanceTypeLimits, error) {
+	limits, ok := awsutils.InstanceNetworkingLimits[instanceType]
+	if !ok {
+		return awsutils.InstanceTypeLimits{}, fmt.Errorf("%s: %s", awsutils.UnknownInstanceType, instanceType)
+	}
+	return limits, nil
+}
+
+// ForInstanceType returns the max pods for an instance type, and the limits found for it by lookup. The ENILimit and
+// IPv4Limit in opts are ignored.
+func ForInstanceType(instanceType string, opts Options, lookup LimitsLookup) (int, awsutils.InstanceTypeLimits, error) {
+	limits, err := lookup(instanceType)
+	if err != nil {
+		return 0, awsutils.InstanceTypeLimits{}, err
+	}
+	opts.ENILimit = limits.ENILimit
+	opts.IPv4Limit = limits.IPv4Limit
+	return Calculate(opts), limits, nil
+}
@@ -0,0 +1,68 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package maxpods
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils"
+)
+
+func TestCalculate(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want int
+	}{
+		{"secondary IPs", Options{ENILimit: 3, IPv4Limit: 10}, 29},
+		{"custom networking", Options{ENILimit: 3, IPv4Limit: 10, CustomNetworking: true}, 20},
+		{"MAX_ENI", Options{ENILimit: 3, IPv4Limit: 10, MaxENI: 1}, 11},
+		{"MAX_ENI above the limit", Options{ENILimit: 3, IPv4Limit: 10, MaxENI: 5}, 29},
+		{"prefix delegation on a small instance", Options{ENILimit: 3, IPv4Limit: 10, CPUs: 2, PrefixDelegation: true}, 110},
+		{"prefix delegation on a large instance", Options{ENILimit: 15, IPv4Limit: 50, CPUs: 96, PrefixDelegation: true}, 250},
+		{"prefix delegation below the cap", Options{ENILimit: 2, IPv4Limit: 2, PrefixDelegation: true}, 34},
+		{"IPv6", Options{ENILimit: 3, IPv4Limit: 10, CPUs: 48, IPv6: true}, 250},
+		{"custom networking with a single ENI", Options{ENILimit: 1, IPv4Limit: 10, CustomNetworking: true}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Calculate(tt.opts))
+		})
+	}
+}
+
+func TestForInstanceType(t *testing.T) {
+	// Matches misc/eni-max-pods.txt
+	maxPods, limits, err := ForInstanceType("m5.large", Options{}, VendoredLimits)
+	assert.NoError(t, err)
+	assert.Equal(t, 29, maxPods)
+	assert.Equal(t, 3, limits.ENILimit)
+
+	maxPods, _, err = ForInstanceType("m5.24xlarge", Options{}, VendoredLimits)
+	assert.NoError(t, err)
+	assert.Equal(t, 737, maxPods)
+
+	_, _, err = ForInstanceType("x9.unknown", Options{}, VendoredLimits)
+	assert.Error(t, err)
+
+	// An instance type missing from the vendored table, found by the lookup
+	lookup := func(instanceType string) (awsutils.InstanceTypeLimits, error) {
+		return awsutils.InstanceTypeLimits{ENILimit: 4, IPv4Limit: 15}, nil
+	}
+	maxPods, _, err = ForInstanceType("x9.unknown", Options{}, lookup)
+	assert.NoError(t, err)
+	assert.Equal(t, 58, maxPods)
+}
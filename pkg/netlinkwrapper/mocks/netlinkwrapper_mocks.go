@@ -19,6 +19,7 @@
 package mock_netlinkwrapper
 
 import (
+	net "net"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -91,6 +92,35 @@ func (mr *MockNetLinkMockRecorder) AddrList(arg0, arg1 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddrList", reflect.TypeOf((*MockNetLink)(nil).AddrList), arg0, arg1)
 }
 
+// ConntrackTableList mocks base method
+func (m *MockNetLink) ConntrackTableList(arg0 netlink.ConntrackTableType, arg1 netlink.InetFamily) ([]*netlink.ConntrackFlow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConntrackTableList", arg0, arg1)
+	ret0, _ := ret[0].([]*netlink.ConntrackFlow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConntrackTableList indicates an expected call of ConntrackTableList
+func (mr *MockNetLinkMockRecorder) ConntrackTableList(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConntrackTableList", reflect.TypeOf((*MockNetLink)(nil).ConntrackTableList), arg0, arg1)
+}
+
+// FilterAdd mocks base method
+func (m *MockNetLink) FilterAdd(arg0 netlink.Filter) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FilterAdd", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FilterAdd indicates an expected call of FilterAdd
+func (mr *MockNetLinkMockRecorder) FilterAdd(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FilterAdd", reflect.TypeOf((*MockNetLink)(nil).FilterAdd), arg0)
+}
+
 // LinkAdd mocks base method
 func (m *MockNetLink) LinkAdd(arg0 netlink.Link) error {
 	m.ctrl.T.Helper()
@@ -177,6 +207,48 @@ func (mr *MockNetLinkMockRecorder) LinkSetMTU(arg0, arg1 interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LinkSetMTU", reflect.TypeOf((*MockNetLink)(nil).LinkSetMTU), arg0, arg1)
 }
 
+// LinkSetMulticastOn mocks base method
+func (m *MockNetLink) LinkSetMulticastOn(arg0 netlink.Link) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LinkSetMulticastOn", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LinkSetMulticastOn indicates an expected call of LinkSetMulticastOn
+func (mr *MockNetLinkMockRecorder) LinkSetMulticastOn(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LinkSetMulticastOn", reflect.TypeOf((*MockNetLink)(nil).LinkSetMulticastOn), arg0)
+}
+
+// LinkSetName mocks base method
+func (m *MockNetLink) LinkSetName(arg0 netlink.Link, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LinkSetName", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LinkSetName indicates an expected call of LinkSetName
+func (mr *MockNetLinkMockRecorder) LinkSetName(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LinkSetName", reflect.TypeOf((*MockNetLink)(nil).LinkSetName), arg0, arg1)
+}
+
+// LinkSetNoMaster mocks base method
+func (m *MockNetLink) LinkSetNoMaster(arg0 netlink.Link) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LinkSetNoMaster", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LinkSetNoMaster indicates an expected call of LinkSetNoMaster
+func (mr *MockNetLinkMockRecorder) LinkSetNoMaster(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LinkSetNoMaster", reflect.TypeOf((*MockNetLink)(nil).LinkSetNoMaster), arg0)
+}
+
 // LinkSetNsFd mocks base method
 func (m *MockNetLink) LinkSetNsFd(arg0 netlink.Link, arg1 int) error {
 	m.ctrl.T.Helper()
@@ -248,6 +320,20 @@ func (mr *MockNetLinkMockRecorder) ParseAddr(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ParseAddr", reflect.TypeOf((*MockNetLink)(nil).ParseAddr), arg0)
 }
 
+// QdiscAdd mocks base method
+func (m *MockNetLink) QdiscAdd(arg0 netlink.Qdisc) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QdiscAdd", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// QdiscAdd indicates an expected call of QdiscAdd
+func (mr *MockNetLinkMockRecorder) QdiscAdd(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QdiscAdd", reflect.TypeOf((*MockNetLink)(nil).QdiscAdd), arg0)
+}
+
 // RouteAdd mocks base method
 func (m *MockNetLink) RouteAdd(arg0 *netlink.Route) error {
 	m.ctrl.T.Helper()
@@ -276,6 +362,21 @@ func (mr *MockNetLinkMockRecorder) RouteDel(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RouteDel", reflect.TypeOf((*MockNetLink)(nil).RouteDel), arg0)
 }
 
+// RouteGet mocks base method
+func (m *MockNetLink) RouteGet(arg0 net.IP) ([]netlink.Route, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RouteGet", arg0)
+	ret0, _ := ret[0].([]netlink.Route)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RouteGet indicates an expected call of RouteGet
+func (mr *MockNetLinkMockRecorder) RouteGet(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RouteGet", reflect.TypeOf((*MockNetLink)(nil).RouteGet), arg0)
+}
+
 // RouteList mocks base method
 func (m *MockNetLink) RouteList(arg0 netlink.Link, arg1 int) ([]netlink.Route, error) {
 	m.ctrl.T.Helper()
@@ -291,6 +392,21 @@ func (mr *MockNetLinkMockRecorder) RouteList(arg0, arg1 interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RouteList", reflect.TypeOf((*MockNetLink)(nil).RouteList), arg0, arg1)
 }
 
+// RouteListFiltered mocks base method
+func (m *MockNetLink) RouteListFiltered(arg0 int, arg1 *netlink.Route, arg2 uint64) ([]netlink.Route, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RouteListFiltered", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]netlink.Route)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RouteListFiltered indicates an expected call of RouteListFiltered
+func (mr *MockNetLinkMockRecorder) RouteListFiltered(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RouteListFiltered", reflect.TypeOf((*MockNetLink)(nil).RouteListFiltered), arg0, arg1, arg2)
+}
+
 // RouteReplace mocks base method
 func (m *MockNetLink) RouteReplace(arg0 *netlink.Route) error {
 	m.ctrl.T.Helper()
@@ -15,9 +15,12 @@
 package netlinkwrapper
 
 import (
+	"net"
 	"syscall"
 
 	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
 )
 
 // NetLink wraps methods used from the vishvananda/netlink package
@@ -44,6 +47,8 @@ type NetLink interface {
 	LinkSetDown(link netlink.Link) error
 	// RouteList gets a list of routes in the system.
 	RouteList(link netlink.Link, family int) ([]netlink.Route, error)
+	// RouteListFiltered gets the routes matching the fields of filter selected by filterMask, e.g. the routes of a table
+	RouteListFiltered(family int, filter *netlink.Route, filterMask uint64) ([]netlink.Route, error)
 	// RouteAdd will add a route to the route table
 	RouteAdd(route *netlink.Route) error
 	// RouteReplace will replace the route in the route table
@@ -64,6 +69,20 @@ type NetLink interface {
 	RuleList(family int) ([]netlink.Rule, error)
 	// LinkSetMTU is equivalent to `ip link set dev $link mtu $mtu`
 	LinkSetMTU(link netlink.Link, mtu int) error
+	// ConntrackTableList is equivalent to `conntrack -L $table -f $family`
+	ConntrackTableList(table netlink.ConntrackTableType, family netlink.InetFamily) ([]*netlink.ConntrackFlow, error)
+	// LinkSetName is equivalent to `ip link set dev $link name $name`
+	LinkSetName(link netlink.Link, name string) error
+	// LinkSetNoMaster is equivalent to `ip link set dev $link nomaster`
+	LinkSetNoMaster(link netlink.Link) error
+	// LinkSetMulticastOn is equivalent to `ip link set dev $link multicast on`
+	LinkSetMulticastOn(link netlink.Link) error
+	// RouteGet is equivalent to `ip route get $destination`
+	RouteGet(destination net.IP) ([]netlink.Route, error)
+	// QdiscAdd is equivalent to `tc qdisc add`
+	QdiscAdd(qdisc netlink.Qdisc) error
+	// FilterAdd is equivalent to `tc filter add`
+	FilterAdd(filter netlink.Filter) error
 }
 
 type netLink struct {
@@ -114,6 +133,10 @@ func (*netLink) RouteList(link netlink.Link, family int) ([]netlink.Route, error
 	return netlink.RouteList(link, family)
 }
 
+func (*netLink) RouteListFiltered(family int, filter *netlink.Route, filterMask uint64) ([]netlink.Route, error) {
+	return netlink.RouteListFiltered(family, filter, filterMask)
+}
+
 func (*netLink) RouteAdd(route *netlink.Route) error {
 	return netlink.RouteAdd(route)
 }
@@ -158,6 +181,42 @@ func (*netLink) LinkSetMTU(link netlink.Link, mtu int) error {
 	return netlink.LinkSetMTU(link, mtu)
 }
 
+func (*netLink) ConntrackTableList(table netlink.ConntrackTableType, family netlink.InetFamily) ([]*netlink.ConntrackFlow, error) {
+	return netlink.ConntrackTableList(table, family)
+}
+
+func (*netLink) LinkSetName(link netlink.Link, name string) error {
+	return netlink.LinkSetName(link, name)
+}
+
+func (*netLink) LinkSetNoMaster(link netlink.Link) error {
+	return netlink.LinkSetNoMaster(link)
+}
+
+// LinkSetMulticastOn sets IFF_MULTICAST on the link, which the vendored netlink package has no helper for
+func (*netLink) LinkSetMulticastOn(link netlink.Link) error {
+	req := nl.NewNetlinkRequest(unix.RTM_NEWLINK, unix.NLM_F_ACK)
+	msg := nl.NewIfInfomsg(unix.AF_UNSPEC)
+	msg.Change = unix.IFF_MULTICAST
+	msg.Flags = unix.IFF_MULTICAST
+	msg.Index = int32(link.Attrs().Index)
+	req.AddData(msg)
+	_, err := req.Execute(unix.NETLINK_ROUTE, 0)
+	return err
+}
+
+func (*netLink) RouteGet(destination net.IP) ([]netlink.Route, error) {
+	return netlink.RouteGet(destination)
+}
+
+func (*netLink) QdiscAdd(qdisc netlink.Qdisc) error {
+	return netlink.QdiscAdd(qdisc)
+}
+
+func (*netLink) FilterAdd(filter netlink.Filter) error {
+	return netlink.FilterAdd(filter)
+}
+
 // IsNotExistsError returns true if the error type is syscall.ESRCH
 // This helps us determine if we should ignore this error as the route
 // that we want to cleanup has been deleted already routing table
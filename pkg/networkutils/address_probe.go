@@ -0,0 +1,232 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package networkutils
+
+import (
+	"encoding/binary"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	arpPacketLen = 28
+	arpOpRequest = 1
+	arpOpReply   = 2
+
+	ipv6HeaderLen                = 40
+	icmpv6NeighborSolicitation   = 135
+	icmpv6NeighborAdvertisement  = 136
+	ndpTargetOffset              = 8 // after the ICMPv6 header and the reserved or flags field
+	ndpOptionsOffset             = 24
+	ndpOptionTargetLinkLayerAddr = 2
+)
+
+// ProbeAddress sends an ARP probe for an IPv4 address, or a neighbor solicitation for an IPv6 address, out of the link
+// that routes to ip, and returns the hardware address of the first neighbor outside of the node that answers for it
+// before timeout, or nil if none does
+func (n *linuxNetwork) ProbeAddress(ip net.IP, timeout time.Duration) (net.HardwareAddr, error) {
+	routes, err := n.netLink.RouteGet(ip)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get the route to %s", ip)
+	}
+	if len(routes) == 0 {
+		return nil, errors.Errorf("no route to %s", ip)
+	}
+	links, err := n.netLink.LinkList()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list the links")
+	}
+	// The links of the node answer for the IPs of its ENIs, only another host answering is a conflict
+	local := make(map[string]bool, len(links))
+	var link netlink.Link
+	for _, l := range links {
+		local[l.Attrs().HardwareAddr.String()] = true
+		if l.Attrs().Index == routes[0].LinkIndex {
+			link = l
+		}
+	}
+	if link == nil {
+		return nil, errors.Errorf("link %d of the route to %s not found", routes[0].LinkIndex, ip)
+	}
+	isRemote := func(mac net.HardwareAddr) bool {
+		return len(mac) > 0 && !local[mac.String()]
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return arpProbe(link.Attrs(), ip4, timeout, isRemote)
+	}
+	return neighborSolicitationProbe(link.Attrs(), ip, timeout, isRemote)
+}
+
+func arpProbe(link *netlink.LinkAttrs, ip net.IP, timeout time.Duration, isRemote func(net.HardwareAddr) bool) (net.HardwareAddr, error) {
+	broadcast := net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	return probe(link, unix.ETH_P_ARP, broadcast, arpProbePacket(link.HardwareAddr, ip), timeout, func(b []byte) net.HardwareAddr {
+		if mac, sender, ok := parseARPSender(b); ok && sender.Equal(ip) && isRemote(mac) {
+			return mac
+		}
+		return nil
+	})
+}
+
+func neighborSolicitationProbe(link *netlink.LinkAttrs, ip net.IP, timeout time.Duration, isRemote func(net.HardwareAddr) bool) (net.HardwareAddr, error) {
+	group := solicitedNodeAddress(ip)
+	dst := net.HardwareAddr{0x33, 0x33, group[12], group[13], group[14], group[15]}
+	return probe(link, unix.ETH_P_IPV6, dst, neighborSolicitationPacket(ip), timeout, func(b []byte) net.HardwareAddr {
+		if mac, target, ok := parseNeighborAdvertisement(b); ok && target.Equal(ip) && isRemote(mac) {
+			return mac
+		}
+		return nil
+	})
+}
+
+// probe sends packet to dst on link, and returns the first non-nil result of conflict for the packets of the same
+// protocol received on link before timeout
+func probe(link *netlink.LinkAttrs, protocol uint16, dst net.HardwareAddr, packet []byte, timeout time.Duration,
+	conflict func([]byte) net.HardwareAddr) (net.HardwareAddr, error) {
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_DGRAM, int(htons(protocol)))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open a packet socket")
+	}
+	defer unix.Close(fd)
+	if err := unix.Bind(fd, &unix.SockaddrLinklayer{Protocol: htons(protocol), Ifindex: link.Index}); err != nil {
+		return nil, errors.Wrapf(err, "failed to bind the packet socket to %s", link.Name)
+	}
+	to := &unix.SockaddrLinklayer{Protocol: htons(protocol), Ifindex: link.Index, Halen: uint8(len(dst))}
+	copy(to.Addr[:], dst)
+	if err := unix.Sendto(fd, packet, 0, to); err != nil {
+		return nil, errors.Wrapf(err, "failed to send the probe on %s", link.Name)
+	}
+
+	deadline := time.Now().Add(timeout)
+	buf := make([]byte, 1500)
+	for {
+		tv := unix.NsecToTimeval(time.Until(deadline).Nanoseconds())
+		if tv.Sec <= 0 && tv.Usec <= 0 {
+			return nil, nil
+		}
+		if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+			return nil, errors.Wrap(err, "failed to set the packet socket timeout")
+		}
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err == unix.EAGAIN || err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read the answers to the probe on %s", link.Name)
+		}
+		if mac := conflict(buf[:n]); mac != nil {
+			return mac, nil
+		}
+	}
+}
+
+// arpProbePacket returns an RFC 5227 ARP probe for ip, sent from srcMAC without a sender IP
+func arpProbePacket(srcMAC net.HardwareAddr, ip net.IP) []byte {
+	b := make([]byte, arpPacketLen)
+	binary.BigEndian.PutUint16(b[0:2], 1) // Ethernet
+	binary.BigEndian.PutUint16(b[2:4], unix.ETH_P_IP)
+	b[4], b[5] = 6, 4
+	binary.BigEndian.PutUint16(b[6:8], arpOpRequest)
+	copy(b[8:14], srcMAC)
+	copy(b[24:28], ip.To4())
+	return b
+}
+
+// parseARPSender returns the sender hardware and IP addresses of an ARP request or reply
+func parseARPSender(b []byte) (net.HardwareAddr, net.IP, bool) {
+	if len(b) < arpPacketLen || b[4] != 6 || b[5] != 4 {
+		return nil, nil, false
+	}
+	if op := binary.BigEndian.Uint16(b[6:8]); op != arpOpRequest && op != arpOpReply {
+		return nil, nil, false
+	}
+	return net.HardwareAddr(append([]byte(nil), b[8:14]...)), net.IP(append([]byte(nil), b[14:18]...)), true
+}
+
+// neighborSolicitationPacket returns an IPv6 packet with a neighbor solicitation for ip, sent from the unspecified
+// address as for duplicate address detection, so that the owner of ip answers to all nodes
+func neighborSolicitationPacket(ip net.IP) []byte {
+	b := make([]byte, ipv6HeaderLen+ndpOptionsOffset)
+	b[0] = 6 << 4
+	binary.BigEndian.PutUint16(b[4:6], ndpOptionsOffset)
+	b[6], b[7] = unix.IPPROTO_ICMPV6, 255
+	dst := solicitedNodeAddress(ip)
+	copy(b[24:40], dst)
+	icmp := b[ipv6HeaderLen:]
+	icmp[0] = icmpv6NeighborSolicitation
+	copy(icmp[ndpTargetOffset:], ip.To16())
+	binary.BigEndian.PutUint16(icmp[2:4], icmpv6Checksum(net.IPv6unspecified, dst, icmp))
+	return b
+}
+
+// parseNeighborAdvertisement returns the target link-layer address and the target address of an IPv6 packet with a
+// neighbor advertisement
+func parseNeighborAdvertisement(b []byte) (net.HardwareAddr, net.IP, bool) {
+	if len(b) < ipv6HeaderLen+ndpOptionsOffset || b[0]>>4 != 6 || b[6] != unix.IPPROTO_ICMPV6 {
+		return nil, nil, false
+	}
+	icmp := b[ipv6HeaderLen:]
+	if icmp[0] != icmpv6NeighborAdvertisement {
+		return nil, nil, false
+	}
+	target := net.IP(append([]byte(nil), icmp[ndpTargetOffset:ndpOptionsOffset]...))
+	for options := icmp[ndpOptionsOffset:]; len(options) >= 8; {
+		length := int(options[1]) * 8
+		if length == 0 || length > len(options) {
+			break
+		}
+		if options[0] == ndpOptionTargetLinkLayerAddr {
+			return net.HardwareAddr(append([]byte(nil), options[2:8]...)), target, true
+		}
+		options = options[length:]
+	}
+	return nil, target, true
+}
+
+// icmpv6Checksum returns the checksum of an ICMPv6 message, whose checksum field is zero, with its pseudo-header
+func icmpv6Checksum(src, dst net.IP, msg []byte) uint16 {
+	pseudo := make([]byte, 40, 40+len(msg))
+	copy(pseudo[0:16], src.To16())
+	copy(pseudo[16:32], dst.To16())
+	binary.BigEndian.PutUint32(pseudo[32:36], uint32(len(msg)))
+	pseudo[39] = unix.IPPROTO_ICMPV6
+	data := append(pseudo, msg...)
+	var sum uint32
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(data[i:]))
+	}
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
+
+// solicitedNodeAddress returns the solicited-node multicast address of ip, ff02::1:ffXX:XXXX
+func solicitedNodeAddress(ip net.IP) net.IP {
+	addr := net.ParseIP("ff02::1:ff00:0")
+	copy(addr[13:], ip.To16()[13:])
+	return addr
+}
+
+func htons(i uint16) uint16 {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, i)
+	return binary.LittleEndian.Uint16(b)
+}
@@ -0,0 +1,88 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package networkutils
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+)
+
+func TestARPProbePacket(t *testing.T) {
+	mac, _ := net.ParseMAC("02:00:00:00:00:01")
+	ip := net.ParseIP("10.0.0.42").To4()
+	packet := arpProbePacket(mac, ip)
+	assert.Len(t, packet, arpPacketLen)
+	// A probe has no sender IP
+	sender, senderIP, ok := parseARPSender(packet)
+	assert.True(t, ok)
+	assert.Equal(t, mac, sender)
+	assert.True(t, senderIP.Equal(net.IPv4zero))
+	assert.Equal(t, []byte(ip), packet[24:28])
+
+	// The owner of the IP answers with it as the sender
+	reply := append([]byte(nil), packet...)
+	reply[7] = arpOpReply
+	copy(reply[8:14], []byte{0x02, 0, 0, 0, 0, 0x02})
+	copy(reply[14:18], ip)
+	sender, senderIP, ok = parseARPSender(reply)
+	assert.True(t, ok)
+	assert.Equal(t, "02:00:00:00:00:02", sender.String())
+	assert.True(t, senderIP.Equal(ip))
+
+	_, _, ok = parseARPSender(reply[:20])
+	assert.False(t, ok)
+}
+
+func TestNeighborSolicitationPacket(t *testing.T) {
+	ip := net.ParseIP("2001:db8::1:2:3")
+	packet := neighborSolicitationPacket(ip)
+	assert.Equal(t, "ff02::1:ff02:3", net.IP(packet[24:40]).String())
+	icmp := packet[ipv6HeaderLen:]
+	assert.Equal(t, byte(icmpv6NeighborSolicitation), icmp[0])
+	// The checksum of a message with its checksum is zero
+	assert.Equal(t, uint16(0), icmpv6Checksum(net.IPv6unspecified, net.IP(packet[24:40]), icmp))
+
+	// A neighbor advertisement for the IP, with the target link-layer address option
+	advertisement := append([]byte(nil), packet...)
+	advertisement = append(advertisement, ndpOptionTargetLinkLayerAddr, 1, 0x02, 0, 0, 0, 0, 0x02)
+	advertisement[ipv6HeaderLen] = icmpv6NeighborAdvertisement
+	mac, target, ok := parseNeighborAdvertisement(advertisement)
+	assert.True(t, ok)
+	assert.True(t, target.Equal(ip))
+	assert.Equal(t, "02:00:00:00:00:02", mac.String())
+
+	_, _, ok = parseNeighborAdvertisement(packet)
+	assert.False(t, ok)
+}
+
+func TestProbeAddressErrors(t *testing.T) {
+	ctrl, mockNetLink, _, _, _, _ := setup(t)
+	defer ctrl.Finish()
+
+	ln := &linuxNetwork{netLink: mockNetLink}
+	ip := net.ParseIP("10.0.0.42")
+	mockNetLink.EXPECT().RouteGet(ip).Return(nil, errors.New("network unreachable"))
+	_, err := ln.ProbeAddress(ip, time.Millisecond)
+	assert.Error(t, err)
+
+	mockNetLink.EXPECT().RouteGet(ip).Return([]netlink.Route{{LinkIndex: 3}}, nil)
+	mockNetLink.EXPECT().LinkList().Return([]netlink.Link{&netlink.Device{LinkAttrs: netlink.LinkAttrs{Index: 2}}}, nil)
+	_, err = ln.ProbeAddress(ip, time.Millisecond)
+	assert.Error(t, err)
+}
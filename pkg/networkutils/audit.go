@@ -0,0 +1,154 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package networkutils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/coreos/go-iptables/iptables"
+	"github.com/vishvananda/netlink"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/netlinkwrapper"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/audit"
+)
+
+// auditedIptables adds the changes to the iptables rules to the audit log
+type auditedIptables struct {
+	iptablesIface
+	protocol string
+}
+
+func newAuditedIptables(ipt iptablesIface, protocol iptables.Protocol) iptablesIface {
+	name := "ipv4"
+	if protocol == iptables.ProtocolIPv6 {
+		name = "ipv6"
+	}
+	return &auditedIptables{iptablesIface: ipt, protocol: name}
+}
+
+func (a *auditedIptables) record(action, table, chain string, rulespec []string, err error) error {
+	target := strings.TrimSpace(fmt.Sprintf("%s %s %s %s", a.protocol, table, chain, strings.Join(rulespec, " ")))
+	audit.Record(audit.CategoryIptables, action, target, "", err)
+	return err
+}
+
+func (a *auditedIptables) Insert(table, chain string, pos int, rulespec ...string) error {
+	return a.record("insert", table, chain, rulespec, a.iptablesIface.Insert(table, chain, pos, rulespec...))
+}
+
+func (a *auditedIptables) Append(table, chain string, rulespec ...string) error {
+	return a.record("append", table, chain, rulespec, a.iptablesIface.Append(table, chain, rulespec...))
+}
+
+func (a *auditedIptables) Delete(table, chain string, rulespec ...string) error {
+	return a.record("delete", table, chain, rulespec, a.iptablesIface.Delete(table, chain, rulespec...))
+}
+
+func (a *auditedIptables) NewChain(table, chain string) error {
+	return a.record("new-chain", table, chain, nil, a.iptablesIface.NewChain(table, chain))
+}
+
+func (a *auditedIptables) ClearChain(table, chain string) error {
+	return a.record("clear-chain", table, chain, nil, a.iptablesIface.ClearChain(table, chain))
+}
+
+func (a *auditedIptables) DeleteChain(table, chain string) error {
+	return a.record("delete-chain", table, chain, nil, a.iptablesIface.DeleteChain(table, chain))
+}
+
+// auditedNetLink adds the changes to the links, addresses, routes, rules and neighbors of the node to the audit log
+type auditedNetLink struct {
+	netlinkwrapper.NetLink
+}
+
+func linkName(link netlink.Link) string {
+	if link == nil || link.Attrs() == nil {
+		return ""
+	}
+	return link.Attrs().Name
+}
+
+func recordNetlink(action, target string, err error) error {
+	audit.Record(audit.CategoryNetlink, action, target, "", err)
+	return err
+}
+
+func (a *auditedNetLink) LinkSetNsFd(link netlink.Link, fd int) error {
+	return recordNetlink("LinkSetNsFd", linkName(link), a.NetLink.LinkSetNsFd(link, fd))
+}
+
+func (a *auditedNetLink) AddrAdd(link netlink.Link, addr *netlink.Addr) error {
+	return recordNetlink("AddrAdd", fmt.Sprintf("%s dev %s", addr, linkName(link)), a.NetLink.AddrAdd(link, addr))
+}
+
+func (a *auditedNetLink) AddrDel(link netlink.Link, addr *netlink.Addr) error {
+	return recordNetlink("AddrDel", fmt.Sprintf("%s dev %s", addr, linkName(link)), a.NetLink.AddrDel(link, addr))
+}
+
+func (a *auditedNetLink) LinkAdd(link netlink.Link) error {
+	return recordNetlink("LinkAdd", linkName(link), a.NetLink.LinkAdd(link))
+}
+
+func (a *auditedNetLink) LinkSetUp(link netlink.Link) error {
+	return recordNetlink("LinkSetUp", linkName(link), a.NetLink.LinkSetUp(link))
+}
+
+func (a *auditedNetLink) LinkSetDown(link netlink.Link) error {
+	return recordNetlink("LinkSetDown", linkName(link), a.NetLink.LinkSetDown(link))
+}
+
+func (a *auditedNetLink) LinkDel(link netlink.Link) error {
+	return recordNetlink("LinkDel", linkName(link), a.NetLink.LinkDel(link))
+}
+
+func (a *auditedNetLink) LinkSetMTU(link netlink.Link, mtu int) error {
+	return recordNetlink("LinkSetMTU", fmt.Sprintf("%s mtu %d", linkName(link), mtu), a.NetLink.LinkSetMTU(link, mtu))
+}
+
+func (a *auditedNetLink) LinkSetName(link netlink.Link, name string) error {
+	return recordNetlink("LinkSetName", fmt.Sprintf("%s name %s", linkName(link), name), a.NetLink.LinkSetName(link, name))
+}
+
+func (a *auditedNetLink) LinkSetNoMaster(link netlink.Link) error {
+	return recordNetlink("LinkSetNoMaster", linkName(link), a.NetLink.LinkSetNoMaster(link))
+}
+
+func (a *auditedNetLink) LinkSetMulticastOn(link netlink.Link) error {
+	return recordNetlink("LinkSetMulticastOn", linkName(link), a.NetLink.LinkSetMulticastOn(link))
+}
+
+func (a *auditedNetLink) RouteAdd(route *netlink.Route) error {
+	return recordNetlink("RouteAdd", route.String(), a.NetLink.RouteAdd(route))
+}
+
+func (a *auditedNetLink) RouteReplace(route *netlink.Route) error {
+	return recordNetlink("RouteReplace", route.String(), a.NetLink.RouteReplace(route))
+}
+
+func (a *auditedNetLink) RouteDel(route *netlink.Route) error {
+	return recordNetlink("RouteDel", route.String(), a.NetLink.RouteDel(route))
+}
+
+func (a *auditedNetLink) NeighAdd(neigh *netlink.Neigh) error {
+	return recordNetlink("NeighAdd", neigh.String(), a.NetLink.NeighAdd(neigh))
+}
+
+func (a *auditedNetLink) RuleAdd(rule *netlink.Rule) error {
+	return recordNetlink("RuleAdd", rule.String(), a.NetLink.RuleAdd(rule))
+}
+
+func (a *auditedNetLink) RuleDel(rule *netlink.Rule) error {
+	return recordNetlink("RuleDel", rule.String(), a.NetLink.RuleDel(rule))
+}
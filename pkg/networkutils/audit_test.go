@@ -0,0 +1,78 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package networkutils
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/coreos/go-iptables/iptables"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+
+	mock_netlinkwrapper "github.com/aws/amazon-vpc-cni-k8s/pkg/netlinkwrapper/mocks"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/audit"
+)
+
+func TestAuditedIptables(t *testing.T) {
+	var buf bytes.Buffer
+	audit.SetLog(audit.NewLog(&buf, 10))
+	defer audit.SetLog(nil)
+
+	ipt := newAuditedIptables(newMockIptables(), iptables.ProtocolIPv6)
+	assert.NoError(t, ipt.Append("filter", "FORWARD", "-i", "eni1", "-j", "DROP"))
+	assert.Error(t, ipt.Delete("filter", "FORWARD", "-i", "eni2", "-j", "DROP"))
+	// Reads are not recorded
+	_, err := ipt.List("filter", "FORWARD")
+	assert.NoError(t, err)
+
+	entries := audit.Tail(0)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, audit.Entry{Time: entries[0].Time, Category: audit.CategoryIptables, Action: "append",
+		Target: "ipv6 filter FORWARD -i eni1 -j DROP"}, entries[0])
+	assert.Equal(t, "delete", entries[1].Action)
+	assert.Equal(t, "not found", entries[1].Error)
+}
+
+func TestAuditedNetLink(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	var buf bytes.Buffer
+	audit.SetLog(audit.NewLog(&buf, 10))
+	defer audit.SetLog(nil)
+
+	mockNetLink := mock_netlinkwrapper.NewMockNetLink(ctrl)
+	nl := &auditedNetLink{NetLink: mockNetLink}
+	link := &netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Name: "eni1"}}
+	route := &netlink.Route{Dst: &net.IPNet{IP: net.ParseIP("10.0.0.1"), Mask: net.CIDRMask(32, 32)}, LinkIndex: 3}
+	mockNetLink.EXPECT().LinkSetUp(link).Return(nil)
+	mockNetLink.EXPECT().RouteAdd(route).Return(errors.New("file exists"))
+	mockNetLink.EXPECT().LinkList().Return(nil, nil)
+
+	assert.NoError(t, nl.LinkSetUp(link))
+	assert.Error(t, nl.RouteAdd(route))
+	_, err := nl.LinkList()
+	assert.NoError(t, err)
+
+	entries := audit.Tail(0)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "LinkSetUp", entries[0].Action)
+	assert.Equal(t, "eni1", entries[0].Target)
+	assert.Equal(t, "RouteAdd", entries[1].Action)
+	assert.Equal(t, route.String(), entries[1].Target)
+	assert.Equal(t, "file exists", entries[1].Error)
+}
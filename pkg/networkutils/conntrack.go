@@ -0,0 +1,59 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package networkutils
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	conntrackCountKey = "net/netfilter/nf_conntrack_count"
+	conntrackMaxKey   = "net/netfilter/nf_conntrack_max"
+)
+
+// GetConntrackUsage returns the number of entries of the conntrack table of the node and its size
+func (n *linuxNetwork) GetConntrackUsage() (int, int, error) {
+	count, err := n.readProcSysInt(conntrackCountKey)
+	if err != nil {
+		return 0, 0, err
+	}
+	max, err := n.readProcSysInt(conntrackMaxKey)
+	if err != nil {
+		return 0, 0, err
+	}
+	return count, max, nil
+}
+
+// SetConntrackMax sets the size of the conntrack table of the node
+func (n *linuxNetwork) SetConntrackMax(max int) error {
+	if err := n.procSys.Set(conntrackMaxKey, strconv.Itoa(max)); err != nil {
+		return errors.Wrapf(err, "failed to set %s to %d", conntrackMaxKey, max)
+	}
+	return nil
+}
+
+func (n *linuxNetwork) readProcSysInt(key string) (int, error) {
+	raw, err := n.procSys.Get(key)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to read %s", key)
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to parse %s", key)
+	}
+	return value, nil
+}
@@ -0,0 +1,46 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package networkutils
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	mock_procsyswrapper "github.com/aws/amazon-vpc-cni-k8s/pkg/procsyswrapper/mocks"
+)
+
+func TestConntrackUsage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockProcSys := mock_procsyswrapper.NewMockProcSys(ctrl)
+	ln := &linuxNetwork{procSys: mockProcSys}
+
+	mockProcSys.EXPECT().Get(conntrackCountKey).Return("1200\n", nil)
+	mockProcSys.EXPECT().Get(conntrackMaxKey).Return("262144\n", nil)
+	count, max, err := ln.GetConntrackUsage()
+	assert.NoError(t, err)
+	assert.Equal(t, 1200, count)
+	assert.Equal(t, 262144, max)
+
+	// The files don't exist until the conntrack module is loaded
+	mockProcSys.EXPECT().Get(conntrackCountKey).Return("", errors.New("no such file or directory"))
+	_, _, err = ln.GetConntrackUsage()
+	assert.Error(t, err)
+
+	mockProcSys.EXPECT().Set(conntrackMaxKey, "524288").Return(nil)
+	assert.NoError(t, ln.SetConntrackMax(524288))
+}
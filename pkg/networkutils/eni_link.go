@@ -0,0 +1,140 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package networkutils
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/netlinkwrapper"
+)
+
+// The problems of the network of a secondary ENI found by CheckENINetwork
+const (
+	// ENILinkMissing is an ENI without link, e.g. while it is detached
+	ENILinkMissing = "missing"
+	// ENILinkEnslaved is an ENI taken by a bond or a bridge
+	ENILinkEnslaved = "enslaved"
+	// ENILinkDown is an ENI which was brought down, e.g. to rename it
+	ENILinkDown = "down"
+	// ENILinkAddressMissing is an ENI which lost its primary IP
+	ENILinkAddressMissing = "address_missing"
+	// ENILinkRouteMissing is an ENI whose route table lost its default route
+	ENILinkRouteMissing = "route_missing"
+	// ENILinkRouteDrift is an ENI whose route table has no route with the metric or MTU of
+	// AWS_VPC_K8S_CNI_ENI_ROUTE_METRICS or AWS_VPC_K8S_CNI_ENI_ROUTE_MTUS, e.g. after another agent replaced it
+	ENILinkRouteDrift = "route_drift"
+)
+
+// CheckENINetwork returns the name of the link of a secondary ENI, and the first problem found with the network set up
+// by SetupENINetwork, or "" if it is intact. udev or systemd-networkd can rename, bounce or enslave the link after the
+// ENI is attached, which flushes its address and routes until SetupENINetwork runs again.
+func (n *linuxNetwork) CheckENINetwork(eniIP string, mac string, deviceNumber int) (string, string, error) {
+	tableNumber, err := eniRouteTable(n.routeTableBase, deviceNumber)
+	if err != nil {
+		return "", "", err
+	}
+	links, err := n.netLink.LinkList()
+	if err != nil {
+		return "", "", errors.Wrap(err, "CheckENINetwork: failed to list the links")
+	}
+	var link netlink.Link
+	for _, l := range links {
+		if l.Attrs().HardwareAddr.String() == mac {
+			link = l
+			break
+		}
+	}
+	if link == nil {
+		return "", ENILinkMissing, nil
+	}
+	attrs := link.Attrs()
+	if attrs.MasterIndex != 0 {
+		return attrs.Name, ENILinkEnslaved, nil
+	}
+	if attrs.Flags&net.FlagUp == 0 {
+		return attrs.Name, ENILinkDown, nil
+	}
+
+	addrs, err := n.netLink.AddrList(link, unix.AF_INET)
+	if err != nil {
+		return attrs.Name, "", errors.Wrapf(err, "CheckENINetwork: failed to list the addresses of %s", attrs.Name)
+	}
+	ip := net.ParseIP(eniIP)
+	hasAddress := false
+	for _, addr := range addrs {
+		if addr.IP.Equal(ip) {
+			hasAddress = true
+			break
+		}
+	}
+	if !hasAddress {
+		return attrs.Name, ENILinkAddressMissing, nil
+	}
+
+	routes, err := n.netLink.RouteListFiltered(unix.AF_INET, &netlink.Route{Table: tableNumber}, netlink.RT_FILTER_TABLE)
+	if err != nil {
+		return attrs.Name, "", errors.Wrapf(err, "CheckENINetwork: failed to list the routes of table %d", tableNumber)
+	}
+	hasDefault := false
+	matching := make(map[string]bool)
+	for _, route := range routes {
+		if route.LinkIndex != attrs.Index {
+			continue
+		}
+		kind := eniRouteKind(route)
+		if kind == eniRouteDefault {
+			hasDefault = true
+		}
+		if kind != "" && n.eniRouteOptions[kind].matches(route) {
+			matching[kind] = true
+		}
+	}
+	if !hasDefault {
+		return attrs.Name, ENILinkRouteMissing, nil
+	}
+	for kind := range n.eniRouteOptions {
+		if !matching[kind] {
+			return attrs.Name, ENILinkRouteDrift, nil
+		}
+	}
+	return attrs.Name, "", nil
+}
+
+// isZeroMask returns true for the mask of a default route
+func isZeroMask(mask net.IPMask) bool {
+	ones, _ := mask.Size()
+	return ones == 0
+}
+
+// releaseENILink takes the link of an ENI back from a bond or a bridge set up by udev or systemd-networkd, which routes
+// nothing through the ENI. A link which isn't there yet is left to setupENINetwork, which waits for it.
+func releaseENILink(mac string, netLink netlinkwrapper.NetLink) error {
+	links, err := netLink.LinkList()
+	if err != nil {
+		return errors.Wrap(err, "releaseENILink: failed to list the links")
+	}
+	for _, link := range links {
+		attrs := link.Attrs()
+		if attrs.HardwareAddr.String() != mac || attrs.MasterIndex == 0 {
+			continue
+		}
+		log.Warnf("Releasing the link %s of ENI %s from its master link %d", attrs.Name, mac, attrs.MasterIndex)
+		return errors.Wrapf(netLink.LinkSetNoMaster(link), "releaseENILink: failed to release %s", attrs.Name)
+	}
+	return nil
+}
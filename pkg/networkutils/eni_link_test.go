@@ -0,0 +1,101 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package networkutils
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+func TestCheckENINetwork(t *testing.T) {
+	ctrl, mockNetLink, _, _, _, _ := setup(t)
+	defer ctrl.Finish()
+
+	ln := &linuxNetwork{netLink: mockNetLink, routeTableBase: DefaultRouteTableBase}
+	mac2, _ := net.ParseMAC(testMAC2)
+	eniAddr := netlink.Addr{IPNet: &net.IPNet{IP: net.ParseIP(testeniIP), Mask: net.CIDRMask(24, 32)}}
+	defaultRoute := netlink.Route{LinkIndex: 3, Gw: net.ParseIP("10.10.10.1"), Table: 3}
+	tunedDefaultRoute := netlink.Route{LinkIndex: 3, Gw: net.ParseIP("10.10.10.1"), Table: 3, Priority: 100}
+	link := func(flags net.Flags, masterIndex int) netlink.Link {
+		return &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "ens6", Index: 3, HardwareAddr: mac2, Flags: flags,
+			MasterIndex: masterIndex}}
+	}
+
+	tests := []struct {
+		name    string
+		link    netlink.Link
+		addrs   []netlink.Addr
+		routes  []netlink.Route
+		options map[string]eniRouteOption
+		problem string
+	}{
+		{name: "intact", link: link(net.FlagUp, 0), addrs: []netlink.Addr{eniAddr},
+			routes: []netlink.Route{defaultRoute}},
+		{name: "missing", problem: ENILinkMissing},
+		{name: "enslaved", link: link(net.FlagUp, 9), problem: ENILinkEnslaved},
+		{name: "down", link: link(0, 0), problem: ENILinkDown},
+		{name: "address missing", link: link(net.FlagUp, 0), problem: ENILinkAddressMissing},
+		{name: "route missing", link: link(net.FlagUp, 0), addrs: []netlink.Addr{eniAddr},
+			problem: ENILinkRouteMissing},
+		{name: "route drift", link: link(net.FlagUp, 0), addrs: []netlink.Addr{eniAddr},
+			routes: []netlink.Route{defaultRoute}, options: map[string]eniRouteOption{eniRouteDefault: {metric: 100}},
+			problem: ENILinkRouteDrift},
+		{name: "route tuned", link: link(net.FlagUp, 0), addrs: []netlink.Addr{eniAddr},
+			routes:  []netlink.Route{defaultRoute, tunedDefaultRoute},
+			options: map[string]eniRouteOption{eniRouteDefault: {metric: 100}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ln.eniRouteOptions = tt.options
+			var links []netlink.Link
+			if tt.link != nil {
+				links = append(links, tt.link)
+			}
+			mockNetLink.EXPECT().LinkList().Return(links, nil)
+			if tt.link != nil && tt.link.Attrs().MasterIndex == 0 && tt.link.Attrs().Flags&net.FlagUp != 0 {
+				mockNetLink.EXPECT().AddrList(tt.link, unix.AF_INET).Return(tt.addrs, nil)
+				if len(tt.addrs) > 0 {
+					mockNetLink.EXPECT().RouteListFiltered(unix.AF_INET, &netlink.Route{Table: 3}, netlink.RT_FILTER_TABLE).
+						Return(tt.routes, nil)
+				}
+			}
+			name, problem, err := ln.CheckENINetwork(testeniIP, testMAC2, 2)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.problem, problem)
+			if tt.link != nil {
+				assert.Equal(t, "ens6", name)
+			}
+		})
+	}
+}
+
+func TestReleaseENILink(t *testing.T) {
+	ctrl, mockNetLink, _, _, _, _ := setup(t)
+	defer ctrl.Finish()
+
+	mac2, _ := net.ParseMAC(testMAC2)
+	bond := &netlink.Bond{LinkAttrs: netlink.LinkAttrs{Name: "bond0", Index: 9}}
+	eth1 := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "ens6", Index: 3, HardwareAddr: mac2}}
+	mockNetLink.EXPECT().LinkList().Return([]netlink.Link{bond, eth1}, nil)
+	assert.NoError(t, releaseENILink(testMAC2, mockNetLink))
+
+	enslaved := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "ens6", Index: 3, HardwareAddr: mac2, MasterIndex: 9}}
+	mockNetLink.EXPECT().LinkList().Return([]netlink.Link{bond, enslaved}, nil)
+	mockNetLink.EXPECT().LinkSetNoMaster(enslaved).Return(nil)
+	assert.NoError(t, releaseENILink(testMAC2, mockNetLink))
+}
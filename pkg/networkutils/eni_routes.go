@@ -0,0 +1,92 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package networkutils
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+)
+
+// The routes of the route table of a secondary ENI, as named in AWS_VPC_K8S_CNI_ENI_ROUTE_METRICS and
+// AWS_VPC_K8S_CNI_ENI_ROUTE_MTUS
+const (
+	// eniRouteGateway is the link route to the gateway of the ENI subnet
+	eniRouteGateway = "gateway"
+	// eniRouteDefault is the default route through the gateway of the ENI subnet
+	eniRouteDefault = "default"
+)
+
+// eniRouteOption is the metric and MTU of a route of the ENI route tables. Zero leaves the kernel default.
+type eniRouteOption struct {
+	metric int
+	mtu    int
+}
+
+// apply sets the metric and MTU of a route
+func (o eniRouteOption) apply(route *netlink.Route) {
+	route.Priority = o.metric
+	route.MTU = o.mtu
+}
+
+// matches returns true if a route has the metric and MTU of the option
+func (o eniRouteOption) matches(route netlink.Route) bool {
+	return route.Priority == o.metric && route.MTU == o.mtu
+}
+
+// parseENIRouteOptions parses the comma or whitespace separated lists of <route>=<metric> and <route>=<MTU> entries of
+// AWS_VPC_K8S_CNI_ENI_ROUTE_METRICS and AWS_VPC_K8S_CNI_ENI_ROUTE_MTUS. Invalid entries are logged and skipped.
+func parseENIRouteOptions(metrics string, mtus string) map[string]eniRouteOption {
+	options := make(map[string]eniRouteOption)
+	parse := func(env string, value string, min int, max int, set func(option *eniRouteOption, value int)) {
+		for _, entry := range strings.FieldsFunc(value, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\n' || r == '\t'
+		}) {
+			parts := strings.SplitN(entry, "=", 2)
+			if len(parts) != 2 {
+				log.Errorf("Ignoring %s entry %q, expected <route>=<value>", env, entry)
+				continue
+			}
+			route := parts[0]
+			if route != eniRouteGateway && route != eniRouteDefault {
+				log.Errorf("Ignoring %s entry %q, the route must be %s or %s", env, entry, eniRouteGateway, eniRouteDefault)
+				continue
+			}
+			n, err := strconv.Atoi(parts[1])
+			if err != nil || n < min || n > max {
+				log.Errorf("Ignoring %s entry %q, the value must be between %d and %d", env, entry, min, max)
+				continue
+			}
+			option := options[route]
+			set(&option, n)
+			options[route] = option
+		}
+	}
+	parse(envENIRouteMetrics, metrics, 0, 1<<31-1, func(option *eniRouteOption, value int) { option.metric = value })
+	parse(envENIRouteMTUs, mtus, minimumMTU, maximumMTU, func(option *eniRouteOption, value int) { option.mtu = value })
+	return options
+}
+
+// eniRouteKind returns the name of a route of an ENI route table, or "" if it isn't one that setupENINetwork adds
+func eniRouteKind(route netlink.Route) string {
+	if route.Dst == nil || (route.Dst.IP.Equal(net.IPv4zero) && isZeroMask(route.Dst.Mask)) {
+		return eniRouteDefault
+	}
+	if ones, bits := route.Dst.Mask.Size(); ones == 32 && bits == 32 && route.Scope == netlink.SCOPE_LINK {
+		return eniRouteGateway
+	}
+	return ""
+}
@@ -0,0 +1,72 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package networkutils
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+func TestParseENIRouteOptions(t *testing.T) {
+	assert.Empty(t, parseENIRouteOptions("", ""))
+	assert.Equal(t, map[string]eniRouteOption{
+		eniRouteGateway: {metric: 10},
+		eniRouteDefault: {metric: 100, mtu: 1400},
+	}, parseENIRouteOptions("gateway=10,\n default=100 vpc=5 default=-1", "default=1400 gateway=100 gateway default=abc"))
+}
+
+func TestSetupENINetworkRouteOptions(t *testing.T) {
+	ctrl, mockNetLink, _, _, _, _ := setup(t)
+	defer ctrl.Finish()
+
+	mac2, _ := net.ParseMAC(testMAC2)
+	eth1 := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Index: 3, HardwareAddr: mac2}}
+	mockNetLink.EXPECT().LinkList().Return([]netlink.Link{eth1}, nil)
+	mockNetLink.EXPECT().LinkSetMTU(eth1, testMTU).Return(nil)
+	mockNetLink.EXPECT().LinkSetUp(eth1).Return(nil)
+	mockNetLink.EXPECT().AddrList(eth1, unix.AF_INET).Return(nil, nil)
+	mockNetLink.EXPECT().AddrAdd(eth1, gomock.Any()).Return(nil)
+
+	// The old routes are deleted whatever their metric, the new ones get the metric and MTU of their route
+	var deleted, replaced []netlink.Route
+	mockNetLink.EXPECT().RouteDel(gomock.Any()).DoAndReturn(func(route *netlink.Route) error {
+		deleted = append(deleted, *route)
+		return nil
+	}).Times(3)
+	mockNetLink.EXPECT().RouteReplace(gomock.Any()).DoAndReturn(func(route *netlink.Route) error {
+		replaced = append(replaced, *route)
+		return nil
+	}).Times(2)
+
+	options := map[string]eniRouteOption{eniRouteDefault: {metric: 100, mtu: 1400}}
+	err := setupENINetwork(testeniIP, testMAC2, testTable, DefaultRouteTableBase, testeniSubnet, mockNetLink,
+		0*time.Second, 0*time.Second, testMTU, options)
+	assert.NoError(t, err)
+	for _, route := range deleted {
+		assert.Zero(t, route.Priority)
+		assert.Zero(t, route.MTU)
+	}
+	assert.Len(t, replaced, 2)
+	assert.Equal(t, eniRouteGateway, eniRouteKind(replaced[0]))
+	assert.Zero(t, replaced[0].Priority)
+	assert.Equal(t, eniRouteDefault, eniRouteKind(replaced[1]))
+	assert.Equal(t, 100, replaced[1].Priority)
+	assert.Equal(t, 1400, replaced[1].MTU)
+}
@@ -0,0 +1,63 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package networkutils
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// envHostPodHairpinRules is the environment variable to route the traffic between the primary IP of the node and
+	// the pods through the main table, whatever the ENI of the pod. Defaults to false.
+	envHostPodHairpinRules = "ENABLE_HOST_POD_HAIRPIN_RULES"
+
+	// hostPodRulePriority is above the rules of the pods on secondary ENIs, in the range left free below the main ENI
+	// rule
+	hostPodRulePriority = hostRulePriority + 1
+)
+
+func hostPodHairpinRulesEnabled() bool {
+	return getBoolEnvVar(envHostPodHairpinRules, false)
+}
+
+// setupHostPodRules adds the rules that send the traffic from the primary IP of the node, e.g. kubelet probes, and the
+// traffic to it through the main table, so that both directions use the host veth of the pod instead of the route
+// table of the ENI of the pod. The rules only select the main table, so they are left in place when disabled.
+func (n *linuxNetwork) setupHostPodRules(primaryAddr *net.IP) error {
+	if !n.hostPodHairpinRules {
+		return nil
+	}
+	primaryIP := &net.IPNet{IP: *primaryAddr, Mask: net.CIDRMask(32, 32)}
+	fromHostRule := n.netLink.NewRule()
+	fromHostRule.Src = primaryIP
+	toHostRule := n.netLink.NewRule()
+	toHostRule.Dst = primaryIP
+	for _, rule := range []*netlink.Rule{fromHostRule, toHostRule} {
+		rule.Table = mainRoutingTable
+		rule.Priority = hostPodRulePriority
+		rule.Family = unix.AF_INET
+		// If this is a restart, cleanup previous rule first
+		if err := n.netLink.RuleDel(rule); err != nil && !containsNoSuchRule(err) {
+			return errors.Wrapf(err, "host network setup: failed to delete old host pod rule %s", rule.String())
+		}
+		if err := n.netLink.RuleAdd(rule); err != nil {
+			return errors.Wrapf(err, "host network setup: failed to add host pod rule %s", rule.String())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package networkutils
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+func TestSetupHostPodRules(t *testing.T) {
+	ctrl, mockNetLink, _, _, _, _ := setup(t)
+	defer ctrl.Finish()
+
+	primaryIP := net.ParseIP("10.0.0.10")
+	ln := &linuxNetwork{netLink: mockNetLink}
+	// Disabled by default
+	assert.NoError(t, ln.setupHostPodRules(&primaryIP))
+
+	ln.hostPodHairpinRules = true
+	var fromHostRule, toHostRule netlink.Rule
+	mockNetLink.EXPECT().NewRule().Return(&fromHostRule)
+	mockNetLink.EXPECT().NewRule().Return(&toHostRule)
+	mockNetLink.EXPECT().RuleDel(&fromHostRule).Return(syscall.ENOENT)
+	mockNetLink.EXPECT().RuleAdd(&fromHostRule).Return(nil)
+	mockNetLink.EXPECT().RuleDel(&toHostRule).Return(nil)
+	mockNetLink.EXPECT().RuleAdd(&toHostRule).Return(nil)
+	assert.NoError(t, ln.setupHostPodRules(&primaryIP))
+
+	primaryIPNet := &net.IPNet{IP: primaryIP, Mask: net.CIDRMask(32, 32)}
+	assert.Equal(t, primaryIPNet, fromHostRule.Src)
+	assert.Equal(t, primaryIPNet, toHostRule.Dst)
+	for _, rule := range []netlink.Rule{fromHostRule, toHostRule} {
+		assert.Equal(t, mainRoutingTable, rule.Table)
+		assert.Equal(t, hostPodRulePriority, rule.Priority)
+		assert.Equal(t, unix.AF_INET, rule.Family)
+	}
+
+	mockNetLink.EXPECT().NewRule().Return(&netlink.Rule{})
+	mockNetLink.EXPECT().NewRule().Return(&netlink.Rule{})
+	mockNetLink.EXPECT().RuleDel(gomock.Any()).Return(nil)
+	mockNetLink.EXPECT().RuleAdd(gomock.Any()).Return(errors.New("netlink error"))
+	assert.Error(t, ln.setupHostPodRules(&primaryIP))
+}
@@ -0,0 +1,179 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package networkutils
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/coreos/go-iptables/iptables"
+	"github.com/pkg/errors"
+)
+
+const (
+	// imdsBlockChain is the filter table chain that holds a drop rule for the host veth of each pod denied access to
+	// the instance metadata service
+	imdsBlockChain = "AWS-IMDS-BLOCK"
+
+	imdsBlockComment = "AWS, pod IMDS access"
+
+	imdsV4Address = "169.254.169.254/32"
+	imdsV6Address = "fd00:ec2::254/128"
+)
+
+// podIptables returns the iptables instance of the IP family of the pods
+func (n *linuxNetwork) podIptables(v6Enabled bool) (iptablesIface, error) {
+	protocol := iptables.ProtocolIPv4
+	if v6Enabled {
+		protocol = iptables.ProtocolIPv6
+	}
+	ipt, err := n.newIptables(protocol)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create iptables")
+	}
+	return ipt, nil
+}
+
+// imdsBlockIptables returns the iptables instance and the IMDS address of the IP family of the pods
+func (n *linuxNetwork) imdsBlockIptables(v6Enabled bool) (iptablesIface, string, error) {
+	address := imdsV4Address
+	if v6Enabled {
+		address = imdsV6Address
+	}
+	ipt, err := n.podIptables(v6Enabled)
+	return ipt, address, err
+}
+
+func imdsBlockJumpRule(address string) []string {
+	return []string{"-d", address, "-m", "comment", "--comment", imdsBlockComment, "-j", imdsBlockChain}
+}
+
+func imdsBlockPodRule(hostVeth string) []string {
+	return []string{"-i", hostVeth, "-m", "comment", "--comment", imdsBlockComment, "-j", "DROP"}
+}
+
+// SetupPodIMDSBlock creates the chain that the IMDS traffic forwarded from the pods goes through, or deletes it along
+// with the pod rules when disabled
+func (n *linuxNetwork) SetupPodIMDSBlock(enabled bool, v6Enabled bool) error {
+	ipt, address, err := n.imdsBlockIptables(v6Enabled)
+	if err != nil {
+		return err
+	}
+	jumpRule := imdsBlockJumpRule(address)
+	exists, err := ipt.Exists("filter", "FORWARD", jumpRule...)
+	if err != nil {
+		return errors.Wrap(err, "failed to check the IMDS block jump rule")
+	}
+	if !enabled {
+		if exists {
+			if err := ipt.Delete("filter", "FORWARD", jumpRule...); err != nil {
+				return errors.Wrap(err, "failed to delete the IMDS block jump rule")
+			}
+		}
+		if err := ipt.ClearChain("filter", imdsBlockChain); err != nil {
+			return errors.Wrapf(err, "failed to clear chain %s", imdsBlockChain)
+		}
+		if err := ipt.DeleteChain("filter", imdsBlockChain); err != nil {
+			return errors.Wrapf(err, "failed to delete chain %s", imdsBlockChain)
+		}
+		return nil
+	}
+	// The pod rules of a previous run are kept, so that the pods stay blocked while ipamd restarts
+	if err := ipt.NewChain("filter", imdsBlockChain); err != nil && !containChainExistErr(err) {
+		return errors.Wrapf(err, "failed to create chain %s", imdsBlockChain)
+	}
+	if !exists {
+		// Insert first, so that the rules of network policy agents accepting the traffic do not bypass the chain
+		if err := ipt.Insert("filter", "FORWARD", 1, jumpRule...); err != nil {
+			return errors.Wrap(err, "failed to add the IMDS block jump rule")
+		}
+	}
+	return nil
+}
+
+// BlockPodIMDSAccess drops the traffic to the instance metadata service coming from the host veth of a pod
+func (n *linuxNetwork) BlockPodIMDSAccess(hostVeth string, v6Enabled bool) error {
+	ipt, _, err := n.imdsBlockIptables(v6Enabled)
+	if err != nil {
+		return err
+	}
+	rule := imdsBlockPodRule(hostVeth)
+	exists, err := ipt.Exists("filter", imdsBlockChain, rule...)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check the IMDS block rule of %s", hostVeth)
+	}
+	if exists {
+		return nil
+	}
+	if err := ipt.Append("filter", imdsBlockChain, rule...); err != nil {
+		return errors.Wrapf(err, "failed to add the IMDS block rule of %s", hostVeth)
+	}
+	return nil
+}
+
+// UnblockPodIMDSAccess deletes the rule added by BlockPodIMDSAccess, if any
+func (n *linuxNetwork) UnblockPodIMDSAccess(hostVeth string, v6Enabled bool) error {
+	ipt, _, err := n.imdsBlockIptables(v6Enabled)
+	if err != nil {
+		return err
+	}
+	rule := imdsBlockPodRule(hostVeth)
+	exists, err := ipt.Exists("filter", imdsBlockChain, rule...)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check the IMDS block rule of %s", hostVeth)
+	}
+	if !exists {
+		return nil
+	}
+	if err := ipt.Delete("filter", imdsBlockChain, rule...); err != nil {
+		return errors.Wrapf(err, "failed to delete the IMDS block rule of %s", hostVeth)
+	}
+	return nil
+}
+
+// GetPodIMDSBlockedPackets returns the number of packets dropped by the rule of each host veth in the IMDS block chain
+func (n *linuxNetwork) GetPodIMDSBlockedPackets(v6Enabled bool) (map[string]uint64, error) {
+	ipt, _, err := n.imdsBlockIptables(v6Enabled)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := ipt.ListWithCounters("filter", imdsBlockChain)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list chain %s", imdsBlockChain)
+	}
+	return parseIMDSBlockCounters(rules), nil
+}
+
+// parseIMDSBlockCounters reads the input interface and the packet counter of rules listed as
+// "-A <chain> -i <veth> ... -c <packets> <bytes>"
+func parseIMDSBlockCounters(rules []string) map[string]uint64 {
+	packets := map[string]uint64{}
+	for _, rule := range rules {
+		fields := strings.Fields(rule)
+		var hostVeth string
+		var count uint64
+		for i := 0; i < len(fields)-1; i++ {
+			switch fields[i] {
+			case "-i":
+				hostVeth = fields[i+1]
+			case "-c":
+				count, _ = strconv.ParseUint(fields[i+1], 10, 64)
+			}
+		}
+		if hostVeth != "" {
+			packets[hostVeth] += count
+		}
+	}
+	return packets
+}
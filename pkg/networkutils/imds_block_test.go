@@ -0,0 +1,61 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package networkutils
+
+import (
+	"testing"
+
+	"github.com/coreos/go-iptables/iptables"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPodIMDSBlock(t *testing.T) {
+	ipt := newMockIptables()
+	ln := &linuxNetwork{
+		newIptables: func(iptables.Protocol) (iptablesIface, error) {
+			return ipt, nil
+		},
+	}
+	assert.NoError(t, ipt.Append("filter", "FORWARD", "-j", "ACCEPT"))
+
+	assert.NoError(t, ln.SetupPodIMDSBlock(true, false))
+	// A restart does not add a second jump rule
+	assert.NoError(t, ln.SetupPodIMDSBlock(true, false))
+	assert.Equal(t, [][]string{imdsBlockJumpRule(imdsV4Address), {"-j", "ACCEPT"}}, ipt.dataplaneState["filter"]["FORWARD"])
+
+	assert.NoError(t, ln.BlockPodIMDSAccess("eni1", false))
+	assert.NoError(t, ln.BlockPodIMDSAccess("eni1", false))
+	assert.NoError(t, ln.BlockPodIMDSAccess("eni2", false))
+	assert.Equal(t, [][]string{imdsBlockPodRule("eni1"), imdsBlockPodRule("eni2")}, ipt.dataplaneState["filter"][imdsBlockChain])
+
+	assert.NoError(t, ln.UnblockPodIMDSAccess("eni1", false))
+	assert.NoError(t, ln.UnblockPodIMDSAccess("eni3", false))
+	assert.Equal(t, [][]string{imdsBlockPodRule("eni2")}, ipt.dataplaneState["filter"][imdsBlockChain])
+
+	packets, err := ln.GetPodIMDSBlockedPackets(false)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]uint64{"eni2": 0}, packets)
+
+	assert.NoError(t, ln.SetupPodIMDSBlock(false, false))
+	assert.Equal(t, [][]string{{"-j", "ACCEPT"}}, ipt.dataplaneState["filter"]["FORWARD"])
+}
+
+func TestParseIMDSBlockCounters(t *testing.T) {
+	rules := []string{
+		"-N AWS-IMDS-BLOCK",
+		`-A AWS-IMDS-BLOCK -i eni1 -m comment --comment "AWS, pod IMDS access" -j DROP -c 12 720`,
+		`-c 3 180 -A AWS-IMDS-BLOCK -i eni2 -m comment --comment "AWS, pod IMDS access" -j DROP`,
+	}
+	assert.Equal(t, map[string]uint64{"eni1": 12, "eni2": 3}, parseIMDSBlockCounters(rules))
+}
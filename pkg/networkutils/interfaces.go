@@ -0,0 +1,72 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package networkutils
+
+import (
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+)
+
+// envPrimaryInterface is the environment variable to set the name of the primary interface, for images where it
+// can't be found by the MAC address of the primary ENI
+const envPrimaryInterface = "AWS_VPC_K8S_CNI_PRIMARY_INTERFACE"
+
+// getPrimaryInterfaceOverride returns the name of the primary interface set through AWS_VPC_K8S_CNI_PRIMARY_INTERFACE
+func getPrimaryInterfaceOverride() string {
+	return strings.TrimSpace(os.Getenv(envPrimaryInterface))
+}
+
+// findPrimaryInterfaceName returns the name of the interface of the primary ENI. It is looked up by the MAC address
+// the instance metadata reports for the primary ENI, since its name depends on the image: eth0, ens5, enX0 on newer
+// Nitro instances, or a custom udev name.
+func (n *linuxNetwork) findPrimaryInterfaceName(primaryMAC string) (string, error) {
+	if n.primaryInterfaceOverride != "" {
+		link, err := n.netLink.LinkByName(n.primaryInterfaceOverride)
+		if err != nil {
+			return "", errors.Wrapf(err, "findPrimaryInterfaceName: failed to find primary interface %s set through %s",
+				n.primaryInterfaceOverride, envPrimaryInterface)
+		}
+		if mac := link.Attrs().HardwareAddr.String(); mac != primaryMAC {
+			log.Warnf("Primary interface %s set through %s has MAC address %s, the primary ENI has %s",
+				n.primaryInterfaceOverride, envPrimaryInterface, mac, primaryMAC)
+		}
+		return n.primaryInterfaceOverride, nil
+	}
+
+	log.Debugf("Trying to find primary interface that has mac : %s", primaryMAC)
+	links, err := n.netLink.LinkList()
+	if err != nil {
+		return "", errors.Wrapf(err, "findPrimaryInterfaceName: failed to list interfaces")
+	}
+	var primary netlink.Link
+	for _, link := range links {
+		if link.Attrs().HardwareAddr.String() != primaryMAC {
+			continue
+		}
+		log.Debugf("Discovered interface: %s, mac: %s", link.Attrs().Name, primaryMAC)
+		// VLAN devices and bridges can share the MAC address of the ENI, prefer the ENI itself
+		if primary == nil || (primary.Type() != "device" && link.Type() == "device") {
+			primary = link
+		}
+	}
+	if primary == nil {
+		return "", errors.Errorf("no primary interface found with mac address %s, set %s to its name",
+			primaryMAC, envPrimaryInterface)
+	}
+	log.Infof("Discovered primary interface: %s", primary.Attrs().Name)
+	return primary.Attrs().Name, nil
+}
@@ -0,0 +1,79 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package networkutils
+
+import (
+	"errors"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+)
+
+func TestFindPrimaryInterfaceName(t *testing.T) {
+	ctrl, mockNetLink, _, _, _, _ := setup(t)
+	defer ctrl.Finish()
+
+	ln := &linuxNetwork{netLink: mockNetLink}
+	mac1, _ := net.ParseMAC(testMAC1)
+	mac2, _ := net.ParseMAC(testMAC2)
+	lo := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "lo", Index: 1}}
+	// The trunk ENI and its VLAN devices share a MAC address
+	vlan := &netlink.Vlan{LinkAttrs: netlink.LinkAttrs{Name: "vlan.eth.1", Index: 2, HardwareAddr: mac1}}
+	enX0 := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "enX0", Index: 3, HardwareAddr: mac1}}
+	ens6 := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "ens6", Index: 4, HardwareAddr: mac2}}
+
+	mockNetLink.EXPECT().LinkList().Return([]netlink.Link{lo, vlan, enX0, ens6}, nil)
+	name, err := ln.findPrimaryInterfaceName(testMAC1)
+	assert.NoError(t, err)
+	assert.Equal(t, "enX0", name)
+
+	mockNetLink.EXPECT().LinkList().Return([]netlink.Link{lo, ens6}, nil)
+	_, err = ln.findPrimaryInterfaceName(testMAC1)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), envPrimaryInterface)
+
+	mockNetLink.EXPECT().LinkList().Return(nil, errors.New("netlink error"))
+	_, err = ln.findPrimaryInterfaceName(testMAC1)
+	assert.Error(t, err)
+}
+
+func TestFindPrimaryInterfaceNameOverride(t *testing.T) {
+	ctrl, mockNetLink, _, _, _, _ := setup(t)
+	defer ctrl.Finish()
+
+	ln := &linuxNetwork{netLink: mockNetLink, primaryInterfaceOverride: "primary0"}
+	mac1, _ := net.ParseMAC(testMAC1)
+	primary := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "primary0", Index: 2, HardwareAddr: mac1}}
+
+	mockNetLink.EXPECT().LinkByName("primary0").Return(primary, nil)
+	name, err := ln.findPrimaryInterfaceName(testMAC1)
+	assert.NoError(t, err)
+	assert.Equal(t, "primary0", name)
+
+	mockNetLink.EXPECT().LinkByName("primary0").Return(nil, errors.New("Link not found"))
+	_, err = ln.findPrimaryInterfaceName(testMAC1)
+	assert.Error(t, err)
+}
+
+func TestGetPrimaryInterfaceOverride(t *testing.T) {
+	os.Unsetenv(envPrimaryInterface)
+	assert.Equal(t, "", getPrimaryInterfaceOverride())
+
+	os.Setenv(envPrimaryInterface, " ens5 ")
+	defer os.Unsetenv(envPrimaryInterface)
+	assert.Equal(t, "ens5", getPrimaryInterfaceOverride())
+}
@@ -23,6 +23,7 @@ import (
 	reflect "reflect"
 	time "time"
 
+	networkutils "github.com/aws/amazon-vpc-cni-k8s/pkg/networkutils"
 	gomock "github.com/golang/mock/gomock"
 	netlink "github.com/vishvananda/netlink"
 )
@@ -50,18 +51,108 @@ func (m *MockNetworkAPIs) EXPECT() *MockNetworkAPIsMockRecorder {
 	return m.recorder
 }
 
-// DeleteRuleListBySrc mocks base method
-func (m *MockNetworkAPIs) DeleteRuleListBySrc(arg0 net.IPNet) error {
+// BlockPodIMDSAccess mocks base method
+func (m *MockNetworkAPIs) BlockPodIMDSAccess(arg0 string, arg1 bool) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteRuleListBySrc", arg0)
+	ret := m.ctrl.Call(m, "BlockPodIMDSAccess", arg0, arg1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// DeleteRuleListBySrc indicates an expected call of DeleteRuleListBySrc
-func (mr *MockNetworkAPIsMockRecorder) DeleteRuleListBySrc(arg0 interface{}) *gomock.Call {
+// BlockPodIMDSAccess indicates an expected call of BlockPodIMDSAccess
+func (mr *MockNetworkAPIsMockRecorder) BlockPodIMDSAccess(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRuleListBySrc", reflect.TypeOf((*MockNetworkAPIs)(nil).DeleteRuleListBySrc), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlockPodIMDSAccess", reflect.TypeOf((*MockNetworkAPIs)(nil).BlockPodIMDSAccess), arg0, arg1)
+}
+
+// CheckENINetwork mocks base method
+func (m *MockNetworkAPIs) CheckENINetwork(arg0, arg1 string, arg2 int) (string, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckENINetwork", arg0, arg1, arg2)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CheckENINetwork indicates an expected call of CheckENINetwork
+func (mr *MockNetworkAPIsMockRecorder) CheckENINetwork(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckENINetwork", reflect.TypeOf((*MockNetworkAPIs)(nil).CheckENINetwork), arg0, arg1, arg2)
+}
+
+// CheckKernelSettings mocks base method
+func (m *MockNetworkAPIs) CheckKernelSettings(arg0 string, arg1, arg2 bool) []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckKernelSettings", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// CheckKernelSettings indicates an expected call of CheckKernelSettings
+func (mr *MockNetworkAPIsMockRecorder) CheckKernelSettings(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckKernelSettings", reflect.TypeOf((*MockNetworkAPIs)(nil).CheckKernelSettings), arg0, arg1, arg2)
+}
+
+// CountConntrackFlows mocks base method
+func (m *MockNetworkAPIs) CountConntrackFlows(arg0 bool) (map[string]int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountConntrackFlows", arg0)
+	ret0, _ := ret[0].(map[string]int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountConntrackFlows indicates an expected call of CountConntrackFlows
+func (mr *MockNetworkAPIsMockRecorder) CountConntrackFlows(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountConntrackFlows", reflect.TypeOf((*MockNetworkAPIs)(nil).CountConntrackFlows), arg0)
+}
+
+// DeleteWarmVeth mocks base method
+func (m *MockNetworkAPIs) DeleteWarmVeth(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWarmVeth", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWarmVeth indicates an expected call of DeleteWarmVeth
+func (mr *MockNetworkAPIsMockRecorder) DeleteWarmVeth(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWarmVeth", reflect.TypeOf((*MockNetworkAPIs)(nil).DeleteWarmVeth), arg0)
+}
+
+// FindRouteTableConflicts mocks base method
+func (m *MockNetworkAPIs) FindRouteTableConflicts(arg0 string, arg1 int) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindRouteTableConflicts", arg0, arg1)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindRouteTableConflicts indicates an expected call of FindRouteTableConflicts
+func (mr *MockNetworkAPIsMockRecorder) FindRouteTableConflicts(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindRouteTableConflicts", reflect.TypeOf((*MockNetworkAPIs)(nil).FindRouteTableConflicts), arg0, arg1)
+}
+
+// GetConntrackUsage mocks base method
+func (m *MockNetworkAPIs) GetConntrackUsage() (int, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetConntrackUsage")
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetConntrackUsage indicates an expected call of GetConntrackUsage
+func (mr *MockNetworkAPIsMockRecorder) GetConntrackUsage() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetConntrackUsage", reflect.TypeOf((*MockNetworkAPIs)(nil).GetConntrackUsage))
 }
 
 // GetExcludeSNATCIDRs mocks base method
@@ -78,6 +169,20 @@ func (mr *MockNetworkAPIsMockRecorder) GetExcludeSNATCIDRs() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetExcludeSNATCIDRs", reflect.TypeOf((*MockNetworkAPIs)(nil).GetExcludeSNATCIDRs))
 }
 
+// GetHostVethName mocks base method
+func (m *MockNetworkAPIs) GetHostVethName(arg0, arg1 string) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetHostVethName", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GetHostVethName indicates an expected call of GetHostVethName
+func (mr *MockNetworkAPIsMockRecorder) GetHostVethName(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHostVethName", reflect.TypeOf((*MockNetworkAPIs)(nil).GetHostVethName), arg0, arg1)
+}
+
 // GetLinkByMac mocks base method
 func (m *MockNetworkAPIs) GetLinkByMac(arg0 string, arg1 time.Duration) (netlink.Link, error) {
 	m.ctrl.T.Helper()
@@ -93,6 +198,51 @@ func (mr *MockNetworkAPIsMockRecorder) GetLinkByMac(arg0, arg1 interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLinkByMac", reflect.TypeOf((*MockNetworkAPIs)(nil).GetLinkByMac), arg0, arg1)
 }
 
+// GetPodIMDSBlockedPackets mocks base method
+func (m *MockNetworkAPIs) GetPodIMDSBlockedPackets(arg0 bool) (map[string]uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPodIMDSBlockedPackets", arg0)
+	ret0, _ := ret[0].(map[string]uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPodIMDSBlockedPackets indicates an expected call of GetPodIMDSBlockedPackets
+func (mr *MockNetworkAPIsMockRecorder) GetPodIMDSBlockedPackets(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPodIMDSBlockedPackets", reflect.TypeOf((*MockNetworkAPIs)(nil).GetPodIMDSBlockedPackets), arg0)
+}
+
+// GetPodNetworkStats mocks base method
+func (m *MockNetworkAPIs) GetPodNetworkStats(arg0 string) (*networkutils.PodNetworkStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPodNetworkStats", arg0)
+	ret0, _ := ret[0].(*networkutils.PodNetworkStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPodNetworkStats indicates an expected call of GetPodNetworkStats
+func (mr *MockNetworkAPIsMockRecorder) GetPodNetworkStats(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPodNetworkStats", reflect.TypeOf((*MockNetworkAPIs)(nil).GetPodNetworkStats), arg0)
+}
+
+// GetPodRoutes mocks base method
+func (m *MockNetworkAPIs) GetPodRoutes(arg0 bool) ([]networkutils.PodRoute, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPodRoutes", arg0)
+	ret0, _ := ret[0].([]networkutils.PodRoute)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPodRoutes indicates an expected call of GetPodRoutes
+func (mr *MockNetworkAPIsMockRecorder) GetPodRoutes(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPodRoutes", reflect.TypeOf((*MockNetworkAPIs)(nil).GetPodRoutes), arg0)
+}
+
 // GetRuleList mocks base method
 func (m *MockNetworkAPIs) GetRuleList() ([]netlink.Rule, error) {
 	m.ctrl.T.Helper()
@@ -123,6 +273,179 @@ func (mr *MockNetworkAPIsMockRecorder) GetRuleListBySrc(arg0, arg1 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRuleListBySrc", reflect.TypeOf((*MockNetworkAPIs)(nil).GetRuleListBySrc), arg0, arg1)
 }
 
+// HostIptablesRulesModified mocks base method
+func (m *MockNetworkAPIs) HostIptablesRulesModified() (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HostIptablesRulesModified")
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HostIptablesRulesModified indicates an expected call of HostIptablesRulesModified
+func (mr *MockNetworkAPIsMockRecorder) HostIptablesRulesModified() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HostIptablesRulesModified", reflect.TypeOf((*MockNetworkAPIs)(nil).HostIptablesRulesModified))
+}
+
+// LimitPodConnections mocks base method
+func (m *MockNetworkAPIs) LimitPodConnections(arg0 string, arg1 networkutils.PodConnectionLimits, arg2 bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LimitPodConnections", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LimitPodConnections indicates an expected call of LimitPodConnections
+func (mr *MockNetworkAPIsMockRecorder) LimitPodConnections(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LimitPodConnections", reflect.TypeOf((*MockNetworkAPIs)(nil).LimitPodConnections), arg0, arg1, arg2)
+}
+
+// ListWarmVeths mocks base method
+func (m *MockNetworkAPIs) ListWarmVeths() ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWarmVeths")
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListWarmVeths indicates an expected call of ListWarmVeths
+func (mr *MockNetworkAPIsMockRecorder) ListWarmVeths() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWarmVeths", reflect.TypeOf((*MockNetworkAPIs)(nil).ListWarmVeths))
+}
+
+// ProbeAddress mocks base method
+func (m *MockNetworkAPIs) ProbeAddress(arg0 net.IP, arg1 time.Duration) (net.HardwareAddr, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProbeAddress", arg0, arg1)
+	ret0, _ := ret[0].(net.HardwareAddr)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ProbeAddress indicates an expected call of ProbeAddress
+func (mr *MockNetworkAPIsMockRecorder) ProbeAddress(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProbeAddress", reflect.TypeOf((*MockNetworkAPIs)(nil).ProbeAddress), arg0, arg1)
+}
+
+// ReconcileSysctls mocks base method
+func (m *MockNetworkAPIs) ReconcileSysctls() ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReconcileSysctls")
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReconcileSysctls indicates an expected call of ReconcileSysctls
+func (mr *MockNetworkAPIsMockRecorder) ReconcileSysctls() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReconcileSysctls", reflect.TypeOf((*MockNetworkAPIs)(nil).ReconcileSysctls))
+}
+
+// RestrictPodIngress mocks base method
+func (m *MockNetworkAPIs) RestrictPodIngress(arg0 string, arg1 bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestrictPodIngress", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestrictPodIngress indicates an expected call of RestrictPodIngress
+func (mr *MockNetworkAPIsMockRecorder) RestrictPodIngress(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestrictPodIngress", reflect.TypeOf((*MockNetworkAPIs)(nil).RestrictPodIngress), arg0, arg1)
+}
+
+// ScrubStaleRules mocks base method
+func (m *MockNetworkAPIs) ScrubStaleRules(arg0 func(net.IP) bool, arg1, arg2 bool) (networkutils.StaleRuleReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ScrubStaleRules", arg0, arg1, arg2)
+	ret0, _ := ret[0].(networkutils.StaleRuleReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ScrubStaleRules indicates an expected call of ScrubStaleRules
+func (mr *MockNetworkAPIsMockRecorder) ScrubStaleRules(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScrubStaleRules", reflect.TypeOf((*MockNetworkAPIs)(nil).ScrubStaleRules), arg0, arg1, arg2)
+}
+
+// SetConntrackMax mocks base method
+func (m *MockNetworkAPIs) SetConntrackMax(arg0 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetConntrackMax", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetConntrackMax indicates an expected call of SetConntrackMax
+func (mr *MockNetworkAPIsMockRecorder) SetConntrackMax(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetConntrackMax", reflect.TypeOf((*MockNetworkAPIs)(nil).SetConntrackMax), arg0)
+}
+
+// SetDynamicExcludeSNATCIDRs mocks base method
+func (m *MockNetworkAPIs) SetDynamicExcludeSNATCIDRs(arg0 []string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetDynamicExcludeSNATCIDRs", arg0)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// SetDynamicExcludeSNATCIDRs indicates an expected call of SetDynamicExcludeSNATCIDRs
+func (mr *MockNetworkAPIsMockRecorder) SetDynamicExcludeSNATCIDRs(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDynamicExcludeSNATCIDRs", reflect.TypeOf((*MockNetworkAPIs)(nil).SetDynamicExcludeSNATCIDRs), arg0)
+}
+
+// SetDynamicSNATCIDRs mocks base method
+func (m *MockNetworkAPIs) SetDynamicSNATCIDRs(arg0 []string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetDynamicSNATCIDRs", arg0)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// SetDynamicSNATCIDRs indicates an expected call of SetDynamicSNATCIDRs
+func (mr *MockNetworkAPIsMockRecorder) SetDynamicSNATCIDRs(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDynamicSNATCIDRs", reflect.TypeOf((*MockNetworkAPIs)(nil).SetDynamicSNATCIDRs), arg0)
+}
+
+// SetExcludeSNATCIDRs mocks base method
+func (m *MockNetworkAPIs) SetExcludeSNATCIDRs(arg0 []string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetExcludeSNATCIDRs", arg0)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// SetExcludeSNATCIDRs indicates an expected call of SetExcludeSNATCIDRs
+func (mr *MockNetworkAPIsMockRecorder) SetExcludeSNATCIDRs(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetExcludeSNATCIDRs", reflect.TypeOf((*MockNetworkAPIs)(nil).SetExcludeSNATCIDRs), arg0)
+}
+
+// SetPodSNATIPs mocks base method
+func (m *MockNetworkAPIs) SetPodSNATIPs(arg0 map[string]string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPodSNATIPs", arg0)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// SetPodSNATIPs indicates an expected call of SetPodSNATIPs
+func (mr *MockNetworkAPIsMockRecorder) SetPodSNATIPs(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPodSNATIPs", reflect.TypeOf((*MockNetworkAPIs)(nil).SetPodSNATIPs), arg0)
+}
+
 // SetupENINetwork mocks base method
 func (m *MockNetworkAPIs) SetupENINetwork(arg0, arg1 string, arg2 int, arg3 string) error {
 	m.ctrl.T.Helper()
@@ -140,7 +463,7 @@ func (mr *MockNetworkAPIsMockRecorder) SetupENINetwork(arg0, arg1, arg2, arg3 in
 // SetupHostNetwork mocks base method
 func (m *MockNetworkAPIs) SetupHostNetwork(arg0 []string, arg1 string, arg2 *net.IP, arg3, arg4, arg5 bool) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SetupHostNetwork", arg0, arg1, arg2, arg3)
+	ret := m.ctrl.Call(m, "SetupHostNetwork", arg0, arg1, arg2, arg3, arg4, arg5)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
@@ -148,13 +471,139 @@ func (m *MockNetworkAPIs) SetupHostNetwork(arg0 []string, arg1 string, arg2 *net
 // SetupHostNetwork indicates an expected call of SetupHostNetwork
 func (mr *MockNetworkAPIsMockRecorder) SetupHostNetwork(arg0, arg1, arg2, arg3, arg4, arg5 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetupHostNetwork", reflect.TypeOf((*MockNetworkAPIs)(nil).SetupHostNetwork), arg0, arg1, arg2, arg3)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetupHostNetwork", reflect.TypeOf((*MockNetworkAPIs)(nil).SetupHostNetwork), arg0, arg1, arg2, arg3, arg4, arg5)
+}
+
+// SetupNAT64Route mocks base method
+func (m *MockNetworkAPIs) SetupNAT64Route(arg0 string, arg1 *net.IPNet, arg2 net.IP) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetupNAT64Route", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetupNAT64Route indicates an expected call of SetupNAT64Route
+func (mr *MockNetworkAPIsMockRecorder) SetupNAT64Route(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetupNAT64Route", reflect.TypeOf((*MockNetworkAPIs)(nil).SetupNAT64Route), arg0, arg1, arg2)
+}
+
+// SetupPodConnectionLimits mocks base method
+func (m *MockNetworkAPIs) SetupPodConnectionLimits(arg0, arg1 bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetupPodConnectionLimits", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetupPodConnectionLimits indicates an expected call of SetupPodConnectionLimits
+func (mr *MockNetworkAPIsMockRecorder) SetupPodConnectionLimits(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetupPodConnectionLimits", reflect.TypeOf((*MockNetworkAPIs)(nil).SetupPodConnectionLimits), arg0, arg1)
+}
+
+// SetupPodIMDSBlock mocks base method
+func (m *MockNetworkAPIs) SetupPodIMDSBlock(arg0, arg1 bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetupPodIMDSBlock", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetupPodIMDSBlock indicates an expected call of SetupPodIMDSBlock
+func (mr *MockNetworkAPIsMockRecorder) SetupPodIMDSBlock(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetupPodIMDSBlock", reflect.TypeOf((*MockNetworkAPIs)(nil).SetupPodIMDSBlock), arg0, arg1)
+}
+
+// SetupPodIngressAllowlist mocks base method
+func (m *MockNetworkAPIs) SetupPodIngressAllowlist(arg0 bool, arg1 []string, arg2 bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetupPodIngressAllowlist", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetupPodIngressAllowlist indicates an expected call of SetupPodIngressAllowlist
+func (mr *MockNetworkAPIsMockRecorder) SetupPodIngressAllowlist(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetupPodIngressAllowlist", reflect.TypeOf((*MockNetworkAPIs)(nil).SetupPodIngressAllowlist), arg0, arg1, arg2)
+}
+
+// SetupWarmVeth mocks base method
+func (m *MockNetworkAPIs) SetupWarmVeth(arg0 string, arg1 *net.IPNet, arg2 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetupWarmVeth", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetupWarmVeth indicates an expected call of SetupWarmVeth
+func (mr *MockNetworkAPIsMockRecorder) SetupWarmVeth(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetupWarmVeth", reflect.TypeOf((*MockNetworkAPIs)(nil).SetupWarmVeth), arg0, arg1, arg2)
+}
+
+// TeardownPodRouteRules mocks base method
+func (m *MockNetworkAPIs) TeardownPodRouteRules(arg0 *net.IPNet, arg1 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TeardownPodRouteRules", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TeardownPodRouteRules indicates an expected call of TeardownPodRouteRules
+func (mr *MockNetworkAPIsMockRecorder) TeardownPodRouteRules(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TeardownPodRouteRules", reflect.TypeOf((*MockNetworkAPIs)(nil).TeardownPodRouteRules), arg0, arg1)
+}
+
+// UnblockPodIMDSAccess mocks base method
+func (m *MockNetworkAPIs) UnblockPodIMDSAccess(arg0 string, arg1 bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnblockPodIMDSAccess", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnblockPodIMDSAccess indicates an expected call of UnblockPodIMDSAccess
+func (mr *MockNetworkAPIsMockRecorder) UnblockPodIMDSAccess(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnblockPodIMDSAccess", reflect.TypeOf((*MockNetworkAPIs)(nil).UnblockPodIMDSAccess), arg0, arg1)
+}
+
+// UnlimitPodConnections mocks base method
+func (m *MockNetworkAPIs) UnlimitPodConnections(arg0 string, arg1 bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnlimitPodConnections", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnlimitPodConnections indicates an expected call of UnlimitPodConnections
+func (mr *MockNetworkAPIsMockRecorder) UnlimitPodConnections(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnlimitPodConnections", reflect.TypeOf((*MockNetworkAPIs)(nil).UnlimitPodConnections), arg0, arg1)
+}
+
+// UnrestrictPodIngress mocks base method
+func (m *MockNetworkAPIs) UnrestrictPodIngress(arg0 string, arg1 bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnrestrictPodIngress", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnrestrictPodIngress indicates an expected call of UnrestrictPodIngress
+func (mr *MockNetworkAPIsMockRecorder) UnrestrictPodIngress(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnrestrictPodIngress", reflect.TypeOf((*MockNetworkAPIs)(nil).UnrestrictPodIngress), arg0, arg1)
 }
 
 // UpdateHostIptablesRules mocks base method
 func (m *MockNetworkAPIs) UpdateHostIptablesRules(arg0 []string, arg1 string, arg2 *net.IP, arg3, arg4 bool) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateHostIptablesRules", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "UpdateHostIptablesRules", arg0, arg1, arg2, arg3, arg4)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
@@ -162,7 +611,7 @@ func (m *MockNetworkAPIs) UpdateHostIptablesRules(arg0 []string, arg1 string, ar
 // UpdateHostIptablesRules indicates an expected call of UpdateHostIptablesRules
 func (mr *MockNetworkAPIsMockRecorder) UpdateHostIptablesRules(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateHostIptablesRules", reflect.TypeOf((*MockNetworkAPIs)(nil).UpdateHostIptablesRules), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateHostIptablesRules", reflect.TypeOf((*MockNetworkAPIs)(nil).UpdateHostIptablesRules), arg0, arg1, arg2, arg3, arg4)
 }
 
 // UpdateRuleListBySrc mocks base method
@@ -0,0 +1,114 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package networkutils
+
+import (
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/netlinkwrapper"
+)
+
+// NetlinkTransaction applies a batch of related link, route and rule changes, and remembers how to undo each of them,
+// so that a setup failing halfway can roll back the changes it already made rather than leave them half programmed
+// for CHECK and DEL to stumble on. Changes that were already in place, like a rule that exists, are not undone.
+type NetlinkTransaction struct {
+	netLink netlinkwrapper.NetLink
+	undo    []func() error
+}
+
+// NewNetlinkTransaction returns an empty transaction applying its changes through netLink
+func NewNetlinkTransaction(netLink netlinkwrapper.NetLink) *NetlinkTransaction {
+	return &NetlinkTransaction{netLink: netLink}
+}
+
+// LinkAdd adds the link, which a rollback deletes
+func (tx *NetlinkTransaction) LinkAdd(link netlink.Link) error {
+	if err := tx.netLink.LinkAdd(link); err != nil {
+		return err
+	}
+	tx.undo = append(tx.undo, func() error {
+		if err := tx.netLink.LinkDel(link); err != nil {
+			if _, ok := err.(netlink.LinkNotFoundError); !ok {
+				return errors.Wrapf(err, "failed to delete link %s", link.Attrs().Name)
+			}
+		}
+		return nil
+	})
+	return nil
+}
+
+// RouteAdd adds the route, which a rollback deletes
+func (tx *NetlinkTransaction) RouteAdd(route *netlink.Route) error {
+	if err := tx.netLink.RouteAdd(route); err != nil {
+		return err
+	}
+	tx.undoRoute(route)
+	return nil
+}
+
+// RouteReplace adds or replaces the route. A rollback deletes it, since the route it replaced, if any, was stale.
+func (tx *NetlinkTransaction) RouteReplace(route *netlink.Route) error {
+	if err := tx.netLink.RouteReplace(route); err != nil {
+		return err
+	}
+	tx.undoRoute(route)
+	return nil
+}
+
+func (tx *NetlinkTransaction) undoRoute(route *netlink.Route) {
+	undone := *route
+	tx.undo = append(tx.undo, func() error {
+		if err := tx.netLink.RouteDel(&undone); err != nil && !netlinkwrapper.IsNotExistsError(err) {
+			return errors.Wrapf(err, "failed to delete route %s", undone.String())
+		}
+		return nil
+	})
+}
+
+// RuleAdd adds the rule, which a rollback deletes. A rule that already exists is left to its owner.
+func (tx *NetlinkTransaction) RuleAdd(rule *netlink.Rule) error {
+	if err := tx.netLink.RuleAdd(rule); err != nil {
+		if isRuleExistsError(err) {
+			return nil
+		}
+		return err
+	}
+	undone := *rule
+	tx.undo = append(tx.undo, func() error {
+		if err := tx.netLink.RuleDel(&undone); err != nil && !containsNoSuchRule(err) {
+			return errors.Wrapf(err, "failed to delete rule %s", undone.String())
+		}
+		return nil
+	})
+	return nil
+}
+
+// Commit keeps the changes made so far, which a later rollback doesn't undo
+func (tx *NetlinkTransaction) Commit() {
+	tx.undo = nil
+}
+
+// Rollback undoes the changes made since the transaction was created or last committed, in reverse order. It goes on
+// after a change fails to be undone, and returns the first error.
+func (tx *NetlinkTransaction) Rollback() error {
+	var firstErr error
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		if err := tx.undo[i](); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	tx.undo = nil
+	return errors.Wrap(firstErr, "netlink rollback")
+}
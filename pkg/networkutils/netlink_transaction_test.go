@@ -0,0 +1,80 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package networkutils
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+)
+
+func TestNetlinkTransactionRollback(t *testing.T) {
+	ctrl, mockNetLink, _, _, _, _ := setup(t)
+	defer ctrl.Finish()
+
+	link := &netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Name: "dummy0"}}
+	route := &netlink.Route{LinkIndex: 12, Dst: &net.IPNet{IP: net.ParseIP("10.0.0.42"), Mask: net.CIDRMask(32, 32)}}
+	rule := netlink.NewRule()
+	rule.Dst = route.Dst
+	existingRule := netlink.NewRule()
+	existingRule.Src = route.Dst
+
+	gomock.InOrder(
+		mockNetLink.EXPECT().LinkAdd(link).Return(nil),
+		mockNetLink.EXPECT().RouteReplace(route).Return(nil),
+		mockNetLink.EXPECT().RuleAdd(rule).Return(nil),
+		mockNetLink.EXPECT().RuleAdd(existingRule).Return(syscall.EEXIST),
+		// the existing rule is left in place, the rest is undone in reverse order
+		mockNetLink.EXPECT().RuleDel(rule).Return(nil),
+		mockNetLink.EXPECT().RouteDel(route).Return(errors.New("some error")),
+		mockNetLink.EXPECT().LinkDel(link).Return(netlink.LinkNotFoundError{}),
+	)
+
+	tx := NewNetlinkTransaction(mockNetLink)
+	assert.NoError(t, tx.LinkAdd(link))
+	assert.NoError(t, tx.RouteReplace(route))
+	assert.NoError(t, tx.RuleAdd(rule))
+	assert.NoError(t, tx.RuleAdd(existingRule))
+	err := tx.Rollback()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "some error")
+
+	// a second rollback has nothing left to undo
+	assert.NoError(t, tx.Rollback())
+}
+
+func TestNetlinkTransactionCommit(t *testing.T) {
+	ctrl, mockNetLink, _, _, _, _ := setup(t)
+	defer ctrl.Finish()
+
+	route := &netlink.Route{LinkIndex: 12, Dst: &net.IPNet{IP: net.ParseIP("10.0.0.42"), Mask: net.CIDRMask(32, 32)}}
+	rule := netlink.NewRule()
+	rule.Dst = route.Dst
+
+	gomock.InOrder(
+		mockNetLink.EXPECT().RouteAdd(route).Return(nil),
+		mockNetLink.EXPECT().RuleAdd(rule).Return(errors.New("some error")),
+	)
+
+	tx := NewNetlinkTransaction(mockNetLink)
+	assert.NoError(t, tx.RouteAdd(route))
+	assert.Error(t, tx.RuleAdd(rule))
+	tx.Commit()
+	assert.NoError(t, tx.Rollback())
+}
@@ -15,15 +15,19 @@
 package networkutils
 
 import (
+	"crypto/sha1"
 	"encoding/binary"
 	"encoding/csv"
+	"encoding/hex"
 	"fmt"
 	"math"
 	"net"
 	"os"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -40,6 +44,7 @@ import (
 	"github.com/coreos/go-iptables/iptables"
 	"github.com/vishvananda/netlink"
 
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/ipsetwrapper"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/netlinkwrapper"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/nswrapper"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/procsyswrapper"
@@ -49,8 +54,14 @@ const (
 	// Local rule, needs to come after the pod ENI rules
 	localRulePriority = 20
 
+	// Rule to the main table for traffic to a pod IP, set up by the CNI plugin
+	toPodRulePriority = 512
+
 	// 513 - 1023, can be used priority lower than toPodRulePriority but higher than default nonVPC CIDR rule
 
+	// Rules from a pod IP to the destinations annotated with vpc.amazonaws.com/egress-routes, set up by the CNI plugin
+	podEgressRulePriority = 768
+
 	// 1024 is reserved for (ip rule not to <VPC's subnet> table main)
 	hostRulePriority = 1024
 
@@ -60,6 +71,10 @@ const (
 	// Main route table
 	mainRoutingTable = unix.RT_TABLE_MAIN
 
+	// The CNI plugin routes the traffic of a pod using a branch ENI through table vlanID + podVlanRouteTableBase. The
+	// route tables of the ENIs are below it.
+	podVlanRouteTableBase = 100
+
 	// Local route table
 	localRouteTable = unix.RT_TABLE_LOCAL
 
@@ -73,6 +88,28 @@ const (
 	// Defaults to empty.
 	envExcludeSNATCIDRs = "AWS_VPC_K8S_CNI_EXCLUDE_SNAT_CIDRS"
 
+	// envSNATCIDRs is a comma separated list of ipv4 CIDRs that are still SNATed to the primary IP of the node when
+	// AWS_VPC_K8S_CNI_EXTERNALSNAT is set, e.g. on-premises ranges that only accept the node IPs while the internet
+	// traffic goes through a NAT gateway. The CIDRs are matched through the snatCIDRsSet ipset. Defaults to empty.
+	envSNATCIDRs = "AWS_VPC_K8S_CNI_SNAT_CIDRS"
+
+	// envNonRoutableCIDRs is a comma separated list of ipv4 CIDRs whose addresses are not routable outside the VPC,
+	// typically a 100.64.0.0/10 (CG-NAT) secondary CIDR. The traffic of the pods in these CIDRs to destinations outside
+	// the VPC is always SNATed to the primary IP, despite AWS_VPC_K8S_CNI_EXCLUDE_SNAT_CIDRS and
+	// AWS_VPC_K8S_CNI_EXTERNALSNAT. Defaults to empty.
+	envNonRoutableCIDRs = "AWS_VPC_K8S_CNI_NON_ROUTABLE_CIDRS"
+
+	// envPathMTUCIDRs is a comma separated list of <ipv4 CIDR>=<MTU> entries, giving the path MTU to destinations
+	// reached over links with a smaller MTU than the ENIs, e.g. VPC peering or a VPN. The MSS of the TCP connections
+	// between the pods and these destinations is clamped to fit. Defaults to empty.
+	envPathMTUCIDRs = "AWS_VPC_K8S_CNI_PATH_MTU_CIDRS"
+
+	// envENIRouteMetrics and envENIRouteMTUs are comma separated lists of <route>=<metric> and <route>=<MTU> entries,
+	// giving the metric and MTU of the gateway and default routes of the route tables of the secondary ENIs, e.g.
+	// "default=100" to let a VPN or overlay agent add a preferred default route to the same table. Default to empty.
+	envENIRouteMetrics = "AWS_VPC_K8S_CNI_ENI_ROUTE_METRICS"
+	envENIRouteMTUs    = "AWS_VPC_K8S_CNI_ENI_ROUTE_MTUS"
+
 	// This environment is used to specify weather the SNAT rule added to iptables should randomize port allocation for
 	// outgoing connections. If set to "hashrandom" the SNAT iptables rule will have the "--random" flag added to it.
 	// Use "prng" if you want to use pseudo random numbers, i.e. "--random-fully".
@@ -134,14 +171,101 @@ type NetworkAPIs interface {
 		v4Enabled bool, v6Enabled bool) error
 	// SetupENINetwork performs ENI level network configuration. Not needed on the primary ENI
 	SetupENINetwork(eniIP string, mac string, deviceNumber int, subnetCIDR string) error
+	// ReconcileSysctls sets back the kernel settings applied to the interfaces that something else changed, and returns
+	// their keys
+	ReconcileSysctls() ([]string, error)
+	// CheckENINetwork returns the name of the link of a secondary ENI and the first problem of the network set up for
+	// it by SetupENINetwork, or "" if there is none
+	CheckENINetwork(eniIP string, mac string, deviceNumber int) (string, string, error)
+	// FindRouteTableConflicts returns a description of each route another agent added to the route table of an ENI
+	FindRouteTableConflicts(mac string, deviceNumber int) ([]string, error)
 	// UpdateHostIptablesRules updates the nat table iptables rules on the host
 	UpdateHostIptablesRules(vpcCIDRs []string, primaryMAC string, primaryAddr *net.IP, v4Enabled bool, v6Enabled bool) error
 	UseExternalSNAT() bool
 	GetExcludeSNATCIDRs() []string
+	SetDynamicExcludeSNATCIDRs(cidrs []string) bool
+	SetExcludeSNATCIDRs(cidrs []string) bool
+	// SetDynamicSNATCIDRs replaces the destinations SNATed to the primary IP despite external SNAT, on top of the ones
+	// configured through AWS_VPC_K8S_CNI_SNAT_CIDRS
+	SetDynamicSNATCIDRs(cidrs []string) bool
+	// SetPodSNATIPs replaces the addresses the traffic of some pods is SNATed to, keyed by pod IP
+	SetPodSNATIPs(podSNATIPs map[string]string) bool
+	// HostIptablesRulesModified returns true if the IPv4 rules programmed by UpdateHostIptablesRules changed since
+	// they were last programmed
+	HostIptablesRulesModified() (bool, error)
 	GetRuleList() ([]netlink.Rule, error)
 	GetRuleListBySrc(ruleList []netlink.Rule, src net.IPNet) ([]netlink.Rule, error)
 	UpdateRuleListBySrc(ruleList []netlink.Rule, src net.IPNet) error
 	GetLinkByMac(mac string, retryInterval time.Duration) (netlink.Link, error)
+	// CheckKernelSettings returns a description of each kernel setting that breaks pod networking
+	CheckKernelSettings(primaryMAC string, v4Enabled bool, v6Enabled bool) []string
+	// GetPodRoutes returns the pod IPs routed to host-side veth devices, as set up by the CNI plugin
+	GetPodRoutes(v6Enabled bool) ([]PodRoute, error)
+	// GetHostVethName returns the name of the host-side veth device of a pod
+	GetHostVethName(namespace, podName string) string
+	// GetPodNetworkStats returns the counters of the host-side veth device of a pod
+	GetPodNetworkStats(hostVeth string) (*PodNetworkStats, error)
+	// CountConntrackFlows returns the number of conntrack flows of each IPv4, or IPv6, address
+	CountConntrackFlows(v6 bool) (map[string]int, error)
+	// ScrubStaleRules deletes the pod rules and routes left behind for IPs that isAssigned reports as not assigned
+	ScrubStaleRules(isAssigned func(ip net.IP) bool, v6Enabled bool, dryRun bool) (StaleRuleReport, error)
+	// TeardownPodRouteRules deletes the route to a pod IP and its rules, as the CNI plugin does on DEL, for the
+	// teardowns the CNI plugin failed
+	TeardownPodRouteRules(addr *net.IPNet, routeTable int) error
+	// SetupNAT64Route routes the NAT64 prefix out of the primary ENI, through gateway or, if it is nil, through the IPv6
+	// default gateway of the primary ENI
+	SetupNAT64Route(primaryMAC string, prefix *net.IPNet, gateway net.IP) error
+	// SetupWarmVeth creates a veth pair whose host side routes ip the way the CNI plugin does for a pod on the ENI with
+	// the given device number, so that a pod only has to take the peer
+	SetupWarmVeth(hostVeth string, ip *net.IPNet, deviceNumber int) error
+	// ListWarmVeths returns the names of the host side of the veth pairs created by SetupWarmVeth
+	ListWarmVeths() ([]string, error)
+	// DeleteWarmVeth deletes a veth pair created by SetupWarmVeth
+	DeleteWarmVeth(hostVeth string) error
+	// ProbeAddress sends an ARP probe, or an IPv6 neighbor solicitation, for ip and returns the hardware address of
+	// the host outside of the node that answers for it before timeout, or nil if none does
+	ProbeAddress(ip net.IP, timeout time.Duration) (net.HardwareAddr, error)
+	// SetupPodIMDSBlock creates the iptables chain that BlockPodIMDSAccess adds the pod rules to, or deletes it when
+	// not enabled
+	SetupPodIMDSBlock(enabled bool, v6Enabled bool) error
+	// BlockPodIMDSAccess drops the traffic from the host veth of a pod to the instance metadata service
+	BlockPodIMDSAccess(hostVeth string, v6Enabled bool) error
+	// UnblockPodIMDSAccess deletes the rule added by BlockPodIMDSAccess
+	UnblockPodIMDSAccess(hostVeth string, v6Enabled bool) error
+	// GetPodIMDSBlockedPackets returns the number of packets to the instance metadata service dropped for each host
+	// veth
+	GetPodIMDSBlockedPackets(v6Enabled bool) (map[string]uint64, error)
+	// SetupPodIngressAllowlist creates the iptables chains that make the pods restricted by RestrictPodIngress only
+	// accept traffic from allowedCIDRs, or deletes them when not enabled
+	SetupPodIngressAllowlist(enabled bool, allowedCIDRs []string, v6Enabled bool) error
+	// RestrictPodIngress makes the host veth of a pod only forward traffic from the allowed CIDRs to it
+	RestrictPodIngress(hostVeth string, v6Enabled bool) error
+	// UnrestrictPodIngress deletes the rule added by RestrictPodIngress
+	UnrestrictPodIngress(hostVeth string, v6Enabled bool) error
+	// SetupPodConnectionLimits creates the iptables chain that LimitPodConnections adds the pod rules to, or deletes
+	// it when not enabled
+	SetupPodConnectionLimits(enabled bool, v6Enabled bool) error
+	// LimitPodConnections drops the new connections from the host veth of a pod above limits
+	LimitPodConnections(hostVeth string, limits PodConnectionLimits, v6Enabled bool) error
+	// UnlimitPodConnections deletes the rules added by LimitPodConnections
+	UnlimitPodConnections(hostVeth string, v6Enabled bool) error
+	// GetConntrackUsage returns the number of entries of the conntrack table of the node and its size
+	GetConntrackUsage() (int, int, error)
+	// SetConntrackMax sets the size of the conntrack table of the node
+	SetConntrackMax(max int) error
+}
+
+// PodRoute is the route the CNI plugin sets up to the IP of a pod
+type PodRoute struct {
+	IP       net.IP
+	HostVeth string
+}
+
+// StaleRuleReport counts the stale pod rules and routes found by ScrubStaleRules. They are only deleted when it
+// doesn't run dry.
+type StaleRuleReport struct {
+	Rules  int
+	Routes int
 }
 
 type linuxNetwork struct {
@@ -153,12 +277,51 @@ type linuxNetwork struct {
 	mtu                     int
 	vethPrefix              string
 	podSGEnforcingMode      sgpp.EnforcingMode
+	// primaryInterfaceOverride is the name of the primary interface, when it is set instead of found by MAC address
+	primaryInterfaceOverride string
+	// routeTableBase is added to the device index of a secondary ENI to get its route table
+	routeTableBase int
+	// hostPodHairpinRules routes the traffic between the primary IP of the node and the pods through the main table
+	hostPodHairpinRules bool
+	// interfaceSysctls are the kernel settings of AWS_VPC_K8S_CNI_INTERFACE_SYSCTLS
+	interfaceSysctls []interfaceSysctl
 
 	netLink     netlinkwrapper.NetLink
 	ns          nswrapper.NS
 	newIptables func(IPProtocol iptables.Protocol) (iptablesIface, error)
 	mainENIMark uint32
 	procSys     procsyswrapper.ProcSys
+	sysctls     *procsyswrapper.Manager
+
+	// dynamicExcludeSNATCIDRs are CIDRs excluded from SNAT in addition to excludeSNATCIDRs. They are pushed by ipamd
+	// at runtime, e.g. from a ConfigMap, and can change without restarting the aws-node pod.
+	dynamicExcludeSNATCIDRs []string
+	excludeSNATCIDRsLock    sync.RWMutex
+
+	// podSNATIPs maps pod IPs to the address their non-VPC traffic is SNATed to instead of the primary IP of the node,
+	// e.g. a secondary IP of the primary ENI associated with an Elastic IP. They are pushed by ipamd at runtime.
+	podSNATIPs     map[string]string
+	podSNATIPsLock sync.RWMutex
+
+	// snatCIDRs and dynamicSNATCIDRs are the destinations SNATed to the primary IP when useExternalSNAT is set. The
+	// dynamic ones are pushed by ipamd at runtime, like dynamicExcludeSNATCIDRs.
+	snatCIDRs        []string
+	dynamicSNATCIDRs []string
+	snatCIDRsLock    sync.RWMutex
+
+	// nonRoutableCIDRs are the pod CIDRs always SNATed to the primary IP for destinations outside the VPC
+	nonRoutableCIDRs []string
+	ipset            ipsetwrapper.IPSet
+
+	// pathMTUs are the destinations of AWS_VPC_K8S_CNI_PATH_MTU_CIDRS, with the MTU of the path to them
+	pathMTUs []pathMTU
+	// eniRouteOptions are the metrics and MTUs of the routes of the ENI route tables, by route
+	eniRouteOptions map[string]eniRouteOption
+
+	// iptablesChecksum is the checksum of the CNI-owned IPv4 iptables rules after the last update, used to detect rules
+	// modified or flushed by other agents. It is empty until the rules are programmed.
+	iptablesChecksum     string
+	iptablesChecksumLock sync.Mutex
 }
 
 type iptablesIface interface {
@@ -167,6 +330,7 @@ type iptablesIface interface {
 	Append(table, chain string, rulespec ...string) error
 	Delete(table, chain string, rulespec ...string) error
 	List(table, chain string) ([]string, error)
+	ListWithCounters(table, chain string) ([]string, error)
 	NewChain(table, chain string) error
 	ClearChain(table, chain string) error
 	DeleteChain(table, chain string) error
@@ -182,50 +346,99 @@ const (
 	randomPRNGSNAT
 )
 
+// namespaceSNATChain holds the SNAT rules of the pods in podSNATIPs. The last AWS-SNAT-CHAIN jumps to it before the
+// SNAT rule to the primary IP.
+const namespaceSNATChain = "AWS-SNAT-CHAIN-NAMESPACE"
+
+// nonRoutableSNATChain SNATs the non-VPC traffic of the pods in nonRoutableCIDRs. POSTROUTING jumps to it before
+// AWS-SNAT-CHAIN-0, whose exclusions don't apply to these pods.
+const nonRoutableSNATChain = "AWS-SNAT-CHAIN-NON-ROUTABLE"
+
+// snatCIDRsSet is the ipset of the destinations that are SNATed to the primary IP despite external SNAT
+const snatCIDRsSet = "AWS-SNAT-CIDRS"
+
+// mssClampChain holds the TCP MSS clamping rules of the destinations in AWS_VPC_K8S_CNI_PATH_MTU_CIDRS. The mangle
+// FORWARD chain jumps to it for the TCP SYN packets.
+const mssClampChain = "AWS-MSS-CLAMP"
+
+// tcpIPv4HeadersSize is the size of the IPv4 and TCP headers without options, which the MSS leaves out of the MTU
+const tcpIPv4HeadersSize = 40
+
+// pathMTU is the MTU of the path to the destinations of a CIDR
+type pathMTU struct {
+	cidr string
+	mtu  int
+}
+
 // New creates a linuxNetwork object
 func New() NetworkAPIs {
+	procSys := procsyswrapper.NewProcSys()
 	return &linuxNetwork{
-		useExternalSNAT:         useExternalSNAT(),
-		excludeSNATCIDRs:        getExcludeSNATCIDRs(),
-		typeOfSNAT:              typeOfSNAT(),
-		nodePortSupportEnabled:  nodePortSupportEnabled(),
-		shouldConfigureRpFilter: shouldConfigureRpFilter(),
-		mainENIMark:             getConnmark(),
-		mtu:                     GetEthernetMTU(""),
-		vethPrefix:              getVethPrefixName(),
-		podSGEnforcingMode:      sgpp.LoadEnforcingModeFromEnv(),
-
-		netLink: netlinkwrapper.NewNetLink(),
+		useExternalSNAT:          useExternalSNAT(),
+		excludeSNATCIDRs:         getExcludeSNATCIDRs(),
+		snatCIDRs:                getSNATCIDRs(),
+		nonRoutableCIDRs:         NonRoutableCIDRs(),
+		typeOfSNAT:               typeOfSNAT(),
+		nodePortSupportEnabled:   nodePortSupportEnabled(),
+		shouldConfigureRpFilter:  shouldConfigureRpFilter(),
+		mainENIMark:              getConnmark(),
+		mtu:                      GetEthernetMTU(""),
+		vethPrefix:               getVethPrefixName(),
+		podSGEnforcingMode:       sgpp.LoadEnforcingModeFromEnv(),
+		primaryInterfaceOverride: getPrimaryInterfaceOverride(),
+		routeTableBase:           getRouteTableBase(),
+		hostPodHairpinRules:      hostPodHairpinRulesEnabled(),
+		interfaceSysctls:         parseInterfaceSysctls(os.Getenv(envInterfaceSysctls)),
+		pathMTUs:                 parsePathMTUs(os.Getenv(envPathMTUCIDRs)),
+		eniRouteOptions:          parseENIRouteOptions(os.Getenv(envENIRouteMetrics), os.Getenv(envENIRouteMTUs)),
+
+		netLink: &auditedNetLink{NetLink: netlinkwrapper.NewNetLink()},
 		ns:      nswrapper.NewNS(),
 		newIptables: func(IPProtocol iptables.Protocol) (iptablesIface, error) {
 			ipt, err := iptables.NewWithProtocol(IPProtocol)
-			return ipt, err
+			if err != nil {
+				return nil, err
+			}
+			return newAuditedIptables(ipt, IPProtocol), nil
 		},
-		procSys: procsyswrapper.NewProcSys(),
+		procSys: procSys,
+		sysctls: procsyswrapper.NewManager(procSys),
+		ipset:   ipsetwrapper.NewIPSet(),
 	}
 }
 
-// find out the primary interface name
-func findPrimaryInterfaceName(primaryMAC string) (string, error) {
-	log.Debugf("Trying to find primary interface that has mac : %s", primaryMAC)
-
-	interfaces, err := net.Interfaces()
-	if err != nil {
-		log.Errorf("Failed to read all interfaces: %v", err)
-		return "", errors.Wrapf(err, "findPrimaryInterfaceName: failed to find interfaces")
+// CheckKernelSettings returns a description of each kernel setting that breaks pod networking: forwarding must be
+// enabled, and with node port support the primary interface can't use strict reverse path filtering.
+func (n *linuxNetwork) CheckKernelSettings(primaryMAC string, v4Enabled bool, v6Enabled bool) []string {
+	var problems []string
+	check := func(key string, ok func(value string) bool, reason string) {
+		value, err := n.procSys.Get(key)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("failed to read %s: %v", key, err))
+			return
+		}
+		if value = strings.TrimSpace(value); !ok(value) {
+			problems = append(problems, fmt.Sprintf("%s is %s, %s", key, value, reason))
+		}
 	}
+	isEnabled := func(value string) bool { return value == "1" }
 
-	for _, intf := range interfaces {
-		log.Debugf("Discovered interface: %v, mac: %v", intf.Name, intf.HardwareAddr)
-
-		if strings.Compare(primaryMAC, intf.HardwareAddr.String()) == 0 {
-			log.Infof("Discovered primary interface: %s", intf.Name)
-			return intf.Name, nil
+	if v4Enabled {
+		check("net/ipv4/ip_forward", isEnabled, "pod traffic can't be forwarded")
+		if n.nodePortSupportEnabled {
+			primaryIntf, err := n.findPrimaryInterfaceName(primaryMAC)
+			if err != nil {
+				problems = append(problems, err.Error())
+			} else {
+				check("net/ipv4/conf/"+primaryIntf+"/rp_filter", func(value string) bool { return value != "1" },
+					"strict reverse path filtering drops NodePort traffic to pods on secondary ENIs")
+			}
 		}
 	}
-
-	log.Errorf("No primary interface found")
-	return "", errors.New("no primary interface found")
+	if v6Enabled {
+		check("net/ipv6/conf/all/forwarding", isEnabled, "pod traffic can't be forwarded")
+	}
+	return problems
 }
 
 func (n *linuxNetwork) enableIPv6() (err error) {
@@ -274,16 +487,15 @@ func (n *linuxNetwork) SetupHostNetwork(vpcv4CIDRs []string, primaryMAC string,
 	log.Info("Setting up host network... ")
 
 	var err error
-	primaryIntf := "eth0"
 	//RP Filter setting is only needed if IPv4 mode is enabled.
 	if v4Enabled && n.nodePortSupportEnabled {
-		primaryIntf, err = findPrimaryInterfaceName(primaryMAC)
+		primaryIntf, err := n.findPrimaryInterfaceName(primaryMAC)
 		if err != nil {
 			return errors.Wrapf(err, "failed to SetupHostNetwork")
 		}
-		// If node port support is enabled, configure the kernel's reverse path filter check on eth0 for "loose"
-		// filtering. This is required because
-		// - NodePorts are exposed on eth0
+		// If node port support is enabled, configure the kernel's reverse path filter check on the primary interface
+		// for "loose" filtering. This is required because
+		// - NodePorts are exposed on the primary interface
 		// - The kernel's RPF check happens after incoming packets to NodePorts are DNATted to the pod IP.
 		// - For pods assigned to secondary ENIs, the routing table includes source-based routing. When the kernel does
 		//   the RPF check, it looks up the route using the pod IP as the source.
@@ -295,7 +507,7 @@ func (n *linuxNetwork) SetupHostNetwork(vpcv4CIDRs []string, primaryMAC string,
 
 		if n.shouldConfigureRpFilter {
 			log.Debugf("Setting RPF for primary interface: %s", primaryIntfRPFilter)
-			err = n.procSys.Set(primaryIntfRPFilter, rpFilterLoose)
+			err = n.sysctls.Apply(primaryIntfRPFilter, rpFilterLoose)
 			if err != nil {
 				return errors.Wrapf(err, "failed to configure %s RPF check", primaryIntf)
 			}
@@ -303,13 +515,19 @@ func (n *linuxNetwork) SetupHostNetwork(vpcv4CIDRs []string, primaryMAC string,
 			log.Infof("Skip updating RPF for primary interface: %s", primaryIntfRPFilter)
 		}
 	}
+	// The settings set explicitly come last, so that they win over the defaults above
+	if v4Enabled && len(n.interfaceSysctls) > 0 {
+		if err := n.applyHostSysctls(primaryMAC); err != nil {
+			return errors.Wrapf(err, "failed to SetupHostNetwork")
+		}
+	}
 
 	link, err := linkByMac(primaryMAC, n.netLink, retryLinkByMacInterval)
 	if err != nil {
 		return errors.Wrapf(err, "setupHostNetwork: failed to find the link primary ENI with MAC address %s", primaryMAC)
 	}
 	if err = n.netLink.LinkSetMTU(link, n.mtu); err != nil {
-		return errors.Wrapf(err, "setupHostNetwork: failed to set MTU to %d for %s", n.mtu, primaryIntf)
+		return errors.Wrapf(err, "setupHostNetwork: failed to set MTU to %d for %s", n.mtu, link.Attrs().Name)
 	}
 
 	ipFamily := unix.AF_INET
@@ -369,6 +587,12 @@ func (n *linuxNetwork) SetupHostNetwork(vpcv4CIDRs []string, primaryMAC string,
 		}
 	}
 
+	if v4Enabled && primaryAddr != nil {
+		if err := n.setupHostPodRules(primaryAddr); err != nil {
+			return err
+		}
+	}
+
 	return n.updateHostIptablesRules(vpcv4CIDRs, primaryMAC, primaryAddr, v4Enabled, v6Enabled)
 }
 
@@ -380,7 +604,7 @@ func (n *linuxNetwork) UpdateHostIptablesRules(vpcCIDRs []string, primaryMAC str
 
 func (n *linuxNetwork) updateHostIptablesRules(vpcCIDRs []string, primaryMAC string, primaryAddr *net.IP, v4Enabled bool,
 	v6Enabled bool) error {
-	primaryIntf, err := findPrimaryInterfaceName(primaryMAC)
+	primaryIntf, err := n.findPrimaryInterfaceName(primaryMAC)
 	if err != nil {
 		return errors.Wrapf(err, "failed to SetupHostNetwork")
 	}
@@ -415,10 +639,84 @@ func (n *linuxNetwork) updateHostIptablesRules(vpcCIDRs []string, primaryMAC str
 		if err := n.updateIptablesRules(iptablesConnmarkRules, ipt); err != nil {
 			return err
 		}
+
+		iptablesMSSClampRules, err := n.buildIptablesMSSClampRules(ipt)
+		if err != nil {
+			return err
+		}
+		if err := n.updateIptablesRules(iptablesMSSClampRules, ipt); err != nil {
+			return err
+		}
+		n.recordIptablesChecksum(ipt)
 	}
 	return nil
 }
 
+// recordIptablesChecksum remembers the checksum of the rules just programmed. If it can't be computed, it is cleared
+// so that HostIptablesRulesModified doesn't report a change.
+func (n *linuxNetwork) recordIptablesChecksum(ipt iptablesIface) {
+	checksum, err := ownedIptablesChecksum(ipt)
+	if err != nil {
+		log.Warnf("Failed to compute the checksum of the host iptables rules: %v", err)
+	}
+	n.iptablesChecksumLock.Lock()
+	defer n.iptablesChecksumLock.Unlock()
+	n.iptablesChecksum = checksum
+}
+
+// HostIptablesRulesModified compares the CNI-owned IPv4 iptables rules with the checksum recorded when they were last
+// programmed. It returns false until the rules have been programmed once.
+func (n *linuxNetwork) HostIptablesRulesModified() (bool, error) {
+	n.iptablesChecksumLock.Lock()
+	expected := n.iptablesChecksum
+	n.iptablesChecksumLock.Unlock()
+	if expected == "" {
+		return false, nil
+	}
+
+	ipt, err := n.newIptables(iptables.ProtocolIPv4)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to create iptables")
+	}
+	checksum, err := ownedIptablesChecksum(ipt)
+	if err != nil {
+		return false, err
+	}
+	return checksum != expected, nil
+}
+
+// ownedIptablesChecksum returns a checksum of the AWS- chains of the nat and mangle tables, and of the rules that the
+// CNI adds to the other chains
+func ownedIptablesChecksum(ipt iptablesIface) (string, error) {
+	hash := sha1.New()
+	for _, table := range []string{"nat", "mangle"} {
+		chains, err := ipt.ListChains(table)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to list iptables %s chains", table)
+		}
+		sort.Strings(chains)
+		for _, chain := range chains {
+			rules, err := ipt.List(table, chain)
+			if err != nil {
+				return "", errors.Wrapf(err, "failed to list iptables %s chain %s", table, chain)
+			}
+			for _, rule := range rules {
+				if isOwnedIptablesRule(chain, rule) {
+					fmt.Fprintf(hash, "%s %s\n", table, rule)
+				}
+			}
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// isOwnedIptablesRule returns true for the rules of the AWS- chains, and for the rules of other chains that carry an
+// AWS comment or jump to an AWS- chain, as listed by `iptables -S`
+func isOwnedIptablesRule(chain, rule string) bool {
+	return strings.HasPrefix(chain, "AWS-") || strings.Contains(rule, `--comment "AWS`) ||
+		strings.Contains(rule, "--comment AWS") || strings.Contains(rule, "-j AWS-")
+}
+
 func (n *linuxNetwork) buildIptablesSNATRules(vpcCIDRs []string, primaryAddr *net.IP, primaryIntf string, ipt iptablesIface) ([]iptablesRule, error) {
 	type snatCIDR struct {
 		cidr        string
@@ -429,12 +727,21 @@ func (n *linuxNetwork) buildIptablesSNATRules(vpcCIDRs []string, primaryAddr *ne
 		log.Debugf("Adding %s CIDR to NAT chain", cidr)
 		allCIDRs = append(allCIDRs, snatCIDR{cidr: cidr, isExclusion: false})
 	}
-	for _, cidr := range n.excludeSNATCIDRs {
+	for _, cidr := range n.allExcludeSNATCIDRs() {
 		log.Debugf("Adding %s Excluded CIDR to NAT chain", cidr)
 		allCIDRs = append(allCIDRs, snatCIDR{cidr: cidr, isExclusion: true})
 	}
 
 	log.Debugf("Total CIDRs to program - %d", len(allCIDRs))
+	// With external SNAT, the chains are only needed for the destinations that are SNATed anyway
+	snatCIDRs := n.externalSNATExceptions()
+	snatEnabled := !n.useExternalSNAT || len(snatCIDRs) > 0
+	if len(snatCIDRs) > 0 {
+		if err := n.syncSNATCIDRsSet(snatCIDRs); err != nil {
+			return []iptablesRule{}, err
+		}
+	}
+
 	// build IPTABLES chain for SNAT of non-VPC outbound traffic and excluded CIDRs
 	var chains []string
 	for i := 0; i <= len(allCIDRs); i++ {
@@ -452,7 +759,7 @@ func (n *linuxNetwork) buildIptablesSNATRules(vpcCIDRs []string, primaryAddr *ne
 	log.Debugf("Setup Host Network: iptables -A POSTROUTING -m comment --comment \"AWS SNAT CHAIN\" -j AWS-SNAT-CHAIN-0")
 	iptableRules = append(iptableRules, iptablesRule{
 		name:        "first SNAT rules for non-VPC outbound traffic",
-		shouldExist: !n.useExternalSNAT,
+		shouldExist: snatEnabled,
 		table:       "nat",
 		chain:       "POSTROUTING",
 		rule: []string{
@@ -471,7 +778,7 @@ func (n *linuxNetwork) buildIptablesSNATRules(vpcCIDRs []string, primaryAddr *ne
 
 		iptableRules = append(iptableRules, iptablesRule{
 			name:        curName,
-			shouldExist: !n.useExternalSNAT,
+			shouldExist: snatEnabled,
 			table:       "nat",
 			chain:       curChain,
 			rule: []string{
@@ -484,20 +791,21 @@ func (n *linuxNetwork) buildIptablesSNATRules(vpcCIDRs []string, primaryAddr *ne
 		"-m", "comment", "--comment", "AWS, SNAT",
 		"-m", "addrtype", "!", "--dst-type", "LOCAL",
 		"-j", "SNAT", "--to-source", primaryAddr.String()}
-	if n.typeOfSNAT == randomHashSNAT {
-		snatRule = append(snatRule, "--random")
+	snatRule = append(snatRule, n.snatRandomFlags(ipt)...)
+
+	lastChain := chains[len(chains)-1]
+	namespaceSNATRules, err := n.buildNamespaceSNATRules(lastChain, ipt)
+	if err != nil {
+		return []iptablesRule{}, err
 	}
-	if n.typeOfSNAT == randomPRNGSNAT {
-		if ipt.HasRandomFully() {
-			snatRule = append(snatRule, "--random-fully")
-		} else {
-			log.Warn("prng (--random-fully) requested, but iptables version does not support it. " +
-				"Falling back to hashrandom (--random)")
-			snatRule = append(snatRule, "--random")
-		}
+	iptableRules = append(iptableRules, namespaceSNATRules...)
+
+	nonRoutableSNATRules, err := n.buildNonRoutableSNATRules(vpcCIDRs, primaryAddr, ipt)
+	if err != nil {
+		return []iptablesRule{}, err
 	}
+	iptableRules = append(iptableRules, nonRoutableSNATRules...)
 
-	lastChain := chains[len(chains)-1]
 	iptableRules = append(iptableRules, iptablesRule{
 		name:        "last SNAT rule for non-VPC outbound traffic",
 		shouldExist: !n.useExternalSNAT,
@@ -505,8 +813,26 @@ func (n *linuxNetwork) buildIptablesSNATRules(vpcCIDRs []string, primaryAddr *ne
 		chain:       lastChain,
 		rule:        snatRule,
 	})
+	// The rule can only be checked while the ipset exists, so it isn't listed when it shouldn't exist. It is then
+	// removed as a stale rule.
+	if len(snatCIDRs) > 0 {
+		rule := []string{"!", "-o", "vlan+",
+			"-m", "set", "--match-set", snatCIDRsSet, "dst",
+			"-m", "comment", "--comment", "AWS, SNAT CIDRS",
+			"-m", "addrtype", "!", "--dst-type", "LOCAL",
+			"-j", "SNAT", "--to-source", primaryAddr.String()}
+		iptableRules = append(iptableRules, iptablesRule{
+			name:        "SNAT rule for the destinations in AWS_VPC_K8S_CNI_SNAT_CIDRS",
+			shouldExist: true,
+			table:       "nat",
+			chain:       lastChain,
+			rule:        append(rule, n.snatRandomFlags(ipt)...),
+		})
+	}
 
-	snatStaleRules, err := computeStaleIptablesRules(ipt, "nat", "AWS-SNAT-CHAIN", iptableRules, chains)
+	// The namespace and non-routable chains are kept when no pod needs them anymore, only their rules are stale
+	snatStaleRules, err := computeStaleIptablesRules(ipt, "nat", "AWS-SNAT-CHAIN", iptableRules,
+		append(chains, namespaceSNATChain, nonRoutableSNATChain))
 	if err != nil {
 		return []iptablesRule{}, err
 	}
@@ -552,13 +878,132 @@ func (n *linuxNetwork) buildIptablesSNATRules(vpcCIDRs []string, primaryAddr *ne
 	return iptableRules, nil
 }
 
+// snatRandomFlags returns the flags for the port allocation set through AWS_VPC_K8S_CNI_RANDOMIZESNAT
+func (n *linuxNetwork) snatRandomFlags(ipt iptablesIface) []string {
+	switch n.typeOfSNAT {
+	case randomHashSNAT:
+		return []string{"--random"}
+	case randomPRNGSNAT:
+		if ipt.HasRandomFully() {
+			return []string{"--random-fully"}
+		}
+		log.Warn("prng (--random-fully) requested, but iptables version does not support it. " +
+			"Falling back to hashrandom (--random)")
+		return []string{"--random"}
+	}
+	return nil
+}
+
+// buildNamespaceSNATRules returns the rules that SNAT the non-VPC traffic of the pods in podSNATIPs to their own
+// address. The jump to namespaceSNATChain has to come before the SNAT rule to the primary IP in lastChain, so it is
+// inserted rather than appended.
+func (n *linuxNetwork) buildNamespaceSNATRules(lastChain string, ipt iptablesIface) ([]iptablesRule, error) {
+	n.podSNATIPsLock.RLock()
+	defer n.podSNATIPsLock.RUnlock()
+	if len(n.podSNATIPs) == 0 {
+		return nil, nil
+	}
+
+	log.Debugf("Setup Host Network: iptables -N %s -t nat", namespaceSNATChain)
+	if err := ipt.NewChain("nat", namespaceSNATChain); err != nil && !containChainExistErr(err) {
+		log.Errorf("ipt.NewChain error for chain [%s]: %v", namespaceSNATChain, err)
+		return nil, errors.Wrapf(err, "host network setup: failed to add chain")
+	}
+	iptableRules := []iptablesRule{{
+		name:        "jump to the namespace SNAT rules",
+		shouldExist: !n.useExternalSNAT,
+		table:       "nat",
+		chain:       lastChain,
+		rule: []string{
+			"-m", "comment", "--comment", "AWS SNAT CHAIN NAMESPACE", "-j", namespaceSNATChain,
+		},
+		insert: true,
+	}}
+
+	podIPs := make([]string, 0, len(n.podSNATIPs))
+	for podIP := range n.podSNATIPs {
+		podIPs = append(podIPs, podIP)
+	}
+	sort.Strings(podIPs)
+	randomFlags := n.snatRandomFlags(ipt)
+	for _, podIP := range podIPs {
+		rule := []string{"-s", podIP + "/32",
+			"-m", "comment", "--comment", "AWS, NAMESPACE SNAT",
+			"-m", "addrtype", "!", "--dst-type", "LOCAL",
+			"-j", "SNAT", "--to-source", n.podSNATIPs[podIP]}
+		iptableRules = append(iptableRules, iptablesRule{
+			name:        fmt.Sprintf("namespace SNAT rule for %s", podIP),
+			shouldExist: !n.useExternalSNAT,
+			table:       "nat",
+			chain:       namespaceSNATChain,
+			rule:        append(rule, randomFlags...),
+		})
+	}
+	return iptableRules, nil
+}
+
+// buildNonRoutableSNATRules returns the rules that SNAT the traffic of the pods in nonRoutableCIDRs to the primary IP
+// for every destination outside the VPC, including the excluded CIDRs, and with external SNAT. The jump to
+// nonRoutableSNATChain has to come before the one to AWS-SNAT-CHAIN-0, so it is inserted rather than appended.
+func (n *linuxNetwork) buildNonRoutableSNATRules(vpcCIDRs []string, primaryAddr *net.IP, ipt iptablesIface) ([]iptablesRule, error) {
+	enabled := len(n.nonRoutableCIDRs) > 0
+	iptableRules := []iptablesRule{{
+		name:        "jump to the non-routable SNAT rules",
+		shouldExist: enabled,
+		table:       "nat",
+		chain:       "POSTROUTING",
+		rule: []string{
+			"-m", "comment", "--comment", "AWS SNAT CHAIN NON-ROUTABLE", "-j", nonRoutableSNATChain,
+		},
+		insert: true,
+	}}
+	if !enabled {
+		return iptableRules, nil
+	}
+
+	log.Debugf("Setup Host Network: iptables -N %s -t nat", nonRoutableSNATChain)
+	if err := ipt.NewChain("nat", nonRoutableSNATChain); err != nil && !containChainExistErr(err) {
+		log.Errorf("ipt.NewChain error for chain [%s]: %v", nonRoutableSNATChain, err)
+		return nil, errors.Wrapf(err, "host network setup: failed to add chain")
+	}
+	for _, cidr := range vpcCIDRs {
+		iptableRules = append(iptableRules, iptablesRule{
+			name:        fmt.Sprintf("non-routable SNAT exemption for VPC CIDR %s", cidr),
+			shouldExist: true,
+			table:       "nat",
+			chain:       nonRoutableSNATChain,
+			rule: []string{
+				"-d", cidr, "-m", "comment", "--comment", "AWS SNAT NON-ROUTABLE, VPC CIDR", "-j", "RETURN",
+			},
+		})
+	}
+	randomFlags := n.snatRandomFlags(ipt)
+	for _, cidr := range n.nonRoutableCIDRs {
+		rule := []string{"-s", cidr, "!", "-o", "vlan+",
+			"-m", "comment", "--comment", "AWS, SNAT NON-ROUTABLE",
+			"-m", "addrtype", "!", "--dst-type", "LOCAL",
+			"-j", "SNAT", "--to-source", primaryAddr.String()}
+		iptableRules = append(iptableRules, iptablesRule{
+			name:        fmt.Sprintf("non-routable SNAT rule for %s", cidr),
+			shouldExist: true,
+			table:       "nat",
+			chain:       nonRoutableSNATChain,
+			rule:        append(rule, randomFlags...),
+		})
+	}
+	return iptableRules, nil
+}
+
 func (n *linuxNetwork) buildIptablesConnmarkRules(vpcCIDRs []string, ipt iptablesIface) ([]iptablesRule, error) {
 	var allCIDRs []string
 	allCIDRs = append(allCIDRs, vpcCIDRs...)
-	allCIDRs = append(allCIDRs, n.excludeSNATCIDRs...)
-	excludeCIDRs := sets.NewString(n.excludeSNATCIDRs...)
+	excludeSNATCIDRs := n.allExcludeSNATCIDRs()
+	allCIDRs = append(allCIDRs, excludeSNATCIDRs...)
+	excludeCIDRs := sets.NewString(excludeSNATCIDRs...)
 
 	log.Debugf("Total CIDRs to exempt from connmark rules - %d", len(allCIDRs))
+	snatCIDRs := n.externalSNATExceptions()
+	snatEnabled := !n.useExternalSNAT || len(snatCIDRs) > 0
 	var chains []string
 	for i := 0; i <= len(allCIDRs); i++ {
 		chain := fmt.Sprintf("AWS-CONNMARK-CHAIN-%d", i)
@@ -574,7 +1019,7 @@ func (n *linuxNetwork) buildIptablesConnmarkRules(vpcCIDRs []string, ipt iptable
 	log.Debugf("Setup Host Network: iptables -t nat -A PREROUTING -i %s+ -m comment --comment \"AWS, outbound connections\" -m state --state NEW -j AWS-CONNMARK-CHAIN-0", n.vethPrefix)
 	iptableRules = append(iptableRules, iptablesRule{
 		name:        "connmark rule for non-VPC outbound traffic",
-		shouldExist: !n.useExternalSNAT,
+		shouldExist: snatEnabled,
 		table:       "nat",
 		chain:       "PREROUTING",
 		rule: []string{
@@ -594,7 +1039,7 @@ func (n *linuxNetwork) buildIptablesConnmarkRules(vpcCIDRs []string, ipt iptable
 
 		iptableRules = append(iptableRules, iptablesRule{
 			name:        curName,
-			shouldExist: !n.useExternalSNAT,
+			shouldExist: snatEnabled,
 			table:       "nat",
 			chain:       curChain,
 			rule: []string{
@@ -612,6 +1057,20 @@ func (n *linuxNetwork) buildIptablesConnmarkRules(vpcCIDRs []string, ipt iptable
 			"--set-xmark", fmt.Sprintf("%#x/%#x", n.mainENIMark, n.mainENIMark),
 		},
 	})
+	// The destinations SNATed to the primary IP have to leave through the primary ENI as well
+	if len(snatCIDRs) > 0 {
+		iptableRules = append(iptableRules, iptablesRule{
+			name:        "connmark rule for the destinations in AWS_VPC_K8S_CNI_SNAT_CIDRS",
+			shouldExist: true,
+			table:       "nat",
+			chain:       chains[len(chains)-1],
+			rule: []string{
+				"-m", "set", "--match-set", snatCIDRsSet, "dst",
+				"-m", "comment", "--comment", "AWS, CONNMARK SNAT CIDRS", "-j", "CONNMARK",
+				"--set-xmark", fmt.Sprintf("%#x/%#x", n.mainENIMark, n.mainENIMark),
+			},
+		})
+	}
 
 	// Force delete existing restore mark rule so that the subsequent rule gets added to the end
 	iptableRules = append(iptableRules, iptablesRule{
@@ -627,7 +1086,7 @@ func (n *linuxNetwork) buildIptablesConnmarkRules(vpcCIDRs []string, ipt iptable
 
 	iptableRules = append(iptableRules, iptablesRule{
 		name:        "connmark to fwmark copy",
-		shouldExist: !n.useExternalSNAT,
+		shouldExist: snatEnabled,
 		table:       "nat",
 		chain:       "PREROUTING",
 		rule: []string{
@@ -646,6 +1105,58 @@ func (n *linuxNetwork) buildIptablesConnmarkRules(vpcCIDRs []string, ipt iptable
 	return iptableRules, nil
 }
 
+// buildIptablesMSSClampRules clamps the MSS of the TCP connections to and from the destinations of
+// AWS_VPC_K8S_CNI_PATH_MTU_CIDRS whose path MTU is smaller than the ENI MTU, which no pod MTU can exceed. Without it, the
+// packets of a pod using jumbo frames are dropped on the way, since the path MTU discovery often doesn't make it back
+// through a VPN or a firewall.
+func (n *linuxNetwork) buildIptablesMSSClampRules(ipt iptablesIface) ([]iptablesRule, error) {
+	log.Debugf("Setup Host Network: iptables -N %s -t mangle", mssClampChain)
+	if err := ipt.NewChain("mangle", mssClampChain); err != nil && !containChainExistErr(err) {
+		log.Errorf("ipt.NewChain error for chain [%s]: %v", mssClampChain, err)
+		return nil, errors.Wrapf(err, "host network setup: failed to add chain")
+	}
+
+	var clampRules []iptablesRule
+	for _, path := range n.pathMTUs {
+		if path.mtu >= n.mtu {
+			log.Debugf("Not clamping the MSS to %s, its path MTU %d is not below the ENI MTU %d", path.cidr, path.mtu, n.mtu)
+			continue
+		}
+		mss := path.mtu - tcpIPv4HeadersSize
+		for _, direction := range []string{"-d", "-s"} {
+			clampRules = append(clampRules, iptablesRule{
+				name:        fmt.Sprintf("MSS clamping rule for %s %s", direction, path.cidr),
+				shouldExist: true,
+				table:       "mangle",
+				chain:       mssClampChain,
+				rule: []string{
+					direction, path.cidr, "-p", "tcp", "-m", "tcp", "--tcp-flags", "SYN,RST", "SYN",
+					"-m", "comment", "--comment", "AWS, MSS CLAMP",
+					"-m", "tcpmss", "--mss", fmt.Sprintf("%d:65535", mss+1), "-j", "TCPMSS", "--set-mss", strconv.Itoa(mss),
+				},
+			})
+		}
+	}
+
+	iptableRules := []iptablesRule{{
+		name:        "jump to the MSS clamping rules",
+		shouldExist: len(clampRules) > 0,
+		table:       "mangle",
+		chain:       "FORWARD",
+		rule: []string{
+			"-p", "tcp", "-m", "tcp", "--tcp-flags", "SYN,RST", "SYN",
+			"-m", "comment", "--comment", "AWS, MSS CLAMP", "-j", mssClampChain,
+		},
+	}}
+	iptableRules = append(iptableRules, clampRules...)
+
+	staleRules, err := computeStaleIptablesRules(ipt, "mangle", mssClampChain, iptableRules, []string{mssClampChain})
+	if err != nil {
+		return nil, err
+	}
+	return append(iptableRules, staleRules...), nil
+}
+
 func (n *linuxNetwork) updateIptablesRules(iptableRules []iptablesRule, ipt iptablesIface) error {
 	for _, rule := range iptableRules {
 		log.Debugf("execute iptable rule : %s", rule.name)
@@ -658,7 +1169,11 @@ func (n *linuxNetwork) updateIptablesRules(iptableRules []iptablesRule, ipt ipta
 		}
 
 		if !exists && rule.shouldExist {
-			err = ipt.Append(rule.table, rule.chain, rule.rule...)
+			if rule.insert {
+				err = ipt.Insert(rule.table, rule.chain, 1, rule.rule...)
+			} else {
+				err = ipt.Append(rule.table, rule.chain, rule.rule...)
+			}
 			if err != nil {
 				log.Errorf("host network setup: failed to add %v, %v", rule, err)
 				return errors.Wrapf(err, "host network setup: failed to add %v", rule)
@@ -747,6 +1262,8 @@ type iptablesRule struct {
 	shouldExist  bool
 	table, chain string
 	rule         []string
+	// insert puts the rule first in its chain instead of last
+	insert bool
 }
 
 func (r iptablesRule) String() string {
@@ -773,6 +1290,11 @@ func GetConfigForDebug() map[string]interface{} {
 		envConfigureRpfilter: shouldConfigureRpFilter(),
 		envConnmark:          getConnmark(),
 		envExcludeSNATCIDRs:  getExcludeSNATCIDRs(),
+		envSNATCIDRs:         getSNATCIDRs(),
+		envNonRoutableCIDRs:  NonRoutableCIDRs(),
+		envPathMTUCIDRs:      os.Getenv(envPathMTUCIDRs),
+		envENIRouteMetrics:   os.Getenv(envENIRouteMetrics),
+		envENIRouteMTUs:      os.Getenv(envENIRouteMTUs),
 		envExternalSNAT:      useExternalSNAT(),
 		envMTU:               GetEthernetMTU(""),
 		envVethPrefix:        getVethPrefixName(),
@@ -795,20 +1317,142 @@ func useExternalSNAT() bool {
 // GetExcludeSNATCIDRs returns a list of cidrs that should be excluded from SNAT if UseExternalSNAT is false,
 // otherwise it returns an empty list.
 func (n *linuxNetwork) GetExcludeSNATCIDRs() []string {
-	return getExcludeSNATCIDRs()
+	if useExternalSNAT() {
+		return nil
+	}
+	return n.allExcludeSNATCIDRs()
 }
 
-func getExcludeSNATCIDRs() []string {
-	if useExternalSNAT() {
+// SetExcludeSNATCIDRs replaces the CIDRs configured through AWS_VPC_K8S_CNI_EXCLUDE_SNAT_CIDRS, e.g. when ipamd
+// reloads its config file. Like SetDynamicExcludeSNATCIDRs, it returns true if the set changed.
+func (n *linuxNetwork) SetExcludeSNATCIDRs(cidrs []string) bool {
+	n.excludeSNATCIDRsLock.Lock()
+	defer n.excludeSNATCIDRsLock.Unlock()
+	if sets.NewString(n.excludeSNATCIDRs...).Equal(sets.NewString(cidrs...)) {
+		return false
+	}
+	n.excludeSNATCIDRs = cidrs
+	return true
+}
+
+// SetDynamicExcludeSNATCIDRs replaces the set of CIDRs excluded from SNAT on top of the ones configured through
+// AWS_VPC_K8S_CNI_EXCLUDE_SNAT_CIDRS. It returns true if the set changed, in which case the caller is expected to
+// reprogram the host iptables rules with UpdateHostIptablesRules.
+func (n *linuxNetwork) SetDynamicExcludeSNATCIDRs(cidrs []string) bool {
+	n.excludeSNATCIDRsLock.Lock()
+	defer n.excludeSNATCIDRsLock.Unlock()
+	if sets.NewString(n.dynamicExcludeSNATCIDRs...).Equal(sets.NewString(cidrs...)) {
+		return false
+	}
+	n.dynamicExcludeSNATCIDRs = cidrs
+	return true
+}
+
+// SetPodSNATIPs replaces the addresses that the non-VPC traffic of some pods is SNATed to, keyed by pod IP. It returns
+// true if the map changed, in which case the caller is expected to reprogram the host iptables rules.
+func (n *linuxNetwork) SetPodSNATIPs(podSNATIPs map[string]string) bool {
+	n.podSNATIPsLock.Lock()
+	defer n.podSNATIPsLock.Unlock()
+	if reflect.DeepEqual(n.podSNATIPs, podSNATIPs) || (len(n.podSNATIPs) == 0 && len(podSNATIPs) == 0) {
+		return false
+	}
+	n.podSNATIPs = podSNATIPs
+	return true
+}
+
+// SetDynamicSNATCIDRs replaces the set of CIDRs SNATed to the primary IP despite external SNAT, on top of the ones
+// configured through AWS_VPC_K8S_CNI_SNAT_CIDRS. Like SetDynamicExcludeSNATCIDRs, it returns true if the set changed.
+func (n *linuxNetwork) SetDynamicSNATCIDRs(cidrs []string) bool {
+	n.snatCIDRsLock.Lock()
+	defer n.snatCIDRsLock.Unlock()
+	if sets.NewString(n.dynamicSNATCIDRs...).Equal(sets.NewString(cidrs...)) {
+		return false
+	}
+	n.dynamicSNATCIDRs = cidrs
+	return true
+}
+
+// externalSNATExceptions returns the destinations SNATed to the primary IP despite external SNAT, or nil when SNAT
+// isn't external
+func (n *linuxNetwork) externalSNATExceptions() []string {
+	if !n.useExternalSNAT {
 		return nil
 	}
+	n.snatCIDRsLock.RLock()
+	defer n.snatCIDRsLock.RUnlock()
+	return mergeCIDRs(n.snatCIDRs, n.dynamicSNATCIDRs)
+}
 
-	excludeCIDRs := os.Getenv(envExcludeSNATCIDRs)
-	if excludeCIDRs == "" {
+// syncSNATCIDRsSet makes snatCIDRsSet hold exactly cidrs. Entries are added before the stale ones are removed, so
+// that a CIDR kept across an update is never missing.
+func (n *linuxNetwork) syncSNATCIDRsSet(cidrs []string) error {
+	if err := n.ipset.Create(snatCIDRsSet, "hash:net"); err != nil {
+		return errors.Wrapf(err, "host network setup: failed to create ipset %s", snatCIDRsSet)
+	}
+	current, err := n.ipset.List(snatCIDRsSet)
+	if err != nil {
+		return errors.Wrapf(err, "host network setup: failed to list ipset %s", snatCIDRsSet)
+	}
+	// ipset prints host entries without their /32
+	existing := sets.NewString()
+	for _, entry := range current {
+		if !strings.Contains(entry, "/") {
+			entry += "/32"
+		}
+		existing.Insert(entry)
+	}
+	desired := sets.NewString(cidrs...)
+	for _, cidr := range desired.Difference(existing).List() {
+		log.Debugf("Setup Host Network: ipset add %s %s", snatCIDRsSet, cidr)
+		if err := n.ipset.Add(snatCIDRsSet, cidr); err != nil {
+			return errors.Wrapf(err, "host network setup: failed to add %s to ipset %s", cidr, snatCIDRsSet)
+		}
+	}
+	for _, cidr := range existing.Difference(desired).List() {
+		log.Debugf("Setup Host Network: ipset del %s %s", snatCIDRsSet, cidr)
+		if err := n.ipset.Del(snatCIDRsSet, cidr); err != nil {
+			return errors.Wrapf(err, "host network setup: failed to delete %s from ipset %s", cidr, snatCIDRsSet)
+		}
+	}
+	return nil
+}
+
+// allExcludeSNATCIDRs returns the static and dynamic SNAT exclusions, without duplicates
+func (n *linuxNetwork) allExcludeSNATCIDRs() []string {
+	n.excludeSNATCIDRsLock.RLock()
+	defer n.excludeSNATCIDRsLock.RUnlock()
+	return mergeCIDRs(n.excludeSNATCIDRs, n.dynamicExcludeSNATCIDRs)
+}
+
+func mergeCIDRs(first []string, second []string) []string {
+	if len(second) == 0 {
+		return first
+	}
+	seen := sets.NewString()
+	var merged []string
+	for _, cidr := range append(append([]string{}, first...), second...) {
+		if seen.Has(cidr) {
+			continue
+		}
+		seen.Insert(cidr)
+		merged = append(merged, cidr)
+	}
+	return merged
+}
+
+func getExcludeSNATCIDRs() []string {
+	if useExternalSNAT() {
 		return nil
 	}
+	return ParseExcludeSNATCIDRs(os.Getenv(envExcludeSNATCIDRs))
+}
+
+// ParseExcludeSNATCIDRs parses a comma or whitespace separated list of IPv4 CIDRs. Invalid entries are logged and skipped.
+func ParseExcludeSNATCIDRs(excludeCIDRs string) []string {
 	var cidrs []string
-	for _, excludeCIDR := range strings.Split(excludeCIDRs, ",") {
+	for _, excludeCIDR := range strings.FieldsFunc(excludeCIDRs, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n' || r == '\t'
+	}) {
 		_, parseCIDR, err := net.ParseCIDR(excludeCIDR)
 		if err != nil {
 			log.Errorf("getExcludeSNATCIDRs : ignoring %v is not a valid IPv4 CIDR", excludeCIDR)
@@ -819,6 +1463,47 @@ func getExcludeSNATCIDRs() []string {
 	return cidrs
 }
 
+// NonRoutableCIDRs returns the CIDRs of AWS_VPC_K8S_CNI_NON_ROUTABLE_CIDRS, whose addresses are only routable inside
+// the VPC
+func NonRoutableCIDRs() []string {
+	return ParseExcludeSNATCIDRs(os.Getenv(envNonRoutableCIDRs))
+}
+
+// getSNATCIDRs returns the CIDRs of AWS_VPC_K8S_CNI_SNAT_CIDRS. They are only used with external SNAT.
+func getSNATCIDRs() []string {
+	if !useExternalSNAT() {
+		return nil
+	}
+	return ParseExcludeSNATCIDRs(os.Getenv(envSNATCIDRs))
+}
+
+// parsePathMTUs parses a comma or whitespace separated list of <ipv4 CIDR>=<MTU> entries. Invalid entries are logged
+// and skipped.
+func parsePathMTUs(value string) []pathMTU {
+	var pathMTUs []pathMTU
+	for _, entry := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n' || r == '\t'
+	}) {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			log.Errorf("Ignoring %s entry %q, expected <CIDR>=<MTU>", envPathMTUCIDRs, entry)
+			continue
+		}
+		_, cidr, err := net.ParseCIDR(parts[0])
+		if err != nil || cidr.IP.To4() == nil {
+			log.Errorf("Ignoring %s entry %q, %q is not a valid IPv4 CIDR", envPathMTUCIDRs, entry, parts[0])
+			continue
+		}
+		mtu, err := strconv.Atoi(parts[1])
+		if err != nil || mtu < minimumMTU || mtu > maximumMTU {
+			log.Errorf("Ignoring %s entry %q, the MTU must be between %d and %d", envPathMTUCIDRs, entry, minimumMTU, maximumMTU)
+			continue
+		}
+		pathMTUs = append(pathMTUs, pathMTU{cidr: cidr.String(), mtu: mtu})
+	}
+	return pathMTUs
+}
+
 func typeOfSNAT() snatType {
 	defaultValue := randomPRNGSNAT
 	strValue := os.Getenv(envRandomizeSNAT)
@@ -919,15 +1604,27 @@ func linkByMac(mac string, netLink netlinkwrapper.NetLink, retryInterval time.Du
 
 // SetupENINetwork adds default route to route table (eni-<eni_table>), so it does not need to be called on the primary ENI
 func (n *linuxNetwork) SetupENINetwork(eniIP string, eniMAC string, deviceNumber int, eniSubnetCIDR string) error {
-	return setupENINetwork(eniIP, eniMAC, deviceNumber, eniSubnetCIDR, n.netLink, retryLinkByMacInterval, retryRouteAddInterval, n.mtu)
+	if err := releaseENILink(eniMAC, n.netLink); err != nil {
+		return err
+	}
+	err := setupENINetwork(eniIP, eniMAC, deviceNumber, n.routeTableBase, eniSubnetCIDR, n.netLink, retryLinkByMacInterval,
+		retryRouteAddInterval, n.mtu, n.eniRouteOptions)
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(n.applySecondaryENISysctls(eniMAC), "setupENINetwork: failed to apply the secondary ENI settings")
 }
 
-func setupENINetwork(eniIP string, eniMAC string, deviceNumber int, eniSubnetCIDR string, netLink netlinkwrapper.NetLink,
-	retryLinkByMacInterval time.Duration, retryRouteAddInterval time.Duration, mtu int) error {
+func setupENINetwork(eniIP string, eniMAC string, deviceNumber int, routeTableBase int, eniSubnetCIDR string,
+	netLink netlinkwrapper.NetLink, retryLinkByMacInterval time.Duration, retryRouteAddInterval time.Duration, mtu int,
+	routeOptions map[string]eniRouteOption) error {
 	if deviceNumber == 0 {
 		return errors.New("setupENINetwork should never be called on the primary ENI")
 	}
-	tableNumber := deviceNumber + 1
+	tableNumber, err := eniRouteTable(routeTableBase, deviceNumber)
+	if err != nil {
+		return errors.Wrap(err, "setupENINetwork")
+	}
 	log.Infof("Setting up network for an ENI with IP address %s, MAC address %s, CIDR %s and route table %d",
 		eniIP, eniMAC, eniSubnetCIDR, tableNumber)
 	link, err := linkByMac(eniMAC, netLink, retryLinkByMacInterval)
@@ -998,14 +1695,22 @@ func setupENINetwork(eniIP string, eniMAC string, deviceNumber int, eniSubnetCID
 			Table:     tableNumber,
 		},
 	}
+	routeOptions[eniRouteGateway].apply(&routes[0])
+	routeOptions[eniRouteDefault].apply(&routes[1])
+	// The gateway route is rolled back if the default route fails, so that the table doesn't end up half programmed
+	tx := NewNetlinkTransaction(netLink)
 	for _, r := range routes {
-		err := netLink.RouteDel(&r)
+		// Without a metric, the route is deleted whatever its metric is, e.g. one set before the metric was changed
+		old := r
+		old.Priority = 0
+		old.MTU = 0
+		err := netLink.RouteDel(&old)
 		if err != nil && !netlinkwrapper.IsNotExistsError(err) {
 			return errors.Wrap(err, "setupENINetwork: failed to clean up old routes")
 		}
 
 		err = retry.NWithBackoff(retry.NewSimpleBackoff(500*time.Millisecond, retryRouteAddInterval, 0.15, 2.0), maxRetryRouteAdd, func() error {
-			if err := netLink.RouteReplace(&r); err != nil {
+			if err := tx.RouteReplace(&r); err != nil {
 				log.Debugf("Not able to set route %s/0 via %s table %d", r.Dst.IP.String(), gw.String(), tableNumber)
 				return errors.Wrapf(err, "setupENINetwork: unable to replace route entry %s", r.Dst.IP.String())
 			}
@@ -1013,6 +1718,9 @@ func setupENINetwork(eniIP string, eniMAC string, deviceNumber int, eniSubnetCID
 			return nil
 		})
 		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				log.Warnf("setupENINetwork: %v", rollbackErr)
+			}
 			return err
 		}
 	}
@@ -1053,6 +1761,247 @@ func IncrementIPv4Addr(ip net.IP) (net.IP, error) {
 	return nextIPv4, nil
 }
 
+// GetPodRoutes returns the host routes in the main table to a single IP through a veth device named with the veth prefix.
+// They are set up by the CNI plugin for each pod, and outlive ipamd and the checkpoint file.
+func (n *linuxNetwork) GetPodRoutes(v6Enabled bool) ([]PodRoute, error) {
+	routes, hostVeths, err := n.listPodRoutes(v6Enabled)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetPodRoutes")
+	}
+	var podRoutes []PodRoute
+	for _, route := range routes {
+		podRoutes = append(podRoutes, PodRoute{IP: route.Dst.IP, HostVeth: hostVeths[route.LinkIndex]})
+	}
+	return podRoutes, nil
+}
+
+// listPodRoutes returns the pod routes in the main table, and the names of the host veths by link index
+func (n *linuxNetwork) listPodRoutes(v6Enabled bool) ([]netlink.Route, map[int]string, error) {
+	links, err := n.netLink.LinkList()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list links")
+	}
+	hostVeths := make(map[int]string)
+	for _, link := range links {
+		if link.Type() == "veth" && strings.HasPrefix(link.Attrs().Name, n.vethPrefix) {
+			hostVeths[link.Attrs().Index] = link.Attrs().Name
+		}
+	}
+	routes, err := n.netLink.RouteList(nil, addressFamily(v6Enabled))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list routes")
+	}
+	var podRoutes []netlink.Route
+	for _, route := range routes {
+		if _, ok := hostVeths[route.LinkIndex]; !ok || !isSingleIP(route.Dst) ||
+			(route.Table != 0 && route.Table != unix.RT_TABLE_MAIN) {
+			continue
+		}
+		podRoutes = append(podRoutes, route)
+	}
+	return podRoutes, hostVeths, nil
+}
+
+// SetupNAT64Route replaces the main table route to the NAT64 prefix, so that pods of IPv6-only clusters reach IPv4-only
+// endpoints through a NAT64 gateway, e.g. an AWS NAT Gateway, with the addresses synthesized by DNS64
+func (n *linuxNetwork) SetupNAT64Route(primaryMAC string, prefix *net.IPNet, gateway net.IP) error {
+	link, err := linkByMac(primaryMAC, n.netLink, retryLinkByMacInterval)
+	if err != nil {
+		return errors.Wrapf(err, "SetupNAT64Route: failed to find the link primary ENI with MAC address %s", primaryMAC)
+	}
+	if gateway == nil {
+		gateway, err = n.defaultIPv6Gateway(link)
+		if err != nil {
+			return errors.Wrap(err, "SetupNAT64Route")
+		}
+	}
+
+	route := netlink.Route{
+		LinkIndex: link.Attrs().Index,
+		Dst:       prefix,
+		Gw:        gateway,
+		Table:     mainRoutingTable,
+	}
+	log.Infof("Routing NAT64 prefix %s through %s on %s", prefix.String(), gateway.String(), link.Attrs().Name)
+	if err := n.netLink.RouteReplace(&route); err != nil {
+		return errors.Wrapf(err, "SetupNAT64Route: failed to add route to %s via %s", prefix.String(), gateway.String())
+	}
+	return nil
+}
+
+// defaultIPv6Gateway returns the gateway of the IPv6 default route through link, as learned from the router
+// advertisements of the VPC
+func (n *linuxNetwork) defaultIPv6Gateway(link netlink.Link) (net.IP, error) {
+	routes, err := n.netLink.RouteList(link, unix.AF_INET6)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list IPv6 routes")
+	}
+	for _, route := range routes {
+		if route.Gw == nil {
+			continue
+		}
+		if route.Dst == nil {
+			return route.Gw, nil
+		}
+		if ones, _ := route.Dst.Mask.Size(); ones == 0 {
+			return route.Gw, nil
+		}
+	}
+	return nil, errors.Errorf("no IPv6 default route on %s", link.Attrs().Name)
+}
+
+func addressFamily(v6Enabled bool) int {
+	if v6Enabled {
+		return unix.AF_INET6
+	}
+	return unix.AF_INET
+}
+
+func isSingleIP(ipNet *net.IPNet) bool {
+	if ipNet == nil {
+		return false
+	}
+	ones, bits := ipNet.Mask.Size()
+	return ones == bits
+}
+
+// ScrubStaleRules deletes the rules the CNI plugin sets up for a pod IP, and the host routes to it, when isAssigned
+// reports the IP as not assigned. They are left behind when the CNI DEL of the pod crashed or never ran. isAssigned is
+// asked right before each deletion, so that an IP assigned in the meantime keeps its rules. The rules of pods using
+// branch ENIs are skipped, since their IPs are not managed by ipamd.
+func (n *linuxNetwork) ScrubStaleRules(isAssigned func(ip net.IP) bool, v6Enabled bool, dryRun bool) (StaleRuleReport, error) {
+	var report StaleRuleReport
+	rules, err := n.netLink.RuleList(addressFamily(v6Enabled))
+	if err != nil {
+		return report, errors.Wrap(err, "ScrubStaleRules: failed to list rules")
+	}
+
+	branchENIPodIPs := make(map[string]bool)
+	for _, rule := range rules {
+		if rule.Priority == fromPodRulePriority && isSingleIP(rule.Src) && rule.Table > podVlanRouteTableBase &&
+			rule.Table != mainRoutingTable {
+			branchENIPodIPs[rule.Src.IP.String()] = true
+		}
+	}
+	isStale := func(ip net.IP) bool {
+		return !branchENIPodIPs[ip.String()] && !isAssigned(ip)
+	}
+
+	for i := range rules {
+		rule := rules[i]
+		var podIP net.IP
+		switch {
+		case rule.Priority == toPodRulePriority && isSingleIP(rule.Dst) && rule.Table == mainRoutingTable:
+			podIP = rule.Dst.IP
+		case rule.Priority == fromPodRulePriority && isSingleIP(rule.Src) && rule.Table < podVlanRouteTableBase:
+			podIP = rule.Src.IP
+		case rule.Priority == podEgressRulePriority && isSingleIP(rule.Src):
+			podIP = rule.Src.IP
+		default:
+			continue
+		}
+		if !isStale(podIP) {
+			continue
+		}
+		if dryRun {
+			report.Rules++
+			log.Infof("ScrubStaleRules: would delete stale rule %s", rule.String())
+			continue
+		}
+		if err := n.netLink.RuleDel(&rule); err != nil && !containsNoSuchRule(err) {
+			return report, errors.Wrapf(err, "ScrubStaleRules: failed to delete stale rule %s", rule.String())
+		}
+		report.Rules++
+		log.Infof("ScrubStaleRules: deleted stale rule %s", rule.String())
+	}
+
+	routes, _, err := n.listPodRoutes(v6Enabled)
+	if err != nil {
+		return report, errors.Wrap(err, "ScrubStaleRules")
+	}
+	for i := range routes {
+		route := routes[i]
+		if !isStale(route.Dst.IP) {
+			continue
+		}
+		if dryRun {
+			report.Routes++
+			log.Infof("ScrubStaleRules: would delete stale route %s", route.String())
+			continue
+		}
+		if err := n.netLink.RouteDel(&route); err != nil && !netlinkwrapper.IsNotExistsError(err) {
+			return report, errors.Wrapf(err, "ScrubStaleRules: failed to delete stale route %s", route.String())
+		}
+		report.Routes++
+		log.Infof("ScrubStaleRules: deleted stale route %s", route.String())
+	}
+	return report, nil
+}
+
+// TeardownPodRouteRules deletes the to-pod rule of addr, its from-pod rules through routeTable unless it is the main
+// table, its egress rules, and the route to addr in the main table. Whatever is already gone is skipped.
+func (n *linuxNetwork) TeardownPodRouteRules(addr *net.IPNet, routeTable int) error {
+	toPodRule := n.netLink.NewRule()
+	toPodRule.Dst = addr
+	toPodRule.Priority = toPodRulePriority
+	toPodRule.Table = mainRoutingTable
+	if err := n.netLink.RuleDel(toPodRule); err != nil && !containsNoSuchRule(err) {
+		return errors.Wrapf(err, "TeardownPodRouteRules: failed to delete the rule to %s", addr)
+	}
+
+	if routeTable != mainRoutingTable {
+		fromPodRule := n.netLink.NewRule()
+		fromPodRule.Src = addr
+		fromPodRule.Priority = fromPodRulePriority
+		fromPodRule.Table = routeTable
+		// Older CNI versions added several from-pod rules, delete them all
+		for {
+			if err := n.netLink.RuleDel(fromPodRule); err != nil {
+				if !containsNoSuchRule(err) {
+					return errors.Wrapf(err, "TeardownPodRouteRules: failed to delete the rule from %s", addr)
+				}
+				break
+			}
+		}
+	}
+
+	egressRule := n.netLink.NewRule()
+	egressRule.Src = addr
+	egressRule.Priority = podEgressRulePriority
+	for {
+		if err := n.netLink.RuleDel(egressRule); err != nil {
+			if !containsNoSuchRule(err) {
+				return errors.Wrapf(err, "TeardownPodRouteRules: failed to delete the egress rules from %s", addr)
+			}
+			break
+		}
+	}
+
+	route := &netlink.Route{
+		Scope: netlink.SCOPE_LINK,
+		Dst:   addr,
+		Table: mainRoutingTable,
+	}
+	if err := n.netLink.RouteDel(route); err != nil && !netlinkwrapper.IsNotExistsError(err) {
+		return errors.Wrapf(err, "TeardownPodRouteRules: failed to delete the route to %s", addr)
+	}
+	return nil
+}
+
+// GetHostVethName returns the name of the host-side veth device of a pod
+func (n *linuxNetwork) GetHostVethName(namespace, podName string) string {
+	return GenerateHostVethName(n.vethPrefix, namespace, podName)
+}
+
+// GenerateHostVethName returns a name to be used on the host-side veth device.
+// The veth name is generated such that it aligns with the value expected
+// by Calico for NetworkPolicy enforcement.
+func GenerateHostVethName(prefix, namespace, podname string) string {
+	h := sha1.New()
+	h.Write([]byte(fmt.Sprintf("%s.%s", namespace, podname)))
+	return fmt.Sprintf("%s%s", prefix, hex.EncodeToString(h.Sum(nil))[:11])
+}
+
 // GetRuleList returns IP rules
 func (n *linuxNetwork) GetRuleList() ([]netlink.Rule, error) {
 	return n.netLink.RuleList(unix.AF_INET)
@@ -20,6 +20,7 @@ import (
 	"os"
 	"reflect"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 
@@ -32,10 +33,12 @@ import (
 	"github.com/vishvananda/netlink"
 	"golang.org/x/sys/unix"
 
+	mock_ipsetwrapper "github.com/aws/amazon-vpc-cni-k8s/pkg/ipsetwrapper/mocks"
 	mocks_ip "github.com/aws/amazon-vpc-cni-k8s/pkg/ipwrapper/mocks"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/netlinkwrapper/mock_netlink"
 	mock_netlinkwrapper "github.com/aws/amazon-vpc-cni-k8s/pkg/netlinkwrapper/mocks"
 	mock_nswrapper "github.com/aws/amazon-vpc-cni-k8s/pkg/nswrapper/mocks"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/procsyswrapper"
 	mock_procsyswrapper "github.com/aws/amazon-vpc-cni-k8s/pkg/procsyswrapper/mocks"
 )
 
@@ -118,7 +121,7 @@ func TestSetupENINetwork(t *testing.T) {
 
 	mockNetLink.EXPECT().RouteDel(gomock.Any()).Return(nil)
 
-	err = setupENINetwork(testeniIP, testMAC2, testTable, testeniSubnet, mockNetLink, 0*time.Second, 0*time.Second, testMTU)
+	err = setupENINetwork(testeniIP, testMAC2, testTable, DefaultRouteTableBase, testeniSubnet, mockNetLink, 0*time.Second, 0*time.Second, testMTU, nil)
 	assert.NoError(t, err)
 }
 
@@ -132,7 +135,7 @@ func TestSetupENINetworkMACFail(t *testing.T) {
 		mockNetLink.EXPECT().LinkList().Return(nil, fmt.Errorf("simulated failure"))
 	}
 
-	err := setupENINetwork(testeniIP, testMAC2, testTable, testeniSubnet, mockNetLink, 0*time.Second, 0*time.Second, testMTU)
+	err := setupENINetwork(testeniIP, testMAC2, testTable, DefaultRouteTableBase, testeniSubnet, mockNetLink, 0*time.Second, 0*time.Second, testMTU, nil)
 	assert.Errorf(t, err, "simulated failure")
 }
 
@@ -140,7 +143,7 @@ func TestSetupENINetowrkErrorOnPrimaryENI(t *testing.T) {
 	ctrl, mockNetLink, _, _, _, _ := setup(t)
 	defer ctrl.Finish()
 	deviceNumber := 0
-	err := setupENINetwork(testeniIP, testMAC2, deviceNumber, testeniSubnet, mockNetLink, 0*time.Second, 0*time.Second, testMTU)
+	err := setupENINetwork(testeniIP, testMAC2, deviceNumber, DefaultRouteTableBase, testeniSubnet, mockNetLink, 0*time.Second, 0*time.Second, testMTU, nil)
 	assert.Error(t, err)
 }
 
@@ -172,9 +175,10 @@ func TestSetupHostNetworkNodePortDisabled(t *testing.T) {
 func mockPrimaryInterfaceLookup(ctrl *gomock.Controller, mockNetLink *mock_netlinkwrapper.MockNetLink) {
 	lo := mock_netlink.NewMockLink(ctrl)
 	mockLinkAttrs1 := &netlink.LinkAttrs{
+		Name:         "lo",
 		HardwareAddr: net.HardwareAddr{},
 	}
-	mockNetLink.EXPECT().LinkList().Return([]netlink.Link{lo}, nil)
+	mockNetLink.EXPECT().LinkList().AnyTimes().Return([]netlink.Link{lo}, nil)
 	lo.EXPECT().Attrs().AnyTimes().Return(mockLinkAttrs1)
 }
 
@@ -239,6 +243,7 @@ func TestSetupHostNetworkNodePortEnabled(t *testing.T) {
 			return mockIptables, nil
 		},
 		procSys: mockProcSys,
+		sysctls: procsyswrapper.NewManager(mockProcSys),
 	}
 
 	log.Debugf("mockIPtables.Dp state: ", mockIptables.dataplaneState)
@@ -297,6 +302,197 @@ func TestLoadExcludeSNATCIDRsFromEnv(t *testing.T) {
 	assert.Equal(t, getExcludeSNATCIDRs(), expected)
 }
 
+func TestParseExcludeSNATCIDRs(t *testing.T) {
+	expected := []string{"10.12.0.0/16", "10.13.0.0/16", "192.168.0.0/24"}
+	assert.Equal(t, expected, ParseExcludeSNATCIDRs("10.12.0.0/16, 10.13.0.0/16\nfoo,192.168.0.1/24,"))
+	assert.Empty(t, ParseExcludeSNATCIDRs(""))
+}
+
+func TestSetDynamicExcludeSNATCIDRs(t *testing.T) {
+	_ = os.Setenv(envExternalSNAT, "false")
+	_ = os.Setenv(envExcludeSNATCIDRs, "10.12.0.0/16")
+	defer os.Unsetenv(envExcludeSNATCIDRs)
+
+	ln := &linuxNetwork{excludeSNATCIDRs: []string{"10.12.0.0/16"}}
+	assert.True(t, ln.SetDynamicExcludeSNATCIDRs([]string{"10.12.0.0/16", "172.16.0.0/12"}))
+	assert.False(t, ln.SetDynamicExcludeSNATCIDRs([]string{"172.16.0.0/12", "10.12.0.0/16"}))
+	assert.Equal(t, []string{"10.12.0.0/16", "172.16.0.0/12"}, ln.allExcludeSNATCIDRs())
+	assert.Equal(t, []string{"10.12.0.0/16", "172.16.0.0/12"}, ln.GetExcludeSNATCIDRs())
+
+	assert.True(t, ln.SetDynamicExcludeSNATCIDRs(nil))
+	assert.Equal(t, []string{"10.12.0.0/16"}, ln.allExcludeSNATCIDRs())
+}
+
+func TestGetPodRoutes(t *testing.T) {
+	ctrl, mockNetLink, _, _, _, _ := setup(t)
+	defer ctrl.Finish()
+
+	ln := &linuxNetwork{
+		vethPrefix: "eni",
+		netLink:    mockNetLink,
+	}
+	podVeth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "eni1a2b3c4d5e6", Index: 10}}
+	otherVeth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "cali1a2b3c4d5e6", Index: 11}}
+	eth0 := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth0", Index: 2}}
+	mockNetLink.EXPECT().LinkList().Return([]netlink.Link{podVeth, otherVeth, eth0}, nil)
+
+	_, podIP, _ := net.ParseCIDR("10.0.0.5/32")
+	_, otherIP, _ := net.ParseCIDR("10.0.0.6/32")
+	_, subnet, _ := net.ParseCIDR("10.0.0.0/24")
+	mockNetLink.EXPECT().RouteList(nil, unix.AF_INET).Return([]netlink.Route{
+		{LinkIndex: 10, Dst: podIP, Table: unix.RT_TABLE_MAIN},
+		{LinkIndex: 11, Dst: otherIP, Table: unix.RT_TABLE_MAIN},
+		{LinkIndex: 2, Dst: subnet, Table: unix.RT_TABLE_MAIN},
+		{LinkIndex: 2},
+		{LinkIndex: 10, Dst: subnet, Table: unix.RT_TABLE_MAIN},
+	}, nil)
+
+	podRoutes, err := ln.GetPodRoutes(false)
+	assert.NoError(t, err)
+	assert.Equal(t, []PodRoute{{IP: podIP.IP, HostVeth: "eni1a2b3c4d5e6"}}, podRoutes)
+}
+
+func TestSetupNAT64Route(t *testing.T) {
+	ctrl, mockNetLink, _, _, _, _ := setup(t)
+	defer ctrl.Finish()
+
+	ln := &linuxNetwork{netLink: mockNetLink}
+	lo := mock_netlink.NewMockLink(ctrl)
+	mockNetLink.EXPECT().LinkList().AnyTimes().Return([]netlink.Link{lo}, nil)
+	lo.EXPECT().Attrs().AnyTimes().Return(&netlink.LinkAttrs{Name: "lo", Index: 1, HardwareAddr: net.HardwareAddr{}})
+	_, prefix, _ := net.ParseCIDR("64:ff9b::/96")
+	_, subnet, _ := net.ParseCIDR("2001:db8::/64")
+	routerIP := net.ParseIP("fe80::1")
+
+	// Without a gateway, the default IPv6 gateway of the primary ENI is used
+	mockNetLink.EXPECT().RouteList(lo, unix.AF_INET6).Return([]netlink.Route{
+		{LinkIndex: 1, Dst: subnet},
+		{LinkIndex: 1, Gw: routerIP},
+	}, nil)
+	mockNetLink.EXPECT().RouteReplace(&netlink.Route{LinkIndex: 1, Dst: prefix, Gw: routerIP, Table: mainRoutingTable}).Return(nil)
+	assert.NoError(t, ln.SetupNAT64Route(loopback, prefix, nil))
+
+	gateway := net.ParseIP("2001:db8::64")
+	mockNetLink.EXPECT().RouteReplace(&netlink.Route{LinkIndex: 1, Dst: prefix, Gw: gateway, Table: mainRoutingTable}).Return(nil)
+	assert.NoError(t, ln.SetupNAT64Route(loopback, prefix, gateway))
+
+	mockNetLink.EXPECT().RouteList(lo, unix.AF_INET6).Return([]netlink.Route{{LinkIndex: 1, Dst: subnet}}, nil)
+	assert.Error(t, ln.SetupNAT64Route(loopback, prefix, nil))
+}
+
+func TestScrubStaleRules(t *testing.T) {
+	ctrl, mockNetLink, _, _, _, _ := setup(t)
+	defer ctrl.Finish()
+
+	ln := &linuxNetwork{
+		vethPrefix: "eni",
+		netLink:    mockNetLink,
+	}
+	_, assignedIP, _ := net.ParseCIDR("10.0.0.5/32")
+	_, staleIP, _ := net.ParseCIDR("10.0.0.6/32")
+	_, branchIP, _ := net.ParseCIDR("10.0.0.7/32")
+	_, vpcCIDR, _ := net.ParseCIDR("10.0.0.0/16")
+	rule := func(priority, table int, src, dst *net.IPNet) netlink.Rule {
+		r := netlink.NewRule()
+		r.Priority = priority
+		r.Table = table
+		r.Src = src
+		r.Dst = dst
+		return *r
+	}
+	staleToPod := rule(toPodRulePriority, mainRoutingTable, nil, staleIP)
+	staleFromPod := rule(fromPodRulePriority, 2, staleIP, nil)
+	rules := []netlink.Rule{
+		rule(toPodRulePriority, mainRoutingTable, nil, assignedIP),
+		rule(fromPodRulePriority, 2, assignedIP, nil),
+		staleToPod,
+		staleFromPod,
+		// A pod using a branch ENI in standard mode
+		rule(toPodRulePriority, mainRoutingTable, nil, branchIP),
+		rule(fromPodRulePriority, 101, branchIP, nil),
+		rule(hostRulePriority, mainRoutingTable, nil, vpcCIDR),
+	}
+	podVeth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "eni1a2b3c4d5e6", Index: 10}}
+	routes := []netlink.Route{
+		{LinkIndex: 10, Dst: assignedIP, Table: unix.RT_TABLE_MAIN},
+		{LinkIndex: 10, Dst: staleIP, Table: unix.RT_TABLE_MAIN},
+	}
+	isAssigned := func(ip net.IP) bool {
+		return ip.Equal(assignedIP.IP)
+	}
+
+	mockNetLink.EXPECT().RuleList(unix.AF_INET).Return(rules, nil)
+	mockNetLink.EXPECT().LinkList().Return([]netlink.Link{podVeth}, nil)
+	mockNetLink.EXPECT().RouteList(nil, unix.AF_INET).Return(routes, nil)
+	report, err := ln.ScrubStaleRules(isAssigned, false, true)
+	assert.NoError(t, err)
+	assert.Equal(t, StaleRuleReport{Rules: 2, Routes: 1}, report)
+
+	mockNetLink.EXPECT().RuleList(unix.AF_INET).Return(rules, nil)
+	mockNetLink.EXPECT().RuleDel(&staleToPod).Return(nil)
+	mockNetLink.EXPECT().RuleDel(&staleFromPod).Return(syscall.ENOENT)
+	mockNetLink.EXPECT().LinkList().Return([]netlink.Link{podVeth}, nil)
+	mockNetLink.EXPECT().RouteList(nil, unix.AF_INET).Return(routes, nil)
+	mockNetLink.EXPECT().RouteDel(&routes[1]).Return(nil)
+	report, err = ln.ScrubStaleRules(isAssigned, false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, StaleRuleReport{Rules: 2, Routes: 1}, report)
+}
+
+func TestTeardownPodRouteRules(t *testing.T) {
+	ctrl, mockNetLink, _, _, _, _ := setup(t)
+	defer ctrl.Finish()
+
+	ln := &linuxNetwork{netLink: mockNetLink}
+	_, podIP, _ := net.ParseCIDR("10.0.0.6/32")
+	var toPodRule, fromPodRule, egressRule netlink.Rule
+	route := &netlink.Route{Scope: netlink.SCOPE_LINK, Dst: podIP, Table: mainRoutingTable}
+
+	gomock.InOrder(
+		mockNetLink.EXPECT().NewRule().Return(&toPodRule),
+		mockNetLink.EXPECT().RuleDel(&toPodRule).Return(syscall.ENOENT),
+		mockNetLink.EXPECT().NewRule().Return(&fromPodRule),
+		mockNetLink.EXPECT().RuleDel(&fromPodRule).Return(nil),
+		mockNetLink.EXPECT().RuleDel(&fromPodRule).Return(syscall.ENOENT),
+		mockNetLink.EXPECT().NewRule().Return(&egressRule),
+		mockNetLink.EXPECT().RuleDel(&egressRule).Return(nil),
+		mockNetLink.EXPECT().RuleDel(&egressRule).Return(syscall.ENOENT),
+		mockNetLink.EXPECT().RouteDel(route).Return(syscall.EBUSY),
+	)
+	err := ln.TeardownPodRouteRules(podIP, 3)
+	assert.EqualError(t, err, "TeardownPodRouteRules: failed to delete the route to 10.0.0.6/32: device or resource busy")
+	assert.Equal(t, podIP, toPodRule.Dst)
+	assert.Equal(t, toPodRulePriority, toPodRule.Priority)
+	assert.Equal(t, podIP, fromPodRule.Src)
+	assert.Equal(t, 3, fromPodRule.Table)
+	assert.Equal(t, podIP, egressRule.Src)
+	assert.Equal(t, podEgressRulePriority, egressRule.Priority)
+
+	// No from-pod rule through the main table
+	gomock.InOrder(
+		mockNetLink.EXPECT().NewRule().Return(&toPodRule),
+		mockNetLink.EXPECT().RuleDel(&toPodRule).Return(nil),
+		mockNetLink.EXPECT().NewRule().Return(&egressRule),
+		mockNetLink.EXPECT().RuleDel(&egressRule).Return(syscall.ENOENT),
+		mockNetLink.EXPECT().RouteDel(route).Return(syscall.ESRCH),
+	)
+	assert.NoError(t, ln.TeardownPodRouteRules(podIP, mainRoutingTable))
+}
+
+func TestGetHostVethName(t *testing.T) {
+	ln := &linuxNetwork{vethPrefix: "eni"}
+	assert.Equal(t, "enicc21c2d7785", ln.GetHostVethName("default", "sample-pod"))
+}
+
+func TestSetExcludeSNATCIDRs(t *testing.T) {
+	_ = os.Setenv(envExternalSNAT, "false")
+
+	ln := &linuxNetwork{excludeSNATCIDRs: []string{"10.12.0.0/16"}, dynamicExcludeSNATCIDRs: []string{"172.16.0.0/12"}}
+	assert.False(t, ln.SetExcludeSNATCIDRs([]string{"10.12.0.0/16"}))
+	assert.True(t, ln.SetExcludeSNATCIDRs([]string{"10.13.0.0/16"}))
+	assert.Equal(t, []string{"10.13.0.0/16", "172.16.0.0/12"}, ln.GetExcludeSNATCIDRs())
+}
+
 func TestSetupHostNetworkWithExcludeSNATCIDRs(t *testing.T) {
 	ctrl, mockNetLink, _, mockNS, mockIptables, mockProcSys := setup(t)
 	defer ctrl.Finish()
@@ -316,6 +512,7 @@ func TestSetupHostNetworkWithExcludeSNATCIDRs(t *testing.T) {
 			return mockIptables, nil
 		},
 		procSys: mockProcSys,
+		sysctls: procsyswrapper.NewManager(mockProcSys),
 	}
 
 	setupNetLinkMocks(ctrl, mockNetLink)
@@ -373,6 +570,7 @@ func TestSetupHostNetworkCleansUpStaleSNATRules(t *testing.T) {
 			return mockIptables, nil
 		},
 		procSys: mockProcSys,
+		sysctls: procsyswrapper.NewManager(mockProcSys),
 	}
 	setupNetLinkMocks(ctrl, mockNetLink)
 
@@ -445,6 +643,7 @@ func TestSetupHostNetworkWithDifferentVethPrefix(t *testing.T) {
 			return mockIptables, nil
 		},
 		procSys: mockProcSys,
+		sysctls: procsyswrapper.NewManager(mockProcSys),
 	}
 
 	setupNetLinkMocks(ctrl, mockNetLink)
@@ -517,6 +716,7 @@ func TestSetupHostNetworkExternalNATCleanupConnmark(t *testing.T) {
 			return mockIptables, nil
 		},
 		procSys: mockProcSys,
+		sysctls: procsyswrapper.NewManager(mockProcSys),
 	}
 	setupNetLinkMocks(ctrl, mockNetLink)
 
@@ -585,6 +785,7 @@ func TestSetupHostNetworkExcludedSNATCIDRsIdempotent(t *testing.T) {
 			return mockIptables, nil
 		},
 		procSys: mockProcSys,
+		sysctls: procsyswrapper.NewManager(mockProcSys),
 	}
 	setupNetLinkMocks(ctrl, mockNetLink)
 
@@ -656,6 +857,7 @@ func TestUpdateHostIptablesRules(t *testing.T) {
 			return mockIptables, nil
 		},
 		procSys: mockProcSys,
+		sysctls: procsyswrapper.NewManager(mockProcSys),
 	}
 
 	setupNetLinkMocks(ctrl, mockNetLink)
@@ -702,6 +904,252 @@ func TestUpdateHostIptablesRules(t *testing.T) {
 			},
 		}, mockIptables.dataplaneState)
 }
+func TestUpdateHostIptablesRulesWithPodSNATIPs(t *testing.T) {
+	ctrl, mockNetLink, _, mockNS, mockIptables, _ := setup(t)
+	defer ctrl.Finish()
+
+	ln := &linuxNetwork{
+		mainENIMark: defaultConnmark,
+		vethPrefix:  eniPrefix,
+
+		netLink: mockNetLink,
+		ns:      mockNS,
+		newIptables: func(iptables.Protocol) (iptablesIface, error) {
+			return mockIptables, nil
+		},
+	}
+	mockPrimaryInterfaceLookup(ctrl, mockNetLink)
+
+	vpcCIDRs := []string{"10.10.0.0/16"}
+	defaultSNAT := []string{"!", "-o", "vlan+", "-m", "comment", "--comment", "AWS, SNAT", "-m", "addrtype", "!", "--dst-type", "LOCAL", "-j", "SNAT", "--to-source", "10.10.10.20"}
+	jump := []string{"-m", "comment", "--comment", "AWS SNAT CHAIN NAMESPACE", "-j", "AWS-SNAT-CHAIN-NAMESPACE"}
+	podSNAT := func(podIP, snatIP string) []string {
+		return []string{"-s", podIP + "/32", "-m", "comment", "--comment", "AWS, NAMESPACE SNAT", "-m", "addrtype", "!", "--dst-type", "LOCAL", "-j", "SNAT", "--to-source", snatIP}
+	}
+
+	assert.NoError(t, ln.UpdateHostIptablesRules(vpcCIDRs, loopback, &testENINetIP, true, false))
+	assert.Equal(t, [][]string{defaultSNAT}, mockIptables.dataplaneState["nat"]["AWS-SNAT-CHAIN-1"])
+
+	// The jump to the namespace rules goes before the SNAT rule to the primary IP
+	assert.True(t, ln.SetPodSNATIPs(map[string]string{"10.10.1.5": "10.10.0.100", "10.10.1.4": "10.10.0.101"}))
+	assert.NoError(t, ln.UpdateHostIptablesRules(vpcCIDRs, loopback, &testENINetIP, true, false))
+	assert.Equal(t, [][]string{jump, defaultSNAT}, mockIptables.dataplaneState["nat"]["AWS-SNAT-CHAIN-1"])
+	assert.Equal(t, [][]string{podSNAT("10.10.1.4", "10.10.0.101"), podSNAT("10.10.1.5", "10.10.0.100")},
+		mockIptables.dataplaneState["nat"]["AWS-SNAT-CHAIN-NAMESPACE"])
+
+	assert.False(t, ln.SetPodSNATIPs(map[string]string{"10.10.1.5": "10.10.0.100", "10.10.1.4": "10.10.0.101"}))
+	assert.True(t, ln.SetPodSNATIPs(map[string]string{"10.10.1.5": "10.10.0.100"}))
+	assert.NoError(t, ln.UpdateHostIptablesRules(vpcCIDRs, loopback, &testENINetIP, true, false))
+	assert.Equal(t, [][]string{podSNAT("10.10.1.5", "10.10.0.100")}, mockIptables.dataplaneState["nat"]["AWS-SNAT-CHAIN-NAMESPACE"])
+
+	// A new VPC CIDR moves the jump to the new last chain
+	vpcCIDRs = append(vpcCIDRs, "10.11.0.0/16")
+	assert.NoError(t, ln.UpdateHostIptablesRules(vpcCIDRs, loopback, &testENINetIP, true, false))
+	assert.Equal(t, [][]string{{"!", "-d", "10.11.0.0/16", "-m", "comment", "--comment", "AWS SNAT CHAIN", "-j", "AWS-SNAT-CHAIN-2"}},
+		mockIptables.dataplaneState["nat"]["AWS-SNAT-CHAIN-1"])
+	assert.Equal(t, [][]string{jump, defaultSNAT}, mockIptables.dataplaneState["nat"]["AWS-SNAT-CHAIN-2"])
+
+	assert.True(t, ln.SetPodSNATIPs(nil))
+	assert.NoError(t, ln.UpdateHostIptablesRules(vpcCIDRs, loopback, &testENINetIP, true, false))
+	assert.Equal(t, [][]string{defaultSNAT}, mockIptables.dataplaneState["nat"]["AWS-SNAT-CHAIN-2"])
+	assert.Empty(t, mockIptables.dataplaneState["nat"]["AWS-SNAT-CHAIN-NAMESPACE"])
+}
+
+func TestHostIptablesRulesModified(t *testing.T) {
+	ctrl, mockNetLink, _, mockNS, mockIptables, _ := setup(t)
+	defer ctrl.Finish()
+
+	ln := &linuxNetwork{
+		mainENIMark: defaultConnmark,
+		vethPrefix:  eniPrefix,
+
+		netLink: mockNetLink,
+		ns:      mockNS,
+		newIptables: func(iptables.Protocol) (iptablesIface, error) {
+			return mockIptables, nil
+		},
+	}
+	mockPrimaryInterfaceLookup(ctrl, mockNetLink)
+
+	// Nothing to compare with before the rules are programmed
+	modified, err := ln.HostIptablesRulesModified()
+	assert.NoError(t, err)
+	assert.False(t, modified)
+
+	vpcCIDRs := []string{"10.10.0.0/16"}
+	assert.NoError(t, ln.UpdateHostIptablesRules(vpcCIDRs, loopback, &testENINetIP, true, false))
+	modified, err = ln.HostIptablesRulesModified()
+	assert.NoError(t, err)
+	assert.False(t, modified)
+
+	// Rules of other agents don't count
+	_ = mockIptables.Append("nat", "POSTROUTING", "-m", "comment", "--comment", "kubernetes postrouting rules", "-j", "KUBE-POSTROUTING")
+	modified, err = ln.HostIptablesRulesModified()
+	assert.NoError(t, err)
+	assert.False(t, modified)
+
+	// A flushed CNI chain does
+	mockIptables.dataplaneState["nat"]["AWS-SNAT-CHAIN-1"] = nil
+	modified, err = ln.HostIptablesRulesModified()
+	assert.NoError(t, err)
+	assert.True(t, modified)
+
+	assert.NoError(t, ln.UpdateHostIptablesRules(vpcCIDRs, loopback, &testENINetIP, true, false))
+	modified, err = ln.HostIptablesRulesModified()
+	assert.NoError(t, err)
+	assert.False(t, modified)
+
+	// So does a CNI rule removed from a built-in chain
+	assert.NoError(t, mockIptables.Delete("nat", "POSTROUTING", "-m", "comment", "--comment", "AWS SNAT CHAIN", "-j", "AWS-SNAT-CHAIN-0"))
+	modified, err = ln.HostIptablesRulesModified()
+	assert.NoError(t, err)
+	assert.True(t, modified)
+}
+
+func TestUpdateHostIptablesRulesWithSNATCIDRs(t *testing.T) {
+	ctrl, mockNetLink, _, mockNS, mockIptables, _ := setup(t)
+	defer ctrl.Finish()
+	mockIPSet := mock_ipsetwrapper.NewMockIPSet(ctrl)
+
+	ln := &linuxNetwork{
+		useExternalSNAT:        true,
+		nodePortSupportEnabled: true,
+		mainENIMark:            defaultConnmark,
+		vethPrefix:             eniPrefix,
+		snatCIDRs:              []string{"192.168.0.0/16"},
+
+		netLink: mockNetLink,
+		ns:      mockNS,
+		newIptables: func(iptables.Protocol) (iptablesIface, error) {
+			return mockIptables, nil
+		},
+		ipset: mockIPSet,
+	}
+	mockPrimaryInterfaceLookup(ctrl, mockNetLink)
+
+	vpcCIDRs := []string{"10.10.0.0/16"}
+	assert.True(t, ln.SetDynamicSNATCIDRs([]string{"10.20.1.1/32"}))
+	mockIPSet.EXPECT().Create("AWS-SNAT-CIDRS", "hash:net").Return(nil)
+	// ipset prints host entries without their /32
+	mockIPSet.EXPECT().List("AWS-SNAT-CIDRS").Return([]string{"172.16.0.0/12", "10.20.1.1"}, nil)
+	mockIPSet.EXPECT().Add("AWS-SNAT-CIDRS", "192.168.0.0/16").Return(nil)
+	mockIPSet.EXPECT().Del("AWS-SNAT-CIDRS", "172.16.0.0/12").Return(nil)
+	assert.NoError(t, ln.UpdateHostIptablesRules(vpcCIDRs, loopback, &testENINetIP, true, false))
+	assert.Equal(t,
+		map[string][][]string{
+			"AWS-SNAT-CHAIN-0":     {{"!", "-d", "10.10.0.0/16", "-m", "comment", "--comment", "AWS SNAT CHAIN", "-j", "AWS-SNAT-CHAIN-1"}},
+			"AWS-SNAT-CHAIN-1":     {{"!", "-o", "vlan+", "-m", "set", "--match-set", "AWS-SNAT-CIDRS", "dst", "-m", "comment", "--comment", "AWS, SNAT CIDRS", "-m", "addrtype", "!", "--dst-type", "LOCAL", "-j", "SNAT", "--to-source", "10.10.10.20"}},
+			"POSTROUTING":          {{"-m", "comment", "--comment", "AWS SNAT CHAIN", "-j", "AWS-SNAT-CHAIN-0"}},
+			"AWS-CONNMARK-CHAIN-0": {{"!", "-d", "10.10.0.0/16", "-m", "comment", "--comment", "AWS CONNMARK CHAIN, VPC CIDR", "-j", "AWS-CONNMARK-CHAIN-1"}},
+			"AWS-CONNMARK-CHAIN-1": {{"-m", "set", "--match-set", "AWS-SNAT-CIDRS", "dst", "-m", "comment", "--comment", "AWS, CONNMARK SNAT CIDRS", "-j", "CONNMARK", "--set-xmark", "0x80/0x80"}},
+			"PREROUTING": {
+				{"-i", "eni+", "-m", "comment", "--comment", "AWS, outbound connections", "-m", "state", "--state", "NEW", "-j", "AWS-CONNMARK-CHAIN-0"},
+				{"-m", "comment", "--comment", "AWS, CONNMARK", "-j", "CONNMARK", "--restore-mark", "--mask", "0x80"},
+			},
+		}, mockIptables.dataplaneState["nat"])
+
+	// Without SNAT CIDRs, external SNAT leaves all the non-VPC traffic alone again
+	ln.snatCIDRs = nil
+	assert.True(t, ln.SetDynamicSNATCIDRs(nil))
+	assert.NoError(t, ln.UpdateHostIptablesRules(vpcCIDRs, loopback, &testENINetIP, true, false))
+	for chain, rules := range mockIptables.dataplaneState["nat"] {
+		assert.Empty(t, rules, chain)
+	}
+}
+
+func TestUpdateHostIptablesRulesWithNonRoutableCIDRs(t *testing.T) {
+	ctrl, mockNetLink, _, mockNS, mockIptables, _ := setup(t)
+	defer ctrl.Finish()
+
+	ln := &linuxNetwork{
+		useExternalSNAT:        true,
+		nodePortSupportEnabled: true,
+		mainENIMark:            defaultConnmark,
+		vethPrefix:             eniPrefix,
+		nonRoutableCIDRs:       []string{"100.64.0.0/16"},
+
+		netLink: mockNetLink,
+		ns:      mockNS,
+		newIptables: func(iptables.Protocol) (iptablesIface, error) {
+			return mockIptables, nil
+		},
+	}
+	mockPrimaryInterfaceLookup(ctrl, mockNetLink)
+
+	// Even with external SNAT, the pods in the non-routable CIDR are SNATed outside the VPC
+	vpcCIDRs := []string{"10.10.0.0/16", "100.64.0.0/16"}
+	assert.NoError(t, ln.UpdateHostIptablesRules(vpcCIDRs, loopback, &testENINetIP, true, false))
+	assert.Equal(t, [][]string{{"-m", "comment", "--comment", "AWS SNAT CHAIN NON-ROUTABLE", "-j", "AWS-SNAT-CHAIN-NON-ROUTABLE"}},
+		mockIptables.dataplaneState["nat"]["POSTROUTING"])
+	assert.Equal(t, [][]string{
+		{"-d", "10.10.0.0/16", "-m", "comment", "--comment", "AWS SNAT NON-ROUTABLE, VPC CIDR", "-j", "RETURN"},
+		{"-d", "100.64.0.0/16", "-m", "comment", "--comment", "AWS SNAT NON-ROUTABLE, VPC CIDR", "-j", "RETURN"},
+		{"-s", "100.64.0.0/16", "!", "-o", "vlan+", "-m", "comment", "--comment", "AWS, SNAT NON-ROUTABLE", "-m", "addrtype",
+			"!", "--dst-type", "LOCAL", "-j", "SNAT", "--to-source", "10.10.10.20"},
+	}, mockIptables.dataplaneState["nat"]["AWS-SNAT-CHAIN-NON-ROUTABLE"])
+
+	// Without non-routable CIDRs, the jump and the rules go away
+	ln.nonRoutableCIDRs = nil
+	assert.NoError(t, ln.UpdateHostIptablesRules(vpcCIDRs, loopback, &testENINetIP, true, false))
+	for chain, rules := range mockIptables.dataplaneState["nat"] {
+		assert.Empty(t, rules, chain)
+	}
+}
+
+func TestParsePathMTUs(t *testing.T) {
+	assert.Empty(t, parsePathMTUs(""))
+	assert.Equal(t, []pathMTU{{cidr: "10.20.0.0/16", mtu: 1400}, {cidr: "172.16.0.0/12", mtu: 1500}},
+		parsePathMTUs("10.20.1.0/16=1400,\n 172.16.0.0/12=1500 10.30.0.0/16 10.40.0.0/16=100 fd00::/8=1400 10.50.0.0/16=abc"))
+}
+
+func TestUpdateHostIptablesRulesWithPathMTUs(t *testing.T) {
+	ctrl, mockNetLink, _, mockNS, mockIptables, _ := setup(t)
+	defer ctrl.Finish()
+
+	ln := &linuxNetwork{
+		mainENIMark: defaultConnmark,
+		vethPrefix:  eniPrefix,
+		mtu:         testMTU,
+		pathMTUs:    []pathMTU{{cidr: "10.20.0.0/16", mtu: 1400}, {cidr: "10.30.0.0/16", mtu: testMTU}},
+
+		netLink: mockNetLink,
+		ns:      mockNS,
+		newIptables: func(iptables.Protocol) (iptablesIface, error) {
+			return mockIptables, nil
+		},
+	}
+	mockPrimaryInterfaceLookup(ctrl, mockNetLink)
+
+	clamp := func(direction, cidr, mss, minMSS string) []string {
+		return []string{direction, cidr, "-p", "tcp", "-m", "tcp", "--tcp-flags", "SYN,RST", "SYN", "-m", "comment", "--comment",
+			"AWS, MSS CLAMP", "-m", "tcpmss", "--mss", minMSS + ":65535", "-j", "TCPMSS", "--set-mss", mss}
+	}
+	jump := []string{"-p", "tcp", "-m", "tcp", "--tcp-flags", "SYN,RST", "SYN", "-m", "comment", "--comment", "AWS, MSS CLAMP",
+		"-j", "AWS-MSS-CLAMP"}
+
+	// Only the path with a smaller MTU than the ENIs is clamped
+	vpcCIDRs := []string{"10.10.0.0/16"}
+	assert.NoError(t, ln.UpdateHostIptablesRules(vpcCIDRs, loopback, &testENINetIP, true, false))
+	assert.Equal(t, [][]string{jump}, mockIptables.dataplaneState["mangle"]["FORWARD"])
+	assert.Equal(t, [][]string{clamp("-d", "10.20.0.0/16", "1360", "1361"), clamp("-s", "10.20.0.0/16", "1360", "1361")},
+		mockIptables.dataplaneState["mangle"]["AWS-MSS-CLAMP"])
+
+	// Idempotent
+	assert.NoError(t, ln.UpdateHostIptablesRules(vpcCIDRs, loopback, &testENINetIP, true, false))
+	assert.Len(t, mockIptables.dataplaneState["mangle"]["AWS-MSS-CLAMP"], 2)
+
+	// A changed path MTU replaces the rules, and no path MTU removes them
+	ln.pathMTUs = []pathMTU{{cidr: "10.20.0.0/16", mtu: 1300}}
+	assert.NoError(t, ln.UpdateHostIptablesRules(vpcCIDRs, loopback, &testENINetIP, true, false))
+	assert.Equal(t, [][]string{clamp("-d", "10.20.0.0/16", "1260", "1261"), clamp("-s", "10.20.0.0/16", "1260", "1261")},
+		mockIptables.dataplaneState["mangle"]["AWS-MSS-CLAMP"])
+
+	ln.pathMTUs = nil
+	assert.NoError(t, ln.UpdateHostIptablesRules(vpcCIDRs, loopback, &testENINetIP, true, false))
+	assert.Empty(t, mockIptables.dataplaneState["mangle"]["FORWARD"])
+	assert.Empty(t, mockIptables.dataplaneState["mangle"]["AWS-MSS-CLAMP"])
+}
+
 func TestSetupHostNetworkMultipleCIDRs(t *testing.T) {
 	ctrl, mockNetLink, _, mockNS, mockIptables, mockProcSys := setup(t)
 	defer ctrl.Finish()
@@ -720,6 +1168,7 @@ func TestSetupHostNetworkMultipleCIDRs(t *testing.T) {
 			return mockIptables, nil
 		},
 		procSys: mockProcSys,
+		sysctls: procsyswrapper.NewManager(mockProcSys),
 	}
 	setupNetLinkMocks(ctrl, mockNetLink)
 
@@ -749,6 +1198,7 @@ func TestSetupHostNetworkWithIPv6Enabled(t *testing.T) {
 			return mockIptables, nil
 		},
 		procSys: mockProcSys,
+		sysctls: procsyswrapper.NewManager(mockProcSys),
 	}
 
 	setupNetLinkMocks(ctrl, mockNetLink)
@@ -815,6 +1265,7 @@ func TestSetupHostNetworkIgnoringRpFilterUpdate(t *testing.T) {
 			return mockIptables, nil
 		},
 		procSys: mockProcSys,
+		sysctls: procsyswrapper.NewManager(mockProcSys),
 	}
 	setupNetLinkMocks(ctrl, mockNetLink)
 
@@ -842,6 +1293,7 @@ func TestSetupHostNetworkUpdateLocalRule(t *testing.T) {
 			return mockIptables, nil
 		},
 		procSys: mockProcSys,
+		sysctls: procsyswrapper.NewManager(mockProcSys),
 	}
 	setupNetLinkMocks(ctrl, mockNetLink)
 	setupVethNetLinkMocks(mockNetLink)
@@ -893,7 +1345,12 @@ func (ipt *mockIptables) Insert(table, chain string, pos int, rulespec ...string
 	if ipt.dataplaneState[table] == nil {
 		ipt.dataplaneState[table] = map[string][][]string{}
 	}
-	ipt.dataplaneState[table][chain] = append(ipt.dataplaneState[table][chain], rulespec)
+	rules := ipt.dataplaneState[table][chain]
+	if pos < 1 || pos > len(rules)+1 {
+		return errors.New("index of insertion too big")
+	}
+	rules = append(rules[:pos-1], append([][]string{rulespec}, rules[pos-1:]...)...)
+	ipt.dataplaneState[table][chain] = rules
 	return nil
 }
 
@@ -940,11 +1397,18 @@ func (ipt *mockIptables) List(table, chain string) ([]string, error) {
 
 }
 
+func (ipt *mockIptables) ListWithCounters(table, chain string) ([]string, error) {
+	return ipt.List(table, chain)
+}
+
 func (ipt *mockIptables) NewChain(table, chain string) error {
 	return nil
 }
 
 func (ipt *mockIptables) ClearChain(table, chain string) error {
+	if ipt.dataplaneState[table] != nil {
+		delete(ipt.dataplaneState[table], chain)
+	}
 	return nil
 }
 
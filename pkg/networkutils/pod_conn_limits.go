@@ -0,0 +1,141 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package networkutils
+
+import (
+	"encoding/csv"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// podConnLimitChain holds the rules limiting the new connections of the pods with PodConnectionLimits. Only the
+	// first packet of a connection jumps to it.
+	podConnLimitChain = "AWS-POD-CONN-LIMIT"
+
+	podConnLimitComment = "AWS, pod connection limits"
+)
+
+// PodConnectionLimits are the limits of the connections a pod opens through its host veth, 0 for no limit
+type PodConnectionLimits struct {
+	// NewConnectionsPerSecond is the rate of new connections, with a burst of the same size
+	NewConnectionsPerSecond int
+	// MaxConnections is the number of connections of the pod tracked at the same time
+	MaxConnections int
+}
+
+func podConnLimitJumpRule() []string {
+	return []string{"-m", "conntrack", "--ctstate", "NEW", "-m", "comment", "--comment", podConnLimitComment,
+		"-j", podConnLimitChain}
+}
+
+// podConnLimitPodRules returns the rules dropping the new connections of hostVeth above limits. The hashlimit name is
+// the host veth name, which is unique on the node and short enough for the kernel.
+func podConnLimitPodRules(hostVeth string, limits PodConnectionLimits) [][]string {
+	var rules [][]string
+	if limits.NewConnectionsPerSecond > 0 {
+		rate := strconv.Itoa(limits.NewConnectionsPerSecond)
+		rules = append(rules, []string{"-i", hostVeth, "-m", "hashlimit", "--hashlimit-above", rate + "/sec",
+			"--hashlimit-burst", rate, "--hashlimit-name", hostVeth,
+			"-m", "comment", "--comment", podConnLimitComment, "-j", "DROP"})
+	}
+	if limits.MaxConnections > 0 {
+		rules = append(rules, []string{"-i", hostVeth, "-m", "connlimit", "--connlimit-above",
+			strconv.Itoa(limits.MaxConnections), "-m", "comment", "--comment", podConnLimitComment, "-j", "DROP"})
+	}
+	return rules
+}
+
+// SetupPodConnectionLimits creates the chain that LimitPodConnections adds the pod rules to, or deletes it when not
+// enabled
+func (n *linuxNetwork) SetupPodConnectionLimits(enabled bool, v6Enabled bool) error {
+	ipt, err := n.podIptables(v6Enabled)
+	if err != nil {
+		return err
+	}
+	jumpRule := podConnLimitJumpRule()
+	exists, err := ipt.Exists("filter", "FORWARD", jumpRule...)
+	if err != nil {
+		return errors.Wrap(err, "failed to check the pod connection limit jump rule")
+	}
+	if !enabled {
+		if exists {
+			if err := ipt.Delete("filter", "FORWARD", jumpRule...); err != nil {
+				return errors.Wrap(err, "failed to delete the pod connection limit jump rule")
+			}
+		}
+		if err := ipt.ClearChain("filter", podConnLimitChain); err != nil {
+			return errors.Wrapf(err, "failed to clear chain %s", podConnLimitChain)
+		}
+		if err := ipt.DeleteChain("filter", podConnLimitChain); err != nil {
+			return errors.Wrapf(err, "failed to delete chain %s", podConnLimitChain)
+		}
+		return nil
+	}
+	// The pod rules of a previous run are kept, so that the pods stay limited while ipamd restarts
+	if err := ipt.NewChain("filter", podConnLimitChain); err != nil && !containChainExistErr(err) {
+		return errors.Wrapf(err, "failed to create chain %s", podConnLimitChain)
+	}
+	if !exists {
+		if err := ipt.Insert("filter", "FORWARD", 1, jumpRule...); err != nil {
+			return errors.Wrap(err, "failed to add the pod connection limit jump rule")
+		}
+	}
+	return nil
+}
+
+// LimitPodConnections replaces the connection limits of the host veth of a pod with limits
+func (n *linuxNetwork) LimitPodConnections(hostVeth string, limits PodConnectionLimits, v6Enabled bool) error {
+	if err := n.UnlimitPodConnections(hostVeth, v6Enabled); err != nil {
+		return err
+	}
+	ipt, err := n.podIptables(v6Enabled)
+	if err != nil {
+		return err
+	}
+	for _, rule := range podConnLimitPodRules(hostVeth, limits) {
+		if err := ipt.Append("filter", podConnLimitChain, rule...); err != nil {
+			return errors.Wrapf(err, "failed to add the connection limit rule %v of %s", rule, hostVeth)
+		}
+	}
+	return nil
+}
+
+// UnlimitPodConnections deletes the rules added by LimitPodConnections, if any. They are found by their input
+// interface, as the limits they were added with may not be known anymore.
+func (n *linuxNetwork) UnlimitPodConnections(hostVeth string, v6Enabled bool) error {
+	ipt, err := n.podIptables(v6Enabled)
+	if err != nil {
+		return err
+	}
+	rules, err := ipt.List("filter", podConnLimitChain)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list chain %s", podConnLimitChain)
+	}
+	for _, rule := range rules {
+		r := csv.NewReader(strings.NewReader(rule))
+		r.Comma = ' '
+		ruleSpec, err := r.Read()
+		// Skip the "-N <chain>" line and the rules of the other pods
+		if err != nil || len(ruleSpec) < 4 || ruleSpec[0] != "-A" || ruleSpec[2] != "-i" || ruleSpec[3] != hostVeth {
+			continue
+		}
+		if err := ipt.Delete("filter", podConnLimitChain, ruleSpec[2:]...); err != nil {
+			return errors.Wrapf(err, "failed to delete the connection limit rule %v of %s", ruleSpec[2:], hostVeth)
+		}
+	}
+	return nil
+}
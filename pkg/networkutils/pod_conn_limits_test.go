@@ -0,0 +1,64 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package networkutils
+
+import (
+	"testing"
+
+	"github.com/coreos/go-iptables/iptables"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPodConnectionLimits(t *testing.T) {
+	ipt := newMockIptables()
+	ln := &linuxNetwork{
+		newIptables: func(iptables.Protocol) (iptablesIface, error) {
+			return ipt, nil
+		},
+	}
+	assert.NoError(t, ipt.Append("filter", "FORWARD", "-j", "ACCEPT"))
+
+	assert.NoError(t, ln.SetupPodConnectionLimits(true, false))
+	assert.NoError(t, ln.SetupPodConnectionLimits(true, false))
+	assert.Equal(t, [][]string{podConnLimitJumpRule(), {"-j", "ACCEPT"}}, ipt.dataplaneState["filter"]["FORWARD"])
+
+	assert.NoError(t, ln.LimitPodConnections("eni1", PodConnectionLimits{NewConnectionsPerSecond: 100, MaxConnections: 1000}, false))
+	assert.NoError(t, ln.LimitPodConnections("eni2", PodConnectionLimits{MaxConnections: 50}, false))
+	assert.Equal(t, [][]string{
+		{"-i", "eni1", "-m", "hashlimit", "--hashlimit-above", "100/sec", "--hashlimit-burst", "100",
+			"--hashlimit-name", "eni1", "-m", "comment", "--comment", podConnLimitComment, "-j", "DROP"},
+		{"-i", "eni1", "-m", "connlimit", "--connlimit-above", "1000", "-m", "comment", "--comment", podConnLimitComment,
+			"-j", "DROP"},
+		{"-i", "eni2", "-m", "connlimit", "--connlimit-above", "50", "-m", "comment", "--comment", podConnLimitComment,
+			"-j", "DROP"},
+	}, ipt.dataplaneState["filter"][podConnLimitChain])
+
+	// New limits replace the previous ones
+	assert.NoError(t, ln.LimitPodConnections("eni1", PodConnectionLimits{NewConnectionsPerSecond: 10}, false))
+	assert.Equal(t, [][]string{
+		{"-i", "eni2", "-m", "connlimit", "--connlimit-above", "50", "-m", "comment", "--comment", podConnLimitComment,
+			"-j", "DROP"},
+		{"-i", "eni1", "-m", "hashlimit", "--hashlimit-above", "10/sec", "--hashlimit-burst", "10",
+			"--hashlimit-name", "eni1", "-m", "comment", "--comment", podConnLimitComment, "-j", "DROP"},
+	}, ipt.dataplaneState["filter"][podConnLimitChain])
+
+	assert.NoError(t, ln.UnlimitPodConnections("eni2", false))
+	assert.NoError(t, ln.UnlimitPodConnections("eni3", false))
+	assert.Equal(t, podConnLimitPodRules("eni1", PodConnectionLimits{NewConnectionsPerSecond: 10}),
+		ipt.dataplaneState["filter"][podConnLimitChain])
+
+	assert.NoError(t, ln.SetupPodConnectionLimits(false, false))
+	assert.Equal(t, [][]string{{"-j", "ACCEPT"}}, ipt.dataplaneState["filter"]["FORWARD"])
+	assert.Empty(t, ipt.dataplaneState["filter"][podConnLimitChain])
+}
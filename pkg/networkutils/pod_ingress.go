@@ -0,0 +1,139 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package networkutils
+
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	// podIngressChain holds a rule for the host veth of each pod whose ingress is restricted, which sends the traffic
+	// forwarded to the pod through podIngressAllowChain
+	podIngressChain = "AWS-POD-INGRESS"
+
+	// podIngressAllowChain accepts the replies to the connections of the pods and the traffic from the allowed CIDRs,
+	// and drops the rest
+	podIngressAllowChain = "AWS-POD-INGRESS-ALLOW"
+
+	podIngressComment = "AWS, pod ingress allowlist"
+)
+
+func podIngressJumpRule() []string {
+	return []string{"-m", "comment", "--comment", podIngressComment, "-j", podIngressChain}
+}
+
+func podIngressPodRule(hostVeth string) []string {
+	return []string{"-o", hostVeth, "-m", "comment", "--comment", podIngressComment, "-j", podIngressAllowChain}
+}
+
+func podIngressAllowRules(allowedCIDRs []string) [][]string {
+	rules := [][]string{{"-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", "RETURN"}}
+	for _, cidr := range allowedCIDRs {
+		rules = append(rules, []string{"-s", cidr, "-j", "RETURN"})
+	}
+	return append(rules, []string{"-j", "DROP"})
+}
+
+// SetupPodIngressAllowlist creates the chains that the traffic forwarded to the pods restricted by RestrictPodIngress
+// goes through, so that they only accept it from allowedCIDRs, or deletes them along with the pod rules when disabled
+func (n *linuxNetwork) SetupPodIngressAllowlist(enabled bool, allowedCIDRs []string, v6Enabled bool) error {
+	ipt, err := n.podIptables(v6Enabled)
+	if err != nil {
+		return err
+	}
+	jumpRule := podIngressJumpRule()
+	exists, err := ipt.Exists("filter", "FORWARD", jumpRule...)
+	if err != nil {
+		return errors.Wrap(err, "failed to check the pod ingress jump rule")
+	}
+	if !enabled {
+		if exists {
+			if err := ipt.Delete("filter", "FORWARD", jumpRule...); err != nil {
+				return errors.Wrap(err, "failed to delete the pod ingress jump rule")
+			}
+		}
+		// The pod rules jump to the allow chain, so they go first
+		for _, chain := range []string{podIngressChain, podIngressAllowChain} {
+			if err := ipt.ClearChain("filter", chain); err != nil {
+				return errors.Wrapf(err, "failed to clear chain %s", chain)
+			}
+			if err := ipt.DeleteChain("filter", chain); err != nil {
+				return errors.Wrapf(err, "failed to delete chain %s", chain)
+			}
+		}
+		return nil
+	}
+	// The pod rules of a previous run are kept, so that the pods stay restricted while ipamd restarts, but the allowed
+	// CIDRs may have changed
+	for _, chain := range []string{podIngressChain, podIngressAllowChain} {
+		if err := ipt.NewChain("filter", chain); err != nil && !containChainExistErr(err) {
+			return errors.Wrapf(err, "failed to create chain %s", chain)
+		}
+	}
+	if err := ipt.ClearChain("filter", podIngressAllowChain); err != nil {
+		return errors.Wrapf(err, "failed to clear chain %s", podIngressAllowChain)
+	}
+	for _, rule := range podIngressAllowRules(allowedCIDRs) {
+		if err := ipt.Append("filter", podIngressAllowChain, rule...); err != nil {
+			return errors.Wrapf(err, "failed to add rule %v to chain %s", rule, podIngressAllowChain)
+		}
+	}
+	if !exists {
+		if err := ipt.Insert("filter", "FORWARD", 1, jumpRule...); err != nil {
+			return errors.Wrap(err, "failed to add the pod ingress jump rule")
+		}
+	}
+	return nil
+}
+
+// RestrictPodIngress makes the traffic forwarded to the host veth of a pod go through the allowlist set up by
+// SetupPodIngressAllowlist
+func (n *linuxNetwork) RestrictPodIngress(hostVeth string, v6Enabled bool) error {
+	ipt, err := n.podIptables(v6Enabled)
+	if err != nil {
+		return err
+	}
+	rule := podIngressPodRule(hostVeth)
+	exists, err := ipt.Exists("filter", podIngressChain, rule...)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check the pod ingress rule of %s", hostVeth)
+	}
+	if exists {
+		return nil
+	}
+	if err := ipt.Append("filter", podIngressChain, rule...); err != nil {
+		return errors.Wrapf(err, "failed to add the pod ingress rule of %s", hostVeth)
+	}
+	return nil
+}
+
+// UnrestrictPodIngress deletes the rule added by RestrictPodIngress, if any
+func (n *linuxNetwork) UnrestrictPodIngress(hostVeth string, v6Enabled bool) error {
+	ipt, err := n.podIptables(v6Enabled)
+	if err != nil {
+		return err
+	}
+	rule := podIngressPodRule(hostVeth)
+	exists, err := ipt.Exists("filter", podIngressChain, rule...)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check the pod ingress rule of %s", hostVeth)
+	}
+	if !exists {
+		return nil
+	}
+	if err := ipt.Delete("filter", podIngressChain, rule...); err != nil {
+		return errors.Wrapf(err, "failed to delete the pod ingress rule of %s", hostVeth)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package networkutils
+
+import (
+	"testing"
+
+	"github.com/coreos/go-iptables/iptables"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPodIngressAllowlist(t *testing.T) {
+	ipt := newMockIptables()
+	ln := &linuxNetwork{
+		newIptables: func(iptables.Protocol) (iptablesIface, error) {
+			return ipt, nil
+		},
+	}
+	assert.NoError(t, ipt.Append("filter", "FORWARD", "-j", "ACCEPT"))
+
+	assert.NoError(t, ln.SetupPodIngressAllowlist(true, []string{"10.0.0.0/16"}, false))
+	assert.NoError(t, ln.RestrictPodIngress("eni1", false))
+	assert.NoError(t, ln.RestrictPodIngress("eni1", false))
+	assert.NoError(t, ln.RestrictPodIngress("eni2", false))
+
+	// A restart with other CIDRs replaces the allow rules, and keeps the jump rule and the pod rules
+	assert.NoError(t, ln.SetupPodIngressAllowlist(true, []string{"10.0.0.0/16", "100.64.0.0/16"}, false))
+	assert.Equal(t, [][]string{podIngressJumpRule(), {"-j", "ACCEPT"}}, ipt.dataplaneState["filter"]["FORWARD"])
+	assert.Equal(t, [][]string{
+		{"-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", "RETURN"},
+		{"-s", "10.0.0.0/16", "-j", "RETURN"},
+		{"-s", "100.64.0.0/16", "-j", "RETURN"},
+		{"-j", "DROP"},
+	}, ipt.dataplaneState["filter"][podIngressAllowChain])
+	assert.Equal(t, [][]string{podIngressPodRule("eni1"), podIngressPodRule("eni2")}, ipt.dataplaneState["filter"][podIngressChain])
+
+	assert.NoError(t, ln.UnrestrictPodIngress("eni1", false))
+	assert.NoError(t, ln.UnrestrictPodIngress("eni3", false))
+	assert.Equal(t, [][]string{podIngressPodRule("eni2")}, ipt.dataplaneState["filter"][podIngressChain])
+
+	assert.NoError(t, ln.SetupPodIngressAllowlist(false, nil, false))
+	assert.Equal(t, [][]string{{"-j", "ACCEPT"}}, ipt.dataplaneState["filter"]["FORWARD"])
+	assert.Empty(t, ipt.dataplaneState["filter"][podIngressChain])
+	assert.Empty(t, ipt.dataplaneState["filter"][podIngressAllowChain])
+}
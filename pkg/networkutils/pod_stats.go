@@ -0,0 +1,68 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package networkutils
+
+import (
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+)
+
+// PodNetworkStats are the counters of the host-side veth device of a pod, from the point of view of the pod: what the
+// host side transmits, the pod receives.
+type PodNetworkStats struct {
+	RxBytes   uint64
+	TxBytes   uint64
+	RxDropped uint64
+	TxDropped uint64
+}
+
+// GetPodNetworkStats returns the counters of the host-side veth device of a pod
+func (n *linuxNetwork) GetPodNetworkStats(hostVeth string) (*PodNetworkStats, error) {
+	link, err := n.netLink.LinkByName(hostVeth)
+	if err != nil {
+		return nil, errors.Wrapf(err, "GetPodNetworkStats: failed to find link %s", hostVeth)
+	}
+	stats := link.Attrs().Statistics
+	if stats == nil {
+		return nil, errors.Errorf("GetPodNetworkStats: link %s has no statistics", hostVeth)
+	}
+	return &PodNetworkStats{
+		RxBytes:   stats.TxBytes,
+		TxBytes:   stats.RxBytes,
+		RxDropped: stats.TxDropped,
+		TxDropped: stats.RxDropped,
+	}, nil
+}
+
+// CountConntrackFlows returns the number of conntrack flows of each address of the family, as the source of the
+// original direction or of the reply direction, which is the pod behind a DNATed service IP
+func (n *linuxNetwork) CountConntrackFlows(v6 bool) (map[string]int, error) {
+	family := netlink.InetFamily(netlink.FAMILY_V4)
+	if v6 {
+		family = netlink.FAMILY_V6
+	}
+	flows, err := n.netLink.ConntrackTableList(netlink.ConntrackTable, family)
+	if err != nil {
+		return nil, errors.Wrap(err, "CountConntrackFlows: failed to list the conntrack flows")
+	}
+	counts := make(map[string]int)
+	for _, flow := range flows {
+		src := flow.Forward.SrcIP.String()
+		counts[src]++
+		if replySrc := flow.Reverse.SrcIP.String(); replySrc != src {
+			counts[replySrc]++
+		}
+	}
+	return counts, nil
+}
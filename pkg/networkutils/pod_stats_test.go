@@ -0,0 +1,71 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package networkutils
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+)
+
+func TestGetPodNetworkStats(t *testing.T) {
+	ctrl, mockNetLink, _, _, _, _ := setup(t)
+	defer ctrl.Finish()
+
+	ln := &linuxNetwork{netLink: mockNetLink}
+	veth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{
+		Name:       "eni8ea2c11fe35",
+		Statistics: &netlink.LinkStatistics{RxBytes: 100, TxBytes: 200, RxDropped: 1, TxDropped: 2},
+	}}
+	mockNetLink.EXPECT().LinkByName("eni8ea2c11fe35").Return(veth, nil)
+	stats, err := ln.GetPodNetworkStats("eni8ea2c11fe35")
+	assert.NoError(t, err)
+	assert.Equal(t, &PodNetworkStats{RxBytes: 200, TxBytes: 100, RxDropped: 2, TxDropped: 1}, stats)
+
+	mockNetLink.EXPECT().LinkByName("eni8ea2c11fe35").Return(nil, errors.New("link not found"))
+	_, err = ln.GetPodNetworkStats("eni8ea2c11fe35")
+	assert.Error(t, err)
+}
+
+func TestCountConntrackFlows(t *testing.T) {
+	ctrl, mockNetLink, _, _, _, _ := setup(t)
+	defer ctrl.Finish()
+
+	ln := &linuxNetwork{netLink: mockNetLink}
+	flow := func(src, dst, replySrc string) *netlink.ConntrackFlow {
+		f := &netlink.ConntrackFlow{}
+		f.Forward.SrcIP, f.Forward.DstIP = net.ParseIP(src), net.ParseIP(dst)
+		f.Reverse.SrcIP, f.Reverse.DstIP = net.ParseIP(replySrc), net.ParseIP(src)
+		return f
+	}
+	mockNetLink.EXPECT().ConntrackTableList(netlink.ConntrackTableType(netlink.ConntrackTable), netlink.InetFamily(netlink.FAMILY_V4)).Return([]*netlink.ConntrackFlow{
+		// Pod to the internet
+		flow("10.0.0.5", "1.1.1.1", "1.1.1.1"),
+		// Client to a service backed by the pod
+		flow("10.0.0.6", "172.20.0.10", "10.0.0.5"),
+		// Pod to itself through a service
+		flow("10.0.0.5", "172.20.0.10", "10.0.0.5"),
+	}, nil)
+	counts, err := ln.CountConntrackFlows(false)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, counts["10.0.0.5"])
+	assert.Equal(t, 1, counts["10.0.0.6"])
+
+	mockNetLink.EXPECT().ConntrackTableList(netlink.ConntrackTableType(netlink.ConntrackTable), netlink.InetFamily(netlink.FAMILY_V6)).Return(nil, errors.New("netlink error"))
+	_, err = ln.CountConntrackFlows(true)
+	assert.Error(t, err)
+}
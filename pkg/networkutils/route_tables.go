@@ -0,0 +1,100 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package networkutils
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// envRouteTableBase is the environment variable to set the number added to the device index of a secondary ENI to
+	// get its route table, for nodes where other agents, e.g. VPN agents, use the low route table numbers
+	envRouteTableBase = "AWS_VPC_K8S_CNI_ROUTE_TABLE_BASE"
+
+	// DefaultRouteTableBase puts the ENI with device index 1 in route table 2
+	DefaultRouteTableBase = 1
+)
+
+// ParseRouteTableBase parses a route table base. The route tables of the ENIs must stay below the ones the CNI
+// plugin uses for branch ENIs.
+func ParseRouteTableBase(value string) (int, error) {
+	base, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid route table base %q", value)
+	}
+	if base < 1 || base >= podVlanRouteTableBase {
+		return 0, errors.Errorf("route table base %d is out of range [1, %d)", base, podVlanRouteTableBase)
+	}
+	return base, nil
+}
+
+// getRouteTableBase returns the route table base set through AWS_VPC_K8S_CNI_ROUTE_TABLE_BASE
+func getRouteTableBase() int {
+	value, found := os.LookupEnv(envRouteTableBase)
+	if !found || value == "" {
+		return DefaultRouteTableBase
+	}
+	base, err := ParseRouteTableBase(value)
+	if err != nil {
+		log.Errorf("Failed to parse %s, will use %d: %v", envRouteTableBase, DefaultRouteTableBase, err)
+		return DefaultRouteTableBase
+	}
+	return base
+}
+
+// ENIRouteTable returns the route table of the secondary ENI with the given device index
+func ENIRouteTable(routeTableBase int, deviceNumber int) int {
+	return routeTableBase + deviceNumber
+}
+
+// eniRouteTable returns the route table of a secondary ENI, which must be below the branch ENI route tables
+func eniRouteTable(routeTableBase int, deviceNumber int) (int, error) {
+	tableNumber := ENIRouteTable(routeTableBase, deviceNumber)
+	if tableNumber >= podVlanRouteTableBase {
+		return 0, errors.Errorf("route table %d of the ENI with device index %d is not below %d, lower %s",
+			tableNumber, deviceNumber, podVlanRouteTableBase, envRouteTableBase)
+	}
+	return tableNumber, nil
+}
+
+// FindRouteTableConflicts returns a description of each route of the route table of a secondary ENI that goes through
+// another interface, which means that another agent of the node uses the same table. It is meant to be called before
+// SetupENINetwork replaces the default route of the table.
+func (n *linuxNetwork) FindRouteTableConflicts(mac string, deviceNumber int) ([]string, error) {
+	tableNumber, err := eniRouteTable(n.routeTableBase, deviceNumber)
+	if err != nil {
+		return nil, err
+	}
+	link, err := linkByMac(mac, n.netLink, retryLinkByMacInterval)
+	if err != nil {
+		return nil, errors.Wrapf(err, "FindRouteTableConflicts: failed to find the link which uses MAC address %s", mac)
+	}
+	routes, err := n.netLink.RouteListFiltered(unix.AF_INET, &netlink.Route{Table: tableNumber}, netlink.RT_FILTER_TABLE)
+	if err != nil {
+		return nil, errors.Wrapf(err, "FindRouteTableConflicts: failed to list the routes of table %d", tableNumber)
+	}
+	var conflicts []string
+	for _, route := range routes {
+		if route.LinkIndex != link.Attrs().Index {
+			conflicts = append(conflicts, fmt.Sprintf("table %d has route %s", tableNumber, route.String()))
+		}
+	}
+	return conflicts, nil
+}
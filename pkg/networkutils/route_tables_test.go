@@ -0,0 +1,80 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package networkutils
+
+import (
+	"net"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+func TestParseRouteTableBase(t *testing.T) {
+	base, err := ParseRouteTableBase("20")
+	assert.NoError(t, err)
+	assert.Equal(t, 20, base)
+	for _, value := range []string{"", "x", "0", "-1", "100"} {
+		_, err := ParseRouteTableBase(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestGetRouteTableBase(t *testing.T) {
+	defer os.Unsetenv(envRouteTableBase)
+
+	_ = os.Unsetenv(envRouteTableBase)
+	assert.Equal(t, DefaultRouteTableBase, getRouteTableBase())
+	_ = os.Setenv(envRouteTableBase, "20")
+	assert.Equal(t, 20, getRouteTableBase())
+	_ = os.Setenv(envRouteTableBase, "254")
+	assert.Equal(t, DefaultRouteTableBase, getRouteTableBase())
+}
+
+func TestENIRouteTable(t *testing.T) {
+	assert.Equal(t, 2, ENIRouteTable(DefaultRouteTableBase, 1))
+	table, err := eniRouteTable(20, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 23, table)
+	_, err = eniRouteTable(90, 10)
+	assert.Error(t, err)
+}
+
+func TestFindRouteTableConflicts(t *testing.T) {
+	ctrl, mockNetLink, _, _, _, _ := setup(t)
+	defer ctrl.Finish()
+
+	ln := &linuxNetwork{netLink: mockNetLink, routeTableBase: 20}
+	mac2, _ := net.ParseMAC(testMAC2)
+	eth1 := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth1", Index: 3, HardwareAddr: mac2}}
+	ours := netlink.Route{LinkIndex: 3, Dst: &net.IPNet{IP: net.IPv4zero, Mask: net.CIDRMask(0, 32)}, Table: 22}
+	vpn := netlink.Route{LinkIndex: 7, Dst: &net.IPNet{IP: net.ParseIP("172.16.0.0"), Mask: net.CIDRMask(12, 32)}, Table: 22}
+
+	mockNetLink.EXPECT().LinkList().Return([]netlink.Link{eth1}, nil)
+	mockNetLink.EXPECT().RouteListFiltered(unix.AF_INET, &netlink.Route{Table: 22}, netlink.RT_FILTER_TABLE).
+		Return([]netlink.Route{ours}, nil)
+	conflicts, err := ln.FindRouteTableConflicts(testMAC2, 2)
+	assert.NoError(t, err)
+	assert.Empty(t, conflicts)
+
+	mockNetLink.EXPECT().LinkList().Return([]netlink.Link{eth1}, nil)
+	mockNetLink.EXPECT().RouteListFiltered(unix.AF_INET, &netlink.Route{Table: 22}, netlink.RT_FILTER_TABLE).
+		Return([]netlink.Route{ours, vpn}, nil)
+	conflicts, err = ln.FindRouteTableConflicts(testMAC2, 2)
+	assert.NoError(t, err)
+	assert.Len(t, conflicts, 1)
+	assert.Contains(t, conflicts[0], "172.16.0.0/12")
+}
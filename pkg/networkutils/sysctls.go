@@ -0,0 +1,142 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package networkutils
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// envInterfaceSysctls is a comma separated list of <interfaces>.<setting>=<value> entries, giving the IPv4 settings
+	// of the primary ENI, the secondary ENIs or all interfaces that ipamd applies and re-asserts. Defaults to empty.
+	envInterfaceSysctls = "AWS_VPC_K8S_CNI_INTERFACE_SYSCTLS"
+
+	sysctlPrimaryInterface    = "primary"
+	sysctlSecondaryInterfaces = "secondary"
+	sysctlAllInterfaces       = "all"
+)
+
+// managedSysctls are the per interface settings that can be set through AWS_VPC_K8S_CNI_INTERFACE_SYSCTLS
+var managedSysctls = map[string]bool{
+	"rp_filter":    true,
+	"log_martians": true,
+	"arp_ignore":   true,
+	"arp_announce": true,
+	"forwarding":   true,
+}
+
+// interfaceSysctl is a setting of net/ipv4/conf/<interface> for a kind of interface
+type interfaceSysctl struct {
+	interfaces string
+	setting    string
+	value      string
+}
+
+// parseInterfaceSysctls parses a comma or whitespace separated list of <interfaces>.<setting>=<value> entries. Invalid
+// entries are logged and skipped.
+func parseInterfaceSysctls(value string) []interfaceSysctl {
+	var sysctls []interfaceSysctl
+	for _, entry := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		sysctl, err := parseInterfaceSysctl(entry)
+		if err != nil {
+			log.Errorf("Ignoring %s entry %q: %v", envInterfaceSysctls, entry, err)
+			continue
+		}
+		sysctls = append(sysctls, sysctl)
+	}
+	return sysctls
+}
+
+func parseInterfaceSysctl(entry string) (interfaceSysctl, error) {
+	parts := strings.SplitN(entry, "=", 2)
+	if len(parts) != 2 {
+		return interfaceSysctl{}, errors.New("expected <interfaces>.<setting>=<value>")
+	}
+	names := strings.SplitN(parts[0], ".", 2)
+	if len(names) != 2 {
+		return interfaceSysctl{}, errors.New("expected <interfaces>.<setting>=<value>")
+	}
+	switch names[0] {
+	case sysctlPrimaryInterface, sysctlSecondaryInterfaces, sysctlAllInterfaces:
+	default:
+		return interfaceSysctl{}, errors.Errorf("interfaces must be %s, %s or %s", sysctlPrimaryInterface,
+			sysctlSecondaryInterfaces, sysctlAllInterfaces)
+	}
+	if !managedSysctls[names[1]] {
+		return interfaceSysctl{}, errors.Errorf("setting %s can't be managed", names[1])
+	}
+	if _, err := strconv.Atoi(parts[1]); err != nil {
+		return interfaceSysctl{}, errors.Errorf("value %s is not a number", parts[1])
+	}
+	return interfaceSysctl{interfaces: names[0], setting: names[1], value: parts[1]}, nil
+}
+
+// applyInterfaceSysctls applies the settings of a kind of interfaces to the interface name
+func (n *linuxNetwork) applyInterfaceSysctls(interfaces string, name string) error {
+	for _, sysctl := range n.interfaceSysctls {
+		if sysctl.interfaces != interfaces {
+			continue
+		}
+		if err := n.sysctls.Apply("net/ipv4/conf/"+name+"/"+sysctl.setting, sysctl.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasInterfaceSysctls returns true if settings are configured for a kind of interfaces
+func (n *linuxNetwork) hasInterfaceSysctls(interfaces string) bool {
+	for _, sysctl := range n.interfaceSysctls {
+		if sysctl.interfaces == interfaces {
+			return true
+		}
+	}
+	return false
+}
+
+// applyHostSysctls applies the settings of all interfaces and of the primary ENI
+func (n *linuxNetwork) applyHostSysctls(primaryMAC string) error {
+	if err := n.applyInterfaceSysctls(sysctlAllInterfaces, sysctlAllInterfaces); err != nil {
+		return err
+	}
+	if !n.hasInterfaceSysctls(sysctlPrimaryInterface) {
+		return nil
+	}
+	primaryIntf, err := n.findPrimaryInterfaceName(primaryMAC)
+	if err != nil {
+		return err
+	}
+	return n.applyInterfaceSysctls(sysctlPrimaryInterface, primaryIntf)
+}
+
+// applySecondaryENISysctls applies the settings of the secondary ENIs to the interface of an ENI
+func (n *linuxNetwork) applySecondaryENISysctls(eniMAC string) error {
+	if !n.hasInterfaceSysctls(sysctlSecondaryInterfaces) {
+		return nil
+	}
+	link, err := linkByMac(eniMAC, n.netLink, retryLinkByMacInterval)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the link which uses MAC address %s", eniMAC)
+	}
+	return n.applyInterfaceSysctls(sysctlSecondaryInterfaces, link.Attrs().Name)
+}
+
+// ReconcileSysctls sets back the settings ipamd applied that something else changed, and returns their keys
+func (n *linuxNetwork) ReconcileSysctls() ([]string, error) {
+	return n.sysctls.Reconcile()
+}
@@ -0,0 +1,69 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package networkutils
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/procsyswrapper"
+)
+
+func TestParseInterfaceSysctls(t *testing.T) {
+	assert.Empty(t, parseInterfaceSysctls(""))
+	assert.Equal(t, []interfaceSysctl{
+		{interfaces: "primary", setting: "rp_filter", value: "2"},
+		{interfaces: "secondary", setting: "arp_ignore", value: "1"},
+		{interfaces: "all", setting: "log_martians", value: "1"},
+	}, parseInterfaceSysctls("primary.rp_filter=2,\n secondary.arp_ignore=1 all.log_martians=1 eth0.rp_filter=1 "+
+		"all.accept_local=1 primary.rp_filter=loose secondary=1"))
+}
+
+func TestApplyInterfaceSysctls(t *testing.T) {
+	ctrl, mockNetLink, _, _, _, mockProcSys := setup(t)
+	defer ctrl.Finish()
+
+	ln := &linuxNetwork{
+		netLink:          mockNetLink,
+		procSys:          mockProcSys,
+		sysctls:          procsyswrapper.NewManager(mockProcSys),
+		interfaceSysctls: parseInterfaceSysctls("primary.rp_filter=2,secondary.rp_filter=1,secondary.log_martians=1,all.arp_announce=2"),
+	}
+	mac1, _ := net.ParseMAC(testMAC1)
+	mac2, _ := net.ParseMAC(testMAC2)
+	eth0 := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth0", Index: 2, HardwareAddr: mac1}}
+	eth1 := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth1", Index: 3, HardwareAddr: mac2}}
+	mockNetLink.EXPECT().LinkList().Return([]netlink.Link{eth0, eth1}, nil).Times(2)
+
+	mockProcSys.EXPECT().Set("net/ipv4/conf/all/arp_announce", "2").Return(nil)
+	mockProcSys.EXPECT().Set("net/ipv4/conf/eth0/rp_filter", "2").Return(nil)
+	assert.NoError(t, ln.applyHostSysctls(testMAC1))
+
+	mockProcSys.EXPECT().Set("net/ipv4/conf/eth1/rp_filter", "1").Return(nil)
+	mockProcSys.EXPECT().Set("net/ipv4/conf/eth1/log_martians", "1").Return(nil)
+	assert.NoError(t, ln.applySecondaryENISysctls(testMAC2))
+
+	// Drifted settings are set back
+	mockProcSys.EXPECT().Get("net/ipv4/conf/all/arp_announce").Return("2\n", nil)
+	mockProcSys.EXPECT().Get("net/ipv4/conf/eth0/rp_filter").Return("1\n", nil)
+	mockProcSys.EXPECT().Set("net/ipv4/conf/eth0/rp_filter", "2").Return(nil)
+	mockProcSys.EXPECT().Get("net/ipv4/conf/eth1/log_martians").Return("1\n", nil)
+	mockProcSys.EXPECT().Get("net/ipv4/conf/eth1/rp_filter").Return("1\n", nil)
+	drifted, err := ln.ReconcileSysctls()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"net/ipv4/conf/eth0/rp_filter"}, drifted)
+}
@@ -0,0 +1,138 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package networkutils
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	// WarmVethPrefix is the name prefix of the host side of the veth pairs pre-plumbed for pods, see SetupWarmVeth
+	WarmVethPrefix = "warmveth"
+	// warmVethPeerPrefix is the name prefix of the pod side of the pre-plumbed veth pairs, until a pod takes them
+	warmVethPeerPrefix = "warmpeer"
+)
+
+// WarmVethName returns the name of the host side of the pre-plumbed veth pair with the given index
+func WarmVethName(index int) string {
+	return fmt.Sprintf("%s%d", WarmVethPrefix, index)
+}
+
+// WarmVethPeerName returns the name of the pod side of the pre-plumbed veth pair whose host side is hostVeth
+func WarmVethPeerName(hostVeth string) string {
+	return warmVethPeerPrefix + strings.TrimPrefix(hostVeth, WarmVethPrefix)
+}
+
+// SetupWarmVeth creates a veth pair and wires its host side for ip the way the CNI plugin does for a pod: the kernel
+// settings, the route to ip and the rules sending the traffic to and from ip through the main table and the route
+// table of the ENI. A pod then only has to take the peer, which is left down in the host network namespace.
+func (n *linuxNetwork) SetupWarmVeth(hostVeth string, ip *net.IPNet, deviceNumber int) error {
+	// A veth pair failing to be wired is removed along with the routes and rules it already got
+	tx := NewNetlinkTransaction(n.netLink)
+	if err := n.setupWarmVeth(tx, hostVeth, ip, deviceNumber); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Warnf("SetupWarmVeth: %v", rollbackErr)
+		}
+		return errors.Wrap(err, "SetupWarmVeth")
+	}
+	return nil
+}
+
+func (n *linuxNetwork) setupWarmVeth(tx *NetlinkTransaction, hostVeth string, ip *net.IPNet, deviceNumber int) error {
+	peer := WarmVethPeerName(hostVeth)
+	veth := &netlink.Veth{
+		LinkAttrs: netlink.LinkAttrs{Name: hostVeth, MTU: n.mtu},
+		PeerName:  peer,
+	}
+	if err := tx.LinkAdd(veth); err != nil {
+		return errors.Wrapf(err, "failed to create veth pair %s/%s", hostVeth, peer)
+	}
+	link, err := n.netLink.LinkByName(hostVeth)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find link %s", hostVeth)
+	}
+	for _, key := range []string{"accept_ra", "accept_redirects"} {
+		if err := n.procSys.Set(fmt.Sprintf("net/ipv6/conf/%s/%s", hostVeth, key), "0"); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "failed to set %s of %s", key, hostVeth)
+		}
+	}
+	if err := n.netLink.LinkSetUp(link); err != nil {
+		return errors.Wrapf(err, "failed to set link %s up", hostVeth)
+	}
+	return setupWarmVethRouteRules(tx, link, ip, n.routeTableBase, deviceNumber)
+}
+
+// setupWarmVethRouteRules adds the route to ip through the host side of a warm veth pair, and the rules of ip
+func setupWarmVethRouteRules(tx *NetlinkTransaction, link netlink.Link, ip *net.IPNet, routeTableBase int, deviceNumber int) error {
+	route := &netlink.Route{
+		LinkIndex: link.Attrs().Index,
+		Scope:     netlink.SCOPE_LINK,
+		Dst:       ip,
+		Table:     mainRoutingTable,
+	}
+	if err := tx.RouteReplace(route); err != nil {
+		return errors.Wrapf(err, "failed to add route to %s", ip)
+	}
+	toPodRule := tx.netLink.NewRule()
+	toPodRule.Dst = ip
+	toPodRule.Priority = toPodRulePriority
+	toPodRule.Table = mainRoutingTable
+	if err := tx.RuleAdd(toPodRule); err != nil {
+		return errors.Wrapf(err, "failed to add rule to %s", ip)
+	}
+	if deviceNumber > 0 {
+		fromPodRule := tx.netLink.NewRule()
+		fromPodRule.Src = ip
+		fromPodRule.Priority = fromPodRulePriority
+		fromPodRule.Table = ENIRouteTable(routeTableBase, deviceNumber)
+		if err := tx.RuleAdd(fromPodRule); err != nil {
+			return errors.Wrapf(err, "failed to add rule from %s", ip)
+		}
+	}
+	return nil
+}
+
+// ListWarmVeths returns the names of the host side of the pre-plumbed veth pairs
+func (n *linuxNetwork) ListWarmVeths() ([]string, error) {
+	links, err := n.netLink.LinkList()
+	if err != nil {
+		return nil, errors.Wrap(err, "ListWarmVeths: failed to list links")
+	}
+	var names []string
+	for _, link := range links {
+		if link.Type() == "veth" && strings.HasPrefix(link.Attrs().Name, WarmVethPrefix) {
+			names = append(names, link.Attrs().Name)
+		}
+	}
+	return names, nil
+}
+
+// DeleteWarmVeth deletes a pre-plumbed veth pair, which also deletes the route to its IP. The rules of the IP are kept,
+// since they are the same for the pod which may have the IP by then.
+func (n *linuxNetwork) DeleteWarmVeth(hostVeth string) error {
+	link, err := n.netLink.LinkByName(hostVeth)
+	if err != nil {
+		if _, ok := err.(netlink.LinkNotFoundError); ok {
+			return nil
+		}
+		return errors.Wrapf(err, "DeleteWarmVeth: failed to find link %s", hostVeth)
+	}
+	return errors.Wrapf(n.netLink.LinkDel(link), "DeleteWarmVeth: failed to delete link %s", hostVeth)
+}
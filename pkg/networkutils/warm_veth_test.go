@@ -0,0 +1,128 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package networkutils
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+)
+
+func TestWarmVethNames(t *testing.T) {
+	assert.Equal(t, "warmveth3", WarmVethName(3))
+	assert.Equal(t, "warmpeer3", WarmVethPeerName("warmveth3"))
+}
+
+func TestSetupWarmVeth(t *testing.T) {
+	ctrl, mockNetLink, _, _, _, mockProcSys := setup(t)
+	defer ctrl.Finish()
+
+	ln := &linuxNetwork{netLink: mockNetLink, procSys: mockProcSys, mtu: testMTU, routeTableBase: DefaultRouteTableBase}
+	ip := &net.IPNet{IP: net.ParseIP("10.0.0.42"), Mask: net.CIDRMask(32, 32)}
+	link := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "warmveth0", Index: 12}}
+	var toPodRule, fromPodRule netlink.Rule
+
+	gomock.InOrder(
+		mockNetLink.EXPECT().LinkAdd(&netlink.Veth{
+			LinkAttrs: netlink.LinkAttrs{Name: "warmveth0", MTU: testMTU},
+			PeerName:  "warmpeer0",
+		}).Return(nil),
+		mockNetLink.EXPECT().LinkByName("warmveth0").Return(link, nil),
+		mockProcSys.EXPECT().Set("net/ipv6/conf/warmveth0/accept_ra", "0").Return(nil),
+		mockProcSys.EXPECT().Set("net/ipv6/conf/warmveth0/accept_redirects", "0").Return(nil),
+		mockNetLink.EXPECT().LinkSetUp(link).Return(nil),
+		mockNetLink.EXPECT().RouteReplace(&netlink.Route{
+			LinkIndex: 12,
+			Scope:     netlink.SCOPE_LINK,
+			Dst:       ip,
+			Table:     mainRoutingTable,
+		}).Return(nil),
+		mockNetLink.EXPECT().NewRule().Return(&toPodRule),
+		mockNetLink.EXPECT().RuleAdd(&toPodRule).Return(nil),
+		mockNetLink.EXPECT().NewRule().Return(&fromPodRule),
+		mockNetLink.EXPECT().RuleAdd(&fromPodRule).Return(nil),
+	)
+
+	assert.NoError(t, ln.SetupWarmVeth("warmveth0", ip, 2))
+	assert.Equal(t, ip, toPodRule.Dst)
+	assert.Equal(t, toPodRulePriority, toPodRule.Priority)
+	assert.Equal(t, mainRoutingTable, toPodRule.Table)
+	assert.Equal(t, ip, fromPodRule.Src)
+	assert.Equal(t, fromPodRulePriority, fromPodRule.Priority)
+	assert.Equal(t, 3, fromPodRule.Table)
+}
+
+func TestSetupWarmVethRollback(t *testing.T) {
+	ctrl, mockNetLink, _, _, _, mockProcSys := setup(t)
+	defer ctrl.Finish()
+
+	ln := &linuxNetwork{netLink: mockNetLink, procSys: mockProcSys, mtu: testMTU, routeTableBase: DefaultRouteTableBase}
+	ip := &net.IPNet{IP: net.ParseIP("10.0.0.42"), Mask: net.CIDRMask(32, 32)}
+	veth := &netlink.Veth{
+		LinkAttrs: netlink.LinkAttrs{Name: "warmveth0", MTU: testMTU},
+		PeerName:  "warmpeer0",
+	}
+	link := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "warmveth0", Index: 12}}
+	route := &netlink.Route{
+		LinkIndex: 12,
+		Scope:     netlink.SCOPE_LINK,
+		Dst:       ip,
+		Table:     mainRoutingTable,
+	}
+	toPodRule := netlink.NewRule()
+	fromPodRule := netlink.NewRule()
+
+	gomock.InOrder(
+		mockNetLink.EXPECT().LinkAdd(veth).Return(nil),
+		mockNetLink.EXPECT().LinkByName("warmveth0").Return(link, nil),
+		mockProcSys.EXPECT().Set("net/ipv6/conf/warmveth0/accept_ra", "0").Return(nil),
+		mockProcSys.EXPECT().Set("net/ipv6/conf/warmveth0/accept_redirects", "0").Return(nil),
+		mockNetLink.EXPECT().LinkSetUp(link).Return(nil),
+		mockNetLink.EXPECT().RouteReplace(route).Return(nil),
+		mockNetLink.EXPECT().NewRule().Return(toPodRule),
+		mockNetLink.EXPECT().RuleAdd(toPodRule).Return(nil),
+		mockNetLink.EXPECT().NewRule().Return(fromPodRule),
+		mockNetLink.EXPECT().RuleAdd(fromPodRule).Return(errors.New("some error")),
+		// the rule, route and veth pair already set up are removed, the last first
+		mockNetLink.EXPECT().RuleDel(toPodRule).Return(nil),
+		mockNetLink.EXPECT().RouteDel(route).Return(nil),
+		mockNetLink.EXPECT().LinkDel(veth).Return(nil),
+	)
+
+	assert.EqualError(t, ln.SetupWarmVeth("warmveth0", ip, 2), "SetupWarmVeth: failed to add rule from 10.0.0.42/32: some error")
+}
+
+func TestListAndDeleteWarmVeths(t *testing.T) {
+	ctrl, mockNetLink, _, _, _, _ := setup(t)
+	defer ctrl.Finish()
+
+	ln := &linuxNetwork{netLink: mockNetLink}
+	warm := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "warmveth0"}}
+	peer := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "warmpeer0"}}
+	pod := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "eni12345678901"}}
+	mockNetLink.EXPECT().LinkList().Return([]netlink.Link{warm, peer, pod}, nil)
+	names, err := ln.ListWarmVeths()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"warmveth0"}, names)
+
+	mockNetLink.EXPECT().LinkByName("warmveth0").Return(warm, nil)
+	mockNetLink.EXPECT().LinkDel(warm).Return(nil)
+	assert.NoError(t, ln.DeleteWarmVeth("warmveth0"))
+	mockNetLink.EXPECT().LinkByName("warmveth1").Return(nil, netlink.LinkNotFoundError{})
+	assert.NoError(t, ln.DeleteWarmVeth("warmveth1"))
+}
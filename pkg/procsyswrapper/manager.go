@@ -0,0 +1,82 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package procsyswrapper
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Manager applies /proc/sys settings and re-asserts them when something else on the node changes them
+type Manager struct {
+	procSys  ProcSys
+	lock     sync.Mutex
+	settings map[string]string
+}
+
+// NewManager returns a Manager applying the settings through procSys
+func NewManager(procSys ProcSys) *Manager {
+	return &Manager{procSys: procSys, settings: make(map[string]string)}
+}
+
+// Apply sets key to value, and keeps it at that value on every Reconcile
+func (m *Manager) Apply(key, value string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if err := m.procSys.Set(key, value); err != nil {
+		return errors.Wrapf(err, "failed to set %s to %s", key, value)
+	}
+	m.settings[key] = value
+	return nil
+}
+
+// Reconcile sets back the settings that drifted from the value they were applied with, and returns their keys.
+// Settings whose key no longer exists, e.g. the ones of a detached interface, are dropped.
+func (m *Manager) Reconcile() ([]string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	keys := make([]string, 0, len(m.settings))
+	for key := range m.settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var drifted, failures []string
+	for _, key := range keys {
+		value, err := m.procSys.Get(key)
+		if os.IsNotExist(err) {
+			delete(m.settings, key)
+			continue
+		}
+		if err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+		if strings.TrimSpace(value) == m.settings[key] {
+			continue
+		}
+		drifted = append(drifted, key)
+		if err := m.procSys.Set(key, m.settings[key]); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	if len(failures) > 0 {
+		return drifted, errors.Errorf("failed to reconcile settings: %s", strings.Join(failures, ", "))
+	}
+	return drifted, nil
+}
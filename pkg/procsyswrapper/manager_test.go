@@ -0,0 +1,53 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package procsyswrapper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "net/ipv4/conf/eth0"), 0755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "net/ipv4/conf/eth1"), 0755))
+	procSys := &procSys{prefix: dir + "/"}
+	m := NewManager(procSys)
+
+	assert.NoError(t, m.Apply("net/ipv4/conf/eth0/rp_filter", "2"))
+	assert.NoError(t, m.Apply("net/ipv4/conf/eth1/log_martians", "1"))
+	assert.Error(t, m.Apply("net/ipv4/conf/eth2/rp_filter", "1"))
+	drifted, err := m.Reconcile()
+	assert.NoError(t, err)
+	assert.Empty(t, drifted)
+
+	// Something else loosens the setting
+	assert.NoError(t, procSys.Set("net/ipv4/conf/eth0/rp_filter", "1\n"))
+	drifted, err = m.Reconcile()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"net/ipv4/conf/eth0/rp_filter"}, drifted)
+	value, err := procSys.Get("net/ipv4/conf/eth0/rp_filter")
+	assert.NoError(t, err)
+	assert.Equal(t, "2", value)
+
+	// The settings of a detached interface are dropped
+	assert.NoError(t, os.RemoveAll(filepath.Join(dir, "net/ipv4/conf/eth1")))
+	drifted, err = m.Reconcile()
+	assert.NoError(t, err)
+	assert.Empty(t, drifted)
+	assert.Len(t, m.settings, 1)
+}
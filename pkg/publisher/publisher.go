@@ -16,6 +16,8 @@ package publisher
 
 import (
 	"context"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -24,6 +26,7 @@ import (
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/ec2wrapper"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/service/cloudwatch"
 	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
 	"github.com/pkg/errors"
@@ -33,7 +36,7 @@ const (
 	// defaultInterval for monitoring the watch list
 	defaultInterval = time.Second * 60
 
-	// cloudwatchMetricNamespace for custom metrics
+	// cloudwatchMetricNamespace is the default namespace for custom metrics
 	cloudwatchMetricNamespace = "Kubernetes"
 
 	// Metric dimension constants
@@ -72,6 +75,20 @@ type Publisher interface {
 	Stop()
 }
 
+// Config is the configuration of the CloudWatch publisher
+type Config struct {
+	// Region to publish the metrics to, the region of the instance if empty
+	Region string
+	// ClusterID is the value of the CLUSTER_ID dimension, found from the instance tags if empty
+	ClusterID string
+	// Namespace of the metrics, "Kubernetes" if empty
+	Namespace string
+	// RoleARN is the IAM role assumed to publish the metrics, the default credentials are used if empty
+	RoleARN string
+	// Dimensions are added to every metric, after CLUSTER_ID
+	Dimensions map[string]string
+}
+
 // cloudWatchPublisher implements the `Publisher` interface for batching and publishing
 // metric data to the CloudWatch metrics backend
 type cloudWatchPublisher struct {
@@ -79,6 +96,8 @@ type cloudWatchPublisher struct {
 	cancel               context.CancelFunc
 	updateIntervalTicker *time.Ticker
 	clusterID            string
+	namespace            string
+	dimensions           map[string]string
 	cloudwatchClient     cloudwatchiface.CloudWatchAPI
 	localMetricData      []*cloudwatch.MetricDatum
 	lock                 sync.RWMutex
@@ -90,8 +109,9 @@ type cloudWatchPublisher struct {
 // Case 3: Cx blocked IMDS access and not using IRSA (which means region == "") AND
 // not specified clusterID then its a Cx error
 // New returns a new instance of `Publisher`
-func New(ctx context.Context, region string, clusterID string, log logger.Logger) (Publisher, error) {
+func New(ctx context.Context, cfg Config, log logger.Logger) (Publisher, error) {
 	sess := awssession.New()
+	region, clusterID := cfg.Region, cfg.ClusterID
 
 	// If Customers have explicitly specified clusterID then skip generating it
 	if clusterID == "" {
@@ -120,6 +140,11 @@ func New(ctx context.Context, region string, clusterID string, log logger.Logger
 		Region: aws.String(region),
 	}
 	sess = sess.Copy(&awsCfg)
+	if cfg.RoleARN != "" {
+		// The role is assumed with the credentials of the node or of the service account
+		log.Infof("Assuming role %s to publish metrics", cfg.RoleARN)
+		sess = sess.Copy(&aws.Config{Credentials: stscreds.NewCredentials(sess, cfg.RoleARN)})
+	}
 
 	// Get CloudWatch client
 	cloudwatchClient := cloudwatch.New(sess)
@@ -132,6 +157,8 @@ func New(ctx context.Context, region string, clusterID string, log logger.Logger
 		cancel:           cancel,
 		cloudwatchClient: cloudwatchClient,
 		clusterID:        clusterID,
+		namespace:        cfg.Namespace,
+		dimensions:       cfg.Dimensions,
 		localMetricData:  make([]*cloudwatch.MetricDatum, 0, localMetricDataSize),
 	}, nil
 }
@@ -150,7 +177,7 @@ func (p *cloudWatchPublisher) Stop() {
 
 // Publish is a variadic function to publish one or more metric data points
 func (p *cloudWatchPublisher) Publish(metricDataPoints ...*cloudwatch.MetricDatum) {
-	// Fetch dimensions for override
+	// Fetch the cluster dimensions
 	log.Info("Fetching CloudWatch dimensions")
 	dimensions := p.getCloudWatchMetricDatumDimensions()
 
@@ -158,9 +185,9 @@ func (p *cloudWatchPublisher) Publish(metricDataPoints ...*cloudwatch.MetricDatu
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
-	// NOTE: Iteration is used to override the metric dimensions
+	// NOTE: Iteration is used to add the cluster dimension in front of the datum's own dimensions
 	for _, metricDatum := range metricDataPoints {
-		metricDatum.Dimensions = dimensions
+		metricDatum.Dimensions = append(dimensions[:len(dimensions):len(dimensions)], metricDatum.Dimensions...)
 		p.localMetricData = append(p.localMetricData, metricDatum)
 	}
 }
@@ -224,6 +251,9 @@ func (p *cloudWatchPublisher) monitor(interval time.Duration) {
 }
 
 func (p *cloudWatchPublisher) getCloudWatchMetricNamespace() *string {
+	if p.namespace != "" {
+		return aws.String(p.namespace)
+	}
 	return aws.String(cloudwatchMetricNamespace)
 }
 
@@ -244,12 +274,45 @@ func getClusterID(ec2Client *ec2wrapper.EC2Wrapper) string {
 }
 
 func (p *cloudWatchPublisher) getCloudWatchMetricDatumDimensions() []*cloudwatch.Dimension {
-	return []*cloudwatch.Dimension{
+	dimensions := []*cloudwatch.Dimension{
 		{
 			Name:  aws.String(clusterIDDimension),
 			Value: aws.String(p.clusterID),
 		},
 	}
+	names := make([]string, 0, len(p.dimensions))
+	for name := range p.dimensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		dimensions = append(dimensions, &cloudwatch.Dimension{
+			Name:  aws.String(name),
+			Value: aws.String(p.dimensions[name]),
+		})
+	}
+	return dimensions
+}
+
+// ParseDimensions parses a comma separated list of <name>=<value> metric dimensions
+func ParseDimensions(value string) (map[string]string, error) {
+	dimensions := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || name == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, errors.Errorf("invalid dimension %q, expected <name>=<value>", entry)
+		}
+		if name == clusterIDDimension {
+			return nil, errors.Errorf("invalid dimension %q, %s is set from the cluster ID", entry, clusterIDDimension)
+		}
+		dimensions[name] = strings.TrimSpace(parts[1])
+	}
+	return dimensions, nil
 }
 
 // min is a helper to compute the min of two integers
@@ -46,7 +46,7 @@ func TestCloudWatchPublisherWithNoIMDS(t *testing.T) {
 	region := "us-west-2"
 	clusterID := testClusterID
 
-	cw, err := New(ctx, region, clusterID, log)
+	cw, err := New(ctx, Config{Region: region, ClusterID: clusterID, RoleARN: "arn:aws:iam::123456789012:role/cni-metrics"}, log)
 	assert.NoError(t, err)
 	assert.NotNil(t, cw)
 }
@@ -171,6 +171,9 @@ func TestGetCloudWatchMetricNamespace(t *testing.T) {
 
 	testNamespace := cloudwatchPublisher.getCloudWatchMetricNamespace()
 	assert.Equal(t, aws.StringValue(testNamespace), cloudwatchMetricNamespace)
+
+	cloudwatchPublisher.namespace = "EKS/CNI"
+	assert.Equal(t, "EKS/CNI", aws.StringValue(cloudwatchPublisher.getCloudWatchMetricNamespace()))
 }
 
 func TestGetCloudWatchMetricDatumDimensions(t *testing.T) {
@@ -201,6 +204,35 @@ func TestGetCloudWatchMetricDatumDimensionsWithMissingClusterID(t *testing.T) {
 	assert.Equal(t, testCloudwatchDimensions, expectedCloudwatchDimensions)
 }
 
+func TestGetCloudWatchMetricDatumDimensionsWithCustomDimensions(t *testing.T) {
+	cloudwatchPublisher := getCloudWatchPublisher(t)
+	cloudwatchPublisher.dimensions = map[string]string{"NodeGroup": "ng-1", "Environment": "prod"}
+
+	expectedCloudwatchDimensions := []*cloudwatch.Dimension{
+		{Name: aws.String(clusterIDDimension), Value: aws.String(testClusterID)},
+		{Name: aws.String("Environment"), Value: aws.String("prod")},
+		{Name: aws.String("NodeGroup"), Value: aws.String("ng-1")},
+	}
+	assert.Equal(t, expectedCloudwatchDimensions, cloudwatchPublisher.getCloudWatchMetricDatumDimensions())
+}
+
+func TestPublishKeepsDatumDimensions(t *testing.T) {
+	cloudwatchPublisher := getCloudWatchPublisher(t)
+
+	testCloudwatchMetricDatum := &cloudwatch.MetricDatum{
+		MetricName: aws.String(testMetricOne),
+		Unit:       aws.String(cloudwatch.StandardUnitNone),
+		Value:      aws.Float64(1.0),
+		Dimensions: []*cloudwatch.Dimension{{Name: aws.String("SubnetId"), Value: aws.String("subnet-1")}},
+	}
+
+	cloudwatchPublisher.Publish(testCloudwatchMetricDatum)
+	assert.Equal(t, []*cloudwatch.Dimension{
+		{Name: aws.String(clusterIDDimension), Value: aws.String(testClusterID)},
+		{Name: aws.String("SubnetId"), Value: aws.String("subnet-1")},
+	}, cloudwatchPublisher.localMetricData[0].Dimensions)
+}
+
 func TestPublishWithNoData(t *testing.T) {
 	cloudwatchPublisher := &cloudWatchPublisher{}
 
@@ -218,6 +250,21 @@ func TestPushWithMissingData(t *testing.T) {
 	assert.Empty(t, cloudwatchPublisher.localMetricData)
 }
 
+func TestParseDimensions(t *testing.T) {
+	dimensions, err := ParseDimensions("")
+	assert.NoError(t, err)
+	assert.Empty(t, dimensions)
+
+	dimensions, err = ParseDimensions("NodeGroup=ng-1, Environment = prod,")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"NodeGroup": "ng-1", "Environment": "prod"}, dimensions)
+
+	for _, value := range []string{"NodeGroup", "=ng-1", "NodeGroup=", "CLUSTER_ID=my-cluster"} {
+		_, err = ParseDimensions(value)
+		assert.Error(t, err, value)
+	}
+}
+
 func TestMin(t *testing.T) {
 	a, b := 1, 2
 
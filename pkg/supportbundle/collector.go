@@ -0,0 +1,302 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package supportbundle collects the state of aws-node and of the node network into a tarball for support
+// investigations, replacing the aws-cni-support.sh script
+package supportbundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/pkg/errors"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils/awssession"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
+)
+
+var log = logger.Get()
+
+const (
+	defaultIntrospectionAddress = "http://localhost:61679"
+	defaultMetricsAddress       = "http://localhost:61678"
+	defaultLogDir               = "/host/var/log/aws-routed-eni"
+	// defaultMaxLogBytes is how much of the end of each log file is collected
+	defaultMaxLogBytes = 10 << 20
+	// httpTimeout bounds each request to the introspection, metrics and instance metadata endpoints
+	httpTimeout = 10 * time.Second
+)
+
+// introspectionPaths are the ipamd introspection endpoints collected
+var introspectionPaths = []string{
+	"/v1/enis",
+	"/v1/pods",
+	"/v1/datastore-snapshot",
+	"/v1/datastore-invariants",
+	"/v1/eni-configs",
+	"/v1/efa-enis",
+	"/v1/pool-state",
+	"/v1/subnet-prefixes",
+	"/v1/networkutils-env-settings",
+	"/v1/ipamd-env-settings",
+	"/v1/config",
+	"/v1/readiness",
+	"/v1/capabilities",
+	"/v1/pool-decisions",
+	"/v1/host-veths",
+	"/v1/teardown-queue",
+	"/v1/quarantine",
+	"/v1/audit-log",
+}
+
+// commands are the outputs of the network tools collected, by file name. A tool missing from the image only leaves
+// an entry in errors.txt.
+var commands = map[string][]string{
+	"iptables-save.txt":     {"iptables-save", "-c"},
+	"ip6tables-save.txt":    {"ip6tables-save", "-c"},
+	"nft-ruleset.txt":       {"nft", "list", "ruleset"},
+	"ip-rule.txt":           {"ip", "rule", "show"},
+	"ip6-rule.txt":          {"ip", "-6", "rule", "show"},
+	"ip-route.txt":          {"ip", "route", "show", "table", "all"},
+	"ip6-route.txt":         {"ip", "-6", "route", "show", "table", "all"},
+	"ip-addr.txt":           {"ip", "addr", "show"},
+	"ip-link.txt":           {"ip", "-d", "link", "show"},
+	"ip-neigh.txt":          {"ip", "neigh", "show"},
+	"sysctl-net-ipv4.txt":   {"sysctl", "net.ipv4"},
+	"conntrack-summary.txt": {"conntrack", "-S"},
+}
+
+// imdsPaths are the instance metadata collected, next to the ones of each ENI under network/interfaces/macs/<mac>/
+var imdsPaths = []string{"instance-id", "instance-type", "placement/availability-zone", "local-ipv4", "mac"}
+
+var imdsENIPaths = []string{"device-number", "interface-id", "local-ipv4s", "ipv4-prefix", "ipv6s", "ipv6-prefix",
+	"subnet-id", "subnet-ipv4-cidr-block", "vpc-ipv4-cidr-blocks", "security-group-ids"}
+
+// Options of a bundle
+type Options struct {
+	// Output is the path of the gzipped tarball written
+	Output string
+	// IntrospectionAddress and MetricsAddress are the base URLs of the ipamd endpoints
+	IntrospectionAddress string
+	MetricsAddress       string
+	// LogDir is the directory of the aws-node and CNI plugin logs
+	LogDir string
+	// MaxLogBytes is how much of the end of each log file is collected
+	MaxLogBytes int64
+	Redaction   Redaction
+}
+
+// DefaultOptions returns the options to collect a bundle from the aws-node container into its log directory, which is
+// mounted from the host
+func DefaultOptions() Options {
+	redaction, _ := ParseRedaction(DefaultRedaction)
+	return Options{
+		Output: filepath.Join(defaultLogDir,
+			fmt.Sprintf("aws-cni-support-%s.tar.gz", time.Now().UTC().Format("2006-01-02T150405Z"))),
+		IntrospectionAddress: defaultIntrospectionAddress,
+		MetricsAddress:       defaultMetricsAddress,
+		LogDir:               defaultLogDir,
+		MaxLogBytes:          defaultMaxLogBytes,
+		Redaction:            redaction,
+	}
+}
+
+// source is a file of the bundle
+type source struct {
+	name string
+	read func() ([]byte, error)
+}
+
+// Collect writes a bundle of the introspection endpoints, the metrics, the iptables, nftables, rules and routes of
+// the node, its instance metadata and the end of the logs to opts.Output. A source that fails is listed in
+// errors.txt instead of failing the bundle.
+func Collect(opts Options) error {
+	httpClient := &http.Client{Timeout: httpTimeout}
+	var sources []source
+	for _, path := range introspectionPaths {
+		sources = append(sources, httpSource(httpClient, "introspection"+path+".json", opts.IntrospectionAddress+path))
+	}
+	sources = append(sources, httpSource(httpClient, "metrics.txt", opts.MetricsAddress+"/metrics"))
+	sources = append(sources, commandSources()...)
+	sources = append(sources, imdsSources(ec2metadata.New(awssession.New()))...)
+	logSources, err := logSources(opts.LogDir, opts.MaxLogBytes)
+	if err != nil {
+		log.Warnf("Failed to list the logs in %s: %v", opts.LogDir, err)
+	}
+	sources = append(sources, logSources...)
+
+	if err := os.MkdirAll(filepath.Dir(opts.Output), 0755); err != nil {
+		return errors.Wrap(err, "failed to create the bundle directory")
+	}
+	file, err := os.OpenFile(opts.Output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to create the bundle")
+	}
+	if err := writeBundle(file, sources, newRedactor(opts.Redaction)); err != nil {
+		file.Close()
+		return err
+	}
+	return errors.Wrap(file.Close(), "failed to write the bundle")
+}
+
+// writeBundle reads the sources and writes them to a gzipped tarball, with an errors.txt of the failed ones
+func writeBundle(w io.Writer, sources []source, r *redactor) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	var failures []string
+	add := func(name string, data []byte) error {
+		header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), ModTime: now}
+		if err := tw.WriteHeader(header); err != nil {
+			return errors.Wrapf(err, "failed to add %s to the bundle", name)
+		}
+		_, err := tw.Write(data)
+		return errors.Wrapf(err, "failed to add %s to the bundle", name)
+	}
+	for _, s := range sources {
+		data, err := s.read()
+		if err != nil {
+			log.Warnf("Failed to collect %s: %v", s.name, err)
+			failures = append(failures, fmt.Sprintf("%s: %v", s.name, err))
+			if len(data) == 0 {
+				continue
+			}
+		}
+		if err := add(s.name, r.redact(data)); err != nil {
+			return err
+		}
+	}
+	if len(failures) > 0 {
+		if err := add("errors.txt", r.redact([]byte(strings.Join(failures, "\n")+"\n"))); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return errors.Wrap(err, "failed to write the bundle")
+	}
+	return errors.Wrap(gz.Close(), "failed to write the bundle")
+}
+
+func httpSource(client *http.Client, name, url string) source {
+	return source{name: name, read: func() ([]byte, error) {
+		resp, err := client.Get(url)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		data, err := ioutil.ReadAll(resp.Body)
+		if err == nil && resp.StatusCode != http.StatusOK {
+			err = errors.Errorf("%s returned %s", url, resp.Status)
+		}
+		return data, err
+	}}
+}
+
+func commandSources() []source {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sources := make([]source, 0, len(names))
+	for _, name := range names {
+		argv := commands[name]
+		sources = append(sources, source{name: "network/" + name, read: func() ([]byte, error) {
+			// The output of a failed command, e.g. the usage of a tool too old for a flag, is still worth keeping
+			return exec.Command(argv[0], argv[1:]...).CombinedOutput()
+		}})
+	}
+	return sources
+}
+
+// metadataClient is the part of the ec2metadata client used by the bundle
+type metadataClient interface {
+	GetMetadata(path string) (string, error)
+}
+
+func imdsSources(client metadataClient) []source {
+	read := func(path string) func() ([]byte, error) {
+		return func() ([]byte, error) {
+			value, err := client.GetMetadata(path)
+			return []byte(value), err
+		}
+	}
+	var sources []source
+	for _, path := range imdsPaths {
+		sources = append(sources, source{name: "imds/" + path, read: read(path)})
+	}
+	macs, err := client.GetMetadata("network/interfaces/macs/")
+	if err != nil {
+		return append(sources, source{name: "imds/network/interfaces/macs", read: func() ([]byte, error) {
+			return nil, err
+		}})
+	}
+	for _, mac := range strings.Fields(macs) {
+		mac = strings.TrimSuffix(mac, "/")
+		for _, path := range imdsENIPaths {
+			eniPath := "network/interfaces/macs/" + mac + "/" + path
+			sources = append(sources, source{name: "imds/" + eniPath, read: read(eniPath)})
+		}
+	}
+	return sources
+}
+
+// logSources returns the end of each file of the log directory
+func logSources(dir string, maxBytes int64) ([]source, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var sources []source
+	for _, entry := range entries {
+		// Bundles collected earlier are in the same directory
+		if !entry.Mode().IsRegular() || strings.HasSuffix(entry.Name(), ".tar.gz") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		sources = append(sources, source{name: "logs/" + entry.Name(), read: func() ([]byte, error) {
+			return tailFile(path, maxBytes)
+		}})
+	}
+	return sources, nil
+}
+
+// tailFile returns the last maxBytes bytes of a file
+func tailFile(path string, maxBytes int64) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if offset := info.Size() - maxBytes; offset > 0 {
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+	return ioutil.ReadAll(file)
+}
@@ -0,0 +1,130 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package supportbundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRedaction(t *testing.T) {
+	redaction, err := ParseRedaction(DefaultRedaction)
+	assert.NoError(t, err)
+	assert.Equal(t, Redaction{IPs: true, AccountIDs: true}, redaction)
+
+	redaction, err = ParseRedaction("none")
+	assert.NoError(t, err)
+	assert.Equal(t, Redaction{}, redaction)
+
+	redaction, err = ParseRedaction("account-ids")
+	assert.NoError(t, err)
+	assert.Equal(t, Redaction{AccountIDs: true}, redaction)
+
+	_, err = ParseRedaction("ips,macs")
+	assert.Error(t, err)
+}
+
+func TestRedact(t *testing.T) {
+	r := newRedactor(Redaction{IPs: true, AccountIDs: true})
+	in := "eni 10.0.1.5 via 10.0.0.1 dev eth1, pod 10.0.1.5 2600:1f14:abc::5 " +
+		"lo 127.0.0.1 gw 169.254.1.1 fe80::1 arn:aws:iam::123456789012:role/node"
+	assert.Equal(t, "eni ip-1 via ip-2 dev eth1, pod ip-1 ip-3 "+
+		"lo 127.0.0.1 gw 169.254.1.1 fe80::1 arn:aws:iam::<account-id>:role/node", string(r.redact([]byte(in))))
+
+	// Version strings and times aren't addresses
+	assert.Equal(t, "v1.11.4 10:15:00 999.1.1.1", string(r.redact([]byte("v1.11.4 10:15:00 999.1.1.1"))))
+
+	r = newRedactor(Redaction{})
+	assert.Equal(t, in, string(r.redact([]byte(in))))
+}
+
+func TestWriteBundle(t *testing.T) {
+	sources := []source{
+		{name: "introspection/v1/enis.json", read: func() ([]byte, error) {
+			return []byte(`{"IP":"10.0.1.5"}`), nil
+		}},
+		{name: "network/nft-ruleset.txt", read: func() ([]byte, error) {
+			return nil, errors.New("nft not found")
+		}},
+		{name: "network/ip-rule.txt", read: func() ([]byte, error) {
+			return []byte("Usage: ip rule"), errors.New("exit status 1")
+		}},
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, writeBundle(&buf, sources, newRedactor(Redaction{IPs: true})))
+
+	files := readBundle(t, &buf)
+	assert.Equal(t, map[string]string{
+		"introspection/v1/enis.json": `{"IP":"ip-1"}`,
+		"network/ip-rule.txt":        "Usage: ip rule",
+		"errors.txt":                 "network/nft-ruleset.txt: nft not found\nnetwork/ip-rule.txt: exit status 1\n",
+	}, files)
+}
+
+func TestLogSources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "supportbundle")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "ipamd.log"), []byte("0123456789"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "aws-cni-support-old.tar.gz"), []byte("bundle"), 0600))
+
+	sources, err := logSources(dir, 4)
+	assert.NoError(t, err)
+	assert.Len(t, sources, 1)
+	assert.Equal(t, "logs/ipamd.log", sources[0].name)
+	data, err := sources[0].read()
+	assert.NoError(t, err)
+	assert.Equal(t, "6789", string(data))
+}
+
+type fakeMetadata map[string]string
+
+func (m fakeMetadata) GetMetadata(path string) (string, error) {
+	value, ok := m[path]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	return value, nil
+}
+
+func TestIMDSSources(t *testing.T) {
+	sources := imdsSources(fakeMetadata{"network/interfaces/macs/": "02:00:00:00:00:01/\n02:00:00:00:00:02/"})
+	assert.Len(t, sources, len(imdsPaths)+2*len(imdsENIPaths))
+	assert.Equal(t, "imds/network/interfaces/macs/02:00:00:00:00:02/security-group-ids", sources[len(sources)-1].name)
+}
+
+func readBundle(t *testing.T, buf *bytes.Buffer) map[string]string {
+	gz, err := gzip.NewReader(buf)
+	assert.NoError(t, err)
+	tr := tar.NewReader(gz)
+	files := make(map[string]string)
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			break
+		}
+		data, err := ioutil.ReadAll(tr)
+		assert.NoError(t, err)
+		files[header.Name] = string(data)
+	}
+	return files
+}
@@ -0,0 +1,96 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package supportbundle
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Redaction tells what is replaced in the collected files before they are added to the bundle
+type Redaction struct {
+	// IPs replaces each IP address, except the loopback, unspecified and link-local ones, with ip-<n>. An address is
+	// always replaced with the same token, so that it can still be followed across the files of a bundle.
+	IPs bool
+	// AccountIDs replaces the 12 digit numbers, as in ARNs and owner IDs, with <account-id>
+	AccountIDs bool
+}
+
+// DefaultRedaction redacts both the IPs and the account IDs
+const DefaultRedaction = "ips,account-ids"
+
+// ParseRedaction parses a comma separated list of "ips" and "account-ids", or "none"
+func ParseRedaction(value string) (Redaction, error) {
+	var redaction Redaction
+	for _, item := range strings.Split(value, ",") {
+		switch strings.TrimSpace(item) {
+		case "ips":
+			redaction.IPs = true
+		case "account-ids":
+			redaction.AccountIDs = true
+		case "none", "":
+		default:
+			return Redaction{}, fmt.Errorf("unknown redaction %q, expected ips, account-ids or none", item)
+		}
+	}
+	return redaction, nil
+}
+
+var (
+	ipv4Pattern      = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	ipv6Pattern      = regexp.MustCompile(`\b[0-9a-fA-F]{0,4}(?::[0-9a-fA-F]{0,4}){2,7}\b`)
+	accountIDPattern = regexp.MustCompile(`\b\d{12}\b`)
+)
+
+// redactor applies a Redaction to the files of a bundle
+type redactor struct {
+	redaction Redaction
+	lock      sync.Mutex
+	ips       map[string]string
+}
+
+func newRedactor(redaction Redaction) *redactor {
+	return &redactor{redaction: redaction, ips: make(map[string]string)}
+}
+
+func (r *redactor) redact(data []byte) []byte {
+	if r.redaction.IPs {
+		data = ipv4Pattern.ReplaceAllFunc(data, r.redactIP)
+		data = ipv6Pattern.ReplaceAllFunc(data, r.redactIP)
+	}
+	if r.redaction.AccountIDs {
+		data = accountIDPattern.ReplaceAll(data, []byte("<account-id>"))
+	}
+	return data
+}
+
+// redactIP returns the token of an address, or the match unchanged if it isn't an address worth hiding
+func (r *redactor) redactIP(match []byte) []byte {
+	ip := net.ParseIP(string(match))
+	if ip == nil || ip.IsLoopback() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+		return match
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	key := ip.String()
+	token, ok := r.ips[key]
+	if !ok {
+		token = fmt.Sprintf("ip-%d", len(r.ips)+1)
+		r.ips[key] = token
+	}
+	return []byte(token)
+}
@@ -0,0 +1,171 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package audit records the changes that ipamd makes to EC2, to the iptables rules and network configuration of the
+// node and to its datastore, as an append-only log of JSON lines
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
+)
+
+const (
+	// envEnableAuditLog turns on the audit log. Defaults to false.
+	envEnableAuditLog = "ENABLE_AUDIT_LOG"
+
+	// envAuditLogFile is the path of the audit log, rotated like the ipamd log
+	envAuditLogFile     = "AUDIT_LOG_FILE"
+	defaultAuditLogFile = "/host/var/log/aws-routed-eni/audit.log"
+
+	// recentEntries is the number of entries kept in memory for Tail
+	recentEntries = 1000
+)
+
+// The categories of the changes
+const (
+	CategoryEC2       = "ec2"
+	CategoryIptables  = "iptables"
+	CategoryNetlink   = "netlink"
+	CategoryDatastore = "datastore"
+)
+
+var log = logger.Get()
+
+// auditLog is nil unless the audit log is enabled, in which case Record is a no-op
+var auditLog *Log
+
+// Entry is a change made by ipamd
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Category string    `json:"category"`
+	// Action is the EC2 API, the iptables or netlink operation, or assign and unassign for the datastore
+	Action string `json:"action"`
+	// Target is what was changed, e.g. the parameters of the EC2 request or the iptables rule
+	Target string `json:"target"`
+	// CorrelationID ties the entries of the same request, e.g. the container ID of a pod or the ipamd subsystem that
+	// called EC2, when known
+	CorrelationID string `json:"correlationID,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// Log writes the entries to a file, and keeps the last ones in memory
+type Log struct {
+	lock   sync.Mutex
+	writer io.Writer
+	size   int
+	recent []Entry
+}
+
+// NewLog returns a log that writes the entries to writer and keeps the last size ones in memory
+func NewLog(writer io.Writer, size int) *Log {
+	return &Log{writer: writer, size: size}
+}
+
+// Init enables the audit log if ENABLE_AUDIT_LOG is set, so that Record writes to AUDIT_LOG_FILE
+func Init() {
+	if !strings.EqualFold(os.Getenv(envEnableAuditLog), "true") {
+		return
+	}
+	path := os.Getenv(envAuditLogFile)
+	if path == "" {
+		path = defaultAuditLogFile
+	}
+	log.Infof("Writing the audit log to %s", path)
+	auditLog = NewLog(logger.NewRotatingWriter(path), recentEntries)
+}
+
+// SetLog replaces the audit log, nil disables it
+func SetLog(l *Log) {
+	auditLog = l
+}
+
+// Enabled returns true if the audit log is enabled
+func Enabled() bool {
+	return auditLog != nil
+}
+
+// Record adds a change to the audit log, if enabled
+func Record(category, action, target, correlationID string, err error) {
+	if auditLog == nil {
+		return
+	}
+	entry := Entry{
+		Time:          time.Now().UTC(),
+		Category:      category,
+		Action:        action,
+		Target:        target,
+		CorrelationID: correlationID,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	auditLog.record(entry)
+}
+
+// Tail returns the last n entries recorded since ipamd started, or all of them if n is not positive
+func Tail(n int) []Entry {
+	if auditLog == nil {
+		return []Entry{}
+	}
+	return auditLog.tail(n)
+}
+
+func (l *Log) record(entry Entry) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Errorf("Failed to marshal audit log entry: %v", err)
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if _, err := l.writer.Write(append(line, '\n')); err != nil {
+		log.Errorf("Failed to write audit log entry: %v", err)
+	}
+	l.recent = append(l.recent, entry)
+	if len(l.recent) > l.size {
+		l.recent = append([]Entry(nil), l.recent[len(l.recent)-l.size:]...)
+	}
+}
+
+func (l *Log) tail(n int) []Entry {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if n <= 0 || n > len(l.recent) {
+		n = len(l.recent)
+	}
+	return append([]Entry{}, l.recent[len(l.recent)-n:]...)
+}
+
+type correlationIDKey struct{}
+
+// WithCorrelationID returns a copy of ctx whose changes are recorded with id
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationIDFromContext returns the ID set on ctx by WithCorrelationID, or an empty string
+func CorrelationIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,59 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecord(t *testing.T) {
+	// Nothing is recorded while disabled
+	Record(CategoryIptables, "append", "nat AWS-SNAT-CHAIN-0 -j RETURN", "", nil)
+	assert.Empty(t, Tail(0))
+
+	var buf bytes.Buffer
+	SetLog(NewLog(&buf, 2))
+	defer SetLog(nil)
+
+	Record(CategoryDatastore, "assign", "10.0.0.1 to default/pod-1", "cid-1", nil)
+	Record(CategoryNetlink, "RouteAdd", "10.0.0.1 dev eni1", "", errors.New("file exists"))
+	Record(CategoryDatastore, "unassign", "10.0.0.1 from default/pod-1", "cid-1", nil)
+
+	// The file has every entry, one per line
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 3)
+	var entry Entry
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+	assert.Equal(t, CategoryNetlink, entry.Category)
+	assert.Equal(t, "file exists", entry.Error)
+
+	// Only the last ones are kept in memory
+	entries := Tail(0)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "RouteAdd", entries[0].Action)
+	assert.Equal(t, []Entry{entries[1]}, Tail(1))
+	assert.Equal(t, "unassign", entries[1].Action)
+}
+
+func TestCorrelationIDFromContext(t *testing.T) {
+	assert.Equal(t, "", CorrelationIDFromContext(context.Background()))
+	assert.Equal(t, "trace-1", CorrelationIDFromContext(WithCorrelationID(context.Background(), "trace-1")))
+}
@@ -0,0 +1,112 @@
+package cniutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	"github.com/containernetworking/cni/pkg/types"
+	"github.com/containernetworking/cni/pkg/types/current"
+	"github.com/containernetworking/cni/pkg/version"
+)
+
+// SpecVersion100 is the 1.0.0 version of the CNI spec. The vendored CNI library stops at 0.4.0, so the 1.0.0 results
+// are converted here. They only differ from 0.4.0 ones by the IP configurations, which no longer have a version.
+const SpecVersion100 = "1.0.0"
+
+// AllSpecVersions are the CNI spec versions supported by the plugins
+var AllSpecVersions = version.PluginSupports("0.1.0", "0.2.0", "0.3.0", "0.3.1", "0.4.0", SpecVersion100)
+
+// ParsePrevResult parses the prevResult of conf into a *current.Result, like version.ParsePrevResult, for every
+// version of AllSpecVersions
+func ParsePrevResult(conf *types.NetConf) error {
+	if conf.RawPrevResult == nil || conf.CNIVersion != SpecVersion100 {
+		return version.ParsePrevResult(conf)
+	}
+
+	resultBytes, err := json.Marshal(conf.RawPrevResult)
+	if err != nil {
+		return fmt.Errorf("could not serialize prevResult: %v", err)
+	}
+	result := &current.Result{}
+	if err := json.Unmarshal(resultBytes, result); err != nil {
+		return fmt.Errorf("could not parse prevResult: %v", err)
+	}
+	result.CNIVersion = current.ImplementedSpecVersion
+	for _, ipConfig := range result.IPs {
+		ipConfig.Version = "6"
+		if ipConfig.Address.IP.To4() != nil {
+			ipConfig.Version = "4"
+		}
+	}
+	conf.RawPrevResult = nil
+	conf.PrevResult = result
+	return nil
+}
+
+// ResultForVersion returns result in a form that converts to the CNI spec version of the network configuration
+func ResultForVersion(result *current.Result, specVersion string) types.Result {
+	if specVersion != SpecVersion100 {
+		return result
+	}
+	r := &Result100{
+		CNIVersion: SpecVersion100,
+		Interfaces: result.Interfaces,
+		Routes:     result.Routes,
+		DNS:        result.DNS,
+	}
+	for _, ipConfig := range result.IPs {
+		r.IPs = append(r.IPs, &IPConfig100{
+			Interface: ipConfig.Interface,
+			Address:   types.IPNet(ipConfig.Address),
+			Gateway:   ipConfig.Gateway,
+		})
+	}
+	return r
+}
+
+// Result100 is a CNI spec 1.0.0 result
+type Result100 struct {
+	CNIVersion string               `json:"cniVersion,omitempty"`
+	Interfaces []*current.Interface `json:"interfaces,omitempty"`
+	IPs        []*IPConfig100       `json:"ips,omitempty"`
+	Routes     []*types.Route       `json:"routes,omitempty"`
+	DNS        types.DNS            `json:"dns,omitempty"`
+}
+
+// IPConfig100 is an IP configuration of a CNI spec 1.0.0 result
+type IPConfig100 struct {
+	Interface *int        `json:"interface,omitempty"`
+	Address   types.IPNet `json:"address"`
+	Gateway   net.IP      `json:"gateway,omitempty"`
+}
+
+// Version returns the spec version of the result
+func (r *Result100) Version() string {
+	return r.CNIVersion
+}
+
+// GetAsVersion returns the result, which can't be converted to another version
+func (r *Result100) GetAsVersion(specVersion string) (types.Result, error) {
+	if specVersion != SpecVersion100 {
+		return nil, fmt.Errorf("cannot convert version %s to %s", SpecVersion100, specVersion)
+	}
+	return r, nil
+}
+
+// Print prints the result in JSON format to stdout
+func (r *Result100) Print() error {
+	return r.PrintTo(os.Stdout)
+}
+
+// PrintTo prints the result in JSON format to writer
+func (r *Result100) PrintTo(writer io.Writer) error {
+	data, err := json.MarshalIndent(r, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(data)
+	return err
+}
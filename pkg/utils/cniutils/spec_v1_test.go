@@ -0,0 +1,80 @@
+package cniutils
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/containernetworking/cni/pkg/types"
+	"github.com/containernetworking/cni/pkg/types/current"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePrevResultSpecVersion100(t *testing.T) {
+	var conf types.NetConf
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"cniVersion": "1.0.0",
+		"name": "aws-cni",
+		"prevResult": {
+			"cniVersion": "1.0.0",
+			"interfaces": [{"name": "eni8ea2c11fe35"}, {"name": "eth0", "sandbox": "/var/run/netns/cni-1"}],
+			"ips": [
+				{"interface": 1, "address": "192.168.1.1/32", "gateway": "169.254.1.1"},
+				{"interface": 1, "address": "2001:db8::1/128"}
+			],
+			"routes": [{"dst": "0.0.0.0/0", "gw": "169.254.1.1"}]
+		}
+	}`), &conf))
+
+	assert.NoError(t, ParsePrevResult(&conf))
+	result, ok := conf.PrevResult.(*current.Result)
+	assert.True(t, ok)
+	assert.Len(t, result.Interfaces, 2)
+	assert.Equal(t, "4", result.IPs[0].Version)
+	assert.Equal(t, "192.168.1.1/32", result.IPs[0].Address.String())
+	assert.Equal(t, "6", result.IPs[1].Version)
+	assert.Len(t, result.Routes, 1)
+}
+
+func TestParsePrevResultSpecVersion040(t *testing.T) {
+	var conf types.NetConf
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"cniVersion": "0.4.0",
+		"prevResult": {"cniVersion": "0.4.0", "ips": [{"version": "4", "address": "192.168.1.1/32"}]}
+	}`), &conf))
+
+	assert.NoError(t, ParsePrevResult(&conf))
+	result, ok := conf.PrevResult.(*current.Result)
+	assert.True(t, ok)
+	assert.Equal(t, "4", result.IPs[0].Version)
+}
+
+func TestResultForVersion(t *testing.T) {
+	result := &current.Result{
+		Interfaces: []*current.Interface{{Name: "eni8ea2c11fe35"}, {Name: "eth0", Sandbox: "/var/run/netns/cni-1"}},
+		IPs: []*current.IPConfig{{
+			Version:   "4",
+			Interface: aws.Int(1),
+			Address:   net.IPNet{IP: net.ParseIP("192.168.1.1"), Mask: net.CIDRMask(32, 32)},
+			Gateway:   net.ParseIP("169.254.1.1"),
+		}},
+		DNS: types.DNS{Nameservers: []string{"10.100.0.10"}},
+	}
+	assert.Equal(t, result, ResultForVersion(result, "0.4.0"))
+
+	converted, err := ResultForVersion(result, SpecVersion100).GetAsVersion(SpecVersion100)
+	assert.NoError(t, err)
+	var out bytes.Buffer
+	assert.NoError(t, converted.PrintTo(&out))
+	assert.JSONEq(t, `{
+		"cniVersion": "1.0.0",
+		"interfaces": [{"name": "eni8ea2c11fe35"}, {"name": "eth0", "sandbox": "/var/run/netns/cni-1"}],
+		"ips": [{"interface": 1, "address": "192.168.1.1/32", "gateway": "169.254.1.1"}],
+		"dns": {"nameservers": ["10.100.0.10"]}
+	}`, out.String())
+
+	_, err = converted.GetAsVersion("0.4.0")
+	assert.Error(t, err)
+}
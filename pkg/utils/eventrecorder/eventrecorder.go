@@ -24,6 +24,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/tools/record"
@@ -97,3 +98,24 @@ func (e *EventRecorder) BroadcastEvent(eventType, reason, message string) {
 		e.recorder.Event(&pod, eventType, reason, message)
 	}
 }
+
+// SendNodeEvent records an event on the node aws-node is running on
+func (e *EventRecorder) SendNodeEvent(eventType, reason, message string) {
+	nodeRef := &corev1.ObjectReference{
+		Kind: "Node",
+		Name: myNodeName,
+		UID:  types.UID(myNodeName),
+	}
+	log.Debugf("Sending event on node %s", myNodeName)
+	e.recorder.Event(nodeRef, eventType, reason, message)
+}
+
+// InitMockEventRecorder sets up an event recorder backed by a fake recorder, for use in other packages' tests
+func InitMockEventRecorder(k8sClient client.Client) *record.FakeRecorder {
+	fakeRecorder := record.NewFakeRecorder(10)
+	eventRecorder = &EventRecorder{
+		recorder:  fakeRecorder,
+		k8sClient: k8sClient,
+	}
+	return fakeRecorder
+}
@@ -103,3 +103,20 @@ func TestBroadcastEvents(t *testing.T) {
 	got := <-fakeRecorder.Events
 	assert.Equal(t, expected, got)
 }
+
+func TestSendNodeEvent(t *testing.T) {
+	m := setup(t)
+	defer m.ctrl.Finish()
+
+	fakeRecorder = record.NewFakeRecorder(1)
+	mockEventRecorder := &EventRecorder{
+		recorder:  fakeRecorder,
+		k8sClient: m.mockK8sClient,
+	}
+
+	reason := "IPAddressesExhausted"
+	msg := "No free IP addresses left on the node"
+	mockEventRecorder.SendNodeEvent(v1.EventTypeWarning, reason, msg)
+	assert.Len(t, fakeRecorder.Events, 1)
+	assert.Equal(t, fmt.Sprintf("%s %s %s", v1.EventTypeWarning, reason, msg), <-fakeRecorder.Events)
+}
@@ -15,6 +15,8 @@ package logger
 
 import (
 	"os"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -22,6 +24,21 @@ const (
 	defaultLogLevel    = "Debug"
 	envLogLevel        = "AWS_VPC_K8S_CNI_LOGLEVEL"
 	envLogFilePath     = "AWS_VPC_K8S_CNI_LOG_FILE"
+
+	// envLogMaxSize is the size in megabytes at which a log file is rotated
+	envLogMaxSize     = "AWS_VPC_K8S_CNI_LOG_MAX_SIZE_MB"
+	defaultLogMaxSize = 100
+	// envLogMaxBackups is the number of rotated log files kept
+	envLogMaxBackups     = "AWS_VPC_K8S_CNI_LOG_MAX_BACKUPS"
+	defaultLogMaxBackups = 5
+	// envLogMaxAge is the number of days a rotated log file is kept
+	envLogMaxAge     = "AWS_VPC_K8S_CNI_LOG_MAX_AGE_DAYS"
+	defaultLogMaxAge = 30
+	// envLogCompress turns the gzip compression of the rotated log files on or off. Defaults to true.
+	envLogCompress = "AWS_VPC_K8S_CNI_LOG_COMPRESS"
+	// envLogMaxTotalSize caps the disk space in megabytes of a log file and of its rotated files, by lowering the
+	// number of rotated files kept, and the rotation size if the cap is below two files. Not capped by default.
+	envLogMaxTotalSize = "AWS_VPC_K8S_CNI_LOG_MAX_TOTAL_SIZE_MB"
 )
 
 // Configuration stores the config for the logger
@@ -58,3 +75,59 @@ func GetLogLevel() string {
 		return logLevel
 	}
 }
+
+// RotationConfig is how the log files are rotated
+type RotationConfig struct {
+	// MaxSize in megabytes of a log file before it is rotated
+	MaxSize int
+	// MaxBackups is the number of rotated files kept
+	MaxBackups int
+	// MaxAge in days of the rotated files kept
+	MaxAge int
+	// Compress the rotated files
+	Compress bool
+	// MaxTotalSize in megabytes of a log file and of its rotated files, 0 if not capped
+	MaxTotalSize int
+}
+
+// LoadRotationConfig returns the rotation of the log files configured by the environment, capped to the disk budget
+func LoadRotationConfig() RotationConfig {
+	config := RotationConfig{
+		MaxSize:      getPositiveIntEnv(envLogMaxSize, defaultLogMaxSize),
+		MaxBackups:   getPositiveIntEnv(envLogMaxBackups, defaultLogMaxBackups),
+		MaxAge:       getPositiveIntEnv(envLogMaxAge, defaultLogMaxAge),
+		Compress:     !strings.EqualFold(os.Getenv(envLogCompress), "false"),
+		MaxTotalSize: getPositiveIntEnv(envLogMaxTotalSize, 0),
+	}
+	config.capToBudget()
+	return config
+}
+
+// capToBudget lowers the number of rotated files, then the rotation size, so that a full set of files fits in
+// MaxTotalSize. The size of the rotated files is counted before compression.
+func (config *RotationConfig) capToBudget() {
+	if config.MaxTotalSize <= 0 {
+		return
+	}
+	// At least one rotated file is kept, since lumberjack keeps all of them with 0
+	if config.MaxTotalSize < 2*config.MaxSize {
+		config.MaxSize = config.MaxTotalSize / 2
+		if config.MaxSize < 1 {
+			config.MaxSize = 1
+		}
+	}
+	if backups := config.MaxTotalSize/config.MaxSize - 1; backups < config.MaxBackups {
+		config.MaxBackups = backups
+	}
+	if config.MaxBackups < 1 {
+		config.MaxBackups = 1
+	}
+}
+
+// getPositiveIntEnv returns the positive integer value of env, or defaultValue
+func getPositiveIntEnv(env string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(env)); err == nil && value > 0 {
+		return value
+	}
+	return defaultValue
+}
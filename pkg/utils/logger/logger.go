@@ -61,3 +61,10 @@ func New(inputLogConfig *Configuration) Logger {
 	log.Info("Constructed new logger instance")
 	return log
 }
+
+// SetLogLevel changes the level of the default logger without recreating it, so that it can be changed at runtime
+func SetLogLevel(logLevel string) {
+	if logger, ok := Get().(*structuredLogger); ok {
+		logger.level.SetLevel(getZapLevel(logLevel))
+	}
+}
@@ -98,3 +98,43 @@ func TestGetPluginLogFilePath(t *testing.T) {
 	}
 	assert.Equal(t, zapcore.AddSync(expectedLumberJackLogger), getPluginLogFilePath(inputPluginLogFile))
 }
+
+func TestSetLogLevel(t *testing.T) {
+	logConfig := &Configuration{LogLevel: "info", LogLocation: "stdout"}
+	logger := New(logConfig).(*structuredLogger)
+	assert.False(t, logger.zapLogger.Desugar().Core().Enabled(zapcore.DebugLevel))
+
+	SetLogLevel("debug")
+	assert.True(t, logger.zapLogger.Desugar().Core().Enabled(zapcore.DebugLevel))
+}
+
+func TestLoadRotationConfig(t *testing.T) {
+	assert.Equal(t, RotationConfig{MaxSize: 100, MaxBackups: 5, MaxAge: 30, Compress: true}, LoadRotationConfig())
+
+	t.Setenv(envLogMaxSize, "50")
+	t.Setenv(envLogMaxBackups, "10")
+	t.Setenv(envLogMaxAge, "-1")
+	t.Setenv(envLogCompress, "false")
+	assert.Equal(t, RotationConfig{MaxSize: 50, MaxBackups: 10, MaxAge: 30}, LoadRotationConfig())
+
+	// The budget fits the active file and 3 rotated files of 50MB
+	t.Setenv(envLogMaxTotalSize, "200")
+	assert.Equal(t, RotationConfig{MaxSize: 50, MaxBackups: 3, MaxAge: 30, MaxTotalSize: 200}, LoadRotationConfig())
+}
+
+func TestRotationConfigCapToBudget(t *testing.T) {
+	for _, test := range []struct {
+		config   RotationConfig
+		expected RotationConfig
+	}{
+		{RotationConfig{MaxSize: 100, MaxBackups: 5}, RotationConfig{MaxSize: 100, MaxBackups: 5}},
+		{RotationConfig{MaxSize: 100, MaxBackups: 5, MaxTotalSize: 1000}, RotationConfig{MaxSize: 100, MaxBackups: 5, MaxTotalSize: 1000}},
+		{RotationConfig{MaxSize: 100, MaxBackups: 5, MaxTotalSize: 250}, RotationConfig{MaxSize: 100, MaxBackups: 1, MaxTotalSize: 250}},
+		// Below two files, the files get smaller
+		{RotationConfig{MaxSize: 100, MaxBackups: 5, MaxTotalSize: 150}, RotationConfig{MaxSize: 75, MaxBackups: 1, MaxTotalSize: 150}},
+		{RotationConfig{MaxSize: 100, MaxBackups: 5, MaxTotalSize: 1}, RotationConfig{MaxSize: 1, MaxBackups: 1, MaxTotalSize: 1}},
+	} {
+		test.config.capToBudget()
+		assert.Equal(t, test.expected, test.config)
+	}
+}
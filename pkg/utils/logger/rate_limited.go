@@ -0,0 +1,115 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package logger
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RateLimitedLogger logs each message format at most once per interval, so that a loop failing the same way on every
+// pass does not flood the log. The next message of a format that passes says how many were suppressed. Fatal and
+// panic messages are never suppressed.
+type RateLimitedLogger struct {
+	Logger
+	limiter *rateLimiter
+}
+
+// NewRateLimitedLogger returns a logger that writes to log at most one message of each format per interval
+func NewRateLimitedLogger(log Logger, interval time.Duration) *RateLimitedLogger {
+	return &RateLimitedLogger{Logger: log, limiter: newRateLimiter(interval, time.Now)}
+}
+
+type rateLimiter struct {
+	lock       sync.Mutex
+	interval   time.Duration
+	now        func() time.Time
+	last       map[string]time.Time
+	suppressed map[string]int
+}
+
+func newRateLimiter(interval time.Duration, now func() time.Time) *rateLimiter {
+	return &rateLimiter{interval: interval, now: now, last: map[string]time.Time{}, suppressed: map[string]int{}}
+}
+
+// allow returns true if a message of key can be logged, with the number of messages of key suppressed since the last
+// one logged
+func (r *rateLimiter) allow(key string) (bool, int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	now := r.now()
+	if last, found := r.last[key]; found && now.Sub(last) < r.interval {
+		r.suppressed[key]++
+		return false, 0
+	}
+	suppressed := r.suppressed[key]
+	r.last[key] = now
+	delete(r.suppressed, key)
+	return true, suppressed
+}
+
+// write logs the message of format with write, unless messages of format are suppressed, adding the number of
+// suppressed messages
+func (l *RateLimitedLogger) write(write func(string), format string, args ...interface{}) {
+	allowed, suppressed := l.limiter.allow(format)
+	if !allowed {
+		return
+	}
+	message := format
+	if len(args) > 0 {
+		message = fmt.Sprintf(format, args...)
+	}
+	if suppressed > 0 {
+		message = fmt.Sprintf("%s (%d similar messages suppressed)", message, suppressed)
+	}
+	write(message)
+}
+
+func (l *RateLimitedLogger) Debugf(format string, args ...interface{}) {
+	l.write(l.Logger.Debug, format, args...)
+}
+
+func (l *RateLimitedLogger) Debug(format string) {
+	l.write(l.Logger.Debug, format)
+}
+
+func (l *RateLimitedLogger) Infof(format string, args ...interface{}) {
+	l.write(l.Logger.Info, format, args...)
+}
+
+func (l *RateLimitedLogger) Info(format string) {
+	l.write(l.Logger.Info, format)
+}
+
+func (l *RateLimitedLogger) Warnf(format string, args ...interface{}) {
+	l.write(l.Logger.Warn, format, args...)
+}
+
+func (l *RateLimitedLogger) Warn(format string) {
+	l.write(l.Logger.Warn, format)
+}
+
+func (l *RateLimitedLogger) Errorf(format string, args ...interface{}) {
+	l.write(l.Logger.Error, format, args...)
+}
+
+func (l *RateLimitedLogger) Error(format string) {
+	l.write(l.Logger.Error, format)
+}
+
+// WithFields returns a logger with the fields that shares the rate limits of l
+func (l *RateLimitedLogger) WithFields(fields Fields) Logger {
+	return &RateLimitedLogger{Logger: l.Logger.WithFields(fields), limiter: l.limiter}
+}
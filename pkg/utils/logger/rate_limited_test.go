@@ -0,0 +1,58 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package logger
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingLogger keeps the messages logged through Warn and Error
+type recordingLogger struct {
+	Logger
+	messages []string
+}
+
+func (r *recordingLogger) Warn(message string) {
+	r.messages = append(r.messages, "warn: "+message)
+}
+
+func (r *recordingLogger) Error(message string) {
+	r.messages = append(r.messages, "error: "+message)
+}
+
+func TestRateLimitedLogger(t *testing.T) {
+	recorder := &recordingLogger{}
+	now := time.Now()
+	log := &RateLimitedLogger{Logger: recorder, limiter: newRateLimiter(time.Minute, func() time.Time { return now })}
+
+	for i := 0; i < 3; i++ {
+		log.Errorf("Failed to reconcile ENI %s: %v", "eni-1", fmt.Errorf("attempt %d", i))
+		log.Warn("No ENI found")
+	}
+	now = now.Add(time.Minute)
+	log.Errorf("Failed to reconcile ENI %s: %v", "eni-2", "throttled")
+	log.Warn("No ENI found")
+	log.Warn("No ENI found")
+
+	assert.Equal(t, []string{
+		"error: Failed to reconcile ENI eni-1: attempt 0",
+		"warn: No ENI found",
+		"error: Failed to reconcile ENI eni-2: throttled (2 similar messages suppressed)",
+		"warn: No ENI found (2 similar messages suppressed)",
+	}, recorder.messages)
+}
@@ -14,6 +14,7 @@
 package logger
 
 import (
+	"io"
 	"os"
 	"runtime"
 	"strings"
@@ -25,6 +26,7 @@ import (
 
 type structuredLogger struct {
 	zapLogger *zap.SugaredLogger
+	level     zap.AtomicLevel
 }
 
 // getZapLevel converts log level string to zapcore.Level
@@ -94,7 +96,7 @@ func (logf *structuredLogger) WithFields(fields Fields) Logger {
 		f = append(f, v)
 	}
 	newLogger := logf.zapLogger.With(f...)
-	return &structuredLogger{newLogger}
+	return &structuredLogger{zapLogger: newLogger, level: logf.level}
 }
 
 func getEncoder() zapcore.Encoder {
@@ -106,7 +108,7 @@ func getEncoder() zapcore.Encoder {
 func (logConfig *Configuration) newZapLogger() *structuredLogger {
 	var cores []zapcore.Core
 
-	logLevel := getZapLevel(logConfig.LogLevel)
+	logLevel := zap.NewAtomicLevelAt(getZapLevel(logConfig.LogLevel))
 
 	writer := getPluginLogFilePath(logConfig.LogLocation)
 
@@ -123,6 +125,7 @@ func (logConfig *Configuration) newZapLogger() *structuredLogger {
 
 	return &structuredLogger{
 		zapLogger: sugar,
+		level:     logLevel,
 	}
 }
 
@@ -143,14 +146,19 @@ func getPluginLogFilePath(logFilePath string) zapcore.WriteSyncer {
 
 // getLogWriter is for lumberjack
 func getLogWriter(logFilePath string) zapcore.WriteSyncer {
-	lumberJackLogger := &lumberjack.Logger{
+	return zapcore.AddSync(NewRotatingWriter(logFilePath))
+}
+
+// NewRotatingWriter returns a writer to logFilePath that rotates it as configured by LoadRotationConfig
+func NewRotatingWriter(logFilePath string) io.WriteCloser {
+	rotation := LoadRotationConfig()
+	return &lumberjack.Logger{
 		Filename:   logFilePath,
-		MaxSize:    100,
-		MaxBackups: 5,
-		MaxAge:     30,
-		Compress:   true,
+		MaxSize:    rotation.MaxSize,
+		MaxBackups: rotation.MaxBackups,
+		MaxAge:     rotation.MaxAge,
+		Compress:   rotation.Compress,
 	}
-	return zapcore.AddSync(lumberJackLogger)
 }
 
 // DefaultLogger creates and returns a new default logger.
@@ -0,0 +1,112 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package paths holds the paths of the files aws-node keeps on the host. They can be moved for hosts whose root
+// filesystem is read-only, like Bottlerocket or some custom AMIs.
+package paths
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// EnvRunDir is the directory of the state ipamd keeps across restarts: the IPAM checkpoint, the gRPC socket shared
+	// with the CNI binary and the persisted instance type limits. It must be mounted at the same path as on the host.
+	EnvRunDir = "AWS_VPC_CNI_RUN_DIR"
+	// DefaultRunDir is under /var/run, which is a tmpfs on read-only root filesystems
+	DefaultRunDir = "/var/run/aws-node"
+
+	// EnvCNIBinDir is the directory the CNI binaries are installed to, as mounted in the aws-node container
+	EnvCNIBinDir     = "HOST_CNI_BIN_PATH"
+	defaultCNIBinDir = "/host/opt/cni/bin"
+
+	// EnvCNIConfDir is the directory the CNI network configuration is installed to, as mounted in the aws-node container
+	EnvCNIConfDir     = "HOST_CNI_CONFDIR_PATH"
+	defaultCNIConfDir = "/host/etc/cni/net.d"
+
+	ipamdSocketName        = "ipamd.sock"
+	checkpointName         = "ipam.json"
+	instanceTypeLimitsName = "instance-type-limits.json"
+	poolDecisionLogName    = "pool-decisions.json"
+	teardownQueueName      = "teardown-queue.json"
+)
+
+// RunDir returns the directory of the ipamd state
+func RunDir() string {
+	return getEnv(EnvRunDir, DefaultRunDir)
+}
+
+// IPAMDSocket returns the unix socket ipamd serves gRPC on
+func IPAMDSocket() string {
+	return filepath.Join(RunDir(), ipamdSocketName)
+}
+
+// DefaultIPAMDSocket returns the unix socket ipamd serves gRPC on by default, for a CNI binary that isn't told otherwise
+func DefaultIPAMDSocket() string {
+	return filepath.Join(DefaultRunDir, ipamdSocketName)
+}
+
+// Checkpoint returns the default IPAM checkpoint file
+func Checkpoint() string {
+	return filepath.Join(RunDir(), checkpointName)
+}
+
+// InstanceTypeLimitsFile returns the file the ENI limits found with EC2 are persisted to
+func InstanceTypeLimitsFile() string {
+	return filepath.Join(RunDir(), instanceTypeLimitsName)
+}
+
+// PoolDecisionLog returns the file the warm pool decision log is persisted to
+func PoolDecisionLog() string {
+	return filepath.Join(RunDir(), poolDecisionLogName)
+}
+
+// TeardownQueue returns the file the pod teardowns retried by ipamd are persisted to
+func TeardownQueue() string {
+	return filepath.Join(RunDir(), teardownQueueName)
+}
+
+// CNIBinDir returns the directory the CNI binaries are installed to
+func CNIBinDir() string {
+	return getEnv(EnvCNIBinDir, defaultCNIBinDir)
+}
+
+// CNIConfDir returns the directory the CNI network configuration is installed to
+func CNIConfDir() string {
+	return getEnv(EnvCNIConfDir, defaultCNIConfDir)
+}
+
+// ValidateWritableDir creates dir if needed and checks that files can be written in it. env is the variable that
+// moves the directory, which the error suggests.
+func ValidateWritableDir(dir, env string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrapf(err, "%s is not writable, set %s to a writable directory", dir, env)
+	}
+	f, err := ioutil.TempFile(dir, ".write-check")
+	if err != nil {
+		return errors.Wrapf(err, "%s is not writable, set %s to a writable directory", dir, env)
+	}
+	_ = f.Close()
+	return os.Remove(f.Name())
+}
+
+func getEnv(name, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return defaultValue
+}
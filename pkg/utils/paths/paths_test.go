@@ -0,0 +1,53 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package paths
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunDirPaths(t *testing.T) {
+	defer os.Unsetenv(EnvRunDir)
+
+	_ = os.Unsetenv(EnvRunDir)
+	assert.Equal(t, "/var/run/aws-node/ipamd.sock", IPAMDSocket())
+	assert.Equal(t, "/var/run/aws-node/ipam.json", Checkpoint())
+
+	_ = os.Setenv(EnvRunDir, "/run/aws-node")
+	assert.Equal(t, "/run/aws-node/ipamd.sock", IPAMDSocket())
+	assert.Equal(t, "/run/aws-node/ipam.json", Checkpoint())
+	assert.Equal(t, "/run/aws-node/instance-type-limits.json", InstanceTypeLimitsFile())
+	assert.Equal(t, "/run/aws-node/pool-decisions.json", PoolDecisionLog())
+	assert.Equal(t, "/var/run/aws-node/ipamd.sock", DefaultIPAMDSocket())
+}
+
+func TestValidateWritableDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "aws-node")
+	assert.NoError(t, ValidateWritableDir(dir, EnvRunDir))
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+
+	// A directory can't be created under a file
+	file := filepath.Join(dir, "file")
+	assert.NoError(t, ioutil.WriteFile(file, nil, 0644))
+	err = ValidateWritableDir(filepath.Join(file, "aws-node"), EnvRunDir)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), EnvRunDir)
+}
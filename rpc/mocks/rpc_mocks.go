@@ -89,3 +89,83 @@ func (mr *MockCNIBackendClientMockRecorder) DelNetwork(arg0, arg1 interface{}, a
 	varargs := append([]interface{}{arg0, arg1}, arg2...)
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DelNetwork", reflect.TypeOf((*MockCNIBackendClient)(nil).DelNetwork), varargs...)
 }
+
+// EnqueueTeardown mocks base method
+func (m *MockCNIBackendClient) EnqueueTeardown(arg0 context.Context, arg1 *rpc.EnqueueTeardownRequest, arg2 ...grpc.CallOption) (*rpc.EnqueueTeardownReply, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "EnqueueTeardown", varargs...)
+	ret0, _ := ret[0].(*rpc.EnqueueTeardownReply)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnqueueTeardown indicates an expected call of EnqueueTeardown
+func (mr *MockCNIBackendClientMockRecorder) EnqueueTeardown(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnqueueTeardown", reflect.TypeOf((*MockCNIBackendClient)(nil).EnqueueTeardown), varargs...)
+}
+
+// GarbageCollect mocks base method
+func (m *MockCNIBackendClient) GarbageCollect(arg0 context.Context, arg1 *rpc.GarbageCollectRequest, arg2 ...grpc.CallOption) (*rpc.GarbageCollectReply, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GarbageCollect", varargs...)
+	ret0, _ := ret[0].(*rpc.GarbageCollectReply)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GarbageCollect indicates an expected call of GarbageCollect
+func (mr *MockCNIBackendClientMockRecorder) GarbageCollect(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GarbageCollect", reflect.TypeOf((*MockCNIBackendClient)(nil).GarbageCollect), varargs...)
+}
+
+// GetMaxPods mocks base method
+func (m *MockCNIBackendClient) GetMaxPods(arg0 context.Context, arg1 *rpc.GetMaxPodsRequest, arg2 ...grpc.CallOption) (*rpc.GetMaxPodsReply, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetMaxPods", varargs...)
+	ret0, _ := ret[0].(*rpc.GetMaxPodsReply)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMaxPods indicates an expected call of GetMaxPods
+func (mr *MockCNIBackendClientMockRecorder) GetMaxPods(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMaxPods", reflect.TypeOf((*MockCNIBackendClient)(nil).GetMaxPods), varargs...)
+}
+
+// WatchPodIPs mocks base method
+func (m *MockCNIBackendClient) WatchPodIPs(arg0 context.Context, arg1 *rpc.WatchPodIPsRequest, arg2 ...grpc.CallOption) (rpc.CNIBackend_WatchPodIPsClient, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "WatchPodIPs", varargs...)
+	ret0, _ := ret[0].(rpc.CNIBackend_WatchPodIPsClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WatchPodIPs indicates an expected call of WatchPodIPs
+func (mr *MockCNIBackendClientMockRecorder) WatchPodIPs(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WatchPodIPs", reflect.TypeOf((*MockCNIBackendClient)(nil).WatchPodIPs), varargs...)
+}
@@ -0,0 +1,43 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package rpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// traceIDMetadataKey is the gRPC metadata key of the trace ID of a CNI request
+const traceIDMetadataKey = "x-aws-cni-trace-id"
+
+// WithTraceID returns a copy of ctx that sends traceID in the metadata of the requests to ipamd
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	if traceID == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, traceIDMetadataKey, traceID)
+}
+
+// TraceIDFromIncomingContext returns the trace ID in the metadata of a request received by ipamd, or an empty string
+func TraceIDFromIncomingContext(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get(traceIDMetadataKey); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -82,6 +82,11 @@ validate_env_var()
           ;;
     esac
 
+    if ! [[ "${POD_MTU}" =~ ^[0-9]+$ ]] || [[ ${POD_MTU} -gt ${AWS_VPC_ENI_MTU} ]]; then
+        log_in_json error "POD_MTU must be a number no larger than AWS_VPC_ENI_MTU (${AWS_VPC_ENI_MTU})"
+        exit 1
+    fi
+
     if is_prefix_delegation_enabled && unsupported_prefix_target_conf ; then
        log_in_json error "Setting WARM_PREFIX_TARGET = 0 is not supported while WARM_IP_TARGET/MINIMUM_IP_TARGET is not set. Please configure either one of the WARM_{PREFIX/IP}_TARGET or MINIMUM_IP_TARGET env variables"
        exit 1
@@ -97,13 +102,16 @@ if [ ! -f grpc-health-probe ]; then
     log_in_json error "Required grpc-health-probe executable not found."
     exit 1
 fi
+if [ ! -f cni-installer ]; then
+    log_in_json error "Required cni-installer executable not found."
+    exit 1
+fi
 
 AGENT_LOG_PATH=${AGENT_LOG_PATH:-"aws-k8s-agent.log"}
-HOST_CNI_BIN_PATH=${HOST_CNI_BIN_PATH:-"/host/opt/cni/bin"}
-HOST_CNI_CONFDIR_PATH=${HOST_CNI_CONFDIR_PATH:-"/host/etc/cni/net.d"}
 AWS_VPC_K8S_CNI_VETHPREFIX=${AWS_VPC_K8S_CNI_VETHPREFIX:-"eni"}
 AWS_VPC_K8S_CNI_RANDOMIZESNAT=${AWS_VPC_K8S_CNI_RANDOMIZESNAT:-"prng"}
 AWS_VPC_ENI_MTU=${AWS_VPC_ENI_MTU:-"9001"}
+POD_MTU=${POD_MTU:-"${AWS_VPC_ENI_MTU}"}
 POD_SECURITY_GROUP_ENFORCING_MODE=${POD_SECURITY_GROUP_ENFORCING_MODE:-"strict"}
 AWS_VPC_K8S_PLUGIN_LOG_FILE=${AWS_VPC_K8S_PLUGIN_LOG_FILE:-"/var/log/aws-routed-eni/plugin.log"}
 AWS_VPC_K8S_PLUGIN_LOG_LEVEL=${AWS_VPC_K8S_PLUGIN_LOG_LEVEL:-"Debug"}
@@ -116,8 +124,6 @@ WARM_IP_TARGET=${WARM_IP_TARGET:-"0"}
 MINIMUM_IP_TARGET=${MINIMUM_IP_TARGET:-"0"}
 WARM_PREFIX_TARGET=${WARM_PREFIX_TARGET:-"0"}
 ENABLE_BANDWIDTH_PLUGIN=${ENABLE_BANDWIDTH_PLUGIN:-"false"}
-TMP_AWS_CONFLIST_FILE="/tmp/10-aws.conflist"
-TMP_AWS_BW_CONFLIST_FILE="/tmp/10-aws-bandwidth-plugin.conflist"
 
 validate_env_var
 
@@ -147,21 +153,6 @@ get_node_primary_v4_address() {
     done
 }
 
-# If there is no init container, copy the required files
-if [[ "$AWS_VPC_K8S_CNI_CONFIGURE_RPFILTER" != "false" ]]; then
-    # Copy files
-    log_in_json info "Copying CNI plugin binaries ... "
-    PLUGIN_BINS="loopback portmap bandwidth host-local aws-cni-support.sh"
-    for b in $PLUGIN_BINS; do
-        # Install the binary
-        install "$b" "$HOST_CNI_BIN_PATH"
-    done
-fi
-
-log_in_json info "Install CNI binaries.."
-install aws-cni "$HOST_CNI_BIN_PATH"
-install egress-v4-cni "$HOST_CNI_BIN_PATH"
-
 log_in_json info "Starting IPAM daemon in the background ... "
 ./aws-k8s-agent | tee -i "$AGENT_LOG_PATH" 2>&1 &
 
@@ -174,32 +165,13 @@ if ! wait_for_ipam; then
 fi
 
 get_node_primary_v4_address
-log_in_json info "Copying config file ... "
-
-# modify the static config to populate it with the env vars
-sed \
-  -e s~__VETHPREFIX__~"${AWS_VPC_K8S_CNI_VETHPREFIX}"~g \
-  -e s~__MTU__~"${AWS_VPC_ENI_MTU}"~g \
-  -e s~__PODSGENFORCINGMODE__~"${POD_SECURITY_GROUP_ENFORCING_MODE}"~g \
-  -e s~__PLUGINLOGFILE__~"${AWS_VPC_K8S_PLUGIN_LOG_FILE}"~g \
-  -e s~__PLUGINLOGLEVEL__~"${AWS_VPC_K8S_PLUGIN_LOG_LEVEL}"~g \
-  -e s~__EGRESSV4PLUGINLOGFILE__~"${AWS_VPC_K8S_EGRESS_V4_PLUGIN_LOG_FILE}"~g \
-  -e s~__EGRESSV4PLUGINENABLED__~"${ENABLE_IPv6}"~g \
-  -e s~__RANDOMIZESNAT__~"${AWS_VPC_K8S_CNI_RANDOMIZESNAT}"~g \
-  -e s~__NODEIP__~"${NODE_IP}"~g \
-  10-aws.conflist > "$TMP_AWS_CONFLIST_FILE"
-
-if [[ "$ENABLE_BANDWIDTH_PLUGIN" == "true" ]]; then
-    jq '.plugins += [{"type": "bandwidth","capabilities": {"bandwidth": true}}]' "$TMP_AWS_CONFLIST_FILE" > "$TMP_AWS_BW_CONFLIST_FILE"
-    mv "$TMP_AWS_BW_CONFLIST_FILE" "$TMP_AWS_CONFLIST_FILE" 
-fi
+log_in_json info "Installing CNI plugin binaries and config file ... "
 
-mv "$TMP_AWS_CONFLIST_FILE" "$HOST_CNI_CONFDIR_PATH/10-aws.conflist"
-
-log_in_json info "Successfully copied CNI plugin binary and config file."
-
-if [[ -f "$HOST_CNI_CONFDIR_PATH/aws.conf" ]]; then
-    rm "$HOST_CNI_CONFDIR_PATH/aws.conf"
+# cni-installer replaces each file atomically, and rolls back to the previous files if ipamd isn't healthy with them.
+# If there is no init container, it also installs the upstream plugins.
+if ! NODE_IP="${NODE_IP}" ./cni-installer; then
+    log_in_json error "Failed to install the CNI plugin binaries and config file"
+    exit 1
 fi
 
 # Bring the aws-k8s-agent process back into the foreground
@@ -25,6 +25,7 @@ import (
 	"time"
 
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/awsutils"
+	"github.com/aws/amazon-vpc-cni-k8s/pkg/maxpods"
 	"github.com/aws/amazon-vpc-cni-k8s/pkg/utils/logger"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -46,7 +47,7 @@ func printMapLine(instanceType string, l awsutils.InstanceTypeLimits) string {
 
 // Helper to calculate the --max-pods to match the ENIs and IPs on the instance
 func printPodLimit(instanceType string, l awsutils.InstanceTypeLimits) string {
-	maxPods := l.ENILimit*(l.IPv4Limit-1) + 2
+	maxPods := maxpods.Calculate(maxpods.Options{ENILimit: l.ENILimit, IPv4Limit: l.IPv4Limit})
 	return fmt.Sprintf("%s %d", instanceType, maxPods)
 }
 
@@ -164,10 +165,11 @@ func describeInstanceTypes(region string, eniLimitMap map[string]awsutils.Instan
 		for _, info := range output.InstanceTypes {
 			// Ignore any missing values
 			instanceType := aws.StringValue(info.InstanceType)
-			// only one network card is supported, so use the MaximumNetworkInterfaces from the default card if more than one are present
-			var eniLimit int
+			// ENIs are attached to a network card, so use the MaximumNetworkInterfaces from the default card if more than one are present
+			var eniLimit, networkCards int
 			if len(info.NetworkInfo.NetworkCards) > 1 {
 				eniLimit = int(aws.Int64Value(info.NetworkInfo.NetworkCards[*info.NetworkInfo.DefaultNetworkCardIndex].MaximumNetworkInterfaces))
+				networkCards = len(info.NetworkInfo.NetworkCards)
 			} else {
 				eniLimit = int(aws.Int64Value(info.NetworkInfo.MaximumNetworkInterfaces))
 			}
@@ -176,7 +178,7 @@ func describeInstanceTypes(region string, eniLimitMap map[string]awsutils.Instan
 			isBareMetalInstance := aws.BoolValue(info.BareMetal)
 			if instanceType != "" && eniLimit > 0 && ipv4Limit > 0 {
 				limits := awsutils.InstanceTypeLimits{ENILimit: eniLimit, IPv4Limit: ipv4Limit, HypervisorType: hypervisorType,
-					IsBareMetal: isBareMetalInstance}
+					IsBareMetal: isBareMetalInstance, NetworkCards: networkCards}
 				if existingLimits, contains := eniLimitMap[instanceType]; contains && existingLimits != limits {
 					// this should never happen
 					log.Fatalf("A previous region has different limits for instanceType=%s than region=%s", instanceType, region)
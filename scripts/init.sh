@@ -22,7 +22,7 @@ get_metadata()
     echo "$meta"
 }
 
-PLUGIN_BINS="loopback portmap bandwidth aws-cni-support.sh"
+PLUGIN_BINS="loopback portmap bandwidth"
 
 for b in $PLUGIN_BINS; do
     if [ ! -f "$b" ]; then